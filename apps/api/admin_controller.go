@@ -0,0 +1,312 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/penguintechinc/project-template/shared/apierrors"
+	"github.com/penguintechinc/project-template/shared/licensing"
+	"gorm.io/gorm"
+)
+
+const (
+	// overviewJobWindow is how far back failed jobs are counted
+	overviewJobWindow = 24 * time.Hour
+	// overviewCertWindow is how far ahead certificates count as expiring
+	overviewCertWindow = 30 * 24 * time.Hour
+	// overviewBackupWindow is how recent a backup must be to count as coverage
+	overviewBackupWindow = 24 * time.Hour
+)
+
+// AdminController handles installation-wide admin HTTP requests
+type AdminController struct {
+	db              *gorm.DB
+	license         *licensing.Client
+	controllerStale time.Duration
+}
+
+// NewAdminController creates a new admin controller. Controllers without a
+// heartbeat for controllerStale are reported stale.
+func NewAdminController(db *gorm.DB, license *licensing.Client, controllerStale time.Duration) *AdminController {
+	return &AdminController{db: db, license: license, controllerStale: controllerStale}
+}
+
+// groupCount is a single row of a grouped count query
+type groupCount struct {
+	Key   string
+	Count int64
+}
+
+// countGrouped runs a query returning (key, count) rows and collects them
+func countGrouped(db *gorm.DB, query string, args ...interface{}) (map[string]int64, error) {
+	var rows []groupCount
+	if err := db.Raw(query, args...).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.Key] = row.Count
+	}
+	return counts, nil
+}
+
+// GetOverview aggregates resource, job, certificate, backup, license, and
+// controller health for the ops dashboard. Tables owned by the manager are
+// skipped when they do not exist.
+// GET /api/v1/admin/overview
+func (ac *AdminController) GetOverview(c *gin.Context) {
+	if !requireGlobalAdmin(c) {
+		return
+	}
+
+	now := time.Now().UTC()
+	db := tenantDB(c, ac.db).WithContext(c.Request.Context())
+	overview := AdminOverviewResponse{GeneratedAt: now}
+
+	fail := func(what string, err error) {
+		log.Printf("Error building admin overview (%s): %v", what, err)
+		apierrors.AbortWithDetails(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to build overview", what)
+	}
+
+	var err error
+	res := &overview.Resources
+	if res.ByStatus, err = countGrouped(db, `
+		SELECT status AS key, COUNT(*) AS count FROM resources
+		WHERE deleted_at IS NULL GROUP BY status`); err != nil {
+		fail("resources by status", err)
+		return
+	}
+	if res.ByType, err = countGrouped(db, `
+		SELECT rt.name AS key, COUNT(*) AS count FROM resources r
+		JOIN resource_types rt ON rt.id = r.resource_type_id
+		WHERE r.deleted_at IS NULL GROUP BY rt.name`); err != nil {
+		fail("resources by type", err)
+		return
+	}
+	if res.ByTeam, err = countGrouped(db, `
+		SELECT t.name AS key, COUNT(*) AS count FROM resources r
+		JOIN teams t ON t.id = r.team_id
+		WHERE r.deleted_at IS NULL GROUP BY t.name`); err != nil {
+		fail("resources by team", err)
+		return
+	}
+	for _, n := range res.ByStatus {
+		res.Total += n
+	}
+
+	migrator := db.Migrator()
+	if migrator.HasTable("provisioning_jobs") {
+		if err := db.Raw(`
+			SELECT
+				COUNT(*) FILTER (WHERE status IN ('pending', 'running')),
+				COUNT(*) FILTER (WHERE status IN ('failed', 'rolled_back') AND created_at > ?)
+			FROM provisioning_jobs`, now.Add(-overviewJobWindow)).
+			Row().Scan(&overview.Jobs.PendingProvisioning, &overview.Jobs.FailedProvisioning); err != nil {
+			fail("provisioning jobs", err)
+			return
+		}
+	}
+
+	if migrator.HasTable("backup_jobs") {
+		if err := db.Raw(`SELECT COUNT(*) FROM backup_jobs WHERE status = 'failed' AND created_at > ?`,
+			now.Add(-overviewJobWindow)).Row().Scan(&overview.Jobs.FailedBackups); err != nil {
+			fail("backup jobs", err)
+			return
+		}
+
+		backups := &overview.Backups
+		if err := db.Raw(`
+			SELECT
+				COUNT(*),
+				COUNT(*) FILTER (WHERE EXISTS (
+					SELECT 1 FROM backup_jobs b
+					WHERE b.resource_id = r.id AND b.status = 'completed' AND b.completed_at > ?))
+			FROM resources r
+			WHERE r.deleted_at IS NULL AND r.can_backup`, now.Add(-overviewBackupWindow)).
+			Row().Scan(&backups.Eligible, &backups.Covered); err != nil {
+			fail("backup coverage", err)
+			return
+		}
+		if backups.Eligible > 0 {
+			backups.Coverage = float64(backups.Covered) / float64(backups.Eligible)
+		}
+	}
+
+	if migrator.HasTable("certificates") {
+		if err := db.Raw(`
+			SELECT
+				COUNT(*) FILTER (WHERE valid_until > ? AND valid_until <= ?),
+				COUNT(*) FILTER (WHERE valid_until <= ?)
+			FROM certificates WHERE deleted_at IS NULL`, now, now.Add(overviewCertWindow), now).
+			Row().Scan(&overview.Certificates.Expiring, &overview.Certificates.Expired); err != nil {
+			fail("certificates", err)
+			return
+		}
+	}
+
+	lic := &overview.License
+	if err := db.Model(&User{}).Where("is_active = ?", true).Count(&lic.Users).Error; err != nil {
+		fail("users", err)
+		return
+	}
+	if ac.license != nil {
+		if validation, err := ac.license.CachedValidate(); err != nil {
+			log.Printf("License validation failed for admin overview: %v", err)
+		} else {
+			lic.Valid = validation.Valid
+			lic.Tier = validation.Tier
+			lic.ExpiresAt = validation.ExpiresAt
+			lic.MaxUsers = validation.Limits.MaxUsers
+			if lic.MaxUsers > 0 {
+				lic.Utilization = float64(lic.Users) / float64(lic.MaxUsers)
+			}
+		}
+	}
+
+	fleet, err := controllerFleet(db, ac.controllerStale)
+	if err != nil {
+		fail("controllers", err)
+		return
+	}
+	overview.Controller = summarizeFleet(fleet)
+
+	c.JSON(http.StatusOK, overview)
+}
+
+// summarizeFleet counts the running controller instances and the stale ones
+// among them. The fleet is healthy while any instance is heartbeating.
+func summarizeFleet(fleet []*ControllerStatusResponse) ControllerOverview {
+	var ctrl ControllerOverview
+	for _, instance := range fleet {
+		if instance.Status == ControllerStatusStopped {
+			continue
+		}
+		ctrl.Instances++
+		if instance.Stale {
+			ctrl.Stale++
+		}
+		if ctrl.LastSeenAt == nil || instance.LastHeartbeatAt.After(*ctrl.LastSeenAt) {
+			seen := instance.LastHeartbeatAt
+			ctrl.LastSeenAt = &seen
+		}
+	}
+	ctrl.Healthy = ctrl.Instances > ctrl.Stale
+	return ctrl
+}
+
+// GetSecurityCompliance lists full lifecycle resources whose pods fall short
+// of the hardening baseline, as last reported by the K8s controller
+// GET /api/v1/admin/security-compliance?team_id=
+func (ac *AdminController) GetSecurityCompliance(c *gin.Context) {
+	if !requireGlobalAdmin(c) {
+		return
+	}
+
+	query := tenantDB(c, ac.db).Model(&Resource{}).Where("lifecycle_mode = ?", "full").Session(&gorm.Session{})
+	if teamID := c.Query("team_id"); teamID != "" {
+		query = query.Where("team_id = ?", teamID).Session(&gorm.Session{})
+	}
+
+	response := SecurityComplianceResponse{Resources: []SecurityComplianceEntry{}}
+	if err := query.Count(&response.CheckedResources).Error; err != nil {
+		log.Printf("Error counting resources: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to check security compliance")
+		return
+	}
+
+	var resources []Resource
+	if err := query.Where("jsonb_array_length(COALESCE(security_findings, '[]'::jsonb)) > 0").
+		Order("team_id, name").Find(&resources).Error; err != nil {
+		log.Printf("Error listing non-compliant resources: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to check security compliance")
+		return
+	}
+
+	for i := range resources {
+		entry := SecurityComplianceEntry{
+			ResourceID:  resources[i].ID,
+			Name:        resources[i].Name,
+			TeamID:      resources[i].TeamID,
+			Environment: resources[i].Environment,
+		}
+		// Findings that don't decode are reported as such rather than as none
+		if !decodeJSONField(resources[i].SecurityFindings, &entry.Findings, "security findings") {
+			entry.Findings = []string{"stored findings can't be read"}
+		}
+		response.Resources = append(response.Resources, entry)
+	}
+	response.NonCompliant = len(response.Resources)
+
+	c.JSON(http.StatusOK, response)
+}
+
+// consistencyRemediations are the suggested remediations of the consistency
+// audit's checks
+var consistencyRemediations = map[string]string{
+	"existence": "Reconcile the resource with POST /api/v1/resources/:id/reconcile to recreate its StatefulSet; " +
+		"its volumes are reused while their claims remain. A resource without a StatefulSet recorded never " +
+		"finished provisioning: check its provisioning jobs.",
+	"replicas": "Reconcile the resource to scale the StatefulSet back. If it was scaled by hand on purpose, " +
+		"set replicas in the resource's config instead.",
+	"image": "Reconcile the resource to roll the StatefulSet back to the expected image. Images set by hand " +
+		"are replaced on the next update of the resource.",
+	"labels": "Restore the labels with kubectl label. Without managed-by and resource-id the controller " +
+		"doesn't recognise the StatefulSet, and reconciles don't rewrite them.",
+	"secrets": "Reconcile the resource to recreate its exporter and registry pull secrets. Other secrets, " +
+		"such as those of injected containers, must be recreated by their owner; pods can't start without them.",
+}
+
+// GetConsistency returns the latest consistency report of each cluster,
+// with a suggested remediation for each discrepancy
+// GET /api/v1/admin/consistency?cluster=&team_id=&check=
+func (ac *AdminController) GetConsistency(c *gin.Context) {
+	if !requireGlobalAdmin(c) {
+		return
+	}
+
+	latest := tenantDB(c, ac.db).Model(&ConsistencyReport{}).Select("MAX(id)").Group("cluster")
+	query := tenantDB(c, ac.db).Where("id IN (?)", latest).Order("cluster")
+	if cluster := c.Query("cluster"); cluster != "" {
+		query = query.Where("cluster = ?", cluster)
+	}
+
+	var reports []*ConsistencyReport
+	if err := query.Find(&reports).Error; err != nil {
+		log.Printf("Error listing consistency reports: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to list consistency reports")
+		return
+	}
+
+	var teamID uint64
+	if v := c.Query("team_id"); v != "" {
+		teamID, _ = strconv.ParseUint(v, 10, 32)
+	}
+	check := c.Query("check")
+
+	response := []ConsistencyReportResponse{}
+	for _, report := range reports {
+		var discrepancies []ConsistencyDiscrepancy
+		if !decodeJSONField(report.Discrepancies, &discrepancies, "consistency discrepancies") {
+			response = append(response, ConsistencyReportResponse{
+				ConsistencyReport: report,
+				Discrepancies:     []ConsistencyDiscrepancyEntry{},
+				Unreadable:        true,
+			})
+			continue
+		}
+
+		entries := []ConsistencyDiscrepancyEntry{}
+		for _, d := range discrepancies {
+			if (teamID != 0 && uint64(d.TeamID) != teamID) || (check != "" && d.Check != check) {
+				continue
+			}
+			entries = append(entries, ConsistencyDiscrepancyEntry{ConsistencyDiscrepancy: d, Remediation: consistencyRemediations[d.Check]})
+		}
+		response = append(response, ConsistencyReportResponse{ConsistencyReport: report, Discrepancies: entries})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"reports": response})
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/penguintechinc/project-template/shared/apierrors"
+	"github.com/penguintechinc/project-template/shared/audit"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// AdoptionController handles adoption candidate HTTP requests
+type AdoptionController struct {
+	db *gorm.DB
+}
+
+// NewAdoptionController creates a new adoption controller
+func NewAdoptionController(db *gorm.DB) *AdoptionController {
+	return &AdoptionController{db: db}
+}
+
+// loadCandidate returns the adoption candidate named by the path
+func (ac *AdoptionController) loadCandidate(c *gin.Context) (*AdoptionCandidate, bool) {
+	var candidate AdoptionCandidate
+	if err := tenantDB(c, ac.db).First(&candidate, c.Param("id")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierrors.Abort(c, http.StatusNotFound, "adoption_candidate_not_found", "Adoption candidate not found")
+		} else {
+			log.Printf("Error retrieving adoption candidate: %v", err)
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to retrieve adoption candidate")
+		}
+		return nil, false
+	}
+	return &candidate, true
+}
+
+// ListAdoptionCandidates retrieves the StatefulSets the K8s controller
+// proposes for adoption, optionally filtered by status
+// GET /api/v1/adoption-candidates
+func (ac *AdoptionController) ListAdoptionCandidates(c *gin.Context) {
+	if !requireGlobalAdmin(c) {
+		return
+	}
+
+	query := tenantDB(c, ac.db).Order("namespace, name")
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var candidates []*AdoptionCandidate
+	if err := query.Find(&candidates).Error; err != nil {
+		log.Printf("Error listing adoption candidates: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to list adoption candidates")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"adoption_candidates": candidates})
+}
+
+// AdoptCandidate approves a candidate, creating the resource it's linked to.
+// The K8s controller labels the StatefulSet on its next discovery pass and
+// the resource becomes active; the StatefulSet isn't recreated. Adopted
+// resources are partial or monitor_only, as a full lifecycle would replace
+// the StatefulSet's spec with NEST's.
+// POST /api/v1/adoption-candidates/:id/adopt
+func (ac *AdoptionController) AdoptCandidate(c *gin.Context) {
+	if !requireGlobalAdmin(c) {
+		return
+	}
+
+	candidate, ok := ac.loadCandidate(c)
+	if !ok {
+		return
+	}
+	if candidate.ResourceID != nil {
+		apierrors.Abort(c, http.StatusConflict, "already_adopted", "The StatefulSet has already been adopted")
+		return
+	}
+
+	var req AdoptCandidateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierrors.AbortWithDetails(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Invalid request body", err.Error())
+		return
+	}
+	if req.Name == "" {
+		req.Name = candidate.Name
+	}
+	if req.LifecycleMode == "" {
+		req.LifecycleMode = "partial"
+	}
+
+	db := tenantDB(c, ac.db)
+	var team Team
+	if err := db.Where("id = ? AND deleted_at IS NULL", req.TeamID).First(&team).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierrors.Abort(c, http.StatusNotFound, "team_not_found", "Team not found")
+		} else {
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to verify team")
+		}
+		return
+	}
+
+	var resourceType ResourceType
+	if err := db.Where("name = ?", candidate.Engine).First(&resourceType).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierrors.Abort(c, http.StatusBadRequest, "unsupported_engine", "No resource type matches the StatefulSet's engine")
+		} else {
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to verify resource type")
+		}
+		return
+	}
+
+	envs, err := teamEnvironments(db, req.TeamID)
+	if err != nil {
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to load environments")
+		return
+	}
+	envIdx := 0
+	if req.Environment != "" {
+		envIdx = findEnvironment(envs, req.Environment)
+	}
+	if envIdx < 0 {
+		apierrors.Abort(c, http.StatusBadRequest, "invalid_environment", "Environment is not part of the team's pipeline")
+		return
+	}
+	env := envs[envIdx].Name
+
+	// Clients reach the StatefulSet through its governing service
+	connectionInfo := map[string]interface{}{}
+	if candidate.ServiceName != "" {
+		connectionInfo["host"] = fmt.Sprintf("%s.%s.svc.cluster.local", candidate.ServiceName, candidate.Namespace)
+	}
+	if candidate.Port > 0 {
+		connectionInfo["port"] = float64(candidate.Port)
+	}
+	if err := validateResourcePayload(resourceType.Name, connectionInfo, req.Credentials, nil); err != nil {
+		apierrors.Abort(c, http.StatusBadRequest, "invalid_payload", err.Error())
+		return
+	}
+	connInfo, _ := json.Marshal(connectionInfo)
+	creds, _ := json.Marshal(req.Credentials)
+
+	userID := c.MustGet("user_id").(uint)
+	partial := req.LifecycleMode == "partial"
+	resource := &Resource{
+		Name:               req.Name,
+		ResourceTypeID:     resourceType.ID,
+		TeamID:             req.TeamID,
+		Environment:        env,
+		Status:             "pending",
+		LifecycleMode:      req.LifecycleMode,
+		ProvisioningMethod: "kubernetes",
+		ConnectionInfo:     datatypes.JSON(connInfo),
+		Credentials:        datatypes.JSON(creds),
+		K8sNamespace:       candidate.Namespace,
+		K8sResourceName:    candidate.Name,
+		K8sResourceType:    "StatefulSet",
+		CanModifyUsers:     partial && resourceType.SupportsUserManagement,
+		CanBackup:          partial && resourceType.SupportsBackup,
+		CreatedBy:          userID,
+	}
+
+	// The candidate is claimed in the same transaction, so a StatefulSet is
+	// only ever linked to one resource
+	if !unitOfWork(c, ac.db, "Failed to adopt StatefulSet", func(tx *gorm.DB) error {
+		if err := checkResourceName(tx, resource.TeamID, resource.Environment, resource.Name, 0); err != nil {
+			return err
+		}
+		if err := tx.Create(resource).Error; err != nil {
+			return err
+		}
+		claimed := tx.Model(&AdoptionCandidate{}).
+			Where("id = ? AND resource_id IS NULL", candidate.ID).
+			Updates(map[string]interface{}{"status": "approved", "resource_id": resource.ID})
+		if claimed.Error != nil {
+			return claimed.Error
+		}
+		if claimed.RowsAffected == 0 {
+			// A concurrent request adopted the candidate first
+			return apierrors.New(http.StatusConflict, "already_adopted", "The StatefulSet has already been adopted")
+		}
+		return audit.Record(c, tx, userID, "resources", resource.ID, &resource.TeamID, nil, resource)
+	}) {
+		return
+	}
+
+	c.JSON(http.StatusCreated, resourceToResponse(resource))
+}
+
+// DismissCandidate stops a candidate from being proposed, such as for a
+// StatefulSet managed by another operator
+// POST /api/v1/adoption-candidates/:id/dismiss
+func (ac *AdoptionController) DismissCandidate(c *gin.Context) {
+	if !requireGlobalAdmin(c) {
+		return
+	}
+
+	candidate, ok := ac.loadCandidate(c)
+	if !ok {
+		return
+	}
+	if candidate.ResourceID != nil {
+		apierrors.Abort(c, http.StatusConflict, "already_adopted", "The StatefulSet has already been adopted")
+		return
+	}
+
+	if err := tenantDB(c, ac.db).Model(candidate).Update("status", "dismissed").Error; err != nil {
+		log.Printf("Error dismissing adoption candidate %d: %v", candidate.ID, err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to dismiss adoption candidate")
+		return
+	}
+
+	c.JSON(http.StatusOK, candidate)
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// Backup job statuses shared with the manager's backup_jobs table
+const (
+	backupJobPending   = "pending"
+	backupJobRunning   = "running"
+	backupJobCompleted = "completed"
+	backupJobFailed    = "failed"
+	backupJobCancelled = "cancelled"
+)
+
+// agentResource is a resource assigned to an agent with its engine
+type agentResource struct {
+	Resource
+	TypeName string
+}
+
+// agentResources loads the live resources assigned to an agent
+func agentResources(db *gorm.DB, agentID uint) ([]agentResource, error) {
+	var rows []agentResource
+	err := db.Table("resources").
+		Select("resources.*, resource_types.name AS type_name").
+		Joins("INNER JOIN resource_types ON resource_types.id = resources.resource_type_id").
+		Where("resources.agent_id = ? AND resources.deleted_at IS NULL", agentID).
+		Order("resources.id").
+		Find(&rows).Error
+	return rows, err
+}
+
+// agentResourceState builds the desired state of a resource. Users and
+// backup jobs come from the manager's resource_users and backup_jobs
+// tables, and the certificate from its certificates table, when they
+// exist. Backup jobs handed out are marked running so that they are run
+// once.
+func agentResourceState(db *gorm.DB, row *agentResource) (*AgentResourceState, error) {
+	resource := &row.Resource
+	state := &AgentResourceState{
+		ResourceID:    resource.ID,
+		Name:          resource.Name,
+		Engine:        row.TypeName,
+		LifecycleMode: resource.LifecycleMode,
+	}
+	decodeJSONField(resource.ConnectionInfo, &state.ConnectionInfo, "connection info")
+	decodeJSONField(resource.Credentials, &state.Credentials, "credentials")
+	if resource.LifecycleMode != "partial" {
+		return state, nil
+	}
+
+	if resource.CanModifyConfig {
+		var cfg map[string]interface{}
+		decodeJSONField(resource.Config, &cfg, "config")
+		state.Tuning, _ = cfg["tuning"].(map[string]interface{})
+	}
+
+	var err error
+	if resource.CanModifyUsers {
+		if state.Users, err = pendingResourceUsers(db, resource.ID); err != nil {
+			return nil, err
+		}
+	}
+	if resource.CanBackup {
+		if state.Backups, err = claimBackupJobs(db, resource.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	if resource.TLSEnabled && db.Migrator().HasTable("certificates") {
+		var certs []*AgentCertificate
+		if err := db.Raw(`SELECT id, certificate, private_key, valid_until FROM certificates
+			WHERE resource_id = ? AND deleted_at IS NULL
+			ORDER BY valid_until DESC LIMIT 1`, resource.ID).Scan(&certs).Error; err != nil {
+			return nil, fmt.Errorf("failed to load certificate: %w", err)
+		}
+		if len(certs) > 0 {
+			state.Certificate = certs[0]
+		}
+	}
+
+	return state, nil
+}
+
+// pendingResourceUsers loads the users of a resource waiting to be synced
+// from the manager's resource_users table, when it exists
+func pendingResourceUsers(db *gorm.DB, resourceID uint) ([]*AgentUser, error) {
+	if !db.Migrator().HasTable("resource_users") {
+		return nil, nil
+	}
+	var rows []struct {
+		ID           uint
+		Username     string
+		PasswordHash string
+		Roles        datatypes.JSON
+	}
+	if err := db.Raw(`SELECT id, username, password_hash, roles FROM resource_users
+		WHERE resource_id = ? AND deleted_at IS NULL AND sync_status IN ('pending', 'error')
+		ORDER BY id`, resourceID).Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to load resource users: %w", err)
+	}
+	var users []*AgentUser
+	for _, u := range rows {
+		user := &AgentUser{ID: u.ID, Username: u.Username, Password: u.PasswordHash}
+		decodeJSONField(u.Roles, &user.Roles, "user roles")
+		users = append(users, user)
+	}
+	return users, nil
+}
+
+// claimBackupJobs marks the pending backup jobs of a resource in the
+// manager's backup_jobs table running, when it exists, and returns them
+func claimBackupJobs(db *gorm.DB, resourceID uint) ([]*AgentBackupJob, error) {
+	if !db.Migrator().HasTable("backup_jobs") {
+		return nil, nil
+	}
+	var jobs []*AgentBackupJob
+	if err := db.Raw(`UPDATE backup_jobs SET status = ?, started_at = ?
+		WHERE resource_id = ? AND status = ? AND job_type <> 'restore'
+		RETURNING id, job_type`, backupJobRunning, time.Now().UTC(), resourceID, backupJobPending).
+		Scan(&jobs).Error; err != nil {
+		return nil, fmt.Errorf("failed to claim backup jobs: %w", err)
+	}
+	return jobs, nil
+}
+
+// applyAgentReport records an agent's report on one of its resources: the
+// resource's stats and error state, and the outcome of its user syncs and
+// backup jobs
+func applyAgentReport(db *gorm.DB, resource *Resource, report *AgentResourceReport) error {
+	now := time.Now().UTC()
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		query := tx.Model(&Resource{}).Where("id = ?", resource.ID)
+		var err error
+		if report.Error != "" {
+			err = query.UpdateColumns(map[string]interface{}{
+				"last_error":           report.Error,
+				"last_error_at":        now,
+				"consecutive_failures": gorm.Expr("consecutive_failures + 1"),
+			}).Error
+		} else {
+			err = query.UpdateColumns(map[string]interface{}{
+				"status":               "active",
+				"last_error":           "",
+				"last_error_at":        nil,
+				"consecutive_failures": 0,
+			}).Error
+		}
+		if err != nil {
+			return fmt.Errorf("failed to update resource: %w", err)
+		}
+
+		if report.Metrics != nil {
+			metrics, _ := json.Marshal(report.Metrics)
+			factors, _ := json.Marshal(map[string]interface{}{"factors": report.RiskFactors})
+			riskLevel := report.RiskLevel
+			if riskLevel == "" {
+				riskLevel = "low"
+			}
+			if err := tx.Create(&ResourceStats{
+				ResourceID:  resource.ID,
+				Timestamp:   now,
+				Metrics:     datatypes.JSON(metrics),
+				RiskLevel:   riskLevel,
+				RiskFactors: datatypes.JSON(factors),
+			}).Error; err != nil {
+				return fmt.Errorf("failed to store stats: %w", err)
+			}
+		}
+
+		for _, result := range report.Users {
+			if err := recordUserSync(tx, resource.ID, result, now); err != nil {
+				return err
+			}
+		}
+		for _, result := range report.Backups {
+			if err := recordBackupResult(tx, resource.ID, result, now); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// recordUserSync records the outcome of syncing a resource user
+func recordUserSync(db *gorm.DB, resourceID uint, result *AgentTaskResult, now time.Time) error {
+	status, syncError := "synced", interface{}(nil)
+	if result.Error != "" {
+		status, syncError = "error", result.Error
+	}
+	if err := db.Exec(`UPDATE resource_users
+		SET sync_status = ?, sync_error = ?, last_synced_at = CASE WHEN ? THEN ? ELSE last_synced_at END, updated_at = ?
+		WHERE id = ? AND resource_id = ?`,
+		status, syncError, result.Error == "", now, now, result.ID, resourceID).Error; err != nil {
+		return fmt.Errorf("failed to record user sync: %w", err)
+	}
+	return nil
+}
+
+// recordBackupResult records the outcome of a running backup job
+func recordBackupResult(db *gorm.DB, resourceID uint, result *AgentTaskResult, now time.Time) error {
+	status, jobError := backupJobCompleted, interface{}(nil)
+	if result.Error != "" {
+		status, jobError = backupJobFailed, result.Error
+	}
+	if err := db.Exec(`UPDATE backup_jobs
+		SET status = ?, error_message = ?, backup_location = COALESCE(NULLIF(?, ''), backup_location),
+		    backup_size_bytes = ?, completed_at = ?
+		WHERE id = ? AND resource_id = ? AND status = ?`,
+		status, jobError, result.Location, result.SizeBytes, now, result.ID, resourceID, backupJobRunning).Error; err != nil {
+		return fmt.Errorf("failed to record backup job: %w", err)
+	}
+	return nil
+}
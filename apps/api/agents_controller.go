@@ -0,0 +1,344 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/penguintechinc/project-template/shared/apierrors"
+	"github.com/penguintechinc/project-template/shared/audit"
+	"github.com/penguintechinc/project-template/shared/mtls"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// AgentController handles the nest-agent HTTP requests: registration,
+// desired state polling, and reports from agents, and fleet listing for
+// admins
+type AgentController struct {
+	db         *gorm.DB
+	staleAfter time.Duration
+	timeouts   JobTimeouts
+}
+
+// NewAgentController creates a new agent controller. Agents without a
+// report for staleAfter are reported stale, and stop backups that run past
+// their timeouts.
+func NewAgentController(db *gorm.DB, staleAfter time.Duration, timeouts JobTimeouts) *AgentController {
+	return &AgentController{db: db, staleAfter: staleAfter, timeouts: timeouts}
+}
+
+// agentIdentityPrefix starts the service name each agent is issued, such as
+// nest-agent-db01 for the agent named db01
+const agentIdentityPrefix = "nest-agent-"
+
+// agentIdentityName returns the name of the agent a service identity is
+// issued for: the internal service name nest-agent-<name>, or a SPIFFE ID
+// whose last path segment is. Other identities aren't agents.
+func agentIdentityName(identity string) (string, bool) {
+	if u, err := url.Parse(identity); err == nil && u.Scheme == mtls.URIScheme {
+		identity = path.Base(u.Path)
+	}
+	name := strings.TrimPrefix(identity, agentIdentityPrefix)
+	if name == identity || name == "" {
+		return "", false
+	}
+	return name, true
+}
+
+// callerIdentity returns the service identity of an agent's request, its
+// SPIFFE ID when it authenticated with an SVID or its internal service
+// name, and the agent name it is issued for. Agents have no static
+// credentials, so requests without either are refused, as are other
+// services' identities.
+func callerIdentity(c *gin.Context) (string, string, bool) {
+	var identity string
+	if spiffeID, ok := c.Get("workload_identity"); ok {
+		identity = spiffeID.(string)
+	} else if peer, ok := c.Get("peer_service"); ok {
+		identity = peer.(string)
+	} else {
+		apierrors.Abort(c, http.StatusUnauthorized, "client_certificate_required", "A client certificate is required")
+		return "", "", false
+	}
+	name, ok := agentIdentityName(identity)
+	if !ok {
+		apierrors.Abort(c, http.StatusForbidden, "not_an_agent", "The client certificate is not an agent's identity")
+		return "", "", false
+	}
+	return identity, name, true
+}
+
+// loadCallerAgent returns the agent named by the path, which must be the
+// agent the caller's identity is issued for, registered with that identity
+func (ac *AgentController) loadCallerAgent(c *gin.Context) (*Agent, bool) {
+	identity, name, ok := callerIdentity(c)
+	if !ok {
+		return nil, false
+	}
+	if c.Param("name") != name {
+		apierrors.Abort(c, http.StatusForbidden, apierrors.CodeForbidden, "The client certificate is issued for another agent")
+		return nil, false
+	}
+
+	var agent Agent
+	if err := tenantDB(c, ac.db).Where("name = ?", c.Param("name")).First(&agent).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierrors.Abort(c, http.StatusNotFound, apierrors.CodeNotFound, "Agent not found")
+		} else {
+			log.Printf("Error retrieving agent: %v", err)
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to retrieve agent")
+		}
+		return nil, false
+	}
+	if agent.Identity != identity {
+		apierrors.Abort(c, http.StatusForbidden, apierrors.CodeForbidden, "Agent is registered to a different identity")
+		return nil, false
+	}
+	return &agent, true
+}
+
+// RegisterAgent registers an agent, or refreshes the registration of one
+// that restarted. An agent registers under the name its identity is issued
+// for, and the name stays bound to the identity it first registered with.
+// POST /api/v1/agents/register
+func (ac *AgentController) RegisterAgent(c *gin.Context) {
+	identity, name, ok := callerIdentity(c)
+	if !ok {
+		return
+	}
+
+	var req AgentRegisterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierrors.AbortWithDetails(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Invalid request body", err.Error())
+		return
+	}
+	if req.Name != name {
+		apierrors.Abort(c, http.StatusForbidden, apierrors.CodeForbidden, "The client certificate is issued for another agent")
+		return
+	}
+
+	db := tenantDB(c, ac.db)
+	var agent Agent
+	err := db.Where("name = ?", req.Name).First(&agent).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Printf("Error retrieving agent: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to retrieve agent")
+		return
+	}
+	created := err != nil
+	if !created && agent.Identity != identity {
+		apierrors.Abort(c, http.StatusConflict, "agent_exists", "An agent with this name is registered to a different identity")
+		return
+	}
+
+	engines, _ := json.Marshal(req.Engines)
+	agent.Name = req.Name
+	agent.Identity = identity
+	agent.Hostname = req.Hostname
+	agent.Version = req.Version
+	agent.Engines = datatypes.JSON(engines)
+	agent.LastHeartbeatAt = time.Now().UTC()
+	if err := db.Save(&agent).Error; err != nil {
+		log.Printf("Error registering agent %s: %v", req.Name, err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to register agent")
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+		log.Printf("Registered agent %s on %s as %s", agent.Name, agent.Hostname, identity)
+	}
+	c.JSON(status, agent)
+}
+
+// GetDesiredState returns the desired state of the resources assigned to
+// the calling agent
+// GET /api/v1/agents/:name/desired-state
+func (ac *AgentController) GetDesiredState(c *gin.Context) {
+	agent, ok := ac.loadCallerAgent(c)
+	if !ok {
+		return
+	}
+
+	db := tenantDB(c, ac.db)
+	rows, err := agentResources(db, agent.ID)
+	if err != nil {
+		log.Printf("Error listing resources of agent %s: %v", agent.Name, err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to load agent resources")
+		return
+	}
+
+	desired := &AgentDesiredState{Agent: agent.Name, Resources: make([]*AgentResourceState, 0, len(rows))}
+	for i := range rows {
+		state, err := agentResourceState(db, &rows[i])
+		if err != nil {
+			log.Printf("Error building desired state of resource %d: %v", rows[i].ID, err)
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to load agent resources")
+			return
+		}
+		for _, job := range state.Backups {
+			job.TimeoutSeconds = int(ac.timeouts.Backup(job.JobType).Seconds())
+		}
+		desired.Resources = append(desired.Resources, state)
+	}
+
+	// The host trusts the SSH CAs of its resources' teams, so certificates
+	// for them can log in
+	cas, err := activeSSHCAs(db)
+	if err != nil {
+		log.Printf("Error loading SSH CAs for agent %s: %v", agent.Name, err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to load SSH CAs")
+		return
+	}
+	for _, ca := range cas {
+		for i := range rows {
+			if caAllowsTeam(ca.TeamIDs, rows[i].TeamID) {
+				desired.SSHCAKeys = append(desired.SSHCAKeys, ca.Certificate)
+				break
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, desired)
+}
+
+// ReportState records the calling agent's report on its resources, which
+// also serves as its heartbeat
+// POST /api/v1/agents/:name/report
+func (ac *AgentController) ReportState(c *gin.Context) {
+	agent, ok := ac.loadCallerAgent(c)
+	if !ok {
+		return
+	}
+
+	var req AgentReportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierrors.AbortWithDetails(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	db := tenantDB(c, ac.db)
+	for _, report := range req.Resources {
+		var resource Resource
+		if err := db.Where("id = ? AND agent_id = ?", report.ResourceID, agent.ID).First(&resource).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				// Reassigned or deleted since the agent polled
+				continue
+			}
+			log.Printf("Error retrieving resource %d: %v", report.ResourceID, err)
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to record agent report")
+			return
+		}
+		if err := applyAgentReport(db, &resource, report); err != nil {
+			log.Printf("Error recording report of agent %s on resource %d: %v", agent.Name, resource.ID, err)
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to record agent report")
+			return
+		}
+	}
+
+	if err := db.Model(agent).UpdateColumn("last_heartbeat_at", time.Now().UTC()).Error; err != nil {
+		log.Printf("Error recording heartbeat of agent %s: %v", agent.Name, err)
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// ListAgents retrieves the registered agents, their resource counts, and
+// their health
+// GET /api/v1/agents
+func (ac *AgentController) ListAgents(c *gin.Context) {
+	if !requireGlobalAdmin(c) {
+		return
+	}
+
+	db := tenantDB(c, ac.db)
+	var agents []*Agent
+	if err := db.Order("name").Find(&agents).Error; err != nil {
+		log.Printf("Error listing agents: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to list agents")
+		return
+	}
+
+	var counts []struct {
+		AgentID uint
+		Count   int64
+	}
+	if err := db.Model(&Resource{}).Select("agent_id, COUNT(*) AS count").
+		Where("agent_id IS NOT NULL").Group("agent_id").Scan(&counts).Error; err != nil {
+		log.Printf("Error counting agent resources: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to list agents")
+		return
+	}
+	resources := make(map[uint]int64, len(counts))
+	for _, count := range counts {
+		resources[count.AgentID] = count.Count
+	}
+
+	cutoff := time.Now().UTC().Add(-ac.staleAfter)
+	fleet := make([]*AgentStatusResponse, 0, len(agents))
+	for _, agent := range agents {
+		fleet = append(fleet, &AgentStatusResponse{
+			Agent:     agent,
+			Resources: resources[agent.ID],
+			Stale:     agent.LastHeartbeatAt.Before(cutoff),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"agents":      fleet,
+		"stale_after": ac.staleAfter.String(),
+	})
+}
+
+// DeleteAgent removes an agent's registration, so that its name can be
+// registered again by another identity. Agents still managing resources
+// can't be removed.
+// DELETE /api/v1/agents/:name
+func (ac *AgentController) DeleteAgent(c *gin.Context) {
+	if !requireGlobalAdmin(c) {
+		return
+	}
+
+	db := tenantDB(c, ac.db)
+	var agent Agent
+	if err := db.Where("name = ?", c.Param("name")).First(&agent).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierrors.Abort(c, http.StatusNotFound, apierrors.CodeNotFound, "Agent not found")
+		} else {
+			log.Printf("Error retrieving agent: %v", err)
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to retrieve agent")
+		}
+		return
+	}
+
+	var assigned int64
+	if err := db.Model(&Resource{}).Where("agent_id = ?", agent.ID).Count(&assigned).Error; err != nil {
+		log.Printf("Error counting resources of agent %s: %v", agent.Name, err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to delete agent")
+		return
+	}
+	if assigned > 0 {
+		apierrors.Abort(c, http.StatusConflict, "agent_in_use", "The agent still manages resources")
+		return
+	}
+
+	if err := db.Unscoped().Delete(&agent).Error; err != nil {
+		log.Printf("Error deleting agent %s: %v", agent.Name, err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to delete agent")
+		return
+	}
+	userID := c.MustGet("user_id").(uint)
+	if err := audit.Record(c, db, userID, "agents", agent.ID, nil, agent, nil); err != nil {
+		log.Printf("Error recording deletion of agent %d in the audit log: %v", agent.ID, err)
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
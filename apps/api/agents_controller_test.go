@@ -0,0 +1,25 @@
+package main
+
+import "testing"
+
+func TestAgentIdentityName(t *testing.T) {
+	tests := []struct {
+		identity string
+		name     string
+		ok       bool
+	}{
+		{identity: "nest-agent-db01", name: "db01", ok: true},
+		{identity: "spiffe://example.org/hosts/nest-agent-db01", name: "db01", ok: true},
+		{identity: "nest-agent"},
+		{identity: "nest-agent-"},
+		{identity: "nest-api"},
+		{identity: "nest-controller"},
+		{identity: "spiffe://example.org/ns/nest/sa/nest-controller"},
+	}
+	for _, tt := range tests {
+		name, ok := agentIdentityName(tt.identity)
+		if name != tt.name || ok != tt.ok {
+			t.Errorf("%s: expected %q %v, got %q %v", tt.identity, tt.name, tt.ok, name, ok)
+		}
+	}
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/penguintechinc/project-template/shared/database"
+	"gorm.io/gorm"
+)
+
+// Alert states
+const (
+	AlertStatePending  = "pending"
+	AlertStateFiring   = "firing"
+	AlertStateResolved = "resolved"
+)
+
+// AlertEvaluator evaluates alert rules against the latest resource stats.
+// It runs as a periodic job, and queues a notification job for each alert
+// that starts or stops firing.
+type AlertEvaluator struct {
+	db *gorm.DB
+}
+
+// NewAlertEvaluator creates a new alert evaluator
+func NewAlertEvaluator(db *gorm.DB) *AlertEvaluator {
+	return &AlertEvaluator{db: db}
+}
+
+// Evaluate runs a single evaluation pass over all enabled rules
+func (e *AlertEvaluator) Evaluate(ctx context.Context) error {
+	var rules []AlertRule
+	if err := e.db.WithContext(ctx).Where("enabled = ?", true).Find(&rules).Error; err != nil {
+		return fmt.Errorf("failed to load alert rules: %w", err)
+	}
+
+	now := time.Now()
+	for i := range rules {
+		rule := &rules[i]
+
+		resources, err := e.ruleTargets(ctx, rule)
+		if err != nil {
+			log.Printf("Failed to resolve targets for alert rule %d: %v", rule.ID, err)
+			continue
+		}
+
+		for _, resource := range resources {
+			if err := e.evaluateRule(ctx, rule, resource, now); err != nil {
+				log.Printf("Failed to evaluate alert rule %d for resource %d: %v", rule.ID, resource.ID, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// ruleTargets returns the resources a rule applies to
+func (e *AlertEvaluator) ruleTargets(ctx context.Context, rule *AlertRule) ([]Resource, error) {
+	query := e.db.WithContext(ctx).Where("team_id = ? AND deleted_at IS NULL", rule.TeamID)
+	if rule.ResourceID != nil {
+		query = query.Where("id = ?", *rule.ResourceID)
+	}
+
+	var resources []Resource
+	if err := query.Find(&resources).Error; err != nil {
+		return nil, err
+	}
+	return resources, nil
+}
+
+// evaluateRule checks a single rule against a resource's latest stats and
+// advances the alert through pending -> firing -> resolved
+func (e *AlertEvaluator) evaluateRule(ctx context.Context, rule *AlertRule, resource Resource, now time.Time) error {
+	var stats ResourceStats
+	if err := e.db.WithContext(ctx).Where("resource_id = ? AND timestamp >= ?", resource.ID, now.Add(-defaultStatsLookback)).
+		Order("timestamp DESC").
+		First(&stats).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	var metrics map[string]interface{}
+	if err := json.Unmarshal(stats.Metrics, &metrics); err != nil {
+		return fmt.Errorf("failed to parse metrics: %w", err)
+	}
+
+	value, ok := metricValue(metrics, rule.Metric)
+	if !ok {
+		return nil
+	}
+	breached := compareThreshold(rule.Operator, value, rule.Threshold)
+
+	var alert Alert
+	err := e.db.WithContext(ctx).
+		Where("alert_rule_id = ? AND resource_id = ? AND state IN ?", rule.ID, resource.ID,
+			[]string{AlertStatePending, AlertStateFiring}).
+		First(&alert).Error
+	hasOpen := err == nil
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	switch {
+	case breached && !hasOpen:
+		alert = Alert{
+			AlertRuleID:     rule.ID,
+			ResourceID:      resource.ID,
+			TeamID:          resource.TeamID,
+			State:           AlertStatePending,
+			Severity:        rule.Severity,
+			Value:           value,
+			Message:         alertMessage(rule, resource, value),
+			StartedAt:       now,
+			LastEvaluatedAt: now,
+		}
+		if rule.DurationSeconds == 0 {
+			alert.State = AlertStateFiring
+			alert.FiredAt = &now
+		}
+		if err := e.db.WithContext(ctx).Create(&alert).Error; err != nil {
+			return err
+		}
+		if alert.State == AlertStateFiring {
+			e.notify(ctx, rule, &alert)
+		}
+
+	case breached && hasOpen:
+		alert.Value = value
+		alert.Message = alertMessage(rule, resource, value)
+		alert.LastEvaluatedAt = now
+		promote := alert.State == AlertStatePending &&
+			now.Sub(alert.StartedAt) >= time.Duration(rule.DurationSeconds)*time.Second
+		if promote {
+			alert.State = AlertStateFiring
+			alert.FiredAt = &now
+		}
+		if err := e.db.WithContext(ctx).Save(&alert).Error; err != nil {
+			return err
+		}
+		if promote {
+			e.notify(ctx, rule, &alert)
+		}
+
+	case !breached && hasOpen:
+		wasFiring := alert.State == AlertStateFiring
+		alert.State = AlertStateResolved
+		alert.Value = value
+		alert.ResolvedAt = &now
+		alert.LastEvaluatedAt = now
+		if err := e.db.WithContext(ctx).Save(&alert).Error; err != nil {
+			return err
+		}
+		// Pending alerts never notified, so resolving them is silent
+		if wasFiring {
+			e.notify(ctx, rule, &alert)
+		}
+	}
+
+	return nil
+}
+
+// notify queues the delivery of an alert state change through the
+// notification subsystem
+func (e *AlertEvaluator) notify(ctx context.Context, rule *AlertRule, alert *Alert) {
+	n := Notification{
+		Event:      "alert." + alert.State,
+		Severity:   alert.Severity,
+		Title:      fmt.Sprintf("[%s] %s", strings.ToUpper(alert.State), rule.Name),
+		Message:    alert.Message,
+		TeamID:     alert.TeamID,
+		ResourceID: alert.ResourceID,
+		Details: map[string]interface{}{
+			"alert_id":      alert.ID,
+			"alert_rule_id": rule.ID,
+			"metric":        rule.Metric,
+			"operator":      rule.Operator,
+			"threshold":     rule.Threshold,
+			"value":         alert.Value,
+		},
+		Timestamp: time.Now(),
+		Tenant:    database.TenantSchema(ctx),
+	}
+
+	if _, err := EnqueueJob(e.db.WithContext(ctx), JobRequest{Kind: JobNotification, Payload: n, Priority: JobPriorityHigh}); err != nil {
+		log.Printf("Failed to queue notification for alert %d: %v", alert.ID, err)
+	}
+}
+
+// alertMessage builds a human-readable description of an alert condition
+func alertMessage(rule *AlertRule, resource Resource, value float64) string {
+	return fmt.Sprintf("%s on resource %q is %g (%s %g)",
+		rule.Metric, resource.Name, value, rule.Operator, rule.Threshold)
+}
+
+// metricValue resolves a dot-separated path (e.g. "connections.active") in a
+// metrics document to a numeric value
+func metricValue(metrics map[string]interface{}, path string) (float64, bool) {
+	var current interface{} = metrics
+	for _, part := range strings.Split(path, ".") {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return 0, false
+		}
+		current, ok = m[part]
+		if !ok {
+			return 0, false
+		}
+	}
+
+	switch v := current.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	}
+
+	return 0, false
+}
+
+// compareThreshold reports whether value breaches threshold under the operator
+func compareThreshold(operator string, value, threshold float64) bool {
+	switch operator {
+	case "gt":
+		return value > threshold
+	case "gte":
+		return value >= threshold
+	case "lt":
+		return value < threshold
+	case "lte":
+		return value <= threshold
+	case "eq":
+		return value == threshold
+	case "ne":
+		return value != threshold
+	}
+	return false
+}
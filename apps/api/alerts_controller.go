@@ -0,0 +1,292 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/penguintechinc/project-template/shared/apierrors"
+	"github.com/penguintechinc/project-template/shared/pagination"
+	"gorm.io/gorm"
+)
+
+// AlertController handles alert and alert rule HTTP requests
+type AlertController struct {
+	db     *gorm.DB
+	access *AccessCache
+}
+
+// NewAlertController creates a new alert controller
+func NewAlertController(db *gorm.DB, access *AccessCache) *AlertController {
+	return &AlertController{db: db, access: access}
+}
+
+// ListAlerts retrieves alerts for resources visible to the current user
+// GET /api/v1/alerts
+func (ac *AlertController) ListAlerts(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "User context not found")
+		return
+	}
+
+	page, pageSize := pagination.Parse(c, 20, 100)
+
+	// Alerts are scoped by the user's team membership
+	query := tenantDB(c, ac.db).Where("alerts.deleted_at IS NULL").
+		Joins("INNER JOIN team_members ON alerts.team_id = team_members.team_id").
+		Where("team_members.user_id = ?", userID.(uint))
+
+	if state := c.Query("state"); state != "" {
+		query = query.Where("alerts.state = ?", state)
+	}
+
+	if severity := c.Query("severity"); severity != "" {
+		query = query.Where("alerts.severity = ?", severity)
+	}
+
+	if resourceID := c.Query("resource_id"); resourceID != "" {
+		if rid, err := strconv.ParseUint(resourceID, 10, 32); err == nil {
+			query = query.Where("alerts.resource_id = ?", uint(rid))
+		}
+	}
+
+	if teamID := c.Query("team_id"); teamID != "" {
+		if tid, err := strconv.ParseUint(teamID, 10, 32); err == nil {
+			query = query.Where("alerts.team_id = ?", uint(tid))
+		}
+	}
+
+	var total int64
+	countQuery := query
+	if err := countQuery.Model(&Alert{}).Count(&total).Error; err != nil {
+		log.Printf("Error counting alerts: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to count alerts")
+		return
+	}
+
+	offset := (page - 1) * pageSize
+	var alerts []*Alert
+	if err := query.Preload("AlertRule").
+		Offset(offset).Limit(pageSize).
+		Order("alerts.started_at DESC").
+		Find(&alerts).Error; err != nil {
+		log.Printf("Error listing alerts: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to list alerts")
+		return
+	}
+
+	pagination.SetHeaders(c, page, pageSize, total)
+	c.JSON(http.StatusOK, AlertListResponse{
+		Alerts:   alerts,
+		Total:    total,
+		Page:     page,
+		PageSize: pageSize,
+	})
+}
+
+// ListAlertRules retrieves alert rules for teams the current user belongs to
+// GET /api/v1/alert-rules
+func (ac *AlertController) ListAlertRules(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "User context not found")
+		return
+	}
+
+	query := tenantDB(c, ac.db).Where("alert_rules.deleted_at IS NULL").
+		Joins("INNER JOIN team_members ON alert_rules.team_id = team_members.team_id").
+		Where("team_members.user_id = ?", userID.(uint))
+
+	if resourceID := c.Query("resource_id"); resourceID != "" {
+		if rid, err := strconv.ParseUint(resourceID, 10, 32); err == nil {
+			query = query.Where("alert_rules.resource_id = ?", uint(rid))
+		}
+	}
+
+	var rules []*AlertRule
+	if err := query.Order("alert_rules.created_at DESC").Find(&rules).Error; err != nil {
+		log.Printf("Error listing alert rules: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to list alert rules")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"alert_rules": rules})
+}
+
+// CreateAlertRule creates a new alert rule for a team or a single resource
+// POST /api/v1/alert-rules
+func (ac *AlertController) CreateAlertRule(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "User context not found")
+		return
+	}
+
+	var req CreateAlertRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierrors.AbortWithDetails(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	// Resource-scoped rules inherit the resource's team
+	teamID := req.TeamID
+	if req.ResourceID != nil {
+		var resource Resource
+		if err := tenantDB(c, ac.db).Where("id = ? AND deleted_at IS NULL", *req.ResourceID).First(&resource).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				apierrors.Abort(c, http.StatusNotFound, "resource_not_found", "Resource not found")
+			} else {
+				apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to verify resource")
+			}
+			return
+		}
+		teamID = resource.TeamID
+	}
+
+	if teamID == 0 {
+		apierrors.Abort(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Either team_id or resource_id is required")
+		return
+	}
+
+	if !ac.canManageTeamRules(c, userID.(uint), teamID) {
+		return
+	}
+
+	rule := &AlertRule{
+		Name:            req.Name,
+		Description:     req.Description,
+		TeamID:          teamID,
+		ResourceID:      req.ResourceID,
+		Metric:          req.Metric,
+		Operator:        req.Operator,
+		Threshold:       req.Threshold,
+		DurationSeconds: req.DurationSeconds,
+		Severity:        req.Severity,
+		Enabled:         true,
+		CreatedBy:       userID.(uint),
+	}
+
+	if err := tenantDB(c, ac.db).Create(rule).Error; err != nil {
+		log.Printf("Error creating alert rule: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to create alert rule")
+		return
+	}
+
+	c.JSON(http.StatusCreated, rule)
+}
+
+// UpdateAlertRule updates an existing alert rule
+// PUT /api/v1/alert-rules/:id
+func (ac *AlertController) UpdateAlertRule(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "User context not found")
+		return
+	}
+
+	rule, ok := ac.loadRule(c)
+	if !ok {
+		return
+	}
+
+	if !ac.canManageTeamRules(c, userID.(uint), rule.TeamID) {
+		return
+	}
+
+	var req UpdateAlertRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierrors.AbortWithDetails(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	if req.Name != nil {
+		rule.Name = *req.Name
+	}
+	if req.Description != nil {
+		rule.Description = *req.Description
+	}
+	if req.Operator != nil {
+		rule.Operator = *req.Operator
+	}
+	if req.Threshold != nil {
+		rule.Threshold = *req.Threshold
+	}
+	if req.DurationSeconds != nil {
+		rule.DurationSeconds = *req.DurationSeconds
+	}
+	if req.Severity != nil {
+		rule.Severity = *req.Severity
+	}
+	if req.Enabled != nil {
+		rule.Enabled = *req.Enabled
+	}
+
+	if err := tenantDB(c, ac.db).Save(rule).Error; err != nil {
+		log.Printf("Error updating alert rule: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to update alert rule")
+		return
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+// DeleteAlertRule deletes an alert rule
+// DELETE /api/v1/alert-rules/:id
+func (ac *AlertController) DeleteAlertRule(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "User context not found")
+		return
+	}
+
+	rule, ok := ac.loadRule(c)
+	if !ok {
+		return
+	}
+
+	if !ac.canManageTeamRules(c, userID.(uint), rule.TeamID) {
+		return
+	}
+
+	if err := tenantDB(c, ac.db).Delete(rule).Error; err != nil {
+		log.Printf("Error deleting alert rule: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to delete alert rule")
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// loadRule fetches the alert rule named by the :id path parameter
+func (ac *AlertController) loadRule(c *gin.Context) (*AlertRule, bool) {
+	var rule AlertRule
+	if err := tenantDB(c, ac.db).Where("id = ? AND deleted_at IS NULL", c.Param("id")).First(&rule).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierrors.Abort(c, http.StatusNotFound, "alert_rule_not_found", "Alert rule not found")
+		} else {
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to retrieve alert rule")
+		}
+		return nil, false
+	}
+	return &rule, true
+}
+
+// canManageTeamRules verifies the user is a global admin or a team maintainer,
+// writing a 403 response if not
+func (ac *AlertController) canManageTeamRules(c *gin.Context, userID, teamID uint) bool {
+	userRole, _ := c.Get("user_role")
+	if hasMinimumRole(userRole, "admin") {
+		return true
+	}
+
+	role, isMember, err := ac.access.TeamRole(c.Request.Context(), userID, teamID)
+	if err != nil || !isMember || !hasMinimumRole(role, "maintainer") {
+		apierrors.Abort(c, http.StatusForbidden, apierrors.CodeForbidden, "Insufficient permissions to manage alert rules for this team")
+		return false
+	}
+
+	return true
+}
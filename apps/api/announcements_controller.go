@@ -0,0 +1,224 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/penguintechinc/project-template/shared/apierrors"
+	"github.com/penguintechinc/project-template/shared/audit"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// announcementOrder shows critical announcements first, then the newest
+const announcementOrder = `CASE severity WHEN 'critical' THEN 0 WHEN 'warning' THEN 1 ELSE 2 END, starts_at DESC, id DESC`
+
+// AnnouncementController handles organization-wide announcements: admins
+// publish them, and every user reads and acknowledges the active ones
+type AnnouncementController struct {
+	db *gorm.DB
+}
+
+// NewAnnouncementController creates a new announcement controller
+func NewAnnouncementController(db *gorm.DB) *AnnouncementController {
+	return &AnnouncementController{db: db}
+}
+
+// loadAnnouncement writes the error response when the announcement of the
+// :id path parameter can't be loaded
+func (ac *AnnouncementController) loadAnnouncement(c *gin.Context) (*Announcement, bool) {
+	var announcement Announcement
+	if err := tenantDB(c, ac.db).First(&announcement, c.Param("id")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierrors.Abort(c, http.StatusNotFound, apierrors.CodeNotFound, "Announcement not found")
+		} else {
+			log.Printf("Error retrieving announcement: %v", err)
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to retrieve announcement")
+		}
+		return nil, false
+	}
+	return &announcement, true
+}
+
+// bindAnnouncement applies an announcement request, writing the error
+// response when it is invalid
+func bindAnnouncement(c *gin.Context, announcement *Announcement) bool {
+	var req AnnouncementRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierrors.AbortWithDetails(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Invalid request body", err.Error())
+		return false
+	}
+
+	startsAt := time.Now().UTC()
+	if req.StartsAt != nil {
+		startsAt = req.StartsAt.UTC()
+	}
+	if req.EndsAt != nil && !req.EndsAt.After(startsAt) {
+		apierrors.Abort(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "ends_at must be after starts_at")
+		return false
+	}
+
+	announcement.Title = req.Title
+	announcement.Message = req.Message
+	announcement.Severity = req.Severity
+	announcement.StartsAt = startsAt
+	announcement.EndsAt = req.EndsAt
+	return true
+}
+
+// ListActiveAnnouncements lists the announcements being shown now, critical
+// first, with whether the caller has acknowledged each.
+// unacknowledged=true leaves out those they have.
+// GET /api/v1/announcements
+func (ac *AnnouncementController) ListActiveAnnouncements(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "User context not found")
+		return
+	}
+
+	now := time.Now().UTC()
+	query := tenantDB(c, ac.db).Model(&Announcement{}).
+		Select("announcements.*, aa.id IS NOT NULL AS acknowledged").
+		Joins("LEFT JOIN announcement_acks aa ON aa.announcement_id = announcements.id AND aa.user_id = ? AND aa.deleted_at IS NULL", userID.(uint)).
+		Where("announcements.starts_at <= ? AND (announcements.ends_at IS NULL OR announcements.ends_at > ?)", now, now)
+	if c.Query("unacknowledged") == "true" {
+		query = query.Where("aa.id IS NULL")
+	}
+
+	announcements := []ActiveAnnouncement{}
+	if err := query.Order(announcementOrder).Scan(&announcements).Error; err != nil {
+		log.Printf("Error listing announcements: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to list announcements")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"announcements": announcements})
+}
+
+// AcknowledgeAnnouncement records that the caller has seen an announcement.
+// Acknowledging one again changes nothing.
+// POST /api/v1/announcements/:id/acknowledge
+func (ac *AnnouncementController) AcknowledgeAnnouncement(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "User context not found")
+		return
+	}
+	announcement, ok := ac.loadAnnouncement(c)
+	if !ok {
+		return
+	}
+
+	ack := &AnnouncementAck{AnnouncementID: announcement.ID, UserID: userID.(uint)}
+	if err := tenantDB(c, ac.db).Clauses(clause.OnConflict{DoNothing: true}).Create(ack).Error; err != nil {
+		log.Printf("Error acknowledging announcement: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to acknowledge announcement")
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// ListAnnouncements lists every announcement, including scheduled and
+// expired ones
+// GET /api/v1/admin/announcements
+func (ac *AnnouncementController) ListAnnouncements(c *gin.Context) {
+	if !requireGlobalAdmin(c) {
+		return
+	}
+
+	announcements := []*Announcement{}
+	if err := tenantDB(c, ac.db).Order("starts_at DESC, id DESC").Find(&announcements).Error; err != nil {
+		log.Printf("Error listing announcements: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to list announcements")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"announcements": announcements})
+}
+
+// CreateAnnouncement publishes an announcement
+// POST /api/v1/admin/announcements
+func (ac *AnnouncementController) CreateAnnouncement(c *gin.Context) {
+	if !requireGlobalAdmin(c) {
+		return
+	}
+	userID, _ := c.Get("user_id")
+
+	announcement := &Announcement{CreatedBy: userID.(uint)}
+	if !bindAnnouncement(c, announcement) {
+		return
+	}
+
+	if !unitOfWork(c, ac.db, "Failed to create announcement", func(tx *gorm.DB) error {
+		if err := tx.Create(announcement).Error; err != nil {
+			return err
+		}
+		return audit.Record(c, tx, userID.(uint), "announcements", announcement.ID, nil, nil, announcement)
+	}) {
+		return
+	}
+
+	c.JSON(http.StatusCreated, announcement)
+}
+
+// UpdateAnnouncement replaces an announcement. Users who acknowledged it
+// stay acknowledged.
+// PUT /api/v1/admin/announcements/:id
+func (ac *AnnouncementController) UpdateAnnouncement(c *gin.Context) {
+	if !requireGlobalAdmin(c) {
+		return
+	}
+	userID, _ := c.Get("user_id")
+	announcement, ok := ac.loadAnnouncement(c)
+	if !ok {
+		return
+	}
+	before := *announcement
+
+	if !bindAnnouncement(c, announcement) {
+		return
+	}
+
+	if !unitOfWork(c, ac.db, "Failed to update announcement", func(tx *gorm.DB) error {
+		if err := tx.Save(announcement).Error; err != nil {
+			return err
+		}
+		return audit.Record(c, tx, userID.(uint), "announcements", announcement.ID, nil, &before, announcement)
+	}) {
+		return
+	}
+
+	c.JSON(http.StatusOK, announcement)
+}
+
+// DeleteAnnouncement removes an announcement and its acknowledgements
+// DELETE /api/v1/admin/announcements/:id
+func (ac *AnnouncementController) DeleteAnnouncement(c *gin.Context) {
+	if !requireGlobalAdmin(c) {
+		return
+	}
+	userID, _ := c.Get("user_id")
+	announcement, ok := ac.loadAnnouncement(c)
+	if !ok {
+		return
+	}
+
+	if !unitOfWork(c, ac.db, "Failed to delete announcement", func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("announcement_id = ?", announcement.ID).Delete(&AnnouncementAck{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Delete(announcement).Error; err != nil {
+			return err
+		}
+		return audit.Record(c, tx, userID.(uint), "announcements", announcement.ID, nil, announcement, nil)
+	}) {
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/penguintechinc/project-template/shared/database"
+	"gorm.io/gorm"
+)
+
+// auditChainHashFunction computes an audit log entry's hash from the hash of
+// the entry before it and every field of the entry. Fields are separated so
+// that moving text between them changes the hash. Personal data enters only
+// through its digest, so it can be erased without breaking the chain.
+const auditChainHashFunction = `CREATE OR REPLACE FUNCTION nest_audit_entry_hash(
+	prev text, seq bigint, entry_id bigint, user_id bigint, action text, resource_type text,
+	resource_id bigint, team_id bigint, details jsonb, pii_digest text, ts timestamptz
+) RETURNS text AS $$
+	SELECT encode(sha256(convert_to(concat_ws(chr(31),
+		prev, seq::text, entry_id::text, COALESCE(user_id::text, ''), action, COALESCE(resource_type, ''),
+		COALESCE(resource_id::text, ''), COALESCE(team_id::text, ''), COALESCE(details::text, ''),
+		COALESCE(pii_digest, ''), to_char(ts AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS.US')
+	), 'UTF8')), 'hex')
+$$ LANGUAGE sql IMMUTABLE`
+
+// auditPIIDigestFunction digests the personal data of an audit log entry
+const auditPIIDigestFunction = `CREATE OR REPLACE FUNCTION nest_audit_pii_digest(ip_address text, user_agent text)
+RETURNS text AS $$
+	SELECT encode(sha256(convert_to(concat_ws(chr(31), COALESCE(ip_address, ''), COALESCE(user_agent, '')), 'UTF8')), 'hex')
+$$ LANGUAGE sql IMMUTABLE`
+
+// auditChainTriggerFunction links each new entry to the head of its
+// schema's chain. The advisory lock serializes inserts, so every entry sees
+// the one committed before it; ids can't order the chain because they are
+// assigned before the lock is taken.
+const auditChainTriggerFunction = `CREATE OR REPLACE FUNCTION nest_audit_chain() RETURNS trigger AS $$
+DECLARE
+	last_seq bigint;
+	last_hash text;
+BEGIN
+	PERFORM pg_advisory_xact_lock(hashtext(TG_TABLE_SCHEMA || '.audit_logs'));
+	EXECUTE format('SELECT chain_seq, entry_hash FROM %I.audit_logs WHERE chain_seq IS NOT NULL ORDER BY chain_seq DESC LIMIT 1', TG_TABLE_SCHEMA)
+		INTO last_seq, last_hash;
+	NEW.chain_seq := COALESCE(last_seq, 0) + 1;
+	NEW.prev_hash := COALESCE(last_hash, '');
+	NEW.pii_digest := nest_audit_pii_digest(NEW.ip_address, NEW.user_agent);
+	NEW.erased_at := NULL;
+	NEW.entry_hash := nest_audit_entry_hash(NEW.prev_hash, NEW.chain_seq, NEW.id, NEW.user_id, NEW.action,
+		NEW.resource_type, NEW.resource_id, NEW.team_id, NEW.details, NEW.pii_digest, NEW.timestamp);
+	RETURN NEW;
+END
+$$ LANGUAGE plpgsql`
+
+// auditImmutableFunction rejects changes to recorded entries, except
+// erasure of their personal data: clearing ip_address and user_agent while
+// setting erased_at. Deletes stay allowed so retention policies can prune
+// old entries; verification starts from the oldest entry that remains.
+const auditImmutableFunction = `CREATE OR REPLACE FUNCTION nest_audit_immutable() RETURNS trigger AS $$
+BEGIN
+	IF NEW.erased_at IS NOT NULL AND COALESCE(NEW.ip_address, '') = '' AND COALESCE(NEW.user_agent, '') = ''
+		AND (NEW.id, NEW.chain_seq, NEW.prev_hash, NEW.entry_hash, NEW.pii_digest, NEW.user_id, NEW.action,
+			NEW.resource_type, NEW.resource_id, NEW.team_id, NEW.details, NEW.timestamp)
+		IS NOT DISTINCT FROM (OLD.id, OLD.chain_seq, OLD.prev_hash, OLD.entry_hash, OLD.pii_digest, OLD.user_id, OLD.action,
+			OLD.resource_type, OLD.resource_id, OLD.team_id, OLD.details, OLD.timestamp) THEN
+		RETURN NEW;
+	END IF;
+	RAISE EXCEPTION 'audit log entries are immutable';
+END
+$$ LANGUAGE plpgsql`
+
+// auditChainVerifyBatchSize bounds the entries verified per query
+const auditChainVerifyBatchSize = 1000
+
+// maxAuditChainProblems bounds the problems a verification reports
+const maxAuditChainProblems = 100
+
+// EnableAuditChain installs the hash chain and immutability triggers on the
+// audit_logs table of the connection's current schema. Entries written by
+// the API and the K8s controller alike are chained by the trigger. It does
+// nothing when the schema has no audit_logs table.
+func EnableAuditChain(ctx context.Context, db *gorm.DB) error {
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var exists bool
+		if err := tx.Raw("SELECT to_regclass(quote_ident(current_schema()) || '.audit_logs') IS NOT NULL").
+			Scan(&exists).Error; err != nil {
+			return err
+		}
+		if !exists {
+			return nil
+		}
+
+		statements := []string{
+			auditChainHashFunction,
+			auditPIIDigestFunction,
+			auditChainTriggerFunction,
+			auditImmutableFunction,
+			"DROP TRIGGER IF EXISTS nest_audit_chain ON audit_logs",
+			"CREATE TRIGGER nest_audit_chain BEFORE INSERT ON audit_logs FOR EACH ROW EXECUTE FUNCTION nest_audit_chain()",
+			"DROP TRIGGER IF EXISTS nest_audit_immutable ON audit_logs",
+			"CREATE TRIGGER nest_audit_immutable BEFORE UPDATE ON audit_logs FOR EACH ROW EXECUTE FUNCTION nest_audit_immutable()",
+		}
+		for _, stmt := range statements {
+			if err := tx.Exec(stmt).Error; err != nil {
+				return fmt.Errorf("failed to enable audit log chaining: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// auditChainRow is an entry's chain fields and the hash recomputed from its
+// stored fields
+type auditChainRow struct {
+	ID        uint
+	ChainSeq  int64
+	PrevHash  string
+	EntryHash string
+	Computed  string
+	PIIValid  bool
+	Erased    bool
+}
+
+// VerifyAuditChain walks the audit log hash chain of the connection's
+// schema. An entry whose recomputed hash differs was modified; a gap in the
+// sequence or a PrevHash that doesn't match the entry before means entries
+// were removed or reordered. The newest anchor must still match its entry,
+// which catches the chain being rewritten from some point on.
+func VerifyAuditChain(ctx context.Context, db *gorm.DB) (*AuditChainReport, error) {
+	db = db.WithContext(ctx)
+	report := &AuditChainReport{Problems: []AuditChainProblem{}}
+	problem := func(id uint, seq int64, format string, args ...interface{}) {
+		if len(report.Problems) < maxAuditChainProblems {
+			report.Problems = append(report.Problems, AuditChainProblem{EntryID: id, ChainSeq: seq, Problem: fmt.Sprintf(format, args...)})
+		}
+	}
+
+	if err := db.Model(&database.AuditLog{}).Where("chain_seq IS NULL").Count(&report.Unchained).Error; err != nil {
+		return nil, err
+	}
+
+	var last *auditChainRow
+	for {
+		after := int64(0)
+		if last != nil {
+			after = last.ChainSeq
+		}
+		var rows []auditChainRow
+		if err := db.Raw(`SELECT id, chain_seq, prev_hash, entry_hash,
+			nest_audit_entry_hash(prev_hash, chain_seq, id, user_id, action, resource_type,
+				resource_id, team_id, details, pii_digest, timestamp) AS computed,
+			pii_digest = nest_audit_pii_digest(ip_address, user_agent) AS pii_valid,
+			erased_at IS NOT NULL AS erased
+			FROM audit_logs WHERE chain_seq > ? ORDER BY chain_seq ASC LIMIT ?`,
+			after, auditChainVerifyBatchSize).Scan(&rows).Error; err != nil {
+			return nil, err
+		}
+
+		for i := range rows {
+			row := &rows[i]
+			if row.Computed != row.EntryHash {
+				problem(row.ID, row.ChainSeq, "entry was modified: hash does not match its contents")
+			}
+			if !row.Erased && !row.PIIValid {
+				problem(row.ID, row.ChainSeq, "entry's IP address or user agent was modified")
+			}
+			if row.Erased {
+				report.Erased++
+			}
+			if last == nil {
+				report.FirstSeq = row.ChainSeq
+			} else {
+				if row.ChainSeq != last.ChainSeq+1 {
+					problem(row.ID, row.ChainSeq, "%d entries missing after chain_seq %d", row.ChainSeq-last.ChainSeq-1, last.ChainSeq)
+				}
+				if row.PrevHash != last.EntryHash {
+					problem(row.ID, row.ChainSeq, "prev_hash does not match the hash of chain_seq %d", last.ChainSeq)
+				}
+			}
+			last = row
+			report.Verified++
+		}
+		if len(rows) < auditChainVerifyBatchSize {
+			break
+		}
+	}
+	if last != nil {
+		report.HeadSeq = last.ChainSeq
+		report.HeadHash = last.EntryHash
+	}
+
+	var anchor AuditAnchor
+	err := db.Order("chain_seq DESC").First(&anchor).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if err == nil {
+		report.LastAnchor = &anchor
+		var anchored database.AuditLog
+		err := db.Where("chain_seq = ?", anchor.ChainSeq).First(&anchored).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			if anchor.ChainSeq > report.HeadSeq {
+				problem(0, anchor.ChainSeq, "anchored entry is missing: the chain was truncated")
+			}
+		case err != nil:
+			return nil, err
+		case anchored.EntryHash != anchor.EntryHash:
+			problem(anchored.ID, anchor.ChainSeq, "entry hash differs from the hash anchored at %s", anchor.Location)
+		}
+	}
+
+	report.Valid = len(report.Problems) == 0
+	return report, nil
+}
+
+// auditAnchorDocument is the object written to external storage for an anchor
+type auditAnchorDocument struct {
+	Schema     string    `json:"schema"`
+	ChainSeq   int64     `json:"chain_seq"`
+	EntryHash  string    `json:"entry_hash"`
+	AnchoredAt time.Time `json:"anchored_at"`
+}
+
+// AuditAnchorer periodically writes the head of each schema's audit log
+// hash chain to the object store, so that a chain rewritten in the
+// database no longer matches the copy held outside it
+type AuditAnchorer struct {
+	db      *gorm.DB
+	tenants *TenantRouter
+	store   ObjectStore
+}
+
+// NewAuditAnchorer creates an audit anchorer. tenants may be nil when
+// tenancy is off, in which case only the public schema is anchored.
+func NewAuditAnchorer(db *gorm.DB, tenants *TenantRouter, store ObjectStore) *AuditAnchorer {
+	return &AuditAnchorer{db: db, tenants: tenants, store: store}
+}
+
+// AnchorAll anchors the public schema and every tenant schema. It runs as a
+// periodic job, so replicas don't write the same anchor twice.
+func (a *AuditAnchorer) AnchorAll(ctx context.Context) error {
+	if err := a.Anchor(ctx, a.db, "public"); err != nil {
+		log.Printf("Failed to anchor audit log: %v", err)
+	}
+	if a.tenants == nil {
+		return nil
+	}
+
+	var tenants []Tenant
+	if err := a.db.WithContext(ctx).Find(&tenants).Error; err != nil {
+		return fmt.Errorf("failed to load tenants for audit anchoring: %w", err)
+	}
+	for _, tenant := range tenants {
+		pool, err := a.tenants.Pool(tenant.Schema)
+		if err == nil {
+			err = a.Anchor(ctx, pool, tenant.Schema)
+		}
+		if err != nil {
+			log.Printf("Failed to anchor audit log of tenant %s: %v", tenant.Slug, err)
+		}
+	}
+	return nil
+}
+
+// Anchor writes the head of a schema's chain to the object store and
+// records the anchor. Nothing is written when the head was already anchored.
+func (a *AuditAnchorer) Anchor(ctx context.Context, db *gorm.DB, schema string) error {
+	db = db.WithContext(ctx)
+
+	var head database.AuditLog
+	if err := db.Where("chain_seq IS NOT NULL").Order("chain_seq DESC").First(&head).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	var last AuditAnchor
+	if err := db.Order("chain_seq DESC").First(&last).Error; err == nil && last.ChainSeq == *head.ChainSeq {
+		return nil
+	} else if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	now := time.Now().UTC()
+	body, err := json.Marshal(auditAnchorDocument{
+		Schema:     schema,
+		ChainSeq:   *head.ChainSeq,
+		EntryHash:  head.EntryHash,
+		AnchoredAt: now,
+	})
+	if err != nil {
+		return err
+	}
+	key := fmt.Sprintf("audit-anchors/%s/%020d.json", schema, *head.ChainSeq)
+	if err := a.store.PutObject(ctx, key, body, "application/json"); err != nil {
+		return err
+	}
+
+	return db.Create(&AuditAnchor{
+		ChainSeq:   *head.ChainSeq,
+		EntryHash:  head.EntryHash,
+		Location:   key,
+		AnchoredAt: now,
+	}).Error
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/penguintechinc/project-template/shared/apierrors"
+	"github.com/penguintechinc/project-template/shared/audit"
+	"github.com/penguintechinc/project-template/shared/database"
+	"github.com/penguintechinc/project-template/shared/pagination"
+	"gorm.io/gorm"
+)
+
+// AuditController handles audit log queries
+type AuditController struct {
+	db *gorm.DB
+}
+
+// NewAuditController creates a new audit controller
+func NewAuditController(db *gorm.DB) *AuditController {
+	return &AuditController{db: db}
+}
+
+// ListAuditLogs retrieves audit log entries, newest first. Entries that
+// record field-level changes include them rendered as readable lines in
+// diff.
+// GET /api/v1/admin/audit-logs
+func (ac *AuditController) ListAuditLogs(c *gin.Context) {
+	if !requireGlobalAdmin(c) {
+		return
+	}
+
+	page, pageSize := pagination.Parse(c, 50, 200)
+
+	query := tenantDB(c, ac.db).Model(&database.AuditLog{})
+	for _, filter := range []string{"user_id", "team_id", "resource_id"} {
+		if v := c.Query(filter); v != "" {
+			id, err := strconv.ParseUint(v, 10, 32)
+			if err != nil {
+				apierrors.Abort(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, filter+" must be a valid number")
+				return
+			}
+			query = query.Where(filter+" = ?", uint(id))
+		}
+	}
+	if v := c.Query("resource_type"); v != "" {
+		query = query.Where("resource_type = ?", v)
+	}
+	if v := c.Query("action"); v != "" {
+		query = query.Where("action = ?", v)
+	}
+	for filter, op := range map[string]string{"since": ">=", "until": "<"} {
+		if v := c.Query(filter); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				apierrors.Abort(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, filter+" must be an RFC 3339 timestamp")
+				return
+			}
+			query = query.Where("timestamp "+op+" ?", t)
+		}
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		log.Printf("Error counting audit logs: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to count audit logs")
+		return
+	}
+
+	var entries []*database.AuditLog
+	if err := query.Order("timestamp DESC, id DESC").
+		Offset((page - 1) * pageSize).Limit(pageSize).
+		Find(&entries).Error; err != nil {
+		log.Printf("Error listing audit logs: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to list audit logs")
+		return
+	}
+
+	responses := make([]*AuditLogResponse, 0, len(entries))
+	for _, entry := range entries {
+		responses = append(responses, &AuditLogResponse{
+			AuditLog: entry,
+			Diff:     audit.Render(audit.Changes(entry.Details)),
+		})
+	}
+
+	pagination.SetHeaders(c, page, pageSize, total)
+	c.JSON(http.StatusOK, AuditLogListResponse{
+		Entries:  responses,
+		Total:    total,
+		Page:     page,
+		PageSize: pageSize,
+	})
+}
+
+// VerifyAuditLogs checks the integrity of the audit log hash chain of the
+// caller's tenant, or of the platform outside any tenant
+// GET /api/v1/admin/audit-logs/verify
+func (ac *AuditController) VerifyAuditLogs(c *gin.Context) {
+	if !requireGlobalAdmin(c) {
+		return
+	}
+
+	report, err := VerifyAuditChain(c.Request.Context(), tenantDB(c, ac.db))
+	if err != nil {
+		log.Printf("Error verifying audit log chain: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to verify audit logs")
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
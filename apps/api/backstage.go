@@ -0,0 +1,269 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/penguintechinc/project-template/shared/apierrors"
+	"gorm.io/gorm"
+)
+
+// Backstage token scopes: catalog reads only the Backstage endpoints, read
+// makes any GET request, and write may also act on resources. No token
+// reaches the admin endpoints.
+const (
+	backstageScopeCatalog = "catalog"
+	backstageScopeRead    = "read"
+	backstageScopeWrite   = "write"
+)
+
+// backstageTokenPrefix marks Backstage tokens, so that other bearer tokens
+// pass through
+const backstageTokenPrefix = "nestbs_"
+
+// backstageAnnotationPrefix prefixes the annotations NEST sets on catalog
+// entities and reads from components
+const backstageAnnotationPrefix = "nest.penguintech.io/"
+
+// backstageSeenInterval bounds how often a token's last_used_at is written
+const backstageSeenInterval = time.Minute
+
+// BackstageTokenMiddleware authenticates requests bearing a Backstage token
+// as the token's service account, within the token's scope. Requests
+// already authenticated, or without a Backstage token, pass through.
+func BackstageTokenMiddleware(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, authenticated := c.Get("user_id"); authenticated {
+			c.Next()
+			return
+		}
+		raw, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || !strings.HasPrefix(raw, backstageTokenPrefix) {
+			c.Next()
+			return
+		}
+
+		var token BackstageToken
+		if err := tenantDB(c, db).Preload("User").Where("token_hash = ?", sha256Hex([]byte(raw))).First(&token).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "Invalid or expired token")
+			} else {
+				log.Printf("Error retrieving Backstage token: %v", err)
+				apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to retrieve token")
+			}
+			return
+		}
+		now := time.Now()
+		if token.ExpiresAt != nil && now.After(*token.ExpiresAt) {
+			apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "Invalid or expired token")
+			return
+		}
+		if token.User == nil || !token.User.IsActive {
+			apierrors.Abort(c, http.StatusForbidden, apierrors.CodeForbidden, "Service account is inactive")
+			return
+		}
+		if !backstageScopeAllows(token.Scope, c.Request.Method, c.Request.URL.Path) {
+			apierrors.Abort(c, http.StatusForbidden, "token_scope", "Token scope does not allow this request")
+			return
+		}
+
+		if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) > backstageSeenInterval {
+			if err := tenantDB(c, db).Model(&token).UpdateColumn("last_used_at", now).Error; err != nil {
+				log.Printf("Error recording use of Backstage token %d: %v", token.ID, err)
+			}
+		}
+
+		c.Set("user_id", token.UserID)
+		c.Set("user_role", token.User.Role)
+		c.Set("backstage_token", token.ID)
+		c.Next()
+	}
+}
+
+// backstageScopeAllows reports whether a token scope covers a request
+func backstageScopeAllows(scope, method, path string) bool {
+	if strings.HasPrefix(path, "/api/v1/admin/") {
+		return false
+	}
+	readOnly := method == http.MethodGet || method == http.MethodHead
+	switch scope {
+	case backstageScopeCatalog:
+		return readOnly && strings.HasPrefix(path, "/api/v1/backstage/")
+	case backstageScopeRead:
+		return readOnly
+	case backstageScopeWrite:
+		return true
+	}
+	return false
+}
+
+// backstageEntityName converts a NEST name into a valid Backstage entity
+// name: at most 63 lowercase letters and digits, separated by single
+// dashes
+func backstageEntityName(name string) string {
+	var b strings.Builder
+	separator := false
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			if separator && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			separator = false
+			b.WriteRune(r)
+		default:
+			separator = true
+		}
+	}
+	result := b.String()
+	if len(result) > 63 {
+		result = strings.TrimRight(result[:63], "-")
+	}
+	return result
+}
+
+// backstageResourceName is the entity name of a resource. The ID keeps
+// names unique across teams and environments.
+func backstageResourceName(resource *Resource) string {
+	suffix := "-" + strconv.FormatUint(uint64(resource.ID), 10)
+	name := backstageEntityName(resource.Name)
+	if len(name)+len(suffix) > 63 {
+		name = strings.TrimRight(name[:63-len(suffix)], "-")
+	}
+	if name == "" {
+		return "nest" + suffix
+	}
+	return name + suffix
+}
+
+// backstageGroupName is the entity name of a team's group
+func backstageGroupName(team *Team) string {
+	if name := backstageEntityName(team.Name); name != "" {
+		return name
+	}
+	return "team-" + strconv.FormatUint(uint64(team.ID), 10)
+}
+
+// backstageGroupEntity is the Group entity of a team
+func backstageGroupEntity(team *Team, location string) BackstageEntity {
+	id := strconv.FormatUint(uint64(team.ID), 10)
+	return BackstageEntity{
+		APIVersion: "backstage.io/v1alpha1",
+		Kind:       "Group",
+		Metadata: BackstageEntityMeta{
+			Name:        backstageGroupName(team),
+			Title:       team.Name,
+			Description: team.Description,
+			Annotations: map[string]string{
+				backstageAnnotationPrefix + "team-id":     id,
+				"backstage.io/managed-by-location":        "url:" + location + "/api/v1/teams/" + id,
+				"backstage.io/managed-by-origin-location": "url:" + location + "/api/v1/backstage/entities",
+			},
+		},
+		Spec: map[string]interface{}{
+			"type":     "team",
+			"children": []string{},
+		},
+	}
+}
+
+// backstageResourceEntity is the Resource entity of a resource, owned by
+// its team's group
+func backstageResourceEntity(resource *Resource, team *Team, location string) BackstageEntity {
+	id := strconv.FormatUint(uint64(resource.ID), 10)
+	resourceType, engine := "database", ""
+	if resource.ResourceType != nil {
+		engine = resource.ResourceType.Name
+		if resource.ResourceType.Category != "" {
+			resourceType = resource.ResourceType.Category
+		}
+	}
+
+	var tags []string
+	for _, tag := range []string{engine, resource.Environment} {
+		if tag = backstageEntityName(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+
+	return BackstageEntity{
+		APIVersion: "backstage.io/v1alpha1",
+		Kind:       "Resource",
+		Metadata: BackstageEntityMeta{
+			Name:  backstageResourceName(resource),
+			Title: resource.Name,
+			Annotations: map[string]string{
+				backstageAnnotationPrefix + "resource-id": id,
+				backstageAnnotationPrefix + "team-id":     strconv.FormatUint(uint64(resource.TeamID), 10),
+				backstageAnnotationPrefix + "environment": resource.Environment,
+				backstageAnnotationPrefix + "engine":      engine,
+				backstageAnnotationPrefix + "status":      resource.Status,
+				"backstage.io/managed-by-location":        "url:" + location + "/api/v1/resources/" + id,
+				"backstage.io/managed-by-origin-location": "url:" + location + "/api/v1/backstage/entities",
+			},
+			Tags: tags,
+		},
+		Spec: map[string]interface{}{
+			"type":  resourceType,
+			"owner": "group:default/" + backstageGroupName(team),
+		},
+	}
+}
+
+// backstageResourceRef is a reference to a resource in a component's
+// nest.penguintech.io/resources annotation: an ID, <team>/<name>, or
+// <team>/<environment>/<name>
+type backstageResourceRef struct {
+	id          uint
+	team        string
+	environment string
+	name        string
+}
+
+// String returns the reference as written
+func (r backstageResourceRef) String() string {
+	switch {
+	case r.id != 0:
+		return strconv.FormatUint(uint64(r.id), 10)
+	case r.environment != "":
+		return r.team + "/" + r.environment + "/" + r.name
+	}
+	return r.team + "/" + r.name
+}
+
+// parseBackstageResourceRefs parses a comma-separated list of resource
+// references
+func parseBackstageResourceRefs(value string) ([]backstageResourceRef, error) {
+	var refs []backstageResourceRef
+	for _, raw := range strings.Split(value, ",") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		if id, err := strconv.ParseUint(raw, 10, 32); err == nil {
+			refs = append(refs, backstageResourceRef{id: uint(id)})
+			continue
+		}
+		parts := strings.Split(raw, "/")
+		for _, part := range parts {
+			if part == "" {
+				return nil, fmt.Errorf("invalid resource reference %q", raw)
+			}
+		}
+		switch len(parts) {
+		case 2:
+			refs = append(refs, backstageResourceRef{team: parts[0], name: parts[1]})
+		case 3:
+			refs = append(refs, backstageResourceRef{team: parts[0], environment: parts[1], name: parts[2]})
+		default:
+			return nil, fmt.Errorf("invalid resource reference %q, expected <id>, <team>/<name>, or <team>/<environment>/<name>", raw)
+		}
+	}
+	return refs, nil
+}
@@ -0,0 +1,349 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/penguintechinc/project-template/shared/apierrors"
+	"github.com/penguintechinc/project-template/shared/audit"
+	"github.com/penguintechinc/project-template/shared/fields"
+	"gorm.io/gorm"
+)
+
+// BackstageController serves the catalog feed and resource lookups of the
+// Backstage plugin, and manages the tokens it calls the API with
+type BackstageController struct {
+	db     *gorm.DB
+	access *AccessCache
+	apiURL string
+}
+
+// NewBackstageController creates a new Backstage controller. Entities point
+// back at apiURL, or at the address each request was made to when empty.
+func NewBackstageController(db *gorm.DB, access *AccessCache, apiURL string) *BackstageController {
+	return &BackstageController{db: db, access: access, apiURL: strings.TrimRight(apiURL, "/")}
+}
+
+// location returns the API address entities point back at
+func (bc *BackstageController) location(c *gin.Context) string {
+	if bc.apiURL != "" {
+		return bc.apiURL
+	}
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host
+}
+
+// visibleTeams returns the teams the caller can see: every team for global
+// admins, and otherwise the teams they are a member of
+func (bc *BackstageController) visibleTeams(c *gin.Context, userID uint) ([]*Team, bool) {
+	query := tenantDB(c, bc.db).Order("id")
+	userRole, _ := c.Get("user_role")
+	if !hasMinimumRole(userRole, "admin") {
+		roles, err := bc.access.TeamRoles(c.Request.Context(), userID)
+		if err != nil {
+			log.Printf("Error retrieving team memberships of user %d: %v", userID, err)
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to retrieve team memberships")
+			return nil, false
+		}
+		teamIDs := make([]uint, 0, len(roles))
+		for teamID := range roles {
+			teamIDs = append(teamIDs, teamID)
+		}
+		if len(teamIDs) == 0 {
+			return nil, true
+		}
+		query = query.Where("id IN ?", teamIDs)
+	}
+
+	var teams []*Team
+	if err := query.Find(&teams).Error; err != nil {
+		log.Printf("Error listing teams: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to list teams")
+		return nil, false
+	}
+	return teams, true
+}
+
+// ListEntities returns the caller's teams as Group entities and their
+// resources as Resource entities, for a Backstage entity provider to apply
+// in full on each refresh. kind limits the feed to Group or Resource.
+// GET /api/v1/backstage/entities
+func (bc *BackstageController) ListEntities(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "User context not found")
+		return
+	}
+
+	kinds := map[string]bool{}
+	for _, kind := range strings.Split(c.DefaultQuery("kind", "Group,Resource"), ",") {
+		switch kind = strings.ToLower(strings.TrimSpace(kind)); kind {
+		case "group", "resource":
+			kinds[kind] = true
+		default:
+			apierrors.Abort(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "kind must be Group or Resource")
+			return
+		}
+	}
+
+	teams, ok := bc.visibleTeams(c, userID.(uint))
+	if !ok {
+		return
+	}
+
+	location := bc.location(c)
+	entities := []BackstageEntity{}
+	byID := make(map[uint]*Team, len(teams))
+	teamIDs := make([]uint, 0, len(teams))
+	for _, team := range teams {
+		byID[team.ID] = team
+		teamIDs = append(teamIDs, team.ID)
+		if kinds["group"] {
+			entities = append(entities, backstageGroupEntity(team, location))
+		}
+	}
+
+	if kinds["resource"] && len(teamIDs) > 0 {
+		var resources []*Resource
+		if err := tenantDB(c, bc.db).Preload("ResourceType").
+			Where("team_id IN ?", teamIDs).Order("id").Find(&resources).Error; err != nil {
+			log.Printf("Error listing resources: %v", err)
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to list resources")
+			return
+		}
+		for _, resource := range resources {
+			entities = append(entities, backstageResourceEntity(resource, byID[resource.TeamID], location))
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"entities": entities})
+}
+
+// LookupResources resolves the resources a component's annotations name:
+// refs takes the nest.penguintech.io/resources annotation, and team the
+// nest.penguintech.io/team annotation for all of a team's resources. Refs
+// that match no resource the caller can see are returned as unresolved.
+// GET /api/v1/backstage/resources
+func (bc *BackstageController) LookupResources(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "User context not found")
+		return
+	}
+
+	teamName := strings.TrimSpace(c.Query("team"))
+	refs, err := parseBackstageResourceRefs(c.Query("refs"))
+	if err != nil {
+		apierrors.Abort(c, http.StatusBadRequest, "invalid_reference", err.Error())
+		return
+	}
+	if teamName == "" && len(refs) == 0 {
+		apierrors.Abort(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "refs or team is required")
+		return
+	}
+
+	teams, ok := bc.visibleTeams(c, userID.(uint))
+	if !ok {
+		return
+	}
+	// Teams are named by their NEST name or their group's entity name
+	byName := make(map[string]*Team, 2*len(teams))
+	teamIDs := make([]uint, 0, len(teams))
+	for _, team := range teams {
+		byName[team.Name] = team
+		byName[backstageGroupName(team)] = team
+		teamIDs = append(teamIDs, team.ID)
+	}
+
+	responses := []*ResourceResponse{}
+	unresolved := []string{}
+	seen := map[uint]bool{}
+	add := func(resources []*Resource) {
+		for _, resource := range resources {
+			if !seen[resource.ID] {
+				seen[resource.ID] = true
+				responses = append(responses, resourceToResponse(resource))
+			}
+		}
+	}
+	query := func() *gorm.DB {
+		return tenantDB(c, bc.db).Preload("ResourceType").Preload("Team").Order("id")
+	}
+
+	if teamName != "" {
+		team, ok := byName[teamName]
+		if !ok {
+			unresolved = append(unresolved, teamName)
+		} else {
+			var resources []*Resource
+			if err := query().Where("team_id = ?", team.ID).Find(&resources).Error; err != nil {
+				log.Printf("Error listing resources of team %d: %v", team.ID, err)
+				apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to list resources")
+				return
+			}
+			add(resources)
+		}
+	}
+
+	for _, ref := range refs {
+		var resources []*Resource
+		var q *gorm.DB
+		switch {
+		case ref.id != 0:
+			if len(teamIDs) > 0 {
+				q = query().Where("id = ? AND team_id IN ?", ref.id, teamIDs)
+			}
+		default:
+			if team, ok := byName[ref.team]; ok {
+				q = query().Where("team_id = ? AND name = ?", team.ID, ref.name)
+				if ref.environment != "" {
+					q = q.Where("environment = ?", ref.environment)
+				}
+			}
+		}
+		if q != nil {
+			if err := q.Find(&resources).Error; err != nil {
+				log.Printf("Error resolving resource reference: %v", err)
+				apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to list resources")
+				return
+			}
+		}
+		if len(resources) == 0 {
+			unresolved = append(unresolved, ref.String())
+			continue
+		}
+		add(resources)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"resources": responses, "unresolved": unresolved})
+}
+
+// ListBackstageTokens retrieves the Backstage tokens
+// GET /api/v1/admin/backstage-tokens
+func (bc *BackstageController) ListBackstageTokens(c *gin.Context) {
+	if !requireGlobalAdmin(c) {
+		return
+	}
+
+	var tokens []*BackstageToken
+	if err := fields.Preload(c, tenantDB(c, bc.db), "User").Order("id").Find(&tokens).Error; err != nil {
+		log.Printf("Error listing Backstage tokens: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to list Backstage tokens")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"backstage_tokens": tokens})
+}
+
+// CreateBackstageToken issues a Backstage token for a service account. The
+// token is only returned in this response.
+// POST /api/v1/admin/backstage-tokens
+func (bc *BackstageController) CreateBackstageToken(c *gin.Context) {
+	if !requireGlobalAdmin(c) {
+		return
+	}
+
+	var req CreateBackstageTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierrors.AbortWithDetails(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Invalid request body", err.Error())
+		return
+	}
+	if req.Scope == "" {
+		req.Scope = backstageScopeCatalog
+	}
+	if req.Scope != backstageScopeCatalog && req.Scope != backstageScopeRead && req.Scope != backstageScopeWrite {
+		apierrors.Abort(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "scope must be catalog, read, or write")
+		return
+	}
+	var expiresAt *time.Time
+	if req.TTL != "" {
+		ttl, err := time.ParseDuration(req.TTL)
+		if err != nil || ttl <= 0 {
+			apierrors.Abort(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "TTL must be a positive duration")
+			return
+		}
+		expires := time.Now().Add(ttl)
+		expiresAt = &expires
+	}
+
+	db := tenantDB(c, bc.db)
+	var user User
+	if err := db.First(&user, req.UserID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierrors.Abort(c, http.StatusNotFound, apierrors.CodeNotFound, "User not found")
+		} else {
+			log.Printf("Error retrieving user %d: %v", req.UserID, err)
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to retrieve user")
+		}
+		return
+	}
+
+	secret, err := randomToken()
+	if err != nil {
+		log.Printf("Error generating Backstage token: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeInternal, "Failed to create Backstage token")
+		return
+	}
+	raw := backstageTokenPrefix + secret
+
+	userID := c.MustGet("user_id").(uint)
+	token := &BackstageToken{
+		Name:        req.Name,
+		UserID:      user.ID,
+		Scope:       req.Scope,
+		TokenHash:   sha256Hex([]byte(raw)),
+		TokenPrefix: raw[:len(backstageTokenPrefix)+8],
+		ExpiresAt:   expiresAt,
+		CreatedBy:   userID,
+	}
+	if err := db.Create(token).Error; err != nil {
+		log.Printf("Error creating Backstage token: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to create Backstage token")
+		return
+	}
+	if err := audit.Record(c, db, userID, "backstage_tokens", token.ID, nil, nil, token); err != nil {
+		log.Printf("Error recording creation of Backstage token %d in the audit log: %v", token.ID, err)
+	}
+
+	token.User = &user
+	c.JSON(http.StatusCreated, BackstageTokenResponse{BackstageToken: token, Token: raw})
+}
+
+// DeleteBackstageToken revokes a Backstage token
+// DELETE /api/v1/admin/backstage-tokens/:id
+func (bc *BackstageController) DeleteBackstageToken(c *gin.Context) {
+	if !requireGlobalAdmin(c) {
+		return
+	}
+
+	db := tenantDB(c, bc.db)
+	var token BackstageToken
+	if err := db.First(&token, c.Param("id")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierrors.Abort(c, http.StatusNotFound, apierrors.CodeNotFound, "Backstage token not found")
+		} else {
+			log.Printf("Error retrieving Backstage token: %v", err)
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to retrieve token")
+		}
+		return
+	}
+
+	if err := db.Unscoped().Delete(&token).Error; err != nil {
+		log.Printf("Error deleting Backstage token %d: %v", token.ID, err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to delete Backstage token")
+		return
+	}
+	userID := c.MustGet("user_id").(uint)
+	if err := audit.Record(c, db, userID, "backstage_tokens", token.ID, nil, token, nil); err != nil {
+		log.Printf("Error recording deletion of Backstage token %d in the audit log: %v", token.ID, err)
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
@@ -0,0 +1,190 @@
+package main
+
+// Benchmarks of the API's hot paths against an in-memory SQLite database,
+// seeded like a busy installation. They measure the Go side of each path,
+// not Postgres; use cmd/nest-bench for end-to-end latencies.
+//
+//	go test -run '^$' -bench . -benchmem ./apps/api/
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/penguintechinc/project-template/shared/database"
+	"gorm.io/datatypes"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const (
+	benchTeams            = 50
+	benchResourcesPerTeam = 40
+	benchTeamsPerUser     = 5
+)
+
+// newBenchDB creates a database with benchTeams teams of
+// benchResourcesPerTeam resources, and a user who is a member of the first
+// benchTeamsPerUser teams. It returns the database and the user's ID.
+func newBenchDB(b *testing.B) (*gorm.DB, uint) {
+	b.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		b.Fatalf("Failed to open database: %v", err)
+	}
+	// Every connection to :memory: is a database of its own
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+
+	if err := db.AutoMigrate(&User{}, &Team{}, &TeamMember{}, &ResourceType{}, &Resource{},
+		&ResourceStats{}, &FeatureFlag{}); err != nil {
+		b.Fatalf("Failed to migrate: %v", err)
+	}
+
+	user := &User{Username: "bench", Email: "bench@example.com", PasswordHash: "-", Role: "user", IsActive: true}
+	resourceType := &ResourceType{Name: "redis", Category: "cache", DisplayName: "Redis"}
+	if err := db.Create(user).Error; err != nil {
+		b.Fatalf("Failed to create user: %v", err)
+	}
+	if err := db.Create(resourceType).Error; err != nil {
+		b.Fatalf("Failed to create resource type: %v", err)
+	}
+
+	for t := 0; t < benchTeams; t++ {
+		team := &Team{Name: fmt.Sprintf("team-%d", t)}
+		if err := db.Create(team).Error; err != nil {
+			b.Fatalf("Failed to create team: %v", err)
+		}
+		if t < benchTeamsPerUser {
+			if err := db.Create(&TeamMember{TeamID: team.ID, UserID: user.ID, Role: "maintainer"}).Error; err != nil {
+				b.Fatalf("Failed to create membership: %v", err)
+			}
+		}
+		resources := make([]*Resource, 0, benchResourcesPerTeam)
+		for r := 0; r < benchResourcesPerTeam; r++ {
+			resources = append(resources, &Resource{
+				Name:           fmt.Sprintf("team-%d-resource-%d", t, r),
+				ResourceTypeID: resourceType.ID,
+				TeamID:         team.ID,
+				Environment:    []string{"dev", "staging", "prod"}[r%3],
+				Status:         "active",
+				LifecycleMode:  "full",
+			})
+		}
+		if err := db.CreateInBatches(resources, 100).Error; err != nil {
+			b.Fatalf("Failed to create resources: %v", err)
+		}
+	}
+	return db, user.ID
+}
+
+func BenchmarkAccessCacheTeamRoles(b *testing.B) {
+	db, userID := newBenchDB(b)
+	ctx := context.Background()
+
+	b.Run("uncached", func(b *testing.B) {
+		access := NewAccessCache(db, nil, 0)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := access.TeamRoles(ctx, userID); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("cached", func(b *testing.B) {
+		access := NewAccessCache(db, database.NewMemoryCache(), time.Hour)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := access.TeamRoles(ctx, userID); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkPermissionExplain(b *testing.B) {
+	db, userID := newBenchDB(b)
+	explainer := &permissionExplainer{db: db, access: NewAccessCache(db, database.NewMemoryCache(), time.Hour)}
+	ctx := context.Background()
+
+	for _, action := range []string{"read", "resize"} {
+		b.Run(action, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				resourceID := uint(i%(benchTeamsPerUser*benchResourcesPerTeam)) + 1
+				if _, err := explainer.explain(ctx, userID, resourceID, action); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkListResources(b *testing.B) {
+	gin.SetMode(gin.TestMode)
+	db, userID := newBenchDB(b)
+	controller := NewResourceController(db, NewAccessCache(db, nil, 0), nil, nil, nil, 0)
+
+	for name, query := range map[string]string{
+		"expanded":   "",
+		"unexpanded": "?expand=",
+		"filtered":   "?environment=prod&status=active&page_size=100",
+	} {
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				w := httptest.NewRecorder()
+				c, _ := gin.CreateTestContext(w)
+				c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/resources"+query, nil)
+				c.Set("user_id", userID)
+				controller.ListResources(c)
+				if w.Code != http.StatusOK {
+					b.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkApplyAgentReport(b *testing.B) {
+	db, _ := newBenchDB(b)
+	var resource Resource
+	if err := db.First(&resource).Error; err != nil {
+		b.Fatalf("Failed to load resource: %v", err)
+	}
+	report := &AgentResourceReport{
+		Metrics:   map[string]interface{}{"connections": 42, "memory_used_bytes": 1 << 28, "cpu_percent": 12.5},
+		RiskLevel: "low",
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := applyAgentReport(db, &resource, report); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkStatsInsert(b *testing.B) {
+	db, _ := newBenchDB(b)
+	metrics := datatypes.JSON(`{"connections":42,"memory_used_bytes":268435456,"cpu_percent":12.5}`)
+	resources := benchTeams * benchResourcesPerTeam
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := db.Create(&ResourceStats{
+			ResourceID: uint(i%resources) + 1,
+			Timestamp:  time.Now().UTC(),
+			Metrics:    metrics,
+			RiskLevel:  "low",
+		}).Error; err != nil {
+			b.Fatal(err)
+		}
+	}
+}
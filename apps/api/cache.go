@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/penguintechinc/project-template/shared/database"
+	"gorm.io/gorm"
+)
+
+// Cache namespaces
+const (
+	cacheNSMemberships   = "memberships"
+	cacheNSResourceTypes = "resource_types"
+	cacheNSNetworkRules  = "network_rules"
+)
+
+// cacheInvalidationTables maps tables to the cache namespaces derived from them
+var cacheInvalidationTables = map[string][]string{
+	"team_members":         {cacheNSMemberships},
+	"teams":                {cacheNSMemberships},
+	"resource_types":       {cacheNSResourceTypes},
+	"network_access_rules": {cacheNSNetworkRules},
+}
+
+// AccessCache caches team membership, resource type and network access rule
+// lookups. A nil cache disables caching and every lookup goes to the
+// database.
+type AccessCache struct {
+	db    *gorm.DB
+	cache database.Cache
+	ttl   time.Duration
+}
+
+// NewAccessCache creates an access cache
+func NewAccessCache(db *gorm.DB, cache database.Cache, ttl time.Duration) *AccessCache {
+	return &AccessCache{db: db, cache: cache, ttl: ttl}
+}
+
+// TeamRoles returns the user's role in each of their teams, keyed by team ID
+func (a *AccessCache) TeamRoles(ctx context.Context, userID uint) (map[uint]string, error) {
+	key := membershipsKey(ctx, userID)
+	roles := make(map[uint]string)
+	if a.cache != nil {
+		if found, err := a.cache.Get(ctx, key, &roles); err == nil && found {
+			return roles, nil
+		}
+	}
+
+	var members []TeamMember
+	if err := database.TenantDB(ctx, a.db).WithContext(ctx).
+		Joins("INNER JOIN teams ON teams.id = team_members.team_id AND teams.deleted_at IS NULL").
+		Where("team_members.user_id = ?", userID).
+		Find(&members).Error; err != nil {
+		return nil, err
+	}
+	for _, member := range members {
+		roles[member.TeamID] = member.Role
+	}
+
+	a.store(ctx, key, roles)
+	return roles, nil
+}
+
+// TeamRole returns the user's role in a team and whether they are a member
+func (a *AccessCache) TeamRole(ctx context.Context, userID, teamID uint) (string, bool, error) {
+	roles, err := a.TeamRoles(ctx, userID)
+	if err != nil {
+		return "", false, err
+	}
+	role, ok := roles[teamID]
+	return role, ok, nil
+}
+
+// ResourceType returns an active resource type, or gorm.ErrRecordNotFound
+func (a *AccessCache) ResourceType(ctx context.Context, id uint) (*ResourceType, error) {
+	key := fmt.Sprintf("%s:%d", cacheNSResourceTypes, id)
+	var resourceType ResourceType
+	if a.cache != nil {
+		if found, err := a.cache.Get(ctx, key, &resourceType); err == nil && found {
+			return &resourceType, nil
+		}
+	}
+
+	if err := a.db.WithContext(ctx).Where("id = ? AND deleted_at IS NULL", id).
+		First(&resourceType).Error; err != nil {
+		return nil, err
+	}
+
+	a.store(ctx, key, &resourceType)
+	return &resourceType, nil
+}
+
+// NetworkRules returns the enabled network access rules of the tenant
+func (a *AccessCache) NetworkRules(ctx context.Context) ([]NetworkAccessRule, error) {
+	key := cacheNSNetworkRules
+	if schema := database.TenantSchema(ctx); schema != "" {
+		key = fmt.Sprintf("%s:%s", cacheNSNetworkRules, schema)
+	}
+	rules := []NetworkAccessRule{}
+	if a.cache != nil {
+		if found, err := a.cache.Get(ctx, key, &rules); err == nil && found {
+			return rules, nil
+		}
+	}
+
+	if err := database.TenantDB(ctx, a.db).WithContext(ctx).Where("enabled = ?", true).
+		Order("id").Find(&rules).Error; err != nil {
+		return nil, err
+	}
+
+	a.store(ctx, key, rules)
+	return rules, nil
+}
+
+// InvalidateUser drops cached memberships for a user
+func (a *AccessCache) InvalidateUser(ctx context.Context, userID uint) {
+	if a.cache == nil {
+		return
+	}
+	if err := a.cache.Delete(ctx, membershipsKey(ctx, userID)); err != nil {
+		log.Printf("Failed to invalidate cached memberships for user %d: %v", userID, err)
+	}
+}
+
+// membershipsKey is the cache key of a user's memberships. User IDs are
+// only unique within a tenant, so the key includes the tenant schema.
+func membershipsKey(ctx context.Context, userID uint) string {
+	if schema := database.TenantSchema(ctx); schema != "" {
+		return fmt.Sprintf("%s:%s:%d", cacheNSMemberships, schema, userID)
+	}
+	return fmt.Sprintf("%s:%d", cacheNSMemberships, userID)
+}
+
+// store writes a value to the cache, logging rather than failing on errors
+func (a *AccessCache) store(ctx context.Context, key string, value interface{}) {
+	if a.cache == nil {
+		return
+	}
+	if err := a.cache.Set(ctx, key, value, a.ttl); err != nil {
+		log.Printf("Failed to cache %s: %v", key, err)
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/penguintechinc/project-template/shared/apierrors"
+	"github.com/penguintechinc/project-template/shared/pagination"
+	"gorm.io/gorm"
+)
+
+// defaultCertificateWindow is how many days ahead certificates are listed as
+// expiring, unless a request says otherwise
+const defaultCertificateWindow = 30
+
+// CertificateController reports the TLS certificates of resources
+type CertificateController struct {
+	db *gorm.DB
+}
+
+// NewCertificateController creates a new certificate controller
+func NewCertificateController(db *gorm.DB) *CertificateController {
+	return &CertificateController{db: db}
+}
+
+// ListCertificates lists the TLS certificates of the user's teams' resources
+// that expire within within_days (default 30), including those already
+// expired, soonest first. within_days=0 lists every certificate.
+// GET /api/v1/certificates
+func (cc *CertificateController) ListCertificates(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "User context not found")
+		return
+	}
+
+	withinDays, ok := certificateWindow(c)
+	if !ok {
+		return
+	}
+
+	page, pageSize := pagination.Parse(c, 50, 200)
+	now := time.Now().UTC()
+	response := CertificateListResponse{Certificates: []*CertificateSummary{}, Page: page, PageSize: pageSize}
+
+	// The table belongs to the certificate subsystem and is missing until it
+	// has run
+	db := tenantDB(c, cc.db)
+	if db.Migrator().HasTable("certificates") {
+		query := certificateQuery(c, db, now, withinDays).
+			Joins("JOIN team_members tm ON tm.team_id = r.team_id AND tm.user_id = ?", userID.(uint))
+
+		if err := query.Session(&gorm.Session{}).Count(&response.Total).Error; err != nil {
+			log.Printf("Error counting certificates: %v", err)
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to list certificates")
+			return
+		}
+		if err := query.Select(certificateSummaryColumns).
+			Order("c.valid_until ASC, c.id ASC").
+			Offset((page - 1) * pageSize).Limit(pageSize).
+			Scan(&response.Certificates).Error; err != nil {
+			log.Printf("Error listing certificates: %v", err)
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to list certificates")
+			return
+		}
+	}
+
+	setCertificateExpiry(response.Certificates, now)
+	pagination.SetHeaders(c, page, pageSize, response.Total)
+	c.JSON(http.StatusOK, response)
+}
+
+// certificateWindow parses the within_days query parameter. It writes the
+// error response and returns false when it is invalid.
+func certificateWindow(c *gin.Context) (int, bool) {
+	withinDays := defaultCertificateWindow
+	if v := c.Query("within_days"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed < 0 {
+			apierrors.Abort(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "within_days must be a number of days")
+			return 0, false
+		}
+		withinDays = parsed
+	}
+	return withinDays, true
+}
+
+// certificateSummaryColumns selects a CertificateSummary from a
+// certificateQuery
+const certificateSummaryColumns = `c.id, COALESCE(c.common_name, '') AS common_name,
+	COALESCE(c.serial_number, '') AS serial_number, c.valid_from, c.valid_until,
+	COALESCE(c.auto_renew, false) AS auto_renew, r.id AS resource_id,
+	r.name AS resource, r.environment, r.team_id, t.name AS team`
+
+// certificateQuery selects the certificates of live resources, as c with
+// their resource r and team t, that expire within withinDays of now, or
+// every one when it is zero, filtered by the team_id query parameter
+func certificateQuery(c *gin.Context, db *gorm.DB, now time.Time, withinDays int) *gorm.DB {
+	query := db.Table("certificates c").
+		Joins("JOIN resources r ON r.id = c.resource_id AND r.deleted_at IS NULL").
+		Joins("JOIN teams t ON t.id = r.team_id").
+		Where("c.deleted_at IS NULL")
+	if withinDays > 0 {
+		query = query.Where("c.valid_until <= ?", now.AddDate(0, 0, withinDays))
+	}
+	if teamID := c.Query("team_id"); teamID != "" {
+		if tid, err := strconv.ParseUint(teamID, 10, 32); err == nil {
+			query = query.Where("r.team_id = ?", uint(tid))
+		}
+	}
+	return query
+}
+
+// setCertificateExpiry fills in whether certificates have expired and the
+// days they have left
+func setCertificateExpiry(certs []*CertificateSummary, now time.Time) {
+	for _, cert := range certs {
+		cert.Expired = !cert.ValidUntil.After(now)
+		cert.DaysRemaining = int(cert.ValidUntil.Sub(now).Hours() / 24)
+	}
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/penguintechinc/project-template/shared/apierrors"
+	"github.com/penguintechinc/project-template/shared/audit"
+	"gorm.io/gorm"
+)
+
+// CloudAccountController handles cloud account HTTP requests
+type CloudAccountController struct {
+	db *gorm.DB
+}
+
+// NewCloudAccountController creates a new cloud account controller
+func NewCloudAccountController(db *gorm.DB) *CloudAccountController {
+	return &CloudAccountController{db: db}
+}
+
+// loadCloudAccount returns the cloud account named by the path
+func (cc *CloudAccountController) loadCloudAccount(c *gin.Context) (*CloudAccount, bool) {
+	var account CloudAccount
+	if err := tenantDB(c, cc.db).First(&account, c.Param("id")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierrors.Abort(c, http.StatusNotFound, "cloud_account_not_found", "Cloud account not found")
+		} else {
+			log.Printf("Error retrieving cloud account: %v", err)
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to retrieve cloud account")
+		}
+		return nil, false
+	}
+	return &account, true
+}
+
+// ListCloudAccounts retrieves the cloud accounts and their last sync
+// GET /api/v1/cloud-accounts
+func (cc *CloudAccountController) ListCloudAccounts(c *gin.Context) {
+	if !requireGlobalAdmin(c) {
+		return
+	}
+
+	var accounts []*CloudAccount
+	if err := tenantDB(c, cc.db).Order("name").Find(&accounts).Error; err != nil {
+		log.Printf("Error listing cloud accounts: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to list cloud accounts")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"cloud_accounts": accounts})
+}
+
+// CreateCloudAccount adds a cloud account. Its instances are imported on the
+// next sync, or at once with the sync endpoint.
+// POST /api/v1/cloud-accounts
+func (cc *CloudAccountController) CreateCloudAccount(c *gin.Context) {
+	if !requireGlobalAdmin(c) {
+		return
+	}
+
+	var req CreateCloudAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierrors.AbortWithDetails(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	account := &CloudAccount{
+		Name:              req.Name,
+		Provider:          req.Provider,
+		TeamID:            req.TeamID,
+		LifecycleMode:     req.LifecycleMode,
+		Region:            req.Region,
+		AccessKeyID:       req.AccessKeyID,
+		SecretAccessKey:   req.SecretAccessKey,
+		Project:           req.Project,
+		ServiceAccountKey: req.ServiceAccountKey,
+		CreatedBy:         c.MustGet("user_id").(uint),
+	}
+	if account.LifecycleMode == "" {
+		account.LifecycleMode = "monitor_only"
+	}
+	if err := validateCloudAccount(account); err != nil {
+		apierrors.Abort(c, http.StatusBadRequest, "invalid_cloud_account", err.Error())
+		return
+	}
+
+	db := tenantDB(c, cc.db)
+	var team Team
+	if err := db.Where("id = ? AND deleted_at IS NULL", req.TeamID).First(&team).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierrors.Abort(c, http.StatusNotFound, "team_not_found", "Team not found")
+		} else {
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to verify team")
+		}
+		return
+	}
+
+	// Instances are imported into the given environment, defaulting to the
+	// first in the team's pipeline
+	envs, err := teamEnvironments(db, req.TeamID)
+	if err != nil {
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to load environments")
+		return
+	}
+	envIdx := 0
+	if req.Environment != "" {
+		envIdx = findEnvironment(envs, req.Environment)
+	}
+	if envIdx < 0 {
+		apierrors.Abort(c, http.StatusBadRequest, "invalid_environment", "Environment is not part of the team's pipeline")
+		return
+	}
+	account.Environment = envs[envIdx].Name
+
+	var existing int64
+	if err := db.Model(&CloudAccount{}).Where("name = ?", account.Name).Count(&existing).Error; err != nil {
+		log.Printf("Error checking cloud account names: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to create cloud account")
+		return
+	}
+	if existing > 0 {
+		apierrors.Abort(c, http.StatusConflict, "cloud_account_exists", "A cloud account with this name already exists")
+		return
+	}
+
+	if err := db.Create(account).Error; err != nil {
+		log.Printf("Error creating cloud account: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to create cloud account")
+		return
+	}
+	if err := audit.Record(c, db, account.CreatedBy, "cloud_accounts", account.ID, &account.TeamID, nil, account); err != nil {
+		log.Printf("Error recording creation of cloud account %d in the audit log: %v", account.ID, err)
+	}
+
+	c.JSON(http.StatusCreated, account)
+}
+
+// UpdateCloudAccount updates a cloud account's lifecycle mode or rotates its
+// credentials. The lifecycle mode applies to instances imported afterwards.
+// PUT /api/v1/cloud-accounts/:id
+func (cc *CloudAccountController) UpdateCloudAccount(c *gin.Context) {
+	if !requireGlobalAdmin(c) {
+		return
+	}
+
+	account, ok := cc.loadCloudAccount(c)
+	if !ok {
+		return
+	}
+	before := *account
+
+	var req UpdateCloudAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierrors.AbortWithDetails(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	if req.LifecycleMode != nil {
+		account.LifecycleMode = *req.LifecycleMode
+	}
+	if req.AccessKeyID != nil {
+		account.AccessKeyID = *req.AccessKeyID
+	}
+	if req.SecretAccessKey != nil {
+		account.SecretAccessKey = *req.SecretAccessKey
+	}
+	if req.ServiceAccountKey != nil {
+		account.ServiceAccountKey = *req.ServiceAccountKey
+	}
+	if err := validateCloudAccount(account); err != nil {
+		apierrors.Abort(c, http.StatusBadRequest, "invalid_cloud_account", err.Error())
+		return
+	}
+
+	db := tenantDB(c, cc.db)
+	if err := db.Save(account).Error; err != nil {
+		log.Printf("Error updating cloud account %d: %v", account.ID, err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to update cloud account")
+		return
+	}
+	userID := c.MustGet("user_id").(uint)
+	if err := audit.Record(c, db, userID, "cloud_accounts", account.ID, &account.TeamID, &before, account); err != nil {
+		log.Printf("Error recording update of cloud account %d in the audit log: %v", account.ID, err)
+	}
+
+	c.JSON(http.StatusOK, account)
+}
+
+// SyncCloudAccountNow imports and updates the account's instances at once
+// rather than waiting for the next sync
+// POST /api/v1/cloud-accounts/:id/sync
+func (cc *CloudAccountController) SyncCloudAccountNow(c *gin.Context) {
+	if !requireGlobalAdmin(c) {
+		return
+	}
+
+	account, ok := cc.loadCloudAccount(c)
+	if !ok {
+		return
+	}
+
+	result, err := SyncCloudAccount(c.Request.Context(), tenantDB(c, cc.db), account)
+	if err != nil {
+		log.Printf("Cloud account %s sync failed: %v", account.Name, err)
+		apierrors.AbortWithDetails(c, http.StatusBadGateway, "cloud_sync_failed", "Failed to sync cloud account", err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// DeleteCloudAccount removes a cloud account. Accounts with live imported
+// resources can't be removed; delete the resources first.
+// DELETE /api/v1/cloud-accounts/:id
+func (cc *CloudAccountController) DeleteCloudAccount(c *gin.Context) {
+	if !requireGlobalAdmin(c) {
+		return
+	}
+
+	account, ok := cc.loadCloudAccount(c)
+	if !ok {
+		return
+	}
+
+	db := tenantDB(c, cc.db)
+	var imported int64
+	if err := db.Model(&Resource{}).Where("cloud_account_id = ?", account.ID).Count(&imported).Error; err != nil {
+		log.Printf("Error counting resources of cloud account %s: %v", account.Name, err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to delete cloud account")
+		return
+	}
+	if imported > 0 {
+		apierrors.Abort(c, http.StatusConflict, "cloud_account_in_use", "The cloud account still has imported resources")
+		return
+	}
+
+	if err := db.Unscoped().Delete(account).Error; err != nil {
+		log.Printf("Error deleting cloud account %s: %v", account.Name, err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to delete cloud account")
+		return
+	}
+	userID := c.MustGet("user_id").(uint)
+	if err := audit.Record(c, db, userID, "cloud_accounts", account.ID, &account.TeamID, account, nil); err != nil {
+		log.Printf("Error recording deletion of cloud account %d in the audit log: %v", account.ID, err)
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
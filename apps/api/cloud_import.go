@@ -0,0 +1,376 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// Cloud providers instances are imported from
+const (
+	CloudProviderAWS = "aws"
+	CloudProviderGCP = "gcp"
+)
+
+// CloudInstance is a managed database instance discovered in a cloud account
+type CloudInstance struct {
+	// ID identifies the instance within the account, such as an RDS DB
+	// instance identifier or a Cloud SQL instance name
+	ID string
+	// Engine is the name of the instance's resource type
+	Engine        string
+	EngineVersion string
+	// State is the provider's state of the instance, and Status the NEST
+	// resource status it maps to
+	State  string
+	Status string
+	Host   string
+	Port   int
+	// CACertificate identifies the server CA certificate and when it expires,
+	// where the provider reports it
+	CACertificate string
+	CAValidUntil  *time.Time
+}
+
+// CloudImporter discovers the database instances of a cloud account and
+// takes snapshots of them
+type CloudImporter interface {
+	// ListInstances lists the account's instances. Instances of engines NEST
+	// has no resource type for have an empty Engine.
+	ListInstances(ctx context.Context) ([]*CloudInstance, error)
+	// Snapshot starts a snapshot of an instance and returns its location
+	Snapshot(ctx context.Context, instanceID, name string) (string, error)
+}
+
+// cloudUserManager is implemented by importers whose provider API can
+// manage database users
+type cloudUserManager interface {
+	SyncUser(ctx context.Context, instanceID string, user *AgentUser) error
+}
+
+// newCloudImporter creates the importer for an account's provider
+func newCloudImporter(account *CloudAccount) (CloudImporter, error) {
+	switch account.Provider {
+	case CloudProviderAWS:
+		return newRDSImporter(account), nil
+	case CloudProviderGCP:
+		return newCloudSQLImporter(account)
+	default:
+		return nil, fmt.Errorf("unsupported cloud provider: %s", account.Provider)
+	}
+}
+
+// validateCloudAccount checks an account's provider, lifecycle mode, and
+// credentials
+func validateCloudAccount(account *CloudAccount) error {
+	if account.LifecycleMode != "monitor_only" && account.LifecycleMode != "partial" {
+		return errors.New("lifecycle_mode must be one of: monitor_only, partial")
+	}
+	switch account.Provider {
+	case CloudProviderAWS:
+		if account.Region == "" || account.AccessKeyID == "" || account.SecretAccessKey == "" {
+			return errors.New("AWS accounts require region, access_key_id, and secret_access_key")
+		}
+	case CloudProviderGCP:
+		if account.Project == "" {
+			return errors.New("GCP accounts require project and service_account_key")
+		}
+		if _, err := parseServiceAccountKey(account.ServiceAccountKey); err != nil {
+			return err
+		}
+	default:
+		return errors.New("provider must be one of: aws, gcp")
+	}
+	return nil
+}
+
+// cloudResourceNameInvalid matches the characters of instance IDs that
+// resource names replace with hyphens
+var cloudResourceNameInvalid = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// cloudResourceName derives a resource name from an instance ID
+func cloudResourceName(instanceID string) string {
+	return strings.Trim(cloudResourceNameInvalid.ReplaceAllString(strings.ToLower(instanceID), "-"), "-")
+}
+
+// CloudSyncer imports the instances of cloud accounts as resources and keeps
+// them in sync: their status, engine version, endpoint, and server CA. For
+// partial resources it takes snapshots for pending backup jobs, and syncs
+// pending users where the provider's API manages them. Each account is
+// synced by a job of its own.
+type CloudSyncer struct {
+	db *gorm.DB
+}
+
+// NewCloudSyncer creates a cloud syncer
+func NewCloudSyncer(db *gorm.DB) *CloudSyncer {
+	return &CloudSyncer{db: db}
+}
+
+// EnqueueAccountSyncs enqueues a sync job for every cloud account, so a
+// failing account is retried on its own. parent is the job doing the
+// fan-out; a retry of it doesn't enqueue the accounts twice.
+func (s *CloudSyncer) EnqueueAccountSyncs(ctx context.Context, parent *Job) error {
+	var accounts []*CloudAccount
+	if err := s.db.WithContext(ctx).Find(&accounts).Error; err != nil {
+		return fmt.Errorf("failed to list cloud accounts: %w", err)
+	}
+	for _, account := range accounts {
+		if _, err := EnqueueJob(s.db.WithContext(ctx), JobRequest{
+			Kind:      JobCloudAccountSync,
+			Payload:   cloudAccountSyncPayload{CloudAccountID: account.ID},
+			UniqueKey: fmt.Sprintf("%s@%d:%d", JobCloudAccountSync, parent.ID, account.ID),
+		}); err != nil {
+			return fmt.Errorf("failed to enqueue sync of cloud account %s: %w", account.Name, err)
+		}
+	}
+	return nil
+}
+
+// cloudAccountSyncPayload is the payload of a cloud account sync job
+type cloudAccountSyncPayload struct {
+	CloudAccountID uint `json:"cloud_account_id"`
+}
+
+// SyncAccount runs a cloud account sync job. An account deleted since the
+// job was enqueued is skipped.
+func (s *CloudSyncer) SyncAccount(ctx context.Context, job *Job) error {
+	var payload cloudAccountSyncPayload
+	if err := decodeJobPayload(job, &payload); err != nil {
+		return err
+	}
+	var account CloudAccount
+	err := s.db.WithContext(ctx).First(&account, payload.CloudAccountID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	_, err = SyncCloudAccount(ctx, s.db, &account)
+	return err
+}
+
+// SyncCloudAccount imports and updates the instances of one account,
+// recording the outcome on the account
+func SyncCloudAccount(ctx context.Context, db *gorm.DB, account *CloudAccount) (*CloudSyncResult, error) {
+	db = db.WithContext(ctx)
+	result, err := syncCloudAccount(ctx, db, account)
+
+	updates := map[string]interface{}{"last_error": ""}
+	if err != nil {
+		updates["last_error"] = err.Error()
+	} else {
+		updates["last_sync_at"] = time.Now().UTC()
+	}
+	if updateErr := db.Model(&CloudAccount{}).Where("id = ?", account.ID).Updates(updates).Error; updateErr != nil {
+		log.Printf("Error recording sync of cloud account %s: %v", account.Name, updateErr)
+	}
+	return result, err
+}
+
+func syncCloudAccount(ctx context.Context, db *gorm.DB, account *CloudAccount) (*CloudSyncResult, error) {
+	importer, err := newCloudImporter(account)
+	if err != nil {
+		return nil, err
+	}
+	instances, err := importer.ListInstances(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list instances: %w", err)
+	}
+
+	var types []ResourceType
+	if err := db.Find(&types).Error; err != nil {
+		return nil, fmt.Errorf("failed to load resource types: %w", err)
+	}
+	typeIDs := make(map[string]uint, len(types))
+	for _, t := range types {
+		typeIDs[t.Name] = t.ID
+	}
+
+	// Resources deleted in NEST stay deleted rather than being imported
+	// again, so deleted ones are loaded too
+	var existing []*Resource
+	if err := db.Unscoped().Where("cloud_account_id = ?", account.ID).Find(&existing).Error; err != nil {
+		return nil, fmt.Errorf("failed to load imported resources: %w", err)
+	}
+	byInstance := make(map[string]*Resource, len(existing))
+	for _, r := range existing {
+		byInstance[r.CloudInstanceID] = r
+	}
+
+	result := &CloudSyncResult{Discovered: len(instances)}
+	seen := make(map[string]bool, len(instances))
+	for _, instance := range instances {
+		seen[instance.ID] = true
+		typeID, ok := typeIDs[instance.Engine]
+		if instance.Engine == "" || !ok {
+			result.Skipped = append(result.Skipped, instance.ID)
+			continue
+		}
+
+		resource := byInstance[instance.ID]
+		switch {
+		case resource == nil:
+			if resource, err = importCloudInstance(db, account, instance, typeID); err != nil {
+				log.Printf("Error importing instance %s of cloud account %s: %v", instance.ID, account.Name, err)
+				result.Skipped = append(result.Skipped, instance.ID)
+				continue
+			}
+			result.Imported++
+		case resource.DeletedAt.Valid:
+			continue
+		default:
+			if err := updateCloudResource(db, account, resource, instance); err != nil {
+				return nil, err
+			}
+			result.Updated++
+		}
+
+		if resource.LifecycleMode == "partial" && instance.Status == "active" {
+			if err := manageCloudResource(ctx, db, importer, resource); err != nil {
+				log.Printf("Error managing resource %d of cloud account %s: %v", resource.ID, account.Name, err)
+			}
+		}
+	}
+
+	// Instances removed from the account are flagged, not deleted
+	for _, resource := range existing {
+		if seen[resource.CloudInstanceID] || resource.DeletedAt.Valid {
+			continue
+		}
+		if err := db.Model(&Resource{}).Where("id = ?", resource.ID).UpdateColumns(map[string]interface{}{
+			"status":        "error",
+			"last_error":    fmt.Sprintf("Instance %s no longer exists in the cloud account", resource.CloudInstanceID),
+			"last_error_at": time.Now().UTC(),
+		}).Error; err != nil {
+			return nil, fmt.Errorf("failed to update resource %d: %w", resource.ID, err)
+		}
+		result.Missing++
+	}
+
+	return result, nil
+}
+
+// cloudConnectionInfo returns the connection info of an imported instance,
+// keeping keys set in NEST such as the database name
+func cloudConnectionInfo(current datatypes.JSON, account *CloudAccount, instance *CloudInstance) datatypes.JSON {
+	info := map[string]interface{}{}
+	decodeJSONField(current, &info, "connection info")
+	info["host"] = instance.Host
+	info["port"] = instance.Port
+	info["engine_version"] = instance.EngineVersion
+	info["cloud_provider"] = account.Provider
+	info["cloud_state"] = instance.State
+	if instance.CACertificate != "" {
+		info["server_ca"] = map[string]interface{}{
+			"certificate": instance.CACertificate,
+			"valid_until": instance.CAValidUntil,
+		}
+	} else {
+		delete(info, "server_ca")
+	}
+	encoded, _ := json.Marshal(info)
+	return datatypes.JSON(encoded)
+}
+
+// importCloudInstance creates the resource of a newly discovered instance.
+// Partial resources can back up through snapshots, and manage users where
+// the provider's API does.
+func importCloudInstance(db *gorm.DB, account *CloudAccount, instance *CloudInstance, typeID uint) (*Resource, error) {
+	name := cloudResourceName(instance.ID)
+	var conflicts int64
+	if err := db.Model(&Resource{}).Where("team_id = ? AND environment = ? AND name = ?",
+		account.TeamID, account.Environment, name).Count(&conflicts).Error; err != nil {
+		return nil, err
+	}
+	if conflicts > 0 {
+		return nil, fmt.Errorf("a resource named %s already exists in the team environment", name)
+	}
+
+	partial := account.LifecycleMode == "partial"
+	resource := &Resource{
+		Name:               name,
+		ResourceTypeID:     typeID,
+		TeamID:             account.TeamID,
+		Environment:        account.Environment,
+		Status:             instance.Status,
+		LifecycleMode:      account.LifecycleMode,
+		ProvisioningMethod: account.Provider,
+		ConnectionInfo:     cloudConnectionInfo(nil, account, instance),
+		CanBackup:          partial,
+		CanModifyUsers:     partial && account.Provider == CloudProviderGCP,
+		CreatedBy:          account.CreatedBy,
+		CloudAccountID:     &account.ID,
+		CloudInstanceID:    instance.ID,
+	}
+	if err := db.Create(resource).Error; err != nil {
+		return nil, err
+	}
+	log.Printf("Imported %s instance %s as resource %d (%s)", account.Provider, instance.ID, resource.ID, resource.Name)
+	return resource, nil
+}
+
+// updateCloudResource syncs a resource with its instance
+func updateCloudResource(db *gorm.DB, account *CloudAccount, resource *Resource, instance *CloudInstance) error {
+	updates := map[string]interface{}{
+		"status":          instance.Status,
+		"connection_info": cloudConnectionInfo(resource.ConnectionInfo, account, instance),
+	}
+	if instance.Status != "error" {
+		updates["last_error"] = ""
+		updates["last_error_at"] = nil
+	}
+	if err := db.Model(&Resource{}).Where("id = ?", resource.ID).UpdateColumns(updates).Error; err != nil {
+		return fmt.Errorf("failed to update resource %d: %w", resource.ID, err)
+	}
+	return nil
+}
+
+// manageCloudResource takes snapshots for the pending backup jobs of a
+// partial resource and syncs its pending users
+func manageCloudResource(ctx context.Context, db *gorm.DB, importer CloudImporter, resource *Resource) error {
+	now := time.Now().UTC()
+
+	if users, ok := importer.(cloudUserManager); ok && resource.CanModifyUsers {
+		pending, err := pendingResourceUsers(db, resource.ID)
+		if err != nil {
+			return err
+		}
+		for _, user := range pending {
+			result := &AgentTaskResult{ID: user.ID}
+			if err := users.SyncUser(ctx, resource.CloudInstanceID, user); err != nil {
+				result.Error = err.Error()
+			}
+			if err := recordUserSync(db, resource.ID, result, now); err != nil {
+				return err
+			}
+		}
+	}
+
+	if resource.CanBackup {
+		jobs, err := claimBackupJobs(db, resource.ID)
+		if err != nil {
+			return err
+		}
+		for _, job := range jobs {
+			result := &AgentTaskResult{ID: job.ID}
+			name := fmt.Sprintf("nest-%d-%d-%s", resource.ID, job.ID, now.Format("20060102150405"))
+			if result.Location, err = importer.Snapshot(ctx, resource.CloudInstanceID, name); err != nil {
+				result.Error = err.Error()
+			}
+			if err := recordBackupResult(db, resource.ID, result, now); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// rdsAPIVersion is the version of the RDS Query API
+const rdsAPIVersion = "2014-10-31"
+
+// rdsEngines maps RDS engines to resource type names
+var rdsEngines = map[string]string{
+	"postgres":          "postgresql",
+	"aurora-postgresql": "postgresql",
+	"mysql":             "mysql",
+	"aurora-mysql":      "mysql",
+	"mariadb":           "mariadb",
+}
+
+// rdsStatuses maps RDS instance statuses to resource statuses. Statuses not
+// listed are transitional and map to updating.
+var rdsStatuses = map[string]string{
+	"available":                           "active",
+	"storage-optimization":                "active",
+	"creating":                            "provisioning",
+	"stopped":                             "paused",
+	"stopping":                            "paused",
+	"failed":                              "error",
+	"inaccessible-encryption-credentials": "error",
+	"incompatible-network":                "error",
+	"incompatible-parameters":             "error",
+	"incompatible-restore":                "error",
+	"insufficient-capacity":               "error",
+	"restore-error":                       "error",
+	"storage-full":                        "error",
+}
+
+// rdsImporter discovers RDS instances through the RDS Query API, signing
+// requests with AWS Signature Version 4 as the archive object store does
+type rdsImporter struct {
+	endpoint  string
+	region    string
+	accessKey string
+	secretKey string
+	client    *http.Client
+}
+
+// newRDSImporter creates an importer for an AWS account's region
+func newRDSImporter(account *CloudAccount) *rdsImporter {
+	return &rdsImporter{
+		endpoint:  fmt.Sprintf("https://rds.%s.amazonaws.com/", account.Region),
+		region:    account.Region,
+		accessKey: account.AccessKeyID,
+		secretKey: account.SecretAccessKey,
+		client:    &http.Client{Timeout: time.Minute},
+	}
+}
+
+// rdsDBInstance is the subset of an RDS DB instance the importer uses
+type rdsDBInstance struct {
+	DBInstanceIdentifier string
+	Engine               string
+	EngineVersion        string
+	DBInstanceStatus     string
+	Endpoint             struct {
+		Address string
+		Port    int
+	}
+	CACertificateIdentifier string
+	CertificateDetails      struct {
+		ValidTill string
+	}
+}
+
+// ListInstances lists the DB instances of the region
+func (i *rdsImporter) ListInstances(ctx context.Context) ([]*CloudInstance, error) {
+	var instances []*CloudInstance
+	marker := ""
+	for {
+		params := url.Values{"Action": {"DescribeDBInstances"}}
+		if marker != "" {
+			params.Set("Marker", marker)
+		}
+		var resp struct {
+			Result struct {
+				Marker      string
+				DBInstances []rdsDBInstance `xml:"DBInstances>DBInstance"`
+			} `xml:"DescribeDBInstancesResult"`
+		}
+		if err := i.query(ctx, params, &resp); err != nil {
+			return nil, err
+		}
+
+		for _, db := range resp.Result.DBInstances {
+			status, ok := rdsStatuses[db.DBInstanceStatus]
+			if !ok {
+				status = "updating"
+			}
+			instance := &CloudInstance{
+				ID:            db.DBInstanceIdentifier,
+				Engine:        rdsEngines[db.Engine],
+				EngineVersion: db.EngineVersion,
+				State:         db.DBInstanceStatus,
+				Status:        status,
+				Host:          db.Endpoint.Address,
+				Port:          db.Endpoint.Port,
+				CACertificate: db.CACertificateIdentifier,
+			}
+			if validTill, err := time.Parse(time.RFC3339, db.CertificateDetails.ValidTill); err == nil {
+				instance.CAValidUntil = &validTill
+			}
+			instances = append(instances, instance)
+		}
+
+		if resp.Result.Marker == "" {
+			return instances, nil
+		}
+		marker = resp.Result.Marker
+	}
+}
+
+// Snapshot starts a manual DB snapshot and returns its ARN
+func (i *rdsImporter) Snapshot(ctx context.Context, instanceID, name string) (string, error) {
+	var resp struct {
+		Result struct {
+			DBSnapshot struct {
+				DBSnapshotArn string
+			}
+		} `xml:"CreateDBSnapshotResult"`
+	}
+	if err := i.query(ctx, url.Values{
+		"Action":               {"CreateDBSnapshot"},
+		"DBInstanceIdentifier": {instanceID},
+		"DBSnapshotIdentifier": {name},
+	}, &resp); err != nil {
+		return "", err
+	}
+	return resp.Result.DBSnapshot.DBSnapshotArn, nil
+}
+
+// query sends a signed Query API request and decodes its XML response
+func (i *rdsImporter) query(ctx context.Context, params url.Values, out interface{}) error {
+	params.Set("Version", rdsAPIVersion)
+	body := []byte(params.Encode())
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, i.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	i.sign(req, body, time.Now().UTC())
+
+	resp, err := i.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("RDS %s request failed: %w", params.Get("Action"), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var apiErr struct {
+			Error struct {
+				Code    string
+				Message string
+			}
+		}
+		raw, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		if xml.Unmarshal(raw, &apiErr) != nil || apiErr.Error.Code == "" {
+			return fmt.Errorf("RDS %s returned %d: %s", params.Get("Action"), resp.StatusCode, bytes.TrimSpace(raw))
+		}
+		return fmt.Errorf("RDS %s failed: %s: %s", params.Get("Action"), apiErr.Error.Code, apiErr.Error.Message)
+	}
+
+	return xml.NewDecoder(resp.Body).Decode(out)
+}
+
+// sign adds SigV4 authentication headers to a Query API request
+func (i *rdsImporter) sign(req *http.Request, body []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	signedHeaders := "content-type;host;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		"/",
+		"",
+		"content-type:" + req.Header.Get("Content-Type") + "\n" +
+			"host:" + req.URL.Host + "\n" +
+			"x-amz-date:" + amzDate + "\n",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + i.region + "/rds/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+i.secretKey), date)
+	key = hmacSHA256(key, i.region)
+	key = hmacSHA256(key, "rds")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		i.accessKey, scope, signedHeaders, signature))
+}
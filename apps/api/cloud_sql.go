@@ -0,0 +1,302 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// cloudSQLAPI is the base URL of the Cloud SQL Admin API
+	cloudSQLAPI = "https://sqladmin.googleapis.com/v1"
+	// cloudSQLScope is the OAuth scope the service account is granted
+	cloudSQLScope = "https://www.googleapis.com/auth/sqlservice.admin"
+	// googleTokenURL is used when a service account key names no token URI
+	googleTokenURL = "https://oauth2.googleapis.com/token"
+)
+
+// cloudSQLStatuses maps Cloud SQL instance states to resource statuses
+var cloudSQLStatuses = map[string]string{
+	"RUNNABLE":       "active",
+	"PENDING_CREATE": "provisioning",
+	"MAINTENANCE":    "updating",
+	"SUSPENDED":      "paused",
+	"STOPPED":        "paused",
+	"FAILED":         "error",
+}
+
+// serviceAccountKey is the subset of a GCP service account key file used to
+// obtain access tokens
+type serviceAccountKey struct {
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+	key         *rsa.PrivateKey
+}
+
+// parseServiceAccountKey parses a service account key file's JSON
+func parseServiceAccountKey(raw string) (*serviceAccountKey, error) {
+	var sa serviceAccountKey
+	if err := json.Unmarshal([]byte(raw), &sa); err != nil || sa.ClientEmail == "" || sa.PrivateKey == "" {
+		return nil, errors.New("service_account_key must be a service account key file with client_email and private_key")
+	}
+	block, _ := pem.Decode([]byte(sa.PrivateKey))
+	if block == nil {
+		return nil, errors.New("service account private_key is not PEM encoded")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid service account private_key: %v", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("service account private_key must be an RSA key")
+	}
+	sa.key = key
+	if sa.TokenURI == "" {
+		sa.TokenURI = googleTokenURL
+	}
+	return &sa, nil
+}
+
+// cloudSQLImporter discovers Cloud SQL instances through the Cloud SQL Admin
+// API, authenticating as a service account
+type cloudSQLImporter struct {
+	project string
+	account *serviceAccountKey
+	client  *http.Client
+	token   string
+	expiry  time.Time
+}
+
+// newCloudSQLImporter creates an importer for a GCP project
+func newCloudSQLImporter(account *CloudAccount) (*cloudSQLImporter, error) {
+	sa, err := parseServiceAccountKey(account.ServiceAccountKey)
+	if err != nil {
+		return nil, err
+	}
+	return &cloudSQLImporter{
+		project: account.Project,
+		account: sa,
+		client:  &http.Client{Timeout: time.Minute},
+	}, nil
+}
+
+// ListInstances lists the project's instances
+func (i *cloudSQLImporter) ListInstances(ctx context.Context) ([]*CloudInstance, error) {
+	var instances []*CloudInstance
+	pageToken := ""
+	for {
+		path := "/projects/" + url.PathEscape(i.project) + "/instances"
+		if pageToken != "" {
+			path += "?pageToken=" + url.QueryEscape(pageToken)
+		}
+		var resp struct {
+			Items []struct {
+				Name            string `json:"name"`
+				DatabaseVersion string `json:"databaseVersion"`
+				State           string `json:"state"`
+				IPAddresses     []struct {
+					Type      string `json:"type"`
+					IPAddress string `json:"ipAddress"`
+				} `json:"ipAddresses"`
+				ServerCaCert *struct {
+					SHA1Fingerprint string     `json:"sha1Fingerprint"`
+					ExpirationTime  *time.Time `json:"expirationTime"`
+				} `json:"serverCaCert"`
+			} `json:"items"`
+			NextPageToken string `json:"nextPageToken"`
+		}
+		if err := i.do(ctx, http.MethodGet, path, nil, &resp); err != nil {
+			return nil, err
+		}
+
+		for _, item := range resp.Items {
+			status, ok := cloudSQLStatuses[item.State]
+			if !ok {
+				status = "updating"
+			}
+			instance := &CloudInstance{
+				ID:            item.Name,
+				EngineVersion: item.DatabaseVersion,
+				State:         item.State,
+				Status:        status,
+			}
+			switch {
+			case strings.HasPrefix(item.DatabaseVersion, "POSTGRES_"):
+				instance.Engine, instance.Port = "postgresql", 5432
+			case strings.HasPrefix(item.DatabaseVersion, "MYSQL_"):
+				instance.Engine, instance.Port = "mysql", 3306
+			}
+			// Prefer the private address, for an API running in the same VPC
+			for _, addr := range item.IPAddresses {
+				if addr.Type == "PRIVATE" || instance.Host == "" {
+					instance.Host = addr.IPAddress
+				}
+			}
+			if item.ServerCaCert != nil {
+				instance.CACertificate = item.ServerCaCert.SHA1Fingerprint
+				instance.CAValidUntil = item.ServerCaCert.ExpirationTime
+			}
+			instances = append(instances, instance)
+		}
+
+		if resp.NextPageToken == "" {
+			return instances, nil
+		}
+		pageToken = resp.NextPageToken
+	}
+}
+
+// Snapshot starts an on-demand backup run and returns its operation
+func (i *cloudSQLImporter) Snapshot(ctx context.Context, instanceID, name string) (string, error) {
+	var op struct {
+		SelfLink string `json:"selfLink"`
+	}
+	if err := i.do(ctx, http.MethodPost, i.instancePath(instanceID)+"/backupRuns",
+		map[string]string{"description": name}, &op); err != nil {
+		return "", err
+	}
+	return op.SelfLink, nil
+}
+
+// SyncUser creates a built-in database user, or sets the password of an
+// existing one. Roles aren't managed by the API and are left to the engine.
+func (i *cloudSQLImporter) SyncUser(ctx context.Context, instanceID string, user *AgentUser) error {
+	body := map[string]string{"name": user.Username, "password": user.Password}
+	err := i.do(ctx, http.MethodPost, i.instancePath(instanceID)+"/users", body, nil)
+	if errors.Is(err, errCloudSQLConflict) {
+		err = i.do(ctx, http.MethodPut, i.instancePath(instanceID)+"/users?name="+url.QueryEscape(user.Username), body, nil)
+	}
+	return err
+}
+
+// instancePath returns the API path of an instance
+func (i *cloudSQLImporter) instancePath(instanceID string) string {
+	return "/projects/" + url.PathEscape(i.project) + "/instances/" + url.PathEscape(instanceID)
+}
+
+// errCloudSQLConflict is returned for requests that conflict with an
+// existing object, such as creating a user that exists
+var errCloudSQLConflict = errors.New("already exists")
+
+// do sends an authenticated API request and decodes its JSON response
+func (i *cloudSQLImporter) do(ctx context.Context, method, path string, body, out interface{}) error {
+	token, err := i.accessToken(ctx)
+	if err != nil {
+		return err
+	}
+
+	var reader io.Reader
+	if body != nil {
+		buf, _ := json.Marshal(body)
+		reader = bytes.NewReader(buf)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, cloudSQLAPI+path, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := i.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("Cloud SQL request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusConflict {
+		return errCloudSQLConflict
+	}
+	if resp.StatusCode >= 300 {
+		var apiErr struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		raw, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		if json.Unmarshal(raw, &apiErr) != nil || apiErr.Error.Message == "" {
+			return fmt.Errorf("Cloud SQL returned %d: %s", resp.StatusCode, bytes.TrimSpace(raw))
+		}
+		return fmt.Errorf("Cloud SQL returned %d: %s", resp.StatusCode, apiErr.Error.Message)
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// accessToken returns an OAuth access token for the service account,
+// exchanging a signed JWT for a new one when the last has expired
+func (i *cloudSQLImporter) accessToken(ctx context.Context) (string, error) {
+	if i.token != "" && time.Now().Before(i.expiry) {
+		return i.token, nil
+	}
+
+	now := time.Now()
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	claims, _ := json.Marshal(map[string]interface{}{
+		"iss":   i.account.ClientEmail,
+		"scope": cloudSQLScope,
+		"aud":   i.account.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	unsigned := header + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, i.account.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token request: %w", err)
+	}
+	assertion := unsigned + "." + base64.RawURLEncoding.EncodeToString(signature)
+
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, i.account.TokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := i.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var token struct {
+		AccessToken      string `json:"access_token"`
+		ExpiresIn        int    `json:"expires_in"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("invalid token response: %w", err)
+	}
+	if resp.StatusCode >= 300 || token.AccessToken == "" {
+		return "", fmt.Errorf("token request returned %d: %s", resp.StatusCode, token.ErrorDescription)
+	}
+
+	// Refresh a minute early
+	i.token = token.AccessToken
+	i.expiry = now.Add(time.Duration(token.ExpiresIn)*time.Second - time.Minute)
+	return i.token, nil
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Kubernetes name patterns for consumer binding targets
+var (
+	namespaceNamePattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+	secretNamePattern    = regexp.MustCompile(`^[a-z0-9]([-a-z0-9.]*[a-z0-9])?$`)
+)
+
+// defaultBindingSecretName is the Secret a binding writes unless named
+func defaultBindingSecretName(resourceID uint) string {
+	return fmt.Sprintf("nest-resource-%d", resourceID)
+}
+
+// validateBindingTarget checks the namespace and Secret name of a binding.
+// The kube- namespaces belong to the cluster itself.
+func validateBindingTarget(namespace, secretName string) error {
+	if !namespaceNamePattern.MatchString(namespace) || len(namespace) > 63 {
+		return fmt.Errorf("namespace must be a valid Kubernetes namespace name")
+	}
+	if strings.HasPrefix(namespace, "kube-") {
+		return fmt.Errorf("namespace %s is reserved for the cluster", namespace)
+	}
+	if !secretNamePattern.MatchString(secretName) || len(secretName) > 253 {
+		return fmt.Errorf("secret_name must be a valid Kubernetes Secret name")
+	}
+	return nil
+}
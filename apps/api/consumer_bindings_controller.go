@@ -0,0 +1,168 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/penguintechinc/project-template/shared/apierrors"
+	"github.com/penguintechinc/project-template/shared/audit"
+	"github.com/penguintechinc/project-template/shared/locks"
+	"gorm.io/gorm"
+)
+
+// ListConsumerBindings retrieves the application namespaces a resource is
+// bound to, with the sync status of their Secrets
+// GET /api/v1/resources/:id/bindings
+func (rc *ResourceController) ListConsumerBindings(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "User context not found")
+		return
+	}
+
+	resource, ok := rc.loadMemberResource(c, userID.(uint))
+	if !ok {
+		return
+	}
+
+	var bindings []*ConsumerBinding
+	if err := tenantDB(c, rc.db).Where("resource_id = ?", resource.ID).Order("id").Find(&bindings).Error; err != nil {
+		log.Printf("Error listing consumer bindings of resource %d: %v", resource.ID, err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to list consumer bindings")
+		return
+	}
+
+	c.JSON(http.StatusOK, ConsumerBindingListResponse{ConsumerBindings: bindings})
+}
+
+// CreateConsumerBinding binds a resource to an application namespace. The
+// K8s controller writes the Secret on its next sync and keeps it up to date
+// as credentials rotate. Requires the maintainer role in the resource's team.
+// POST /api/v1/resources/:id/bindings
+func (rc *ResourceController) CreateConsumerBinding(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "User context not found")
+		return
+	}
+
+	resource, ok := rc.loadMemberResource(c, userID.(uint))
+	if !ok || !rc.requireBindingRole(c, userID.(uint), resource) {
+		return
+	}
+
+	if resource.AgentID != nil {
+		apierrors.Abort(c, http.StatusBadRequest, "agent_managed", "Agent-managed resources can't be bound to namespaces")
+		return
+	}
+
+	var req CreateConsumerBindingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierrors.AbortWithDetails(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Invalid request body", err.Error())
+		return
+	}
+	if req.SecretName == "" {
+		req.SecretName = defaultBindingSecretName(resource.ID)
+	}
+	if err := validateBindingTarget(req.Namespace, req.SecretName); err != nil {
+		apierrors.Abort(c, http.StatusBadRequest, "invalid_binding", err.Error())
+		return
+	}
+
+	binding := &ConsumerBinding{
+		ResourceID: resource.ID,
+		TeamID:     resource.TeamID,
+		Namespace:  req.Namespace,
+		SecretName: req.SecretName,
+		Status:     "pending",
+		CreatedBy:  userID.(uint),
+	}
+
+	// The check and insert share a transaction holding a lock on the
+	// Secret, so a Secret is only ever kept for one binding
+	db := tenantDB(c, rc.db)
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := locks.Lock(tx, locks.Key("binding-secret", req.Namespace, req.SecretName)); err != nil {
+			return err
+		}
+		var existing int64
+		if err := tx.Model(&ConsumerBinding{}).Where("namespace = ? AND secret_name = ?", req.Namespace, req.SecretName).
+			Count(&existing).Error; err != nil {
+			return err
+		}
+		if existing > 0 {
+			return errBindingExists
+		}
+		return tx.Create(binding).Error
+	})
+	if errors.Is(err, errBindingExists) {
+		apierrors.Abort(c, http.StatusConflict, "binding_exists", "A binding already writes this Secret")
+		return
+	}
+	if err != nil {
+		log.Printf("Error binding resource %d to namespace %s: %v", resource.ID, req.Namespace, err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to create consumer binding")
+		return
+	}
+	if err := audit.Record(c, db, userID.(uint), "consumer_bindings", binding.ID, &resource.TeamID, nil, binding); err != nil {
+		log.Printf("Error recording creation of consumer binding %d in the audit log: %v", binding.ID, err)
+	}
+
+	c.JSON(http.StatusCreated, binding)
+}
+
+// errBindingExists is returned when another binding writes the same Secret
+var errBindingExists = errors.New("consumer binding already exists")
+
+// DeleteConsumerBinding unbinds a resource from a namespace. The K8s
+// controller deletes the Secret on its next sync. Requires the maintainer
+// role in the resource's team.
+// DELETE /api/v1/resources/:id/bindings/:binding_id
+func (rc *ResourceController) DeleteConsumerBinding(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "User context not found")
+		return
+	}
+
+	resource, ok := rc.loadMemberResource(c, userID.(uint))
+	if !ok || !rc.requireBindingRole(c, userID.(uint), resource) {
+		return
+	}
+
+	db := tenantDB(c, rc.db)
+	var binding ConsumerBinding
+	if err := db.Where("id = ? AND resource_id = ?", c.Param("binding_id"), resource.ID).First(&binding).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierrors.Abort(c, http.StatusNotFound, "binding_not_found", "Consumer binding not found")
+		} else {
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to retrieve consumer binding")
+		}
+		return
+	}
+
+	if err := db.Delete(&binding).Error; err != nil {
+		log.Printf("Error deleting consumer binding %d: %v", binding.ID, err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to delete consumer binding")
+		return
+	}
+	if err := audit.Record(c, db, userID.(uint), "consumer_bindings", binding.ID, &resource.TeamID, &binding, nil); err != nil {
+		log.Printf("Error recording deletion of consumer binding %d in the audit log: %v", binding.ID, err)
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// requireBindingRole checks the caller may change a resource's bindings,
+// which expose its credentials outside the team's namespace
+func (rc *ResourceController) requireBindingRole(c *gin.Context, userID uint, resource *Resource) bool {
+	userRole, _ := c.Get("user_role")
+	teamRole, _, err := rc.access.TeamRole(c.Request.Context(), userID, resource.TeamID)
+	if err != nil || (!hasMinimumRole(userRole, "admin") && !hasMinimumRole(teamRole, "maintainer")) {
+		apierrors.Abort(c, http.StatusForbidden, apierrors.CodeForbidden, "Insufficient permissions to manage consumer bindings")
+		return false
+	}
+	return true
+}
@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
@@ -11,6 +12,7 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/penguintechinc/project-template/apps/api/middleware"
 	"github.com/penguintechinc/project-template/apps/api/models"
+	"github.com/penguintechinc/project-template/shared/apierrors"
 	"gorm.io/gorm"
 )
 
@@ -44,9 +46,13 @@ type MeResponse struct {
 	User *UserResponse `json:"user"`
 }
 
+// PasswordChecker returns the password policy rules a password breaks
+type PasswordChecker func(ctx context.Context, password string) ([]string, error)
+
 // AuthController handles authentication endpoints
 type AuthController struct {
-	db *gorm.DB
+	db            *gorm.DB
+	checkPassword PasswordChecker
 }
 
 // NewAuthController creates a new auth controller
@@ -56,14 +62,18 @@ func NewAuthController(db *gorm.DB) *AuthController {
 	}
 }
 
+// WithPasswordPolicy rejects new passwords that break the password policy
+func (ac *AuthController) WithPasswordPolicy(check PasswordChecker) *AuthController {
+	ac.checkPassword = check
+	return ac
+}
+
 // Login handles user login and returns JWT token
 // POST /api/v1/auth/login
 func (ac *AuthController) Login(c *gin.Context) {
 	var req LoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "invalid request body",
-		})
+		apierrors.Abort(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Invalid request body")
 		return
 	}
 
@@ -72,23 +82,17 @@ func (ac *AuthController) Login(c *gin.Context) {
 	result := ac.db.Where("username = ?", req.Username).First(user)
 	if result.Error != nil {
 		if result.Error == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"error": "invalid username or password",
-			})
+			apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "Invalid username or password")
 			return
 		}
 		log.Printf("Database error: %v", result.Error)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "internal server error",
-		})
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeInternal, "Internal server error")
 		return
 	}
 
 	// Verify password
 	if !user.VerifyPassword(req.Password) {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": "invalid username or password",
-		})
+		apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "Invalid username or password")
 		return
 	}
 
@@ -107,9 +111,7 @@ func (ac *AuthController) Login(c *gin.Context) {
 	jwtSecret := os.Getenv("JWT_SECRET")
 	if jwtSecret == "" {
 		log.Println("JWT_SECRET environment variable not set")
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "internal server error",
-		})
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeInternal, "Internal server error")
 		return
 	}
 
@@ -117,9 +119,7 @@ func (ac *AuthController) Login(c *gin.Context) {
 	tokenString, err := token.SignedString([]byte(jwtSecret))
 	if err != nil {
 		log.Printf("Failed to sign token: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "failed to generate token",
-		})
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeInternal, "Failed to generate token")
 		return
 	}
 
@@ -141,9 +141,7 @@ func (ac *AuthController) Logout(c *gin.Context) {
 	// Get user from context to verify authentication
 	_, err := middleware.GetUserClaims(c)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": "unauthorized",
-		})
+		apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "Unauthorized")
 		return
 	}
 
@@ -160,9 +158,7 @@ func (ac *AuthController) Me(c *gin.Context) {
 	// Get user claims from context
 	userClaims, err := middleware.GetUserClaims(c)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": "unauthorized",
-		})
+		apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "Unauthorized")
 		return
 	}
 
@@ -171,15 +167,11 @@ func (ac *AuthController) Me(c *gin.Context) {
 	result := ac.db.First(user, userClaims.UserID)
 	if result.Error != nil {
 		if result.Error == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, gin.H{
-				"error": "user not found",
-			})
+			apierrors.Abort(c, http.StatusNotFound, apierrors.CodeNotFound, "User not found")
 			return
 		}
 		log.Printf("Database error: %v", result.Error)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "internal server error",
-		})
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeInternal, "Internal server error")
 		return
 	}
 
@@ -198,34 +190,39 @@ func (ac *AuthController) Me(c *gin.Context) {
 func (ac *AuthController) CreateUser(c *gin.Context) {
 	var user models.User
 	if err := c.ShouldBindJSON(&user); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "invalid request body",
-		})
+		apierrors.Abort(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Invalid request body")
 		return
 	}
 
 	// Validate input
 	if user.Username == "" || user.Email == "" || user.Password == "" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "username, email, and password are required",
-		})
+		apierrors.Abort(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Username, email, and password are required")
 		return
 	}
 
+	if ac.checkPassword != nil {
+		violations, err := ac.checkPassword(c.Request.Context(), user.Password)
+		if err != nil {
+			log.Printf("Failed to check password policy: %v", err)
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeInternal, "Internal server error")
+			return
+		}
+		if len(violations) > 0 {
+			apierrors.AbortWithDetails(c, http.StatusBadRequest, "weak_password", "The password does not meet the password policy", violations)
+			return
+		}
+	}
+
 	// Check if user already exists
 	existingUser := &models.User{}
 	result := ac.db.Where("username = ? OR email = ?", user.Username, user.Email).First(existingUser)
 	if result.Error == nil {
-		c.JSON(http.StatusConflict, gin.H{
-			"error": "username or email already exists",
-		})
+		apierrors.Abort(c, http.StatusConflict, apierrors.CodeConflict, "Username or email already exists")
 		return
 	}
 	if result.Error != gorm.ErrRecordNotFound {
 		log.Printf("Database error: %v", result.Error)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "internal server error",
-		})
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeInternal, "Internal server error")
 		return
 	}
 
@@ -233,9 +230,7 @@ func (ac *AuthController) CreateUser(c *gin.Context) {
 	result = ac.db.Create(&user)
 	if result.Error != nil {
 		log.Printf("Failed to create user: %v", result.Error)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": fmt.Sprintf("failed to create user: %v", result.Error),
-		})
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeInternal, fmt.Sprintf("failed to create user: %v", result.Error))
 		return
 	}
 
@@ -2,11 +2,17 @@ package controllers
 
 import (
 	"errors"
+	"log"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/penguintechinc/project-template/shared/apierrors"
+	"github.com/penguintechinc/project-template/shared/audit"
+	"github.com/penguintechinc/project-template/shared/database"
+	"github.com/penguintechinc/project-template/shared/fields"
 	"github.com/penguintechinc/project-template/shared/licensing"
+	"github.com/penguintechinc/project-template/shared/locks"
 	"gorm.io/gorm"
 )
 
@@ -59,20 +65,40 @@ func NewTeamsController(database *database.Database) *TeamsController {
 	}
 }
 
+// tenantDB returns the request tenant's connection pool, or db for platform
+// requests
+func tenantDB(c *gin.Context, db *gorm.DB) *gorm.DB {
+	return database.TenantDB(c.Request.Context(), db)
+}
+
+// unitOfWork runs fn as one transaction of the request's tenant database and
+// reports whether it committed. An *apierrors.Error fn returns is written as
+// it is, and any other error as a database error with message.
+func unitOfWork(c *gin.Context, db *gorm.DB, message string, fn func(tx *gorm.DB) error) bool {
+	err := database.UnitOfWork(c.Request.Context(), db, fn)
+	if err == nil {
+		return true
+	}
+	var apiErr *apierrors.Error
+	if !errors.As(err, &apiErr) {
+		log.Printf("%s: %v", message, err)
+		apiErr = apierrors.New(http.StatusInternalServerError, apierrors.CodeDatabaseError, message).Wrap(err)
+	}
+	apierrors.AbortWith(c, apiErr)
+	return false
+}
+
 // ListTeams retrieves all teams (scoped by user permissions)
 // GET /api/v1/teams
 func (tc *TeamsController) ListTeams(c *gin.Context) {
 	userCtx, err := licensing.GetUserContext(c)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error":   "unauthorized",
-			"message": "User context not found",
-		})
+		apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "User context not found")
 		return
 	}
 
 	var teams []Team
-	query := tc.db
+	query := tenantDB(c, tc.db)
 
 	// Non-global-admins only see teams they're members of
 	if userCtx.Role != "global_admin" {
@@ -81,11 +107,8 @@ func (tc *TeamsController) ListTeams(c *gin.Context) {
 			Group("teams.id")
 	}
 
-	if err := query.Preload("Members").Find(&teams).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "database_error",
-			"message": "Failed to retrieve teams",
-		})
+	if err := fields.Preload(c, query, "Members").Find(&teams).Error; err != nil {
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to retrieve teams")
 		return
 	}
 
@@ -105,44 +128,29 @@ func (tc *TeamsController) ListTeams(c *gin.Context) {
 func (tc *TeamsController) GetTeam(c *gin.Context) {
 	teamID, err := parseTeamID(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "invalid_team_id",
-			"message": "Team ID must be a valid number",
-		})
+		apierrors.Abort(c, http.StatusBadRequest, "invalid_team_id", "Team ID must be a valid number")
 		return
 	}
 
 	userCtx, err := licensing.GetUserContext(c)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error":   "unauthorized",
-			"message": "User context not found",
-		})
+		apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "User context not found")
 		return
 	}
 
 	var team Team
-	if err := tc.db.Preload("Members").First(&team, teamID).Error; err != nil {
+	if err := fields.Preload(c, tenantDB(c, tc.db), "Members").First(&team, teamID).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{
-				"error":   "not_found",
-				"message": "Team not found",
-			})
+			apierrors.Abort(c, http.StatusNotFound, apierrors.CodeNotFound, "Team not found")
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "database_error",
-			"message": "Failed to retrieve team",
-		})
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to retrieve team")
 		return
 	}
 
 	// Check user has access to this team
-	if !userCtx.IsGlobalAdmin() && !userIsMemberOfTeam(tc.db, teamID, userCtx.UserID) {
-		c.JSON(http.StatusForbidden, gin.H{
-			"error":   "insufficient_permissions",
-			"message": "User does not have access to this team",
-		})
+	if !userCtx.IsGlobalAdmin() && !userIsMemberOfTeam(tenantDB(c, tc.db), teamID, userCtx.UserID) {
+		apierrors.Abort(c, http.StatusForbidden, "insufficient_permissions", "User does not have access to this team")
 		return
 	}
 
@@ -154,43 +162,18 @@ func (tc *TeamsController) GetTeam(c *gin.Context) {
 func (tc *TeamsController) CreateTeam(c *gin.Context) {
 	userCtx, err := licensing.GetUserContext(c)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error":   "unauthorized",
-			"message": "User context not found",
-		})
+		apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "User context not found")
 		return
 	}
 
 	if !userCtx.IsGlobalAdmin() {
-		c.JSON(http.StatusForbidden, gin.H{
-			"error":   "insufficient_permissions",
-			"message": "Only global admins can create teams",
-		})
+		apierrors.Abort(c, http.StatusForbidden, "insufficient_permissions", "Only global admins can create teams")
 		return
 	}
 
 	var req CreateTeamRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "invalid_request",
-			"message": err.Error(),
-		})
-		return
-	}
-
-	// Check if team name already exists
-	var existingTeam Team
-	if err := tc.db.Where("name = ?", req.Name).First(&existingTeam).Error; err == nil {
-		c.JSON(http.StatusConflict, gin.H{
-			"error":   "duplicate_name",
-			"message": "Team name already exists",
-		})
-		return
-	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "database_error",
-			"message": "Failed to check team name uniqueness",
-		})
+		apierrors.Abort(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, err.Error())
 		return
 	}
 
@@ -200,101 +183,92 @@ func (tc *TeamsController) CreateTeam(c *gin.Context) {
 		IsGlobal:    false,
 	}
 
-	if err := tc.db.Create(&team).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "database_error",
-			"message": "Failed to create team",
-		})
+	// Check if team name already exists
+	if !unitOfWork(c, tc.db, "Failed to create team", func(tx *gorm.DB) error {
+		if err := checkTeamName(tx, req.Name, 0); err != nil {
+			return err
+		}
+		return tx.Create(&team).Error
+	}) {
 		return
 	}
 
 	c.JSON(http.StatusCreated, teamToResponse(team))
 }
 
+// checkTeamName returns a conflict when a team other than exclude has the
+// name. The name is locked until tx ends, so concurrent requests
+// for the same name on different replicas can't both pass the check.
+func checkTeamName(tx *gorm.DB, name string, exclude uint) error {
+	if err := locks.Lock(tx, locks.Key("team-name", name)); err != nil {
+		return err
+	}
+	var existing int64
+	if err := tx.Model(&Team{}).Where("name = ? AND id != ?", name, exclude).Count(&existing).Error; err != nil {
+		return err
+	}
+	if existing > 0 {
+		return apierrors.New(http.StatusConflict, "duplicate_name", "Team name already exists")
+	}
+	return nil
+}
+
 // UpdateTeam updates a team (TeamAdmin or GlobalAdmin)
 // PUT /api/v1/teams/:id
 func (tc *TeamsController) UpdateTeam(c *gin.Context) {
 	teamID, err := parseTeamID(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "invalid_team_id",
-			"message": "Team ID must be a valid number",
-		})
+		apierrors.Abort(c, http.StatusBadRequest, "invalid_team_id", "Team ID must be a valid number")
 		return
 	}
 
 	userCtx, err := licensing.GetUserContext(c)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error":   "unauthorized",
-			"message": "User context not found",
-		})
+		apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "User context not found")
 		return
 	}
 
 	var team Team
-	if err := tc.db.First(&team, teamID).Error; err != nil {
+	if err := tenantDB(c, tc.db).First(&team, teamID).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{
-				"error":   "not_found",
-				"message": "Team not found",
-			})
+			apierrors.Abort(c, http.StatusNotFound, apierrors.CodeNotFound, "Team not found")
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "database_error",
-			"message": "Failed to retrieve team",
-		})
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to retrieve team")
 		return
 	}
 
 	// Check permissions
 	if !userCtx.IsGlobalAdmin() {
-		if !userIsTeamAdminOfTeam(tc.db, teamID, userCtx.UserID) {
-			c.JSON(http.StatusForbidden, gin.H{
-				"error":   "insufficient_permissions",
-				"message": "User does not have admin rights in this team",
-			})
+		if !userIsTeamAdminOfTeam(tenantDB(c, tc.db), teamID, userCtx.UserID) {
+			apierrors.Abort(c, http.StatusForbidden, "insufficient_permissions", "User does not have admin rights in this team")
 			return
 		}
 	}
 
 	var req UpdateTeamRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "invalid_request",
-			"message": err.Error(),
-		})
+		apierrors.Abort(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, err.Error())
 		return
 	}
 
-	// Check if new name conflicts with existing team (excluding current team)
-	if req.Name != team.Name {
-		var existingTeam Team
-		if err := tc.db.Where("name = ? AND id != ?", req.Name, teamID).First(&existingTeam).Error; err == nil {
-			c.JSON(http.StatusConflict, gin.H{
-				"error":   "duplicate_name",
-				"message": "Team name already exists",
-			})
-			return
-		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error":   "database_error",
-				"message": "Failed to check team name uniqueness",
-			})
-			return
-		}
-	}
-
 	// Update team fields
+	before := team
 	team.Name = req.Name
 	team.Description = req.Description
 
-	if err := tc.db.Save(&team).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "database_error",
-			"message": "Failed to update team",
-		})
+	// Check if new name conflicts with existing team (excluding current team)
+	if !unitOfWork(c, tc.db, "Failed to update team", func(tx *gorm.DB) error {
+		if team.Name != before.Name {
+			if err := checkTeamName(tx, team.Name, team.ID); err != nil {
+				return err
+			}
+		}
+		if err := tx.Save(&team).Error; err != nil {
+			return err
+		}
+		return audit.Record(c, tx, userCtx.UserID, "teams", team.ID, &team.ID, &before, &team)
+	}) {
 		return
 	}
 
@@ -306,70 +280,45 @@ func (tc *TeamsController) UpdateTeam(c *gin.Context) {
 func (tc *TeamsController) DeleteTeam(c *gin.Context) {
 	teamID, err := parseTeamID(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "invalid_team_id",
-			"message": "Team ID must be a valid number",
-		})
+		apierrors.Abort(c, http.StatusBadRequest, "invalid_team_id", "Team ID must be a valid number")
 		return
 	}
 
 	userCtx, err := licensing.GetUserContext(c)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error":   "unauthorized",
-			"message": "User context not found",
-		})
+		apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "User context not found")
 		return
 	}
 
 	if !userCtx.IsGlobalAdmin() {
-		c.JSON(http.StatusForbidden, gin.H{
-			"error":   "insufficient_permissions",
-			"message": "Only global admins can delete teams",
-		})
+		apierrors.Abort(c, http.StatusForbidden, "insufficient_permissions", "Only global admins can delete teams")
 		return
 	}
 
 	var team Team
-	if err := tc.db.First(&team, teamID).Error; err != nil {
+	if err := tenantDB(c, tc.db).First(&team, teamID).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{
-				"error":   "not_found",
-				"message": "Team not found",
-			})
+			apierrors.Abort(c, http.StatusNotFound, apierrors.CodeNotFound, "Team not found")
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "database_error",
-			"message": "Failed to retrieve team",
-		})
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to retrieve team")
 		return
 	}
 
 	// Prevent deletion of global team
 	if team.IsGlobal {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "cannot_delete_global",
-			"message": "Cannot delete the global team",
-		})
-		return
-	}
-
-	// Delete associated team members
-	if err := tc.db.Where("team_id = ?", teamID).Delete(&TeamMember{}).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "database_error",
-			"message": "Failed to delete team members",
-		})
+		apierrors.Abort(c, http.StatusBadRequest, "cannot_delete_global", "Cannot delete the global team")
 		return
 	}
 
-	// Delete team
-	if err := tc.db.Delete(&team).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "database_error",
-			"message": "Failed to delete team",
-		})
+	// Delete associated team members along with the team, so a failure
+	// leaves both in place
+	if !unitOfWork(c, tc.db, "Failed to delete team", func(tx *gorm.DB) error {
+		if err := tx.Where("team_id = ?", teamID).Delete(&TeamMember{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&team).Error
+	}) {
 		return
 	}
 
@@ -383,54 +332,36 @@ func (tc *TeamsController) DeleteTeam(c *gin.Context) {
 func (tc *TeamsController) ListTeamMembers(c *gin.Context) {
 	teamID, err := parseTeamID(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "invalid_team_id",
-			"message": "Team ID must be a valid number",
-		})
+		apierrors.Abort(c, http.StatusBadRequest, "invalid_team_id", "Team ID must be a valid number")
 		return
 	}
 
 	userCtx, err := licensing.GetUserContext(c)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error":   "unauthorized",
-			"message": "User context not found",
-		})
+		apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "User context not found")
 		return
 	}
 
 	// Check if team exists
 	var team Team
-	if err := tc.db.First(&team, teamID).Error; err != nil {
+	if err := tenantDB(c, tc.db).First(&team, teamID).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{
-				"error":   "not_found",
-				"message": "Team not found",
-			})
+			apierrors.Abort(c, http.StatusNotFound, apierrors.CodeNotFound, "Team not found")
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "database_error",
-			"message": "Failed to retrieve team",
-		})
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to retrieve team")
 		return
 	}
 
 	// Check user has access to this team
-	if !userCtx.IsGlobalAdmin() && !userIsMemberOfTeam(tc.db, teamID, userCtx.UserID) {
-		c.JSON(http.StatusForbidden, gin.H{
-			"error":   "insufficient_permissions",
-			"message": "User does not have access to this team",
-		})
+	if !userCtx.IsGlobalAdmin() && !userIsMemberOfTeam(tenantDB(c, tc.db), teamID, userCtx.UserID) {
+		apierrors.Abort(c, http.StatusForbidden, "insufficient_permissions", "User does not have access to this team")
 		return
 	}
 
 	var members []TeamMember
-	if err := tc.db.Preload("User").Where("team_id = ?", teamID).Find(&members).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "database_error",
-			"message": "Failed to retrieve team members",
-		})
+	if err := tenantDB(c, tc.db).Preload("User").Where("team_id = ?", teamID).Find(&members).Error; err != nil {
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to retrieve team members")
 		return
 	}
 
@@ -450,89 +381,49 @@ func (tc *TeamsController) ListTeamMembers(c *gin.Context) {
 func (tc *TeamsController) AddTeamMember(c *gin.Context) {
 	teamID, err := parseTeamID(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "invalid_team_id",
-			"message": "Team ID must be a valid number",
-		})
+		apierrors.Abort(c, http.StatusBadRequest, "invalid_team_id", "Team ID must be a valid number")
 		return
 	}
 
 	userCtx, err := licensing.GetUserContext(c)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error":   "unauthorized",
-			"message": "User context not found",
-		})
+		apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "User context not found")
 		return
 	}
 
 	// Check if team exists
 	var team Team
-	if err := tc.db.First(&team, teamID).Error; err != nil {
+	if err := tenantDB(c, tc.db).First(&team, teamID).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{
-				"error":   "not_found",
-				"message": "Team not found",
-			})
+			apierrors.Abort(c, http.StatusNotFound, apierrors.CodeNotFound, "Team not found")
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "database_error",
-			"message": "Failed to retrieve team",
-		})
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to retrieve team")
 		return
 	}
 
 	// Check permissions
 	if !userCtx.IsGlobalAdmin() {
-		if !userIsTeamAdminOfTeam(tc.db, teamID, userCtx.UserID) {
-			c.JSON(http.StatusForbidden, gin.H{
-				"error":   "insufficient_permissions",
-				"message": "User does not have admin rights in this team",
-			})
+		if !userIsTeamAdminOfTeam(tenantDB(c, tc.db), teamID, userCtx.UserID) {
+			apierrors.Abort(c, http.StatusForbidden, "insufficient_permissions", "User does not have admin rights in this team")
 			return
 		}
 	}
 
 	var req AddMemberRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "invalid_request",
-			"message": err.Error(),
-		})
+		apierrors.Abort(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, err.Error())
 		return
 	}
 
 	// Check if user exists
 	var user User
-	if err := tc.db.First(&user, req.UserID).Error; err != nil {
+	if err := tenantDB(c, tc.db).First(&user, req.UserID).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{
-				"error":   "not_found",
-				"message": "User not found",
-			})
+			apierrors.Abort(c, http.StatusNotFound, apierrors.CodeNotFound, "User not found")
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "database_error",
-			"message": "Failed to retrieve user",
-		})
-		return
-	}
-
-	// Check if user is already a member
-	var existingMember TeamMember
-	if err := tc.db.Where("team_id = ? AND user_id = ?", teamID, req.UserID).First(&existingMember).Error; err == nil {
-		c.JSON(http.StatusConflict, gin.H{
-			"error":   "already_member",
-			"message": "User is already a member of this team",
-		})
-		return
-	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "database_error",
-			"message": "Failed to check membership status",
-		})
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to retrieve user")
 		return
 	}
 
@@ -542,11 +433,20 @@ func (tc *TeamsController) AddTeamMember(c *gin.Context) {
 		Role:   req.Role,
 	}
 
-	if err := tc.db.Create(&member).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "database_error",
-			"message": "Failed to add team member",
-		})
+	// The membership check, the new member and its audit entry are one unit
+	if !unitOfWork(c, tc.db, "Failed to add team member", func(tx *gorm.DB) error {
+		var existing int64
+		if err := tx.Model(&TeamMember{}).Where("team_id = ? AND user_id = ?", teamID, req.UserID).Count(&existing).Error; err != nil {
+			return err
+		}
+		if existing > 0 {
+			return apierrors.New(http.StatusConflict, "already_member", "User is already a member of this team")
+		}
+		if err := tx.Create(&member).Error; err != nil {
+			return err
+		}
+		return audit.Record(c, tx, userCtx.UserID, "team_members", member.ID, &member.TeamID, nil, &member)
+	}) {
 		return
 	}
 
@@ -558,82 +458,59 @@ func (tc *TeamsController) AddTeamMember(c *gin.Context) {
 func (tc *TeamsController) RemoveTeamMember(c *gin.Context) {
 	teamID, err := parseTeamID(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "invalid_team_id",
-			"message": "Team ID must be a valid number",
-		})
+		apierrors.Abort(c, http.StatusBadRequest, "invalid_team_id", "Team ID must be a valid number")
 		return
 	}
 
 	userIDStr := c.Param("user_id")
 	userID, err := strconv.ParseUint(userIDStr, 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "invalid_user_id",
-			"message": "User ID must be a valid number",
-		})
+		apierrors.Abort(c, http.StatusBadRequest, "invalid_user_id", "User ID must be a valid number")
 		return
 	}
 
 	userCtx, err := licensing.GetUserContext(c)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error":   "unauthorized",
-			"message": "User context not found",
-		})
+		apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "User context not found")
 		return
 	}
 
 	// Check if team exists
 	var team Team
-	if err := tc.db.First(&team, teamID).Error; err != nil {
+	if err := tenantDB(c, tc.db).First(&team, teamID).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{
-				"error":   "not_found",
-				"message": "Team not found",
-			})
+			apierrors.Abort(c, http.StatusNotFound, apierrors.CodeNotFound, "Team not found")
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "database_error",
-			"message": "Failed to retrieve team",
-		})
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to retrieve team")
 		return
 	}
 
 	// Check permissions
 	if !userCtx.IsGlobalAdmin() {
-		if !userIsTeamAdminOfTeam(tc.db, teamID, userCtx.UserID) {
-			c.JSON(http.StatusForbidden, gin.H{
-				"error":   "insufficient_permissions",
-				"message": "User does not have admin rights in this team",
-			})
+		if !userIsTeamAdminOfTeam(tenantDB(c, tc.db), teamID, userCtx.UserID) {
+			apierrors.Abort(c, http.StatusForbidden, "insufficient_permissions", "User does not have admin rights in this team")
 			return
 		}
 	}
 
 	// Check if member exists
 	var member TeamMember
-	if err := tc.db.Where("team_id = ? AND user_id = ?", teamID, uint(userID)).First(&member).Error; err != nil {
+	if err := tenantDB(c, tc.db).Where("team_id = ? AND user_id = ?", teamID, uint(userID)).First(&member).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{
-				"error":   "not_found",
-				"message": "Team member not found",
-			})
+			apierrors.Abort(c, http.StatusNotFound, apierrors.CodeNotFound, "Team member not found")
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "database_error",
-			"message": "Failed to retrieve team member",
-		})
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to retrieve team member")
 		return
 	}
 
-	if err := tc.db.Delete(&member).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "database_error",
-			"message": "Failed to remove team member",
-		})
+	if !unitOfWork(c, tc.db, "Failed to remove team member", func(tx *gorm.DB) error {
+		if err := tx.Delete(&member).Error; err != nil {
+			return err
+		}
+		return audit.Record(c, tx, userCtx.UserID, "team_members", member.ID, &member.TeamID, &member, nil)
+	}) {
 		return
 	}
 
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/penguintechinc/project-template/shared/apierrors"
+	"gorm.io/gorm"
+)
+
+// credentialUsageTypes are the resource types whose connections the K8s
+// controller's stats collection sees
+var credentialUsageTypes = []string{"postgresql", "mariadb", "mysql"}
+
+// CredentialUsageController reports database accounts that aren't used,
+// for access reviews
+type CredentialUsageController struct {
+	db         *gorm.DB
+	access     *AccessCache
+	staleAfter time.Duration
+}
+
+// NewCredentialUsageController creates a new credential usage controller.
+// Accounts not seen connected for staleAfter are listed as unused.
+func NewCredentialUsageController(db *gorm.DB, access *AccessCache, staleAfter time.Duration) *CredentialUsageController {
+	return &CredentialUsageController{db: db, access: access, staleAfter: staleAfter}
+}
+
+// ListUnusedAccounts lists the accounts of a team's resources in the
+// manager's resource_users table not seen connected for CREDENTIAL_STALE_AFTER,
+// or ?days=, longest unused first. Accounts never seen count from when they
+// were created. Only resources whose connections the K8s controller
+// collects are reviewed.
+// GET /api/v1/teams/:id/unused-accounts
+func (uc *CredentialUsageController) ListUnusedAccounts(c *gin.Context) {
+	teamID, role, ok := teamAccess(c, uc.access)
+	if !ok {
+		return
+	}
+	if !hasMinimumRole(role, "admin") {
+		apierrors.Abort(c, http.StatusForbidden, apierrors.CodeForbidden, "Team admin access required")
+		return
+	}
+
+	staleAfter := uc.staleAfter
+	if v := c.Query("days"); v != "" {
+		days, err := strconv.Atoi(v)
+		if err != nil || days <= 0 {
+			apierrors.Abort(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "days must be a positive number")
+			return
+		}
+		staleAfter = time.Duration(days) * 24 * time.Hour
+	}
+	unusedSince := time.Now().UTC().Add(-staleAfter)
+
+	accounts := []*UnusedAccount{}
+	db := tenantDB(c, uc.db)
+	if db.Migrator().HasTable("resource_users") {
+		if err := db.Raw(`SELECT ru.id AS resource_user_id, ru.resource_id, r.name AS resource_name, r.environment,
+				ru.username, ru.created_at, cu.last_seen_at
+			FROM resource_users ru
+			INNER JOIN resources r ON r.id = ru.resource_id AND r.deleted_at IS NULL
+			INNER JOIN resource_types rt ON rt.id = r.resource_type_id
+			LEFT JOIN credential_usages cu ON cu.resource_id = ru.resource_id AND cu.username = ru.username
+			WHERE r.team_id = ? AND ru.deleted_at IS NULL
+			  AND r.lifecycle_mode IN ('full', 'partial') AND r.agent_id IS NULL AND rt.name IN ?
+			  AND COALESCE(cu.last_seen_at, ru.created_at) < ?
+			ORDER BY COALESCE(cu.last_seen_at, ru.created_at), r.name, ru.username`,
+			teamID, credentialUsageTypes, unusedSince).Scan(&accounts).Error; err != nil {
+			log.Printf("Error listing unused accounts of team %d: %v", teamID, err)
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to list unused accounts")
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"unused_since": unusedSince,
+		"accounts":     accounts,
+	})
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/penguintechinc/project-template/shared/apierrors"
+	"github.com/penguintechinc/project-template/shared/database"
+	"github.com/penguintechinc/project-template/shared/diagnostics"
+	"gorm.io/gorm"
+)
+
+// DiagnosticsController serves runtime diagnostics and pprof profiles for
+// troubleshooting in production
+type DiagnosticsController struct {
+	db        *database.Database
+	queues    *gorm.DB
+	startedAt time.Time
+}
+
+// NewDiagnosticsController creates a new diagnostics controller. Queue
+// depths are read from queues, which should be the primary so they are
+// current.
+func NewDiagnosticsController(db *database.Database, queues *gorm.DB) *DiagnosticsController {
+	return &DiagnosticsController{db: db, queues: queues, startedAt: time.Now().UTC()}
+}
+
+// GetDiagnostics reports the replica's goroutines, memory and GC, database
+// connection pools, and the depths of the job, reconcile and operation
+// queues
+// GET /api/v1/admin/diagnostics
+func (dc *DiagnosticsController) GetDiagnostics(c *gin.Context) {
+	if !requireGlobalAdmin(c) {
+		return
+	}
+	dc.serveDiagnostics(c)
+}
+
+// GetProfile serves a pprof profile of the replica, such as heap or
+// goroutine, or the index of profiles
+// GET /api/v1/admin/debug/pprof/*profile
+func (dc *DiagnosticsController) GetProfile(c *gin.Context) {
+	if !requireGlobalAdmin(c) {
+		return
+	}
+	diagnostics.ServeProfile(c.Writer, c.Request, c.Param("profile"))
+}
+
+// LocalDiagnostics serves the diagnostics to loopback clients, such as
+// through kubectl port-forward, without authentication
+// GET /debug/diagnostics
+func (dc *DiagnosticsController) LocalDiagnostics(c *gin.Context) {
+	if !requireLocalClient(c) {
+		return
+	}
+	dc.serveDiagnostics(c)
+}
+
+// LocalProfile serves pprof profiles to loopback clients without
+// authentication
+// GET /debug/pprof/*profile
+func (dc *DiagnosticsController) LocalProfile(c *gin.Context) {
+	if !requireLocalClient(c) {
+		return
+	}
+	diagnostics.ServeProfile(c.Writer, c.Request, c.Param("profile"))
+}
+
+// requireLocalClient writes the error response when the request doesn't
+// come from a loopback address. The connection's address is used rather
+// than forwarding headers, which clients can set.
+func requireLocalClient(c *gin.Context) bool {
+	if !diagnostics.IsLocal(c.Request.RemoteAddr) {
+		apierrors.Abort(c, http.StatusForbidden, apierrors.CodeForbidden, "Diagnostics are only served to local clients")
+		return false
+	}
+	return true
+}
+
+func (dc *DiagnosticsController) serveDiagnostics(c *gin.Context) {
+	queues, err := dc.queueDepths(c)
+	if err != nil {
+		log.Printf("Error reading queue depths: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to read queue depths")
+		return
+	}
+
+	pools := map[string]diagnostics.PoolStats{}
+	for name, pool := range dc.db.Pools() {
+		pools[name] = diagnostics.Pool(pool)
+	}
+
+	c.JSON(http.StatusOK, DiagnosticsResponse{
+		Runtime:  diagnostics.Runtime(dc.startedAt),
+		Database: pools,
+		Queues:   *queues,
+	})
+}
+
+// queueDepths counts the work waiting in the database-backed queues
+func (dc *DiagnosticsController) queueDepths(c *gin.Context) (*QueueDiagnostics, error) {
+	db := dc.queues.WithContext(c.Request.Context())
+	queues := &QueueDiagnostics{
+		Jobs:       map[string]map[string]int64{},
+		Operations: map[string]int64{},
+	}
+
+	var jobs []struct {
+		Status string
+		Kind   string
+		Count  int64
+	}
+	if err := db.Model(&Job{}).Select("status, kind, COUNT(*) AS count").
+		Where("status IN ?", []string{JobQueued, JobRunning, JobDead}).
+		Group("status, kind").Scan(&jobs).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range jobs {
+		if queues.Jobs[row.Status] == nil {
+			queues.Jobs[row.Status] = map[string]int64{}
+		}
+		queues.Jobs[row.Status][row.Kind] = row.Count
+	}
+
+	if err := db.Model(&Job{}).Where("status = ? AND run_at <= ?", JobQueued, time.Now().UTC()).
+		Count(&queues.DueJobs).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Model(&ReconcileRequest{}).Where("processed_at IS NULL").
+		Count(&queues.ReconcileRequests).Error; err != nil {
+		return nil, err
+	}
+
+	var operations []struct {
+		Status string
+		Count  int64
+	}
+	if err := db.Model(&Operation{}).Select("status, COUNT(*) AS count").
+		Where("status IN ?", []string{OperationPending, OperationRunning}).
+		Group("status").Scan(&operations).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range operations {
+		queues.Operations[row.Status] = row.Count
+	}
+	return queues, nil
+}
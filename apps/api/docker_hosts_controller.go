@@ -0,0 +1,257 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+	"github.com/penguintechinc/project-template/shared/apierrors"
+	"github.com/penguintechinc/project-template/shared/audit"
+	"gorm.io/gorm"
+)
+
+// dockerRuntimes are the container runtimes whose Engine API the K8s
+// controller can drive. Podman serves the Docker-compatible API.
+var dockerRuntimes = map[string]bool{"docker": true, "podman": true}
+
+// DockerHostController handles Docker host HTTP requests
+type DockerHostController struct {
+	db *gorm.DB
+}
+
+// NewDockerHostController creates a new Docker host controller
+func NewDockerHostController(db *gorm.DB) *DockerHostController {
+	return &DockerHostController{db: db}
+}
+
+// validateDockerHost checks a host's endpoint, runtime, and TLS material.
+// The Engine API is only reached over mutual TLS, as it grants root on the
+// host.
+func validateDockerHost(host *DockerHost) error {
+	endpoint, err := url.Parse(host.Endpoint)
+	if err != nil || (endpoint.Scheme != "tcp" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		return errors.New("endpoint must be a tcp:// or https:// URL, such as tcp://edge-1.example.com:2376")
+	}
+	if !dockerRuntimes[host.Runtime] {
+		return errors.New("runtime must be one of: docker, podman")
+	}
+	if !x509.NewCertPool().AppendCertsFromPEM([]byte(host.CACert)) {
+		return errors.New("ca_cert must hold a PEM encoded certificate")
+	}
+	if _, err := tls.X509KeyPair([]byte(host.ClientCert), []byte(host.ClientKey)); err != nil {
+		return fmt.Errorf("client_cert and client_key must be a PEM encoded key pair: %v", err)
+	}
+	return nil
+}
+
+// loadDockerHost returns the Docker host named by the path
+func (dc *DockerHostController) loadDockerHost(c *gin.Context) (*DockerHost, bool) {
+	var host DockerHost
+	if err := tenantDB(c, dc.db).First(&host, c.Param("id")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierrors.Abort(c, http.StatusNotFound, "docker_host_not_found", "Docker host not found")
+		} else {
+			log.Printf("Error retrieving Docker host: %v", err)
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to retrieve Docker host")
+		}
+		return nil, false
+	}
+	return &host, true
+}
+
+// ListDockerHosts retrieves the Docker hosts and the number of resources on
+// each
+// GET /api/v1/docker-hosts
+func (dc *DockerHostController) ListDockerHosts(c *gin.Context) {
+	if !requireGlobalAdmin(c) {
+		return
+	}
+
+	db := tenantDB(c, dc.db)
+	var hosts []*DockerHost
+	if err := db.Order("name").Find(&hosts).Error; err != nil {
+		log.Printf("Error listing Docker hosts: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to list Docker hosts")
+		return
+	}
+
+	var counts []struct {
+		DockerHostID uint
+		Count        int64
+	}
+	if err := db.Model(&Resource{}).Select("docker_host_id, COUNT(*) AS count").
+		Where("docker_host_id IS NOT NULL").Group("docker_host_id").Scan(&counts).Error; err != nil {
+		log.Printf("Error counting Docker host resources: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to list Docker hosts")
+		return
+	}
+	resources := make(map[uint]int64, len(counts))
+	for _, count := range counts {
+		resources[count.DockerHostID] = count.Count
+	}
+
+	response := make([]*DockerHostResponse, 0, len(hosts))
+	for _, host := range hosts {
+		response = append(response, &DockerHostResponse{DockerHost: host, Resources: resources[host.ID]})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"docker_hosts": response})
+}
+
+// CreateDockerHost registers a Docker or Podman host
+// POST /api/v1/docker-hosts
+func (dc *DockerHostController) CreateDockerHost(c *gin.Context) {
+	if !requireGlobalAdmin(c) {
+		return
+	}
+
+	var req CreateDockerHostRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierrors.AbortWithDetails(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	host := &DockerHost{
+		Name:             req.Name,
+		Endpoint:         req.Endpoint,
+		Runtime:          req.Runtime,
+		AdvertiseAddress: req.AdvertiseAddress,
+		CACert:           req.CACert,
+		ClientCert:       req.ClientCert,
+		ClientKey:        req.ClientKey,
+		CreatedBy:        c.MustGet("user_id").(uint),
+	}
+	if host.Runtime == "" {
+		host.Runtime = "docker"
+	}
+	if err := validateDockerHost(host); err != nil {
+		apierrors.Abort(c, http.StatusBadRequest, "invalid_docker_host", err.Error())
+		return
+	}
+
+	db := tenantDB(c, dc.db)
+	var existing int64
+	if err := db.Model(&DockerHost{}).Where("name = ?", host.Name).Count(&existing).Error; err != nil {
+		log.Printf("Error checking Docker host names: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to create Docker host")
+		return
+	}
+	if existing > 0 {
+		apierrors.Abort(c, http.StatusConflict, "docker_host_exists", "A Docker host with this name already exists")
+		return
+	}
+
+	if err := db.Create(host).Error; err != nil {
+		log.Printf("Error creating Docker host: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to create Docker host")
+		return
+	}
+	if err := audit.Record(c, db, host.CreatedBy, "docker_hosts", host.ID, nil, nil, host); err != nil {
+		log.Printf("Error recording creation of Docker host %d in the audit log: %v", host.ID, err)
+	}
+
+	c.JSON(http.StatusCreated, host)
+}
+
+// UpdateDockerHost updates a Docker host's endpoint or TLS material, such as
+// when its certificates are rotated
+// PUT /api/v1/docker-hosts/:id
+func (dc *DockerHostController) UpdateDockerHost(c *gin.Context) {
+	if !requireGlobalAdmin(c) {
+		return
+	}
+
+	host, ok := dc.loadDockerHost(c)
+	if !ok {
+		return
+	}
+	before := *host
+
+	var req UpdateDockerHostRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierrors.AbortWithDetails(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Invalid request body", err.Error())
+		return
+	}
+	if (req.ClientCert == nil) != (req.ClientKey == nil) {
+		apierrors.Abort(c, http.StatusBadRequest, "invalid_docker_host", "client_cert and client_key must be updated together")
+		return
+	}
+
+	if req.Endpoint != nil {
+		host.Endpoint = *req.Endpoint
+	}
+	if req.AdvertiseAddress != nil {
+		host.AdvertiseAddress = *req.AdvertiseAddress
+	}
+	if req.CACert != nil {
+		host.CACert = *req.CACert
+	}
+	if req.ClientCert != nil {
+		host.ClientCert = *req.ClientCert
+		host.ClientKey = *req.ClientKey
+	}
+	if err := validateDockerHost(host); err != nil {
+		apierrors.Abort(c, http.StatusBadRequest, "invalid_docker_host", err.Error())
+		return
+	}
+
+	db := tenantDB(c, dc.db)
+	if err := db.Save(host).Error; err != nil {
+		log.Printf("Error updating Docker host %d: %v", host.ID, err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to update Docker host")
+		return
+	}
+	userID := c.MustGet("user_id").(uint)
+	if err := audit.Record(c, db, userID, "docker_hosts", host.ID, nil, &before, host); err != nil {
+		log.Printf("Error recording update of Docker host %d in the audit log: %v", host.ID, err)
+	}
+
+	c.JSON(http.StatusOK, host)
+}
+
+// DeleteDockerHost removes a Docker host. Hosts with resources, including
+// deleted resources whose containers haven't been removed yet, can't be
+// removed.
+// DELETE /api/v1/docker-hosts/:id
+func (dc *DockerHostController) DeleteDockerHost(c *gin.Context) {
+	if !requireGlobalAdmin(c) {
+		return
+	}
+
+	host, ok := dc.loadDockerHost(c)
+	if !ok {
+		return
+	}
+
+	db := tenantDB(c, dc.db)
+	var assigned int64
+	if err := db.Unscoped().Model(&Resource{}).
+		Where("docker_host_id = ? AND deletion_state NOT IN ?", host.ID,
+			[]string{DeletionStateDeprovisioned, DeletionStatePurged}).
+		Count(&assigned).Error; err != nil {
+		log.Printf("Error counting resources of Docker host %s: %v", host.Name, err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to delete Docker host")
+		return
+	}
+	if assigned > 0 {
+		apierrors.Abort(c, http.StatusConflict, "docker_host_in_use", "The Docker host still has resources")
+		return
+	}
+
+	if err := db.Unscoped().Delete(host).Error; err != nil {
+		log.Printf("Error deleting Docker host %s: %v", host.Name, err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to delete Docker host")
+		return
+	}
+	userID := c.MustGet("user_id").(uint)
+	if err := audit.Record(c, db, userID, "docker_hosts", host.ID, nil, host, nil); err != nil {
+		log.Printf("Error recording deletion of Docker host %d in the audit log: %v", host.ID, err)
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/penguintechinc/project-template/shared/apierrors"
+	"gorm.io/gorm"
+)
+
+// EmailController handles mail template HTTP requests
+type EmailController struct {
+	db     *gorm.DB
+	mailer Mailer
+}
+
+// NewEmailController creates a new email controller. mailer is nil when
+// mail isn't configured.
+func NewEmailController(db *gorm.DB, mailer Mailer) *EmailController {
+	return &EmailController{db: db, mailer: mailer}
+}
+
+// templateName returns the built-in template named by the :name path
+// parameter
+func templateName(c *gin.Context) (string, bool) {
+	name := c.Param("name")
+	if _, ok := defaultMailTemplates[name]; !ok {
+		apierrors.Abort(c, http.StatusNotFound, apierrors.CodeNotFound, "Email template not found")
+		return "", false
+	}
+	return name, true
+}
+
+// loadTemplate writes the error response when a template can't be loaded
+func (ec *EmailController) loadTemplate(c *gin.Context, name string) (mailTemplate, bool, bool) {
+	tmpl, overridden, err := loadMailTemplate(tenantDB(c, ec.db), name)
+	if err != nil {
+		log.Printf("Error retrieving email template %s: %v", name, err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to retrieve email template")
+		return tmpl, false, false
+	}
+	return tmpl, overridden, true
+}
+
+// ListEmailTemplates retrieves every mail template as it is sent
+// GET /api/v1/admin/email-templates
+func (ec *EmailController) ListEmailTemplates(c *gin.Context) {
+	if !requirePlatformAdmin(c) {
+		return
+	}
+
+	templates := make([]EmailTemplateResponse, 0, len(defaultMailTemplates))
+	for name := range defaultMailTemplates {
+		tmpl, overridden, ok := ec.loadTemplate(c, name)
+		if !ok {
+			return
+		}
+		templates = append(templates, EmailTemplateResponse{
+			Name: name, Subject: tmpl.Subject, Text: tmpl.Text, HTML: tmpl.HTML, Overridden: overridden,
+		})
+	}
+	sort.Slice(templates, func(i, j int) bool { return templates[i].Name < templates[j].Name })
+
+	c.JSON(http.StatusOK, gin.H{"email_templates": templates, "mail_configured": ec.mailer != nil})
+}
+
+// GetEmailTemplate retrieves a mail template as it is sent
+// GET /api/v1/admin/email-templates/:name
+func (ec *EmailController) GetEmailTemplate(c *gin.Context) {
+	if !requirePlatformAdmin(c) {
+		return
+	}
+	name, ok := templateName(c)
+	if !ok {
+		return
+	}
+	tmpl, overridden, ok := ec.loadTemplate(c, name)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, EmailTemplateResponse{
+		Name: name, Subject: tmpl.Subject, Text: tmpl.Text, HTML: tmpl.HTML, Overridden: overridden,
+	})
+}
+
+// UpdateEmailTemplate overrides a mail template. The override must render
+// the template's sample data.
+// PUT /api/v1/admin/email-templates/:name
+func (ec *EmailController) UpdateEmailTemplate(c *gin.Context) {
+	if !requirePlatformAdmin(c) {
+		return
+	}
+	name, ok := templateName(c)
+	if !ok {
+		return
+	}
+
+	var req EmailTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierrors.AbortWithDetails(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Invalid request body", err.Error())
+		return
+	}
+	candidate := mailTemplate{Subject: req.Subject, Text: req.Text, HTML: req.HTML}
+	if _, err := candidate.render(defaultMailTemplates[name].Sample); err != nil {
+		apierrors.AbortWithDetails(c, http.StatusBadRequest, "invalid_template", "Email template does not render", err.Error())
+		return
+	}
+
+	db := tenantDB(c, ec.db)
+	var override EmailTemplate
+	if err := db.Where("name = ?", name).First(&override).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Printf("Error retrieving email template %s: %v", name, err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to retrieve email template")
+		return
+	}
+	override.Name = name
+	override.Subject = req.Subject
+	override.Text = req.Text
+	override.HTML = req.HTML
+	override.UpdatedBy = c.MustGet("user_id").(uint)
+
+	if err := db.Save(&override).Error; err != nil {
+		log.Printf("Error saving email template %s: %v", name, err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to save email template")
+		return
+	}
+
+	c.JSON(http.StatusOK, EmailTemplateResponse{
+		Name: name, Subject: override.Subject, Text: override.Text, HTML: override.HTML, Overridden: true,
+	})
+}
+
+// DeleteEmailTemplate removes a template's override, returning it to the
+// built-in template
+// DELETE /api/v1/admin/email-templates/:name
+func (ec *EmailController) DeleteEmailTemplate(c *gin.Context) {
+	if !requirePlatformAdmin(c) {
+		return
+	}
+	name, ok := templateName(c)
+	if !ok {
+		return
+	}
+
+	result := tenantDB(c, ec.db).Unscoped().Where("name = ?", name).Delete(&EmailTemplate{})
+	if result.Error != nil {
+		log.Printf("Error deleting email template %s: %v", name, result.Error)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to delete email template")
+		return
+	}
+	if result.RowsAffected == 0 {
+		apierrors.Abort(c, http.StatusNotFound, apierrors.CodeNotFound, "Email template override not found")
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// TestEmailTemplate sends a mail template to an address, rendered with the
+// request's data merged over the template's sample data
+// POST /api/v1/admin/email-templates/:name/test
+func (ec *EmailController) TestEmailTemplate(c *gin.Context) {
+	if !requirePlatformAdmin(c) {
+		return
+	}
+	name, ok := templateName(c)
+	if !ok {
+		return
+	}
+	if ec.mailer == nil {
+		apierrors.Abort(c, http.StatusServiceUnavailable, "mail_not_configured", "Mail is not configured")
+		return
+	}
+
+	var req TestEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierrors.AbortWithDetails(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	tmpl, _, ok := ec.loadTemplate(c, name)
+	if !ok {
+		return
+	}
+	data := make(map[string]interface{}, len(tmpl.Sample)+len(req.Data))
+	for k, v := range tmpl.Sample {
+		data[k] = v
+	}
+	for k, v := range req.Data {
+		data[k] = v
+	}
+	msg, err := tmpl.render(data)
+	if err != nil {
+		apierrors.AbortWithDetails(c, http.StatusBadRequest, "invalid_template", "Email template does not render", err.Error())
+		return
+	}
+	msg.To = []string{req.To}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+	if err := ec.mailer.Send(ctx, msg); err != nil {
+		log.Printf("Error test-sending email template %s: %v", name, err)
+		apierrors.AbortWithDetails(c, http.StatusBadGateway, "mail_delivery_failed", "Failed to send email", err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"sent": true, "to": req.To, "subject": msg.Subject})
+}
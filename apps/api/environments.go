@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+
+	"github.com/penguintechinc/project-template/shared/locks"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// Config keys populated from environment defaults
+const (
+	configKeyBackupSchedule   = "backup_schedule"
+	configKeyHighAvailability = "high_availability"
+)
+
+// DefaultEnvironments is the promotion pipeline of teams that have not
+// defined their own
+var DefaultEnvironments = []Environment{
+	{Name: "dev", Position: 0},
+	{Name: "staging", Position: 1, BackupSchedule: "0 3 * * *"},
+	{Name: "prod", Position: 2, BackupSchedule: "0 * * * *", HighAvailability: true, RequiresApproval: true},
+}
+
+// teamEnvironments returns a team's promotion pipeline in order, falling back
+// to DefaultEnvironments
+func teamEnvironments(db *gorm.DB, teamID uint) ([]Environment, error) {
+	var envs []Environment
+	if err := db.Where("team_id = ?", teamID).Order("position ASC").Find(&envs).Error; err != nil {
+		return nil, err
+	}
+	if len(envs) == 0 {
+		envs = make([]Environment, len(DefaultEnvironments))
+		copy(envs, DefaultEnvironments)
+		for i := range envs {
+			envs[i].TeamID = teamID
+		}
+	}
+	return envs, nil
+}
+
+// findEnvironment returns the index of the named environment in a pipeline,
+// or -1 if it is not part of it
+func findEnvironment(envs []Environment, name string) int {
+	for i := range envs {
+		if envs[i].Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// applyEnvironmentDefaults fills config keys the caller did not set from the
+// environment's defaults
+func applyEnvironmentDefaults(cfg map[string]interface{}, env *Environment) map[string]interface{} {
+	if cfg == nil {
+		cfg = make(map[string]interface{})
+	}
+	if _, ok := cfg[configKeyBackupSchedule]; !ok && env.BackupSchedule != "" {
+		cfg[configKeyBackupSchedule] = env.BackupSchedule
+	}
+	if _, ok := cfg[configKeyHighAvailability]; !ok && env.HighAvailability {
+		cfg[configKeyHighAvailability] = true
+	}
+	if _, ok := cfg[configKeyMaintenanceWindow]; !ok && env.MaintenanceWindow != "" {
+		cfg[configKeyMaintenanceWindow] = env.MaintenanceWindow
+	}
+	return cfg
+}
+
+// promotedConfig clones a source config for the target environment. Keys that
+// are governed by environment defaults are replaced with the target's values.
+func promotedConfig(source map[string]interface{}, target *Environment) map[string]interface{} {
+	cfg := make(map[string]interface{}, len(source))
+	for k, v := range source {
+		if k == configKeyBackupSchedule || k == configKeyHighAvailability || k == configKeyMaintenanceWindow {
+			continue
+		}
+		cfg[k] = v
+	}
+	return applyEnvironmentDefaults(cfg, target)
+}
+
+// diffConfig lists the keys whose values differ between two configs, sorted
+// by key
+func diffConfig(from, to map[string]interface{}) []ConfigChange {
+	keys := make(map[string]struct{}, len(from)+len(to))
+	for k := range from {
+		keys[k] = struct{}{}
+	}
+	for k := range to {
+		keys[k] = struct{}{}
+	}
+
+	changes := []ConfigChange{}
+	for k := range keys {
+		if !reflect.DeepEqual(from[k], to[k]) {
+			changes = append(changes, ConfigChange{Key: k, From: from[k], To: to[k]})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+	return changes
+}
+
+// resourceConfig decodes a resource's config, normalised through JSON so that
+// it compares equal to configs built in memory
+func resourceConfig(r *Resource) map[string]interface{} {
+	cfg := make(map[string]interface{})
+	if r != nil {
+		decodeJSONField(r.Config, &cfg, "config")
+	}
+	return cfg
+}
+
+// applyPromotion gives the same-named resource in the target environment
+// the promoted config, creating it from the source if there is none, and
+// reports whether it was created. The target name is locked while it is
+// looked up, so concurrent promotions create the resource once.
+func applyPromotion(db *gorm.DB, source *Resource, target *Environment, cfg map[string]interface{}, userID uint) (promoted *Resource, created bool, err error) {
+	err = db.Transaction(func(tx *gorm.DB) error {
+		promoted, created, err = promoteInto(tx, source, target, cfg, userID)
+		return err
+	})
+	return promoted, created, err
+}
+
+// promoteInto applies a promotion inside tx
+func promoteInto(tx *gorm.DB, source *Resource, target *Environment, cfg map[string]interface{}, userID uint) (*Resource, bool, error) {
+	cfgJSON, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := locks.Lock(tx, resourceNameLock(source.TeamID, target.Name, source.Name)); err != nil {
+		return nil, false, err
+	}
+
+	var existing Resource
+	err = tx.Where("team_id = ? AND name = ? AND environment = ? AND deleted_at IS NULL",
+		source.TeamID, source.Name, target.Name).First(&existing).Error
+	if err == nil {
+		return &existing, false, tx.Model(&existing).Updates(map[string]interface{}{
+			"config":     datatypes.JSON(cfgJSON),
+			"generation": nextGeneration,
+		}).Error
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, err
+	}
+
+	promoted := &Resource{
+		Name:               source.Name,
+		ResourceTypeID:     source.ResourceTypeID,
+		TeamID:             source.TeamID,
+		Environment:        target.Name,
+		Status:             "pending",
+		LifecycleMode:      source.LifecycleMode,
+		ProvisioningMethod: source.ProvisioningMethod,
+		Config:             datatypes.JSON(cfgJSON),
+		TLSEnabled:         source.TLSEnabled,
+		CanModifyUsers:     source.CanModifyUsers,
+		CanModifyConfig:    source.CanModifyConfig,
+		CanBackup:          source.CanBackup,
+		CanScale:           source.CanScale,
+		CreatedBy:          userID,
+	}
+	return promoted, true, tx.Create(promoted).Error
+}
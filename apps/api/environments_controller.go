@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/penguintechinc/project-template/shared/apierrors"
+	"github.com/penguintechinc/project-template/shared/database"
+	"gorm.io/gorm"
+)
+
+// EnvironmentController handles team environment and promotion HTTP requests
+type EnvironmentController struct {
+	db     *gorm.DB
+	access *AccessCache
+}
+
+// NewEnvironmentController creates a new environment controller
+func NewEnvironmentController(db *gorm.DB, access *AccessCache) *EnvironmentController {
+	return &EnvironmentController{db: db, access: access}
+}
+
+// teamAccess parses the team ID parameter and returns the caller's team role.
+// Global admins are treated as team admins. It writes an error response and
+// returns false when the caller is not a member of the team.
+func teamAccess(c *gin.Context, access *AccessCache) (uint, string, bool) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "User context not found")
+		return 0, "", false
+	}
+
+	teamID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		apierrors.Abort(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Invalid team ID")
+		return 0, "", false
+	}
+
+	userRole, _ := c.Get("user_role")
+	if hasMinimumRole(userRole, "admin") {
+		return uint(teamID), "admin", true
+	}
+
+	role, isMember, err := access.TeamRole(c.Request.Context(), userID.(uint), uint(teamID))
+	if err != nil || !isMember {
+		apierrors.Abort(c, http.StatusForbidden, apierrors.CodeForbidden, "You do not have access to this team")
+		return 0, "", false
+	}
+	return uint(teamID), role, true
+}
+
+// ListEnvironments retrieves a team's promotion pipeline
+// GET /api/v1/teams/:id/environments
+func (ec *EnvironmentController) ListEnvironments(c *gin.Context) {
+	teamID, _, ok := teamAccess(c, ec.access)
+	if !ok {
+		return
+	}
+
+	envs, err := teamEnvironments(tenantDB(c, ec.db), teamID)
+	if err != nil {
+		log.Printf("Error listing environments: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to list environments")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"environments": envs})
+}
+
+// SetEnvironments replaces a team's promotion pipeline. Environments are
+// promoted through in the order given.
+// PUT /api/v1/teams/:id/environments
+func (ec *EnvironmentController) SetEnvironments(c *gin.Context) {
+	teamID, role, ok := teamAccess(c, ec.access)
+	if !ok {
+		return
+	}
+	if !hasMinimumRole(role, "admin") {
+		apierrors.Abort(c, http.StatusForbidden, apierrors.CodeForbidden, "Team admin access required")
+		return
+	}
+
+	var req SetEnvironmentsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierrors.AbortWithDetails(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	envs := make([]Environment, 0, len(req.Environments))
+	names := make([]string, 0, len(req.Environments))
+	seen := make(map[string]bool, len(req.Environments))
+	for i, e := range req.Environments {
+		if seen[e.Name] {
+			apierrors.Abort(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Duplicate environment: "+e.Name)
+			return
+		}
+		seen[e.Name] = true
+		if err := validateMaintenanceWindow(e.MaintenanceWindow); err != nil {
+			apierrors.Abort(c, http.StatusBadRequest, "invalid_maintenance_window", err.Error())
+			return
+		}
+		names = append(names, e.Name)
+		envs = append(envs, Environment{
+			TeamID:            teamID,
+			Name:              e.Name,
+			Position:          i,
+			BackupSchedule:    e.BackupSchedule,
+			HighAvailability:  e.HighAvailability,
+			RequiresApproval:  e.RequiresApproval,
+			MaintenanceWindow: e.MaintenanceWindow,
+		})
+	}
+
+	// Resources must stay within the pipeline
+	var orphaned int64
+	if err := tenantDB(c, ec.db).Model(&Resource{}).
+		Where("team_id = ? AND environment NOT IN ?", teamID, names).
+		Count(&orphaned).Error; err != nil {
+		log.Printf("Error checking environment usage: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to check environment usage")
+		return
+	}
+	if orphaned > 0 {
+		apierrors.Abort(c, http.StatusConflict, "environment_in_use", "Resources exist in environments that would be removed")
+		return
+	}
+
+	if err := tenantDB(c, ec.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("team_id = ?", teamID).Delete(&Environment{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&envs).Error
+	}); err != nil {
+		log.Printf("Error saving environments: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to save environments")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"environments": envs})
+}
+
+// PromoteResource clones a resource's config into the next environment of its
+// team's pipeline, creating or updating the same-named resource there. With
+// dry_run the config diff is returned without applying it. Promotion into an
+// environment that requires approval is limited to team admins; when the
+// team has a ticketing integration, other maintainers' promotions open an
+// approval ticket instead, and are applied once it is approved.
+// POST /api/v1/resources/:id/promote
+func (ec *EnvironmentController) PromoteResource(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "User context not found")
+		return
+	}
+
+	var req PromoteResourceRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		apierrors.AbortWithDetails(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	var source Resource
+	if err := tenantDB(c, ec.db).Where("resources.id = ? AND resources.deleted_at IS NULL", c.Param("id")).
+		Joins("INNER JOIN team_members ON resources.team_id = team_members.team_id").
+		Where("team_members.user_id = ?", userID.(uint)).
+		First(&source).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierrors.Abort(c, http.StatusNotFound, "resource_not_found", "Resource not found or you do not have access")
+		} else {
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to retrieve resource")
+		}
+		return
+	}
+
+	userRole, _ := c.Get("user_role")
+	teamRole, _, err := ec.access.TeamRole(c.Request.Context(), userID.(uint), source.TeamID)
+	if err != nil || (!hasMinimumRole(userRole, "admin") && !hasMinimumRole(teamRole, "maintainer")) {
+		apierrors.Abort(c, http.StatusForbidden, apierrors.CodeForbidden, "Insufficient permissions to promote resources")
+		return
+	}
+
+	envs, err := teamEnvironments(tenantDB(c, ec.db), source.TeamID)
+	if err != nil {
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to load environments")
+		return
+	}
+
+	from := findEnvironment(envs, source.Environment)
+	to := from + 1
+	if req.TargetEnvironment != "" {
+		to = findEnvironment(envs, req.TargetEnvironment)
+	}
+	if from < 0 || to <= from || to >= len(envs) {
+		apierrors.Abort(c, http.StatusBadRequest, "invalid_target_environment", "Resources can only be promoted to a later environment in the team's pipeline")
+		return
+	}
+	target := &envs[to]
+
+	var existing *Resource
+	var found Resource
+	if err := tenantDB(c, ec.db).Where("team_id = ? AND name = ? AND environment = ? AND deleted_at IS NULL",
+		source.TeamID, source.Name, target.Name).First(&found).Error; err == nil {
+		existing = &found
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to check target environment")
+		return
+	}
+
+	cfg := promotedConfig(resourceConfig(&source), target)
+	resp := PromotionResponse{
+		SourceResourceID:  source.ID,
+		SourceEnvironment: source.Environment,
+		TargetEnvironment: target.Name,
+		RequiresApproval:  target.RequiresApproval,
+		Changes:           diffConfig(resourceConfig(existing), cfg),
+	}
+	if existing != nil {
+		resp.TargetResourceID = &existing.ID
+	}
+
+	if req.DryRun {
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+
+	if target.RequiresApproval && !hasMinimumRole(userRole, "admin") && !hasMinimumRole(teamRole, "admin") {
+		// With a ticketing integration, the promotion waits on an approval
+		// ticket instead of a team admin
+		integration, err := ticketIntegrationFor(tenantDB(c, ec.db), source.TeamID, ticketKindApproval)
+		if err != nil {
+			log.Printf("Error retrieving ticketing integration of team %d: %v", source.TeamID, err)
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to retrieve integration")
+			return
+		}
+		if integration == nil {
+			apierrors.Abort(c, http.StatusForbidden, "approval_required", "Promotion to "+target.Name+" requires a team admin")
+			return
+		}
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 20*time.Second)
+		defer cancel()
+		resp.Ticket, err = requestPromotionApproval(ctx, tenantDB(c, ec.db), integration, &source, target, cfg, resp.Changes, userID.(uint))
+		if err != nil {
+			log.Printf("Error opening approval ticket for resource %d: %v", source.ID, err)
+			apierrors.AbortWithDetails(c, http.StatusBadGateway, "integration_unreachable", "Failed to open approval ticket", err.Error())
+			return
+		}
+		c.JSON(http.StatusAccepted, resp)
+		return
+	}
+
+	promoted, created, err := applyPromotion(tenantDB(c, ec.db), &source, target, cfg, userID.(uint))
+	if err != nil {
+		log.Printf("Error promoting resource %d: %v", source.ID, err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to promote resource")
+		return
+	}
+	existing = promoted
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+
+	database.UsePrimary(tenantDB(c, ec.db)).Preload("ResourceType").Preload("Team").First(existing, existing.ID)
+	resp.Applied = true
+	resp.TargetResourceID = &existing.ID
+	resp.Resource = resourceToResponse(existing)
+	c.JSON(status, resp)
+}
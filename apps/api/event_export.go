@@ -0,0 +1,469 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/penguintechinc/project-template/shared/database"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Event export integration types
+const (
+	IntegrationTypeSyslog  = "syslog"
+	IntegrationTypeKafka   = "kafka"
+	IntegrationTypeWebhook = "webhook"
+)
+
+// eventExportBatchSize bounds the number of events shipped per sink per pass
+const eventExportBatchSize = 500
+
+// EventSink delivers a batch of audit events to an external system. A sink
+// must return an error unless every event in the batch was accepted.
+type EventSink interface {
+	Send(ctx context.Context, events []database.AuditLog) error
+}
+
+// SyslogConfig is the Config document of a syslog integration
+type SyslogConfig struct {
+	Address  string `json:"address"`
+	Protocol string `json:"protocol"` // udp, tcp, or tls
+	Facility int    `json:"facility"`
+	AppName  string `json:"app_name"`
+}
+
+// KafkaConfig is the Config document of a kafka integration. Events are
+// produced through a Kafka REST Proxy (v2 API).
+type KafkaConfig struct {
+	RESTProxyURL string `json:"rest_proxy_url"`
+	Topic        string `json:"topic"`
+}
+
+// WebhookConfig is the Config document of a webhook integration
+type WebhookConfig struct {
+	URL string `json:"url"`
+}
+
+// SinkCredentials holds optional credentials shared by the HTTP-based sinks
+type SinkCredentials struct {
+	Username    string `json:"username"`
+	Password    string `json:"password"`
+	BearerToken string `json:"bearer_token"`
+}
+
+// SyslogCEFSink writes events to a syslog collector as RFC 5424 messages
+// carrying a CEF payload
+type SyslogCEFSink struct {
+	cfg      SyslogConfig
+	hostname string
+}
+
+// NewSyslogCEFSink creates a syslog sink
+func NewSyslogCEFSink(cfg SyslogConfig) *SyslogCEFSink {
+	if cfg.Protocol == "" {
+		cfg.Protocol = "udp"
+	}
+	if cfg.Facility == 0 {
+		cfg.Facility = 13 // log audit
+	}
+	if cfg.AppName == "" {
+		cfg.AppName = "nest"
+	}
+	hostname, _ := os.Hostname()
+	return &SyslogCEFSink{cfg: cfg, hostname: hostname}
+}
+
+// Send writes each event as one syslog message
+func (s *SyslogCEFSink) Send(ctx context.Context, events []database.AuditLog) error {
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+
+	var conn net.Conn
+	var err error
+	switch s.cfg.Protocol {
+	case "tls":
+		conn, err = tls.DialWithDialer(dialer, "tcp", s.cfg.Address, &tls.Config{MinVersion: tls.VersionTLS12})
+	case "tcp", "udp":
+		conn, err = dialer.DialContext(ctx, s.cfg.Protocol, s.cfg.Address)
+	default:
+		return fmt.Errorf("unsupported syslog protocol %q", s.cfg.Protocol)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to syslog: %w", err)
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetWriteDeadline(deadline)
+	}
+
+	for i := range events {
+		msg := s.format(&events[i])
+		if s.cfg.Protocol != "udp" {
+			// Octet-counting framing (RFC 6587) for stream transports
+			msg = strconv.Itoa(len(msg)) + " " + msg
+		}
+		if _, err := io.WriteString(conn, msg); err != nil {
+			return fmt.Errorf("failed to write syslog message: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// format renders an event as an RFC 5424 syslog line with a CEF message
+func (s *SyslogCEFSink) format(e *database.AuditLog) string {
+	severity := cefSeverity(e.Action)
+	// Map CEF severity onto syslog severity: notice for routine, warning/error otherwise
+	syslogSeverity := 5
+	if severity >= 7 {
+		syslogSeverity = 3
+	} else if severity >= 5 {
+		syslogSeverity = 4
+	}
+	pri := s.cfg.Facility*8 + syslogSeverity
+
+	ext := []string{
+		"rt=" + strconv.FormatInt(e.Timestamp.UnixMilli(), 10),
+		"externalId=" + strconv.FormatUint(uint64(e.ID), 10),
+		"act=" + cefExtEscape(e.Action),
+	}
+	if e.UserID != nil {
+		ext = append(ext, "suid="+strconv.FormatUint(uint64(*e.UserID), 10))
+	}
+	if e.IPAddress != "" {
+		ext = append(ext, "src="+cefExtEscape(e.IPAddress))
+	}
+	if e.ResourceType != "" {
+		ext = append(ext, "cs1Label=resourceType", "cs1="+cefExtEscape(e.ResourceType))
+	}
+	if e.ResourceID != nil {
+		ext = append(ext, "cn1Label=resourceId", "cn1="+strconv.FormatUint(uint64(*e.ResourceID), 10))
+	}
+	if e.TeamID != nil {
+		ext = append(ext, "cn2Label=teamId", "cn2="+strconv.FormatUint(uint64(*e.TeamID), 10))
+	}
+	if len(e.Details) > 0 {
+		ext = append(ext, "msg="+cefExtEscape(string(e.Details)))
+	}
+
+	cef := fmt.Sprintf("CEF:0|PenguinTech|NEST|%s|%s|%s|%d|%s",
+		cefHeaderEscape(os.Getenv("VERSION")),
+		cefHeaderEscape(e.Action),
+		cefHeaderEscape(e.Action),
+		severity,
+		strings.Join(ext, " "))
+
+	return fmt.Sprintf("<%d>1 %s %s %s - %s - %s\n",
+		pri, e.Timestamp.UTC().Format(time.RFC3339Nano), s.hostname, s.cfg.AppName, "audit", cef)
+}
+
+// cefSeverity assigns a CEF severity (0-10) from the event action
+func cefSeverity(action string) int {
+	switch {
+	case strings.Contains(action, "delete"), strings.Contains(action, "failed"), strings.Contains(action, "denied"):
+		return 7
+	case strings.Contains(action, "update"), strings.Contains(action, "login"):
+		return 5
+	}
+	return 3
+}
+
+func cefHeaderEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\n", " ", "\r", " ").Replace(s)
+}
+
+func cefExtEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\n", `\n`, "\r", `\r`).Replace(s)
+}
+
+// KafkaRESTSink produces events to a Kafka topic through a Kafka REST Proxy
+type KafkaRESTSink struct {
+	cfg    KafkaConfig
+	creds  SinkCredentials
+	client *http.Client
+}
+
+// NewKafkaRESTSink creates a Kafka REST Proxy sink
+func NewKafkaRESTSink(cfg KafkaConfig, creds SinkCredentials) *KafkaRESTSink {
+	return &KafkaRESTSink{cfg: cfg, creds: creds, client: &http.Client{Timeout: 30 * time.Second}}
+}
+
+// Send produces the batch as JSON records keyed by event ID
+func (k *KafkaRESTSink) Send(ctx context.Context, events []database.AuditLog) error {
+	records := make([]map[string]interface{}, 0, len(events))
+	for i := range events {
+		records = append(records, map[string]interface{}{
+			"key":   strconv.FormatUint(uint64(events[i].ID), 10),
+			"value": events[i],
+		})
+	}
+
+	body, err := json.Marshal(map[string]interface{}{"records": records})
+	if err != nil {
+		return fmt.Errorf("failed to marshal records: %w", err)
+	}
+
+	url := strings.TrimRight(k.cfg.RESTProxyURL, "/") + "/topics/" + k.cfg.Topic
+	var result struct {
+		Offsets []struct {
+			ErrorCode *int   `json:"error_code"`
+			Error     string `json:"error"`
+		} `json:"offsets"`
+	}
+	if err := postJSON(ctx, k.client, url, "application/vnd.kafka.json.v2+json", body, k.creds, &result); err != nil {
+		return err
+	}
+
+	for _, o := range result.Offsets {
+		if o.ErrorCode != nil {
+			return fmt.Errorf("kafka rejected record: %s", o.Error)
+		}
+	}
+
+	return nil
+}
+
+// WebhookEventSink posts event batches as JSON to an HTTP endpoint
+type WebhookEventSink struct {
+	cfg    WebhookConfig
+	creds  SinkCredentials
+	client *http.Client
+}
+
+// NewWebhookEventSink creates a webhook sink
+func NewWebhookEventSink(cfg WebhookConfig, creds SinkCredentials) *WebhookEventSink {
+	return &WebhookEventSink{cfg: cfg, creds: creds, client: &http.Client{Timeout: 30 * time.Second}}
+}
+
+// Send posts the batch to the webhook
+func (w *WebhookEventSink) Send(ctx context.Context, events []database.AuditLog) error {
+	body, err := json.Marshal(map[string]interface{}{"events": events})
+	if err != nil {
+		return fmt.Errorf("failed to marshal events: %w", err)
+	}
+	return postJSON(ctx, w.client, w.cfg.URL, "application/json", body, w.creds, nil)
+}
+
+// postJSON posts a body with optional auth and decodes the response into out
+func postJSON(ctx context.Context, client *http.Client, url, contentType string, body []byte,
+	creds SinkCredentials, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	if creds.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+creds.BearerToken)
+	} else if creds.Username != "" {
+		req.SetBasicAuth(creds.Username, creds.Password)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("endpoint returned %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+
+	if out != nil {
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+	return nil
+}
+
+// newEventSink builds the sink for an event export integration
+func newEventSink(integration *Integration) (EventSink, error) {
+	var creds SinkCredentials
+	if len(integration.Credentials) > 0 {
+		if err := json.Unmarshal(integration.Credentials, &creds); err != nil {
+			return nil, fmt.Errorf("invalid credentials: %w", err)
+		}
+	}
+
+	switch integration.Type {
+	case IntegrationTypeSyslog:
+		var cfg SyslogConfig
+		if err := json.Unmarshal(integration.Config, &cfg); err != nil {
+			return nil, fmt.Errorf("invalid syslog config: %w", err)
+		}
+		return NewSyslogCEFSink(cfg), nil
+	case IntegrationTypeKafka:
+		var cfg KafkaConfig
+		if err := json.Unmarshal(integration.Config, &cfg); err != nil {
+			return nil, fmt.Errorf("invalid kafka config: %w", err)
+		}
+		return NewKafkaRESTSink(cfg, creds), nil
+	case IntegrationTypeWebhook:
+		var cfg WebhookConfig
+		if err := json.Unmarshal(integration.Config, &cfg); err != nil {
+			return nil, fmt.Errorf("invalid webhook config: %w", err)
+		}
+		return NewWebhookEventSink(cfg, creds), nil
+	}
+
+	return nil, fmt.Errorf("integration type %q is not an event sink", integration.Type)
+}
+
+// isEventSinkType reports whether an integration type exports events
+func isEventSinkType(t string) bool {
+	return t == IntegrationTypeSyslog || t == IntegrationTypeKafka || t == IntegrationTypeWebhook
+}
+
+// EventExporter ships audit events to every enabled event sink integration.
+// Each integration has a persistent cursor on the audit chain that only
+// advances after a batch is accepted, giving at-least-once delivery across
+// restarts.
+type EventExporter struct {
+	db *gorm.DB
+}
+
+// NewEventExporter creates a new event exporter
+func NewEventExporter(db *gorm.DB) *EventExporter {
+	return &EventExporter{db: db}
+}
+
+// ExportAll runs one export pass for every enabled event sink integration.
+// It runs as a periodic job, so one replica exports at a time and each
+// cursor has a single writer.
+func (e *EventExporter) ExportAll(ctx context.Context) error {
+	var integrations []Integration
+	if err := e.db.WithContext(ctx).
+		Where("type IN ? AND enabled = ?", []string{IntegrationTypeSyslog, IntegrationTypeKafka, IntegrationTypeWebhook}, true).
+		Find(&integrations).Error; err != nil {
+		return fmt.Errorf("failed to load event export integrations: %w", err)
+	}
+
+	for i := range integrations {
+		integration := &integrations[i]
+		sent, err := e.exportIntegration(ctx, integration)
+
+		updates := map[string]interface{}{"last_error": ""}
+		if err != nil {
+			log.Printf("Event export to integration %d failed: %v", integration.ID, err)
+			updates["last_error"] = err.Error()
+		} else if sent > 0 {
+			updates["last_sync_at"] = time.Now()
+		}
+		e.db.Model(integration).Updates(updates)
+	}
+	return nil
+}
+
+// exportIntegration drains pending events for one integration in batches
+func (e *EventExporter) exportIntegration(ctx context.Context, integration *Integration) (int, error) {
+	sink, err := newEventSink(integration)
+	if err != nil {
+		return 0, err
+	}
+
+	cursor, err := e.loadCursor(ctx, integration.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	total := 0
+	for {
+		query := e.db.WithContext(ctx).Where("chain_seq > ?", *cursor.LastChainSeq)
+		if integration.TeamID != nil {
+			query = query.Where("team_id = ?", *integration.TeamID)
+		}
+
+		var events []database.AuditLog
+		if err := query.Order("chain_seq ASC").Limit(eventExportBatchSize).Find(&events).Error; err != nil {
+			return total, fmt.Errorf("failed to load events: %w", err)
+		}
+		if len(events) == 0 {
+			return total, nil
+		}
+
+		sendCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		err := sink.Send(sendCtx, events)
+		cancel()
+		if err != nil {
+			return total, err
+		}
+
+		last := events[len(events)-1]
+		cursor.LastChainSeq, cursor.LastEventID = last.ChainSeq, last.ID
+		if err := e.db.WithContext(ctx).Save(cursor).Error; err != nil {
+			return total, fmt.Errorf("failed to advance cursor: %w", err)
+		}
+		total += len(events)
+
+		if len(events) < eventExportBatchSize {
+			return total, nil
+		}
+	}
+}
+
+// loadCursor returns the integration's cursor, creating it at the current
+// head of the audit log so new sinks do not replay history by default
+func (e *EventExporter) loadCursor(ctx context.Context, integrationID uint) (*EventExportCursor, error) {
+	var cursor EventExportCursor
+	err := e.db.WithContext(ctx).Where("integration_id = ?", integrationID).First(&cursor).Error
+	if err == nil {
+		return e.chainCursor(ctx, &cursor)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to load cursor: %w", err)
+	}
+
+	var head database.AuditLog
+	if err := e.db.WithContext(ctx).Where("chain_seq IS NOT NULL").Order("chain_seq DESC").First(&head).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("failed to find the head of the audit log: %w", err)
+		}
+		var none int64
+		head.ChainSeq = &none
+	}
+
+	cursor = EventExportCursor{IntegrationID: integrationID, LastChainSeq: head.ChainSeq, LastEventID: head.ID}
+	if err := e.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&cursor).Error; err != nil {
+		return nil, fmt.Errorf("failed to create cursor: %w", err)
+	}
+
+	// Another API replica may have created the cursor first
+	if err := e.db.WithContext(ctx).Where("integration_id = ?", integrationID).First(&cursor).Error; err != nil {
+		return nil, fmt.Errorf("failed to load cursor: %w", err)
+	}
+	return e.chainCursor(ctx, &cursor)
+}
+
+// chainCursor moves a cursor written before export followed the audit chain
+// onto it, after the last chained event up to its LastEventID. Cursors
+// already on the chain are returned as they are.
+func (e *EventExporter) chainCursor(ctx context.Context, cursor *EventExportCursor) (*EventExportCursor, error) {
+	if cursor.LastChainSeq != nil {
+		return cursor, nil
+	}
+
+	var seq int64
+	if err := e.db.WithContext(ctx).Model(&database.AuditLog{}).
+		Select("COALESCE(MAX(chain_seq), 0)").
+		Where("id <= ?", cursor.LastEventID).
+		Scan(&seq).Error; err != nil {
+		return nil, fmt.Errorf("failed to find the cursor's position in the audit chain: %w", err)
+	}
+	cursor.LastChainSeq = &seq
+	if err := e.db.WithContext(ctx).Save(cursor).Error; err != nil {
+		return nil, fmt.Errorf("failed to save cursor: %w", err)
+	}
+	return cursor, nil
+}
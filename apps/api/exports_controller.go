@@ -0,0 +1,279 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/penguintechinc/project-template/shared/apierrors"
+	"github.com/penguintechinc/project-template/shared/export"
+	"gorm.io/gorm"
+)
+
+// ExportController exports resource inventories as CSV or Excel for audits
+// and management reporting
+type ExportController struct {
+	db     *gorm.DB
+	access *AccessCache
+}
+
+// NewExportController creates a new export controller
+func NewExportController(db *gorm.DB, access *AccessCache) *ExportController {
+	return &ExportController{db: db, access: access}
+}
+
+// exportScope returns the teams the caller may export: nil for global
+// admins, who export every team, or the teams they are an admin of. It
+// writes the error response and returns false when the caller administers
+// no team, or filters by team_id for a team they don't administer.
+func (ec *ExportController) exportScope(c *gin.Context) ([]uint, bool) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "User context not found")
+		return nil, false
+	}
+	userRole, _ := c.Get("user_role")
+	if hasMinimumRole(userRole, "admin") {
+		return nil, true
+	}
+
+	roles, err := ec.access.TeamRoles(c.Request.Context(), userID.(uint))
+	if err != nil {
+		log.Printf("Error loading team roles for export: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to load team memberships")
+		return nil, false
+	}
+	var teams []uint
+	for teamID, role := range roles {
+		if hasMinimumRole(role, "admin") {
+			teams = append(teams, teamID)
+		}
+	}
+	if len(teams) == 0 {
+		apierrors.Abort(c, http.StatusForbidden, apierrors.CodeForbidden, "Team admin access required to export")
+		return nil, false
+	}
+
+	if teamID := c.Query("team_id"); teamID != "" {
+		if tid, err := strconv.ParseUint(teamID, 10, 32); err == nil && !hasMinimumRole(roles[uint(tid)], "admin") {
+			apierrors.Abort(c, http.StatusForbidden, apierrors.CodeForbidden, "Team admin access required to export")
+			return nil, false
+		}
+	}
+	return teams, true
+}
+
+// exportFormat parses the format query parameter, csv by default. It writes
+// the error response and returns false when it is unsupported.
+func exportFormat(c *gin.Context) (string, bool) {
+	format := c.DefaultQuery("format", export.FormatCSV)
+	if !export.ValidFormat(format) {
+		apierrors.Abort(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "format must be csv or xlsx")
+		return "", false
+	}
+	return format, true
+}
+
+// sendExport writes a table as a download, narrowed to the columns query
+// parameter. name is the file name without its extension and the sheet name.
+func sendExport(c *gin.Context, format, name string, table export.Table) {
+	table, err := table.Select(c.Query("columns"))
+	if err != nil {
+		apierrors.AbortWithDetails(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Invalid export columns", err.Error())
+		return
+	}
+
+	filename := fmt.Sprintf("%s-%s.%s", name, time.Now().UTC().Format("20060102"), format)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Header("Content-Type", export.ContentType(format))
+	c.Status(http.StatusOK)
+	if err := table.Write(c.Writer, format, name); err != nil {
+		log.Printf("Error writing %s export: %v", name, err)
+	}
+}
+
+// exportTime formats an optional time for an export, empty when unset
+func exportTime(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// ExportResources exports the resources of the caller's teams with the
+// filters of the resource list
+// GET /api/v1/exports/resources?format=csv|xlsx&columns=
+func (ec *ExportController) ExportResources(c *gin.Context) {
+	teams, ok := ec.exportScope(c)
+	if !ok {
+		return
+	}
+	format, ok := exportFormat(c)
+	if !ok {
+		return
+	}
+
+	query := tenantDB(c, ec.db).Where("resources.deleted_at IS NULL").
+		Preload("ResourceType").Preload("Team")
+	if teams != nil {
+		query = query.Where("resources.team_id IN ?", teams)
+	}
+	query = filterResources(c, query)
+
+	var resources []*Resource
+	if err := query.Order("resources.id").Find(&resources).Error; err != nil {
+		log.Printf("Error exporting resources: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to export resources")
+		return
+	}
+
+	table := export.Table{Columns: []string{
+		"id", "name", "type", "team_id", "team", "environment", "status", "lifecycle_mode",
+		"provisioning_method", "size_class", "tls_enabled", "can_backup", "deletion_protection",
+		"last_error", "created_by", "created_at", "updated_at",
+	}}
+	for _, r := range resources {
+		typeName, teamName := "", ""
+		if r.ResourceType != nil {
+			typeName = r.ResourceType.Name
+		}
+		if r.Team != nil {
+			teamName = r.Team.Name
+		}
+		table.Rows = append(table.Rows, []string{
+			strconv.FormatUint(uint64(r.ID), 10), r.Name, typeName,
+			strconv.FormatUint(uint64(r.TeamID), 10), teamName, r.Environment, r.Status,
+			r.LifecycleMode, r.ProvisioningMethod, r.SizeClass,
+			strconv.FormatBool(r.TLSEnabled), strconv.FormatBool(r.CanBackup),
+			strconv.FormatBool(r.DeletionProtection), r.LastError,
+			strconv.FormatUint(uint64(r.CreatedBy), 10),
+			exportTime(&r.CreatedAt), exportTime(&r.UpdatedAt),
+		})
+	}
+	sendExport(c, format, "resources", table)
+}
+
+// ExportCertificates exports the certificates of the caller's teams'
+// resources with the filters of the certificate list
+// GET /api/v1/exports/certificates?format=csv|xlsx&columns=
+func (ec *ExportController) ExportCertificates(c *gin.Context) {
+	teams, ok := ec.exportScope(c)
+	if !ok {
+		return
+	}
+	format, ok := exportFormat(c)
+	if !ok {
+		return
+	}
+	withinDays, ok := certificateWindow(c)
+	if !ok {
+		return
+	}
+
+	now := time.Now().UTC()
+	certs := []*CertificateSummary{}
+	db := tenantDB(c, ec.db)
+	if db.Migrator().HasTable("certificates") {
+		query := certificateQuery(c, db, now, withinDays)
+		if teams != nil {
+			query = query.Where("r.team_id IN ?", teams)
+		}
+		if err := query.Select(certificateSummaryColumns).Order("c.valid_until ASC, c.id ASC").
+			Scan(&certs).Error; err != nil {
+			log.Printf("Error exporting certificates: %v", err)
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to export certificates")
+			return
+		}
+	}
+	setCertificateExpiry(certs, now)
+
+	table := export.Table{Columns: []string{
+		"id", "common_name", "serial_number", "valid_from", "valid_until", "days_remaining",
+		"expired", "auto_renew", "resource_id", "resource", "environment", "team_id", "team",
+	}}
+	for _, cert := range certs {
+		table.Rows = append(table.Rows, []string{
+			strconv.FormatUint(uint64(cert.ID), 10), cert.CommonName, cert.SerialNumber,
+			exportTime(&cert.ValidFrom), exportTime(&cert.ValidUntil), strconv.Itoa(cert.DaysRemaining),
+			strconv.FormatBool(cert.Expired), strconv.FormatBool(cert.AutoRenew),
+			strconv.FormatUint(uint64(cert.ResourceID), 10), cert.Resource, cert.Environment,
+			strconv.FormatUint(uint64(cert.TeamID), 10), cert.Team,
+		})
+	}
+	sendExport(c, format, "certificates", table)
+}
+
+// backupCoverageRow is a resource that can be backed up, with its latest
+// backup
+type backupCoverageRow struct {
+	ResourceID       uint
+	Resource         string
+	Environment      string
+	TeamID           uint
+	Team             string
+	LastBackupStatus string
+	LastBackupAt     *time.Time
+	LastCompletedAt  *time.Time
+	LastBackupBytes  int64
+}
+
+// ExportBackupCoverage exports the resources of the caller's teams that can
+// be backed up, with their latest backup and whether one completed within
+// the coverage window of the admin overview. It takes the filters of the
+// resource list.
+// GET /api/v1/exports/backup-coverage?format=csv|xlsx&columns=
+func (ec *ExportController) ExportBackupCoverage(c *gin.Context) {
+	teams, ok := ec.exportScope(c)
+	if !ok {
+		return
+	}
+	format, ok := exportFormat(c)
+	if !ok {
+		return
+	}
+
+	db := tenantDB(c, ec.db)
+	// Backups are run by the manager, whose table is missing until it has
+	// run; every resource is then uncovered
+	columns := `resources.id AS resource_id, resources.name AS resource, resources.environment,
+		resources.team_id, t.name AS team`
+	query := db.Table("resources").Joins("JOIN teams t ON t.id = resources.team_id")
+	if db.Migrator().HasTable("backup_jobs") {
+		columns += `, COALESCE(b.status, '') AS last_backup_status, b.created_at AS last_backup_at,
+			COALESCE(b.backup_size_bytes, 0) AS last_backup_bytes,
+			(SELECT MAX(completed_at) FROM backup_jobs WHERE resource_id = resources.id AND status = 'completed') AS last_completed_at`
+		query = query.Joins(`LEFT JOIN LATERAL (SELECT status, created_at, backup_size_bytes FROM backup_jobs
+			WHERE resource_id = resources.id ORDER BY created_at DESC LIMIT 1) b ON true`)
+	}
+	query = query.Where("resources.deleted_at IS NULL AND resources.can_backup")
+	if teams != nil {
+		query = query.Where("resources.team_id IN ?", teams)
+	}
+	query = filterResources(c, query)
+
+	var rows []backupCoverageRow
+	if err := query.Select(columns).Order("resources.id").Scan(&rows).Error; err != nil {
+		log.Printf("Error exporting backup coverage: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to export backup coverage")
+		return
+	}
+
+	cutoff := time.Now().UTC().Add(-overviewBackupWindow)
+	table := export.Table{Columns: []string{
+		"resource_id", "resource", "environment", "team_id", "team", "covered",
+		"last_completed_at", "last_backup_status", "last_backup_at", "last_backup_bytes",
+	}}
+	for _, row := range rows {
+		covered := row.LastCompletedAt != nil && row.LastCompletedAt.After(cutoff)
+		table.Rows = append(table.Rows, []string{
+			strconv.FormatUint(uint64(row.ResourceID), 10), row.Resource, row.Environment,
+			strconv.FormatUint(uint64(row.TeamID), 10), row.Team, strconv.FormatBool(covered),
+			exportTime(row.LastCompletedAt), row.LastBackupStatus, exportTime(row.LastBackupAt),
+			strconv.FormatInt(row.LastBackupBytes, 10),
+		})
+	}
+	sendExport(c, format, "backup-coverage", table)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"regexp"
+
+	"gorm.io/gorm"
+)
+
+// Feature flags consumed by the API and K8s controller
+const (
+	// FlagResourceResize allows resources to be moved between size classes
+	FlagResourceResize = "resource-resize"
+	// FlagAutoRestarts lets the K8s controller roll pods when the Secrets and
+	// ConfigMaps they read change
+	FlagAutoRestarts = "controller.auto-restarts"
+)
+
+// featureFlagDefault is a flag declared in code and its value for teams
+// when no FeatureFlag row overrides it
+type featureFlagDefault struct {
+	description string
+	enabled     bool
+}
+
+// knownFeatureFlags lists the flags declared in code. The K8s controller
+// declares its own flags with the same keys and defaults.
+var knownFeatureFlags = map[string]featureFlagDefault{
+	FlagResourceResize: {"Resize resources between size classes", true},
+	FlagAutoRestarts:   {"Roll pods on Secret and ConfigMap changes", true},
+}
+
+// featureFlagKeyPattern restricts flag keys to lowercase dotted identifiers
+var featureFlagKeyPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]{0,63}$`)
+
+// flagBucket places a team in one of 100 rollout buckets for a flag, so a
+// percentage rollout enables the same teams on the API and controller
+func flagBucket(key string, teamID uint) int {
+	h := fnv.New32a()
+	fmt.Fprintf(h, "%s:%d", key, teamID)
+	return int(h.Sum32() % 100)
+}
+
+// evaluateFlag reports whether a flag override enables a team. An override
+// whose team list doesn't decode enables no one.
+func evaluateFlag(flag *FeatureFlag, teamID uint) bool {
+	if !flag.Enabled {
+		return false
+	}
+	var teams []uint
+	if !decodeJSONField(flag.Teams, &teams, "feature flag teams") {
+		return false
+	}
+	for _, id := range teams {
+		if id == teamID {
+			return true
+		}
+	}
+	return flagBucket(flag.Key, teamID) < flag.Percentage
+}
+
+// featureEnabled reports whether a flag is on for a team, falling back to its
+// code default when no override exists
+func featureEnabled(db *gorm.DB, key string, teamID uint) (bool, error) {
+	var flag FeatureFlag
+	err := db.Where("key = ?", key).First(&flag).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return knownFeatureFlags[key].enabled, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return evaluateFlag(&flag, teamID), nil
+}
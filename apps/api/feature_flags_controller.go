@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/penguintechinc/project-template/shared/apierrors"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// FeatureFlagController handles feature flag HTTP requests
+type FeatureFlagController struct {
+	db     *gorm.DB
+	access *AccessCache
+}
+
+// NewFeatureFlagController creates a new feature flag controller
+func NewFeatureFlagController(db *gorm.DB, access *AccessCache) *FeatureFlagController {
+	return &FeatureFlagController{db: db, access: access}
+}
+
+// ListFeatureFlags retrieves the flags declared in code and any overrides,
+// including overrides for flags not yet consumed by this version
+// GET /api/v1/admin/feature-flags
+func (fc *FeatureFlagController) ListFeatureFlags(c *gin.Context) {
+	if !requirePlatformAdmin(c) {
+		return
+	}
+
+	var overrides []*FeatureFlag
+	if err := tenantDB(c, fc.db).Find(&overrides).Error; err != nil {
+		log.Printf("Error listing feature flags: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to list feature flags")
+		return
+	}
+
+	flags := make(map[string]*FeatureFlagResponse, len(knownFeatureFlags)+len(overrides))
+	for key, def := range knownFeatureFlags {
+		flags[key] = &FeatureFlagResponse{Key: key, Description: def.description, Default: def.enabled}
+	}
+	for _, override := range overrides {
+		flag, ok := flags[override.Key]
+		if !ok {
+			flag = &FeatureFlagResponse{Key: override.Key, Description: override.Description}
+			flags[override.Key] = flag
+		}
+		flag.Override = override
+	}
+
+	list := make([]*FeatureFlagResponse, 0, len(flags))
+	for _, flag := range flags {
+		list = append(list, flag)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Key < list[j].Key })
+
+	c.JSON(http.StatusOK, gin.H{"feature_flags": list})
+}
+
+// UpsertFeatureFlag creates or updates the rollout override of a flag
+// PUT /api/v1/admin/feature-flags/:key
+func (fc *FeatureFlagController) UpsertFeatureFlag(c *gin.Context) {
+	if !requirePlatformAdmin(c) {
+		return
+	}
+	userID, _ := c.Get("user_id")
+
+	key := c.Param("key")
+	if !featureFlagKeyPattern.MatchString(key) {
+		apierrors.Abort(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Invalid feature flag key")
+		return
+	}
+
+	var req UpsertFeatureFlagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierrors.AbortWithDetails(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	var flag FeatureFlag
+	if err := tenantDB(c, fc.db).Where("key = ?", key).First(&flag).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Printf("Error fetching feature flag: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to retrieve feature flag")
+		return
+	}
+
+	teams, _ := json.Marshal(req.Teams)
+	flag.Key = key
+	flag.Description = req.Description
+	flag.Enabled = req.Enabled
+	flag.Percentage = req.Percentage
+	flag.Teams = datatypes.JSON(teams)
+	flag.UpdatedBy = userID.(uint)
+
+	if err := tenantDB(c, fc.db).Save(&flag).Error; err != nil {
+		log.Printf("Error saving feature flag: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to save feature flag")
+		return
+	}
+
+	c.JSON(http.StatusOK, flag)
+}
+
+// DeleteFeatureFlag removes a flag's override, returning it to its code
+// default
+// DELETE /api/v1/admin/feature-flags/:key
+func (fc *FeatureFlagController) DeleteFeatureFlag(c *gin.Context) {
+	if !requirePlatformAdmin(c) {
+		return
+	}
+
+	result := tenantDB(c, fc.db).Unscoped().Where("key = ?", c.Param("key")).Delete(&FeatureFlag{})
+	if result.Error != nil {
+		log.Printf("Error deleting feature flag: %v", result.Error)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to delete feature flag")
+		return
+	}
+	if result.RowsAffected == 0 {
+		apierrors.Abort(c, http.StatusNotFound, apierrors.CodeNotFound, "Feature flag override not found")
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// ListTeamFeatureFlags evaluates every declared flag for a team
+// GET /api/v1/teams/:id/feature-flags
+func (fc *FeatureFlagController) ListTeamFeatureFlags(c *gin.Context) {
+	teamID, _, ok := teamAccess(c, fc.access)
+	if !ok {
+		return
+	}
+
+	var overrides []FeatureFlag
+	if err := tenantDB(c, fc.db).Find(&overrides).Error; err != nil {
+		log.Printf("Error listing feature flags: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to list feature flags")
+		return
+	}
+
+	flags := make(map[string]bool, len(knownFeatureFlags))
+	for key, def := range knownFeatureFlags {
+		flags[key] = def.enabled
+	}
+	for i := range overrides {
+		if _, ok := flags[overrides[i].Key]; ok {
+			flags[overrides[i].Key] = evaluateFlag(&overrides[i], teamID)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"team_id": teamID, "feature_flags": flags})
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/penguintechinc/project-template/shared/apierrors"
+	"gorm.io/gorm"
+)
+
+// ControllerStatusStopped is reported by a controller instance on clean shutdown
+const ControllerStatusStopped = "stopped"
+
+// FleetController handles K8s controller fleet status HTTP requests
+type FleetController struct {
+	db         *gorm.DB
+	staleAfter time.Duration
+}
+
+// NewFleetController creates a new fleet controller. Instances without a
+// heartbeat for staleAfter are reported stale.
+func NewFleetController(db *gorm.DB, staleAfter time.Duration) *FleetController {
+	return &FleetController{db: db, staleAfter: staleAfter}
+}
+
+// controllerFleet loads every known controller instance, newest heartbeat
+// first, and flags running instances whose heartbeat is older than staleAfter
+// and instances the API's schema doesn't support
+func controllerFleet(db *gorm.DB, staleAfter time.Duration) ([]*ControllerStatusResponse, error) {
+	var instances []*ControllerInstance
+	if err := db.Order("last_heartbeat_at DESC").Find(&instances).Error; err != nil {
+		return nil, err
+	}
+
+	cutoff := time.Now().UTC().Add(-staleAfter)
+	fleet := make([]*ControllerStatusResponse, 0, len(instances))
+	for _, instance := range instances {
+		fleet = append(fleet, &ControllerStatusResponse{
+			ControllerInstance: instance,
+			Stale:              instance.Status != ControllerStatusStopped && instance.LastHeartbeatAt.Before(cutoff),
+			Compatible:         controllerCompatible(instance.SchemaVersion),
+		})
+	}
+	return fleet, nil
+}
+
+// ListControllers retrieves the registered controller instances and their health
+// GET /api/v1/controllers
+func (fc *FleetController) ListControllers(c *gin.Context) {
+	if !requirePlatformAdmin(c) {
+		return
+	}
+
+	fleet, err := controllerFleet(tenantDB(c, fc.db), fc.staleAfter)
+	if err != nil {
+		log.Printf("Error listing controllers: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to list controllers")
+		return
+	}
+
+	// Resources backing off after failed reconciles, persisted by the
+	// controllers so the queue survives restarts
+	var retrying []*ReconcileStatus
+	if err := tenantDB(c, fc.db).Where("retry_count > 0").Order("next_retry_at ASC").Find(&retrying).Error; err != nil {
+		log.Printf("Error listing controller retry queue: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to list controller retry queue")
+		return
+	}
+
+	c.JSON(http.StatusOK, ControllerListResponse{
+		Controllers: fleet,
+		RetryQueue:  retrying,
+		StaleAfter:  fc.staleAfter.String(),
+	})
+}
+
+// DrainController asks a running controller instance to drain before it is
+// replaced: it stops taking new reconciles, finishes those in flight and
+// checkpoints its retry queue, reporting draining and then drained. The
+// instance picks the request up with its next heartbeat.
+// POST /api/v1/controllers/:instance_id/drain
+func (fc *FleetController) DrainController(c *gin.Context) {
+	if !requirePlatformAdmin(c) {
+		return
+	}
+
+	var instance ControllerInstance
+	if err := tenantDB(c, fc.db).Where("instance_id = ?", c.Param("instance_id")).First(&instance).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierrors.Abort(c, http.StatusNotFound, apierrors.CodeNotFound, "Controller instance not found")
+			return
+		}
+		log.Printf("Error fetching controller instance: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to retrieve controller instance")
+		return
+	}
+	if instance.Status == ControllerStatusStopped {
+		apierrors.Abort(c, http.StatusConflict, apierrors.CodeConflict, "Controller instance is stopped")
+		return
+	}
+
+	now := time.Now().UTC()
+	if err := tenantDB(c, fc.db).Model(&instance).Update("drain_requested_at", now).Error; err != nil {
+		log.Printf("Error requesting controller drain: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to request drain")
+		return
+	}
+
+	c.JSON(http.StatusAccepted, instance)
+}
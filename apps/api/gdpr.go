@@ -0,0 +1,140 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/penguintechinc/project-template/shared/database"
+	"gorm.io/gorm"
+)
+
+// erasureConfirmationWindow is how long an erasure request can be confirmed
+const erasureConfirmationWindow = 15 * time.Minute
+
+// listMemberships returns the teams a user belongs to and their role in each
+func listMemberships(db *gorm.DB, userID uint) ([]MembershipSummary, error) {
+	memberships := []MembershipSummary{}
+	err := db.Table("team_members").
+		Select("team_members.team_id, teams.name AS team_name, teams.is_global, team_members.role").
+		Joins("INNER JOIN teams ON teams.id = team_members.team_id AND teams.deleted_at IS NULL").
+		Where("team_members.user_id = ? AND team_members.deleted_at IS NULL", userID).
+		Order("teams.name ASC").
+		Scan(&memberships).Error
+	return memberships, err
+}
+
+// exportUserData collects the personal data held about a user: their
+// profile, team memberships, the audit log entries of their actions, and
+// their preferences and saved views
+func exportUserData(db *gorm.DB, user *User) (*UserDataExport, error) {
+	memberships, err := listMemberships(db, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list memberships: %w", err)
+	}
+
+	entries := []database.AuditLog{}
+	if err := db.Where("user_id = ?", user.ID).Order("timestamp ASC, id ASC").Find(&entries).Error; err != nil {
+		return nil, fmt.Errorf("failed to list audit entries: %w", err)
+	}
+
+	prefs := []UserPreference{}
+	if err := db.Where("user_id = ?", user.ID).Order("key").Find(&prefs).Error; err != nil {
+		return nil, fmt.Errorf("failed to list preferences: %w", err)
+	}
+	views := []SavedView{}
+	if err := db.Where("user_id = ?", user.ID).Order("target, name").Find(&views).Error; err != nil {
+		return nil, fmt.Errorf("failed to list saved views: %w", err)
+	}
+
+	return &UserDataExport{
+		ExportedAt:   time.Now().UTC(),
+		User:         *user,
+		Memberships:  memberships,
+		AuditEntries: entries,
+		Preferences:  prefs,
+		SavedViews:   views,
+	}, nil
+}
+
+// erasureImpact counts what erasing a user's personal data would change
+func erasureImpact(db *gorm.DB, userID uint) (ErasureImpact, error) {
+	var impact ErasureImpact
+	if err := db.Model(&TeamMember{}).Where("user_id = ?", userID).Count(&impact.Memberships).Error; err != nil {
+		return impact, err
+	}
+	if err := db.Model(&database.Session{}).Where("user_id = ?", userID).Count(&impact.Sessions).Error; err != nil {
+		return impact, err
+	}
+	if err := db.Model(&UserPreference{}).Where("user_id = ?", userID).Count(&impact.Preferences).Error; err != nil {
+		return impact, err
+	}
+	if err := db.Model(&SavedView{}).Where("user_id = ?", userID).Count(&impact.SavedViews).Error; err != nil {
+		return impact, err
+	}
+	err := db.Model(&database.AuditLog{}).Where("user_id = ? AND erased_at IS NULL", userID).Count(&impact.AuditEntries).Error
+	return impact, err
+}
+
+// eraseUserData anonymizes a user's profile, ends their sessions, removes
+// their team memberships, preferences, saved views and announcement
+// acknowledgements, and erases the IP addresses and user agents of
+// their audit log entries. The entries themselves are kept, still
+// attributed to the now anonymous user, and the hash chain stays intact.
+// It returns the number of audit log entries erased.
+func eraseUserData(tx *gorm.DB, userID uint) (int64, error) {
+	now := time.Now().UTC()
+
+	erased := tx.Model(&database.AuditLog{}).
+		Where("user_id = ? AND erased_at IS NULL", userID).
+		Updates(map[string]interface{}{"ip_address": "", "user_agent": "", "erased_at": now})
+	if erased.Error != nil {
+		return 0, fmt.Errorf("failed to erase audit entries: %w", erased.Error)
+	}
+
+	if err := tx.Where("user_id = ?", userID).Delete(&database.Session{}).Error; err != nil {
+		return 0, fmt.Errorf("failed to end sessions: %w", err)
+	}
+	if err := tx.Where("user_id = ?", userID).Delete(&TeamMember{}).Error; err != nil {
+		return 0, fmt.Errorf("failed to remove memberships: %w", err)
+	}
+	if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&UserPreference{}).Error; err != nil {
+		return 0, fmt.Errorf("failed to remove preferences: %w", err)
+	}
+	if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&SavedView{}).Error; err != nil {
+		return 0, fmt.Errorf("failed to remove saved views: %w", err)
+	}
+	if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&AnnouncementAck{}).Error; err != nil {
+		return 0, fmt.Errorf("failed to remove announcement acknowledgements: %w", err)
+	}
+
+	// The password hash is replaced by one no password can match
+	unusable, err := randomToken()
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Model(&User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"username":            fmt.Sprintf("erased-user-%d", userID),
+		"email":               fmt.Sprintf("erased-user-%d@erased.invalid", userID),
+		"first_name":          "",
+		"last_name":           "",
+		"password_hash":       "!" + unusable,
+		"is_active":           false,
+		"last_login_at":       nil,
+		"password_changed_at": nil,
+	}).Error; err != nil {
+		return 0, fmt.Errorf("failed to anonymize user: %w", err)
+	}
+
+	return erased.RowsAffected, nil
+}
+
+// randomToken returns 32 random bytes, hex encoded
+func randomToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/penguintechinc/project-template/shared/apierrors"
+	"github.com/penguintechinc/project-template/shared/audit"
+	"github.com/penguintechinc/project-template/shared/locks"
+	"gorm.io/gorm"
+)
+
+// GDPRController handles data subject export and erasure requests
+type GDPRController struct {
+	db *gorm.DB
+}
+
+// NewGDPRController creates a new GDPR controller
+func NewGDPRController(db *gorm.DB) *GDPRController {
+	return &GDPRController{db: db}
+}
+
+// loadUser returns the user named by the :id path parameter
+func (gc *GDPRController) loadUser(c *gin.Context) (*User, bool) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		apierrors.Abort(c, http.StatusBadRequest, "invalid_user_id", "User ID must be a valid number")
+		return nil, false
+	}
+
+	var user User
+	if err := tenantDB(c, gc.db).First(&user, uint(userID)).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierrors.Abort(c, http.StatusNotFound, apierrors.CodeNotFound, "User not found")
+		} else {
+			log.Printf("Error retrieving user %d: %v", userID, err)
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to retrieve user")
+		}
+		return nil, false
+	}
+	return &user, true
+}
+
+// ExportUserData downloads the personal data held about a user as a JSON
+// bundle. The export is recorded in the audit log.
+// GET /api/v1/admin/users/:id/export
+func (gc *GDPRController) ExportUserData(c *gin.Context) {
+	if !requireGlobalAdmin(c) {
+		return
+	}
+	user, ok := gc.loadUser(c)
+	if !ok {
+		return
+	}
+
+	db := tenantDB(c, gc.db)
+	export, err := exportUserData(db, user)
+	if err != nil {
+		log.Printf("Error exporting data of user %d: %v", user.ID, err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to export user data")
+		return
+	}
+	body, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		log.Printf("Error encoding data export of user %d: %v", user.ID, err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeInternal, "Failed to export user data")
+		return
+	}
+
+	adminID := c.MustGet("user_id").(uint)
+	if err := audit.RecordAction(c, db, adminID, audit.ActionExport, "users", user.ID, nil,
+		map[string]interface{}{"audit_entries": len(export.AuditEntries)}); err != nil {
+		log.Printf("Error recording data export of user %d in the audit log: %v", user.ID, err)
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="user-%d-export.json"`, user.ID))
+	c.Data(http.StatusOK, "application/json", body)
+}
+
+// StartErasure requests erasure of a user's personal data. Nothing is
+// erased yet: the response reports what erasure would change and carries a
+// confirmation token, which ConfirmErasure must be given before it expires.
+// POST /api/v1/admin/users/:id/erasure
+func (gc *GDPRController) StartErasure(c *gin.Context) {
+	if !requireGlobalAdmin(c) {
+		return
+	}
+	user, ok := gc.loadUser(c)
+	if !ok {
+		return
+	}
+	if hasMinimumRole(user.Role, "admin") {
+		apierrors.Abort(c, http.StatusConflict, "user_is_admin", "Global admins must be demoted before they can be erased")
+		return
+	}
+
+	db := tenantDB(c, gc.db)
+	impact, err := erasureImpact(db, user.ID)
+	if err != nil {
+		log.Printf("Error counting erasure impact for user %d: %v", user.ID, err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to start erasure")
+		return
+	}
+	token, err := randomToken()
+	if err != nil {
+		log.Printf("Error generating erasure token: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeInternal, "Failed to start erasure")
+		return
+	}
+
+	request := ErasureRequest{
+		UserID:      user.ID,
+		RequestedBy: c.MustGet("user_id").(uint),
+		Status:      ErasurePending,
+		TokenHash:   sha256Hex([]byte(token)),
+		ExpiresAt:   time.Now().UTC().Add(erasureConfirmationWindow),
+	}
+	if err := db.Create(&request).Error; err != nil {
+		log.Printf("Error creating erasure request for user %d: %v", user.ID, err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to start erasure")
+		return
+	}
+
+	c.JSON(http.StatusAccepted, ErasureRequestResponse{
+		ErasureRequest:    &request,
+		ConfirmationToken: token,
+		Impact:            impact,
+	})
+}
+
+// loadErasureRequest returns the pending erasure request named by the
+// :request_id path parameter for the user
+func (gc *GDPRController) loadErasureRequest(c *gin.Context, user *User) (*ErasureRequest, bool) {
+	var request ErasureRequest
+	if err := tenantDB(c, gc.db).Where("id = ? AND user_id = ?", c.Param("request_id"), user.ID).First(&request).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierrors.Abort(c, http.StatusNotFound, apierrors.CodeNotFound, "Erasure request not found")
+		} else {
+			log.Printf("Error retrieving erasure request: %v", err)
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to retrieve erasure request")
+		}
+		return nil, false
+	}
+	if request.Status != ErasurePending {
+		apierrors.Abort(c, http.StatusConflict, "erasure_not_pending", "Erasure request is no longer pending")
+		return nil, false
+	}
+	return &request, true
+}
+
+// ConfirmErasure erases a user's personal data. The confirmation token of
+// the request and the user's username must both be given.
+// POST /api/v1/admin/users/:id/erasure/:request_id/confirm
+func (gc *GDPRController) ConfirmErasure(c *gin.Context) {
+	if !requireGlobalAdmin(c) {
+		return
+	}
+	user, ok := gc.loadUser(c)
+	if !ok {
+		return
+	}
+	request, ok := gc.loadErasureRequest(c, user)
+	if !ok {
+		return
+	}
+
+	var req ConfirmErasureRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierrors.AbortWithDetails(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Invalid request body", err.Error())
+		return
+	}
+	if time.Now().UTC().After(request.ExpiresAt) {
+		apierrors.Abort(c, http.StatusGone, "erasure_expired", "Erasure request has expired")
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(sha256Hex([]byte(req.ConfirmationToken))), []byte(request.TokenHash)) != 1 ||
+		req.Username != user.Username {
+		apierrors.Abort(c, http.StatusForbidden, "erasure_not_confirmed", "Confirmation token or username does not match")
+		return
+	}
+
+	adminID := c.MustGet("user_id").(uint)
+	err := tenantDB(c, gc.db).Transaction(func(tx *gorm.DB) error {
+		// The request is locked and checked again, so a concurrent confirm
+		// or cancel on another replica can't run alongside the erasure
+		var current ErasureRequest
+		if err := locks.ForUpdate(tx).First(&current, request.ID).Error; err != nil {
+			return err
+		}
+		if current.Status != ErasurePending {
+			return errErasureNotPending
+		}
+
+		erased, err := eraseUserData(tx, user.ID)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now().UTC()
+		request.Status = ErasureCompleted
+		request.ConfirmedBy = &adminID
+		request.CompletedAt = &now
+		request.AuditEntriesErased = erased
+		if err := tx.Save(request).Error; err != nil {
+			return err
+		}
+
+		return audit.RecordAction(c, tx, adminID, audit.ActionErase, "users", user.ID, nil,
+			map[string]interface{}{"erasure_request_id": request.ID, "audit_entries_erased": erased})
+	})
+	if errors.Is(err, errErasureNotPending) {
+		apierrors.Abort(c, http.StatusConflict, "erasure_not_pending", "Erasure request is no longer pending")
+		return
+	}
+	if err != nil {
+		log.Printf("Error erasing data of user %d: %v", user.ID, err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to erase user data")
+		return
+	}
+
+	c.JSON(http.StatusOK, request)
+}
+
+// errErasureNotPending is returned when an erasure request was confirmed or
+// cancelled concurrently
+var errErasureNotPending = errors.New("erasure request is no longer pending")
+
+// CancelErasure cancels a pending erasure request
+// DELETE /api/v1/admin/users/:id/erasure/:request_id
+func (gc *GDPRController) CancelErasure(c *gin.Context) {
+	if !requireGlobalAdmin(c) {
+		return
+	}
+	user, ok := gc.loadUser(c)
+	if !ok {
+		return
+	}
+	request, ok := gc.loadErasureRequest(c, user)
+	if !ok {
+		return
+	}
+
+	// Only a request still pending is cancelled, so a cancel can't undo
+	// an erasure another replica just completed
+	result := tenantDB(c, gc.db).Model(request).Where("status = ?", ErasurePending).Update("status", ErasureCancelled)
+	if result.Error != nil {
+		log.Printf("Error cancelling erasure request %d: %v", request.ID, result.Error)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to cancel erasure")
+		return
+	}
+	if result.RowsAffected == 0 {
+		apierrors.Abort(c, http.StatusConflict, "erasure_not_pending", "Erasure request is no longer pending")
+		return
+	}
+
+	c.JSON(http.StatusOK, request)
+}
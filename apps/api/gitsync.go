@@ -0,0 +1,626 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+)
+
+// Git sync integration types, which reconcile a team's resources to the
+// definitions in a repository
+const (
+	IntegrationTypeGitHub = "github"
+	IntegrationTypeGitLab = "gitlab"
+)
+
+// Limits on the definitions read from a repository
+const (
+	gitSyncMaxFiles    = 200
+	gitSyncMaxFileSize = 256 << 10
+	gitSyncMaxResponse = 8 << 20
+)
+
+// Git sync plan actions
+const (
+	gitSyncCreate  = "create"
+	gitSyncUpdate  = "update"
+	gitSyncDelete  = "delete"
+	gitSyncRelease = "release"
+)
+
+// GitSyncConfig is the Config document of a github or gitlab integration
+type GitSyncConfig struct {
+	Repository string `json:"repository"` // owner/repo, or the GitLab project path
+	Branch     string `json:"branch"`
+	Path       string `json:"path"`
+	APIURL     string `json:"api_url"`
+	Prune      bool   `json:"prune"`
+}
+
+// GitSyncCredentials holds the API token a git sync integration reads the
+// repository and comments with, and the secret its webhooks are signed with
+type GitSyncCredentials struct {
+	Token         string `json:"token"`
+	WebhookSecret string `json:"webhook_secret"`
+}
+
+// ResourceDefinition is a resource defined in a repository
+type ResourceDefinition struct {
+	APIVersion string `yaml:"apiVersion"`
+	Kind       string `yaml:"kind"`
+	Metadata   struct {
+		Name string `yaml:"name"`
+		// Team names the team of a manifest posted to the apply endpoint.
+		// Definitions in a repository belong to the integration's team.
+		Team string `yaml:"team"`
+	} `yaml:"metadata"`
+	Spec struct {
+		Engine             string                 `yaml:"engine"`
+		Environment        string                 `yaml:"environment"`
+		SizeClass          string                 `yaml:"sizeClass"`
+		TLSEnabled         bool                   `yaml:"tlsEnabled"`
+		DeletionProtection bool                   `yaml:"deletionProtection"`
+		Config             map[string]interface{} `yaml:"config"`
+	} `yaml:"spec"`
+
+	// File the definition was read from
+	File string `yaml:"-"`
+}
+
+// GitSyncChange is a change a sync makes to bring a resource in line with
+// its definition
+type GitSyncChange struct {
+	Action      string   `json:"action"`
+	Name        string   `json:"name"`
+	Environment string   `json:"environment"`
+	File        string   `json:"file,omitempty"`
+	ResourceID  uint     `json:"resource_id,omitempty"`
+	Fields      []string `json:"fields,omitempty"`
+
+	resource *Resource
+	link     *GitSyncedResource
+}
+
+// GitSyncPlan is the changes a commit's definitions make to a team's
+// resources. A plan with errors is not applied.
+type GitSyncPlan struct {
+	Commit  string          `json:"commit"`
+	Changes []GitSyncChange `json:"changes"`
+	Errors  []string        `json:"errors,omitempty"`
+	Applied bool            `json:"applied"`
+}
+
+// gitProvider reads definitions from a repository host and comments on its
+// pull or merge requests
+type gitProvider interface {
+	ReadFiles(ctx context.Context, ref, dir string) (map[string][]byte, error)
+	Comment(ctx context.Context, number int, body string) error
+}
+
+// isGitSyncType reports whether an integration type syncs a repository
+func isGitSyncType(t string) bool {
+	return t == IntegrationTypeGitHub || t == IntegrationTypeGitLab
+}
+
+// newGitProvider builds the provider of a git sync integration
+func newGitProvider(integration *Integration) (gitProvider, *GitSyncConfig, *GitSyncCredentials, error) {
+	var cfg GitSyncConfig
+	if err := json.Unmarshal(integration.Config, &cfg); err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid git sync config: %w", err)
+	}
+	var creds GitSyncCredentials
+	if len(integration.Credentials) > 0 {
+		if err := json.Unmarshal(integration.Credentials, &creds); err != nil {
+			return nil, nil, nil, fmt.Errorf("invalid credentials: %w", err)
+		}
+	}
+	if cfg.Branch == "" {
+		cfg.Branch = "main"
+	}
+	cfg.Path = strings.Trim(cfg.Path, "/")
+
+	client := &http.Client{Timeout: 15 * time.Second}
+	switch integration.Type {
+	case IntegrationTypeGitHub:
+		if cfg.APIURL == "" {
+			cfg.APIURL = "https://api.github.com"
+		}
+		return &githubProvider{client: client, apiURL: strings.TrimRight(cfg.APIURL, "/"), repo: cfg.Repository, token: creds.Token}, &cfg, &creds, nil
+	case IntegrationTypeGitLab:
+		if cfg.APIURL == "" {
+			cfg.APIURL = "https://gitlab.com/api/v4"
+		}
+		return &gitlabProvider{client: client, apiURL: strings.TrimRight(cfg.APIURL, "/"), project: url.PathEscape(cfg.Repository), token: creds.Token}, &cfg, &creds, nil
+	}
+	return nil, nil, nil, fmt.Errorf("integration type %q is not a git sync integration", integration.Type)
+}
+
+// isDefinitionFile reports whether a repository file under dir holds
+// resource definitions
+func isDefinitionFile(file, dir string) bool {
+	if dir != "" && !strings.HasPrefix(file, dir+"/") {
+		return false
+	}
+	ext := path.Ext(file)
+	return ext == ".yaml" || ext == ".yml"
+}
+
+// gitRequest makes a repository host API request and decodes a JSON
+// response into out, or returns the raw body when out is nil
+func gitRequest(ctx context.Context, client *http.Client, method, endpoint string, header http.Header, body interface{}, out interface{}) ([]byte, http.Header, error) {
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to marshal request: %w", err)
+		}
+		reader = bytes.NewReader(encoded)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, nil, fmt.Errorf("repository host returned %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, gitSyncMaxResponse))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if out != nil {
+		if err := json.Unmarshal(data, out); err != nil {
+			return nil, nil, fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
+	return data, resp.Header, nil
+}
+
+// githubProvider reads repositories through the GitHub REST API
+type githubProvider struct {
+	client *http.Client
+	apiURL string
+	repo   string
+	token  string
+}
+
+func (g *githubProvider) header(accept string) http.Header {
+	header := http.Header{"Accept": {accept}, "X-Github-Api-Version": {"2022-11-28"}}
+	if g.token != "" {
+		header.Set("Authorization", "Bearer "+g.token)
+	}
+	return header
+}
+
+// ReadFiles returns the definition files under dir at ref
+func (g *githubProvider) ReadFiles(ctx context.Context, ref, dir string) (map[string][]byte, error) {
+	var tree struct {
+		Tree []struct {
+			Path string `json:"path"`
+			Type string `json:"type"`
+			Size int    `json:"size"`
+		} `json:"tree"`
+		Truncated bool `json:"truncated"`
+	}
+	treeURL := fmt.Sprintf("%s/repos/%s/git/trees/%s?recursive=1", g.apiURL, g.repo, url.PathEscape(ref))
+	if _, _, err := gitRequest(ctx, g.client, http.MethodGet, treeURL, g.header("application/vnd.github+json"), nil, &tree); err != nil {
+		return nil, err
+	}
+	if tree.Truncated {
+		return nil, errors.New("the repository tree is too large to read")
+	}
+
+	files := make(map[string][]byte)
+	for _, entry := range tree.Tree {
+		if entry.Type != "blob" || !isDefinitionFile(entry.Path, dir) {
+			continue
+		}
+		if len(files) == gitSyncMaxFiles {
+			return nil, fmt.Errorf("more than %d definition files", gitSyncMaxFiles)
+		}
+		if entry.Size > gitSyncMaxFileSize {
+			return nil, fmt.Errorf("%s is larger than %d bytes", entry.Path, gitSyncMaxFileSize)
+		}
+		fileURL := fmt.Sprintf("%s/repos/%s/contents/%s?ref=%s", g.apiURL, g.repo, escapeFilePath(entry.Path), url.QueryEscape(ref))
+		data, _, err := gitRequest(ctx, g.client, http.MethodGet, fileURL, g.header("application/vnd.github.raw+json"), nil, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", entry.Path, err)
+		}
+		files[entry.Path] = data
+	}
+	return files, nil
+}
+
+// Comment comments on a pull request
+func (g *githubProvider) Comment(ctx context.Context, number int, body string) error {
+	commentURL := fmt.Sprintf("%s/repos/%s/issues/%d/comments", g.apiURL, g.repo, number)
+	_, _, err := gitRequest(ctx, g.client, http.MethodPost, commentURL, g.header("application/vnd.github+json"), map[string]string{"body": body}, nil)
+	return err
+}
+
+// gitlabProvider reads repositories through the GitLab REST API
+type gitlabProvider struct {
+	client  *http.Client
+	apiURL  string
+	project string
+	token   string
+}
+
+func (g *gitlabProvider) header() http.Header {
+	header := http.Header{}
+	if g.token != "" {
+		header.Set("Private-Token", g.token)
+	}
+	return header
+}
+
+// ReadFiles returns the definition files under dir at ref
+func (g *gitlabProvider) ReadFiles(ctx context.Context, ref, dir string) (map[string][]byte, error) {
+	var paths []string
+	for page := "1"; page != ""; {
+		treeURL := fmt.Sprintf("%s/projects/%s/repository/tree?recursive=true&per_page=100&page=%s&ref=%s&path=%s",
+			g.apiURL, g.project, page, url.QueryEscape(ref), url.QueryEscape(dir))
+		var entries []struct {
+			Path string `json:"path"`
+			Type string `json:"type"`
+		}
+		_, header, err := gitRequest(ctx, g.client, http.MethodGet, treeURL, g.header(), nil, &entries)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if entry.Type == "blob" && isDefinitionFile(entry.Path, dir) {
+				paths = append(paths, entry.Path)
+			}
+		}
+		if len(paths) > gitSyncMaxFiles {
+			return nil, fmt.Errorf("more than %d definition files", gitSyncMaxFiles)
+		}
+		page = header.Get("X-Next-Page")
+	}
+
+	files := make(map[string][]byte, len(paths))
+	for _, file := range paths {
+		fileURL := fmt.Sprintf("%s/projects/%s/repository/files/%s/raw?ref=%s", g.apiURL, g.project, url.PathEscape(file), url.QueryEscape(ref))
+		data, _, err := gitRequest(ctx, g.client, http.MethodGet, fileURL, g.header(), nil, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		if len(data) > gitSyncMaxFileSize {
+			return nil, fmt.Errorf("%s is larger than %d bytes", file, gitSyncMaxFileSize)
+		}
+		files[file] = data
+	}
+	return files, nil
+}
+
+// Comment comments on a merge request
+func (g *gitlabProvider) Comment(ctx context.Context, number int, body string) error {
+	noteURL := fmt.Sprintf("%s/projects/%s/merge_requests/%d/notes", g.apiURL, g.project, number)
+	_, _, err := gitRequest(ctx, g.client, http.MethodPost, noteURL, g.header(), map[string]string{"body": body}, nil)
+	return err
+}
+
+// escapeFilePath escapes each segment of a repository file path
+func escapeFilePath(file string) string {
+	segments := strings.Split(file, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// parseResourceDefinitions parses the Resource documents of definition
+// files. Config is normalized to JSON types, so it compares with stored
+// configs.
+func parseResourceDefinitions(files map[string][]byte) ([]*ResourceDefinition, error) {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var defs []*ResourceDefinition
+	for _, name := range names {
+		decoder := yaml.NewDecoder(bytes.NewReader(files[name]))
+		for {
+			def := &ResourceDefinition{File: name}
+			if err := decoder.Decode(def); err != nil {
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			if def.APIVersion == "" && def.Kind == "" {
+				continue
+			}
+			if def.APIVersion != "nest.penguintech.io/v1" || def.Kind != "Resource" {
+				return nil, fmt.Errorf("%s: expected apiVersion nest.penguintech.io/v1 and kind Resource", name)
+			}
+			if def.Metadata.Name == "" || def.Spec.Engine == "" {
+				return nil, fmt.Errorf("%s: metadata.name and spec.engine are required", name)
+			}
+			if def.Spec.Config != nil {
+				encoded, err := json.Marshal(def.Spec.Config)
+				if err != nil {
+					return nil, fmt.Errorf("%s: invalid config for %s: %w", name, def.Metadata.Name, err)
+				}
+				def.Spec.Config = nil
+				if err := json.Unmarshal(encoded, &def.Spec.Config); err != nil {
+					return nil, fmt.Errorf("%s: invalid config for %s: %w", name, def.Metadata.Name, err)
+				}
+			}
+			defs = append(defs, def)
+		}
+	}
+	return defs, nil
+}
+
+// planGitSync compares a team's resources with their definitions. Resources
+// the integration created and that are no longer defined are deleted when
+// cfg.Prune is set, and otherwise released from the integration.
+func planGitSync(db *gorm.DB, integration *Integration, cfg *GitSyncConfig, commit string, defs []*ResourceDefinition) (*GitSyncPlan, error) {
+	plan := &GitSyncPlan{Commit: commit, Changes: []GitSyncChange{}}
+	teamID := *integration.TeamID
+
+	var links []*GitSyncedResource
+	if err := db.Where("integration_id = ?", integration.ID).Find(&links).Error; err != nil {
+		return nil, fmt.Errorf("failed to load synced resources: %w", err)
+	}
+	linked := make(map[uint]*GitSyncedResource, len(links))
+	for _, link := range links {
+		linked[link.ResourceID] = link
+	}
+
+	envs, err := teamEnvironments(db, teamID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load environments: %w", err)
+	}
+
+	defined := make(map[string]bool)
+	kept := make(map[uint]bool)
+	for _, def := range defs {
+		envIdx := 0
+		if def.Spec.Environment != "" {
+			envIdx = findEnvironment(envs, def.Spec.Environment)
+		}
+		if envIdx < 0 {
+			plan.Errors = append(plan.Errors, fmt.Sprintf("%s: environment %s is not part of the team's pipeline", def.File, def.Spec.Environment))
+			continue
+		}
+		env := &envs[envIdx]
+		key := env.Name + "/" + def.Metadata.Name
+		if defined[key] {
+			plan.Errors = append(plan.Errors, fmt.Sprintf("%s: %s is defined more than once in %s", def.File, def.Metadata.Name, env.Name))
+			continue
+		}
+		defined[key] = true
+
+		change, err := planDefinition(db, teamID, env, def)
+		if err != nil {
+			plan.Errors = append(plan.Errors, fmt.Sprintf("%s: %s", def.File, err))
+			continue
+		}
+		if change.resource.ID != 0 {
+			change.link = linked[change.resource.ID]
+			if change.link == nil {
+				plan.Errors = append(plan.Errors, fmt.Sprintf("%s: %s already exists in %s and isn't managed by this repository", def.File, def.Metadata.Name, env.Name))
+				continue
+			}
+			kept[change.resource.ID] = true
+			if change.Action == "" {
+				continue
+			}
+		}
+		plan.Changes = append(plan.Changes, *change)
+	}
+
+	for _, link := range links {
+		if kept[link.ResourceID] {
+			continue
+		}
+		var resource Resource
+		if err := db.First(&resource, link.ResourceID).Error; err != nil {
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, fmt.Errorf("failed to load resource %d: %w", link.ResourceID, err)
+			}
+			// Deleted outside the repository
+			plan.Changes = append(plan.Changes, GitSyncChange{Action: gitSyncRelease, Name: link.Name, Environment: link.Environment, ResourceID: link.ResourceID, link: link})
+			continue
+		}
+		change := GitSyncChange{Action: gitSyncRelease, Name: resource.Name, Environment: resource.Environment, File: link.File, ResourceID: resource.ID, resource: &resource, link: link}
+		if cfg.Prune {
+			if resource.DeletionProtection {
+				plan.Errors = append(plan.Errors, fmt.Sprintf("%s in %s has deletion protection enabled; disable it before removing its definition", resource.Name, resource.Environment))
+				continue
+			}
+			change.Action = gitSyncDelete
+		}
+		plan.Changes = append(plan.Changes, change)
+	}
+
+	return plan, nil
+}
+
+// planDefinition validates a definition and plans its change: a create
+// when no resource of its name exists in the environment, an update of the
+// fields that differ, or none. The existing resource is returned in the
+// change for the caller to check it's managed by the integration.
+func planDefinition(db *gorm.DB, teamID uint, env *Environment, def *ResourceDefinition) (*GitSyncChange, error) {
+	desired, err := definedResource(db, teamID, env, def)
+	if err != nil {
+		return nil, err
+	}
+	change := &GitSyncChange{Name: desired.Name, Environment: env.Name, File: def.File, resource: desired}
+
+	var existing Resource
+	err = db.Where("team_id = ? AND environment = ? AND name = ?", teamID, env.Name, desired.Name).First(&existing).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		change.Action = gitSyncCreate
+		return change, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to check existing resources: %w", err)
+	}
+
+	change.ResourceID = existing.ID
+	if existing.ResourceTypeID != desired.ResourceTypeID {
+		return nil, fmt.Errorf("the engine of %s can't be changed; define a new resource instead", desired.Name)
+	}
+	if existing.SizeClass != desired.SizeClass {
+		return nil, fmt.Errorf("the size class of %s can't be changed by a sync; resize it through the API and update its definition", desired.Name)
+	}
+
+	// A stored config that doesn't decode counts as changed, so the sync
+	// replaces it with the definition's
+	var current, wanted map[string]interface{}
+	if !decodeJSONField(desired.Config, &wanted, "definition config") {
+		return nil, fmt.Errorf("the config of %s doesn't decode", desired.Name)
+	}
+	if !decodeJSONField(existing.Config, &current, "config") ||
+		!reflect.DeepEqual(normalizeConfig(current), normalizeConfig(wanted)) {
+		change.Fields = append(change.Fields, "config")
+	}
+	if existing.TLSEnabled != desired.TLSEnabled {
+		change.Fields = append(change.Fields, "tls_enabled")
+	}
+	if existing.DeletionProtection != desired.DeletionProtection {
+		change.Fields = append(change.Fields, "deletion_protection")
+	}
+	if len(change.Fields) > 0 {
+		change.Action = gitSyncUpdate
+	}
+
+	existing.Config = desired.Config
+	existing.TLSEnabled = desired.TLSEnabled
+	existing.DeletionProtection = desired.DeletionProtection
+	change.resource = &existing
+	return change, nil
+}
+
+// definedResource validates a definition and returns the full lifecycle
+// resource it defines in the team environment, with its size class and the
+// environment's defaults applied to its config
+func definedResource(db *gorm.DB, teamID uint, env *Environment, def *ResourceDefinition) (*Resource, error) {
+	var resourceType ResourceType
+	if err := db.Where("name = ?", def.Spec.Engine).First(&resourceType).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("unknown engine %s", def.Spec.Engine)
+		}
+		return nil, fmt.Errorf("failed to load resource type: %w", err)
+	}
+
+	cfg := def.Spec.Config
+	if err := validateResourcePayload(resourceType.Name, nil, nil, cfg); err != nil {
+		return nil, err
+	}
+	if err := validateConfigInjections(db, cfg); err != nil {
+		return nil, err
+	}
+	if err := validateTuning(resourceType.Name, cfg); err != nil {
+		return nil, err
+	}
+	if err := validateConfigResources(cfg); err != nil {
+		return nil, err
+	}
+	if err := validateConfigMaintenanceWindow(cfg); err != nil {
+		return nil, err
+	}
+	switch def.Spec.SizeClass {
+	case "":
+	case SizeClassCustom:
+		if requests, _ := configResources(cfg); requests == nil {
+			return nil, errors.New("the custom size class requires config.resources")
+		}
+	default:
+		classes, err := resourceSizeClasses(db, &resourceType)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load size classes: %w", err)
+		}
+		class := findSizeClass(classes, def.Spec.SizeClass)
+		if class == nil {
+			return nil, fmt.Errorf("unknown size class %s for %s", def.Spec.SizeClass, resourceType.Name)
+		}
+		cfg = applySizeClass(cfg, nil, class)
+	}
+	cfg = applyEnvironmentDefaults(cfg, env)
+	encoded, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode config: %w", err)
+	}
+
+	desired := &Resource{
+		Name:               def.Metadata.Name,
+		ResourceTypeID:     resourceType.ID,
+		TeamID:             teamID,
+		Environment:        env.Name,
+		Status:             "pending",
+		LifecycleMode:      "full",
+		ProvisioningMethod: "kubernetes",
+		Config:             encoded,
+		TLSEnabled:         def.Spec.TLSEnabled,
+		DeletionProtection: def.Spec.DeletionProtection,
+		SizeClass:          def.Spec.SizeClass,
+	}
+	return desired, nil
+}
+
+// renderGitSyncPlan renders a plan as a Markdown comment for a pull or
+// merge request
+func renderGitSyncPlan(plan *GitSyncPlan) string {
+	var b strings.Builder
+	commit := plan.Commit
+	if len(commit) > 12 {
+		commit = commit[:12]
+	}
+	fmt.Fprintf(&b, "#### NEST plan for `%s`\n\n", commit)
+
+	if len(plan.Errors) > 0 {
+		b.WriteString("The definitions can't be applied:\n\n")
+		for _, e := range plan.Errors {
+			fmt.Fprintf(&b, "- %s\n", e)
+		}
+		b.WriteString("\n")
+	}
+
+	if len(plan.Changes) == 0 {
+		b.WriteString("No changes to resources.\n")
+		return b.String()
+	}
+
+	counts := map[string]int{}
+	b.WriteString("| Action | Resource | Environment | Changes |\n|---|---|---|---|\n")
+	for _, change := range plan.Changes {
+		counts[change.Action]++
+		fmt.Fprintf(&b, "| %s | %s | %s | %s |\n", change.Action, change.Name, change.Environment, strings.Join(change.Fields, ", "))
+	}
+	fmt.Fprintf(&b, "\n%d to create, %d to update, %d to delete, %d to release.\n",
+		counts[gitSyncCreate], counts[gitSyncUpdate], counts[gitSyncDelete], counts[gitSyncRelease])
+	return b.String()
+}
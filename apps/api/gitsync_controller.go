@@ -0,0 +1,374 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/penguintechinc/project-template/shared/apierrors"
+	"github.com/penguintechinc/project-template/shared/audit"
+	"github.com/penguintechinc/project-template/shared/database"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// gitSyncTimeout bounds reading a repository and applying its definitions
+const gitSyncTimeout = 30 * time.Second
+
+// gitEvent is a webhook event of a repository host that git sync acts on:
+// a push to a branch, or a pull or merge request opened or updated against
+// one
+type gitEvent struct {
+	push   bool
+	review bool
+	branch string
+	commit string
+	number int
+}
+
+// verifyGitWebhook checks a webhook was sent by the repository host with
+// the integration's secret: GitHub signs the body with it, and GitLab sends
+// it as a token
+func verifyGitWebhook(integrationType, secret string, header http.Header, body []byte) bool {
+	if secret == "" {
+		return false
+	}
+	switch integrationType {
+	case IntegrationTypeGitHub:
+		expected := "sha256=" + hex.EncodeToString(hmacSHA256([]byte(secret), string(body)))
+		return subtle.ConstantTimeCompare([]byte(header.Get("X-Hub-Signature-256")), []byte(expected)) == 1
+	case IntegrationTypeGitLab:
+		return subtle.ConstantTimeCompare([]byte(header.Get("X-Gitlab-Token")), []byte(secret)) == 1
+	}
+	return false
+}
+
+// parseGitEvent extracts the event from a webhook, or nil for events git
+// sync ignores
+func parseGitEvent(integrationType string, header http.Header, body []byte) (*gitEvent, error) {
+	switch integrationType {
+	case IntegrationTypeGitHub:
+		switch header.Get("X-GitHub-Event") {
+		case "push":
+			var payload struct {
+				Ref     string `json:"ref"`
+				After   string `json:"after"`
+				Deleted bool   `json:"deleted"`
+			}
+			if err := json.Unmarshal(body, &payload); err != nil {
+				return nil, err
+			}
+			if payload.Deleted || !strings.HasPrefix(payload.Ref, "refs/heads/") {
+				return nil, nil
+			}
+			return &gitEvent{push: true, branch: strings.TrimPrefix(payload.Ref, "refs/heads/"), commit: payload.After}, nil
+		case "pull_request":
+			var payload struct {
+				Action      string `json:"action"`
+				Number      int    `json:"number"`
+				PullRequest struct {
+					Head struct {
+						SHA string `json:"sha"`
+					} `json:"head"`
+					Base struct {
+						Ref string `json:"ref"`
+					} `json:"base"`
+				} `json:"pull_request"`
+			}
+			if err := json.Unmarshal(body, &payload); err != nil {
+				return nil, err
+			}
+			if payload.Action != "opened" && payload.Action != "synchronize" && payload.Action != "reopened" {
+				return nil, nil
+			}
+			return &gitEvent{review: true, branch: payload.PullRequest.Base.Ref, commit: payload.PullRequest.Head.SHA, number: payload.Number}, nil
+		}
+	case IntegrationTypeGitLab:
+		switch header.Get("X-Gitlab-Event") {
+		case "Push Hook":
+			var payload struct {
+				Ref         string `json:"ref"`
+				CheckoutSHA string `json:"checkout_sha"`
+			}
+			if err := json.Unmarshal(body, &payload); err != nil {
+				return nil, err
+			}
+			if payload.CheckoutSHA == "" || !strings.HasPrefix(payload.Ref, "refs/heads/") {
+				return nil, nil
+			}
+			return &gitEvent{push: true, branch: strings.TrimPrefix(payload.Ref, "refs/heads/"), commit: payload.CheckoutSHA}, nil
+		case "Merge Request Hook":
+			var payload struct {
+				ObjectAttributes struct {
+					IID          int    `json:"iid"`
+					Action       string `json:"action"`
+					TargetBranch string `json:"target_branch"`
+					LastCommit   struct {
+						ID string `json:"id"`
+					} `json:"last_commit"`
+				} `json:"object_attributes"`
+			}
+			if err := json.Unmarshal(body, &payload); err != nil {
+				return nil, err
+			}
+			attrs := payload.ObjectAttributes
+			if attrs.Action != "open" && attrs.Action != "update" && attrs.Action != "reopen" {
+				return nil, nil
+			}
+			return &gitEvent{review: true, branch: attrs.TargetBranch, commit: attrs.LastCommit.ID, number: attrs.IID}, nil
+		}
+	}
+	return nil, nil
+}
+
+// GitWebhook receives push and pull or merge request webhooks of a git sync
+// integration's repository. Pushes to the integration's branch are applied
+// to the team's resources, and pull or merge requests against it are
+// commented on with the changes they would make.
+// POST /api/v1/integrations/:id/webhook
+func (ic *IntegrationController) GitWebhook(c *gin.Context) {
+	integration, ok := ic.loadIntegration(c)
+	if !ok {
+		return
+	}
+	if !isGitSyncType(integration.Type) {
+		apierrors.Abort(c, http.StatusBadRequest, "invalid_integration", "Only git sync integrations receive webhooks")
+		return
+	}
+	provider, cfg, creds, err := newGitProvider(integration)
+	if err != nil {
+		apierrors.Abort(c, http.StatusBadRequest, "invalid_integration", err.Error())
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, 5<<20))
+	if err != nil {
+		apierrors.Abort(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Invalid request body")
+		return
+	}
+	if !verifyGitWebhook(integration.Type, creds.WebhookSecret, c.Request.Header, body) {
+		apierrors.Abort(c, http.StatusUnauthorized, "invalid_signature", "Webhook signature is invalid")
+		return
+	}
+
+	event, err := parseGitEvent(integration.Type, c.Request.Header, body)
+	if err != nil {
+		apierrors.AbortWithDetails(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Invalid request body", err.Error())
+		return
+	}
+	if event == nil || event.branch != cfg.Branch || !integration.Enabled {
+		c.JSON(http.StatusOK, gin.H{"status": "ignored"})
+		return
+	}
+
+	plan, err := ic.syncCommit(c, integration, provider, cfg, event.commit, event.push)
+	if err != nil {
+		log.Printf("Git sync of integration %d at %s failed: %v", integration.ID, event.commit, err)
+		apierrors.AbortWithDetails(c, http.StatusBadGateway, "git_sync_failed", "Git sync failed", err.Error())
+		return
+	}
+	if event.review {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), gitSyncTimeout)
+		defer cancel()
+		if err := provider.Comment(ctx, event.number, renderGitSyncPlan(plan)); err != nil {
+			log.Printf("Error commenting the plan of integration %d on request %d: %v", integration.ID, event.number, err)
+		}
+	}
+
+	c.JSON(http.StatusOK, plan)
+}
+
+// SyncGitRepository syncs a git sync integration's team to its branch, or
+// to ref, without waiting for a push. With dry_run=true the plan is
+// returned without applying it.
+// POST /api/v1/integrations/:id/sync
+func (ic *IntegrationController) SyncGitRepository(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "User context not found")
+		return
+	}
+
+	integration, ok := ic.loadIntegration(c)
+	if !ok {
+		return
+	}
+	if !ic.canManageIntegration(c, userID.(uint), integration.TeamID) {
+		return
+	}
+	if !isGitSyncType(integration.Type) {
+		apierrors.Abort(c, http.StatusBadRequest, "invalid_integration", "Only git sync integrations can be synced")
+		return
+	}
+	provider, cfg, _, err := newGitProvider(integration)
+	if err != nil {
+		apierrors.Abort(c, http.StatusBadRequest, "invalid_integration", err.Error())
+		return
+	}
+
+	ref := c.DefaultQuery("ref", cfg.Branch)
+	plan, err := ic.syncCommit(c, integration, provider, cfg, ref, c.Query("dry_run") != "true")
+	if err != nil {
+		log.Printf("Git sync of integration %d at %s failed: %v", integration.ID, ref, err)
+		apierrors.AbortWithDetails(c, http.StatusBadGateway, "git_sync_failed", "Git sync failed", err.Error())
+		return
+	}
+	if len(plan.Errors) > 0 {
+		apierrors.AbortWithDetails(c, http.StatusUnprocessableEntity, "invalid_definitions", "The repository's definitions can't be applied", plan)
+		return
+	}
+
+	c.JSON(http.StatusOK, plan)
+}
+
+// syncCommit plans the definitions at a commit against the integration's
+// team, and applies the plan when apply is set and it has no errors.
+// Errors reading the repository are returned; errors in the definitions
+// are reported in the plan.
+func (ic *IntegrationController) syncCommit(c *gin.Context, integration *Integration, provider gitProvider, cfg *GitSyncConfig, commit string, apply bool) (*GitSyncPlan, error) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), gitSyncTimeout)
+	defer cancel()
+
+	files, err := provider.ReadFiles(ctx, commit, cfg.Path)
+	if err != nil {
+		ic.recordGitSync(c, integration, apply, err.Error())
+		return nil, err
+	}
+
+	db := tenantDB(c, ic.db).WithContext(ctx)
+	plan := &GitSyncPlan{Commit: commit, Changes: []GitSyncChange{}}
+	defs, err := parseResourceDefinitions(files)
+	if err != nil {
+		plan.Errors = append(plan.Errors, err.Error())
+	} else {
+		// Syncs of the same integration are applied one at a time, so that
+		// each plans against the result of the last
+		ic.syncMu.Lock()
+		defer ic.syncMu.Unlock()
+		if plan, err = planGitSync(db, integration, cfg, commit, defs); err != nil {
+			return nil, err
+		}
+	}
+	if !apply {
+		return plan, nil
+	}
+	if len(plan.Errors) > 0 {
+		ic.recordGitSync(c, integration, apply, strings.Join(plan.Errors, "; "))
+		return plan, nil
+	}
+
+	created, err := ic.applyGitSync(c, db, integration, plan)
+	if err != nil {
+		ic.recordGitSync(c, integration, apply, err.Error())
+		return nil, err
+	}
+	plan.Applied = true
+	ic.recordGitSync(c, integration, apply, "")
+
+	// Provision monitoring dashboards for the new resources
+	primary := database.UsePrimary(tenantDB(c, ic.db))
+	for _, id := range created {
+		go func(id uint) {
+			if err := NewGrafanaProvisioner(primary).ProvisionResource(context.Background(), id); err != nil {
+				log.Printf("Grafana provisioning failed for resource %d: %v", id, err)
+			}
+		}(id)
+	}
+	return plan, nil
+}
+
+// applyGitSync applies a plan in one transaction, as the user who set up
+// the integration, and returns the IDs of the resources it created
+func (ic *IntegrationController) applyGitSync(c *gin.Context, db *gorm.DB, integration *Integration, plan *GitSyncPlan) ([]uint, error) {
+	userID := integration.CreatedBy
+	teamID := *integration.TeamID
+	finalizers, _ := json.Marshal([]string{ControllerFinalizer})
+
+	var created []uint
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for i := range plan.Changes {
+			change := &plan.Changes[i]
+			resource := change.resource
+			switch change.Action {
+			case gitSyncCreate:
+				resource.CreatedBy = userID
+				resource.Finalizers = datatypes.JSON(finalizers)
+				if err := tx.Create(resource).Error; err != nil {
+					return err
+				}
+				change.ResourceID = resource.ID
+				created = append(created, resource.ID)
+				if err := tx.Create(&GitSyncedResource{
+					IntegrationID: integration.ID,
+					ResourceID:    resource.ID,
+					TeamID:        teamID,
+					Name:          resource.Name,
+					Environment:   resource.Environment,
+					File:          change.File,
+				}).Error; err != nil {
+					return err
+				}
+				if err := audit.Record(c, tx, userID, "resources", resource.ID, &teamID, nil, resource); err != nil {
+					return err
+				}
+			case gitSyncUpdate:
+				var before Resource
+				if err := tx.First(&before, resource.ID).Error; err != nil {
+					return err
+				}
+				if err := tx.Model(&Resource{}).Where("id = ?", resource.ID).Updates(map[string]interface{}{
+					"config":              resource.Config,
+					"tls_enabled":         resource.TLSEnabled,
+					"deletion_protection": resource.DeletionProtection,
+					"generation":          nextGeneration,
+				}).Error; err != nil {
+					return err
+				}
+				if err := audit.Record(c, tx, userID, "resources", resource.ID, &teamID, &before, resource); err != nil {
+					return err
+				}
+			case gitSyncDelete:
+				if err := tx.Delete(resource).Error; err != nil {
+					return err
+				}
+				if err := tx.Unscoped().Delete(change.link).Error; err != nil {
+					return err
+				}
+				if err := audit.Record(c, tx, userID, "resources", resource.ID, &teamID, resource, nil); err != nil {
+					return err
+				}
+			case gitSyncRelease:
+				if err := tx.Unscoped().Delete(change.link).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return tx.Model(&GitSyncedResource{}).Where("integration_id = ?", integration.ID).Update("commit", plan.Commit).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// recordGitSync records the outcome of a sync on the integration. Plans
+// that weren't to be applied leave it as it was.
+func (ic *IntegrationController) recordGitSync(c *gin.Context, integration *Integration, apply bool, lastError string) {
+	if !apply {
+		return
+	}
+	updates := map[string]interface{}{"last_error": lastError}
+	if lastError == "" {
+		updates["last_sync_at"] = time.Now()
+	}
+	if err := tenantDB(c, ic.db).Model(integration).Updates(updates).Error; err != nil {
+		log.Printf("Error recording git sync of integration %d: %v", integration.ID, err)
+	}
+}
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// IntegrationTypeGrafana identifies Grafana dashboard provisioning integrations
+const IntegrationTypeGrafana = "grafana"
+
+// GrafanaConfig is the Config document of a grafana integration
+type GrafanaConfig struct {
+	URL           string `json:"url"`
+	OrgID         int    `json:"org_id"`
+	DatasourceUID string `json:"datasource_uid"`
+	FolderPrefix  string `json:"folder_prefix"`
+	SyncMembers   bool   `json:"sync_members"`
+}
+
+// GrafanaCredentials is the Credentials document of a grafana integration
+type GrafanaCredentials struct {
+	APIToken string `json:"api_token"`
+}
+
+// errGrafanaNotFound is returned by the client for 404 responses
+var errGrafanaNotFound = errors.New("grafana: not found")
+
+// GrafanaClient is a minimal client for the Grafana HTTP API
+type GrafanaClient struct {
+	baseURL string
+	token   string
+	orgID   int
+	client  *http.Client
+}
+
+// NewGrafanaClient creates a Grafana API client
+func NewGrafanaClient(cfg GrafanaConfig, creds GrafanaCredentials) *GrafanaClient {
+	return &GrafanaClient{
+		baseURL: strings.TrimRight(cfg.URL, "/"),
+		token:   creds.APIToken,
+		orgID:   cfg.OrgID,
+		client:  &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+// do performs an API request, decoding the JSON response into out when non-nil
+func (g *GrafanaClient) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request: %w", err)
+		}
+		reader = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, g.baseURL+path, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+g.token)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if g.orgID > 0 {
+		req.Header.Set("X-Grafana-Org-Id", fmt.Sprintf("%d", g.orgID))
+	}
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("grafana request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return errGrafanaNotFound
+	}
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("grafana %s %s returned %d: %s", method, path, resp.StatusCode, bytes.TrimSpace(msg))
+	}
+
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("failed to decode grafana response: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Health checks that Grafana is reachable and the token is accepted
+func (g *GrafanaClient) Health(ctx context.Context) error {
+	return g.do(ctx, http.MethodGet, "/api/folders?limit=1", nil, nil)
+}
+
+// EnsureFolder creates the folder if it does not already exist
+func (g *GrafanaClient) EnsureFolder(ctx context.Context, uid, title string) error {
+	err := g.do(ctx, http.MethodGet, "/api/folders/"+url.PathEscape(uid), nil, nil)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, errGrafanaNotFound) {
+		return err
+	}
+
+	return g.do(ctx, http.MethodPost, "/api/folders", map[string]interface{}{
+		"uid":   uid,
+		"title": title,
+	}, nil)
+}
+
+// EnsureTeam returns the ID of the named Grafana team, creating it if needed
+func (g *GrafanaClient) EnsureTeam(ctx context.Context, name string) (int64, error) {
+	var search struct {
+		Teams []struct {
+			ID   int64  `json:"id"`
+			Name string `json:"name"`
+		} `json:"teams"`
+	}
+	if err := g.do(ctx, http.MethodGet, "/api/teams/search?name="+url.QueryEscape(name), nil, &search); err != nil {
+		return 0, err
+	}
+	for _, t := range search.Teams {
+		if t.Name == name {
+			return t.ID, nil
+		}
+	}
+
+	var created struct {
+		TeamID int64 `json:"teamId"`
+	}
+	if err := g.do(ctx, http.MethodPost, "/api/teams", map[string]interface{}{"name": name}, &created); err != nil {
+		return 0, err
+	}
+	return created.TeamID, nil
+}
+
+// AddTeamMember adds an existing Grafana user (looked up by email) to a team.
+// Users that do not exist in Grafana are skipped.
+func (g *GrafanaClient) AddTeamMember(ctx context.Context, teamID int64, email string) error {
+	var user struct {
+		ID int64 `json:"id"`
+	}
+	err := g.do(ctx, http.MethodGet, "/api/users/lookup?loginOrEmail="+url.QueryEscape(email), nil, &user)
+	if errors.Is(err, errGrafanaNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	err = g.do(ctx, http.MethodPost, fmt.Sprintf("/api/teams/%d/members", teamID),
+		map[string]interface{}{"userId": user.ID}, nil)
+	// Grafana rejects duplicate membership; treat it as success
+	if err != nil && strings.Contains(err.Error(), "already") {
+		return nil
+	}
+	return err
+}
+
+// SetFolderViewers grants a Grafana team view permission on a folder
+func (g *GrafanaClient) SetFolderViewers(ctx context.Context, folderUID string, teamID int64) error {
+	return g.do(ctx, http.MethodPost, "/api/folders/"+url.PathEscape(folderUID)+"/permissions",
+		map[string]interface{}{
+			"items": []map[string]interface{}{
+				{"teamId": teamID, "permission": 1},
+			},
+		}, nil)
+}
+
+// UpsertDashboard creates or replaces a dashboard in the given folder
+func (g *GrafanaClient) UpsertDashboard(ctx context.Context, folderUID string, dashboard map[string]interface{}) error {
+	return g.do(ctx, http.MethodPost, "/api/dashboards/db", map[string]interface{}{
+		"dashboard": dashboard,
+		"folderUid": folderUID,
+		"overwrite": true,
+		"message":   "Provisioned by NEST",
+	}, nil)
+}
+
+// GrafanaProvisioner provisions team folders, viewer permissions, and
+// per-resource dashboards through every applicable grafana integration
+type GrafanaProvisioner struct {
+	db *gorm.DB
+}
+
+// NewGrafanaProvisioner creates a new Grafana provisioner
+func NewGrafanaProvisioner(db *gorm.DB) *GrafanaProvisioner {
+	return &GrafanaProvisioner{db: db}
+}
+
+// ProvisionResource provisions Grafana objects for a resource using every
+// enabled grafana integration that is global or scoped to the resource's team
+func (p *GrafanaProvisioner) ProvisionResource(ctx context.Context, resourceID uint) error {
+	var resource Resource
+	if err := p.db.WithContext(ctx).Preload("Team").Preload("ResourceType").
+		First(&resource, resourceID).Error; err != nil {
+		return fmt.Errorf("failed to load resource: %w", err)
+	}
+	if resource.Team == nil {
+		return fmt.Errorf("resource %d has no team", resourceID)
+	}
+
+	var integrations []Integration
+	if err := p.db.WithContext(ctx).
+		Where("type = ? AND enabled = ?", IntegrationTypeGrafana, true).
+		Where("team_id IS NULL OR team_id = ?", resource.TeamID).
+		Find(&integrations).Error; err != nil {
+		return fmt.Errorf("failed to load grafana integrations: %w", err)
+	}
+
+	var errs []error
+	for i := range integrations {
+		integration := &integrations[i]
+		err := p.provision(ctx, integration, &resource)
+
+		now := time.Now()
+		updates := map[string]interface{}{"last_sync_at": now, "last_error": ""}
+		if err != nil {
+			updates["last_error"] = err.Error()
+			errs = append(errs, fmt.Errorf("integration %d: %w", integration.ID, err))
+		}
+		p.db.Model(integration).Updates(updates)
+	}
+
+	return errors.Join(errs...)
+}
+
+// provision applies a single grafana integration to a resource
+func (p *GrafanaProvisioner) provision(ctx context.Context, integration *Integration, resource *Resource) error {
+	var cfg GrafanaConfig
+	if err := json.Unmarshal(integration.Config, &cfg); err != nil {
+		return fmt.Errorf("invalid grafana config: %w", err)
+	}
+	var creds GrafanaCredentials
+	if len(integration.Credentials) > 0 {
+		if err := json.Unmarshal(integration.Credentials, &creds); err != nil {
+			return fmt.Errorf("invalid grafana credentials: %w", err)
+		}
+	}
+
+	client := NewGrafanaClient(cfg, creds)
+	team := resource.Team
+
+	folderUID := fmt.Sprintf("nest-team-%d", team.ID)
+	if err := client.EnsureFolder(ctx, folderUID, cfg.FolderPrefix+team.Name); err != nil {
+		return fmt.Errorf("failed to ensure folder: %w", err)
+	}
+
+	grafanaTeamID, err := client.EnsureTeam(ctx, "nest-"+team.Name)
+	if err != nil {
+		return fmt.Errorf("failed to ensure team: %w", err)
+	}
+
+	if cfg.SyncMembers {
+		var members []TeamMember
+		if err := p.db.WithContext(ctx).Preload("User").
+			Where("team_id = ?", team.ID).Find(&members).Error; err != nil {
+			return fmt.Errorf("failed to load team members: %w", err)
+		}
+		for _, m := range members {
+			if m.User == nil || m.User.Email == "" {
+				continue
+			}
+			if err := client.AddTeamMember(ctx, grafanaTeamID, m.User.Email); err != nil {
+				log.Printf("Failed to add %s to grafana team %d: %v", m.User.Email, grafanaTeamID, err)
+			}
+		}
+	}
+
+	if err := client.SetFolderViewers(ctx, folderUID, grafanaTeamID); err != nil {
+		return fmt.Errorf("failed to set folder permissions: %w", err)
+	}
+
+	if err := client.UpsertDashboard(ctx, folderUID, buildResourceDashboard(resource, cfg.DatasourceUID)); err != nil {
+		return fmt.Errorf("failed to upsert dashboard: %w", err)
+	}
+
+	return nil
+}
+
+// buildResourceDashboard renders a dashboard for the nest_resource_* series
+// exported by the controller
+func buildResourceDashboard(resource *Resource, datasourceUID string) map[string]interface{} {
+	datasource := map[string]interface{}{"type": "prometheus", "uid": datasourceUID}
+	selector := fmt.Sprintf(`{resource_id="%d"}`, resource.ID)
+
+	panel := func(id, x, y int, title, expr, unit string) map[string]interface{} {
+		return map[string]interface{}{
+			"id":         id,
+			"type":       "timeseries",
+			"title":      title,
+			"datasource": datasource,
+			"gridPos":    map[string]int{"x": x, "y": y, "w": 12, "h": 8},
+			"fieldConfig": map[string]interface{}{
+				"defaults": map[string]interface{}{"unit": unit},
+			},
+			"targets": []map[string]interface{}{
+				{"refId": "A", "datasource": datasource, "expr": expr},
+			},
+		}
+	}
+
+	title := resource.Name
+	if resource.Team != nil {
+		title = resource.Team.Name + " / " + resource.Name
+	}
+
+	tags := []string{"nest"}
+	if resource.ResourceType != nil {
+		tags = append(tags, resource.ResourceType.Name)
+	}
+
+	return map[string]interface{}{
+		"uid":      fmt.Sprintf("nest-resource-%d", resource.ID),
+		"title":    title,
+		"tags":     tags,
+		"timezone": "browser",
+		"refresh":  "1m",
+		"time":     map[string]string{"from": "now-6h", "to": "now"},
+		"panels": []map[string]interface{}{
+			panel(1, 0, 0, "Connections", "nest_resource_connections_active"+selector, "short"),
+			panel(2, 12, 0, "Cache hit ratio", "nest_resource_cache_hit_ratio"+selector, "percentunit"),
+			panel(3, 0, 8, "Deadlocks", "nest_resource_database_insights_deadlocks"+selector, "short"),
+			panel(4, 12, 8, "Risk level", "nest_resource_risk_level"+selector, "short"),
+		},
+	}
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/penguintechinc/project-template/shared/database"
+	"gorm.io/gorm"
+)
+
+// Incident integration types, which page on-call responders for alerts
+const (
+	IntegrationTypePagerDuty = "pagerduty"
+	IntegrationTypeOpsgenie  = "opsgenie"
+)
+
+// alertSeverityRank orders alert severities, for an integration's
+// min_severity
+var alertSeverityRank = map[string]int{
+	"info":     1,
+	"warning":  2,
+	"critical": 3,
+}
+
+// Default mappings of alert severities to PagerDuty severities and
+// Opsgenie priorities
+var (
+	defaultPagerDutySeverities = map[string]string{"info": "info", "warning": "warning", "critical": "critical"}
+	defaultOpsgeniePriorities  = map[string]string{"info": "P5", "warning": "P3", "critical": "P1"}
+)
+
+// IncidentConfig is the Config document of a pagerduty or opsgenie
+// integration. Alerts below MinSeverity are not sent. SeverityMap maps
+// alert severities to PagerDuty severities (critical, error, warning,
+// info) or Opsgenie priorities (P1 to P5).
+type IncidentConfig struct {
+	MinSeverity string            `json:"min_severity"`
+	SeverityMap map[string]string `json:"severity_map"`
+	APIURL      string            `json:"api_url"`
+
+	// Opsgenie teams, users, or schedules the alert is assigned to
+	Responders []map[string]string `json:"responders"`
+}
+
+// IncidentCredentials holds a PagerDuty Events API v2 integration's routing
+// key, or an Opsgenie API integration's key
+type IncidentCredentials struct {
+	RoutingKey string `json:"routing_key"`
+	APIKey     string `json:"api_key"`
+}
+
+// incidentEvent is an alert opened or resolved on an incident platform
+type incidentEvent struct {
+	resolve  bool
+	dedupKey string
+	severity string
+	summary  string
+	details  map[string]interface{}
+}
+
+// incidentProvider sends events to an incident platform
+type incidentProvider interface {
+	Send(ctx context.Context, event incidentEvent) error
+}
+
+// newIncidentProvider builds the provider of an incident integration
+func newIncidentProvider(integration *Integration) (incidentProvider, *IncidentConfig, error) {
+	var cfg IncidentConfig
+	if err := json.Unmarshal(integration.Config, &cfg); err != nil {
+		return nil, nil, fmt.Errorf("invalid incident config: %w", err)
+	}
+	var creds IncidentCredentials
+	if len(integration.Credentials) > 0 {
+		if err := json.Unmarshal(integration.Credentials, &creds); err != nil {
+			return nil, nil, fmt.Errorf("invalid credentials: %w", err)
+		}
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	switch integration.Type {
+	case IntegrationTypePagerDuty:
+		if creds.RoutingKey == "" {
+			return nil, nil, errors.New("pagerduty integrations require credentials.routing_key")
+		}
+		apiURL := strings.TrimSuffix(cfg.APIURL, "/")
+		if apiURL == "" {
+			apiURL = "https://events.pagerduty.com"
+		}
+		return &pagerDutyProvider{client: client, apiURL: apiURL, routingKey: creds.RoutingKey, cfg: &cfg}, &cfg, nil
+	case IntegrationTypeOpsgenie:
+		if creds.APIKey == "" {
+			return nil, nil, errors.New("opsgenie integrations require credentials.api_key")
+		}
+		apiURL := strings.TrimSuffix(cfg.APIURL, "/")
+		if apiURL == "" {
+			apiURL = "https://api.opsgenie.com"
+		}
+		return &opsgenieProvider{client: client, apiURL: apiURL, apiKey: creds.APIKey, cfg: &cfg}, &cfg, nil
+	}
+	return nil, nil, fmt.Errorf("integration type %q is not an incident integration", integration.Type)
+}
+
+// incidentRequest sends a JSON request to an incident platform
+func incidentRequest(ctx context.Context, client *http.Client, endpoint string, header http.Header, body interface{}) error {
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(encoded))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("incident platform returned %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// pagerDutyProvider sends events through the PagerDuty Events API v2
+type pagerDutyProvider struct {
+	client     *http.Client
+	apiURL     string
+	routingKey string
+	cfg        *IncidentConfig
+}
+
+// Send triggers or resolves the event's incident
+func (p *pagerDutyProvider) Send(ctx context.Context, event incidentEvent) error {
+	body := map[string]interface{}{
+		"routing_key":  p.routingKey,
+		"event_action": "trigger",
+		"dedup_key":    event.dedupKey,
+	}
+	if event.resolve {
+		body["event_action"] = "resolve"
+	} else {
+		severity := p.cfg.SeverityMap[event.severity]
+		if severity == "" {
+			severity = defaultPagerDutySeverities[event.severity]
+		}
+		body["payload"] = map[string]interface{}{
+			"summary":        truncate(event.summary, 1024),
+			"source":         "nest",
+			"severity":       severity,
+			"custom_details": event.details,
+		}
+	}
+	return incidentRequest(ctx, p.client, p.apiURL+"/v2/enqueue", nil, body)
+}
+
+// opsgenieProvider sends events through the Opsgenie Alert API
+type opsgenieProvider struct {
+	client *http.Client
+	apiURL string
+	apiKey string
+	cfg    *IncidentConfig
+}
+
+// Send creates the event's alert, or closes it. Opsgenie deduplicates
+// open alerts by alias.
+func (o *opsgenieProvider) Send(ctx context.Context, event incidentEvent) error {
+	header := http.Header{"Authorization": []string{"GenieKey " + o.apiKey}}
+	if event.resolve {
+		endpoint := o.apiURL + "/v2/alerts/" + url.PathEscape(event.dedupKey) + "/close?identifierType=alias"
+		return incidentRequest(ctx, o.client, endpoint, header, map[string]string{"source": "nest", "note": "Resolved in NEST"})
+	}
+
+	priority := o.cfg.SeverityMap[event.severity]
+	if priority == "" {
+		priority = defaultOpsgeniePriorities[event.severity]
+	}
+	details := make(map[string]string, len(event.details))
+	for k, v := range event.details {
+		details[k] = fmt.Sprint(v)
+	}
+	body := map[string]interface{}{
+		"message":     truncate(event.summary, 130),
+		"alias":       event.dedupKey,
+		"description": event.summary,
+		"priority":    priority,
+		"source":      "nest",
+		"tags":        []string{"nest", event.severity},
+		"details":     details,
+	}
+	if len(o.cfg.Responders) > 0 {
+		body["responders"] = o.cfg.Responders
+	}
+	return incidentRequest(ctx, o.client, o.apiURL+"/v2/alerts", header, body)
+}
+
+// truncate shortens s to at most n bytes, on a rune boundary
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// IncidentNotifier opens and resolves incidents for alerts on the
+// pagerduty and opsgenie integrations of the alert's team, and the global
+// ones. An alert's incidents are keyed by its resource and rule, so that a
+// flapping alert updates one incident rather than opening many.
+type IncidentNotifier struct {
+	db *gorm.DB
+}
+
+// NewIncidentNotifier creates a new incident notifier
+func NewIncidentNotifier(db *gorm.DB) *IncidentNotifier {
+	return &IncidentNotifier{db: db}
+}
+
+// Notify sends firing and resolved alerts to the incident integrations
+// that route them
+func (in *IncidentNotifier) Notify(ctx context.Context, n Notification) error {
+	if n.Event != "alert."+AlertStateFiring && n.Event != "alert."+AlertStateResolved {
+		return nil
+	}
+
+	db := database.TenantDB(ctx, in.db).WithContext(ctx)
+	var integrations []Integration
+	if err := db.
+		Where("type IN ? AND enabled = ? AND deleted_at IS NULL", []string{IntegrationTypePagerDuty, IntegrationTypeOpsgenie}, true).
+		Where("team_id IS NULL OR team_id = ?", n.TeamID).
+		Find(&integrations).Error; err != nil {
+		return fmt.Errorf("failed to load incident integrations: %w", err)
+	}
+
+	event := incidentEvent{
+		resolve:  n.Event == "alert."+AlertStateResolved,
+		dedupKey: fmt.Sprintf("nest-resource-%d-rule-%v", n.ResourceID, n.Details["alert_rule_id"]),
+		severity: n.Severity,
+		summary:  n.Title + ": " + n.Message,
+		details:  n.Details,
+	}
+
+	var firstErr error
+	for i := range integrations {
+		integration := &integrations[i]
+		provider, cfg, err := newIncidentProvider(integration)
+		if err == nil {
+			// Resolves are sent whatever the severity, since the alert's
+			// severity may have been raised since it fired
+			if !event.resolve && alertSeverityRank[event.severity] < alertSeverityRank[cfg.MinSeverity] {
+				continue
+			}
+			err = provider.Send(ctx, event)
+		}
+
+		updates := map[string]interface{}{"last_error": "", "last_sync_at": time.Now()}
+		if err != nil {
+			log.Printf("Incident delivery to integration %d failed: %v", integration.ID, err)
+			updates = map[string]interface{}{"last_error": err.Error()}
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+		db.Model(integration).Updates(updates)
+	}
+	return firstErr
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+
+	"gorm.io/gorm"
+)
+
+// Container injection kinds
+const (
+	InjectionKindInit    = "init"
+	InjectionKindSidecar = "sidecar"
+)
+
+// containerNamePattern matches valid Kubernetes container names
+var containerNamePattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+// reservedContainerNames are used by the containers the K8s controller
+// generates itself
+var reservedContainerNames = map[string]bool{
+	"postgresql":       true,
+	"mariadb":          true,
+	"redis":            true,
+	"metrics-exporter": true,
+}
+
+// configInjections decodes the init_containers and sidecars of a resource
+// Config document
+func configInjections(cfg map[string]interface{}) (init, sidecars []InjectedContainer, err error) {
+	var doc struct {
+		InitContainers []InjectedContainer `json:"init_containers"`
+		Sidecars       []InjectedContainer `json:"sidecars"`
+	}
+	raw, _ := json.Marshal(cfg)
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, nil, fmt.Errorf("init_containers and sidecars must be lists of containers: %w", err)
+	}
+	return doc.InitContainers, doc.Sidecars, nil
+}
+
+// imageAllowed reports whether an image matches an allowlisted pattern
+func imageAllowed(db *gorm.DB, image string) (bool, error) {
+	var patterns []string
+	if err := db.Model(&AllowedImage{}).Pluck("pattern", &patterns).Error; err != nil {
+		return false, err
+	}
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, image); ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// validateInjectedContainers checks names and allowlisted images of injected
+// containers. Names must be unique across the given lists.
+func validateInjectedContainers(db *gorm.DB, lists ...[]InjectedContainer) error {
+	seen := map[string]bool{}
+	for _, containers := range lists {
+		for _, c := range containers {
+			if !containerNamePattern.MatchString(c.Name) || len(c.Name) > 63 {
+				return fmt.Errorf("invalid container name %q", c.Name)
+			}
+			if reservedContainerNames[c.Name] || seen[c.Name] {
+				return fmt.Errorf("container name %q is already in use", c.Name)
+			}
+			seen[c.Name] = true
+
+			if c.Image == "" {
+				return fmt.Errorf("container %q has no image", c.Name)
+			}
+			allowed, err := imageAllowed(db, c.Image)
+			if err != nil {
+				return err
+			}
+			if !allowed {
+				return fmt.Errorf("image %q is not on the allowlist", c.Image)
+			}
+		}
+	}
+	return nil
+}
+
+// validateConfigInjections validates the containers a resource Config injects
+func validateConfigInjections(db *gorm.DB, cfg map[string]interface{}) error {
+	init, sidecars, err := configInjections(cfg)
+	if err != nil {
+		return err
+	}
+	return validateInjectedContainers(db, init, sidecars)
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/penguintechinc/project-template/shared/apierrors"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// InjectionController handles the image allowlist and team container policy
+// HTTP requests
+type InjectionController struct {
+	db     *gorm.DB
+	access *AccessCache
+}
+
+// NewInjectionController creates a new container injection controller
+func NewInjectionController(db *gorm.DB, access *AccessCache) *InjectionController {
+	return &InjectionController{db: db, access: access}
+}
+
+// ListAllowedImages retrieves the image patterns that may be injected
+// GET /api/v1/allowed-images
+func (ic *InjectionController) ListAllowedImages(c *gin.Context) {
+	var images []*AllowedImage
+	if err := tenantDB(c, ic.db).Order("pattern").Find(&images).Error; err != nil {
+		log.Printf("Error listing allowed images: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to list allowed images")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"allowed_images": images})
+}
+
+// CreateAllowedImage adds an image pattern to the allowlist
+// POST /api/v1/allowed-images
+func (ic *InjectionController) CreateAllowedImage(c *gin.Context) {
+	if !requirePlatformAdmin(c) {
+		return
+	}
+	userID, _ := c.Get("user_id")
+
+	var req CreateAllowedImageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierrors.AbortWithDetails(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	image := &AllowedImage{Pattern: req.Pattern, Description: req.Description, CreatedBy: userID.(uint)}
+	if err := tenantDB(c, ic.db).Create(image).Error; err != nil {
+		log.Printf("Error creating allowed image: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to create allowed image")
+		return
+	}
+
+	c.JSON(http.StatusCreated, image)
+}
+
+// DeleteAllowedImage removes an image pattern from the allowlist. Containers
+// already injected with a matching image are dropped on the next reconcile.
+// DELETE /api/v1/allowed-images/:id
+func (ic *InjectionController) DeleteAllowedImage(c *gin.Context) {
+	if !requirePlatformAdmin(c) {
+		return
+	}
+
+	result := tenantDB(c, ic.db).Unscoped().Delete(&AllowedImage{}, c.Param("id"))
+	if result.Error != nil {
+		log.Printf("Error deleting allowed image: %v", result.Error)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to delete allowed image")
+		return
+	}
+	if result.RowsAffected == 0 {
+		apierrors.Abort(c, http.StatusNotFound, apierrors.CodeNotFound, "Allowed image not found")
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// ListContainerPolicies retrieves a team's container policies
+// GET /api/v1/teams/:id/container-policies
+func (ic *InjectionController) ListContainerPolicies(c *gin.Context) {
+	teamID, _, ok := teamAccess(c, ic.access)
+	if !ok {
+		return
+	}
+
+	var policies []*ContainerPolicy
+	if err := tenantDB(c, ic.db).Where("team_id = ?", teamID).Order("kind, name").Find(&policies).Error; err != nil {
+		log.Printf("Error listing container policies: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to list container policies")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"container_policies": policies})
+}
+
+// CreateContainerPolicy adds an init container or sidecar to every generated
+// StatefulSet of a team
+// POST /api/v1/teams/:id/container-policies
+func (ic *InjectionController) CreateContainerPolicy(c *gin.Context) {
+	teamID, role, ok := teamAccess(c, ic.access)
+	if !ok {
+		return
+	}
+	if !hasMinimumRole(role, "admin") {
+		apierrors.Abort(c, http.StatusForbidden, apierrors.CodeForbidden, "Team admin access required")
+		return
+	}
+	userID, _ := c.Get("user_id")
+
+	var req CreateContainerPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierrors.AbortWithDetails(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Invalid request body", err.Error())
+		return
+	}
+	if req.Kind != InjectionKindInit && req.Kind != InjectionKindSidecar {
+		apierrors.Abort(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "kind must be one of init, sidecar")
+		return
+	}
+
+	if err := validateInjectedContainers(tenantDB(c, ic.db), []InjectedContainer{req.InjectedContainer}); err != nil {
+		apierrors.Abort(c, http.StatusBadRequest, "invalid_container", err.Error())
+		return
+	}
+
+	var duplicates int64
+	if err := tenantDB(c, ic.db).Model(&ContainerPolicy{}).Where("team_id = ? AND name = ?", teamID, req.Name).
+		Count(&duplicates).Error; err != nil {
+		log.Printf("Error checking container policies: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to check container policies")
+		return
+	}
+	if duplicates > 0 {
+		apierrors.Abort(c, http.StatusConflict, "policy_exists", "A container policy with this name already exists for the team")
+		return
+	}
+
+	command, _ := json.Marshal(req.Command)
+	args, _ := json.Marshal(req.Args)
+	env, _ := json.Marshal(req.Env)
+	types, _ := json.Marshal(req.ResourceTypes)
+
+	policy := &ContainerPolicy{
+		TeamID:        teamID,
+		Kind:          req.Kind,
+		Name:          req.Name,
+		Image:         req.Image,
+		Command:       datatypes.JSON(command),
+		Args:          datatypes.JSON(args),
+		Env:           datatypes.JSON(env),
+		ResourceTypes: datatypes.JSON(types),
+		Enabled:       true,
+		CreatedBy:     userID.(uint),
+	}
+	if err := tenantDB(c, ic.db).Create(policy).Error; err != nil {
+		log.Printf("Error creating container policy: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to create container policy")
+		return
+	}
+
+	c.JSON(http.StatusCreated, policy)
+}
+
+// DeleteContainerPolicy removes a team container policy; the container is
+// removed from the team's StatefulSets on the next reconcile
+// DELETE /api/v1/teams/:id/container-policies/:policy_id
+func (ic *InjectionController) DeleteContainerPolicy(c *gin.Context) {
+	teamID, role, ok := teamAccess(c, ic.access)
+	if !ok {
+		return
+	}
+	if !hasMinimumRole(role, "admin") {
+		apierrors.Abort(c, http.StatusForbidden, apierrors.CodeForbidden, "Team admin access required")
+		return
+	}
+
+	var policy ContainerPolicy
+	if err := tenantDB(c, ic.db).Where("id = ? AND team_id = ?", c.Param("policy_id"), teamID).First(&policy).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierrors.Abort(c, http.StatusNotFound, apierrors.CodeNotFound, "Container policy not found")
+		} else {
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to retrieve container policy")
+		}
+		return
+	}
+
+	if err := tenantDB(c, ic.db).Delete(&policy).Error; err != nil {
+		log.Printf("Error deleting container policy: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to delete container policy")
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
@@ -0,0 +1,524 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/penguintechinc/project-template/shared/apierrors"
+	"github.com/penguintechinc/project-template/shared/database"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// supportedIntegrationTypes lists the integration types that can be configured
+var supportedIntegrationTypes = map[string]bool{
+	IntegrationTypeGrafana:    true,
+	IntegrationTypeSyslog:     true,
+	IntegrationTypeKafka:      true,
+	IntegrationTypeWebhook:    true,
+	IntegrationTypeGitHub:     true,
+	IntegrationTypeGitLab:     true,
+	IntegrationTypeSlack:      true,
+	IntegrationTypePagerDuty:  true,
+	IntegrationTypeOpsgenie:   true,
+	IntegrationTypeJira:       true,
+	IntegrationTypeServiceNow: true,
+}
+
+// IntegrationController handles external integration HTTP requests
+type IntegrationController struct {
+	db     *gorm.DB
+	access *AccessCache
+	syncMu sync.Mutex
+}
+
+// NewIntegrationController creates a new integration controller
+func NewIntegrationController(db *gorm.DB, access *AccessCache) *IntegrationController {
+	return &IntegrationController{db: db, access: access}
+}
+
+// ListIntegrations retrieves global integrations and those of the user's teams
+// GET /api/v1/integrations
+func (ic *IntegrationController) ListIntegrations(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "User context not found")
+		return
+	}
+
+	userRole, _ := c.Get("user_role")
+
+	query := tenantDB(c, ic.db).Where("deleted_at IS NULL")
+	if !hasMinimumRole(userRole, "admin") {
+		query = query.Where("team_id IS NULL OR team_id IN (?)",
+			tenantDB(c, ic.db).Model(&TeamMember{}).Select("team_id").Where("user_id = ?", userID.(uint)))
+	}
+	if t := c.Query("type"); t != "" {
+		query = query.Where("type = ?", t)
+	}
+
+	var integrations []*Integration
+	if err := query.Order("created_at DESC").Find(&integrations).Error; err != nil {
+		log.Printf("Error listing integrations: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to list integrations")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"integrations": integrations})
+}
+
+// CreateIntegration creates a new integration
+// POST /api/v1/integrations
+func (ic *IntegrationController) CreateIntegration(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "User context not found")
+		return
+	}
+
+	var req CreateIntegrationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierrors.AbortWithDetails(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	if err := validateIntegrationConfig(req.Type, req.Config); err != nil {
+		apierrors.Abort(c, http.StatusBadRequest, "invalid_integration", err.Error())
+		return
+	}
+	if isGitSyncType(req.Type) && req.TeamID == nil {
+		apierrors.Abort(c, http.StatusBadRequest, "invalid_integration", "Git sync integrations require a team_id")
+		return
+	}
+	if err := validateJSONField("credentials", req.Credentials, nil); err != nil {
+		apierrors.Abort(c, http.StatusBadRequest, "invalid_payload", err.Error())
+		return
+	}
+
+	if !ic.canManageIntegration(c, userID.(uint), req.TeamID) {
+		return
+	}
+
+	cfg, _ := json.Marshal(req.Config)
+	creds, _ := json.Marshal(req.Credentials)
+
+	integration := &Integration{
+		Name:        req.Name,
+		Type:        req.Type,
+		TeamID:      req.TeamID,
+		Enabled:     true,
+		Config:      datatypes.JSON(cfg),
+		Credentials: datatypes.JSON(creds),
+		CreatedBy:   userID.(uint),
+	}
+
+	if err := tenantDB(c, ic.db).Create(integration).Error; err != nil {
+		log.Printf("Error creating integration: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to create integration")
+		return
+	}
+
+	c.JSON(http.StatusCreated, integration)
+}
+
+// GetIntegration retrieves a single integration
+// GET /api/v1/integrations/:id
+func (ic *IntegrationController) GetIntegration(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "User context not found")
+		return
+	}
+
+	integration, ok := ic.loadIntegration(c)
+	if !ok {
+		return
+	}
+
+	userRole, _ := c.Get("user_role")
+	if integration.TeamID != nil && !hasMinimumRole(userRole, "admin") {
+		if _, isMember, err := ic.access.TeamRole(c.Request.Context(), userID.(uint), *integration.TeamID); err != nil || !isMember {
+			apierrors.Abort(c, http.StatusNotFound, "integration_not_found", "Integration not found")
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, integration)
+}
+
+// UpdateIntegration updates an integration
+// PUT /api/v1/integrations/:id
+func (ic *IntegrationController) UpdateIntegration(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "User context not found")
+		return
+	}
+
+	integration, ok := ic.loadIntegration(c)
+	if !ok {
+		return
+	}
+
+	if !ic.canManageIntegration(c, userID.(uint), integration.TeamID) {
+		return
+	}
+
+	var req UpdateIntegrationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierrors.AbortWithDetails(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	if req.Name != nil {
+		integration.Name = *req.Name
+	}
+	if req.Enabled != nil {
+		integration.Enabled = *req.Enabled
+	}
+	if req.Config != nil {
+		if err := validateIntegrationConfig(integration.Type, req.Config); err != nil {
+			apierrors.Abort(c, http.StatusBadRequest, "invalid_integration", err.Error())
+			return
+		}
+		cfg, _ := json.Marshal(req.Config)
+		integration.Config = datatypes.JSON(cfg)
+	}
+	if req.Credentials != nil {
+		if err := validateJSONField("credentials", req.Credentials, nil); err != nil {
+			apierrors.Abort(c, http.StatusBadRequest, "invalid_payload", err.Error())
+			return
+		}
+		creds, _ := json.Marshal(req.Credentials)
+		integration.Credentials = datatypes.JSON(creds)
+	}
+
+	if err := tenantDB(c, ic.db).Save(integration).Error; err != nil {
+		log.Printf("Error updating integration: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to update integration")
+		return
+	}
+
+	c.JSON(http.StatusOK, integration)
+}
+
+// DeleteIntegration deletes an integration
+// DELETE /api/v1/integrations/:id
+func (ic *IntegrationController) DeleteIntegration(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "User context not found")
+		return
+	}
+
+	integration, ok := ic.loadIntegration(c)
+	if !ok {
+		return
+	}
+
+	if !ic.canManageIntegration(c, userID.(uint), integration.TeamID) {
+		return
+	}
+
+	if err := tenantDB(c, ic.db).Delete(integration).Error; err != nil {
+		log.Printf("Error deleting integration: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to delete integration")
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// TestIntegration verifies connectivity and credentials for an integration
+// POST /api/v1/integrations/:id/test
+func (ic *IntegrationController) TestIntegration(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "User context not found")
+		return
+	}
+
+	integration, ok := ic.loadIntegration(c)
+	if !ok {
+		return
+	}
+
+	if !ic.canManageIntegration(c, userID.(uint), integration.TeamID) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 20*time.Second)
+	defer cancel()
+
+	var err error
+	switch integration.Type {
+	case IntegrationTypeGrafana:
+		var cfg GrafanaConfig
+		var creds GrafanaCredentials
+		if !decodeJSONField(integration.Config, &cfg, "integration config") ||
+			!decodeJSONField(integration.Credentials, &creds, "integration credentials") {
+			err = errors.New("stored integration settings are malformed")
+		} else {
+			err = NewGrafanaClient(cfg, creds).Health(ctx)
+		}
+	case IntegrationTypeSyslog, IntegrationTypeKafka, IntegrationTypeWebhook:
+		var sink EventSink
+		sink, err = newEventSink(integration)
+		if err == nil {
+			err = sink.Send(ctx, []database.AuditLog{{
+				Action:       "integration.test",
+				ResourceType: "integrations",
+				ResourceID:   &integration.ID,
+				TeamID:       integration.TeamID,
+				Timestamp:    time.Now().UTC(),
+			}})
+		}
+	case IntegrationTypeGitHub, IntegrationTypeGitLab:
+		var provider gitProvider
+		var cfg *GitSyncConfig
+		provider, cfg, _, err = newGitProvider(integration)
+		if err == nil {
+			_, err = provider.ReadFiles(ctx, cfg.Branch, cfg.Path)
+		}
+	case IntegrationTypePagerDuty, IntegrationTypeOpsgenie:
+		// The test incident is resolved as soon as it is opened
+		var provider incidentProvider
+		provider, _, err = newIncidentProvider(integration)
+		if err == nil {
+			event := incidentEvent{
+				dedupKey: fmt.Sprintf("nest-integration-test-%d", integration.ID),
+				severity: "info",
+				summary:  "NEST integration test",
+			}
+			if err = provider.Send(ctx, event); err == nil {
+				event.resolve = true
+				err = provider.Send(ctx, event)
+			}
+		}
+	case IntegrationTypeJira, IntegrationTypeServiceNow:
+		var provider ticketProvider
+		provider, _, err = newTicketProvider(integration)
+		if err == nil {
+			err = provider.Check(ctx)
+		}
+	case IntegrationTypeSlack:
+		var client *slackClient
+		client, _, _, err = newSlackClient(integration)
+		if err == nil {
+			err = client.call(ctx, "auth.test", map[string]string{}, nil)
+		}
+	default:
+		err = fmt.Errorf("integration type %q cannot be tested", integration.Type)
+	}
+
+	if err != nil {
+		apierrors.AbortWithDetails(c, http.StatusBadGateway, "integration_unreachable", "Integration test failed", err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// ReplayEvents rewinds an event sink integration's export cursor so that
+// events are delivered again from the given event ID or timestamp
+// POST /api/v1/integrations/:id/replay
+func (ic *IntegrationController) ReplayEvents(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "User context not found")
+		return
+	}
+
+	integration, ok := ic.loadIntegration(c)
+	if !ok {
+		return
+	}
+
+	if !ic.canManageIntegration(c, userID.(uint), integration.TeamID) {
+		return
+	}
+
+	if !isEventSinkType(integration.Type) {
+		apierrors.Abort(c, http.StatusBadRequest, "invalid_integration", "Only event export integrations support replay")
+		return
+	}
+
+	var req ReplayEventsRequest
+	if err := c.ShouldBindJSON(&req); err != nil || (req.FromEventID == nil) == (req.Since == nil) {
+		apierrors.Abort(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Exactly one of from_event_id or since is required")
+		return
+	}
+
+	// Positions are resolved on the audit chain the exporter follows: the
+	// cursor moves to just before the first chained event from the given ID
+	// or timestamp, or to the head when there is none
+	query := tenantDB(c, ic.db).Model(&database.AuditLog{}).Where("chain_seq IS NOT NULL")
+	if req.FromEventID != nil {
+		query = query.Where("id >= ?", *req.FromEventID)
+	} else {
+		query = query.Where("timestamp >= ?", *req.Since)
+	}
+	var first int64
+	if err := query.Select("COALESCE(MIN(chain_seq), 0)").Scan(&first).Error; err != nil {
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to resolve replay position")
+		return
+	}
+	lastChainSeq := first - 1
+	if first == 0 {
+		if err := tenantDB(c, ic.db).Model(&database.AuditLog{}).Select("COALESCE(MAX(chain_seq), 0)").Scan(&lastChainSeq).Error; err != nil {
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to resolve replay position")
+			return
+		}
+	}
+
+	cursor := EventExportCursor{IntegrationID: integration.ID, LastChainSeq: &lastChainSeq}
+	if err := tenantDB(c, ic.db).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "integration_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"last_chain_seq", "last_event_id", "updated_at"}),
+	}).Create(&cursor).Error; err != nil {
+		log.Printf("Error updating export cursor: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to update export cursor")
+		return
+	}
+
+	c.JSON(http.StatusOK, cursor)
+}
+
+// loadIntegration fetches the integration named by the :id path parameter
+func (ic *IntegrationController) loadIntegration(c *gin.Context) (*Integration, bool) {
+	var integration Integration
+	if err := tenantDB(c, ic.db).Where("id = ? AND deleted_at IS NULL", c.Param("id")).First(&integration).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierrors.Abort(c, http.StatusNotFound, "integration_not_found", "Integration not found")
+		} else {
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to retrieve integration")
+		}
+		return nil, false
+	}
+	return &integration, true
+}
+
+// canManageIntegration verifies the user may manage an integration: global
+// integrations require a global admin, team integrations a team admin
+func (ic *IntegrationController) canManageIntegration(c *gin.Context, userID uint, teamID *uint) bool {
+	userRole, _ := c.Get("user_role")
+	if hasMinimumRole(userRole, "admin") {
+		return true
+	}
+
+	if teamID != nil {
+		role, isMember, err := ic.access.TeamRole(c.Request.Context(), userID, *teamID)
+		if err == nil && isMember && hasMinimumRole(role, "admin") {
+			return true
+		}
+	}
+
+	apierrors.Abort(c, http.StatusForbidden, apierrors.CodeForbidden, "Insufficient permissions to manage this integration")
+	return false
+}
+
+// validateIntegrationConfig checks the type-specific configuration document
+func validateIntegrationConfig(integrationType string, cfg map[string]interface{}) error {
+	if !supportedIntegrationTypes[integrationType] {
+		return fmt.Errorf("unsupported integration type %q", integrationType)
+	}
+	if err := validateJSONField("config", cfg, nil); err != nil {
+		return err
+	}
+
+	switch integrationType {
+	case IntegrationTypeGrafana:
+		if u, _ := cfg["url"].(string); u == "" {
+			return errors.New("grafana integrations require config.url")
+		}
+		if ds, _ := cfg["datasource_uid"].(string); ds == "" {
+			return errors.New("grafana integrations require config.datasource_uid")
+		}
+	case IntegrationTypeSyslog:
+		if a, _ := cfg["address"].(string); a == "" {
+			return errors.New("syslog integrations require config.address")
+		}
+		if p, _ := cfg["protocol"].(string); p != "" && p != "udp" && p != "tcp" && p != "tls" {
+			return errors.New("syslog config.protocol must be one of: udp, tcp, tls")
+		}
+	case IntegrationTypeKafka:
+		if u, _ := cfg["rest_proxy_url"].(string); u == "" {
+			return errors.New("kafka integrations require config.rest_proxy_url")
+		}
+		if t, _ := cfg["topic"].(string); t == "" {
+			return errors.New("kafka integrations require config.topic")
+		}
+	case IntegrationTypeWebhook:
+		if u, _ := cfg["url"].(string); u == "" {
+			return errors.New("webhook integrations require config.url")
+		}
+	case IntegrationTypeGitHub, IntegrationTypeGitLab:
+		if r, _ := cfg["repository"].(string); r == "" || strings.Count(r, "/") < 1 {
+			return fmt.Errorf("%s integrations require config.repository as owner/name", integrationType)
+		}
+		if p, _ := cfg["path"].(string); strings.Contains(p, "..") {
+			return errors.New("config.path must not contain ..")
+		}
+		if u, _ := cfg["api_url"].(string); u != "" && !strings.HasPrefix(u, "https://") && !strings.HasPrefix(u, "http://") {
+			return errors.New("config.api_url must be an http or https URL")
+		}
+	case IntegrationTypePagerDuty, IntegrationTypeOpsgenie:
+		if s, _ := cfg["min_severity"].(string); s != "" && alertSeverityRank[s] == 0 {
+			return errors.New("config.min_severity must be one of: info, warning, critical")
+		}
+		if m, ok := cfg["severity_map"]; ok {
+			mapping, ok := m.(map[string]interface{})
+			if !ok {
+				return errors.New("config.severity_map must be an object")
+			}
+			allowed := map[string]bool{"critical": true, "error": true, "warning": true, "info": true}
+			if integrationType == IntegrationTypeOpsgenie {
+				allowed = map[string]bool{"P1": true, "P2": true, "P3": true, "P4": true, "P5": true}
+			}
+			for severity, target := range mapping {
+				if alertSeverityRank[severity] == 0 {
+					return fmt.Errorf("config.severity_map has unknown alert severity %q", severity)
+				}
+				if t, _ := target.(string); !allowed[t] {
+					return fmt.Errorf("config.severity_map.%s is not a valid %s severity", severity, integrationType)
+				}
+			}
+		}
+		if u, _ := cfg["api_url"].(string); u != "" && !strings.HasPrefix(u, "https://") && !strings.HasPrefix(u, "http://") {
+			return errors.New("config.api_url must be an http or https URL")
+		}
+	case IntegrationTypeJira, IntegrationTypeServiceNow:
+		if u, _ := cfg["url"].(string); !strings.HasPrefix(u, "https://") && !strings.HasPrefix(u, "http://") {
+			return fmt.Errorf("%s integrations require config.url as an http or https URL", integrationType)
+		}
+		if k, _ := cfg["project_key"].(string); integrationType == IntegrationTypeJira && k == "" {
+			return errors.New("jira integrations require config.project_key")
+		}
+		if events, ok := cfg["events"]; ok {
+			list, ok := events.([]interface{})
+			if !ok {
+				return errors.New("config.events must be a list")
+			}
+			for _, event := range list {
+				if e, _ := event.(string); e != ticketKindApproval && e != ticketKindJobFailure && e != ticketKindCompliance {
+					return fmt.Errorf("config.events has unknown event %v; expected approval, job_failure, or compliance", event)
+				}
+			}
+		}
+	case IntegrationTypeSlack:
+		if u, _ := cfg["api_url"].(string); u != "" && !strings.HasPrefix(u, "https://") && !strings.HasPrefix(u, "http://") {
+			return errors.New("config.api_url must be an http or https URL")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/penguintechinc/project-template/shared/apierrors"
+	"github.com/penguintechinc/project-template/shared/audit"
+	"gorm.io/gorm"
+)
+
+// invitationTTL is how long an invitation can be accepted for
+const invitationTTL = 7 * 24 * time.Hour
+
+// errAlreadyMember is returned when the invited user is already a member
+var errAlreadyMember = errors.New("already a member of the team")
+
+// errInvitationAccepted is returned when a concurrent request accepted the
+// invitation first
+var errInvitationAccepted = errors.New("invitation already accepted")
+
+// InvitationController handles team invitation HTTP requests
+type InvitationController struct {
+	db     *gorm.DB
+	access *AccessCache
+	mailer Mailer
+}
+
+// NewInvitationController creates a new invitation controller. mailer is
+// nil when mail isn't configured, and invitations aren't emailed.
+func NewInvitationController(db *gorm.DB, access *AccessCache, mailer Mailer) *InvitationController {
+	return &InvitationController{db: db, access: access, mailer: mailer}
+}
+
+// teamAdminAccess returns the team of a team route when the caller is a
+// team admin
+func (ic *InvitationController) teamAdminAccess(c *gin.Context) (uint, bool) {
+	teamID, role, ok := teamAccess(c, ic.access)
+	if !ok {
+		return 0, false
+	}
+	if !hasMinimumRole(role, "admin") {
+		apierrors.Abort(c, http.StatusForbidden, apierrors.CodeForbidden, "Team admin access required")
+		return 0, false
+	}
+	return teamID, true
+}
+
+// invitationAcceptURL returns the link an invitation is accepted at, or ""
+// when NEST_PUBLIC_URL is unset
+func invitationAcceptURL(token string) string {
+	base := strings.TrimSuffix(os.Getenv("NEST_PUBLIC_URL"), "/")
+	if base == "" {
+		return ""
+	}
+	return base + "/invitations/accept?token=" + url.QueryEscape(token)
+}
+
+// ListInvitations retrieves a team's invitations
+// GET /api/v1/teams/:id/invitations
+func (ic *InvitationController) ListInvitations(c *gin.Context) {
+	teamID, ok := ic.teamAdminAccess(c)
+	if !ok {
+		return
+	}
+
+	var invitations []TeamInvitation
+	if err := tenantDB(c, ic.db).Where("team_id = ?", teamID).Order("id DESC").Find(&invitations).Error; err != nil {
+		log.Printf("Error listing invitations of team %d: %v", teamID, err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to list invitations")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"invitations": invitations})
+}
+
+// CreateInvitation invites an email address to a team and emails it the
+// invitation. The token that accepts it is only returned here, so that it
+// can be passed on when mail isn't configured or fails.
+// POST /api/v1/teams/:id/invitations
+func (ic *InvitationController) CreateInvitation(c *gin.Context) {
+	teamID, ok := ic.teamAdminAccess(c)
+	if !ok {
+		return
+	}
+	userID := c.MustGet("user_id").(uint)
+
+	var req CreateInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierrors.AbortWithDetails(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Invalid request body", err.Error())
+		return
+	}
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+
+	db := tenantDB(c, ic.db)
+	var team Team
+	if err := db.First(&team, teamID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierrors.Abort(c, http.StatusNotFound, apierrors.CodeNotFound, "Team not found")
+		} else {
+			log.Printf("Error retrieving team %d: %v", teamID, err)
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to retrieve team")
+		}
+		return
+	}
+
+	var members, pending int64
+	if err := db.Table("team_members").
+		Joins("INNER JOIN users ON users.id = team_members.user_id").
+		Where("team_members.team_id = ? AND team_members.deleted_at IS NULL AND LOWER(users.email) = ?", teamID, email).
+		Count(&members).Error; err != nil {
+		log.Printf("Error checking membership of %s in team %d: %v", email, teamID, err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to create invitation")
+		return
+	}
+	if members > 0 {
+		apierrors.Abort(c, http.StatusConflict, "already_member", "User is already a member of this team")
+		return
+	}
+	if err := db.Model(&TeamInvitation{}).
+		Where("team_id = ? AND email = ? AND accepted_at IS NULL AND expires_at > ?", teamID, email, time.Now().UTC()).
+		Count(&pending).Error; err != nil {
+		log.Printf("Error checking invitations of %s to team %d: %v", email, teamID, err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to create invitation")
+		return
+	}
+	if pending > 0 {
+		apierrors.Abort(c, http.StatusConflict, "invitation_pending", "An invitation for this email address is already pending")
+		return
+	}
+
+	token, err := randomToken()
+	if err != nil {
+		log.Printf("Error generating invitation token: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeInternal, "Failed to create invitation")
+		return
+	}
+	invitation := TeamInvitation{
+		TeamID:    teamID,
+		Email:     email,
+		Role:      req.Role,
+		TokenHash: sha256Hex([]byte(token)),
+		InvitedBy: userID,
+		ExpiresAt: time.Now().UTC().Add(invitationTTL),
+	}
+	if err := db.Create(&invitation).Error; err != nil {
+		log.Printf("Error creating invitation to team %d: %v", teamID, err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to create invitation")
+		return
+	}
+	if err := audit.Record(c, db, userID, "team_invitations", invitation.ID, &teamID, nil, &invitation); err != nil {
+		log.Printf("Error recording invitation %d in the audit log: %v", invitation.ID, err)
+	}
+
+	acceptURL := invitationAcceptURL(token)
+	if ic.mailer != nil {
+		inviter := "A NEST admin"
+		var user User
+		if err := db.Select("username").First(&user, userID).Error; err == nil {
+			inviter = user.Username
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+		err := sendMail(ctx, db, ic.mailer, mailInvitation, []string{email}, map[string]interface{}{
+			"Team":      team.Name,
+			"Role":      invitation.Role,
+			"InvitedBy": inviter,
+			"Token":     token,
+			"AcceptURL": acceptURL,
+			"ExpiresAt": invitation.ExpiresAt.Format("2006-01-02 15:04 MST"),
+		})
+		cancel()
+		if err != nil {
+			log.Printf("Error emailing invitation %d: %v", invitation.ID, err)
+			invitation.EmailError = err.Error()
+			db.Model(&invitation).UpdateColumn("email_error", invitation.EmailError)
+		}
+	}
+
+	c.JSON(http.StatusCreated, TeamInvitationResponse{
+		TeamInvitation: &invitation,
+		Token:          token,
+		AcceptURL:      acceptURL,
+	})
+}
+
+// DeleteInvitation revokes a pending invitation
+// DELETE /api/v1/teams/:id/invitations/:invitation_id
+func (ic *InvitationController) DeleteInvitation(c *gin.Context) {
+	teamID, ok := ic.teamAdminAccess(c)
+	if !ok {
+		return
+	}
+
+	db := tenantDB(c, ic.db)
+	var invitation TeamInvitation
+	if err := db.Where("id = ? AND team_id = ?", c.Param("invitation_id"), teamID).First(&invitation).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierrors.Abort(c, http.StatusNotFound, apierrors.CodeNotFound, "Invitation not found")
+		} else {
+			log.Printf("Error retrieving invitation: %v", err)
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to retrieve invitation")
+		}
+		return
+	}
+	if invitation.AcceptedAt != nil {
+		apierrors.Abort(c, http.StatusConflict, "invitation_accepted", "Invitation has already been accepted")
+		return
+	}
+
+	if err := db.Delete(&invitation).Error; err != nil {
+		log.Printf("Error deleting invitation %d: %v", invitation.ID, err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to delete invitation")
+		return
+	}
+	if err := audit.Record(c, db, c.MustGet("user_id").(uint), "team_invitations", invitation.ID, &teamID, &invitation, nil); err != nil {
+		log.Printf("Error recording deletion of invitation %d in the audit log: %v", invitation.ID, err)
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// AcceptInvitation adds the caller to the team of an invitation. The
+// invitation must have been sent to the caller's email address.
+// POST /api/v1/invitations/accept
+func (ic *InvitationController) AcceptInvitation(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "User context not found")
+		return
+	}
+	uid := userID.(uint)
+
+	var req AcceptInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierrors.AbortWithDetails(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	db := tenantDB(c, ic.db)
+	var invitation TeamInvitation
+	if err := db.Where("token_hash = ?", sha256Hex([]byte(req.Token))).First(&invitation).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierrors.Abort(c, http.StatusNotFound, apierrors.CodeNotFound, "Invitation not found")
+		} else {
+			log.Printf("Error retrieving invitation: %v", err)
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to retrieve invitation")
+		}
+		return
+	}
+	if invitation.AcceptedAt != nil {
+		apierrors.Abort(c, http.StatusConflict, "invitation_accepted", "Invitation has already been accepted")
+		return
+	}
+	if time.Now().UTC().After(invitation.ExpiresAt) {
+		apierrors.Abort(c, http.StatusGone, "invitation_expired", "Invitation has expired")
+		return
+	}
+
+	var user User
+	if err := db.First(&user, uid).Error; err != nil {
+		log.Printf("Error retrieving user %d: %v", uid, err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to retrieve user")
+		return
+	}
+	if !strings.EqualFold(user.Email, invitation.Email) {
+		apierrors.Abort(c, http.StatusForbidden, apierrors.CodeForbidden, "Invitation was sent to a different email address")
+		return
+	}
+
+	var member TeamMember
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// The invitation is claimed first, locking its row, so it is only
+		// ever accepted once across replicas
+		now := time.Now().UTC()
+		claimed := tx.Model(&invitation).Where("accepted_at IS NULL").
+			Updates(map[string]interface{}{"accepted_at": now, "accepted_by": uid})
+		if claimed.Error != nil {
+			return claimed.Error
+		}
+		if claimed.RowsAffected == 0 {
+			return errInvitationAccepted
+		}
+
+		// A member who left keeps their soft-deleted row, which the unique
+		// team and user index still covers, so it is restored instead
+		err := tx.Unscoped().Where("team_id = ? AND user_id = ?", invitation.TeamID, uid).First(&member).Error
+		switch {
+		case err == nil && !member.DeletedAt.Valid:
+			return errAlreadyMember
+		case err == nil:
+			member.DeletedAt = gorm.DeletedAt{}
+			member.Role = invitation.Role
+			if err := tx.Unscoped().Save(&member).Error; err != nil {
+				return err
+			}
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			member = TeamMember{TeamID: invitation.TeamID, UserID: uid, Role: invitation.Role}
+			if err := tx.Create(&member).Error; err != nil {
+				return err
+			}
+		default:
+			return err
+		}
+		return nil
+	})
+	if errors.Is(err, errInvitationAccepted) {
+		apierrors.Abort(c, http.StatusConflict, "invitation_accepted", "Invitation has already been accepted")
+		return
+	}
+	if errors.Is(err, errAlreadyMember) {
+		apierrors.Abort(c, http.StatusConflict, "already_member", "User is already a member of this team")
+		return
+	}
+	if err != nil {
+		log.Printf("Error accepting invitation %d: %v", invitation.ID, err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to accept invitation")
+		return
+	}
+	ic.access.InvalidateUser(c.Request.Context(), uid)
+
+	if err := audit.Record(c, db, uid, "team_members", member.ID, &invitation.TeamID, nil, &member); err != nil {
+		log.Printf("Error recording membership %d in the audit log: %v", member.ID, err)
+	}
+
+	c.JSON(http.StatusOK, member)
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// jobReapGrace is how long past its timeout a job is left before it is
+// reaped, so the controller or agent running it can stop it first
+const jobReapGrace = 5 * time.Minute
+
+// JobReaper fails provisioning and backup jobs stuck past their timeout,
+// such as those of a controller replica or agent that went away mid-job,
+// and settles cancellations no controller picked up
+type JobReaper struct {
+	db       *gorm.DB
+	timeouts JobTimeouts
+}
+
+// NewJobReaper creates a new job reaper
+func NewJobReaper(db *gorm.DB, timeouts JobTimeouts) *JobReaper {
+	return &JobReaper{db: db, timeouts: timeouts}
+}
+
+// stuckJob is a provisioning or backup job that hasn't finished
+type stuckJob struct {
+	ID        uint
+	JobType   string
+	Status    string
+	StartedAt *time.Time
+	UpdatedAt *time.Time
+}
+
+// Reap fails the stuck jobs of both tables. The tables belong to the K8s
+// controller and the manager, and are skipped until they exist.
+func (jr *JobReaper) Reap(ctx context.Context) error {
+	db := jr.db.WithContext(ctx)
+	if db.Migrator().HasTable("provisioning_jobs") {
+		if err := jr.reapProvisioningJobs(db); err != nil {
+			return err
+		}
+	}
+	if db.Migrator().HasTable("backup_jobs") {
+		if err := jr.reapBackupJobs(db); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (jr *JobReaper) reapProvisioningJobs(db *gorm.DB) error {
+	var jobs []stuckJob
+	if err := db.Raw(`SELECT id, job_type, status, started_at, updated_at FROM provisioning_jobs
+		WHERE status IN ?`, []string{provisioningJobRunning, provisioningJobCancelling}).
+		Scan(&jobs).Error; err != nil {
+		return fmt.Errorf("failed to list running provisioning jobs: %w", err)
+	}
+
+	now := time.Now().UTC()
+	for _, job := range jobs {
+		status, message := provisioningJobFailed, ""
+		switch {
+		case job.Status == provisioningJobCancelling && job.UpdatedAt != nil && now.Sub(*job.UpdatedAt) > jobReapGrace:
+			status, message = provisioningJobCancelled, "Cancelled; no controller was running the job"
+		case job.StartedAt != nil && now.Sub(*job.StartedAt) > jr.timeouts.Timeout(job.JobType)+jobReapGrace:
+			message = fmt.Sprintf("Timed out after %s; no controller finished the job", jr.timeouts.Timeout(job.JobType))
+		default:
+			continue
+		}
+
+		if err := db.Transaction(func(tx *gorm.DB) error {
+			result := tx.Exec(`UPDATE provisioning_jobs SET status = ?, error_message = ?, completed_at = ?, updated_at = ?
+				WHERE id = ? AND status = ?`, status, message, now, now, job.ID, job.Status)
+			if result.Error != nil || result.RowsAffected == 0 {
+				return result.Error
+			}
+			return failOperations(tx, message, now, "provisioning_job_id = ?", job.ID)
+		}); err != nil {
+			log.Printf("Failed to reap provisioning job %d: %v", job.ID, err)
+			continue
+		}
+		log.Printf("Reaped provisioning job %d (%s): %s", job.ID, job.JobType, message)
+	}
+	return nil
+}
+
+// failOperations fails the unfinished operations matching query and releases
+// their resource locks, as the K8s controller would have
+func failOperations(tx *gorm.DB, message string, now time.Time, query string, args ...interface{}) error {
+	var operationIDs []uint
+	if err := tx.Model(&Operation{}).Where(query, args...).
+		Where("status IN ?", []string{OperationPending, OperationRunning}).
+		Pluck("id", &operationIDs).Error; err != nil {
+		return err
+	}
+	if len(operationIDs) == 0 {
+		return nil
+	}
+	if err := tx.Model(&Operation{}).Where("id IN ?", operationIDs).Updates(map[string]interface{}{
+		"status":       OperationFailed,
+		"message":      message,
+		"completed_at": now,
+	}).Error; err != nil {
+		return err
+	}
+	return tx.Unscoped().Where("operation_id IN ?", operationIDs).Delete(&ResourceLock{}).Error
+}
+
+func (jr *JobReaper) reapBackupJobs(db *gorm.DB) error {
+	var jobs []stuckJob
+	if err := db.Raw(`SELECT id, job_type, status, started_at FROM backup_jobs WHERE status = ?`,
+		backupJobRunning).Scan(&jobs).Error; err != nil {
+		return fmt.Errorf("failed to list running backup jobs: %w", err)
+	}
+
+	now := time.Now().UTC()
+	for _, job := range jobs {
+		timeout := jr.timeouts.Backup(job.JobType)
+		if job.StartedAt == nil || now.Sub(*job.StartedAt) <= timeout+jobReapGrace {
+			continue
+		}
+		message := fmt.Sprintf("Timed out after %s; the backup never reported back", timeout)
+		if err := db.Exec(`UPDATE backup_jobs SET status = ?, error_message = ?, completed_at = ?
+			WHERE id = ? AND status = ?`, backupJobFailed, message, now, job.ID, backupJobRunning).Error; err != nil {
+			log.Printf("Failed to reap backup job %d: %v", job.ID, err)
+			continue
+		}
+		log.Printf("Reaped backup job %d (%s): %s", job.ID, job.JobType, message)
+	}
+	return nil
+}
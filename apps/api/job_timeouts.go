@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Provisioning job statuses shared with the K8s controller's
+// provisioning_jobs table. A running job the API is asked to cancel is
+// cancelling until the controller running it stops.
+const (
+	provisioningJobRunning    = "running"
+	provisioningJobCancelling = "cancelling"
+	provisioningJobCancelled  = "cancelled"
+	provisioningJobFailed     = "failed"
+)
+
+// Job types with their own timeouts. Backup jobs other than restores share
+// the backup timeout.
+const (
+	jobTimeoutBackup  = "backup"
+	jobTimeoutRestore = "restore"
+)
+
+// defaultJobTimeout bounds jobs of a type without a timeout of its own
+const defaultJobTimeout = time.Hour
+
+// JobTimeouts are how long provisioning and backup jobs of each type may run
+// before they are stopped, or failed when whatever ran them has gone
+type JobTimeouts map[string]time.Duration
+
+// DefaultJobTimeouts returns the timeouts used unless JOB_TIMEOUTS overrides
+// them
+func DefaultJobTimeouts() JobTimeouts {
+	return JobTimeouts{
+		"create":          30 * time.Minute,
+		"update":          15 * time.Minute,
+		"scale":           15 * time.Minute,
+		jobTimeoutBackup:  2 * time.Hour,
+		jobTimeoutRestore: 4 * time.Hour,
+	}
+}
+
+// ParseJobTimeouts overrides the default timeouts with a comma-separated
+// list of type=duration pairs, such as "create=45m,backup=3h"
+func ParseJobTimeouts(s string) (JobTimeouts, error) {
+	timeouts := DefaultJobTimeouts()
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		jobType, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("job timeout %q is not type=duration", pair)
+		}
+		timeout, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil || timeout <= 0 {
+			return nil, fmt.Errorf("job timeout of %s must be a positive duration", jobType)
+		}
+		timeouts[strings.TrimSpace(jobType)] = timeout
+	}
+	return timeouts, nil
+}
+
+// Timeout returns how long a job of jobType may run
+func (t JobTimeouts) Timeout(jobType string) time.Duration {
+	if timeout, ok := t[jobType]; ok {
+		return timeout
+	}
+	return defaultJobTimeout
+}
+
+// Backup returns how long a backup job of jobType may run: a restore, or a
+// full, incremental or differential backup
+func (t JobTimeouts) Backup(jobType string) time.Duration {
+	if jobType == jobTimeoutRestore {
+		return t.Timeout(jobTimeoutRestore)
+	}
+	return t.Timeout(jobTimeoutBackup)
+}
@@ -0,0 +1,368 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Job statuses. A job waits queued until its run time, runs, and then
+// either succeeds or is queued again with backoff. A job that has failed
+// every attempt is dead-lettered until an admin requeues or discards it.
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobDead      = "dead"
+)
+
+// Kinds of the jobs the API runs
+const (
+	JobNotification       = "notification"
+	JobAlertEvaluation    = "alerts.evaluate"
+	JobCertExpiryMail     = "mail.cert_expiry"
+	JobTeamDigestMail     = "mail.team_digest"
+	JobCloudSync          = "cloud.sync"
+	JobCloudAccountSync   = "cloud.sync_account"
+	JobSlackBackupResults = "slack.backup_results"
+	JobResourcePurge      = "resources.purge"
+	JobTeamDeletions      = "teams.complete_deletions"
+	JobPrune              = "jobs.prune"
+	JobPolicySync         = "policies.sync"
+	JobReap               = "jobs.reap"
+	JobStuckResources     = "resources.watchdog"
+	JobEventExport        = "events.export"
+	JobTicketSync         = "tickets.sync"
+	JobRetention          = "retention.apply"
+	JobUsageReport        = "usage.report"
+	JobAuditAnchor        = "audit.anchor"
+)
+
+// Job priorities. Jobs of a higher priority are claimed first.
+const (
+	JobPriorityLow    = -10
+	JobPriorityNormal = 0
+	JobPriorityHigh   = 10
+)
+
+// Retry backoff doubles from jobBackoffBase with each failed attempt, up to
+// jobBackoffMax
+const (
+	jobBackoffBase = 30 * time.Second
+	jobBackoffMax  = time.Hour
+)
+
+// JobHandler runs one attempt of a job. An error fails the attempt.
+type JobHandler func(ctx context.Context, job *Job) error
+
+// JobOptions set how the jobs of a kind run
+type JobOptions struct {
+	// MaxAttempts is how many times a job is tried before it is
+	// dead-lettered
+	MaxAttempts int
+	// Timeout is the visibility timeout of an attempt. The attempt is
+	// cancelled when it passes, and a job still running after it, such as
+	// on a replica that died, is claimed again by another worker.
+	Timeout time.Duration
+}
+
+// JobRequest is a job to enqueue
+type JobRequest struct {
+	Kind     string
+	Payload  interface{}
+	Priority int
+	// RunAt delays the job until then; the zero time runs it at once
+	RunAt time.Time
+	// UniqueKey, when set, enqueues the job only if no job was enqueued
+	// under the same key before
+	UniqueKey string
+}
+
+// EnqueueJob adds a job to the queue. It returns nil without an error when
+// the request's unique key was already enqueued.
+func EnqueueJob(db *gorm.DB, req JobRequest) (*Job, error) {
+	job := &Job{
+		Kind:     req.Kind,
+		Priority: req.Priority,
+		Status:   JobQueued,
+		RunAt:    req.RunAt.UTC(),
+	}
+	if job.RunAt.IsZero() {
+		job.RunAt = time.Now().UTC()
+	}
+	if req.Payload != nil {
+		payload, err := json.Marshal(req.Payload)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode %s job payload: %w", req.Kind, err)
+		}
+		job.Payload = datatypes.JSON(payload)
+	}
+	if req.UniqueKey != "" {
+		job.UniqueKey = &req.UniqueKey
+	}
+
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(job)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+	return job, nil
+}
+
+// PeriodicJob adapts a pass of a background worker, which takes no
+// payload, to a job handler
+func PeriodicJob(pass func(ctx context.Context) error) JobHandler {
+	return func(ctx context.Context, _ *Job) error {
+		return pass(ctx)
+	}
+}
+
+// jobKind is a registered kind of job
+type jobKind struct {
+	handler JobHandler
+	opts    JobOptions
+}
+
+// jobSchedule enqueues a job of a kind once per interval
+type jobSchedule struct {
+	kind     string
+	interval time.Duration
+	priority int
+}
+
+// JobRunner claims jobs from the persistent queue and runs them. Every API
+// replica runs one: jobs are claimed with SELECT ... FOR UPDATE SKIP LOCKED,
+// so each job runs on one replica at a time, and periodic jobs are
+// enqueued under a key per interval, so each period's job is enqueued once.
+type JobRunner struct {
+	db          *gorm.DB
+	interval    time.Duration
+	concurrency int
+	worker      string
+
+	kinds     map[string]jobKind
+	schedules []jobSchedule
+}
+
+// NewJobRunner creates a job runner that polls the queue on every interval
+// and runs up to concurrency jobs at once
+func NewJobRunner(db *gorm.DB, interval time.Duration, concurrency int) *JobRunner {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	hostname, _ := os.Hostname()
+	return &JobRunner{
+		db:          db,
+		interval:    interval,
+		concurrency: concurrency,
+		worker:      fmt.Sprintf("%s-%d", hostname, os.Getpid()),
+		kinds:       make(map[string]jobKind),
+	}
+}
+
+// Handle registers the handler of a kind of job. Zero options take
+// defaults of 5 attempts and a 5 minute timeout.
+func (r *JobRunner) Handle(kind string, handler JobHandler, opts JobOptions) {
+	if opts.MaxAttempts < 1 {
+		opts.MaxAttempts = 5
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 5 * time.Minute
+	}
+	r.kinds[kind] = jobKind{handler: handler, opts: opts}
+}
+
+// Every enqueues a job of a handled kind at the start of each interval
+func (r *JobRunner) Every(kind string, interval time.Duration, priority int) {
+	r.schedules = append(r.schedules, jobSchedule{kind: kind, interval: interval, priority: priority})
+}
+
+// Run enqueues periodic jobs and runs due jobs on every interval until the
+// context is cancelled, then waits for the jobs it is running
+func (r *JobRunner) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	log.Printf("Job runner started (interval: %s, concurrency: %d)", r.interval, r.concurrency)
+
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, r.concurrency)
+	for {
+		r.schedule(ctx)
+		for len(slots) < cap(slots) {
+			job, err := r.claim(ctx)
+			if err != nil {
+				log.Printf("Failed to claim a job: %v", err)
+				break
+			}
+			if job == nil {
+				break
+			}
+			slots <- struct{}{}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer func() { <-slots }()
+				r.run(ctx, job)
+			}()
+		}
+
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			log.Println("Job runner stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// schedule enqueues the job of each schedule's current interval
+func (r *JobRunner) schedule(ctx context.Context) {
+	now := time.Now().UTC()
+	for _, s := range r.schedules {
+		start := now.Truncate(s.interval)
+		if _, err := EnqueueJob(r.db.WithContext(ctx), JobRequest{
+			Kind:      s.kind,
+			Priority:  s.priority,
+			RunAt:     start,
+			UniqueKey: fmt.Sprintf("%s@%d", s.kind, start.Unix()),
+		}); err != nil {
+			log.Printf("Failed to schedule %s job: %v", s.kind, err)
+		}
+	}
+}
+
+// claim takes the next due job of a handled kind: a queued one, or a
+// running one whose visibility timeout has passed
+func (r *JobRunner) claim(ctx context.Context) (*Job, error) {
+	kinds := make([]string, 0, len(r.kinds))
+	for kind := range r.kinds {
+		kinds = append(kinds, kind)
+	}
+
+	var job Job
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now().UTC()
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("kind IN ? AND run_at <= ?", kinds, now).
+			Where("status = ? OR (status = ? AND locked_until < ?)", JobQueued, JobRunning, now).
+			Order("priority DESC, run_at, id").
+			Take(&job).Error; err != nil {
+			return err
+		}
+
+		lockedUntil := now.Add(r.kinds[job.Kind].opts.Timeout)
+		job.Status = JobRunning
+		job.Attempts++
+		job.LockedBy = r.worker
+		job.LockedUntil = &lockedUntil
+		job.StartedAt = &now
+		return tx.Model(&job).Updates(map[string]interface{}{
+			"status":       job.Status,
+			"attempts":     job.Attempts,
+			"locked_by":    job.LockedBy,
+			"locked_until": job.LockedUntil,
+			"started_at":   job.StartedAt,
+		}).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// run runs one attempt of a claimed job and records its outcome. A job
+// interrupted by shutdown is released without using up the attempt.
+func (r *JobRunner) run(ctx context.Context, job *Job) {
+	kind := r.kinds[job.Kind]
+	var err error
+	if job.Attempts > kind.opts.MaxAttempts {
+		// Abandoned by a worker that died on its last attempt
+		err = errors.New("job was abandoned on its last attempt")
+	} else {
+		err = r.attempt(ctx, kind, job)
+	}
+
+	owned := r.db.Model(&Job{}).Where("id = ? AND locked_by = ? AND attempts = ?", job.ID, r.worker, job.Attempts)
+	now := time.Now().UTC()
+	updates := map[string]interface{}{"locked_by": "", "locked_until": nil}
+	switch {
+	case err == nil:
+		updates["status"] = JobSucceeded
+		updates["completed_at"] = now
+		updates["last_error"] = ""
+	case ctx.Err() != nil:
+		updates["status"] = JobQueued
+		updates["attempts"] = job.Attempts - 1
+	case job.Attempts >= kind.opts.MaxAttempts:
+		log.Printf("Job %d (%s) failed its last attempt and was dead-lettered: %v", job.ID, job.Kind, err)
+		updates["status"] = JobDead
+		updates["completed_at"] = now
+		updates["last_error"] = err.Error()
+	default:
+		log.Printf("Job %d (%s) failed attempt %d: %v", job.ID, job.Kind, job.Attempts, err)
+		updates["status"] = JobQueued
+		updates["run_at"] = now.Add(jobBackoff(job.Attempts))
+		updates["last_error"] = err.Error()
+	}
+	if err := owned.Updates(updates).Error; err != nil {
+		log.Printf("Failed to record the outcome of job %d: %v", job.ID, err)
+	}
+}
+
+// attempt calls a job's handler within its visibility timeout, turning a
+// panic into a failed attempt
+func (r *JobRunner) attempt(ctx context.Context, kind jobKind, job *Job) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, kind.opts.Timeout)
+	defer cancel()
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("job panicked: %v", p)
+		}
+	}()
+	return kind.handler(ctx, job)
+}
+
+// jobBackoff returns how long a job waits after its attempt'th failure
+func jobBackoff(attempt int) time.Duration {
+	backoff := jobBackoffBase
+	for i := 1; i < attempt && backoff < jobBackoffMax; i++ {
+		backoff *= 2
+	}
+	if backoff > jobBackoffMax {
+		backoff = jobBackoffMax
+	}
+	return backoff
+}
+
+// decodeJobPayload decodes a job's payload into v
+func decodeJobPayload(job *Job, v interface{}) error {
+	if err := json.Unmarshal(job.Payload, v); err != nil {
+		return fmt.Errorf("invalid %s job payload: %w", job.Kind, err)
+	}
+	return nil
+}
+
+// PruneJobs deletes jobs that succeeded before retention ago. Dead jobs are
+// kept until they are requeued or discarded.
+func PruneJobs(ctx context.Context, db *gorm.DB, retention time.Duration) error {
+	cutoff := time.Now().UTC().Add(-retention)
+	return db.WithContext(ctx).Unscoped().
+		Where("status = ? AND completed_at < ?", JobSucceeded, cutoff).
+		Delete(&Job{}).Error
+}
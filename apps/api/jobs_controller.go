@@ -0,0 +1,160 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/penguintechinc/project-template/shared/apierrors"
+	"github.com/penguintechinc/project-template/shared/audit"
+	"gorm.io/gorm"
+)
+
+// JobController handles background job HTTP requests
+type JobController struct {
+	db *gorm.DB
+}
+
+// NewJobController creates a new job controller
+func NewJobController(db *gorm.DB) *JobController {
+	return &JobController{db: db}
+}
+
+// loadJob writes the error response when the job of the :id path
+// parameter can't be loaded
+func (jc *JobController) loadJob(c *gin.Context) (*Job, bool) {
+	var job Job
+	if err := jc.db.First(&job, c.Param("id")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierrors.Abort(c, http.StatusNotFound, apierrors.CodeNotFound, "Job not found")
+		} else {
+			log.Printf("Error retrieving job: %v", err)
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to retrieve job")
+		}
+		return nil, false
+	}
+	return &job, true
+}
+
+// ListJobs retrieves background jobs, most recently due first. Dead-lettered
+// jobs are listed with ?status=dead.
+// GET /api/v1/admin/jobs
+func (jc *JobController) ListJobs(c *gin.Context) {
+	if !requirePlatformAdmin(c) {
+		return
+	}
+
+	limit := 50
+	if l := c.Query("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 500 {
+			limit = parsed
+		}
+	}
+
+	query := jc.db.Order("run_at DESC").Limit(limit)
+	if kind := c.Query("kind"); kind != "" {
+		query = query.Where("kind = ?", kind)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var jobs []*Job
+	if err := query.Find(&jobs).Error; err != nil {
+		log.Printf("Error listing jobs: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to list jobs")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"jobs": jobs})
+}
+
+// GetJob retrieves a background job
+// GET /api/v1/admin/jobs/:id
+func (jc *JobController) GetJob(c *gin.Context) {
+	if !requirePlatformAdmin(c) {
+		return
+	}
+	job, ok := jc.loadJob(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// RequeueJob queues a dead-lettered job to run again with all its attempts
+// POST /api/v1/admin/jobs/:id/requeue
+func (jc *JobController) RequeueJob(c *gin.Context) {
+	if !requirePlatformAdmin(c) {
+		return
+	}
+	job, ok := jc.loadJob(c)
+	if !ok {
+		return
+	}
+
+	result := jc.db.Model(&Job{}).Where("id = ? AND status = ?", job.ID, JobDead).Updates(map[string]interface{}{
+		"status":       JobQueued,
+		"attempts":     0,
+		"run_at":       time.Now().UTC(),
+		"completed_at": nil,
+	})
+	if result.Error != nil {
+		log.Printf("Error requeueing job %d: %v", job.ID, result.Error)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to requeue job")
+		return
+	}
+	if result.RowsAffected == 0 {
+		apierrors.Abort(c, http.StatusConflict, apierrors.CodeConflict, "Only dead-lettered jobs can be requeued")
+		return
+	}
+
+	userID := c.MustGet("user_id").(uint)
+	if err := audit.RecordAction(c, jc.db, userID, "requeue", "jobs", job.ID, nil, gin.H{
+		"kind": job.Kind, "attempts": job.Attempts, "last_error": job.LastError,
+	}); err != nil {
+		log.Printf("Error recording requeue of job %d in the audit log: %v", job.ID, err)
+	}
+
+	if err := jc.db.First(job, job.ID).Error; err != nil {
+		log.Printf("Error retrieving requeued job %d: %v", job.ID, err)
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// DiscardJob deletes a job that isn't running, such as a dead-lettered job
+// that shouldn't be retried
+// DELETE /api/v1/admin/jobs/:id
+func (jc *JobController) DiscardJob(c *gin.Context) {
+	if !requirePlatformAdmin(c) {
+		return
+	}
+	job, ok := jc.loadJob(c)
+	if !ok {
+		return
+	}
+
+	result := jc.db.Where("id = ? AND status <> ?", job.ID, JobRunning).Delete(&Job{})
+	if result.Error != nil {
+		log.Printf("Error discarding job %d: %v", job.ID, result.Error)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to discard job")
+		return
+	}
+	if result.RowsAffected == 0 {
+		apierrors.Abort(c, http.StatusConflict, apierrors.CodeConflict, "A running job can't be discarded")
+		return
+	}
+
+	userID := c.MustGet("user_id").(uint)
+	if err := audit.RecordAction(c, jc.db, userID, "discard", "jobs", job.ID, nil, gin.H{
+		"kind": job.Kind, "status": job.Status, "last_error": job.LastError,
+	}); err != nil {
+		log.Printf("Error recording discard of job %d in the audit log: %v", job.ID, err)
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Services whose log level can be overridden at runtime
+const (
+	LogServiceAPI        = "api"
+	LogServiceController = "controller"
+)
+
+// logComponents lists the components of each service that can log at their
+// own level. The K8s controller names them by the component field of its
+// log entries, and GORM's SQL traces by their source.
+var logComponents = map[string][]string{
+	LogServiceAPI: {"sql"},
+	LogServiceController: {
+		"controller", "event_handler", "gorm", "identity-issuer", "reconcile_listener",
+		"reconciler", "remote-write", "stats_collector", "watcher",
+	},
+}
+
+// logLevelNames are the levels an override may set
+var logLevelNames = map[string]bool{"debug": true, "info": true, "warn": true, "error": true}
+
+// logLevels sets the level of the API's logging from LOG_LEVEL, the
+// override set through the admin API and the debug switch toggled by
+// SIGUSR1. Only SQL tracing has levels: debug traces every statement.
+type logLevels struct {
+	sqlLog *sqlLogger
+
+	mu         sync.Mutex
+	configured string
+	override   *LogLevelOverride
+	debug      bool
+}
+
+// newLogLevels creates the log levels of the API at LOG_LEVEL
+func newLogLevels(sqlLog *sqlLogger) *logLevels {
+	l := &logLevels{sqlLog: sqlLog}
+	l.SetConfigured(os.Getenv("LOG_LEVEL"))
+	return l
+}
+
+// SetConfigured sets the level configured by LOG_LEVEL, which applies
+// unless overridden
+func (l *logLevels) SetConfigured(level string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.configured = level
+	l.apply()
+}
+
+// setOverride applies an override, or returns to LOG_LEVEL when nil. It
+// reports whether the override changed.
+func (l *logLevels) setOverride(override *LogLevelOverride) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	changed := (l.override == nil) != (override == nil) ||
+		(override != nil && !override.UpdatedAt.Equal(l.override.UpdatedAt))
+	l.override = override
+	l.apply()
+	return changed
+}
+
+// ToggleDebug switches debug logging on or off, returning whether it is now
+// on
+func (l *logLevels) ToggleDebug() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.debug = !l.debug
+	l.apply()
+	return l.debug
+}
+
+// apply sets the level of SQL logging. It is called with mu held.
+func (l *logLevels) apply() {
+	level := l.configured
+	if l.override != nil {
+		if l.override.Level != "" {
+			level = l.override.Level
+		}
+		// An override whose components don't decode keeps the levels in
+		// effect rather than resetting them
+		var components map[string]string
+		if !decodeJSONField(l.override.Components, &components, "log level components") && !l.debug {
+			return
+		}
+		if component, ok := components["sql"]; ok {
+			level = component
+		}
+	}
+	if l.debug {
+		level = "debug"
+	}
+	sqlLevel, _ := parseLogLevel(level)
+	l.sqlLog.SetLevel(sqlLevel)
+}
+
+// Run applies the API's log level override on each interval, so every
+// replica picks it up, and toggles debug logging on each signal received
+// on toggle
+func (l *logLevels) Run(ctx context.Context, db *gorm.DB, interval time.Duration, toggle <-chan os.Signal) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	l.refresh(ctx, db)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-toggle:
+			log.Printf("Debug logging toggled by SIGUSR1; debug: %t", l.ToggleDebug())
+		case <-ticker.C:
+			l.refresh(ctx, db)
+		}
+	}
+}
+
+// refresh loads the API's unexpired override, if any, and applies it
+func (l *logLevels) refresh(ctx context.Context, db *gorm.DB) {
+	override, err := activeLogLevelOverride(db.WithContext(ctx), LogServiceAPI)
+	if err != nil {
+		log.Printf("Failed to load log level override: %v", err)
+		return
+	}
+	if l.setOverride(override) {
+		if override == nil {
+			log.Println("Log level override removed; logging at LOG_LEVEL")
+		} else {
+			log.Printf("Log level override applied: level %q, components %s", override.Level, override.Components)
+		}
+	}
+}
+
+// activeLogLevelOverride returns the unexpired override of a service, or
+// nil when there is none
+func activeLogLevelOverride(db *gorm.DB, service string) (*LogLevelOverride, error) {
+	var override LogLevelOverride
+	err := db.Where("service = ?", service).
+		Where("expires_at IS NULL OR expires_at > ?", time.Now().UTC()).
+		First(&override).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &override, nil
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/penguintechinc/project-template/shared/apierrors"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// LogLevelController handles runtime log level HTTP requests
+type LogLevelController struct {
+	db *gorm.DB
+}
+
+// NewLogLevelController creates a new log level controller
+func NewLogLevelController(db *gorm.DB) *LogLevelController {
+	return &LogLevelController{db: db}
+}
+
+// ListLogLevels retrieves each service's components and the log level
+// override in effect, if any
+// GET /api/v1/admin/log-levels
+func (lc *LogLevelController) ListLogLevels(c *gin.Context) {
+	if !requirePlatformAdmin(c) {
+		return
+	}
+
+	services := make([]string, 0, len(logComponents))
+	for service := range logComponents {
+		services = append(services, service)
+	}
+	sort.Strings(services)
+
+	list := make([]*LogLevelResponse, 0, len(services))
+	for _, service := range services {
+		override, err := activeLogLevelOverride(lc.db, service)
+		if err != nil {
+			log.Printf("Error fetching log level override: %v", err)
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to list log levels")
+			return
+		}
+		list = append(list, &LogLevelResponse{Service: service, Components: logComponents[service], Override: override})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"log_levels": list})
+}
+
+// SetLogLevel overrides the log level of a service, as a whole or for some
+// of its components. Every replica applies it within
+// LOG_LEVEL_POLL_INTERVAL, or HEARTBEAT_INTERVAL for the K8s controller.
+// PUT /api/v1/admin/log-levels/:service
+func (lc *LogLevelController) SetLogLevel(c *gin.Context) {
+	if !requirePlatformAdmin(c) {
+		return
+	}
+	userID, _ := c.Get("user_id")
+
+	service := c.Param("service")
+	if _, ok := logComponents[service]; !ok {
+		apierrors.Abort(c, http.StatusNotFound, apierrors.CodeNotFound, "Unknown service")
+		return
+	}
+
+	var req SetLogLevelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierrors.AbortWithDetails(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Invalid request body", err.Error())
+		return
+	}
+	expiresAt, err := validateLogLevelRequest(service, &req)
+	if err != nil {
+		apierrors.AbortWithDetails(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Invalid log level", err.Error())
+		return
+	}
+
+	var override LogLevelOverride
+	if err := lc.db.Where("service = ?", service).First(&override).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Printf("Error fetching log level override: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to retrieve log level")
+		return
+	}
+
+	components, _ := json.Marshal(req.Components)
+	override.Service = service
+	override.Level = req.Level
+	override.Components = datatypes.JSON(components)
+	override.ExpiresAt = expiresAt
+	override.UpdatedBy = userID.(uint)
+
+	if err := lc.db.Save(&override).Error; err != nil {
+		log.Printf("Error saving log level override: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to save log level")
+		return
+	}
+
+	log.Printf("Log level of %s overridden by user %d: level %q, components %s", service, override.UpdatedBy, req.Level, components)
+	c.JSON(http.StatusOK, override)
+}
+
+// ClearLogLevel removes a service's override, returning it to LOG_LEVEL
+// DELETE /api/v1/admin/log-levels/:service
+func (lc *LogLevelController) ClearLogLevel(c *gin.Context) {
+	if !requirePlatformAdmin(c) {
+		return
+	}
+
+	result := lc.db.Unscoped().Where("service = ?", c.Param("service")).Delete(&LogLevelOverride{})
+	if result.Error != nil {
+		log.Printf("Error deleting log level override: %v", result.Error)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to clear log level")
+		return
+	}
+	if result.RowsAffected == 0 {
+		apierrors.Abort(c, http.StatusNotFound, apierrors.CodeNotFound, "No log level override for this service")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Log level override removed"})
+}
+
+// validateLogLevelRequest checks the levels and components of an override,
+// normalizing the levels, and returns when it expires
+func validateLogLevelRequest(service string, req *SetLogLevelRequest) (*time.Time, error) {
+	req.Level = strings.ToLower(strings.TrimSpace(req.Level))
+	if req.Level != "" && !logLevelNames[req.Level] {
+		return nil, fmt.Errorf("level must be one of debug, info, warn or error")
+	}
+	if req.Level == "" && len(req.Components) == 0 {
+		return nil, fmt.Errorf("set a level, components, or both")
+	}
+
+	known := make(map[string]bool, len(logComponents[service]))
+	for _, component := range logComponents[service] {
+		known[component] = true
+	}
+	for component, level := range req.Components {
+		if !known[component] {
+			return nil, fmt.Errorf("unknown component %q; %s has %s", component, service, strings.Join(logComponents[service], ", "))
+		}
+		level = strings.ToLower(strings.TrimSpace(level))
+		if !logLevelNames[level] {
+			return nil, fmt.Errorf("component %s: level must be one of debug, info, warn or error", component)
+		}
+		req.Components[component] = level
+	}
+
+	if req.Duration == "" {
+		return nil, nil
+	}
+	duration, err := time.ParseDuration(req.Duration)
+	if err != nil || duration <= 0 {
+		return nil, fmt.Errorf("duration must be a positive duration, such as 30m")
+	}
+	expiresAt := time.Now().UTC().Add(duration)
+	return &expiresAt, nil
+}
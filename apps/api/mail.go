@@ -0,0 +1,612 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"log"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"os"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/penguintechinc/project-template/shared/database"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Transactional mail templates
+const (
+	mailInvitation    = "invitation"
+	mailPasswordReset = "password_reset"
+	mailCertExpiry    = "cert_expiry"
+	mailTeamDigest    = "team_digest"
+	mailAlert         = "alert"
+)
+
+// MailMessage is a rendered email
+type MailMessage struct {
+	To      []string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Mailer delivers email
+type Mailer interface {
+	Send(ctx context.Context, msg MailMessage) error
+}
+
+// SMTPMailer delivers email through an SMTP relay. tlsMode is "starttls",
+// upgrading the connection when the server offers it, "tls" for implicit
+// TLS, or "none".
+type SMTPMailer struct {
+	host     string
+	port     int
+	username string
+	password string
+	from     string
+	tlsMode  string
+}
+
+// NewSMTPMailerFromEnv builds the SMTP mailer from SMTP_SERVER, SMTP_PORT,
+// SMTP_USER, SMTP_PASSWORD, SMTP_SENDER and SMTP_TLS, the settings the web
+// app mails with. SMTP_SERVER may include the port. It returns nil when
+// SMTP_SERVER is unset, and mail is not sent.
+func NewSMTPMailerFromEnv() *SMTPMailer {
+	server := os.Getenv("SMTP_SERVER")
+	if server == "" {
+		return nil
+	}
+	m := &SMTPMailer{
+		host:     server,
+		port:     587,
+		username: os.Getenv("SMTP_USER"),
+		password: os.Getenv("SMTP_PASSWORD"),
+		from:     os.Getenv("SMTP_SENDER"),
+		tlsMode:  "starttls",
+	}
+	if host, port, err := net.SplitHostPort(server); err == nil {
+		m.host = host
+		if v, err := strconv.Atoi(port); err == nil && v > 0 {
+			m.port = v
+		}
+	}
+	if v, err := strconv.Atoi(os.Getenv("SMTP_PORT")); err == nil && v > 0 {
+		m.port = v
+	}
+	if useTLS, err := strconv.ParseBool(os.Getenv("SMTP_TLS")); err == nil && !useTLS {
+		m.tlsMode = "none"
+	} else if m.port == 465 {
+		m.tlsMode = "tls"
+	}
+	if m.from == "" {
+		m.from = "noreply@" + m.host
+	}
+	return m
+}
+
+// Send delivers a message to its recipients in one SMTP transaction
+func (m *SMTPMailer) Send(ctx context.Context, msg MailMessage) error {
+	if len(msg.To) == 0 {
+		return errors.New("message has no recipients")
+	}
+	for _, to := range append([]string{m.from}, msg.To...) {
+		if strings.ContainsAny(to, "\r\n") {
+			return fmt.Errorf("invalid address %q", to)
+		}
+	}
+	body, err := m.encode(msg)
+	if err != nil {
+		return err
+	}
+
+	addr := net.JoinHostPort(m.host, strconv.Itoa(m.port))
+	dialer := &net.Dialer{Timeout: 15 * time.Second}
+	var conn net.Conn
+	if m.tlsMode == "tls" {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: m.host}}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", addr, err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	} else {
+		conn.SetDeadline(time.Now().Add(time.Minute))
+	}
+
+	client, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to start SMTP session: %w", err)
+	}
+	defer client.Close()
+
+	if m.tlsMode == "starttls" {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(&tls.Config{ServerName: m.host}); err != nil {
+				return fmt.Errorf("STARTTLS failed: %w", err)
+			}
+		}
+	}
+	if m.username != "" {
+		if err := client.Auth(smtp.PlainAuth("", m.username, m.password, m.host)); err != nil {
+			return fmt.Errorf("SMTP authentication failed: %w", err)
+		}
+	}
+	if err := client.Mail(m.from); err != nil {
+		return fmt.Errorf("SMTP MAIL FROM failed: %w", err)
+	}
+	for _, to := range msg.To {
+		if err := client.Rcpt(to); err != nil {
+			return fmt.Errorf("SMTP RCPT TO %s failed: %w", to, err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("SMTP DATA failed: %w", err)
+	}
+	if _, err := w.Write(body); err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	return client.Quit()
+}
+
+// encode builds the MIME message: a multipart/alternative of the text and
+// HTML bodies, or the text body alone
+func (m *SMTPMailer) encode(msg MailMessage) ([]byte, error) {
+	var buf bytes.Buffer
+	header := func(k, v string) { fmt.Fprintf(&buf, "%s: %s\r\n", k, v) }
+	header("From", m.from)
+	header("To", strings.Join(msg.To, ", "))
+	header("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("MIME-Version", "1.0")
+
+	part := func(contentType, content string) error {
+		header("Content-Type", contentType+"; charset=utf-8")
+		header("Content-Transfer-Encoding", "quoted-printable")
+		buf.WriteString("\r\n")
+		qp := quotedprintable.NewWriter(&buf)
+		if _, err := qp.Write([]byte(content)); err != nil {
+			return err
+		}
+		if err := qp.Close(); err != nil {
+			return err
+		}
+		buf.WriteString("\r\n")
+		return nil
+	}
+
+	if msg.HTML == "" {
+		if err := part("text/plain", msg.Text); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	raw := make([]byte, 12)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, err
+	}
+	boundary := "nest-" + hex.EncodeToString(raw)
+	header("Content-Type", `multipart/alternative; boundary="`+boundary+`"`)
+	buf.WriteString("\r\n")
+	for _, p := range []struct{ contentType, content string }{{"text/plain", msg.Text}, {"text/html", msg.HTML}} {
+		buf.WriteString("--" + boundary + "\r\n")
+		if err := part(p.contentType, p.content); err != nil {
+			return nil, err
+		}
+	}
+	buf.WriteString("--" + boundary + "--\r\n")
+	return buf.Bytes(), nil
+}
+
+// mailTemplate is a mail template's subject, text body and HTML body, and
+// sample data it is test-sent with. The subject and text body are
+// text/template templates, and the HTML body an html/template template.
+type mailTemplate struct {
+	Subject string
+	Text    string
+	HTML    string
+	Sample  map[string]interface{}
+}
+
+// defaultMailTemplates are the built-in templates, which an EmailTemplate
+// of the same name overrides
+var defaultMailTemplates = map[string]mailTemplate{
+	mailInvitation: {
+		Subject: "You're invited to join {{.Team}} on NEST",
+		Text: "{{.InvitedBy}} invited you to join the {{.Team}} team on NEST as {{.Role}}.\n\n" +
+			"Accept the invitation before {{.ExpiresAt}}:\n{{if .AcceptURL}}{{.AcceptURL}}{{else}}invitation token {{.Token}}{{end}}\n",
+		HTML: "<p>{{.InvitedBy}} invited you to join the <strong>{{.Team}}</strong> team on NEST as {{.Role}}.</p>" +
+			`<p>{{if .AcceptURL}}<a href="{{.AcceptURL}}">Accept the invitation</a>{{else}}Accept the invitation with the token <code>{{.Token}}</code>{{end}} before {{.ExpiresAt}}.</p>`,
+		Sample: map[string]interface{}{
+			"Team": "payments", "Role": "maintainer", "InvitedBy": "alice", "Token": "sample",
+			"AcceptURL": "https://nest.example.com/invitations/accept?token=sample", "ExpiresAt": "2026-01-08 12:00 UTC",
+		},
+	},
+	mailPasswordReset: {
+		Subject: "Reset your NEST password",
+		Text: "A password reset was requested for {{.Username}}.\n\n" +
+			"Reset your password before {{.ExpiresAt}}:\n{{.ResetURL}}\n\n" +
+			"If you didn't request it, ignore this email.\n",
+		HTML: "<p>A password reset was requested for {{.Username}}.</p>" +
+			`<p><a href="{{.ResetURL}}">Reset your password</a> before {{.ExpiresAt}}.</p>` +
+			"<p>If you didn't request it, ignore this email.</p>",
+		Sample: map[string]interface{}{
+			"Username": "alice", "ResetURL": "https://nest.example.com/reset?token=sample", "ExpiresAt": "2026-01-01 13:00 UTC",
+		},
+	},
+	mailCertExpiry: {
+		Subject: "Certificate for {{.Resource}} expires in {{.Days}} days",
+		Text: "The certificate {{.CommonName}} of {{.Resource}} ({{.Environment}}) in team {{.Team}} expires on {{.ValidUntil}}.\n" +
+			"{{if .AutoRenew}}It is set to renew automatically; check that renewal succeeds.{{else}}It doesn't renew automatically; renew it before then.{{end}}\n",
+		HTML: "<p>The certificate <code>{{.CommonName}}</code> of <strong>{{.Resource}}</strong> ({{.Environment}}) in team {{.Team}} expires on {{.ValidUntil}}.</p>" +
+			"<p>{{if .AutoRenew}}It is set to renew automatically; check that renewal succeeds.{{else}}It doesn't renew automatically; renew it before then.{{end}}</p>",
+		Sample: map[string]interface{}{
+			"Resource": "orders", "Environment": "prod", "Team": "payments", "CommonName": "orders.payments.svc",
+			"ValidUntil": "2026-01-15 00:00 UTC", "Days": 14, "AutoRenew": false,
+		},
+	},
+	mailTeamDigest: {
+		Subject: "NEST weekly digest for {{.Team}}",
+		Text: "{{.Team}}, week of {{.Since}}\n\n" +
+			"Resources: {{.Resources}} ({{.Failed}} failed)\n" +
+			"Alerts fired: {{.AlertsFired}}\n" +
+			"Backups: {{.BackupsCompleted}} completed, {{.BackupsFailed}} failed\n" +
+			"Certificates expiring within 30 days: {{.CertsExpiring}}\n",
+		HTML: "<h2>{{.Team}}, week of {{.Since}}</h2><ul>" +
+			"<li>Resources: {{.Resources}} ({{.Failed}} failed)</li>" +
+			"<li>Alerts fired: {{.AlertsFired}}</li>" +
+			"<li>Backups: {{.BackupsCompleted}} completed, {{.BackupsFailed}} failed</li>" +
+			"<li>Certificates expiring within 30 days: {{.CertsExpiring}}</li></ul>",
+		Sample: map[string]interface{}{
+			"Team": "payments", "Since": "2026-01-01", "Resources": 12, "Failed": 1, "AlertsFired": 3,
+			"BackupsCompleted": 84, "BackupsFailed": 2, "CertsExpiring": 1,
+		},
+	},
+	mailAlert: {
+		Subject: "{{.Title}}",
+		Text:    "{{.Message}}\n\nSeverity: {{.Severity}}\nTime: {{.Timestamp}}\n",
+		HTML:    "<p>{{.Message}}</p><p>Severity: {{.Severity}}<br>Time: {{.Timestamp}}</p>",
+		Sample: map[string]interface{}{
+			"Title": "[FIRING] High connections", "Message": `connections.active on resource "orders" is 480 (gt 400)`,
+			"Severity": "critical", "Timestamp": "2026-01-01 12:00 UTC",
+		},
+	},
+}
+
+// loadMailTemplate returns a template with its override applied, and
+// whether it is overridden
+func loadMailTemplate(db *gorm.DB, name string) (mailTemplate, bool, error) {
+	tmpl, ok := defaultMailTemplates[name]
+	if !ok {
+		return mailTemplate{}, false, fmt.Errorf("unknown mail template %q", name)
+	}
+	var override EmailTemplate
+	err := db.Where("name = ?", name).First(&override).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return tmpl, false, nil
+	}
+	if err != nil {
+		return tmpl, false, err
+	}
+	tmpl.Subject, tmpl.Text, tmpl.HTML = override.Subject, override.Text, override.HTML
+	return tmpl, true, nil
+}
+
+// render executes a template with data
+func (t mailTemplate) render(data map[string]interface{}) (MailMessage, error) {
+	var msg MailMessage
+	execText := func(name, source string) (string, error) {
+		tmpl, err := texttemplate.New(name).Option("missingkey=error").Parse(source)
+		if err != nil {
+			return "", err
+		}
+		var out strings.Builder
+		err = tmpl.Execute(&out, data)
+		return out.String(), err
+	}
+
+	var err error
+	if msg.Subject, err = execText("subject", t.Subject); err != nil {
+		return msg, fmt.Errorf("subject: %w", err)
+	}
+	msg.Subject = strings.Join(strings.Fields(msg.Subject), " ")
+	if msg.Text, err = execText("text", t.Text); err != nil {
+		return msg, fmt.Errorf("text: %w", err)
+	}
+	if t.HTML != "" {
+		tmpl, err := htmltemplate.New("html").Option("missingkey=error").Parse(t.HTML)
+		if err != nil {
+			return msg, fmt.Errorf("html: %w", err)
+		}
+		var out strings.Builder
+		if err := tmpl.Execute(&out, data); err != nil {
+			return msg, fmt.Errorf("html: %w", err)
+		}
+		msg.HTML = out.String()
+	}
+	return msg, nil
+}
+
+// sendMail renders a template with data and sends it to recipients. It
+// does nothing when mail isn't configured.
+func sendMail(ctx context.Context, db *gorm.DB, mailer Mailer, name string, to []string, data map[string]interface{}) error {
+	if mailer == nil || len(to) == 0 {
+		return nil
+	}
+	tmpl, _, err := loadMailTemplate(db, name)
+	if err != nil {
+		return err
+	}
+	msg, err := tmpl.render(data)
+	if err != nil {
+		return fmt.Errorf("failed to render %s mail: %w", name, err)
+	}
+	msg.To = to
+	return mailer.Send(ctx, msg)
+}
+
+// teamRecipients returns the email addresses of a team's active members
+// with at least the given role
+func teamRecipients(db *gorm.DB, teamID uint, minRole string) ([]string, error) {
+	var members []struct {
+		Email string
+		Role  string
+	}
+	if err := db.Table("team_members").
+		Select("users.email, team_members.role").
+		Joins("INNER JOIN users ON users.id = team_members.user_id").
+		Where("team_members.team_id = ? AND team_members.deleted_at IS NULL AND users.deleted_at IS NULL AND users.is_active = ?", teamID, true).
+		Scan(&members).Error; err != nil {
+		return nil, err
+	}
+	var emails []string
+	for _, m := range members {
+		if m.Email != "" && hasMinimumRole(m.Role, minRole) {
+			emails = append(emails, m.Email)
+		}
+	}
+	return emails, nil
+}
+
+// EmailNotifier emails firing and resolved alerts to the admins and
+// maintainers of the alert's team, from minSeverity up
+type EmailNotifier struct {
+	db          *gorm.DB
+	mailer      Mailer
+	minSeverity string
+}
+
+// NewEmailNotifier creates a new email notifier. ALERT_EMAIL_SEVERITY sets
+// the least severe alert emailed, critical by default.
+func NewEmailNotifier(db *gorm.DB, mailer Mailer) *EmailNotifier {
+	minSeverity := os.Getenv("ALERT_EMAIL_SEVERITY")
+	if _, ok := alertSeverityRank[minSeverity]; !ok {
+		minSeverity = "critical"
+	}
+	return &EmailNotifier{db: db, mailer: mailer, minSeverity: minSeverity}
+}
+
+// Notify emails an alert notification to its team
+func (en *EmailNotifier) Notify(ctx context.Context, n Notification) error {
+	if n.Event != "alert."+AlertStateFiring && n.Event != "alert."+AlertStateResolved {
+		return nil
+	}
+	if n.TeamID == 0 || alertSeverityRank[n.Severity] < alertSeverityRank[en.minSeverity] {
+		return nil
+	}
+
+	db := database.TenantDB(ctx, en.db).WithContext(ctx)
+	to, err := teamRecipients(db, n.TeamID, "maintainer")
+	if err != nil {
+		return fmt.Errorf("failed to load alert email recipients: %w", err)
+	}
+	return sendMail(ctx, db, en.mailer, mailAlert, to, map[string]interface{}{
+		"Title":     n.Title,
+		"Message":   n.Message,
+		"Severity":  n.Severity,
+		"Timestamp": n.Timestamp.UTC().Format("2006-01-02 15:04 MST"),
+		"Details":   n.Details,
+	})
+}
+
+// MailScheduler sends scheduled email: warnings about certificates nearing
+// expiry, and weekly digests to each team. Each runs as a periodic job.
+// Sent email is recorded as a MailDelivery, so that each is only sent once
+// however often the jobs run or are retried.
+type MailScheduler struct {
+	db          *gorm.DB
+	mailer      Mailer
+	certWarning time.Duration
+}
+
+// NewMailScheduler creates a new mail scheduler, which warns of
+// certificates expiring within certWarning
+func NewMailScheduler(db *gorm.DB, mailer Mailer, certWarning time.Duration) *MailScheduler {
+	return &MailScheduler{db: db, mailer: mailer, certWarning: certWarning}
+}
+
+// deliverOnce sends a message unless one with the same key was sent. The
+// key is claimed before sending and released if sending fails, so that it
+// is retried on the next run.
+func (s *MailScheduler) deliverOnce(ctx context.Context, key string, send func() error) error {
+	db := s.db.WithContext(ctx)
+	delivery := MailDelivery{Key: key, SentAt: time.Now()}
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&delivery)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return result.Error
+	}
+	if err := send(); err != nil {
+		db.Unscoped().Delete(&delivery)
+		return err
+	}
+	return nil
+}
+
+// expiringCertificate is a resource certificate nearing expiry
+type expiringCertificate struct {
+	ID          uint
+	CommonName  string
+	ValidUntil  time.Time
+	AutoRenew   bool
+	Resource    string
+	Environment string
+	TeamID      uint
+	Team        string
+}
+
+// SendCertExpiryWarnings warns teams of their certificates expiring within
+// the warning window, once for each certificate and expiry
+func (s *MailScheduler) SendCertExpiryWarnings(ctx context.Context) error {
+	db := s.db.WithContext(ctx)
+	if !db.Migrator().HasTable("certificates") {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	var certs []expiringCertificate
+	if err := db.Raw(`SELECT c.id, COALESCE(c.common_name, '') AS common_name, c.valid_until,
+			COALESCE(c.auto_renew, false) AS auto_renew,
+			r.name AS resource, r.environment, r.team_id, t.name AS team
+		FROM certificates c
+		JOIN resources r ON r.id = c.resource_id AND r.deleted_at IS NULL
+		JOIN teams t ON t.id = r.team_id
+		WHERE c.deleted_at IS NULL AND c.valid_until > ? AND c.valid_until <= ?`,
+		now, now.Add(s.certWarning)).Scan(&certs).Error; err != nil {
+		return fmt.Errorf("failed to load expiring certificates: %w", err)
+	}
+
+	failed := 0
+	for _, cert := range certs {
+		to, err := teamRecipients(db, cert.TeamID, "maintainer")
+		if err != nil {
+			log.Printf("Failed to load recipients for team %d: %v", cert.TeamID, err)
+			failed++
+			continue
+		}
+		if len(to) == 0 {
+			continue
+		}
+		key := fmt.Sprintf("cert_expiry:%d:%s", cert.ID, cert.ValidUntil.UTC().Format("2006-01-02"))
+		err = s.deliverOnce(ctx, key, func() error {
+			return sendMail(ctx, db, s.mailer, mailCertExpiry, to, map[string]interface{}{
+				"Resource":    cert.Resource,
+				"Environment": cert.Environment,
+				"Team":        cert.Team,
+				"CommonName":  cert.CommonName,
+				"ValidUntil":  cert.ValidUntil.UTC().Format("2006-01-02 15:04 MST"),
+				"Days":        int(cert.ValidUntil.Sub(now).Hours() / 24),
+				"AutoRenew":   cert.AutoRenew,
+			})
+		})
+		if err != nil {
+			log.Printf("Error sending expiry warning for certificate %d: %v", cert.ID, err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to send %d of %d certificate expiry warnings", failed, len(certs))
+	}
+	return nil
+}
+
+// SendTeamDigests sends each team its digest of the past week, once in
+// every ISO week
+func (s *MailScheduler) SendTeamDigests(ctx context.Context) error {
+	db := s.db.WithContext(ctx)
+	var teams []Team
+	if err := db.Find(&teams).Error; err != nil {
+		return fmt.Errorf("failed to load teams for digests: %w", err)
+	}
+
+	now := time.Now().UTC()
+	year, week := now.ISOWeek()
+	failed := 0
+	for _, team := range teams {
+		key := fmt.Sprintf("team_digest:%d:%d-W%02d", team.ID, year, week)
+		err := s.deliverOnce(ctx, key, func() error {
+			to, err := teamRecipients(db, team.ID, "viewer")
+			if err != nil || len(to) == 0 {
+				return err
+			}
+			data, err := s.teamDigest(db, &team, now.AddDate(0, 0, -7))
+			if err != nil {
+				return err
+			}
+			return sendMail(ctx, db, s.mailer, mailTeamDigest, to, data)
+		})
+		if err != nil {
+			log.Printf("Error sending digest to team %d: %v", team.ID, err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to send %d of %d team digests", failed, len(teams))
+	}
+	return nil
+}
+
+// teamDigest gathers a team's activity since a time
+func (s *MailScheduler) teamDigest(db *gorm.DB, team *Team, since time.Time) (map[string]interface{}, error) {
+	var resources, failed, alertsFired, backupsCompleted, backupsFailed, certsExpiring int64
+	if err := db.Raw(`SELECT COUNT(*), COUNT(*) FILTER (WHERE status = 'failed')
+		FROM resources WHERE team_id = ? AND deleted_at IS NULL`, team.ID).
+		Row().Scan(&resources, &failed); err != nil {
+		return nil, fmt.Errorf("failed to count resources: %w", err)
+	}
+	if err := db.Model(&Alert{}).Where("team_id = ? AND fired_at > ?", team.ID, since).
+		Count(&alertsFired).Error; err != nil {
+		return nil, fmt.Errorf("failed to count alerts: %w", err)
+	}
+
+	migrator := db.Migrator()
+	if migrator.HasTable("backup_jobs") {
+		if err := db.Raw(`SELECT
+				COUNT(*) FILTER (WHERE b.status = ?),
+				COUNT(*) FILTER (WHERE b.status = ?)
+			FROM backup_jobs b JOIN resources r ON r.id = b.resource_id
+			WHERE r.team_id = ? AND b.created_at > ?`, backupJobCompleted, backupJobFailed, team.ID, since).
+			Row().Scan(&backupsCompleted, &backupsFailed); err != nil {
+			return nil, fmt.Errorf("failed to count backups: %w", err)
+		}
+	}
+	if migrator.HasTable("certificates") {
+		now := time.Now().UTC()
+		if err := db.Raw(`SELECT COUNT(*) FROM certificates c
+			JOIN resources r ON r.id = c.resource_id AND r.deleted_at IS NULL
+			WHERE r.team_id = ? AND c.deleted_at IS NULL AND c.valid_until > ? AND c.valid_until <= ?`,
+			team.ID, now, now.AddDate(0, 0, 30)).Row().Scan(&certsExpiring); err != nil {
+			return nil, fmt.Errorf("failed to count expiring certificates: %w", err)
+		}
+	}
+
+	return map[string]interface{}{
+		"Team":             team.Name,
+		"Since":            since.Format("2006-01-02"),
+		"Resources":        resources,
+		"Failed":           failed,
+		"AlertsFired":      alertsFired,
+		"BackupsCompleted": backupsCompleted,
+		"BackupsFailed":    backupsFailed,
+		"CertsExpiring":    certsExpiring,
+	}, nil
+}
@@ -1,43 +1,136 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"flag"
+	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/penguintechinc/project-template/shared/apierrors"
+	"github.com/penguintechinc/project-template/shared/compress"
+	"github.com/penguintechinc/project-template/shared/configfile"
+	"github.com/penguintechinc/project-template/shared/credpolicy"
 	"github.com/penguintechinc/project-template/shared/database"
+	"github.com/penguintechinc/project-template/shared/fields"
 	"github.com/penguintechinc/project-template/shared/licensing"
+	"github.com/penguintechinc/project-template/shared/mtls"
+	"github.com/penguintechinc/project-template/shared/servertls"
+	"github.com/penguintechinc/project-template/shared/websecurity"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"gorm.io/gorm"
 )
 
-var (
-	// Prometheus metrics
-	requestsTotal = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "http_requests_total",
-			Help: "Total number of HTTP requests",
-		},
-		[]string{"method", "endpoint", "status"},
-	)
-
-	requestDuration = prometheus.NewHistogramVec(
-		prometheus.HistogramOpts{
-			Name: "http_request_duration_seconds",
-			Help: "HTTP request duration in seconds",
-		},
-		[]string{"method", "endpoint"},
-	)
-)
-
-func init() {
-	// Register Prometheus metrics
-	prometheus.MustRegister(requestsTotal)
-	prometheus.MustRegister(requestDuration)
+// migratedModels are the tables the API migrates on startup, and before
+// seeding demo data
+var migratedModels = []interface{}{
+	&User{},
+	&Team{},
+	&TeamMember{},
+	&ResourceType{},
+	&Resource{},
+	&ResourceStats{},
+	&AlertRule{},
+	&Alert{},
+	&Integration{},
+	&EventExportCursor{},
+	&RetentionPolicy{},
+	&ArchiveRun{},
+	&TeamDeletion{},
+	&Environment{},
+	&ControllerInstance{},
+	&SchemaInfo{},
+	&Agent{},
+	&DockerHost{},
+	&CloudAccount{},
+	&AdoptionCandidate{},
+	&SSHCertificate{},
+	&ConsumerBinding{},
+	&ResourceClaim{},
+	&BackstageToken{},
+	&GitSyncedResource{},
+	&SlackBackupThread{},
+	&Ticket{},
+	&TeamInvitation{},
+	&MailDelivery{},
+	&ReconcileRequest{},
+	&ReconcileStatus{},
+	&StuckResource{},
+	&ConsistencyReport{},
+	&CredentialUsage{},
+	&ImageRegistry{},
+	&AllowedImage{},
+	&ContainerPolicy{},
+	&SizeClass{},
+	&FeatureFlag{},
+	&LogLevelOverride{},
+	&Tenant{},
+	&PasswordPolicy{},
+	&EmailTemplate{},
+	&AuditAnchor{},
+	&ErasureRequest{},
+	&NetworkAccessRule{},
+	&WorkloadIdentity{},
+	&Job{},
+	&Operation{},
+	&ResourceLock{},
+	&Policy{},
+	&ValidationWebhook{},
+	&UserPreference{},
+	&SavedView{},
+	&Announcement{},
+	&AnnouncementAck{},
+	&database.AuditLog{},
+	&database.Session{},
+	&database.LicenseUsage{},
 }
 
 func main() {
+	configFile := flag.String("config", os.Getenv("CONFIG_FILE"), "YAML config file; environment variables override its settings")
+	validateConfig := flag.Bool("validate-config", false, "Validate the configuration, print the problems found and exit")
+	flag.Parse()
+
+	// Apply the config file to the environment before anything reads it
+	var configSource *configfile.Source
+	if *configFile != "" {
+		configSource = configfile.NewSource(*configFile)
+		if _, err := configSource.Apply(); err != nil {
+			log.Fatalf("Failed to load config file: %v", err)
+		}
+	}
+	if *validateConfig {
+		if err := validateSettings(); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid configuration:\n%v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println("Configuration is valid")
+		return
+	}
+	if err := validateSettings(); err != nil {
+		log.Printf("Invalid settings, which fall back to their defaults: %v", err)
+	}
+	if configSource != nil {
+		overridden, _ := configSource.Overridden()
+		log.Printf("Loaded config file %s; overridden by the environment: %s", configSource.Path(), strings.Join(overridden, ", "))
+	}
+
+	// `api seed` fills the database with demo data for local development
+	// and exits, without needing a license
+	if flag.Arg(0) == "seed" {
+		seedCommand(flag.Args()[1:])
+		return
+	}
+
 	// Initialize license client
 	licenseClient := licensing.NewClientFromEnv()
 	if licenseClient == nil {
@@ -63,68 +156,560 @@ func main() {
 		}
 	}
 
+	// Use the service identity issued by the controller for mutual TLS with
+	// Postgres and with the API's own clients when MTLS_ENABLED is true
+	identity, err := mtls.FromEnv()
+	if err != nil {
+		log.Fatalf("Failed to load service identity: %v", err)
+	}
+
+	// Accept X.509-SVIDs issued by SPIRE for SPIFFE_TRUST_DOMAIN in place of
+	// static credentials, verified against the bundle the SPIRE agent writes
+	trustDomain := os.Getenv("SPIFFE_TRUST_DOMAIN")
+	if trustDomain != "" {
+		if identity == nil {
+			log.Fatal("SPIFFE_TRUST_DOMAIN requires MTLS_ENABLED")
+		}
+		bundleFile := os.Getenv("SPIFFE_BUNDLE_FILE")
+		if bundleFile == "" {
+			bundleFile = "/etc/nest/spiffe/bundle.pem"
+		}
+		if err := identity.AddTrustBundle(trustDomain, bundleFile); err != nil {
+			log.Fatalf("Failed to load SPIFFE trust bundle: %v", err)
+		}
+	}
+
+	// Serve TLS with the certificate files in TLS_CERT_FILE and TLS_KEY_FILE
+	// or, with mutual TLS, the service identity. Only the internal routes
+	// agents and the controller call require a client certificate.
+	var identityTLS *tls.Config
+	if identity != nil {
+		identityTLS = identity.ServerConfig(tls.VerifyClientCertIfGiven)
+	}
+	tlsConfig, err := servertls.Settings{
+		CertFile:     os.Getenv("TLS_CERT_FILE"),
+		KeyFile:      os.Getenv("TLS_KEY_FILE"),
+		MinVersion:   os.Getenv("TLS_MIN_VERSION"),
+		CipherSuites: strings.Split(os.Getenv("TLS_CIPHER_SUITES"), ","),
+		ClientCAFile: os.Getenv("TLS_CLIENT_CA_FILE"),
+		ClientAuth:   os.Getenv("TLS_CLIENT_AUTH"),
+	}.ServerConfig(identityTLS)
+	if err != nil {
+		log.Fatalf("Invalid TLS configuration: %v", err)
+	}
+
 	// Initialize database
-	db, err := database.New(database.DefaultConfig())
+	dbConfig := database.DefaultConfig()
+	if identity != nil {
+		dbConfig.TLSConfig = identity.ClientConfig(dbConfig.Host)
+	}
+	db, err := database.New(dbConfig)
 	if err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
 	defer db.Close()
 
+	// Log SQL at the level of LOG_LEVEL, and reread the config file and
+	// apply the settings that don't need a restart on SIGHUP
+	sqlLog := &sqlLogger{}
+	db.DB.Logger = sqlLog
+	levels := newLogLevels(sqlLog)
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	go watchConfigReloads(reload, configSource, levels)
+
 	// Run database migrations
-	if err := db.Migrate(
-		&User{},
-		&Team{},
-		&TeamMember{},
-		&ResourceType{},
-		&Resource{},
-		&ResourceStats{},
-		&database.Session{},
-		&database.LicenseUsage{},
-	); err != nil {
+	if err := db.Migrate(migratedModels...); err != nil {
 		log.Fatalf("Failed to run database migrations: %v", err)
 	}
 
+	// Record the schema version for controllers to check against, and warn
+	// about running controllers it no longer supports
+	if err := recordSchemaVersion(db.DB); err != nil {
+		log.Fatalf("Failed to record schema version: %v", err)
+	}
+	warnIncompatibleControllers(db.DB)
+
 	log.Println("Database initialized and migrations completed")
 
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Pick up the service identity when the controller rotates it
+	if identity != nil {
+		reloadInterval := time.Minute
+		if v := os.Getenv("MTLS_RELOAD_INTERVAL"); v != "" {
+			if parsed, err := time.ParseDuration(v); err == nil && parsed > 0 {
+				reloadInterval = parsed
+			}
+		}
+		go identity.Watch(ctx, reloadInterval)
+	}
+
+	// Background workers read their own recent writes, so they always use the
+	// primary even when read replicas are configured
+	primaryDB := database.UsePrimary(db.DB)
+
+	// Apply log level overrides set through the admin API on every replica,
+	// and toggle debug logging on SIGUSR1
+	logLevelPoll := 15 * time.Second
+	if v := os.Getenv("LOG_LEVEL_POLL_INTERVAL"); v != "" {
+		if parsed, err := time.ParseDuration(v); err == nil && parsed > 0 {
+			logLevelPoll = parsed
+		}
+	}
+	debugToggle := make(chan os.Signal, 1)
+	signal.Notify(debugToggle, syscall.SIGUSR1)
+	go levels.Run(ctx, primaryDB, logLevelPoll, debugToggle)
+
+	// Partition time-series tables by month and keep future partitions created
+	if os.Getenv("PARTITIONING_ENABLED") == "true" {
+		monthsAhead := 3
+		if v := os.Getenv("PARTITION_MONTHS_AHEAD"); v != "" {
+			if parsed, err := strconv.Atoi(v); err == nil && parsed >= 0 {
+				monthsAhead = parsed
+			}
+		}
+		partitionMaintainer := NewPartitionMaintainer(primaryDB, monthsAhead, 24*time.Hour)
+		if err := partitionMaintainer.Convert(ctx); err != nil {
+			log.Fatalf("Failed to partition tables: %v", err)
+		}
+		go partitionMaintainer.Run(ctx)
+	}
+
+	// Chain audit log entries by hash so tampering can be detected
+	if err := EnableAuditChain(ctx, primaryDB); err != nil {
+		log.Fatalf("Failed to enable audit log chaining: %v", err)
+	}
+
+	// Export connection pool statistics and per-team resource and alert counts
+	RegisterDatabaseMetrics(db)
+	if os.Getenv("EXPOSE_TEAM_METRICS") != "false" {
+		prometheus.MustRegister(NewTeamMetricsCollector(primaryDB))
+	}
+
+	// Send transactional mail through SMTP when it is configured
+	var mailer Mailer
+	if m := NewSMTPMailerFromEnv(); m != nil {
+		mailer = m
+	}
+
+	// Cache hot membership and metadata lookups, invalidated on writes
+	cache := database.NewCacheFromEnv()
+	if cache != nil {
+		if err := database.RegisterCacheInvalidation(db.DB, cache, cacheInvalidationTables); err != nil {
+			log.Fatalf("Failed to register cache invalidation: %v", err)
+		}
+	}
+	accessCache := NewAccessCache(db.DB, cache, database.CacheTTLFromEnv())
+
+	// Enforce team scoping in Postgres as well as in handlers
+	rowSecurity := os.Getenv("ROW_SECURITY_ENABLED") == "true"
+	if rowSecurity {
+		if err := EnableRowSecurity(ctx, primaryDB); err != nil {
+			log.Fatalf("Failed to enable row security: %v", err)
+		}
+	}
+
+	// Isolate tenants of hosted deployments in their own schemas
+	var tenantRouter *TenantRouter
+	switch mode := os.Getenv("TENANCY_MODE"); mode {
+	case "":
+	case TenancyModeSchema:
+		poolSize := 5
+		if v := os.Getenv("TENANT_POOL_SIZE"); v != "" {
+			if parsed, err := strconv.Atoi(v); err == nil && parsed > 0 {
+				poolSize = parsed
+			}
+		}
+		tenantRouter = NewTenantRouter(primaryDB, dbConfig, cache, poolSize, rowSecurity)
+		defer tenantRouter.Close()
+		if err := tenantRouter.MigrateAll(ctx); err != nil {
+			log.Fatalf("Failed to migrate tenant schemas: %v", err)
+		}
+	default:
+		log.Fatalf("Unknown TENANCY_MODE %q", mode)
+	}
+
+	// Run background work as jobs on a persistent queue. Jobs are retried
+	// with backoff when they fail, and each runs on one replica at a time.
+	jobInterval := 5 * time.Second
+	if v := os.Getenv("JOB_POLL_INTERVAL"); v != "" {
+		if parsed, err := time.ParseDuration(v); err == nil && parsed > 0 {
+			jobInterval = parsed
+		}
+	}
+	jobConcurrency := 4
+	if v := os.Getenv("JOB_CONCURRENCY"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil && parsed > 0 {
+			jobConcurrency = parsed
+		}
+	}
+	jobRunner := NewJobRunner(primaryDB, jobInterval, jobConcurrency)
+
+	// Evaluate alert rules, and deliver their notifications as jobs
+
+	alertInterval := 60 * time.Second
+	if v := os.Getenv("ALERT_EVALUATION_INTERVAL"); v != "" {
+		if parsed, err := time.ParseDuration(v); err == nil && parsed > 0 {
+			alertInterval = parsed
+		}
+	}
+	notifier := MultiNotifier{NewNotifierFromEnv(), NewIncidentNotifier(primaryDB)}
+	if mailer != nil {
+		notifier = append(notifier, NewEmailNotifier(primaryDB, mailer))
+	}
+	jobRunner.Handle(JobNotification, NotificationJob(notifier, tenantRouter), JobOptions{MaxAttempts: 8, Timeout: time.Minute})
+	jobRunner.Handle(JobAlertEvaluation, PeriodicJob(perTenant(primaryDB, tenantRouter, func(db *gorm.DB) func(context.Context) error {
+		return NewAlertEvaluator(db).Evaluate
+	})), JobOptions{MaxAttempts: 1})
+	jobRunner.Every(JobAlertEvaluation, alertInterval, JobPriorityHigh)
+
+	// Start audit event export to SIEM/Kafka/webhook integrations
+	exportInterval := 5 * time.Second
+	if v := os.Getenv("EVENT_EXPORT_INTERVAL"); v != "" {
+		if parsed, err := time.ParseDuration(v); err == nil && parsed > 0 {
+			exportInterval = parsed
+		}
+	}
+	jobRunner.Handle(JobEventExport, PeriodicJob(perTenant(primaryDB, tenantRouter, func(db *gorm.DB) func(context.Context) error {
+		return NewEventExporter(db).ExportAll
+	})), JobOptions{MaxAttempts: 1})
+	jobRunner.Every(JobEventExport, exportInterval, JobPriorityNormal)
+
+	// Post the results of backups queued from Slack to their threads
+	slackInterval := 15 * time.Second
+	if v := os.Getenv("SLACK_NOTIFY_INTERVAL"); v != "" {
+		if parsed, err := time.ParseDuration(v); err == nil && parsed > 0 {
+			slackInterval = parsed
+		}
+	}
+	jobRunner.Handle(JobSlackBackupResults, PeriodicJob(NewSlackNotifier(primaryDB).NotifyAll), JobOptions{MaxAttempts: 1})
+	jobRunner.Every(JobSlackBackupResults, slackInterval, JobPriorityNormal)
+
+	// Open tickets for failed production jobs and compliance violations, and
+	// sync approvals back from Jira and ServiceNow
+	ticketInterval := time.Minute
+	if v := os.Getenv("TICKET_SYNC_INTERVAL"); v != "" {
+		if parsed, err := time.ParseDuration(v); err == nil && parsed > 0 {
+			ticketInterval = parsed
+		}
+	}
+	jobRunner.Handle(JobTicketSync, PeriodicJob(perTenant(primaryDB, tenantRouter, func(db *gorm.DB) func(context.Context) error {
+		return NewTicketSyncer(db).SyncAll
+	})), JobOptions{MaxAttempts: 1})
+	jobRunner.Every(JobTicketSync, ticketInterval, JobPriorityNormal)
+
+	// Warn teams of expiring certificates and send weekly digests
+	if mailer != nil {
+		mailInterval := 15 * time.Minute
+		if v := os.Getenv("MAIL_SCHEDULE_INTERVAL"); v != "" {
+			if parsed, err := time.ParseDuration(v); err == nil && parsed > 0 {
+				mailInterval = parsed
+			}
+		}
+		certWarning := 14 * 24 * time.Hour
+		if v := os.Getenv("CERT_EXPIRY_WARNING"); v != "" {
+			if parsed, err := time.ParseDuration(v); err == nil && parsed > 0 {
+				certWarning = parsed
+			}
+		}
+		mailScheduler := NewMailScheduler(primaryDB, mailer, certWarning)
+		jobRunner.Handle(JobCertExpiryMail, PeriodicJob(mailScheduler.SendCertExpiryWarnings), JobOptions{MaxAttempts: 3})
+		jobRunner.Handle(JobTeamDigestMail, PeriodicJob(mailScheduler.SendTeamDigests), JobOptions{MaxAttempts: 3})
+		jobRunner.Every(JobCertExpiryMail, mailInterval, JobPriorityLow)
+		jobRunner.Every(JobTeamDigestMail, mailInterval, JobPriorityLow)
+	}
+
+	// Start retention pruning and archival
+	var archiveStore ObjectStore
+	if store := NewObjectStoreFromEnv(); store != nil {
+		archiveStore = store
+	}
+	retentionInterval := time.Hour
+	if v := os.Getenv("RETENTION_INTERVAL"); v != "" {
+		if parsed, err := time.ParseDuration(v); err == nil && parsed > 0 {
+			retentionInterval = parsed
+		}
+	}
+	retentionManager := NewRetentionManager(primaryDB, archiveStore)
+	jobRunner.Handle(JobRetention, PeriodicJob(retentionManager.ApplyPolicies), JobOptions{MaxAttempts: 1, Timeout: time.Hour})
+	jobRunner.Every(JobRetention, retentionInterval, JobPriorityLow)
+
+	// Purge deleted resources once their restore window has passed
+	trashRetention := 7 * 24 * time.Hour
+	if v := os.Getenv("RESOURCE_TRASH_RETENTION"); v != "" {
+		if parsed, err := time.ParseDuration(v); err == nil && parsed > 0 {
+			trashRetention = parsed
+		}
+	}
+	purgeInterval := time.Hour
+	if v := os.Getenv("RESOURCE_PURGE_INTERVAL"); v != "" {
+		if parsed, err := time.ParseDuration(v); err == nil && parsed > 0 {
+			purgeInterval = parsed
+		}
+	}
+	jobRunner.Handle(JobResourcePurge, PeriodicJob(NewResourcePurger(primaryDB, trashRetention).Purge), JobOptions{MaxAttempts: 3, Timeout: 30 * time.Minute})
+	jobRunner.Every(JobResourcePurge, purgeInterval, JobPriorityLow)
+
+	// Complete force team deletions once their resources are deprovisioned
+	teamDeletionInterval := 30 * time.Second
+	if v := os.Getenv("TEAM_DELETION_INTERVAL"); v != "" {
+		if parsed, err := time.ParseDuration(v); err == nil && parsed > 0 {
+			teamDeletionInterval = parsed
+		}
+	}
+	jobRunner.Handle(JobTeamDeletions, PeriodicJob(NewTeamDeletionWorker(primaryDB).Process), JobOptions{MaxAttempts: 1})
+	jobRunner.Every(JobTeamDeletions, teamDeletionInterval, JobPriorityNormal)
+
+	// Import RDS and Cloud SQL instances of the configured cloud accounts
+	cloudSyncInterval := 15 * time.Minute
+	if v := os.Getenv("CLOUD_SYNC_INTERVAL"); v != "" {
+		if parsed, err := time.ParseDuration(v); err == nil && parsed > 0 {
+			cloudSyncInterval = parsed
+		}
+	}
+	cloudSyncer := NewCloudSyncer(primaryDB)
+	jobRunner.Handle(JobCloudSync, cloudSyncer.EnqueueAccountSyncs, JobOptions{MaxAttempts: 3})
+	jobRunner.Handle(JobCloudAccountSync, cloudSyncer.SyncAccount, JobOptions{MaxAttempts: 3, Timeout: 15 * time.Minute})
+	jobRunner.Every(JobCloudSync, cloudSyncInterval, JobPriorityLow)
+
+	// Delete succeeded jobs after JOB_RETENTION; dead jobs are kept for
+	// admins to requeue or discard
+	jobRetention := 7 * 24 * time.Hour
+	if v := os.Getenv("JOB_RETENTION"); v != "" {
+		if parsed, err := time.ParseDuration(v); err == nil && parsed > 0 {
+			jobRetention = parsed
+		}
+	}
+	jobRunner.Handle(JobPrune, func(ctx context.Context, _ *Job) error {
+		return PruneJobs(ctx, primaryDB, jobRetention)
+	}, JobOptions{MaxAttempts: 1})
+	jobRunner.Every(JobPrune, time.Hour, JobPriorityLow)
+
+	// Fail provisioning and backup jobs stuck past their JOB_TIMEOUTS, such
+	// as those of a controller replica or agent that went away
+	jobTimeouts, err := ParseJobTimeouts(os.Getenv("JOB_TIMEOUTS"))
+	if err != nil {
+		log.Fatalf("Invalid JOB_TIMEOUTS: %v", err)
+	}
+	jobReapInterval := time.Minute
+	if v := os.Getenv("JOB_REAP_INTERVAL"); v != "" {
+		if parsed, err := time.ParseDuration(v); err == nil && parsed > 0 {
+			jobReapInterval = parsed
+		}
+	}
+	jobRunner.Handle(JobReap, PeriodicJob(NewJobReaper(primaryDB, jobTimeouts).Reap), JobOptions{MaxAttempts: 1})
+	jobRunner.Every(JobReap, jobReapInterval, JobPriorityNormal)
+
+	// Re-reconcile full lifecycle resources stuck in a transitional status
+	// for STUCK_RESOURCE_THRESHOLD, then mark them errored, alerting their
+	// teams
+	stuckThreshold := time.Hour
+	if v := os.Getenv("STUCK_RESOURCE_THRESHOLD"); v != "" {
+		if parsed, err := time.ParseDuration(v); err == nil && parsed > 0 {
+			stuckThreshold = parsed
+		}
+	}
+	stuckRequeues := 2
+	if v := os.Getenv("STUCK_RESOURCE_REQUEUES"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil && parsed >= 0 {
+			stuckRequeues = parsed
+		}
+	}
+	stuckCheckInterval := 5 * time.Minute
+	if v := os.Getenv("STUCK_RESOURCE_CHECK_INTERVAL"); v != "" {
+		if parsed, err := time.ParseDuration(v); err == nil && parsed > 0 {
+			stuckCheckInterval = parsed
+		}
+	}
+	jobRunner.Handle(JobStuckResources, PeriodicJob(perTenant(primaryDB, tenantRouter, func(db *gorm.DB) func(context.Context) error {
+		return NewResourceWatchdog(db, stuckThreshold, stuckRequeues).Check
+	})),
+		JobOptions{MaxAttempts: 1})
+	jobRunner.Every(JobStuckResources, stuckCheckInterval, JobPriorityNormal)
+
+	// Evaluate Rego policies on resource changes through the OPA server at
+	// OPA_URL, keeping the policies loaded in it
+	policyEngine := NewPolicyEngine(primaryDB, os.Getenv("OPA_URL"))
+	if policyEngine.Enabled() {
+		policySyncInterval := 5 * time.Minute
+		if v := os.Getenv("POLICY_SYNC_INTERVAL"); v != "" {
+			if parsed, err := time.ParseDuration(v); err == nil && parsed > 0 {
+				policySyncInterval = parsed
+			}
+		}
+		jobRunner.Handle(JobPolicySync, PeriodicJob(policyEngine.Sync), JobOptions{MaxAttempts: 1})
+		jobRunner.Every(JobPolicySync, policySyncInterval, JobPriorityNormal)
+	}
+
+	// Report anonymized usage counts to the license server when opted in.
+	// USAGE_REPORTING_OPT_OUT turns reporting off regardless.
+	usageInterval := 24 * time.Hour
+	if v := os.Getenv("USAGE_REPORTING_INTERVAL"); v != "" {
+		if parsed, err := time.ParseDuration(v); err == nil && parsed > 0 {
+			usageInterval = parsed
+		}
+	}
+	usageReporter := NewUsageReporter(primaryDB, licenseClient, usageInterval,
+		os.Getenv("USAGE_REPORTING_ENABLED") == "true", os.Getenv("USAGE_REPORTING_OPT_OUT") == "true")
+	if usageReporter.Active() {
+		jobRunner.Handle(JobUsageReport, PeriodicJob(usageReporter.Send), JobOptions{MaxAttempts: 1})
+		jobRunner.Every(JobUsageReport, usageInterval, JobPriorityLow)
+	} else {
+		log.Println("Usage reporting disabled")
+	}
+
+	// Check passwords against the password policy. Breach checks send only a
+	// hash prefix to the range API; PASSWORD_BREACH_CHECK_URL=off disables them.
+	var breaches credpolicy.BreachChecker
+	if url := os.Getenv("PASSWORD_BREACH_CHECK_URL"); url != "off" {
+		breachTimeout := 3 * time.Second
+		if v := os.Getenv("PASSWORD_BREACH_CHECK_TIMEOUT"); v != "" {
+			if parsed, err := time.ParseDuration(v); err == nil && parsed > 0 {
+				breachTimeout = parsed
+			}
+		}
+		breaches = credpolicy.NewPwnedPasswords(url, breachTimeout)
+	}
+	passwordPolicies := NewPasswordPolicies(db.DB, breaches)
+
+	// Anchor the head of each audit log chain in the archive object store
+	// when AUDIT_ANCHOR_INTERVAL is set
+	if v := os.Getenv("AUDIT_ANCHOR_INTERVAL"); v != "" {
+		if parsed, err := time.ParseDuration(v); err == nil && parsed > 0 {
+			if archiveStore != nil {
+				jobRunner.Handle(JobAuditAnchor, PeriodicJob(NewAuditAnchorer(primaryDB, tenantRouter, archiveStore).AnchorAll),
+					JobOptions{MaxAttempts: 1})
+				jobRunner.Every(JobAuditAnchor, parsed, JobPriorityNormal)
+			} else {
+				log.Println("Audit anchoring is disabled: no archive object store is configured")
+			}
+		}
+	}
+
+	// Start the job workers once every kind is registered
+	go jobRunner.Run(ctx)
+
 	// Set up Gin router
 	if os.Getenv("GIN_MODE") == "release" {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
 	r := gin.Default()
+	r.HandleMethodNotAllowed = true
+
+	// Take client addresses from X-Forwarded-For only when the request came
+	// through one of TRUSTED_PROXIES, so network access rules can't be
+	// bypassed with a forged header
+	var proxies []string
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	if err := r.SetTrustedProxies(proxies); err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
+
+	// Report every error as application/problem+json with a request ID
+	r.Use(apierrors.Middleware())
+	r.NoRoute(apierrors.NotFound)
+	r.NoMethod(apierrors.MethodNotAllowed)
+
+	// Security headers, CORS and CSRF protection for browser clients
+	webSecurity := websecurity.ConfigFromEnv()
+	r.Use(websecurity.Headers(webSecurity), websecurity.CORS(webSecurity), websecurity.CSRF(webSecurity))
+
+	// Compress responses for clients that accept gzip or deflate
+	r.Use(compress.Middleware(compress.DefaultMinSize))
 
 	// Add license middleware
 	r.Use(licensing.LicenseMiddleware(licenseClient))
 
 	// Add metrics middleware
-	r.Use(func(c *gin.Context) {
-		timer := prometheus.NewTimer(requestDuration.WithLabelValues(c.Request.Method, c.FullPath()))
-		defer timer.ObserveDuration()
-
-		c.Next()
-
-		requestsTotal.WithLabelValues(
-			c.Request.Method,
-			c.FullPath(),
-			string(rune(c.Writer.Status())),
-		).Inc()
-	})
+	r.Use(MetricsMiddleware(metricsExcludedRoutes()))
 
 	// Health check endpoint
 	r.GET("/health", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{
-			"status":  "healthy",
-			"version": os.Getenv("VERSION"),
+			"status":   "healthy",
+			"version":  buildVersion(),
+			"replicas": db.ReplicaStatus(),
 		})
 	})
 
 	// Metrics endpoint
 	r.GET("/metrics", gin.WrapH(promhttp.Handler()))
 
+	// Runtime diagnostics and pprof profiles, for global admins or, with
+	// DEBUG_LOCAL_ONLY, for loopback clients only
+	diagnosticsCtrl := NewDiagnosticsController(db, primaryDB)
+	debugEndpoints := os.Getenv("ENABLE_DEBUG_ENDPOINTS") == "true"
+	debugLocalOnly := os.Getenv("DEBUG_LOCAL_ONLY") == "true"
+	if debugEndpoints && debugLocalOnly {
+		r.GET("/debug/diagnostics", diagnosticsCtrl.LocalDiagnostics)
+		r.GET("/debug/pprof/*profile", diagnosticsCtrl.LocalProfile)
+	}
+
+	// Controllers without a heartbeat for CONTROLLER_STALE_AFTER are
+	// reported stale
+	controllerStale := 2 * time.Minute
+	if v := os.Getenv("CONTROLLER_STALE_AFTER"); v != "" {
+		if parsed, err := time.ParseDuration(v); err == nil && parsed > 0 {
+			controllerStale = parsed
+		}
+	}
+
+	// Public status page for internal dashboards, without authentication
+	if os.Getenv("STATUS_PAGE_ENABLED") == "true" {
+		statusRefresh := time.Minute
+		if v := os.Getenv("STATUS_PAGE_REFRESH"); v != "" {
+			if parsed, err := time.ParseDuration(v); err == nil && parsed >= 0 {
+				statusRefresh = parsed
+			}
+		}
+		statusPath := os.Getenv("STATUS_PAGE_PATH")
+		if statusPath == "" {
+			statusPath = "/status"
+		}
+		statusPageCtrl := NewStatusPageController(db, StatusPageConfig{
+			Title:           os.Getenv("STATUS_PAGE_TITLE"),
+			Refresh:         statusRefresh,
+			ShowTeams:       os.Getenv("STATUS_PAGE_SHOW_TEAMS") != "false",
+			ControllerStale: controllerStale,
+		})
+		r.GET(statusPath, statusPageCtrl.GetStatusPage)
+	}
+
+	// Admin UI, unless the install serves its own frontend
+	if os.Getenv("ENABLE_WEB_UI") != "false" {
+		registerUI(r)
+	}
+
 	// API routes
 	v1 := r.Group("/api/v1")
+	// Internal routes, called by agents and the controller rather than users,
+	// require a client certificate from the internal CA
+	var internalOnly []gin.HandlerFunc
+	if identity != nil {
+		if trustDomain != "" {
+			v1.Use(WorkloadIdentityMiddleware(db.DB, identity, trustDomain))
+		}
+		internalOnly = append(internalOnly, PeerCertificateMiddleware(identity, strings.Split(os.Getenv("MTLS_ALLOWED_PEERS"), ",")))
+	}
+	if tenantRouter != nil {
+		v1.Use(tenantRouter.Middleware())
+	}
+	v1.Use(BackstageTokenMiddleware(db.DB))
+	v1.Use(NetworkAccessMiddleware(db.DB, accessCache))
+	if rowSecurity {
+		v1.Use(RowSecurityMiddleware(db.DB))
+	}
+	v1.Use(fields.Middleware())
 	{
 		v1.GET("/status", getStatus)
+		v1.GET("/version", getVersion)
 		v1.GET("/features", getFeatures)
 
 		// Feature-gated endpoints
@@ -143,32 +728,341 @@ func main() {
 		}
 
 		// Resource endpoints
-		resourceCtrl := NewResourceController(db.DB)
+		resourceCtrl := NewResourceController(db.DB, accessCache, passwordPolicies, policyEngine, NewValidationWebhooks(primaryDB), trashRetention)
+		environmentCtrl := NewEnvironmentController(db.DB, accessCache)
+		sizingCtrl := NewSizingController(db.DB, accessCache)
 		resources := v1.Group("/resources")
 		{
 			resources.GET("", resourceCtrl.ListResources)
 			resources.POST("", resourceCtrl.CreateResource)
+			resources.GET("/trash", resourceCtrl.ListTrash)
+			resources.POST("/:id/restore-deleted", resourceCtrl.RestoreDeletedResource)
+			resources.GET("/:id/deletion", resourceCtrl.GetDeletionProgress)
 			resources.GET("/:id", resourceCtrl.GetResource)
 			resources.PUT("/:id", resourceCtrl.UpdateResource)
+			resources.Group("", internalOnly...).PUT("/:id/status", resourceCtrl.UpdateResourceStatus)
 			resources.DELETE("/:id", resourceCtrl.DeleteResource)
 			resources.GET("/:id/stats", resourceCtrl.GetResourceStats)
+			resources.GET("/:id/stats/history", resourceCtrl.GetResourceStatsHistory)
+			resources.GET("/:id/insights", resourceCtrl.GetDatabaseInsights)
 			resources.GET("/:id/connection-info", resourceCtrl.GetConnectionInfo)
+			resources.POST("/:id/promote", environmentCtrl.PromoteResource)
+			resources.POST("/:id/reconcile", resourceCtrl.TriggerReconcile)
+			resources.GET("/:id/reconcile-status", resourceCtrl.GetReconcileStatus)
+			resources.GET("/:id/provisioning-jobs", resourceCtrl.ListProvisioningJobs)
+			resources.POST("/:id/provisioning-jobs/:job_id/cancel", resourceCtrl.CancelProvisioningJob)
+			resources.POST("/:id/backup-jobs/:job_id/cancel", resourceCtrl.CancelBackupJob)
+			resources.GET("/:id/lock", resourceCtrl.GetResourceLock)
+			resources.GET("/:id/ssh-certificates", resourceCtrl.ListSSHCertificates)
+			resources.POST("/:id/ssh-certificates", resourceCtrl.IssueSSHCertificate)
+			resources.GET("/:id/ssh-logins", resourceCtrl.GetSSHLogins)
+			resources.PUT("/:id/ssh-logins", resourceCtrl.UpdateSSHLogins)
+			resources.GET("/:id/bindings", resourceCtrl.ListConsumerBindings)
+			resources.POST("/:id/bindings", resourceCtrl.CreateConsumerBinding)
+			resources.DELETE("/:id/bindings/:binding_id", resourceCtrl.DeleteConsumerBinding)
+			resources.POST("/:id/resize", sizingCtrl.ResizeResource)
+		}
+
+		// Operation endpoints
+		operationCtrl := NewOperationController(db.DB)
+		operations := v1.Group("/operations")
+		{
+			operations.GET("", operationCtrl.ListOperations)
+			operations.GET("/:id", operationCtrl.GetOperation)
+		}
+
+		// Declarative apply of resource manifests
+		v1.POST("/apply", resourceCtrl.ApplyResource)
+
+		// Resource type size class endpoints
+		resourceTypes := v1.Group("/resource-types")
+		{
+			resourceTypes.GET("/:id/size-classes", sizingCtrl.ListSizeClasses)
+			resourceTypes.PUT("/:id/size-classes", sizingCtrl.SetSizeClasses)
+		}
+
+		// Certificate expiry
+		certificateCtrl := NewCertificateController(db.DB)
+		v1.GET("/certificates", certificateCtrl.ListCertificates)
+
+		// CSV and Excel exports for team admins
+		exportCtrl := NewExportController(db.DB, accessCache)
+		exports := v1.Group("/exports")
+		{
+			exports.GET("/resources", exportCtrl.ExportResources)
+			exports.GET("/certificates", exportCtrl.ExportCertificates)
+			exports.GET("/backup-coverage", exportCtrl.ExportBackupCoverage)
+		}
+
+		// Alert endpoints
+		alertCtrl := NewAlertController(db.DB, accessCache)
+		v1.GET("/alerts", alertCtrl.ListAlerts)
+		alertRules := v1.Group("/alert-rules")
+		{
+			alertRules.GET("", alertCtrl.ListAlertRules)
+			alertRules.POST("", alertCtrl.CreateAlertRule)
+			alertRules.PUT("/:id", alertCtrl.UpdateAlertRule)
+			alertRules.DELETE("/:id", alertCtrl.DeleteAlertRule)
+		}
+
+		// Integration endpoints
+		integrationCtrl := NewIntegrationController(db.DB, accessCache)
+		integrations := v1.Group("/integrations")
+		{
+			integrations.GET("", integrationCtrl.ListIntegrations)
+			integrations.POST("", integrationCtrl.CreateIntegration)
+			integrations.GET("/:id", integrationCtrl.GetIntegration)
+			integrations.PUT("/:id", integrationCtrl.UpdateIntegration)
+			integrations.DELETE("/:id", integrationCtrl.DeleteIntegration)
+			integrations.POST("/:id/test", integrationCtrl.TestIntegration)
+			integrations.POST("/:id/replay", integrationCtrl.ReplayEvents)
+			integrations.POST("/:id/sync", integrationCtrl.SyncGitRepository)
+			integrations.POST("/:id/webhook", integrationCtrl.GitWebhook)
+			integrations.POST("/:id/slack/commands", integrationCtrl.SlackCommand)
+		}
+		v1.GET("/tickets", integrationCtrl.ListTickets)
+
+		// Container injection allowlist endpoints
+		injectionCtrl := NewInjectionController(db.DB, accessCache)
+		allowedImages := v1.Group("/allowed-images")
+		{
+			allowedImages.GET("", injectionCtrl.ListAllowedImages)
+			allowedImages.POST("", injectionCtrl.CreateAllowedImage)
+			allowedImages.DELETE("/:id", injectionCtrl.DeleteAllowedImage)
+		}
+
+		// Image registry endpoints
+		registryCtrl := NewRegistryController(db.DB, accessCache)
+		registries := v1.Group("/registries")
+		{
+			registries.GET("", registryCtrl.ListRegistries)
+			registries.POST("", registryCtrl.CreateRegistry)
+			registries.PUT("/:id", registryCtrl.UpdateRegistry)
+			registries.DELETE("/:id", registryCtrl.DeleteRegistry)
+			registries.POST("/:id/dry-run", registryCtrl.DryRunRegistry)
+		}
+
+		// Backstage plugin endpoints
+		backstageCtrl := NewBackstageController(db.DB, accessCache, os.Getenv("BACKSTAGE_API_URL"))
+		emailCtrl := NewEmailController(db.DB, mailer)
+		backstage := v1.Group("/backstage")
+		{
+			backstage.GET("/entities", backstageCtrl.ListEntities)
+			backstage.GET("/resources", backstageCtrl.LookupResources)
+		}
+
+		// Admin endpoints
+		retentionCtrl := NewRetentionController(db.DB, retentionManager)
+		adminCtrl := NewAdminController(db.DB, licenseClient, controllerStale)
+		usageCtrl := NewUsageReportingController(usageReporter)
+		featureFlagCtrl := NewFeatureFlagController(db.DB, accessCache)
+		logLevelCtrl := NewLogLevelController(primaryDB)
+		passwordPolicyCtrl := NewPasswordPolicyController(db.DB, passwordPolicies)
+		auditCtrl := NewAuditController(db.DB)
+		gdprCtrl := NewGDPRController(db.DB)
+		networkAccessCtrl := NewNetworkAccessController(db.DB, accessCache)
+		jobCtrl := NewJobController(primaryDB)
+		policyCtrl := NewPolicyController(db.DB, policyEngine)
+		validationWebhookCtrl := NewValidationWebhookController(db.DB)
+		announcementCtrl := NewAnnouncementController(db.DB)
+		resourceLockCtrl := NewResourceLockController(db.DB)
+		stuckResourceCtrl := NewStuckResourceController(db.DB)
+		admin := v1.Group("/admin")
+		{
+			admin.GET("/overview", adminCtrl.GetOverview)
+			admin.GET("/security-compliance", adminCtrl.GetSecurityCompliance)
+			admin.GET("/consistency", adminCtrl.GetConsistency)
+			admin.GET("/usage-report", usageCtrl.PreviewUsageReport)
+			admin.GET("/feature-flags", featureFlagCtrl.ListFeatureFlags)
+			admin.PUT("/feature-flags/:key", featureFlagCtrl.UpsertFeatureFlag)
+			admin.DELETE("/feature-flags/:key", featureFlagCtrl.DeleteFeatureFlag)
+			admin.GET("/log-levels", logLevelCtrl.ListLogLevels)
+			admin.PUT("/log-levels/:service", logLevelCtrl.SetLogLevel)
+			admin.DELETE("/log-levels/:service", logLevelCtrl.ClearLogLevel)
+			admin.GET("/retention-policies", retentionCtrl.ListRetentionPolicies)
+			admin.PUT("/retention-policies/:target", retentionCtrl.UpsertRetentionPolicy)
+			admin.POST("/retention-policies/:target/run", retentionCtrl.TriggerArchiveRun)
+			admin.GET("/archive-runs", retentionCtrl.ListArchiveRuns)
+			admin.GET("/password-policy", passwordPolicyCtrl.GetPasswordPolicy)
+			admin.PUT("/password-policy", passwordPolicyCtrl.UpdatePasswordPolicy)
+			admin.GET("/audit-logs", auditCtrl.ListAuditLogs)
+			admin.GET("/audit-logs/verify", auditCtrl.VerifyAuditLogs)
+			admin.GET("/diagnostics", diagnosticsCtrl.GetDiagnostics)
+			if debugEndpoints && !debugLocalOnly {
+				admin.GET("/debug/pprof/*profile", diagnosticsCtrl.GetProfile)
+			}
+			admin.GET("/users/:id/export", gdprCtrl.ExportUserData)
+			admin.POST("/users/:id/erasure", gdprCtrl.StartErasure)
+			admin.POST("/users/:id/erasure/:request_id/confirm", gdprCtrl.ConfirmErasure)
+			admin.DELETE("/users/:id/erasure/:request_id", gdprCtrl.CancelErasure)
+			admin.GET("/network-rules", networkAccessCtrl.ListGlobalRules)
+			admin.POST("/network-rules", networkAccessCtrl.CreateGlobalRule)
+			admin.PUT("/network-rules/:id", networkAccessCtrl.UpdateGlobalRule)
+			admin.DELETE("/network-rules/:id", networkAccessCtrl.DeleteGlobalRule)
+			admin.GET("/backstage-tokens", backstageCtrl.ListBackstageTokens)
+			admin.POST("/backstage-tokens", backstageCtrl.CreateBackstageToken)
+			admin.DELETE("/backstage-tokens/:id", backstageCtrl.DeleteBackstageToken)
+			admin.GET("/email-templates", emailCtrl.ListEmailTemplates)
+			admin.GET("/email-templates/:name", emailCtrl.GetEmailTemplate)
+			admin.PUT("/email-templates/:name", emailCtrl.UpdateEmailTemplate)
+			admin.DELETE("/email-templates/:name", emailCtrl.DeleteEmailTemplate)
+			admin.POST("/email-templates/:name/test", emailCtrl.TestEmailTemplate)
+			admin.GET("/jobs", jobCtrl.ListJobs)
+			admin.GET("/jobs/:id", jobCtrl.GetJob)
+			admin.POST("/jobs/:id/requeue", jobCtrl.RequeueJob)
+			admin.DELETE("/jobs/:id", jobCtrl.DiscardJob)
+			admin.GET("/policies", policyCtrl.ListPolicies)
+			admin.POST("/policies", policyCtrl.CreatePolicy)
+			admin.POST("/policies/evaluate", policyCtrl.EvaluatePolicies)
+			admin.GET("/policies/:id", policyCtrl.GetPolicy)
+			admin.PUT("/policies/:id", policyCtrl.UpdatePolicy)
+			admin.DELETE("/policies/:id", policyCtrl.DeletePolicy)
+			admin.GET("/validation-webhooks", validationWebhookCtrl.ListValidationWebhooks)
+			admin.POST("/validation-webhooks", validationWebhookCtrl.CreateValidationWebhook)
+			admin.PUT("/validation-webhooks/:id", validationWebhookCtrl.UpdateValidationWebhook)
+			admin.DELETE("/validation-webhooks/:id", validationWebhookCtrl.DeleteValidationWebhook)
+			admin.GET("/announcements", announcementCtrl.ListAnnouncements)
+			admin.POST("/announcements", announcementCtrl.CreateAnnouncement)
+			admin.PUT("/announcements/:id", announcementCtrl.UpdateAnnouncement)
+			admin.DELETE("/announcements/:id", announcementCtrl.DeleteAnnouncement)
+			admin.GET("/resource-locks", resourceLockCtrl.ListResourceLocks)
+			admin.DELETE("/resource-locks/:resource_id", resourceLockCtrl.ForceUnlockResource)
+			admin.GET("/stuck-resources", stuckResourceCtrl.ListStuckResources)
+			if trustDomain != "" {
+				workloadCtrl := NewWorkloadIdentityController(db.DB, trustDomain)
+				admin.GET("/workload-identities", workloadCtrl.ListWorkloadIdentities)
+				admin.POST("/workload-identities", workloadCtrl.CreateWorkloadIdentity)
+				admin.DELETE("/workload-identities/:id", workloadCtrl.DeleteWorkloadIdentity)
+			}
+			if tenantRouter != nil {
+				tenancyCtrl := NewTenancyController(primaryDB, tenantRouter)
+				admin.GET("/tenants", tenancyCtrl.ListTenants)
+				admin.POST("/tenants", tenancyCtrl.CreateTenant)
+			}
+		}
+
+		// Caller profile and access summary
+		meCtrl := NewMeController(db.DB, fg, passwordPolicies)
+		v1.GET("/me", meCtrl.GetMe)
+
+		// Preferences and saved views of the calling user
+		prefCtrl := NewPreferenceController(db.DB)
+		v1.GET("/me/preferences", prefCtrl.ListPreferences)
+		v1.GET("/me/preferences/:key", prefCtrl.GetPreference)
+		v1.PUT("/me/preferences/:key", prefCtrl.SetPreference)
+		v1.DELETE("/me/preferences/:key", prefCtrl.DeletePreference)
+		v1.GET("/me/views", prefCtrl.ListSavedViews)
+		v1.POST("/me/views", prefCtrl.CreateSavedView)
+		v1.PUT("/me/views/:id", prefCtrl.UpdateSavedView)
+		v1.DELETE("/me/views/:id", prefCtrl.DeleteSavedView)
+
+		// Active announcements, for banners
+		v1.GET("/announcements", announcementCtrl.ListActiveAnnouncements)
+		v1.POST("/announcements/:id/acknowledge", announcementCtrl.AcknowledgeAnnouncement)
+
+		// Permission debugging endpoints
+		permissionsCtrl := NewPermissionsController(db.DB, accessCache, fg)
+		v1.GET("/debug/permissions", permissionsCtrl.ExplainPermissions)
+
+		// Controller fleet endpoints
+		fleetCtrl := NewFleetController(db.DB, controllerStale)
+		v1.GET("/controllers", fleetCtrl.ListControllers)
+		v1.POST("/controllers/:instance_id/drain", fleetCtrl.DrainController)
+
+		// nest-agent endpoints for resources outside Kubernetes
+		agentStale := 5 * time.Minute
+		if v := os.Getenv("AGENT_STALE_AFTER"); v != "" {
+			if parsed, err := time.ParseDuration(v); err == nil && parsed > 0 {
+				agentStale = parsed
+			}
+		}
+		agentCtrl := NewAgentController(db.DB, agentStale, jobTimeouts)
+		agents := v1.Group("/agents")
+		{
+			agents.GET("", agentCtrl.ListAgents)
+			agents.DELETE("/:name", agentCtrl.DeleteAgent)
+
+			agentAPI := agents.Group("", internalOnly...)
+			agentAPI.POST("/register", agentCtrl.RegisterAgent)
+			agentAPI.GET("/:name/desired-state", agentCtrl.GetDesiredState)
+			agentAPI.POST("/:name/report", agentCtrl.ReportState)
+		}
+
+		// Docker and Podman hosts for single-host deployments
+		dockerHostCtrl := NewDockerHostController(db.DB)
+		dockerHosts := v1.Group("/docker-hosts")
+		{
+			dockerHosts.GET("", dockerHostCtrl.ListDockerHosts)
+			dockerHosts.POST("", dockerHostCtrl.CreateDockerHost)
+			dockerHosts.PUT("/:id", dockerHostCtrl.UpdateDockerHost)
+			dockerHosts.DELETE("/:id", dockerHostCtrl.DeleteDockerHost)
+		}
+
+		// Cloud accounts managed databases are imported from
+		cloudAccountCtrl := NewCloudAccountController(db.DB)
+		cloudAccounts := v1.Group("/cloud-accounts")
+		{
+			cloudAccounts.GET("", cloudAccountCtrl.ListCloudAccounts)
+			cloudAccounts.POST("", cloudAccountCtrl.CreateCloudAccount)
+			cloudAccounts.PUT("/:id", cloudAccountCtrl.UpdateCloudAccount)
+			cloudAccounts.DELETE("/:id", cloudAccountCtrl.DeleteCloudAccount)
+			cloudAccounts.POST("/:id/sync", cloudAccountCtrl.SyncCloudAccountNow)
+		}
+
+		// Unmanaged in-cluster databases found by the K8s controller
+		adoptionCtrl := NewAdoptionController(db.DB)
+		adoption := v1.Group("/adoption-candidates")
+		{
+			adoption.GET("", adoptionCtrl.ListAdoptionCandidates)
+			adoption.POST("/:id/adopt", adoptionCtrl.AdoptCandidate)
+			adoption.POST("/:id/dismiss", adoptionCtrl.DismissCandidate)
 		}
 
 		// Team endpoints
 		teamsController := controllers.NewTeamsController(db)
+		teamDeletionCtrl := NewTeamDeletionController(db.DB)
+		trustBundleCtrl := NewTrustBundleController(db.DB, accessCache)
+		invitationCtrl := NewInvitationController(db.DB, accessCache, mailer)
+		// Database accounts not seen connected for CREDENTIAL_STALE_AFTER
+		// are listed as unused
+		credentialStaleAfter := 90 * 24 * time.Hour
+		if v := os.Getenv("CREDENTIAL_STALE_AFTER"); v != "" {
+			if parsed, err := time.ParseDuration(v); err == nil && parsed > 0 {
+				credentialStaleAfter = parsed
+			}
+		}
+		credentialUsageCtrl := NewCredentialUsageController(db.DB, accessCache, credentialStaleAfter)
+		v1.POST("/invitations/accept", invitationCtrl.AcceptInvitation)
 		teams := v1.Group("/teams")
 		{
 			teams.GET("", teamsController.ListTeams)
 			teams.POST("", teamsController.CreateTeam)
 			teams.GET("/:id", teamsController.GetTeam)
 			teams.PUT("/:id", teamsController.UpdateTeam)
-			teams.DELETE("/:id", teamsController.DeleteTeam)
+			teams.DELETE("/:id", teamDeletionCtrl.DeleteTeam)
+			teams.GET("/:id/deletion", teamDeletionCtrl.GetDeletionStatus)
+			teams.GET("/:id/environments", environmentCtrl.ListEnvironments)
+			teams.PUT("/:id/environments", environmentCtrl.SetEnvironments)
+			teams.GET("/:id/feature-flags", featureFlagCtrl.ListTeamFeatureFlags)
+			teams.GET("/:id/container-policies", injectionCtrl.ListContainerPolicies)
+			teams.POST("/:id/container-policies", injectionCtrl.CreateContainerPolicy)
+			teams.DELETE("/:id/container-policies/:policy_id", injectionCtrl.DeleteContainerPolicy)
+			teams.GET("/:id/network-rules", networkAccessCtrl.ListTeamRules)
+			teams.POST("/:id/network-rules", networkAccessCtrl.CreateTeamRule)
+			teams.PUT("/:id/network-rules/:rule_id", networkAccessCtrl.UpdateTeamRule)
+			teams.DELETE("/:id/network-rules/:rule_id", networkAccessCtrl.DeleteTeamRule)
+			teams.GET("/:id/trust-bundle", trustBundleCtrl.GetTeamTrustBundle)
+			teams.GET("/:id/unused-accounts", credentialUsageCtrl.ListUnusedAccounts)
 
 			// Team members routes
 			teams.GET("/:id/members", teamsController.ListTeamMembers)
 			teams.POST("/:id/members", teamsController.AddTeamMember)
 			teams.DELETE("/:id/members/:user_id", teamsController.RemoveTeamMember)
+
+			// Team invitations routes
+			teams.GET("/:id/invitations", invitationCtrl.ListInvitations)
+			teams.POST("/:id/invitations", invitationCtrl.CreateInvitation)
+			teams.DELETE("/:id/invitations/:invitation_id", invitationCtrl.DeleteInvitation)
 		}
 	}
 
@@ -178,8 +1072,24 @@ func main() {
 		port = "8080"
 	}
 
-	log.Printf("Starting server on port %s", port)
-	if err := r.Run(":" + port); err != nil {
+	// BIND_ADDRESS restricts the server to one address, such as :: or an
+	// IPv6 address on IPv6-only clusters; by default it listens on every
+	// address of both families
+	addr := net.JoinHostPort(os.Getenv("BIND_ADDRESS"), port)
+
+	log.Printf("Starting server on %s", addr)
+	if tlsConfig != nil {
+		server := &http.Server{
+			Addr:      addr,
+			Handler:   r,
+			TLSConfig: tlsConfig,
+		}
+		if err := server.ListenAndServeTLS("", ""); err != nil {
+			log.Fatal("Failed to start server:", err)
+		}
+		return
+	}
+	if err := r.Run(addr); err != nil {
 		log.Fatal("Failed to start server:", err)
 	}
 }
@@ -188,14 +1098,14 @@ func getStatus(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"status":    "ok",
 		"timestamp": "2025-01-01T00:00:00Z",
-		"version":   os.Getenv("VERSION"),
+		"version":   buildVersion(),
 	})
 }
 
 func getFeatures(c *gin.Context) {
 	fg, err := licensing.GetFeatureGate(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		apierrors.AbortWith(c, apierrors.New(http.StatusInternalServerError, apierrors.CodeInternal, "Failed to load features").Wrap(err))
 		return
 	}
 
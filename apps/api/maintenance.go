@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// configKeyMaintenanceWindow holds the UTC window in which the K8s controller
+// may restart a resource's pods for changed Secrets and ConfigMaps
+const configKeyMaintenanceWindow = "maintenance_window"
+
+// maintenanceDays lists the day names accepted in a maintenance window
+var maintenanceDays = map[string]bool{
+	"*": true, "sun": true, "mon": true, "tue": true, "wed": true, "thu": true, "fri": true, "sat": true,
+}
+
+// validateMaintenanceWindow checks a maintenance window of the form
+// "sat,sun 02:00-04:00" or "* 22:00-02:00". The K8s controller parses the
+// same format; a window that ends before it starts runs past midnight.
+func validateMaintenanceWindow(window string) error {
+	if window == "" {
+		return nil
+	}
+	fields := strings.Fields(window)
+	if len(fields) != 2 {
+		return fmt.Errorf("maintenance window must be \"<days> HH:MM-HH:MM\"")
+	}
+	for _, day := range strings.Split(strings.ToLower(fields[0]), ",") {
+		if !maintenanceDays[day] {
+			return fmt.Errorf("unknown day %q in maintenance window", day)
+		}
+	}
+
+	bounds := strings.Split(fields[1], "-")
+	if len(bounds) != 2 {
+		return fmt.Errorf("maintenance window must be \"<days> HH:MM-HH:MM\"")
+	}
+	for _, bound := range bounds {
+		if _, err := time.Parse("15:04", bound); err != nil {
+			return fmt.Errorf("invalid time %q in maintenance window", bound)
+		}
+	}
+	if bounds[0] == bounds[1] {
+		return fmt.Errorf("maintenance window must not be empty")
+	}
+	return nil
+}
+
+// validateConfigMaintenanceWindow checks Config.maintenance_window when it is
+// set
+func validateConfigMaintenanceWindow(cfg map[string]interface{}) error {
+	raw, ok := cfg[configKeyMaintenanceWindow]
+	if !ok || raw == nil {
+		return nil
+	}
+	window, ok := raw.(string)
+	if !ok {
+		return fmt.Errorf("maintenance_window must be a string")
+	}
+	return validateMaintenanceWindow(window)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/penguintechinc/project-template/shared/apierrors"
+	"github.com/penguintechinc/project-template/shared/licensing"
+	"gorm.io/gorm"
+)
+
+// MeController handles requests about the calling user
+type MeController struct {
+	db        *gorm.DB
+	features  *licensing.FeatureGate
+	passwords *PasswordPolicies
+}
+
+// NewMeController creates a new me controller
+func NewMeController(db *gorm.DB, features *licensing.FeatureGate, passwords *PasswordPolicies) *MeController {
+	return &MeController{db: db, features: features, passwords: passwords}
+}
+
+// GetMe returns the caller's profile, global role, team memberships, the
+// license features the deployment is entitled to, and when their password
+// expires
+// GET /api/v1/me
+func (mc *MeController) GetMe(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "User context not found")
+		return
+	}
+
+	db := tenantDB(c, mc.db)
+	var user User
+	if err := db.First(&user, userID.(uint)).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierrors.Abort(c, http.StatusNotFound, apierrors.CodeNotFound, "User not found")
+		} else {
+			log.Printf("Error retrieving user %d: %v", userID, err)
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to retrieve user")
+		}
+		return
+	}
+
+	memberships, err := listMemberships(db, user.ID)
+	if err != nil {
+		log.Printf("Error listing memberships of user %d: %v", user.ID, err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to retrieve teams")
+		return
+	}
+
+	features := []string{}
+	if mc.features != nil {
+		for name, entitled := range mc.features.GetAllFeatures() {
+			if entitled {
+				features = append(features, name)
+			}
+		}
+		sort.Strings(features)
+	}
+
+	response := MeResponse{
+		User:            user,
+		GlobalRole:      user.Role,
+		GlobalAdmin:     hasMinimumRole(user.Role, "admin"),
+		Memberships:     memberships,
+		LicenseFeatures: features,
+	}
+	if user.PasswordChangedAt != nil {
+		if policy, err := mc.passwords.Policy(c.Request.Context()); err != nil {
+			log.Printf("Error retrieving password policy: %v", err)
+		} else {
+			response.PasswordExpiresAt = policy.ExpiresAt(*user.PasswordChangedAt)
+		}
+	}
+	if tenantID, ok := c.Get("tenant_id"); ok {
+		id := tenantID.(uint)
+		response.TenantID = &id
+	}
+
+	c.JSON(http.StatusOK, response)
+}
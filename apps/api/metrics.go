@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/penguintechinc/project-template/shared/database"
+	"github.com/penguintechinc/project-template/shared/licensing"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"gorm.io/gorm"
+)
+
+// unmatchedRoute labels requests that matched no route, so scans of random
+// paths don't each create a series
+const unmatchedRoute = "unmatched"
+
+// defaultExcludedRoutes are not recorded unless METRICS_EXCLUDE_ROUTES is set
+var defaultExcludedRoutes = []string{"/health", "/metrics"}
+
+// knownMethods are labelled as they are; any other method is labelled OTHER
+var knownMethods = map[string]bool{
+	http.MethodGet: true, http.MethodHead: true, http.MethodPost: true, http.MethodPut: true,
+	http.MethodPatch: true, http.MethodDelete: true, http.MethodOptions: true,
+}
+
+var (
+	// Prometheus metrics
+	requestsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "Total number of HTTP requests",
+		},
+		[]string{"method", "endpoint", "status"},
+	)
+
+	requestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name: "http_request_duration_seconds",
+			Help: "HTTP request duration in seconds",
+		},
+		[]string{"method", "endpoint"},
+	)
+
+	requestsInFlight = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "http_requests_in_flight",
+			Help: "Number of HTTP requests being served",
+		},
+	)
+)
+
+func init() {
+	// Register Prometheus metrics
+	prometheus.MustRegister(requestsTotal)
+	prometheus.MustRegister(requestDuration)
+	prometheus.MustRegister(requestsInFlight)
+	prometheus.MustRegister(licensing.ChecksTotal)
+}
+
+// MetricsMiddleware records the count, duration and concurrency of requests.
+// Requests are labelled by route template, such as /api/v1/resources/:id,
+// rather than by path; excluded routes are not recorded.
+func MetricsMiddleware(excluded []string) gin.HandlerFunc {
+	skip := make(map[string]bool, len(excluded))
+	for _, route := range excluded {
+		if route = strings.TrimSpace(route); route != "" {
+			skip[route] = true
+		}
+	}
+
+	return func(c *gin.Context) {
+		if skip[c.FullPath()] {
+			c.Next()
+			return
+		}
+
+		requestsInFlight.Inc()
+		defer requestsInFlight.Dec()
+		start := time.Now()
+
+		c.Next()
+
+		method := c.Request.Method
+		if !knownMethods[method] {
+			method = "OTHER"
+		}
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		requestDuration.WithLabelValues(method, route).Observe(time.Since(start).Seconds())
+		requestsTotal.WithLabelValues(method, route, strconv.Itoa(c.Writer.Status())).Inc()
+	}
+}
+
+// metricsExcludedRoutes reads METRICS_EXCLUDE_ROUTES, a comma-separated list
+// of route templates, defaulting to the health check and metrics endpoints
+func metricsExcludedRoutes() []string {
+	if v, ok := os.LookupEnv("METRICS_EXCLUDE_ROUTES"); ok {
+		return strings.Split(v, ",")
+	}
+	return defaultExcludedRoutes
+}
+
+// RegisterDatabaseMetrics exports the connection pool statistics of the
+// primary and each read replica, labelled by db_name
+func RegisterDatabaseMetrics(db *database.Database) {
+	for name, pool := range db.Pools() {
+		prometheus.MustRegister(collectors.NewDBStatsCollector(pool, name))
+	}
+}
+
+// TeamMetricsCollector is a Prometheus collector that exposes the number of
+// resources and open alerts of every team. Teams are read at scrape time, so
+// their series come and go with the teams.
+type TeamMetricsCollector struct {
+	db      *gorm.DB
+	timeout time.Duration
+
+	resourcesDesc *prometheus.Desc
+	alertsDesc    *prometheus.Desc
+}
+
+// teamCount is a row of the per-team count queries
+type teamCount struct {
+	TeamID   uint
+	TeamName string
+	Label    string
+	Count    int64
+}
+
+// NewTeamMetricsCollector creates a collector backed by the resources and
+// alerts tables
+func NewTeamMetricsCollector(db *gorm.DB) *TeamMetricsCollector {
+	return &TeamMetricsCollector{
+		db:      db,
+		timeout: 10 * time.Second,
+		resourcesDesc: prometheus.NewDesc(
+			"nest_team_resources",
+			"Number of resources of a team, by status",
+			[]string{"team", "team_id", "status"}, nil,
+		),
+		alertsDesc: prometheus.NewDesc(
+			"nest_team_open_alerts",
+			"Number of pending or firing alerts of a team, by severity",
+			[]string{"team", "team_id", "severity"}, nil,
+		),
+	}
+}
+
+// Describe implements prometheus.Collector
+func (c *TeamMetricsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.resourcesDesc
+	ch <- c.alertsDesc
+}
+
+// Collect implements prometheus.Collector
+func (c *TeamMetricsCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	var resources []teamCount
+	err := c.db.WithContext(ctx).Raw(`
+		SELECT t.id AS team_id, t.name AS team_name, r.status AS label, COUNT(*) AS count
+		FROM resources r
+		JOIN teams t ON t.id = r.team_id
+		WHERE r.deleted_at IS NULL
+		GROUP BY t.id, t.name, r.status`).Scan(&resources).Error
+	if err != nil {
+		log.Printf("Error loading team resource counts for metrics: %v", err)
+	}
+	c.emit(ch, c.resourcesDesc, resources)
+
+	var alerts []teamCount
+	err = c.db.WithContext(ctx).Raw(`
+		SELECT t.id AS team_id, t.name AS team_name, a.severity AS label, COUNT(*) AS count
+		FROM alerts a
+		JOIN teams t ON t.id = a.team_id
+		WHERE a.deleted_at IS NULL AND a.state IN ?
+		GROUP BY t.id, t.name, a.severity`, []string{AlertStatePending, AlertStateFiring}).Scan(&alerts).Error
+	if err != nil {
+		log.Printf("Error loading team alert counts for metrics: %v", err)
+	}
+	c.emit(ch, c.alertsDesc, alerts)
+}
+
+// emit sends a gauge per counted row
+func (c *TeamMetricsCollector) emit(ch chan<- prometheus.Metric, desc *prometheus.Desc, rows []teamCount) {
+	for _, row := range rows {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, float64(row.Count),
+			row.TeamName, strconv.FormatUint(uint64(row.TeamID), 10), row.Label)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/penguintechinc/project-template/apps/api/models"
+	"github.com/penguintechinc/project-template/shared/apierrors"
 )
 
 const (
@@ -27,6 +28,7 @@ type CustomClaims struct {
 	UserID   uint   `json:"user_id"`
 	Username string `json:"username"`
 	Email    string `json:"email"`
+	Tenant   string `json:"tenant,omitempty"`
 	jwt.RegisteredClaims
 }
 
@@ -36,20 +38,14 @@ func AuthMiddleware() gin.HandlerFunc {
 		// Get token from Authorization header
 		authHeader := c.GetHeader(AuthorizationHeader)
 		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"error": "missing authorization header",
-			})
-			c.Abort()
+			apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "Missing authorization header")
 			return
 		}
 
 		// Parse Bearer token
 		parts := strings.SplitN(authHeader, " ", 2)
 		if len(parts) != 2 || parts[0] != BearerScheme {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"error": "invalid authorization header format",
-			})
-			c.Abort()
+			apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "Invalid authorization header format")
 			return
 		}
 
@@ -70,10 +66,7 @@ func AuthMiddleware() gin.HandlerFunc {
 		})
 
 		if err != nil || !token.Valid {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"error": "invalid or expired token",
-			})
-			c.Abort()
+			apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "Invalid or expired token")
 			return
 		}
 
@@ -82,6 +75,7 @@ func AuthMiddleware() gin.HandlerFunc {
 			UserID:   claims.UserID,
 			Username: claims.Username,
 			Email:    claims.Email,
+			Tenant:   claims.Tenant,
 		}
 
 		// Store user in context
@@ -132,6 +126,7 @@ func OptionalAuthMiddleware() gin.HandlerFunc {
 			UserID:   claims.UserID,
 			Username: claims.Username,
 			Email:    claims.Email,
+			Tenant:   claims.Tenant,
 		}
 
 		// Store user in context
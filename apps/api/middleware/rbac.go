@@ -1,12 +1,18 @@
 package middleware
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"log"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/penguintechinc/project-template/shared/apierrors"
+	"gorm.io/datatypes"
 	"gorm.io/gorm"
 )
 
@@ -32,10 +38,26 @@ const (
 
 // Context keys
 const (
-	UserIDKey   = "user_id"
-	UserRoleKey = "user_role"
+	UserIDKey       = "user_id"
+	UserRoleKey     = "user_role"
+	AccessClaimsKey = "access_claims"
+	ImpersonatorKey = "impersonator_id"
 )
 
+// Impersonation headers
+const (
+	// ImpersonateUserHeader names the user a global admin acts as
+	ImpersonateUserHeader = "X-Impersonate-User"
+
+	// ImpersonatedUserHeader and ImpersonatorHeader are set on responses to
+	// impersonated requests
+	ImpersonatedUserHeader = "X-Impersonated-User"
+	ImpersonatorHeader     = "X-Impersonator"
+)
+
+// AuditActionImpersonate is the audit log action of impersonated requests
+const AuditActionImpersonate = "impersonate"
+
 // Database models
 
 // BaseModel provides common fields for all models
@@ -91,9 +113,84 @@ type Resource struct {
 	Team        Team   `gorm:"foreignKey:TeamID" json:"team,omitempty"`
 }
 
+// AuditLog is an entry in the audit log shared with the API
+type AuditLog struct {
+	ID           uint           `gorm:"primaryKey" json:"id"`
+	UserID       *uint          `gorm:"index" json:"user_id,omitempty"`
+	Action       string         `gorm:"not null;size:100" json:"action"`
+	ResourceType string         `gorm:"size:100" json:"resource_type"`
+	ResourceID   *uint          `gorm:"index" json:"resource_id,omitempty"`
+	Details      datatypes.JSON `gorm:"type:jsonb" json:"details,omitempty"`
+	IPAddress    string         `gorm:"size:45" json:"ip_address"`
+	UserAgent    string         `gorm:"type:text" json:"user_agent,omitempty"`
+	Timestamp    time.Time      `gorm:"index" json:"timestamp"`
+}
+
+// TableName specifies the table name for AuditLog
+func (AuditLog) TableName() string {
+	return "audit_logs"
+}
+
+// cacheNSClaims is the cache namespace for resolved access claims
+const cacheNSClaims = "rbac_claims"
+
+// Cache is the subset of database.Cache used for RBAC lookups
+type Cache interface {
+	Get(ctx context.Context, key string, dest interface{}) (bool, error)
+	Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error
+}
+
+// CacheInvalidationTables maps RBAC tables to the cache namespaces derived
+// from them, for use with database.RegisterCacheInvalidation
+var CacheInvalidationTables = map[string][]string{
+	"users":            {cacheNSClaims},
+	"team_memberships": {cacheNSClaims},
+}
+
+// AccessClaims is a user's resolved identity: their global role and role in
+// every team. It is loaded once per request by RequireAuth and shared by all
+// downstream permission checks through the Gin context.
+type AccessClaims struct {
+	UserID     uint            `json:"user_id"`
+	GlobalRole string          `json:"global_role"`
+	IsActive   bool            `json:"is_active"`
+	TeamRoles  map[uint]string `json:"team_roles"`
+}
+
+// Roles returns the global role (if any) followed by every team role
+func (a *AccessClaims) Roles() []string {
+	roles := make([]string, 0, len(a.TeamRoles)+1)
+	if a.GlobalRole != "" {
+		roles = append(roles, a.GlobalRole)
+	}
+	for _, role := range a.TeamRoles {
+		roles = append(roles, role)
+	}
+	return roles
+}
+
+// TeamRole returns the user's role in a team and whether they are a member
+func (a *AccessClaims) TeamRole(teamID uint) (string, bool) {
+	role, ok := a.TeamRoles[teamID]
+	return role, ok
+}
+
+// GetAccessClaims retrieves the access claims stored by RequireAuth
+func GetAccessClaims(c *gin.Context) (*AccessClaims, bool) {
+	value, exists := c.Get(AccessClaimsKey)
+	if !exists {
+		return nil, false
+	}
+	claims, ok := value.(*AccessClaims)
+	return claims, ok
+}
+
 // RBACMiddleware holds the database connection for RBAC operations
 type RBACMiddleware struct {
-	db *gorm.DB
+	db            *gorm.DB
+	cache         Cache
+	cacheTTL      time.Duration
+	impersonation bool
 }
 
 // NewRBACMiddleware creates a new RBAC middleware instance
@@ -101,6 +198,20 @@ func NewRBACMiddleware(db *gorm.DB) *RBACMiddleware {
 	return &RBACMiddleware{db: db}
 }
 
+// WithCache enables caching of resolved access claims across requests
+func (r *RBACMiddleware) WithCache(cache Cache, ttl time.Duration) *RBACMiddleware {
+	r.cache = cache
+	r.cacheTTL = ttl
+	return r
+}
+
+// WithImpersonation lets global admins act as another user by sending
+// X-Impersonate-User. Every impersonated request is recorded in the audit log.
+func (r *RBACMiddleware) WithImpersonation(enabled bool) *RBACMiddleware {
+	r.impersonation = enabled
+	return r
+}
+
 // RequireAuth checks if the user is authenticated
 // Sets user_id in the context if authentication succeeds
 func (r *RBACMiddleware) RequireAuth() gin.HandlerFunc {
@@ -109,70 +220,133 @@ func (r *RBACMiddleware) RequireAuth() gin.HandlerFunc {
 		// This is a placeholder - implement your actual authentication logic
 		userIDStr := c.GetHeader("X-User-ID")
 		if userIDStr == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"error": "Authentication required",
-			})
-			c.Abort()
+			apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "Authentication required")
 			return
 		}
 
 		userID, err := strconv.ParseUint(userIDStr, 10, 32)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"error": "Invalid user ID",
-			})
-			c.Abort()
+			apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "Invalid user ID")
 			return
 		}
 
-		// Verify user exists and is active
-		var user User
-		if err := r.db.First(&user, userID).Error; err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"error": "User not found",
-			})
-			c.Abort()
+		// Verify user exists and is active, loading their memberships at the
+		// same time for downstream checks
+		claims, err := r.resolveClaims(c.Request.Context(), uint(userID))
+		if err != nil {
+			apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "User not found")
 			return
 		}
 
-		if !user.IsActive {
-			c.JSON(http.StatusForbidden, gin.H{
-				"error": "User account is inactive",
-			})
-			c.Abort()
+		if !claims.IsActive {
+			apierrors.Abort(c, http.StatusForbidden, apierrors.CodeForbidden, "User account is inactive")
 			return
 		}
 
-		// Set user ID and role in context
-		c.Set(UserIDKey, uint(userID))
-		c.Set(UserRoleKey, user.GlobalRole)
+		if target := c.GetHeader(ImpersonateUserHeader); target != "" {
+			impersonated, ok := r.impersonate(c, claims, target)
+			if !ok {
+				return
+			}
+			claims = impersonated
+		}
+
+		// Set user ID, role, and claims in context
+		c.Set(UserIDKey, claims.UserID)
+		c.Set(UserRoleKey, claims.GlobalRole)
+		c.Set(AccessClaimsKey, claims)
 		c.Next()
 	}
 }
 
+// impersonate swaps the claims of a global admin for those of the user they
+// impersonate, after recording the request in the audit log. It writes an
+// error response and returns false when impersonation is not allowed.
+func (r *RBACMiddleware) impersonate(c *gin.Context, admin *AccessClaims, target string) (*AccessClaims, bool) {
+	if !r.impersonation {
+		apierrors.Abort(c, http.StatusForbidden, "impersonation_disabled", "Impersonation is not enabled")
+		return nil, false
+	}
+	if admin.GlobalRole != GlobalAdmin {
+		apierrors.Abort(c, http.StatusForbidden, apierrors.CodeForbidden, "Global admin access required")
+		return nil, false
+	}
+
+	targetID, err := strconv.ParseUint(target, 10, 32)
+	if err != nil {
+		apierrors.Abort(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Invalid user ID")
+		return nil, false
+	}
+	claims, err := r.resolveClaims(c.Request.Context(), uint(targetID))
+	if err != nil {
+		apierrors.Abort(c, http.StatusNotFound, apierrors.CodeNotFound, "User not found")
+		return nil, false
+	}
+	if claims.GlobalRole == GlobalAdmin {
+		apierrors.Abort(c, http.StatusForbidden, apierrors.CodeForbidden, "Global admins cannot be impersonated")
+		return nil, false
+	}
+	if !claims.IsActive {
+		apierrors.Abort(c, http.StatusForbidden, apierrors.CodeForbidden, "User account is inactive")
+		return nil, false
+	}
+
+	details, _ := json.Marshal(map[string]interface{}{
+		"impersonated_user_id": claims.UserID,
+		"method":               c.Request.Method,
+		"path":                 c.Request.URL.Path,
+		"request_id":           apierrors.RequestID(c),
+	})
+	entry := AuditLog{
+		UserID:       &admin.UserID,
+		Action:       AuditActionImpersonate,
+		ResourceType: "user",
+		ResourceID:   &claims.UserID,
+		Details:      datatypes.JSON(details),
+		IPAddress:    c.ClientIP(),
+		UserAgent:    c.Request.UserAgent(),
+		Timestamp:    time.Now().UTC(),
+	}
+	if err := r.db.WithContext(c.Request.Context()).Create(&entry).Error; err != nil {
+		log.Printf("Error recording impersonation of user %d by %d: %v", claims.UserID, admin.UserID, err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeInternal, "Failed to record impersonation")
+		return nil, false
+	}
+
+	c.Header(ImpersonatedUserHeader, strconv.FormatUint(uint64(claims.UserID), 10))
+	c.Header(ImpersonatorHeader, strconv.FormatUint(uint64(admin.UserID), 10))
+	c.Set(ImpersonatorKey, admin.UserID)
+	return claims, true
+}
+
+// GetImpersonator returns the ID of the global admin impersonating the
+// current user, if any
+func GetImpersonator(c *gin.Context) (uint, bool) {
+	value, exists := c.Get(ImpersonatorKey)
+	if !exists {
+		return 0, false
+	}
+	id, ok := value.(uint)
+	return id, ok
+}
+
 // RequireRole requires the user to have one of the specified roles
 func (r *RBACMiddleware) RequireRole(roles ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := c.Get(UserIDKey)
 		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"error": "Authentication required",
-			})
-			c.Abort()
+			apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "Authentication required")
 			return
 		}
 
-		uid := userID.(uint)
-		userRoles, err := r.getUserRoles(uid)
+		claims, err := r.requestClaims(c, userID.(uint))
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": "Failed to retrieve user roles",
-			})
-			c.Abort()
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeInternal, "Failed to retrieve user roles")
 			return
 		}
 
 		// Check if user has any of the required roles
+		userRoles := claims.Roles()
 		for _, requiredRole := range roles {
 			for _, userRole := range userRoles {
 				if userRole == requiredRole {
@@ -182,10 +356,7 @@ func (r *RBACMiddleware) RequireRole(roles ...string) gin.HandlerFunc {
 			}
 		}
 
-		c.JSON(http.StatusForbidden, gin.H{
-			"error": "Insufficient permissions",
-		})
-		c.Abort()
+		apierrors.Abort(c, http.StatusForbidden, apierrors.CodeForbidden, "Insufficient permissions")
 	}
 }
 
@@ -195,46 +366,30 @@ func (r *RBACMiddleware) RequireTeamAccess(permission string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := c.Get(UserIDKey)
 		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"error": "Authentication required",
-			})
-			c.Abort()
+			apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "Authentication required")
 			return
 		}
 
 		teamIDStr := c.Param("team_id")
 		if teamIDStr == "" {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error": "Team ID required",
-			})
-			c.Abort()
+			apierrors.Abort(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Team ID required")
 			return
 		}
 
 		teamID, err := strconv.ParseUint(teamIDStr, 10, 32)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error": "Invalid team ID",
-			})
-			c.Abort()
+			apierrors.Abort(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Invalid team ID")
 			return
 		}
 
-		uid := userID.(uint)
-		hasAccess, err := r.checkTeamPermission(uid, uint(teamID), permission)
+		claims, err := r.requestClaims(c, userID.(uint))
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": "Failed to check permissions",
-			})
-			c.Abort()
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeInternal, "Failed to check permissions")
 			return
 		}
 
-		if !hasAccess {
-			c.JSON(http.StatusForbidden, gin.H{
-				"error": "Insufficient team permissions",
-			})
-			c.Abort()
+		if !r.claimsHaveTeamPermission(claims, uint(teamID), permission) {
+			apierrors.Abort(c, http.StatusForbidden, apierrors.CodeForbidden, "Insufficient team permissions")
 			return
 		}
 
@@ -248,46 +403,36 @@ func (r *RBACMiddleware) CheckResourceAccess(permission string) gin.HandlerFunc
 	return func(c *gin.Context) {
 		userID, exists := c.Get(UserIDKey)
 		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"error": "Authentication required",
-			})
-			c.Abort()
+			apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "Authentication required")
 			return
 		}
 
 		resourceIDStr := c.Param("resource_id")
 		if resourceIDStr == "" {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error": "Resource ID required",
-			})
-			c.Abort()
+			apierrors.Abort(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Resource ID required")
 			return
 		}
 
 		resourceID, err := strconv.ParseUint(resourceIDStr, 10, 32)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error": "Invalid resource ID",
-			})
-			c.Abort()
+			apierrors.Abort(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Invalid resource ID")
+			return
+		}
+
+		claims, err := r.requestClaims(c, userID.(uint))
+		if err != nil {
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeInternal, "Failed to check resource access")
 			return
 		}
 
-		uid := userID.(uint)
-		hasAccess, err := r.canAccessResource(uid, uint(resourceID), permission)
+		hasAccess, err := r.canAccessResource(claims, uint(resourceID), permission)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": "Failed to check resource access",
-			})
-			c.Abort()
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeInternal, "Failed to check resource access")
 			return
 		}
 
 		if !hasAccess {
-			c.JSON(http.StatusForbidden, gin.H{
-				"error": "Insufficient permissions to access this resource",
-			})
-			c.Abort()
+			apierrors.Abort(c, http.StatusForbidden, apierrors.CodeForbidden, "Insufficient permissions to access this resource")
 			return
 		}
 
@@ -297,91 +442,92 @@ func (r *RBACMiddleware) CheckResourceAccess(permission string) gin.HandlerFunc
 
 // Helper functions
 
-// getUserRoles retrieves all roles for a user (global + team roles)
-func (r *RBACMiddleware) getUserRoles(userID uint) ([]string, error) {
-	roles := make([]string, 0)
-
-	// Get user's global role
-	var user User
-	if err := r.db.First(&user, userID).Error; err != nil {
-		return nil, err
-	}
+// claimRow is one row of the claims query: the user joined with one membership
+type claimRow struct {
+	UserID     uint
+	GlobalRole string
+	IsActive   bool
+	TeamID     *uint
+	Role       *string
+}
 
-	if user.GlobalRole != "" {
-		roles = append(roles, user.GlobalRole)
+// resolveClaims loads the user and all of their memberships in a single
+// query, from the cache when enabled. It returns gorm.ErrRecordNotFound when
+// the user does not exist.
+func (r *RBACMiddleware) resolveClaims(ctx context.Context, userID uint) (*AccessClaims, error) {
+	key := fmt.Sprintf("%s:%d", cacheNSClaims, userID)
+	if r.cache != nil {
+		var cached AccessClaims
+		if found, err := r.cache.Get(ctx, key, &cached); err == nil && found {
+			return &cached, nil
+		}
 	}
 
-	// Get user's team roles
-	var memberships []TeamMembership
-	if err := r.db.Where("user_id = ?", userID).Find(&memberships).Error; err != nil {
+	var rows []claimRow
+	if err := r.db.WithContext(ctx).Table("users").
+		Select("users.id AS user_id, users.global_role, users.is_active, team_memberships.team_id, team_memberships.role").
+		Joins("LEFT JOIN team_memberships ON team_memberships.user_id = users.id AND team_memberships.deleted_at IS NULL").
+		Where("users.id = ? AND users.deleted_at IS NULL", userID).
+		Scan(&rows).Error; err != nil {
 		return nil, err
 	}
-
-	for _, membership := range memberships {
-		roles = append(roles, membership.Role)
+	if len(rows) == 0 {
+		return nil, gorm.ErrRecordNotFound
 	}
 
-	return roles, nil
-}
-
-// hasGlobalRole checks if user has a specific global role
-func (r *RBACMiddleware) hasGlobalRole(userID uint, role string) (bool, error) {
-	var user User
-	if err := r.db.First(&user, userID).Error; err != nil {
-		return false, err
+	claims := &AccessClaims{
+		UserID:     rows[0].UserID,
+		GlobalRole: rows[0].GlobalRole,
+		IsActive:   rows[0].IsActive,
+		TeamRoles:  make(map[uint]string),
+	}
+	for _, row := range rows {
+		if row.TeamID != nil && row.Role != nil {
+			claims.TeamRoles[*row.TeamID] = *row.Role
+		}
 	}
 
-	return user.GlobalRole == role, nil
+	if r.cache != nil {
+		r.cache.Set(ctx, key, claims, r.cacheTTL)
+	}
+	return claims, nil
 }
 
-// hasTeamRole checks if user has a specific role in a team
-func (r *RBACMiddleware) hasTeamRole(userID uint, teamID uint, role string) (bool, error) {
-	var membership TeamMembership
-	err := r.db.Where("user_id = ? AND team_id = ? AND role = ?", userID, teamID, role).
-		First(&membership).Error
+// requestClaims returns the claims RequireAuth stored for this request,
+// resolving them only if the middleware chain did not already do so
+func (r *RBACMiddleware) requestClaims(c *gin.Context, userID uint) (*AccessClaims, error) {
+	if claims, ok := GetAccessClaims(c); ok && claims.UserID == userID {
+		return claims, nil
+	}
 
+	claims, err := r.resolveClaims(c.Request.Context(), userID)
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return false, nil
-		}
-		return false, err
+		return nil, err
 	}
-
-	return true, nil
+	c.Set(AccessClaimsKey, claims)
+	return claims, nil
 }
 
-// checkTeamPermission checks if user has permission to perform action on team
-func (r *RBACMiddleware) checkTeamPermission(userID uint, teamID uint, permission string) (bool, error) {
-	// Check if user has global admin role (can access all teams)
-	hasGlobalAdmin, err := r.hasGlobalRole(userID, GlobalAdmin)
-	if err != nil {
-		return false, err
-	}
-	if hasGlobalAdmin {
-		return true, nil
+// claimsHaveTeamPermission applies the permission matrix to resolved claims
+func (r *RBACMiddleware) claimsHaveTeamPermission(claims *AccessClaims, teamID uint, permission string) bool {
+	// Global admins can access all teams
+	if claims.GlobalRole == GlobalAdmin {
+		return true
 	}
 
-	// Check if user has global viewer role (read-only access to all teams)
-	hasGlobalViewer, err := r.hasGlobalRole(userID, GlobalViewer)
-	if err != nil {
-		return false, err
-	}
-	if hasGlobalViewer && permission == PermissionRead {
-		return true, nil
+	// Global viewers have read-only access to all teams
+	if claims.GlobalRole == GlobalViewer && permission == PermissionRead {
+		return true
 	}
 
 	// Check team-specific role
-	var membership TeamMembership
-	err = r.db.Where("user_id = ? AND team_id = ?", userID, teamID).First(&membership).Error
-	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return false, nil
-		}
-		return false, err
+	role, ok := claims.TeamRole(teamID)
+	if !ok {
+		return false
 	}
 
 	// Apply permission matrix
-	return r.hasPermission(membership.Role, permission), nil
+	return r.hasPermission(role, permission)
 }
 
 // hasPermission checks if a role has a specific permission
@@ -414,7 +560,7 @@ func (r *RBACMiddleware) hasPermission(role string, permission string) bool {
 }
 
 // canAccessResource verifies if user can access a specific resource
-func (r *RBACMiddleware) canAccessResource(userID uint, resourceID uint, permission string) (bool, error) {
+func (r *RBACMiddleware) canAccessResource(claims *AccessClaims, resourceID uint, permission string) (bool, error) {
 	// Get resource to find its team
 	var resource Resource
 	if err := r.db.First(&resource, resourceID).Error; err != nil {
@@ -425,18 +571,13 @@ func (r *RBACMiddleware) canAccessResource(userID uint, resourceID uint, permiss
 	}
 
 	// Check team permission for the resource's team
-	return r.checkTeamPermission(userID, resource.TeamID, permission)
+	return r.claimsHaveTeamPermission(claims, resource.TeamID, permission), nil
 }
 
 // GetUserTeams returns all teams the user has access to
 func (r *RBACMiddleware) GetUserTeams(userID uint) ([]Team, error) {
 	// Check if user has global role
-	hasGlobalAdmin, err := r.hasGlobalRole(userID, GlobalAdmin)
-	if err != nil {
-		return nil, err
-	}
-
-	hasGlobalViewer, err := r.hasGlobalRole(userID, GlobalViewer)
+	claims, err := r.resolveClaims(context.Background(), userID)
 	if err != nil {
 		return nil, err
 	}
@@ -444,7 +585,7 @@ func (r *RBACMiddleware) GetUserTeams(userID uint) ([]Team, error) {
 	var teams []Team
 
 	// Global roles can access all teams
-	if hasGlobalAdmin || hasGlobalViewer {
+	if claims.GlobalRole == GlobalAdmin || claims.GlobalRole == GlobalViewer {
 		if err := r.db.Find(&teams).Error; err != nil {
 			return nil, err
 		}
@@ -466,20 +607,22 @@ func (r *RBACMiddleware) GetUserTeams(userID uint) ([]Team, error) {
 
 // CanManageTeamMembers checks if user can manage team members
 func (r *RBACMiddleware) CanManageTeamMembers(userID uint, teamID uint) (bool, error) {
-	// Check if user has global admin role
-	hasGlobalAdmin, err := r.hasGlobalRole(userID, GlobalAdmin)
+	claims, err := r.resolveClaims(context.Background(), userID)
 	if err != nil {
 		return false, err
 	}
-	if hasGlobalAdmin {
+
+	// Global admins can manage any team
+	if claims.GlobalRole == GlobalAdmin {
 		return true, nil
 	}
 
 	// Only team admins can manage members
-	return r.hasTeamRole(userID, teamID, TeamAdmin)
+	role, ok := claims.TeamRole(teamID)
+	return ok && role == TeamAdmin, nil
 }
 
 // Migrate runs database migrations for RBAC models
 func (r *RBACMiddleware) Migrate() error {
-	return r.db.AutoMigrate(&User{}, &Team{}, &TeamMembership{}, &Resource{})
+	return r.db.AutoMigrate(&User{}, &Team{}, &TeamMembership{}, &Resource{}, &AuditLog{})
 }
@@ -4,6 +4,8 @@ import (
 	"database/sql"
 	"time"
 
+	"github.com/penguintechinc/project-template/shared/database"
+	"github.com/penguintechinc/project-template/shared/diagnostics"
 	"gorm.io/datatypes"
 	"gorm.io/gorm"
 )
@@ -46,6 +48,7 @@ type Resource struct {
 	ResourceType       *ResourceType  `gorm:"foreignKey:ResourceTypeID" json:"resource_type,omitempty"`
 	TeamID             uint           `gorm:"not null;index" json:"team_id"`
 	Team               *Team          `gorm:"foreignKey:TeamID" json:"team,omitempty"`
+	Environment        string         `gorm:"not null;default:'dev';index" json:"environment"`
 	Status             string         `gorm:"default:'pending'" json:"status"`
 	LifecycleMode      string         `gorm:"not null" json:"lifecycle_mode"`
 	ProvisioningMethod string         `json:"provisioning_method"`
@@ -62,14 +65,91 @@ type Resource struct {
 	CanBackup          bool           `gorm:"default:false" json:"can_backup"`
 	CanScale           bool           `gorm:"default:false" json:"can_scale"`
 	CreatedBy          uint           `json:"created_by"`
+
+	// Reconcile errors reported by the K8s controller, cleared on success
+	LastError           string     `json:"last_error,omitempty"`
+	LastErrorAt         *time.Time `json:"last_error_at,omitempty"`
+	ConsecutiveFailures int        `gorm:"default:0" json:"consecutive_failures"`
+
+	// Deletion lifecycle: DeletionState advances deleting -> deprovisioned ->
+	// purged once the restore window passes, and waits on Finalizers held by
+	// the K8s controller until its Kubernetes objects are removed
+	DeletionProtection bool           `gorm:"default:false" json:"deletion_protection"`
+	DeletionState      string         `gorm:"not null;default:'';index" json:"deletion_state,omitempty"`
+	Finalizers         datatypes.JSON `gorm:"type:jsonb" json:"finalizers,omitempty"`
+
+	// Hardening gaps the K8s controller found in the live pod spec
+	SecurityFindings datatypes.JSON `gorm:"type:jsonb" json:"security_findings,omitempty"`
+
+	// When the credentials were last set, for the password policy's
+	// maximum age
+	CredentialsChangedAt *time.Time `json:"credentials_changed_at,omitempty"`
+
+	// Size class the resource was created or last resized with; "custom"
+	// when its requests were set directly in Config.resources
+	SizeClass string `gorm:"index" json:"size_class,omitempty"`
+
+	// Priority class the K8s controller reconciles the resource with; empty
+	// for the class of its environment
+	PriorityClass string `json:"priority_class,omitempty"`
+
+	// Set by the K8s controller while a restart for changed Secrets or
+	// ConfigMaps waits for the resource's maintenance window
+	PendingRestartSince *time.Time `json:"pending_restart_since,omitempty"`
+
+	// The nest-agent managing the resource on a host outside Kubernetes
+	AgentID *uint `gorm:"index" json:"agent_id,omitempty"`
+
+	// Host accounts team maintainers may request SSH certificates for on
+	// the agent's host, set by team admins; other logins need a team admin
+	SSHLogins datatypes.JSON `gorm:"type:jsonb" json:"ssh_logins,omitempty"`
+
+	// The Docker or Podman host the K8s controller provisions the resource
+	// on as a container, instead of in the cluster
+	DockerHostID *uint `gorm:"index" json:"docker_host_id,omitempty"`
+
+	// The cloud account and instance a resource was imported from, such as
+	// an RDS instance identifier or a Cloud SQL instance name
+	CloudAccountID  *uint  `gorm:"index" json:"cloud_account_id,omitempty"`
+	CloudInstanceID string `gorm:"index" json:"cloud_instance_id,omitempty"`
+
+	// Config of the manifest last applied through POST /api/v1/apply, the
+	// base of the three-way merge of the next apply
+	AppliedConfig datatypes.JSON `gorm:"type:jsonb" json:"-"`
+
+	// Violations of warning policies found when the resource was last
+	// created, updated or reconciled
+	PolicyViolations datatypes.JSON `gorm:"type:jsonb" json:"policy_violations,omitempty"`
+
+	// Generation counts changes of the resource's spec, and
+	// ObservedGeneration is the generation the K8s controller last
+	// reconciled successfully; the controller has acted on the latest
+	// change once they are equal
+	Generation         int64 `gorm:"not null;default:1" json:"generation"`
+	ObservedGeneration int64 `gorm:"not null;default:0" json:"observed_generation"`
+
+	// Ready, Progressing, BackupHealthy and CertValid conditions maintained
+	// by the K8s controller, alongside Status
+	Conditions datatypes.JSON `gorm:"type:jsonb" json:"conditions,omitempty"`
+}
+
+// ResourceCondition is an aspect of a resource's state, following the
+// Kubernetes conventions: Status is True, False or Unknown, and
+// LastTransitionTime changes only with it
+type ResourceCondition struct {
+	Type               string    `json:"type" binding:"required"`
+	Status             string    `json:"status" binding:"required,oneof=True False Unknown"`
+	Reason             string    `json:"reason"`
+	Message            string    `json:"message,omitempty"`
+	LastTransitionTime time.Time `json:"last_transition_time"`
 }
 
 // ResourceStats represents statistics for a resource
 type ResourceStats struct {
 	BaseModel
-	ResourceID  uint           `gorm:"not null;index" json:"resource_id"`
+	ResourceID  uint           `gorm:"not null;index;index:idx_resource_stats_resource_time,priority:1" json:"resource_id"`
 	Resource    *Resource      `gorm:"foreignKey:ResourceID" json:"resource,omitempty"`
-	Timestamp   time.Time      `gorm:"not null;index" json:"timestamp"`
+	Timestamp   time.Time      `gorm:"not null;index;index:idx_resource_stats_resource_time,priority:2" json:"timestamp"`
 	Metrics     datatypes.JSON `gorm:"type:jsonb" json:"metrics"`
 	RiskLevel   string         `json:"risk_level"`
 	RiskFactors datatypes.JSON `gorm:"type:jsonb" json:"risk_factors"`
@@ -85,6 +165,800 @@ func (ResourceStats) TableName() string {
 	return "resource_stats"
 }
 
+// AlertRule defines a threshold condition evaluated against collected resource metrics.
+// A rule without a ResourceID applies to every resource owned by the team.
+type AlertRule struct {
+	BaseModel
+	Name            string    `gorm:"not null" json:"name"`
+	Description     string    `json:"description"`
+	TeamID          uint      `gorm:"not null;index" json:"team_id"`
+	ResourceID      *uint     `gorm:"index" json:"resource_id,omitempty"`
+	Resource        *Resource `gorm:"foreignKey:ResourceID" json:"resource,omitempty"`
+	Metric          string    `gorm:"not null" json:"metric"`
+	Operator        string    `gorm:"not null;default:'gt'" json:"operator"`
+	Threshold       float64   `json:"threshold"`
+	DurationSeconds int       `gorm:"default:0" json:"duration_seconds"`
+	Severity        string    `gorm:"not null;default:'warning'" json:"severity"`
+	Enabled         bool      `gorm:"default:true" json:"enabled"`
+	CreatedBy       uint      `json:"created_by"`
+}
+
+// Alert tracks the state of an alert rule for a single resource
+type Alert struct {
+	BaseModel
+	AlertRuleID     uint       `gorm:"not null;index" json:"alert_rule_id"`
+	AlertRule       *AlertRule `gorm:"foreignKey:AlertRuleID" json:"alert_rule,omitempty"`
+	ResourceID      uint       `gorm:"not null;index" json:"resource_id"`
+	TeamID          uint       `gorm:"not null;index" json:"team_id"`
+	State           string     `gorm:"not null;index" json:"state"`
+	Severity        string     `gorm:"not null" json:"severity"`
+	Value           float64    `json:"value"`
+	Message         string     `json:"message"`
+	StartedAt       time.Time  `gorm:"not null" json:"started_at"`
+	FiredAt         *time.Time `json:"fired_at,omitempty"`
+	ResolvedAt      *time.Time `json:"resolved_at,omitempty"`
+	LastEvaluatedAt time.Time  `json:"last_evaluated_at"`
+}
+
+// TableName specifies the table name for AlertRule
+func (AlertRule) TableName() string {
+	return "alert_rules"
+}
+
+// TableName specifies the table name for Alert
+func (Alert) TableName() string {
+	return "alerts"
+}
+
+// Integration configures a connection to an external system. Integrations
+// without a TeamID apply to every team.
+type Integration struct {
+	BaseModel
+	Name        string         `gorm:"not null" json:"name"`
+	Type        string         `gorm:"not null;index" json:"type"`
+	TeamID      *uint          `gorm:"index" json:"team_id,omitempty"`
+	Enabled     bool           `gorm:"default:true" json:"enabled"`
+	Config      datatypes.JSON `gorm:"type:jsonb" json:"config"`
+	Credentials datatypes.JSON `gorm:"type:jsonb" json:"-"`
+	LastError   string         `json:"last_error,omitempty"`
+	LastSyncAt  *time.Time     `json:"last_sync_at,omitempty"`
+	CreatedBy   uint           `json:"created_by"`
+}
+
+// TableName specifies the table name for Integration
+func (Integration) TableName() string {
+	return "integrations"
+}
+
+// EventExportCursor records the last audit event delivered to an event sink
+// integration. Replaying events is done by moving the cursor back.
+//
+// Events are exported in the order of their chain_seq, which is the order
+// they commit in, so that an entry whose transaction commits after later ids
+// isn't skipped. LastChainSeq is nil on cursors written before, which follow
+// the chain from LastEventID when next loaded.
+type EventExportCursor struct {
+	ID            uint      `gorm:"primarykey" json:"id"`
+	IntegrationID uint      `gorm:"not null;uniqueIndex" json:"integration_id"`
+	LastChainSeq  *int64    `json:"last_chain_seq"`
+	LastEventID   uint      `gorm:"not null;default:0" json:"last_event_id"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// TableName specifies the table name for EventExportCursor
+func (EventExportCursor) TableName() string {
+	return "event_export_cursors"
+}
+
+// RetentionPolicy controls how long rows of a time-series table are kept
+// and whether they are archived to object storage before deletion
+type RetentionPolicy struct {
+	BaseModel
+	Target         string     `gorm:"uniqueIndex;not null" json:"target"`
+	RetentionDays  int        `gorm:"not null" json:"retention_days"`
+	ArchiveEnabled bool       `gorm:"default:false" json:"archive_enabled"`
+	ArchiveFormat  string     `gorm:"default:'jsonl'" json:"archive_format"`
+	Enabled        bool       `gorm:"default:true" json:"enabled"`
+	LastRunAt      *time.Time `json:"last_run_at,omitempty"`
+}
+
+// ArchiveRun records one execution of a retention policy
+type ArchiveRun struct {
+	BaseModel
+	RetentionPolicyID uint           `gorm:"not null;index" json:"retention_policy_id"`
+	Target            string         `gorm:"not null" json:"target"`
+	Status            string         `gorm:"not null;index" json:"status"`
+	Cutoff            time.Time      `json:"cutoff"`
+	RowsArchived      int64          `json:"rows_archived"`
+	RowsDeleted       int64          `json:"rows_deleted"`
+	ObjectKeys        datatypes.JSON `gorm:"type:jsonb" json:"object_keys"`
+	Error             string         `json:"error,omitempty"`
+	TriggeredBy       *uint          `json:"triggered_by,omitempty"`
+	StartedAt         time.Time      `json:"started_at"`
+	CompletedAt       *time.Time     `json:"completed_at,omitempty"`
+}
+
+// TableName specifies the table name for RetentionPolicy
+func (RetentionPolicy) TableName() string {
+	return "retention_policies"
+}
+
+// TableName specifies the table name for ArchiveRun
+func (ArchiveRun) TableName() string {
+	return "archive_runs"
+}
+
+// TeamDeletion tracks a guided team deletion. Force deletions wait for the
+// K8s controller to deprovision the team's resources before the team itself
+// is removed.
+type TeamDeletion struct {
+	BaseModel
+	TeamID             uint       `gorm:"not null;index" json:"team_id"`
+	TeamName           string     `json:"team_name"`
+	Mode               string     `gorm:"not null" json:"mode"`
+	TransferTeamID     *uint      `json:"transfer_team_id,omitempty"`
+	Status             string     `gorm:"not null;index" json:"status"`
+	TotalResources     int        `json:"total_resources"`
+	RemainingResources int        `json:"remaining_resources"`
+	Error              string     `json:"error,omitempty"`
+	RequestedBy        uint       `json:"requested_by"`
+	CompletedAt        *time.Time `json:"completed_at,omitempty"`
+}
+
+// Environment is a stage in a team's promotion pipeline. Environments are
+// ordered by Position and resources are promoted from one to the next.
+type Environment struct {
+	BaseModel
+	TeamID            uint   `gorm:"not null;uniqueIndex:idx_team_environment,priority:1" json:"team_id"`
+	Name              string `gorm:"not null;uniqueIndex:idx_team_environment,priority:2" json:"name"`
+	Position          int    `gorm:"not null" json:"position"`
+	BackupSchedule    string `json:"backup_schedule"`
+	HighAvailability  bool   `gorm:"default:false" json:"high_availability"`
+	RequiresApproval  bool   `gorm:"default:false" json:"requires_approval"`
+	MaintenanceWindow string `json:"maintenance_window"`
+}
+
+// ControllerInstance is the heartbeat record of a running K8s controller,
+// written by the controller and read by the API
+type ControllerInstance struct {
+	BaseModel
+	InstanceID      string     `gorm:"uniqueIndex;not null" json:"instance_id"`
+	Hostname        string     `json:"hostname"`
+	Cluster         string     `gorm:"index" json:"cluster"`
+	Version         string     `json:"version"`
+	Status          string     `json:"status"`
+	WorkerCount     int        `json:"worker_count"`
+	QueueDepth      int        `json:"queue_depth"`
+	StartedAt       time.Time  `json:"started_at"`
+	LastHeartbeatAt time.Time  `gorm:"index" json:"last_heartbeat_at"`
+	LastReconcileAt *time.Time `json:"last_reconcile_at,omitempty"`
+	// SchemaVersion is the schema version the controller was built for
+	SchemaVersion int    `json:"schema_version"`
+	BuildTime     string `json:"build_time,omitempty"`
+	GitCommit     string `json:"git_commit,omitempty"`
+	// DrainRequestedAt is set to ask the instance to drain; it reports
+	// draining, then drained
+	DrainRequestedAt *time.Time `json:"drain_requested_at,omitempty"`
+}
+
+// SchemaInfo records the schema version the API last migrated, so K8s
+// controllers can check they are compatible with it. It holds one row.
+type SchemaInfo struct {
+	ID                         uint      `gorm:"primaryKey" json:"-"`
+	SchemaVersion              int       `gorm:"not null" json:"schema_version"`
+	MinControllerSchemaVersion int       `gorm:"not null" json:"min_controller_schema_version"`
+	APIVersion                 string    `json:"api_version"`
+	MigratedAt                 time.Time `json:"migrated_at"`
+}
+
+// TableName specifies the table name for SchemaInfo
+func (SchemaInfo) TableName() string {
+	return "schema_info"
+}
+
+// Agent is a nest-agent running on a host outside Kubernetes. It polls the
+// API for the desired state of the partial and monitor_only resources
+// assigned to it, applies it locally, and reports back.
+type Agent struct {
+	BaseModel
+	Name            string         `gorm:"uniqueIndex;not null" json:"name"`
+	Identity        string         `gorm:"not null" json:"identity"`
+	Hostname        string         `json:"hostname"`
+	Version         string         `json:"version"`
+	Engines         datatypes.JSON `gorm:"type:jsonb" json:"engines"`
+	LastHeartbeatAt time.Time      `gorm:"index" json:"last_heartbeat_at"`
+}
+
+// ReconcileRequest asks the K8s controller to reconcile a resource
+// immediately rather than waiting for its next loop
+type ReconcileRequest struct {
+	BaseModel
+	ResourceID  uint       `gorm:"not null;index" json:"resource_id"`
+	RequestedBy uint       `json:"requested_by"`
+	ProcessedAt *time.Time `gorm:"index" json:"processed_at,omitempty"`
+}
+
+// ReconcileStatus is the K8s controller's latest reconcile outcome and retry
+// state for a resource
+type ReconcileStatus struct {
+	ResourceID      uint       `gorm:"primaryKey;autoIncrement:false" json:"resource_id"`
+	LastReconcileAt *time.Time `json:"last_reconcile_at,omitempty"`
+	LastOutcome     string     `json:"last_outcome"`
+	LastError       string     `json:"last_error,omitempty"`
+	RetryCount      int        `json:"retry_count"`
+	NextRetryAt     *time.Time `json:"next_retry_at,omitempty"`
+	InstanceID      string     `json:"instance_id"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// StuckResource tracks a full lifecycle resource the watchdog found in a
+// transitional status, from when it was first seen in it. The row is removed
+// once the resource leaves the status.
+type StuckResource struct {
+	BaseModel
+	ResourceID uint      `gorm:"not null;uniqueIndex" json:"resource_id"`
+	TeamID     uint      `gorm:"not null;index" json:"team_id"`
+	Status     string    `gorm:"size:50;not null" json:"status"`
+	Since      time.Time `gorm:"not null" json:"since"`
+	// Reconciles the watchdog queued, the last one at RemediatedAt
+	Requeues     int        `gorm:"not null;default:0" json:"requeues"`
+	RemediatedAt *time.Time `json:"remediated_at,omitempty"`
+	// When the owning team was alerted that the resource is stuck
+	AlertedAt *time.Time `json:"alerted_at,omitempty"`
+	Resource  *Resource  `gorm:"foreignKey:ResourceID" json:"resource,omitempty"`
+}
+
+// ConsistencyReport is a run of the K8s controller's consistency audit,
+// which compares each provisioned full lifecycle resource with its
+// StatefulSet in the controller's cluster. Discrepancies holds what it
+// found, as ConsistencyDiscrepancy entries.
+type ConsistencyReport struct {
+	BaseModel
+	Cluster          string         `gorm:"size:100;not null;index" json:"cluster"`
+	InstanceID       string         `gorm:"size:100" json:"instance_id"`
+	StartedAt        time.Time      `json:"started_at"`
+	CompletedAt      time.Time      `json:"completed_at"`
+	ResourcesChecked int            `json:"resources_checked"`
+	ResourcesFailed  int            `json:"resources_failed"`
+	DiscrepancyCount int            `json:"discrepancy_count"`
+	Discrepancies    datatypes.JSON `gorm:"type:jsonb" json:"-"`
+}
+
+// CredentialUsage is when the K8s controller's stats collection last saw an
+// account of a resource connected, by its username. Accounts are matched to
+// the manager's resource_users by username.
+type CredentialUsage struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	ResourceID  uint      `gorm:"not null;uniqueIndex:idx_credential_usages_account" json:"resource_id"`
+	TeamID      uint      `gorm:"not null;index" json:"team_id"`
+	Username    string    `gorm:"size:255;not null;uniqueIndex:idx_credential_usages_account" json:"username"`
+	LastSeenAt  time.Time `gorm:"not null" json:"last_seen_at"`
+	Connections int       `gorm:"not null;default:0" json:"connections"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// ConsistencyDiscrepancy is a difference between a resource's record and
+// its objects in the cluster. Check is existence, replicas, image, labels
+// or secrets.
+type ConsistencyDiscrepancy struct {
+	ResourceID uint   `json:"resource_id"`
+	TeamID     uint   `json:"team_id"`
+	Name       string `json:"name"`
+	Check      string `json:"check"`
+	Expected   string `json:"expected,omitempty"`
+	Actual     string `json:"actual,omitempty"`
+	Message    string `json:"message"`
+}
+
+// ImageRegistry is a registry mirror the K8s controller pulls resource images
+// through. A registry without a team applies to every team and one without a
+// cluster to every cluster; the most specific match wins.
+type ImageRegistry struct {
+	BaseModel
+	Name         string `gorm:"not null" json:"name"`
+	TeamID       *uint  `gorm:"index" json:"team_id,omitempty"`
+	Cluster      string `gorm:"not null;default:'';index" json:"cluster,omitempty"`
+	MirrorPrefix string `gorm:"not null" json:"mirror_prefix"`
+	Username     string `json:"username,omitempty"`
+	Password     string `json:"-"`
+	CreatedBy    uint   `json:"created_by"`
+}
+
+// TableName specifies the table name for ImageRegistry
+func (ImageRegistry) TableName() string {
+	return "image_registries"
+}
+
+// AllowedImage is an image pattern that may be injected into generated
+// StatefulSets as an init container or sidecar. Patterns use path.Match
+// syntax, e.g. docker.io/fluent/fluent-bit:*.
+type AllowedImage struct {
+	BaseModel
+	Pattern     string `gorm:"uniqueIndex;not null" json:"pattern"`
+	Description string `json:"description,omitempty"`
+	CreatedBy   uint   `json:"created_by"`
+}
+
+// TableName specifies the table name for AllowedImage
+func (AllowedImage) TableName() string {
+	return "allowed_images"
+}
+
+// ContainerPolicy attaches an init container or sidecar to every generated
+// StatefulSet of a team, optionally limited to some resource types
+type ContainerPolicy struct {
+	BaseModel
+	TeamID        uint           `gorm:"not null;index" json:"team_id"`
+	Kind          string         `gorm:"not null" json:"kind"`
+	Name          string         `gorm:"not null" json:"name"`
+	Image         string         `gorm:"not null" json:"image"`
+	Command       datatypes.JSON `gorm:"type:jsonb" json:"command,omitempty"`
+	Args          datatypes.JSON `gorm:"type:jsonb" json:"args,omitempty"`
+	Env           datatypes.JSON `gorm:"type:jsonb" json:"env,omitempty"`
+	ResourceTypes datatypes.JSON `gorm:"type:jsonb" json:"resource_types,omitempty"`
+	Enabled       bool           `gorm:"default:true" json:"enabled"`
+	CreatedBy     uint           `json:"created_by"`
+}
+
+// TableName specifies the table name for ContainerPolicy
+func (ContainerPolicy) TableName() string {
+	return "container_policies"
+}
+
+// SizeClass is a named sizing preset of a resource type, such as small,
+// medium, or large. A class sets a resource's CPU, memory, and storage
+// requests and provides engine tuning defaults.
+type SizeClass struct {
+	BaseModel
+	ResourceTypeID uint           `gorm:"not null;uniqueIndex:idx_type_size_class,priority:1" json:"resource_type_id"`
+	Name           string         `gorm:"not null;uniqueIndex:idx_type_size_class,priority:2" json:"name"`
+	Position       int            `gorm:"not null" json:"position"`
+	CPU            string         `gorm:"not null" json:"cpu"`
+	Memory         string         `gorm:"not null" json:"memory"`
+	Storage        string         `json:"storage"`
+	Tuning         datatypes.JSON `gorm:"type:jsonb" json:"tuning"`
+}
+
+// FeatureFlag overrides the default of a feature flag declared in code,
+// rolling a subsystem out to listed teams and a percentage of the rest.
+// The K8s controller reads the same table.
+type FeatureFlag struct {
+	BaseModel
+	Key         string         `gorm:"uniqueIndex;not null" json:"key"`
+	Description string         `json:"description,omitempty"`
+	Enabled     bool           `gorm:"default:false" json:"enabled"`
+	Percentage  int            `gorm:"default:0" json:"percentage"`
+	Teams       datatypes.JSON `gorm:"type:jsonb" json:"teams"`
+	UpdatedBy   uint           `json:"updated_by"`
+}
+
+// LogLevelOverride changes the log level of the API or the K8s controller
+// at runtime, as a whole or for single components, until it expires. Every
+// replica of the service picks it up. The K8s controller reads the same
+// table.
+type LogLevelOverride struct {
+	BaseModel
+	Service    string         `gorm:"uniqueIndex;not null" json:"service"`
+	Level      string         `json:"level,omitempty"`
+	Components datatypes.JSON `gorm:"type:jsonb" json:"components"`
+	ExpiresAt  *time.Time     `json:"expires_at,omitempty"`
+	UpdatedBy  uint           `json:"updated_by"`
+}
+
+// UserPreference is a UI setting of a user, such as a column layout or
+// default team, kept as any JSON value under a key the UI chooses
+type UserPreference struct {
+	BaseModel
+	UserID uint           `gorm:"not null;uniqueIndex:idx_user_preferences_user_key" json:"-"`
+	Key    string         `gorm:"size:100;not null;uniqueIndex:idx_user_preferences_user_key" json:"key"`
+	Value  datatypes.JSON `gorm:"type:jsonb;not null" json:"value"`
+}
+
+// SavedView is a named set of filters a user saved for a list, such as
+// resources or audit logs, with the columns to show. At most one view of
+// each list is the user's default.
+type SavedView struct {
+	BaseModel
+	UserID    uint           `gorm:"not null;index" json:"-"`
+	Name      string         `gorm:"size:100;not null" json:"name"`
+	Target    string         `gorm:"size:50;not null;index" json:"target"`
+	Filters   datatypes.JSON `gorm:"type:jsonb" json:"filters"`
+	Columns   datatypes.JSON `gorm:"type:jsonb" json:"columns,omitempty"`
+	IsDefault bool           `gorm:"default:false" json:"is_default"`
+}
+
+// Announcement is an organization-wide notice, such as planned maintenance
+// or a deprecation, shown to users between its start and end times
+type Announcement struct {
+	BaseModel
+	Title     string     `gorm:"size:200;not null" json:"title"`
+	Message   string     `gorm:"type:text;not null" json:"message"`
+	Severity  string     `gorm:"size:20;not null;default:'info'" json:"severity"`
+	StartsAt  time.Time  `gorm:"not null;index" json:"starts_at"`
+	EndsAt    *time.Time `gorm:"index" json:"ends_at,omitempty"`
+	CreatedBy uint       `json:"created_by"`
+}
+
+// AnnouncementAck records that a user has acknowledged an announcement
+type AnnouncementAck struct {
+	BaseModel
+	AnnouncementID uint `gorm:"not null;uniqueIndex:idx_announcement_user" json:"announcement_id"`
+	UserID         uint `gorm:"not null;uniqueIndex:idx_announcement_user;index" json:"user_id"`
+}
+
+// Tenant is an isolated customer of a hosted deployment. Its users, teams,
+// and resources live in their own Postgres schema; resource types, size
+// classes, feature flags, and the image allowlist stay shared in public.
+type Tenant struct {
+	BaseModel
+	Name   string         `gorm:"not null" json:"name"`
+	Slug   string         `gorm:"uniqueIndex;not null" json:"slug"`
+	Hosts  datatypes.JSON `gorm:"type:jsonb" json:"hosts"`
+	Schema string         `gorm:"uniqueIndex;not null" json:"schema"`
+}
+
+// PasswordPolicy is the password and credential policy of the deployment.
+// There is at most one row; until one is saved, credpolicy.Default applies.
+type PasswordPolicy struct {
+	BaseModel
+	MinLength      int     `gorm:"not null" json:"min_length"`
+	MinClasses     int     `gorm:"not null" json:"min_classes"`
+	MinEntropyBits float64 `gorm:"not null" json:"min_entropy_bits"`
+	BreachCheck    bool    `gorm:"not null" json:"breach_check"`
+	MaxAgeDays     int     `gorm:"not null" json:"max_age_days"`
+}
+
+// AuditAnchor records the head of the audit log hash chain as it was
+// written to external storage. Verification checks that the anchored entry
+// still carries the anchored hash.
+type AuditAnchor struct {
+	ID         uint      `gorm:"primarykey" json:"id"`
+	ChainSeq   int64     `gorm:"not null;index" json:"chain_seq"`
+	EntryHash  string    `gorm:"size:64;not null" json:"entry_hash"`
+	Location   string    `gorm:"not null" json:"location"`
+	AnchoredAt time.Time `gorm:"not null;index" json:"anchored_at"`
+}
+
+// Erasure request statuses
+const (
+	ErasurePending   = "pending"
+	ErasureCompleted = "completed"
+	ErasureCancelled = "cancelled"
+)
+
+// ErasureRequest is a request to erase a user's personal data. Nothing is
+// erased until it is confirmed with its token, before it expires.
+type ErasureRequest struct {
+	BaseModel
+	UserID             uint       `gorm:"not null;index" json:"user_id"`
+	RequestedBy        uint       `gorm:"not null" json:"requested_by"`
+	ConfirmedBy        *uint      `json:"confirmed_by,omitempty"`
+	Status             string     `gorm:"size:20;not null;index" json:"status"`
+	TokenHash          string     `gorm:"size:64;not null" json:"-"`
+	ExpiresAt          time.Time  `gorm:"not null" json:"expires_at"`
+	CompletedAt        *time.Time `json:"completed_at,omitempty"`
+	AuditEntriesErased int64      `gorm:"not null;default:0" json:"audit_entries_erased"`
+}
+
+// Network access rule actions
+const (
+	NetworkAllow = "allow"
+	NetworkDeny  = "deny"
+)
+
+// NetworkAccessRule allows or denies API requests from a CIDR block. Rules
+// without a team apply to every request; team rules apply to requests about
+// the team and its resources, and to cross-team requests of its members.
+type NetworkAccessRule struct {
+	BaseModel
+	TeamID      *uint  `gorm:"index" json:"team_id,omitempty"`
+	Action      string `gorm:"size:10;not null" json:"action"`
+	CIDR        string `gorm:"size:50;not null" json:"cidr"`
+	Description string `json:"description,omitempty"`
+	Enabled     bool   `gorm:"not null;default:true" json:"enabled"`
+	CreatedBy   uint   `json:"created_by"`
+}
+
+// WorkloadIdentity maps the SPIFFE ID of a workload, such as the
+// controller or a node agent, to the service account user it acts as when
+// it authenticates with an X.509-SVID
+type WorkloadIdentity struct {
+	BaseModel
+	SPIFFEID    string     `gorm:"column:spiffe_id;uniqueIndex;not null" json:"spiffe_id"`
+	UserID      uint       `gorm:"not null;index" json:"user_id"`
+	User        *User      `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	Description string     `json:"description,omitempty"`
+	CreatedBy   uint       `json:"created_by"`
+	LastSeenAt  *time.Time `json:"last_seen_at,omitempty"`
+}
+
+// DockerHost is a Docker or Podman host outside Kubernetes, for labs and
+// edge sites, that the K8s controller provisions full lifecycle resources on
+// as containers. The controller reaches its Engine API over mutual TLS.
+type DockerHost struct {
+	BaseModel
+	Name     string `gorm:"uniqueIndex;not null" json:"name"`
+	Endpoint string `gorm:"not null" json:"endpoint"`
+	Runtime  string `gorm:"not null;default:'docker'" json:"runtime"`
+	// AdvertiseAddress is the address clients reach published ports on,
+	// defaulting to the endpoint's host
+	AdvertiseAddress string     `json:"advertise_address,omitempty"`
+	CACert           string     `gorm:"type:text" json:"ca_cert"`
+	ClientCert       string     `gorm:"type:text" json:"client_cert"`
+	ClientKey        string     `gorm:"type:text" json:"-"`
+	CreatedBy        uint       `json:"created_by"`
+	LastContactAt    *time.Time `json:"last_contact_at,omitempty"`
+	LastError        string     `json:"last_error,omitempty"`
+}
+
+// CloudAccount holds the credentials NEST discovers managed database
+// instances with, in AWS RDS or GCP Cloud SQL. Instances are imported as
+// monitor_only or partial resources of the account's team.
+type CloudAccount struct {
+	BaseModel
+	Name          string `gorm:"uniqueIndex;not null" json:"name"`
+	Provider      string `gorm:"not null" json:"provider"`
+	TeamID        uint   `gorm:"not null;index" json:"team_id"`
+	Environment   string `gorm:"not null" json:"environment"`
+	LifecycleMode string `gorm:"not null;default:'monitor_only'" json:"lifecycle_mode"`
+	// Region and access key of an AWS account
+	Region          string `json:"region,omitempty"`
+	AccessKeyID     string `json:"access_key_id,omitempty"`
+	SecretAccessKey string `json:"-"`
+	// Project and service account key JSON of a GCP account
+	Project           string     `json:"project,omitempty"`
+	ServiceAccountKey string     `gorm:"type:text" json:"-"`
+	CreatedBy         uint       `json:"created_by"`
+	LastSyncAt        *time.Time `json:"last_sync_at,omitempty"`
+	LastError         string     `json:"last_error,omitempty"`
+}
+
+// AdoptionCandidate is a StatefulSet the K8s controller found in a team
+// namespace that looks like a database but isn't managed by NEST. Approving
+// it links it to a new resource, which the controller then labels as its own
+// without recreating it.
+type AdoptionCandidate struct {
+	BaseModel
+	Namespace   string `gorm:"not null;uniqueIndex:idx_adoption_candidate" json:"namespace"`
+	Name        string `gorm:"not null;uniqueIndex:idx_adoption_candidate" json:"name"`
+	Engine      string `gorm:"not null" json:"engine"`
+	Image       string `json:"image"`
+	Replicas    int    `json:"replicas"`
+	ServiceName string `json:"service_name,omitempty"`
+	Port        int    `json:"port,omitempty"`
+	// proposed, approved (waiting for the controller), adopted, or dismissed
+	Status     string     `gorm:"not null;default:'proposed';index" json:"status"`
+	ResourceID *uint      `json:"resource_id,omitempty"`
+	LastSeenAt *time.Time `json:"last_seen_at,omitempty"`
+}
+
+// SSHCertificate records an SSH user certificate issued for access to the
+// host of an agent-managed resource. The certificate itself isn't kept.
+type SSHCertificate struct {
+	BaseModel
+	ResourceID  uint           `gorm:"not null;index" json:"resource_id"`
+	TeamID      uint           `gorm:"not null;index" json:"team_id"`
+	UserID      uint           `gorm:"not null;index" json:"user_id"`
+	CAID        uint           `gorm:"not null" json:"ca_id"`
+	Serial      uint64         `gorm:"not null" json:"serial"`
+	KeyID       string         `gorm:"not null" json:"key_id"`
+	Principals  datatypes.JSON `gorm:"type:jsonb" json:"principals"`
+	Fingerprint string         `gorm:"not null" json:"fingerprint"`
+	ValidAfter  time.Time      `json:"valid_after"`
+	ValidBefore time.Time      `gorm:"index" json:"valid_before"`
+}
+
+// ConsumerBinding binds a resource to an application namespace. The K8s
+// controller keeps a Secret there with the resource's connection details
+// and CA, updating it when the credentials rotate. Deleted bindings stay
+// until the controller has removed their Secret.
+type ConsumerBinding struct {
+	BaseModel
+	ResourceID uint   `gorm:"not null;index" json:"resource_id"`
+	TeamID     uint   `gorm:"not null;index" json:"team_id"`
+	Namespace  string `gorm:"not null" json:"namespace"`
+	SecretName string `gorm:"not null" json:"secret_name"`
+	// pending (waiting for the controller), synced, or error
+	Status       string     `gorm:"not null;default:'pending'" json:"status"`
+	LastError    string     `json:"last_error,omitempty"`
+	LastSyncedAt *time.Time `json:"last_synced_at,omitempty"`
+	CreatedBy    uint       `json:"created_by"`
+}
+
+// ResourceClaim links a Crossplane-style claim in the cluster to the
+// resource the K8s controller created for it. Deleting the claim deletes the
+// resource, so claimed resources can't be deleted through the API.
+type ResourceClaim struct {
+	BaseModel
+	ResourceID uint   `gorm:"not null;index" json:"resource_id"`
+	TeamID     uint   `gorm:"not null;index" json:"team_id"`
+	APIVersion string `gorm:"not null" json:"api_version"`
+	Kind       string `gorm:"not null" json:"kind"`
+	Namespace  string `gorm:"not null" json:"namespace"`
+	Name       string `gorm:"not null" json:"name"`
+	UID        string `gorm:"column:uid;uniqueIndex;not null" json:"uid"`
+}
+
+// BackstageToken is a bearer token a Backstage backend, or the Backstage
+// proxy, calls the API with as a service account. Its scope limits which
+// requests it can make; only the token's SHA-256 hash is stored.
+type BackstageToken struct {
+	BaseModel
+	Name        string     `gorm:"not null" json:"name"`
+	UserID      uint       `gorm:"not null;index" json:"user_id"`
+	User        *User      `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	Scope       string     `gorm:"not null;default:'catalog'" json:"scope"`
+	TokenHash   string     `gorm:"uniqueIndex;not null" json:"-"`
+	TokenPrefix string     `gorm:"not null" json:"token_prefix"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt  *time.Time `json:"last_used_at,omitempty"`
+	CreatedBy   uint       `json:"created_by"`
+}
+
+// GitSyncedResource links a resource to the git sync integration that
+// created it from a definition in the integration's repository
+type GitSyncedResource struct {
+	BaseModel
+	IntegrationID uint   `gorm:"not null;index" json:"integration_id"`
+	ResourceID    uint   `gorm:"not null;uniqueIndex" json:"resource_id"`
+	TeamID        uint   `gorm:"not null;index" json:"team_id"`
+	Name          string `gorm:"not null" json:"name"`
+	Environment   string `gorm:"not null" json:"environment"`
+	File          string `json:"file"`
+	Commit        string `json:"commit"`
+}
+
+// SlackBackupThread is a backup job queued from Slack, whose result is
+// posted back to the thread the command was announced in
+type SlackBackupThread struct {
+	BaseModel
+	IntegrationID uint       `gorm:"not null;index" json:"integration_id"`
+	JobID         uint       `gorm:"not null;uniqueIndex" json:"job_id"`
+	ResourceID    uint       `gorm:"not null" json:"resource_id"`
+	Channel       string     `gorm:"not null" json:"channel"`
+	ThreadTS      string     `gorm:"not null" json:"thread_ts"`
+	NotifiedAt    *time.Time `gorm:"index" json:"notified_at,omitempty"`
+}
+
+// Ticket is an issue a ticketing integration opened in Jira or ServiceNow:
+// an approval a gated operation waits on, a failed job, or a compliance
+// violation. Subject names what the ticket is about, so that it is only
+// opened once.
+type Ticket struct {
+	BaseModel
+	IntegrationID  uint           `gorm:"not null;index" json:"integration_id"`
+	TeamID         uint           `gorm:"not null;index" json:"team_id"`
+	Kind           string         `gorm:"not null" json:"kind"`
+	Subject        string         `gorm:"not null;index" json:"subject"`
+	ResourceID     *uint          `gorm:"index" json:"resource_id,omitempty"`
+	ExternalKey    string         `gorm:"not null" json:"external_key"`
+	URL            string         `json:"url"`
+	Status         string         `gorm:"not null;default:'open';index" json:"status"`
+	ExternalStatus string         `json:"external_status,omitempty"`
+	Operation      datatypes.JSON `gorm:"type:jsonb" json:"operation,omitempty"`
+	RequestedBy    *uint          `json:"requested_by,omitempty"`
+	ResolvedAt     *time.Time     `json:"resolved_at,omitempty"`
+	LastError      string         `json:"last_error,omitempty"`
+}
+
+// EmailTemplate overrides the subject and bodies of a built-in mail
+// template
+type EmailTemplate struct {
+	BaseModel
+	Name      string `gorm:"size:50;not null;uniqueIndex" json:"name"`
+	Subject   string `gorm:"not null" json:"subject"`
+	Text      string `gorm:"type:text;not null" json:"text"`
+	HTML      string `gorm:"type:text" json:"html"`
+	UpdatedBy uint   `gorm:"not null" json:"updated_by"`
+}
+
+// TeamInvitation invites an email address to join a team. The invited user
+// accepts it with its token, before it expires.
+type TeamInvitation struct {
+	BaseModel
+	TeamID     uint       `gorm:"not null;index" json:"team_id"`
+	Email      string     `gorm:"not null;index" json:"email"`
+	Role       string     `gorm:"not null" json:"role"`
+	TokenHash  string     `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	InvitedBy  uint       `gorm:"not null" json:"invited_by"`
+	ExpiresAt  time.Time  `gorm:"not null" json:"expires_at"`
+	AcceptedAt *time.Time `json:"accepted_at,omitempty"`
+	AcceptedBy *uint      `json:"accepted_by,omitempty"`
+	EmailError string     `json:"email_error,omitempty"`
+}
+
+// MailDelivery records a scheduled email by a key naming what it was sent
+// about, so that it is only sent once
+type MailDelivery struct {
+	BaseModel
+	Key    string    `gorm:"not null;uniqueIndex" json:"key"`
+	SentAt time.Time `gorm:"not null" json:"sent_at"`
+}
+
+// Job is a unit of background work in the persistent job queue. Failed
+// attempts are retried with backoff; a job that fails every attempt is
+// kept as dead until it is requeued or discarded.
+type Job struct {
+	BaseModel
+	Kind        string         `gorm:"size:100;not null;index" json:"kind"`
+	Payload     datatypes.JSON `gorm:"type:jsonb" json:"payload,omitempty"`
+	Priority    int            `gorm:"not null;default:0" json:"priority"`
+	Status      string         `gorm:"size:20;not null;index" json:"status"`
+	Attempts    int            `gorm:"not null;default:0" json:"attempts"`
+	RunAt       time.Time      `gorm:"not null;index" json:"run_at"`
+	UniqueKey   *string        `gorm:"uniqueIndex" json:"unique_key,omitempty"`
+	LockedBy    string         `json:"locked_by,omitempty"`
+	LockedUntil *time.Time     `json:"locked_until,omitempty"`
+	LastError   string         `gorm:"type:text" json:"last_error,omitempty"`
+	StartedAt   *time.Time     `json:"started_at,omitempty"`
+	CompletedAt *time.Time     `json:"completed_at,omitempty"`
+}
+
+// Operation tracks a long-running change to a full lifecycle resource, such
+// as its creation, restore or resize, from the request that started it until
+// the K8s controller has carried it out. The controller links the
+// provisioning job it runs for the change and records the outcome.
+type Operation struct {
+	BaseModel
+	Type              string     `gorm:"size:50;not null" json:"type"`
+	Status            string     `gorm:"size:20;not null;default:'pending';index" json:"status"`
+	ResourceID        uint       `gorm:"not null;index" json:"resource_id"`
+	TeamID            uint       `gorm:"not null;index" json:"team_id"`
+	ProvisioningJobID *uint      `gorm:"index" json:"provisioning_job_id,omitempty"`
+	Message           string     `gorm:"type:text" json:"message,omitempty"`
+	RequestedBy       uint       `json:"requested_by"`
+	StartedAt         *time.Time `json:"started_at,omitempty"`
+	CompletedAt       *time.Time `json:"completed_at,omitempty"`
+}
+
+// ResourceLock keeps a second operation from starting on a resource while
+// one is in progress. The lock is taken with the operation and released by
+// the K8s controller when the operation finishes, or by an admin; it lapses
+// at ExpiresAt in case neither happens.
+type ResourceLock struct {
+	BaseModel
+	ResourceID  uint      `gorm:"not null;uniqueIndex" json:"resource_id"`
+	Holder      string    `gorm:"size:100;not null" json:"holder"`
+	Reason      string    `gorm:"size:255" json:"reason"`
+	OperationID *uint     `gorm:"index" json:"operation_id,omitempty"`
+	AcquiredBy  uint      `json:"acquired_by"`
+	ExpiresAt   time.Time `gorm:"not null;index" json:"expires_at"`
+}
+
+// Policy is a Rego policy resource specs are checked against on create,
+// update and reconcile. The Rego declares package nest.policies.<name> and
+// lists violations in its deny rule; violations of a blocking policy reject
+// the change, those of a warning policy are recorded on the resource.
+type Policy struct {
+	BaseModel
+	Name        string `gorm:"uniqueIndex;not null" json:"name"`
+	Description string `json:"description,omitempty"`
+	Rego        string `gorm:"type:text;not null" json:"rego"`
+	Severity    string `gorm:"size:20;not null;default:'block'" json:"severity"`
+	Enabled     bool   `gorm:"default:true" json:"enabled"`
+	CreatedBy   uint   `json:"created_by"`
+}
+
+// ValidationWebhook is an external validator called with the proposed spec
+// of a resource before it is created or updated. A webhook that can't be
+// called, or answers with an error, rejects the change when its failure
+// policy is fail and lets it through when it is ignore.
+type ValidationWebhook struct {
+	BaseModel
+	Name           string         `gorm:"uniqueIndex;not null" json:"name"`
+	URL            string         `gorm:"not null" json:"url"`
+	Secret         string         `json:"-"`
+	Operations     datatypes.JSON `gorm:"type:jsonb" json:"operations,omitempty"`
+	TimeoutSeconds int            `gorm:"not null;default:5" json:"timeout_seconds"`
+	FailurePolicy  string         `gorm:"size:20;not null;default:'fail'" json:"failure_policy"`
+	Enabled        bool           `gorm:"default:true" json:"enabled"`
+	LastCalledAt   *time.Time     `json:"last_called_at,omitempty"`
+	LastError      string         `gorm:"type:text" json:"last_error,omitempty"`
+	LastErrorAt    *time.Time     `json:"last_error_at,omitempty"`
+	CreatedBy      uint           `json:"created_by"`
+}
+
 // User represents a system user
 type User struct {
 	BaseModel
@@ -96,6 +970,8 @@ type User struct {
 	Role         string     `gorm:"default:'user'" json:"role"`
 	IsActive     bool       `gorm:"default:true" json:"is_active"`
 	LastLoginAt  *time.Time `json:"last_login_at,omitempty"`
+
+	PasswordChangedAt *time.Time `json:"password_changed_at,omitempty"`
 }
 
 // TeamMember represents membership in a team
@@ -129,6 +1005,7 @@ type CreateResourceRequest struct {
 	Name               string                 `json:"name" binding:"required"`
 	ResourceTypeID     uint                   `json:"resource_type_id" binding:"required"`
 	TeamID             uint                   `json:"team_id" binding:"required"`
+	Environment        string                 `json:"environment"`
 	LifecycleMode      string                 `json:"lifecycle_mode" binding:"required,oneof=full partial monitor_only"`
 	ProvisioningMethod string                 `json:"provisioning_method"`
 	ConnectionInfo     map[string]interface{} `json:"connection_info"`
@@ -136,37 +1013,77 @@ type CreateResourceRequest struct {
 	Config             map[string]interface{} `json:"config"`
 	TLSEnabled         bool                   `json:"tls_enabled"`
 	Capabilities       map[string]bool        `json:"capabilities"`
+	DeletionProtection bool                   `json:"deletion_protection"`
+	SizeClass          string                 `json:"size_class"`
+	PriorityClass      string                 `json:"priority_class"`
+	AgentID            *uint                  `json:"agent_id"`
+	DockerHostID       *uint                  `json:"docker_host_id"`
 }
 
-// UpdateResourceRequest is the request body for updating a resource
+// UpdateResourceRequest is the request body for updating a resource's spec
 type UpdateResourceRequest struct {
-	Name   *string                `json:"name"`
-	Status *string                `json:"status"`
-	Config map[string]interface{} `json:"config"`
+	Name               *string                `json:"name"`
+	Config             map[string]interface{} `json:"config"`
+	DeletionProtection *bool                  `json:"deletion_protection"`
+	PriorityClass      *string                `json:"priority_class"`
+}
+
+// ResourceStatusRequest is the request body the K8s controller sends to
+// record what it observed of a resource
+type ResourceStatusRequest struct {
+	Status          *string                `json:"status"`
+	ConnectionInfo  map[string]interface{} `json:"connection_info"`
+	K8sNamespace    *string                `json:"k8s_namespace"`
+	K8sResourceName *string                `json:"k8s_resource_name"`
+	K8sResourceType *string                `json:"k8s_resource_type"`
+	// ObservedGeneration is the generation the reconcile reported acted on
+	ObservedGeneration *int64 `json:"observed_generation"`
+	// Conditions replaces the resource's conditions when set
+	Conditions []ResourceCondition `json:"conditions" binding:"omitempty,dive"`
 }
 
 // ResourceResponse is the response body for a resource
 type ResourceResponse struct {
-	ID                 uint                   `json:"id"`
-	Name               string                 `json:"name"`
-	ResourceTypeID     uint                   `json:"resource_type_id"`
-	ResourceType       *ResourceType          `json:"resource_type,omitempty"`
-	TeamID             uint                   `json:"team_id"`
-	Team               *Team                  `json:"team,omitempty"`
-	Status             string                 `json:"status"`
-	LifecycleMode      string                 `json:"lifecycle_mode"`
-	ProvisioningMethod string                 `json:"provisioning_method"`
-	ConnectionInfo     map[string]interface{} `json:"connection_info"`
-	TLSEnabled         bool                   `json:"tls_enabled"`
-	Config             map[string]interface{} `json:"config"`
-	CanModifyUsers     bool                   `json:"can_modify_users"`
-	CanModifyConfig    bool                   `json:"can_modify_config"`
-	CanBackup          bool                   `json:"can_backup"`
-	CanScale           bool                   `json:"can_scale"`
-	CreatedBy          uint                   `json:"created_by"`
-	CreatedAt          time.Time              `json:"created_at"`
-	UpdatedAt          time.Time              `json:"updated_at"`
-	DeletedAt          sql.NullTime           `json:"deleted_at,omitempty"`
+	ID                  uint                   `json:"id"`
+	Name                string                 `json:"name"`
+	ResourceTypeID      uint                   `json:"resource_type_id"`
+	ResourceType        *ResourceType          `json:"resource_type,omitempty"`
+	TeamID              uint                   `json:"team_id"`
+	Team                *Team                  `json:"team,omitempty"`
+	Environment         string                 `json:"environment"`
+	Status              string                 `json:"status"`
+	LifecycleMode       string                 `json:"lifecycle_mode"`
+	ProvisioningMethod  string                 `json:"provisioning_method"`
+	ConnectionInfo      map[string]interface{} `json:"connection_info"`
+	TLSEnabled          bool                   `json:"tls_enabled"`
+	Config              map[string]interface{} `json:"config"`
+	CanModifyUsers      bool                   `json:"can_modify_users"`
+	CanModifyConfig     bool                   `json:"can_modify_config"`
+	CanBackup           bool                   `json:"can_backup"`
+	CanScale            bool                   `json:"can_scale"`
+	CreatedBy           uint                   `json:"created_by"`
+	LastError           string                 `json:"last_error,omitempty"`
+	LastErrorAt         *time.Time             `json:"last_error_at,omitempty"`
+	ConsecutiveFailures int                    `json:"consecutive_failures"`
+	DeletionProtection  bool                   `json:"deletion_protection"`
+	DeletionState       string                 `json:"deletion_state,omitempty"`
+	SecurityFindings    []string               `json:"security_findings,omitempty"`
+	PolicyViolations    []PolicyViolation      `json:"policy_violations,omitempty"`
+	SizeClass           string                 `json:"size_class,omitempty"`
+	PriorityClass       string                 `json:"priority_class,omitempty"`
+	Generation          int64                  `json:"generation"`
+	ObservedGeneration  int64                  `json:"observed_generation"`
+	Conditions          []ResourceCondition    `json:"conditions"`
+	PendingRestart      bool                   `json:"pending_restart"`
+	PendingRestartSince *time.Time             `json:"pending_restart_since,omitempty"`
+	RestartRequired     []string               `json:"restart_required,omitempty"`
+	AgentID             *uint                  `json:"agent_id,omitempty"`
+	DockerHostID        *uint                  `json:"docker_host_id,omitempty"`
+	CloudAccountID      *uint                  `json:"cloud_account_id,omitempty"`
+	CloudInstanceID     string                 `json:"cloud_instance_id,omitempty"`
+	CreatedAt           time.Time              `json:"created_at"`
+	UpdatedAt           time.Time              `json:"updated_at"`
+	DeletedAt           sql.NullTime           `json:"deleted_at,omitempty"`
 }
 
 // ConnectionInfoResponse is the response for connection details
@@ -176,6 +1093,9 @@ type ConnectionInfoResponse struct {
 	TLSEnabled     bool                   `json:"tls_enabled"`
 	TLSCertID      *uint                  `json:"tls_cert_id,omitempty"`
 	AccessLevel    string                 `json:"access_level"`
+
+	// When the credentials must be rotated under the password policy
+	CredentialsExpireAt *time.Time `json:"credentials_expire_at,omitempty"`
 }
 
 // ResourceStatsResponse is the response for resource statistics
@@ -187,6 +1107,99 @@ type ResourceStatsResponse struct {
 	RiskFactors map[string]interface{} `json:"risk_factors"`
 }
 
+// ResourceStatsHistoryResponse is the response for a time range of resource statistics
+type ResourceStatsHistoryResponse struct {
+	ResourceID uint                     `json:"resource_id"`
+	Since      time.Time                `json:"since"`
+	Until      time.Time                `json:"until"`
+	Stats      []*ResourceStatsResponse `json:"stats"`
+}
+
+// QueryStatResponse describes a single statement from the engine's statement statistics
+type QueryStatResponse struct {
+	Query       string  `json:"query"`
+	Calls       int64   `json:"calls"`
+	TotalTimeMs float64 `json:"total_time_ms"`
+	MeanTimeMs  float64 `json:"mean_time_ms"`
+	Rows        int64   `json:"rows"`
+}
+
+// DatabaseInsightsResponse is the response for engine-level database insights
+type DatabaseInsightsResponse struct {
+	ResourceID            uint                `json:"resource_id"`
+	Timestamp             time.Time           `json:"timestamp"`
+	Engine                string              `json:"engine"`
+	Connections           map[string]int64    `json:"connections"`
+	CacheHitRatio         float64             `json:"cache_hit_ratio"`
+	Deadlocks             int64               `json:"deadlocks"`
+	TopQueries            []QueryStatResponse `json:"top_queries"`
+	StatementStatsEnabled bool                `json:"statement_stats_enabled"`
+}
+
+// CreateAlertRuleRequest is the request body for creating an alert rule
+type CreateAlertRuleRequest struct {
+	Name            string  `json:"name" binding:"required"`
+	Description     string  `json:"description"`
+	TeamID          uint    `json:"team_id"`
+	ResourceID      *uint   `json:"resource_id"`
+	Metric          string  `json:"metric" binding:"required"`
+	Operator        string  `json:"operator" binding:"required,oneof=gt gte lt lte eq ne"`
+	Threshold       float64 `json:"threshold"`
+	DurationSeconds int     `json:"duration_seconds" binding:"min=0"`
+	Severity        string  `json:"severity" binding:"required,oneof=info warning critical"`
+}
+
+// UpdateAlertRuleRequest is the request body for updating an alert rule
+type UpdateAlertRuleRequest struct {
+	Name            *string  `json:"name"`
+	Description     *string  `json:"description"`
+	Operator        *string  `json:"operator" binding:"omitempty,oneof=gt gte lt lte eq ne"`
+	Threshold       *float64 `json:"threshold"`
+	DurationSeconds *int     `json:"duration_seconds" binding:"omitempty,min=0"`
+	Severity        *string  `json:"severity" binding:"omitempty,oneof=info warning critical"`
+	Enabled         *bool    `json:"enabled"`
+}
+
+// AlertListResponse is the response for a list of alerts
+type AlertListResponse struct {
+	Alerts   []*Alert `json:"alerts"`
+	Total    int64    `json:"total"`
+	Page     int      `json:"page"`
+	PageSize int      `json:"page_size"`
+}
+
+// CreateIntegrationRequest is the request body for creating an integration
+type CreateIntegrationRequest struct {
+	Name        string                 `json:"name" binding:"required"`
+	Type        string                 `json:"type" binding:"required"`
+	TeamID      *uint                  `json:"team_id"`
+	Config      map[string]interface{} `json:"config" binding:"required"`
+	Credentials map[string]interface{} `json:"credentials"`
+}
+
+// UpdateIntegrationRequest is the request body for updating an integration
+type UpdateIntegrationRequest struct {
+	Name        *string                `json:"name"`
+	Enabled     *bool                  `json:"enabled"`
+	Config      map[string]interface{} `json:"config"`
+	Credentials map[string]interface{} `json:"credentials"`
+}
+
+// ReplayEventsRequest is the request body for replaying exported events.
+// Exactly one of FromEventID or Since must be set.
+type ReplayEventsRequest struct {
+	FromEventID *uint      `json:"from_event_id"`
+	Since       *time.Time `json:"since"`
+}
+
+// UpsertRetentionPolicyRequest is the request body for configuring a retention policy
+type UpsertRetentionPolicyRequest struct {
+	RetentionDays  int    `json:"retention_days" binding:"required,min=1"`
+	ArchiveEnabled bool   `json:"archive_enabled"`
+	ArchiveFormat  string `json:"archive_format" binding:"omitempty,oneof=jsonl"`
+	Enabled        *bool  `json:"enabled"`
+}
+
 // ResourceListResponse is the response for a list of resources
 type ResourceListResponse struct {
 	Resources []*ResourceResponse `json:"resources"`
@@ -195,9 +1208,947 @@ type ResourceListResponse struct {
 	PageSize  int                 `json:"page_size"`
 }
 
-// ErrorResponse is a standard error response
-type ErrorResponse struct {
-	Error   string `json:"error"`
-	Message string `json:"message"`
-	Details string `json:"details,omitempty"`
+// TrashedResourceResponse is a soft-deleted resource that can still be restored
+type TrashedResourceResponse struct {
+	*ResourceResponse
+	PurgeAt time.Time `json:"purge_at"`
+}
+
+// TrashListResponse is the response for the list of soft-deleted resources
+type TrashListResponse struct {
+	Resources       []*TrashedResourceResponse `json:"resources"`
+	RetentionPeriod string                     `json:"retention_period"`
+}
+
+// TeamDependenciesResponse describes what blocks a team from being deleted
+type TeamDependenciesResponse struct {
+	TeamID          uint  `json:"team_id"`
+	ActiveResources int64 `json:"active_resources"`
+	AlertRules      int64 `json:"alert_rules"`
+	Integrations    int64 `json:"integrations"`
+	Members         int64 `json:"members"`
+}
+
+// EnvironmentRequest describes one stage of a team's promotion pipeline
+type EnvironmentRequest struct {
+	Name              string `json:"name" binding:"required"`
+	BackupSchedule    string `json:"backup_schedule"`
+	HighAvailability  bool   `json:"high_availability"`
+	RequiresApproval  bool   `json:"requires_approval"`
+	MaintenanceWindow string `json:"maintenance_window"`
+}
+
+// SetEnvironmentsRequest replaces a team's promotion pipeline, in order
+type SetEnvironmentsRequest struct {
+	Environments []EnvironmentRequest `json:"environments" binding:"required,min=1,dive"`
+}
+
+// PromoteResourceRequest is the request body for promoting a resource. The
+// target defaults to the next environment in the team's pipeline.
+type PromoteResourceRequest struct {
+	TargetEnvironment string `json:"target_environment"`
+	DryRun            bool   `json:"dry_run"`
+}
+
+// ConfigChange is a single config key that differs between environments
+type ConfigChange struct {
+	Key  string      `json:"key"`
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// PromotionResponse previews or reports a resource promotion
+type PromotionResponse struct {
+	SourceResourceID  uint              `json:"source_resource_id"`
+	SourceEnvironment string            `json:"source_environment"`
+	TargetEnvironment string            `json:"target_environment"`
+	TargetResourceID  *uint             `json:"target_resource_id,omitempty"`
+	RequiresApproval  bool              `json:"requires_approval"`
+	Applied           bool              `json:"applied"`
+	Changes           []ConfigChange    `json:"changes"`
+	Resource          *ResourceResponse `json:"resource,omitempty"`
+
+	// The approval ticket an unapplied promotion waits on
+	Ticket *Ticket `json:"ticket,omitempty"`
+}
+
+// AdminOverviewResponse summarises the health of the whole installation
+type AdminOverviewResponse struct {
+	GeneratedAt  time.Time           `json:"generated_at"`
+	Resources    ResourceOverview    `json:"resources"`
+	Jobs         JobOverview         `json:"jobs"`
+	Certificates CertificateOverview `json:"certificates"`
+	Backups      BackupOverview      `json:"backups"`
+	License      LicenseOverview     `json:"license"`
+	Controller   ControllerOverview  `json:"controller"`
+}
+
+// ResourceOverview counts live resources by status, type, and team
+type ResourceOverview struct {
+	Total    int64            `json:"total"`
+	ByStatus map[string]int64 `json:"by_status"`
+	ByType   map[string]int64 `json:"by_type"`
+	ByTeam   map[string]int64 `json:"by_team"`
+}
+
+// JobOverview counts queued and recently failed jobs
+type JobOverview struct {
+	PendingProvisioning int64 `json:"pending_provisioning"`
+	FailedProvisioning  int64 `json:"failed_provisioning"`
+	FailedBackups       int64 `json:"failed_backups"`
+}
+
+// CertificateOverview counts certificates nearing or past expiry
+type CertificateOverview struct {
+	Expiring int64 `json:"expiring"`
+	Expired  int64 `json:"expired"`
+}
+
+// BackupOverview reports how many backup-capable resources have a recent backup
+type BackupOverview struct {
+	Eligible int64   `json:"eligible"`
+	Covered  int64   `json:"covered"`
+	Coverage float64 `json:"coverage"`
+}
+
+// LicenseOverview reports license tier and user utilization
+type LicenseOverview struct {
+	Valid       bool      `json:"valid"`
+	Tier        string    `json:"tier"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	Users       int64     `json:"users"`
+	MaxUsers    int       `json:"max_users"`
+	Utilization float64   `json:"utilization"`
+}
+
+// ControllerOverview reports the health of the K8s controller fleet
+type ControllerOverview struct {
+	Instances  int        `json:"instances"`
+	Stale      int        `json:"stale"`
+	LastSeenAt *time.Time `json:"last_seen_at,omitempty"`
+	Healthy    bool       `json:"healthy"`
+}
+
+// ControllerStatusResponse is a controller instance with its derived health
+type ControllerStatusResponse struct {
+	*ControllerInstance
+	Stale bool `json:"stale"`
+	// Compatible reports whether the API's schema supports the schema
+	// version the controller was built for
+	Compatible bool `json:"compatible"`
+}
+
+// VersionResponse describes the API's build and the schema versions it
+// supports
+type VersionResponse struct {
+	Version                    string `json:"version"`
+	BuildTime                  string `json:"build_time"`
+	GitCommit                  string `json:"git_commit"`
+	GoVersion                  string `json:"go_version"`
+	SchemaVersion              int    `json:"schema_version"`
+	MinControllerSchemaVersion int    `json:"min_controller_schema_version"`
+}
+
+// AgentStatusResponse is an agent with its derived health
+type AgentStatusResponse struct {
+	*Agent
+	Resources int64 `json:"resources"`
+	Stale     bool  `json:"stale"`
+}
+
+// ControllerListResponse is the response for listing controller instances
+type ControllerListResponse struct {
+	Controllers []*ControllerStatusResponse `json:"controllers"`
+	RetryQueue  []*ReconcileStatus          `json:"retry_queue"`
+	StaleAfter  string                      `json:"stale_after"`
+}
+
+// ReconcileStatusResponse reports a resource's reconcile state and any
+// request still waiting for the controller
+type ReconcileStatusResponse struct {
+	*ReconcileStatus
+	ResourceID  uint       `json:"resource_id"`
+	Pending     bool       `json:"pending"`
+	RequestedAt *time.Time `json:"requested_at,omitempty"`
+}
+
+// DeletionProgressResponse reports where a resource is in the deletion lifecycle
+type DeletionProgressResponse struct {
+	ResourceID         uint       `json:"resource_id"`
+	State              string     `json:"state"`
+	DeletionProtection bool       `json:"deletion_protection"`
+	DeletedAt          *time.Time `json:"deleted_at,omitempty"`
+	PurgeAt            *time.Time `json:"purge_at,omitempty"`
+	Restorable         bool       `json:"restorable"`
+	Finalizers         []string   `json:"finalizers"`
+}
+
+// CreateImageRegistryRequest is the request body for creating an image registry
+type CreateImageRegistryRequest struct {
+	Name         string `json:"name" binding:"required"`
+	TeamID       *uint  `json:"team_id"`
+	Cluster      string `json:"cluster"`
+	MirrorPrefix string `json:"mirror_prefix" binding:"required"`
+	Username     string `json:"username"`
+	Password     string `json:"password"`
+}
+
+// UpdateImageRegistryRequest is the request body for updating an image registry
+type UpdateImageRegistryRequest struct {
+	Name         *string `json:"name"`
+	Cluster      *string `json:"cluster"`
+	MirrorPrefix *string `json:"mirror_prefix"`
+	Username     *string `json:"username"`
+	Password     *string `json:"password"`
+}
+
+// RegistryDryRunRequest is the request body for a registry dry-run. Without
+// images, the default images of the resource type (or of every type) are
+// checked.
+type RegistryDryRunRequest struct {
+	ResourceType string   `json:"resource_type"`
+	Images       []string `json:"images"`
+}
+
+// ImageCheckResult reports whether a mirrored image is available
+type ImageCheckResult struct {
+	Image     string `json:"image"`
+	Mirrored  string `json:"mirrored"`
+	Available bool   `json:"available"`
+	Error     string `json:"error,omitempty"`
+}
+
+// RegistryDryRunResponse is the result of a registry dry-run
+type RegistryDryRunResponse struct {
+	RegistryID   uint               `json:"registry_id"`
+	AllAvailable bool               `json:"all_available"`
+	Images       []ImageCheckResult `json:"images"`
+}
+
+// SecurityComplianceEntry lists the hardening gaps of a single resource
+type SecurityComplianceEntry struct {
+	ResourceID  uint     `json:"resource_id"`
+	Name        string   `json:"name"`
+	TeamID      uint     `json:"team_id"`
+	Environment string   `json:"environment"`
+	Findings    []string `json:"findings"`
+}
+
+// ConsistencyDiscrepancyEntry is a discrepancy of a consistency report with
+// the suggested remediation
+type ConsistencyDiscrepancyEntry struct {
+	ConsistencyDiscrepancy
+	Remediation string `json:"remediation"`
+}
+
+// ConsistencyReportResponse is a cluster's latest consistency report.
+// Unreadable is set when the report's discrepancies don't decode, so that
+// it isn't taken for a clean report.
+type ConsistencyReportResponse struct {
+	*ConsistencyReport
+	Discrepancies []ConsistencyDiscrepancyEntry `json:"discrepancies"`
+	Unreadable    bool                          `json:"unreadable,omitempty"`
+}
+
+// UnusedAccount is a database account of a team's resource not seen
+// connected for the access review's period. LastSeenAt is unset for
+// accounts never seen.
+type UnusedAccount struct {
+	ResourceUserID uint       `json:"resource_user_id"`
+	ResourceID     uint       `json:"resource_id"`
+	ResourceName   string     `json:"resource_name"`
+	Environment    string     `json:"environment"`
+	Username       string     `json:"username"`
+	CreatedAt      time.Time  `json:"created_at"`
+	LastSeenAt     *time.Time `json:"last_seen_at"`
+}
+
+// SecurityComplianceResponse reports full lifecycle resources whose pods do
+// not meet the hardening baseline
+type SecurityComplianceResponse struct {
+	CheckedResources int64                     `json:"checked_resources"`
+	NonCompliant     int                       `json:"non_compliant"`
+	Resources        []SecurityComplianceEntry `json:"resources"`
+}
+
+// InjectedContainer describes an init container or sidecar, either in the
+// init_containers and sidecars lists of a resource Config or in a container
+// policy
+type InjectedContainer struct {
+	Name    string            `json:"name" binding:"required"`
+	Image   string            `json:"image" binding:"required"`
+	Command []string          `json:"command,omitempty"`
+	Args    []string          `json:"args,omitempty"`
+	Env     map[string]string `json:"env,omitempty"`
+}
+
+// CreateContainerPolicyRequest is the request body for creating a team
+// container policy
+type CreateContainerPolicyRequest struct {
+	InjectedContainer
+	Kind          string   `json:"kind" binding:"required"`
+	ResourceTypes []string `json:"resource_types"`
+}
+
+// CreateAllowedImageRequest is the request body for allowlisting an image
+type CreateAllowedImageRequest struct {
+	Pattern     string `json:"pattern" binding:"required"`
+	Description string `json:"description"`
+}
+
+// SizeClassRequest describes one size class of a resource type
+type SizeClassRequest struct {
+	Name    string                 `json:"name" binding:"required"`
+	CPU     string                 `json:"cpu" binding:"required"`
+	Memory  string                 `json:"memory" binding:"required"`
+	Storage string                 `json:"storage"`
+	Tuning  map[string]interface{} `json:"tuning"`
+}
+
+// SetSizeClassesRequest replaces a resource type's size classes, in order
+type SetSizeClassesRequest struct {
+	SizeClasses []SizeClassRequest `json:"size_classes" binding:"required,min=1,dive"`
+}
+
+// ResizeResourceRequest moves a resource to another size class. Resources
+// is required for the custom class and holds cpu, memory, and storage.
+type ResizeResourceRequest struct {
+	SizeClass string            `json:"size_class" binding:"required"`
+	Resources map[string]string `json:"resources"`
+}
+
+// UsageReportPreviewResponse shows the usage report payload as it would be
+// sent to the license server
+type UsageReportPreviewResponse struct {
+	Enabled    bool                   `json:"enabled"`
+	OptedOut   bool                   `json:"opted_out"`
+	Interval   string                 `json:"interval"`
+	LastSentAt *time.Time             `json:"last_sent_at,omitempty"`
+	LastError  string                 `json:"last_error,omitempty"`
+	Payload    map[string]interface{} `json:"payload"`
+}
+
+// UpsertFeatureFlagRequest sets a feature flag's rollout. A disabled flag is
+// off for every team; an enabled one is on for the listed teams and for the
+// given percentage of all others.
+type UpsertFeatureFlagRequest struct {
+	Description string `json:"description"`
+	Enabled     bool   `json:"enabled"`
+	Percentage  int    `json:"percentage" binding:"min=0,max=100"`
+	Teams       []uint `json:"teams"`
+}
+
+// FeatureFlagResponse describes a feature flag with its code default and
+// rollout override, if any
+type FeatureFlagResponse struct {
+	Key         string       `json:"key"`
+	Description string       `json:"description"`
+	Default     bool         `json:"default"`
+	Override    *FeatureFlag `json:"override,omitempty"`
+}
+
+// SetLogLevelRequest overrides the log level of a service. Level applies to
+// the components not listed, or leaves them at LOG_LEVEL when empty. The
+// override is removed after Duration, when set.
+type SetLogLevelRequest struct {
+	Level      string            `json:"level"`
+	Components map[string]string `json:"components"`
+	Duration   string            `json:"duration"`
+}
+
+// LogLevelResponse describes a service's log level: its components and the
+// override in effect, if any
+type LogLevelResponse struct {
+	Service    string            `json:"service"`
+	Components []string          `json:"components"`
+	Override   *LogLevelOverride `json:"override,omitempty"`
+}
+
+// CreateTenantRequest creates a tenant. Requests whose Host is one of the
+// tenant's hosts, or whose token carries its slug, are scoped to it.
+type CreateTenantRequest struct {
+	Name  string   `json:"name" binding:"required"`
+	Slug  string   `json:"slug" binding:"required"`
+	Hosts []string `json:"hosts"`
+}
+
+// PermissionCheck is one step of a permission evaluation. Result is pass,
+// fail, or skip.
+type PermissionCheck struct {
+	Check  string `json:"check"`
+	Result string `json:"result"`
+	Detail string `json:"detail"`
+}
+
+// PermissionExplanation is the trace of whether a user may perform an action
+// on a resource. The action is allowed when no check failed.
+type PermissionExplanation struct {
+	UserID     uint              `json:"user_id"`
+	ResourceID uint              `json:"resource_id"`
+	Action     string            `json:"action"`
+	Allowed    bool              `json:"allowed"`
+	Checks     []PermissionCheck `json:"checks"`
+}
+
+// MembershipSummary is a team the caller belongs to and their role in it
+type MembershipSummary struct {
+	TeamID   uint   `json:"team_id"`
+	TeamName string `json:"team_name"`
+	IsGlobal bool   `json:"is_global"`
+	Role     string `json:"role"`
+}
+
+// MeResponse describes the caller and everything they can access
+type MeResponse struct {
+	User              User                `json:"user"`
+	GlobalRole        string              `json:"global_role"`
+	GlobalAdmin       bool                `json:"global_admin"`
+	TenantID          *uint               `json:"tenant_id,omitempty"`
+	PasswordExpiresAt *time.Time          `json:"password_expires_at,omitempty"`
+	Memberships       []MembershipSummary `json:"memberships"`
+	LicenseFeatures   []string            `json:"license_features"`
+}
+
+// AuditLogResponse is an audit log entry with its recorded changes rendered
+// as readable lines
+type AuditLogResponse struct {
+	*database.AuditLog
+	Diff []string `json:"diff,omitempty"`
+}
+
+// AuditLogListResponse is the response for a list of audit log entries
+type AuditLogListResponse struct {
+	Entries  []*AuditLogResponse `json:"entries"`
+	Total    int64               `json:"total"`
+	Page     int                 `json:"page"`
+	PageSize int                 `json:"page_size"`
+}
+
+// AuditChainProblem is an audit log entry that fails chain verification
+type AuditChainProblem struct {
+	EntryID  uint   `json:"entry_id"`
+	ChainSeq int64  `json:"chain_seq"`
+	Problem  string `json:"problem"`
+}
+
+// AuditChainReport is the result of verifying the audit log hash chain.
+// Unchained entries were written before chaining was enabled; erased ones
+// had their personal data erased, which the chain still accounts for.
+type AuditChainReport struct {
+	Valid      bool                `json:"valid"`
+	Verified   int64               `json:"verified"`
+	Erased     int64               `json:"erased"`
+	Unchained  int64               `json:"unchained"`
+	FirstSeq   int64               `json:"first_seq,omitempty"`
+	HeadSeq    int64               `json:"head_seq,omitempty"`
+	HeadHash   string              `json:"head_hash,omitempty"`
+	LastAnchor *AuditAnchor        `json:"last_anchor,omitempty"`
+	Problems   []AuditChainProblem `json:"problems"`
+}
+
+// UserDataExport is the personal data held about a user, for data subject
+// access requests
+type UserDataExport struct {
+	ExportedAt   time.Time           `json:"exported_at"`
+	User         User                `json:"user"`
+	Memberships  []MembershipSummary `json:"memberships"`
+	AuditEntries []database.AuditLog `json:"audit_entries"`
+	Preferences  []UserPreference    `json:"preferences"`
+	SavedViews   []SavedView         `json:"saved_views"`
+}
+
+// ErasureImpact counts what erasing a user's personal data changes
+type ErasureImpact struct {
+	Memberships  int64 `json:"memberships"`
+	Sessions     int64 `json:"sessions"`
+	AuditEntries int64 `json:"audit_entries"`
+	Preferences  int64 `json:"preferences"`
+	SavedViews   int64 `json:"saved_views"`
+}
+
+// ErasureRequestResponse is a new erasure request with the token that
+// confirms it, which is only ever returned here
+type ErasureRequestResponse struct {
+	*ErasureRequest
+	ConfirmationToken string        `json:"confirmation_token"`
+	Impact            ErasureImpact `json:"impact"`
+}
+
+// ConfirmErasureRequest is the request body for confirming an erasure. The
+// user's username must be repeated to guard against erasing the wrong user.
+type ConfirmErasureRequest struct {
+	ConfirmationToken string `json:"confirmation_token" binding:"required"`
+	Username          string `json:"username" binding:"required"`
+}
+
+// NetworkAccessRuleRequest is the request body for creating or updating a
+// network access rule. A single address is taken as a /32 or /128 block.
+type NetworkAccessRuleRequest struct {
+	Action      string `json:"action" binding:"required"`
+	CIDR        string `json:"cidr" binding:"required"`
+	Description string `json:"description"`
+	Enabled     *bool  `json:"enabled"`
+}
+
+// WorkloadIdentityRequest is the request body for mapping a SPIFFE ID to a
+// service account
+type WorkloadIdentityRequest struct {
+	SPIFFEID    string `json:"spiffe_id" binding:"required"`
+	UserID      uint   `json:"user_id" binding:"required"`
+	Description string `json:"description"`
+}
+
+// AgentRegisterRequest is the request body a nest-agent registers with
+type AgentRegisterRequest struct {
+	Name     string   `json:"name" binding:"required"`
+	Hostname string   `json:"hostname"`
+	Version  string   `json:"version"`
+	Engines  []string `json:"engines"`
+}
+
+// AgentDesiredState is the desired state of every resource assigned to an
+// agent, and the SSH CA keys its host trusts for user certificates
+type AgentDesiredState struct {
+	Agent     string                `json:"agent"`
+	Resources []*AgentResourceState `json:"resources"`
+	SSHCAKeys []string              `json:"ssh_ca_keys,omitempty"`
+}
+
+// AgentResourceState is the desired state of one resource. Users, tuning,
+// backups, and the certificate are only given for partial resources whose
+// capabilities allow the agent to manage them.
+type AgentResourceState struct {
+	ResourceID     uint                   `json:"resource_id"`
+	Name           string                 `json:"name"`
+	Engine         string                 `json:"engine"`
+	LifecycleMode  string                 `json:"lifecycle_mode"`
+	ConnectionInfo map[string]interface{} `json:"connection_info"`
+	Credentials    map[string]interface{} `json:"credentials,omitempty"`
+	Tuning         map[string]interface{} `json:"tuning,omitempty"`
+	Users          []*AgentUser           `json:"users,omitempty"`
+	Backups        []*AgentBackupJob      `json:"backups,omitempty"`
+	Certificate    *AgentCertificate      `json:"certificate,omitempty"`
+}
+
+// AgentUser is a resource user waiting to be synced
+type AgentUser struct {
+	ID       uint     `json:"id"`
+	Username string   `json:"username"`
+	Password string   `json:"password,omitempty"`
+	Roles    []string `json:"roles,omitempty"`
+}
+
+// AgentBackupJob is a backup job handed to an agent to run, which it stops
+// after TimeoutSeconds
+type AgentBackupJob struct {
+	ID             uint   `json:"id"`
+	JobType        string `json:"job_type"`
+	TimeoutSeconds int    `json:"timeout_seconds,omitempty"`
+}
+
+// AgentCertificate is the TLS certificate a resource should serve
+type AgentCertificate struct {
+	ID          uint      `json:"id"`
+	Certificate string    `json:"certificate"`
+	PrivateKey  string    `json:"private_key"`
+	ValidUntil  time.Time `json:"valid_until"`
+}
+
+// AgentReportRequest is the request body an agent reports the outcome of
+// applying its desired state with
+type AgentReportRequest struct {
+	Resources []*AgentResourceReport `json:"resources"`
+}
+
+// AgentResourceReport is an agent's report on one resource. Error is set
+// when the resource couldn't be reached or its state couldn't be applied.
+type AgentResourceReport struct {
+	ResourceID  uint                   `json:"resource_id" binding:"required"`
+	Error       string                 `json:"error"`
+	Metrics     map[string]interface{} `json:"metrics"`
+	RiskLevel   string                 `json:"risk_level"`
+	RiskFactors []string               `json:"risk_factors"`
+	Users       []*AgentTaskResult     `json:"users"`
+	Backups     []*AgentTaskResult     `json:"backups"`
+}
+
+// AgentTaskResult is the outcome of syncing a user or running a backup job
+type AgentTaskResult struct {
+	ID        uint   `json:"id" binding:"required"`
+	Error     string `json:"error"`
+	Location  string `json:"location,omitempty"`
+	SizeBytes int64  `json:"size_bytes,omitempty"`
+}
+
+// CreateDockerHostRequest is the request body for registering a Docker host
+type CreateDockerHostRequest struct {
+	Name             string `json:"name" binding:"required"`
+	Endpoint         string `json:"endpoint" binding:"required"`
+	Runtime          string `json:"runtime"`
+	AdvertiseAddress string `json:"advertise_address"`
+	CACert           string `json:"ca_cert" binding:"required"`
+	ClientCert       string `json:"client_cert" binding:"required"`
+	ClientKey        string `json:"client_key" binding:"required"`
+}
+
+// UpdateDockerHostRequest is the request body for updating a Docker host.
+// The certificate and key are replaced together.
+type UpdateDockerHostRequest struct {
+	Endpoint         *string `json:"endpoint"`
+	AdvertiseAddress *string `json:"advertise_address"`
+	CACert           *string `json:"ca_cert"`
+	ClientCert       *string `json:"client_cert"`
+	ClientKey        *string `json:"client_key"`
+}
+
+// DockerHostResponse is a Docker host with the number of resources on it
+type DockerHostResponse struct {
+	*DockerHost
+	Resources int64 `json:"resources"`
+}
+
+// CreateCloudAccountRequest is the request body for adding a cloud account
+type CreateCloudAccountRequest struct {
+	Name              string `json:"name" binding:"required"`
+	Provider          string `json:"provider" binding:"required"`
+	TeamID            uint   `json:"team_id" binding:"required"`
+	Environment       string `json:"environment"`
+	LifecycleMode     string `json:"lifecycle_mode"`
+	Region            string `json:"region"`
+	AccessKeyID       string `json:"access_key_id"`
+	SecretAccessKey   string `json:"secret_access_key"`
+	Project           string `json:"project"`
+	ServiceAccountKey string `json:"service_account_key"`
+}
+
+// UpdateCloudAccountRequest is the request body for updating a cloud
+// account, such as to rotate its credentials
+type UpdateCloudAccountRequest struct {
+	LifecycleMode     *string `json:"lifecycle_mode"`
+	AccessKeyID       *string `json:"access_key_id"`
+	SecretAccessKey   *string `json:"secret_access_key"`
+	ServiceAccountKey *string `json:"service_account_key"`
+}
+
+// CloudSyncResult summarizes a cloud account sync
+type CloudSyncResult struct {
+	Discovered int      `json:"discovered"`
+	Imported   int      `json:"imported"`
+	Updated    int      `json:"updated"`
+	Missing    int      `json:"missing"`
+	Skipped    []string `json:"skipped,omitempty"`
+}
+
+// AdoptCandidateRequest is the request body for adopting a StatefulSet as a
+// resource
+type AdoptCandidateRequest struct {
+	TeamID        uint                   `json:"team_id" binding:"required"`
+	Environment   string                 `json:"environment"`
+	Name          string                 `json:"name"`
+	LifecycleMode string                 `json:"lifecycle_mode" binding:"omitempty,oneof=partial monitor_only"`
+	Credentials   map[string]interface{} `json:"credentials"`
+}
+
+// IssueSSHCertificateRequest is the request body for issuing an SSH
+// certificate. Logins default to the caller's username; TTL defaults to 1h.
+type IssueSSHCertificateRequest struct {
+	PublicKey string   `json:"public_key" binding:"required"`
+	Logins    []string `json:"logins"`
+	TTL       string   `json:"ttl"`
+}
+
+// UpdateSSHLoginsRequest is the request body for replacing a resource's SSH
+// login allowlist
+type UpdateSSHLoginsRequest struct {
+	Logins []string `json:"logins" binding:"required"`
+}
+
+// SSHLoginsResponse lists the logins team maintainers may request SSH
+// certificates for on a resource's host
+type SSHLoginsResponse struct {
+	Logins []string `json:"logins"`
+}
+
+// SSHCertificateResponse is an issued SSH certificate in authorized_keys
+// format, ready to save as the key's -cert.pub file
+type SSHCertificateResponse struct {
+	*SSHCertificate
+	Certificate string `json:"certificate"`
+}
+
+// SSHCertificateListResponse lists the SSH certificates issued for a resource
+type SSHCertificateListResponse struct {
+	SSHCertificates []*SSHCertificate `json:"ssh_certificates"`
+}
+
+// CreateConsumerBindingRequest is the request body for binding a resource
+// to an application namespace. The Secret name defaults to
+// nest-resource-<id>.
+type CreateConsumerBindingRequest struct {
+	Namespace  string `json:"namespace" binding:"required"`
+	SecretName string `json:"secret_name"`
+}
+
+// ConsumerBindingListResponse lists the consumer bindings of a resource
+type ConsumerBindingListResponse struct {
+	ConsumerBindings []*ConsumerBinding `json:"consumer_bindings"`
+}
+
+// CreateBackstageTokenRequest is the request body for issuing a Backstage
+// token. The scope defaults to catalog, and the token doesn't expire
+// without a TTL.
+type CreateBackstageTokenRequest struct {
+	Name   string `json:"name" binding:"required"`
+	UserID uint   `json:"user_id" binding:"required"`
+	Scope  string `json:"scope"`
+	TTL    string `json:"ttl"`
+}
+
+// BackstageTokenResponse is a newly issued Backstage token, the only time
+// the token itself is returned
+type BackstageTokenResponse struct {
+	*BackstageToken
+	Token string `json:"token"`
+}
+
+// BackstageEntity is a Backstage catalog entity in the shape an entity
+// provider applies
+type BackstageEntity struct {
+	APIVersion string                 `json:"apiVersion"`
+	Kind       string                 `json:"kind"`
+	Metadata   BackstageEntityMeta    `json:"metadata"`
+	Spec       map[string]interface{} `json:"spec"`
+}
+
+// BackstageEntityMeta is the metadata of a Backstage catalog entity
+type BackstageEntityMeta struct {
+	Name        string            `json:"name"`
+	Title       string            `json:"title,omitempty"`
+	Description string            `json:"description,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Tags        []string          `json:"tags,omitempty"`
+}
+
+// EmailTemplateResponse is a mail template as it is sent, and whether it
+// overrides the built-in one
+type EmailTemplateResponse struct {
+	Name       string `json:"name"`
+	Subject    string `json:"subject"`
+	Text       string `json:"text"`
+	HTML       string `json:"html"`
+	Overridden bool   `json:"overridden"`
+}
+
+// EmailTemplateRequest is the request body for overriding a mail template
+type EmailTemplateRequest struct {
+	Subject string `json:"subject" binding:"required"`
+	Text    string `json:"text" binding:"required"`
+	HTML    string `json:"html"`
+}
+
+// TestEmailRequest is the request body for test-sending a mail template.
+// Data replaces the template's sample data when it is given.
+type TestEmailRequest struct {
+	To   string                 `json:"to" binding:"required,email"`
+	Data map[string]interface{} `json:"data"`
+}
+
+// CreateInvitationRequest is the request body for inviting someone to a
+// team
+type CreateInvitationRequest struct {
+	Email string `json:"email" binding:"required,email"`
+	Role  string `json:"role" binding:"required,oneof=admin maintainer contributor viewer"`
+}
+
+// TeamInvitationResponse is a new invitation with the token that accepts
+// it, which is only ever returned here
+type TeamInvitationResponse struct {
+	*TeamInvitation
+	Token     string `json:"token"`
+	AcceptURL string `json:"accept_url,omitempty"`
+}
+
+// AcceptInvitationRequest is the request body for accepting an invitation
+type AcceptInvitationRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// OperationResponse reports an operation's progress, the provisioning job
+// carrying it out, and the current state of its resource
+type OperationResponse struct {
+	*Operation
+	ProvisioningJob *OperationJobResponse `json:"provisioning_job,omitempty"`
+	Resource        *ResourceResponse     `json:"resource,omitempty"`
+}
+
+// OperationJobResponse is the provisioning job the K8s controller runs for
+// an operation
+type OperationJobResponse struct {
+	ID           uint       `json:"id"`
+	JobType      string     `json:"job_type"`
+	Status       string     `json:"status"`
+	StartedAt    *time.Time `json:"started_at,omitempty"`
+	CompletedAt  *time.Time `json:"completed_at,omitempty"`
+	ErrorMessage string     `json:"error_message,omitempty"`
+}
+
+// ApplyResponse reports what an apply did, or would do on a dry run:
+// Changes to the resource's config, and to its other Fields
+type ApplyResponse struct {
+	Action      string            `json:"action"`
+	DryRun      bool              `json:"dry_run"`
+	Changes     []ConfigChange    `json:"changes"`
+	Fields      []ConfigChange    `json:"fields"`
+	Resource    *ResourceResponse `json:"resource"`
+	OperationID *uint             `json:"operation_id,omitempty"`
+}
+
+// PolicyViolation is a violation of a policy by a resource spec
+type PolicyViolation struct {
+	Policy   string `json:"policy"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+// CreatePolicyRequest is the request body for creating a policy
+type CreatePolicyRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+	Rego        string `json:"rego" binding:"required"`
+	Severity    string `json:"severity"`
+	Enabled     *bool  `json:"enabled"`
+}
+
+// UpdatePolicyRequest is the request body for updating a policy
+type UpdatePolicyRequest struct {
+	Description *string `json:"description"`
+	Rego        *string `json:"rego"`
+	Severity    *string `json:"severity"`
+	Enabled     *bool   `json:"enabled"`
+}
+
+// EvaluatePoliciesRequest is the request body for checking a resource
+// against the enabled policies
+type EvaluatePoliciesRequest struct {
+	ResourceID uint `json:"resource_id" binding:"required"`
+}
+
+// CreateValidationWebhookRequest is the request body for registering a
+// validation webhook
+type CreateValidationWebhookRequest struct {
+	Name           string   `json:"name" binding:"required"`
+	URL            string   `json:"url" binding:"required"`
+	Secret         string   `json:"secret"`
+	Operations     []string `json:"operations"`
+	TimeoutSeconds int      `json:"timeout_seconds"`
+	FailurePolicy  string   `json:"failure_policy"`
+	Enabled        *bool    `json:"enabled"`
+}
+
+// UpdateValidationWebhookRequest is the request body for updating a
+// validation webhook
+type UpdateValidationWebhookRequest struct {
+	URL            *string   `json:"url"`
+	Secret         *string   `json:"secret"`
+	Operations     *[]string `json:"operations"`
+	TimeoutSeconds *int      `json:"timeout_seconds"`
+	FailurePolicy  *string   `json:"failure_policy"`
+	Enabled        *bool     `json:"enabled"`
+}
+
+// DiagnosticsResponse is a snapshot of the API replica's runtime, database
+// connection pools and queues
+type DiagnosticsResponse struct {
+	Runtime  diagnostics.RuntimeStats         `json:"runtime"`
+	Database map[string]diagnostics.PoolStats `json:"database"`
+	Queues   QueueDiagnostics                 `json:"queues"`
+}
+
+// QueueDiagnostics are the depths of the queues the API and the K8s
+// controller work through, shared by every replica
+type QueueDiagnostics struct {
+	// Jobs counts the queued, running and dead-lettered jobs by status and
+	// kind
+	Jobs              map[string]map[string]int64 `json:"jobs"`
+	DueJobs           int64                       `json:"due_jobs"`
+	ReconcileRequests int64                       `json:"reconcile_requests"`
+	Operations        map[string]int64            `json:"operations"`
+}
+
+// ProvisioningJobResponse is a provisioning job the K8s controller ran for a
+// resource, with its log
+type ProvisioningJobResponse struct {
+	ID           uint       `json:"id"`
+	JobType      string     `json:"job_type"`
+	Status       string     `json:"status"`
+	StartedAt    *time.Time `json:"started_at,omitempty"`
+	CompletedAt  *time.Time `json:"completed_at,omitempty"`
+	Logs         string     `json:"logs,omitempty"`
+	ErrorMessage string     `json:"error_message,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// ProvisioningJobListResponse is a page of a resource's provisioning jobs
+type ProvisioningJobListResponse struct {
+	Jobs  []*ProvisioningJobResponse `json:"jobs"`
+	Total int64                      `json:"total"`
+}
+
+// CertificateSummary is a resource's TLS certificate and how long it has
+// left, without the certificate itself
+type CertificateSummary struct {
+	ID            uint      `json:"id"`
+	CommonName    string    `json:"common_name"`
+	SerialNumber  string    `json:"serial_number"`
+	ValidFrom     time.Time `json:"valid_from"`
+	ValidUntil    time.Time `json:"valid_until"`
+	AutoRenew     bool      `json:"auto_renew"`
+	Expired       bool      `json:"expired" gorm:"-"`
+	DaysRemaining int       `json:"days_remaining" gorm:"-"`
+	ResourceID    uint      `json:"resource_id"`
+	Resource      string    `json:"resource"`
+	Environment   string    `json:"environment"`
+	TeamID        uint      `json:"team_id"`
+	Team          string    `json:"team"`
+}
+
+// CertificateListResponse is a page of certificates, soonest to expire first
+type CertificateListResponse struct {
+	Certificates []*CertificateSummary `json:"certificates"`
+	Total        int64                 `json:"total"`
+	Page         int                   `json:"page"`
+	PageSize     int                   `json:"page_size"`
+}
+
+// SavedViewRequest is the request body for saving a view. Filters are the
+// list's query parameters by name.
+type SavedViewRequest struct {
+	Name      string            `json:"name" binding:"required,min=1,max=100"`
+	Target    string            `json:"target" binding:"required"`
+	Filters   map[string]string `json:"filters"`
+	Columns   []string          `json:"columns"`
+	IsDefault bool              `json:"is_default"`
+}
+
+// AnnouncementRequest is the request body for creating or replacing an
+// announcement. starts_at defaults to now, and without ends_at the
+// announcement shows until it is removed.
+type AnnouncementRequest struct {
+	Title    string     `json:"title" binding:"required,max=200"`
+	Message  string     `json:"message" binding:"required"`
+	Severity string     `json:"severity" binding:"required,oneof=info warning critical"`
+	StartsAt *time.Time `json:"starts_at"`
+	EndsAt   *time.Time `json:"ends_at"`
+}
+
+// ActiveAnnouncement is an announcement being shown, with whether the
+// caller has acknowledged it
+type ActiveAnnouncement struct {
+	ID           uint       `json:"id"`
+	Title        string     `json:"title"`
+	Message      string     `json:"message"`
+	Severity     string     `json:"severity"`
+	StartsAt     time.Time  `json:"starts_at"`
+	EndsAt       *time.Time `json:"ends_at,omitempty"`
+	Acknowledged bool       `json:"acknowledged"`
 }
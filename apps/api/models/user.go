@@ -16,6 +16,8 @@ type User struct {
 	Password  string    `gorm:"not null" json:"-"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+
+	PasswordChangedAt *time.Time `json:"password_changed_at,omitempty"`
 }
 
 // TableName sets the table name
@@ -23,11 +25,13 @@ func (User) TableName() string {
 	return "users"
 }
 
-// BeforeSave hook to hash password
+// BeforeSave hook to hash password and record when it changed
 func (u *User) BeforeSave(tx *gorm.DB) error {
 	if tx.Statement.Changed("Password") {
 		hash := sha256.Sum256([]byte(u.Password))
 		u.Password = hex.EncodeToString(hash[:])
+		now := time.Now().UTC()
+		u.PasswordChangedAt = &now
 	}
 	return nil
 }
@@ -43,4 +47,5 @@ type UserClaims struct {
 	UserID   uint   `json:"user_id"`
 	Username string `json:"username"`
 	Email    string `json:"email"`
+	Tenant   string `json:"tenant,omitempty"`
 }
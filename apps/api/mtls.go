@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/penguintechinc/project-template/shared/apierrors"
+	"github.com/penguintechinc/project-template/shared/mtls"
+)
+
+// PeerCertificateMiddleware refuses requests whose connection presented no
+// client certificate issued from the internal CA. Workloads already
+// authenticated by WorkloadIdentityMiddleware are let through. When allowed
+// names any services, only their certificates are accepted. The caller's
+// service name is set as peer_service.
+func PeerCertificateMiddleware(identity *mtls.Identity, allowed []string) gin.HandlerFunc {
+	allowedPeers := make(map[string]bool)
+	for _, name := range allowed {
+		if name = strings.TrimSpace(name); name != "" {
+			allowedPeers[name] = true
+		}
+	}
+
+	return func(c *gin.Context) {
+		if _, ok := c.Get("workload_identity"); ok {
+			c.Next()
+			return
+		}
+		if c.Request.TLS == nil || len(c.Request.TLS.VerifiedChains) == 0 {
+			apierrors.Abort(c, http.StatusUnauthorized, "client_certificate_required", "A client certificate is required")
+			return
+		}
+
+		// The handshake also accepts certificates from SPIFFE trust bundles,
+		// so the issuer is checked again against the internal CA alone
+		peer, err := identity.VerifyPeer(c.Request.TLS.PeerCertificates)
+		if err != nil || (len(allowedPeers) > 0 && !allowedPeers[peer]) {
+			apierrors.Abort(c, http.StatusForbidden, apierrors.CodeForbidden, "Client certificate is not allowed")
+			return
+		}
+
+		c.Set("peer_service", peer)
+		c.Next()
+	}
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/penguintechinc/project-template/shared/apierrors"
+	"github.com/penguintechinc/project-template/shared/audit"
+	"github.com/penguintechinc/project-template/shared/database"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// parseNetwork parses a CIDR block, taking a single address as a /32 or
+// /128 block, and returns it in canonical form
+func parseNetwork(cidr string) (*net.IPNet, error) {
+	cidr = strings.TrimSpace(cidr)
+	if !strings.Contains(cidr, "/") {
+		ip := net.ParseIP(cidr)
+		if ip == nil {
+			return nil, fmt.Errorf("%q is not an IP address or CIDR block", cidr)
+		}
+		if v4 := ip.To4(); v4 != nil {
+			return &net.IPNet{IP: v4, Mask: net.CIDRMask(32, 32)}, nil
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, nil
+	}
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, fmt.Errorf("%q is not an IP address or CIDR block", cidr)
+	}
+	return network, nil
+}
+
+// ruleMatches reports whether a rule's block contains the address. Rules
+// whose block no longer parses match nothing.
+func ruleMatches(rule NetworkAccessRule, ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	network, err := parseNetwork(rule.CIDR)
+	if err != nil {
+		return false
+	}
+	return network.Contains(ip)
+}
+
+// evaluateNetworkRules decides whether a request from an address is
+// allowed. The global rules and the rules of each team the request is about
+// are checked in turn: a matching deny rule blocks the request, and a scope
+// with allow rules blocks addresses none of them match. It returns whether
+// the request is blocked and the deny rule that blocked it, if any.
+func evaluateNetworkRules(rules []NetworkAccessRule, ip net.IP, teamIDs []uint) (bool, *NetworkAccessRule) {
+	scopes := []*uint{nil}
+	for i := range teamIDs {
+		scopes = append(scopes, &teamIDs[i])
+	}
+
+	for _, scope := range scopes {
+		hasAllow, allowed := false, false
+		for i := range rules {
+			rule := rules[i]
+			if !rule.Enabled || !sameTeam(rule.TeamID, scope) {
+				continue
+			}
+			switch rule.Action {
+			case NetworkDeny:
+				if ruleMatches(rule, ip) {
+					return true, &rule
+				}
+			case NetworkAllow:
+				hasAllow = true
+				allowed = allowed || ruleMatches(rule, ip)
+			}
+		}
+		if hasAllow && !allowed {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// sameTeam reports whether two optional team IDs are equal
+func sameTeam(a, b *uint) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+// globalOnlyRoutes are the routes, with the routes under them, only the
+// global rules apply to: those whose responses hold no team's data, and the
+// admin routes, which a team's rules must not close to global admins
+var globalOnlyRoutes = []string{
+	"/api/v1/status",
+	"/api/v1/version",
+	"/api/v1/features",
+	"/api/v1/me",
+	"/api/v1/announcements",
+	"/api/v1/invitations/accept",
+	"/api/v1/admin",
+}
+
+// requestTeams returns the teams whose rules apply to a request: the team of
+// a team route, the owner of a resource route, or the team_id query
+// parameter. Other requests can return the data of any team the caller
+// belongs to, so the rules of those teams apply; global admins, and callers
+// not yet identified, are held to the global rules only.
+func requestTeams(c *gin.Context, db *gorm.DB, access *AccessCache) ([]uint, error) {
+	path := c.FullPath()
+	for _, route := range globalOnlyRoutes {
+		if path == route || strings.HasPrefix(path, route+"/") {
+			return nil, nil
+		}
+	}
+
+	switch {
+	case strings.HasPrefix(path, "/api/v1/teams/:id"):
+		if id, err := strconv.ParseUint(c.Param("id"), 10, 32); err == nil {
+			return []uint{uint(id)}, nil
+		}
+	case strings.HasPrefix(path, "/api/v1/resources/:id"):
+		var teamIDs []uint
+		if err := tenantDB(c, db).Unscoped().Model(&Resource{}).Where("id = ?", c.Param("id")).
+			Limit(1).Pluck("team_id", &teamIDs).Error; err != nil {
+			return nil, fmt.Errorf("failed to retrieve team of resource %s: %w", c.Param("id"), err)
+		} else if len(teamIDs) > 0 {
+			return teamIDs, nil
+		}
+	}
+	if v := c.Query("team_id"); v != "" {
+		if id, err := strconv.ParseUint(v, 10, 32); err == nil {
+			return []uint{uint(id)}, nil
+		}
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		return nil, nil
+	}
+	if userRole, _ := c.Get("user_role"); hasMinimumRole(userRole, "admin") {
+		return nil, nil
+	}
+	roles, err := access.TeamRoles(c.Request.Context(), userID.(uint))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load teams of user %d: %w", userID.(uint), err)
+	}
+	teamIDs := make([]uint, 0, len(roles))
+	for teamID := range roles {
+		teamIDs = append(teamIDs, teamID)
+	}
+	sort.Slice(teamIDs, func(i, j int) bool { return teamIDs[i] < teamIDs[j] })
+	return teamIDs, nil
+}
+
+// NetworkAccessMiddleware refuses requests from addresses the network
+// access rules don't allow and records each refusal in the audit log. The
+// client address is taken from X-Forwarded-For only when the request came
+// through a trusted proxy.
+func NetworkAccessMiddleware(db *gorm.DB, access *AccessCache) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rules, err := access.NetworkRules(c.Request.Context())
+		if err != nil {
+			log.Printf("Error loading network access rules: %v", err)
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to load network access rules")
+			return
+		}
+		if len(rules) == 0 {
+			c.Next()
+			return
+		}
+
+		teamIDs, err := requestTeams(c, db, access)
+		if err != nil {
+			log.Printf("Error resolving the teams of a request: %v", err)
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to check network access rules")
+			return
+		}
+		blocked, rule := evaluateNetworkRules(rules, net.ParseIP(c.ClientIP()), teamIDs)
+		if !blocked {
+			c.Next()
+			return
+		}
+
+		var teamID *uint
+		switch {
+		case rule != nil:
+			teamID = rule.TeamID
+		case len(teamIDs) == 1:
+			teamID = &teamIDs[0]
+		}
+
+		if err := recordBlockedRequest(c, db, teamID, rule); err != nil {
+			log.Printf("Error recording blocked request from %s in the audit log: %v", c.ClientIP(), err)
+		}
+		apierrors.Abort(c, http.StatusForbidden, "network_blocked", "Access from this network address is not allowed")
+	}
+}
+
+// recordBlockedRequest writes an audit log entry for a request refused by
+// the network access rules. The entry names the deny rule that matched, or
+// none when the address was missing from an allowlist.
+func recordBlockedRequest(c *gin.Context, db *gorm.DB, teamID *uint, rule *NetworkAccessRule) error {
+	details := map[string]interface{}{
+		"method": c.Request.Method,
+		"path":   c.Request.URL.Path,
+	}
+	entry := database.AuditLog{
+		Action:       audit.ActionBlocked,
+		ResourceType: "network_access_rules",
+		TeamID:       teamID,
+		IPAddress:    c.ClientIP(),
+		UserAgent:    c.Request.UserAgent(),
+		Timestamp:    time.Now().UTC(),
+	}
+	if rule != nil {
+		entry.ResourceID = &rule.ID
+		details["cidr"] = rule.CIDR
+	}
+	if userID, exists := c.Get("user_id"); exists {
+		if id, ok := userID.(uint); ok {
+			entry.UserID = &id
+		}
+	}
+	encoded, err := json.Marshal(details)
+	if err != nil {
+		return err
+	}
+	entry.Details = datatypes.JSON(encoded)
+	return tenantDB(c, db).WithContext(c.Request.Context()).Create(&entry).Error
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/penguintechinc/project-template/shared/apierrors"
+	"github.com/penguintechinc/project-template/shared/audit"
+	"gorm.io/gorm"
+)
+
+// NetworkAccessController handles the global and team network access rule
+// HTTP requests
+type NetworkAccessController struct {
+	db     *gorm.DB
+	access *AccessCache
+}
+
+// NewNetworkAccessController creates a new network access controller
+func NewNetworkAccessController(db *gorm.DB, access *AccessCache) *NetworkAccessController {
+	return &NetworkAccessController{db: db, access: access}
+}
+
+// teamAdminAccess returns the team of a team route when the caller is a
+// team admin
+func (nc *NetworkAccessController) teamAdminAccess(c *gin.Context) (*uint, bool) {
+	teamID, role, ok := teamAccess(c, nc.access)
+	if !ok {
+		return nil, false
+	}
+	if !hasMinimumRole(role, "admin") {
+		apierrors.Abort(c, http.StatusForbidden, apierrors.CodeForbidden, "Team admin access required")
+		return nil, false
+	}
+	return &teamID, true
+}
+
+// scopeQuery restricts a query to the rules of a team, or to the global
+// rules when teamID is nil
+func scopeQuery(db *gorm.DB, teamID *uint) *gorm.DB {
+	if teamID == nil {
+		return db.Where("team_id IS NULL")
+	}
+	return db.Where("team_id = ?", *teamID)
+}
+
+// listRules writes the rules of a scope
+func (nc *NetworkAccessController) listRules(c *gin.Context, teamID *uint) {
+	var rules []*NetworkAccessRule
+	if err := scopeQuery(tenantDB(c, nc.db), teamID).Order("id").Find(&rules).Error; err != nil {
+		log.Printf("Error listing network access rules: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to list network access rules")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"network_access_rules": rules})
+}
+
+// bindRule validates a rule request and applies it to the rule
+func bindRule(c *gin.Context, rule *NetworkAccessRule) bool {
+	var req NetworkAccessRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierrors.AbortWithDetails(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Invalid request body", err.Error())
+		return false
+	}
+	if req.Action != NetworkAllow && req.Action != NetworkDeny {
+		apierrors.Abort(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "action must be one of allow, deny")
+		return false
+	}
+	network, err := parseNetwork(req.CIDR)
+	if err != nil {
+		apierrors.Abort(c, http.StatusBadRequest, "invalid_cidr", err.Error())
+		return false
+	}
+
+	rule.Action = req.Action
+	rule.CIDR = network.String()
+	rule.Description = req.Description
+	if req.Enabled != nil {
+		rule.Enabled = *req.Enabled
+	}
+	return true
+}
+
+// guardLockout refuses a change to a scope's rules that would block the
+// caller's own address, so that admins can't lock themselves out
+func (nc *NetworkAccessController) guardLockout(c *gin.Context, teamID *uint, changed *NetworkAccessRule, removed bool) bool {
+	var rules []NetworkAccessRule
+	if err := tenantDB(c, nc.db).Where("enabled = ?", true).Find(&rules).Error; err != nil {
+		log.Printf("Error listing network access rules: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to check network access rules")
+		return false
+	}
+
+	proposed := make([]NetworkAccessRule, 0, len(rules)+1)
+	for _, rule := range rules {
+		if rule.ID != changed.ID || changed.ID == 0 {
+			proposed = append(proposed, rule)
+		}
+	}
+	if !removed {
+		proposed = append(proposed, *changed)
+	}
+
+	var teamIDs []uint
+	if teamID != nil {
+		teamIDs = []uint{*teamID}
+	}
+	if blocked, _ := evaluateNetworkRules(proposed, net.ParseIP(c.ClientIP()), teamIDs); blocked {
+		apierrors.Abort(c, http.StatusConflict, "rule_blocks_caller", "The rules would block your own address")
+		return false
+	}
+	return true
+}
+
+// createRule adds a rule to a scope
+func (nc *NetworkAccessController) createRule(c *gin.Context, teamID *uint) {
+	userID := c.MustGet("user_id").(uint)
+	rule := &NetworkAccessRule{TeamID: teamID, Enabled: true, CreatedBy: userID}
+	if !bindRule(c, rule) || !nc.guardLockout(c, teamID, rule, false) {
+		return
+	}
+
+	db := tenantDB(c, nc.db)
+	if err := db.Create(rule).Error; err != nil {
+		log.Printf("Error creating network access rule: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to create network access rule")
+		return
+	}
+	if err := audit.Record(c, db, userID, "network_access_rules", rule.ID, teamID, nil, rule); err != nil {
+		log.Printf("Error recording creation of network access rule %d in the audit log: %v", rule.ID, err)
+	}
+
+	c.JSON(http.StatusCreated, rule)
+}
+
+// loadRule returns the rule of a scope named by the path parameter
+func (nc *NetworkAccessController) loadRule(c *gin.Context, teamID *uint, param string) (*NetworkAccessRule, bool) {
+	var rule NetworkAccessRule
+	if err := scopeQuery(tenantDB(c, nc.db), teamID).Where("id = ?", c.Param(param)).First(&rule).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierrors.Abort(c, http.StatusNotFound, apierrors.CodeNotFound, "Network access rule not found")
+		} else {
+			log.Printf("Error retrieving network access rule: %v", err)
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to retrieve network access rule")
+		}
+		return nil, false
+	}
+	return &rule, true
+}
+
+// updateRule changes a rule of a scope
+func (nc *NetworkAccessController) updateRule(c *gin.Context, teamID *uint, param string) {
+	rule, ok := nc.loadRule(c, teamID, param)
+	if !ok {
+		return
+	}
+	before := *rule
+	if !bindRule(c, rule) || !nc.guardLockout(c, teamID, rule, false) {
+		return
+	}
+
+	db := tenantDB(c, nc.db)
+	if err := db.Save(rule).Error; err != nil {
+		log.Printf("Error updating network access rule %d: %v", rule.ID, err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to update network access rule")
+		return
+	}
+	userID := c.MustGet("user_id").(uint)
+	if err := audit.Record(c, db, userID, "network_access_rules", rule.ID, teamID, before, rule); err != nil {
+		log.Printf("Error recording update of network access rule %d in the audit log: %v", rule.ID, err)
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+// deleteRule removes a rule from a scope
+func (nc *NetworkAccessController) deleteRule(c *gin.Context, teamID *uint, param string) {
+	rule, ok := nc.loadRule(c, teamID, param)
+	if !ok || !nc.guardLockout(c, teamID, rule, true) {
+		return
+	}
+
+	db := tenantDB(c, nc.db)
+	if err := db.Delete(rule).Error; err != nil {
+		log.Printf("Error deleting network access rule %d: %v", rule.ID, err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to delete network access rule")
+		return
+	}
+	userID := c.MustGet("user_id").(uint)
+	if err := audit.Record(c, db, userID, "network_access_rules", rule.ID, teamID, rule, nil); err != nil {
+		log.Printf("Error recording deletion of network access rule %d in the audit log: %v", rule.ID, err)
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// ListGlobalRules retrieves the network access rules that apply to every
+// request
+// GET /api/v1/admin/network-rules
+func (nc *NetworkAccessController) ListGlobalRules(c *gin.Context) {
+	if !requireGlobalAdmin(c) {
+		return
+	}
+	nc.listRules(c, nil)
+}
+
+// CreateGlobalRule adds a network access rule that applies to every request
+// POST /api/v1/admin/network-rules
+func (nc *NetworkAccessController) CreateGlobalRule(c *gin.Context) {
+	if !requireGlobalAdmin(c) {
+		return
+	}
+	nc.createRule(c, nil)
+}
+
+// UpdateGlobalRule changes a global network access rule
+// PUT /api/v1/admin/network-rules/:id
+func (nc *NetworkAccessController) UpdateGlobalRule(c *gin.Context) {
+	if !requireGlobalAdmin(c) {
+		return
+	}
+	nc.updateRule(c, nil, "id")
+}
+
+// DeleteGlobalRule removes a global network access rule
+// DELETE /api/v1/admin/network-rules/:id
+func (nc *NetworkAccessController) DeleteGlobalRule(c *gin.Context) {
+	if !requireGlobalAdmin(c) {
+		return
+	}
+	nc.deleteRule(c, nil, "id")
+}
+
+// ListTeamRules retrieves a team's network access rules
+// GET /api/v1/teams/:id/network-rules
+func (nc *NetworkAccessController) ListTeamRules(c *gin.Context) {
+	teamID, _, ok := teamAccess(c, nc.access)
+	if !ok {
+		return
+	}
+	nc.listRules(c, &teamID)
+}
+
+// CreateTeamRule adds a network access rule for requests about a team
+// POST /api/v1/teams/:id/network-rules
+func (nc *NetworkAccessController) CreateTeamRule(c *gin.Context) {
+	teamID, ok := nc.teamAdminAccess(c)
+	if !ok {
+		return
+	}
+	nc.createRule(c, teamID)
+}
+
+// UpdateTeamRule changes a team network access rule
+// PUT /api/v1/teams/:id/network-rules/:rule_id
+func (nc *NetworkAccessController) UpdateTeamRule(c *gin.Context) {
+	teamID, ok := nc.teamAdminAccess(c)
+	if !ok {
+		return
+	}
+	nc.updateRule(c, teamID, "rule_id")
+}
+
+// DeleteTeamRule removes a team network access rule
+// DELETE /api/v1/teams/:id/network-rules/:rule_id
+func (nc *NetworkAccessController) DeleteTeamRule(c *gin.Context) {
+	teamID, ok := nc.teamAdminAccess(c)
+	if !ok {
+		return
+	}
+	nc.deleteRule(c, teamID, "rule_id")
+}
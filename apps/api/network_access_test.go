@@ -0,0 +1,187 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/penguintechinc/project-template/shared/database"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func testNetworkRule(id uint, team *uint, action, cidr string) NetworkAccessRule {
+	r := NetworkAccessRule{TeamID: team, Action: action, CIDR: cidr, Enabled: true}
+	r.ID = id
+	return r
+}
+
+func TestEvaluateNetworkRules(t *testing.T) {
+	teamA, teamB := uint(1), uint(2)
+
+	tests := []struct {
+		name    string
+		rules   []NetworkAccessRule
+		ip      string
+		teamIDs []uint
+		blocked bool
+		ruleID  uint
+	}{
+		{
+			name: "no rules",
+			ip:   "10.0.0.1",
+		},
+		{
+			name:    "global deny",
+			rules:   []NetworkAccessRule{testNetworkRule(1, nil, NetworkDeny, "10.0.0.0/8")},
+			ip:      "10.0.0.1",
+			teamIDs: []uint{teamA},
+			blocked: true,
+			ruleID:  1,
+		},
+		{
+			name:    "global allowlist without a match",
+			rules:   []NetworkAccessRule{testNetworkRule(1, nil, NetworkAllow, "192.168.0.0/16")},
+			ip:      "10.0.0.1",
+			blocked: true,
+		},
+		{
+			name:  "global allowlist with a match",
+			rules: []NetworkAccessRule{testNetworkRule(1, nil, NetworkAllow, "10.0.0.0/8")},
+			ip:    "10.0.0.1",
+		},
+		{
+			name:    "deny wins over allow",
+			rules:   []NetworkAccessRule{testNetworkRule(1, nil, NetworkAllow, "10.0.0.0/8"), testNetworkRule(2, nil, NetworkDeny, "10.0.0.1")},
+			ip:      "10.0.0.1",
+			blocked: true,
+			ruleID:  2,
+		},
+		{
+			name:    "team deny on the team's request",
+			rules:   []NetworkAccessRule{testNetworkRule(1, &teamA, NetworkDeny, "10.0.0.0/8")},
+			ip:      "10.0.0.1",
+			teamIDs: []uint{teamA},
+			blocked: true,
+			ruleID:  1,
+		},
+		{
+			name:    "team deny on another team's request",
+			rules:   []NetworkAccessRule{testNetworkRule(1, &teamA, NetworkDeny, "10.0.0.0/8")},
+			ip:      "10.0.0.1",
+			teamIDs: []uint{teamB},
+		},
+		{
+			name:    "team allowlist without a match",
+			rules:   []NetworkAccessRule{testNetworkRule(1, &teamA, NetworkAllow, "192.168.0.0/16")},
+			ip:      "10.0.0.1",
+			teamIDs: []uint{teamA},
+			blocked: true,
+		},
+		{
+			name:  "team rule on a global-only request",
+			rules: []NetworkAccessRule{testNetworkRule(1, &teamA, NetworkDeny, "10.0.0.0/8")},
+			ip:    "10.0.0.1",
+		},
+		{
+			name:    "team allowlist of one of several teams",
+			rules:   []NetworkAccessRule{testNetworkRule(1, &teamA, NetworkAllow, "192.168.0.0/16"), testNetworkRule(2, &teamB, NetworkAllow, "10.0.0.0/8")},
+			ip:      "10.0.0.1",
+			teamIDs: []uint{teamA, teamB},
+			blocked: true,
+		},
+		{
+			name:  "disabled rule",
+			rules: []NetworkAccessRule{{Action: NetworkDeny, CIDR: "10.0.0.0/8"}},
+			ip:    "10.0.0.1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			blocked, matched := evaluateNetworkRules(tt.rules, net.ParseIP(tt.ip), tt.teamIDs)
+			if blocked != tt.blocked {
+				t.Fatalf("Expected blocked %v, got %v", tt.blocked, blocked)
+			}
+			switch {
+			case tt.ruleID == 0 && matched != nil:
+				t.Errorf("Expected no deny rule, got %+v", matched)
+			case tt.ruleID != 0 && (matched == nil || matched.ID != tt.ruleID):
+				t.Errorf("Expected deny rule %d, got %+v", tt.ruleID, matched)
+			}
+		})
+	}
+}
+
+func TestNetworkAccessMiddlewareTeamRules(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	if err := db.AutoMigrate(&User{}, &Team{}, &TeamMember{}, &NetworkAccessRule{}, &database.AuditLog{}); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	// Team A denies the address its members and team B's members call from
+	teamA, teamB := &Team{Name: "a"}, &Team{Name: "b"}
+	db.Create(teamA)
+	db.Create(teamB)
+	memberA := &User{Username: "a", Email: "a@example.com", PasswordHash: "-", Role: "user", IsActive: true}
+	memberB := &User{Username: "b", Email: "b@example.com", PasswordHash: "-", Role: "user", IsActive: true}
+	admin := &User{Username: "admin", Email: "admin@example.com", PasswordHash: "-", Role: "admin", IsActive: true}
+	db.Create(memberA)
+	db.Create(memberB)
+	db.Create(admin)
+	db.Create(&TeamMember{TeamID: teamA.ID, UserID: memberA.ID, Role: "viewer"})
+	db.Create(&TeamMember{TeamID: teamB.ID, UserID: memberB.ID, Role: "viewer"})
+	db.Create(&NetworkAccessRule{TeamID: &teamA.ID, Action: NetworkDeny, CIDR: "10.0.0.0/8", Enabled: true})
+
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		users := map[string]*User{"a": memberA, "b": memberB, "admin": admin}
+		if user := users[c.GetHeader("X-Test-User")]; user != nil {
+			c.Set("user_id", user.ID)
+			c.Set("user_role", user.Role)
+		}
+	})
+	r.Use(NetworkAccessMiddleware(db, NewAccessCache(db, nil, 0)))
+	for _, path := range []string{"/api/v1/resources", "/api/v1/admin/overview", "/api/v1/teams/:id/environments"} {
+		r.GET(path, func(c *gin.Context) { c.Status(http.StatusOK) })
+	}
+
+	teamPath := func(team *Team) string { return "/api/v1/teams/" + strconv.Itoa(int(team.ID)) + "/environments" }
+	tests := []struct {
+		name    string
+		user    string
+		path    string
+		blocked bool
+	}{
+		{name: "team A member listing", user: "a", path: "/api/v1/resources", blocked: true},
+		{name: "team B member listing", user: "b", path: "/api/v1/resources"},
+		{name: "global admin listing", user: "admin", path: "/api/v1/resources"},
+		{name: "team A member on admin routes", user: "a", path: "/api/v1/admin/overview"},
+		{name: "global admin on admin routes", user: "admin", path: "/api/v1/admin/overview"},
+		{name: "team B member on team B", user: "b", path: teamPath(teamB)},
+		{name: "team B member on team A", user: "b", path: teamPath(teamA), blocked: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.RemoteAddr = "10.0.0.1:40000"
+			req.Header.Set("X-Test-User", tt.user)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if blocked := w.Code == http.StatusForbidden; blocked != tt.blocked {
+				t.Errorf("Expected blocked %v, got status %d", tt.blocked, w.Code)
+			}
+		})
+	}
+}
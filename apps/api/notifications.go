@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/penguintechinc/project-template/shared/database"
+)
+
+// Notification is a message delivered through the notification subsystem
+type Notification struct {
+	Event      string                 `json:"event"`
+	Severity   string                 `json:"severity"`
+	Title      string                 `json:"title"`
+	Message    string                 `json:"message"`
+	TeamID     uint                   `json:"team_id,omitempty"`
+	ResourceID uint                   `json:"resource_id,omitempty"`
+	Details    map[string]interface{} `json:"details,omitempty"`
+	Timestamp  time.Time              `json:"timestamp"`
+	// Tenant is the schema of the tenant the notification was raised in,
+	// whose teams and integrations it is delivered to
+	Tenant string `json:"tenant,omitempty"`
+}
+
+// Notifier delivers notifications to a destination
+type Notifier interface {
+	Notify(ctx context.Context, n Notification) error
+}
+
+// LogNotifier writes notifications to the service log
+type LogNotifier struct{}
+
+// Notify logs the notification
+func (LogNotifier) Notify(ctx context.Context, n Notification) error {
+	log.Printf("[notification] event=%s severity=%s team=%d resource=%d: %s - %s",
+		n.Event, n.Severity, n.TeamID, n.ResourceID, n.Title, n.Message)
+	return nil
+}
+
+// WebhookNotifier posts notifications as JSON to an HTTP endpoint
+type WebhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhookNotifier creates a webhook notifier for the given URL
+func NewWebhookNotifier(url string) *WebhookNotifier {
+	return &WebhookNotifier{
+		url:    url,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Notify posts the notification to the webhook
+func (w *WebhookNotifier) Notify(ctx context.Context, n Notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return fmt.Errorf("failed to marshal notification: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// MultiNotifier fans a notification out to several notifiers
+type MultiNotifier []Notifier
+
+// Notify delivers to every notifier and returns the first error encountered
+func (m MultiNotifier) Notify(ctx context.Context, n Notification) error {
+	var firstErr error
+	for _, notifier := range m {
+		if err := notifier.Notify(ctx, n); err != nil {
+			log.Printf("Notification delivery failed: %v", err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// NewNotifierFromEnv builds the notifier chain from environment variables.
+// Notifications are always logged; NOTIFICATION_WEBHOOK_URLS adds a
+// comma-separated list of webhook destinations.
+func NewNotifierFromEnv() Notifier {
+	notifiers := MultiNotifier{LogNotifier{}}
+
+	for _, url := range strings.Split(os.Getenv("NOTIFICATION_WEBHOOK_URLS"), ",") {
+		if url = strings.TrimSpace(url); url != "" {
+			notifiers = append(notifiers, NewWebhookNotifier(url))
+		}
+	}
+
+	return notifiers
+}
+
+// NotificationJob returns the handler of notification jobs, which delivers
+// the queued notification through notifier, in the context of the tenant it
+// was raised in. A failed delivery is retried with the whole job, so
+// destinations that succeeded may see it again.
+func NotificationJob(notifier Notifier, tenants *TenantRouter) JobHandler {
+	return func(ctx context.Context, job *Job) error {
+		var n Notification
+		if err := decodeJobPayload(job, &n); err != nil {
+			return err
+		}
+		if n.Tenant != "" {
+			if tenants == nil {
+				return fmt.Errorf("notification of tenant schema %s without tenancy", n.Tenant)
+			}
+			pool, err := tenants.Pool(n.Tenant)
+			if err != nil {
+				return err
+			}
+			ctx = database.WithTenantDB(ctx, n.Tenant, pool)
+		}
+		return notifier.Notify(ctx, n)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Operation types
+const (
+	OperationCreate  = "create"
+	OperationRestore = "restore"
+	OperationScale   = "scale"
+)
+
+// Operation statuses. An operation is pending until the K8s controller
+// starts a provisioning job for it, and running until the job finishes. An
+// operation the controller carries out without a job goes from pending
+// straight to succeeded or failed when its reconcile ends.
+const (
+	OperationPending   = "pending"
+	OperationRunning   = "running"
+	OperationSucceeded = "succeeded"
+	OperationFailed    = "failed"
+)
+
+// operationIDHeader carries the ID of the operation a request started, in
+// either API mode
+const operationIDHeader = "X-Operation-ID"
+
+// startOperation records in tx an operation of opType on a resource, for a
+// change the K8s controller carries out, and takes the resource's lock for
+// it. It returns a 423 *apierrors.Error when another operation on the
+// resource is still in progress.
+func startOperation(tx *gorm.DB, opType string, resource *Resource, userID uint) (*Operation, error) {
+	op := &Operation{
+		Type:        opType,
+		Status:      OperationPending,
+		ResourceID:  resource.ID,
+		TeamID:      resource.TeamID,
+		RequestedBy: userID,
+	}
+	if err := tx.Create(op).Error; err != nil {
+		return nil, err
+	}
+	if err := acquireOperationLock(tx, resource, op); err != nil {
+		return nil, err
+	}
+	return op, nil
+}
+
+// respondAsync reports whether the client asked for the asynchronous API
+// mode with a Prefer: respond-async header
+func respondAsync(c *gin.Context) bool {
+	for _, prefer := range c.Request.Header.Values("Prefer") {
+		for _, pref := range strings.Split(prefer, ",") {
+			if strings.EqualFold(strings.TrimSpace(pref), "respond-async") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// acceptOperation names the operation a request started in its response
+// headers. In the asynchronous API mode it also writes the 202 response with
+// the operation, pointing Location at it, and returns true; otherwise the
+// caller writes its usual response.
+func acceptOperation(c *gin.Context, op *Operation) bool {
+	if op == nil {
+		return false
+	}
+	c.Header(operationIDHeader, strconv.FormatUint(uint64(op.ID), 10))
+	if !respondAsync(c) {
+		return false
+	}
+	c.Header("Preference-Applied", "respond-async")
+	c.Header("Location", "/api/v1/operations/"+strconv.FormatUint(uint64(op.ID), 10))
+	c.JSON(http.StatusAccepted, OperationResponse{Operation: op})
+	return true
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/penguintechinc/project-template/shared/apierrors"
+	"gorm.io/gorm"
+)
+
+// OperationController handles operation HTTP requests
+type OperationController struct {
+	db *gorm.DB
+}
+
+// NewOperationController creates a new operation controller
+func NewOperationController(db *gorm.DB) *OperationController {
+	return &OperationController{db: db}
+}
+
+// ListOperations retrieves the operations on resources of the user's teams,
+// most recent first
+// GET /api/v1/operations
+func (oc *OperationController) ListOperations(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "User context not found")
+		return
+	}
+
+	limit := 50
+	if l := c.Query("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 500 {
+			limit = parsed
+		}
+	}
+
+	query := tenantDB(c, oc.db).
+		Joins("INNER JOIN team_members ON operations.team_id = team_members.team_id").
+		Where("team_members.user_id = ?", userID.(uint)).
+		Order("operations.created_at DESC").Limit(limit)
+	if resourceID := c.Query("resource_id"); resourceID != "" {
+		query = query.Where("operations.resource_id = ?", resourceID)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("operations.status = ?", status)
+	}
+	if opType := c.Query("type"); opType != "" {
+		query = query.Where("operations.type = ?", opType)
+	}
+
+	var operations []*Operation
+	if err := query.Find(&operations).Error; err != nil {
+		log.Printf("Error listing operations: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to list operations")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"operations": operations})
+}
+
+// GetOperation retrieves an operation's progress, the provisioning job
+// carrying it out, and the current state of its resource
+// GET /api/v1/operations/:id
+func (oc *OperationController) GetOperation(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "User context not found")
+		return
+	}
+
+	db := tenantDB(c, oc.db)
+	var op Operation
+	if err := db.Joins("INNER JOIN team_members ON operations.team_id = team_members.team_id").
+		Where("team_members.user_id = ?", userID.(uint)).
+		Where("operations.id = ?", c.Param("id")).
+		First(&op).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierrors.Abort(c, http.StatusNotFound, apierrors.CodeNotFound, "Operation not found or you do not have access")
+		} else {
+			log.Printf("Error retrieving operation: %v", err)
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to retrieve operation")
+		}
+		return
+	}
+
+	response := OperationResponse{Operation: &op}
+
+	if op.ProvisioningJobID != nil && db.Migrator().HasTable("provisioning_jobs") {
+		var job OperationJobResponse
+		err := db.Raw(`SELECT id, job_type, status, started_at, completed_at, COALESCE(error_message, '') AS error_message
+			FROM provisioning_jobs WHERE id = ?`, *op.ProvisioningJobID).Scan(&job).Error
+		if err != nil {
+			log.Printf("Error retrieving provisioning job %d: %v", *op.ProvisioningJobID, err)
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to retrieve operation")
+			return
+		}
+		if job.ID != 0 {
+			response.ProvisioningJob = &job
+		}
+	}
+
+	// The resource may have been deleted since; it is reported as it is
+	var resource Resource
+	if err := db.Unscoped().Preload("ResourceType").Preload("Team").First(&resource, op.ResourceID).Error; err == nil {
+		response.Resource = resourceToResponse(&resource)
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Printf("Error retrieving resource %d of operation %d: %v", op.ResourceID, op.ID, err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to retrieve operation")
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
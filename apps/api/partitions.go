@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/penguintechinc/project-template/shared/database"
+	"gorm.io/gorm"
+)
+
+// PartitionMaintainer keeps monthly partitions of the time-series tables
+// created ahead of time
+type PartitionMaintainer struct {
+	db          *gorm.DB
+	specs       []database.PartitionSpec
+	monthsAhead int
+	interval    time.Duration
+}
+
+// NewPartitionMaintainer creates a partition maintainer for the default
+// partitioned tables
+func NewPartitionMaintainer(db *gorm.DB, monthsAhead int, interval time.Duration) *PartitionMaintainer {
+	return &PartitionMaintainer{
+		db:          db,
+		specs:       database.DefaultPartitionSpecs,
+		monthsAhead: monthsAhead,
+		interval:    interval,
+	}
+}
+
+// Convert converts any unpartitioned tables to monthly partitioning
+func (m *PartitionMaintainer) Convert(ctx context.Context) error {
+	for _, spec := range m.specs {
+		if err := database.ConvertToPartitioned(ctx, m.db, spec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Maintain creates upcoming monthly partitions for every table
+func (m *PartitionMaintainer) Maintain(ctx context.Context) {
+	for _, spec := range m.specs {
+		if err := database.EnsureMonthlyPartitions(ctx, m.db, spec, m.monthsAhead); err != nil {
+			log.Printf("Failed to create partitions for %s: %v", spec.Table, err)
+		}
+	}
+}
+
+// Run maintains partitions immediately and then on each interval until the
+// context is cancelled
+func (m *PartitionMaintainer) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	log.Printf("Partition maintainer started (interval: %s, months ahead: %d)", m.interval, m.monthsAhead)
+	m.Maintain(ctx)
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("Partition maintainer stopped")
+			return
+		case <-ticker.C:
+			m.Maintain(ctx)
+		}
+	}
+}
+
+// partitionSpecFor returns the partition spec for a table, if it has one
+func partitionSpecFor(table string) (database.PartitionSpec, bool) {
+	for _, spec := range database.DefaultPartitionSpecs {
+		if spec.Table == table {
+			return spec, true
+		}
+	}
+	return database.PartitionSpec{}, false
+}
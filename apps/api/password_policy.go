@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/penguintechinc/project-template/shared/credpolicy"
+	"gorm.io/gorm"
+)
+
+// PasswordPolicies loads the password policy and checks passwords against
+// it. The policy is shared by every tenant, so it is always read from the
+// public schema.
+type PasswordPolicies struct {
+	db       *gorm.DB
+	breaches credpolicy.BreachChecker
+}
+
+// NewPasswordPolicies creates a password policy checker. A nil breach
+// checker disables breach checks regardless of the policy.
+func NewPasswordPolicies(db *gorm.DB, breaches credpolicy.BreachChecker) *PasswordPolicies {
+	return &PasswordPolicies{db: db, breaches: breaches}
+}
+
+// Policy returns the saved policy, or the default when none is saved
+func (p *PasswordPolicies) Policy(ctx context.Context) (credpolicy.Policy, error) {
+	var stored PasswordPolicy
+	err := p.db.WithContext(ctx).Order("id ASC").First(&stored).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return credpolicy.Default(), nil
+	}
+	if err != nil {
+		return credpolicy.Policy{}, err
+	}
+	return credpolicy.Policy{
+		MinLength:      stored.MinLength,
+		MinClasses:     stored.MinClasses,
+		MinEntropyBits: stored.MinEntropyBits,
+		BreachCheck:    stored.BreachCheck,
+		MaxAgeDays:     stored.MaxAgeDays,
+	}, nil
+}
+
+// Check returns the policy rules a password breaks. An unreachable breach
+// API is logged and doesn't block the password, so an outage there can't
+// stop users from being created.
+func (p *PasswordPolicies) Check(ctx context.Context, password string) ([]string, error) {
+	policy, err := p.Policy(ctx)
+	if err != nil {
+		return nil, err
+	}
+	violations, err := policy.Check(ctx, password, p.breaches)
+	if err != nil {
+		log.Printf("Skipping password breach check: %v", err)
+	}
+	return violations, nil
+}
+
+// Generate returns a random password that meets the policy
+func (p *PasswordPolicies) Generate(ctx context.Context) (string, error) {
+	policy, err := p.Policy(ctx)
+	if err != nil {
+		return "", err
+	}
+	return policy.Generate()
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/penguintechinc/project-template/shared/apierrors"
+	"github.com/penguintechinc/project-template/shared/credpolicy"
+	"gorm.io/gorm"
+)
+
+// PasswordPolicyController handles password policy HTTP requests
+type PasswordPolicyController struct {
+	db       *gorm.DB
+	policies *PasswordPolicies
+}
+
+// NewPasswordPolicyController creates a new password policy controller
+func NewPasswordPolicyController(db *gorm.DB, policies *PasswordPolicies) *PasswordPolicyController {
+	return &PasswordPolicyController{db: db, policies: policies}
+}
+
+// GetPasswordPolicy retrieves the password policy in effect
+// GET /api/v1/admin/password-policy
+func (pc *PasswordPolicyController) GetPasswordPolicy(c *gin.Context) {
+	if !requireGlobalAdmin(c) {
+		return
+	}
+
+	policy, err := pc.policies.Policy(c.Request.Context())
+	if err != nil {
+		log.Printf("Error retrieving password policy: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to retrieve password policy")
+		return
+	}
+
+	c.JSON(http.StatusOK, policy)
+}
+
+// UpdatePasswordPolicy replaces the password policy. It applies to
+// passwords set from then on; existing ones only expire by max_age_days.
+// PUT /api/v1/admin/password-policy
+func (pc *PasswordPolicyController) UpdatePasswordPolicy(c *gin.Context) {
+	if !requirePlatformAdmin(c) {
+		return
+	}
+
+	var req credpolicy.Policy
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierrors.AbortWithDetails(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Invalid request body", err.Error())
+		return
+	}
+	if err := req.Validate(); err != nil {
+		apierrors.Abort(c, http.StatusBadRequest, "invalid_policy", err.Error())
+		return
+	}
+
+	var stored PasswordPolicy
+	if err := pc.db.Order("id ASC").First(&stored).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Printf("Error retrieving password policy: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to retrieve password policy")
+		return
+	}
+	stored.MinLength = req.MinLength
+	stored.MinClasses = req.MinClasses
+	stored.MinEntropyBits = req.MinEntropyBits
+	stored.BreachCheck = req.BreachCheck
+	stored.MaxAgeDays = req.MaxAgeDays
+
+	if err := pc.db.Save(&stored).Error; err != nil {
+		log.Printf("Error saving password policy: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to save password policy")
+		return
+	}
+
+	c.JSON(http.StatusOK, req)
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+
+	"gorm.io/datatypes"
+)
+
+// Limits on the JSONB fields accepted in request bodies
+const (
+	maxJSONFieldBytes = 64 << 10
+	maxJSONDepth      = 8
+	maxJSONKeyLength  = 128
+)
+
+// jsonKind is the JSON type a field value must have
+type jsonKind string
+
+const (
+	kindString  jsonKind = "string"
+	kindInteger jsonKind = "integer"
+	kindNumber  jsonKind = "number"
+	kindBool    jsonKind = "boolean"
+	kindObject  jsonKind = "object"
+	kindArray   jsonKind = "array"
+)
+
+// fieldSpec lists the keys allowed in a JSONB field and their types
+type fieldSpec map[string]jsonKind
+
+// connectionInfoSpecs are the connection_info keys accepted per resource
+// type. Types without their own entry use the "" entry.
+var connectionInfoSpecs = map[string]fieldSpec{
+	"": {
+		"host": kindString, "port": kindInteger, "service_name": kindString,
+		"database": kindString, "ssl_mode": kindString,
+	},
+	"postgresql": {
+		"host": kindString, "port": kindInteger, "service_name": kindString,
+		"database": kindString, "ssl_mode": kindString, "schema": kindString,
+	},
+	"mariadb": {
+		"host": kindString, "port": kindInteger, "service_name": kindString,
+		"database": kindString, "ssl_mode": kindString,
+	},
+	"redis": {
+		"host": kindString, "port": kindInteger, "service_name": kindString,
+		"database": kindInteger,
+	},
+}
+
+// credentialSpecs are the credentials keys accepted per resource type
+var credentialSpecs = map[string]fieldSpec{
+	"": {"username": kindString, "password": kindString},
+}
+
+// configSpec is the config keys accepted for every resource type. Nested
+// values are checked by their own validators.
+var configSpec = fieldSpec{
+	"tuning":                   kindObject,
+	configKeyResources:         kindObject,
+	configKeyMaintenanceWindow: kindString,
+	configKeyBackupSchedule:    kindString,
+	configKeyHighAvailability:  kindBool,
+	"init_containers":          kindArray,
+	"sidecars":                 kindArray,
+	"replicas":                 kindInteger,
+	"monitoring":               kindObject,
+}
+
+// specFor returns a resource type's spec from specs, falling back to the
+// default entry
+func specFor(specs map[string]fieldSpec, resourceType string) fieldSpec {
+	if spec, ok := specs[resourceType]; ok {
+		return spec
+	}
+	return specs[""]
+}
+
+// validateResourcePayload checks the connection_info, credentials, and
+// config of a resource request against the resource type's allowlists
+func validateResourcePayload(resourceType string, connInfo, creds, cfg map[string]interface{}) error {
+	if err := validateJSONField("connection_info", connInfo, specFor(connectionInfoSpecs, resourceType)); err != nil {
+		return err
+	}
+	if err := validateJSONField("credentials", creds, specFor(credentialSpecs, resourceType)); err != nil {
+		return err
+	}
+	if err := validateJSONField("config", cfg, configSpec); err != nil {
+		return err
+	}
+	if port, ok := connInfo["port"].(float64); ok && (port < 1 || port > 65535) {
+		return fmt.Errorf("connection_info.port must be between 1 and 65535")
+	}
+	if replicas, ok := cfg["replicas"].(float64); ok && (replicas < 0 || replicas > 100) {
+		return fmt.Errorf("config.replicas must be between 0 and 100")
+	}
+	return nil
+}
+
+// validateJSONField checks a JSONB field's size and nesting, and, when spec
+// is non-nil, that its keys are allowed and have the right types
+func validateJSONField(name string, value map[string]interface{}, spec fieldSpec) error {
+	if value == nil {
+		return nil
+	}
+
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("%s is not valid JSON: %w", name, err)
+	}
+	if len(raw) > maxJSONFieldBytes {
+		return fmt.Errorf("%s exceeds %d bytes", name, maxJSONFieldBytes)
+	}
+	if err := checkJSONShape(name, value, 1); err != nil {
+		return err
+	}
+
+	if spec == nil {
+		return nil
+	}
+	keys := make([]string, 0, len(value))
+	for key := range value {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		kind, ok := spec[key]
+		if !ok {
+			return fmt.Errorf("unknown %s key %q; allowed: %s", name, key, strings.Join(spec.keys(), ", "))
+		}
+		if value[key] != nil && !kind.matches(value[key]) {
+			return fmt.Errorf("%s.%s must be a%s %s", name, key, article(kind), kind)
+		}
+	}
+	return nil
+}
+
+// checkJSONShape enforces the depth and key length limits
+func checkJSONShape(path string, value interface{}, depth int) error {
+	if depth > maxJSONDepth {
+		return fmt.Errorf("%s is nested more than %d levels deep", path, maxJSONDepth)
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if key == "" || len(key) > maxJSONKeyLength {
+				return fmt.Errorf("%s has a key that is empty or longer than %d characters", path, maxJSONKeyLength)
+			}
+			if err := checkJSONShape(path+"."+key, child, depth+1); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for i, child := range v {
+			if err := checkJSONShape(fmt.Sprintf("%s[%d]", path, i), child, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// matches reports whether a decoded JSON value has the kind
+func (k jsonKind) matches(value interface{}) bool {
+	switch v := value.(type) {
+	case string:
+		return k == kindString
+	case float64:
+		return k == kindNumber || (k == kindInteger && v == math.Trunc(v))
+	case bool:
+		return k == kindBool
+	case map[string]interface{}:
+		return k == kindObject
+	case []interface{}:
+		return k == kindArray
+	}
+	return false
+}
+
+// keys returns the spec's keys in order
+func (s fieldSpec) keys() []string {
+	keys := make([]string, 0, len(s))
+	for key := range s {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// article returns the indefinite article suffix for a kind
+func article(k jsonKind) string {
+	if k == kindInteger || k == kindObject || k == kindArray {
+		return "n"
+	}
+	return ""
+}
+
+// decodeJSONField decodes a stored JSONB field, logging rather than hiding
+// values that no longer decode. It reports whether decoding succeeded.
+func decodeJSONField(raw datatypes.JSON, dest interface{}, field string) bool {
+	if len(raw) == 0 {
+		return true
+	}
+	if err := json.Unmarshal(raw, dest); err != nil {
+		log.Printf("Error decoding stored %s: %v", field, err)
+		return false
+	}
+	return true
+}
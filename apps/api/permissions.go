@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/penguintechinc/project-template/shared/licensing"
+	"gorm.io/gorm"
+)
+
+// Results of a permission check
+const (
+	checkPass = "pass"
+	checkFail = "fail"
+	checkSkip = "skip"
+)
+
+// permissionAction is what a resource action requires beyond team
+// membership, mirroring the checks its handler makes
+type permissionAction struct {
+	minTeamRole    string
+	flag           string
+	licenseFeature string
+	fullLifecycle  bool
+	deleted        bool
+	unprotected    bool
+	agentManaged   bool
+}
+
+// permissionActions lists the resource actions that can be explained
+var permissionActions = map[string]permissionAction{
+	"read":      {minTeamRole: "viewer"},
+	"update":    {minTeamRole: "maintainer"},
+	"delete":    {minTeamRole: "admin", unprotected: true},
+	"restore":   {minTeamRole: "admin", deleted: true},
+	"promote":   {minTeamRole: "maintainer"},
+	"reconcile": {minTeamRole: "maintainer", fullLifecycle: true},
+	"resize":    {minTeamRole: "maintainer", flag: FlagResourceResize, fullLifecycle: true},
+	"ssh":       {minTeamRole: "maintainer", agentManaged: true},
+}
+
+// permissionExplainer evaluates permission checks against the database,
+// access cache, and license
+type permissionExplainer struct {
+	db       *gorm.DB
+	access   *AccessCache
+	features *licensing.FeatureGate
+}
+
+// explain evaluates every check for a user acting on a resource. Checks
+// after a failure still run, so the trace shows everything standing in the
+// user's way; checks that need a missing user or resource are skipped.
+func (p *permissionExplainer) explain(ctx context.Context, userID, resourceID uint, action string) (*PermissionExplanation, error) {
+	spec := permissionActions[action]
+	result := &PermissionExplanation{UserID: userID, ResourceID: resourceID, Action: action}
+	add := func(check, outcome, format string, args ...interface{}) {
+		result.Checks = append(result.Checks, PermissionCheck{Check: check, Result: outcome, Detail: fmt.Sprintf(format, args...)})
+	}
+
+	var user User
+	err := p.db.WithContext(ctx).First(&user, userID).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		add("user", checkFail, "User %d does not exist", userID)
+	case err != nil:
+		return nil, err
+	case !user.IsActive:
+		add("user", checkFail, "User %s is inactive", user.Username)
+	default:
+		add("user", checkPass, "User %s is active", user.Username)
+	}
+
+	var resource Resource
+	err = p.db.WithContext(ctx).Unscoped().First(&resource, resourceID).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		add("resource", checkFail, "Resource %d does not exist", resourceID)
+	case err != nil:
+		return nil, err
+	case spec.deleted && !resource.DeletedAt.Valid:
+		add("resource", checkFail, "Resource %s is not deleted", resource.Name)
+	case spec.deleted && resource.DeletionState != "":
+		add("resource", checkFail, "Resource %s is past its restore window (%s)", resource.Name, resource.DeletionState)
+	case !spec.deleted && resource.DeletedAt.Valid:
+		add("resource", checkFail, "Resource %s is deleted", resource.Name)
+	default:
+		add("resource", checkPass, "Resource %s belongs to team %d", resource.Name, resource.TeamID)
+	}
+
+	if user.ID == 0 || resource.ID == 0 {
+		add("membership", checkSkip, "Requires both the user and the resource")
+		add("role", checkSkip, "Requires both the user and the resource")
+	} else {
+		if hasMinimumRole(user.Role, "admin") {
+			add("global_role", checkPass, "User is a global admin and passes team role checks, but still needs membership")
+		} else {
+			add("global_role", checkSkip, "Global role %q grants no team access", user.Role)
+		}
+
+		teamRole, isMember, err := p.access.TeamRole(ctx, user.ID, resource.TeamID)
+		if err != nil {
+			return nil, err
+		}
+		if isMember {
+			add("membership", checkPass, "User is a %s of team %d", teamRole, resource.TeamID)
+		} else {
+			add("membership", checkFail, "User is not a member of team %d", resource.TeamID)
+		}
+
+		switch {
+		case hasMinimumRole(user.Role, "admin"):
+			add("role", checkPass, "Global admins may %s resources", action)
+		case hasMinimumRole(teamRole, spec.minTeamRole):
+			add("role", checkPass, "Team role %s meets the required %s", teamRole, spec.minTeamRole)
+		case isMember:
+			add("role", checkFail, "Team role %s is below the required %s", teamRole, spec.minTeamRole)
+		default:
+			add("role", checkFail, "Action requires team role %s", spec.minTeamRole)
+		}
+	}
+
+	if resource.ID != 0 {
+		if spec.unprotected {
+			if resource.DeletionProtection {
+				add("deletion_protection", checkFail, "Deletion protection is enabled")
+			} else {
+				add("deletion_protection", checkPass, "Deletion protection is disabled")
+			}
+		}
+		if spec.fullLifecycle {
+			if resource.LifecycleMode == "full" {
+				add("lifecycle", checkPass, "Resource is full lifecycle")
+			} else {
+				add("lifecycle", checkFail, "Resource is %s lifecycle; the action requires full", resource.LifecycleMode)
+			}
+		}
+		if spec.agentManaged {
+			if resource.AgentID != nil {
+				add("agent", checkPass, "Resource is managed by agent %d", *resource.AgentID)
+			} else {
+				add("agent", checkFail, "Resource is not managed by a nest-agent")
+			}
+		}
+		if spec.flag != "" {
+			enabled, err := featureEnabled(p.db.WithContext(ctx), spec.flag, resource.TeamID)
+			if err != nil {
+				return nil, err
+			}
+			if enabled {
+				add("feature_flag", checkPass, "Feature flag %s is enabled for team %d", spec.flag, resource.TeamID)
+			} else {
+				add("feature_flag", checkFail, "Feature flag %s is disabled for team %d", spec.flag, resource.TeamID)
+			}
+		}
+	}
+
+	switch {
+	case spec.licenseFeature == "":
+		add("license", checkSkip, "Action is not license gated")
+	case p.features != nil && p.features.HasFeature(spec.licenseFeature):
+		add("license", checkPass, "License includes %s", spec.licenseFeature)
+	default:
+		add("license", checkFail, "License does not include %s", spec.licenseFeature)
+	}
+
+	result.Allowed = true
+	for _, check := range result.Checks {
+		if check.Result == checkFail {
+			result.Allowed = false
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/penguintechinc/project-template/shared/apierrors"
+	"github.com/penguintechinc/project-template/shared/licensing"
+	"gorm.io/gorm"
+)
+
+// PermissionsController handles permission debugging HTTP requests
+type PermissionsController struct {
+	db       *gorm.DB
+	access   *AccessCache
+	features *licensing.FeatureGate
+}
+
+// NewPermissionsController creates a new permissions controller
+func NewPermissionsController(db *gorm.DB, access *AccessCache, features *licensing.FeatureGate) *PermissionsController {
+	return &PermissionsController{db: db, access: access, features: features}
+}
+
+// ExplainPermissions reports every check that decides whether a user may
+// perform an action on a resource, and the outcome
+// GET /api/v1/debug/permissions?user_id=&resource_id=&action=
+func (pc *PermissionsController) ExplainPermissions(c *gin.Context) {
+	if !requireGlobalAdmin(c) {
+		return
+	}
+
+	userID, err := strconv.ParseUint(c.Query("user_id"), 10, 32)
+	if err != nil {
+		apierrors.Abort(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Invalid user ID")
+		return
+	}
+	resourceID, err := strconv.ParseUint(c.Query("resource_id"), 10, 32)
+	if err != nil {
+		apierrors.Abort(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Invalid resource ID")
+		return
+	}
+	action := c.DefaultQuery("action", "read")
+	if _, ok := permissionActions[action]; !ok {
+		actions := make([]string, 0, len(permissionActions))
+		for name := range permissionActions {
+			actions = append(actions, name)
+		}
+		sort.Strings(actions)
+		apierrors.Abort(c, http.StatusBadRequest, "invalid_action", "action must be one of: "+strings.Join(actions, ", "))
+		return
+	}
+
+	explainer := &permissionExplainer{db: tenantDB(c, pc.db), access: pc.access, features: pc.features}
+	explanation, err := explainer.explain(c.Request.Context(), uint(userID), uint(resourceID), action)
+	if err != nil {
+		log.Printf("Error explaining permissions: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to evaluate permissions")
+		return
+	}
+
+	c.JSON(http.StatusOK, explanation)
+}
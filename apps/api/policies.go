@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/penguintechinc/project-template/shared/apierrors"
+	"github.com/penguintechinc/project-template/shared/policy"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// Operations a resource spec is checked for, given to policies as
+// input.operation
+const (
+	PolicyOperationCreate = "create"
+	PolicyOperationUpdate = "update"
+)
+
+// PolicyEngine checks resource specs against the Rego policies, evaluated
+// by the OPA server at OPA_URL. Policies are shared by every tenant, so they
+// are always read from the public schema. OPA keeps policies in memory, so
+// one it has lost, such as after a restart, is loaded again when an
+// evaluation misses it, and all of them are synced periodically.
+type PolicyEngine struct {
+	db     *gorm.DB
+	client *policy.Client
+}
+
+// NewPolicyEngine creates a policy engine evaluating policies on the OPA
+// server at opaURL. Policies aren't enforced when opaURL is empty.
+func NewPolicyEngine(db *gorm.DB, opaURL string) *PolicyEngine {
+	e := &PolicyEngine{db: db}
+	if opaURL != "" {
+		e.client = policy.NewClient(opaURL)
+	}
+	return e
+}
+
+// Enabled reports whether an OPA server is configured
+func (e *PolicyEngine) Enabled() bool {
+	return e.client != nil
+}
+
+// Evaluate returns the violations of the enabled policies by a resource
+// spec, checked for operation
+func (e *PolicyEngine) Evaluate(ctx context.Context, operation string, resource *Resource) ([]PolicyViolation, error) {
+	if e.client == nil {
+		return nil, nil
+	}
+	var policies []Policy
+	if err := e.db.WithContext(ctx).Where("enabled = ?", true).Order("name").Find(&policies).Error; err != nil {
+		return nil, err
+	}
+	if len(policies) == 0 {
+		return nil, nil
+	}
+
+	input, err := e.policyInput(ctx, operation, resource)
+	if err != nil {
+		return nil, err
+	}
+	results, err := e.client.Evaluate(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	reloaded := false
+	for _, p := range policies {
+		if _, ok := results[p.Name]; ok {
+			continue
+		}
+		if err := e.client.Put(ctx, p.Name, p.Rego); err != nil {
+			return nil, fmt.Errorf("loading policy %s: %w", p.Name, err)
+		}
+		reloaded = true
+	}
+	if reloaded {
+		if results, err = e.client.Evaluate(ctx, input); err != nil {
+			return nil, err
+		}
+	}
+
+	var violations []PolicyViolation
+	for _, p := range policies {
+		for _, msg := range results[p.Name] {
+			violations = append(violations, PolicyViolation{Policy: p.Name, Severity: p.Severity, Message: msg})
+		}
+	}
+	return violations, nil
+}
+
+// Check evaluates the enabled policies against a resource about to be
+// created or updated. Violations of blocking policies reject the change;
+// warnings are set on the resource, to be saved with it. A change that
+// can't be checked because OPA is unreachable is rejected too.
+func (e *PolicyEngine) Check(ctx context.Context, operation string, resource *Resource) *apierrors.Error {
+	violations, err := e.Evaluate(ctx, operation, resource)
+	if err != nil {
+		log.Printf("Error evaluating policies for resource %s: %v", resource.Name, err)
+		return apierrors.New(http.StatusServiceUnavailable, "policy_unavailable", "Policies could not be evaluated")
+	}
+
+	var blocking, warnings []PolicyViolation
+	for _, v := range violations {
+		if v.Severity == policy.SeverityBlock {
+			blocking = append(blocking, v)
+		} else {
+			warnings = append(warnings, v)
+		}
+	}
+	if len(blocking) > 0 {
+		return apierrors.New(http.StatusUnprocessableEntity, "policy_violation", "The resource violates a blocking policy").
+			WithDetails(blocking)
+	}
+
+	resource.PolicyViolations = nil
+	if len(warnings) > 0 {
+		encoded, _ := json.Marshal(warnings)
+		resource.PolicyViolations = datatypes.JSON(encoded)
+	}
+	return nil
+}
+
+// Sync loads the enabled policies into OPA and unloads the disabled ones
+func (e *PolicyEngine) Sync(ctx context.Context) error {
+	if e.client == nil {
+		return nil
+	}
+	var policies []Policy
+	if err := e.db.WithContext(ctx).Find(&policies).Error; err != nil {
+		return err
+	}
+	for _, p := range policies {
+		var err error
+		if p.Enabled {
+			err = e.client.Put(ctx, p.Name, p.Rego)
+		} else {
+			err = e.client.Delete(ctx, p.Name)
+		}
+		if err != nil {
+			return fmt.Errorf("syncing policy %s: %w", p.Name, err)
+		}
+	}
+	return nil
+}
+
+// policyInput builds the input document policies are evaluated against.
+// The images are those of the containers the resource's config injects;
+// the K8s controller evaluates policies again with every image of the
+// generated StatefulSet.
+func (e *PolicyEngine) policyInput(ctx context.Context, operation string, r *Resource) (map[string]interface{}, error) {
+	spec, images, err := resourceSpec(ctx, e.db, r)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"operation": operation,
+		"resource":  spec,
+		"images":    images,
+	}, nil
+}
+
+// resourceSpec describes a resource as policies and validation webhooks see
+// it, and lists the images of the containers its config injects
+func resourceSpec(ctx context.Context, db *gorm.DB, r *Resource) (map[string]interface{}, []string, error) {
+	engine := ""
+	if r.ResourceType != nil {
+		engine = r.ResourceType.Name
+	} else {
+		var names []string
+		if err := db.WithContext(ctx).Model(&ResourceType{}).Where("id = ?", r.ResourceTypeID).Pluck("name", &names).Error; err != nil {
+			return nil, nil, err
+		}
+		if len(names) > 0 {
+			engine = names[0]
+		}
+	}
+
+	var cfg map[string]interface{}
+	decodeJSONField(r.Config, &cfg, "config")
+	images := []string{}
+	if init, sidecars, err := configInjections(cfg); err == nil {
+		for _, containers := range [][]InjectedContainer{init, sidecars} {
+			for _, c := range containers {
+				images = append(images, c.Image)
+			}
+		}
+	}
+
+	return map[string]interface{}{
+		"id":                  r.ID,
+		"name":                r.Name,
+		"engine":              engine,
+		"team_id":             r.TeamID,
+		"environment":         r.Environment,
+		"lifecycle_mode":      r.LifecycleMode,
+		"size_class":          r.SizeClass,
+		"tls_enabled":         r.TLSEnabled,
+		"deletion_protection": r.DeletionProtection,
+		"can_backup":          r.CanBackup,
+		"config":              cfg,
+	}, images, nil
+}
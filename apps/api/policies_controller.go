@@ -0,0 +1,300 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/penguintechinc/project-template/shared/apierrors"
+	"github.com/penguintechinc/project-template/shared/audit"
+	"github.com/penguintechinc/project-template/shared/policy"
+	"gorm.io/gorm"
+)
+
+// PolicyController handles Rego policy HTTP requests
+type PolicyController struct {
+	db     *gorm.DB
+	engine *PolicyEngine
+}
+
+// NewPolicyController creates a new policy controller. Policies are shared
+// by every tenant, so they are always read from the public schema.
+func NewPolicyController(db *gorm.DB, engine *PolicyEngine) *PolicyController {
+	return &PolicyController{db: db, engine: engine}
+}
+
+// loadPolicy writes the error response when the policy of the :id path
+// parameter can't be loaded
+func (pc *PolicyController) loadPolicy(c *gin.Context) (*Policy, bool) {
+	var p Policy
+	if err := pc.db.First(&p, c.Param("id")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierrors.Abort(c, http.StatusNotFound, apierrors.CodeNotFound, "Policy not found")
+		} else {
+			log.Printf("Error retrieving policy: %v", err)
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to retrieve policy")
+		}
+		return nil, false
+	}
+	return &p, true
+}
+
+// pushPolicy loads a policy into OPA, or unloads it when it is disabled,
+// writing the error response when OPA rejects or can't be reached. The Rego
+// of a disabled policy is still compiled, so it can't be saved broken.
+func (pc *PolicyController) pushPolicy(c *gin.Context, p *Policy) bool {
+	if !pc.engine.Enabled() {
+		apierrors.Abort(c, http.StatusServiceUnavailable, "policy_engine_disabled", "The policy engine is not configured")
+		return false
+	}
+	ctx := c.Request.Context()
+	err := pc.engine.client.Put(ctx, p.Name, p.Rego)
+	if err == nil && !p.Enabled {
+		err = pc.engine.client.Delete(ctx, p.Name)
+	}
+	if err == nil {
+		return true
+	}
+
+	var compileErr *policy.CompileError
+	if errors.As(err, &compileErr) {
+		apierrors.AbortWithDetails(c, http.StatusBadRequest, "invalid_policy", "The policy could not be compiled", compileErr.Message)
+	} else {
+		log.Printf("Error loading policy %s into OPA: %v", p.Name, err)
+		apierrors.Abort(c, http.StatusBadGateway, "policy_unavailable", "The policy engine could not be reached")
+	}
+	return false
+}
+
+// validPolicy writes the error response when a policy's severity or Rego is
+// invalid
+func validPolicy(c *gin.Context, p *Policy) bool {
+	if p.Severity != policy.SeverityBlock && p.Severity != policy.SeverityWarn {
+		apierrors.Abort(c, http.StatusBadRequest, "invalid_severity", "severity must be one of: block, warn")
+		return false
+	}
+	if err := policy.CheckModule(p.Name, p.Rego); err != nil {
+		apierrors.Abort(c, http.StatusBadRequest, "invalid_policy", err.Error())
+		return false
+	}
+	return true
+}
+
+// ListPolicies retrieves the Rego policies resource specs are checked against
+// GET /api/v1/admin/policies
+func (pc *PolicyController) ListPolicies(c *gin.Context) {
+	if !requirePlatformAdmin(c) {
+		return
+	}
+
+	var policies []*Policy
+	if err := pc.db.Order("name").Find(&policies).Error; err != nil {
+		log.Printf("Error listing policies: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to list policies")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"policies": policies, "enforced": pc.engine.Enabled()})
+}
+
+// GetPolicy retrieves a policy
+// GET /api/v1/admin/policies/:id
+func (pc *PolicyController) GetPolicy(c *gin.Context) {
+	if !requirePlatformAdmin(c) {
+		return
+	}
+	p, ok := pc.loadPolicy(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, p)
+}
+
+// CreatePolicy uploads a Rego policy, which must declare package
+// nest.policies.<name>. It is loaded into OPA, which must compile it, before
+// it is saved.
+// POST /api/v1/admin/policies
+func (pc *PolicyController) CreatePolicy(c *gin.Context) {
+	if !requirePlatformAdmin(c) {
+		return
+	}
+	userID, _ := c.Get("user_id")
+
+	var req CreatePolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierrors.AbortWithDetails(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Invalid request body", err.Error())
+		return
+	}
+	if !policy.ValidName(req.Name) {
+		apierrors.Abort(c, http.StatusBadRequest, "invalid_name", "name must be lowercase letters, digits and underscores, starting with a letter")
+		return
+	}
+
+	p := &Policy{
+		Name:        req.Name,
+		Description: req.Description,
+		Rego:        req.Rego,
+		Severity:    req.Severity,
+		Enabled:     true,
+		CreatedBy:   userID.(uint),
+	}
+	if p.Severity == "" {
+		p.Severity = policy.SeverityBlock
+	}
+	if req.Enabled != nil {
+		p.Enabled = *req.Enabled
+	}
+	if !validPolicy(c, p) {
+		return
+	}
+
+	var existing int64
+	if err := pc.db.Model(&Policy{}).Where("name = ?", p.Name).Count(&existing).Error; err != nil {
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to create policy")
+		return
+	}
+	if existing > 0 {
+		apierrors.Abort(c, http.StatusConflict, apierrors.CodeConflict, "A policy with this name already exists")
+		return
+	}
+
+	if !pc.pushPolicy(c, p) {
+		return
+	}
+	if !unitOfWork(c, pc.db, "Failed to create policy", func(tx *gorm.DB) error {
+		if err := tx.Create(p).Error; err != nil {
+			return err
+		}
+		return audit.Record(c, tx, userID.(uint), "policies", p.ID, nil, nil, p)
+	}) {
+		return
+	}
+
+	c.JSON(http.StatusCreated, p)
+}
+
+// UpdatePolicy changes a policy's Rego, severity or description, or enables
+// or disables it
+// PUT /api/v1/admin/policies/:id
+func (pc *PolicyController) UpdatePolicy(c *gin.Context) {
+	if !requirePlatformAdmin(c) {
+		return
+	}
+	userID, _ := c.Get("user_id")
+	p, ok := pc.loadPolicy(c)
+	if !ok {
+		return
+	}
+	before := *p
+
+	var req UpdatePolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierrors.AbortWithDetails(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Invalid request body", err.Error())
+		return
+	}
+	if req.Description != nil {
+		p.Description = *req.Description
+	}
+	if req.Rego != nil {
+		p.Rego = *req.Rego
+	}
+	if req.Severity != nil {
+		p.Severity = *req.Severity
+	}
+	if req.Enabled != nil {
+		p.Enabled = *req.Enabled
+	}
+	if !validPolicy(c, p) {
+		return
+	}
+
+	if !pc.pushPolicy(c, p) {
+		return
+	}
+	if !unitOfWork(c, pc.db, "Failed to update policy", func(tx *gorm.DB) error {
+		if err := tx.Save(p).Error; err != nil {
+			return err
+		}
+		return audit.Record(c, tx, userID.(uint), "policies", p.ID, nil, &before, p)
+	}) {
+		return
+	}
+
+	c.JSON(http.StatusOK, p)
+}
+
+// DeletePolicy deletes a policy and unloads it from OPA. Violations of it
+// recorded on resources are cleared by their next update or reconcile.
+// DELETE /api/v1/admin/policies/:id
+func (pc *PolicyController) DeletePolicy(c *gin.Context) {
+	if !requirePlatformAdmin(c) {
+		return
+	}
+	userID, _ := c.Get("user_id")
+	p, ok := pc.loadPolicy(c)
+	if !ok {
+		return
+	}
+
+	if !unitOfWork(c, pc.db, "Failed to delete policy", func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Delete(p).Error; err != nil {
+			return err
+		}
+		return audit.Record(c, tx, userID.(uint), "policies", p.ID, nil, p, nil)
+	}) {
+		return
+	}
+
+	// A policy left loaded is no longer evaluated, so failing to unload it
+	// doesn't fail the request
+	if pc.engine.Enabled() {
+		if err := pc.engine.client.Delete(c.Request.Context(), p.Name); err != nil {
+			log.Printf("Error unloading policy %s from OPA: %v", p.Name, err)
+		}
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// EvaluatePolicies checks a resource as it is against the enabled policies,
+// to try policies out before they are enforced on changes
+// POST /api/v1/admin/policies/evaluate
+func (pc *PolicyController) EvaluatePolicies(c *gin.Context) {
+	if !requirePlatformAdmin(c) {
+		return
+	}
+	if !pc.engine.Enabled() {
+		apierrors.Abort(c, http.StatusServiceUnavailable, "policy_engine_disabled", "The policy engine is not configured")
+		return
+	}
+
+	var req EvaluatePoliciesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierrors.AbortWithDetails(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	var resource Resource
+	if err := tenantDB(c, pc.db).Preload("ResourceType").First(&resource, req.ResourceID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierrors.Abort(c, http.StatusNotFound, "resource_not_found", "Resource not found")
+		} else {
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to retrieve resource")
+		}
+		return
+	}
+
+	violations, err := pc.engine.Evaluate(c.Request.Context(), PolicyOperationUpdate, &resource)
+	if err != nil {
+		log.Printf("Error evaluating policies for resource %d: %v", resource.ID, err)
+		apierrors.Abort(c, http.StatusBadGateway, "policy_unavailable", "Policies could not be evaluated")
+		return
+	}
+	if violations == nil {
+		violations = []PolicyViolation{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"resource_id": resource.ID, "violations": violations})
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Limits on what a user can store, so preferences stay small settings
+// rather than a general-purpose store
+const (
+	maxPreferencesPerUser = 100
+	maxPreferenceBytes    = 16 * 1024
+	maxSavedViewsPerUser  = 50
+)
+
+// preferenceKeyPattern matches valid preference keys, such as
+// resources.columns or default-team
+var preferenceKeyPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{0,99}$`)
+
+// savedViewFilters are the lists views can be saved for, with the query
+// parameters of each that a view may set
+var savedViewFilters = map[string][]string{
+	"resources":  {"team_id", "status", "environment", "resource_type_id", "page_size", "fields"},
+	"audit_logs": {"user_id", "team_id", "resource_id", "resource_type", "action", "since", "until", "page_size"},
+}
+
+// validateSavedView checks a view's target and filters. It returns a
+// message describing the first problem, or "" when the view is valid.
+func validateSavedView(req *SavedViewRequest) string {
+	allowed, ok := savedViewFilters[req.Target]
+	if !ok {
+		targets := make([]string, 0, len(savedViewFilters))
+		for target := range savedViewFilters {
+			targets = append(targets, target)
+		}
+		sort.Strings(targets)
+		return "target must be one of: " + strings.Join(targets, ", ")
+	}
+	for name := range req.Filters {
+		found := false
+		for _, filter := range allowed {
+			if name == filter {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return "unsupported filter " + name + " for " + req.Target + "; supported: " + strings.Join(allowed, ", ")
+		}
+	}
+	return ""
+}
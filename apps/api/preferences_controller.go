@@ -0,0 +1,327 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/penguintechinc/project-template/shared/apierrors"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// PreferenceController keeps the calling user's UI preferences and saved
+// views, so a UI can persist them server-side
+type PreferenceController struct {
+	db *gorm.DB
+}
+
+// NewPreferenceController creates a new preference controller
+func NewPreferenceController(db *gorm.DB) *PreferenceController {
+	return &PreferenceController{db: db}
+}
+
+// currentUserID returns the calling user's ID, writing the error response
+// and returning false when there is none
+func currentUserID(c *gin.Context) (uint, bool) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "User context not found")
+		return 0, false
+	}
+	return userID.(uint), true
+}
+
+// ListPreferences returns the caller's preferences as an object of values
+// by key
+// GET /api/v1/me/preferences
+func (pc *PreferenceController) ListPreferences(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	var prefs []UserPreference
+	if err := tenantDB(c, pc.db).Where("user_id = ?", userID).Order("key").Find(&prefs).Error; err != nil {
+		log.Printf("Error listing preferences: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to list preferences")
+		return
+	}
+
+	values := make(map[string]datatypes.JSON, len(prefs))
+	for _, pref := range prefs {
+		values[pref.Key] = pref.Value
+	}
+	c.JSON(http.StatusOK, gin.H{"preferences": values})
+}
+
+// GetPreference returns one of the caller's preferences
+// GET /api/v1/me/preferences/:key
+func (pc *PreferenceController) GetPreference(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	var pref UserPreference
+	if err := tenantDB(c, pc.db).Where("user_id = ? AND key = ?", userID, c.Param("key")).First(&pref).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierrors.Abort(c, http.StatusNotFound, apierrors.CodeNotFound, "Preference not found")
+		} else {
+			log.Printf("Error retrieving preference: %v", err)
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to retrieve preference")
+		}
+		return
+	}
+	c.JSON(http.StatusOK, pref)
+}
+
+// SetPreference stores a preference of the caller. The request body is the
+// value, any JSON document of up to 16 KiB.
+// PUT /api/v1/me/preferences/:key
+func (pc *PreferenceController) SetPreference(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	key := c.Param("key")
+	if !preferenceKeyPattern.MatchString(key) {
+		apierrors.Abort(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Invalid preference key")
+		return
+	}
+	value, err := io.ReadAll(io.LimitReader(c.Request.Body, maxPreferenceBytes+1))
+	if err != nil {
+		apierrors.Abort(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Invalid request body")
+		return
+	}
+	if len(value) > maxPreferenceBytes {
+		apierrors.Abort(c, http.StatusRequestEntityTooLarge, apierrors.CodeInvalidRequest, "Preference values are limited to 16 KiB")
+		return
+	}
+	if !json.Valid(value) {
+		apierrors.Abort(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Preference value must be JSON")
+		return
+	}
+
+	db := tenantDB(c, pc.db)
+	var pref UserPreference
+	if err := db.Where("user_id = ? AND key = ?", userID, key).First(&pref).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("Error retrieving preference: %v", err)
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to save preference")
+			return
+		}
+		var count int64
+		if err := db.Model(&UserPreference{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+			log.Printf("Error counting preferences: %v", err)
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to save preference")
+			return
+		}
+		if count >= maxPreferencesPerUser {
+			apierrors.Abort(c, http.StatusConflict, "preference_limit", "Preference limit reached; delete unused preferences first")
+			return
+		}
+		pref = UserPreference{UserID: userID, Key: key}
+	}
+
+	pref.Value = datatypes.JSON(value)
+	if err := db.Save(&pref).Error; err != nil {
+		log.Printf("Error saving preference: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to save preference")
+		return
+	}
+	c.JSON(http.StatusOK, pref)
+}
+
+// DeletePreference removes one of the caller's preferences
+// DELETE /api/v1/me/preferences/:key
+func (pc *PreferenceController) DeletePreference(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	result := tenantDB(c, pc.db).Unscoped().Where("user_id = ? AND key = ?", userID, c.Param("key")).Delete(&UserPreference{})
+	if result.Error != nil {
+		log.Printf("Error deleting preference: %v", result.Error)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to delete preference")
+		return
+	}
+	if result.RowsAffected == 0 {
+		apierrors.Abort(c, http.StatusNotFound, apierrors.CodeNotFound, "Preference not found")
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// ListSavedViews lists the caller's saved views, optionally of one target
+// list, by name
+// GET /api/v1/me/views?target=
+func (pc *PreferenceController) ListSavedViews(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	query := tenantDB(c, pc.db).Where("user_id = ?", userID)
+	if target := c.Query("target"); target != "" {
+		query = query.Where("target = ?", target)
+	}
+	views := []SavedView{}
+	if err := query.Order("target, name").Find(&views).Error; err != nil {
+		log.Printf("Error listing saved views: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to list saved views")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"views": views})
+}
+
+// CreateSavedView saves a view of the caller
+// POST /api/v1/me/views
+func (pc *PreferenceController) CreateSavedView(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	var req SavedViewRequest
+	if !bindSavedView(c, &req) {
+		return
+	}
+
+	db := tenantDB(c, pc.db)
+	var count int64
+	if err := db.Model(&SavedView{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+		log.Printf("Error counting saved views: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to save view")
+		return
+	}
+	if count >= maxSavedViewsPerUser {
+		apierrors.Abort(c, http.StatusConflict, "saved_view_limit", "Saved view limit reached; delete unused views first")
+		return
+	}
+
+	view := &SavedView{UserID: userID}
+	if !pc.saveView(c, view, &req) {
+		return
+	}
+	c.JSON(http.StatusCreated, view)
+}
+
+// UpdateSavedView replaces one of the caller's saved views
+// PUT /api/v1/me/views/:id
+func (pc *PreferenceController) UpdateSavedView(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	var view SavedView
+	if err := tenantDB(c, pc.db).Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&view).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierrors.Abort(c, http.StatusNotFound, apierrors.CodeNotFound, "Saved view not found")
+		} else {
+			log.Printf("Error retrieving saved view: %v", err)
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to retrieve saved view")
+		}
+		return
+	}
+
+	var req SavedViewRequest
+	if !bindSavedView(c, &req) {
+		return
+	}
+	if !pc.saveView(c, &view, &req) {
+		return
+	}
+	c.JSON(http.StatusOK, view)
+}
+
+// DeleteSavedView removes one of the caller's saved views
+// DELETE /api/v1/me/views/:id
+func (pc *PreferenceController) DeleteSavedView(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	result := tenantDB(c, pc.db).Unscoped().Where("id = ? AND user_id = ?", c.Param("id"), userID).Delete(&SavedView{})
+	if result.Error != nil {
+		log.Printf("Error deleting saved view: %v", result.Error)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to delete saved view")
+		return
+	}
+	if result.RowsAffected == 0 {
+		apierrors.Abort(c, http.StatusNotFound, apierrors.CodeNotFound, "Saved view not found")
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// bindSavedView parses and validates a saved view request, writing the
+// error response and returning false when it is invalid
+func bindSavedView(c *gin.Context, req *SavedViewRequest) bool {
+	if err := c.ShouldBindJSON(req); err != nil {
+		apierrors.AbortWithDetails(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Invalid request body", err.Error())
+		return false
+	}
+	if problem := validateSavedView(req); problem != "" {
+		apierrors.AbortWithDetails(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Invalid saved view", problem)
+		return false
+	}
+	return true
+}
+
+// saveView applies a request to a view and saves it. Names are unique per
+// user and target, and making a view the default unsets the previous one.
+// It writes the error response and returns false when the view can't be
+// saved.
+func (pc *PreferenceController) saveView(c *gin.Context, view *SavedView, req *SavedViewRequest) bool {
+	filters, _ := json.Marshal(req.Filters)
+	view.Name = req.Name
+	view.Target = req.Target
+	view.Filters = datatypes.JSON(filters)
+	view.Columns = nil
+	if len(req.Columns) > 0 {
+		columns, _ := json.Marshal(req.Columns)
+		view.Columns = datatypes.JSON(columns)
+	}
+	view.IsDefault = req.IsDefault
+
+	err := tenantDB(c, pc.db).Transaction(func(tx *gorm.DB) error {
+		var existing int64
+		if err := tx.Model(&SavedView{}).
+			Where("user_id = ? AND target = ? AND name = ? AND id <> ?", view.UserID, view.Target, view.Name, view.ID).
+			Count(&existing).Error; err != nil {
+			return err
+		}
+		if existing > 0 {
+			return apierrors.New(http.StatusConflict, "saved_view_exists", "A saved view with this name already exists")
+		}
+		if view.IsDefault {
+			if err := tx.Model(&SavedView{}).
+				Where("user_id = ? AND target = ? AND id <> ?", view.UserID, view.Target, view.ID).
+				Update("is_default", false).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Save(view).Error
+	})
+	if err != nil {
+		var apiErr *apierrors.Error
+		if errors.As(err, &apiErr) {
+			apierrors.AbortWith(c, apiErr)
+		} else {
+			log.Printf("Error saving view: %v", err)
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to save view")
+		}
+		return false
+	}
+	return true
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// priorityClassPattern restricts priority class names to lowercase
+// identifiers, matching the names of the K8s controller's PRIORITY_CLASSES
+var priorityClassPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,31}$`)
+
+// validatePriorityClass checks the priority class a resource names. The
+// classes themselves are configured on the K8s controller, which reconciles
+// resources naming a class it doesn't have with the class of their
+// environment, so any well-formed name is accepted; empty clears it.
+func validatePriorityClass(class string) error {
+	if class != "" && !priorityClassPattern.MatchString(class) {
+		return fmt.Errorf("priority_class must be a lowercase name of up to 32 letters, digits and hyphens")
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/penguintechinc/project-template/shared/apierrors"
+	"github.com/penguintechinc/project-template/shared/audit"
+	"github.com/penguintechinc/project-template/shared/pagination"
+	"gorm.io/gorm"
+)
+
+// ListProvisioningJobs lists the provisioning jobs the K8s controller ran for
+// a resource, newest first, with their logs
+// GET /api/v1/resources/:id/provisioning-jobs
+func (rc *ResourceController) ListProvisioningJobs(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "User context not found")
+		return
+	}
+
+	resource, ok := rc.loadMemberResource(c, userID.(uint))
+	if !ok {
+		return
+	}
+
+	page, pageSize := pagination.Parse(c, 20, 100)
+	response := ProvisioningJobListResponse{Jobs: []*ProvisioningJobResponse{}}
+
+	// The table belongs to the K8s controller and is missing until it has run
+	db := tenantDB(c, rc.db)
+	if db.Migrator().HasTable("provisioning_jobs") {
+		if err := db.Table("provisioning_jobs").Where("resource_id = ?", resource.ID).
+			Count(&response.Total).Error; err != nil {
+			log.Printf("Error counting provisioning jobs: %v", err)
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to list provisioning jobs")
+			return
+		}
+		if err := db.Raw(`SELECT id, job_type, status, started_at, completed_at,
+				COALESCE(logs, '') AS logs, COALESCE(error_message, '') AS error_message, created_at
+			FROM provisioning_jobs WHERE resource_id = ?
+			ORDER BY created_at DESC, id DESC LIMIT ? OFFSET ?`,
+			resource.ID, pageSize, (page-1)*pageSize).Scan(&response.Jobs).Error; err != nil {
+			log.Printf("Error listing provisioning jobs: %v", err)
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to list provisioning jobs")
+			return
+		}
+	}
+
+	pagination.SetHeaders(c, page, pageSize, response.Total)
+	c.JSON(http.StatusOK, response)
+}
+
+// loadMaintainedResource loads the resource of the :id path parameter for a
+// change to its jobs, which takes a team maintainer or a global admin. It
+// writes the error response and returns false otherwise.
+func (rc *ResourceController) loadMaintainedResource(c *gin.Context, userID uint, forbidden string) (*Resource, bool) {
+	resource, ok := rc.loadMemberResource(c, userID)
+	if !ok {
+		return nil, false
+	}
+	userRole, _ := c.Get("user_role")
+	teamRole, _, err := rc.access.TeamRole(c.Request.Context(), userID, resource.TeamID)
+	if err != nil || (!hasMinimumRole(userRole, "admin") && !hasMinimumRole(teamRole, "maintainer")) {
+		apierrors.Abort(c, http.StatusForbidden, apierrors.CodeForbidden, forbidden)
+		return nil, false
+	}
+	return resource, true
+}
+
+// CancelProvisioningJob asks the K8s controller running a provisioning job
+// to stop it. The job is cancelling until the controller has stopped, and
+// its operation then fails; the controller's next reconcile of the resource
+// works toward its desired state again.
+// POST /api/v1/resources/:id/provisioning-jobs/:job_id/cancel
+func (rc *ResourceController) CancelProvisioningJob(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "User context not found")
+		return
+	}
+
+	resource, ok := rc.loadMaintainedResource(c, userID.(uint), "Insufficient permissions to cancel jobs")
+	if !ok {
+		return
+	}
+
+	var job ProvisioningJobResponse
+	if !unitOfWork(c, rc.db, "Failed to cancel provisioning job", func(tx *gorm.DB) error {
+		if !tx.Migrator().HasTable("provisioning_jobs") {
+			return apierrors.New(http.StatusNotFound, apierrors.CodeNotFound, "Provisioning job not found")
+		}
+		if err := tx.Raw(`SELECT id, job_type, status, started_at, created_at FROM provisioning_jobs
+			WHERE id = ? AND resource_id = ? FOR UPDATE`, c.Param("job_id"), resource.ID).Scan(&job).Error; err != nil {
+			return err
+		}
+		if job.ID == 0 {
+			return apierrors.New(http.StatusNotFound, apierrors.CodeNotFound, "Provisioning job not found")
+		}
+		if job.Status != provisioningJobRunning {
+			return apierrors.New(http.StatusConflict, "job_not_running", "Only running jobs can be cancelled")
+		}
+		job.Status = provisioningJobCancelling
+		if err := tx.Exec(`UPDATE provisioning_jobs SET status = ?, updated_at = ? WHERE id = ?`,
+			job.Status, time.Now().UTC(), job.ID).Error; err != nil {
+			return err
+		}
+		return audit.RecordAction(c, tx, userID.(uint), "cancel", "provisioning_jobs", job.ID, &resource.TeamID,
+			gin.H{"resource_id": resource.ID, "job_type": job.JobType})
+	}) {
+		return
+	}
+
+	c.JSON(http.StatusAccepted, job)
+}
+
+// CancelBackupJob cancels a backup job. A pending job is never run; a
+// running one is abandoned, and the outcome its agent reports is ignored.
+// POST /api/v1/resources/:id/backup-jobs/:job_id/cancel
+func (rc *ResourceController) CancelBackupJob(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "User context not found")
+		return
+	}
+
+	resource, ok := rc.loadMaintainedResource(c, userID.(uint), "Insufficient permissions to cancel jobs")
+	if !ok {
+		return
+	}
+
+	var job struct {
+		ID      uint   `json:"id"`
+		JobType string `json:"job_type"`
+		Status  string `json:"status"`
+	}
+	if !unitOfWork(c, rc.db, "Failed to cancel backup job", func(tx *gorm.DB) error {
+		if !tx.Migrator().HasTable("backup_jobs") {
+			return apierrors.New(http.StatusNotFound, apierrors.CodeNotFound, "Backup job not found")
+		}
+		if err := tx.Raw(`SELECT id, job_type, status FROM backup_jobs WHERE id = ? AND resource_id = ? FOR UPDATE`,
+			c.Param("job_id"), resource.ID).Scan(&job).Error; err != nil {
+			return err
+		}
+		if job.ID == 0 {
+			return apierrors.New(http.StatusNotFound, apierrors.CodeNotFound, "Backup job not found")
+		}
+		if job.Status != backupJobPending && job.Status != backupJobRunning {
+			return apierrors.New(http.StatusConflict, "job_not_running", "Only pending or running jobs can be cancelled")
+		}
+		job.Status = backupJobCancelled
+		if err := tx.Exec(`UPDATE backup_jobs SET status = ?, error_message = ?, completed_at = ? WHERE id = ?`,
+			job.Status, "Cancelled", time.Now().UTC(), job.ID).Error; err != nil {
+			return err
+		}
+		return audit.RecordAction(c, tx, userID.(uint), "cancel", "backup_jobs", job.ID, &resource.TeamID,
+			gin.H{"resource_id": resource.ID, "job_type": job.JobType})
+	}) {
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
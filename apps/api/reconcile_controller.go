@@ -0,0 +1,132 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/penguintechinc/project-template/shared/apierrors"
+	"gorm.io/gorm"
+)
+
+// reconcileChannel is the Postgres NOTIFY channel the K8s controller listens
+// on for reconcile requests
+const reconcileChannel = "nest_reconcile"
+
+// queueReconcile records a reconcile request for a resource, reusing one that
+// is still pending, and wakes the K8s controller when tx commits
+func queueReconcile(tx *gorm.DB, resourceID, userID uint) (ReconcileRequest, error) {
+	var request ReconcileRequest
+	err := tx.Where("resource_id = ? AND processed_at IS NULL", resourceID).First(&request).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		request = ReconcileRequest{ResourceID: resourceID, RequestedBy: userID}
+		err = tx.Create(&request).Error
+	}
+	if err != nil {
+		return request, err
+	}
+	return request, tx.Exec("SELECT pg_notify(?, ?)", reconcileChannel, strconv.FormatUint(uint64(resourceID), 10)).Error
+}
+
+// loadMemberResource loads a live resource the user can access through team
+// membership, writing a 404 or 500 response on failure
+func (rc *ResourceController) loadMemberResource(c *gin.Context, userID uint) (*Resource, bool) {
+	var resource Resource
+	if err := tenantDB(c, rc.db).Where("resources.id = ? AND resources.deleted_at IS NULL", c.Param("id")).
+		Joins("INNER JOIN team_members ON resources.team_id = team_members.team_id").
+		Where("team_members.user_id = ?", userID).
+		First(&resource).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierrors.Abort(c, http.StatusNotFound, "resource_not_found", "Resource not found or you do not have access")
+		} else {
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to retrieve resource")
+		}
+		return nil, false
+	}
+	return &resource, true
+}
+
+// TriggerReconcile queues an immediate reconcile of a full lifecycle resource
+// and wakes the K8s controller. Repeated requests while one is pending are
+// coalesced.
+// POST /api/v1/resources/:id/reconcile
+func (rc *ResourceController) TriggerReconcile(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "User context not found")
+		return
+	}
+
+	resource, ok := rc.loadMemberResource(c, userID.(uint))
+	if !ok {
+		return
+	}
+
+	userRole, _ := c.Get("user_role")
+	teamRole, _, err := rc.access.TeamRole(c.Request.Context(), userID.(uint), resource.TeamID)
+	if err != nil || (!hasMinimumRole(userRole, "admin") && !hasMinimumRole(teamRole, "maintainer")) {
+		apierrors.Abort(c, http.StatusForbidden, apierrors.CodeForbidden, "Insufficient permissions to reconcile resources")
+		return
+	}
+
+	if resource.LifecycleMode != "full" {
+		apierrors.Abort(c, http.StatusBadRequest, "not_managed", "Only full lifecycle resources are reconciled by the controller")
+		return
+	}
+
+	var request ReconcileRequest
+	err = tenantDB(c, rc.db).Transaction(func(tx *gorm.DB) error {
+		var err error
+		request, err = queueReconcile(tx, resource.ID, userID.(uint))
+		return err
+	})
+	if err != nil {
+		log.Printf("Error queueing reconcile for resource %d: %v", resource.ID, err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to queue reconcile")
+		return
+	}
+
+	c.JSON(http.StatusAccepted, request)
+}
+
+// GetReconcileStatus retrieves the controller's last reconcile outcome, retry
+// count, and next scheduled attempt for a resource
+// GET /api/v1/resources/:id/reconcile-status
+func (rc *ResourceController) GetReconcileStatus(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "User context not found")
+		return
+	}
+
+	resource, ok := rc.loadMemberResource(c, userID.(uint))
+	if !ok {
+		return
+	}
+
+	response := ReconcileStatusResponse{ResourceID: resource.ID}
+
+	var status ReconcileStatus
+	if err := tenantDB(c, rc.db).Where("resource_id = ?", resource.ID).First(&status).Error; err == nil {
+		response.ReconcileStatus = &status
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Printf("Error fetching reconcile status: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to fetch reconcile status")
+		return
+	}
+
+	var pending ReconcileRequest
+	if err := tenantDB(c, rc.db).Where("resource_id = ? AND processed_at IS NULL", resource.ID).
+		Order("created_at ASC").First(&pending).Error; err == nil {
+		response.Pending = true
+		response.RequestedAt = &pending.CreatedAt
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Printf("Error fetching reconcile requests: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to fetch reconcile status")
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
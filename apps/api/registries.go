@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// defaultImages lists the images the K8s controller deploys for each resource
+// type, including the metrics exporter sidecar
+var defaultImages = map[string][]string{
+	"postgresql": {"postgres:16-alpine", "quay.io/prometheuscommunity/postgres-exporter:v0.15.0"},
+	"mariadb":    {"mariadb:11-jammy", "prom/mysqld-exporter:v0.15.1"},
+	"redis":      {"redis:7-alpine", "oliver006/redis_exporter:v1.62.0"},
+}
+
+// manifestAccept lists the manifest media types accepted when checking images
+var manifestAccept = strings.Join([]string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}, ", ")
+
+// mirrorImage rewrites an image reference to be pulled through a mirror
+// prefix, matching the rewrite performed by the K8s controller
+func mirrorImage(image, prefix string) string {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if prefix == "" || strings.HasPrefix(image, prefix+"/") {
+		return image
+	}
+
+	path := image
+	if i := strings.Index(image, "/"); i >= 0 {
+		host := image[:i]
+		if strings.ContainsAny(host, ".:") || host == "localhost" {
+			path = image[i+1:]
+		}
+	} else {
+		path = "library/" + image
+	}
+	return prefix + "/" + path
+}
+
+// RegistryClient checks image availability through the Docker Registry v2 API
+type RegistryClient struct {
+	username string
+	password string
+	client   *http.Client
+}
+
+// NewRegistryClient creates a registry client for a registry's credentials
+func NewRegistryClient(registry *ImageRegistry) *RegistryClient {
+	return &RegistryClient{
+		username: registry.Username,
+		password: registry.Password,
+		client:   &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+// ImageExists reports whether an image reference resolves to a manifest
+func (rc *RegistryClient) ImageExists(ctx context.Context, image string) (bool, error) {
+	host, repo, ref, err := splitImage(image)
+	if err != nil {
+		return false, err
+	}
+	manifestURL := fmt.Sprintf("https://%s/v2/%s/manifests/%s", host, repo, ref)
+
+	resp, err := rc.head(ctx, manifestURL, "")
+	if err != nil {
+		return false, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		token, err := rc.token(ctx, resp.Header.Get("WWW-Authenticate"))
+		if err != nil {
+			return false, err
+		}
+		if resp, err = rc.head(ctx, manifestURL, token); err != nil {
+			return false, err
+		}
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return false, nil
+	case resp.StatusCode >= 300:
+		return false, fmt.Errorf("registry returned %s", resp.Status)
+	}
+	return true, nil
+}
+
+// head issues a manifest HEAD request, authenticating with a bearer token when
+// given and basic auth otherwise
+func (rc *RegistryClient) head(ctx context.Context, manifestURL, token string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, manifestURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", manifestAccept)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	} else if rc.username != "" {
+		req.SetBasicAuth(rc.username, rc.password)
+	}
+
+	resp, err := rc.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return resp, nil
+}
+
+// token obtains a bearer token for a registry's WWW-Authenticate challenge
+func (rc *RegistryClient) token(ctx context.Context, challenge string) (string, error) {
+	if !strings.HasPrefix(challenge, "Bearer ") {
+		return "", fmt.Errorf("registry rejected credentials")
+	}
+
+	params := map[string]string{}
+	for _, part := range strings.Split(strings.TrimPrefix(challenge, "Bearer "), ",") {
+		if k, v, ok := strings.Cut(strings.TrimSpace(part), "="); ok {
+			params[k] = strings.Trim(v, `"`)
+		}
+	}
+	if params["realm"] == "" {
+		return "", fmt.Errorf("registry challenge has no realm")
+	}
+
+	query := url.Values{}
+	for _, k := range []string{"service", "scope"} {
+		if params[k] != "" {
+			query.Set(k, params[k])
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, params["realm"]+"?"+query.Encode(), nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create token request: %w", err)
+	}
+	if rc.username != "" {
+		req.SetBasicAuth(rc.username, rc.password)
+	}
+
+	resp, err := rc.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token request returned %s", resp.Status)
+	}
+
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("failed to decode token response: %w", err)
+	}
+	if body.Token != "" {
+		return body.Token, nil
+	}
+	return body.AccessToken, nil
+}
+
+// splitImage splits a fully qualified image reference into registry host,
+// repository, and tag or digest
+func splitImage(image string) (host, repo, ref string, err error) {
+	host, rest, ok := strings.Cut(image, "/")
+	if !ok {
+		return "", "", "", fmt.Errorf("image %q has no registry host", image)
+	}
+
+	ref = "latest"
+	if i := strings.Index(rest, "@"); i >= 0 {
+		rest, ref = rest[:i], rest[i+1:]
+	} else if i := strings.LastIndex(rest, ":"); i > strings.LastIndex(rest, "/") {
+		rest, ref = rest[:i], rest[i+1:]
+	}
+	return host, rest, ref, nil
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/penguintechinc/project-template/shared/apierrors"
+	"gorm.io/gorm"
+)
+
+// RegistryController handles image registry HTTP requests
+type RegistryController struct {
+	db     *gorm.DB
+	access *AccessCache
+}
+
+// NewRegistryController creates a new image registry controller
+func NewRegistryController(db *gorm.DB, access *AccessCache) *RegistryController {
+	return &RegistryController{db: db, access: access}
+}
+
+// ListRegistries retrieves global registries and those of the user's teams
+// GET /api/v1/registries
+func (rc *RegistryController) ListRegistries(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "User context not found")
+		return
+	}
+
+	userRole, _ := c.Get("user_role")
+
+	query := tenantDB(c, rc.db).Where("deleted_at IS NULL")
+	if !hasMinimumRole(userRole, "admin") {
+		query = query.Where("team_id IS NULL OR team_id IN (?)",
+			tenantDB(c, rc.db).Model(&TeamMember{}).Select("team_id").Where("user_id = ?", userID.(uint)))
+	}
+	if cluster := c.Query("cluster"); cluster != "" {
+		query = query.Where("cluster = '' OR cluster = ?", cluster)
+	}
+
+	var registries []*ImageRegistry
+	if err := query.Order("created_at DESC").Find(&registries).Error; err != nil {
+		log.Printf("Error listing image registries: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to list image registries")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"registries": registries})
+}
+
+// CreateRegistry creates a new image registry
+// POST /api/v1/registries
+func (rc *RegistryController) CreateRegistry(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "User context not found")
+		return
+	}
+
+	var req CreateImageRegistryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierrors.AbortWithDetails(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	if !rc.canManageRegistry(c, userID.(uint), req.TeamID) {
+		return
+	}
+
+	registry := &ImageRegistry{
+		Name:         req.Name,
+		TeamID:       req.TeamID,
+		Cluster:      req.Cluster,
+		MirrorPrefix: strings.TrimSuffix(req.MirrorPrefix, "/"),
+		Username:     req.Username,
+		Password:     req.Password,
+		CreatedBy:    userID.(uint),
+	}
+
+	if err := tenantDB(c, rc.db).Create(registry).Error; err != nil {
+		log.Printf("Error creating image registry: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to create image registry")
+		return
+	}
+
+	c.JSON(http.StatusCreated, registry)
+}
+
+// UpdateRegistry updates an image registry
+// PUT /api/v1/registries/:id
+func (rc *RegistryController) UpdateRegistry(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "User context not found")
+		return
+	}
+
+	registry, ok := rc.loadRegistry(c)
+	if !ok {
+		return
+	}
+
+	if !rc.canManageRegistry(c, userID.(uint), registry.TeamID) {
+		return
+	}
+
+	var req UpdateImageRegistryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierrors.AbortWithDetails(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	if req.Name != nil {
+		registry.Name = *req.Name
+	}
+	if req.Cluster != nil {
+		registry.Cluster = *req.Cluster
+	}
+	if req.MirrorPrefix != nil && *req.MirrorPrefix != "" {
+		registry.MirrorPrefix = strings.TrimSuffix(*req.MirrorPrefix, "/")
+	}
+	if req.Username != nil {
+		registry.Username = *req.Username
+	}
+	if req.Password != nil {
+		registry.Password = *req.Password
+	}
+
+	if err := tenantDB(c, rc.db).Save(registry).Error; err != nil {
+		log.Printf("Error updating image registry: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to update image registry")
+		return
+	}
+
+	c.JSON(http.StatusOK, registry)
+}
+
+// DeleteRegistry deletes an image registry
+// DELETE /api/v1/registries/:id
+func (rc *RegistryController) DeleteRegistry(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "User context not found")
+		return
+	}
+
+	registry, ok := rc.loadRegistry(c)
+	if !ok {
+		return
+	}
+
+	if !rc.canManageRegistry(c, userID.(uint), registry.TeamID) {
+		return
+	}
+
+	if err := tenantDB(c, rc.db).Delete(registry).Error; err != nil {
+		log.Printf("Error deleting image registry: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to delete image registry")
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// DryRunRegistry rewrites images as the K8s controller would and checks that
+// each is available in the registry, without changing any resource
+// POST /api/v1/registries/:id/dry-run
+func (rc *RegistryController) DryRunRegistry(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "User context not found")
+		return
+	}
+
+	registry, ok := rc.loadRegistry(c)
+	if !ok {
+		return
+	}
+
+	if !rc.canManageRegistry(c, userID.(uint), registry.TeamID) {
+		return
+	}
+
+	var req RegistryDryRunRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierrors.AbortWithDetails(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Invalid request body", err.Error())
+			return
+		}
+	}
+
+	images := req.Images
+	if len(images) == 0 {
+		if req.ResourceType != "" {
+			var known bool
+			if images, known = defaultImages[req.ResourceType]; !known {
+				apierrors.Abort(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Unknown resource type")
+				return
+			}
+		} else {
+			types := make([]string, 0, len(defaultImages))
+			for t := range defaultImages {
+				types = append(types, t)
+			}
+			sort.Strings(types)
+			for _, t := range types {
+				images = append(images, defaultImages[t]...)
+			}
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	client := NewRegistryClient(registry)
+	response := RegistryDryRunResponse{RegistryID: registry.ID, AllAvailable: true}
+	for _, image := range images {
+		result := ImageCheckResult{Image: image, Mirrored: mirrorImage(image, registry.MirrorPrefix)}
+		available, err := client.ImageExists(ctx, result.Mirrored)
+		result.Available = available
+		if err != nil {
+			result.Error = err.Error()
+		}
+		if !available {
+			response.AllAvailable = false
+		}
+		response.Images = append(response.Images, result)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// loadRegistry fetches the image registry named by the :id path parameter
+func (rc *RegistryController) loadRegistry(c *gin.Context) (*ImageRegistry, bool) {
+	var registry ImageRegistry
+	if err := tenantDB(c, rc.db).Where("id = ? AND deleted_at IS NULL", c.Param("id")).First(&registry).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierrors.Abort(c, http.StatusNotFound, "registry_not_found", "Image registry not found")
+		} else {
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to retrieve image registry")
+		}
+		return nil, false
+	}
+	return &registry, true
+}
+
+// canManageRegistry verifies the user may manage a registry: global
+// registries require a global admin, team registries a team admin
+func (rc *RegistryController) canManageRegistry(c *gin.Context, userID uint, teamID *uint) bool {
+	userRole, _ := c.Get("user_role")
+	if hasMinimumRole(userRole, "admin") {
+		return true
+	}
+
+	if teamID != nil {
+		role, isMember, err := rc.access.TeamRole(c.Request.Context(), userID, *teamID)
+		if err == nil && isMember && hasMinimumRole(role, "admin") {
+			return true
+		}
+	}
+
+	apierrors.Abort(c, http.StatusForbidden, apierrors.CodeForbidden, "Insufficient permissions to manage this image registry")
+	return false
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"reflect"
+
+	"github.com/gin-gonic/gin"
+	"github.com/penguintechinc/project-template/shared/apierrors"
+	"github.com/penguintechinc/project-template/shared/audit"
+	"github.com/penguintechinc/project-template/shared/database"
+	"github.com/penguintechinc/project-template/shared/locks"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// Apply actions
+const (
+	applyCreate    = "create"
+	applyUpdate    = "update"
+	applyUnchanged = "unchanged"
+)
+
+// ApplyResource brings a resource in line with a manifest: the resource
+// definition git sync reads, with metadata.team naming its team. A resource
+// of the manifest's name is created when none exists in the environment, and
+// updated otherwise by a three-way merge of its config with the manifest and
+// the manifest last applied, so config keys set outside apply are kept and
+// keys removed from the manifest are removed. With ?dry_run=true the changes
+// are reported without being made.
+// POST /api/v1/apply
+func (rc *ResourceController) ApplyResource(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "User context not found")
+		return
+	}
+	dryRun := c.Query("dry_run") == "true"
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, gitSyncMaxFileSize+1))
+	if err != nil {
+		apierrors.AbortWithDetails(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Invalid request body", err.Error())
+		return
+	}
+	if len(body) > gitSyncMaxFileSize {
+		apierrors.Abort(c, http.StatusRequestEntityTooLarge, "manifest_too_large", "The manifest is too large")
+		return
+	}
+	defs, err := parseResourceDefinitions(map[string][]byte{"manifest": body})
+	if err != nil {
+		apierrors.Abort(c, http.StatusBadRequest, "invalid_manifest", err.Error())
+		return
+	}
+	if len(defs) != 1 {
+		apierrors.Abort(c, http.StatusBadRequest, "invalid_manifest", "The request must contain exactly one Resource manifest")
+		return
+	}
+	def := defs[0]
+	if def.Metadata.Team == "" {
+		apierrors.Abort(c, http.StatusBadRequest, "invalid_manifest", "metadata.team is required")
+		return
+	}
+
+	db := tenantDB(c, rc.db)
+	var team Team
+	if err := db.Where("name = ?", def.Metadata.Team).First(&team).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierrors.Abort(c, http.StatusNotFound, "team_not_found", "Team not found")
+		} else {
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to verify team")
+		}
+		return
+	}
+
+	userRole, _ := c.Get("user_role")
+	teamRole, _, err := rc.access.TeamRole(c.Request.Context(), userID.(uint), team.ID)
+	if err != nil || (!hasMinimumRole(userRole, "admin") && !hasMinimumRole(teamRole, "maintainer")) {
+		apierrors.Abort(c, http.StatusForbidden, apierrors.CodeForbidden, "Insufficient permissions to apply resources")
+		return
+	}
+
+	envs, err := teamEnvironments(db, team.ID)
+	if err != nil {
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to load environments")
+		return
+	}
+	envIdx := 0
+	if def.Spec.Environment != "" {
+		envIdx = findEnvironment(envs, def.Spec.Environment)
+	}
+	if envIdx < 0 {
+		apierrors.Abort(c, http.StatusBadRequest, "invalid_environment", "Environment is not part of the team's pipeline")
+		return
+	}
+	desired, err := definedResource(db, team.ID, &envs[envIdx], def)
+	if err != nil {
+		apierrors.Abort(c, http.StatusBadRequest, "invalid_manifest", err.Error())
+		return
+	}
+
+	resp := ApplyResponse{DryRun: dryRun, Fields: []ConfigChange{}}
+	var resource *Resource
+	if !unitOfWork(c, rc.db, "Failed to apply resource", func(tx *gorm.DB) error {
+		if err := locks.Lock(tx, resourceNameLock(desired.TeamID, desired.Environment, desired.Name)); err != nil {
+			return err
+		}
+		var existing Resource
+		err := tx.Where("team_id = ? AND environment = ? AND name = ?", desired.TeamID, desired.Environment, desired.Name).
+			First(&existing).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			resource = desired
+			return rc.applyCreate(c, tx, userID.(uint), resource, &resp)
+		}
+		if err != nil {
+			return err
+		}
+		resource = &existing
+		return rc.applyUpdate(c, tx, userID.(uint), resource, desired, &resp)
+	}) {
+		return
+	}
+
+	status := http.StatusOK
+	if resp.Action == applyCreate && !dryRun {
+		status = http.StatusCreated
+
+		// Provision monitoring dashboards for the new resource
+		primary := database.UsePrimary(tenantDB(c, rc.db))
+		go func(id uint) {
+			if err := NewGrafanaProvisioner(primary).ProvisionResource(context.Background(), id); err != nil {
+				log.Printf("Grafana provisioning failed for resource %d: %v", id, err)
+			}
+		}(resource.ID)
+	}
+	if resource.ID != 0 {
+		database.UsePrimary(tenantDB(c, rc.db)).Preload("ResourceType").Preload("Team").First(resource, resource.ID)
+	}
+	resp.Resource = resourceToResponse(resource)
+	c.JSON(status, resp)
+}
+
+// applyCreate creates the resource a manifest defines, as a create
+// operation of the K8s controller
+func (rc *ResourceController) applyCreate(c *gin.Context, tx *gorm.DB, userID uint, resource *Resource, resp *ApplyResponse) error {
+	var cfg map[string]interface{}
+	if !decodeJSONField(resource.Config, &cfg, "manifest config") {
+		return fmt.Errorf("config of %s doesn't decode", resource.Name)
+	}
+
+	resp.Action = applyCreate
+	resp.Fields = []ConfigChange{
+		{Key: "resource_type_id", To: resource.ResourceTypeID},
+		{Key: "size_class", To: resource.SizeClass},
+		{Key: "tls_enabled", To: resource.TLSEnabled},
+		{Key: "deletion_protection", To: resource.DeletionProtection},
+	}
+	resp.Changes = diffConfig(nil, cfg)
+	if apiErr := rc.admit(c, PolicyOperationCreate, resource, nil); apiErr != nil {
+		return apiErr
+	}
+	if resp.DryRun {
+		return nil
+	}
+
+	finalizers, _ := json.Marshal([]string{ControllerFinalizer})
+	resource.CreatedBy = userID
+	resource.Finalizers = datatypes.JSON(finalizers)
+	resource.AppliedConfig = resource.Config
+	if err := tx.Create(resource).Error; err != nil {
+		return err
+	}
+	if err := audit.Record(c, tx, userID, "resources", resource.ID, &resource.TeamID, nil, resource); err != nil {
+		return err
+	}
+	op, err := startOperation(tx, OperationCreate, resource, userID)
+	if err != nil {
+		return err
+	}
+	resp.OperationID = &op.ID
+	_, err = queueReconcile(tx, resource.ID, userID)
+	return err
+}
+
+// applyUpdate updates a resource to the manifest, merging the manifest's
+// config into the resource's by the config last applied
+func (rc *ResourceController) applyUpdate(c *gin.Context, tx *gorm.DB, userID uint, resource, desired *Resource, resp *ApplyResponse) error {
+	if resource.LifecycleMode != "full" {
+		return apierrors.New(http.StatusConflict, "not_managed", "Only full lifecycle resources can be applied")
+	}
+	if resource.ResourceTypeID != desired.ResourceTypeID {
+		return apierrors.New(http.StatusBadRequest, "invalid_manifest",
+			fmt.Sprintf("The engine of %s can't be changed; define a new resource instead", resource.Name))
+	}
+	if resource.SizeClass != desired.SizeClass {
+		return apierrors.New(http.StatusBadRequest, "invalid_manifest",
+			fmt.Sprintf("The size class of %s can't be changed by apply; resize it through the API and update its manifest", resource.Name))
+	}
+	var synced int64
+	if err := tx.Model(&GitSyncedResource{}).Where("resource_id = ?", resource.ID).Count(&synced).Error; err != nil {
+		return err
+	}
+	if synced > 0 {
+		return apierrors.New(http.StatusConflict, "git_synced", "The resource is managed by a git sync integration")
+	}
+
+	// Merging against a stored config that doesn't decode would drop the
+	// keys set outside apply, so the apply is refused instead
+	var live, last, wanted map[string]interface{}
+	if !decodeJSONField(resource.Config, &live, "config") || !decodeJSONField(resource.AppliedConfig, &last, "applied config") {
+		return apierrors.New(http.StatusConflict, "config_unreadable",
+			fmt.Sprintf("The stored config of %s can't be read, so the manifest can't be merged into it", resource.Name))
+	}
+	if !decodeJSONField(desired.Config, &wanted, "manifest config") {
+		return fmt.Errorf("config of %s doesn't decode", desired.Name)
+	}
+	merged := mergeAppliedConfig(live, last, wanted)
+
+	resp.Changes = diffConfig(live, merged)
+	if resource.TLSEnabled != desired.TLSEnabled {
+		resp.Fields = append(resp.Fields, ConfigChange{Key: "tls_enabled", From: resource.TLSEnabled, To: desired.TLSEnabled})
+	}
+	if resource.DeletionProtection != desired.DeletionProtection {
+		resp.Fields = append(resp.Fields, ConfigChange{Key: "deletion_protection", From: resource.DeletionProtection, To: desired.DeletionProtection})
+	}
+	resp.Action = applyUnchanged
+	if len(resp.Changes) > 0 || len(resp.Fields) > 0 {
+		resp.Action = applyUpdate
+	}
+
+	before := *resource
+	encoded, err := json.Marshal(merged)
+	if err != nil {
+		return err
+	}
+	updated := *resource
+	updated.Config = datatypes.JSON(encoded)
+	updated.TLSEnabled = desired.TLSEnabled
+	updated.DeletionProtection = desired.DeletionProtection
+	if resp.Action == applyUpdate {
+		if apiErr := rc.admit(c, PolicyOperationUpdate, &updated, &before); apiErr != nil {
+			return apiErr
+		}
+	}
+	if resp.DryRun {
+		return nil
+	}
+
+	// The applied config is kept up to date even when nothing changed, so
+	// the next apply merges against this manifest
+	if resp.Action == applyUnchanged {
+		if reflect.DeepEqual(normalizeConfig(last), normalizeConfig(wanted)) {
+			return nil
+		}
+		return tx.Model(&Resource{}).Where("id = ?", resource.ID).UpdateColumn("applied_config", desired.Config).Error
+	}
+
+	*resource = updated
+	resource.AppliedConfig = desired.Config
+	if err := tx.Model(&Resource{}).Where("id = ?", resource.ID).Updates(map[string]interface{}{
+		"config":              resource.Config,
+		"tls_enabled":         resource.TLSEnabled,
+		"deletion_protection": resource.DeletionProtection,
+		"applied_config":      resource.AppliedConfig,
+		"policy_violations":   resource.PolicyViolations,
+		"generation":          nextGeneration,
+	}).Error; err != nil {
+		return err
+	}
+	return audit.Record(c, tx, userID, "resources", resource.ID, &resource.TeamID, &before, resource)
+}
+
+// mergeAppliedConfig merges the config of a manifest into a resource's live
+// config. Keys the last applied manifest set and this one doesn't are
+// removed; keys set outside apply are kept. Nested objects merge the same
+// way.
+func mergeAppliedConfig(live, last, desired map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(live)+len(desired))
+	for key, value := range live {
+		merged[key] = value
+	}
+	for key := range last {
+		if _, ok := desired[key]; !ok {
+			delete(merged, key)
+		}
+	}
+	for key, value := range desired {
+		wanted, wantedMap := value.(map[string]interface{})
+		current, currentMap := merged[key].(map[string]interface{})
+		if wantedMap && currentMap {
+			previous, _ := last[key].(map[string]interface{})
+			merged[key] = mergeAppliedConfig(current, previous, wanted)
+			continue
+		}
+		merged[key] = value
+	}
+	return merged
+}
+
+// normalizeConfig treats an empty config as no config
+func normalizeConfig(cfg map[string]interface{}) map[string]interface{} {
+	if len(cfg) == 0 {
+		return nil
+	}
+	return cfg
+}
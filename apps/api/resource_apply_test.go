@@ -0,0 +1,67 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMergeAppliedConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		live    map[string]interface{}
+		last    map[string]interface{}
+		desired map[string]interface{}
+		want    map[string]interface{}
+	}{
+		{
+			name:    "key removed from the manifest",
+			live:    map[string]interface{}{"max_connections": 200.0, "timezone": "UTC"},
+			last:    map[string]interface{}{"max_connections": 200.0, "timezone": "UTC"},
+			desired: map[string]interface{}{"timezone": "UTC"},
+			want:    map[string]interface{}{"timezone": "UTC"},
+		},
+		{
+			name:    "key set outside apply",
+			live:    map[string]interface{}{"timezone": "UTC", "replicas": 3.0},
+			last:    map[string]interface{}{"timezone": "UTC"},
+			desired: map[string]interface{}{"timezone": "Europe/Berlin"},
+			want:    map[string]interface{}{"timezone": "Europe/Berlin", "replicas": 3.0},
+		},
+		{
+			name:    "first apply of an existing resource",
+			live:    map[string]interface{}{"replicas": 3.0},
+			desired: map[string]interface{}{"timezone": "UTC"},
+			want:    map[string]interface{}{"replicas": 3.0, "timezone": "UTC"},
+		},
+		{
+			name: "nested maps",
+			live: map[string]interface{}{
+				"tuning": map[string]interface{}{"work_mem": "8MB", "shared_buffers": "1GB", "wal_level": "logical"},
+			},
+			last: map[string]interface{}{
+				"tuning": map[string]interface{}{"work_mem": "8MB", "shared_buffers": "1GB"},
+			},
+			desired: map[string]interface{}{
+				"tuning": map[string]interface{}{"work_mem": "16MB"},
+			},
+			want: map[string]interface{}{
+				"tuning": map[string]interface{}{"work_mem": "16MB", "wal_level": "logical"},
+			},
+		},
+		{
+			name:    "map replaced by a value",
+			live:    map[string]interface{}{"tuning": map[string]interface{}{"work_mem": "8MB"}},
+			last:    map[string]interface{}{"tuning": map[string]interface{}{"work_mem": "8MB"}},
+			desired: map[string]interface{}{"tuning": "default"},
+			want:    map[string]interface{}{"tuning": "default"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mergeAppliedConfig(tt.live, tt.last, tt.desired); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/penguintechinc/project-template/shared/apierrors"
+	"github.com/penguintechinc/project-template/shared/locks"
+	"gorm.io/gorm"
+)
+
+// operationLockTTL is how long an operation holds its resource's lock when
+// the K8s controller never finishes it, such as when the operation is lost
+// in a crash. The controller releases the lock as soon as it records the
+// operation's outcome.
+const operationLockTTL = 2 * time.Hour
+
+// resourceLockedCode is the error code of 423 responses to changes of a
+// locked resource
+const resourceLockedCode = "resource_locked"
+
+// activeResourceLock returns the unexpired lock on a resource, or nil when
+// it is unlocked
+func activeResourceLock(tx *gorm.DB, resourceID uint) (*ResourceLock, error) {
+	var lock ResourceLock
+	err := tx.Where("resource_id = ? AND expires_at > ?", resourceID, time.Now().UTC()).First(&lock).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &lock, nil
+}
+
+// resourceLockedError is the 423 response to a change of a resource someone
+// else holds the lock of
+func resourceLockedError(lock *ResourceLock) *apierrors.Error {
+	return apierrors.New(http.StatusLocked, resourceLockedCode, "The resource is locked by another operation").
+		WithDetails(lock)
+}
+
+// acquireOperationLock locks a resource in tx for an operation on it, until
+// the K8s controller finishes the operation or the lock expires. It returns
+// a 423 *apierrors.Error when another operation holds the lock.
+func acquireOperationLock(tx *gorm.DB, resource *Resource, op *Operation) error {
+	// Two requests for the same resource wait for each other here, so the
+	// second sees the first's lock
+	if err := locks.Lock(tx, locks.Key("resource-lock", resource.ID)); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	if err := tx.Unscoped().Where("resource_id = ? AND expires_at <= ?", resource.ID, now).
+		Delete(&ResourceLock{}).Error; err != nil {
+		return err
+	}
+	existing, err := activeResourceLock(tx, resource.ID)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return resourceLockedError(existing)
+	}
+
+	return tx.Create(&ResourceLock{
+		ResourceID:  resource.ID,
+		Holder:      fmt.Sprintf("operation:%d", op.ID),
+		Reason:      op.Type,
+		OperationID: &op.ID,
+		AcquiredBy:  op.RequestedBy,
+		ExpiresAt:   now.Add(operationLockTTL),
+	}).Error
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/penguintechinc/project-template/shared/apierrors"
+	"github.com/penguintechinc/project-template/shared/audit"
+	"gorm.io/gorm"
+)
+
+// ResourceLockController lets admins see and break the locks operations
+// hold on resources
+type ResourceLockController struct {
+	db *gorm.DB
+}
+
+// NewResourceLockController creates a new resource lock controller
+func NewResourceLockController(db *gorm.DB) *ResourceLockController {
+	return &ResourceLockController{db: db}
+}
+
+// GetResourceLock returns the lock an operation holds on a resource, or a
+// null lock when it is unlocked
+// GET /api/v1/resources/:id/lock
+func (rc *ResourceController) GetResourceLock(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "User context not found")
+		return
+	}
+
+	resource, ok := rc.loadMemberResource(c, userID.(uint))
+	if !ok {
+		return
+	}
+
+	lock, err := activeResourceLock(tenantDB(c, rc.db), resource.ID)
+	if err != nil {
+		log.Printf("Error checking resource lock: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to check resource lock")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"lock": lock})
+}
+
+// ListResourceLocks lists the unexpired resource locks, oldest first
+// GET /api/v1/admin/resource-locks
+func (lc *ResourceLockController) ListResourceLocks(c *gin.Context) {
+	if !requireGlobalAdmin(c) {
+		return
+	}
+
+	resourceLocks := []*ResourceLock{}
+	if err := tenantDB(c, lc.db).Where("expires_at > ?", time.Now().UTC()).
+		Order("created_at").Find(&resourceLocks).Error; err != nil {
+		log.Printf("Error listing resource locks: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to list resource locks")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"resource_locks": resourceLocks})
+}
+
+// ForceUnlockResource releases a resource's lock, such as one held by an
+// operation that is stuck, so other operations can start. The operation
+// itself is left as it is.
+// DELETE /api/v1/admin/resource-locks/:resource_id
+func (lc *ResourceLockController) ForceUnlockResource(c *gin.Context) {
+	if !requireGlobalAdmin(c) {
+		return
+	}
+	userID, _ := c.Get("user_id")
+
+	var lock ResourceLock
+	if err := tenantDB(c, lc.db).Where("resource_id = ?", c.Param("resource_id")).First(&lock).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierrors.Abort(c, http.StatusNotFound, apierrors.CodeNotFound, "Resource is not locked")
+		} else {
+			log.Printf("Error retrieving resource lock: %v", err)
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to retrieve resource lock")
+		}
+		return
+	}
+
+	if !unitOfWork(c, lc.db, "Failed to release resource lock", func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Delete(&lock).Error; err != nil {
+			return err
+		}
+		return audit.RecordAction(c, tx, userID.(uint), "force_unlock", "resources", lock.ResourceID, nil, gin.H{
+			"holder":       lock.Holder,
+			"reason":       lock.Reason,
+			"operation_id": lock.OperationID,
+		})
+	}) {
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Resource deletion states. A soft-deleted resource keeps an empty state and
+// stays restorable until its restore window passes.
+const (
+	// DeletionStateDeleting marks a resource whose Kubernetes objects must be
+	// removed before it can be purged
+	DeletionStateDeleting = "deleting"
+	// DeletionStateDeprovisioned is reached once every finalizer is released
+	DeletionStateDeprovisioned = "deprovisioned"
+	// DeletionStatePurged marks the tombstone left after purging
+	DeletionStatePurged = "purged"
+)
+
+// ControllerFinalizer is held by the K8s controller on full lifecycle
+// resources until it has removed their Kubernetes objects
+const ControllerFinalizer = "nest.penguintech.io/k8s-resources"
+
+// resourceFinalizers decodes a resource's finalizer list. A list that
+// doesn't decode is an error rather than no finalizers, which would let the
+// resource be purged while the controller still holds it.
+func resourceFinalizers(r *Resource) ([]string, error) {
+	finalizers := []string{}
+	if len(r.Finalizers) > 0 {
+		if err := json.Unmarshal(r.Finalizers, &finalizers); err != nil {
+			return nil, fmt.Errorf("failed to decode finalizers of resource %d: %w", r.ID, err)
+		}
+	}
+	return finalizers, nil
+}
+
+// ResourcePurger drives soft-deleted resources through the deletion
+// lifecycle once their restore window passes: they move to deleting, to
+// deprovisioned once the K8s controller releases its finalizer, and are then
+// purged, leaving a tombstone with credentials and config removed. It runs
+// as a periodic job.
+type ResourcePurger struct {
+	db        *gorm.DB
+	retention time.Duration
+}
+
+// NewResourcePurger creates a resource purger
+func NewResourcePurger(db *gorm.DB, retention time.Duration) *ResourcePurger {
+	return &ResourcePurger{db: db, retention: retention}
+}
+
+// Purge advances every resource in the deletion lifecycle by one step
+func (p *ResourcePurger) Purge(ctx context.Context) error {
+	db := p.db.WithContext(ctx).Unscoped().Model(&Resource{}).Session(&gorm.Session{})
+	cutoff := time.Now().UTC().Add(-p.retention)
+
+	// Expired trash starts deprovisioning
+	if err := db.Where("deleted_at IS NOT NULL AND deleted_at < ? AND deletion_state = ''", cutoff).
+		Update("deletion_state", DeletionStateDeleting).Error; err != nil {
+		return err
+	}
+
+	// Resources no controller holds a finalizer on are deprovisioned at once
+	if err := db.Where("deleted_at IS NOT NULL AND deletion_state = ?", DeletionStateDeleting).
+		Where("finalizers IS NULL OR finalizers = '[]'::jsonb").
+		Update("deletion_state", DeletionStateDeprovisioned).Error; err != nil {
+		return err
+	}
+
+	var resources []Resource
+	if err := p.db.WithContext(ctx).Unscoped().
+		Where("deleted_at IS NOT NULL AND deletion_state = ?", DeletionStateDeprovisioned).
+		Find(&resources).Error; err != nil {
+		return err
+	}
+
+	for i := range resources {
+		resource := &resources[i]
+		finalizers, err := resourceFinalizers(resource)
+		if err != nil {
+			log.Printf("Skipping purge of resource %d: %v", resource.ID, err)
+			continue
+		}
+		if len(finalizers) > 0 {
+			log.Printf("Skipping purge of resource %d: finalizers %v are still held", resource.ID, finalizers)
+			continue
+		}
+		if err := p.purge(ctx, resource); err != nil {
+			log.Printf("Failed to purge resource %d: %v", resource.ID, err)
+			continue
+		}
+		log.Printf("Purged resource %d (%s)", resource.ID, resource.Name)
+	}
+
+	return nil
+}
+
+// purge removes the rows that reference a resource and reduces the resource
+// to a tombstone
+func (p *ResourcePurger) purge(ctx context.Context, resource *Resource) error {
+	return p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, model := range []interface{}{&ResourceStats{}, &Alert{}, &AlertRule{}, &ReconcileRequest{}, &ReconcileStatus{}, &StuckResource{}, &CredentialUsage{}} {
+			if err := tx.Unscoped().Where("resource_id = ?", resource.ID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		// Bindings are only soft-deleted, so the K8s controller removes
+		// their Secrets before dropping them
+		if err := tx.Where("resource_id = ?", resource.ID).Delete(&ConsumerBinding{}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Model(resource).UpdateColumns(map[string]interface{}{
+			"deletion_state":  DeletionStatePurged,
+			"credentials":     gorm.Expr("NULL"),
+			"connection_info": gorm.Expr("NULL"),
+			"config":          gorm.Expr("NULL"),
+			"applied_config":  gorm.Expr("NULL"),
+		}).Error
+	})
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/penguintechinc/project-template/shared/apierrors"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// controllerPeerName is the mutual TLS identity of the K8s controller
+const controllerPeerName = "nest-controller"
+
+// resourceSpecColumns are the columns of a resource that users change with
+// PUT /api/v1/resources/:id. Everything the K8s controller observes, such
+// as status, connection_info and the Kubernetes object it manages, is
+// status, written only through the status subresource, so a user's edit
+// can't overwrite what the controller recorded in the meantime.
+var resourceSpecColumns = []string{"name", "config", "deletion_protection", "priority_class", "updated_at"}
+
+// nextGeneration is the update of the generation column on a change of a
+// resource's spec
+var nextGeneration = gorm.Expr("generation + 1")
+
+// specChanged reports whether an update changed a resource's spec
+func specChanged(before, after *Resource) bool {
+	return before.Name != after.Name ||
+		!bytes.Equal(before.Config, after.Config) ||
+		before.DeletionProtection != after.DeletionProtection ||
+		before.PriorityClass != after.PriorityClass
+}
+
+// bumpGeneration records a change of a resource's spec, setting its
+// Generation to the new generation
+func bumpGeneration(tx *gorm.DB, resource *Resource) error {
+	return tx.Model(resource).Clauses(clause.Returning{Columns: []clause.Column{{Name: "generation"}}}).
+		UpdateColumn("generation", nextGeneration).Error
+}
+
+// validResourceStatuses are the statuses a resource can be in
+var validResourceStatuses = map[string]bool{
+	"pending": true, "provisioning": true, "active": true,
+	"updating": true, "paused": true, "error": true, "deleted": true,
+}
+
+// requireController verifies the caller is the K8s controller, by its
+// mutual TLS identity, writing a 401 or 403 response if not
+func requireController(c *gin.Context) bool {
+	peer, ok := c.Get("peer_service")
+	if !ok {
+		apierrors.Abort(c, http.StatusUnauthorized, "client_certificate_required", "A client certificate is required")
+		return false
+	}
+	if _, workload := c.Get("workload_identity"); workload || peer.(string) != controllerPeerName {
+		apierrors.Abort(c, http.StatusForbidden, apierrors.CodeForbidden, "Only the K8s controller may write resource status")
+		return false
+	}
+	return true
+}
+
+// UpdateResourceStatus records what the K8s controller observed of a
+// resource. Only the fields set are written, and none of the resource's
+// spec.
+// PUT /api/v1/resources/:id/status
+func (rc *ResourceController) UpdateResourceStatus(c *gin.Context) {
+	if !requireController(c) {
+		return
+	}
+
+	var req ResourceStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierrors.AbortWithDetails(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Invalid request body", err.Error())
+		return
+	}
+	if req.Status != nil && !validResourceStatuses[*req.Status] {
+		apierrors.Abort(c, http.StatusBadRequest, "invalid_status", "Invalid status value")
+		return
+	}
+
+	var resource Resource
+	if err := tenantDB(c, rc.db).Unscoped().First(&resource, c.Param("id")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierrors.Abort(c, http.StatusNotFound, "resource_not_found", "Resource not found")
+		} else {
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to retrieve resource")
+		}
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if req.Status != nil {
+		updates["status"] = *req.Status
+	}
+	if req.ConnectionInfo != nil {
+		info, _ := json.Marshal(req.ConnectionInfo)
+		updates["connection_info"] = datatypes.JSON(info)
+	}
+	if req.K8sNamespace != nil {
+		updates["k8s_namespace"] = *req.K8sNamespace
+	}
+	if req.K8sResourceName != nil {
+		updates["k8s_resource_name"] = *req.K8sResourceName
+	}
+	if req.K8sResourceType != nil {
+		updates["k8s_resource_type"] = *req.K8sResourceType
+	}
+	if req.ObservedGeneration != nil {
+		if *req.ObservedGeneration > resource.Generation {
+			apierrors.Abort(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "observed_generation is ahead of the resource's generation")
+			return
+		}
+		updates["observed_generation"] = *req.ObservedGeneration
+	}
+	if req.Conditions != nil {
+		conditions, _ := json.Marshal(req.Conditions)
+		updates["conditions"] = datatypes.JSON(conditions)
+	}
+	if len(updates) == 0 {
+		apierrors.Abort(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "No status fields to update")
+		return
+	}
+
+	if err := tenantDB(c, rc.db).Unscoped().Model(&resource).Updates(updates).Error; err != nil {
+		log.Printf("Error updating status of resource %d: %v", resource.ID, err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to update resource status")
+		return
+	}
+
+	c.JSON(http.StatusOK, resourceToResponse(&resource))
+}
@@ -1,26 +1,43 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
 	"log"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/penguintechinc/project-template/shared/apierrors"
+	"github.com/penguintechinc/project-template/shared/audit"
+	"github.com/penguintechinc/project-template/shared/database"
+	"github.com/penguintechinc/project-template/shared/fields"
+	"github.com/penguintechinc/project-template/shared/locks"
+	"github.com/penguintechinc/project-template/shared/pagination"
 	"gorm.io/datatypes"
 	"gorm.io/gorm"
 )
 
 // ResourceController handles resource-related HTTP requests
 type ResourceController struct {
-	db *gorm.DB
+	db             *gorm.DB
+	access         *AccessCache
+	passwords      *PasswordPolicies
+	policies       *PolicyEngine
+	webhooks       *ValidationWebhooks
+	trashRetention time.Duration
 }
 
-// NewResourceController creates a new resource controller
-func NewResourceController(db *gorm.DB) *ResourceController {
-	return &ResourceController{db: db}
+// NewResourceController creates a new resource controller. Deleted resources
+// can be restored for trashRetention before they are purged.
+func NewResourceController(db *gorm.DB, access *AccessCache, passwords *PasswordPolicies, policies *PolicyEngine,
+	webhooks *ValidationWebhooks, trashRetention time.Duration) *ResourceController {
+	return &ResourceController{
+		db: db, access: access, passwords: passwords, policies: policies, webhooks: webhooks, trashRetention: trashRetention,
+	}
 }
 
 // ListResources retrieves all resources visible to the current user
@@ -29,82 +46,39 @@ func (rc *ResourceController) ListResources(c *gin.Context) {
 	// Extract user context (would be set by auth middleware)
 	userID, exists := c.Get("user_id")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, ErrorResponse{
-			Error:   "unauthorized",
-			Message: "User context not found",
-		})
+		apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "User context not found")
 		return
 	}
 
 	userIDUint := userID.(uint)
 
 	// Get pagination parameters
-	page := 1
-	if p := c.Query("page"); p != "" {
-		if parsed, err := strconv.Atoi(p); err == nil && parsed > 0 {
-			page = parsed
-		}
-	}
-
-	pageSize := 20
-	if ps := c.Query("page_size"); ps != "" {
-		if parsed, err := strconv.Atoi(ps); err == nil && parsed > 0 && parsed <= 100 {
-			pageSize = parsed
-		}
-	}
-
-	// Get filter parameters
-	teamID := c.Query("team_id")
-	status := c.Query("status")
-	resourceTypeID := c.Query("resource_type_id")
+	page, pageSize := pagination.Parse(c, 20, 100)
 
 	// Build query - resources scoped by user's team membership
-	query := rc.db.Where("resources.deleted_at IS NULL").
+	query := tenantDB(c, rc.db).Where("resources.deleted_at IS NULL").
 		Joins("INNER JOIN team_members ON resources.team_id = team_members.team_id").
-		Where("team_members.user_id = ?", userIDUint).
-		Preload("ResourceType").
-		Preload("Team")
-
-	// Apply filters
-	if teamID != "" {
-		if tid, err := strconv.ParseUint(teamID, 10, 32); err == nil {
-			query = query.Where("resources.team_id = ?", uint(tid))
-		}
-	}
-
-	if status != "" {
-		query = query.Where("resources.status = ?", status)
-	}
-
-	if resourceTypeID != "" {
-		if rtid, err := strconv.ParseUint(resourceTypeID, 10, 32); err == nil {
-			query = query.Where("resources.resource_type_id = ?", uint(rtid))
-		}
-	}
+		Where("team_members.user_id = ?", userIDUint)
+	query = fields.Preload(c, query, "ResourceType", "Team")
+	query = filterResources(c, query)
 
 	// Count total
 	var total int64
 	countQuery := query
 	if err := countQuery.Model(&Resource{}).Count(&total).Error; err != nil {
 		log.Printf("Error counting resources: %v", err)
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
-			Error:   "database_error",
-			Message: "Failed to count resources",
-		})
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to count resources")
 		return
 	}
 
 	// Paginate
 	offset := (page - 1) * pageSize
-	query = query.Offset(offset).Limit(pageSize).Order("created_at DESC")
+	query = query.Offset(offset).Limit(pageSize).Order("resources.created_at DESC")
 
 	var resources []*Resource
 	if err := query.Find(&resources).Error; err != nil {
 		log.Printf("Error listing resources: %v", err)
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
-			Error:   "database_error",
-			Message: "Failed to list resources",
-		})
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to list resources")
 		return
 	}
 
@@ -114,6 +88,7 @@ func (rc *ResourceController) ListResources(c *gin.Context) {
 		responses = append(responses, resourceToResponse(r))
 	}
 
+	pagination.SetHeaders(c, page, pageSize, total)
 	c.JSON(http.StatusOK, ResourceListResponse{
 		Resources: responses,
 		Total:     total,
@@ -122,15 +97,39 @@ func (rc *ResourceController) ListResources(c *gin.Context) {
 	})
 }
 
-// CreateResource creates a new resource
+// filterResources applies the team_id, status, environment and
+// resource_type_id query parameters of the resource list to a query
+func filterResources(c *gin.Context, query *gorm.DB) *gorm.DB {
+	if teamID := c.Query("team_id"); teamID != "" {
+		if tid, err := strconv.ParseUint(teamID, 10, 32); err == nil {
+			query = query.Where("resources.team_id = ?", uint(tid))
+		}
+	}
+
+	if status := c.Query("status"); status != "" {
+		query = query.Where("resources.status = ?", status)
+	}
+
+	if environment := c.Query("environment"); environment != "" {
+		query = query.Where("resources.environment = ?", environment)
+	}
+
+	if resourceTypeID := c.Query("resource_type_id"); resourceTypeID != "" {
+		if rtid, err := strconv.ParseUint(resourceTypeID, 10, 32); err == nil {
+			query = query.Where("resources.resource_type_id = ?", uint(rtid))
+		}
+	}
+	return query
+}
+
+// CreateResource creates a new resource. A full lifecycle resource is
+// provisioned by the K8s controller as a create operation; with Prefer:
+// respond-async the operation is returned with 202 instead of the resource.
 // POST /api/v1/resources
 func (rc *ResourceController) CreateResource(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, ErrorResponse{
-			Error:   "unauthorized",
-			Message: "User context not found",
-		})
+		apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "User context not found")
 		return
 	}
 
@@ -139,100 +138,178 @@ func (rc *ResourceController) CreateResource(c *gin.Context) {
 
 	// Check authorization - must be TeamMaintainer or higher
 	if !hasMinimumRole(userRole, "admin") && !hasMinimumRole(teamRole, "maintainer") {
-		c.JSON(http.StatusForbidden, ErrorResponse{
-			Error:   "forbidden",
-			Message: "Insufficient permissions to create resources",
-		})
+		apierrors.Abort(c, http.StatusForbidden, apierrors.CodeForbidden, "Insufficient permissions to create resources")
 		return
 	}
 
 	var req CreateResourceRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{
-			Error:   "invalid_request",
-			Message: "Invalid request body",
-			Details: err.Error(),
-		})
+		apierrors.AbortWithDetails(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Invalid request body", err.Error())
 		return
 	}
 
 	// Validate lifecycle_mode
 	validModes := map[string]bool{"full": true, "partial": true, "monitor_only": true}
 	if !validModes[req.LifecycleMode] {
-		c.JSON(http.StatusBadRequest, ErrorResponse{
-			Error:   "invalid_lifecycle_mode",
-			Message: "lifecycle_mode must be one of: full, partial, monitor_only",
-		})
+		apierrors.Abort(c, http.StatusBadRequest, "invalid_lifecycle_mode", "lifecycle_mode must be one of: full, partial, monitor_only")
+		return
+	}
+
+	if err := validatePriorityClass(req.PriorityClass); err != nil {
+		apierrors.Abort(c, http.StatusBadRequest, "invalid_priority_class", err.Error())
 		return
 	}
 
+	// Resources on hosts outside Kubernetes are managed by a nest-agent,
+	// which can't provision them
+	if req.AgentID != nil {
+		if req.LifecycleMode == "full" {
+			apierrors.Abort(c, http.StatusBadRequest, "invalid_lifecycle_mode", "Resources managed by an agent must be partial or monitor_only")
+			return
+		}
+		var agent Agent
+		if err := tenantDB(c, rc.db).First(&agent, *req.AgentID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				apierrors.Abort(c, http.StatusNotFound, "agent_not_found", "Agent not found")
+			} else {
+				apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to verify agent")
+			}
+			return
+		}
+		if req.ProvisioningMethod == "" {
+			req.ProvisioningMethod = "agent"
+		}
+	}
+
+	// Resources on a Docker host are provisioned there by the K8s controller
+	if req.DockerHostID != nil {
+		if req.LifecycleMode != "full" {
+			apierrors.Abort(c, http.StatusBadRequest, "invalid_lifecycle_mode", "Resources on a Docker host must be full")
+			return
+		}
+		if req.AgentID != nil {
+			apierrors.Abort(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "A resource can't have both an agent and a Docker host")
+			return
+		}
+		var host DockerHost
+		if err := tenantDB(c, rc.db).First(&host, *req.DockerHostID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				apierrors.Abort(c, http.StatusNotFound, "docker_host_not_found", "Docker host not found")
+			} else {
+				apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to verify Docker host")
+			}
+			return
+		}
+		req.ProvisioningMethod = "docker"
+	}
+
 	// Verify team exists and user has access
 	var team Team
-	if err := rc.db.Where("id = ? AND deleted_at IS NULL", req.TeamID).First(&team).Error; err != nil {
+	if err := tenantDB(c, rc.db).Where("id = ? AND deleted_at IS NULL", req.TeamID).First(&team).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusNotFound, ErrorResponse{
-				Error:   "team_not_found",
-				Message: "Team not found",
-			})
+			apierrors.Abort(c, http.StatusNotFound, "team_not_found", "Team not found")
 		} else {
-			c.JSON(http.StatusInternalServerError, ErrorResponse{
-				Error:   "database_error",
-				Message: "Failed to verify team",
-			})
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to verify team")
 		}
 		return
 	}
 
 	// Verify user has access to team
-	var teamMember TeamMember
-	if err := rc.db.Where("team_id = ? AND user_id = ?", req.TeamID, userID.(uint)).
-		First(&teamMember).Error; err != nil {
-		c.JSON(http.StatusForbidden, ErrorResponse{
-			Error:   "forbidden",
-			Message: "You do not have access to this team",
-		})
+	if _, isMember, err := rc.access.TeamRole(c.Request.Context(), userID.(uint), req.TeamID); err != nil || !isMember {
+		apierrors.Abort(c, http.StatusForbidden, apierrors.CodeForbidden, "You do not have access to this team")
 		return
 	}
 
 	// Verify resource type exists
-	var resourceType ResourceType
-	if err := rc.db.Where("id = ? AND deleted_at IS NULL", req.ResourceTypeID).
-		First(&resourceType).Error; err != nil {
+	resourceType, err := rc.access.ResourceType(c.Request.Context(), req.ResourceTypeID)
+	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusNotFound, ErrorResponse{
-				Error:   "resource_type_not_found",
-				Message: "Resource type not found",
-			})
+			apierrors.Abort(c, http.StatusNotFound, "resource_type_not_found", "Resource type not found")
 		} else {
-			c.JSON(http.StatusInternalServerError, ErrorResponse{
-				Error:   "database_error",
-				Message: "Failed to verify resource type",
-			})
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to verify resource type")
 		}
 		return
 	}
 
-	// Check unique constraint - name must be unique within team
-	var existing Resource
-	if err := rc.db.Where("team_id = ? AND name = ? AND deleted_at IS NULL",
-		req.TeamID, req.Name).First(&existing).Error; err == nil {
-		c.JSON(http.StatusConflict, ErrorResponse{
-			Error:   "resource_exists",
-			Message: "A resource with this name already exists in this team",
-		})
+	// Resolve the environment, defaulting to the first in the team's pipeline
+	envs, err := teamEnvironments(tenantDB(c, rc.db), req.TeamID)
+	if err != nil {
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to load environments")
 		return
-	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
-			Error:   "database_error",
-			Message: "Failed to check existing resources",
-		})
+	}
+	envIdx := 0
+	if req.Environment != "" {
+		envIdx = findEnvironment(envs, req.Environment)
+	}
+	if envIdx < 0 {
+		apierrors.Abort(c, http.StatusBadRequest, "invalid_environment", "Environment is not part of the team's pipeline")
+		return
+	}
+	env := &envs[envIdx]
+
+	if err := validateResourcePayload(resourceType.Name, req.ConnectionInfo, req.Credentials, req.Config); err != nil {
+		apierrors.Abort(c, http.StatusBadRequest, "invalid_payload", err.Error())
 		return
 	}
+	if err := validateConfigInjections(tenantDB(c, rc.db), req.Config); err != nil {
+		apierrors.Abort(c, http.StatusBadRequest, "invalid_container", err.Error())
+		return
+	}
+	var credentialsChangedAt *time.Time
+	if password, _ := req.Credentials["password"].(string); password != "" {
+		violations, err := rc.passwords.Check(c.Request.Context(), password)
+		if err != nil {
+			log.Printf("Error checking password policy: %v", err)
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to retrieve password policy")
+			return
+		}
+		if len(violations) > 0 {
+			apierrors.AbortWithDetails(c, http.StatusBadRequest, "weak_password", "The password does not meet the password policy", violations)
+			return
+		}
+		now := time.Now().UTC()
+		credentialsChangedAt = &now
+	}
+	if err := validateTuning(resourceType.Name, req.Config); err != nil {
+		apierrors.Abort(c, http.StatusBadRequest, "invalid_tuning", err.Error())
+		return
+	}
+	if err := validateConfigResources(req.Config); err != nil {
+		apierrors.Abort(c, http.StatusBadRequest, "invalid_size_class", err.Error())
+		return
+	}
+	if err := validateConfigMaintenanceWindow(req.Config); err != nil {
+		apierrors.Abort(c, http.StatusBadRequest, "invalid_maintenance_window", err.Error())
+		return
+	}
+
+	// Size classes set the requests and merge their tuning under the
+	// caller's; the custom class takes the requests from Config.resources
+	switch req.SizeClass {
+	case "":
+	case SizeClassCustom:
+		if requests, _ := configResources(req.Config); requests == nil {
+			apierrors.Abort(c, http.StatusBadRequest, "invalid_size_class", "The custom size class requires config.resources")
+			return
+		}
+	default:
+		classes, err := resourceSizeClasses(tenantDB(c, rc.db), resourceType)
+		if err != nil {
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to load size classes")
+			return
+		}
+		class := findSizeClass(classes, req.SizeClass)
+		if class == nil {
+			apierrors.Abort(c, http.StatusBadRequest, "invalid_size_class", "Unknown size class for this resource type: "+req.SizeClass)
+			return
+		}
+		req.Config = applySizeClass(req.Config, nil, class)
+	}
 
 	// Marshal connection info and config to JSON
 	connInfo, _ := json.Marshal(req.ConnectionInfo)
 	creds, _ := json.Marshal(req.Credentials)
-	cfg, _ := json.Marshal(req.Config)
+	cfg, _ := json.Marshal(applyEnvironmentDefaults(req.Config, env))
 
 	// Set capabilities
 	canBackup := false
@@ -246,49 +323,116 @@ func (rc *ResourceController) CreateResource(c *gin.Context) {
 		canScale = req.Capabilities["can_scale"]
 	}
 
+	// The K8s controller holds a finalizer on the resources it manages until
+	// their Kubernetes objects are removed
+	var finalizers datatypes.JSON
+	if req.LifecycleMode == "full" {
+		finalizers, _ = json.Marshal([]string{ControllerFinalizer})
+	}
+
 	// Create resource
 	resource := &Resource{
-		Name:               req.Name,
-		ResourceTypeID:     req.ResourceTypeID,
-		TeamID:             req.TeamID,
-		Status:             "pending",
-		LifecycleMode:      req.LifecycleMode,
-		ProvisioningMethod: req.ProvisioningMethod,
-		ConnectionInfo:     datatypes.JSON(connInfo),
-		Credentials:        datatypes.JSON(creds),
-		Config:             datatypes.JSON(cfg),
-		TLSEnabled:         req.TLSEnabled,
-		CanBackup:          canBackup,
-		CanModifyConfig:    canModifyConfig,
-		CanModifyUsers:     canModifyUsers,
-		CanScale:           canScale,
-		CreatedBy:          userID.(uint),
-	}
-
-	if err := rc.db.Create(resource).Error; err != nil {
-		log.Printf("Error creating resource: %v", err)
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
-			Error:   "database_error",
-			Message: "Failed to create resource",
-		})
+		Name:                 req.Name,
+		ResourceTypeID:       req.ResourceTypeID,
+		TeamID:               req.TeamID,
+		Environment:          env.Name,
+		Status:               "pending",
+		LifecycleMode:        req.LifecycleMode,
+		ProvisioningMethod:   req.ProvisioningMethod,
+		ConnectionInfo:       datatypes.JSON(connInfo),
+		Credentials:          datatypes.JSON(creds),
+		Config:               datatypes.JSON(cfg),
+		TLSEnabled:           req.TLSEnabled,
+		CanBackup:            canBackup,
+		CanModifyConfig:      canModifyConfig,
+		CanModifyUsers:       canModifyUsers,
+		CanScale:             canScale,
+		CreatedBy:            userID.(uint),
+		DeletionProtection:   req.DeletionProtection,
+		CredentialsChangedAt: credentialsChangedAt,
+		Finalizers:           finalizers,
+		SizeClass:            req.SizeClass,
+		PriorityClass:        req.PriorityClass,
+		AgentID:              req.AgentID,
+		DockerHostID:         req.DockerHostID,
+	}
+	if apiErr := rc.admit(c, PolicyOperationCreate, resource, nil); apiErr != nil {
+		apierrors.AbortWith(c, apiErr)
+		return
+	}
+
+	// Name must be unique within the team environment
+	var op *Operation
+	if !unitOfWork(c, rc.db, "Failed to create resource", func(tx *gorm.DB) error {
+		if err := checkResourceName(tx, resource.TeamID, resource.Environment, resource.Name, 0); err != nil {
+			return err
+		}
+		if err := tx.Create(resource).Error; err != nil {
+			return err
+		}
+		if resource.LifecycleMode != "full" {
+			return nil
+		}
+		var err error
+		if op, err = startOperation(tx, OperationCreate, resource, userID.(uint)); err != nil {
+			return err
+		}
+		_, err = queueReconcile(tx, resource.ID, userID.(uint))
+		return err
+	}) {
 		return
 	}
 
-	// Preload associations for response
-	rc.db.Preload("ResourceType").Preload("Team").First(resource)
+	// Preload associations for response. The row was just written, so read
+	// it back from the primary rather than a possibly lagging replica.
+	primary := database.UsePrimary(tenantDB(c, rc.db))
+	primary.Preload("ResourceType").Preload("Team").First(resource)
 
+	// Provision monitoring dashboards for the new resource
+	go func(id uint) {
+		if err := NewGrafanaProvisioner(primary).ProvisionResource(context.Background(), id); err != nil {
+			log.Printf("Grafana provisioning failed for resource %d: %v", id, err)
+		}
+	}(resource.ID)
+
+	if acceptOperation(c, op) {
+		return
+	}
 	c.JSON(http.StatusCreated, resourceToResponse(resource))
 }
 
+// resourceNameLock names the lock held while a resource name is checked and
+// taken in a team environment. The K8s controller takes the same lock when a
+// claim creates a resource.
+func resourceNameLock(teamID uint, environment, name string) string {
+	return locks.Key("resource-name", teamID, environment, name)
+}
+
+// checkResourceName returns a conflict when a live resource other than
+// exclude has the name in the team environment. The name is locked
+// until tx ends, so replicas of the API and the controller creating or
+// renaming resources to the same name can't both pass the check.
+func checkResourceName(tx *gorm.DB, teamID uint, environment, name string, exclude uint) error {
+	if err := locks.Lock(tx, resourceNameLock(teamID, environment, name)); err != nil {
+		return err
+	}
+	var existing int64
+	if err := tx.Model(&Resource{}).Where("team_id = ? AND environment = ? AND name = ? AND id != ? AND deleted_at IS NULL",
+		teamID, environment, name, exclude).Count(&existing).Error; err != nil {
+		return err
+	}
+	if existing > 0 {
+		return apierrors.New(http.StatusConflict, "resource_exists", "A resource with this name already exists in this team environment")
+	}
+	return nil
+}
+
 // GetResource retrieves a single resource by ID
 // GET /api/v1/resources/:id
 func (rc *ResourceController) GetResource(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, ErrorResponse{
-			Error:   "unauthorized",
-			Message: "User context not found",
-		})
+		apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "User context not found")
 		return
 	}
 
@@ -296,23 +440,16 @@ func (rc *ResourceController) GetResource(c *gin.Context) {
 
 	var resource Resource
 	// Verify user has access to this resource's team
-	query := rc.db.Where("resources.id = ? AND resources.deleted_at IS NULL", resourceID).
+	query := tenantDB(c, rc.db).Where("resources.id = ? AND resources.deleted_at IS NULL", resourceID).
 		Joins("INNER JOIN team_members ON resources.team_id = team_members.team_id").
-		Where("team_members.user_id = ?", userID.(uint)).
-		Preload("ResourceType").
-		Preload("Team")
+		Where("team_members.user_id = ?", userID.(uint))
+	query = fields.Preload(c, query, "ResourceType", "Team")
 
 	if err := query.First(&resource).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusNotFound, ErrorResponse{
-				Error:   "resource_not_found",
-				Message: "Resource not found or you do not have access",
-			})
+			apierrors.Abort(c, http.StatusNotFound, "resource_not_found", "Resource not found or you do not have access")
 		} else {
-			c.JSON(http.StatusInternalServerError, ErrorResponse{
-				Error:   "database_error",
-				Message: "Failed to retrieve resource",
-			})
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to retrieve resource")
 		}
 		return
 	}
@@ -325,10 +462,7 @@ func (rc *ResourceController) GetResource(c *gin.Context) {
 func (rc *ResourceController) UpdateResource(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, ErrorResponse{
-			Error:   "unauthorized",
-			Message: "User context not found",
-		})
+		apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "User context not found")
 		return
 	}
 
@@ -337,10 +471,7 @@ func (rc *ResourceController) UpdateResource(c *gin.Context) {
 
 	// Check authorization - must be TeamMaintainer or higher
 	if !hasMinimumRole(userRole, "admin") && !hasMinimumRole(teamRole, "maintainer") {
-		c.JSON(http.StatusForbidden, ErrorResponse{
-			Error:   "forbidden",
-			Message: "Insufficient permissions to update resources",
-		})
+		apierrors.Abort(c, http.StatusForbidden, apierrors.CodeForbidden, "Insufficient permissions to update resources")
 		return
 	}
 
@@ -348,88 +479,107 @@ func (rc *ResourceController) UpdateResource(c *gin.Context) {
 
 	var resource Resource
 	// Verify user has access
-	if err := rc.db.Where("id = ? AND deleted_at IS NULL", resourceID).
+	if err := tenantDB(c, rc.db).Where("id = ? AND deleted_at IS NULL", resourceID).
 		Joins("INNER JOIN team_members ON resources.team_id = team_members.team_id").
 		Where("team_members.user_id = ?", userID.(uint)).
 		Preload("ResourceType").
 		Preload("Team").
 		First(&resource).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusNotFound, ErrorResponse{
-				Error:   "resource_not_found",
-				Message: "Resource not found or you do not have access",
-			})
+			apierrors.Abort(c, http.StatusNotFound, "resource_not_found", "Resource not found or you do not have access")
 		} else {
-			c.JSON(http.StatusInternalServerError, ErrorResponse{
-				Error:   "database_error",
-				Message: "Failed to retrieve resource",
-			})
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to retrieve resource")
 		}
 		return
 	}
 
+	before := resource
+
 	var req UpdateResourceRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{
-			Error:   "invalid_request",
-			Message: "Invalid request body",
-			Details: err.Error(),
-		})
+		apierrors.AbortWithDetails(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Invalid request body", err.Error())
 		return
 	}
 
 	// Apply updates
 	if req.Name != nil {
-		// Check uniqueness in team
-		var existing Resource
-		if err := rc.db.Where("team_id = ? AND name = ? AND id != ? AND deleted_at IS NULL",
-			resource.TeamID, *req.Name, resource.ID).First(&existing).Error; err == nil {
-			c.JSON(http.StatusConflict, ErrorResponse{
-				Error:   "resource_exists",
-				Message: "A resource with this name already exists in this team",
-			})
-			return
-		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusInternalServerError, ErrorResponse{
-				Error:   "database_error",
-				Message: "Failed to check existing resources",
-			})
-			return
-		}
 		resource.Name = *req.Name
 	}
 
-	if req.Status != nil {
-		validStatuses := map[string]bool{
-			"pending": true, "provisioning": true, "active": true,
-			"updating": true, "paused": true, "error": true, "deleted": true,
+	var restartRequired []string
+	if req.Config != nil {
+		typeName := ""
+		if resource.ResourceType != nil {
+			typeName = resource.ResourceType.Name
 		}
-		if !validStatuses[*req.Status] {
-			c.JSON(http.StatusBadRequest, ErrorResponse{
-				Error:   "invalid_status",
-				Message: "Invalid status value",
-			})
+		if err := validateResourcePayload(typeName, nil, nil, req.Config); err != nil {
+			apierrors.Abort(c, http.StatusBadRequest, "invalid_payload", err.Error())
 			return
 		}
-		resource.Status = *req.Status
-	}
+		if err := validateConfigInjections(tenantDB(c, rc.db), req.Config); err != nil {
+			apierrors.Abort(c, http.StatusBadRequest, "invalid_container", err.Error())
+			return
+		}
+		if err := validateTuning(typeName, req.Config); err != nil {
+			apierrors.Abort(c, http.StatusBadRequest, "invalid_tuning", err.Error())
+			return
+		}
+		if err := validateConfigResources(req.Config); err != nil {
+			apierrors.Abort(c, http.StatusBadRequest, "invalid_size_class", err.Error())
+			return
+		}
+		if err := validateConfigMaintenanceWindow(req.Config); err != nil {
+			apierrors.Abort(c, http.StatusBadRequest, "invalid_maintenance_window", err.Error())
+			return
+		}
+		restartRequired = tuningRestartChanges(typeName, resourceConfig(&resource), req.Config)
 
-	if req.Config != nil {
 		cfg, _ := json.Marshal(req.Config)
 		resource.Config = datatypes.JSON(cfg)
 	}
 
-	// Save updates
-	if err := rc.db.Save(&resource).Error; err != nil {
-		log.Printf("Error updating resource: %v", err)
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
-			Error:   "database_error",
-			Message: "Failed to update resource",
-		})
+	if req.DeletionProtection != nil {
+		resource.DeletionProtection = *req.DeletionProtection
+	}
+	if req.PriorityClass != nil {
+		if err := validatePriorityClass(*req.PriorityClass); err != nil {
+			apierrors.Abort(c, http.StatusBadRequest, "invalid_priority_class", err.Error())
+			return
+		}
+		resource.PriorityClass = *req.PriorityClass
+	}
+	if apiErr := rc.admit(c, PolicyOperationUpdate, &resource, &before); apiErr != nil {
+		apierrors.AbortWith(c, apiErr)
 		return
 	}
 
-	c.JSON(http.StatusOK, resourceToResponse(&resource))
+	// Save the spec, checking a new name is unique in the team environment.
+	// Status is left to the K8s controller, which may have updated it since
+	// the resource was loaded.
+	if !unitOfWork(c, rc.db, "Failed to update resource", func(tx *gorm.DB) error {
+		if resource.Name != before.Name {
+			if err := checkResourceName(tx, resource.TeamID, resource.Environment, resource.Name, resource.ID); err != nil {
+				return err
+			}
+		}
+		if !specChanged(&before, &resource) {
+			return nil
+		}
+		if err := tx.Model(&resource).Select(resourceSpecColumns).Updates(&resource).Error; err != nil {
+			return err
+		}
+		if err := bumpGeneration(tx, &resource); err != nil {
+			return err
+		}
+		return audit.Record(c, tx, userID.(uint), "resources", resource.ID, &resource.TeamID, &before, &resource)
+	}) {
+		return
+	}
+
+	// Restart-only tuning changes roll the resource's pods
+	resp := resourceToResponse(&resource)
+	resp.RestartRequired = restartRequired
+	c.JSON(http.StatusOK, resp)
 }
 
 // DeleteResource soft-deletes a resource
@@ -437,10 +587,7 @@ func (rc *ResourceController) UpdateResource(c *gin.Context) {
 func (rc *ResourceController) DeleteResource(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, ErrorResponse{
-			Error:   "unauthorized",
-			Message: "User context not found",
-		})
+		apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "User context not found")
 		return
 	}
 
@@ -449,10 +596,7 @@ func (rc *ResourceController) DeleteResource(c *gin.Context) {
 
 	// Check authorization - must be TeamAdmin or GlobalAdmin
 	if !hasMinimumRole(userRole, "admin") && !hasMinimumRole(teamRole, "admin") {
-		c.JSON(http.StatusForbidden, ErrorResponse{
-			Error:   "forbidden",
-			Message: "Insufficient permissions to delete resources",
-		})
+		apierrors.Abort(c, http.StatusForbidden, apierrors.CodeForbidden, "Insufficient permissions to delete resources")
 		return
 	}
 
@@ -460,46 +604,218 @@ func (rc *ResourceController) DeleteResource(c *gin.Context) {
 
 	var resource Resource
 	// Verify user has access
-	if err := rc.db.Where("id = ? AND deleted_at IS NULL", resourceID).
+	if err := tenantDB(c, rc.db).Where("id = ? AND deleted_at IS NULL", resourceID).
 		Joins("INNER JOIN team_members ON resources.team_id = team_members.team_id").
 		Where("team_members.user_id = ?", userID.(uint)).
 		First(&resource).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusNotFound, ErrorResponse{
-				Error:   "resource_not_found",
-				Message: "Resource not found or you do not have access",
-			})
+			apierrors.Abort(c, http.StatusNotFound, "resource_not_found", "Resource not found or you do not have access")
 		} else {
-			c.JSON(http.StatusInternalServerError, ErrorResponse{
-				Error:   "database_error",
-				Message: "Failed to retrieve resource",
-			})
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to retrieve resource")
 		}
 		return
 	}
 
+	if resource.DeletionProtection {
+		apierrors.Abort(c, http.StatusConflict, "deletion_protected", "Resource has deletion protection enabled; disable it before deleting")
+		return
+	}
+
+	// A claim would recreate its resource, so the claim is deleted instead
+	var claims int64
+	if err := tenantDB(c, rc.db).Model(&ResourceClaim{}).Where("resource_id = ?", resource.ID).Count(&claims).Error; err != nil {
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to check resource claims")
+		return
+	}
+	if claims > 0 {
+		apierrors.Abort(c, http.StatusConflict, "claim_managed", "Resource was created by a claim; delete the claim instead")
+		return
+	}
+
+	// A resource can't be deleted while an operation is changing it
+	lock, err := activeResourceLock(tenantDB(c, rc.db), resource.ID)
+	if err != nil {
+		log.Printf("Error checking resource lock: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to check resource lock")
+		return
+	}
+	if lock != nil {
+		apierrors.AbortWith(c, resourceLockedError(lock))
+		return
+	}
+
 	// Soft delete
-	if err := rc.db.Delete(&resource).Error; err != nil {
+	if err := tenantDB(c, rc.db).Delete(&resource).Error; err != nil {
 		log.Printf("Error deleting resource: %v", err)
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
-			Error:   "database_error",
-			Message: "Failed to delete resource",
-		})
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to delete resource")
 		return
 	}
 
 	c.JSON(http.StatusNoContent, nil)
 }
 
+// ListTrash retrieves soft-deleted resources that can still be restored
+// GET /api/v1/resources/trash
+func (rc *ResourceController) ListTrash(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "User context not found")
+		return
+	}
+
+	cutoff := time.Now().UTC().Add(-rc.trashRetention)
+
+	var resources []*Resource
+	if err := fields.Preload(c, tenantDB(c, rc.db).Unscoped(), "ResourceType", "Team").
+		Joins("INNER JOIN team_members ON resources.team_id = team_members.team_id").
+		Where("team_members.user_id = ?", userID.(uint)).
+		Where("resources.deleted_at IS NOT NULL AND resources.deleted_at > ?", cutoff).
+		Where("resources.deletion_state = ''").
+		Order("resources.deleted_at DESC").
+		Find(&resources).Error; err != nil {
+		log.Printf("Error listing deleted resources: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to list deleted resources")
+		return
+	}
+
+	response := TrashListResponse{
+		Resources:       make([]*TrashedResourceResponse, 0, len(resources)),
+		RetentionPeriod: rc.trashRetention.String(),
+	}
+	for _, r := range resources {
+		response.Resources = append(response.Resources, &TrashedResourceResponse{
+			ResourceResponse: resourceToResponse(r),
+			PurgeAt:          r.DeletedAt.Time.Add(rc.trashRetention),
+		})
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// RestoreDeletedResource restores a soft-deleted resource within the trash
+// retention window
+// POST /api/v1/resources/:id/restore-deleted
+func (rc *ResourceController) RestoreDeletedResource(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "User context not found")
+		return
+	}
+
+	userRole, _ := c.Get("user_role")
+	teamRole, _ := c.Get("team_role")
+
+	// Restoring requires the same rights as deleting
+	if !hasMinimumRole(userRole, "admin") && !hasMinimumRole(teamRole, "admin") {
+		apierrors.Abort(c, http.StatusForbidden, apierrors.CodeForbidden, "Insufficient permissions to restore resources")
+		return
+	}
+
+	var resource Resource
+	if err := tenantDB(c, rc.db).Unscoped().
+		Joins("INNER JOIN team_members ON resources.team_id = team_members.team_id").
+		Where("team_members.user_id = ?", userID.(uint)).
+		Where("resources.id = ? AND resources.deleted_at IS NOT NULL", c.Param("id")).
+		First(&resource).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierrors.Abort(c, http.StatusNotFound, "resource_not_found", "Deleted resource not found or you do not have access")
+		} else {
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to retrieve resource")
+		}
+		return
+	}
+
+	if resource.DeletionState != "" ||
+		resource.DeletedAt.Time.Before(time.Now().UTC().Add(-rc.trashRetention)) {
+		apierrors.Abort(c, http.StatusGone, "restore_window_expired", "The resource is past its restore window and is being purged")
+		return
+	}
+
+	// A full lifecycle resource is reconciled again as a restore operation
+	var op *Operation
+	if !unitOfWork(c, rc.db, "Failed to restore resource", func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Model(&resource).Update("deleted_at", nil).Error; err != nil {
+			return err
+		}
+		if resource.LifecycleMode != "full" {
+			return nil
+		}
+		var err error
+		if op, err = startOperation(tx, OperationRestore, &resource, userID.(uint)); err != nil {
+			return err
+		}
+		_, err = queueReconcile(tx, resource.ID, userID.(uint))
+		return err
+	}) {
+		return
+	}
+
+	database.UsePrimary(tenantDB(c, rc.db)).Preload("ResourceType").Preload("Team").First(&resource, resource.ID)
+
+	if acceptOperation(c, op) {
+		return
+	}
+	c.JSON(http.StatusOK, resourceToResponse(&resource))
+}
+
+// GetDeletionProgress reports where a deleted resource is in the deletion
+// lifecycle, including the finalizers it is waiting on
+// GET /api/v1/resources/:id/deletion
+func (rc *ResourceController) GetDeletionProgress(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "User context not found")
+		return
+	}
+
+	var resource Resource
+	if err := tenantDB(c, rc.db).Unscoped().
+		Joins("INNER JOIN team_members ON resources.team_id = team_members.team_id").
+		Where("team_members.user_id = ?", userID.(uint)).
+		Where("resources.id = ?", c.Param("id")).
+		First(&resource).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierrors.Abort(c, http.StatusNotFound, "resource_not_found", "Resource not found or you do not have access")
+		} else {
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to retrieve resource")
+		}
+		return
+	}
+
+	finalizers, err := resourceFinalizers(&resource)
+	if err != nil {
+		log.Printf("Error reading deletion progress: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeInternal, "Failed to read resource finalizers")
+		return
+	}
+	progress := DeletionProgressResponse{
+		ResourceID:         resource.ID,
+		State:              resource.DeletionState,
+		DeletionProtection: resource.DeletionProtection,
+		Finalizers:         finalizers,
+	}
+	if resource.DeletedAt.Valid {
+		deletedAt := resource.DeletedAt.Time
+		progress.DeletedAt = &deletedAt
+		if resource.DeletionState == "" {
+			purgeAt := deletedAt.Add(rc.trashRetention)
+			progress.PurgeAt = &purgeAt
+			progress.Restorable = time.Now().UTC().Before(purgeAt)
+			progress.State = "trashed"
+		}
+	} else {
+		progress.State = "active"
+	}
+
+	c.JSON(http.StatusOK, progress)
+}
+
 // GetResourceStats retrieves statistics for a resource
 // GET /api/v1/resources/:id/stats
 func (rc *ResourceController) GetResourceStats(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, ErrorResponse{
-			Error:   "unauthorized",
-			Message: "User context not found",
-		})
+		apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "User context not found")
 		return
 	}
 
@@ -507,47 +823,40 @@ func (rc *ResourceController) GetResourceStats(c *gin.Context) {
 
 	// Verify user has access to resource
 	var resource Resource
-	if err := rc.db.Where("id = ? AND deleted_at IS NULL", resourceID).
+	if err := tenantDB(c, rc.db).Where("id = ? AND deleted_at IS NULL", resourceID).
 		Joins("INNER JOIN team_members ON resources.team_id = team_members.team_id").
 		Where("team_members.user_id = ?", userID.(uint)).
 		First(&resource).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusNotFound, ErrorResponse{
-				Error:   "resource_not_found",
-				Message: "Resource not found or you do not have access",
-			})
+			apierrors.Abort(c, http.StatusNotFound, "resource_not_found", "Resource not found or you do not have access")
 		} else {
-			c.JSON(http.StatusInternalServerError, ErrorResponse{
-				Error:   "database_error",
-				Message: "Failed to retrieve resource",
-			})
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to retrieve resource")
 		}
 		return
 	}
 
-	// Get latest stats
+	since, until, ok := statsTimeRange(c, defaultStatsLookback)
+	if !ok {
+		return
+	}
+
+	// Get latest stats. The time bounds let Postgres prune partitions.
 	var stats ResourceStats
-	if err := rc.db.Where("resource_id = ?", resourceID).
+	if err := tenantDB(c, rc.db).Where("resource_id = ? AND timestamp >= ? AND timestamp <= ?", resourceID, since, until).
 		Order("timestamp DESC").
 		First(&stats).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusNotFound, ErrorResponse{
-				Error:   "stats_not_found",
-				Message: "No statistics available for this resource",
-			})
+			apierrors.Abort(c, http.StatusNotFound, "stats_not_found", "No statistics available for this resource")
 		} else {
-			c.JSON(http.StatusInternalServerError, ErrorResponse{
-				Error:   "database_error",
-				Message: "Failed to retrieve statistics",
-			})
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to retrieve statistics")
 		}
 		return
 	}
 
 	// Parse JSON fields
 	var metrics, riskFactors map[string]interface{}
-	json.Unmarshal(stats.Metrics, &metrics)
-	json.Unmarshal(stats.RiskFactors, &riskFactors)
+	decodeJSONField(stats.Metrics, &metrics, "stats metrics")
+	decodeJSONField(stats.RiskFactors, &riskFactors, "stats risk factors")
 
 	c.JSON(http.StatusOK, ResourceStatsResponse{
 		ResourceID:  stats.ResourceID,
@@ -558,15 +867,142 @@ func (rc *ResourceController) GetResourceStats(c *gin.Context) {
 	})
 }
 
+// GetResourceStatsHistory retrieves statistics for a resource over a time range
+// GET /api/v1/resources/:id/stats/history?since=&until=&limit=
+func (rc *ResourceController) GetResourceStatsHistory(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "User context not found")
+		return
+	}
+
+	resourceID := c.Param("id")
+
+	// Verify user has access to resource
+	var resource Resource
+	if err := tenantDB(c, rc.db).Where("id = ? AND deleted_at IS NULL", resourceID).
+		Joins("INNER JOIN team_members ON resources.team_id = team_members.team_id").
+		Where("team_members.user_id = ?", userID.(uint)).
+		First(&resource).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierrors.Abort(c, http.StatusNotFound, "resource_not_found", "Resource not found or you do not have access")
+		} else {
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to retrieve resource")
+		}
+		return
+	}
+
+	since, until, ok := statsTimeRange(c, 24*time.Hour)
+	if !ok {
+		return
+	}
+
+	limit := 1000
+	if l := c.Query("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 10000 {
+			limit = parsed
+		}
+	}
+
+	var samples []*ResourceStats
+	if err := tenantDB(c, rc.db).Where("resource_id = ? AND timestamp >= ? AND timestamp <= ?", resource.ID, since, until).
+		Order("timestamp ASC").
+		Limit(limit).
+		Find(&samples).Error; err != nil {
+		log.Printf("Error retrieving stats history for resource %d: %v", resource.ID, err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to retrieve statistics")
+		return
+	}
+
+	response := ResourceStatsHistoryResponse{
+		ResourceID: resource.ID,
+		Since:      since,
+		Until:      until,
+		Stats:      make([]*ResourceStatsResponse, 0, len(samples)),
+	}
+	for _, stats := range samples {
+		var metrics, riskFactors map[string]interface{}
+		decodeJSONField(stats.Metrics, &metrics, "stats metrics")
+		decodeJSONField(stats.RiskFactors, &riskFactors, "stats risk factors")
+		response.Stats = append(response.Stats, &ResourceStatsResponse{
+			ResourceID:  stats.ResourceID,
+			Timestamp:   stats.Timestamp,
+			Metrics:     metrics,
+			RiskLevel:   stats.RiskLevel,
+			RiskFactors: riskFactors,
+		})
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// GetDatabaseInsights retrieves the latest engine-level insights for a resource
+// (top queries, connection counts, cache hit ratio, deadlocks)
+// GET /api/v1/resources/:id/insights
+func (rc *ResourceController) GetDatabaseInsights(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "User context not found")
+		return
+	}
+
+	resourceID := c.Param("id")
+
+	// Verify user has access to resource
+	var resource Resource
+	if err := tenantDB(c, rc.db).Where("id = ? AND deleted_at IS NULL", resourceID).
+		Joins("INNER JOIN team_members ON resources.team_id = team_members.team_id").
+		Where("team_members.user_id = ?", userID.(uint)).
+		First(&resource).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierrors.Abort(c, http.StatusNotFound, "resource_not_found", "Resource not found or you do not have access")
+		} else {
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to retrieve resource")
+		}
+		return
+	}
+
+	since, until, ok := statsTimeRange(c, defaultStatsLookback)
+	if !ok {
+		return
+	}
+
+	// Get the latest stats sample that carries database insights
+	var stats ResourceStats
+	if err := tenantDB(c, rc.db).Where("resource_id = ? AND timestamp >= ? AND timestamp <= ?", resource.ID, since, until).
+		Where("metrics -> 'database_insights' IS NOT NULL").
+		Order("timestamp DESC").
+		First(&stats).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierrors.Abort(c, http.StatusNotFound, "insights_not_found", "No database insights available for this resource")
+		} else {
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to retrieve database insights")
+		}
+		return
+	}
+
+	var metrics struct {
+		DatabaseInsights DatabaseInsightsResponse `json:"database_insights"`
+	}
+	if err := json.Unmarshal(stats.Metrics, &metrics); err != nil {
+		log.Printf("Error parsing database insights for resource %d: %v", resource.ID, err)
+		apierrors.Abort(c, http.StatusInternalServerError, "invalid_stats", "Stored database insights could not be parsed")
+		return
+	}
+
+	insights := metrics.DatabaseInsights
+	insights.ResourceID = stats.ResourceID
+	insights.Timestamp = stats.Timestamp
+
+	c.JSON(http.StatusOK, insights)
+}
+
 // GetConnectionInfo retrieves connection information for a resource
 // GET /api/v1/resources/:id/connection-info
 func (rc *ResourceController) GetConnectionInfo(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, ErrorResponse{
-			Error:   "unauthorized",
-			Message: "User context not found",
-		})
+		apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "User context not found")
 		return
 	}
 
@@ -577,27 +1013,21 @@ func (rc *ResourceController) GetConnectionInfo(c *gin.Context) {
 
 	var resource Resource
 	// Verify user has access to resource
-	if err := rc.db.Where("id = ? AND deleted_at IS NULL", resourceID).
+	if err := tenantDB(c, rc.db).Where("id = ? AND deleted_at IS NULL", resourceID).
 		Joins("INNER JOIN team_members ON resources.team_id = team_members.team_id").
 		Where("team_members.user_id = ?", userID.(uint)).
 		First(&resource).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusNotFound, ErrorResponse{
-				Error:   "resource_not_found",
-				Message: "Resource not found or you do not have access",
-			})
+			apierrors.Abort(c, http.StatusNotFound, "resource_not_found", "Resource not found or you do not have access")
 		} else {
-			c.JSON(http.StatusInternalServerError, ErrorResponse{
-				Error:   "database_error",
-				Message: "Failed to retrieve resource",
-			})
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to retrieve resource")
 		}
 		return
 	}
 
 	// Parse connection info
 	var connInfo map[string]interface{}
-	json.Unmarshal(resource.ConnectionInfo, &connInfo)
+	decodeJSONField(resource.ConnectionInfo, &connInfo, "connection info")
 
 	response := &ConnectionInfoResponse{
 		ConnectionInfo: connInfo,
@@ -608,9 +1038,16 @@ func (rc *ResourceController) GetConnectionInfo(c *gin.Context) {
 	// Only expose credentials to TeamMaintainer+ roles
 	if hasMinimumRole(userRole, "admin") || hasMinimumRole(teamRole, "maintainer") {
 		var creds map[string]interface{}
-		json.Unmarshal(resource.Credentials, &creds)
+		decodeJSONField(resource.Credentials, &creds, "credentials")
 		response.Credentials = creds
 		response.AccessLevel = "full"
+		if resource.CredentialsChangedAt != nil {
+			if policy, err := rc.passwords.Policy(c.Request.Context()); err != nil {
+				log.Printf("Error retrieving password policy: %v", err)
+			} else {
+				response.CredentialsExpireAt = policy.ExpiresAt(*resource.CredentialsChangedAt)
+			}
+		}
 	} else {
 		response.AccessLevel = "restricted"
 	}
@@ -623,27 +1060,52 @@ func (rc *ResourceController) GetConnectionInfo(c *gin.Context) {
 // resourceToResponse converts a Resource model to ResourceResponse DTO
 func resourceToResponse(r *Resource) *ResourceResponse {
 	var connInfo, cfg map[string]interface{}
-	json.Unmarshal(r.ConnectionInfo, &connInfo)
-	json.Unmarshal(r.Config, &cfg)
+	var findings []string
+	var violations []PolicyViolation
+	conditions := []ResourceCondition{}
+	decodeJSONField(r.ConnectionInfo, &connInfo, "connection info")
+	decodeJSONField(r.Config, &cfg, "config")
+	decodeJSONField(r.SecurityFindings, &findings, "security findings")
+	decodeJSONField(r.PolicyViolations, &violations, "policy violations")
+	decodeJSONField(r.Conditions, &conditions, "conditions")
 
 	resp := &ResourceResponse{
-		ID:                 r.ID,
-		Name:               r.Name,
-		ResourceTypeID:     r.ResourceTypeID,
-		TeamID:             r.TeamID,
-		Status:             r.Status,
-		LifecycleMode:      r.LifecycleMode,
-		ProvisioningMethod: r.ProvisioningMethod,
-		ConnectionInfo:     connInfo,
-		TLSEnabled:         r.TLSEnabled,
-		Config:             cfg,
-		CanModifyUsers:     r.CanModifyUsers,
-		CanModifyConfig:    r.CanModifyConfig,
-		CanBackup:          r.CanBackup,
-		CanScale:           r.CanScale,
-		CreatedBy:          r.CreatedBy,
-		CreatedAt:          r.CreatedAt,
-		UpdatedAt:          r.UpdatedAt,
+		ID:                  r.ID,
+		Name:                r.Name,
+		ResourceTypeID:      r.ResourceTypeID,
+		TeamID:              r.TeamID,
+		Environment:         r.Environment,
+		Status:              r.Status,
+		LifecycleMode:       r.LifecycleMode,
+		ProvisioningMethod:  r.ProvisioningMethod,
+		ConnectionInfo:      connInfo,
+		TLSEnabled:          r.TLSEnabled,
+		Config:              cfg,
+		CanModifyUsers:      r.CanModifyUsers,
+		CanModifyConfig:     r.CanModifyConfig,
+		CanBackup:           r.CanBackup,
+		CanScale:            r.CanScale,
+		CreatedBy:           r.CreatedBy,
+		LastError:           r.LastError,
+		LastErrorAt:         r.LastErrorAt,
+		ConsecutiveFailures: r.ConsecutiveFailures,
+		DeletionProtection:  r.DeletionProtection,
+		DeletionState:       r.DeletionState,
+		SecurityFindings:    findings,
+		PolicyViolations:    violations,
+		SizeClass:           r.SizeClass,
+		PriorityClass:       r.PriorityClass,
+		Generation:          r.Generation,
+		ObservedGeneration:  r.ObservedGeneration,
+		Conditions:          conditions,
+		PendingRestart:      r.PendingRestartSince != nil,
+		PendingRestartSince: r.PendingRestartSince,
+		AgentID:             r.AgentID,
+		DockerHostID:        r.DockerHostID,
+		CloudAccountID:      r.CloudAccountID,
+		CloudInstanceID:     r.CloudInstanceID,
+		CreatedAt:           r.CreatedAt,
+		UpdatedAt:           r.UpdatedAt,
 	}
 
 	if r.ResourceType != nil {
@@ -680,3 +1142,56 @@ func hasMinimumRole(role interface{}, minRequired string) bool {
 
 	return roleHierarchy[roleStr] >= roleHierarchy[minRequired]
 }
+
+// requireGlobalAdmin verifies the current user is a global admin, writing a
+// 401 or 403 response if not
+func requireGlobalAdmin(c *gin.Context) bool {
+	if _, exists := c.Get("user_id"); !exists {
+		apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "User context not found")
+		return false
+	}
+
+	userRole, _ := c.Get("user_role")
+	if !hasMinimumRole(userRole, "admin") {
+		apierrors.Abort(c, http.StatusForbidden, apierrors.CodeForbidden, "Global admin access required")
+		return false
+	}
+
+	return true
+}
+
+// defaultStatsLookback bounds "latest stats" queries so that only recent
+// monthly partitions of resource_stats are scanned
+const defaultStatsLookback = 7 * 24 * time.Hour
+
+// statsTimeRange parses the optional RFC3339 since and until query parameters,
+// defaulting to the lookback window ending now. It writes a 400 response and
+// returns false when the parameters are invalid.
+func statsTimeRange(c *gin.Context, lookback time.Duration) (time.Time, time.Time, bool) {
+	until := time.Now().UTC()
+	if v := c.Query("until"); v != "" {
+		parsed, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			apierrors.Abort(c, http.StatusBadRequest, "invalid_time_range", "until must be an RFC3339 timestamp")
+			return time.Time{}, time.Time{}, false
+		}
+		until = parsed
+	}
+
+	since := until.Add(-lookback)
+	if v := c.Query("since"); v != "" {
+		parsed, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			apierrors.Abort(c, http.StatusBadRequest, "invalid_time_range", "since must be an RFC3339 timestamp")
+			return time.Time{}, time.Time{}, false
+		}
+		since = parsed
+	}
+
+	if !since.Before(until) {
+		apierrors.Abort(c, http.StatusBadRequest, "invalid_time_range", "since must be before until")
+		return time.Time{}, time.Time{}, false
+	}
+
+	return since, until, true
+}
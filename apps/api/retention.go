@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/penguintechinc/project-template/shared/database"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// retentionTargets lists the tables retention policies can be applied to
+var retentionTargets = map[string]bool{
+	"audit_logs":     true,
+	"resource_stats": true,
+}
+
+// retentionBatchSize bounds the rows archived and deleted per statement
+const retentionBatchSize = 5000
+
+// Archive run statuses
+const (
+	ArchiveRunRunning   = "running"
+	ArchiveRunCompleted = "completed"
+	ArchiveRunFailed    = "failed"
+)
+
+// RetentionManager applies retention policies: rows older than the policy's
+// retention window are optionally archived as gzipped JSONL objects and then
+// deleted
+type RetentionManager struct {
+	db    *gorm.DB
+	store ObjectStore
+}
+
+// NewRetentionManager creates a retention manager. store may be nil, in
+// which case policies with archival enabled are skipped rather than deleting
+// unarchived data.
+func NewRetentionManager(db *gorm.DB, store ObjectStore) *RetentionManager {
+	return &RetentionManager{db: db, store: store}
+}
+
+// ApplyPolicies runs every enabled policy once. It runs as a periodic job,
+// so a single replica archives and deletes at a time.
+func (m *RetentionManager) ApplyPolicies(ctx context.Context) error {
+	var policies []RetentionPolicy
+	if err := m.db.WithContext(ctx).Where("enabled = ?", true).Find(&policies).Error; err != nil {
+		return fmt.Errorf("failed to load retention policies: %w", err)
+	}
+	for i := range policies {
+		run, err := m.StartRun(ctx, &policies[i], nil)
+		if err != nil {
+			log.Printf("Failed to start retention run for %s: %v", policies[i].Target, err)
+			continue
+		}
+		m.Execute(ctx, &policies[i], run)
+	}
+	return nil
+}
+
+// StartRun records a new archive run for a policy. A policy that already has
+// a run in progress is rejected.
+func (m *RetentionManager) StartRun(ctx context.Context, policy *RetentionPolicy, triggeredBy *uint) (*ArchiveRun, error) {
+	if policy.ArchiveEnabled && m.store == nil {
+		return nil, errors.New("archival is enabled but no object store is configured")
+	}
+
+	var running int64
+	m.db.WithContext(ctx).Model(&ArchiveRun{}).
+		Where("retention_policy_id = ? AND status = ?", policy.ID, ArchiveRunRunning).
+		Count(&running)
+	if running > 0 {
+		return nil, errors.New("a run is already in progress for this policy")
+	}
+
+	run := &ArchiveRun{
+		RetentionPolicyID: policy.ID,
+		Target:            policy.Target,
+		Status:            ArchiveRunRunning,
+		Cutoff:            time.Now().UTC().AddDate(0, 0, -policy.RetentionDays),
+		TriggeredBy:       triggeredBy,
+		StartedAt:         time.Now().UTC(),
+	}
+	if err := m.db.WithContext(ctx).Create(run).Error; err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+// Execute archives and prunes rows older than the run's cutoff, recording
+// the outcome on the run
+func (m *RetentionManager) Execute(ctx context.Context, policy *RetentionPolicy, run *ArchiveRun) {
+	var keys []string
+	err := m.prune(ctx, policy, run, &keys)
+
+	now := time.Now().UTC()
+	run.CompletedAt = &now
+	run.Status = ArchiveRunCompleted
+	if err != nil {
+		run.Status = ArchiveRunFailed
+		run.Error = err.Error()
+		log.Printf("Retention run %d for %s failed: %v", run.ID, policy.Target, err)
+	}
+	keysJSON, _ := json.Marshal(keys)
+	run.ObjectKeys = datatypes.JSON(keysJSON)
+
+	m.db.Save(run)
+	m.db.Model(policy).Update("last_run_at", now)
+}
+
+// prune processes expired rows in id order, one batch at a time, so that a
+// failed upload never deletes rows that were not archived
+func (m *RetentionManager) prune(ctx context.Context, policy *RetentionPolicy, run *ArchiveRun, keys *[]string) error {
+	if !retentionTargets[policy.Target] {
+		return fmt.Errorf("unsupported retention target %q", policy.Target)
+	}
+
+	// Without archival, whole expired partitions are dropped instead of
+	// deleting their rows; rows in the partially expired month are then
+	// deleted in batches below
+	if spec, ok := partitionSpecFor(policy.Target); ok && !policy.ArchiveEnabled {
+		dropped, err := database.DropPartitionsBefore(ctx, m.db, spec, run.Cutoff)
+		if err != nil {
+			return err
+		}
+		if len(dropped) > 0 {
+			log.Printf("Retention run %d dropped partitions: %v", run.ID, dropped)
+		}
+	}
+
+	for batch := 1; ; batch++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		var rows []map[string]interface{}
+		if err := m.db.WithContext(ctx).Table(policy.Target).
+			Where("timestamp < ?", run.Cutoff).
+			Order("id ASC").
+			Limit(retentionBatchSize).
+			Find(&rows).Error; err != nil {
+			return fmt.Errorf("failed to read expired rows: %w", err)
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+
+		ids := make([]interface{}, 0, len(rows))
+		for _, row := range rows {
+			ids = append(ids, row["id"])
+		}
+
+		if policy.ArchiveEnabled {
+			key := fmt.Sprintf("%s/%s/run-%d-batch-%04d.jsonl.gz",
+				policy.Target, run.Cutoff.Format("2006/01/02"), run.ID, batch)
+			payload, err := encodeJSONLGzip(rows)
+			if err != nil {
+				return err
+			}
+			if err := m.store.PutObject(ctx, key, payload, "application/gzip"); err != nil {
+				return fmt.Errorf("failed to archive batch %d: %w", batch, err)
+			}
+			*keys = append(*keys, key)
+			run.RowsArchived += int64(len(rows))
+		}
+
+		result := m.db.WithContext(ctx).Exec("DELETE FROM "+policy.Target+" WHERE id IN ?", ids)
+		if result.Error != nil {
+			return fmt.Errorf("failed to delete expired rows: %w", result.Error)
+		}
+		run.RowsDeleted += result.RowsAffected
+
+		// Persist progress so long runs are observable through the API
+		m.db.Model(run).Updates(map[string]interface{}{
+			"rows_archived": run.RowsArchived,
+			"rows_deleted":  run.RowsDeleted,
+		})
+
+		if len(rows) < retentionBatchSize {
+			return nil
+		}
+	}
+}
+
+// encodeJSONLGzip encodes rows as gzip-compressed JSON lines
+func encodeJSONLGzip(rows []map[string]interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	enc := json.NewEncoder(gz)
+	for _, row := range rows {
+		if err := enc.Encode(row); err != nil {
+			return nil, fmt.Errorf("failed to encode archive row: %w", err)
+		}
+	}
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress archive: %w", err)
+	}
+	return buf.Bytes(), nil
+}
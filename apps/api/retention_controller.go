@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/penguintechinc/project-template/shared/apierrors"
+	"gorm.io/gorm"
+)
+
+// RetentionController handles retention policy and archive run HTTP requests
+type RetentionController struct {
+	db      *gorm.DB
+	manager *RetentionManager
+}
+
+// NewRetentionController creates a new retention controller
+func NewRetentionController(db *gorm.DB, manager *RetentionManager) *RetentionController {
+	return &RetentionController{db: db, manager: manager}
+}
+
+// ListRetentionPolicies retrieves all retention policies
+// GET /api/v1/admin/retention-policies
+func (rc *RetentionController) ListRetentionPolicies(c *gin.Context) {
+	if !requirePlatformAdmin(c) {
+		return
+	}
+
+	var policies []*RetentionPolicy
+	if err := rc.db.Order("target ASC").Find(&policies).Error; err != nil {
+		log.Printf("Error listing retention policies: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to list retention policies")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"retention_policies": policies})
+}
+
+// UpsertRetentionPolicy creates or updates the retention policy for a table
+// PUT /api/v1/admin/retention-policies/:target
+func (rc *RetentionController) UpsertRetentionPolicy(c *gin.Context) {
+	if !requirePlatformAdmin(c) {
+		return
+	}
+
+	target := c.Param("target")
+	if !retentionTargets[target] {
+		apierrors.Abort(c, http.StatusBadRequest, "invalid_target", "target must be one of: audit_logs, resource_stats")
+		return
+	}
+
+	var req UpsertRetentionPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierrors.AbortWithDetails(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	var policy RetentionPolicy
+	if err := rc.db.Where("target = ?", target).First(&policy).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to retrieve retention policy")
+			return
+		}
+		policy = RetentionPolicy{Target: target, Enabled: true}
+	}
+
+	policy.RetentionDays = req.RetentionDays
+	policy.ArchiveEnabled = req.ArchiveEnabled
+	policy.ArchiveFormat = "jsonl"
+	if req.Enabled != nil {
+		policy.Enabled = *req.Enabled
+	}
+
+	if err := rc.db.Save(&policy).Error; err != nil {
+		log.Printf("Error saving retention policy: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to save retention policy")
+		return
+	}
+
+	c.JSON(http.StatusOK, policy)
+}
+
+// TriggerArchiveRun starts a retention run for a table immediately
+// POST /api/v1/admin/retention-policies/:target/run
+func (rc *RetentionController) TriggerArchiveRun(c *gin.Context) {
+	if !requirePlatformAdmin(c) {
+		return
+	}
+
+	var policy RetentionPolicy
+	if err := rc.db.Where("target = ?", c.Param("target")).First(&policy).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierrors.Abort(c, http.StatusNotFound, "retention_policy_not_found", "No retention policy is configured for this target")
+		} else {
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to retrieve retention policy")
+		}
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	var triggeredBy *uint
+	if id, ok := userID.(uint); ok {
+		triggeredBy = &id
+	}
+
+	run, err := rc.manager.StartRun(c.Request.Context(), &policy, triggeredBy)
+	if err != nil {
+		apierrors.AbortWithDetails(c, http.StatusConflict, "run_not_started", "Archive run could not be started", err.Error())
+		return
+	}
+
+	go rc.manager.Execute(context.Background(), &policy, run)
+
+	c.JSON(http.StatusAccepted, run)
+}
+
+// ListArchiveRuns retrieves recent archive runs
+// GET /api/v1/admin/archive-runs
+func (rc *RetentionController) ListArchiveRuns(c *gin.Context) {
+	if !requirePlatformAdmin(c) {
+		return
+	}
+
+	limit := 50
+	if l := c.Query("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 500 {
+			limit = parsed
+		}
+	}
+
+	query := rc.db.Order("started_at DESC").Limit(limit)
+	if target := c.Query("target"); target != "" {
+		query = query.Where("target = ?", target)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var runs []*ArchiveRun
+	if err := query.Find(&runs).Error; err != nil {
+		log.Printf("Error listing archive runs: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to list archive runs")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"archive_runs": runs})
+}
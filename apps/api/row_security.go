@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/penguintechinc/project-template/shared/apierrors"
+	"github.com/penguintechinc/project-template/shared/database"
+	"gorm.io/gorm"
+)
+
+// rowSecurityPolicy is the name of the team scoping policy on each table
+const rowSecurityPolicy = "nest_team_scope"
+
+// rowSecurityUnrestricted passes every row when no user is set, as for
+// background workers and the K8s controller, and for global admins
+const rowSecurityUnrestricted = `COALESCE(current_setting('nest.user_id', true), '') = ''
+	OR current_setting('nest.user_role', true) = 'admin'`
+
+// rowSecurityMember restricts resources to the teams the user belongs to
+const rowSecurityMember = `team_id IN (SELECT team_id FROM team_members
+	WHERE user_id = NULLIF(current_setting('nest.user_id', true), '')::bigint AND deleted_at IS NULL)`
+
+// rowSecurityResource restricts rows to resources the user can see. The
+// subquery is itself filtered by the resources policy.
+const rowSecurityResource = `resource_id IN (SELECT id FROM resources)`
+
+// rowSecurityTables maps team scoped tables to the condition a row must
+// meet for users who are not global admins
+var rowSecurityTables = []struct {
+	table     string
+	condition string
+}{
+	{"resources", rowSecurityMember},
+	{"resource_stats", rowSecurityResource},
+	{"provisioning_jobs", rowSecurityResource},
+	{"backup_jobs", rowSecurityResource},
+	{"certificates", rowSecurityResource},
+}
+
+// EnableRowSecurity installs the team scoping policies on the tables of the
+// connection's current schema. Tables that don't exist there are skipped.
+// Row security is forced, since the API connects as the tables' owner.
+func EnableRowSecurity(ctx context.Context, db *gorm.DB) error {
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, t := range rowSecurityTables {
+			var exists bool
+			if err := tx.Raw("SELECT to_regclass(quote_ident(current_schema()) || '.' || ?) IS NOT NULL", t.table).
+				Scan(&exists).Error; err != nil {
+				return err
+			}
+			if !exists {
+				continue
+			}
+
+			statements := []string{
+				fmt.Sprintf("ALTER TABLE %s ENABLE ROW LEVEL SECURITY", t.table),
+				fmt.Sprintf("ALTER TABLE %s FORCE ROW LEVEL SECURITY", t.table),
+				fmt.Sprintf("DROP POLICY IF EXISTS %s ON %s", rowSecurityPolicy, t.table),
+				fmt.Sprintf("CREATE POLICY %s ON %s USING ((%s) OR (%s))",
+					rowSecurityPolicy, t.table, rowSecurityUnrestricted, t.condition),
+			}
+			for _, stmt := range statements {
+				if err := tx.Exec(stmt).Error; err != nil {
+					return fmt.Errorf("failed to enable row security on %s: %w", t.table, err)
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// RowSecurityMiddleware runs each authenticated request in a transaction
+// whose nest.user_id and nest.user_role are set with SET LOCAL, so the
+// row security policies apply to every query the handler makes. The
+// transaction is committed before the response is written; error
+// responses roll it back.
+func RowSecurityMiddleware(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("user_id")
+		if !exists {
+			c.Next()
+			return
+		}
+		role, _ := c.Get("user_role")
+		roleName, _ := role.(string)
+
+		ctx := c.Request.Context()
+		tx := database.TenantDB(ctx, db).WithContext(ctx).Begin()
+		if tx.Error == nil {
+			tx.Exec("SELECT set_config('nest.user_id', ?, true), set_config('nest.user_role', ?, true)",
+				strconv.FormatUint(uint64(userID.(uint)), 10), roleName)
+		}
+		if tx.Error != nil {
+			log.Printf("Error starting row security transaction: %v", tx.Error)
+			tx.Rollback()
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to start transaction")
+			return
+		}
+
+		w := &rowSecurityWriter{ResponseWriter: c.Writer, c: c, tx: tx}
+		defer func() {
+			if !w.finished {
+				tx.Rollback()
+			}
+		}()
+		c.Writer = w
+		c.Request = c.Request.WithContext(database.WithTenantDB(ctx, database.TenantSchema(ctx), tx))
+		c.Next()
+		w.finish()
+	}
+}
+
+// rowSecurityWriter ends a request's transaction just before the response
+// is written, so that a failed commit can still be reported
+type rowSecurityWriter struct {
+	gin.ResponseWriter
+	c        *gin.Context
+	tx       *gorm.DB
+	finished bool
+	failed   bool
+}
+
+// finish commits the transaction for successful responses and rolls it back
+// otherwise. A failed commit replaces the response with an error.
+func (w *rowSecurityWriter) finish() {
+	if w.finished {
+		return
+	}
+	w.finished = true
+
+	if w.ResponseWriter.Status() >= http.StatusBadRequest {
+		w.tx.Rollback()
+		return
+	}
+	if err := w.tx.Commit().Error; err != nil {
+		log.Printf("Error committing row security transaction: %v", err)
+		w.failed = true
+		problem, _ := json.Marshal(apierrors.NewProblem(w.c, apierrors.New(http.StatusInternalServerError,
+			apierrors.CodeDatabaseError, "Failed to commit transaction")))
+		w.ResponseWriter.Header().Set("Content-Type", apierrors.ContentType)
+		w.ResponseWriter.Header().Set("Content-Language", apierrors.Language(w.c))
+		w.ResponseWriter.WriteHeader(http.StatusInternalServerError)
+		w.ResponseWriter.Write(problem)
+	}
+}
+
+func (w *rowSecurityWriter) WriteHeaderNow() {
+	w.finish()
+	if !w.failed {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *rowSecurityWriter) Write(data []byte) (int, error) {
+	w.finish()
+	if w.failed {
+		return len(data), nil
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *rowSecurityWriter) WriteString(s string) (int, error) {
+	w.finish()
+	if w.failed {
+		return len(s), nil
+	}
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,440 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"math/big"
+	mathrand "math/rand"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/penguintechinc/project-template/shared/database"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// SeedOptions shape the demo data SeedDemoData creates
+type SeedOptions struct {
+	// Teams is how many of the demo teams to create, at most
+	// len(demoTeams)
+	Teams int
+	// ResourcesPerTeam is how many resources each team gets
+	ResourcesPerTeam int
+	// StatsHistory is how far back hourly stats samples go
+	StatsHistory time.Duration
+	// Password is the password of every demo user
+	Password string
+	// Seed makes the generated data repeatable
+	Seed int64
+}
+
+// SeedSummary counts what SeedDemoData created
+type SeedSummary struct {
+	Teams         int
+	Users         int
+	ResourceTypes int
+	Resources     int
+	Stats         int
+	Certificates  int
+}
+
+// demoTeams name the demo teams, in the order they are created
+var demoTeams = []struct{ Name, Description string }{
+	{"platform", "Shared infrastructure and developer tooling"},
+	{"payments", "Checkout, billing and payouts"},
+	{"search", "Catalog indexing and search relevance"},
+	{"analytics", "Reporting pipelines and dashboards"},
+	{"mobile", "iOS and Android app backends"},
+	{"identity", "Login, sessions and account management"},
+}
+
+// demoMembers are the members of each demo team, by team role. Usernames
+// are prefixed with the team name.
+var demoMembers = []struct{ Name, Role string }{
+	{"lead", "admin"},
+	{"sre", "maintainer"},
+	{"dev", "contributor"},
+	{"analyst", "viewer"},
+}
+
+// demoResourceTypes are created when missing, so resources have a type
+// even on a fresh database
+var demoResourceTypes = []ResourceType{
+	{Name: "postgresql", Category: "database", DisplayName: "PostgreSQL", Icon: "postgresql",
+		SupportsFullLifecycle: true, SupportsPartialLifecycle: true, SupportsUserManagement: true, SupportsBackup: true},
+	{Name: "mariadb", Category: "database", DisplayName: "MariaDB", Icon: "mariadb",
+		SupportsFullLifecycle: true, SupportsPartialLifecycle: true, SupportsUserManagement: true, SupportsBackup: true},
+	{Name: "redis", Category: "cache", DisplayName: "Redis", Icon: "redis",
+		SupportsFullLifecycle: true, SupportsPartialLifecycle: true, SupportsUserManagement: true, SupportsBackup: true},
+}
+
+// demoStatuses weight the statuses of demo resources: mostly active, with
+// a few in each transitional status and in error
+var demoStatuses = []string{
+	"active", "active", "active", "active", "active", "active",
+	"pending", "provisioning", "updating", "error", "inactive",
+}
+
+// demoCertificateExpiry spreads the expiry of demo certificates so that
+// some have expired, some are inside the expiry warning window and the
+// rest are far off
+var demoCertificateExpiry = []time.Duration{
+	-2 * 24 * time.Hour,
+	3 * 24 * time.Hour,
+	10 * 24 * time.Hour,
+	25 * 24 * time.Hour,
+	180 * 24 * time.Hour,
+	365 * 24 * time.Hour,
+}
+
+// demoAdmin is the username of the global admin SeedDemoData creates, and
+// marks a database that was already seeded
+const demoAdmin = "demo-admin"
+
+// errAlreadySeeded is returned when the database holds demo data
+var errAlreadySeeded = errors.New("demo data is already seeded")
+
+// seedCommand runs `api seed`, migrating the database in the environment's
+// DB_* settings and filling it with demo data
+func seedCommand(args []string) {
+	opts := SeedOptions{}
+	fs := flag.NewFlagSet("seed", flag.ExitOnError)
+	fs.IntVar(&opts.Teams, "teams", 4, fmt.Sprintf("Demo teams to create, at most %d", len(demoTeams)))
+	fs.IntVar(&opts.ResourcesPerTeam, "resources", 8, "Resources per team")
+	fs.DurationVar(&opts.StatsHistory, "history", 7*24*time.Hour, "How far back hourly stats go")
+	fs.StringVar(&opts.Password, "password", os.Getenv("SEED_PASSWORD"), "Password of every demo user (default: nest-demo-password)")
+	fs.Int64Var(&opts.Seed, "seed", 1, "Random seed, for repeatable data")
+	fs.Parse(args)
+
+	if opts.Password == "" {
+		opts.Password = "nest-demo-password"
+	}
+	if opts.Teams <= 0 || opts.Teams > len(demoTeams) || opts.ResourcesPerTeam <= 0 {
+		log.Fatalf("-teams must be between 1 and %d, and -resources positive", len(demoTeams))
+	}
+
+	db, err := database.New(database.DefaultConfig())
+	if err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer db.Close()
+	if err := db.Migrate(migratedModels...); err != nil {
+		log.Fatalf("Failed to run database migrations: %v", err)
+	}
+
+	summary, err := SeedDemoData(context.Background(), db.DB, opts)
+	if errors.Is(err, errAlreadySeeded) {
+		log.Printf("Demo data is already seeded; reset the database to seed it again")
+		return
+	}
+	if err != nil {
+		log.Fatalf("Failed to seed demo data: %v", err)
+	}
+	log.Printf("Seeded %d teams, %d users, %d resource types, %d resources, %d stats samples and %d certificates",
+		summary.Teams, summary.Users, summary.ResourceTypes, summary.Resources, summary.Stats, summary.Certificates)
+	log.Printf("Log in as %s, or <team>-lead, -sre, -dev or -analyst, with the password %q", demoAdmin, opts.Password)
+}
+
+// SeedDemoData fills the database with realistic demo data for local
+// development, demos and UI work: teams whose members hold each team role,
+// a global admin, resources of each type across environments and
+// statuses, hourly stats history, and TLS certificates nearing expiry.
+// Resources are partial lifecycle, so a running controller leaves them
+// alone. Certificates are only seeded once the certificate subsystem has
+// created its tables. Everything is created in one transaction, and
+// errAlreadySeeded is returned when the database was seeded before.
+func SeedDemoData(ctx context.Context, db *gorm.DB, opts SeedOptions) (*SeedSummary, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(opts.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash password: %w", err)
+	}
+	rng := mathrand.New(mathrand.NewSource(opts.Seed))
+	now := time.Now().UTC().Truncate(time.Hour)
+	summary := &SeedSummary{}
+
+	err = db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var existing int64
+		if err := tx.Model(&User{}).Where("username = ?", demoAdmin).Count(&existing).Error; err != nil {
+			return err
+		}
+		if existing > 0 {
+			return errAlreadySeeded
+		}
+
+		admin := &User{Username: demoAdmin, Email: demoAdmin + "@example.com", PasswordHash: string(hash),
+			FirstName: "Demo", LastName: "Admin", Role: "admin", IsActive: true, PasswordChangedAt: &now}
+		if err := tx.Create(admin).Error; err != nil {
+			return fmt.Errorf("failed to create admin: %w", err)
+		}
+		summary.Users++
+
+		types, err := seedResourceTypes(tx, summary)
+		if err != nil {
+			return err
+		}
+
+		var tlsResources []Resource
+		for _, demo := range demoTeams[:opts.Teams] {
+			team := &Team{Name: demo.Name, Description: demo.Description}
+			if err := tx.Create(team).Error; err != nil {
+				return fmt.Errorf("failed to create team %s: %w", demo.Name, err)
+			}
+			summary.Teams++
+
+			var createdBy uint
+			for _, member := range demoMembers {
+				username := demo.Name + "-" + member.Name
+				user := &User{Username: username, Email: username + "@example.com", PasswordHash: string(hash),
+					FirstName: capitalize(demo.Name), LastName: capitalize(member.Name), Role: "user",
+					IsActive: true, PasswordChangedAt: &now}
+				if err := tx.Create(user).Error; err != nil {
+					return fmt.Errorf("failed to create user %s: %w", username, err)
+				}
+				if err := tx.Create(&TeamMember{TeamID: team.ID, UserID: user.ID, Role: member.Role}).Error; err != nil {
+					return fmt.Errorf("failed to add %s to team: %w", username, err)
+				}
+				if createdBy == 0 {
+					createdBy = user.ID
+				}
+				summary.Users++
+			}
+
+			resources := make([]Resource, 0, opts.ResourcesPerTeam)
+			for i := 0; i < opts.ResourcesPerTeam; i++ {
+				resources = append(resources, demoResource(rng, team, types[i%len(types)], i, createdBy, now))
+			}
+			if err := tx.Create(&resources).Error; err != nil {
+				return fmt.Errorf("failed to create resources: %w", err)
+			}
+			summary.Resources += len(resources)
+
+			for _, resource := range resources {
+				if resource.TLSEnabled {
+					tlsResources = append(tlsResources, resource)
+				}
+				stats, err := seedStats(tx, rng, &resource, opts.StatsHistory, now)
+				if err != nil {
+					return err
+				}
+				summary.Stats += stats
+			}
+		}
+
+		summary.Certificates, err = seedCertificates(tx, tlsResources, admin.ID, now)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
+// seedResourceTypes returns the demo resource types, creating those missing
+func seedResourceTypes(tx *gorm.DB, summary *SeedSummary) ([]ResourceType, error) {
+	types := make([]ResourceType, 0, len(demoResourceTypes))
+	for _, demo := range demoResourceTypes {
+		rt := demo
+		result := tx.Where(ResourceType{Name: demo.Name}).FirstOrCreate(&rt)
+		if result.Error != nil {
+			return nil, fmt.Errorf("failed to create resource type %s: %w", demo.Name, result.Error)
+		}
+		if result.RowsAffected > 0 {
+			summary.ResourceTypes++
+		}
+		types = append(types, rt)
+	}
+	return types, nil
+}
+
+// demoResource builds the i-th demo resource of a team
+func demoResource(rng *mathrand.Rand, team *Team, rt ResourceType, i int, createdBy uint, now time.Time) Resource {
+	env := []string{"dev", "staging", "prod"}[(i+i/3)%3]
+	name := fmt.Sprintf("%s-%s-%s-%d", team.Name, rt.Name, env, i/3+1)
+	port := map[string]int{"postgresql": 5432, "mariadb": 3306, "redis": 6379}[rt.Name]
+	namespace := "nest-" + team.Name
+	host := fmt.Sprintf("%s.%s.svc.cluster.local", name, namespace)
+	connection, _ := json.Marshal(map[string]interface{}{"host": host, "port": port})
+
+	resource := Resource{
+		Name:               name,
+		ResourceTypeID:     rt.ID,
+		TeamID:             team.ID,
+		Environment:        env,
+		Status:             demoStatuses[rng.Intn(len(demoStatuses))],
+		LifecycleMode:      "partial",
+		ProvisioningMethod: "kubernetes",
+		ConnectionInfo:     datatypes.JSON(connection),
+		TLSEnabled:         env != "dev",
+		K8sNamespace:       namespace,
+		K8sResourceName:    name,
+		Config:             datatypes.JSON(`{}`),
+		CanModifyUsers:     true,
+		CanBackup:          env == "prod",
+		CreatedBy:          createdBy,
+	}
+	resource.CreatedAt = now.Add(-time.Duration(30+rng.Intn(300)) * 24 * time.Hour)
+	if resource.Status == "error" {
+		failedAt := now.Add(-time.Duration(1+rng.Intn(48)) * time.Hour)
+		resource.LastError = fmt.Sprintf("dial tcp %s:%d: connect: connection refused", host, port)
+		resource.LastErrorAt = &failedAt
+		resource.ConsecutiveFailures = 1 + rng.Intn(5)
+	}
+	return resource
+}
+
+// seedStats inserts hourly stats samples for a resource over history,
+// following a daily load curve with noise. Inactive resources have none.
+func seedStats(tx *gorm.DB, rng *mathrand.Rand, resource *Resource, history time.Duration, now time.Time) (int, error) {
+	if resource.Status == "inactive" || history <= 0 {
+		return 0, nil
+	}
+	base := 20 + rng.Float64()*40
+	memory := float64(int64(256+rng.Intn(1792)) << 20)
+	maxConnections := 100 + rng.Intn(400)
+
+	samples := make([]ResourceStats, 0, int(history/time.Hour))
+	for ts := now.Add(-history); !ts.After(now); ts = ts.Add(time.Hour) {
+		load := base * (1 + 0.6*math.Sin(2*math.Pi*float64(ts.Hour()-9)/24)) * (0.85 + 0.3*rng.Float64())
+		cpu := math.Min(math.Round(load*10)/10, 100)
+		connections := int(float64(maxConnections) * cpu / 100)
+
+		risk, factors := "low", []string{}
+		switch {
+		case resource.Status == "error" && ts.After(*resource.LastErrorAt):
+			risk, factors = "critical", []string{"unreachable"}
+			cpu, connections = 0, 0
+		case cpu >= 85:
+			risk, factors = "high", []string{"cpu_saturation"}
+		case cpu >= 70:
+			risk, factors = "medium", []string{"cpu_pressure"}
+		}
+		metrics, _ := json.Marshal(map[string]interface{}{
+			"connections":       connections,
+			"max_connections":   maxConnections,
+			"cpu_percent":       cpu,
+			"memory_used_bytes": int64(memory * (0.5 + cpu/200)),
+		})
+		riskFactors, _ := json.Marshal(factors)
+		samples = append(samples, ResourceStats{
+			ResourceID:  resource.ID,
+			Timestamp:   ts,
+			Metrics:     datatypes.JSON(metrics),
+			RiskLevel:   risk,
+			RiskFactors: datatypes.JSON(riskFactors),
+		})
+	}
+	if err := tx.CreateInBatches(samples, 500).Error; err != nil {
+		return 0, fmt.Errorf("failed to create stats: %w", err)
+	}
+	return len(samples), nil
+}
+
+// seedCertificates issues a certificate from a demo CA to each resource,
+// with expiries spread over demoCertificateExpiry. It does nothing until
+// the certificate subsystem has created its tables.
+func seedCertificates(tx *gorm.DB, resources []Resource, createdBy uint, now time.Time) (int, error) {
+	if len(resources) == 0 || !tx.Migrator().HasTable("certificates") || !tx.Migrator().HasTable("certificate_authorities") {
+		return 0, nil
+	}
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return 0, err
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "NEST Demo CA", Organization: []string{"NEST Demo"}},
+		NotBefore:             now.AddDate(-1, 0, 0),
+		NotAfter:              now.AddDate(9, 0, 0),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caPEM, caKeyPEM, err := encodeCertificate(caTemplate, caTemplate, &caKey.PublicKey, caKey, caKey)
+	if err != nil {
+		return 0, err
+	}
+	var caID uint
+	if err := tx.Raw(`INSERT INTO certificate_authorities
+		(name, type, certificate, private_key, subject, issuer, valid_from, valid_until, serial_number,
+		 is_nest_managed, status, created_by, created_at, updated_at)
+		VALUES (?, 'root', ?, ?, ?, ?, ?, ?, ?, true, 'active', ?, ?, ?) RETURNING id`,
+		"nest-demo-ca", caPEM, caKeyPEM, caTemplate.Subject.String(), caTemplate.Subject.String(),
+		caTemplate.NotBefore, caTemplate.NotAfter, caTemplate.SerialNumber.String(), createdBy, now, now).
+		Scan(&caID).Error; err != nil {
+		return 0, fmt.Errorf("failed to create demo CA: %w", err)
+	}
+
+	for i, resource := range resources {
+		var host string
+		var connection map[string]interface{}
+		if json.Unmarshal(resource.ConnectionInfo, &connection) == nil {
+			host, _ = connection["host"].(string)
+		}
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return i, err
+		}
+		notAfter := now.Add(demoCertificateExpiry[i%len(demoCertificateExpiry)])
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(int64(i + 2)),
+			Subject:      pkix.Name{CommonName: host},
+			DNSNames:     []string{host},
+			NotBefore:    notAfter.AddDate(-1, 0, 0),
+			NotAfter:     notAfter,
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		}
+		certPEM, keyPEM, err := encodeCertificate(template, caTemplate, &key.PublicKey, caKey, key)
+		if err != nil {
+			return i, err
+		}
+		sans, _ := json.Marshal([]string{host})
+		if err := tx.Exec(`INSERT INTO certificates
+			(resource_id, ca_id, certificate, private_key, common_name, san_dns, valid_from, valid_until,
+			 serial_number, auto_renew, renewal_threshold_days, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 30, ?, ?)`,
+			resource.ID, caID, certPEM, keyPEM, host, string(sans), template.NotBefore, template.NotAfter,
+			template.SerialNumber.String(), resource.Environment == "prod", now, now).Error; err != nil {
+			return i, fmt.Errorf("failed to create certificate: %w", err)
+		}
+	}
+	return len(resources), nil
+}
+
+// encodeCertificate signs template with the parent's key and returns the
+// certificate and the subject's key as PEM
+func encodeCertificate(template, parent *x509.Certificate, pub *ecdsa.PublicKey, signer, key *ecdsa.PrivateKey) (string, string, error) {
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, pub, signer)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to sign certificate: %w", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return "", "", err
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return string(certPEM), string(keyPEM), nil
+}
+
+// capitalize upper-cases the first letter of a demo name
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/penguintechinc/project-template/shared/configfile"
+	"gorm.io/gorm/logger"
+)
+
+// Kinds of values the API's settings take
+const (
+	settingDuration = "a duration, such as 30s or 5m"
+	settingInt      = "an integer"
+	settingBool     = "true or false"
+)
+
+// apiSettings are the typed settings the API reads from the environment.
+// Values that don't parse fall back to their defaults at startup, so they
+// are checked here to report them.
+var apiSettings = map[string]string{
+	"AGENT_STALE_AFTER":             settingDuration,
+	"ALERT_EVALUATION_INTERVAL":     settingDuration,
+	"AUDIT_ANCHOR_INTERVAL":         settingDuration,
+	"CERT_EXPIRY_WARNING":           settingDuration,
+	"CLOUD_SYNC_INTERVAL":           settingDuration,
+	"CONTROLLER_STALE_AFTER":        settingDuration,
+	"EVENT_EXPORT_INTERVAL":         settingDuration,
+	"JOB_POLL_INTERVAL":             settingDuration,
+	"JOB_REAP_INTERVAL":             settingDuration,
+	"JOB_RETENTION":                 settingDuration,
+	"LOG_LEVEL_POLL_INTERVAL":       settingDuration,
+	"MAIL_SCHEDULE_INTERVAL":        settingDuration,
+	"MTLS_RELOAD_INTERVAL":          settingDuration,
+	"PASSWORD_BREACH_CHECK_TIMEOUT": settingDuration,
+	"POLICY_SYNC_INTERVAL":          settingDuration,
+	"RESOURCE_PURGE_INTERVAL":       settingDuration,
+	"RESOURCE_TRASH_RETENTION":      settingDuration,
+	"RETENTION_INTERVAL":            settingDuration,
+	"SLACK_NOTIFY_INTERVAL":         settingDuration,
+	"STATUS_PAGE_REFRESH":           settingDuration,
+	"STUCK_RESOURCE_CHECK_INTERVAL": settingDuration,
+	"STUCK_RESOURCE_THRESHOLD":      settingDuration,
+	"TEAM_DELETION_INTERVAL":        settingDuration,
+	"TICKET_SYNC_INTERVAL":          settingDuration,
+	"USAGE_REPORTING_INTERVAL":      settingDuration,
+	"DB_PORT":                       settingInt,
+	"JOB_CONCURRENCY":               settingInt,
+	"PARTITION_MONTHS_AHEAD":        settingInt,
+	"SMTP_PORT":                     settingInt,
+	"STUCK_RESOURCE_REQUEUES":       settingInt,
+	"TENANT_POOL_SIZE":              settingInt,
+	"DEBUG_LOCAL_ONLY":              settingBool,
+	"ENABLE_DEBUG_ENDPOINTS":        settingBool,
+	"ENABLE_WEB_UI":                 settingBool,
+	"EXPOSE_TEAM_METRICS":           settingBool,
+	"PARTITIONING_ENABLED":          settingBool,
+	"ROW_SECURITY_ENABLED":          settingBool,
+	"SMTP_TLS":                      settingBool,
+	"STATUS_PAGE_ENABLED":           settingBool,
+	"USAGE_REPORTING_ENABLED":       settingBool,
+	"USAGE_REPORTING_OPT_OUT":       settingBool,
+}
+
+// validateSettings checks the API's settings in the environment, returning
+// every problem found
+func validateSettings() error {
+	var errs []error
+	keys := make([]string, 0, len(apiSettings))
+	for key := range apiSettings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := os.Getenv(key)
+		if value == "" {
+			continue
+		}
+		var err error
+		switch kind := apiSettings[key]; kind {
+		case settingDuration:
+			_, err = time.ParseDuration(value)
+		case settingInt:
+			_, err = strconv.Atoi(value)
+		case settingBool:
+			_, err = strconv.ParseBool(value)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %q is not %s", key, value, apiSettings[key]))
+		}
+	}
+
+	if _, ok := parseLogLevel(os.Getenv("LOG_LEVEL")); !ok {
+		errs = append(errs, fmt.Errorf("LOG_LEVEL must be one of debug, info, warn or error"))
+	}
+	if mode := os.Getenv("TENANCY_MODE"); mode != "" && mode != TenancyModeSchema {
+		errs = append(errs, fmt.Errorf("TENANCY_MODE must be empty or %s", TenancyModeSchema))
+	}
+	if _, err := ParseJobTimeouts(os.Getenv("JOB_TIMEOUTS")); err != nil {
+		errs = append(errs, fmt.Errorf("JOB_TIMEOUTS: %w", err))
+	}
+	return errors.Join(errs...)
+}
+
+// parseLogLevel maps LOG_LEVEL to the level of SQL logging: debug traces
+// every statement, and the other levels log none
+func parseLogLevel(level string) (logger.LogLevel, bool) {
+	switch strings.ToLower(level) {
+	case "debug":
+		return logger.Info, true
+	case "", "info", "warn", "error":
+		return logger.Silent, true
+	}
+	return logger.Silent, false
+}
+
+// sqlLogger is a GORM logger whose level can change while the API runs,
+// as set by logLevels
+type sqlLogger struct {
+	level atomic.Int32
+}
+
+// SetLevel changes the level of SQL logging
+func (l *sqlLogger) SetLevel(level logger.LogLevel) {
+	l.level.Store(int32(level))
+}
+
+func (l *sqlLogger) current() logger.Interface {
+	return logger.Default.LogMode(logger.LogLevel(l.level.Load()))
+}
+
+// LogMode implements logger.Interface. The level is shared by every
+// session, so it is left to SetLevel.
+func (l *sqlLogger) LogMode(logger.LogLevel) logger.Interface {
+	return l
+}
+
+func (l *sqlLogger) Info(ctx context.Context, msg string, data ...interface{}) {
+	l.current().Info(ctx, msg, data...)
+}
+
+func (l *sqlLogger) Warn(ctx context.Context, msg string, data ...interface{}) {
+	l.current().Warn(ctx, msg, data...)
+}
+
+func (l *sqlLogger) Error(ctx context.Context, msg string, data ...interface{}) {
+	l.current().Error(ctx, msg, data...)
+}
+
+func (l *sqlLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	l.current().Trace(ctx, begin, fc, err)
+}
+
+// watchConfigReloads rereads the config file, when there is one, on each
+// signal received on reload, and applies the settings that take effect
+// without a restart: the log level, and the settings read as they are used,
+// such as NEST_PUBLIC_URL. An invalid file is logged and the running
+// configuration kept.
+func watchConfigReloads(reload <-chan os.Signal, source *configfile.Source, levels *logLevels) {
+	for range reload {
+		if source != nil {
+			changed, err := source.Apply()
+			if err != nil {
+				log.Printf("Failed to reload config file; keeping the running configuration: %v", err)
+				continue
+			}
+			log.Printf("Config file %s reloaded; changed: %s", source.Path(), strings.Join(changed, ", "))
+		}
+		if err := validateSettings(); err != nil {
+			log.Printf("Invalid settings after reload, which fall back to their defaults: %v", err)
+		}
+		levels.SetConfigured(os.Getenv("LOG_LEVEL"))
+		log.Printf("Configuration reloaded with LOG_LEVEL=%s; other settings take effect on restart", os.Getenv("LOG_LEVEL"))
+	}
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// SizeClassCustom is the size class of resources whose requests are set
+// directly in Config.resources rather than from a preset
+const SizeClassCustom = "custom"
+
+// configKeyResources holds a resource's cpu, memory, and storage requests,
+// applied by the K8s controller to the engine container
+const configKeyResources = "resources"
+
+// sizeClassNamePattern restricts size class names to lowercase identifiers
+var sizeClassNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,31}$`)
+
+// quantityPattern matches Kubernetes resource quantities such as 500m, 2, or
+// 10Gi
+var quantityPattern = regexp.MustCompile(`^\d+(\.\d+)?(m|k|M|G|T|P|E|Ki|Mi|Gi|Ti|Pi|Ei)?$`)
+
+// defaultSizeClass builds a size class preset
+func defaultSizeClass(name, cpu, memory, storage string, tuning map[string]interface{}) SizeClass {
+	raw, _ := json.Marshal(tuning)
+	return SizeClass{Name: name, CPU: cpu, Memory: memory, Storage: storage, Tuning: datatypes.JSON(raw)}
+}
+
+// DefaultSizeClasses are the size classes of resource types that have not
+// had their own defined, keyed by resource type name
+var DefaultSizeClasses = map[string][]SizeClass{
+	"postgresql": {
+		defaultSizeClass("small", "250m", "512Mi", "5Gi", map[string]interface{}{
+			"shared_buffers": "128MB", "effective_cache_size": "384MB", "max_connections": 100,
+		}),
+		defaultSizeClass("medium", "1", "2Gi", "20Gi", map[string]interface{}{
+			"shared_buffers": "512MB", "effective_cache_size": "1536MB", "max_connections": 200,
+		}),
+		defaultSizeClass("large", "4", "8Gi", "100Gi", map[string]interface{}{
+			"shared_buffers": "2GB", "effective_cache_size": "6GB", "max_connections": 400,
+		}),
+	},
+	"mariadb": {
+		defaultSizeClass("small", "250m", "512Mi", "5Gi", map[string]interface{}{
+			"innodb_buffer_pool_size": "256M", "max_connections": 100,
+		}),
+		defaultSizeClass("medium", "1", "2Gi", "20Gi", map[string]interface{}{
+			"innodb_buffer_pool_size": "1G", "max_connections": 200,
+		}),
+		defaultSizeClass("large", "4", "8Gi", "100Gi", map[string]interface{}{
+			"innodb_buffer_pool_size": "5G", "max_connections": 400,
+		}),
+	},
+	"redis": {
+		defaultSizeClass("small", "100m", "256Mi", "1Gi", map[string]interface{}{
+			"maxmemory": "192mb",
+		}),
+		defaultSizeClass("medium", "500m", "1Gi", "4Gi", map[string]interface{}{
+			"maxmemory": "768mb",
+		}),
+		defaultSizeClass("large", "2", "4Gi", "16Gi", map[string]interface{}{
+			"maxmemory": "3gb",
+		}),
+	},
+}
+
+// resourceSizeClasses returns a resource type's size classes in order,
+// falling back to DefaultSizeClasses
+func resourceSizeClasses(db *gorm.DB, resourceType *ResourceType) ([]SizeClass, error) {
+	var classes []SizeClass
+	if err := db.Where("resource_type_id = ?", resourceType.ID).Order("position ASC").Find(&classes).Error; err != nil {
+		return nil, err
+	}
+	// A class whose tuning doesn't decode would be applied without it, so
+	// the classes aren't used until it is replaced
+	for i := range classes {
+		var tuning map[string]interface{}
+		if !decodeJSONField(classes[i].Tuning, &tuning, "size class tuning") {
+			return nil, fmt.Errorf("tuning of size class %s doesn't decode", classes[i].Name)
+		}
+	}
+	if len(classes) == 0 {
+		defaults := DefaultSizeClasses[resourceType.Name]
+		classes = make([]SizeClass, len(defaults))
+		copy(classes, defaults)
+		for i := range classes {
+			classes[i].ResourceTypeID = resourceType.ID
+			classes[i].Position = i
+		}
+	}
+	return classes, nil
+}
+
+// findSizeClass returns the named size class, or nil if the resource type
+// has no such class
+func findSizeClass(classes []SizeClass, name string) *SizeClass {
+	for i := range classes {
+		if classes[i].Name == name {
+			return &classes[i]
+		}
+	}
+	return nil
+}
+
+// validateSizeClass checks a size class definition for a resource type
+func validateSizeClass(resourceType string, class SizeClassRequest) error {
+	if !sizeClassNamePattern.MatchString(class.Name) || class.Name == SizeClassCustom {
+		return fmt.Errorf("invalid size class name %q", class.Name)
+	}
+	if err := validateQuantities(map[string]string{"cpu": class.CPU, "memory": class.Memory, "storage": class.Storage}); err != nil {
+		return fmt.Errorf("size class %q: %w", class.Name, err)
+	}
+	if err := validateTuning(resourceType, map[string]interface{}{"tuning": class.Tuning}); err != nil {
+		return fmt.Errorf("size class %q: %w", class.Name, err)
+	}
+	return nil
+}
+
+// validateQuantities checks cpu, memory, and storage requests. Storage may be
+// omitted; cpu and memory are required.
+func validateQuantities(requests map[string]string) error {
+	for name, value := range requests {
+		switch name {
+		case "cpu", "memory", "storage":
+		default:
+			return fmt.Errorf("unknown resource request %q", name)
+		}
+		if value != "" && !quantityPattern.MatchString(value) {
+			return fmt.Errorf("invalid %s quantity %q", name, value)
+		}
+	}
+	if requests["cpu"] == "" || requests["memory"] == "" {
+		return fmt.Errorf("cpu and memory requests are required")
+	}
+	return nil
+}
+
+// configResources returns Config.resources, or nil when it is not set
+func configResources(cfg map[string]interface{}) (map[string]string, error) {
+	raw, ok := cfg[configKeyResources]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+	m, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("resources must be an object of cpu, memory, and storage quantities")
+	}
+
+	requests := make(map[string]string, len(m))
+	for name, v := range m {
+		value, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("resource request %q must be a string quantity", name)
+		}
+		requests[name] = value
+	}
+	return requests, nil
+}
+
+// validateConfigResources checks Config.resources when it is set
+func validateConfigResources(cfg map[string]interface{}) error {
+	requests, err := configResources(cfg)
+	if err != nil || requests == nil {
+		return err
+	}
+	return validateQuantities(requests)
+}
+
+// applySizeClass sets a config's requests from a size class and merges the
+// class's tuning under the caller's own parameters. Tuning carried over from
+// the previous class is replaced rather than kept as a caller override. A nil
+// class leaves the requests already in the config, for the custom class.
+func applySizeClass(cfg map[string]interface{}, previous, class *SizeClass) map[string]interface{} {
+	if cfg == nil {
+		cfg = make(map[string]interface{})
+	}
+
+	tuning := make(map[string]interface{})
+	if m, ok := cfg["tuning"].(map[string]interface{}); ok {
+		for k, v := range m {
+			tuning[k] = v
+		}
+	}
+	if previous != nil {
+		for k, v := range previous.tuning() {
+			if reflect.DeepEqual(tuning[k], v) {
+				delete(tuning, k)
+			}
+		}
+	}
+
+	if class != nil {
+		requests := map[string]interface{}{"cpu": class.CPU, "memory": class.Memory}
+		if class.Storage != "" {
+			requests["storage"] = class.Storage
+		}
+		cfg[configKeyResources] = requests
+
+		for k, v := range class.tuning() {
+			if _, ok := tuning[k]; !ok {
+				tuning[k] = v
+			}
+		}
+	}
+
+	if len(tuning) > 0 {
+		cfg["tuning"] = tuning
+	} else {
+		delete(cfg, "tuning")
+	}
+	return cfg
+}
+
+// tuning decodes the class's engine tuning, normalised through JSON so that
+// it compares equal to decoded resource configs
+func (sc *SizeClass) tuning() map[string]interface{} {
+	tuning := make(map[string]interface{})
+	decodeJSONField(sc.Tuning, &tuning, "size class tuning")
+	return tuning
+}
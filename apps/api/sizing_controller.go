@@ -0,0 +1,239 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/penguintechinc/project-template/shared/apierrors"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// SizingController handles resource type size class and resize HTTP requests
+type SizingController struct {
+	db     *gorm.DB
+	access *AccessCache
+}
+
+// NewSizingController creates a new sizing controller
+func NewSizingController(db *gorm.DB, access *AccessCache) *SizingController {
+	return &SizingController{db: db, access: access}
+}
+
+// loadResourceType parses the resource type ID parameter and loads the type,
+// writing an error response on failure
+func (sc *SizingController) loadResourceType(c *gin.Context) (*ResourceType, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		apierrors.Abort(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Invalid resource type ID")
+		return nil, false
+	}
+
+	resourceType, err := sc.access.ResourceType(c.Request.Context(), uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierrors.Abort(c, http.StatusNotFound, "resource_type_not_found", "Resource type not found")
+		} else {
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to retrieve resource type")
+		}
+		return nil, false
+	}
+	return resourceType, true
+}
+
+// ListSizeClasses retrieves a resource type's size classes
+// GET /api/v1/resource-types/:id/size-classes
+func (sc *SizingController) ListSizeClasses(c *gin.Context) {
+	resourceType, ok := sc.loadResourceType(c)
+	if !ok {
+		return
+	}
+
+	classes, err := resourceSizeClasses(tenantDB(c, sc.db), resourceType)
+	if err != nil {
+		log.Printf("Error listing size classes: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to list size classes")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"size_classes": classes})
+}
+
+// SetSizeClasses replaces a resource type's size classes. Existing resources
+// keep their requests until they are resized.
+// PUT /api/v1/resource-types/:id/size-classes
+func (sc *SizingController) SetSizeClasses(c *gin.Context) {
+	if !requirePlatformAdmin(c) {
+		return
+	}
+	resourceType, ok := sc.loadResourceType(c)
+	if !ok {
+		return
+	}
+
+	var req SetSizeClassesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierrors.AbortWithDetails(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	classes := make([]SizeClass, 0, len(req.SizeClasses))
+	seen := make(map[string]bool, len(req.SizeClasses))
+	for i, class := range req.SizeClasses {
+		if seen[class.Name] {
+			apierrors.Abort(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Duplicate size class: "+class.Name)
+			return
+		}
+		seen[class.Name] = true
+		if err := validateSizeClass(resourceType.Name, class); err != nil {
+			apierrors.Abort(c, http.StatusBadRequest, "invalid_size_class", err.Error())
+			return
+		}
+
+		tuning, _ := json.Marshal(class.Tuning)
+		classes = append(classes, SizeClass{
+			ResourceTypeID: resourceType.ID,
+			Name:           class.Name,
+			Position:       i,
+			CPU:            class.CPU,
+			Memory:         class.Memory,
+			Storage:        class.Storage,
+			Tuning:         datatypes.JSON(tuning),
+		})
+	}
+
+	if err := tenantDB(c, sc.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("resource_type_id = ?", resourceType.ID).Delete(&SizeClass{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&classes).Error
+	}); err != nil {
+		log.Printf("Error saving size classes: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to save size classes")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"size_classes": classes})
+}
+
+// ResizeResource moves a full lifecycle resource to another size class. The
+// new requests and tuning are written to its config and a reconcile is
+// queued, which the K8s controller carries out as a scale operation. With
+// Prefer: respond-async the operation is returned instead of the resource.
+// POST /api/v1/resources/:id/resize
+func (sc *SizingController) ResizeResource(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "User context not found")
+		return
+	}
+
+	var req ResizeResourceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierrors.AbortWithDetails(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	var resource Resource
+	if err := tenantDB(c, sc.db).Where("resources.id = ? AND resources.deleted_at IS NULL", c.Param("id")).
+		Joins("INNER JOIN team_members ON resources.team_id = team_members.team_id").
+		Where("team_members.user_id = ?", userID.(uint)).
+		Preload("ResourceType").
+		First(&resource).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierrors.Abort(c, http.StatusNotFound, "resource_not_found", "Resource not found or you do not have access")
+		} else {
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to retrieve resource")
+		}
+		return
+	}
+
+	userRole, _ := c.Get("user_role")
+	teamRole, _, err := sc.access.TeamRole(c.Request.Context(), userID.(uint), resource.TeamID)
+	if err != nil || (!hasMinimumRole(userRole, "admin") && !hasMinimumRole(teamRole, "maintainer")) {
+		apierrors.Abort(c, http.StatusForbidden, apierrors.CodeForbidden, "Insufficient permissions to resize resources")
+		return
+	}
+
+	enabled, err := featureEnabled(tenantDB(c, sc.db), FlagResourceResize, resource.TeamID)
+	if err != nil {
+		log.Printf("Error evaluating feature flag %s: %v", FlagResourceResize, err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to evaluate feature flags")
+		return
+	}
+	if !enabled {
+		apierrors.Abort(c, http.StatusForbidden, "feature_disabled", "Resizing is not enabled for this team")
+		return
+	}
+
+	if resource.LifecycleMode != "full" || resource.ResourceType == nil {
+		apierrors.Abort(c, http.StatusBadRequest, "not_managed", "Only full lifecycle resources can be resized")
+		return
+	}
+
+	classes, err := resourceSizeClasses(tenantDB(c, sc.db), resource.ResourceType)
+	if err != nil {
+		log.Printf("Error loading size classes: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to load size classes")
+		return
+	}
+
+	before := resourceConfig(&resource)
+	cfg := resourceConfig(&resource)
+	previous := findSizeClass(classes, resource.SizeClass)
+	if req.SizeClass == SizeClassCustom {
+		if err := validateQuantities(req.Resources); err != nil {
+			apierrors.Abort(c, http.StatusBadRequest, "invalid_size_class", err.Error())
+			return
+		}
+		requests := make(map[string]interface{}, len(req.Resources))
+		for name, value := range req.Resources {
+			if value != "" {
+				requests[name] = value
+			}
+		}
+		cfg[configKeyResources] = requests
+		cfg = applySizeClass(cfg, previous, nil)
+	} else {
+		class := findSizeClass(classes, req.SizeClass)
+		if class == nil {
+			apierrors.Abort(c, http.StatusBadRequest, "invalid_size_class", "Unknown size class for this resource type: "+req.SizeClass)
+			return
+		}
+		cfg = applySizeClass(cfg, previous, class)
+	}
+
+	raw, _ := json.Marshal(cfg)
+	resource.Config = datatypes.JSON(raw)
+	resource.SizeClass = req.SizeClass
+
+	var op *Operation
+	if !unitOfWork(c, sc.db, "Failed to resize resource", func(tx *gorm.DB) error {
+		if err := tx.Model(&Resource{}).Where("id = ?", resource.ID).Updates(map[string]interface{}{
+			"config":     resource.Config,
+			"size_class": resource.SizeClass,
+			"generation": nextGeneration,
+		}).Error; err != nil {
+			return err
+		}
+		var err error
+		if op, err = startOperation(tx, OperationScale, &resource, userID.(uint)); err != nil {
+			return err
+		}
+		_, err = queueReconcile(tx, resource.ID, userID.(uint))
+		return err
+	}) {
+		return
+	}
+	if acceptOperation(c, op) {
+		return
+	}
+
+	resp := resourceToResponse(&resource)
+	resp.RestartRequired = tuningRestartChanges(resource.ResourceType.Name, before, cfg)
+	c.JSON(http.StatusAccepted, resp)
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// IntegrationTypeSlack is a Slack app that runs /nest slash commands
+const IntegrationTypeSlack = "slack"
+
+// slackMaxSkew bounds the age of a signed Slack request, so that captured
+// requests can't be replayed
+const slackMaxSkew = 5 * time.Minute
+
+// slackListLimit bounds the resources /nest list replies with
+const slackListLimit = 50
+
+// SlackConfig is the Config document of a slack integration. Commands from
+// other workspaces than WorkspaceID are refused when it is set.
+type SlackConfig struct {
+	WorkspaceID string `json:"workspace_id"`
+	APIURL      string `json:"api_url"`
+}
+
+// SlackCredentials holds the secret Slack signs requests with and the bot
+// token replies are posted and users looked up with
+type SlackCredentials struct {
+	SigningSecret string `json:"signing_secret"`
+	BotToken      string `json:"bot_token"`
+}
+
+// slackClient calls the Slack Web API as the integration's bot
+type slackClient struct {
+	client *http.Client
+	apiURL string
+	token  string
+}
+
+// newSlackClient builds the client of a slack integration
+func newSlackClient(integration *Integration) (*slackClient, *SlackConfig, *SlackCredentials, error) {
+	var cfg SlackConfig
+	if err := json.Unmarshal(integration.Config, &cfg); err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid slack config: %w", err)
+	}
+	var creds SlackCredentials
+	if len(integration.Credentials) > 0 {
+		if err := json.Unmarshal(integration.Credentials, &creds); err != nil {
+			return nil, nil, nil, fmt.Errorf("invalid credentials: %w", err)
+		}
+	}
+	if creds.BotToken == "" {
+		return nil, nil, nil, errors.New("slack integrations require credentials.bot_token")
+	}
+
+	apiURL := strings.TrimSuffix(cfg.APIURL, "/")
+	if apiURL == "" {
+		apiURL = "https://slack.com/api"
+	}
+	return &slackClient{
+		client: &http.Client{Timeout: 10 * time.Second},
+		apiURL: apiURL,
+		token:  creds.BotToken,
+	}, &cfg, &creds, nil
+}
+
+// call invokes a Web API method. Slack reports errors in the body of
+// successful responses, so they are checked for too.
+func (s *slackClient) call(ctx context.Context, method string, body interface{}, out interface{}) error {
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.apiURL+"/"+method, bytes.NewReader(encoded))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("Authorization", "Bearer "+s.token)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("slack returned %d: %s", resp.StatusCode, bytes.TrimSpace(data))
+	}
+	var result struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	if !result.OK {
+		return fmt.Errorf("slack %s failed: %s", method, result.Error)
+	}
+	if out != nil {
+		if err := json.Unmarshal(data, out); err != nil {
+			return fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
+	return nil
+}
+
+// PostMessage posts text to a channel, in a thread when threadTS is set,
+// and returns the message's timestamp
+func (s *slackClient) PostMessage(ctx context.Context, channel, threadTS, text string) (string, error) {
+	body := map[string]interface{}{"channel": channel, "text": text}
+	if threadTS != "" {
+		body["thread_ts"] = threadTS
+	}
+	var result struct {
+		TS string `json:"ts"`
+	}
+	if err := s.call(ctx, "chat.postMessage", body, &result); err != nil {
+		return "", err
+	}
+	return result.TS, nil
+}
+
+// UserEmail returns the verified email address of a Slack user. It needs
+// the users:read.email scope.
+func (s *slackClient) UserEmail(ctx context.Context, userID string) (string, error) {
+	var result struct {
+		User struct {
+			Deleted bool `json:"deleted"`
+			IsBot   bool `json:"is_bot"`
+			Profile struct {
+				Email string `json:"email"`
+			} `json:"profile"`
+		} `json:"user"`
+	}
+	if err := s.call(ctx, "users.info", map[string]string{"user": userID}, &result); err != nil {
+		return "", err
+	}
+	if result.User.Deleted || result.User.IsBot || result.User.Profile.Email == "" {
+		return "", fmt.Errorf("slack user %s has no email address", userID)
+	}
+	return result.User.Profile.Email, nil
+}
+
+// verifySlackRequest checks a request was signed by Slack with the
+// integration's signing secret, and recently
+func verifySlackRequest(secret string, header http.Header, body []byte, now time.Time) bool {
+	if secret == "" {
+		return false
+	}
+	timestamp := header.Get("X-Slack-Request-Timestamp")
+	sent, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	if skew := now.Sub(time.Unix(sent, 0)); skew > slackMaxSkew || skew < -slackMaxSkew {
+		return false
+	}
+	expected := "v0=" + hex.EncodeToString(hmacSHA256([]byte(secret), "v0:"+timestamp+":"+string(body)))
+	return subtle.ConstantTimeCompare([]byte(header.Get("X-Slack-Signature")), []byte(expected)) == 1
+}
+
+// slackUsage is the reply to /nest help and to unknown commands
+const slackUsage = "Usage:\n" +
+	"• `/nest list` lists your teams' resources\n" +
+	"• `/nest status <resource>` shows a resource's status and last backup\n" +
+	"• `/nest backup run <resource>` queues a backup and posts its result in a thread\n" +
+	"A resource is its ID, its name, or <environment>/<name>."
+
+// slackResourceLine formats a resource for a reply
+func slackResourceLine(resource *Resource) string {
+	engine, team := "", ""
+	if resource.ResourceType != nil {
+		engine = resource.ResourceType.Name + ", "
+	}
+	if resource.Team != nil {
+		team = " (" + resource.Team.Name + ")"
+	}
+	return fmt.Sprintf("• `%s` #%d: %s%s, %s%s", resource.Name, resource.ID, engine, resource.Environment, resource.Status, team)
+}
+
+// slackJobResult formats the outcome of a finished backup job
+func slackJobResult(name, status, location, jobError string, size int64) string {
+	switch status {
+	case backupJobCompleted:
+		text := fmt.Sprintf(":white_check_mark: Backup of `%s` completed", name)
+		if size > 0 {
+			text += fmt.Sprintf(" (%d bytes)", size)
+		}
+		if location != "" {
+			text += ": " + location
+		}
+		return text
+	case backupJobFailed:
+		if jobError == "" {
+			jobError = "no error was reported"
+		}
+		return fmt.Sprintf(":x: Backup of `%s` failed: %s", name, jobError)
+	}
+	return fmt.Sprintf(":warning: Backup of `%s` was %s", name, status)
+}
+
+// SlackNotifier posts the results of backup jobs queued from Slack to the
+// threads they were announced in. It runs as a periodic job.
+type SlackNotifier struct {
+	db *gorm.DB
+}
+
+// NewSlackNotifier creates a new Slack notifier
+func NewSlackNotifier(db *gorm.DB) *SlackNotifier {
+	return &SlackNotifier{db: db}
+}
+
+// slackFinishedJob is a Slack thread whose backup job has finished
+type slackFinishedJob struct {
+	SlackBackupThread
+	ResourceName    string
+	Status          string
+	BackupLocation  string
+	BackupSizeBytes int64
+	ErrorMessage    string
+}
+
+// NotifyAll posts the result of every finished job not yet posted
+func (n *SlackNotifier) NotifyAll(ctx context.Context) error {
+	db := n.db.WithContext(ctx)
+	if !db.Migrator().HasTable("backup_jobs") {
+		return nil
+	}
+
+	var jobs []slackFinishedJob
+	if err := db.Raw(`SELECT t.*, r.name AS resource_name, j.status,
+			COALESCE(j.backup_location, '') AS backup_location,
+			COALESCE(j.backup_size_bytes, 0) AS backup_size_bytes,
+			COALESCE(j.error_message, '') AS error_message
+		FROM slack_backup_threads t
+		JOIN backup_jobs j ON j.id = t.job_id
+		JOIN resources r ON r.id = t.resource_id
+		WHERE t.notified_at IS NULL AND t.deleted_at IS NULL AND j.status NOT IN ?`,
+		[]string{backupJobPending, backupJobRunning}).Scan(&jobs).Error; err != nil {
+		return fmt.Errorf("failed to load finished Slack backup jobs: %w", err)
+	}
+
+	clients := map[uint]*slackClient{}
+	for i := range jobs {
+		job := &jobs[i]
+		client, ok := clients[job.IntegrationID]
+		if !ok {
+			var integration Integration
+			err := db.Where("id = ? AND deleted_at IS NULL", job.IntegrationID).First(&integration).Error
+			switch {
+			case err == nil:
+				if client, _, _, err = newSlackClient(&integration); err != nil {
+					log.Printf("Slack integration %d is misconfigured: %v", integration.ID, err)
+				}
+			case !errors.Is(err, gorm.ErrRecordNotFound):
+				log.Printf("Failed to load Slack integration %d: %v", job.IntegrationID, err)
+				continue
+			}
+			clients[job.IntegrationID] = client
+		}
+
+		// Results that can't be posted because the integration is gone are
+		// dropped, rather than retried forever
+		if client != nil {
+			postCtx, cancel := context.WithTimeout(ctx, 15*time.Second)
+			_, err := client.PostMessage(postCtx, job.Channel, job.ThreadTS,
+				slackJobResult(job.ResourceName, job.Status, job.BackupLocation, job.ErrorMessage, job.BackupSizeBytes))
+			cancel()
+			if err != nil {
+				log.Printf("Error posting the result of backup job %d to Slack: %v", job.JobID, err)
+				continue
+			}
+		}
+		if err := db.Model(&SlackBackupThread{}).Where("id = ?", job.ID).UpdateColumn("notified_at", time.Now()).Error; err != nil {
+			log.Printf("Error marking backup job %d posted to Slack: %v", job.JobID, err)
+		}
+	}
+	return nil
+}
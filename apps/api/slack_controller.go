@@ -0,0 +1,310 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/penguintechinc/project-template/shared/apierrors"
+	"github.com/penguintechinc/project-template/shared/audit"
+	"gorm.io/gorm"
+)
+
+// slackCommandTimeout bounds handling a command. Slack shows an error when
+// a command takes longer than three seconds to answer.
+const slackCommandTimeout = 2500 * time.Millisecond
+
+// slackCaller is the NEST user a slash command was run by, and their roles
+// in the teams the integration covers
+type slackCaller struct {
+	user  User
+	roles map[uint]string
+}
+
+// teamIDs returns the teams the caller can see resources of
+func (s *slackCaller) teamIDs() []uint {
+	ids := make([]uint, 0, len(s.roles))
+	for id := range s.roles {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// slackReply answers a slash command privately to the user who ran it
+func slackReply(c *gin.Context, text string) {
+	c.JSON(http.StatusOK, gin.H{"response_type": "ephemeral", "text": text})
+}
+
+// SlackCommand runs a /nest slash command sent by a slack integration's
+// app. The Slack user is matched to the active NEST user with the same
+// email address, and acts with their team roles.
+// POST /api/v1/integrations/:id/slack/commands
+func (ic *IntegrationController) SlackCommand(c *gin.Context) {
+	integration, ok := ic.loadIntegration(c)
+	if !ok {
+		return
+	}
+	if integration.Type != IntegrationTypeSlack {
+		apierrors.Abort(c, http.StatusBadRequest, "invalid_integration", "Only slack integrations receive commands")
+		return
+	}
+	client, cfg, creds, err := newSlackClient(integration)
+	if err != nil {
+		apierrors.Abort(c, http.StatusBadRequest, "invalid_integration", err.Error())
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, 64<<10))
+	if err != nil {
+		apierrors.Abort(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Invalid request body")
+		return
+	}
+	if !verifySlackRequest(creds.SigningSecret, c.Request.Header, body, time.Now()) {
+		apierrors.Abort(c, http.StatusUnauthorized, "invalid_signature", "Request signature is invalid")
+		return
+	}
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		apierrors.AbortWithDetails(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	if !integration.Enabled {
+		slackReply(c, "NEST commands are disabled for this workspace.")
+		return
+	}
+	if cfg.WorkspaceID != "" && form.Get("team_id") != cfg.WorkspaceID {
+		slackReply(c, "NEST commands aren't enabled for this workspace.")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), slackCommandTimeout)
+	defer cancel()
+
+	caller, reply := ic.slackCaller(ctx, c, integration, client, form.Get("user_id"))
+	if caller == nil {
+		slackReply(c, reply)
+		return
+	}
+
+	args := strings.Fields(form.Get("text"))
+	switch {
+	case len(args) == 1 && args[0] == "list":
+		reply = ic.slackList(ctx, c, caller)
+	case len(args) == 2 && args[0] == "status":
+		reply = ic.slackStatus(ctx, c, caller, args[1])
+	case len(args) == 3 && args[0] == "backup" && args[1] == "run":
+		reply = ic.slackBackup(ctx, c, integration, client, caller, form, args[2])
+	default:
+		reply = slackUsage
+	}
+	slackReply(c, reply)
+}
+
+// slackCaller finds the NEST user of a Slack user, and their team roles.
+// When the user can't run commands, a reply saying why is returned instead.
+func (ic *IntegrationController) slackCaller(ctx context.Context, c *gin.Context, integration *Integration, client *slackClient, slackUserID string) (*slackCaller, string) {
+	email, err := client.UserEmail(ctx, slackUserID)
+	if err != nil {
+		log.Printf("Error looking up Slack user %s of integration %d: %v", slackUserID, integration.ID, err)
+		return nil, "Your Slack account couldn't be looked up; ask an admin to check the app has the users:read.email scope."
+	}
+
+	caller := &slackCaller{}
+	if err := tenantDB(c, ic.db).WithContext(ctx).
+		Where("LOWER(email) = LOWER(?) AND is_active = ? AND deleted_at IS NULL", email, true).
+		First(&caller.user).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("Error retrieving user for Slack user %s: %v", slackUserID, err)
+			return nil, "NEST couldn't look up your account; try again later."
+		}
+		return nil, fmt.Sprintf("No active NEST user has the email address %s.", email)
+	}
+
+	roles, err := ic.access.TeamRoles(ctx, caller.user.ID)
+	if err != nil {
+		log.Printf("Error retrieving team roles of user %d: %v", caller.user.ID, err)
+		return nil, "NEST couldn't look up your teams; try again later."
+	}
+	caller.roles = roles
+	if integration.TeamID != nil {
+		caller.roles = map[uint]string{}
+		if role, ok := roles[*integration.TeamID]; ok {
+			caller.roles[*integration.TeamID] = role
+		}
+	}
+	if len(caller.roles) == 0 {
+		return nil, "You aren't a member of any team this workspace can reach."
+	}
+	return caller, ""
+}
+
+// slackList replies with the resources of the caller's teams
+func (ic *IntegrationController) slackList(ctx context.Context, c *gin.Context, caller *slackCaller) string {
+	var resources []*Resource
+	if err := tenantDB(c, ic.db).WithContext(ctx).
+		Where("team_id IN ? AND deleted_at IS NULL AND deletion_state = ''", caller.teamIDs()).
+		Preload("ResourceType").
+		Preload("Team").
+		Order("environment, name").
+		Limit(slackListLimit + 1).
+		Find(&resources).Error; err != nil {
+		log.Printf("Error listing resources for Slack user %d: %v", caller.user.ID, err)
+		return "NEST couldn't list your resources; try again later."
+	}
+	if len(resources) == 0 {
+		return "Your teams have no resources."
+	}
+
+	lines := []string{"Your teams' resources:"}
+	for i, resource := range resources {
+		if i == slackListLimit {
+			lines = append(lines, fmt.Sprintf("…and more; the first %d are shown.", slackListLimit))
+			break
+		}
+		lines = append(lines, slackResourceLine(resource))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// slackStatus replies with a resource's status and its last backup
+func (ic *IntegrationController) slackStatus(ctx context.Context, c *gin.Context, caller *slackCaller, ref string) string {
+	resource, reply := ic.slackResource(ctx, c, caller, ref)
+	if resource == nil {
+		return reply
+	}
+
+	lines := []string{slackResourceLine(resource)}
+	if resource.LastError != "" {
+		lines = append(lines, "Last error: "+resource.LastError)
+	}
+
+	db := tenantDB(c, ic.db).WithContext(ctx)
+	if db.Migrator().HasTable("backup_jobs") {
+		var jobs []struct {
+			ID        uint
+			Status    string
+			CreatedAt time.Time
+		}
+		if err := db.Raw(`SELECT id, status, created_at FROM backup_jobs
+			WHERE resource_id = ? AND job_type <> 'restore'
+			ORDER BY created_at DESC LIMIT 1`, resource.ID).Scan(&jobs).Error; err != nil {
+			log.Printf("Error retrieving backup jobs of resource %d: %v", resource.ID, err)
+		} else if len(jobs) == 1 {
+			lines = append(lines, fmt.Sprintf("Last backup: job #%d, %s, queued %s",
+				jobs[0].ID, jobs[0].Status, jobs[0].CreatedAt.UTC().Format(time.RFC3339)))
+		} else {
+			lines = append(lines, "Last backup: none")
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// slackBackup queues a backup of a resource for the manager or its agent
+// to run, and announces it in the channel. The job's result is posted to
+// the announcement's thread by the Slack notifier.
+func (ic *IntegrationController) slackBackup(ctx context.Context, c *gin.Context, integration *Integration, client *slackClient, caller *slackCaller, form url.Values, ref string) string {
+	resource, reply := ic.slackResource(ctx, c, caller, ref)
+	if resource == nil {
+		return reply
+	}
+	if !hasMinimumRole(caller.roles[resource.TeamID], "maintainer") {
+		return fmt.Sprintf("Backing up `%s` requires the maintainer role in its team.", resource.Name)
+	}
+	if !resource.CanBackup {
+		return fmt.Sprintf("`%s` doesn't support backups.", resource.Name)
+	}
+
+	db := tenantDB(c, ic.db).WithContext(ctx)
+	if !db.Migrator().HasTable("backup_jobs") {
+		return "Backups aren't available; the NEST manager hasn't been set up."
+	}
+
+	var jobID uint
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var queued []uint
+		if err := tx.Raw(`SELECT id FROM backup_jobs WHERE resource_id = ? AND status IN ? AND job_type <> 'restore' LIMIT 1`,
+			resource.ID, []string{backupJobPending, backupJobRunning}).Scan(&queued).Error; err != nil {
+			return err
+		}
+		if len(queued) > 0 {
+			jobID = queued[0]
+			return errBackupQueued
+		}
+		if err := tx.Raw(`INSERT INTO backup_jobs (resource_id, job_type, status, created_by, created_at)
+			VALUES (?, 'full', ?, ?, ?) RETURNING id`,
+			resource.ID, backupJobPending, caller.user.ID, time.Now().UTC()).Scan(&jobID).Error; err != nil {
+			return err
+		}
+		return audit.RecordAction(c, tx, caller.user.ID, audit.ActionBackup, "resources", resource.ID, &resource.TeamID,
+			map[string]interface{}{"job_id": jobID, "integration_id": integration.ID})
+	})
+	if errors.Is(err, errBackupQueued) {
+		return fmt.Sprintf("A backup of `%s` is already queued as job #%d.", resource.Name, jobID)
+	}
+	if err != nil {
+		log.Printf("Error queueing backup of resource %d from Slack: %v", resource.ID, err)
+		return "NEST couldn't queue the backup; try again later."
+	}
+
+	channel := form.Get("channel_id")
+	ts, err := client.PostMessage(ctx, channel, "",
+		fmt.Sprintf("<@%s> queued a backup of `%s` (job #%d). The result will be posted in this thread.",
+			form.Get("user_id"), resource.Name, jobID))
+	if err != nil {
+		log.Printf("Error announcing backup job %d in Slack channel %s: %v", jobID, channel, err)
+		return fmt.Sprintf("Queued a backup of `%s` as job #%d. Its result can't be posted here; invite the NEST app to this channel to follow backups.", resource.Name, jobID)
+	}
+
+	thread := &SlackBackupThread{IntegrationID: integration.ID, JobID: jobID, ResourceID: resource.ID, Channel: channel, ThreadTS: ts}
+	if err := db.Create(thread).Error; err != nil {
+		log.Printf("Error recording the Slack thread of backup job %d: %v", jobID, err)
+	}
+	return fmt.Sprintf("Queued a backup of `%s` as job #%d.", resource.Name, jobID)
+}
+
+// errBackupQueued stops queueing a backup of a resource with one queued
+var errBackupQueued = errors.New("a backup is already queued")
+
+// slackResource finds the resource a command names, among the caller's
+// teams' resources. When there is no single match, a reply saying so is
+// returned instead.
+func (ic *IntegrationController) slackResource(ctx context.Context, c *gin.Context, caller *slackCaller, ref string) (*Resource, string) {
+	query := tenantDB(c, ic.db).WithContext(ctx).
+		Where("team_id IN ? AND deleted_at IS NULL AND deletion_state = ''", caller.teamIDs()).
+		Preload("ResourceType").
+		Preload("Team")
+	if id, err := strconv.ParseUint(ref, 10, 32); err == nil {
+		query = query.Where("id = ?", id)
+	} else if environment, name, found := strings.Cut(ref, "/"); found {
+		query = query.Where("environment = ? AND name = ?", environment, name)
+	} else {
+		query = query.Where("name = ?", ref)
+	}
+
+	var resources []*Resource
+	if err := query.Order("id").Limit(5).Find(&resources).Error; err != nil {
+		log.Printf("Error retrieving resource %q for Slack user %d: %v", ref, caller.user.ID, err)
+		return nil, "NEST couldn't look up the resource; try again later."
+	}
+	switch len(resources) {
+	case 0:
+		return nil, fmt.Sprintf("None of your teams' resources is `%s`.", ref)
+	case 1:
+		return resources[0], ""
+	}
+
+	lines := []string{fmt.Sprintf("`%s` matches several resources; use an ID or <environment>/<name>:", ref)}
+	for _, resource := range resources {
+		lines = append(lines, slackResourceLine(resource))
+	}
+	return nil, strings.Join(lines, "\n")
+}
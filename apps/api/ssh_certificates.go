@@ -0,0 +1,92 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"regexp"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"gorm.io/gorm"
+)
+
+// SSH certificate lifetimes. Certificates are short-lived rather than
+// revocable, so access ends when they expire.
+const (
+	defaultSSHCertTTL = time.Hour
+	maxSSHCertTTL     = 8 * time.Hour
+
+	// sshClockSkew backdates certificates for hosts with slow clocks
+	sshClockSkew = 5 * time.Minute
+)
+
+// sshLoginPattern matches the local account names certificates are issued
+// for
+var sshLoginPattern = regexp.MustCompile(`^[a-z_][a-z0-9_-]{0,31}$`)
+
+// sshCA is an SSH CA from the manager's certificate_authorities table. Its
+// certificate is the CA's public key in authorized_keys format and its
+// private key is in OpenSSH format.
+type sshCA struct {
+	ID          uint
+	Certificate string
+	PrivateKey  string
+	TeamIDs     *string
+}
+
+// activeSSHCAs loads the active SSH CAs, newest first, when the manager has
+// created the CA tables
+func activeSSHCAs(db *gorm.DB) ([]sshCA, error) {
+	var cas []sshCA
+	if !db.Migrator().HasTable("certificate_authorities") {
+		return cas, nil
+	}
+	err := db.Table("certificate_authorities").Select("id, certificate, private_key, team_ids").
+		Where("deleted_at IS NULL AND status = ? AND type = ?", "active", "ssh").
+		Order("id DESC").Scan(&cas).Error
+	return cas, err
+}
+
+// sshPrincipal is the principal a certificate carries for a login on an
+// agent's host. Each host accepts only its own principals, through
+// AuthorizedPrincipalsCommand /bin/echo %u@<agent name>, so a certificate
+// is only good on the hosts it was issued for.
+func sshPrincipal(login, agentName string) string {
+	return login + "@" + agentName
+}
+
+// signSSHCertificate signs a user certificate for a public key, valid from
+// shortly before now until the TTL elapses
+func signSSHCertificate(ca *sshCA, key ssh.PublicKey, keyID string, principals []string, ttl time.Duration) (*ssh.Certificate, error) {
+	signer, err := ssh.ParsePrivateKey([]byte(ca.PrivateKey))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse SSH CA key: %w", err)
+	}
+
+	var serial [8]byte
+	if _, err := rand.Read(serial[:]); err != nil {
+		return nil, fmt.Errorf("failed to generate serial: %w", err)
+	}
+
+	now := time.Now().UTC()
+	cert := &ssh.Certificate{
+		Key:             key,
+		Serial:          binary.BigEndian.Uint64(serial[:]),
+		CertType:        ssh.UserCert,
+		KeyId:           keyID,
+		ValidPrincipals: principals,
+		ValidAfter:      uint64(now.Add(-sshClockSkew).Unix()),
+		ValidBefore:     uint64(now.Add(ttl).Unix()),
+		Permissions: ssh.Permissions{
+			Extensions: map[string]string{
+				"permit-pty":             "",
+				"permit-port-forwarding": "",
+			},
+		},
+	}
+	if err := cert.SignCert(rand.Reader, signer); err != nil {
+		return nil, fmt.Errorf("failed to sign certificate: %w", err)
+	}
+	return cert, nil
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/penguintechinc/project-template/shared/apierrors"
+	"github.com/penguintechinc/project-template/shared/audit"
+	"golang.org/x/crypto/ssh"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// IssueSSHCertificate signs a short-lived SSH user certificate for the
+// caller's public key, good for the requested logins on the host of an
+// agent-managed resource. Team maintainers may only request the logins on
+// the resource's SSH login allowlist; any other, and root, needs a team
+// admin. Every issuance is recorded and audited.
+// POST /api/v1/resources/:id/ssh-certificates
+func (rc *ResourceController) IssueSSHCertificate(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "User context not found")
+		return
+	}
+
+	resource, ok := rc.loadMemberResource(c, userID.(uint))
+	if !ok {
+		return
+	}
+
+	userRole, _ := c.Get("user_role")
+	teamRole, _, err := rc.access.TeamRole(c.Request.Context(), userID.(uint), resource.TeamID)
+	isAdmin := hasMinimumRole(userRole, "admin") || hasMinimumRole(teamRole, "admin")
+	if err != nil || (!isAdmin && !hasMinimumRole(teamRole, "maintainer")) {
+		apierrors.Abort(c, http.StatusForbidden, apierrors.CodeForbidden, "Insufficient permissions to request SSH certificates")
+		return
+	}
+
+	if resource.AgentID == nil {
+		apierrors.Abort(c, http.StatusBadRequest, "not_agent_managed", "SSH certificates are only issued for agent-managed resources")
+		return
+	}
+
+	var req IssueSSHCertificateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierrors.AbortWithDetails(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	ttl := defaultSSHCertTTL
+	if req.TTL != "" {
+		ttl, err = time.ParseDuration(req.TTL)
+		if err != nil || ttl <= 0 || ttl > maxSSHCertTTL {
+			apierrors.Abort(c, http.StatusBadRequest, "invalid_ttl", "TTL must be a positive duration of at most 8h")
+			return
+		}
+	}
+
+	key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(req.PublicKey))
+	if err != nil {
+		apierrors.Abort(c, http.StatusBadRequest, "invalid_public_key", "Public key must be in authorized_keys format")
+		return
+	}
+	if _, isCert := key.(*ssh.Certificate); isCert {
+		apierrors.Abort(c, http.StatusBadRequest, "invalid_public_key", "Public key must be in authorized_keys format")
+		return
+	}
+
+	db := tenantDB(c, rc.db)
+	var user User
+	if err := db.First(&user, userID).Error; err != nil {
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to load user")
+		return
+	}
+
+	logins := req.Logins
+	if len(logins) == 0 {
+		logins = []string{user.Username}
+	}
+	var allowlist []string
+	decodeJSONField(resource.SSHLogins, &allowlist, "ssh_logins")
+	allowed := make(map[string]bool, len(allowlist))
+	for _, login := range allowlist {
+		allowed[login] = true
+	}
+	for _, login := range logins {
+		if !sshLoginPattern.MatchString(login) {
+			apierrors.Abort(c, http.StatusBadRequest, "invalid_login", "Logins must be valid local account names")
+			return
+		}
+		if !isAdmin && (login == "root" || !allowed[login]) {
+			apierrors.Abort(c, http.StatusForbidden, "login_not_allowed", "Only team admins can request logins outside the resource's SSH login allowlist")
+			return
+		}
+	}
+
+	var agent Agent
+	if err := db.First(&agent, *resource.AgentID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierrors.Abort(c, http.StatusBadRequest, "not_agent_managed", "SSH certificates are only issued for agent-managed resources")
+		} else {
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to load agent")
+		}
+		return
+	}
+
+	cas, err := activeSSHCAs(db)
+	if err != nil {
+		log.Printf("Error loading SSH CAs: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to load SSH CAs")
+		return
+	}
+	var ca *sshCA
+	for i := range cas {
+		if caAllowsTeam(cas[i].TeamIDs, resource.TeamID) {
+			ca = &cas[i]
+			break
+		}
+	}
+	if ca == nil {
+		apierrors.Abort(c, http.StatusConflict, "no_ssh_ca", "No SSH CA issues certificates for this team")
+		return
+	}
+
+	principals := make([]string, 0, len(logins))
+	for _, login := range logins {
+		principals = append(principals, sshPrincipal(login, agent.Name))
+	}
+	keyID := fmt.Sprintf("nest:%s:resource-%d", user.Username, resource.ID)
+
+	cert, err := signSSHCertificate(ca, key, keyID, principals, ttl)
+	if err != nil {
+		log.Printf("Error signing SSH certificate for resource %d: %v", resource.ID, err)
+		apierrors.Abort(c, http.StatusInternalServerError, "ssh_signing_failed", "Failed to sign SSH certificate")
+		return
+	}
+
+	encodedPrincipals, _ := json.Marshal(principals)
+	record := &SSHCertificate{
+		ResourceID:  resource.ID,
+		TeamID:      resource.TeamID,
+		UserID:      user.ID,
+		CAID:        ca.ID,
+		Serial:      cert.Serial,
+		KeyID:       keyID,
+		Principals:  datatypes.JSON(encodedPrincipals),
+		Fingerprint: ssh.FingerprintSHA256(key),
+		ValidAfter:  time.Unix(int64(cert.ValidAfter), 0).UTC(),
+		ValidBefore: time.Unix(int64(cert.ValidBefore), 0).UTC(),
+	}
+
+	// The certificate is only handed out once its issuance is audited
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(record).Error; err != nil {
+			return err
+		}
+		return audit.RecordAction(c, tx, user.ID, audit.ActionIssue, "ssh_certificates", record.ID, &resource.TeamID, record)
+	})
+	if err != nil {
+		log.Printf("Error recording SSH certificate for resource %d: %v", resource.ID, err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to record SSH certificate")
+		return
+	}
+
+	c.JSON(http.StatusCreated, SSHCertificateResponse{
+		SSHCertificate: record,
+		Certificate:    string(ssh.MarshalAuthorizedKey(cert)),
+	})
+}
+
+// ListSSHCertificates retrieves the SSH certificates issued for a resource,
+// newest first
+// GET /api/v1/resources/:id/ssh-certificates
+func (rc *ResourceController) ListSSHCertificates(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "User context not found")
+		return
+	}
+
+	resource, ok := rc.loadMemberResource(c, userID.(uint))
+	if !ok {
+		return
+	}
+
+	var certs []*SSHCertificate
+	if err := tenantDB(c, rc.db).Where("resource_id = ?", resource.ID).Order("id DESC").Find(&certs).Error; err != nil {
+		log.Printf("Error listing SSH certificates of resource %d: %v", resource.ID, err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to list SSH certificates")
+		return
+	}
+
+	c.JSON(http.StatusOK, SSHCertificateListResponse{SSHCertificates: certs})
+}
+
+// GetSSHLogins retrieves the logins team maintainers may request SSH
+// certificates for on a resource's host
+// GET /api/v1/resources/:id/ssh-logins
+func (rc *ResourceController) GetSSHLogins(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "User context not found")
+		return
+	}
+
+	resource, ok := rc.loadMemberResource(c, userID.(uint))
+	if !ok {
+		return
+	}
+
+	logins := []string{}
+	decodeJSONField(resource.SSHLogins, &logins, "ssh_logins")
+	c.JSON(http.StatusOK, SSHLoginsResponse{Logins: logins})
+}
+
+// UpdateSSHLogins replaces the logins team maintainers may request SSH
+// certificates for on a resource's host. Only team admins may change them,
+// and root can't be allowed.
+// PUT /api/v1/resources/:id/ssh-logins
+func (rc *ResourceController) UpdateSSHLogins(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "User context not found")
+		return
+	}
+
+	resource, ok := rc.loadMemberResource(c, userID.(uint))
+	if !ok {
+		return
+	}
+
+	userRole, _ := c.Get("user_role")
+	teamRole, _, err := rc.access.TeamRole(c.Request.Context(), userID.(uint), resource.TeamID)
+	if err != nil || (!hasMinimumRole(userRole, "admin") && !hasMinimumRole(teamRole, "admin")) {
+		apierrors.Abort(c, http.StatusForbidden, apierrors.CodeForbidden, "Only team admins can change the SSH login allowlist")
+		return
+	}
+
+	var req UpdateSSHLoginsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierrors.AbortWithDetails(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Invalid request body", err.Error())
+		return
+	}
+	for _, login := range req.Logins {
+		if !sshLoginPattern.MatchString(login) {
+			apierrors.Abort(c, http.StatusBadRequest, "invalid_login", "Logins must be valid local account names")
+			return
+		}
+		if login == "root" {
+			apierrors.Abort(c, http.StatusBadRequest, "invalid_login", "root logins always need a team admin and can't be allowlisted")
+			return
+		}
+	}
+
+	before := *resource
+	encoded, _ := json.Marshal(req.Logins)
+	resource.SSHLogins = datatypes.JSON(encoded)
+	err = tenantDB(c, rc.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(resource).UpdateColumn("ssh_logins", resource.SSHLogins).Error; err != nil {
+			return err
+		}
+		return audit.Record(c, tx, userID.(uint), "resources", resource.ID, &resource.TeamID, &before, resource)
+	})
+	if err != nil {
+		log.Printf("Error updating SSH logins of resource %d: %v", resource.ID, err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to update SSH logins")
+		return
+	}
+
+	c.JSON(http.StatusOK, SSHLoginsResponse{Logins: req.Logins})
+}
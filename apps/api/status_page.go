@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/penguintechinc/project-template/shared/database"
+)
+
+// Overall platform states shown on the status page
+const (
+	PlatformOperational = "operational"
+	PlatformDegraded    = "degraded"
+	PlatformOutage      = "outage"
+)
+
+// statusPageCacheTTL is how long a rendered status page is served before it
+// is rebuilt, so the unauthenticated page can't be used to load the database
+const statusPageCacheTTL = 15 * time.Second
+
+// StatusPageConfig configures the public status page
+type StatusPageConfig struct {
+	Title string
+	// Refresh is how often browsers reload the page, for wall monitors
+	Refresh time.Duration
+	// ShowTeams lists resource availability per team; otherwise only the
+	// totals are shown, keeping team names private
+	ShowTeams bool
+	// ControllerStale is how long a controller may go without a heartbeat
+	// before it is considered down
+	ControllerStale time.Duration
+}
+
+// StatusPageController renders a read-only HTML page of the platform's
+// health for anyone who can reach it
+type StatusPageController struct {
+	db     *database.Database
+	config StatusPageConfig
+
+	mu         sync.Mutex
+	page       []byte
+	httpStatus int
+	renderedAt time.Time
+}
+
+// NewStatusPageController creates a new status page controller
+func NewStatusPageController(db *database.Database, config StatusPageConfig) *StatusPageController {
+	if config.Title == "" {
+		config.Title = "Nest Status"
+	}
+	return &StatusPageController{db: db, config: config}
+}
+
+// statusPageData is what the status page template renders
+type statusPageData struct {
+	Title          string
+	RefreshSeconds int
+	GeneratedAt    time.Time
+	Status         string
+	Database       bool
+	Replicas       map[string]bool
+	Controller     ControllerOverview
+	Totals         teamAvailability
+	Teams          []teamAvailability
+	ShowTeams      bool
+}
+
+// teamAvailability counts a team's live resources by whether they are
+// serving. Inactive resources are stopped on purpose and don't count
+// against availability.
+type teamAvailability struct {
+	Team      string
+	Total     int64
+	Available int64
+	Failing   int64
+	Changing  int64
+	Inactive  int64
+}
+
+// Percent is the share of a team's resources, other than inactive ones,
+// that are available
+func (t teamAvailability) Percent() float64 {
+	serving := t.Total - t.Inactive
+	if serving <= 0 {
+		return 100
+	}
+	return float64(t.Available) * 100 / float64(serving)
+}
+
+// GetStatusPage renders the status page. It answers 503 during an outage so
+// that uptime checks can watch it too.
+// GET /status
+func (sc *StatusPageController) GetStatusPage(c *gin.Context) {
+	page, status := sc.render(c.Request.Context())
+
+	// The page is self-contained, with only its inline styles
+	c.Header("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; frame-ancestors 'self'")
+	c.Header("Cache-Control", "no-store")
+	c.Data(status, "text/html; charset=utf-8", page)
+}
+
+// render returns the cached page, rebuilding it when it is older than
+// statusPageCacheTTL
+func (sc *StatusPageController) render(ctx context.Context) ([]byte, int) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if sc.page != nil && time.Since(sc.renderedAt) < statusPageCacheTTL {
+		return sc.page, sc.httpStatus
+	}
+
+	data := sc.snapshot(ctx)
+	var buf bytes.Buffer
+	if err := statusPageTemplate.Execute(&buf, data); err != nil {
+		log.Printf("Error rendering status page: %v", err)
+		return []byte("status page unavailable"), http.StatusInternalServerError
+	}
+
+	sc.page = buf.Bytes()
+	sc.httpStatus = http.StatusOK
+	if data.Status == PlatformOutage {
+		sc.httpStatus = http.StatusServiceUnavailable
+	}
+	sc.renderedAt = time.Now()
+	return sc.page, sc.httpStatus
+}
+
+// snapshot gathers the platform's health. A database that can't be queried
+// is an outage; a controller fleet without heartbeats or failing resources
+// leave the platform degraded.
+func (sc *StatusPageController) snapshot(ctx context.Context) statusPageData {
+	data := statusPageData{
+		Title:          sc.config.Title,
+		RefreshSeconds: int(sc.config.Refresh.Seconds()),
+		GeneratedAt:    time.Now().UTC(),
+		Status:         PlatformOperational,
+		Replicas:       sc.db.ReplicaStatus(),
+		ShowTeams:      sc.config.ShowTeams,
+	}
+
+	db := sc.db.DB.WithContext(ctx)
+	if sqlDB, err := database.UsePrimary(db).DB(); err == nil && sqlDB.PingContext(ctx) == nil {
+		data.Database = true
+	}
+	if !data.Database {
+		data.Status = PlatformOutage
+		return data
+	}
+
+	fleet, err := controllerFleet(db, sc.config.ControllerStale)
+	if err != nil {
+		log.Printf("Error loading controllers for status page: %v", err)
+	}
+	data.Controller = summarizeFleet(fleet)
+
+	if err := db.Raw(`SELECT t.name AS team, COUNT(*) AS total,
+			COUNT(*) FILTER (WHERE r.status = 'active') AS available,
+			COUNT(*) FILTER (WHERE r.status = 'error') AS failing,
+			COUNT(*) FILTER (WHERE r.status IN ('provisioning', 'deprovisioning')) AS changing,
+			COUNT(*) FILTER (WHERE r.status = 'inactive') AS inactive
+		FROM resources r
+		JOIN teams t ON t.id = r.team_id
+		WHERE r.deleted_at IS NULL
+		GROUP BY t.name ORDER BY t.name`).Scan(&data.Teams).Error; err != nil {
+		log.Printf("Error loading resource availability for status page: %v", err)
+	}
+	for _, team := range data.Teams {
+		data.Totals.Total += team.Total
+		data.Totals.Available += team.Available
+		data.Totals.Failing += team.Failing
+		data.Totals.Changing += team.Changing
+		data.Totals.Inactive += team.Inactive
+	}
+
+	if !data.Controller.Healthy || data.Totals.Failing > 0 {
+		data.Status = PlatformDegraded
+	}
+	return data
+}
+
+var statusPageTemplate = template.Must(template.New("status").Funcs(template.FuncMap{
+	"percent": func(v float64) string { return fmt.Sprintf("%.1f%%", v) },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+{{if .RefreshSeconds}}<meta http-equiv="refresh" content="{{.RefreshSeconds}}">{{end}}
+<title>{{.Title}}</title>
+<style>
+body { margin: 0; padding: 32px; font: 18px/1.5 system-ui, sans-serif; background: #102a43; color: #f0f4f8; }
+h1 { margin: 0 0 24px; font-size: 28px; }
+.banner { padding: 20px 24px; margin-bottom: 24px; font-size: 26px; font-weight: 600; border-radius: 6px; }
+.operational { background: #2e7d32; }
+.degraded { background: #b26a00; }
+.outage { background: #c62828; }
+.cards { display: grid; grid-template-columns: repeat(auto-fit, minmax(260px, 1fr)); gap: 16px; margin-bottom: 24px; }
+.card { padding: 16px 20px; background: #243b53; border-radius: 6px; }
+.card h2 { margin: 0 0 8px; font-size: 16px; color: #9fb3c8; font-weight: 500; }
+.card .value { font-size: 24px; font-weight: 600; }
+.ok { color: #81c784; } .warn { color: #ffb74d; } .bad { color: #e57373; }
+table { width: 100%; border-collapse: collapse; background: #243b53; border-radius: 6px; }
+th, td { padding: 10px 16px; text-align: left; border-bottom: 1px solid #334e68; }
+th { color: #9fb3c8; font-weight: 500; }
+footer { margin-top: 24px; color: #9fb3c8; font-size: 14px; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<div class="banner {{.Status}}">{{if eq .Status "operational"}}All systems operational{{else if eq .Status "degraded"}}Degraded performance{{else}}Major outage{{end}}</div>
+<div class="cards">
+  <div class="card"><h2>Database</h2><div class="value {{if .Database}}ok{{else}}bad{{end}}">{{if .Database}}Available{{else}}Unavailable{{end}}</div>
+  {{range $name, $up := .Replicas}}<div class="{{if $up}}ok{{else}}bad{{end}}">{{$name}}: {{if $up}}healthy{{else}}unhealthy{{end}}</div>{{end}}</div>
+  <div class="card"><h2>Controller</h2><div class="value {{if .Controller.Healthy}}ok{{else}}bad{{end}}">{{if .Controller.Healthy}}Running{{else}}No heartbeat{{end}}</div>
+  <div>{{.Controller.Instances}} instances{{if .Controller.Stale}}, {{.Controller.Stale}} stale{{end}}</div>
+  {{with .Controller.LastSeenAt}}<div>Last heartbeat {{.Format "2006-01-02 15:04:05 MST"}}</div>{{end}}</div>
+  <div class="card"><h2>Resource availability</h2><div class="value {{if .Totals.Failing}}warn{{else}}ok{{end}}">{{percent .Totals.Percent}}</div>
+  <div>{{.Totals.Available}} available, {{.Totals.Failing}} failing, {{.Totals.Changing}} changing</div></div>
+</div>
+{{if and .ShowTeams .Teams}}
+<table>
+<thead><tr><th>Team</th><th>Availability</th><th>Available</th><th>Failing</th><th>Changing</th><th>Inactive</th></tr></thead>
+<tbody>
+{{range .Teams}}<tr><td>{{.Team}}</td><td class="{{if .Failing}}warn{{else}}ok{{end}}">{{percent .Percent}}</td><td>{{.Available}}</td><td class="{{if .Failing}}bad{{end}}">{{.Failing}}</td><td>{{.Changing}}</td><td>{{.Inactive}}</td></tr>
+{{end}}</tbody>
+</table>
+{{end}}
+<footer>Updated {{.GeneratedAt.Format "2006-01-02 15:04:05 MST"}}</footer>
+</body>
+</html>
+`))
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/penguintechinc/project-template/shared/database"
+	"gorm.io/gorm"
+)
+
+// stuckResourceStatuses are the transitional statuses the K8s controller
+// moves full lifecycle resources out of once a reconcile finishes
+var stuckResourceStatuses = []string{"pending", "provisioning", "updating"}
+
+// ResourceWatchdog detects full lifecycle resources stuck in a transitional
+// status past a threshold, such as when the controller died mid-way through
+// provisioning. It queues a reconcile for a stuck resource up to maxRequeues
+// times, a threshold apart, and then marks the resource errored. The owning
+// team is notified when the resource is found stuck and when it is errored.
+type ResourceWatchdog struct {
+	db          *gorm.DB
+	threshold   time.Duration
+	maxRequeues int
+}
+
+// NewResourceWatchdog creates a new resource watchdog
+func NewResourceWatchdog(db *gorm.DB, threshold time.Duration, maxRequeues int) *ResourceWatchdog {
+	return &ResourceWatchdog{db: db, threshold: threshold, maxRequeues: maxRequeues}
+}
+
+// Check runs a single pass over the resources in a transitional status
+func (w *ResourceWatchdog) Check(ctx context.Context) error {
+	db := w.db.WithContext(ctx)
+
+	var resources []Resource
+	if err := db.Where("lifecycle_mode = ? AND status IN ? AND deletion_state = ''", "full", stuckResourceStatuses).
+		Find(&resources).Error; err != nil {
+		return fmt.Errorf("failed to list transitional resources: %w", err)
+	}
+
+	// Forget resources that have left their status
+	forget := db.Unscoped()
+	if len(resources) > 0 {
+		ids := make([]uint, len(resources))
+		for i := range resources {
+			ids[i] = resources[i].ID
+		}
+		forget = forget.Where("resource_id NOT IN ?", ids)
+	} else {
+		forget = forget.Where("1 = 1")
+	}
+	if err := forget.Delete(&StuckResource{}).Error; err != nil {
+		return fmt.Errorf("failed to clear recovered resources: %w", err)
+	}
+
+	var tracked []StuckResource
+	if err := db.Find(&tracked).Error; err != nil {
+		return fmt.Errorf("failed to load stuck resources: %w", err)
+	}
+	entries := make(map[uint]*StuckResource, len(tracked))
+	for i := range tracked {
+		entries[tracked[i].ResourceID] = &tracked[i]
+	}
+
+	now := time.Now().UTC()
+	for i := range resources {
+		resource := &resources[i]
+		entry, err := w.track(db, resource, entries[resource.ID])
+		if err != nil {
+			log.Printf("Failed to track resource %d in %s: %v", resource.ID, resource.Status, err)
+			continue
+		}
+		if now.Sub(entry.Since) < w.threshold {
+			continue
+		}
+		if err := w.remediate(db, resource, entry, now); err != nil {
+			log.Printf("Failed to remediate stuck resource %d: %v", resource.ID, err)
+		}
+	}
+	return nil
+}
+
+// track records when a resource was first seen in its status, starting over
+// when the status has changed since the last pass. The resource's last update
+// bounds when it entered the status.
+func (w *ResourceWatchdog) track(db *gorm.DB, resource *Resource, entry *StuckResource) (*StuckResource, error) {
+	if entry != nil && entry.Status == resource.Status {
+		return entry, nil
+	}
+	if entry == nil {
+		entry = &StuckResource{ResourceID: resource.ID}
+	}
+	entry.TeamID = resource.TeamID
+	entry.Status = resource.Status
+	entry.Since = resource.UpdatedAt.UTC()
+	entry.Requeues = 0
+	entry.RemediatedAt = nil
+	entry.AlertedAt = nil
+	return entry, db.Save(entry).Error
+}
+
+// remediate alerts the team of a stuck resource, then queues reconciles for
+// it until maxRequeues are spent, and finally marks it errored. A resource
+// whose provisioning job is still running is left to the job reaper.
+func (w *ResourceWatchdog) remediate(db *gorm.DB, resource *Resource, entry *StuckResource, now time.Time) error {
+	if db.Migrator().HasTable("provisioning_jobs") {
+		var running int64
+		if err := db.Table("provisioning_jobs").Where("resource_id = ? AND status IN ?", resource.ID,
+			[]string{provisioningJobRunning, provisioningJobCancelling}).Count(&running).Error; err != nil {
+			return err
+		}
+		if running > 0 {
+			return nil
+		}
+	}
+
+	if entry.AlertedAt == nil {
+		w.notify(db, resource, entry, "resource.stuck", "warning",
+			fmt.Sprintf("Resource %q has been %s since %s", resource.Name, entry.Status, entry.Since.Format(time.RFC3339)))
+		entry.AlertedAt = &now
+		if err := db.Model(entry).Update("alerted_at", now).Error; err != nil {
+			return err
+		}
+	}
+
+	if entry.RemediatedAt != nil && now.Sub(*entry.RemediatedAt) < w.threshold {
+		return nil
+	}
+
+	if entry.Requeues < w.maxRequeues {
+		if err := db.Transaction(func(tx *gorm.DB) error {
+			if _, err := queueReconcile(tx, resource.ID, 0); err != nil {
+				return err
+			}
+			return tx.Model(entry).Updates(map[string]interface{}{
+				"requeues":      entry.Requeues + 1,
+				"remediated_at": now,
+			}).Error
+		}); err != nil {
+			return err
+		}
+		log.Printf("Queued reconcile %d of %d for resource %d stuck in %s", entry.Requeues+1, w.maxRequeues,
+			resource.ID, entry.Status)
+		return nil
+	}
+
+	reason := fmt.Sprintf("Stuck in %s since %s", entry.Status, entry.Since.Format(time.RFC3339))
+	if entry.Requeues > 0 {
+		reason += fmt.Sprintf("; %d queued reconciles didn't finish it", entry.Requeues)
+	}
+	var status ReconcileStatus
+	if err := db.Where("resource_id = ?", resource.ID).First(&status).Error; err == nil && status.LastError != "" {
+		reason += ": " + status.LastError
+	} else if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	errored := false
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&Resource{}).Where("id = ? AND status = ?", resource.ID, entry.Status).
+			Updates(map[string]interface{}{
+				"status":        "error",
+				"last_error":    reason,
+				"last_error_at": now,
+			})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		errored = true
+		if err := failOperations(tx, reason, now, "resource_id = ?", resource.ID); err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(entry).Error
+	}); err != nil {
+		return err
+	}
+	if errored {
+		log.Printf("Marked stuck resource %d errored: %s", resource.ID, reason)
+		w.notify(db, resource, entry, "resource.stuck_error", "critical", reason)
+	}
+	return nil
+}
+
+// notify queues the delivery of a stuck resource notification to the
+// owning team
+func (w *ResourceWatchdog) notify(db *gorm.DB, resource *Resource, entry *StuckResource, event, severity, message string) {
+	n := Notification{
+		Event:      event,
+		Severity:   severity,
+		Title:      fmt.Sprintf("Resource %s is stuck in %s", resource.Name, entry.Status),
+		Message:    message,
+		TeamID:     resource.TeamID,
+		ResourceID: resource.ID,
+		Details: map[string]interface{}{
+			"status":   entry.Status,
+			"since":    entry.Since,
+			"requeues": entry.Requeues,
+		},
+		Timestamp: time.Now(),
+		Tenant:    database.TenantSchema(db.Statement.Context),
+	}
+
+	if _, err := EnqueueJob(db, JobRequest{Kind: JobNotification, Payload: n, Priority: JobPriorityHigh}); err != nil {
+		log.Printf("Failed to queue notification for stuck resource %d: %v", resource.ID, err)
+	}
+}
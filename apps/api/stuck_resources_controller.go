@@ -0,0 +1,39 @@
+package main
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/penguintechinc/project-template/shared/apierrors"
+	"gorm.io/gorm"
+)
+
+// StuckResourceController lets admins see the resources the watchdog found
+// stuck in a transitional status
+type StuckResourceController struct {
+	db *gorm.DB
+}
+
+// NewStuckResourceController creates a new stuck resource controller
+func NewStuckResourceController(db *gorm.DB) *StuckResourceController {
+	return &StuckResourceController{db: db}
+}
+
+// ListStuckResources lists the resources in a transitional status, longest
+// stuck first, with the watchdog's remediation so far
+// GET /api/v1/admin/stuck-resources
+func (sc *StuckResourceController) ListStuckResources(c *gin.Context) {
+	if !requireGlobalAdmin(c) {
+		return
+	}
+
+	stuck := []*StuckResource{}
+	if err := tenantDB(c, sc.db).Preload("Resource").Order("since").Find(&stuck).Error; err != nil {
+		log.Printf("Error listing stuck resources: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to list stuck resources")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"stuck_resources": stuck})
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Team deletion modes
+const (
+	// TeamDeletionBlock refuses to delete a team that still owns resources
+	TeamDeletionBlock = "block"
+	// TeamDeletionTransfer moves resources, alert rules, and integrations to
+	// another team before deleting
+	TeamDeletionTransfer = "transfer"
+	// TeamDeletionForce deletes and deprovisions every resource of the team
+	TeamDeletionForce = "force"
+)
+
+// Team deletion statuses
+const (
+	TeamDeletionDeprovisioning = "deprovisioning"
+	TeamDeletionCompleted      = "completed"
+	TeamDeletionFailed         = "failed"
+)
+
+// teamDependencies counts the rows that depend on a team
+func teamDependencies(db *gorm.DB, teamID uint) (*TeamDependenciesResponse, error) {
+	deps := &TeamDependenciesResponse{TeamID: teamID}
+	counts := []struct {
+		model interface{}
+		dest  *int64
+	}{
+		{&Resource{}, &deps.ActiveResources},
+		{&AlertRule{}, &deps.AlertRules},
+		{&Integration{}, &deps.Integrations},
+		{&TeamMember{}, &deps.Members},
+	}
+	for _, c := range counts {
+		if err := db.Model(c.model).Where("team_id = ?", teamID).Count(c.dest).Error; err != nil {
+			return nil, err
+		}
+	}
+	return deps, nil
+}
+
+// remainingTeamResources counts resources of a team that have not finished
+// deprovisioning
+func remainingTeamResources(db *gorm.DB, teamID uint) (int64, error) {
+	var remaining int64
+	err := db.Unscoped().Model(&Resource{}).
+		Where("team_id = ? AND deletion_state NOT IN ?", teamID,
+			[]string{DeletionStateDeprovisioned, DeletionStatePurged}).
+		Count(&remaining).Error
+	return remaining, err
+}
+
+// finalizeTeamDeletion removes the team's remaining configuration and the
+// team itself
+func finalizeTeamDeletion(tx *gorm.DB, teamID uint) error {
+	for _, model := range []interface{}{&TeamMember{}, &AlertRule{}, &Alert{}, &Integration{}, &ImageRegistry{}, &ContainerPolicy{}} {
+		if err := tx.Where("team_id = ?", teamID).Delete(model).Error; err != nil {
+			return err
+		}
+	}
+	return tx.Delete(&Team{}, teamID).Error
+}
+
+// TeamDeletionWorker completes force deletions once every resource of the
+// team has been deprovisioned by the K8s controller. It runs as a periodic
+// job.
+type TeamDeletionWorker struct {
+	db *gorm.DB
+}
+
+// NewTeamDeletionWorker creates a team deletion worker
+func NewTeamDeletionWorker(db *gorm.DB) *TeamDeletionWorker {
+	return &TeamDeletionWorker{db: db}
+}
+
+// Process advances every deletion that is waiting on deprovisioning
+func (w *TeamDeletionWorker) Process(ctx context.Context) error {
+	var deletions []TeamDeletion
+	if err := w.db.WithContext(ctx).Where("status = ?", TeamDeletionDeprovisioning).
+		Find(&deletions).Error; err != nil {
+		return fmt.Errorf("failed to load team deletions: %w", err)
+	}
+
+	for i := range deletions {
+		deletion := &deletions[i]
+
+		remaining, err := remainingTeamResources(w.db.WithContext(ctx), deletion.TeamID)
+		if err != nil {
+			log.Printf("Failed to check team %d deprovisioning: %v", deletion.TeamID, err)
+			continue
+		}
+
+		updates := map[string]interface{}{"remaining_resources": int(remaining)}
+		if remaining == 0 {
+			if err := w.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+				return finalizeTeamDeletion(tx, deletion.TeamID)
+			}); err != nil {
+				updates["status"] = TeamDeletionFailed
+				updates["error"] = err.Error()
+				log.Printf("Failed to finalize deletion of team %d: %v", deletion.TeamID, err)
+			} else {
+				updates["status"] = TeamDeletionCompleted
+				updates["completed_at"] = time.Now().UTC()
+				log.Printf("Team %d deleted after deprovisioning %d resources", deletion.TeamID, deletion.TotalResources)
+			}
+		}
+
+		w.db.WithContext(ctx).Model(deletion).Updates(updates)
+	}
+	return nil
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/penguintechinc/project-template/shared/apierrors"
+	"gorm.io/gorm"
+)
+
+// TeamDeletionController handles guided team deletion HTTP requests
+type TeamDeletionController struct {
+	db *gorm.DB
+}
+
+// NewTeamDeletionController creates a new team deletion controller
+func NewTeamDeletionController(db *gorm.DB) *TeamDeletionController {
+	return &TeamDeletionController{db: db}
+}
+
+// DeleteTeam deletes a team and handles the resources it owns. Without a mode
+// the request is refused while the team still owns resources; mode=transfer
+// moves them to the team given by transfer_to, and mode=force deletes them and
+// hands deprovisioning to the K8s controller.
+// DELETE /api/v1/teams/:id?mode=&transfer_to=
+func (tc *TeamDeletionController) DeleteTeam(c *gin.Context) {
+	if !requireGlobalAdmin(c) {
+		return
+	}
+	userID, _ := c.Get("user_id")
+
+	var team Team
+	if err := tenantDB(c, tc.db).First(&team, c.Param("id")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierrors.Abort(c, http.StatusNotFound, apierrors.CodeNotFound, "Team not found")
+		} else {
+			log.Printf("Error fetching team: %v", err)
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to fetch team")
+		}
+		return
+	}
+
+	if team.IsGlobal {
+		apierrors.Abort(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "The global team cannot be deleted")
+		return
+	}
+
+	mode := c.DefaultQuery("mode", TeamDeletionBlock)
+	if mode != TeamDeletionBlock && mode != TeamDeletionTransfer && mode != TeamDeletionForce {
+		apierrors.Abort(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "mode must be one of block, transfer, force")
+		return
+	}
+
+	deps, err := teamDependencies(tenantDB(c, tc.db), team.ID)
+	if err != nil {
+		log.Printf("Error counting team dependencies: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to check team dependencies")
+		return
+	}
+
+	deletion := &TeamDeletion{
+		TeamID:         team.ID,
+		TeamName:       team.Name,
+		Mode:           mode,
+		Status:         TeamDeletionCompleted,
+		TotalResources: int(deps.ActiveResources),
+		RequestedBy:    userID.(uint),
+	}
+
+	switch {
+	case deps.ActiveResources == 0:
+		err = tenantDB(c, tc.db).Transaction(func(tx *gorm.DB) error {
+			if err := finalizeTeamDeletion(tx, team.ID); err != nil {
+				return err
+			}
+			now := time.Now().UTC()
+			deletion.CompletedAt = &now
+			return tx.Create(deletion).Error
+		})
+
+	case mode == TeamDeletionBlock:
+		apierrors.AbortWithDetails(c, http.StatusConflict, "team_has_resources", "Team still owns resources; transfer them with mode=transfer or delete them with mode=force", gin.H{"dependencies": deps})
+		return
+
+	case mode == TeamDeletionTransfer:
+		target, ok := tc.transferTarget(c, team.ID)
+		if !ok {
+			return
+		}
+		deletion.TransferTeamID = &target.ID
+		err = tenantDB(c, tc.db).Transaction(func(tx *gorm.DB) error {
+			for _, model := range []interface{}{&Resource{}, &AlertRule{}, &Alert{}, &Integration{}, &ImageRegistry{}} {
+				if err := tx.Unscoped().Model(model).Where("team_id = ?", team.ID).
+					Update("team_id", target.ID).Error; err != nil {
+					return err
+				}
+			}
+			if err := finalizeTeamDeletion(tx, team.ID); err != nil {
+				return err
+			}
+			now := time.Now().UTC()
+			deletion.CompletedAt = &now
+			return tx.Create(deletion).Error
+		})
+
+	case mode == TeamDeletionForce:
+		var protected int64
+		if err := tenantDB(c, tc.db).Model(&Resource{}).Where("team_id = ? AND deletion_protection", team.ID).
+			Count(&protected).Error; err != nil {
+			log.Printf("Error checking deletion protection: %v", err)
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to check deletion protection")
+			return
+		}
+		if protected > 0 {
+			apierrors.Abort(c, http.StatusConflict, "deletion_protected", "Team owns resources with deletion protection enabled")
+			return
+		}
+
+		deletion.Status = TeamDeletionDeprovisioning
+		deletion.RemainingResources = deletion.TotalResources
+		err = tenantDB(c, tc.db).Transaction(func(tx *gorm.DB) error {
+			return tc.cascadeResources(tx, team.ID, deletion)
+		})
+	}
+
+	if err != nil {
+		log.Printf("Error deleting team %d: %v", team.ID, err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to delete team")
+		return
+	}
+
+	if deletion.Status == TeamDeletionDeprovisioning {
+		c.JSON(http.StatusAccepted, deletion)
+		return
+	}
+	c.JSON(http.StatusOK, deletion)
+}
+
+// transferTarget loads and validates the team named by transfer_to
+func (tc *TeamDeletionController) transferTarget(c *gin.Context, teamID uint) (*Team, bool) {
+	targetID, err := strconv.ParseUint(c.Query("transfer_to"), 10, 32)
+	if err != nil || uint(targetID) == teamID {
+		apierrors.Abort(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "transfer_to must reference a different team")
+		return nil, false
+	}
+
+	var target Team
+	if err := tenantDB(c, tc.db).First(&target, targetID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierrors.Abort(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Transfer team not found")
+		} else {
+			log.Printf("Error fetching transfer team: %v", err)
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to fetch transfer team")
+		}
+		return nil, false
+	}
+	return &target, true
+}
+
+// cascadeResources soft-deletes the team's resources straight into the
+// deleting state, skipping the restore window, and cancels their pending jobs
+func (tc *TeamDeletionController) cascadeResources(tx *gorm.DB, teamID uint, deletion *TeamDeletion) error {
+	var resourceIDs []uint
+	if err := tx.Model(&Resource{}).Where("team_id = ?", teamID).Pluck("id", &resourceIDs).Error; err != nil {
+		return err
+	}
+
+	// Resources already in the trash are deleted along with live ones
+	if err := tx.Unscoped().Model(&Resource{}).Where("team_id = ? AND deletion_state = ''", teamID).
+		Update("deletion_state", DeletionStateDeleting).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("team_id = ?", teamID).Delete(&Resource{}).Error; err != nil {
+		return err
+	}
+
+	// Provisioning jobs are owned by the manager; cancel any still queued
+	if len(resourceIDs) > 0 && tx.Migrator().HasTable("provisioning_jobs") {
+		if err := tx.Exec(
+			"UPDATE provisioning_jobs SET status = 'cancelled' WHERE resource_id IN ? AND status IN ('pending', 'running')",
+			resourceIDs,
+		).Error; err != nil {
+			return err
+		}
+	}
+	if err := tx.Model(&Operation{}).Where("team_id = ? AND status IN ?", teamID, []string{OperationPending, OperationRunning}).
+		Updates(map[string]interface{}{
+			"status":       OperationFailed,
+			"message":      "Cancelled by the deletion of the team",
+			"completed_at": time.Now().UTC(),
+		}).Error; err != nil {
+		return err
+	}
+
+	remaining, err := remainingTeamResources(tx, teamID)
+	if err != nil {
+		return err
+	}
+	deletion.RemainingResources = int(remaining)
+	return tx.Create(deletion).Error
+}
+
+// GetDeletionStatus returns the progress of the team's most recent deletion
+// GET /api/v1/teams/:id/deletion
+func (tc *TeamDeletionController) GetDeletionStatus(c *gin.Context) {
+	if !requireGlobalAdmin(c) {
+		return
+	}
+
+	var deletion TeamDeletion
+	if err := tenantDB(c, tc.db).Where("team_id = ?", c.Param("id")).
+		Order("created_at DESC").
+		First(&deletion).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierrors.Abort(c, http.StatusNotFound, apierrors.CodeNotFound, "No deletion found for team")
+		} else {
+			log.Printf("Error fetching team deletion: %v", err)
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to fetch team deletion")
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, deletion)
+}
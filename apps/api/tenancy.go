@@ -0,0 +1,333 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/penguintechinc/project-template/apps/api/models"
+	"github.com/penguintechinc/project-template/shared/apierrors"
+	"github.com/penguintechinc/project-template/shared/database"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// TenancyModeSchema isolates each tenant in its own Postgres schema. Tenancy
+// is off when TENANCY_MODE is unset.
+const TenancyModeSchema = "schema"
+
+// tenantSlugPattern restricts tenant slugs, which also name their schemas
+var tenantSlugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,30}$`)
+
+// tenantTables are the tables created in every tenant schema. All other
+// tables are shared through public.
+var tenantTables = []interface{}{
+	&User{},
+	&Team{},
+	&TeamMember{},
+	&Resource{},
+	&ResourceStats{},
+	&Agent{},
+	&DockerHost{},
+	&CloudAccount{},
+	&AdoptionCandidate{},
+	&SSHCertificate{},
+	&ConsumerBinding{},
+	&ResourceClaim{},
+	&BackstageToken{},
+	&GitSyncedResource{},
+	&SlackBackupThread{},
+	&Ticket{},
+	&TeamInvitation{},
+	&AlertRule{},
+	&Alert{},
+	&Integration{},
+	&EventExportCursor{},
+	&TeamDeletion{},
+	&Environment{},
+	&ReconcileRequest{},
+	&ReconcileStatus{},
+	&StuckResource{},
+	&ConsistencyReport{},
+	&CredentialUsage{},
+	&Operation{},
+	&ResourceLock{},
+	&ImageRegistry{},
+	&ContainerPolicy{},
+	&AuditAnchor{},
+	&ErasureRequest{},
+	&NetworkAccessRule{},
+	&UserPreference{},
+	&SavedView{},
+	&Announcement{},
+	&AnnouncementAck{},
+	&database.AuditLog{},
+	&database.Session{},
+}
+
+// tenantSchemaName returns the schema of a tenant slug
+func tenantSchemaName(slug string) string {
+	return "tenant_" + strings.ReplaceAll(slug, "-", "_")
+}
+
+// TenantRouter resolves the tenant of each request and hands handlers a
+// connection pool whose search_path starts at the tenant's schema. Pools
+// are opened on first use and kept for the life of the process.
+type TenantRouter struct {
+	db          *gorm.DB
+	config      *database.Config
+	cache       database.Cache
+	poolSize    int
+	rowSecurity bool
+
+	mu    sync.Mutex
+	pools map[string]*database.Database
+}
+
+// NewTenantRouter creates a tenant router. db holds the tenants table;
+// poolSize caps each tenant pool's open connections. With rowSecurity set,
+// tenant schemas get the same team scoping policies as public.
+func NewTenantRouter(db *gorm.DB, config *database.Config, cache database.Cache, poolSize int, rowSecurity bool) *TenantRouter {
+	return &TenantRouter{
+		db:          db,
+		config:      config,
+		cache:       cache,
+		poolSize:    poolSize,
+		rowSecurity: rowSecurity,
+		pools:       make(map[string]*database.Database),
+	}
+}
+
+// Pool returns the connection pool of a tenant schema, opening it if needed
+func (tr *TenantRouter) Pool(schema string) (*gorm.DB, error) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+
+	if pool, ok := tr.pools[schema]; ok {
+		return pool.DB, nil
+	}
+
+	config, err := database.SchemaConfig(tr.config, schema, tr.poolSize)
+	if err != nil {
+		return nil, err
+	}
+	pool, err := database.New(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open pool for tenant schema %s: %w", schema, err)
+	}
+	if tr.cache != nil {
+		if err := database.RegisterCacheInvalidation(pool.DB, tr.cache, cacheInvalidationTables); err != nil {
+			pool.Close()
+			return nil, fmt.Errorf("failed to register cache invalidation: %w", err)
+		}
+	}
+	tr.pools[schema] = pool
+	return pool.DB, nil
+}
+
+// Provision creates a tenant's schema and its tables
+func (tr *TenantRouter) Provision(ctx context.Context, tenant *Tenant) error {
+	if !database.ValidSchemaName(tenant.Schema) {
+		return fmt.Errorf("invalid tenant schema name %q", tenant.Schema)
+	}
+	if err := tr.db.WithContext(ctx).Exec(fmt.Sprintf(`CREATE SCHEMA IF NOT EXISTS "%s"`, tenant.Schema)).Error; err != nil {
+		return fmt.Errorf("failed to create schema: %w", err)
+	}
+
+	pool, err := tr.Pool(tenant.Schema)
+	if err != nil {
+		return err
+	}
+	if err := pool.WithContext(ctx).AutoMigrate(tenantTables...); err != nil {
+		return fmt.Errorf("failed to migrate tenant schema: %w", err)
+	}
+	if err := EnableAuditChain(ctx, pool); err != nil {
+		return err
+	}
+	if tr.rowSecurity {
+		return EnableRowSecurity(ctx, pool)
+	}
+	return nil
+}
+
+// MigrateAll brings every tenant schema up to date with tenantTables
+func (tr *TenantRouter) MigrateAll(ctx context.Context) error {
+	var tenants []Tenant
+	if err := tr.db.WithContext(ctx).Find(&tenants).Error; err != nil {
+		return err
+	}
+	for i := range tenants {
+		if err := tr.Provision(ctx, &tenants[i]); err != nil {
+			return fmt.Errorf("tenant %s: %w", tenants[i].Slug, err)
+		}
+	}
+	return nil
+}
+
+// perTenant runs a background worker's pass over public and then over every
+// tenant schema, the way AuditAnchorer.AnchorAll does, since alert rules,
+// integrations, tickets and the like are created in each tenant's schema.
+// newPass builds a schema's worker on its pool the first time it is needed,
+// and its passes run with the tenant in their context. A tenant whose pass
+// fails is logged so that it doesn't hold up the others; public's error is
+// returned. Without tenancy only public is processed.
+func perTenant(db *gorm.DB, tenants *TenantRouter, newPass func(db *gorm.DB) func(ctx context.Context) error) func(ctx context.Context) error {
+	public := newPass(db)
+	if tenants == nil {
+		return public
+	}
+
+	var mu sync.Mutex
+	passes := make(map[string]func(ctx context.Context) error)
+	return func(ctx context.Context) error {
+		err := public(ctx)
+
+		var list []Tenant
+		if err := db.WithContext(ctx).Find(&list).Error; err != nil {
+			return fmt.Errorf("failed to load tenants: %w", err)
+		}
+		for _, tenant := range list {
+			pool, poolErr := tenants.Pool(tenant.Schema)
+			if poolErr != nil {
+				log.Printf("Skipping background pass of tenant %s: %v", tenant.Slug, poolErr)
+				continue
+			}
+			mu.Lock()
+			pass, ok := passes[tenant.Schema]
+			if !ok {
+				pass = newPass(pool)
+				passes[tenant.Schema] = pass
+			}
+			mu.Unlock()
+
+			if passErr := pass(database.WithTenantDB(ctx, tenant.Schema, pool)); passErr != nil {
+				log.Printf("Background pass of tenant %s failed: %v", tenant.Slug, passErr)
+			}
+		}
+		return err
+	}
+}
+
+// Close closes every tenant pool
+func (tr *TenantRouter) Close() {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	for schema, pool := range tr.pools {
+		if err := pool.Close(); err != nil {
+			log.Printf("Failed to close pool for tenant schema %s: %v", schema, err)
+		}
+		delete(tr.pools, schema)
+	}
+}
+
+// resolve returns the tenant a request belongs to, or nil for platform
+// requests. A token's tenant claim must agree with the tenant of the host.
+func (tr *TenantRouter) resolve(c *gin.Context) (*Tenant, error) {
+	host := c.Request.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+
+	var byHost *Tenant
+	if host != "" {
+		hosts, _ := json.Marshal([]string{host})
+		var tenant Tenant
+		err := tr.db.WithContext(c.Request.Context()).Where("hosts @> ?", datatypes.JSON(hosts)).First(&tenant).Error
+		if err == nil {
+			byHost = &tenant
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+	}
+
+	claimed := ""
+	if value, exists := c.Get("user"); exists {
+		if claims, ok := value.(*models.UserClaims); ok {
+			claimed = claims.Tenant
+		}
+	}
+	if claimed == "" {
+		return byHost, nil
+	}
+	if byHost != nil {
+		if byHost.Slug != claimed {
+			return nil, errTenantMismatch
+		}
+		return byHost, nil
+	}
+
+	var tenant Tenant
+	if err := tr.db.WithContext(c.Request.Context()).Where("slug = ?", claimed).First(&tenant).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errTenantMismatch
+		}
+		return nil, err
+	}
+	return &tenant, nil
+}
+
+// errTenantMismatch is returned when a token belongs to a different tenant
+// than the host it was presented to
+var errTenantMismatch = errors.New("token does not belong to this tenant")
+
+// Middleware scopes each request to its tenant. Handlers reach the tenant's
+// schema through tenantDB; requests without a tenant are platform requests
+// and use the shared schema.
+func (tr *TenantRouter) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tenant, err := tr.resolve(c)
+		if err != nil {
+			if errors.Is(err, errTenantMismatch) {
+				apierrors.Abort(c, http.StatusForbidden, "tenant_mismatch", err.Error())
+				return
+			}
+			log.Printf("Error resolving tenant: %v", err)
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to resolve tenant")
+			return
+		}
+		if tenant == nil {
+			c.Next()
+			return
+		}
+
+		pool, err := tr.Pool(tenant.Schema)
+		if err != nil {
+			log.Printf("Error opening tenant pool: %v", err)
+			apierrors.Abort(c, http.StatusServiceUnavailable, "tenant_unavailable", "Tenant database is unavailable")
+			return
+		}
+
+		c.Set("tenant_id", tenant.ID)
+		c.Request = c.Request.WithContext(database.WithTenantDB(c.Request.Context(), tenant.Schema, pool))
+		c.Next()
+	}
+}
+
+// tenantDB returns the database a handler should query: the request
+// tenant's schema, or db for platform requests
+func tenantDB(c *gin.Context, db *gorm.DB) *gorm.DB {
+	return database.TenantDB(c.Request.Context(), db)
+}
+
+// requirePlatformAdmin verifies the current user is a global admin outside
+// any tenant, for changes to state shared by every tenant. Admins inside a
+// tenant only administer that tenant.
+func requirePlatformAdmin(c *gin.Context) bool {
+	if !requireGlobalAdmin(c) {
+		return false
+	}
+	if _, scoped := c.Get("tenant_id"); scoped {
+		apierrors.Abort(c, http.StatusForbidden, apierrors.CodeForbidden, "Platform admin access required")
+		return false
+	}
+	return true
+}
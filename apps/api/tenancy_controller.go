@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/penguintechinc/project-template/shared/apierrors"
+	"github.com/penguintechinc/project-template/shared/locks"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// TenancyController handles tenant HTTP requests
+type TenancyController struct {
+	db     *gorm.DB
+	router *TenantRouter
+}
+
+// NewTenancyController creates a new tenancy controller
+func NewTenancyController(db *gorm.DB, router *TenantRouter) *TenancyController {
+	return &TenancyController{db: db, router: router}
+}
+
+// ListTenants retrieves all tenants
+// GET /api/v1/admin/tenants
+func (tc *TenancyController) ListTenants(c *gin.Context) {
+	if !requirePlatformAdmin(c) {
+		return
+	}
+
+	var tenants []Tenant
+	if err := tc.db.Order("slug ASC").Find(&tenants).Error; err != nil {
+		log.Printf("Error listing tenants: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to list tenants")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tenants": tenants})
+}
+
+// CreateTenant creates a tenant and provisions its schema. The tenant's
+// first admin is created in its schema by signing up through one of its
+// hosts.
+// POST /api/v1/admin/tenants
+func (tc *TenancyController) CreateTenant(c *gin.Context) {
+	if !requirePlatformAdmin(c) {
+		return
+	}
+
+	var req CreateTenantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierrors.AbortWithDetails(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Invalid request body", err.Error())
+		return
+	}
+	if !tenantSlugPattern.MatchString(req.Slug) {
+		apierrors.Abort(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Tenant slug must be 2-31 lowercase letters, digits, or hyphens")
+		return
+	}
+
+	hosts := make([]string, 0, len(req.Hosts))
+	for _, host := range req.Hosts {
+		if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+	for _, host := range hosts {
+		raw, _ := json.Marshal([]string{host})
+		var count int64
+		if err := tc.db.Model(&Tenant{}).Where("hosts @> ?", datatypes.JSON(raw)).Count(&count).Error; err != nil {
+			log.Printf("Error checking tenant hosts: %v", err)
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to check tenant hosts")
+			return
+		}
+		if count > 0 {
+			apierrors.Abort(c, http.StatusConflict, "host_taken", "Host already belongs to another tenant: "+host)
+			return
+		}
+	}
+
+	rawHosts, _ := json.Marshal(hosts)
+	tenant := Tenant{
+		Name:   req.Name,
+		Slug:   req.Slug,
+		Hosts:  datatypes.JSON(rawHosts),
+		Schema: tenantSchemaName(req.Slug),
+	}
+
+	// The slug stays locked while its schema is provisioned, so two
+	// replicas can't provision the same schema
+	err := tc.db.Transaction(func(tx *gorm.DB) error {
+		if err := locks.Lock(tx, locks.Key("tenant-slug", req.Slug)); err != nil {
+			return err
+		}
+		var count int64
+		if err := tx.Unscoped().Model(&Tenant{}).Where("slug = ?", req.Slug).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return errTenantExists
+		}
+		if err := tc.router.Provision(c.Request.Context(), &tenant); err != nil {
+			return fmt.Errorf("%w: %v", errProvisioningFailed, err)
+		}
+		return tx.Create(&tenant).Error
+	})
+	if errors.Is(err, errTenantExists) {
+		apierrors.Abort(c, http.StatusConflict, "tenant_exists", "A tenant with this slug already exists")
+		return
+	}
+	if errors.Is(err, errProvisioningFailed) {
+		log.Printf("Error provisioning tenant %s: %v", tenant.Slug, err)
+		apierrors.Abort(c, http.StatusInternalServerError, "provisioning_failed", "Failed to provision tenant schema")
+		return
+	}
+	if err != nil {
+		log.Printf("Error creating tenant: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to create tenant")
+		return
+	}
+
+	c.JSON(http.StatusCreated, tenant)
+}
+
+var (
+	// errTenantExists is returned when a tenant, live or deleted, has the slug
+	errTenantExists = errors.New("tenant slug already exists")
+	// errProvisioningFailed wraps a failure to provision a tenant's schema
+	errProvisioningFailed = errors.New("tenant provisioning failed")
+)
@@ -0,0 +1,668 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// Ticketing integration types, which open issues for approvals and
+// problems that need a person
+const (
+	IntegrationTypeJira       = "jira"
+	IntegrationTypeServiceNow = "servicenow"
+)
+
+// Ticket kinds, which a ticketing integration's config.events selects
+const (
+	ticketKindApproval   = "approval"
+	ticketKindJobFailure = "job_failure"
+	ticketKindCompliance = "compliance"
+)
+
+// Ticket statuses. An open ticket becomes approved or rejected when its
+// issue reaches one of the integration's approved or rejected statuses; a
+// ticket that isn't an approval is closed instead.
+const (
+	ticketOpen     = "open"
+	ticketApproved = "approved"
+	ticketRejected = "rejected"
+	ticketClosed   = "closed"
+)
+
+// ticketJobLookback bounds how far back failed jobs are ticketed, so that
+// a new integration doesn't open tickets for old failures
+const ticketJobLookback = 24 * time.Hour
+
+// Default statuses of an issue that approve or reject it, compared without
+// case
+var (
+	defaultJiraApproved       = []string{"approved", "done"}
+	defaultJiraRejected       = []string{"rejected", "declined", "won't do"}
+	defaultServiceNowApproved = []string{"approved", "resolved", "closed"}
+	defaultServiceNowRejected = []string{"rejected", "canceled", "cancelled"}
+)
+
+// TicketConfig is the Config document of a jira or servicenow integration.
+// Events lists the ticket kinds opened, all of them when empty.
+type TicketConfig struct {
+	URL              string   `json:"url"`
+	ProjectKey       string   `json:"project_key"` // jira
+	IssueType        string   `json:"issue_type"`  // jira, default Task
+	Table            string   `json:"table"`       // servicenow, default incident
+	Events           []string `json:"events"`
+	ApprovedStatuses []string `json:"approved_statuses"`
+	RejectedStatuses []string `json:"rejected_statuses"`
+}
+
+// TicketCredentials holds a Jira Cloud account's email and API token, a
+// Jira Data Center personal access token, or a ServiceNow user's username
+// and password
+type TicketCredentials struct {
+	Email    string `json:"email"`
+	APIToken string `json:"api_token"`
+	Token    string `json:"token"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// TicketPromotion is the Operation of an approval ticket for a promotion:
+// the config the target resource gets once approved
+type TicketPromotion struct {
+	SourceResourceID  uint                   `json:"source_resource_id"`
+	TargetEnvironment string                 `json:"target_environment"`
+	Config            map[string]interface{} `json:"config"`
+}
+
+// ticketProvider opens issues on a ticketing system and reads their status
+type ticketProvider interface {
+	Create(ctx context.Context, title, description string, labels []string) (key, link string, err error)
+	Status(ctx context.Context, key string) (string, error)
+	Check(ctx context.Context) error
+}
+
+// wants reports whether the integration opens tickets of a kind
+func (cfg *TicketConfig) wants(kind string) bool {
+	if len(cfg.Events) == 0 {
+		return true
+	}
+	for _, event := range cfg.Events {
+		if event == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// newTicketProvider builds the provider of a ticketing integration
+func newTicketProvider(integration *Integration) (ticketProvider, *TicketConfig, error) {
+	var cfg TicketConfig
+	if err := json.Unmarshal(integration.Config, &cfg); err != nil {
+		return nil, nil, fmt.Errorf("invalid ticketing config: %w", err)
+	}
+	var creds TicketCredentials
+	if len(integration.Credentials) > 0 {
+		if err := json.Unmarshal(integration.Credentials, &creds); err != nil {
+			return nil, nil, fmt.Errorf("invalid credentials: %w", err)
+		}
+	}
+	client := &http.Client{Timeout: 15 * time.Second}
+	baseURL := strings.TrimSuffix(cfg.URL, "/")
+
+	switch integration.Type {
+	case IntegrationTypeJira:
+		if (creds.Email == "" || creds.APIToken == "") && creds.Token == "" {
+			return nil, nil, errors.New("jira integrations require credentials.email and credentials.api_token, or credentials.token")
+		}
+		if len(cfg.ApprovedStatuses) == 0 {
+			cfg.ApprovedStatuses = defaultJiraApproved
+		}
+		if len(cfg.RejectedStatuses) == 0 {
+			cfg.RejectedStatuses = defaultJiraRejected
+		}
+		if cfg.IssueType == "" {
+			cfg.IssueType = "Task"
+		}
+		return &jiraProvider{client: client, baseURL: baseURL, creds: &creds, cfg: &cfg}, &cfg, nil
+	case IntegrationTypeServiceNow:
+		if creds.Username == "" || creds.Password == "" {
+			return nil, nil, errors.New("servicenow integrations require credentials.username and credentials.password")
+		}
+		if len(cfg.ApprovedStatuses) == 0 {
+			cfg.ApprovedStatuses = defaultServiceNowApproved
+		}
+		if len(cfg.RejectedStatuses) == 0 {
+			cfg.RejectedStatuses = defaultServiceNowRejected
+		}
+		if cfg.Table == "" {
+			cfg.Table = "incident"
+		}
+		return &serviceNowProvider{client: client, baseURL: baseURL, creds: &creds, cfg: &cfg}, &cfg, nil
+	}
+	return nil, nil, fmt.Errorf("integration type %q is not a ticketing integration", integration.Type)
+}
+
+// ticketRequest sends a JSON request to a ticketing system, authorized by
+// authorize, and decodes the response into out
+func ticketRequest(ctx context.Context, client *http.Client, method, endpoint string, authorize func(*http.Request), body interface{}, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request: %w", err)
+		}
+		reader = bytes.NewReader(encoded)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	authorize(req)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("ticketing system returned %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	if out != nil {
+		if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(out); err != nil {
+			return fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
+	return nil
+}
+
+// jiraProvider opens issues through the Jira REST API v2, which both Jira
+// Cloud and Data Center serve
+type jiraProvider struct {
+	client  *http.Client
+	baseURL string
+	creds   *TicketCredentials
+	cfg     *TicketConfig
+}
+
+// authorize adds the account's API token, or the personal access token
+func (j *jiraProvider) authorize(req *http.Request) {
+	if j.creds.Email != "" && j.creds.APIToken != "" {
+		req.SetBasicAuth(j.creds.Email, j.creds.APIToken)
+	} else {
+		req.Header.Set("Authorization", "Bearer "+j.creds.Token)
+	}
+}
+
+// Create opens an issue in the integration's project
+func (j *jiraProvider) Create(ctx context.Context, title, description string, labels []string) (string, string, error) {
+	body := map[string]interface{}{
+		"fields": map[string]interface{}{
+			"project":     map[string]string{"key": j.cfg.ProjectKey},
+			"issuetype":   map[string]string{"name": j.cfg.IssueType},
+			"summary":     truncate(title, 255),
+			"description": description,
+			"labels":      labels,
+		},
+	}
+	var result struct {
+		Key string `json:"key"`
+	}
+	if err := ticketRequest(ctx, j.client, http.MethodPost, j.baseURL+"/rest/api/2/issue", j.authorize, body, &result); err != nil {
+		return "", "", err
+	}
+	return result.Key, j.baseURL + "/browse/" + result.Key, nil
+}
+
+// Status returns the name of an issue's status
+func (j *jiraProvider) Status(ctx context.Context, key string) (string, error) {
+	var result struct {
+		Fields struct {
+			Status struct {
+				Name string `json:"name"`
+			} `json:"status"`
+		} `json:"fields"`
+	}
+	endpoint := j.baseURL + "/rest/api/2/issue/" + url.PathEscape(key) + "?fields=status"
+	if err := ticketRequest(ctx, j.client, http.MethodGet, endpoint, j.authorize, nil, &result); err != nil {
+		return "", err
+	}
+	return result.Fields.Status.Name, nil
+}
+
+// Check verifies the credentials by reading the authenticated user
+func (j *jiraProvider) Check(ctx context.Context) error {
+	return ticketRequest(ctx, j.client, http.MethodGet, j.baseURL+"/rest/api/2/myself", j.authorize, nil, nil)
+}
+
+// serviceNowProvider opens records through the ServiceNow Table API
+type serviceNowProvider struct {
+	client  *http.Client
+	baseURL string
+	creds   *TicketCredentials
+	cfg     *TicketConfig
+}
+
+// authorize adds the user's credentials
+func (s *serviceNowProvider) authorize(req *http.Request) {
+	req.SetBasicAuth(s.creds.Username, s.creds.Password)
+}
+
+// Create opens a record in the integration's table, keyed by its sys_id
+func (s *serviceNowProvider) Create(ctx context.Context, title, description string, labels []string) (string, string, error) {
+	body := map[string]string{
+		"short_description": truncate(title, 160),
+		"description":       description,
+		"correlation_id":    strings.Join(labels, ","),
+	}
+	var result struct {
+		Result struct {
+			SysID string `json:"sys_id"`
+		} `json:"result"`
+	}
+	endpoint := s.baseURL + "/api/now/table/" + url.PathEscape(s.cfg.Table)
+	if err := ticketRequest(ctx, s.client, http.MethodPost, endpoint, s.authorize, body, &result); err != nil {
+		return "", "", err
+	}
+	link := s.baseURL + "/nav_to.do?uri=" + url.QueryEscape(s.cfg.Table+".do?sys_id="+result.Result.SysID)
+	return result.Result.SysID, link, nil
+}
+
+// Status returns a record's approval, once approved or rejected, and its
+// state otherwise
+func (s *serviceNowProvider) Status(ctx context.Context, key string) (string, error) {
+	var result struct {
+		Result struct {
+			State    string `json:"state"`
+			Approval string `json:"approval"`
+		} `json:"result"`
+	}
+	endpoint := s.baseURL + "/api/now/table/" + url.PathEscape(s.cfg.Table) + "/" + url.PathEscape(key) +
+		"?sysparm_fields=state,approval&sysparm_display_value=true"
+	if err := ticketRequest(ctx, s.client, http.MethodGet, endpoint, s.authorize, nil, &result); err != nil {
+		return "", err
+	}
+	if approval := strings.ToLower(result.Result.Approval); approval == "approved" || approval == "rejected" {
+		return result.Result.Approval, nil
+	}
+	return result.Result.State, nil
+}
+
+// Check verifies the credentials by reading from the integration's table
+func (s *serviceNowProvider) Check(ctx context.Context) error {
+	endpoint := s.baseURL + "/api/now/table/" + url.PathEscape(s.cfg.Table) + "?sysparm_limit=1&sysparm_fields=sys_id"
+	return ticketRequest(ctx, s.client, http.MethodGet, endpoint, s.authorize, nil, nil)
+}
+
+// ticketIntegrationFor returns the enabled ticketing integration that
+// opens a team's tickets of a kind: the team's own, or else a global one.
+// It returns nil when there is none.
+func ticketIntegrationFor(db *gorm.DB, teamID uint, kind string) (*Integration, error) {
+	var integrations []Integration
+	if err := db.Where("type IN ? AND enabled = ? AND deleted_at IS NULL", []string{IntegrationTypeJira, IntegrationTypeServiceNow}, true).
+		Where("team_id = ? OR team_id IS NULL", teamID).
+		Order("team_id IS NULL, id").
+		Find(&integrations).Error; err != nil {
+		return nil, err
+	}
+	for i := range integrations {
+		var cfg TicketConfig
+		if json.Unmarshal(integrations[i].Config, &cfg) == nil && cfg.wants(kind) {
+			return &integrations[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// openTicket opens an issue for a team on an integration and records it
+func openTicket(ctx context.Context, db *gorm.DB, integration *Integration, ticket *Ticket, title, description string) error {
+	provider, _, err := newTicketProvider(integration)
+	if err != nil {
+		return err
+	}
+	key, link, err := provider.Create(ctx, title, description, []string{"nest", strings.ReplaceAll(ticket.Kind, "_", "-")})
+	if err != nil {
+		return err
+	}
+	ticket.IntegrationID = integration.ID
+	ticket.ExternalKey = key
+	ticket.URL = link
+	ticket.Status = ticketOpen
+	return db.WithContext(ctx).Create(ticket).Error
+}
+
+// statusIn reports whether an issue status is one of statuses, without
+// case
+func statusIn(status string, statuses []string) bool {
+	for _, s := range statuses {
+		if strings.EqualFold(strings.TrimSpace(status), s) {
+			return true
+		}
+	}
+	return false
+}
+
+// TicketSyncer opens tickets for failed production jobs and compliance
+// violations, and syncs the status of open tickets back from their
+// ticketing systems, applying operations whose approval was granted
+type TicketSyncer struct {
+	db *gorm.DB
+}
+
+// NewTicketSyncer creates a new ticket syncer
+func NewTicketSyncer(db *gorm.DB) *TicketSyncer {
+	return &TicketSyncer{db: db}
+}
+
+// SyncAll runs one pass: opening new tickets, then syncing open ones. It
+// runs as a periodic job, so replicas don't race to open the same ticket.
+func (s *TicketSyncer) SyncAll(ctx context.Context) error {
+	var count int64
+	if err := s.db.WithContext(ctx).Model(&Integration{}).
+		Where("type IN ? AND enabled = ? AND deleted_at IS NULL", []string{IntegrationTypeJira, IntegrationTypeServiceNow}, true).
+		Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to load ticketing integrations: %w", err)
+	}
+	if count == 0 {
+		return nil
+	}
+
+	production := map[uint][]Environment{}
+	if err := s.openJobFailureTickets(ctx, production); err != nil {
+		log.Printf("Failed to open tickets for failed jobs: %v", err)
+	}
+	if err := s.openComplianceTickets(ctx, production); err != nil {
+		log.Printf("Failed to open tickets for compliance violations: %v", err)
+	}
+	s.syncOpenTickets(ctx)
+	return nil
+}
+
+// isProduction reports whether an environment of a team requires approval,
+// which marks it as production. Pipelines are cached in envs for the pass.
+func (s *TicketSyncer) isProduction(ctx context.Context, envs map[uint][]Environment, teamID uint, name string) bool {
+	pipeline, ok := envs[teamID]
+	if !ok {
+		var err error
+		if pipeline, err = teamEnvironments(s.db.WithContext(ctx), teamID); err != nil {
+			log.Printf("Failed to load environments of team %d: %v", teamID, err)
+			return false
+		}
+		envs[teamID] = pipeline
+	}
+	i := findEnvironment(pipeline, name)
+	return i >= 0 && pipeline[i].RequiresApproval
+}
+
+// openJobFailureTickets opens a ticket for each recently failed job of a
+// production resource
+func (s *TicketSyncer) openJobFailureTickets(ctx context.Context, envs map[uint][]Environment) error {
+	db := s.db.WithContext(ctx)
+	if !db.Migrator().HasTable("backup_jobs") {
+		return nil
+	}
+
+	var jobs []struct {
+		ID           uint
+		JobType      string
+		ErrorMessage string
+		ResourceID   uint
+		Name         string
+		TeamID       uint
+		Environment  string
+	}
+	if err := db.Raw(`SELECT j.id, j.job_type, COALESCE(j.error_message, '') AS error_message,
+			r.id AS resource_id, r.name, r.team_id, r.environment
+		FROM backup_jobs j
+		JOIN resources r ON r.id = j.resource_id AND r.deleted_at IS NULL
+		WHERE j.status = ? AND COALESCE(j.completed_at, j.created_at) > ?
+		AND NOT EXISTS (SELECT 1 FROM tickets t WHERE t.subject = 'job:' || j.id AND t.deleted_at IS NULL)
+		ORDER BY j.id`, backupJobFailed, time.Now().Add(-ticketJobLookback)).Scan(&jobs).Error; err != nil {
+		return err
+	}
+
+	for _, job := range jobs {
+		if !s.isProduction(ctx, envs, job.TeamID, job.Environment) {
+			continue
+		}
+		integration, err := ticketIntegrationFor(db, job.TeamID, ticketKindJobFailure)
+		if err != nil {
+			return err
+		}
+		if integration == nil {
+			continue
+		}
+
+		resourceID := job.ResourceID
+		ticket := &Ticket{
+			TeamID:     job.TeamID,
+			Kind:       ticketKindJobFailure,
+			Subject:    fmt.Sprintf("job:%d", job.ID),
+			ResourceID: &resourceID,
+		}
+		title := fmt.Sprintf("NEST: %s job failed for %s (%s)", job.JobType, job.Name, job.Environment)
+		description := fmt.Sprintf("The %s job #%d of resource %q (ID %d) in %s failed.\n\nError: %s",
+			job.JobType, job.ID, job.Name, job.ResourceID, job.Environment, job.ErrorMessage)
+		if err := openTicket(ctx, db, integration, ticket, title, description); err != nil {
+			log.Printf("Failed to open a ticket for job %d on integration %d: %v", job.ID, integration.ID, err)
+		}
+	}
+	return nil
+}
+
+// openComplianceTickets opens a ticket for each production resource with
+// hardening findings and no open compliance ticket
+func (s *TicketSyncer) openComplianceTickets(ctx context.Context, envs map[uint][]Environment) error {
+	db := s.db.WithContext(ctx)
+
+	var resources []Resource
+	if err := db.Where("deleted_at IS NULL AND lifecycle_mode = ?", "full").
+		Where("jsonb_array_length(COALESCE(security_findings, '[]'::jsonb)) > 0").
+		Where("NOT EXISTS (SELECT 1 FROM tickets t WHERE t.subject = 'compliance:' || resources.id AND t.status = ? AND t.deleted_at IS NULL)", ticketOpen).
+		Order("id").
+		Find(&resources).Error; err != nil {
+		return err
+	}
+
+	for i := range resources {
+		resource := &resources[i]
+		if !s.isProduction(ctx, envs, resource.TeamID, resource.Environment) {
+			continue
+		}
+		integration, err := ticketIntegrationFor(db, resource.TeamID, ticketKindCompliance)
+		if err != nil {
+			return err
+		}
+		if integration == nil {
+			continue
+		}
+
+		var findings []string
+		decodeJSONField(resource.SecurityFindings, &findings, "security findings")
+		ticket := &Ticket{
+			TeamID:     resource.TeamID,
+			Kind:       ticketKindCompliance,
+			Subject:    fmt.Sprintf("compliance:%d", resource.ID),
+			ResourceID: &resource.ID,
+		}
+		title := fmt.Sprintf("NEST: %s (%s) is out of compliance", resource.Name, resource.Environment)
+		description := fmt.Sprintf("Resource %q (ID %d) in %s has hardening findings:\n\n- %s",
+			resource.Name, resource.ID, resource.Environment, strings.Join(findings, "\n- "))
+		if err := openTicket(ctx, db, integration, ticket, title, description); err != nil {
+			log.Printf("Failed to open a compliance ticket for resource %d on integration %d: %v", resource.ID, integration.ID, err)
+		}
+	}
+	return nil
+}
+
+// syncOpenTickets reads the status of every open ticket from its
+// ticketing system, resolving those that reached an approved or rejected
+// status
+func (s *TicketSyncer) syncOpenTickets(ctx context.Context) {
+	db := s.db.WithContext(ctx)
+
+	var tickets []Ticket
+	if err := db.Where("status = ? AND deleted_at IS NULL", ticketOpen).Order("integration_id, id").Find(&tickets).Error; err != nil {
+		log.Printf("Failed to load open tickets: %v", err)
+		return
+	}
+
+	type integrationState struct {
+		integration *Integration
+		provider    ticketProvider
+		cfg         *TicketConfig
+		err         error
+	}
+	states := map[uint]*integrationState{}
+	for i := range tickets {
+		ticket := &tickets[i]
+		state, ok := states[ticket.IntegrationID]
+		if !ok {
+			state = &integrationState{integration: &Integration{}}
+			if err := db.Where("id = ? AND deleted_at IS NULL", ticket.IntegrationID).First(state.integration).Error; err != nil {
+				state.err = err
+			} else {
+				state.provider, state.cfg, state.err = newTicketProvider(state.integration)
+			}
+			states[ticket.IntegrationID] = state
+		}
+		if state.err != nil {
+			continue
+		}
+
+		status, err := state.provider.Status(ctx, ticket.ExternalKey)
+		if err != nil {
+			state.err = err
+			continue
+		}
+		updates := map[string]interface{}{"external_status": status}
+		resolved := ""
+		switch {
+		case statusIn(status, state.cfg.ApprovedStatuses):
+			resolved = ticketApproved
+		case statusIn(status, state.cfg.RejectedStatuses):
+			resolved = ticketRejected
+		}
+		if resolved != "" {
+			if ticket.Kind != ticketKindApproval {
+				resolved = ticketClosed
+			}
+			updates["status"] = resolved
+			updates["resolved_at"] = time.Now()
+		}
+		// Every replica syncs tickets, so the one that moves the ticket
+		// off open is the one that applies its operation
+		err = db.Transaction(func(tx *gorm.DB) error {
+			result := tx.Model(ticket).Where("status = ?", ticketOpen).Updates(updates)
+			if result.Error != nil || result.RowsAffected == 0 || resolved != ticketApproved {
+				return result.Error
+			}
+			if err := applyTicketOperation(ctx, tx, ticket); err != nil {
+				log.Printf("Failed to apply the operation approved by ticket %d: %v", ticket.ID, err)
+				return tx.Model(ticket).Update("last_error", err.Error()).Error
+			}
+			return nil
+		})
+		if err != nil {
+			log.Printf("Failed to update ticket %d: %v", ticket.ID, err)
+		}
+	}
+
+	for _, state := range states {
+		if state.integration.ID == 0 {
+			continue
+		}
+		updates := map[string]interface{}{"last_error": "", "last_sync_at": time.Now()}
+		if state.err != nil {
+			log.Printf("Ticket sync of integration %d failed: %v", state.integration.ID, state.err)
+			updates = map[string]interface{}{"last_error": state.err.Error()}
+		}
+		db.Model(state.integration).Updates(updates)
+	}
+}
+
+// applyTicketOperation carries out the operation an approval ticket was
+// waiting on, as the user who requested it
+func applyTicketOperation(ctx context.Context, db *gorm.DB, ticket *Ticket) error {
+	if ticket.RequestedBy == nil {
+		return errors.New("ticket has no requester")
+	}
+	var op TicketPromotion
+	if err := json.Unmarshal(ticket.Operation, &op); err != nil {
+		return fmt.Errorf("invalid ticket operation: %w", err)
+	}
+
+	var source Resource
+	if err := db.Where("id = ? AND deleted_at IS NULL", op.SourceResourceID).First(&source).Error; err != nil {
+		return fmt.Errorf("failed to load resource %d: %w", op.SourceResourceID, err)
+	}
+	envs, err := teamEnvironments(db, source.TeamID)
+	if err != nil {
+		return fmt.Errorf("failed to load environments: %w", err)
+	}
+	to := findEnvironment(envs, op.TargetEnvironment)
+	if to < 0 {
+		return fmt.Errorf("environment %q is no longer in the team's pipeline", op.TargetEnvironment)
+	}
+	_, _, err = applyPromotion(db, &source, &envs[to], op.Config, *ticket.RequestedBy)
+	return err
+}
+
+// requestPromotionApproval opens an approval ticket for a promotion, or
+// returns the open one that already waits on it
+func requestPromotionApproval(ctx context.Context, db *gorm.DB, integration *Integration, source *Resource, target *Environment, cfg map[string]interface{}, changes []ConfigChange, userID uint) (*Ticket, error) {
+	subject := fmt.Sprintf("promotion:%d:%s", source.ID, target.Name)
+	var existing Ticket
+	err := db.Where("subject = ? AND status = ? AND deleted_at IS NULL", subject, ticketOpen).First(&existing).Error
+	if err == nil {
+		return &existing, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	op, err := json.Marshal(TicketPromotion{SourceResourceID: source.ID, TargetEnvironment: target.Name, Config: cfg})
+	if err != nil {
+		return nil, err
+	}
+	ticket := &Ticket{
+		TeamID:      source.TeamID,
+		Kind:        ticketKindApproval,
+		Subject:     subject,
+		ResourceID:  &source.ID,
+		Operation:   datatypes.JSON(op),
+		RequestedBy: &userID,
+	}
+
+	lines := []string{fmt.Sprintf("User %d requests promoting resource %q (ID %d) from %s to %s.",
+		userID, source.Name, source.ID, source.Environment, target.Name), "", "Config changes:"}
+	for _, change := range changes {
+		lines = append(lines, fmt.Sprintf("- %s: %v -> %v", change.Key, change.From, change.To))
+	}
+	if len(changes) == 0 {
+		lines = append(lines, "- none")
+	}
+	lines = append(lines, "", "Approving this ticket applies the promotion.")
+
+	title := fmt.Sprintf("NEST: approve promotion of %s to %s", source.Name, target.Name)
+	if err := openTicket(ctx, db, integration, ticket, title, strings.Join(lines, "\n")); err != nil {
+		return nil, err
+	}
+	return ticket, nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/penguintechinc/project-template/shared/apierrors"
+)
+
+// ListTickets retrieves the tickets of the user's teams, newest first
+// GET /api/v1/tickets?status=&kind=&team_id=&resource_id=
+func (ic *IntegrationController) ListTickets(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "User context not found")
+		return
+	}
+
+	// Tickets are scoped by the user's team membership
+	query := tenantDB(c, ic.db).Where("tickets.deleted_at IS NULL").
+		Joins("INNER JOIN team_members ON tickets.team_id = team_members.team_id").
+		Where("team_members.user_id = ?", userID.(uint))
+
+	if status := c.Query("status"); status != "" {
+		query = query.Where("tickets.status = ?", status)
+	}
+	if kind := c.Query("kind"); kind != "" {
+		query = query.Where("tickets.kind = ?", kind)
+	}
+	if teamID := c.Query("team_id"); teamID != "" {
+		if tid, err := strconv.ParseUint(teamID, 10, 32); err == nil {
+			query = query.Where("tickets.team_id = ?", uint(tid))
+		}
+	}
+	if resourceID := c.Query("resource_id"); resourceID != "" {
+		if rid, err := strconv.ParseUint(resourceID, 10, 32); err == nil {
+			query = query.Where("tickets.resource_id = ?", uint(rid))
+		}
+	}
+
+	var tickets []*Ticket
+	if err := query.Order("tickets.created_at DESC").Limit(100).Find(&tickets).Error; err != nil {
+		log.Printf("Error listing tickets: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to list tickets")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tickets": tickets})
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/pem"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/penguintechinc/project-template/shared/apierrors"
+	"gorm.io/gorm"
+)
+
+// TrustBundleController serves team CA trust bundles
+type TrustBundleController struct {
+	db     *gorm.DB
+	access *AccessCache
+}
+
+// NewTrustBundleController creates a new trust bundle controller
+func NewTrustBundleController(db *gorm.DB, access *AccessCache) *TrustBundleController {
+	return &TrustBundleController{db: db, access: access}
+}
+
+// trustBundleCA is a CA row as the manager stores it, with team_ids as a
+// PyDAL list:reference such as |1|2|
+type trustBundleCA struct {
+	Certificate string
+	Chain       *string
+	TeamIDs     *string
+}
+
+// caAllowsTeam reports whether a CA with the given team_ids issues
+// certificates for a team; CAs without teams issue for all of them
+func caAllowsTeam(teamIDs *string, teamID uint) bool {
+	if teamIDs == nil || strings.Trim(*teamIDs, "|") == "" {
+		return true
+	}
+	return strings.Contains(*teamIDs, fmt.Sprintf("|%d|", teamID))
+}
+
+// GetTeamTrustBundle returns the PEM certificates, and imported chains, of
+// the CAs that issue for a team. The K8s controller publishes the same
+// bundle as the nest-ca-bundle ConfigMap in the team's namespaces.
+// GET /api/v1/teams/:id/trust-bundle
+func (tc *TrustBundleController) GetTeamTrustBundle(c *gin.Context) {
+	teamID, _, ok := teamAccess(c, tc.access)
+	if !ok {
+		return
+	}
+
+	// The manager migrates the CA tables; before it has, there are no CAs
+	db := tenantDB(c, tc.db)
+	var cas []trustBundleCA
+	if db.Migrator().HasTable("certificate_authorities") {
+		if err := db.Table("certificate_authorities").Select("certificate, chain, team_ids").
+			Where("deleted_at IS NULL AND status = ? AND type NOT IN ? AND certificate <> ''", "active", []string{"acme", "ssh"}).
+			Order("id").Scan(&cas).Error; err != nil {
+			log.Printf("Error loading certificate authorities for team %d: %v", teamID, err)
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to load trust bundle")
+			return
+		}
+	}
+
+	seen := map[string]bool{}
+	var bundle []byte
+	for _, ca := range cas {
+		if !caAllowsTeam(ca.TeamIDs, teamID) {
+			continue
+		}
+		rest := []byte(ca.Certificate)
+		if ca.Chain != nil {
+			rest = append(append(rest, '\n'), *ca.Chain...)
+		}
+		for {
+			var block *pem.Block
+			block, rest = pem.Decode(rest)
+			if block == nil {
+				break
+			}
+			if block.Type != "CERTIFICATE" || seen[string(block.Bytes)] {
+				continue
+			}
+			seen[string(block.Bytes)] = true
+			bundle = append(bundle, pem.EncodeToMemory(block)...)
+		}
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=team-%d-ca.crt", teamID))
+	c.Data(http.StatusOK, "application/x-pem-file", bundle)
+}
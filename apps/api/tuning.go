@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Tuning parameter value kinds
+const (
+	tuningInt    = "int"
+	tuningFloat  = "float"
+	tuningSize   = "size"
+	tuningBool   = "bool"
+	tuningString = "string"
+)
+
+// tuningParam describes an engine parameter that may be set through
+// Config.tuning. Restart parameters only take effect after the pods are
+// restarted; the others are reloaded live by the K8s controller.
+type tuningParam struct {
+	kind    string
+	restart bool
+}
+
+// tuningParams lists the supported tuning parameters per resource type. The
+// K8s controller keeps the same table.
+var tuningParams = map[string]map[string]tuningParam{
+	"postgresql": {
+		"shared_buffers":                      {tuningSize, true},
+		"max_connections":                     {tuningInt, true},
+		"wal_buffers":                         {tuningSize, true},
+		"max_worker_processes":                {tuningInt, true},
+		"shared_preload_libraries":            {tuningString, true},
+		"work_mem":                            {tuningSize, false},
+		"maintenance_work_mem":                {tuningSize, false},
+		"effective_cache_size":                {tuningSize, false},
+		"max_wal_size":                        {tuningSize, false},
+		"min_wal_size":                        {tuningSize, false},
+		"checkpoint_completion_target":        {tuningFloat, false},
+		"random_page_cost":                    {tuningFloat, false},
+		"effective_io_concurrency":            {tuningInt, false},
+		"default_statistics_target":           {tuningInt, false},
+		"max_parallel_workers":                {tuningInt, false},
+		"max_parallel_workers_per_gather":     {tuningInt, false},
+		"log_min_duration_statement":          {tuningInt, false},
+		"statement_timeout":                   {tuningInt, false},
+		"idle_in_transaction_session_timeout": {tuningInt, false},
+	},
+	"mariadb": {
+		"innodb_log_file_size":           {tuningSize, true},
+		"innodb_buffer_pool_instances":   {tuningInt, true},
+		"performance_schema":             {tuningBool, true},
+		"innodb_buffer_pool_size":        {tuningSize, false},
+		"max_connections":                {tuningInt, false},
+		"innodb_flush_log_at_trx_commit": {tuningInt, false},
+		"innodb_io_capacity":             {tuningInt, false},
+		"tmp_table_size":                 {tuningSize, false},
+		"max_heap_table_size":            {tuningSize, false},
+		"max_allowed_packet":             {tuningSize, false},
+		"table_open_cache":               {tuningInt, false},
+		"thread_cache_size":              {tuningInt, false},
+		"slow_query_log":                 {tuningBool, false},
+		"long_query_time":                {tuningFloat, false},
+	},
+	"redis": {
+		"databases":               {tuningInt, true},
+		"io-threads":              {tuningInt, true},
+		"maxmemory":               {tuningSize, false},
+		"maxmemory-policy":        {tuningString, false},
+		"timeout":                 {tuningInt, false},
+		"tcp-keepalive":           {tuningInt, false},
+		"appendonly":              {tuningBool, false},
+		"appendfsync":             {tuningString, false},
+		"save":                    {tuningString, false},
+		"slowlog-log-slower-than": {tuningInt, false},
+		"slowlog-max-len":         {tuningInt, false},
+	},
+}
+
+// tuningSizePattern matches memory sizes such as 256MB, 1G, or 64kb
+var tuningSizePattern = regexp.MustCompile(`^\d+\s*([kKmMgGtT][bB]?)?$`)
+
+// configTuning returns Config.tuning with every value rendered as a string
+func configTuning(cfg map[string]interface{}) (map[string]string, error) {
+	raw, ok := cfg["tuning"]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+	m, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("tuning must be an object of parameter values")
+	}
+
+	tuning := make(map[string]string, len(m))
+	for name, v := range m {
+		switch value := v.(type) {
+		case string:
+			tuning[name] = value
+		case float64:
+			tuning[name] = strconv.FormatFloat(value, 'f', -1, 64)
+		case bool:
+			tuning[name] = strconv.FormatBool(value)
+		default:
+			return nil, fmt.Errorf("tuning parameter %q must be a string, number, or boolean", name)
+		}
+	}
+	return tuning, nil
+}
+
+// validateTuning checks Config.tuning against the known parameters of a
+// resource type
+func validateTuning(resourceType string, cfg map[string]interface{}) error {
+	tuning, err := configTuning(cfg)
+	if err != nil || len(tuning) == 0 {
+		return err
+	}
+
+	params, ok := tuningParams[resourceType]
+	if !ok {
+		return fmt.Errorf("resource type %q does not support tuning", resourceType)
+	}
+
+	for name, value := range tuning {
+		param, ok := params[name]
+		if !ok {
+			return fmt.Errorf("unknown %s tuning parameter %q", resourceType, name)
+		}
+
+		valid := true
+		switch param.kind {
+		case tuningInt:
+			_, err := strconv.ParseInt(value, 10, 64)
+			valid = err == nil
+		case tuningFloat:
+			_, err := strconv.ParseFloat(value, 64)
+			valid = err == nil
+		case tuningSize:
+			valid = tuningSizePattern.MatchString(value)
+		case tuningBool:
+			switch strings.ToLower(value) {
+			case "true", "false", "on", "off", "yes", "no", "1", "0":
+			default:
+				valid = false
+			}
+		case tuningString:
+			valid = !strings.ContainsAny(value, "\n\r")
+		}
+		if !valid {
+			return fmt.Errorf("invalid value %q for %s tuning parameter %q", value, resourceType, name)
+		}
+	}
+	return nil
+}
+
+// tuningRestartChanges lists the restart parameters whose values differ
+// between two Config documents
+func tuningRestartChanges(resourceType string, before, after map[string]interface{}) []string {
+	old, _ := configTuning(before)
+	updated, _ := configTuning(after)
+
+	var changed []string
+	for name, param := range tuningParams[resourceType] {
+		if param.restart && old[name] != updated[name] {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// uiFiles is the admin UI, a single page that calls the API from the
+// browser, built into the binary so small installs need no separate
+// frontend deployment
+//
+//go:embed ui
+var uiFiles embed.FS
+
+// registerUI serves the admin UI's page at / and its scripts and styles
+// under /ui/. The UI routes its views by the URL fragment, so no other
+// paths need to serve the page.
+func registerUI(r *gin.Engine) {
+	assets, err := fs.Sub(uiFiles, "ui")
+	if err != nil {
+		panic(err)
+	}
+	page, err := fs.ReadFile(assets, "index.html")
+	if err != nil {
+		panic(err)
+	}
+	static := http.FS(assets)
+
+	r.GET("/", func(c *gin.Context) {
+		// The page names its assets without versions, so it is revalidated
+		// to pick up a new release
+		c.Header("Cache-Control", "no-cache")
+		c.Data(http.StatusOK, "text/html; charset=utf-8", page)
+	})
+	r.GET("/ui/*filepath", func(c *gin.Context) {
+		c.Header("Cache-Control", "no-cache")
+		c.FileFromFS(c.Param("filepath"), static)
+	})
+}
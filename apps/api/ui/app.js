@@ -0,0 +1,312 @@
+// Nest admin UI: a single-page app over the API, served by the API itself.
+// Views are routed by the URL fragment so the server only serves this one
+// page.
+"use strict";
+
+const API = "/api/v1";
+const TOKEN_KEY = "nest.token";
+
+const view = document.getElementById("view");
+
+// h builds an element. Text is always set as text, never parsed as HTML.
+function h(tag, attrs, ...children) {
+  const el = document.createElement(tag);
+  for (const [key, value] of Object.entries(attrs || {})) {
+    if (value === undefined || value === null || value === false) continue;
+    if (key.startsWith("on")) el.addEventListener(key.slice(2), value);
+    else el.setAttribute(key, value === true ? "" : value);
+  }
+  for (const child of children.flat()) {
+    if (child === undefined || child === null || child === false) continue;
+    el.append(child instanceof Node ? child : String(child));
+  }
+  return el;
+}
+
+function cookie(name) {
+  const match = document.cookie.split("; ").find((c) => c.startsWith(name + "="));
+  return match ? decodeURIComponent(match.slice(name.length + 1)) : "";
+}
+
+// api calls the API with the session cookie, or the token given in the
+// header bar, and the CSRF token for requests that change state
+async function api(method, path, body) {
+  const headers = { Accept: "application/json" };
+  const token = sessionStorage.getItem(TOKEN_KEY);
+  if (token) headers.Authorization = "Bearer " + token;
+  if (method !== "GET") {
+    headers["X-CSRF-Token"] = cookie("nest_csrf");
+    if (body !== undefined) headers["Content-Type"] = "application/json";
+  }
+  const res = await fetch(API + path, {
+    method,
+    headers,
+    credentials: "same-origin",
+    body: body === undefined ? undefined : JSON.stringify(body),
+  });
+  const data = res.status === 204 ? null : await res.json().catch(() => null);
+  if (!res.ok) {
+    const message = (data && (data.message || data.detail || data.title)) || res.statusText;
+    throw new Error(message);
+  }
+  return { data, total: Number(res.headers.get("X-Total-Count") || 0) };
+}
+
+function formatTime(value) {
+  return value ? new Date(value).toLocaleString() : "";
+}
+
+function statusClass(status) {
+  switch (status) {
+    case "failed":
+    case "error":
+    case "dead":
+    case "expired":
+      return "status bad";
+    case "pending":
+    case "provisioning":
+    case "queued":
+    case "running":
+    case "deleting":
+      return "status warn";
+    default:
+      return "status good";
+  }
+}
+
+function statusCell(status) {
+  return h("td", {}, h("span", { class: statusClass(status) }, status));
+}
+
+function table(columns, rows) {
+  if (rows.length === 0) return h("div", { class: "empty" }, "Nothing to show.");
+  return h("table", {},
+    h("thead", {}, h("tr", {}, columns.map((col) => h("th", {}, col)))),
+    h("tbody", {}, rows));
+}
+
+// pager links to the previous and next pages of a list view, whose route
+// may carry other query parameters
+function pager(route, page, pageSize, total) {
+  const last = Math.max(1, Math.ceil(total / pageSize));
+  const sep = route.includes("?") ? "&" : "?";
+  return h("div", { class: "toolbar" },
+    h("span", {}, `${total} total`),
+    h("span", {},
+      page > 1 ? h("a", { href: `#/${route}${sep}page=${page - 1}` }, "Previous") : null,
+      ` Page ${page} of ${last} `,
+      page < last ? h("a", { href: `#/${route}${sep}page=${page + 1}` }, "Next") : null));
+}
+
+function render(...children) {
+  view.replaceChildren(...children);
+}
+
+function showError(err) {
+  view.prepend(h("div", { class: "error" }, err.message));
+}
+
+// action runs a request that changes state, then rerenders the view
+function action(fn) {
+  return async (event) => {
+    event.preventDefault();
+    try {
+      await fn(event);
+      route();
+    } catch (err) {
+      showError(err);
+    }
+  };
+}
+
+async function resourcesView(params) {
+  const page = Number(params.get("page") || 1);
+  const pageSize = 20;
+  const { data, total } = await api("GET", `/resources?page=${page}&page_size=${pageSize}`);
+  render(
+    h("h1", {}, "Resources"),
+    pager("resources", page, pageSize, total),
+    table(["Name", "Type", "Team", "Environment", "Status", "Created"],
+      data.resources.map((r) => h("tr", {},
+        h("td", {}, h("a", { href: `#/resources/${r.id}` }, r.name)),
+        h("td", {}, r.resource_type ? r.resource_type.name : r.resource_type_id),
+        h("td", {}, r.team ? r.team.name : r.team_id),
+        h("td", {}, r.environment),
+        statusCell(r.status),
+        h("td", {}, formatTime(r.created_at))))));
+}
+
+async function resourceView(id) {
+  const [{ data: r }, { data: reconcile }, { data: jobs }] = await Promise.all([
+    api("GET", `/resources/${id}`),
+    api("GET", `/resources/${id}/reconcile-status`),
+    api("GET", `/resources/${id}/provisioning-jobs`),
+  ]);
+  render(
+    h("h1", {}, r.name),
+    h("dl", {},
+      h("dt", {}, "Type"), h("dd", {}, r.resource_type ? r.resource_type.name : r.resource_type_id),
+      h("dt", {}, "Team"), h("dd", {}, r.team ? r.team.name : r.team_id),
+      h("dt", {}, "Environment"), h("dd", {}, r.environment),
+      h("dt", {}, "Status"), h("dd", {}, h("span", { class: statusClass(r.status) }, r.status)),
+      h("dt", {}, "Lifecycle"), h("dd", {}, `${r.lifecycle_mode} (${r.provisioning_method})`),
+      h("dt", {}, "TLS"), h("dd", {}, r.tls_enabled ? "Enabled" : "Disabled"),
+      h("dt", {}, "Last error"), h("dd", {}, r.last_error ? `${r.last_error} (${formatTime(r.last_error_at)})` : "None"),
+      h("dt", {}, "Last reconcile"), h("dd", {}, reconcile.last_reconcile_at
+        ? `${formatTime(reconcile.last_reconcile_at)} (${reconcile.last_outcome})` : "Never"),
+      h("dt", {}, "Reconcile pending"), h("dd", {}, reconcile.pending ? "Yes" : "No")),
+    h("form", { class: "inline", onsubmit: action(() => api("POST", `/resources/${id}/reconcile`)) },
+      h("button", { type: "submit" }, "Reconcile now")),
+    h("h2", {}, "Provisioning jobs"),
+    table(["Job", "Status", "Started", "Completed", "Log"],
+      jobs.jobs.map((job) => h("tr", {},
+        h("td", {}, job.job_type),
+        statusCell(job.status),
+        h("td", {}, formatTime(job.started_at)),
+        h("td", {}, formatTime(job.completed_at)),
+        h("td", {},
+          job.error_message ? h("div", { class: "status bad" }, job.error_message) : null,
+          job.logs ? h("details", {}, h("summary", {}, "Show log"), h("pre", {}, job.logs)) : null)))));
+}
+
+async function teamsView() {
+  const { data } = await api("GET", "/teams");
+  const name = h("input", { placeholder: "Name", required: true });
+  const description = h("input", { placeholder: "Description" });
+  render(
+    h("h1", {}, "Teams"),
+    h("form", {
+      class: "inline",
+      onsubmit: action(() => api("POST", "/teams", { name: name.value, description: description.value })),
+    }, name, description, h("button", { type: "submit" }, "Create team")),
+    table(["Name", "Description", "Created"],
+      data.teams.map((t) => h("tr", {},
+        h("td", {}, h("a", { href: `#/teams/${t.id}` }, t.name)),
+        h("td", {}, t.description),
+        h("td", {}, formatTime(t.created_at))))));
+}
+
+function roleSelect(roles) {
+  return h("select", {}, roles.map((role) => h("option", { value: role }, role)));
+}
+
+async function teamView(id) {
+  const [{ data: team }, { data: members }] = await Promise.all([
+    api("GET", `/teams/${id}`),
+    api("GET", `/teams/${id}/members`),
+  ]);
+  const userID = h("input", { type: "number", min: 1, placeholder: "User ID", required: true });
+  const memberRole = roleSelect(["team_viewer", "team_maintainer", "team_admin"]);
+  const email = h("input", { type: "email", placeholder: "Email", required: true });
+  const inviteRole = roleSelect(["viewer", "contributor", "maintainer", "admin"]);
+  render(
+    h("h1", {}, team.name),
+    team.description ? h("p", {}, team.description) : null,
+    h("h2", {}, "Members"),
+    table(["User", "Email", "Role", ""],
+      members.members.map((m) => h("tr", {},
+        h("td", {}, m.username),
+        h("td", {}, m.email),
+        h("td", {}, m.role),
+        h("td", {}, h("button", {
+          class: "danger",
+          onclick: action(() => api("DELETE", `/teams/${id}/members/${m.user_id}`)),
+        }, "Remove"))))),
+    h("form", {
+      class: "inline",
+      onsubmit: action(() => api("POST", `/teams/${id}/members`, { user_id: Number(userID.value), role: memberRole.value })),
+    }, userID, memberRole, h("button", { type: "submit" }, "Add member")),
+    h("h2", {}, "Invite"),
+    h("form", {
+      class: "inline",
+      onsubmit: action(() => api("POST", `/teams/${id}/invitations`, { email: email.value, role: inviteRole.value })),
+    }, email, inviteRole, h("button", { type: "submit" }, "Send invitation")));
+}
+
+async function certificatesView(params) {
+  const page = Number(params.get("page") || 1);
+  const withinDays = params.get("within_days") || "30";
+  const pageSize = 50;
+  const { data, total } = await api("GET", `/certificates?within_days=${encodeURIComponent(withinDays)}&page=${page}&page_size=${pageSize}`);
+  const windowSelect = h("select", {
+    onchange: (event) => { location.hash = `#/certificates?within_days=${event.target.value}`; },
+  }, [["7", "7 days"], ["30", "30 days"], ["90", "90 days"], ["0", "All"]].map(([value, label]) =>
+    h("option", { value, selected: value === withinDays }, label)));
+  render(
+    h("h1", {}, "Certificate expiry"),
+    h("form", { class: "inline" }, "Expiring within", windowSelect),
+    pager(`certificates?within_days=${withinDays}`, page, pageSize, total),
+    table(["Common name", "Resource", "Team", "Environment", "Expires", "Remaining", "Auto-renew"],
+      data.certificates.map((cert) => h("tr", {},
+        h("td", {}, cert.common_name),
+        h("td", {}, h("a", { href: `#/resources/${cert.resource_id}` }, cert.resource)),
+        h("td", {}, cert.team),
+        h("td", {}, cert.environment),
+        h("td", {}, formatTime(cert.valid_until)),
+        h("td", {}, h("span", {
+          class: cert.expired ? "status bad" : cert.days_remaining <= 7 ? "status warn" : "status good",
+        }, cert.expired ? "Expired" : `${cert.days_remaining} days`)),
+        h("td", {}, cert.auto_renew ? "Yes" : "No")))));
+}
+
+async function jobsView(params) {
+  const status = params.get("status") || "";
+  const { data } = await api("GET", `/admin/jobs${status ? "?status=" + encodeURIComponent(status) : ""}`);
+  const filter = h("select", {
+    onchange: (event) => { location.hash = `#/jobs${event.target.value ? "?status=" + event.target.value : ""}`; },
+  }, ["", "queued", "running", "completed", "dead"].map((value) =>
+    h("option", { value, selected: value === status }, value || "All")));
+  render(
+    h("h1", {}, "Background jobs"),
+    h("form", { class: "inline" }, "Status", filter),
+    table(["Kind", "Status", "Attempts", "Run at", "Completed", "Last error", ""],
+      data.jobs.map((job) => h("tr", {},
+        h("td", {}, job.kind),
+        statusCell(job.status),
+        h("td", {}, job.attempts),
+        h("td", {}, formatTime(job.run_at)),
+        h("td", {}, formatTime(job.completed_at)),
+        h("td", {}, job.last_error ? h("pre", {}, job.last_error) : ""),
+        h("td", {}, job.status === "dead"
+          ? h("button", { onclick: action(() => api("POST", `/admin/jobs/${job.id}/requeue`)) }, "Requeue")
+          : null)))));
+}
+
+const routes = [
+  [/^resources$/, resourcesView],
+  [/^resources\/(\d+)$/, (params, id) => resourceView(id)],
+  [/^teams$/, teamsView],
+  [/^teams\/(\d+)$/, (params, id) => teamView(id)],
+  [/^certificates$/, certificatesView],
+  [/^jobs$/, jobsView],
+];
+
+async function route() {
+  const [path, query] = location.hash.replace(/^#\/?/, "").split("?");
+  const params = new URLSearchParams(query || "");
+  for (const [pattern, handler] of routes) {
+    const match = pattern.exec(path);
+    if (match) {
+      try {
+        await handler(params, ...match.slice(1));
+      } catch (err) {
+        render();
+        showError(err);
+      }
+      return;
+    }
+  }
+  location.hash = "#/resources";
+}
+
+document.getElementById("token-form").addEventListener("submit", (event) => {
+  event.preventDefault();
+  const input = document.getElementById("token");
+  if (input.value) sessionStorage.setItem(TOKEN_KEY, input.value);
+  else sessionStorage.removeItem(TOKEN_KEY);
+  input.value = "";
+  route();
+});
+
+window.addEventListener("hashchange", route);
+route();
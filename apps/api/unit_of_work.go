@@ -0,0 +1,31 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/penguintechinc/project-template/shared/apierrors"
+	"github.com/penguintechinc/project-template/shared/database"
+	"gorm.io/gorm"
+)
+
+// unitOfWork runs fn as one transaction of the request's tenant database and
+// reports whether it committed. fn returns an *apierrors.Error for a failure
+// the client caused, which is written as it is; any other error is logged
+// and written as a database error with message. Either way every write fn
+// made is rolled back.
+func unitOfWork(c *gin.Context, db *gorm.DB, message string, fn func(tx *gorm.DB) error) bool {
+	err := database.UnitOfWork(c.Request.Context(), db, fn)
+	if err == nil {
+		return true
+	}
+	var apiErr *apierrors.Error
+	if !errors.As(err, &apiErr) {
+		log.Printf("%s: %v", message, err)
+		apiErr = apierrors.New(http.StatusInternalServerError, apierrors.CodeDatabaseError, message).Wrap(err)
+	}
+	apierrors.AbortWith(c, apiErr)
+	return false
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/penguintechinc/project-template/shared/licensing"
+	"gorm.io/gorm"
+)
+
+// UsageReport is the anonymized usage submitted to the license server for
+// entitlement reconciliation. It holds counts only; no names, IDs, hostnames,
+// or cluster names leave the installation.
+type UsageReport struct {
+	ResourcesByType map[string]int64 `json:"resources_by_type"`
+	Teams           int64            `json:"teams"`
+	Clusters        int64            `json:"clusters"`
+	GeneratedAt     time.Time        `json:"generated_at"`
+}
+
+// UsageReporter periodically sends a UsageReport with the license keepalive.
+// Reporting is opt-in, and a hard opt-out keeps it off regardless.
+type UsageReporter struct {
+	db       *gorm.DB
+	license  *licensing.Client
+	interval time.Duration
+	enabled  bool
+	optedOut bool
+
+	mu         sync.Mutex
+	lastSentAt *time.Time
+	lastError  string
+}
+
+// NewUsageReporter creates a usage reporter. Nothing is sent unless enabled
+// is set and optedOut is not.
+func NewUsageReporter(db *gorm.DB, license *licensing.Client, interval time.Duration, enabled, optedOut bool) *UsageReporter {
+	return &UsageReporter{db: db, license: license, interval: interval, enabled: enabled, optedOut: optedOut}
+}
+
+// Active reports whether usage reports are being sent
+func (u *UsageReporter) Active() bool {
+	return u.enabled && !u.optedOut && u.license != nil
+}
+
+// BuildReport counts live resources by type, teams, and clusters with a
+// registered K8s controller
+func (u *UsageReporter) BuildReport(ctx context.Context) (*UsageReport, error) {
+	db := u.db.WithContext(ctx)
+	report := &UsageReport{GeneratedAt: time.Now().UTC()}
+
+	var err error
+	if report.ResourcesByType, err = countGrouped(db, `
+		SELECT rt.name AS key, COUNT(*) AS count FROM resources r
+		JOIN resource_types rt ON rt.id = r.resource_type_id
+		WHERE r.deleted_at IS NULL GROUP BY rt.name`); err != nil {
+		return nil, err
+	}
+	if err := db.Model(&Team{}).Count(&report.Teams).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Model(&ControllerInstance{}).Where("status <> ?", ControllerStatusStopped).
+		Distinct("cluster").Count(&report.Clusters).Error; err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// Payload builds the exact keepalive body a report is sent in
+func (u *UsageReporter) Payload(report *UsageReport) map[string]interface{} {
+	usage := map[string]interface{}{"usage": report}
+	if u.license == nil {
+		return usage
+	}
+	return u.license.KeepalivePayload(usage)
+}
+
+// Status returns when a report was last sent by this replica and the last
+// send error
+func (u *UsageReporter) Status() (*time.Time, string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.lastSentAt, u.lastError
+}
+
+// Send builds and submits one report, recording the outcome. It runs as a
+// periodic job, registered only while reporting is active.
+func (u *UsageReporter) Send(ctx context.Context) error {
+	report, err := u.BuildReport(ctx)
+	if err == nil {
+		err = u.license.Keepalive(map[string]interface{}{"usage": report})
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if err != nil {
+		u.lastError = err.Error()
+		return fmt.Errorf("failed to send usage report: %w", err)
+	}
+	now := time.Now().UTC()
+	u.lastSentAt = &now
+	u.lastError = ""
+	return nil
+}
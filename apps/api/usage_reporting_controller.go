@@ -0,0 +1,46 @@
+package main
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/penguintechinc/project-template/shared/apierrors"
+)
+
+// UsageReportingController handles usage reporting HTTP requests
+type UsageReportingController struct {
+	reporter *UsageReporter
+}
+
+// NewUsageReportingController creates a new usage reporting controller
+func NewUsageReportingController(reporter *UsageReporter) *UsageReportingController {
+	return &UsageReportingController{reporter: reporter}
+}
+
+// PreviewUsageReport builds the usage report from current data and returns
+// the exact payload that would be sent to the license server, along with
+// whether reporting is active
+// GET /api/v1/admin/usage-report
+func (uc *UsageReportingController) PreviewUsageReport(c *gin.Context) {
+	if !requirePlatformAdmin(c) {
+		return
+	}
+
+	report, err := uc.reporter.BuildReport(c.Request.Context())
+	if err != nil {
+		log.Printf("Error building usage report: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to build usage report")
+		return
+	}
+
+	lastSentAt, lastError := uc.reporter.Status()
+	c.JSON(http.StatusOK, UsageReportPreviewResponse{
+		Enabled:    uc.reporter.Active(),
+		OptedOut:   uc.reporter.optedOut,
+		Interval:   uc.reporter.interval.String(),
+		LastSentAt: lastSentAt,
+		LastError:  lastError,
+		Payload:    uc.reporter.Payload(report),
+	})
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/penguintechinc/project-template/shared/apierrors"
+	"gorm.io/gorm"
+)
+
+// Failure policies of a validation webhook, deciding what happens to a
+// change when the webhook can't be called or answers with an error
+const (
+	WebhookFailClosed = "fail"
+	WebhookFailOpen   = "ignore"
+)
+
+// Bounds of a validation webhook's timeout, in seconds
+const (
+	defaultWebhookTimeout = 5
+	maxWebhookTimeout     = 30
+)
+
+// webhookSignatureHeader carries the HMAC-SHA256 of a validation request
+// body, keyed by the webhook's secret, as sha256=<hex>
+const webhookSignatureHeader = "X-Nest-Signature-256"
+
+// ValidationRequest is posted to validation webhooks with the proposed spec
+// of a resource about to be created or updated. OldResource is the spec
+// being replaced on update.
+type ValidationRequest struct {
+	UID         string                 `json:"uid"`
+	Operation   string                 `json:"operation"`
+	UserID      uint                   `json:"user_id"`
+	Resource    map[string]interface{} `json:"resource"`
+	OldResource map[string]interface{} `json:"old_resource,omitempty"`
+	Images      []string               `json:"images"`
+}
+
+// ValidationResponse is a validation webhook's verdict on a change
+type ValidationResponse struct {
+	Allowed  bool     `json:"allowed"`
+	Message  string   `json:"message,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// ValidationRejection names the webhook that rejected a change and why
+type ValidationRejection struct {
+	Webhook string `json:"webhook"`
+	Message string `json:"message"`
+}
+
+// ValidationWebhooks calls the external validators resource changes are
+// checked by. Webhooks are shared by every tenant, so they are always read
+// from the public schema.
+type ValidationWebhooks struct {
+	db     *gorm.DB
+	client *http.Client
+}
+
+// NewValidationWebhooks creates the validation webhook caller
+func NewValidationWebhooks(db *gorm.DB) *ValidationWebhooks {
+	return &ValidationWebhooks{db: db, client: &http.Client{}}
+}
+
+// Validate calls the enabled webhooks for operation, in name order, with
+// the proposed spec of a resource and, on update, the spec it replaces. It
+// returns the webhooks' warnings, or the error rejecting the change when a
+// webhook denies it or fails and its failure policy is fail.
+func (v *ValidationWebhooks) Validate(c *gin.Context, operation string, resource, old *Resource) ([]string, *apierrors.Error) {
+	ctx := c.Request.Context()
+	var webhooks []ValidationWebhook
+	if err := v.db.WithContext(ctx).Where("enabled = ?", true).Order("name").Find(&webhooks).Error; err != nil {
+		log.Printf("Error loading validation webhooks: %v", err)
+		return nil, apierrors.New(http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to load validation webhooks")
+	}
+
+	var req *ValidationRequest
+	var warnings []string
+	var rejections []ValidationRejection
+	for i := range webhooks {
+		webhook := &webhooks[i]
+		if !webhook.appliesTo(operation) {
+			continue
+		}
+		if req == nil {
+			var err error
+			if req, err = v.validationRequest(c, operation, resource, old); err != nil {
+				log.Printf("Error building validation request for resource %s: %v", resource.Name, err)
+				return nil, apierrors.New(http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to load validation webhooks")
+			}
+		}
+
+		resp, err := v.call(ctx, webhook, req)
+		v.recordResult(ctx, webhook, err)
+		if err != nil {
+			if webhook.FailurePolicy == WebhookFailOpen {
+				log.Printf("Validation webhook %s failed, allowing %s of resource %s: %v", webhook.Name, operation, resource.Name, err)
+				continue
+			}
+			log.Printf("Validation webhook %s failed, rejecting %s of resource %s: %v", webhook.Name, operation, resource.Name, err)
+			return nil, apierrors.New(http.StatusServiceUnavailable, "validation_unavailable", "A validation webhook could not be reached").
+				WithDetails(ValidationRejection{Webhook: webhook.Name, Message: err.Error()})
+		}
+
+		for _, warning := range resp.Warnings {
+			warnings = append(warnings, webhook.Name+": "+warning)
+		}
+		if !resp.Allowed {
+			message := resp.Message
+			if message == "" {
+				message = "denied"
+			}
+			rejections = append(rejections, ValidationRejection{Webhook: webhook.Name, Message: message})
+		}
+	}
+
+	if len(rejections) > 0 {
+		return nil, apierrors.New(http.StatusUnprocessableEntity, "validation_rejected", "The resource was rejected by a validation webhook").
+			WithDetails(rejections)
+	}
+	return warnings, nil
+}
+
+// validationRequest builds the request posted to validation webhooks
+func (v *ValidationWebhooks) validationRequest(c *gin.Context, operation string, resource, old *Resource) (*ValidationRequest, error) {
+	ctx := c.Request.Context()
+	spec, images, err := resourceSpec(ctx, v.db, resource)
+	if err != nil {
+		return nil, err
+	}
+	req := &ValidationRequest{
+		UID:       apierrors.RequestID(c),
+		Operation: operation,
+		Resource:  spec,
+		Images:    images,
+	}
+	if userID, ok := c.Get("user_id"); ok {
+		req.UserID, _ = userID.(uint)
+	}
+	if old != nil {
+		if req.OldResource, _, err = resourceSpec(ctx, v.db, old); err != nil {
+			return nil, err
+		}
+	}
+	return req, nil
+}
+
+// call posts a validation request to a webhook within its timeout. Any
+// answer but a 2xx with a verdict is a failure.
+func (v *ValidationWebhooks) call(ctx context.Context, webhook *ValidationWebhook, req *ValidationRequest) (*ValidationResponse, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := webhook.TimeoutSeconds
+	if timeout <= 0 {
+		timeout = defaultWebhookTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+	defer cancel()
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if webhook.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(webhook.Secret))
+		mac.Write(body)
+		httpReq.Header.Set(webhookSignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := v.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+
+	var verdict ValidationResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&verdict); err != nil {
+		return nil, fmt.Errorf("invalid webhook response: %w", err)
+	}
+	return &verdict, nil
+}
+
+// recordResult keeps the last failure of a webhook, so failures of a
+// fail-open webhook are visible to admins
+func (v *ValidationWebhooks) recordResult(ctx context.Context, webhook *ValidationWebhook, callErr error) {
+	updates := map[string]interface{}{"last_called_at": time.Now().UTC()}
+	if callErr != nil {
+		updates["last_error"] = callErr.Error()
+		updates["last_error_at"] = time.Now().UTC()
+	}
+	if err := v.db.WithContext(ctx).Model(&ValidationWebhook{}).Where("id = ?", webhook.ID).UpdateColumns(updates).Error; err != nil {
+		log.Printf("Error recording result of validation webhook %s: %v", webhook.Name, err)
+	}
+}
+
+// appliesTo reports whether the webhook validates operation. A webhook
+// without operations validates all of them.
+func (w *ValidationWebhook) appliesTo(operation string) bool {
+	var operations []string
+	decodeJSONField(w.Operations, &operations, "webhook operations")
+	if len(operations) == 0 {
+		return true
+	}
+	for _, op := range operations {
+		if op == operation {
+			return true
+		}
+	}
+	return false
+}
+
+// admit checks a resource about to be created or updated against the Rego
+// policies and then the validation webhooks. Webhook warnings are returned
+// to the client as Warning headers.
+func (rc *ResourceController) admit(c *gin.Context, operation string, resource, old *Resource) *apierrors.Error {
+	if apiErr := rc.policies.Check(c.Request.Context(), operation, resource); apiErr != nil {
+		return apiErr
+	}
+	warnings, apiErr := rc.webhooks.Validate(c, operation, resource, old)
+	if apiErr != nil {
+		return apiErr
+	}
+	for _, warning := range warnings {
+		c.Writer.Header().Add("Warning", "299 - "+strconv.Quote(strings.ReplaceAll(warning, "\n", " ")))
+	}
+	return nil
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+	"github.com/penguintechinc/project-template/shared/apierrors"
+	"github.com/penguintechinc/project-template/shared/audit"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// ValidationWebhookController handles validation webhook HTTP requests
+type ValidationWebhookController struct {
+	db *gorm.DB
+}
+
+// NewValidationWebhookController creates a new validation webhook
+// controller. Webhooks are shared by every tenant, so they are always read
+// from the public schema.
+func NewValidationWebhookController(db *gorm.DB) *ValidationWebhookController {
+	return &ValidationWebhookController{db: db}
+}
+
+// loadWebhook writes the error response when the webhook of the :id path
+// parameter can't be loaded
+func (vc *ValidationWebhookController) loadWebhook(c *gin.Context) (*ValidationWebhook, bool) {
+	var webhook ValidationWebhook
+	if err := vc.db.First(&webhook, c.Param("id")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierrors.Abort(c, http.StatusNotFound, apierrors.CodeNotFound, "Validation webhook not found")
+		} else {
+			log.Printf("Error retrieving validation webhook: %v", err)
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to retrieve validation webhook")
+		}
+		return nil, false
+	}
+	return &webhook, true
+}
+
+// validWebhook writes the error response when a webhook's URL, timeout,
+// failure policy or operations are invalid
+func validWebhook(c *gin.Context, webhook *ValidationWebhook, operations []string) bool {
+	if u, err := url.Parse(webhook.URL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		apierrors.Abort(c, http.StatusBadRequest, "invalid_url", "url must be an absolute http or https URL")
+		return false
+	}
+	if webhook.TimeoutSeconds < 1 || webhook.TimeoutSeconds > maxWebhookTimeout {
+		apierrors.Abort(c, http.StatusBadRequest, "invalid_timeout", "timeout_seconds must be between 1 and 30")
+		return false
+	}
+	if webhook.FailurePolicy != WebhookFailClosed && webhook.FailurePolicy != WebhookFailOpen {
+		apierrors.Abort(c, http.StatusBadRequest, "invalid_failure_policy", "failure_policy must be one of: fail, ignore")
+		return false
+	}
+	for _, op := range operations {
+		if op != PolicyOperationCreate && op != PolicyOperationUpdate {
+			apierrors.Abort(c, http.StatusBadRequest, "invalid_operations", "operations must be a list of: create, update")
+			return false
+		}
+	}
+	return true
+}
+
+// ListValidationWebhooks retrieves the registered validation webhooks
+// GET /api/v1/admin/validation-webhooks
+func (vc *ValidationWebhookController) ListValidationWebhooks(c *gin.Context) {
+	if !requirePlatformAdmin(c) {
+		return
+	}
+
+	var webhooks []*ValidationWebhook
+	if err := vc.db.Order("name").Find(&webhooks).Error; err != nil {
+		log.Printf("Error listing validation webhooks: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to list validation webhooks")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"validation_webhooks": webhooks})
+}
+
+// CreateValidationWebhook registers a validation webhook. Requests to it are
+// signed with the secret, when one is given, in X-Nest-Signature-256.
+// POST /api/v1/admin/validation-webhooks
+func (vc *ValidationWebhookController) CreateValidationWebhook(c *gin.Context) {
+	if !requirePlatformAdmin(c) {
+		return
+	}
+	userID, _ := c.Get("user_id")
+
+	var req CreateValidationWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierrors.AbortWithDetails(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	webhook := &ValidationWebhook{
+		Name:           req.Name,
+		URL:            req.URL,
+		Secret:         req.Secret,
+		TimeoutSeconds: req.TimeoutSeconds,
+		FailurePolicy:  req.FailurePolicy,
+		Enabled:        true,
+		CreatedBy:      userID.(uint),
+	}
+	if webhook.TimeoutSeconds == 0 {
+		webhook.TimeoutSeconds = defaultWebhookTimeout
+	}
+	if webhook.FailurePolicy == "" {
+		webhook.FailurePolicy = WebhookFailClosed
+	}
+	if req.Enabled != nil {
+		webhook.Enabled = *req.Enabled
+	}
+	if len(req.Operations) > 0 {
+		operations, _ := json.Marshal(req.Operations)
+		webhook.Operations = datatypes.JSON(operations)
+	}
+	if !validWebhook(c, webhook, req.Operations) {
+		return
+	}
+
+	var existing int64
+	if err := vc.db.Model(&ValidationWebhook{}).Where("name = ?", webhook.Name).Count(&existing).Error; err != nil {
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to create validation webhook")
+		return
+	}
+	if existing > 0 {
+		apierrors.Abort(c, http.StatusConflict, apierrors.CodeConflict, "A validation webhook with this name already exists")
+		return
+	}
+
+	if !unitOfWork(c, vc.db, "Failed to create validation webhook", func(tx *gorm.DB) error {
+		if err := tx.Create(webhook).Error; err != nil {
+			return err
+		}
+		return audit.Record(c, tx, userID.(uint), "validation_webhooks", webhook.ID, nil, nil, webhook)
+	}) {
+		return
+	}
+
+	c.JSON(http.StatusCreated, webhook)
+}
+
+// UpdateValidationWebhook changes a validation webhook
+// PUT /api/v1/admin/validation-webhooks/:id
+func (vc *ValidationWebhookController) UpdateValidationWebhook(c *gin.Context) {
+	if !requirePlatformAdmin(c) {
+		return
+	}
+	userID, _ := c.Get("user_id")
+	webhook, ok := vc.loadWebhook(c)
+	if !ok {
+		return
+	}
+	before := *webhook
+
+	var req UpdateValidationWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierrors.AbortWithDetails(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Invalid request body", err.Error())
+		return
+	}
+	if req.URL != nil {
+		webhook.URL = *req.URL
+	}
+	if req.Secret != nil {
+		webhook.Secret = *req.Secret
+	}
+	if req.TimeoutSeconds != nil {
+		webhook.TimeoutSeconds = *req.TimeoutSeconds
+	}
+	if req.FailurePolicy != nil {
+		webhook.FailurePolicy = *req.FailurePolicy
+	}
+	if req.Enabled != nil {
+		webhook.Enabled = *req.Enabled
+	}
+	var operations []string
+	if req.Operations != nil {
+		operations = *req.Operations
+		webhook.Operations = nil
+		if len(operations) > 0 {
+			encoded, _ := json.Marshal(operations)
+			webhook.Operations = datatypes.JSON(encoded)
+		}
+	}
+	if !validWebhook(c, webhook, operations) {
+		return
+	}
+
+	if !unitOfWork(c, vc.db, "Failed to update validation webhook", func(tx *gorm.DB) error {
+		if err := tx.Save(webhook).Error; err != nil {
+			return err
+		}
+		return audit.Record(c, tx, userID.(uint), "validation_webhooks", webhook.ID, nil, &before, webhook)
+	}) {
+		return
+	}
+
+	c.JSON(http.StatusOK, webhook)
+}
+
+// DeleteValidationWebhook removes a validation webhook
+// DELETE /api/v1/admin/validation-webhooks/:id
+func (vc *ValidationWebhookController) DeleteValidationWebhook(c *gin.Context) {
+	if !requirePlatformAdmin(c) {
+		return
+	}
+	userID, _ := c.Get("user_id")
+	webhook, ok := vc.loadWebhook(c)
+	if !ok {
+		return
+	}
+
+	if !unitOfWork(c, vc.db, "Failed to delete validation webhook", func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Delete(webhook).Error; err != nil {
+			return err
+		}
+		return audit.Record(c, tx, userID.(uint), "validation_webhooks", webhook.ID, nil, webhook, nil)
+	}) {
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
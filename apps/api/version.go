@@ -0,0 +1,92 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Build information, set with -ldflags "-X main.version=..."
+var (
+	version   = ""
+	buildTime = "unknown"
+	gitCommit = "unknown"
+)
+
+// SchemaVersion is the version of the database schema the API migrates. It
+// is bumped with each migration that changes a table the K8s controller
+// reads or writes, along with the controller's own schema version.
+const SchemaVersion = 5
+
+// MinControllerSchemaVersion is the oldest controller schema version the
+// current schema still works with. Controllers built for an older schema,
+// or for a newer one than the API has migrated, are incompatible.
+const MinControllerSchemaVersion = 1
+
+// buildVersion returns the version of the API, from the build or VERSION
+func buildVersion() string {
+	if version != "" {
+		return version
+	}
+	if v := os.Getenv("VERSION"); v != "" {
+		return v
+	}
+	return "development"
+}
+
+// recordSchemaVersion records the schema version after migrations
+func recordSchemaVersion(db *gorm.DB) error {
+	info := SchemaInfo{
+		ID:                         1,
+		SchemaVersion:              SchemaVersion,
+		MinControllerSchemaVersion: MinControllerSchemaVersion,
+		APIVersion:                 buildVersion(),
+		MigratedAt:                 time.Now().UTC(),
+	}
+	return db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&info).Error
+}
+
+// controllerCompatible reports whether a controller built for a schema
+// version works with the API's schema. Controllers that predate schema
+// versions report none and are assumed compatible.
+func controllerCompatible(schemaVersion int) bool {
+	if schemaVersion == 0 {
+		return true
+	}
+	return schemaVersion >= MinControllerSchemaVersion && schemaVersion <= SchemaVersion
+}
+
+// getVersion reports the API's build information and schema versions
+// GET /api/v1/version
+func getVersion(c *gin.Context) {
+	c.JSON(http.StatusOK, VersionResponse{
+		Version:                    buildVersion(),
+		BuildTime:                  buildTime,
+		GitCommit:                  gitCommit,
+		GoVersion:                  runtime.Version(),
+		SchemaVersion:              SchemaVersion,
+		MinControllerSchemaVersion: MinControllerSchemaVersion,
+	})
+}
+
+// warnIncompatibleControllers logs running controllers whose schema version
+// the API's schema doesn't support, such as after an upgrade of the API
+func warnIncompatibleControllers(db *gorm.DB) {
+	var instances []*ControllerInstance
+	if err := db.Where("status <> ?", ControllerStatusStopped).Find(&instances).Error; err != nil {
+		log.Printf("Failed to check controller compatibility: %v", err)
+		return
+	}
+	for _, instance := range instances {
+		if !controllerCompatible(instance.SchemaVersion) {
+			log.Printf("Warning: controller %s (version %s) is built for schema version %d, outside the supported %d to %d; upgrade it",
+				instance.InstanceID, instance.Version, instance.SchemaVersion, MinControllerSchemaVersion, SchemaVersion)
+		}
+	}
+}
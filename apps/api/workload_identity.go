@@ -0,0 +1,215 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/penguintechinc/project-template/shared/apierrors"
+	"github.com/penguintechinc/project-template/shared/audit"
+	"github.com/penguintechinc/project-template/shared/fields"
+	"github.com/penguintechinc/project-template/shared/mtls"
+	"gorm.io/gorm"
+)
+
+// workloadSeenInterval bounds how often a workload's last_seen_at is
+// written, so that busy workloads don't update it on every request
+const workloadSeenInterval = time.Minute
+
+// WorkloadIdentityMiddleware authenticates callers that present an
+// X.509-SVID of trustDomain as the service account their SPIFFE ID is
+// mapped to, in place of static credentials. The SVID must have been
+// issued from the trust domain's bundle. Requests without one pass through
+// to PeerCertificateMiddleware.
+func WorkloadIdentityMiddleware(db *gorm.DB, identity *mtls.Identity, trustDomain string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.TLS == nil || len(c.Request.TLS.PeerCertificates) == 0 {
+			c.Next()
+			return
+		}
+		chain := c.Request.TLS.PeerCertificates
+		if spiffeID := mtls.SPIFFEID(chain[0]); spiffeID == nil || spiffeID.Host != trustDomain {
+			c.Next()
+			return
+		}
+
+		spiffeID, err := identity.VerifySVID(chain)
+		if err != nil {
+			log.Printf("Rejected X.509-SVID: %v", err)
+			apierrors.Abort(c, http.StatusUnauthorized, "invalid_svid", "The workload's SVID is not valid")
+			return
+		}
+
+		var workload WorkloadIdentity
+		if err := db.WithContext(c.Request.Context()).Preload("User").Where("spiffe_id = ?", spiffeID.String()).First(&workload).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				apierrors.Abort(c, http.StatusForbidden, "workload_not_mapped", "Workload identity is not mapped to a service account")
+			} else {
+				log.Printf("Error retrieving workload identity %s: %v", spiffeID, err)
+				apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to retrieve workload identity")
+			}
+			return
+		}
+		if workload.User == nil || !workload.User.IsActive {
+			apierrors.Abort(c, http.StatusForbidden, apierrors.CodeForbidden, "Service account is inactive")
+			return
+		}
+
+		now := time.Now()
+		if workload.LastSeenAt == nil || now.Sub(*workload.LastSeenAt) > workloadSeenInterval {
+			if err := db.WithContext(c.Request.Context()).Model(&workload).UpdateColumn("last_seen_at", now).Error; err != nil {
+				log.Printf("Error recording use of workload identity %s: %v", spiffeID, err)
+			}
+		}
+
+		c.Set("user_id", workload.UserID)
+		c.Set("user_role", workload.User.Role)
+		c.Set("workload_identity", spiffeID.String())
+		c.Set("peer_service", workload.User.Username)
+		c.Next()
+	}
+}
+
+// parseSPIFFEID validates a SPIFFE ID of trustDomain and returns it in
+// canonical form
+func parseSPIFFEID(raw, trustDomain string) (string, error) {
+	uri, err := url.Parse(raw)
+	if err != nil || uri.Scheme != mtls.URIScheme {
+		return "", fmt.Errorf("invalid SPIFFE ID: %s", raw)
+	}
+	if uri.Host != trustDomain {
+		return "", fmt.Errorf("SPIFFE ID must be in trust domain %s", trustDomain)
+	}
+	if uri.User != nil || uri.Port() != "" || uri.RawQuery != "" || uri.Fragment != "" || uri.Path == "" || uri.Path == "/" {
+		return "", fmt.Errorf("invalid SPIFFE ID: %s", raw)
+	}
+	return uri.String(), nil
+}
+
+// WorkloadIdentityController handles the SPIFFE ID to service account
+// mapping HTTP requests
+type WorkloadIdentityController struct {
+	db          *gorm.DB
+	trustDomain string
+}
+
+// NewWorkloadIdentityController creates a new workload identity controller
+func NewWorkloadIdentityController(db *gorm.DB, trustDomain string) *WorkloadIdentityController {
+	return &WorkloadIdentityController{db: db, trustDomain: trustDomain}
+}
+
+// ListWorkloadIdentities retrieves the mapped SPIFFE IDs
+// GET /api/v1/admin/workload-identities
+func (wc *WorkloadIdentityController) ListWorkloadIdentities(c *gin.Context) {
+	if !requirePlatformAdmin(c) {
+		return
+	}
+
+	var workloads []*WorkloadIdentity
+	if err := fields.Preload(c, wc.db, "User").Order("id").Find(&workloads).Error; err != nil {
+		log.Printf("Error listing workload identities: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to list workload identities")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"trust_domain":        wc.trustDomain,
+		"workload_identities": workloads,
+	})
+}
+
+// CreateWorkloadIdentity maps a SPIFFE ID to a service account
+// POST /api/v1/admin/workload-identities
+func (wc *WorkloadIdentityController) CreateWorkloadIdentity(c *gin.Context) {
+	if !requirePlatformAdmin(c) {
+		return
+	}
+
+	var req WorkloadIdentityRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierrors.AbortWithDetails(c, http.StatusBadRequest, apierrors.CodeInvalidRequest, "Invalid request body", err.Error())
+		return
+	}
+	spiffeID, err := parseSPIFFEID(req.SPIFFEID, wc.trustDomain)
+	if err != nil {
+		apierrors.Abort(c, http.StatusBadRequest, "invalid_spiffe_id", err.Error())
+		return
+	}
+
+	var user User
+	if err := wc.db.First(&user, req.UserID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierrors.Abort(c, http.StatusNotFound, apierrors.CodeNotFound, "User not found")
+		} else {
+			log.Printf("Error retrieving user %d: %v", req.UserID, err)
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to retrieve user")
+		}
+		return
+	}
+
+	var existing int64
+	if err := wc.db.Model(&WorkloadIdentity{}).Where("spiffe_id = ?", spiffeID).Count(&existing).Error; err != nil {
+		log.Printf("Error checking workload identity %s: %v", spiffeID, err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to create workload identity")
+		return
+	}
+	if existing > 0 {
+		apierrors.Abort(c, http.StatusConflict, apierrors.CodeConflict, "SPIFFE ID is already mapped")
+		return
+	}
+
+	userID := c.MustGet("user_id").(uint)
+	workload := &WorkloadIdentity{
+		SPIFFEID:    spiffeID,
+		UserID:      user.ID,
+		Description: req.Description,
+		CreatedBy:   userID,
+	}
+	if err := wc.db.Create(workload).Error; err != nil {
+		log.Printf("Error creating workload identity: %v", err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to create workload identity")
+		return
+	}
+	if err := audit.Record(c, wc.db, userID, "workload_identities", workload.ID, nil, nil, workload); err != nil {
+		log.Printf("Error recording creation of workload identity %d in the audit log: %v", workload.ID, err)
+	}
+
+	workload.User = &user
+	c.JSON(http.StatusCreated, workload)
+}
+
+// DeleteWorkloadIdentity removes a SPIFFE ID mapping, so that the workload
+// can no longer authenticate
+// DELETE /api/v1/admin/workload-identities/:id
+func (wc *WorkloadIdentityController) DeleteWorkloadIdentity(c *gin.Context) {
+	if !requirePlatformAdmin(c) {
+		return
+	}
+
+	var workload WorkloadIdentity
+	if err := wc.db.First(&workload, c.Param("id")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierrors.Abort(c, http.StatusNotFound, apierrors.CodeNotFound, "Workload identity not found")
+		} else {
+			log.Printf("Error retrieving workload identity: %v", err)
+			apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to retrieve workload identity")
+		}
+		return
+	}
+
+	if err := wc.db.Unscoped().Delete(&workload).Error; err != nil {
+		log.Printf("Error deleting workload identity %d: %v", workload.ID, err)
+		apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeDatabaseError, "Failed to delete workload identity")
+		return
+	}
+	userID := c.MustGet("user_id").(uint)
+	if err := audit.Record(c, wc.db, userID, "workload_identities", workload.ID, nil, workload, nil); err != nil {
+		log.Printf("Error recording deletion of workload identity %d in the audit log: %v", workload.ID, err)
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
@@ -67,6 +67,18 @@ async def healthz():
     return jsonify({"status": "healthy"}), 200
 
 
+# ACME HTTP-01 challenge endpoint, reached through the solver Ingress
+@app.route('/.well-known/acme-challenge/<token>', methods=['GET'])
+async def acme_challenge(token):
+    """Serve the key authorization of a pending HTTP-01 challenge"""
+    from lib.acme_issuer import AcmeIssuer
+
+    key_authorization = AcmeIssuer(db).challenge_response(token)
+    if key_authorization is None:
+        return jsonify({"error": "Not found"}), 404
+    return key_authorization, 200, {'Content-Type': 'text/plain'}
+
+
 # Metrics endpoint
 @app.route('/metrics', methods=['GET'])
 async def metrics():
@@ -3,6 +3,10 @@ Certificates Controller
 
 Handles certificate authority (CA) and certificate lifecycle management including:
 - CA creation, import, and deletion
+- CA hierarchies: signed and externally signed intermediates, team
+  constraints, and cross-signing
+- ACME issuers (e.g. Let's Encrypt) for publicly exposed resources
+- SSH CAs, which the API signs host access certificates with
 - Certificate generation and renewal
 - TLS integration with Kubernetes resources
 - RBAC-enforced access control
@@ -14,7 +18,24 @@ from datetime import datetime, timedelta
 import base64
 
 from lib.ca_manager import CAManager, CAManagerException
+from lib.ca_hierarchy import (
+    CAHierarchyException,
+    cross_sign_certificate,
+    generate_ca_csr,
+    is_issued_by,
+    is_self_signed,
+    sign_intermediate_ca,
+    split_pem_chain,
+    verify_signed_ca
+)
 from lib.k8s_client import KubernetesClient, KubernetesClientException
+from lib.ssh_ca import generate_ssh_ca_key
+from lib.acme_issuer import (
+    AcmeIssuer,
+    AcmeIssuerException,
+    LETSENCRYPT_DIRECTORY,
+    validate_acme_issuer
+)
 
 
 logger = logging.getLogger(__name__)
@@ -51,6 +72,7 @@ class CertificatesController:
         self.db = db
         self.k8s_client = k8s_client
         self.ca_manager = CAManager()
+        self.acme_issuer = AcmeIssuer(db)
 
     # ====== RBAC Helper Methods ======
 
@@ -142,6 +164,20 @@ class CertificatesController:
         if not role:
             raise CertificateAccessDenied("User is not member of this team")
 
+    def _check_ca_team(self, ca, team_id: int) -> None:
+        """
+        Check if a team may issue certificates from a CA.
+
+        Args:
+            ca: CA row
+            team_id: ID of team
+
+        Raises:
+            CertificateAccessDenied: If the CA is constrained to other teams
+        """
+        if ca.team_ids and team_id not in ca.team_ids:
+            raise CertificateAccessDenied(f"CA {ca.name} is not available to this team")
+
     def _create_audit_log(
         self,
         user_id: int,
@@ -188,11 +224,17 @@ class CertificatesController:
         country: str = "US",
         state: str = "CA",
         locality: str = "San Francisco",
-        validity_days: int = 3650
+        validity_days: int = 3650,
+        parent_id: Optional[int] = None,
+        path_length: Optional[int] = 0,
+        team_ids: Optional[List[int]] = None
     ) -> Dict[str, Any]:
         """
         Create a new internal Certificate Authority.
 
+        An intermediate with a parent is signed by that CA, and inherits its
+        team constraint unless given a narrower one.
+
         Args:
             name: CA name for identification
             ca_type: Type of CA (root or intermediate)
@@ -204,19 +246,47 @@ class CertificatesController:
             state: State/province (default: CA)
             locality: Locality (default: San Francisco)
             validity_days: Certificate validity in days (default: 3650 = 10 years)
+            parent_id: CA signing an intermediate (optional)
+            path_length: Maximum CAs below a signed intermediate; None for unlimited (default: 0)
+            team_ids: Teams allowed to issue from the CA; empty for all teams
 
         Returns:
             Dictionary with CA details including id, certificate, and metadata
 
         Raises:
             CertificateAccessDenied: If user is not global admin
+            CertificateNotFound: If the parent CA is not found
+            ValueError: If the parent CA can't sign the intermediate
             CAManagerException: If CA generation fails
         """
         self._check_ca_access(user_id)
 
+        parent = None
+        if parent_id:
+            if ca_type != 'intermediate':
+                raise ValueError("Only intermediate CAs can have a parent")
+            parent = self._load_signing_ca(parent_id)
+            self._check_child_path_length(parent, path_length)
+            team_ids = self._child_team_ids(parent, team_ids)
+        else:
+            path_length = None
+
         try:
             # Generate CA certificate
-            if ca_type == 'root':
+            if parent:
+                cert_data = sign_intermediate_ca(
+                    parent_certificate_pem=parent.certificate,
+                    parent_private_key_pem=parent.private_key,
+                    common_name=common_name,
+                    organization=organization,
+                    organization_unit=organization_unit,
+                    country=country,
+                    state=state,
+                    locality=locality,
+                    validity_days=validity_days,
+                    path_length=path_length
+                )
+            elif ca_type == 'root':
                 cert_data = self.ca_manager.generate_root_ca(
                     common_name=common_name,
                     organization=organization,
@@ -254,6 +324,9 @@ class CertificatesController:
                 valid_until=self.ca_manager.get_certificate_not_after(cert_obj),
                 serial_number=str(self.ca_manager.get_certificate_serial_number(cert_obj)),
                 is_nest_managed=True,
+                parent_id=parent_id,
+                path_length=path_length,
+                team_ids=team_ids or [],
                 created_by=user_id
             )
             self.db.commit()
@@ -267,7 +340,9 @@ class CertificatesController:
                 details={
                     'name': name,
                     'type': ca_type,
-                    'common_name': common_name
+                    'common_name': common_name,
+                    'parent_id': parent_id,
+                    'team_ids': team_ids or []
                 }
             )
 
@@ -277,6 +352,8 @@ class CertificatesController:
                 'id': ca_id,
                 'name': name,
                 'type': ca_type,
+                'parent_id': parent_id,
+                'team_ids': team_ids or [],
                 'subject': self.ca_manager.get_certificate_subject(cert_obj),
                 'issuer': self.ca_manager.get_certificate_issuer(cert_obj),
                 'valid_from': self.ca_manager.get_certificate_not_before(cert_obj),
@@ -288,6 +365,431 @@ class CertificatesController:
             logger.error(f"Failed to create CA: {e}")
             raise
 
+    def create_intermediate_csr(
+        self,
+        name: str,
+        common_name: str,
+        organization: str,
+        user_id: int,
+        organization_unit: Optional[str] = None,
+        country: str = "US",
+        state: str = "CA",
+        locality: str = "San Francisco",
+        path_length: Optional[int] = 0,
+        team_ids: Optional[List[int]] = None
+    ) -> Dict[str, Any]:
+        """
+        Create an intermediate CA to be signed by an external CA.
+
+        The CA is pending_signature, and can't issue certificates, until the
+        certificate signed from its CSR is imported with
+        import_signed_intermediate().
+
+        Args:
+            name: CA name for identification
+            common_name: CN for CA certificate
+            organization: Organization name
+            user_id: User creating CA
+            organization_unit: Optional organization unit
+            country: Country code (default: US)
+            state: State/province (default: CA)
+            locality: Locality (default: San Francisco)
+            path_length: Maximum CAs below the intermediate requested; None for unlimited (default: 0)
+            team_ids: Teams allowed to issue from the CA; empty for all teams
+
+        Returns:
+            Dictionary with CA id, status, and the PEM-encoded CSR
+
+        Raises:
+            CertificateAccessDenied: If user is not global admin
+        """
+        self._check_ca_access(user_id)
+
+        try:
+            csr_data = generate_ca_csr(
+                common_name=common_name,
+                organization=organization,
+                organization_unit=organization_unit,
+                country=country,
+                state=state,
+                locality=locality,
+                path_length=path_length
+            )
+
+            ca_id = self.db.certificate_authorities.insert(
+                name=name,
+                type='intermediate',
+                certificate='',
+                private_key=csr_data['private_key'],
+                csr=csr_data['csr'],
+                status='pending_signature',
+                is_nest_managed=True,
+                path_length=path_length,
+                team_ids=team_ids or [],
+                created_by=user_id
+            )
+            self.db.commit()
+
+            # Create audit log
+            self._create_audit_log(
+                user_id=user_id,
+                action='ca_csr_created',
+                resource_type='certificate_authority',
+                resource_id=ca_id,
+                details={'name': name, 'common_name': common_name}
+            )
+
+            logger.info(f"Created CSR for intermediate CA '{name}' with ID {ca_id}")
+
+            return {
+                'id': ca_id,
+                'name': name,
+                'type': 'intermediate',
+                'status': 'pending_signature',
+                'csr': csr_data['csr']
+            }
+
+        except Exception as e:
+            logger.error(f"Failed to create intermediate CSR: {e}")
+            raise
+
+    def import_signed_intermediate(
+        self,
+        ca_id: int,
+        certificate_pem: str,
+        user_id: int,
+        chain_pem: Optional[str] = None
+    ) -> Dict[str, Any]:
+        """
+        Import the certificate an external CA signed from an intermediate's CSR.
+
+        If a NEST CA signed the certificate, the intermediate is linked to it
+        as its parent; otherwise chain_pem should hold the external chain up
+        to the root, which is served with the CA's certificates.
+
+        Args:
+            ca_id: ID of the pending intermediate
+            certificate_pem: PEM-encoded signed certificate
+            user_id: User importing certificate
+            chain_pem: PEM-encoded chain above the certificate (optional)
+
+        Returns:
+            Dictionary with CA details
+
+        Raises:
+            CertificateAccessDenied: If user is not global admin
+            CertificateNotFound: If CA not found
+            ValueError: If the CA isn't pending or the certificate doesn't match its CSR
+        """
+        self._check_ca_access(user_id)
+
+        ca = self.db.certificate_authorities[ca_id]
+        if not ca or ca.deleted_at:
+            raise CertificateNotFound(f"CA {ca_id} not found")
+        if ca.status != 'pending_signature':
+            raise ValueError(f"CA {ca.name} is not awaiting a signed certificate")
+
+        try:
+            verify_signed_ca(certificate_pem, ca.private_key)
+            chain = split_pem_chain(chain_pem)
+        except CAHierarchyException as e:
+            raise ValueError(str(e))
+
+        parent_id = None
+        signers = self.db(
+            (self.db.certificate_authorities.deleted_at == None) &
+            (self.db.certificate_authorities.status == 'active') &
+            (~self.db.certificate_authorities.type.belongs(['acme', 'ssh'])) &
+            (self.db.certificate_authorities.id != ca_id)
+        ).select()
+        for signer in signers:
+            if signer.certificate and is_issued_by(certificate_pem, signer.certificate):
+                parent_id = signer.id
+                break
+
+        try:
+            cert_obj = self.ca_manager.parse_certificate(certificate_pem)
+
+            ca.update_record(
+                certificate=certificate_pem,
+                chain=''.join(chain) or None,
+                csr=None,
+                status='active',
+                parent_id=parent_id,
+                subject=self.ca_manager.get_certificate_subject(cert_obj),
+                issuer=self.ca_manager.get_certificate_issuer(cert_obj),
+                valid_from=self.ca_manager.get_certificate_not_before(cert_obj),
+                valid_until=self.ca_manager.get_certificate_not_after(cert_obj),
+                serial_number=str(self.ca_manager.get_certificate_serial_number(cert_obj))
+            )
+            self.db.commit()
+
+            # Create audit log
+            self._create_audit_log(
+                user_id=user_id,
+                action='ca_signed_certificate_imported',
+                resource_type='certificate_authority',
+                resource_id=ca_id,
+                details={'name': ca.name, 'parent_id': parent_id}
+            )
+
+            logger.info(f"Imported signed certificate for intermediate CA {ca_id}")
+
+            return {
+                'id': ca_id,
+                'name': ca.name,
+                'type': ca.type,
+                'status': 'active',
+                'parent_id': parent_id,
+                'subject': self.ca_manager.get_certificate_subject(cert_obj),
+                'issuer': self.ca_manager.get_certificate_issuer(cert_obj),
+                'valid_from': self.ca_manager.get_certificate_not_before(cert_obj),
+                'valid_until': self.ca_manager.get_certificate_not_after(cert_obj)
+            }
+
+        except Exception as e:
+            logger.error(f"Failed to import signed intermediate: {e}")
+            raise
+
+    def cross_sign_ca(
+        self,
+        ca_id: int,
+        signer_id: int,
+        user_id: int,
+        validity_days: int = 1825
+    ) -> Dict[str, Any]:
+        """
+        Cross-sign a CA under another CA.
+
+        The cross-signed certificate keeps the CA's subject and key, so its
+        certificates are trusted by clients of either root, such as while
+        migrating to a new root.
+
+        Args:
+            ca_id: ID of CA to cross-sign
+            signer_id: ID of signing CA
+            user_id: User cross-signing
+            validity_days: Validity in days, capped at the signer's (default: 1825 = 5 years)
+
+        Returns:
+            Dictionary with cross-signature details including id and certificate
+
+        Raises:
+            CertificateAccessDenied: If user is not global admin
+            CertificateNotFound: If either CA is not found
+            ValueError: If the signer can't sign the CA
+        """
+        self._check_ca_access(user_id)
+
+        if ca_id == signer_id:
+            raise ValueError("A CA can't cross-sign itself")
+
+        ca = self.db.certificate_authorities[ca_id]
+        if not ca or ca.deleted_at:
+            raise CertificateNotFound(f"CA {ca_id} not found")
+        if ca.type in ('acme', 'ssh') or ca.status != 'active':
+            raise ValueError(f"CA {ca.name} can't be cross-signed")
+        signer = self._load_signing_ca(signer_id)
+
+        try:
+            certificate = cross_sign_certificate(
+                certificate_pem=ca.certificate,
+                signer_certificate_pem=signer.certificate,
+                signer_private_key_pem=signer.private_key,
+                validity_days=validity_days
+            )
+            cert_obj = self.ca_manager.parse_certificate(certificate)
+
+            cross_id = self.db.ca_cross_signatures.insert(
+                ca_id=ca_id,
+                signer_id=signer_id,
+                certificate=certificate,
+                valid_from=self.ca_manager.get_certificate_not_before(cert_obj),
+                valid_until=self.ca_manager.get_certificate_not_after(cert_obj),
+                serial_number=str(self.ca_manager.get_certificate_serial_number(cert_obj)),
+                created_by=user_id
+            )
+            self.db.commit()
+
+            # Create audit log
+            self._create_audit_log(
+                user_id=user_id,
+                action='ca_cross_signed',
+                resource_type='certificate_authority',
+                resource_id=ca_id,
+                details={'name': ca.name, 'signer_id': signer_id}
+            )
+
+            logger.info(f"Cross-signed CA {ca_id} under CA {signer_id}")
+
+            return {
+                'id': cross_id,
+                'ca_id': ca_id,
+                'signer_id': signer_id,
+                'certificate': certificate,
+                'valid_from': self.ca_manager.get_certificate_not_before(cert_obj),
+                'valid_until': self.ca_manager.get_certificate_not_after(cert_obj)
+            }
+
+        except CAHierarchyException as e:
+            raise ValueError(str(e))
+        except Exception as e:
+            logger.error(f"Failed to cross-sign CA: {e}")
+            raise
+
+    def set_ca_teams(self, ca_id: int, team_ids: List[int], user_id: int) -> Dict[str, Any]:
+        """
+        Constrain a CA to teams.
+
+        Existing certificates are unaffected; only new certificates are
+        checked against the constraint.
+
+        Args:
+            ca_id: ID of CA
+            team_ids: Teams allowed to issue from the CA; empty for all teams
+            user_id: User updating CA
+
+        Returns:
+            Dictionary with CA id and team_ids
+
+        Raises:
+            CertificateAccessDenied: If user is not global admin
+            CertificateNotFound: If CA not found
+            ValueError: If the teams fall outside the parent CA's constraint
+        """
+        self._check_ca_access(user_id)
+
+        ca = self.db.certificate_authorities[ca_id]
+        if not ca or ca.deleted_at:
+            raise CertificateNotFound(f"CA {ca_id} not found")
+
+        if ca.parent_id:
+            team_ids = self._child_team_ids(self.db.certificate_authorities[ca.parent_id], team_ids)
+
+        previous = list(ca.team_ids or [])
+        ca.update_record(team_ids=team_ids or [])
+        self.db.commit()
+
+        # Create audit log
+        self._create_audit_log(
+            user_id=user_id,
+            action='ca_teams_updated',
+            resource_type='certificate_authority',
+            resource_id=ca_id,
+            details={'name': ca.name, 'previous': previous, 'team_ids': team_ids or []}
+        )
+
+        return {'id': ca_id, 'team_ids': team_ids or []}
+
+    def _load_signing_ca(self, ca_id: int):
+        """
+        Load a CA that signs intermediates or cross-signatures.
+
+        Args:
+            ca_id: ID of CA
+
+        Returns:
+            CA row
+
+        Raises:
+            CertificateNotFound: If CA not found
+            ValueError: If the CA can't sign CAs
+        """
+        ca = self.db.certificate_authorities[ca_id]
+        if not ca or ca.deleted_at:
+            raise CertificateNotFound(f"CA {ca_id} not found")
+        if ca.type in ('acme', 'ssh') or ca.status != 'active' or not ca.private_key:
+            raise ValueError(f"CA {ca.name} can't sign CAs")
+        if ca.path_length is not None and ca.path_length < 1:
+            raise ValueError(f"CA {ca.name} can't sign CAs: its path length is 0")
+        return ca
+
+    def _check_child_path_length(self, parent, path_length: Optional[int]) -> None:
+        """
+        Check an intermediate's path length fits under its parent's.
+
+        Raises:
+            ValueError: If the path length exceeds what the parent allows
+        """
+        if parent.path_length is None:
+            return
+        allowed = parent.path_length - 1
+        if path_length is None or path_length > allowed:
+            raise ValueError(f"Path length can be at most {allowed} under CA {parent.name}")
+
+    def _child_team_ids(self, parent, team_ids: Optional[List[int]]) -> List[int]:
+        """
+        Resolve an intermediate's team constraint against its parent's.
+
+        Returns:
+            The requested teams, or the parent's when none are requested
+
+        Raises:
+            ValueError: If the teams fall outside the parent's constraint
+        """
+        if not parent or not parent.team_ids:
+            return team_ids or []
+        if not team_ids:
+            return list(parent.team_ids)
+        outside = set(team_ids) - set(parent.team_ids)
+        if outside:
+            raise ValueError(
+                f"CA {parent.name} is not available to teams {sorted(outside)}"
+            )
+        return team_ids
+
+    def _issuing_chain(self, ca) -> List[str]:
+        """
+        Build the chain of CA certificates from a CA up to, not including, its root.
+
+        Args:
+            ca: Issuing CA row
+
+        Returns:
+            List of PEM certificates, issuing CA first
+        """
+        if ca.type in ('acme', 'ssh'):
+            return []
+
+        chain = []
+        seen = set()
+        current = ca
+        while current and current.id not in seen:
+            seen.add(current.id)
+            if not current.certificate or is_self_signed(current.certificate):
+                break
+            chain.append(current.certificate)
+            if current.parent_id:
+                current = self.db.certificate_authorities[current.parent_id]
+                continue
+            # Externally signed: the rest of the chain was imported with it
+            chain.extend(pem for pem in split_pem_chain(current.chain) if not is_self_signed(pem))
+            break
+        return chain
+
+    def _root_certificate(self, ca) -> Optional[str]:
+        """
+        Find the root certificate a CA chains to, if known.
+
+        Args:
+            ca: CA row
+
+        Returns:
+            PEM root certificate, or None if the root isn't held
+        """
+        seen = set()
+        current = ca
+        while current and current.id not in seen:
+            seen.add(current.id)
+            if current.certificate and is_self_signed(current.certificate):
+                return current.certificate
+            if current.parent_id:
+                current = self.db.certificate_authorities[current.parent_id]
+                continue
+            roots = [pem for pem in split_pem_chain(current.chain) if is_self_signed(pem)]
+            return roots[0] if roots else None
+        return None
+
     def import_ca(
         self,
         name: str,
@@ -361,6 +863,169 @@ class CertificatesController:
             logger.error(f"Failed to import CA: {e}")
             raise
 
+    def create_acme_issuer(
+        self,
+        name: str,
+        email: str,
+        user_id: int,
+        directory_url: str = LETSENCRYPT_DIRECTORY,
+        challenge: str = 'http-01',
+        ingress_class: Optional[str] = None,
+        dns_provider: Optional[str] = None,
+        dns_config: Optional[Dict[str, Any]] = None
+    ) -> Dict[str, Any]:
+        """
+        Add an ACME issuer, registering an account with the ACME CA.
+
+        Certificates from the issuer are validated with HTTP-01 through an
+        Ingress for each domain, or with DNS-01 through a DNS provider, so
+        they are only for publicly resolvable names.
+
+        Args:
+            name: Issuer name for identification
+            email: Contact email for expiry notices from the CA
+            user_id: User creating issuer
+            directory_url: ACME directory URL (default: Let's Encrypt production)
+            challenge: Challenge type, http-01 or dns-01 (default: http-01)
+            ingress_class: Ingress class serving HTTP-01 challenges (optional)
+            dns_provider: DNS provider for DNS-01 challenges, e.g. route53 or cloudflare
+            dns_config: DNS provider configuration and credentials
+
+        Returns:
+            Dictionary with issuer details including id
+
+        Raises:
+            CertificateAccessDenied: If user is not global admin
+            ValueError: If the challenge settings are invalid
+            AcmeIssuerException: If account registration fails
+        """
+        self._check_ca_access(user_id)
+        validate_acme_issuer(challenge, dns_provider, dns_config)
+
+        try:
+            account = self.acme_issuer.register_account(directory_url, email)
+
+            ca_id = self.db.certificate_authorities.insert(
+                name=name,
+                type='acme',
+                certificate='',
+                private_key=account['private_key'],
+                subject=directory_url,
+                issuer=directory_url,
+                is_nest_managed=False,
+                acme_directory_url=directory_url,
+                acme_email=email,
+                acme_account_url=account['account_url'],
+                acme_challenge=challenge,
+                acme_ingress_class=ingress_class,
+                acme_dns_provider=dns_provider,
+                acme_dns_config=dns_config,
+                created_by=user_id
+            )
+            self.db.commit()
+
+            # Create audit log
+            self._create_audit_log(
+                user_id=user_id,
+                action='acme_issuer_created',
+                resource_type='certificate_authority',
+                resource_id=ca_id,
+                details={
+                    'name': name,
+                    'directory_url': directory_url,
+                    'challenge': challenge,
+                    'dns_provider': dns_provider
+                }
+            )
+
+            logger.info(f"Created ACME issuer '{name}' with ID {ca_id}")
+
+            return {
+                'id': ca_id,
+                'name': name,
+                'type': 'acme',
+                'directory_url': directory_url,
+                'account_url': account['account_url'],
+                'challenge': challenge
+            }
+
+        except Exception as e:
+            logger.error(f"Failed to create ACME issuer: {e}")
+            raise
+
+    def create_ssh_ca(
+        self,
+        name: str,
+        user_id: int,
+        team_ids: Optional[List[int]] = None
+    ) -> Dict[str, Any]:
+        """
+        Create an SSH CA for access to the hosts of agent-managed resources.
+
+        The API signs short-lived user certificates with the newest SSH CA
+        available to a resource's team, and agents install the public keys
+        of their teams' SSH CAs as trusted. Keep the previous CA until its
+        certificates expire when rotating.
+
+        Args:
+            name: CA name for identification
+            user_id: User creating CA
+            team_ids: Teams allowed to use the CA; empty for all teams
+
+        Returns:
+            Dictionary with CA details including id, public_key, and fingerprint
+
+        Raises:
+            CertificateAccessDenied: If user is not global admin
+        """
+        self._check_ca_access(user_id)
+
+        try:
+            key_data = generate_ssh_ca_key(f"nest-ssh-ca-{name}")
+
+            ca_id = self.db.certificate_authorities.insert(
+                name=name,
+                type='ssh',
+                certificate=key_data['public_key'],
+                private_key=key_data['private_key'],
+                subject=name,
+                issuer=name,
+                valid_from=datetime.now(),
+                serial_number=key_data['fingerprint'],
+                is_nest_managed=True,
+                team_ids=team_ids or [],
+                created_by=user_id
+            )
+            self.db.commit()
+
+            # Create audit log
+            self._create_audit_log(
+                user_id=user_id,
+                action='ssh_ca_created',
+                resource_type='certificate_authority',
+                resource_id=ca_id,
+                details={
+                    'name': name,
+                    'fingerprint': key_data['fingerprint'],
+                    'team_ids': team_ids or []
+                }
+            )
+
+            logger.info(f"Created SSH CA '{name}' with ID {ca_id}")
+
+            return {
+                'id': ca_id,
+                'name': name,
+                'type': 'ssh',
+                'public_key': key_data['public_key'],
+                'fingerprint': key_data['fingerprint'],
+                'team_ids': team_ids or []
+            }
+
+        except Exception as e:
+            logger.error(f"Failed to create SSH CA: {e}")
+            raise
+
     def list_cas(self, user_id: int) -> List[Dict[str, Any]]:
         """
         List all Certificate Authorities (GlobalAdmin only).
@@ -388,6 +1053,10 @@ class CertificatesController:
                 'valid_from': ca.valid_from,
                 'valid_until': ca.valid_until,
                 'is_nest_managed': ca.is_nest_managed,
+                'status': ca.status,
+                'parent_id': ca.parent_id,
+                'path_length': ca.path_length,
+                'team_ids': ca.team_ids or [],
                 'created_at': ca.created_at
             }
             for ca in cas
@@ -414,6 +1083,11 @@ class CertificatesController:
         if not ca or ca.deleted_at:
             raise CertificateNotFound(f"CA {ca_id} not found")
 
+        cross_signatures = self.db(
+            (self.db.ca_cross_signatures.ca_id == ca_id) &
+            (self.db.ca_cross_signatures.deleted_at == None)
+        ).select()
+
         return {
             'id': ca.id,
             'name': ca.name,
@@ -423,10 +1097,29 @@ class CertificatesController:
             'valid_from': ca.valid_from,
             'valid_until': ca.valid_until,
             'is_nest_managed': ca.is_nest_managed,
+            'status': ca.status,
+            'parent_id': ca.parent_id,
+            'path_length': ca.path_length,
+            'team_ids': ca.team_ids or [],
             'created_by': ca.created_by,
             'created_at': ca.created_at,
             'updated_at': ca.updated_at,
-            'certificate': ca.certificate
+            'certificate': ca.certificate,
+            'chain': ca.chain,
+            'csr': ca.csr,
+            'cross_signatures': [
+                {
+                    'id': cross.id,
+                    'signer_id': cross.signer_id,
+                    'certificate': cross.certificate,
+                    'valid_until': cross.valid_until
+                }
+                for cross in cross_signatures
+            ],
+            'acme_directory_url': ca.acme_directory_url,
+            'acme_email': ca.acme_email,
+            'acme_challenge': ca.acme_challenge,
+            'acme_dns_provider': ca.acme_dns_provider
         }
 
     def delete_ca(self, ca_id: int, user_id: int) -> None:
@@ -461,6 +1154,17 @@ class CertificatesController:
                 f"Cannot delete CA: {dependent_certs} certificate(s) depend on it"
             )
 
+        # Check for intermediates it signed
+        dependent_cas = self.db(
+            (self.db.certificate_authorities.parent_id == ca_id) &
+            (self.db.certificate_authorities.deleted_at == None)
+        ).count()
+
+        if dependent_cas > 0:
+            raise ValueError(
+                f"Cannot delete CA: {dependent_cas} intermediate CA(s) depend on it"
+            )
+
         # Soft delete
         ca.update_record(deleted_at=datetime.now())
         self.db.commit()
@@ -493,6 +1197,10 @@ class CertificatesController:
         if not ca or ca.deleted_at:
             raise CertificateNotFound(f"CA {ca_id} not found")
 
+        # SSH CAs hold their public key in place of a certificate
+        if ca.type == 'ssh':
+            return ca.certificate
+
         # Extract public key from certificate
         public_key = self.ca_manager.extract_public_key_from_cert(ca.certificate)
         return public_key
@@ -513,6 +1221,8 @@ class CertificatesController:
         Generate a certificate for a resource.
 
         Builds SAN list from resource metadata and creates Kubernetes Secret if applicable.
+        Certificates from an ACME issuer are ordered for the common name
+        only, as the CA can't validate cluster-internal names.
 
         Args:
             resource_id: ID of resource to certificate
@@ -520,7 +1230,7 @@ class CertificatesController:
             common_name: Certificate common name
             auto_renew: Enable automatic renewal (default: True)
             renewal_threshold_days: Days before expiry to trigger renewal (default: 30)
-            validity_days: Certificate validity in days (default: 365)
+            validity_days: Certificate validity in days (default: 365; set by the CA for ACME)
             user_id: User generating certificate
 
         Returns:
@@ -528,7 +1238,8 @@ class CertificatesController:
 
         Raises:
             CertificateNotFound: If resource or CA not found
-            CertificateAccessDenied: If user lacks permission
+            CertificateAccessDenied: If user lacks permission or the CA is constrained to other teams
+            ValueError: If the CA is awaiting its signed certificate or is an SSH CA
             Exception: If certificate generation fails
         """
         # Load resource
@@ -544,8 +1255,18 @@ class CertificatesController:
         ca = self.db.certificate_authorities[ca_id]
         if not ca or ca.deleted_at:
             raise CertificateNotFound(f"CA {ca_id} not found")
+        if ca.status != 'active':
+            raise ValueError(f"CA {ca.name} is awaiting its signed certificate")
+        if ca.type == 'ssh':
+            raise ValueError(f"CA {ca.name} only signs SSH certificates")
+        self._check_ca_team(ca, resource.team_id)
 
         try:
+            if ca.type == 'acme':
+                return self._generate_acme_certificate(
+                    resource, ca, common_name, auto_renew, renewal_threshold_days, user_id
+                )
+
             # Build SAN list
             san_dns = [resource.name]
             san_ips = []
@@ -604,11 +1325,12 @@ class CertificatesController:
             resource.update_record(tls_cert_id=cert_id)
             self.db.commit()
 
-            # Create Kubernetes Secret if applicable
+            # Create Kubernetes Secret if applicable, with the intermediates
+            # clients need to reach the root
             if resource.k8s_namespace and self.k8s_client:
                 self._create_k8s_secret(
                     resource,
-                    cert_data['certificate'],
+                    cert_data['certificate'] + ''.join(self._issuing_chain(ca)),
                     cert_data['private_key']
                 )
 
@@ -645,6 +1367,130 @@ class CertificatesController:
             logger.error(f"Failed to generate certificate: {e}")
             raise
 
+    def _generate_acme_certificate(
+        self,
+        resource,
+        ca,
+        common_name: str,
+        auto_renew: bool,
+        renewal_threshold_days: int,
+        user_id: Optional[int]
+    ) -> Dict[str, Any]:
+        """
+        Order a certificate for a resource's public name from an ACME issuer.
+
+        Args:
+            resource: Resource to certificate
+            ca: ACME issuer
+            common_name: Public DNS name of the resource
+            auto_renew: Enable automatic renewal
+            renewal_threshold_days: Days before expiry to trigger renewal
+            user_id: User generating certificate
+
+        Returns:
+            Dictionary with certificate details including id and order_id
+
+        Raises:
+            AcmeIssuerException: If the order fails
+        """
+        san_dns = [common_name]
+        issued = self.acme_issuer.issue(ca, san_dns, resource_id=resource.id)
+
+        cert_id = self.db.certificates.insert(
+            resource_id=resource.id,
+            ca_id=ca.id,
+            certificate=issued['certificate'],
+            private_key=issued['private_key'],
+            common_name=common_name,
+            san_dns=san_dns,
+            san_ips=[],
+            valid_from=issued['valid_from'],
+            valid_until=issued['valid_until'],
+            serial_number=issued['serial_number'],
+            auto_renew=auto_renew,
+            renewal_threshold_days=renewal_threshold_days
+        )
+        self.db(self.db.acme_orders.id == issued['order_id']).update(certificate_id=cert_id)
+
+        # Update resource with certificate reference
+        resource.update_record(tls_cert_id=cert_id)
+        self.db.commit()
+
+        # Create Kubernetes Secret if applicable
+        if resource.k8s_namespace and self.k8s_client:
+            self._create_k8s_secret(resource, issued['certificate'], issued['private_key'])
+
+        # Create audit log
+        self._create_audit_log(
+            user_id=user_id,
+            action='certificate_generated',
+            resource_type='certificate',
+            resource_id=cert_id,
+            team_id=resource.team_id,
+            details={
+                'resource_id': resource.id,
+                'ca_id': ca.id,
+                'common_name': common_name,
+                'auto_renew': auto_renew,
+                'acme_order_id': issued['order_id']
+            }
+        )
+
+        logger.info(f"Issued ACME certificate {cert_id} for resource {resource.id}")
+
+        return {
+            'id': cert_id,
+            'resource_id': resource.id,
+            'ca_id': ca.id,
+            'common_name': common_name,
+            'san_dns': san_dns,
+            'san_ips': [],
+            'valid_from': issued['valid_from'],
+            'valid_until': issued['valid_until'],
+            'auto_renew': auto_renew,
+            'order_id': issued['order_id']
+        }
+
+    def list_acme_orders(self, resource_id: int, user_id: int) -> List[Dict[str, Any]]:
+        """
+        List the ACME orders placed for a resource, newest first.
+
+        Args:
+            resource_id: ID of resource
+            user_id: User requesting list
+
+        Returns:
+            List of order dictionaries
+
+        Raises:
+            CertificateAccessDenied: If user lacks permission
+            CertificateNotFound: If resource not found
+        """
+        resource = self.db.resources[resource_id]
+        if not resource or resource.deleted_at:
+            raise CertificateNotFound(f"Resource {resource_id} not found")
+
+        self._check_certificate_view(user_id, resource.team_id)
+
+        orders = self.db(self.db.acme_orders.resource_id == resource_id).select(
+            orderby=~self.db.acme_orders.created_at
+        )
+
+        return [
+            {
+                'id': order.id,
+                'ca_id': order.ca_id,
+                'certificate_id': order.certificate_id,
+                'domains': order.domains,
+                'challenge_type': order.challenge_type,
+                'status': order.status,
+                'error_message': order.error_message,
+                'created_at': order.created_at,
+                'completed_at': order.completed_at
+            }
+            for order in orders
+        ]
+
     def list_certificates(self, resource_id: int, user_id: int) -> List[Dict[str, Any]]:
         """
         List all certificates for a resource.
@@ -685,6 +1531,67 @@ class CertificatesController:
             for cert in certs
         ]
 
+    def get_certificate_chain(self, cert_id: int, user_id: int) -> Dict[str, Any]:
+        """
+        Get a certificate with the chain of CAs that issued it, for download.
+
+        Args:
+            cert_id: ID of certificate
+            user_id: User requesting chain
+
+        Returns:
+            Dictionary with the PEM certificate, chain (intermediates, issuing
+            CA first), fullchain (both), root (None if not held), and
+            cross_signed (cross-signatures of CAs in the chain)
+
+        Raises:
+            CertificateAccessDenied: If user lacks permission
+            CertificateNotFound: If certificate not found
+        """
+        cert = self.db.certificates[cert_id]
+        if not cert or cert.deleted_at:
+            raise CertificateNotFound(f"Certificate {cert_id} not found")
+
+        resource = self.db.resources[cert.resource_id]
+        self._check_certificate_view(user_id, resource.team_id)
+
+        ca = self.db.certificate_authorities[cert.ca_id]
+
+        # ACME certificates are stored with the chain the CA returned
+        pems = split_pem_chain(cert.certificate)
+        leaf = pems[0] if pems else cert.certificate
+        if len(pems) > 1:
+            chain = [pem for pem in pems[1:] if not is_self_signed(pem)]
+            root = None
+        else:
+            chain = self._issuing_chain(ca) if ca else []
+            root = self._root_certificate(ca) if ca else None
+
+        cross_signed = []
+        chain_cas = [ca.id] if ca else []
+        current = ca
+        while current and current.parent_id and current.parent_id not in chain_cas:
+            chain_cas.append(current.parent_id)
+            current = self.db.certificate_authorities[current.parent_id]
+        if chain_cas:
+            cross_signed = [
+                cross.certificate
+                for cross in self.db(
+                    (self.db.ca_cross_signatures.ca_id.belongs(chain_cas)) &
+                    (self.db.ca_cross_signatures.deleted_at == None)
+                ).select()
+            ]
+
+        return {
+            'id': cert.id,
+            'common_name': cert.common_name,
+            'certificate': leaf,
+            'chain': ''.join(chain),
+            'fullchain': leaf + ''.join(chain),
+            'root': root,
+            'cross_signed': cross_signed
+        }
+
     def renew_certificate(self, cert_id: int, user_id: int) -> Dict[str, Any]:
         """
         Manually renew a certificate.
@@ -861,6 +861,13 @@ class ProvisioningController:
             f'{resource_prefix}_replicas': 1,
             'storage_class': 'standard',
             f'{resource_prefix}_storage_size': '10Gi',
+            # Dual-stack Services where the cluster is, single-stack on
+            # IPv4-only and IPv6-only clusters
+            'ip_family_policy': os.getenv('SERVICE_IP_FAMILY_POLICY', 'PreferDualStack'),
+            'ip_families': [
+                family.strip() for family in os.getenv('SERVICE_IP_FAMILIES', '').split(',')
+                if family.strip()
+            ],
         }
 
         # Add credentials to context
@@ -0,0 +1,396 @@
+"""
+ACME Issuer
+
+Issues certificates from ACME certificate authorities such as Let's Encrypt,
+for resources exposed outside the cluster. An ACME issuer is stored as a
+certificate_authorities row of type 'acme', whose private_key is the ACME
+account key. Each order is tracked in acme_orders.
+
+Domains are validated with one of:
+- HTTP-01: the manager serves the key authorization under
+  /.well-known/acme-challenge/, reached through an Ingress for the domain
+  that is created for the order and removed afterwards
+- DNS-01: a pluggable DNS provider publishes the _acme-challenge TXT record
+"""
+
+import logging
+import os
+from datetime import datetime, timedelta
+from typing import Any, Callable, Dict, List, Optional
+
+import josepy as jose
+from acme import challenges, client, crypto_util, messages
+from cryptography import x509
+from cryptography.hazmat.primitives import serialization
+from cryptography.hazmat.primitives.asymmetric import ec, rsa
+
+from lib.dns_providers import DNSProviderException, get_dns_provider
+
+
+logger = logging.getLogger(__name__)
+
+# Let's Encrypt production directory, used when an issuer names none
+LETSENCRYPT_DIRECTORY = 'https://acme-v02.api.letsencrypt.org/directory'
+
+# How long an order may take to validate and finalize
+ORDER_TIMEOUT = timedelta(minutes=10)
+
+
+class AcmeIssuerException(Exception):
+    """Exception raised when an ACME account or order fails"""
+    pass
+
+
+class AcmeIssuer:
+    """
+    Issues certificates from ACME issuers.
+
+    HTTP-01 challenges are routed to the manager by an Ingress in
+    solver_namespace whose backend is solver_service, the manager's own
+    Service there.
+    """
+
+    def __init__(
+        self,
+        db,
+        solver_namespace: Optional[str] = None,
+        solver_service: Optional[str] = None,
+        solver_port: Optional[int] = None
+    ):
+        """
+        Initialize ACME issuer.
+
+        Args:
+            db: PyDAL DAL instance
+            solver_namespace: Namespace of the manager's Service (default: ACME_SOLVER_NAMESPACE)
+            solver_service: Name of the manager's Service (default: ACME_SOLVER_SERVICE)
+            solver_port: Port of the manager's Service (default: ACME_SOLVER_PORT)
+        """
+        self.db = db
+        self.solver_namespace = solver_namespace or os.getenv('ACME_SOLVER_NAMESPACE', 'nest-system')
+        self.solver_service = solver_service or os.getenv('ACME_SOLVER_SERVICE', 'nest-manager')
+        self.solver_port = solver_port or int(os.getenv('ACME_SOLVER_PORT', '5000'))
+        self._networking_api = None
+
+    # ====== Account Methods ======
+
+    def register_account(
+        self,
+        directory_url: str,
+        email: Optional[str]
+    ) -> Dict[str, str]:
+        """
+        Register a new ACME account, agreeing to the CA's terms of service.
+
+        Args:
+            directory_url: ACME directory URL
+            email: Contact email for expiry notices from the CA
+
+        Returns:
+            Dictionary with account_url and the PEM-encoded account private_key
+
+        Raises:
+            AcmeIssuerException: If registration fails
+        """
+        key = rsa.generate_private_key(public_exponent=65537, key_size=2048)
+        account_key = jose.JWKRSA(key=key)
+
+        try:
+            acme_client = self._client(directory_url, account_key)
+            registration = messages.NewRegistration.from_data(
+                email=email,
+                terms_of_service_agreed=True
+            )
+            account = acme_client.new_account(registration)
+        except Exception as e:
+            raise AcmeIssuerException(f"ACME account registration failed: {e}")
+
+        logger.info(f"Registered ACME account {account.uri} with {directory_url}")
+
+        return {
+            'account_url': account.uri,
+            'private_key': key.private_bytes(
+                encoding=serialization.Encoding.PEM,
+                format=serialization.PrivateFormat.PKCS8,
+                encryption_algorithm=serialization.NoEncryption()
+            ).decode()
+        }
+
+    def _client(self, directory_url: str, account_key: jose.JWK,
+                account_url: Optional[str] = None) -> client.ClientV2:
+        """Build an ACME client, signed in to an existing account if given"""
+        net = client.ClientNetwork(account_key, user_agent='nest-manager')
+        if account_url:
+            net.account = messages.RegistrationResource(
+                body=messages.Registration(),
+                uri=account_url
+            )
+        directory = client.ClientV2.get_directory(directory_url, net)
+        return client.ClientV2(directory, net=net)
+
+    # ====== Issuance Methods ======
+
+    def issue(
+        self,
+        ca,
+        domains: List[str],
+        resource_id: Optional[int] = None
+    ) -> Dict[str, Any]:
+        """
+        Order and issue a certificate for public domains.
+
+        The order is recorded in acme_orders and moves through pending,
+        ready once its domains are validated, and valid once issued, or
+        invalid with the error. Challenge records are removed either way.
+
+        Args:
+            ca: certificate_authorities row of type 'acme'
+            domains: Domains to certify; the first is the common name
+            resource_id: Resource the certificate is for
+
+        Returns:
+            Dictionary with certificate (the full chain), private_key,
+            valid_from, valid_until, serial_number, and order_id
+
+        Raises:
+            AcmeIssuerException: If the order fails
+        """
+        if not domains:
+            raise AcmeIssuerException("At least one domain is required")
+        if not ca.acme_account_url:
+            raise AcmeIssuerException(f"ACME issuer {ca.name} has no registered account")
+
+        order_id = self.db.acme_orders.insert(
+            ca_id=ca.id,
+            resource_id=resource_id,
+            domains=domains,
+            challenge_type=ca.acme_challenge,
+            status='pending'
+        )
+        self.db.commit()
+
+        cleanups: List[Callable[[], None]] = []
+        try:
+            account_key = jose.JWKRSA(key=serialization.load_pem_private_key(
+                ca.private_key.encode(), password=None))
+            acme_client = self._client(ca.acme_directory_url, account_key, ca.acme_account_url)
+
+            # The certificate's key is new on every order, so renewals rotate it
+            key = ec.generate_private_key(ec.SECP256R1())
+            key_pem = key.private_bytes(
+                encoding=serialization.Encoding.PEM,
+                format=serialization.PrivateFormat.PKCS8,
+                encryption_algorithm=serialization.NoEncryption()
+            )
+            csr_pem = crypto_util.make_csr(key_pem, domains)
+
+            order = acme_client.new_order(csr_pem)
+            self._update_order(order_id, order_url=order.uri)
+
+            # Answer a challenge of the issuer's type for each domain, then
+            # wait for the CA to validate them all
+            dns_provider = None
+            if ca.acme_challenge == 'dns-01':
+                dns_provider = get_dns_provider(ca.acme_dns_provider, ca.acme_dns_config)
+            pending = []
+            for authz in order.authorizations:
+                if authz.body.status == messages.STATUS_VALID:
+                    continue
+                domain = authz.body.identifier.value
+                challb = self._find_challenge(authz, ca.acme_challenge)
+                response, validation = challb.response_and_validation(account_key)
+                if ca.acme_challenge == 'dns-01':
+                    name = challb.chall.validation_domain_name(domain)
+                    dns_provider.create_txt_record(name, validation)
+                    cleanups.append(lambda n=name, v=validation: dns_provider.delete_txt_record(n, v))
+                else:
+                    cleanups.append(self._publish_http01(ca, order_id, domain, challb, validation))
+                pending.append((challb, response))
+
+            if dns_provider and pending:
+                dns_provider.wait_for_propagation()
+            for challb, response in pending:
+                acme_client.answer_challenge(challb, response)
+
+            deadline = datetime.now() + ORDER_TIMEOUT
+            order = acme_client.poll_authorizations(order, deadline)
+            self._update_order(order_id, status='ready')
+
+            # The domains are validated; the CA now issues the certificate
+            self._update_order(order_id, status='processing')
+            order = acme_client.finalize_order(order, deadline)
+
+        except Exception as e:
+            error = getattr(e, 'detail', None) or str(e)
+            logger.error(f"ACME order {order_id} for {', '.join(domains)} failed: {error}")
+            self._update_order(order_id, status='invalid', error_message=str(error),
+                               completed_at=datetime.utcnow())
+            raise AcmeIssuerException(f"ACME order failed: {error}")
+
+        finally:
+            for cleanup in cleanups:
+                try:
+                    cleanup()
+                except Exception as e:
+                    logger.warning(f"Failed to clean up ACME challenge of order {order_id}: {e}")
+
+        chain = x509.load_pem_x509_certificates(order.fullchain_pem.encode())
+        leaf = chain[0]
+        self._update_order(order_id, status='valid', completed_at=datetime.utcnow())
+
+        # Keep the issuing chain on the issuer, for clients that pin it
+        issuer_chain = ''.join(
+            cert.public_bytes(serialization.Encoding.PEM).decode() for cert in chain[1:]
+        )
+        if issuer_chain:
+            ca.update_record(
+                certificate=issuer_chain,
+                subject=chain[1].subject.rfc4514_string(),
+                issuer=chain[1].issuer.rfc4514_string(),
+                valid_from=chain[1].not_valid_before,
+                valid_until=chain[1].not_valid_after
+            )
+            self.db.commit()
+
+        logger.info(f"ACME order {order_id} issued a certificate for {', '.join(domains)}")
+
+        return {
+            'certificate': order.fullchain_pem,
+            'private_key': key_pem.decode(),
+            'valid_from': leaf.not_valid_before,
+            'valid_until': leaf.not_valid_after,
+            'serial_number': str(leaf.serial_number),
+            'order_id': order_id
+        }
+
+    def _find_challenge(self, authz, challenge_type: str):
+        """Return the authorization's challenge of the given type"""
+        wanted = challenges.DNS01 if challenge_type == 'dns-01' else challenges.HTTP01
+        for challb in authz.body.challenges:
+            if isinstance(challb.chall, wanted):
+                return challb
+        raise AcmeIssuerException(
+            f"The CA offers no {challenge_type} challenge for {authz.body.identifier.value}"
+        )
+
+    def _update_order(self, order_id: int, **fields) -> None:
+        """Record an order's progress"""
+        self.db(self.db.acme_orders.id == order_id).update(**fields)
+        self.db.commit()
+
+    # ====== HTTP-01 Methods ======
+
+    def _publish_http01(self, ca, order_id: int, domain: str, challb,
+                        key_authorization: str) -> Callable[[], None]:
+        """
+        Serve an HTTP-01 challenge and route it to the manager.
+
+        Returns:
+            Callable removing the challenge and its Ingress
+        """
+        token = challb.chall.encode('token')
+        challenge_id = self.db.acme_challenges.insert(
+            order_id=order_id,
+            domain=domain,
+            token=token,
+            key_authorization=key_authorization
+        )
+        self.db.commit()
+
+        ingress_name = f"nest-acme-{order_id}-{challenge_id}"
+        self._create_solver_ingress(ingress_name, domain, challb.chall.path, ca.acme_ingress_class)
+
+        def cleanup():
+            self.db(self.db.acme_challenges.id == challenge_id).delete()
+            self.db.commit()
+            self._delete_solver_ingress(ingress_name)
+
+        return cleanup
+
+    def _networking(self):
+        """Return the Kubernetes networking API, loading the config once"""
+        if self._networking_api is None:
+            from kubernetes import client as k8s, config as k8s_config
+            try:
+                k8s_config.load_incluster_config()
+            except k8s_config.ConfigException:
+                k8s_config.load_kube_config()
+            self._networking_api = k8s.NetworkingV1Api()
+        return self._networking_api
+
+    def _create_solver_ingress(self, name: str, domain: str, path: str,
+                               ingress_class: Optional[str]) -> None:
+        """Create an Ingress routing a challenge path of the domain to the manager"""
+        from kubernetes import client as k8s
+
+        ingress = k8s.V1Ingress(
+            metadata=k8s.V1ObjectMeta(
+                name=name,
+                namespace=self.solver_namespace,
+                labels={'managed-by': 'nest', 'nest-acme-solver': 'true'}
+            ),
+            spec=k8s.V1IngressSpec(
+                ingress_class_name=ingress_class or None,
+                rules=[k8s.V1IngressRule(
+                    host=domain,
+                    http=k8s.V1HTTPIngressRuleValue(paths=[k8s.V1HTTPIngressPath(
+                        path=path,
+                        path_type='Exact',
+                        backend=k8s.V1IngressBackend(service=k8s.V1IngressServiceBackend(
+                            name=self.solver_service,
+                            port=k8s.V1ServiceBackendPort(number=self.solver_port)
+                        ))
+                    )])
+                )]
+            )
+        )
+        try:
+            self._networking().create_namespaced_ingress(self.solver_namespace, ingress)
+        except Exception as e:
+            raise AcmeIssuerException(f"Failed to create HTTP-01 solver Ingress for {domain}: {e}")
+
+    def _delete_solver_ingress(self, name: str) -> None:
+        """Delete a challenge's Ingress"""
+        from kubernetes.client.exceptions import ApiException
+        try:
+            self._networking().delete_namespaced_ingress(name, self.solver_namespace)
+        except ApiException as e:
+            if e.status != 404:
+                raise
+
+    def challenge_response(self, token: str) -> Optional[str]:
+        """
+        Look up the key authorization served for an HTTP-01 token.
+
+        Args:
+            token: Challenge token from the request path
+
+        Returns:
+            Key authorization, or None if no challenge is pending for the token
+        """
+        challenge = self.db(self.db.acme_challenges.token == token).select().first()
+        return challenge.key_authorization if challenge else None
+
+
+def validate_acme_issuer(challenge: str, dns_provider: Optional[str],
+                         dns_config: Optional[Dict[str, Any]]) -> None:
+    """
+    Check an ACME issuer's challenge settings.
+
+    Args:
+        challenge: Challenge type, http-01 or dns-01
+        dns_provider: DNS provider name, required for dns-01
+        dns_config: DNS provider configuration
+
+    Raises:
+        ValueError: If the settings are invalid
+    """
+    if challenge not in ('http-01', 'dns-01'):
+        raise ValueError("ACME challenge must be http-01 or dns-01")
+    if challenge == 'dns-01':
+        if not dns_provider:
+            raise ValueError("dns-01 challenges require a DNS provider")
+        try:
+            get_dns_provider(dns_provider, dns_config)
+        except DNSProviderException as e:
+            raise ValueError(str(e))
@@ -0,0 +1,367 @@
+"""
+CA Hierarchy
+
+Signing operations for building a CA hierarchy:
+- Intermediates signed by a NEST-managed parent CA
+- CSRs for intermediates signed by an external CA
+- Cross-signatures, which certify an existing CA's key under another CA so
+  clients trusting either root accept its certificates
+
+Certificates and keys are PEM strings throughout, as stored in
+certificate_authorities.
+"""
+
+import logging
+from datetime import datetime, timedelta
+from typing import Dict, List, Optional
+
+from cryptography import x509
+from cryptography.exceptions import InvalidSignature
+from cryptography.hazmat.primitives import hashes, serialization
+from cryptography.hazmat.primitives.asymmetric import ec, padding, rsa
+from cryptography.x509.oid import NameOID
+
+
+logger = logging.getLogger(__name__)
+
+# Key size of generated intermediate keys
+CA_KEY_SIZE = 4096
+
+
+class CAHierarchyException(Exception):
+    """Exception raised when a CA can't be signed or verified"""
+    pass
+
+
+def _build_name(
+    common_name: str,
+    organization: str,
+    organization_unit: Optional[str] = None,
+    country: str = "US",
+    state: str = "CA",
+    locality: str = "San Francisco"
+) -> x509.Name:
+    """Build a CA subject name"""
+    attributes = [
+        x509.NameAttribute(NameOID.COUNTRY_NAME, country),
+        x509.NameAttribute(NameOID.STATE_OR_PROVINCE_NAME, state),
+        x509.NameAttribute(NameOID.LOCALITY_NAME, locality),
+        x509.NameAttribute(NameOID.ORGANIZATION_NAME, organization),
+    ]
+    if organization_unit:
+        attributes.append(x509.NameAttribute(NameOID.ORGANIZATIONAL_UNIT_NAME, organization_unit))
+    attributes.append(x509.NameAttribute(NameOID.COMMON_NAME, common_name))
+    return x509.Name(attributes)
+
+
+def _generate_key() -> rsa.RSAPrivateKey:
+    """Generate an intermediate CA key"""
+    return rsa.generate_private_key(public_exponent=65537, key_size=CA_KEY_SIZE)
+
+
+def _key_pem(key) -> str:
+    """Serialize a private key to unencrypted PKCS#8 PEM"""
+    return key.private_bytes(
+        encoding=serialization.Encoding.PEM,
+        format=serialization.PrivateFormat.PKCS8,
+        encryption_algorithm=serialization.NoEncryption()
+    ).decode()
+
+
+def _load_certificate(certificate_pem: str) -> x509.Certificate:
+    """Parse a PEM certificate"""
+    try:
+        return x509.load_pem_x509_certificate(certificate_pem.encode())
+    except ValueError as e:
+        raise CAHierarchyException(f"Invalid certificate: {e}")
+
+
+def _load_key(private_key_pem: str):
+    """Parse a PEM private key"""
+    try:
+        return serialization.load_pem_private_key(private_key_pem.encode(), password=None)
+    except (ValueError, TypeError) as e:
+        raise CAHierarchyException(f"Invalid private key: {e}")
+
+
+def _public_bytes(public_key) -> bytes:
+    """Serialize a public key for comparison"""
+    return public_key.public_bytes(
+        encoding=serialization.Encoding.DER,
+        format=serialization.PublicFormat.SubjectPublicKeyInfo
+    )
+
+
+def _sign_ca(
+    subject: x509.Name,
+    public_key,
+    issuer_cert: x509.Certificate,
+    issuer_key,
+    validity_days: int,
+    path_length: Optional[int]
+) -> x509.Certificate:
+    """
+    Sign a CA certificate under an issuer.
+
+    Validity is capped at the issuer's, as a CA can't outlive the CA
+    certifying it.
+    """
+    now = datetime.utcnow()
+    not_after = min(now + timedelta(days=validity_days), issuer_cert.not_valid_after)
+    if not_after <= now:
+        raise CAHierarchyException("The issuing CA has expired")
+
+    builder = (
+        x509.CertificateBuilder()
+        .subject_name(subject)
+        .issuer_name(issuer_cert.subject)
+        .public_key(public_key)
+        .serial_number(x509.random_serial_number())
+        .not_valid_before(now)
+        .not_valid_after(not_after)
+        .add_extension(x509.BasicConstraints(ca=True, path_length=path_length), critical=True)
+        .add_extension(
+            x509.KeyUsage(
+                digital_signature=True,
+                content_commitment=False,
+                key_encipherment=False,
+                data_encipherment=False,
+                key_agreement=False,
+                key_cert_sign=True,
+                crl_sign=True,
+                encipher_only=False,
+                decipher_only=False
+            ),
+            critical=True
+        )
+        .add_extension(x509.SubjectKeyIdentifier.from_public_key(public_key), critical=False)
+        .add_extension(
+            x509.AuthorityKeyIdentifier.from_issuer_public_key(issuer_cert.public_key()),
+            critical=False
+        )
+    )
+    return builder.sign(issuer_key, hashes.SHA256())
+
+
+def sign_intermediate_ca(
+    parent_certificate_pem: str,
+    parent_private_key_pem: str,
+    common_name: str,
+    organization: str,
+    organization_unit: Optional[str] = None,
+    country: str = "US",
+    state: str = "CA",
+    locality: str = "San Francisco",
+    validity_days: int = 1825,
+    path_length: Optional[int] = 0
+) -> Dict[str, str]:
+    """
+    Generate an intermediate CA signed by a parent CA.
+
+    Args:
+        parent_certificate_pem: PEM-encoded parent CA certificate
+        parent_private_key_pem: PEM-encoded parent CA private key
+        common_name: CN for the intermediate
+        organization: Organization name
+        organization_unit: Optional organization unit
+        country: Country code (default: US)
+        state: State/province (default: CA)
+        locality: Locality (default: San Francisco)
+        validity_days: Validity in days, capped at the parent's (default: 1825 = 5 years)
+        path_length: Maximum CAs below the intermediate; None for unlimited (default: 0)
+
+    Returns:
+        Dictionary with certificate and private_key PEMs
+
+    Raises:
+        CAHierarchyException: If the parent can't sign
+    """
+    parent_cert = _load_certificate(parent_certificate_pem)
+    parent_key = _load_key(parent_private_key_pem)
+
+    key = _generate_key()
+    cert = _sign_ca(
+        _build_name(common_name, organization, organization_unit, country, state, locality),
+        key.public_key(),
+        parent_cert,
+        parent_key,
+        validity_days,
+        path_length
+    )
+
+    return {
+        'certificate': cert.public_bytes(serialization.Encoding.PEM).decode(),
+        'private_key': _key_pem(key)
+    }
+
+
+def generate_ca_csr(
+    common_name: str,
+    organization: str,
+    organization_unit: Optional[str] = None,
+    country: str = "US",
+    state: str = "CA",
+    locality: str = "San Francisco",
+    path_length: Optional[int] = 0
+) -> Dict[str, str]:
+    """
+    Generate a key and CSR for an intermediate to be signed by an external CA.
+
+    Args:
+        common_name: CN for the intermediate
+        organization: Organization name
+        organization_unit: Optional organization unit
+        country: Country code (default: US)
+        state: State/province (default: CA)
+        locality: Locality (default: San Francisco)
+        path_length: Maximum CAs below the intermediate requested; None for unlimited (default: 0)
+
+    Returns:
+        Dictionary with csr and private_key PEMs
+    """
+    key = _generate_key()
+    csr = (
+        x509.CertificateSigningRequestBuilder()
+        .subject_name(_build_name(common_name, organization, organization_unit, country, state, locality))
+        .add_extension(x509.BasicConstraints(ca=True, path_length=path_length), critical=True)
+        .sign(key, hashes.SHA256())
+    )
+
+    return {
+        'csr': csr.public_bytes(serialization.Encoding.PEM).decode(),
+        'private_key': _key_pem(key)
+    }
+
+
+def verify_signed_ca(certificate_pem: str, private_key_pem: str) -> None:
+    """
+    Verify an externally signed certificate matches a pending intermediate.
+
+    Args:
+        certificate_pem: PEM-encoded certificate returned by the external CA
+        private_key_pem: PEM-encoded key the CSR was generated with
+
+    Raises:
+        CAHierarchyException: If the certificate isn't a CA certificate for the key
+    """
+    cert = _load_certificate(certificate_pem)
+    key = _load_key(private_key_pem)
+
+    if _public_bytes(cert.public_key()) != _public_bytes(key.public_key()):
+        raise CAHierarchyException("The certificate was not issued for this CA's CSR")
+
+    try:
+        constraints = cert.extensions.get_extension_for_class(x509.BasicConstraints).value
+    except x509.ExtensionNotFound:
+        constraints = None
+    if not constraints or not constraints.ca:
+        raise CAHierarchyException("The certificate is not a CA certificate")
+
+
+def cross_sign_certificate(
+    certificate_pem: str,
+    signer_certificate_pem: str,
+    signer_private_key_pem: str,
+    validity_days: int = 1825
+) -> str:
+    """
+    Cross-sign a CA, certifying its subject and key under another CA.
+
+    The cross-signed certificate keeps the CA's subject and key, so the
+    certificates it issued chain to the signer as well as to its own issuer.
+
+    Args:
+        certificate_pem: PEM-encoded certificate of the CA to cross-sign
+        signer_certificate_pem: PEM-encoded certificate of the signing CA
+        signer_private_key_pem: PEM-encoded private key of the signing CA
+        validity_days: Validity in days, capped at the signer's (default: 1825 = 5 years)
+
+    Returns:
+        PEM-encoded cross-signed certificate
+
+    Raises:
+        CAHierarchyException: If the signer can't sign
+    """
+    cert = _load_certificate(certificate_pem)
+    signer_cert = _load_certificate(signer_certificate_pem)
+    signer_key = _load_key(signer_private_key_pem)
+
+    try:
+        path_length = cert.extensions.get_extension_for_class(x509.BasicConstraints).value.path_length
+    except x509.ExtensionNotFound:
+        path_length = None
+
+    cross = _sign_ca(cert.subject, cert.public_key(), signer_cert, signer_key, validity_days, path_length)
+    return cross.public_bytes(serialization.Encoding.PEM).decode()
+
+
+def is_issued_by(certificate_pem: str, issuer_certificate_pem: str) -> bool:
+    """
+    Check whether a certificate was signed by an issuer's key.
+
+    Args:
+        certificate_pem: PEM-encoded certificate
+        issuer_certificate_pem: PEM-encoded candidate issuer certificate
+
+    Returns:
+        True if the issuer's key signed the certificate
+    """
+    cert = _load_certificate(certificate_pem)
+    issuer = _load_certificate(issuer_certificate_pem)
+    if cert.issuer != issuer.subject:
+        return False
+
+    public_key = issuer.public_key()
+    try:
+        if isinstance(public_key, rsa.RSAPublicKey):
+            public_key.verify(cert.signature, cert.tbs_certificate_bytes,
+                              padding.PKCS1v15(), cert.signature_hash_algorithm)
+        elif isinstance(public_key, ec.EllipticCurvePublicKey):
+            public_key.verify(cert.signature, cert.tbs_certificate_bytes,
+                              ec.ECDSA(cert.signature_hash_algorithm))
+        else:
+            public_key.verify(cert.signature, cert.tbs_certificate_bytes)
+    except InvalidSignature:
+        return False
+    return True
+
+
+def split_pem_chain(chain_pem: Optional[str]) -> List[str]:
+    """
+    Split a PEM bundle into its certificates.
+
+    Args:
+        chain_pem: Concatenated PEM certificates
+
+    Returns:
+        List of PEM certificates, in bundle order
+
+    Raises:
+        CAHierarchyException: If a certificate in the bundle is invalid
+    """
+    if not chain_pem:
+        return []
+
+    marker = '-----END CERTIFICATE-----'
+    certificates = []
+    for block in chain_pem.split(marker):
+        block = block.strip()
+        if not block:
+            continue
+        pem = block + '\n' + marker + '\n'
+        _load_certificate(pem)
+        certificates.append(pem)
+    return certificates
+
+
+def is_self_signed(certificate_pem: str) -> bool:
+    """
+    Check whether a certificate is a self-signed root.
+
+    Args:
+        certificate_pem: PEM-encoded certificate
+
+    Returns:
+        True if the certificate is its own issuer
+    """
+    return is_issued_by(certificate_pem, certificate_pem)
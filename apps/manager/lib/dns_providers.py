@@ -0,0 +1,259 @@
+"""
+DNS Providers
+
+Pluggable DNS providers that publish the TXT records answering ACME DNS-01
+challenges. Providers are looked up by name from an ACME issuer's
+acme_dns_provider, and built from its acme_dns_config.
+
+Additional providers are added with register_dns_provider().
+"""
+
+import json
+import logging
+import time
+import urllib.error
+import urllib.parse
+import urllib.request
+from typing import Any, Dict, Optional, Type
+
+
+logger = logging.getLogger(__name__)
+
+
+class DNSProviderException(Exception):
+    """Exception raised when a DNS record can't be published or removed"""
+    pass
+
+
+class DNSProvider:
+    """
+    Base class for DNS providers.
+
+    Subclasses publish and remove the TXT record of a challenge. Records are
+    removed by value, as a name can hold the records of several challenges,
+    such as for a wildcard and its base domain.
+    """
+
+    def __init__(self, config: Dict[str, Any]):
+        """
+        Initialize DNS provider.
+
+        Args:
+            config: Provider configuration and credentials
+        """
+        self.config = config or {}
+
+    def create_txt_record(self, name: str, value: str) -> None:
+        """
+        Publish a TXT record.
+
+        Args:
+            name: Fully qualified record name, such as _acme-challenge.example.com
+            value: Record value
+        """
+        raise NotImplementedError
+
+    def delete_txt_record(self, name: str, value: str) -> None:
+        """
+        Remove a TXT record published by create_txt_record().
+
+        Args:
+            name: Fully qualified record name
+            value: Record value
+        """
+        raise NotImplementedError
+
+    def wait_for_propagation(self) -> None:
+        """
+        Wait for published records to reach the authoritative servers.
+
+        Waits propagation_seconds from the configuration (default: 60).
+        """
+        time.sleep(int(self.config.get('propagation_seconds', 60)))
+
+
+class Route53Provider(DNSProvider):
+    """
+    AWS Route 53 DNS provider.
+
+    Config:
+        hosted_zone_id: Hosted zone holding the records (looked up by name if unset)
+        access_key_id, secret_access_key: Credentials (default: the boto3 chain)
+        region: AWS region (default: us-east-1)
+    """
+
+    def __init__(self, config: Dict[str, Any]):
+        super().__init__(config)
+        import boto3
+
+        self.client = boto3.client(
+            'route53',
+            aws_access_key_id=self.config.get('access_key_id'),
+            aws_secret_access_key=self.config.get('secret_access_key'),
+            region_name=self.config.get('region', 'us-east-1')
+        )
+        self._changes = []
+
+    def _zone_id(self, name: str) -> str:
+        """Find the hosted zone of a record, preferring the configured one"""
+        if self.config.get('hosted_zone_id'):
+            return self.config['hosted_zone_id']
+
+        # The longest zone name the record falls under
+        best = None
+        paginator = self.client.get_paginator('list_hosted_zones')
+        for page in paginator.paginate():
+            for zone in page['HostedZones']:
+                zone_name = zone['Name'].rstrip('.')
+                if name == zone_name or name.endswith('.' + zone_name):
+                    if not best or len(zone_name) > len(best[0]):
+                        best = (zone_name, zone['Id'])
+        if not best:
+            raise DNSProviderException(f"No Route 53 hosted zone found for {name}")
+        return best[1]
+
+    def _change(self, action: str, name: str, value: str) -> None:
+        """Submit a change to a TXT record"""
+        try:
+            response = self.client.change_resource_record_sets(
+                HostedZoneId=self._zone_id(name),
+                ChangeBatch={
+                    'Changes': [{
+                        'Action': action,
+                        'ResourceRecordSet': {
+                            'Name': name,
+                            'Type': 'TXT',
+                            'TTL': 60,
+                            'ResourceRecords': [{'Value': f'"{value}"'}],
+                        },
+                    }],
+                }
+            )
+            self._changes.append(response['ChangeInfo']['Id'])
+        except DNSProviderException:
+            raise
+        except Exception as e:
+            raise DNSProviderException(f"Route 53 {action} of {name} failed: {e}")
+
+    def create_txt_record(self, name: str, value: str) -> None:
+        self._change('UPSERT', name, value)
+
+    def delete_txt_record(self, name: str, value: str) -> None:
+        self._change('DELETE', name, value)
+
+    def wait_for_propagation(self) -> None:
+        """Wait for Route 53 to report the changes as in sync"""
+        waiter = self.client.get_waiter('resource_record_sets_changed')
+        for change_id in self._changes:
+            waiter.wait(Id=change_id)
+        self._changes = []
+
+
+class CloudflareProvider(DNSProvider):
+    """
+    Cloudflare DNS provider.
+
+    Config:
+        api_token: API token with the Zone.DNS edit permission
+        zone_id: Zone holding the records (looked up by name if unset)
+    """
+
+    API_URL = 'https://api.cloudflare.com/client/v4'
+
+    def __init__(self, config: Dict[str, Any]):
+        super().__init__(config)
+        if not self.config.get('api_token'):
+            raise DNSProviderException("Cloudflare provider requires api_token")
+
+    def _request(self, method: str, path: str, body: Optional[Dict[str, Any]] = None) -> Any:
+        """Send an API request and return its result"""
+        data = json.dumps(body).encode() if body is not None else None
+        request = urllib.request.Request(
+            self.API_URL + path,
+            data=data,
+            method=method,
+            headers={
+                'Authorization': f"Bearer {self.config['api_token']}",
+                'Content-Type': 'application/json',
+            }
+        )
+        try:
+            with urllib.request.urlopen(request, timeout=30) as response:
+                payload = json.loads(response.read())
+        except urllib.error.HTTPError as e:
+            raise DNSProviderException(f"Cloudflare {method} {path} returned {e.code}: {e.read()[:500]}")
+        except urllib.error.URLError as e:
+            raise DNSProviderException(f"Cloudflare {method} {path} failed: {e.reason}")
+
+        if not payload.get('success'):
+            raise DNSProviderException(f"Cloudflare {method} {path} failed: {payload.get('errors')}")
+        return payload.get('result')
+
+    def _zone_id(self, name: str) -> str:
+        """Find the zone of a record, preferring the configured one"""
+        if self.config.get('zone_id'):
+            return self.config['zone_id']
+
+        # Try each parent domain, longest first
+        labels = name.split('.')
+        for i in range(len(labels) - 1):
+            zone_name = '.'.join(labels[i:])
+            zones = self._request('GET', '/zones?' + urllib.parse.urlencode({'name': zone_name}))
+            if zones:
+                return zones[0]['id']
+        raise DNSProviderException(f"No Cloudflare zone found for {name}")
+
+    def create_txt_record(self, name: str, value: str) -> None:
+        self._request('POST', f'/zones/{self._zone_id(name)}/dns_records', {
+            'type': 'TXT',
+            'name': name,
+            'content': value,
+            'ttl': 120,
+        })
+
+    def delete_txt_record(self, name: str, value: str) -> None:
+        zone_id = self._zone_id(name)
+        query = urllib.parse.urlencode({'type': 'TXT', 'name': name, 'content': value})
+        for record in self._request('GET', f'/zones/{zone_id}/dns_records?{query}') or []:
+            self._request('DELETE', f"/zones/{zone_id}/dns_records/{record['id']}")
+
+
+# Registered providers by name
+DNS_PROVIDERS: Dict[str, Type[DNSProvider]] = {
+    'route53': Route53Provider,
+    'cloudflare': CloudflareProvider,
+}
+
+
+def register_dns_provider(name: str, provider: Type[DNSProvider]) -> None:
+    """
+    Register a DNS provider for ACME issuers to use.
+
+    Args:
+        name: Name issuers select the provider by
+        provider: DNSProvider subclass, constructed with the issuer's acme_dns_config
+    """
+    DNS_PROVIDERS[name] = provider
+    logger.info(f"Registered DNS provider '{name}'")
+
+
+def get_dns_provider(name: str, config: Optional[Dict[str, Any]]) -> DNSProvider:
+    """
+    Build a registered DNS provider.
+
+    Args:
+        name: Provider name
+        config: Provider configuration and credentials
+
+    Returns:
+        DNSProvider instance
+
+    Raises:
+        DNSProviderException: If no provider is registered under the name
+    """
+    provider = DNS_PROVIDERS.get(name)
+    if not provider:
+        raise DNSProviderException(
+            f"Unknown DNS provider '{name}', expected one of: {', '.join(sorted(DNS_PROVIDERS))}"
+        )
+    return provider(config or {})
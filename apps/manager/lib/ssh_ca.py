@@ -0,0 +1,49 @@
+"""
+SSH CA
+
+Keys for SSH certificate authorities. An SSH CA is stored as a
+certificate_authorities row of type 'ssh', whose certificate is the CA's
+public key in authorized_keys format and whose private_key is in OpenSSH
+format. The API signs short-lived user certificates with it for access to
+the hosts of agent-managed resources, and agents install its public key as
+sshd's TrustedUserCAKeys.
+"""
+
+import base64
+import hashlib
+from typing import Dict
+
+from cryptography.hazmat.primitives import serialization
+from cryptography.hazmat.primitives.asymmetric import ed25519
+
+
+def generate_ssh_ca_key(comment: str) -> Dict[str, str]:
+    """
+    Generate an Ed25519 SSH CA key.
+
+    Args:
+        comment: Comment appended to the public key
+
+    Returns:
+        Dictionary with public_key (authorized_keys format), private_key
+        (OpenSSH format), and fingerprint (SHA256, as ssh-keygen -l prints it)
+    """
+    key = ed25519.Ed25519PrivateKey.generate()
+    public_key = key.public_key().public_bytes(
+        encoding=serialization.Encoding.OpenSSH,
+        format=serialization.PublicFormat.OpenSSH
+    ).decode()
+    private_key = key.private_bytes(
+        encoding=serialization.Encoding.PEM,
+        format=serialization.PrivateFormat.OpenSSH,
+        encryption_algorithm=serialization.NoEncryption()
+    ).decode()
+
+    blob = base64.b64decode(public_key.split()[1])
+    fingerprint = 'SHA256:' + base64.b64encode(hashlib.sha256(blob).digest()).decode().rstrip('=')
+
+    return {
+        'public_key': f"{public_key} {comment}",
+        'private_key': private_key,
+        'fingerprint': fingerprint
+    }
@@ -41,7 +41,13 @@ from .resources import (
     define_backup_jobs,
     define_provisioning_jobs
 )
-from .certificates import define_certificate_authorities, define_certificates
+from .certificates import (
+    define_certificate_authorities,
+    define_certificates,
+    define_ca_cross_signatures,
+    define_acme_orders,
+    define_acme_challenges
+)
 from .audit import define_audit_logs
 
 # Define all tables
@@ -56,6 +62,9 @@ define_backup_jobs(db)
 define_provisioning_jobs(db)
 define_certificate_authorities(db)
 define_certificates(db)
+define_ca_cross_signatures(db)
+define_acme_orders(db)
+define_acme_challenges(db)
 define_audit_logs(db)
 
 # Commit schema if needed
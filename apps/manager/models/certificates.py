@@ -4,7 +4,7 @@ Certificates Models
 Defines certificate authorities and certificates for TLS/encryption.
 """
 
-from pydal.validators import IS_NOT_EMPTY, IS_IN_SET
+from pydal.validators import IS_NOT_EMPTY, IS_IN_SET, IS_EMPTY_OR
 
 
 def define_certificate_authorities(db):
@@ -21,13 +21,12 @@ def define_certificate_authorities(db):
                  comment='CA name'),
         db.Field('type', 'string',
                  length=50,
-                 requires=IS_IN_SET(['root', 'intermediate', 'self_signed']),
+                 requires=IS_IN_SET(['root', 'intermediate', 'self_signed', 'acme', 'ssh']),
                  comment='Certificate Authority type'),
         db.Field('certificate', 'text',
-                 requires=IS_NOT_EMPTY(),
-                 comment='PEM-encoded certificate'),
+                 comment='PEM-encoded certificate; for ACME, the last issuing chain; for SSH, the public key'),
         db.Field('private_key', 'text',
-                 comment='PEM-encoded private key'),
+                 comment='PEM-encoded private key; for ACME, the account key; for SSH, in OpenSSH format'),
         db.Field('subject', 'string',
                  length=500,
                  comment='Certificate subject'),
@@ -44,6 +43,42 @@ def define_certificate_authorities(db):
         db.Field('is_nest_managed', 'boolean',
                  default=True,
                  comment='Whether NEST manages this CA'),
+        db.Field('parent_id', 'reference certificate_authorities',
+                 comment='NEST CA that signed this intermediate'),
+        db.Field('chain', 'text',
+                 comment='PEM-encoded chain above an externally signed intermediate'),
+        db.Field('csr', 'text',
+                 comment='PEM-encoded CSR awaiting an external signature'),
+        db.Field('status', 'string',
+                 length=20,
+                 default='active',
+                 requires=IS_IN_SET(['pending_signature', 'active']),
+                 comment='CA status; pending_signature until its signed certificate is imported'),
+        db.Field('path_length', 'integer',
+                 comment='Maximum CAs below this CA; null for unlimited'),
+        db.Field('team_ids', 'list:reference teams',
+                 comment='Teams allowed to issue from this CA; empty for all teams'),
+        db.Field('acme_directory_url', 'string',
+                 length=500,
+                 comment='ACME directory URL'),
+        db.Field('acme_email', 'string',
+                 length=255,
+                 comment='ACME account contact email'),
+        db.Field('acme_account_url', 'string',
+                 length=500,
+                 comment='ACME account URL, set on registration'),
+        db.Field('acme_challenge', 'string',
+                 length=20,
+                 requires=IS_EMPTY_OR(IS_IN_SET(['http-01', 'dns-01'])),
+                 comment='ACME challenge type'),
+        db.Field('acme_ingress_class', 'string',
+                 length=100,
+                 comment='Ingress class serving HTTP-01 challenges'),
+        db.Field('acme_dns_provider', 'string',
+                 length=50,
+                 comment='DNS provider answering DNS-01 challenges'),
+        db.Field('acme_dns_config', 'json',
+                 comment='DNS provider configuration and credentials'),
         db.Field('created_by', 'reference users',
                  comment='User who created this CA'),
         db.Field('created_at', 'datetime',
@@ -62,6 +97,8 @@ def define_certificate_authorities(db):
             ['serial_number'],
             ['created_at'],
             ['is_nest_managed'],
+            ['parent_id'],
+            ['status'],
         ],
 
         migrate=True,
@@ -135,3 +172,147 @@ def define_certificates(db):
         fake_migrate=False,
         format='%(common_name)s'
     )
+
+
+def define_ca_cross_signatures(db):
+    """Define the ca_cross_signatures table.
+
+    Holds certificates certifying a CA's subject and key under another CA,
+    which are served in the CA's chain alongside its own certificate.
+
+    Args:
+        db: PyDAL DAL instance
+    """
+    db.define_table(
+        'ca_cross_signatures',
+        db.Field('ca_id', 'reference certificate_authorities',
+                 requires=IS_NOT_EMPTY(),
+                 comment='Cross-signed CA reference'),
+        db.Field('signer_id', 'reference certificate_authorities',
+                 requires=IS_NOT_EMPTY(),
+                 comment='Signing CA reference'),
+        db.Field('certificate', 'text',
+                 requires=IS_NOT_EMPTY(),
+                 comment='PEM-encoded cross-signed certificate'),
+        db.Field('valid_from', 'datetime',
+                 comment='Certificate validity start'),
+        db.Field('valid_until', 'datetime',
+                 comment='Certificate validity end'),
+        db.Field('serial_number', 'string',
+                 length=255,
+                 comment='Certificate serial number'),
+        db.Field('created_by', 'reference users',
+                 comment='User who created this cross-signature'),
+        db.Field('created_at', 'datetime',
+                 default=db.current_timestamp,
+                 comment='Creation timestamp'),
+        db.Field('deleted_at', 'datetime',
+                 comment='Soft delete timestamp'),
+
+        indexes=[
+            ['ca_id'],
+            ['signer_id'],
+            ['valid_until'],
+        ],
+
+        migrate=True,
+        fake_migrate=False
+    )
+
+
+def define_acme_orders(db):
+    """Define the acme_orders table.
+
+    Tracks each certificate order placed with an ACME issuer, from the
+    first renewal attempt through to the issued certificate.
+
+    Args:
+        db: PyDAL DAL instance
+    """
+    db.define_table(
+        'acme_orders',
+        db.Field('ca_id', 'reference certificate_authorities',
+                 requires=IS_NOT_EMPTY(),
+                 comment='ACME issuer reference'),
+        db.Field('resource_id', 'reference resources',
+                 ondelete='CASCADE',
+                 comment='Resource reference'),
+        db.Field('certificate_id', 'reference certificates',
+                 comment='Issued certificate, once the order is valid'),
+        db.Field('domains', 'json',
+                 comment='Domains ordered'),
+        db.Field('order_url', 'string',
+                 length=500,
+                 comment='ACME order URL'),
+        db.Field('challenge_type', 'string',
+                 length=20,
+                 comment='Challenge type used to validate the domains'),
+        db.Field('status', 'string',
+                 length=20,
+                 default='pending',
+                 requires=IS_IN_SET(['pending', 'ready', 'processing',
+                                     'valid', 'invalid']),
+                 comment='ACME order status'),
+        db.Field('error_message', 'text',
+                 comment='Error message if the order failed'),
+        db.Field('created_at', 'datetime',
+                 default=db.current_timestamp,
+                 comment='Creation timestamp'),
+        db.Field('updated_at', 'datetime',
+                 default=db.current_timestamp,
+                 update=db.current_timestamp,
+                 comment='Last update timestamp'),
+        db.Field('completed_at', 'datetime',
+                 comment='When the order became valid or invalid'),
+
+        indexes=[
+            ['ca_id'],
+            ['resource_id'],
+            ['status'],
+            ['created_at'],
+        ],
+
+        migrate=True,
+        fake_migrate=False
+    )
+
+
+def define_acme_challenges(db):
+    """Define the acme_challenges table.
+
+    Holds the key authorizations of pending HTTP-01 challenges, which the
+    manager serves under /.well-known/acme-challenge/ until the order
+    completes.
+
+    Args:
+        db: PyDAL DAL instance
+    """
+    db.define_table(
+        'acme_challenges',
+        db.Field('order_id', 'reference acme_orders',
+                 ondelete='CASCADE',
+                 requires=IS_NOT_EMPTY(),
+                 comment='ACME order reference'),
+        db.Field('domain', 'string',
+                 length=255,
+                 requires=IS_NOT_EMPTY(),
+                 comment='Domain being validated'),
+        db.Field('token', 'string',
+                 length=255,
+                 requires=IS_NOT_EMPTY(),
+                 comment='Challenge token'),
+        db.Field('key_authorization', 'text',
+                 requires=IS_NOT_EMPTY(),
+                 comment='Response served for the token'),
+        db.Field('created_at', 'datetime',
+                 default=db.current_timestamp,
+                 comment='Creation timestamp'),
+
+        indexes=[
+            ['token'],
+            ['order_id'],
+        ],
+
+        migrate=True,
+        fake_migrate=False
+    )
@@ -7,6 +7,7 @@ This worker automatically renews expiring certificates based on the following cr
 
 Handles:
 - Kubernetes Secret updates for k8s resources
+- ACME issuers, which place a new order for each renewal
 - External resource certificate reloading via connectors
 - Audit logging for all operations
 - Email/webhook notifications for events
@@ -26,6 +27,8 @@ try:
 except ImportError:
     raise ImportError("pydal is required for certificate rotation worker")
 
+from lib.acme_issuer import AcmeIssuer
+
 
 logger = logging.getLogger(__name__)
 
@@ -90,6 +93,7 @@ class CertRotationWorker:
         notification_handler: Optional[Any] = None,
         check_interval: int = 86400,
         notification_threshold_days: int = 7,
+        acme_issuer: Optional[Any] = None,
     ):
         """Initialize Certificate Rotation Worker.
 
@@ -100,6 +104,7 @@ class CertRotationWorker:
             notification_handler: Notification handler for alerts (optional)
             check_interval: Seconds between rotation checks (default: 86400 = 24 hours)
             notification_threshold_days: Days before expiry to notify (default: 7)
+            acme_issuer: ACME issuer for certificates of ACME CAs (optional)
 
         Raises:
             ValueError: If required parameters are invalid
@@ -115,6 +120,7 @@ class CertRotationWorker:
         self.notification_handler = notification_handler
         self.check_interval = check_interval
         self.notification_threshold_days = notification_threshold_days
+        self.acme_issuer = acme_issuer
         self.is_running = False
 
         logger.info(
@@ -279,13 +285,30 @@ class CertRotationWorker:
                 f"using CA {ca.name}"
             )
 
-            # Generate new certificate with same SANs
-            new_cert_pem, new_key_pem, valid_until = self.ca_manager.renew_certificate(
-                ca_id=cert.ca_id,
-                common_name=cert.common_name,
-                san_dns=cert.san_dns,
-                san_ips=cert.san_ips,
-            )
+            # ACME issuers place a new order for the same domains; the
+            # order is tracked in acme_orders
+            if ca.type == 'acme':
+                if not self.acme_issuer:
+                    raise CANotFoundError(f"No ACME issuer configured for CA {ca.name}")
+                issued = self.acme_issuer.issue(
+                    ca,
+                    cert.san_dns or [cert.common_name],
+                    resource_id=cert.resource_id,
+                )
+                self.db(self.db.acme_orders.id == issued['order_id']).update(
+                    certificate_id=cert_id
+                )
+                new_cert_pem = issued['certificate']
+                new_key_pem = issued['private_key']
+                valid_until = issued['valid_until']
+            else:
+                # Generate new certificate with same SANs
+                new_cert_pem, new_key_pem, valid_until = self.ca_manager.renew_certificate(
+                    ca_id=cert.ca_id,
+                    common_name=cert.common_name,
+                    san_dns=cert.san_dns,
+                    san_ips=cert.san_ips,
+                )
 
             logger.info(
                 f"Certificate {cert_id} successfully renewed "
@@ -591,6 +614,7 @@ def create_cert_rotation_worker(
     ca_manager: Any,
     k8s_client: Optional[Any] = None,
     notification_handler: Optional[Any] = None,
+    acme_issuer: Optional[Any] = None,
 ) -> CertRotationWorker:
     """Factory function to create and configure CertRotationWorker.
 
@@ -603,6 +627,7 @@ def create_cert_rotation_worker(
         ca_manager: CA manager instance
         k8s_client: Kubernetes client (optional)
         notification_handler: Notification handler (optional)
+        acme_issuer: ACME issuer (default: an AcmeIssuer for db)
 
     Returns:
         Configured CertRotationWorker instance
@@ -617,4 +642,5 @@ def create_cert_rotation_worker(
         notification_handler=notification_handler,
         check_interval=check_interval,
         notification_threshold_days=notification_threshold,
+        acme_issuer=acme_issuer or AcmeIssuer(db),
     )
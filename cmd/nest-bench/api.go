@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// endpoint is an API request the traffic is made of, with the share of
+// requests it gets
+type endpoint struct {
+	name   string
+	weight int
+	path   func(team *seededTeam, rng *rand.Rand) string
+}
+
+// endpoints mirror what dashboards and the Backstage plugin read most: the
+// resource list, single resources and their latest stats
+var endpoints = []endpoint{
+	{"list resources", 3, func(team *seededTeam, rng *rand.Rand) string {
+		return "/api/v1/resources?page_size=50"
+	}},
+	{"list resources filtered", 1, func(team *seededTeam, rng *rand.Rand) string {
+		return fmt.Sprintf("/api/v1/resources?team_id=%d&environment=prod", team.ID)
+	}},
+	{"get resource", 4, func(team *seededTeam, rng *rand.Rand) string {
+		return fmt.Sprintf("/api/v1/resources/%d", team.Resources[rng.Intn(len(team.Resources))])
+	}},
+	{"get resource stats", 2, func(team *seededTeam, rng *rand.Rand) string {
+		return fmt.Sprintf("/api/v1/resources/%d/stats", team.Resources[rng.Intn(len(team.Resources))])
+	}},
+}
+
+// driveAPI sends requests from cfg.Concurrency clients for cfg.Duration,
+// each as a random seeded team's service account, and records their
+// latencies by endpoint
+func driveAPI(ctx context.Context, cfg *Config, seeded *Seeded) []*Latencies {
+	ctx, cancel := context.WithTimeout(ctx, cfg.Duration)
+	defer cancel()
+
+	client := &http.Client{
+		Timeout:   30 * time.Second,
+		Transport: &http.Transport{MaxIdleConnsPerHost: cfg.Concurrency},
+	}
+	baseURL := strings.TrimRight(cfg.APIURL, "/")
+
+	latencies := make([]*Latencies, len(endpoints))
+	totalWeight := 0
+	for i, ep := range endpoints {
+		latencies[i] = &Latencies{Name: ep.name}
+		totalWeight += ep.weight
+	}
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for w := 0; w < cfg.Concurrency; w++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(int64(worker)))
+			for ctx.Err() == nil {
+				team := seeded.Teams[rng.Intn(len(seeded.Teams))]
+				pick := rng.Intn(totalWeight)
+				i := 0
+				for pick >= endpoints[i].weight {
+					pick -= endpoints[i].weight
+					i++
+				}
+
+				elapsed, err := get(ctx, client, baseURL+endpoints[i].path(team, rng), team.Token)
+				if ctx.Err() != nil {
+					// Requests cut off at the end of the run aren't counted
+					return
+				}
+				latencies[i].Record(elapsed, err)
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	for _, l := range latencies {
+		l.Elapsed = time.Since(start)
+	}
+	return latencies
+}
+
+// get sends a GET request with a bearer token and returns how long it took,
+// and an error for failed requests and non-2xx responses
+func get(ctx context.Context, client *http.Client, url, token string) (time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return time.Since(start), err
+	}
+	defer resp.Body.Close()
+	_, err = io.Copy(io.Discard, resp.Body)
+	elapsed := time.Since(start)
+	if err != nil {
+		return elapsed, err
+	}
+	if resp.StatusCode >= 300 {
+		return elapsed, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return elapsed, nil
+}
@@ -0,0 +1,136 @@
+// Command nest-bench load tests a NEST installation. It seeds teams with
+// resources straight into the API's database, drives read traffic at the
+// API as the teams' service accounts, optionally queues a reconcile of every
+// resource for the K8s controller, and reports latencies and throughput.
+// Everything it seeds is removed when it finishes, unless -keep is set.
+//
+// Run it against a test installation only: seeded resources are real rows,
+// and with -reconcile the controller provisions them in the cluster.
+//
+//	nest-bench -database-url postgres://... -api-url http://localhost:8080 \
+//		-teams 20 -resources 2000 -duration 1m -concurrency 32
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/penguintechinc/project-template/shared/database"
+)
+
+// Config holds the benchmark's settings
+type Config struct {
+	DatabaseURL string
+	APIURL      string
+	// Teams and Resources are how many teams, and resources across them,
+	// to seed
+	Teams     int
+	Resources int
+	// StatsPerResource is how many stats samples to insert per resource
+	StatsPerResource int
+	// Duration and Concurrency shape the API traffic; a zero Duration
+	// skips it
+	Duration    time.Duration
+	Concurrency int
+	// Reconcile queues a reconcile of every resource and waits up to
+	// ReconcileTimeout for the controller to finish them
+	Reconcile        bool
+	ReconcileTimeout time.Duration
+	// ResourceType names the type of the seeded resources, and Namespace
+	// is where the controller provisions them
+	ResourceType string
+	Namespace    string
+	// Keep leaves the seeded rows in place
+	Keep bool
+}
+
+func main() {
+	cfg := &Config{}
+	flag.StringVar(&cfg.DatabaseURL, "database-url", os.Getenv("DATABASE_URL"), "Postgres URL of the API's database")
+	flag.StringVar(&cfg.APIURL, "api-url", getEnv("NEST_API_URL", "http://localhost:8080"), "Base URL of the API")
+	flag.IntVar(&cfg.Teams, "teams", 10, "Teams to seed")
+	flag.IntVar(&cfg.Resources, "resources", 1000, "Resources to seed, spread across the teams")
+	flag.IntVar(&cfg.StatsPerResource, "stats", 5, "Stats samples to insert per resource")
+	flag.DurationVar(&cfg.Duration, "duration", 30*time.Second, "How long to drive API traffic; 0 skips it")
+	flag.IntVar(&cfg.Concurrency, "concurrency", 16, "Concurrent API clients")
+	flag.BoolVar(&cfg.Reconcile, "reconcile", false, "Queue a reconcile of every resource and measure controller throughput")
+	flag.DurationVar(&cfg.ReconcileTimeout, "reconcile-timeout", 10*time.Minute, "How long to wait for the reconciles")
+	flag.StringVar(&cfg.ResourceType, "resource-type", "redis", "Resource type of the seeded resources")
+	flag.StringVar(&cfg.Namespace, "namespace", "nest-bench", "Namespace the controller provisions seeded resources in")
+	flag.BoolVar(&cfg.Keep, "keep", false, "Keep the seeded teams and resources")
+	flag.Parse()
+
+	if cfg.Teams <= 0 || cfg.Resources < cfg.Teams || cfg.Concurrency <= 0 {
+		log.Fatal("-teams and -concurrency must be positive, and -resources at least -teams")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := run(ctx, cfg); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run seeds the data, runs each load, prints the report and cleans up
+func run(ctx context.Context, cfg *Config) error {
+	db, err := database.NewFromURL(cfg.DatabaseURL)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	runID := strconv.FormatInt(time.Now().Unix(), 36)
+	log.Printf("Seeding %d teams with %d resources (run %s)", cfg.Teams, cfg.Resources, runID)
+	seedStart := time.Now()
+	seeded, err := seed(ctx, db.DB, cfg, runID)
+	if seeded != nil && !cfg.Keep {
+		defer func() {
+			// Clean up even when interrupted
+			if err := seeded.cleanup(context.Background(), db.DB); err != nil {
+				log.Printf("Failed to clean up run %s: %v", runID, err)
+			} else {
+				log.Printf("Removed the data seeded by run %s", runID)
+			}
+			if cfg.Reconcile {
+				log.Printf("Resources provisioned in namespace %s remain; remove them with kubectl delete namespace %s",
+					cfg.Namespace, cfg.Namespace)
+			}
+		}()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to seed: %w", err)
+	}
+
+	report := &Report{Seeded: seeded.summary(), SeedTime: time.Since(seedStart)}
+
+	if cfg.Duration > 0 {
+		log.Printf("Driving API traffic for %s with %d clients", cfg.Duration, cfg.Concurrency)
+		report.API = driveAPI(ctx, cfg, seeded)
+	}
+
+	if cfg.Reconcile && ctx.Err() == nil {
+		log.Printf("Queueing reconciles of %d resources", len(seeded.resourceIDs()))
+		report.Reconcile, err = driveReconciles(ctx, db.DB, cfg, seeded)
+		if err != nil {
+			return fmt.Errorf("failed to drive reconciles: %w", err)
+		}
+	}
+
+	report.Print(os.Stdout)
+	return nil
+}
+
+func getEnv(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type benchReconcileRequest struct {
+	ID          uint
+	ResourceID  uint
+	RequestedBy uint
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+func (benchReconcileRequest) TableName() string { return "reconcile_requests" }
+
+// reconcilePollInterval is how often reconcile statuses are read while
+// waiting on the controller
+const reconcilePollInterval = time.Second
+
+// ReconcileResult is the controller's throughput on a reconcile of every
+// seeded resource
+type ReconcileResult struct {
+	Queued    int
+	Completed int
+	Errors    int
+	// Elapsed runs from queueing the requests to the last reconcile
+	Elapsed time.Duration
+	// Latencies are from queueing to each resource's first reconcile
+	Latencies *Latencies
+}
+
+// Throughput returns completed reconciles per second
+func (r *ReconcileResult) Throughput() float64 {
+	if r.Elapsed <= 0 {
+		return 0
+	}
+	return float64(r.Completed) / r.Elapsed.Seconds()
+}
+
+// driveReconciles queues a reconcile request for every seeded resource and
+// waits, up to cfg.ReconcileTimeout, for the controller to record a
+// reconcile of each. Times are taken from the database's clock and the
+// controller's, so they assume the two are in sync.
+func driveReconciles(ctx context.Context, db *gorm.DB, cfg *Config, seeded *Seeded) (*ReconcileResult, error) {
+	db = db.WithContext(ctx)
+
+	var queuedAt time.Time
+	if err := db.Raw("SELECT now()").Scan(&queuedAt).Error; err != nil {
+		return nil, err
+	}
+	var requests []*benchReconcileRequest
+	for _, team := range seeded.Teams {
+		for _, id := range team.Resources {
+			requests = append(requests, &benchReconcileRequest{ResourceID: id, RequestedBy: team.UserID})
+		}
+	}
+	if err := db.CreateInBatches(requests, 500).Error; err != nil {
+		return nil, fmt.Errorf("failed to queue reconcile requests: %w", err)
+	}
+
+	result := &ReconcileResult{Queued: len(requests), Latencies: &Latencies{Name: "reconcile"}}
+	reconciled := make(map[uint]bool, len(requests))
+	ids := seeded.resourceIDs()
+	deadline := time.Now().Add(cfg.ReconcileTimeout)
+
+	for len(reconciled) < len(requests) {
+		var statuses []struct {
+			ResourceID      uint
+			LastReconcileAt time.Time
+			LastOutcome     string
+		}
+		if err := db.Table("reconcile_statuses").
+			Select("resource_id, last_reconcile_at, last_outcome").
+			Where("resource_id IN ? AND last_reconcile_at >= ?", ids, queuedAt).
+			Find(&statuses).Error; err != nil {
+			return nil, fmt.Errorf("failed to read reconcile statuses: %w", err)
+		}
+		for _, status := range statuses {
+			if reconciled[status.ResourceID] {
+				continue
+			}
+			reconciled[status.ResourceID] = true
+			latency := status.LastReconcileAt.Sub(queuedAt)
+			var err error
+			if status.LastOutcome != "success" {
+				err = fmt.Errorf("reconcile outcome %s", status.LastOutcome)
+			}
+			result.Latencies.Record(latency, err)
+			if latency > result.Elapsed {
+				result.Elapsed = latency
+			}
+		}
+		result.Completed = len(reconciled)
+		result.Errors = result.Latencies.Errors
+
+		if result.Completed == result.Queued {
+			break
+		}
+		if time.Now().After(deadline) {
+			log.Printf("Timed out with %d of %d reconciles done", result.Completed, result.Queued)
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return result, nil
+		case <-time.After(reconcilePollInterval):
+		}
+	}
+	result.Latencies.Elapsed = result.Elapsed
+	return result, nil
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// Latencies collects the durations of one kind of operation
+type Latencies struct {
+	Name string
+	// Elapsed is the wall time the operations ran over
+	Elapsed time.Duration
+	Errors  int
+
+	mu        sync.Mutex
+	durations []time.Duration
+	sorted    bool
+}
+
+// Record adds an operation's duration, counting it as an error when err is
+// set
+func (l *Latencies) Record(d time.Duration, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.durations = append(l.durations, d)
+	l.sorted = false
+	if err != nil {
+		l.Errors++
+	}
+}
+
+// Count returns how many operations were recorded
+func (l *Latencies) Count() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.durations)
+}
+
+// Percentile returns the duration p (0 to 1) of the operations took at most
+func (l *Latencies) Percentile(p float64) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.durations) == 0 {
+		return 0
+	}
+	if !l.sorted {
+		sort.Slice(l.durations, func(i, j int) bool { return l.durations[i] < l.durations[j] })
+		l.sorted = true
+	}
+	i := int(math.Ceil(p*float64(len(l.durations)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	return l.durations[i]
+}
+
+// Rate returns operations per second
+func (l *Latencies) Rate() float64 {
+	if l.Elapsed <= 0 {
+		return 0
+	}
+	return float64(l.Count()) / l.Elapsed.Seconds()
+}
+
+// Report is the outcome of a run
+type Report struct {
+	Seeded    string
+	SeedTime  time.Duration
+	API       []*Latencies
+	Reconcile *ReconcileResult
+}
+
+// Print writes the report as tables
+func (r *Report) Print(w io.Writer) {
+	fmt.Fprintf(w, "\nSeeded %s in %s\n", r.Seeded, r.SeedTime.Round(time.Millisecond))
+
+	if len(r.API) > 0 {
+		fmt.Fprintln(w, "\nAPI")
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintln(tw, "endpoint\trequests\terrors\treq/s\tp50\tp99\tmax\t")
+		total := &Latencies{Name: "total"}
+		for _, l := range r.API {
+			printLatencies(tw, l)
+			total.Elapsed = l.Elapsed
+			total.Errors += l.Errors
+			l.mu.Lock()
+			total.durations = append(total.durations, l.durations...)
+			l.mu.Unlock()
+		}
+		printLatencies(tw, total)
+		tw.Flush()
+	}
+
+	if r.Reconcile != nil {
+		fmt.Fprintln(w, "\nReconcile")
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintln(tw, "queued\tdone\terrors\telapsed\tper s\tp50\tp99\tmax\t")
+		l := r.Reconcile.Latencies
+		fmt.Fprintf(tw, "%d\t%d\t%d\t%s\t%.1f\t%s\t%s\t%s\t\n",
+			r.Reconcile.Queued, r.Reconcile.Completed, r.Reconcile.Errors,
+			r.Reconcile.Elapsed.Round(time.Millisecond), r.Reconcile.Throughput(),
+			round(l.Percentile(0.5)), round(l.Percentile(0.99)), round(l.Percentile(1)))
+		tw.Flush()
+	}
+}
+
+func printLatencies(w io.Writer, l *Latencies) {
+	fmt.Fprintf(w, "%s\t%d\t%d\t%.1f\t%s\t%s\t%s\t\n", l.Name, l.Count(), l.Errors, l.Rate(),
+		round(l.Percentile(0.5)), round(l.Percentile(0.99)), round(l.Percentile(1)))
+}
+
+// round rounds a latency for display
+func round(d time.Duration) time.Duration {
+	if d >= time.Second {
+		return d.Round(time.Millisecond)
+	}
+	return d.Round(10 * time.Microsecond)
+}
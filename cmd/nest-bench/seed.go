@@ -0,0 +1,302 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// The rows the benchmark seeds, with the columns of the API's models it
+// sets. The API migrates the tables; the benchmark only writes to them.
+
+type benchTeam struct {
+	ID          uint
+	Name        string
+	Description string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+func (benchTeam) TableName() string { return "teams" }
+
+type benchUser struct {
+	ID           uint
+	Username     string
+	Email        string
+	PasswordHash string
+	Role         string
+	IsActive     bool
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+func (benchUser) TableName() string { return "users" }
+
+type benchTeamMember struct {
+	ID        uint
+	TeamID    uint
+	UserID    uint
+	Role      string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (benchTeamMember) TableName() string { return "team_members" }
+
+type benchToken struct {
+	ID          uint
+	Name        string
+	UserID      uint
+	Scope       string
+	TokenHash   string
+	TokenPrefix string
+	CreatedBy   uint
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+func (benchToken) TableName() string { return "backstage_tokens" }
+
+type benchResource struct {
+	ID             uint
+	Name           string
+	ResourceTypeID uint
+	TeamID         uint
+	Environment    string
+	Status         string
+	LifecycleMode  string
+	K8sNamespace   string
+	CreatedBy      uint
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+func (benchResource) TableName() string { return "resources" }
+
+type benchStats struct {
+	ID         uint
+	ResourceID uint
+	Timestamp  time.Time
+	Metrics    string `gorm:"type:jsonb"`
+	RiskLevel  string
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+func (benchStats) TableName() string { return "resource_stats" }
+
+// seededTeam is a seeded team, the service account that is a viewer of it,
+// and the team's resources
+type seededTeam struct {
+	ID        uint
+	UserID    uint
+	TokenID   uint
+	Token     string
+	Resources []uint
+}
+
+// Seeded is everything a run seeded
+type Seeded struct {
+	RunID string
+	Teams []*seededTeam
+	Stats int
+}
+
+// backstageTokenPrefix marks Backstage tokens, as the API expects
+const backstageTokenPrefix = "nestbs_"
+
+// seed creates the teams, each with a service account holding a read scoped
+// Backstage token, and the resources spread evenly across them with their
+// stats. It returns what was seeded so far even when it fails, so that it
+// can be cleaned up.
+func seed(ctx context.Context, db *gorm.DB, cfg *Config, runID string) (*Seeded, error) {
+	db = db.WithContext(ctx)
+	seeded := &Seeded{RunID: runID}
+
+	var resourceTypeID uint
+	if err := db.Table("resource_types").Where("name = ? AND deleted_at IS NULL", cfg.ResourceType).
+		Pluck("id", &resourceTypeID).Error; err != nil {
+		return nil, err
+	}
+	if resourceTypeID == 0 {
+		return nil, fmt.Errorf("resource type %q does not exist", cfg.ResourceType)
+	}
+
+	// Partial lifecycle resources are left alone by the controller, so they
+	// are only full lifecycle, and pending creation, when they are to be
+	// reconciled
+	lifecycle, status := "partial", "active"
+	if cfg.Reconcile {
+		lifecycle, status = "full", "pending"
+	}
+
+	for t := 0; t < cfg.Teams; t++ {
+		name := fmt.Sprintf("bench-%s-%d", runID, t)
+		team := &benchTeam{Name: name, Description: "Seeded by nest-bench run " + runID}
+		if err := db.Create(team).Error; err != nil {
+			return seeded, fmt.Errorf("failed to create team: %w", err)
+		}
+		st := &seededTeam{ID: team.ID}
+		seeded.Teams = append(seeded.Teams, st)
+
+		user := &benchUser{Username: name, Email: name + "@bench.invalid", PasswordHash: "!", Role: "user", IsActive: true}
+		if err := db.Create(user).Error; err != nil {
+			return seeded, fmt.Errorf("failed to create service account: %w", err)
+		}
+		st.UserID = user.ID
+		if err := db.Create(&benchTeamMember{TeamID: team.ID, UserID: user.ID, Role: "viewer"}).Error; err != nil {
+			return seeded, fmt.Errorf("failed to add service account to team: %w", err)
+		}
+
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return seeded, err
+		}
+		st.Token = backstageTokenPrefix + hex.EncodeToString(secret)
+		sum := sha256.Sum256([]byte(st.Token))
+		token := &benchToken{
+			Name:        name,
+			UserID:      user.ID,
+			Scope:       "read",
+			TokenHash:   hex.EncodeToString(sum[:]),
+			TokenPrefix: st.Token[:len(backstageTokenPrefix)+8],
+			CreatedBy:   user.ID,
+		}
+		if err := db.Create(token).Error; err != nil {
+			return seeded, fmt.Errorf("failed to create token: %w", err)
+		}
+		st.TokenID = token.ID
+
+		count := cfg.Resources / cfg.Teams
+		if t < cfg.Resources%cfg.Teams {
+			count++
+		}
+		resources := make([]*benchResource, 0, count)
+		for r := 0; r < count; r++ {
+			resources = append(resources, &benchResource{
+				Name:           fmt.Sprintf("%s-%d", name, r),
+				ResourceTypeID: resourceTypeID,
+				TeamID:         team.ID,
+				Environment:    []string{"dev", "staging", "prod"}[r%3],
+				Status:         status,
+				LifecycleMode:  lifecycle,
+				K8sNamespace:   cfg.Namespace,
+				CreatedBy:      user.ID,
+			})
+		}
+		if err := db.CreateInBatches(resources, 500).Error; err != nil {
+			return seeded, fmt.Errorf("failed to create resources: %w", err)
+		}
+		for _, resource := range resources {
+			st.Resources = append(st.Resources, resource.ID)
+		}
+
+		if err := seedStats(db, st.Resources, cfg.StatsPerResource); err != nil {
+			return seeded, err
+		}
+		seeded.Stats += len(st.Resources) * cfg.StatsPerResource
+	}
+	return seeded, nil
+}
+
+// seedStats inserts stats samples a minute apart for each resource
+func seedStats(db *gorm.DB, resourceIDs []uint, perResource int) error {
+	if perResource <= 0 {
+		return nil
+	}
+	now := time.Now().UTC()
+	samples := make([]*benchStats, 0, len(resourceIDs)*perResource)
+	for _, id := range resourceIDs {
+		for s := 0; s < perResource; s++ {
+			samples = append(samples, &benchStats{
+				ResourceID: id,
+				Timestamp:  now.Add(-time.Duration(s) * time.Minute),
+				Metrics:    fmt.Sprintf(`{"connections":%d,"cpu_percent":%d}`, s*7%100, s*13%100),
+				RiskLevel:  "low",
+			})
+		}
+	}
+	if err := db.CreateInBatches(samples, 1000).Error; err != nil {
+		return fmt.Errorf("failed to create stats: %w", err)
+	}
+	return nil
+}
+
+// resourceIDs returns the IDs of every seeded resource
+func (s *Seeded) resourceIDs() []uint {
+	var ids []uint
+	for _, team := range s.Teams {
+		ids = append(ids, team.Resources...)
+	}
+	return ids
+}
+
+// summary describes what was seeded
+func (s *Seeded) summary() string {
+	return fmt.Sprintf("%d teams, %d resources, %d stats samples", len(s.Teams), len(s.resourceIDs()), s.Stats)
+}
+
+// resourceTables are the tables whose rows reference seeded resources
+var resourceTables = []string{
+	"resource_stats", "reconcile_requests", "reconcile_statuses", "stuck_resources",
+	"provisioning_jobs", "operations", "resource_locks",
+}
+
+// cleanup removes every row the run seeded, and the rows referencing its
+// resources that the API and controller created meanwhile. Audit logs are
+// left in place, as their hash chain covers them.
+func (s *Seeded) cleanup(ctx context.Context, db *gorm.DB) error {
+	var teamIDs, userIDs, tokenIDs []uint
+	for _, team := range s.Teams {
+		teamIDs = append(teamIDs, team.ID)
+		if team.UserID != 0 {
+			userIDs = append(userIDs, team.UserID)
+		}
+		if team.TokenID != 0 {
+			tokenIDs = append(tokenIDs, team.TokenID)
+		}
+	}
+	resourceIDs := s.resourceIDs()
+
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if len(resourceIDs) > 0 {
+			for _, table := range resourceTables {
+				if !tx.Migrator().HasTable(table) {
+					continue
+				}
+				if err := tx.Exec("DELETE FROM "+table+" WHERE resource_id IN ?", resourceIDs).Error; err != nil {
+					return fmt.Errorf("failed to clean up %s: %w", table, err)
+				}
+			}
+			if err := tx.Exec("DELETE FROM resources WHERE id IN ?", resourceIDs).Error; err != nil {
+				return fmt.Errorf("failed to clean up resources: %w", err)
+			}
+		}
+		if len(tokenIDs) > 0 {
+			if err := tx.Exec("DELETE FROM backstage_tokens WHERE id IN ?", tokenIDs).Error; err != nil {
+				return fmt.Errorf("failed to clean up tokens: %w", err)
+			}
+		}
+		if len(teamIDs) > 0 {
+			if err := tx.Exec("DELETE FROM team_members WHERE team_id IN ?", teamIDs).Error; err != nil {
+				return fmt.Errorf("failed to clean up memberships: %w", err)
+			}
+		}
+		if len(userIDs) > 0 {
+			if err := tx.Exec("DELETE FROM users WHERE id IN ?", userIDs).Error; err != nil {
+				return fmt.Errorf("failed to clean up service accounts: %w", err)
+			}
+		}
+		if len(teamIDs) > 0 {
+			if err := tx.Exec("DELETE FROM teams WHERE id IN ?", teamIDs).Error; err != nil {
+				return fmt.Errorf("failed to clean up teams: %w", err)
+			}
+		}
+		return nil
+	})
+}
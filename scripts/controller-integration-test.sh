@@ -0,0 +1,94 @@
+#!/bin/bash
+# Run the K8s controller integration tests against a kind cluster - nest
+set -euo pipefail
+
+SCRIPT_DIR="$(cd "$(dirname "${BASH_SOURCE[0]}")" && pwd)"
+PROJECT_ROOT="$(cd "$SCRIPT_DIR/.." && pwd)"
+
+CLUSTER_NAME="${CLUSTER_NAME:-nest-it}"
+DB_CONTAINER="${DB_CONTAINER:-nest-it-db}"
+DB_PORT="${DB_PORT:-55432}"
+DB_PASSWORD="${DB_PASSWORD:-integration}"
+KEEP_CLUSTER="${KEEP_CLUSTER:-0}"
+TEST_RUN="${TEST_RUN:-}"
+
+RED='\033[0;31m'
+GREEN='\033[0;32m'
+BLUE='\033[0;34m'
+NC='\033[0m'
+
+log_info() { echo -e "${GREEN}[INFO]${NC} $1"; }
+log_error() { echo -e "${RED}[ERROR]${NC} $1"; }
+log_section() { echo ""; echo -e "${BLUE}========================================${NC}"; echo -e "${BLUE}$1${NC}"; echo -e "${BLUE}========================================${NC}"; echo ""; }
+
+CREATED_CLUSTER=0
+
+cleanup() {
+    log_section "Cleaning Up"
+    docker rm -f "$DB_CONTAINER" &>/dev/null || true
+    if [ "$CREATED_CLUSTER" = "1" ] && [ "$KEEP_CLUSTER" != "1" ]; then
+        kind delete cluster --name "$CLUSTER_NAME" || true
+    fi
+}
+
+check_prerequisites() {
+    log_section "Checking Prerequisites"
+    for cmd in docker kind go; do
+        if ! command -v "$cmd" &>/dev/null; then
+            log_error "$cmd is not installed"
+            return 1
+        fi
+        log_info "$cmd: found"
+    done
+}
+
+start_cluster() {
+    log_section "Starting kind Cluster"
+    if kind get clusters 2>/dev/null | grep -qx "$CLUSTER_NAME"; then
+        log_info "Using existing cluster $CLUSTER_NAME"
+    else
+        kind create cluster --name "$CLUSTER_NAME" --wait 120s
+        CREATED_CLUSTER=1
+    fi
+    KUBECONFIG_FILE="$(mktemp)"
+    kind get kubeconfig --name "$CLUSTER_NAME" > "$KUBECONFIG_FILE"
+}
+
+start_database() {
+    log_section "Starting Postgres"
+    docker rm -f "$DB_CONTAINER" &>/dev/null || true
+    docker run -d --name "$DB_CONTAINER" -e POSTGRES_PASSWORD="$DB_PASSWORD" \
+        -p "$DB_PORT:5432" postgres:16-alpine >/dev/null
+    for _ in $(seq 1 30); do
+        if docker exec "$DB_CONTAINER" pg_isready -U postgres &>/dev/null; then
+            log_info "Postgres is ready on port $DB_PORT"
+            return 0
+        fi
+        sleep 1
+    done
+    log_error "Postgres did not become ready"
+    return 1
+}
+
+run_tests() {
+    log_section "Running Integration Tests"
+    cd "$PROJECT_ROOT/services/k8s-controller"
+    local args=(-v -tags integration -count 1)
+    if [ -n "$TEST_RUN" ]; then
+        args+=(-run "$TEST_RUN")
+    fi
+    USE_EXISTING_CLUSTER=true KUBECONFIG="$KUBECONFIG_FILE" \
+    DB_HOST=localhost DB_PORT="$DB_PORT" DB_USER=postgres DB_PASSWORD="$DB_PASSWORD" DB_NAME=postgres \
+        go test "${args[@]}" ./controller/...
+}
+
+main() {
+    check_prerequisites
+    trap cleanup EXIT
+    start_cluster
+    start_database
+    run_tests
+    log_info "Integration tests passed"
+}
+
+main "$@"
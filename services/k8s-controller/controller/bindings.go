@@ -0,0 +1,360 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/penguintechinc/nest/services/k8s-controller/pkg/models"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// bindingLabel names the consumer binding a Secret is kept for
+const bindingLabel = "nest.penguintech.io/binding-id"
+
+// Consumer binding statuses. The API creates bindings as pending.
+const (
+	bindingSynced = "synced"
+	bindingError  = "error"
+)
+
+// defaultPorts are the ports of engines whose connection info has none
+var defaultPorts = map[string]int{
+	"postgresql": 5432,
+	"mariadb":    3306,
+	"mysql":      3306,
+	"redis":      6379,
+}
+
+// bindingResource is a bound resource with its type name
+type bindingResource struct {
+	models.Resource
+	TypeName string
+}
+
+// bindingLoop syncs the consumer binding Secrets at startup and on each
+// interval, so rotated credentials reach applications within one interval
+func (c *Controller) bindingLoop(ctx context.Context) {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(c.config.BindingSyncInterval)
+	defer ticker.Stop()
+
+	c.log.WithField("interval", c.config.BindingSyncInterval).Info("Starting consumer binding sync")
+	if c.current().EnableConsumerBindings {
+		c.syncBindings(ctx)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-c.stopChan:
+			return
+		case <-ticker.C:
+			if c.current().EnableConsumerBindings {
+				c.syncBindings(ctx)
+			}
+		}
+	}
+}
+
+// syncBindings removes the Secrets of deleted bindings, and of bindings
+// whose resource is deleted, then writes the Secret of every other binding
+func (c *Controller) syncBindings(ctx context.Context) {
+	log := c.log.WithField("action", "consumer_bindings")
+
+	var bindings []models.ConsumerBinding
+	if err := c.db.Order("id").Find(&bindings).Error; err != nil {
+		log.WithError(err).Error("Failed to query consumer bindings")
+		return
+	}
+	if len(bindings) == 0 {
+		return
+	}
+
+	ids := make([]uint, 0, len(bindings))
+	for _, b := range bindings {
+		ids = append(ids, b.ResourceID)
+	}
+	var rows []bindingResource
+	if err := c.db.Table("resources").
+		Select("resources.*, resource_types.name AS type_name").
+		Joins("INNER JOIN resource_types ON resource_types.id = resources.resource_type_id").
+		Where("resources.id IN ? AND resources.deleted_at IS NULL", ids).
+		Find(&rows).Error; err != nil {
+		log.WithError(err).Error("Failed to query bound resources")
+		return
+	}
+	resources := map[uint]*bindingResource{}
+	for i := range rows {
+		resources[rows[i].ID] = &rows[i]
+	}
+	provisionedServices := c.servesResource(provisionedServiceGVR)
+
+	// Removals go first, so a binding recreated for the same Secret takes
+	// it over in the same pass
+	for i := range bindings {
+		binding := &bindings[i]
+		if binding.DeletedAt == nil && resources[binding.ResourceID] != nil {
+			continue
+		}
+		bLog := log.WithFields(logrus.Fields{"binding_id": binding.ID, "secret": binding.Namespace + "/" + binding.SecretName})
+		if provisionedServices {
+			if err := c.removeProvisionedService(ctx, binding); err != nil {
+				bLog.WithError(err).Warn("Failed to remove ProvisionedService")
+				continue
+			}
+		}
+		if err := c.removeBindingSecret(ctx, binding); err != nil {
+			bLog.WithError(err).Warn("Failed to remove consumer binding Secret")
+			continue
+		}
+		if binding.DeletedAt != nil {
+			if err := c.db.Delete(binding).Error; err != nil {
+				bLog.WithError(err).Warn("Failed to remove deleted consumer binding")
+			}
+			continue
+		}
+		// The resource is in the trash; restoring it restores the Secret
+		c.setBindingStatus(binding, bindingError, "The resource has been deleted", false)
+	}
+
+	for i := range bindings {
+		binding := &bindings[i]
+		resource := resources[binding.ResourceID]
+		if binding.DeletedAt != nil || resource == nil {
+			continue
+		}
+
+		written := false
+		data, err := c.bindingSecretData(resource)
+		if err == nil {
+			written, err = c.publishBindingSecret(ctx, binding, data)
+		}
+		bLog := log.WithFields(logrus.Fields{"binding_id": binding.ID, "secret": binding.Namespace + "/" + binding.SecretName})
+		if provisionedServices {
+			hasSecret := written || binding.LastSyncedAt != nil
+			if psErr := c.publishProvisionedService(ctx, binding, resource.TypeName, hasSecret, err); psErr != nil {
+				bLog.WithError(psErr).Warn("Failed to publish ProvisionedService")
+			}
+		}
+		if err != nil {
+			bLog.WithError(err).Warn("Failed to sync consumer binding Secret")
+			c.setBindingStatus(binding, bindingError, err.Error(), false)
+			continue
+		}
+		c.setBindingStatus(binding, bindingSynced, "", written)
+	}
+}
+
+// bindingSecretData builds the Secret of a bound resource in the
+// servicebinding.io layout: its type and provider, connection details, a
+// connection URI, and, for TLS resources, the issuing CA
+func (c *Controller) bindingSecretData(resource *bindingResource) (map[string][]byte, error) {
+	host := stringFromMap(resource.ConnectionInfo, "host")
+	if host == "" {
+		host = stringFromMap(resource.ConnectionInfo, "service_name")
+	}
+	if host == "" {
+		return nil, fmt.Errorf("resource has no host in connection info")
+	}
+	// Applications run in other namespaces than the resource's service
+	if !strings.Contains(host, ".") && host != "localhost" && resource.K8sNamespace != nil && *resource.K8sNamespace != "" {
+		host = fmt.Sprintf("%s.%s.svc.cluster.local", host, *resource.K8sNamespace)
+	}
+
+	port := defaultPorts[resource.TypeName]
+	if p, ok := resource.ConnectionInfo["port"].(float64); ok && p > 0 {
+		port = int(p)
+	}
+	if port == 0 {
+		return nil, fmt.Errorf("resource has no port in connection info")
+	}
+
+	// Redis selects databases by number
+	database := stringFromMap(resource.ConnectionInfo, "database")
+	if n, ok := resource.ConnectionInfo["database"].(float64); ok {
+		database = strconv.Itoa(int(n))
+	}
+	username := stringFromMap(resource.Credentials, "username")
+	password := stringFromMap(resource.Credentials, "password")
+
+	// The binding type is the engine's URI scheme, so MariaDB binds as mysql
+	data := map[string][]byte{
+		"type":     []byte(uriScheme(resource.TypeName, false)),
+		"provider": []byte("nest"),
+		"host":     []byte(host),
+		"port":     []byte(strconv.Itoa(port)),
+	}
+	if database != "" {
+		data["database"] = []byte(database)
+	}
+	if username != "" {
+		data["username"] = []byte(username)
+	}
+	if password != "" {
+		data["password"] = []byte(password)
+	}
+
+	u := &url.URL{
+		Scheme: uriScheme(resource.TypeName, resource.TLSEnabled),
+		Host:   net.JoinHostPort(host, strconv.Itoa(port)),
+	}
+	switch {
+	case username != "":
+		u.User = url.UserPassword(username, password)
+	case password != "":
+		u.User = url.UserPassword("", password)
+	}
+	if database != "" {
+		u.Path = "/" + database
+	}
+	if resource.TypeName == "postgresql" {
+		if sslMode := stringFromMap(resource.ConnectionInfo, "ssl_mode"); sslMode != "" {
+			u.RawQuery = url.Values{"sslmode": {sslMode}}.Encode()
+		} else if resource.TLSEnabled {
+			u.RawQuery = url.Values{"sslmode": {"verify-full"}}.Encode()
+		}
+	}
+	data["uri"] = []byte(u.String())
+
+	if resource.TLSEnabled && resource.TLSCaID != nil {
+		var ca models.CertificateAuthority
+		if err := c.db.Where("id = ? AND deleted_at IS NULL", *resource.TLSCaID).First(&ca).Error; err != nil {
+			return nil, fmt.Errorf("failed to load the resource's CA: %w", err)
+		}
+		var bundle strings.Builder
+		writeCertificates(&bundle, map[string]bool{}, ca)
+		if bundle.Len() > 0 {
+			data["ca.crt"] = []byte(bundle.String())
+		}
+	}
+
+	return data, nil
+}
+
+// uriScheme returns the connection URI scheme of an engine
+func uriScheme(engine string, tls bool) string {
+	switch engine {
+	case "mariadb", "mysql":
+		return "mysql"
+	case "redis":
+		if tls {
+			return "rediss"
+		}
+		return "redis"
+	default:
+		return engine
+	}
+}
+
+// publishBindingSecret creates or updates a binding's Secret, reporting
+// whether it was written. Secrets of the same name that NEST didn't create
+// for the binding are left alone.
+func (c *Controller) publishBindingSecret(ctx context.Context, binding *models.ConsumerBinding, data map[string][]byte) (bool, error) {
+	secrets := c.clientset.CoreV1().Secrets(binding.Namespace)
+	bindingID := strconv.FormatUint(uint64(binding.ID), 10)
+
+	existing, err := secrets.Get(ctx, binding.SecretName, metav1.GetOptions{})
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			return false, fmt.Errorf("failed to get Secret: %w", err)
+		}
+		_, err = secrets.Create(ctx, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      binding.SecretName,
+				Namespace: binding.Namespace,
+				Labels: map[string]string{
+					"managed-by":  "nest-controller",
+					"resource-id": strconv.FormatUint(uint64(binding.ResourceID), 10),
+					bindingLabel:  bindingID,
+				},
+			},
+			Type: corev1.SecretTypeOpaque,
+			Data: data,
+		}, metav1.CreateOptions{})
+		if err != nil {
+			return false, fmt.Errorf("failed to create Secret: %w", err)
+		}
+		c.log.WithField("secret", binding.Namespace+"/"+binding.SecretName).Info("Created consumer binding Secret")
+		return true, nil
+	}
+
+	if existing.Labels["managed-by"] != "nest-controller" || existing.Labels[bindingLabel] != bindingID {
+		return false, fmt.Errorf("Secret %s exists and is not managed by this binding", binding.SecretName)
+	}
+	if secretDataEqual(existing.Data, data) {
+		return false, nil
+	}
+
+	existing.Data = data
+	if _, err := secrets.Update(ctx, existing, metav1.UpdateOptions{}); err != nil {
+		return false, fmt.Errorf("failed to update Secret: %w", err)
+	}
+	c.log.WithField("secret", binding.Namespace+"/"+binding.SecretName).Info("Updated consumer binding Secret")
+	return true, nil
+}
+
+// removeBindingSecret deletes a binding's Secret if NEST created it for the
+// binding
+func (c *Controller) removeBindingSecret(ctx context.Context, binding *models.ConsumerBinding) error {
+	secrets := c.clientset.CoreV1().Secrets(binding.Namespace)
+
+	existing, err := secrets.Get(ctx, binding.SecretName, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to get Secret: %w", err)
+	}
+	if existing.Labels["managed-by"] != "nest-controller" ||
+		existing.Labels[bindingLabel] != strconv.FormatUint(uint64(binding.ID), 10) {
+		return nil
+	}
+	if err := secrets.Delete(ctx, binding.SecretName, metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete Secret: %w", err)
+	}
+	c.log.WithField("secret", binding.Namespace+"/"+binding.SecretName).Info("Removed consumer binding Secret")
+	return nil
+}
+
+// setBindingStatus records a binding's sync outcome, writing only when it
+// changed or the Secret was written
+func (c *Controller) setBindingStatus(binding *models.ConsumerBinding, status, lastError string, written bool) {
+	if binding.Status == status && binding.LastError == lastError && !written {
+		return
+	}
+	updates := map[string]interface{}{
+		"status":     status,
+		"last_error": lastError,
+	}
+	if written {
+		updates["last_synced_at"] = time.Now().UTC()
+	}
+	if err := c.db.Model(binding).Updates(updates).Error; err != nil {
+		c.log.WithError(err).WithField("binding_id", binding.ID).Warn("Failed to update consumer binding status")
+	}
+}
+
+// secretDataEqual reports whether two Secret payloads hold the same keys
+// and values
+func secretDataEqual(a, b map[string][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if w, ok := b[k]; !ok || !bytes.Equal(v, w) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,171 @@
+//go:build integration
+
+package controller
+
+// Chaos tests reconcile resources while faults are injected, and check that
+// the retry queue backs off and the controller recovers once the faults
+// stop. They run with the rest of the integration suite, or on their own:
+//
+//	go test -tags integration -run Chaos ./controller/...
+//
+// Faults are drawn from FAULT_SEED when set, so a failing run can be
+// repeated.
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/penguintechinc/nest/services/k8s-controller/pkg/config"
+	"github.com/penguintechinc/nest/services/k8s-controller/pkg/faults"
+	"github.com/penguintechinc/nest/services/k8s-controller/pkg/models"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// chaosHarness is a test harness injecting faults, and the resource it
+// reconciles
+type chaosHarness struct {
+	*testHarness
+	resource *models.Resource
+}
+
+// newChaosHarness creates a harness injecting faults at the given rates,
+// with one full lifecycle resource waiting to be created
+func newChaosHarness(t *testing.T, rates faults.Config) *chaosHarness {
+	t.Helper()
+	h := newTestHarness(t, func(cfg *config.Config) {
+		cfg.FaultInjectionEnabled = true
+		if os.Getenv("FAULT_SEED") == "" {
+			cfg.FaultSeed = 1
+		}
+		t.Logf("Injecting faults with seed %d", cfg.FaultSeed)
+		cfg.FaultK8sErrorRate = rates.K8sErrorRate
+		cfg.FaultDBTimeoutRate = rates.DBTimeoutRate
+		cfg.FaultSlowReconcileRate = rates.SlowReconcileRate
+		cfg.FaultMaxReconcileDelay = rates.MaxReconcileDelay
+	})
+	return &chaosHarness{testHarness: h, resource: h.createResource(t, "chaos")}
+}
+
+// reconcile runs one reconcile of the harness resource
+func (h *chaosHarness) reconcile(t *testing.T, timeout time.Duration) {
+	t.Helper()
+	h.testHarness.reconcile(t, h.resource.ID, timeout)
+}
+
+// retryCount returns the resource's retry count in the retry queue
+func (h *chaosHarness) retryCount() int {
+	return h.testHarness.retryCount(h.resource.ID)
+}
+
+// recover stops the faults and reconciles until the retry queue lets the
+// resource through and it is created
+func (h *chaosHarness) recover(t *testing.T) {
+	t.Helper()
+	h.controller.faults.Pause()
+	deadline := time.Now().Add(30 * time.Second)
+	for h.retryCount() > 0 || h.status(t) != "active" {
+		if time.Now().After(deadline) {
+			t.Fatalf("Resource didn't recover: status %s, retry count %d", h.status(t), h.retryCount())
+		}
+		if !h.controller.shouldSkipRetry(h.resource.ID) {
+			h.reconcile(t, 10*time.Second)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func (h *chaosHarness) status(t *testing.T) string {
+	t.Helper()
+	return h.load(t, h.resource.ID).Status
+}
+
+// checkSettled checks that no provisioning job was left running and the
+// StatefulSet exists
+func (h *chaosHarness) checkSettled(t *testing.T) {
+	t.Helper()
+	var running int64
+	h.db.Model(&models.ProvisioningJob{}).Where("resource_id = ? AND status = ?", h.resource.ID, jobStatusRunning).
+		Count(&running)
+	if running > 0 {
+		t.Errorf("Expected no running provisioning jobs, got %d", running)
+	}
+	if _, err := h.controller.clientset.AppsV1().StatefulSets(*h.resource.K8sNamespace).
+		Get(context.Background(), h.resource.Name, metav1.GetOptions{}); err != nil {
+		t.Errorf("Expected the StatefulSet to exist: %v", err)
+	}
+}
+
+func TestChaosK8sErrorsBackOff(t *testing.T) {
+	h := newChaosHarness(t, faults.Config{K8sErrorRate: 1})
+
+	for attempt := 1; attempt <= 3; attempt++ {
+		h.reconcile(t, 10*time.Second)
+		if got := h.retryCount(); got != attempt {
+			t.Fatalf("Expected retry count %d after failed reconcile %d, got %d", attempt, attempt, got)
+		}
+	}
+
+	var status models.ReconcileStatus
+	if err := h.db.First(&status, "resource_id = ?", h.resource.ID).Error; err != nil {
+		t.Fatalf("Failed to load reconcile status: %v", err)
+	}
+	if status.LastOutcome != reconcileOutcomeError || status.RetryCount != 3 || status.NextRetryAt == nil {
+		t.Errorf("Expected a recorded error with 3 retries, got %+v", status)
+	}
+	if h.controller.faults.Injected(faults.K8sError) == 0 {
+		t.Error("Expected Kubernetes API errors to be injected")
+	}
+
+	h.recover(t)
+	h.checkSettled(t)
+}
+
+func TestChaosDBTimeoutsRecover(t *testing.T) {
+	h := newChaosHarness(t, faults.Config{DBTimeoutRate: 0.3})
+
+	for attempt := 0; attempt < 10; attempt++ {
+		h.reconcile(t, 10*time.Second)
+	}
+	if h.controller.faults.Injected(faults.DBTimeout) == 0 {
+		t.Error("Expected database timeouts to be injected")
+	}
+
+	h.recover(t)
+	h.checkSettled(t)
+}
+
+func TestChaosSlowReconcilesTimeOut(t *testing.T) {
+	h := newChaosHarness(t, faults.Config{SlowReconcileRate: 1, MaxReconcileDelay: time.Hour})
+
+	start := time.Now()
+	h.reconcile(t, 50*time.Millisecond)
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected the slowed reconcile to stop with its context, took %s", elapsed)
+	}
+	if got := h.retryCount(); got != 1 {
+		t.Errorf("Expected the timed out reconcile to be retried, got retry count %d", got)
+	}
+
+	h.recover(t)
+	h.checkSettled(t)
+}
+
+func TestChaosSameSeedSameFaults(t *testing.T) {
+	run := func() []int64 {
+		h := newChaosHarness(t, faults.Config{K8sErrorRate: 0.5})
+		counts := make([]int64, 0, 5)
+		for attempt := 0; attempt < 5; attempt++ {
+			h.reconcile(t, 10*time.Second)
+			counts = append(counts, h.controller.faults.Injected(faults.K8sError))
+		}
+		return counts
+	}
+
+	first, second := run(), run()
+	if fmt.Sprint(first) != fmt.Sprint(second) {
+		t.Errorf("Expected the same faults with the same seed, got %v and %v", first, second)
+	}
+}
@@ -0,0 +1,493 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/penguintechinc/nest/services/k8s-controller/pkg/locks"
+	"github.com/penguintechinc/nest/services/k8s-controller/pkg/models"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// claimFinalizer keeps a claim until the resource created for it is deleted
+const claimFinalizer = "nest.penguintech.io/claim"
+
+// teamIDLabel maps a namespace to the team its claims create resources for
+const teamIDLabel = "nest.penguintech.io/team-id"
+
+// claimKind is a claim resource the controller fulfils, and the engine its
+// claims provision when fixed by the kind rather than their parameters
+type claimKind struct {
+	gvr    schema.GroupVersionResource
+	engine string
+}
+
+// claimedResource is the resources row created for a claim
+type claimedResource struct {
+	ID                 uint `gorm:"primaryKey"`
+	Name               string
+	ResourceTypeID     uint
+	TeamID             uint
+	Environment        string `gorm:"default:dev"`
+	Status             string
+	LifecycleMode      string
+	ProvisioningMethod string
+	Credentials        models.JSONMap `gorm:"type:jsonb"`
+	Config             models.JSONMap `gorm:"type:jsonb"`
+	K8sNamespace       *string
+	Finalizers         models.StringList `gorm:"type:jsonb"`
+	CreatedAt          time.Time         `gorm:"autoCreateTime"`
+	UpdatedAt          time.Time         `gorm:"autoUpdateTime"`
+}
+
+// TableName specifies the table name for claimedResource
+func (claimedResource) TableName() string {
+	return "resources"
+}
+
+// parseClaimKinds parses CLAIM_RESOURCES entries of the form
+// <plural>.<group>/<version>, optionally followed by =<engine>
+func parseClaimKinds(entries []string) ([]claimKind, error) {
+	var kinds []claimKind
+	for _, entry := range entries {
+		resource, engine, _ := strings.Cut(strings.TrimSpace(entry), "=")
+		groupResource, version, ok := strings.Cut(resource, "/")
+		plural, group, ok2 := strings.Cut(groupResource, ".")
+		if !ok || !ok2 || plural == "" || group == "" || version == "" {
+			return nil, fmt.Errorf("invalid claim resource %q, expected <plural>.<group>/<version>[=<engine>]", entry)
+		}
+		kinds = append(kinds, claimKind{
+			gvr:    schema.GroupVersionResource{Group: group, Version: version, Resource: plural},
+			engine: engine,
+		})
+	}
+	return kinds, nil
+}
+
+// claimLoop fulfils the claims of the configured kinds on each interval
+func (c *Controller) claimLoop(ctx context.Context) {
+	defer c.wg.Done()
+
+	kinds, err := parseClaimKinds(c.config.ClaimResources)
+	if err != nil {
+		c.log.WithError(err).Error("Claims disabled")
+		return
+	}
+
+	ticker := time.NewTicker(c.config.ClaimSyncInterval)
+	defer ticker.Stop()
+
+	c.log.WithField("interval", c.config.ClaimSyncInterval).Info("Starting claim sync")
+	if c.current().EnableClaims {
+		c.syncClaims(ctx, kinds)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-c.stopChan:
+			return
+		case <-ticker.C:
+			if c.current().EnableClaims {
+				c.syncClaims(ctx, kinds)
+			}
+		}
+	}
+}
+
+// syncClaims fulfils the claims of each kind whose CRD is installed
+func (c *Controller) syncClaims(ctx context.Context, kinds []claimKind) {
+	for _, kind := range kinds {
+		if !c.servesResource(kind.gvr) {
+			continue
+		}
+		log := c.log.WithFields(logrus.Fields{"action": "claims", "claim_resource": kind.gvr.String()})
+
+		list, err := c.reconciler.dynamicClient.Resource(kind.gvr).Namespace(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			log.WithError(err).Warn("Failed to list claims")
+			continue
+		}
+		for i := range list.Items {
+			claim := &list.Items[i]
+			if err := c.syncClaim(ctx, kind, claim); err != nil {
+				log.WithError(err).WithField("claim", claim.GetNamespace()+"/"+claim.GetName()).Warn("Failed to sync claim")
+			}
+		}
+	}
+}
+
+// syncClaim creates the resource of a new claim, deletes it with the claim,
+// and reports the resource's state in the claim's status
+func (c *Controller) syncClaim(ctx context.Context, kind claimKind, claim *unstructured.Unstructured) error {
+	client := c.reconciler.dynamicClient.Resource(kind.gvr).Namespace(claim.GetNamespace())
+
+	var link *models.ResourceClaim
+	var existing models.ResourceClaim
+	err := c.db.Where("uid = ? AND deleted_at IS NULL", string(claim.GetUID())).First(&existing).Error
+	if err == nil {
+		link = &existing
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("failed to load claim link: %w", err)
+	}
+
+	if claim.GetDeletionTimestamp() != nil {
+		return c.releaseClaim(ctx, client, claim, link)
+	}
+
+	if !containsString(claim.GetFinalizers(), claimFinalizer) {
+		claim.SetFinalizers(append(claim.GetFinalizers(), claimFinalizer))
+		updated, err := client.Update(ctx, claim, metav1.UpdateOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to add finalizer: %w", err)
+		}
+		claim = updated
+	}
+
+	if link == nil {
+		link, err = c.createClaimedResource(ctx, kind, claim)
+		if err != nil {
+			return c.setClaimStatus(ctx, client, claim, nil, nil, err)
+		}
+		c.log.WithFields(logrus.Fields{
+			"claim":       claim.GetNamespace() + "/" + claim.GetName(),
+			"resource_id": link.ResourceID,
+		}).Info("Created resource for claim")
+	}
+
+	var resource models.Resource
+	if err := c.db.First(&resource, link.ResourceID).Error; err != nil {
+		return c.setClaimStatus(ctx, client, claim, link, nil, fmt.Errorf("failed to load resource: %w", err))
+	}
+
+	binding, err := c.ensureClaimBinding(claim, link)
+	if err != nil {
+		return c.setClaimStatus(ctx, client, claim, link, &resource, err)
+	}
+	return c.setClaimStatus(ctx, client, claim, link, &resource, nil, binding)
+}
+
+// createClaimedResource creates a full lifecycle resource for a claim in its
+// team's namespace, with generated credentials, and links it to the claim
+func (c *Controller) createClaimedResource(ctx context.Context, kind claimKind, claim *unstructured.Unstructured) (*models.ResourceClaim, error) {
+	teamID, err := c.claimTeam(ctx, claim.GetNamespace())
+	if err != nil {
+		return nil, err
+	}
+
+	params, _, _ := unstructured.NestedMap(claim.Object, "spec", "parameters")
+	engine := kind.engine
+	if engine == "" {
+		engine, _ = params["engine"].(string)
+	}
+	if engine == "" {
+		return nil, fmt.Errorf("spec.parameters.engine is required")
+	}
+	var resourceType models.ResourceType
+	if err := c.db.Where("name = ?", engine).First(&resourceType).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("unknown engine %s", engine)
+		}
+		return nil, fmt.Errorf("failed to load resource type: %w", err)
+	}
+	if _, _, err := engineImage(resourceType.Name); err != nil {
+		return nil, err
+	}
+
+	environment, _ := params["environment"].(string)
+	cfg := models.JSONMap{}
+	if replicas, ok := params["replicas"].(int64); ok {
+		if replicas < 1 || replicas > 100 {
+			return nil, fmt.Errorf("spec.parameters.replicas must be between 1 and 100")
+		}
+		cfg["replicas"] = float64(replicas)
+	}
+
+	password, err := c.reconciler.generatePassword(ctx)
+	if err != nil {
+		return nil, err
+	}
+	namespace := c.config.NamespacePrefix + strconv.FormatUint(uint64(teamID), 10)
+	resource := &claimedResource{
+		Name:               claim.GetName(),
+		ResourceTypeID:     resourceType.ID,
+		TeamID:             teamID,
+		Environment:        environment,
+		Status:             "pending",
+		LifecycleMode:      "full",
+		ProvisioningMethod: "kubernetes",
+		Credentials:        models.JSONMap{"username": dockerEngineDefaults[resourceType.Name].username, "password": password},
+		Config:             cfg,
+		K8sNamespace:       &namespace,
+		Finalizers:         models.StringList{controllerFinalizer},
+	}
+	link := &models.ResourceClaim{
+		TeamID:     teamID,
+		APIVersion: claim.GetAPIVersion(),
+		Kind:       claim.GetKind(),
+		Namespace:  claim.GetNamespace(),
+		Name:       claim.GetName(),
+		UID:        string(claim.GetUID()),
+	}
+
+	// The name is locked the way the API locks it, so a claim and an API
+	// request for the same name can't both create a resource
+	err = c.db.Transaction(func(tx *gorm.DB) error {
+		if err := locks.Lock(tx, locks.Key("resource-name", teamID, resource.environment(), resource.Name)); err != nil {
+			return err
+		}
+		var existing int64
+		if err := tx.Model(&models.Resource{}).
+			Where("team_id = ? AND environment = ? AND name = ? AND deleted_at IS NULL", teamID, resource.environment(), resource.Name).
+			Count(&existing).Error; err != nil {
+			return err
+		}
+		if existing > 0 {
+			return fmt.Errorf("a resource named %s already exists in the team's %s environment", resource.Name, resource.environment())
+		}
+		if err := tx.Create(resource).Error; err != nil {
+			return err
+		}
+		link.ResourceID = resource.ID
+		return tx.Create(link).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	c.reconciler.createAuditLog("resource.claimed", "resources", resource.ID, teamID, map[string]interface{}{
+		"claim": claim.GetNamespace() + "/" + claim.GetName(),
+		"kind":  claim.GetKind(),
+	})
+	return link, nil
+}
+
+// environment returns the environment the row is created in
+func (r *claimedResource) environment() string {
+	if r.Environment == "" {
+		return "dev"
+	}
+	return r.Environment
+}
+
+// claimTeam returns the team a namespace's claims create resources for:
+// the namespace's team-id label, or the team of a team namespace
+func (c *Controller) claimTeam(ctx context.Context, namespace string) (uint, error) {
+	ns, err := c.clientset.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{})
+	if err != nil {
+		return 0, fmt.Errorf("failed to get namespace: %w", err)
+	}
+	value, ok := ns.Labels[teamIDLabel]
+	if !ok && c.config.NamespacePrefix != "" && strings.HasPrefix(namespace, c.config.NamespacePrefix) {
+		value, ok = strings.TrimPrefix(namespace, c.config.NamespacePrefix), true
+	}
+	if !ok {
+		return 0, fmt.Errorf("namespace %s is not mapped to a team; label it %s", namespace, teamIDLabel)
+	}
+	teamID, err := strconv.ParseUint(value, 10, 64)
+	if err != nil || teamID == 0 {
+		return 0, fmt.Errorf("namespace %s has an invalid team ID %q", namespace, value)
+	}
+	return uint(teamID), nil
+}
+
+// ensureClaimBinding keeps a consumer binding for the claim's
+// writeConnectionSecretToRef, so the connection Secret is written and
+// rotated like any other binding's
+func (c *Controller) ensureClaimBinding(claim *unstructured.Unstructured, link *models.ResourceClaim) (*models.ConsumerBinding, error) {
+	secretName, _, _ := unstructured.NestedString(claim.Object, "spec", "writeConnectionSecretToRef", "name")
+	if secretName == "" {
+		return nil, nil
+	}
+
+	var binding models.ConsumerBinding
+	err := c.db.Transaction(func(tx *gorm.DB) error {
+		if err := locks.Lock(tx, locks.Key("binding-secret", claim.GetNamespace(), secretName)); err != nil {
+			return err
+		}
+		err := tx.Where("namespace = ? AND secret_name = ? AND deleted_at IS NULL", claim.GetNamespace(), secretName).First(&binding).Error
+		if err == nil {
+			if binding.ResourceID != link.ResourceID {
+				return fmt.Errorf("Secret %s is written by another binding", secretName)
+			}
+			return nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("failed to load consumer binding: %w", err)
+		}
+
+		binding = models.ConsumerBinding{
+			ResourceID: link.ResourceID,
+			TeamID:     link.TeamID,
+			Namespace:  claim.GetNamespace(),
+			SecretName: secretName,
+			Status:     "pending",
+		}
+		if err := tx.Create(&binding).Error; err != nil {
+			return fmt.Errorf("failed to create consumer binding: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &binding, nil
+}
+
+// releaseClaim deletes the resource of a deleted claim, with the bindings
+// made for it, and then lets the claim go. A resource with deletion
+// protection holds the claim until the protection is lifted.
+func (c *Controller) releaseClaim(ctx context.Context, client dynamic.ResourceInterface, claim *unstructured.Unstructured, link *models.ResourceClaim) error {
+	if !containsString(claim.GetFinalizers(), claimFinalizer) {
+		return nil
+	}
+
+	if link != nil {
+		var resource models.Resource
+		if err := c.db.First(&resource, link.ResourceID).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("failed to load resource: %w", err)
+		}
+		if resource.DeletionProtection && resource.DeletedAt == nil {
+			return c.setClaimStatus(ctx, client, claim, link, &resource,
+				fmt.Errorf("resource %d has deletion protection enabled; disable it to delete the claim", resource.ID))
+		}
+
+		now := time.Now().UTC()
+		err := c.db.Transaction(func(tx *gorm.DB) error {
+			if resource.ID != 0 && resource.DeletedAt == nil {
+				if err := tx.Model(&models.Resource{}).Where("id = ?", resource.ID).Update("deleted_at", now).Error; err != nil {
+					return err
+				}
+			}
+			secretName, _, _ := unstructured.NestedString(claim.Object, "spec", "writeConnectionSecretToRef", "name")
+			if err := tx.Model(&models.ConsumerBinding{}).
+				Where("resource_id = ? AND namespace = ? AND secret_name = ? AND deleted_at IS NULL", link.ResourceID, claim.GetNamespace(), secretName).
+				Update("deleted_at", now).Error; err != nil {
+				return err
+			}
+			return tx.Model(link).Update("deleted_at", now).Error
+		})
+		if err != nil {
+			return fmt.Errorf("failed to delete claimed resource: %w", err)
+		}
+		c.reconciler.createAuditLog("resource.claim_deleted", "resources", link.ResourceID, link.TeamID, map[string]interface{}{
+			"claim": claim.GetNamespace() + "/" + claim.GetName(),
+		})
+	}
+
+	var finalizers []string
+	for _, f := range claim.GetFinalizers() {
+		if f != claimFinalizer {
+			finalizers = append(finalizers, f)
+		}
+	}
+	claim.SetFinalizers(finalizers)
+	if _, err := client.Update(ctx, claim, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("failed to remove finalizer: %w", err)
+	}
+	return nil
+}
+
+// setClaimStatus reports a claim's sync and the readiness of its resource
+// with Crossplane's Synced and Ready conditions, and the resource and
+// connection Secret in status. It returns syncErr, so callers can report and
+// return a failure at once.
+func (c *Controller) setClaimStatus(ctx context.Context, client dynamic.ResourceInterface, claim *unstructured.Unstructured,
+	link *models.ResourceClaim, resource *models.Resource, syncErr error, binding ...*models.ConsumerBinding) error {
+	synced := claimCondition("Synced", "True", "ReconcileSuccess", "")
+	if syncErr != nil {
+		synced = claimCondition("Synced", "False", "ReconcileError", syncErr.Error())
+	}
+
+	ready := claimCondition("Ready", "False", "Creating", "")
+	switch {
+	case resource == nil:
+	case resource.DeletedAt != nil:
+		ready = claimCondition("Ready", "False", "Deleting", "The resource has been deleted")
+	case resource.Status == "active":
+		ready = claimCondition("Ready", "True", "Available", "")
+	case resource.Status == "error":
+		ready = claimCondition("Ready", "False", "Unavailable", resource.LastError)
+	default:
+		ready = claimCondition("Ready", "False", "Creating", "Resource is "+resource.Status)
+	}
+
+	status := map[string]interface{}{}
+	if link != nil {
+		status["resourceId"] = int64(link.ResourceID)
+	}
+	if len(binding) > 0 && binding[0] != nil && binding[0].LastSyncedAt != nil {
+		status["connectionDetails"] = map[string]interface{}{
+			"lastPublishedTime": binding[0].LastSyncedAt.UTC().Format(time.RFC3339),
+		}
+	}
+
+	// Conditions keep their transition time until their status changes
+	current, _, _ := unstructured.NestedMap(claim.Object, "status")
+	previous := map[string]map[string]interface{}{}
+	if conditions, ok := current["conditions"].([]interface{}); ok {
+		for _, cond := range conditions {
+			if m, ok := cond.(map[string]interface{}); ok {
+				if t, ok := m["type"].(string); ok {
+					previous[t] = m
+				}
+			}
+		}
+	}
+	unchanged := true
+	for _, cond := range []map[string]interface{}{synced, ready} {
+		prev := previous[cond["type"].(string)]
+		if prev != nil && prev["status"] == cond["status"] && prev["lastTransitionTime"] != nil {
+			cond["lastTransitionTime"] = prev["lastTransitionTime"]
+		}
+		if prev == nil || prev["status"] != cond["status"] || prev["reason"] != cond["reason"] || prev["message"] != cond["message"] {
+			unchanged = false
+		}
+	}
+	status["conditions"] = []interface{}{synced, ready}
+
+	if unchanged && fmt.Sprint(current["resourceId"]) == fmt.Sprint(status["resourceId"]) &&
+		fmt.Sprint(current["connectionDetails"]) == fmt.Sprint(status["connectionDetails"]) {
+		return syncErr
+	}
+
+	claim.Object["status"] = status
+	if _, err := client.UpdateStatus(ctx, claim, metav1.UpdateOptions{}); err != nil {
+		if syncErr != nil {
+			return syncErr
+		}
+		return fmt.Errorf("failed to update claim status: %w", err)
+	}
+	return syncErr
+}
+
+// claimCondition builds a claim status condition
+func claimCondition(conditionType, status, reason, message string) map[string]interface{} {
+	return map[string]interface{}{
+		"type":               conditionType,
+		"status":             status,
+		"reason":             reason,
+		"message":            message,
+		"lastTransitionTime": time.Now().UTC().Format(time.RFC3339),
+	}
+}
+
+// containsString reports whether a list holds a string
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
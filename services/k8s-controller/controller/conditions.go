@@ -0,0 +1,195 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/penguintechinc/nest/services/k8s-controller/pkg/models"
+)
+
+// Condition types maintained on resources
+const (
+	ConditionReady         = "Ready"
+	ConditionProgressing   = "Progressing"
+	ConditionBackupHealthy = "BackupHealthy"
+	ConditionCertValid     = "CertValid"
+)
+
+// Condition statuses
+const (
+	conditionTrue    = "True"
+	conditionFalse   = "False"
+	conditionUnknown = "Unknown"
+)
+
+const (
+	// backupHealthyWindow is how recent a resource's last completed backup
+	// must be: a day, with room for a daily backup's run time
+	backupHealthyWindow = 26 * time.Hour
+	// certExpiringWindow is how soon to expiry a certificate is reported
+	// as expiring, matching the API's admin overview
+	certExpiringWindow = 30 * 24 * time.Hour
+)
+
+// updateConditions recomputes the resource's conditions after a reconcile
+// and writes them when they changed
+func (c *Controller) updateConditions(ctx context.Context, resource *models.Resource, reconcileErr error) {
+	log := c.log.WithField("resource_id", resource.ID)
+
+	// The reconcile may have moved the resource to a new status
+	var status string
+	if err := c.db.WithContext(ctx).Model(&models.Resource{}).Where("id = ?", resource.ID).
+		Pluck("status", &status).Error; err != nil {
+		log.WithError(err).Warn("Failed to load resource status for conditions")
+		return
+	}
+
+	now := time.Now().UTC()
+	conditions := append(models.Conditions(nil), resource.Conditions...)
+	changed := conditions.Set(readyCondition(status, reconcileErr), now)
+	changed = conditions.Set(progressingCondition(status), now) || changed
+
+	if resource.CanBackup {
+		condition, err := c.backupCondition(ctx, resource.ID, now)
+		if err != nil {
+			log.WithError(err).Warn("Failed to check backups for conditions")
+		} else {
+			changed = conditions.Set(condition, now) || changed
+		}
+	} else {
+		changed = conditions.Remove(ConditionBackupHealthy) || changed
+	}
+
+	if resource.TLSEnabled {
+		condition, err := c.certCondition(ctx, resource.ID, now)
+		if err != nil {
+			log.WithError(err).Warn("Failed to check certificate for conditions")
+		} else {
+			changed = conditions.Set(condition, now) || changed
+		}
+	} else {
+		changed = conditions.Remove(ConditionCertValid) || changed
+	}
+
+	if !changed {
+		return
+	}
+	if err := c.db.WithContext(ctx).Model(&models.Resource{}).Where("id = ?", resource.ID).
+		UpdateColumn("conditions", conditions).Error; err != nil {
+		log.WithError(err).Warn("Failed to update resource conditions")
+		return
+	}
+	resource.Conditions = conditions
+}
+
+// readyCondition reports whether the resource is serving as specified
+func readyCondition(status string, reconcileErr error) models.Condition {
+	condition := models.Condition{Type: ConditionReady, Status: conditionFalse}
+	switch {
+	case reconcileErr != nil:
+		condition.Reason = "ReconcileFailed"
+		condition.Message = reconcileErr.Error()
+	case status == "active":
+		condition.Status = conditionTrue
+		condition.Reason = "Reconciled"
+	default:
+		condition.Reason = statusReason(status)
+		condition.Message = fmt.Sprintf("Resource is %s", status)
+	}
+	return condition
+}
+
+// progressingCondition reports whether the resource is being provisioned
+// or changed
+func progressingCondition(status string) models.Condition {
+	switch status {
+	case "pending", "provisioning", "updating":
+		return models.Condition{Type: ConditionProgressing, Status: conditionTrue, Reason: statusReason(status)}
+	}
+	return models.Condition{Type: ConditionProgressing, Status: conditionFalse, Reason: "Settled"}
+}
+
+// backupCondition reports whether the resource's latest backup succeeded
+// within backupHealthyWindow
+func (c *Controller) backupCondition(ctx context.Context, resourceID uint, now time.Time) (models.Condition, error) {
+	condition := models.Condition{Type: ConditionBackupHealthy, Status: conditionUnknown, Reason: "NoBackups"}
+	db := c.db.WithContext(ctx)
+	if !db.Migrator().HasTable("backup_jobs") {
+		return condition, nil
+	}
+
+	var jobs []struct {
+		Status       string
+		CompletedAt  *time.Time
+		ErrorMessage string
+	}
+	if err := db.Raw(`SELECT status, completed_at, error_message FROM backup_jobs
+		WHERE resource_id = ? AND status IN ('completed', 'failed')
+		ORDER BY created_at DESC LIMIT 1`, resourceID).Scan(&jobs).Error; err != nil {
+		return condition, err
+	}
+	if len(jobs) == 0 {
+		return condition, nil
+	}
+
+	job := jobs[0]
+	switch {
+	case job.Status == "failed":
+		condition.Status = conditionFalse
+		condition.Reason = "BackupFailed"
+		condition.Message = job.ErrorMessage
+	case job.CompletedAt == nil || now.Sub(*job.CompletedAt) > backupHealthyWindow:
+		condition.Status = conditionFalse
+		condition.Reason = "BackupStale"
+		condition.Message = fmt.Sprintf("No backup has completed in the last %s", backupHealthyWindow)
+	default:
+		condition.Status = conditionTrue
+		condition.Reason = "BackupSucceeded"
+	}
+	return condition, nil
+}
+
+// certCondition reports whether the resource's TLS certificate is valid
+func (c *Controller) certCondition(ctx context.Context, resourceID uint, now time.Time) (models.Condition, error) {
+	condition := models.Condition{Type: ConditionCertValid, Status: conditionFalse, Reason: "NoCertificate"}
+	db := c.db.WithContext(ctx)
+	if !db.Migrator().HasTable("certificates") {
+		return condition, nil
+	}
+
+	var validUntil []time.Time
+	if err := db.Raw(`SELECT valid_until FROM certificates
+		WHERE resource_id = ? AND deleted_at IS NULL
+		ORDER BY valid_until DESC LIMIT 1`, resourceID).Scan(&validUntil).Error; err != nil {
+		return condition, err
+	}
+	if len(validUntil) == 0 {
+		return condition, nil
+	}
+
+	expiry := validUntil[0]
+	switch {
+	case !expiry.After(now):
+		condition.Reason = "Expired"
+		condition.Message = fmt.Sprintf("Certificate expired at %s", expiry.UTC().Format(time.RFC3339))
+	case expiry.Sub(now) <= certExpiringWindow:
+		condition.Status = conditionTrue
+		condition.Reason = "ExpiringSoon"
+		condition.Message = fmt.Sprintf("Certificate expires at %s", expiry.UTC().Format(time.RFC3339))
+	default:
+		condition.Status = conditionTrue
+		condition.Reason = "Valid"
+	}
+	return condition, nil
+}
+
+// statusReason turns a resource status into a condition reason, such as
+// Provisioning
+func statusReason(status string) string {
+	if status == "" {
+		return "Unknown"
+	}
+	return strings.ToUpper(status[:1]) + status[1:]
+}
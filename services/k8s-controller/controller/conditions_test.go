@@ -0,0 +1,32 @@
+package controller
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/penguintechinc/nest/services/k8s-controller/pkg/models"
+)
+
+func TestConditionsKeepTransitionTimeUntilStatusChanges(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	var conditions models.Conditions
+
+	if !conditions.Set(readyCondition("provisioning", nil), start) {
+		t.Fatal("Expected a new condition to be a change")
+	}
+	if conditions.Set(readyCondition("provisioning", nil), start.Add(time.Minute)) {
+		t.Error("Expected an unchanged condition not to be a change")
+	}
+	if !conditions.Set(readyCondition("provisioning", errors.New("boom")), start.Add(2*time.Minute)) {
+		t.Error("Expected a new reason to be a change")
+	}
+	if got := conditions[0].LastTransitionTime; !got.Equal(start) {
+		t.Errorf("Expected the transition time to stay while the status is False, got %v", got)
+	}
+
+	conditions.Set(readyCondition("active", nil), start.Add(3*time.Minute))
+	if conditions[0].Status != conditionTrue || !conditions[0].LastTransitionTime.Equal(start.Add(3*time.Minute)) {
+		t.Errorf("Expected Ready to turn True now, got %+v", conditions[0])
+	}
+}
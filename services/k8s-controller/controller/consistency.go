@@ -0,0 +1,254 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/penguintechinc/nest/services/k8s-controller/pkg/models"
+	"github.com/sirupsen/logrus"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Checks of the consistency audit
+const (
+	consistencyCheckExistence = "existence"
+	consistencyCheckReplicas  = "replicas"
+	consistencyCheckImage     = "image"
+	consistencyCheckLabels    = "labels"
+	consistencyCheckSecrets   = "secrets"
+)
+
+// consistencyAuditedStatuses are the statuses of resources expected to have
+// their StatefulSet in the cluster
+var consistencyAuditedStatuses = []string{"active", "updating", "paused"}
+
+// consistencyReportsKept is how many reports of each cluster are kept
+const consistencyReportsKept = 30
+
+// consistencyLoop audits the resources' records against the cluster on each
+// interval, daily by default
+func (c *Controller) consistencyLoop(ctx context.Context) {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(c.config.ConsistencyAuditInterval)
+	defer ticker.Stop()
+
+	c.log.WithField("interval", c.config.ConsistencyAuditInterval).Info("Starting consistency audit")
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-c.stopChan:
+			return
+		case <-ticker.C:
+			if c.current().EnableConsistencyAudit {
+				c.auditConsistency(ctx)
+			}
+		}
+	}
+}
+
+// auditConsistency compares every provisioned full lifecycle resource with
+// its StatefulSet, and records the discrepancies found as a report
+func (c *Controller) auditConsistency(ctx context.Context) {
+	log := c.log.WithField("action", "consistency_audit")
+
+	report := models.ConsistencyReport{
+		Cluster:       c.config.ClusterName,
+		InstanceID:    c.config.InstanceID,
+		StartedAt:     time.Now().UTC(),
+		Discrepancies: models.ConsistencyDiscrepancies{},
+	}
+
+	var resources []models.Resource
+	if err := c.db.WithContext(ctx).
+		Where("lifecycle_mode = ? AND deleted_at IS NULL AND docker_host_id IS NULL AND status IN ?", "full", consistencyAuditedStatuses).
+		Order("id").Find(&resources).Error; err != nil {
+		log.WithError(err).Error("Failed to query resources")
+		return
+	}
+
+	for i := range resources {
+		found, err := c.reconciler.checkConsistency(ctx, &resources[i])
+		if err != nil {
+			log.WithError(err).WithField("resource_id", resources[i].ID).Warn("Failed to check resource consistency")
+			report.ResourcesFailed++
+			continue
+		}
+		report.ResourcesChecked++
+		report.Discrepancies = append(report.Discrepancies, found...)
+	}
+	report.DiscrepancyCount = len(report.Discrepancies)
+	report.CompletedAt = time.Now().UTC()
+
+	if err := c.db.WithContext(ctx).Create(&report).Error; err != nil {
+		log.WithError(err).Error("Failed to record consistency report")
+		return
+	}
+
+	// Drop the cluster's reports older than the last consistencyReportsKept
+	var stale []uint
+	if err := c.db.WithContext(ctx).Model(&models.ConsistencyReport{}).Where("cluster = ?", report.Cluster).
+		Order("id DESC").Offset(consistencyReportsKept).Pluck("id", &stale).Error; err != nil {
+		log.WithError(err).Warn("Failed to list old consistency reports")
+	} else if len(stale) > 0 {
+		if err := c.db.WithContext(ctx).Delete(&models.ConsistencyReport{}, stale).Error; err != nil {
+			log.WithError(err).Warn("Failed to remove old consistency reports")
+		}
+	}
+
+	log.WithFields(logrus.Fields{
+		"checked":       report.ResourcesChecked,
+		"failed":        report.ResourcesFailed,
+		"discrepancies": report.DiscrepancyCount,
+	}).Info("Consistency audit complete")
+}
+
+// checkConsistency compares a resource's record with its StatefulSet and
+// the Secrets its pods read. The audit only reads the cluster: nothing is
+// corrected, which is left to a reconcile or an operator.
+func (r *Reconciler) checkConsistency(ctx context.Context, resource *models.Resource) ([]models.ConsistencyDiscrepancy, error) {
+	discrepancy := func(check, expected, actual, message string) models.ConsistencyDiscrepancy {
+		return models.ConsistencyDiscrepancy{
+			ResourceID: resource.ID,
+			TeamID:     resource.TeamID,
+			Name:       resource.Name,
+			Check:      check,
+			Expected:   expected,
+			Actual:     actual,
+			Message:    message,
+		}
+	}
+
+	if resource.K8sNamespace == nil || resource.K8sResourceName == nil {
+		return []models.ConsistencyDiscrepancy{discrepancy(consistencyCheckExistence, "StatefulSet", "",
+			fmt.Sprintf("Resource is %s but has no StatefulSet recorded", resource.Status))}, nil
+	}
+	ref := *resource.K8sNamespace + "/" + *resource.K8sResourceName
+
+	exists, actual, err := r.getK8sState(ctx, resource)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get k8s state: %w", err)
+	}
+	if !exists {
+		return []models.ConsistencyDiscrepancy{discrepancy(consistencyCheckExistence, ref, "",
+			fmt.Sprintf("StatefulSet %s not found", ref))}, nil
+	}
+
+	var resourceType models.ResourceType
+	if err := r.db.WithContext(ctx).First(&resourceType, resource.ResourceTypeID).Error; err != nil {
+		return nil, fmt.Errorf("failed to get resource type: %w", err)
+	}
+	desired, err := r.buildStatefulSet(ctx, resource, resourceType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build desired state: %w", err)
+	}
+
+	// Mirror the images as applyRegistry does, without writing the pull
+	// secret
+	registry, err := r.resolveRegistry(ctx, resource)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve image registry: %w", err)
+	}
+	secrets, _ := referencedObjects(resource, desired)
+	if registry != nil {
+		spec := &desired.Spec.Template.Spec
+		for i := range spec.InitContainers {
+			spec.InitContainers[i].Image = mirrorImage(spec.InitContainers[i].Image, registry.MirrorPrefix)
+		}
+		for i := range spec.Containers {
+			spec.Containers[i].Image = mirrorImage(spec.Containers[i].Image, registry.MirrorPrefix)
+		}
+		if registry.Username != "" {
+			secrets = append(secrets, pullSecretName)
+		}
+	}
+
+	var found []models.ConsistencyDiscrepancy
+	for _, d := range statefulSetDiscrepancies(desired, actual) {
+		found = append(found, discrepancy(d.Check, d.Expected, d.Actual, d.Message))
+	}
+
+	for _, name := range secrets {
+		_, err := r.clientset.CoreV1().Secrets(*resource.K8sNamespace).Get(ctx, name, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			found = append(found, discrepancy(consistencyCheckSecrets, name, "",
+				fmt.Sprintf("Secret %s/%s not found", *resource.K8sNamespace, name)))
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to get secret %s: %w", name, err)
+		}
+	}
+
+	return found, nil
+}
+
+// statefulSetDiscrepancies compares the replicas, container images and
+// labels of a StatefulSet with the ones the controller would generate
+func statefulSetDiscrepancies(desired, actual *appsv1.StatefulSet) []models.ConsistencyDiscrepancy {
+	var found []models.ConsistencyDiscrepancy
+
+	if desired.Spec.Replicas != nil {
+		var replicas int32 = 1
+		if actual.Spec.Replicas != nil {
+			replicas = *actual.Spec.Replicas
+		}
+		if replicas != *desired.Spec.Replicas {
+			found = append(found, models.ConsistencyDiscrepancy{
+				Check:    consistencyCheckReplicas,
+				Expected: fmt.Sprintf("%d", *desired.Spec.Replicas),
+				Actual:   fmt.Sprintf("%d", replicas),
+				Message:  "StatefulSet replicas differ from the resource's",
+			})
+		}
+	}
+
+	images := map[string]string{}
+	for _, c := range actual.Spec.Template.Spec.Containers {
+		images[c.Name] = c.Image
+	}
+	for _, c := range desired.Spec.Template.Spec.Containers {
+		image, ok := images[c.Name]
+		switch {
+		case !ok:
+			found = append(found, models.ConsistencyDiscrepancy{
+				Check:    consistencyCheckImage,
+				Expected: c.Image,
+				Message:  fmt.Sprintf("Container %s is missing", c.Name),
+			})
+		case image != c.Image:
+			found = append(found, models.ConsistencyDiscrepancy{
+				Check:    consistencyCheckImage,
+				Expected: c.Image,
+				Actual:   image,
+				Message:  fmt.Sprintf("Container %s runs another image", c.Name),
+			})
+		}
+	}
+
+	keys := make([]string, 0, len(desired.Labels))
+	for k := range desired.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if v, ok := actual.Labels[k]; !ok || v != desired.Labels[k] {
+			d := models.ConsistencyDiscrepancy{
+				Check:    consistencyCheckLabels,
+				Expected: k + "=" + desired.Labels[k],
+				Message:  fmt.Sprintf("Label %s is missing", k),
+			}
+			if ok {
+				d.Actual = k + "=" + v
+				d.Message = fmt.Sprintf("Label %s differs", k)
+			}
+			found = append(found, d)
+		}
+	}
+
+	return found
+}
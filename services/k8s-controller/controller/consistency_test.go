@@ -0,0 +1,43 @@
+package controller
+
+import (
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func testStatefulSet(replicas int32, image string, labels map[string]string) *appsv1.StatefulSet {
+	return &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "nest-team-a", Labels: labels},
+		Spec: appsv1.StatefulSetSpec{
+			Replicas: &replicas,
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "postgresql", Image: image}}},
+			},
+		},
+	}
+}
+
+func TestStatefulSetDiscrepancies(t *testing.T) {
+	labels := map[string]string{"app": "db", "managed-by": "nest-controller", "resource-id": "7"}
+	desired := testStatefulSet(3, "postgres:16-alpine", labels)
+
+	if found := statefulSetDiscrepancies(desired, testStatefulSet(3, "postgres:16-alpine", labels)); len(found) != 0 {
+		t.Fatalf("Expected no discrepancies, got %+v", found)
+	}
+
+	actual := testStatefulSet(1, "postgres:15", map[string]string{"app": "db", "resource-id": "8"})
+	found := statefulSetDiscrepancies(desired, actual)
+	checks := map[string]int{}
+	for _, d := range found {
+		checks[d.Check]++
+	}
+	if checks[consistencyCheckReplicas] != 1 || checks[consistencyCheckImage] != 1 || checks[consistencyCheckLabels] != 2 {
+		t.Errorf("Expected replicas, image and two label discrepancies, got %+v", found)
+	}
+	if found[0].Expected != "3" || found[0].Actual != "1" {
+		t.Errorf("Expected the replicas to be reported, got %+v", found[0])
+	}
+}
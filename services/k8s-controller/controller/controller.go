@@ -4,14 +4,17 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/penguintechinc/nest/services/k8s-controller/pkg/config"
+	"github.com/penguintechinc/nest/services/k8s-controller/pkg/faults"
 	"github.com/penguintechinc/nest/services/k8s-controller/pkg/models"
 	"github.com/sirupsen/logrus"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
@@ -22,14 +25,38 @@ import (
 type Controller struct {
 	config      *config.Config
 	db          *gorm.DB
-	clientset   *kubernetes.Clientset
+	clientset   kubernetes.Interface
 	reconciler  *Reconciler
 	watcher     *Watcher
+	stats       *StatsCollector
 	log         *logrus.Entry
 	stopChan    chan struct{}
 	wg          sync.WaitGroup
 	retryQueue  map[uint]*retryEntry
 	retryMutex  sync.RWMutex
+
+	// faults injects failures for chaos testing; nil unless enabled
+	faults *faults.Injector
+
+	startedAt     time.Time
+	lastReconcile atomic.Int64
+
+	// queue holds the resources waiting for a worker, by priority class
+	queue    *priorityQueue
+	inFlight sync.Map
+
+	// reloaded holds the configuration last applied with Reload, whose
+	// hot-reloadable settings take effect without a restart
+	reloaded       atomic.Pointer[config.Config]
+	reloadInterval chan struct{}
+
+	// logOverride notes whether a log level override set through the API
+	// is applied, to log when it changes
+	logOverride atomic.Bool
+
+	// draining is set once a drain starts, and drained once it finishes
+	draining atomic.Bool
+	drained  atomic.Bool
 }
 
 type retryEntry struct {
@@ -40,31 +67,109 @@ type retryEntry struct {
 
 // NewController creates a new controller instance
 func NewController(cfg *config.Config, db *gorm.DB) (*Controller, error) {
-	// Create Kubernetes clientset
-	clientset, err := createK8sClient(cfg)
+	// Create Kubernetes clients
+	k8sConfig, err := createK8sConfig(cfg)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create k8s client: %w", err)
 	}
+	return newController(cfg, db, k8sConfig)
+}
 
-	reconciler := NewReconciler(db, clientset)
-	watcher := NewWatcher(clientset, cfg.NamespacePrefix)
-
-	return &Controller{
-		config:     cfg,
-		db:         db,
-		clientset:  clientset,
-		reconciler: reconciler,
-		watcher:    watcher,
-		log:        logrus.WithField("component", "controller"),
-		stopChan:   make(chan struct{}),
-		retryQueue: make(map[uint]*retryEntry),
-	}, nil
+// newController creates a controller talking to the API server of
+// k8sConfig, such as an envtest server in integration tests
+func newController(cfg *config.Config, db *gorm.DB, k8sConfig *rest.Config) (*Controller, error) {
+	// Inject Kubernetes API errors and database timeouts for chaos testing
+	var injector *faults.Injector
+	if cfg.FaultInjectionEnabled {
+		injector = faults.New(faults.Config{
+			Seed:              cfg.FaultSeed,
+			K8sErrorRate:      cfg.FaultK8sErrorRate,
+			DBTimeoutRate:     cfg.FaultDBTimeoutRate,
+			SlowReconcileRate: cfg.FaultSlowReconcileRate,
+			MaxReconcileDelay: cfg.FaultMaxReconcileDelay,
+		})
+		if err := injector.RegisterDB(db); err != nil {
+			return nil, err
+		}
+		k8sConfig = rest.CopyConfig(k8sConfig)
+		k8sConfig.Wrap(injector.WrapTransport)
+		logrus.WithFields(logrus.Fields{
+			"seed":                cfg.FaultSeed,
+			"k8s_error_rate":      cfg.FaultK8sErrorRate,
+			"db_timeout_rate":     cfg.FaultDBTimeoutRate,
+			"slow_reconcile_rate": cfg.FaultSlowReconcileRate,
+		}).Warn("Fault injection enabled")
+	}
+
+	clientset, err := kubernetes.NewForConfig(k8sConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create clientset: %w", err)
+	}
+
+	dynamicClient, err := dynamic.NewForConfig(k8sConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dynamic client: %w", err)
+	}
+
+	reconciler := NewReconciler(db, clientset, dynamicClient, cfg)
+	watcher := NewWatcher(clientset, cfg.NamespacePrefix, cfg.WatchDebounce)
+
+	c := &Controller{
+		config:         cfg,
+		db:             db,
+		clientset:      clientset,
+		reconciler:     reconciler,
+		watcher:        watcher,
+		stats:          NewStatsCollector(db, cfg),
+		log:            logrus.WithField("component", "controller"),
+		stopChan:       make(chan struct{}),
+		retryQueue:     make(map[uint]*retryEntry),
+		faults:         injector,
+		startedAt:      time.Now().UTC(),
+		queue:          newPriorityQueue(cfg.PriorityClasses, cfg.TeamMaxInFlight),
+		reloadInterval: make(chan struct{}, 1),
+	}
+	c.reloaded.Store(cfg)
+	return c, nil
+}
+
+// current returns the configuration to read hot-reloadable settings from:
+// the reconcile interval and whether each optional subsystem is enabled
+func (c *Controller) current() *config.Config {
+	return c.reloaded.Load()
+}
+
+// Reload applies the hot-reloadable settings of cfg: the reconcile
+// interval and the subsystems enabled. Other settings take effect on the
+// next restart.
+func (c *Controller) Reload(cfg *config.Config) {
+	previous := c.reloaded.Swap(cfg)
+	if cfg.ReconcileInterval != previous.ReconcileInterval {
+		select {
+		case c.reloadInterval <- struct{}{}:
+		default:
+		}
+	}
+	c.log.WithFields(logrus.Fields{
+		"reconcile_interval": cfg.ReconcileInterval,
+		"discovery":          cfg.EnableDiscovery,
+		"consistency_audit":  cfg.EnableConsistencyAudit,
+		"trust_bundles":      cfg.EnableTrustBundles,
+		"consumer_bindings":  cfg.EnableConsumerBindings,
+		"claims":             cfg.EnableClaims,
+		"stats_collection":   cfg.EnableStatsCollection,
+	}).Info("Configuration reloaded")
 }
 
 // Start begins the controller's reconciliation loop
 func (c *Controller) Start(ctx context.Context) error {
 	c.log.Info("Starting NEST Kubernetes controller")
 
+	// Restore backoff state from before the last restart
+	if err := c.loadRetryQueue(ctx); err != nil {
+		c.log.WithError(err).Warn("Failed to restore retry queue")
+	}
+
 	// Start event watcher
 	if err := c.watcher.Start(ctx); err != nil {
 		return fmt.Errorf("failed to start watcher: %w", err)
@@ -84,6 +189,32 @@ func (c *Controller) Start(ctx context.Context) error {
 	c.wg.Add(1)
 	go c.reconcileLoop(ctx)
 
+	// Start listening for reconcile requests from the API
+	c.wg.Add(1)
+	go c.listenLoop(ctx)
+
+	// Start polling for provisioning jobs to cancel
+	c.wg.Add(1)
+	go c.jobCancelLoop(ctx)
+
+	// Start fleet heartbeat
+	c.wg.Add(1)
+	go c.heartbeatLoop(ctx)
+
+	// Start the optional subsystems. Each loop skips its work while its
+	// subsystem is disabled, so they can be switched on and off by
+	// reloading the configuration.
+	c.wg.Add(6)
+	go c.discoveryLoop(ctx)
+	go c.consistencyLoop(ctx)
+	go c.trustBundleLoop(ctx)
+	go c.bindingLoop(ctx)
+	go c.claimLoop(ctx)
+	go func() {
+		defer c.wg.Done()
+		c.stats.Run(ctx, c.stopChan, func() bool { return c.current().EnableStatsCollection })
+	}()
+
 	c.log.WithField("workers", c.config.WorkerCount).Info("Controller started")
 
 	return nil
@@ -94,6 +225,8 @@ func (c *Controller) Stop() {
 	c.log.Info("Stopping controller")
 	close(c.stopChan)
 	c.wg.Wait()
+	c.reconciler.Stop()
+	c.heartbeat(context.Background(), instanceStatusStopped)
 	c.log.Info("Controller stopped")
 }
 
@@ -101,10 +234,10 @@ func (c *Controller) Stop() {
 func (c *Controller) reconcileLoop(ctx context.Context) {
 	defer c.wg.Done()
 
-	ticker := time.NewTicker(c.config.ReconcileInterval)
+	ticker := time.NewTicker(c.current().ReconcileInterval)
 	defer ticker.Stop()
 
-	c.log.WithField("interval", c.config.ReconcileInterval).Info("Starting reconciliation loop")
+	c.log.WithField("interval", c.current().ReconcileInterval).Info("Starting reconciliation loop")
 
 	for {
 		select {
@@ -112,13 +245,20 @@ func (c *Controller) reconcileLoop(ctx context.Context) {
 			return
 		case <-c.stopChan:
 			return
+		case <-c.reloadInterval:
+			interval := c.current().ReconcileInterval
+			ticker.Reset(interval)
+			c.log.WithField("interval", interval).Info("Reconcile interval changed")
 		case <-ticker.C:
-			c.reconcileAll(ctx)
+			if !c.draining.Load() {
+				c.reconcileAll(ctx)
+			}
 		}
 	}
 }
 
-// reconcileAll reconciles all resources with full lifecycle management
+// reconcileAll queues all resources with full lifecycle management for the
+// workers, by priority class
 func (c *Controller) reconcileAll(ctx context.Context) {
 	log := c.log.WithField("action", "reconcile_all")
 	log.Debug("Starting full reconciliation")
@@ -129,27 +269,36 @@ func (c *Controller) reconcileAll(ctx context.Context) {
 		return
 	}
 
-	log.WithField("count", len(resources)).Info("Reconciling resources")
+	// Deleted resources past their restore window are moved to deleting by
+	// the API and need their Kubernetes objects removed
+	var deleting []models.Resource
+	if err := c.db.Where("lifecycle_mode = ? AND deleted_at IS NOT NULL AND deletion_state = ?", "full", deletionStateDeleting).
+		Find(&deleting).Error; err != nil {
+		log.WithError(err).Error("Failed to query deleting resources")
+	} else {
+		resources = append(resources, deleting...)
+	}
 
+	queued := 0
 	for _, resource := range resources {
 		// Check if resource is in retry queue
 		if c.shouldSkipRetry(resource.ID) {
 			continue
 		}
 
-		if err := c.reconciler.ReconcileResource(ctx, &resource); err != nil {
-			log.WithFields(logrus.Fields{
-				"resource_id": resource.ID,
-				"error":       err,
-			}).Error("Failed to reconcile resource")
-
-			c.addToRetryQueue(resource.ID)
-		} else {
-			c.removeFromRetryQueue(resource.ID)
+		if c.queue.Push(workItem{
+			resourceID: resource.ID,
+			teamID:     resource.TeamID,
+			class:      c.config.PriorityClassOf(resource.PriorityClass, resource.Environment),
+		}) {
+			queued++
 		}
 	}
 
-	log.Debug("Completed full reconciliation")
+	log.WithFields(logrus.Fields{
+		"count":  len(resources),
+		"queued": queued,
+	}).Info("Queued resources for reconciliation")
 }
 
 // eventHandler handles Kubernetes events from the watcher
@@ -169,6 +318,11 @@ func (c *Controller) eventHandler(ctx context.Context) {
 			return
 		case event := <-eventChan:
 			c.handleEvent(ctx, event)
+		case <-c.watcher.Resync():
+			if !c.draining.Load() {
+				log.Info("Resyncing resources after dropped events")
+				c.reconcileAll(ctx)
+			}
 		}
 	}
 }
@@ -214,11 +368,15 @@ func (c *Controller) handleStatefulSetEvent(ctx context.Context, sts *appsv1.Sta
 		status = "updating"
 	}
 
-	connectionInfo := models.JSONMap{
-		"ready_replicas": sts.Status.ReadyReplicas,
-		"replicas":       sts.Status.Replicas,
-		"service_name":   fmt.Sprintf("%s.%s.svc.cluster.local", sts.Name, sts.Namespace),
+	// Keep the keys set elsewhere, such as the host and port of adopted
+	// StatefulSets
+	connectionInfo := models.JSONMap{}
+	for k, v := range resource.ConnectionInfo {
+		connectionInfo[k] = v
 	}
+	connectionInfo["ready_replicas"] = sts.Status.ReadyReplicas
+	connectionInfo["replicas"] = sts.Status.Replicas
+	connectionInfo["service_name"] = fmt.Sprintf("%s.%s.svc.cluster.local", sts.Name, sts.Namespace)
 
 	updates := map[string]interface{}{
 		"status":          status,
@@ -277,17 +435,25 @@ func (c *Controller) reconcileWorker(ctx context.Context, id int) {
 	log.Info("Worker started")
 
 	for {
-		select {
-		case <-ctx.Done():
-			log.Info("Worker stopping")
-			return
-		case <-c.stopChan:
+		item, release, ok := c.queue.Pop(ctx, c.stopChan)
+		if !ok {
 			log.Info("Worker stopping")
 			return
-		case <-time.After(1 * time.Second):
-			// Workers can be extended to process from a work queue
-			// For now, they handle event-driven reconciliation
 		}
+
+		switch {
+		case c.draining.Load():
+			// Hand requests back for other instances; scheduled
+			// reconciles are picked up by the next instance's pass
+			if item.requested {
+				c.requeueRequest(ctx, item.resourceID)
+			}
+		case item.requested:
+			c.reconcileRequested(ctx, item.resourceID, log)
+		case !c.shouldSkipRetry(item.resourceID):
+			c.reconcileScheduled(ctx, item.resourceID, log)
+		}
+		release()
 	}
 }
 
@@ -347,8 +513,8 @@ func (c *Controller) calculateBackoff(retryCount int) time.Duration {
 	return backoff
 }
 
-// createK8sClient creates a Kubernetes client
-func createK8sClient(cfg *config.Config) (*kubernetes.Clientset, error) {
+// createK8sConfig builds the Kubernetes REST config
+func createK8sConfig(cfg *config.Config) (*rest.Config, error) {
 	var k8sConfig *rest.Config
 	var err error
 
@@ -364,10 +530,5 @@ func createK8sClient(cfg *config.Config) (*kubernetes.Clientset, error) {
 		}
 	}
 
-	clientset, err := kubernetes.NewForConfig(k8sConfig)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create clientset: %w", err)
-	}
-
-	return clientset, nil
+	return k8sConfig, nil
 }
@@ -0,0 +1,50 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/penguintechinc/nest/services/k8s-controller/pkg/models"
+	"gorm.io/gorm/clause"
+)
+
+// recordCredentialUsage records the accounts seen connected to a resource,
+// keeping when each was last seen. Accounts are only seen while connected
+// at a collection, so one connecting briefly between collections can go
+// unseen.
+func (s *StatsCollector) recordCredentialUsage(resource *models.Resource, insights *DatabaseInsights) error {
+	if len(insights.UserConnections) == 0 {
+		return nil
+	}
+
+	usages := make([]models.CredentialUsage, 0, len(insights.UserConnections))
+	for username, connections := range insights.UserConnections {
+		usages = append(usages, models.CredentialUsage{
+			ResourceID:  resource.ID,
+			TeamID:      resource.TeamID,
+			Username:    username,
+			LastSeenAt:  insights.CollectedAt,
+			Connections: int(connections),
+		})
+	}
+
+	return s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "resource_id"}, {Name: "username"}},
+		DoUpdates: clause.AssignmentColumns([]string{"last_seen_at", "connections", "updated_at"}),
+	}).Create(&usages).Error
+}
+
+// staleAccounts returns the accounts of a resource in the manager's
+// resource_users table, when it exists, that weren't seen connected for
+// staleAfter. Accounts never seen count from when they were created.
+func (s *StatsCollector) staleAccounts(resourceID uint, now time.Time) ([]string, error) {
+	if s.staleAfter <= 0 || !s.db.Migrator().HasTable("resource_users") {
+		return nil, nil
+	}
+
+	var stale []string
+	err := s.db.Raw(`SELECT ru.username FROM resource_users ru
+		LEFT JOIN credential_usages cu ON cu.resource_id = ru.resource_id AND cu.username = ru.username
+		WHERE ru.resource_id = ? AND ru.deleted_at IS NULL AND COALESCE(cu.last_seen_at, ru.created_at) < ?
+		ORDER BY ru.username`, resourceID, now.Add(-s.staleAfter)).Scan(&stale).Error
+	return stale, err
+}
@@ -0,0 +1,67 @@
+package controller
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/penguintechinc/nest/services/k8s-controller/pkg/models"
+	"gorm.io/gorm"
+)
+
+// generatedPasswordLength is the length of generated passwords unless the
+// password policy asks for longer ones. At this length a random password
+// clears the policy's highest entropy setting.
+const generatedPasswordLength = 48
+
+// passwordClasses are the character classes of generated passwords, without
+// characters that are easily confused or need quoting in DSNs
+var passwordClasses = []string{
+	"abcdefghijkmnopqrstuvwxyz",
+	"ABCDEFGHJKLMNPQRSTUVWXYZ",
+	"23456789",
+	"-_.~",
+}
+
+// generatePassword returns a random password that uses every character
+// class and meets the password policy's minimum length
+func (r *Reconciler) generatePassword(ctx context.Context) (string, error) {
+	length := generatedPasswordLength
+	var policy models.PasswordPolicy
+	err := r.db.WithContext(ctx).Where("deleted_at IS NULL").Order("id ASC").First(&policy).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", fmt.Errorf("failed to load password policy: %w", err)
+	}
+	if policy.MinLength > length {
+		length = policy.MinLength
+	}
+
+	alphabet := strings.Join(passwordClasses, "")
+	for {
+		buf := make([]byte, length)
+		for i := range buf {
+			n, err := rand.Int(rand.Reader, big.NewInt(int64(len(alphabet))))
+			if err != nil {
+				return "", fmt.Errorf("failed to generate password: %w", err)
+			}
+			buf[i] = alphabet[n.Int64()]
+		}
+		password := string(buf)
+		if usesEveryClass(password) {
+			return password, nil
+		}
+	}
+}
+
+// usesEveryClass reports whether a password has a character of each class
+func usesEveryClass(password string) bool {
+	for _, class := range passwordClasses {
+		if !strings.ContainsAny(password, class) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,68 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/penguintechinc/nest/services/k8s-controller/pkg/diagnostics"
+	"github.com/penguintechinc/nest/services/k8s-controller/pkg/loglevel"
+)
+
+// Diagnostics is a snapshot of the controller's runtime, database pool and
+// queues, for troubleshooting a controller that falls behind
+type Diagnostics struct {
+	Runtime  diagnostics.RuntimeStats `json:"runtime"`
+	Database diagnostics.PoolStats    `json:"database"`
+
+	// WorkQueue holds the resources waiting for a worker, by priority class
+	WorkQueue map[string]int `json:"work_queue"`
+	// WatcherEvents holds Kubernetes events waiting for the event handler
+	WatcherEvents diagnostics.QueueDepth `json:"watcher_events"`
+	RetryQueue    int                    `json:"retry_queue"`
+	InFlight      int                    `json:"in_flight"`
+	LastReconcile *time.Time             `json:"last_reconcile,omitempty"`
+	LogLevels     loglevel.State         `json:"log_levels"`
+	// Status is running, or draining or drained during a drain
+	Status string `json:"status"`
+}
+
+// Diagnostics takes a snapshot of the controller
+func (c *Controller) Diagnostics() Diagnostics {
+	d := Diagnostics{
+		Runtime:       diagnostics.Runtime(c.startedAt),
+		WorkQueue:     c.queue.Depths(),
+		WatcherEvents: diagnostics.QueueDepth{Length: len(c.watcher.eventChannel), Capacity: cap(c.watcher.eventChannel)},
+		LogLevels:     loglevel.Standard().State(),
+		Status:        c.instanceStatus(),
+	}
+	if sqlDB, err := c.db.DB(); err == nil {
+		d.Database = diagnostics.Pool(sqlDB)
+	}
+
+	c.retryMutex.RLock()
+	d.RetryQueue = len(c.retryQueue)
+	c.retryMutex.RUnlock()
+
+	d.InFlight = c.inFlightCount()
+	if last := c.lastReconcile.Load(); last > 0 {
+		t := time.Unix(0, last).UTC()
+		d.LastReconcile = &t
+	}
+	return d
+}
+
+// DebugHandler serves the controller's diagnostics on /debug/diagnostics
+// and its pprof profiles under /debug/pprof/. It is not authenticated.
+func (c *Controller) DebugHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/diagnostics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(c.Diagnostics())
+	})
+	mux.HandleFunc("/debug/pprof/", func(w http.ResponseWriter, r *http.Request) {
+		diagnostics.ServeProfile(w, r, strings.TrimPrefix(r.URL.Path, "/debug/pprof/"))
+	})
+	return mux
+}
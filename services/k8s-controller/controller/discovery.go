@@ -0,0 +1,257 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/penguintechinc/nest/services/k8s-controller/pkg/models"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Adoption candidate statuses. The API approves candidates, linking them to
+// a resource; the controller then adopts them.
+const (
+	candidateProposed  = "proposed"
+	candidateApproved  = "approved"
+	candidateAdopted   = "adopted"
+	candidateDismissed = "dismissed"
+)
+
+// adoptableEngine is the resource type and default port of a database image
+type adoptableEngine struct {
+	name string
+	port int32
+}
+
+// adoptableImages maps the repository names of database images, without
+// their registry or tag, to their engines
+var adoptableImages = map[string]adoptableEngine{
+	"postgres":           {"postgresql", 5432},
+	"postgresql":         {"postgresql", 5432},
+	"postgis":            {"postgresql", 5432},
+	"mariadb":            {"mariadb", 3306},
+	"mysql":              {"mysql", 3306},
+	"mysql-server":       {"mysql", 3306},
+	"percona-server":     {"mysql", 3306},
+	"redis":              {"redis", 6379},
+	"redis-stack-server": {"redis", 6379},
+	"valkey":             {"redis", 6379},
+}
+
+// discoveryLoop scans the team namespaces for unmanaged databases on each
+// interval, and adopts the ones approved through the API
+func (c *Controller) discoveryLoop(ctx context.Context) {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(c.config.DiscoveryInterval)
+	defer ticker.Stop()
+
+	c.log.WithField("interval", c.config.DiscoveryInterval).Info("Starting database discovery")
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-c.stopChan:
+			return
+		case <-ticker.C:
+			if c.current().EnableDiscovery {
+				c.discover(ctx)
+			}
+		}
+	}
+}
+
+// discover proposes the unmanaged database StatefulSets of the team
+// namespaces for adoption, drops proposals for StatefulSets that are gone,
+// and adopts approved candidates
+func (c *Controller) discover(ctx context.Context) {
+	log := c.log.WithField("action", "discover")
+
+	namespaces, err := c.watcher.getTeamNamespaces(ctx)
+	if err != nil {
+		log.WithError(err).Error("Failed to list team namespaces")
+		return
+	}
+
+	now := time.Now().UTC()
+	complete := true
+	for _, ns := range namespaces {
+		list, err := c.clientset.AppsV1().StatefulSets(ns).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			log.WithError(err).WithField("namespace", ns).Warn("Failed to list StatefulSets")
+			complete = false
+			continue
+		}
+		for i := range list.Items {
+			sts := &list.Items[i]
+			if sts.Labels["managed-by"] == "nest-controller" {
+				continue
+			}
+			if err := c.proposeCandidate(sts, now); err != nil {
+				log.WithError(err).WithField("statefulset", ns+"/"+sts.Name).Warn("Failed to record adoption candidate")
+				complete = false
+			}
+		}
+	}
+
+	// Only a complete scan shows which StatefulSets are gone
+	if complete {
+		if err := c.db.Where("status IN ? AND last_seen_at < ?", []string{candidateProposed, candidateDismissed}, now).
+			Delete(&models.AdoptionCandidate{}).Error; err != nil {
+			log.WithError(err).Warn("Failed to remove stale adoption candidates")
+		}
+	}
+
+	var approved []models.AdoptionCandidate
+	if err := c.db.Where("status = ? AND resource_id IS NOT NULL", candidateApproved).Find(&approved).Error; err != nil {
+		log.WithError(err).Error("Failed to query approved adoption candidates")
+		return
+	}
+	for i := range approved {
+		candidate := &approved[i]
+		if err := c.adopt(ctx, candidate); err != nil {
+			log.WithError(err).WithField("statefulset", candidate.Namespace+"/"+candidate.Name).Warn("Failed to adopt StatefulSet")
+		}
+	}
+}
+
+// proposeCandidate records a StatefulSet running a database image as an
+// adoption candidate, keeping the status of one already recorded
+func (c *Controller) proposeCandidate(sts *appsv1.StatefulSet, now time.Time) error {
+	var engine adoptableEngine
+	var image string
+	var port int32
+	for _, container := range sts.Spec.Template.Spec.Containers {
+		if e, ok := adoptableImages[imageRepository(container.Image)]; ok {
+			engine, image, port = e, container.Image, e.port
+			if len(container.Ports) > 0 {
+				port = container.Ports[0].ContainerPort
+			}
+			break
+		}
+	}
+	if engine.name == "" {
+		return nil
+	}
+
+	replicas := 1
+	if sts.Spec.Replicas != nil {
+		replicas = int(*sts.Spec.Replicas)
+	}
+	candidate := &models.AdoptionCandidate{
+		Namespace:   sts.Namespace,
+		Name:        sts.Name,
+		Engine:      engine.name,
+		Image:       image,
+		Replicas:    replicas,
+		ServiceName: sts.Spec.ServiceName,
+		Port:        int(port),
+		Status:      candidateProposed,
+		LastSeenAt:  &now,
+	}
+	return c.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "namespace"}, {Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"engine", "image", "replicas", "service_name", "port", "last_seen_at", "updated_at"}),
+	}).Create(candidate).Error
+}
+
+// imageRepository returns the last path element of an image reference,
+// without its tag or digest, such as postgres for docker.io/library/postgres:16
+func imageRepository(image string) string {
+	if i := strings.Index(image, "@"); i >= 0 {
+		image = image[:i]
+	}
+	if i := strings.LastIndex(image, "/"); i >= 0 {
+		image = image[i+1:]
+	}
+	if i := strings.Index(image, ":"); i >= 0 {
+		image = image[:i]
+	}
+	return image
+}
+
+// adopt labels an approved candidate's StatefulSet as managed by NEST and
+// activates its resource. Only the StatefulSet's own labels are patched, not
+// its pod template, so its pods aren't restarted.
+func (c *Controller) adopt(ctx context.Context, candidate *models.AdoptionCandidate) error {
+	log := c.log.WithFields(logrus.Fields{
+		"statefulset": candidate.Namespace + "/" + candidate.Name,
+		"resource_id": *candidate.ResourceID,
+	})
+
+	// A resource deleted before it was adopted returns its StatefulSet to
+	// the proposals
+	var resource models.Resource
+	if err := c.db.Where("id = ? AND deleted_at IS NULL", *candidate.ResourceID).First(&resource).Error; err != nil {
+		if err != gorm.ErrRecordNotFound {
+			return fmt.Errorf("failed to get resource: %w", err)
+		}
+		return c.db.Model(candidate).Updates(map[string]interface{}{
+			"status":      candidateProposed,
+			"resource_id": nil,
+		}).Error
+	}
+
+	sts, err := c.clientset.AppsV1().StatefulSets(candidate.Namespace).Get(ctx, candidate.Name, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			now := time.Now().UTC()
+			return c.db.Model(&models.Resource{}).Where("id = ?", resource.ID).Updates(map[string]interface{}{
+				"status":        "error",
+				"last_error":    "The adopted StatefulSet no longer exists",
+				"last_error_at": &now,
+			}).Error
+		}
+		return fmt.Errorf("failed to get StatefulSet: %w", err)
+	}
+
+	patch, _ := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"labels": map[string]string{
+				"managed-by":  "nest-controller",
+				"resource-id": fmt.Sprintf("%d", resource.ID),
+			},
+		},
+	})
+	if _, err := c.clientset.AppsV1().StatefulSets(candidate.Namespace).Patch(
+		ctx, candidate.Name, types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+		return fmt.Errorf("failed to label StatefulSet: %w", err)
+	}
+
+	status := "active"
+	if sts.Status.ReadyReplicas < sts.Status.Replicas {
+		status = "updating"
+	}
+	connectionInfo := models.JSONMap{}
+	for k, v := range resource.ConnectionInfo {
+		connectionInfo[k] = v
+	}
+	connectionInfo["ready_replicas"] = sts.Status.ReadyReplicas
+	connectionInfo["replicas"] = sts.Status.Replicas
+	if err := c.db.Model(&models.Resource{}).Where("id = ?", resource.ID).Updates(map[string]interface{}{
+		"status":          status,
+		"connection_info": connectionInfo,
+	}).Error; err != nil {
+		return fmt.Errorf("failed to update resource: %w", err)
+	}
+	if err := c.db.Model(candidate).Update("status", candidateAdopted).Error; err != nil {
+		return fmt.Errorf("failed to update adoption candidate: %w", err)
+	}
+
+	log.Info("Adopted StatefulSet")
+	c.reconciler.createAuditLog("resource.adopted", "resources", resource.ID, resource.TeamID, map[string]interface{}{
+		"namespace":   candidate.Namespace,
+		"statefulset": candidate.Name,
+	})
+	return nil
+}
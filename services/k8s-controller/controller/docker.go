@@ -0,0 +1,374 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/penguintechinc/nest/services/k8s-controller/pkg/models"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// dockerSpecLabel holds a hash of the spec a container was created from, so
+// that changes to the resource recreate it
+const dockerSpecLabel = "nest.penguintech.io/spec-hash"
+
+// dockerEngineDefaults are the data directory and default user of each
+// engine's image
+var dockerEngineDefaults = map[string]struct {
+	dataDir  string
+	username string
+}{
+	"postgresql": {"/var/lib/postgresql/data", "postgres"},
+	"mariadb":    {"/var/lib/mysql", "root"},
+	"redis":      {"/data", "default"},
+}
+
+// dockerContainerName returns the name of a resource's container. Names are
+// only unique per team and environment, so the ID is included.
+func dockerContainerName(resource *models.Resource) string {
+	return fmt.Sprintf("nest-%d-%s", resource.ID, resource.Name)
+}
+
+// reconcileDocker reconciles a resource provisioned as a container on a
+// Docker or Podman host. Containers keep their data in a named volume, which
+// outlives them so that recreating a container, or restoring a deleted
+// resource, keeps the data.
+func (r *Reconciler) reconcileDocker(ctx context.Context, resource *models.Resource, log *logrus.Entry) error {
+	var host models.DockerHost
+	if err := r.db.WithContext(ctx).Where("id = ? AND deleted_at IS NULL", *resource.DockerHostID).
+		First(&host).Error; err != nil {
+		return fmt.Errorf("failed to get Docker host: %w", err)
+	}
+	log = log.WithField("docker_host", host.Name)
+
+	client, err := newDockerClient(&host)
+	if err == nil {
+		err = client.ping(ctx)
+	}
+	r.recordDockerContact(&host, err)
+	if err != nil {
+		return fmt.Errorf("failed to reach Docker host %s: %w", host.Name, err)
+	}
+
+	if resource.DeletedAt != nil {
+		return r.reconcileDockerDelete(ctx, client, resource, log)
+	}
+
+	if err := r.ensureFinalizer(ctx, resource); err != nil {
+		return err
+	}
+
+	var resourceType models.ResourceType
+	if err := r.db.First(&resourceType, resource.ResourceTypeID).Error; err != nil {
+		return fmt.Errorf("failed to get resource type: %w", err)
+	}
+
+	spec, registry, err := r.buildDockerContainer(ctx, resource, resourceType)
+	if err != nil {
+		return err
+	}
+
+	name := dockerContainerName(resource)
+	current, err := client.inspectContainer(ctx, name)
+	if err != nil && !errors.Is(err, errDockerNotFound) {
+		return fmt.Errorf("failed to inspect container: %w", err)
+	}
+
+	switch {
+	case current == nil:
+		log.Info("Creating resource container")
+		if err := r.updateResourceStatus(resource.ID, "provisioning", nil); err != nil {
+			return err
+		}
+		current, err = r.runDockerContainer(ctx, client, resource, name, spec, registry, "create", "", log)
+	case current.Config.Labels[dockerSpecLabel] != spec.Labels[dockerSpecLabel]:
+		log.Info("Recreating resource container for a spec change")
+		current, err = r.runDockerContainer(ctx, client, resource, name, spec, registry, "update", current.ID, log)
+	case !current.State.Running:
+		log.WithField("state", current.State.Status).Info("Starting resource container")
+		if err = client.startContainer(ctx, current.ID); err == nil {
+			current, err = client.inspectContainer(ctx, name)
+		}
+	}
+	if err != nil {
+		if updateErr := r.updateResourceStatus(resource.ID, "error", nil); updateErr != nil {
+			log.WithError(updateErr).Error("Failed to update resource status")
+		}
+		return err
+	}
+
+	return r.updateDockerConnectionInfo(ctx, resource, &host, current, resourceType)
+}
+
+// runDockerContainer pulls the image and creates and starts a container,
+// replacing the container replaceID when given, tracked as a provisioning
+// job of jobType
+func (r *Reconciler) runDockerContainer(ctx context.Context, client *dockerClient, resource *models.Resource,
+	name string, spec *dockerContainerSpec, registry *models.ImageRegistry, jobType, replaceID string,
+	log *logrus.Entry) (*dockerContainer, error) {
+	job, ctx, done := r.beginJob(ctx, resource.ID, jobType, log)
+	defer done()
+
+	fail := func(step string, err error) (*dockerContainer, error) {
+		r.failJob(job.ID, fmt.Sprintf("Failed to %s: %v", step, err))
+		return nil, fmt.Errorf("failed to %s: %w", step, err)
+	}
+
+	// Pull before removing the old container, to keep it running when the
+	// image can't be pulled
+	if err := client.pullImage(ctx, spec.Image, registry); err != nil {
+		return fail("pull image", err)
+	}
+	if replaceID != "" {
+		if err := client.removeContainer(ctx, replaceID); err != nil {
+			return fail("remove container", err)
+		}
+	}
+	id, err := client.createContainer(ctx, name, spec)
+	if err != nil {
+		return fail("create container", err)
+	}
+	if err := client.startContainer(ctx, id); err != nil {
+		return fail("start container", err)
+	}
+	container, err := client.inspectContainer(ctx, id)
+	if err != nil {
+		return fail("inspect container", err)
+	}
+
+	log.WithField("container", name).Info("Container started")
+	if jobType == "create" {
+		r.completeJob(job.ID, "Resource created successfully")
+		r.createAuditLog("resource.created", "resources", resource.ID, resource.TeamID, nil)
+	} else {
+		r.completeJob(job.ID, "Container recreated for a spec change")
+		r.createAuditLog("resource.updated", "resources", resource.ID, resource.TeamID, nil)
+	}
+	return container, nil
+}
+
+// buildDockerContainer builds the container spec of a resource, and returns
+// the registry its image is pulled through. Credentials are generated and
+// stored with the resource when it has none, as the engines' images need
+// them to initialize.
+func (r *Reconciler) buildDockerContainer(ctx context.Context, resource *models.Resource,
+	resourceType models.ResourceType) (*dockerContainerSpec, *models.ImageRegistry, error) {
+	image, port, err := engineImage(resourceType.Name)
+	if err != nil {
+		return nil, nil, err
+	}
+	defaults := dockerEngineDefaults[resourceType.Name]
+
+	registry, err := r.resolveRegistry(ctx, resource)
+	if err != nil {
+		return nil, nil, err
+	}
+	if registry != nil {
+		image = mirrorImage(image, registry.MirrorPrefix)
+	}
+
+	username, password, err := r.ensureDockerCredentials(ctx, resource, defaults.username)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	containerPort := fmt.Sprintf("%d/tcp", port)
+	spec := &dockerContainerSpec{
+		Image: image,
+		Labels: map[string]string{
+			"managed-by":  "nest-controller",
+			"resource-id": fmt.Sprintf("%d", resource.ID),
+		},
+		ExposedPorts: map[string]struct{}{containerPort: {}},
+		HostConfig: dockerHostConfig{
+			Binds: []string{dockerContainerName(resource) + "-data:" + defaults.dataDir},
+			// The published port is kept across recreations once assigned
+			PortBindings: map[string][]dockerPortBinding{
+				containerPort: {{HostPort: dockerHostPort(resource)}},
+			},
+			RestartPolicy: dockerRestartPolicy{Name: "unless-stopped"},
+			SecurityOpt:   []string{"no-new-privileges"},
+		},
+	}
+
+	tuning := tuningConfig(resource, resourceType)
+	names := make([]string, 0, len(tuning))
+	for name := range tuning {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	switch resourceType.Name {
+	case "postgresql":
+		spec.Env = []string{"POSTGRES_USER=" + username, "POSTGRES_PASSWORD=" + password}
+		spec.Cmd = []string{"postgres"}
+		for _, name := range names {
+			spec.Cmd = append(spec.Cmd, "-c", name+"="+tuning[name])
+		}
+	case "mariadb":
+		spec.Env = []string{"MARIADB_ROOT_PASSWORD=" + password}
+		if username != "root" {
+			spec.Env = append(spec.Env, "MARIADB_USER="+username, "MARIADB_PASSWORD="+password)
+		}
+		for _, name := range names {
+			spec.Cmd = append(spec.Cmd, "--"+name+"="+tuning[name])
+		}
+	case "redis":
+		spec.Cmd = []string{"redis-server", "--requirepass", password}
+		for _, name := range names {
+			spec.Cmd = append(spec.Cmd, "--"+name, tuning[name])
+		}
+	}
+
+	// Size the container from its size class; storage isn't limited, as
+	// named volumes have no quota
+	requirements, err := configResources(resource)
+	if err != nil {
+		return nil, nil, err
+	}
+	if memory, ok := requirements.Limits[corev1.ResourceMemory]; ok {
+		spec.HostConfig.Memory = memory.Value()
+	}
+	if cpu, ok := requirements.Requests[corev1.ResourceCPU]; ok {
+		spec.HostConfig.NanoCpus = cpu.MilliValue() * 1e6
+	}
+
+	// The hash leaves out the port bindings, which change when the host
+	// assigns the port
+	hashed := *spec
+	hashed.HostConfig.PortBindings = nil
+	hash, err := json.Marshal(&hashed)
+	if err != nil {
+		return nil, nil, err
+	}
+	sum := sha256.Sum256(hash)
+	spec.Labels[dockerSpecLabel] = hex.EncodeToString(sum[:])
+
+	return spec, registry, nil
+}
+
+// ensureDockerCredentials returns a resource's username and password,
+// generating and storing them when missing
+func (r *Reconciler) ensureDockerCredentials(ctx context.Context, resource *models.Resource, defaultUser string) (string, string, error) {
+	username := stringFromMap(resource.Credentials, "username")
+	password := stringFromMap(resource.Credentials, "password")
+	if username != "" && password != "" {
+		return username, password, nil
+	}
+
+	credentials := models.JSONMap{}
+	for k, v := range resource.Credentials {
+		credentials[k] = v
+	}
+	if username == "" {
+		username = defaultUser
+		credentials["username"] = username
+	}
+	if password == "" {
+		var err error
+		if password, err = r.generatePassword(ctx); err != nil {
+			return "", "", err
+		}
+		credentials["password"] = password
+	}
+
+	if err := r.db.WithContext(ctx).Model(&models.Resource{}).Where("id = ?", resource.ID).
+		UpdateColumn("credentials", credentials).Error; err != nil {
+		return "", "", fmt.Errorf("failed to store credentials: %w", err)
+	}
+	resource.Credentials = credentials
+	return username, password, nil
+}
+
+// dockerHostPort returns the host port a resource's container publishes on,
+// taken from its connection info once assigned; empty lets the host choose
+func dockerHostPort(resource *models.Resource) string {
+	if port, ok := resource.ConnectionInfo["port"].(float64); ok && port > 0 {
+		return strconv.Itoa(int(port))
+	}
+	return ""
+}
+
+// updateDockerConnectionInfo records where a container is reachable and
+// marks the resource active while it runs
+func (r *Reconciler) updateDockerConnectionInfo(ctx context.Context, resource *models.Resource,
+	host *models.DockerHost, container *dockerContainer, resourceType models.ResourceType) error {
+	address := host.AdvertiseAddress
+	if address == "" {
+		if endpoint, err := url.Parse(host.Endpoint); err == nil {
+			address = endpoint.Hostname()
+		}
+	}
+
+	connectionInfo := models.JSONMap{}
+	for k, v := range resource.ConnectionInfo {
+		connectionInfo[k] = v
+	}
+	connectionInfo["host"] = address
+	connectionInfo["container"] = dockerContainerName(resource)
+	connectionInfo["docker_host"] = host.Name
+	_, port, _ := engineImage(resourceType.Name)
+	if bindings := container.NetworkSettings.Ports[fmt.Sprintf("%d/tcp", port)]; len(bindings) > 0 {
+		if hostPort, err := strconv.Atoi(bindings[0].HostPort); err == nil {
+			connectionInfo["port"] = hostPort
+		}
+	}
+
+	status := "active"
+	if !container.State.Running {
+		status = "updating"
+	}
+
+	return r.db.WithContext(ctx).Model(&models.Resource{}).Where("id = ?", resource.ID).Updates(map[string]interface{}{
+		"connection_info":   connectionInfo,
+		"status":            status,
+		"k8s_resource_name": dockerContainerName(resource),
+		"k8s_resource_type": "Container",
+	}).Error
+}
+
+// reconcileDockerDelete removes a deleted resource's container, keeping its
+// data volume, and releases the controller finalizer
+func (r *Reconciler) reconcileDockerDelete(ctx context.Context, client *dockerClient, resource *models.Resource,
+	log *logrus.Entry) error {
+	log.Info("Deleting resource container")
+
+	if err := client.removeContainer(ctx, dockerContainerName(resource)); err != nil {
+		return fmt.Errorf("failed to remove container: %w", err)
+	}
+
+	log.Info("Container removed")
+
+	if err := r.updateResourceStatus(resource.ID, "deleted", nil); err != nil {
+		return err
+	}
+	if err := r.releaseFinalizer(ctx, resource); err != nil {
+		return err
+	}
+
+	r.createAuditLog("resource.deleted", "resources", resource.ID, resource.TeamID, nil)
+
+	return nil
+}
+
+// recordDockerContact records whether a Docker host's Engine API was reached
+func (r *Reconciler) recordDockerContact(host *models.DockerHost, contactErr error) {
+	updates := map[string]interface{}{"last_error": ""}
+	if contactErr != nil {
+		updates["last_error"] = r.config.Redactor.String(contactErr.Error())
+	} else {
+		updates["last_contact_at"] = time.Now().UTC()
+	}
+	if err := r.db.Model(&models.DockerHost{}).Where("id = ?", host.ID).Updates(updates).Error; err != nil {
+		r.log.WithError(err).WithField("docker_host", host.Name).Warn("Failed to record Docker host contact")
+	}
+}
@@ -0,0 +1,260 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/penguintechinc/nest/services/k8s-controller/pkg/models"
+)
+
+// dockerAPIVersion is the Engine API version requested. Docker 20.10 and
+// Podman's Docker-compatible API serve it.
+const dockerAPIVersion = "v1.41"
+
+// dockerRequestTimeout bounds Engine API requests other than image pulls
+const dockerRequestTimeout = 30 * time.Second
+
+// errDockerNotFound is returned for containers and images that don't exist
+var errDockerNotFound = errors.New("not found")
+
+// dockerClient calls the Engine API of a Docker or Podman host over mutual
+// TLS
+type dockerClient struct {
+	baseURL string
+	http    *http.Client
+}
+
+// newDockerClient creates a client for a host from its TLS material
+func newDockerClient(host *models.DockerHost) (*dockerClient, error) {
+	endpoint, err := url.Parse(host.Endpoint)
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid Docker endpoint %q", host.Endpoint)
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM([]byte(host.CACert)) {
+		return nil, errors.New("Docker host CA certificate is invalid")
+	}
+	cert, err := tls.X509KeyPair([]byte(host.ClientCert), []byte(host.ClientKey))
+	if err != nil {
+		return nil, fmt.Errorf("Docker host client certificate is invalid: %w", err)
+	}
+
+	transport := &http.Transport{
+		TLSClientConfig: &tls.Config{
+			RootCAs:      roots,
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS12,
+		},
+	}
+	return &dockerClient{
+		baseURL: "https://" + endpoint.Host + "/" + dockerAPIVersion,
+		http:    &http.Client{Transport: transport},
+	}, nil
+}
+
+// dockerContainerSpec is the body of a container create request
+type dockerContainerSpec struct {
+	Image        string
+	Env          []string            `json:",omitempty"`
+	Cmd          []string            `json:",omitempty"`
+	Labels       map[string]string   `json:",omitempty"`
+	ExposedPorts map[string]struct{} `json:",omitempty"`
+	HostConfig   dockerHostConfig
+}
+
+// dockerHostConfig is the host configuration of a container
+type dockerHostConfig struct {
+	Binds         []string                       `json:",omitempty"`
+	PortBindings  map[string][]dockerPortBinding `json:",omitempty"`
+	RestartPolicy dockerRestartPolicy
+	Memory        int64    `json:",omitempty"`
+	NanoCpus      int64    `json:",omitempty"`
+	CapDrop       []string `json:",omitempty"`
+	SecurityOpt   []string `json:",omitempty"`
+}
+
+// dockerRestartPolicy is a container's restart policy
+type dockerRestartPolicy struct {
+	Name string
+}
+
+// dockerPortBinding publishes a container port on the host
+type dockerPortBinding struct {
+	HostIP   string `json:"HostIp,omitempty"`
+	HostPort string
+}
+
+// dockerContainer is the subset of a container inspection the driver uses
+type dockerContainer struct {
+	ID    string `json:"Id"`
+	State struct {
+		Status   string
+		Running  bool
+		ExitCode int
+		Error    string
+	}
+	Config struct {
+		Image  string
+		Labels map[string]string
+	}
+	NetworkSettings struct {
+		Ports map[string][]dockerPortBinding
+	}
+}
+
+// send sends a request to the Engine API and returns the response of a
+// successful one, which the caller must close. Responses of 404 are returned
+// as errDockerNotFound.
+func (d *dockerClient) send(ctx context.Context, method, path string, query url.Values, body interface{},
+	header http.Header) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		buf, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(buf)
+	}
+
+	target := d.baseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := d.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, errDockerNotFound
+	}
+	// 304 is returned for containers already in the requested state
+	if resp.StatusCode >= 300 && resp.StatusCode != http.StatusNotModified {
+		defer resp.Body.Close()
+		var apiErr struct {
+			Message string `json:"message"`
+		}
+		raw, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		if json.Unmarshal(raw, &apiErr) != nil || apiErr.Message == "" {
+			apiErr.Message = strings.TrimSpace(string(raw))
+		}
+		return nil, fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, apiErr.Message)
+	}
+	return resp, nil
+}
+
+// call sends a request bounded by dockerRequestTimeout and decodes its JSON
+// response into out, when given
+func (d *dockerClient) call(ctx context.Context, method, path string, query url.Values, body, out interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, dockerRequestTimeout)
+	defer cancel()
+
+	resp, err := d.send(ctx, method, path, query, body, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if out == nil || resp.StatusCode == http.StatusNotModified {
+		_, err = io.Copy(io.Discard, resp.Body)
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// ping checks the host's Engine API is reachable
+func (d *dockerClient) ping(ctx context.Context) error {
+	return d.call(ctx, http.MethodGet, "/_ping", nil, nil, nil)
+}
+
+// pullImage pulls an image, authenticating with a registry's credentials
+// when given. The pull's progress stream is read to the end, as the pull
+// stops when the connection closes, and its last error is returned.
+func (d *dockerClient) pullImage(ctx context.Context, image string, registry *models.ImageRegistry) error {
+	header := http.Header{}
+	if registry != nil && registry.Username != "" {
+		auth, _ := json.Marshal(map[string]string{
+			"username":      registry.Username,
+			"password":      registry.Password,
+			"serveraddress": registryHost(registry.MirrorPrefix),
+		})
+		header.Set("X-Registry-Auth", base64.URLEncoding.EncodeToString(auth))
+	}
+
+	resp, err := d.send(ctx, http.MethodPost, "/images/create", url.Values{"fromImage": {image}}, nil, header)
+	if err != nil {
+		return fmt.Errorf("failed to pull %s: %w", image, err)
+	}
+	defer resp.Body.Close()
+
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var message struct {
+			Error string `json:"error"`
+		}
+		if err := decoder.Decode(&message); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to pull %s: %w", image, err)
+		}
+		if message.Error != "" {
+			return fmt.Errorf("failed to pull %s: %s", image, message.Error)
+		}
+	}
+}
+
+// inspectContainer returns a container by name, or errDockerNotFound
+func (d *dockerClient) inspectContainer(ctx context.Context, name string) (*dockerContainer, error) {
+	var container dockerContainer
+	if err := d.call(ctx, http.MethodGet, "/containers/"+url.PathEscape(name)+"/json", nil, nil, &container); err != nil {
+		return nil, err
+	}
+	return &container, nil
+}
+
+// createContainer creates a container and returns its ID
+func (d *dockerClient) createContainer(ctx context.Context, name string, spec *dockerContainerSpec) (string, error) {
+	var created struct {
+		ID string `json:"Id"`
+	}
+	if err := d.call(ctx, http.MethodPost, "/containers/create", url.Values{"name": {name}}, spec, &created); err != nil {
+		return "", err
+	}
+	return created.ID, nil
+}
+
+// startContainer starts a container
+func (d *dockerClient) startContainer(ctx context.Context, id string) error {
+	return d.call(ctx, http.MethodPost, "/containers/"+url.PathEscape(id)+"/start", nil, nil, nil)
+}
+
+// removeContainer stops and removes a container, keeping its named volumes
+func (d *dockerClient) removeContainer(ctx context.Context, id string) error {
+	err := d.call(ctx, http.MethodDelete, "/containers/"+url.PathEscape(id), url.Values{"force": {"true"}}, nil, nil)
+	if errors.Is(err, errDockerNotFound) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,154 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	"github.com/penguintechinc/nest/services/k8s-controller/pkg/models"
+	"gorm.io/gorm/clause"
+)
+
+// Controller instance statuses reported while draining
+const (
+	instanceStatusDraining = "draining"
+	instanceStatusDrained  = "drained"
+)
+
+// Draining reports whether the controller has stopped taking new reconciles
+func (c *Controller) Draining() bool {
+	return c.draining.Load()
+}
+
+// Drained reports whether a drain has finished, leaving nothing in flight
+// and the retry queue checkpointed
+func (c *Controller) Drained() bool {
+	return c.drained.Load()
+}
+
+// instanceStatus returns the status reported in heartbeats
+func (c *Controller) instanceStatus() string {
+	switch {
+	case c.drained.Load():
+		return instanceStatusDrained
+	case c.draining.Load():
+		return instanceStatusDraining
+	}
+	return instanceStatusRunning
+}
+
+// Drain prepares the controller to be replaced, such as for an upgrade. It
+// stops taking new reconciles, handing queued requests back for other
+// instances, waits up to DRAIN_TIMEOUT for the reconciles in flight to finish, and
+// checkpoints the retry queue so the next instance resumes its backoff.
+// The controller stays drained until it restarts; draining twice is a no-op.
+func (c *Controller) Drain(ctx context.Context) {
+	if !c.draining.CompareAndSwap(false, true) {
+		return
+	}
+	log := c.log.WithField("timeout", c.config.DrainTimeout)
+	log.Info("Draining controller")
+	c.heartbeat(ctx, instanceStatusDraining)
+
+	for _, item := range c.queue.Flush() {
+		if item.requested {
+			c.requeueRequest(ctx, item.resourceID)
+		}
+	}
+
+	ticker := time.NewTicker(200 * time.Millisecond)
+	defer ticker.Stop()
+	deadline := time.After(c.config.DrainTimeout)
+wait:
+	for c.inFlightCount() > 0 {
+		select {
+		case <-ctx.Done():
+			return
+		case <-c.stopChan:
+			return
+		case <-deadline:
+			log.WithField("in_flight", c.inFlightCount()).Warn("Drain timed out with reconciles in flight")
+			break wait
+		case <-ticker.C:
+		}
+	}
+
+	if err := c.checkpointRetryQueue(ctx); err != nil {
+		log.WithError(err).Warn("Failed to checkpoint retry queue")
+	}
+	c.drained.Store(true)
+	c.heartbeat(ctx, instanceStatusDrained)
+	log.Info("Controller drained")
+}
+
+// inFlightCount returns the number of reconciles in flight
+func (c *Controller) inFlightCount() int {
+	count := 0
+	c.inFlight.Range(func(_, _ interface{}) bool {
+		count++
+		return true
+	})
+	return count
+}
+
+// requeueRequest hands a claimed reconcile request back while draining, so
+// another instance picks it up
+func (c *Controller) requeueRequest(ctx context.Context, resourceID uint) {
+	if err := c.db.WithContext(ctx).Create(&models.ReconcileRequest{ResourceID: resourceID}).Error; err != nil {
+		c.log.WithError(err).WithField("resource_id", resourceID).Error("Failed to requeue reconcile request while draining")
+		return
+	}
+	if err := c.db.WithContext(ctx).Exec("SELECT pg_notify(?, ?)", reconcileChannel, "").Error; err != nil {
+		c.log.WithError(err).Warn("Failed to notify other instances of a requeued request")
+	}
+}
+
+// checkpointRetryQueue persists the backoff of every resource in the retry
+// queue to reconcile_statuses
+func (c *Controller) checkpointRetryQueue(ctx context.Context) error {
+	c.retryMutex.RLock()
+	statuses := make([]models.ReconcileStatus, 0, len(c.retryQueue))
+	for _, entry := range c.retryQueue {
+		next := entry.nextRetry.UTC()
+		statuses = append(statuses, models.ReconcileStatus{
+			ResourceID:  entry.resourceID,
+			LastOutcome: reconcileOutcomeError,
+			RetryCount:  entry.retryCount,
+			NextRetryAt: &next,
+			InstanceID:  c.config.InstanceID,
+		})
+	}
+	c.retryMutex.RUnlock()
+
+	if len(statuses) == 0 {
+		return nil
+	}
+	if err := c.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "resource_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"retry_count", "next_retry_at", "instance_id", "updated_at"}),
+	}).Create(&statuses).Error; err != nil {
+		return err
+	}
+	c.log.WithField("count", len(statuses)).Info("Checkpointed retry queue")
+	return nil
+}
+
+// checkDrainRequest starts a drain requested through the API since the
+// controller started
+func (c *Controller) checkDrainRequest(ctx context.Context) {
+	if c.draining.Load() {
+		return
+	}
+	var instance models.ControllerInstance
+	if err := c.db.WithContext(ctx).Select("drain_requested_at").
+		Where("instance_id = ?", c.config.InstanceID).First(&instance).Error; err != nil {
+		c.log.WithError(err).Warn("Failed to check for a drain request")
+		return
+	}
+	if instance.DrainRequestedAt != nil && instance.DrainRequestedAt.After(c.startedAt) {
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			c.Drain(ctx)
+		}()
+	}
+}
@@ -0,0 +1,48 @@
+package controller
+
+import (
+	"fmt"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+// Reasons of the Kubernetes Events recorded on managed StatefulSets
+const (
+	eventReasonCreated         = "Created"
+	eventReasonCreateFailed    = "CreateFailed"
+	eventReasonUpdated         = "Updated"
+	eventReasonUpdateFailed    = "UpdateFailed"
+	eventReasonResized         = "Resized"
+	eventReasonRestartPending  = "RestartPending"
+	eventReasonPolicyViolation = "PolicyViolation"
+	eventReasonReconcileFailed = "ReconcileFailed"
+)
+
+// newEventBroadcaster returns a broadcaster writing Events through the
+// clientset, and a recorder attributing them to the controller
+func newEventBroadcaster(clientset kubernetes.Interface) (record.EventBroadcaster, record.EventRecorder) {
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: clientset.CoreV1().Events("")})
+	recorder := broadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: "nest-controller"})
+	return broadcaster, recorder
+}
+
+// event records a Kubernetes Event on a managed StatefulSet, so that
+// kubectl describe shows what the controller did to it
+func (r *Reconciler) event(sts *appsv1.StatefulSet, eventType, reason, messageFmt string, args ...interface{}) {
+	if r.recorder == nil || sts == nil {
+		return
+	}
+	r.recorder.Eventf(sts, eventType, reason, messageFmt, args...)
+}
+
+// changesMessage describes the changes an update applied to a StatefulSet
+func changesMessage(changes []string) string {
+	return fmt.Sprintf("Updated StatefulSet for %s", strings.Join(changes, ", "))
+}
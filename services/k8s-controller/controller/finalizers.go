@@ -0,0 +1,58 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/penguintechinc/nest/services/k8s-controller/pkg/models"
+)
+
+// controllerFinalizer is held on full lifecycle resources until the
+// controller has removed their Kubernetes objects; the API will not purge a
+// resource while any finalizer remains
+const controllerFinalizer = "nest.penguintech.io/k8s-resources"
+
+// Resource deletion states shared with the API
+const (
+	deletionStateDeleting      = "deleting"
+	deletionStateDeprovisioned = "deprovisioned"
+)
+
+// ensureFinalizer adds the controller finalizer to a live resource that does
+// not hold it yet, such as one created before finalizers existed
+func (r *Reconciler) ensureFinalizer(ctx context.Context, resource *models.Resource) error {
+	if resource.Finalizers.Contains(controllerFinalizer) {
+		return nil
+	}
+
+	finalizers := append(models.StringList{}, resource.Finalizers...)
+	finalizers = append(finalizers, controllerFinalizer)
+	if err := r.db.WithContext(ctx).Model(&models.Resource{}).Where("id = ?", resource.ID).
+		UpdateColumn("finalizers", finalizers).Error; err != nil {
+		return fmt.Errorf("failed to add finalizer: %w", err)
+	}
+	resource.Finalizers = finalizers
+	return nil
+}
+
+// releaseFinalizer removes the controller finalizer once Kubernetes cleanup is
+// complete, moving the resource to deprovisioned when no finalizers remain
+func (r *Reconciler) releaseFinalizer(ctx context.Context, resource *models.Resource) error {
+	finalizers := models.StringList{}
+	for _, f := range resource.Finalizers {
+		if f != controllerFinalizer {
+			finalizers = append(finalizers, f)
+		}
+	}
+
+	updates := map[string]interface{}{"finalizers": finalizers}
+	if len(finalizers) == 0 {
+		updates["deletion_state"] = deletionStateDeprovisioned
+	}
+	if err := r.db.WithContext(ctx).Model(&models.Resource{}).Where("id = ?", resource.ID).
+		UpdateColumns(updates).Error; err != nil {
+		return fmt.Errorf("failed to release finalizer: %w", err)
+	}
+	resource.Finalizers = finalizers
+	return nil
+}
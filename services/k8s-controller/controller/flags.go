@@ -0,0 +1,56 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+
+	"github.com/penguintechinc/nest/services/k8s-controller/pkg/models"
+	"gorm.io/gorm"
+)
+
+// Feature flags consumed by the controller, declared with the same keys and
+// defaults as in the API
+const (
+	// flagAutoRestarts rolls pods when the Secrets and ConfigMaps they read
+	// change
+	flagAutoRestarts = "controller.auto-restarts"
+)
+
+// flagDefaults holds each flag's value when no override exists
+var flagDefaults = map[string]bool{
+	flagAutoRestarts: true,
+}
+
+// flagBucket places a team in one of 100 rollout buckets for a flag, hashed
+// the same way as in the API
+func flagBucket(key string, teamID uint) int {
+	h := fnv.New32a()
+	fmt.Fprintf(h, "%s:%d", key, teamID)
+	return int(h.Sum32() % 100)
+}
+
+// featureEnabled reports whether a flag is on for a team. An override that is
+// disabled turns the flag off for everyone; an enabled one turns it on for
+// its listed teams and its percentage of the rest.
+func (r *Reconciler) featureEnabled(ctx context.Context, key string, teamID uint) (bool, error) {
+	var flag models.FeatureFlag
+	err := r.db.WithContext(ctx).Where("key = ? AND deleted_at IS NULL", key).First(&flag).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return flagDefaults[key], nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to load feature flag %s: %w", key, err)
+	}
+
+	if !flag.Enabled {
+		return false, nil
+	}
+	for _, id := range flag.Teams {
+		if id == teamID {
+			return true, nil
+		}
+	}
+	return flagBucket(key, teamID) < flag.Percentage, nil
+}
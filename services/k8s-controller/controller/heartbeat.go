@@ -0,0 +1,84 @@
+package controller
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/penguintechinc/nest/services/k8s-controller/pkg/models"
+	"gorm.io/gorm/clause"
+)
+
+// Controller instance statuses reported in heartbeats
+const (
+	instanceStatusRunning = "running"
+	instanceStatusStopped = "stopped"
+)
+
+// heartbeatLoop records this instance's heartbeat on each interval so the API
+// can report stale or crashed controllers, and picks up log level overrides
+// and drain requests
+func (c *Controller) heartbeatLoop(ctx context.Context) {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(c.config.HeartbeatInterval)
+	defer ticker.Stop()
+
+	log := c.log.WithField("instance_id", c.config.InstanceID)
+	log.WithField("interval", c.config.HeartbeatInterval).Info("Starting heartbeat")
+
+	c.heartbeat(ctx, c.instanceStatus())
+	c.refreshLogLevels(ctx)
+	c.checkDrainRequest(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-c.stopChan:
+			return
+		case <-ticker.C:
+			c.heartbeat(ctx, c.instanceStatus())
+			c.refreshLogLevels(ctx)
+			c.checkDrainRequest(ctx)
+		}
+	}
+}
+
+// heartbeat upserts this instance's row in controller_instances
+func (c *Controller) heartbeat(ctx context.Context, status string) {
+	hostname, _ := os.Hostname()
+
+	c.retryMutex.RLock()
+	queueDepth := len(c.retryQueue)
+	c.retryMutex.RUnlock()
+
+	instance := models.ControllerInstance{
+		InstanceID:      c.config.InstanceID,
+		Hostname:        hostname,
+		Cluster:         c.config.ClusterName,
+		Version:         c.config.Version,
+		SchemaVersion:   SchemaVersion,
+		BuildTime:       c.config.BuildTime,
+		GitCommit:       c.config.GitCommit,
+		Status:          status,
+		WorkerCount:     c.config.WorkerCount,
+		QueueDepth:      queueDepth,
+		StartedAt:       c.startedAt,
+		LastHeartbeatAt: time.Now().UTC(),
+	}
+	if nanos := c.lastReconcile.Load(); nanos > 0 {
+		t := time.Unix(0, nanos).UTC()
+		instance.LastReconcileAt = &t
+	}
+
+	if err := c.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "instance_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"hostname", "cluster", "version", "schema_version", "build_time", "git_commit",
+			"status", "worker_count", "queue_depth", "started_at", "last_heartbeat_at",
+			"last_reconcile_at", "updated_at",
+		}),
+	}).Create(&instance).Error; err != nil {
+		c.log.WithError(err).Warn("Failed to record controller heartbeat")
+	}
+}
@@ -0,0 +1,344 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/url"
+	"time"
+
+	"github.com/penguintechinc/nest/services/k8s-controller/pkg/config"
+	"github.com/penguintechinc/nest/services/k8s-controller/pkg/mtls"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// identityCASecret holds the internal CA that issues service identities
+	identityCASecret = "nest-mtls-ca"
+
+	// identityCAValidity is how long the internal CA is valid for. Deleting
+	// its secret rotates it, and every identity is reissued from the new CA.
+	identityCAValidity = 10 * 365 * 24 * time.Hour
+)
+
+// identityCA is the internal CA's certificate and key
+type identityCA struct {
+	cert    *x509.Certificate
+	key     crypto.Signer
+	certPEM []byte
+}
+
+// IdentityIssuer issues the service identities used for mutual TLS between
+// the API, the controller, and database agents. Each identity is a
+// certificate from the internal CA, kept in a <name>-mtls secret that the
+// service mounts, and is reissued once two thirds of its lifetime has
+// passed. Both the CA and the identities live in Kubernetes secrets, so
+// the controller can issue its own identity before it can reach Postgres.
+type IdentityIssuer struct {
+	clientset kubernetes.Interface
+	config    *config.Config
+	identity  *mtls.Identity
+	log       *logrus.Entry
+}
+
+// NewIdentityIssuer creates an identity issuer. The controller's own
+// identity is loaded into identity on each issue.
+func NewIdentityIssuer(cfg *config.Config, identity *mtls.Identity) (*IdentityIssuer, error) {
+	k8sConfig, err := createK8sConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create k8s client: %w", err)
+	}
+	clientset, err := kubernetes.NewForConfig(k8sConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create clientset: %w", err)
+	}
+
+	return &IdentityIssuer{
+		clientset: clientset,
+		config:    cfg,
+		identity:  identity,
+		log:       logrus.WithField("component", "identity-issuer"),
+	}, nil
+}
+
+// Run checks the identities for renewal on every interval until the
+// context is cancelled
+func (i *IdentityIssuer) Run(ctx context.Context) {
+	ticker := time.NewTicker(i.config.MTLSCheckInterval)
+	defer ticker.Stop()
+
+	i.log.WithField("interval", i.config.MTLSCheckInterval).Info("Identity issuer started")
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := i.Issue(ctx); err != nil {
+				i.log.WithError(err).Error("Failed to issue service identities")
+			}
+		}
+	}
+}
+
+// Issue makes sure the CA and every configured identity exist and are
+// current, and loads the controller's own identity
+func (i *IdentityIssuer) Issue(ctx context.Context) error {
+	ca, err := i.ensureCA(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range i.config.MTLSIdentities {
+		secret, err := i.ensureIdentity(ctx, ca, name)
+		if err != nil {
+			return fmt.Errorf("failed to issue identity %s: %w", name, err)
+		}
+		if name == i.config.MTLSIdentity {
+			if err := i.identity.Update(secret.Data[mtls.CertKey], secret.Data[mtls.KeyKey], secret.Data[mtls.CAKey]); err != nil {
+				return fmt.Errorf("failed to load identity %s: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// ensureCA loads the internal CA, creating it on first use. Controllers
+// racing to create it all end up with the one that was stored first.
+func (i *IdentityIssuer) ensureCA(ctx context.Context) (*identityCA, error) {
+	secrets := i.clientset.CoreV1().Secrets(i.config.MTLSNamespace)
+
+	secret, err := secrets.Get(ctx, identityCASecret, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		secret, err = i.newCASecret()
+		if err != nil {
+			return nil, err
+		}
+		if _, err = secrets.Create(ctx, secret, metav1.CreateOptions{}); err == nil {
+			i.log.WithField("secret", identityCASecret).Info("Created internal CA")
+		} else if errors.IsAlreadyExists(err) {
+			secret, err = secrets.Get(ctx, identityCASecret, metav1.GetOptions{})
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get CA secret: %w", err)
+	}
+
+	return parseCA(secret.Data[mtls.CertKey], secret.Data[mtls.KeyKey])
+}
+
+// newCASecret generates a self-signed CA
+func (i *IdentityIssuer) newCASecret() (*corev1.Secret, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate CA key: %w", err)
+	}
+	serial, err := randomSerial()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "nest-internal-ca", Organization: []string{"nest"}},
+		NotBefore:             now.Add(-5 * time.Minute),
+		NotAfter:              now.Add(identityCAValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CA certificate: %w", err)
+	}
+	keyPEM, err := encodeKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return i.identitySecret(identityCASecret, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), keyPEM, nil), nil
+}
+
+// ensureIdentity returns the secret of an identity, issuing a new
+// certificate when there is none, it wasn't issued by the current CA, or
+// it is due for renewal
+func (i *IdentityIssuer) ensureIdentity(ctx context.Context, ca *identityCA, name string) (*corev1.Secret, error) {
+	secrets := i.clientset.CoreV1().Secrets(i.config.MTLSNamespace)
+	secretName := name + "-mtls"
+
+	existing, err := secrets.Get(ctx, secretName, metav1.GetOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to get identity secret: %w", err)
+	}
+	found := err == nil
+	if found && !i.needsRenewal(ca, existing) {
+		return existing, nil
+	}
+
+	certPEM, keyPEM, err := i.issueCertificate(ca, name)
+	if err != nil {
+		return nil, err
+	}
+	secret := i.identitySecret(secretName, certPEM, keyPEM, ca.certPEM)
+
+	if found {
+		secret.ResourceVersion = existing.ResourceVersion
+		_, err = secrets.Update(ctx, secret, metav1.UpdateOptions{})
+	} else {
+		_, err = secrets.Create(ctx, secret, metav1.CreateOptions{})
+	}
+	if errors.IsConflict(err) || errors.IsAlreadyExists(err) {
+		// Another controller issued it first; use the one it stored
+		stored, getErr := secrets.Get(ctx, secretName, metav1.GetOptions{})
+		if getErr == nil && !i.needsRenewal(ca, stored) {
+			return stored, nil
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to store identity secret: %w", err)
+	}
+
+	i.log.WithFields(logrus.Fields{"identity": name, "secret": secretName}).Info("Issued service identity")
+	return secret, nil
+}
+
+// needsRenewal reports whether an identity secret must be reissued
+func (i *IdentityIssuer) needsRenewal(ca *identityCA, secret *corev1.Secret) bool {
+	if !bytes.Equal(secret.Data[mtls.CAKey], ca.certPEM) {
+		return true
+	}
+	block, _ := pem.Decode(secret.Data[mtls.CertKey])
+	if block == nil {
+		return true
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil || cert.CheckSignatureFrom(ca.cert) != nil {
+		return true
+	}
+
+	lifetime := cert.NotAfter.Sub(cert.NotBefore)
+	renewAt := cert.NotBefore.Add(lifetime * 2 / 3)
+	return time.Now().After(renewAt)
+}
+
+// issueCertificate signs a new certificate for a service, valid both as a
+// server and as a client. The service is named by a spiffe URI SAN and
+// reachable under the DNS names of its Service in the identity namespace.
+func (i *IdentityIssuer) issueCertificate(ca *identityCA, name string) ([]byte, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+	serial, err := randomSerial()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now().UTC()
+	notAfter := now.Add(i.config.MTLSCertTTL)
+	if notAfter.After(ca.cert.NotAfter) {
+		notAfter = ca.cert.NotAfter
+	}
+	namespace := i.config.MTLSNamespace
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: name, Organization: []string{"nest"}},
+		DNSNames: []string{
+			name,
+			fmt.Sprintf("%s.%s", name, namespace),
+			fmt.Sprintf("%s.%s.svc", name, namespace),
+			fmt.Sprintf("%s.%s.svc.cluster.local", name, namespace),
+		},
+		URIs:        []*url.URL{mtls.ServiceURI(name)},
+		NotBefore:   now.Add(-5 * time.Minute),
+		NotAfter:    notAfter,
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to sign certificate: %w", err)
+	}
+	keyPEM, err := encodeKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), keyPEM, nil
+}
+
+// identitySecret builds a TLS secret. The CA certificate is included for
+// service identities so peers can be verified from the same mount.
+func (i *IdentityIssuer) identitySecret(name string, certPEM, keyPEM, caPEM []byte) *corev1.Secret {
+	data := map[string][]byte{
+		mtls.CertKey: certPEM,
+		mtls.KeyKey:  keyPEM,
+	}
+	if caPEM != nil {
+		data[mtls.CAKey] = caPEM
+	}
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: i.config.MTLSNamespace,
+			Labels: map[string]string{
+				"managed-by": "nest-controller",
+			},
+		},
+		Type: corev1.SecretTypeTLS,
+		Data: data,
+	}
+}
+
+// parseCA decodes the internal CA from its secret
+func parseCA(certPEM, keyPEM []byte) (*identityCA, error) {
+	certBlock, _ := pem.Decode(certPEM)
+	keyBlock, _ := pem.Decode(keyPEM)
+	if certBlock == nil || keyBlock == nil {
+		return nil, fmt.Errorf("CA secret %s holds no certificate or key", identityCASecret)
+	}
+	cert, err := x509.ParseCertificate(certBlock.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CA certificate: %w", err)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(keyBlock.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CA key: %w", err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("CA key of type %T cannot sign", key)
+	}
+	return &identityCA{cert: cert, key: signer, certPEM: certPEM}, nil
+}
+
+// encodeKey PEM encodes a private key as PKCS #8
+func encodeKey(key *ecdsa.PrivateKey) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode key: %w", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// randomSerial returns a random 128-bit certificate serial number
+func randomSerial() (*big.Int, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate serial number: %w", err)
+	}
+	return serial, nil
+}
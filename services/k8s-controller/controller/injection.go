@@ -0,0 +1,193 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+
+	"github.com/penguintechinc/nest/services/k8s-controller/pkg/models"
+	"github.com/sirupsen/logrus"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Container injection kinds
+const (
+	injectionKindInit    = "init"
+	injectionKindSidecar = "sidecar"
+)
+
+// injectedContainer is an init container or sidecar from a resource's Config
+// or a team container policy
+type injectedContainer struct {
+	Name    string            `json:"name"`
+	Image   string            `json:"image"`
+	Command []string          `json:"command"`
+	Args    []string          `json:"args"`
+	Env     map[string]string `json:"env"`
+}
+
+// container converts an injected container to its Kubernetes form, with env
+// vars sorted so the pod template is stable across reconciles
+func (ic injectedContainer) container() corev1.Container {
+	c := corev1.Container{Name: ic.Name, Image: ic.Image, Command: ic.Command, Args: ic.Args}
+	names := make([]string, 0, len(ic.Env))
+	for name := range ic.Env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		c.Env = append(c.Env, corev1.EnvVar{Name: name, Value: ic.Env[name]})
+	}
+	return c
+}
+
+// configInjections decodes Config.init_containers and Config.sidecars, e.g.
+//
+//	{"sidecars": [{"name": "log-shipper", "image": "fluent/fluent-bit:3.0"}]}
+//
+// A Config that doesn't decode is an error rather than no containers, so
+// that a malformed edit doesn't silently drop the ones already running.
+func configInjections(resource *models.Resource) (init, sidecars []injectedContainer, err error) {
+	var doc struct {
+		InitContainers []injectedContainer `json:"init_containers"`
+		Sidecars       []injectedContainer `json:"sidecars"`
+	}
+	if resource.Config != nil {
+		raw, err := json.Marshal(resource.Config)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to encode config: %w", err)
+		}
+		if err := json.Unmarshal(raw, &doc); err != nil {
+			return nil, nil, fmt.Errorf("init_containers and sidecars must be lists of containers: %w", err)
+		}
+	}
+	return doc.InitContainers, doc.Sidecars, nil
+}
+
+// policyInjections loads the team's enabled container policies that apply to
+// the resource type
+func (r *Reconciler) policyInjections(ctx context.Context, resource *models.Resource, resourceType models.ResourceType) (init, sidecars []injectedContainer, err error) {
+	var policies []models.ContainerPolicy
+	if err := r.db.WithContext(ctx).
+		Where("team_id = ? AND enabled AND deleted_at IS NULL", resource.TeamID).
+		Order("id").Find(&policies).Error; err != nil {
+		return nil, nil, fmt.Errorf("failed to load container policies: %w", err)
+	}
+
+	for _, p := range policies {
+		if len(p.ResourceTypes) > 0 && !p.ResourceTypes.Contains(resourceType.Name) {
+			continue
+		}
+		ic := injectedContainer{Name: p.Name, Image: p.Image, Command: p.Command, Args: p.Args}
+		for k, v := range p.Env {
+			if ic.Env == nil {
+				ic.Env = map[string]string{}
+			}
+			ic.Env[k] = fmt.Sprint(v)
+		}
+		if p.Kind == injectionKindInit {
+			init = append(init, ic)
+		} else {
+			sidecars = append(sidecars, ic)
+		}
+	}
+	return init, sidecars, nil
+}
+
+// applyInjections attaches the init containers and sidecars requested by the
+// resource's Config and its team's container policies. Containers whose image
+// is not allowlisted, or whose name is already taken, are skipped.
+func (r *Reconciler) applyInjections(ctx context.Context, resource *models.Resource, resourceType models.ResourceType, sts *appsv1.StatefulSet) error {
+	configInit, configSidecars, err := configInjections(resource)
+	if err != nil {
+		return err
+	}
+	policyInit, policySidecars, err := r.policyInjections(ctx, resource, resourceType)
+	if err != nil {
+		return err
+	}
+	if len(configInit)+len(configSidecars)+len(policyInit)+len(policySidecars) == 0 {
+		return nil
+	}
+
+	var patterns []string
+	if err := r.db.WithContext(ctx).Model(&models.AllowedImage{}).
+		Where("deleted_at IS NULL").Pluck("pattern", &patterns).Error; err != nil {
+		return fmt.Errorf("failed to load allowed images: %w", err)
+	}
+
+	spec := &sts.Spec.Template.Spec
+	names := map[string]bool{}
+	for _, c := range spec.Containers {
+		names[c.Name] = true
+	}
+
+	accept := func(ic injectedContainer) bool {
+		log := r.log.WithFields(logrus.Fields{"resource_id": resource.ID, "container": ic.Name, "image": ic.Image})
+		if ic.Name == "" || names[ic.Name] {
+			log.Warn("Skipping injected container with missing or duplicate name")
+			return false
+		}
+		if !imageAllowed(patterns, ic.Image) {
+			log.Warn("Skipping injected container with image not on the allowlist")
+			return false
+		}
+		names[ic.Name] = true
+		return true
+	}
+
+	// Policy containers come first so team-wide proxies and shippers start
+	// before per-resource ones
+	for _, ic := range append(policyInit, configInit...) {
+		if accept(ic) {
+			spec.InitContainers = append(spec.InitContainers, ic.container())
+		}
+	}
+	for _, ic := range append(policySidecars, configSidecars...) {
+		if accept(ic) {
+			spec.Containers = append(spec.Containers, ic.container())
+		}
+	}
+	return nil
+}
+
+// imageAllowed reports whether an image matches an allowlisted pattern
+func imageAllowed(patterns []string, image string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, image); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// containerNames lists the init container and container names of a pod
+// template in order
+func containerNames(sts *appsv1.StatefulSet) []string {
+	var names []string
+	for _, c := range sts.Spec.Template.Spec.InitContainers {
+		names = append(names, "init:"+c.Name)
+	}
+	for _, c := range sts.Spec.Template.Spec.Containers {
+		names = append(names, c.Name)
+	}
+	return names
+}
+
+// injectionsDiffer reports whether two pod templates have different sets of
+// containers, such as after a container policy is added or removed
+func injectionsDiffer(desired, current *appsv1.StatefulSet) bool {
+	want, have := containerNames(desired), containerNames(current)
+	if len(want) != len(have) {
+		return true
+	}
+	for i := range want {
+		if want[i] != have[i] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/penguintechinc/nest/services/k8s-controller/pkg/models"
+)
+
+func TestConfigInjections(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   models.JSONMap
+		init     []string
+		sidecars []string
+		wantErr  bool
+	}{
+		{
+			name: "no config",
+		},
+		{
+			name: "init containers and sidecars",
+			config: models.JSONMap{
+				"init_containers": []interface{}{map[string]interface{}{"name": "wait-for-dns", "image": "busybox:1.36"}},
+				"sidecars":        []interface{}{map[string]interface{}{"name": "log-shipper", "image": "fluent/fluent-bit:3.0"}},
+			},
+			init:     []string{"wait-for-dns"},
+			sidecars: []string{"log-shipper"},
+		},
+		{
+			name:    "sidecars not a list",
+			config:  models.JSONMap{"sidecars": map[string]interface{}{"name": "log-shipper"}},
+			wantErr: true,
+		},
+		{
+			name:    "container with a malformed field",
+			config:  models.JSONMap{"init_containers": []interface{}{map[string]interface{}{"name": "wait-for-dns", "command": "sleep 5"}}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			init, sidecars, err := configInjections(&models.Resource{Config: tt.config})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Expected an error, got init %v and sidecars %v", init, sidecars)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Failed to decode injections: %v", err)
+			}
+			if got := injectedNames(init); !reflect.DeepEqual(got, tt.init) {
+				t.Errorf("Expected init containers %v, got %v", tt.init, got)
+			}
+			if got := injectedNames(sidecars); !reflect.DeepEqual(got, tt.sidecars) {
+				t.Errorf("Expected sidecars %v, got %v", tt.sidecars, got)
+			}
+		})
+	}
+}
+
+func injectedNames(containers []injectedContainer) []string {
+	var names []string
+	for _, c := range containers {
+		names = append(names, c.Name)
+	}
+	return names
+}
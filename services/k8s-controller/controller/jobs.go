@@ -0,0 +1,168 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/penguintechinc/nest/services/k8s-controller/pkg/models"
+	"github.com/sirupsen/logrus"
+)
+
+// Provisioning job statuses. The API marks a running job cancelling when a
+// user cancels it, and the controller running it stops it.
+const (
+	jobStatusRunning    = "running"
+	jobStatusCancelling = "cancelling"
+	jobStatusCancelled  = "cancelled"
+	jobStatusFailed     = "failed"
+)
+
+// Causes a provisioning job's context is cancelled with
+var (
+	errJobCancelled = errors.New("job cancelled")
+	errJobTimedOut  = errors.New("job timed out")
+)
+
+// runningJobs tracks the provisioning jobs this instance is running, so they
+// can be cancelled and their failures attributed to a cancel or timeout
+type runningJobs struct {
+	mu   sync.Mutex
+	jobs map[uint]*runningJob
+}
+
+type runningJob struct {
+	ctx     context.Context
+	cancel  context.CancelCauseFunc
+	timeout time.Duration
+}
+
+func newRunningJobs() *runningJobs {
+	return &runningJobs{jobs: make(map[uint]*runningJob)}
+}
+
+// ids returns the IDs of the running jobs
+func (j *runningJobs) ids() []uint {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	ids := make([]uint, 0, len(j.jobs))
+	for id := range j.jobs {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// cancel stops a running job, reporting whether it was running
+func (j *runningJobs) cancel(id uint) bool {
+	j.mu.Lock()
+	job, ok := j.jobs[id]
+	j.mu.Unlock()
+	if ok {
+		job.cancel(errJobCancelled)
+	}
+	return ok
+}
+
+// get returns a running job, or nil
+func (j *runningJobs) get(id uint) *runningJob {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.jobs[id]
+}
+
+// beginJob records a running provisioning job of jobType and starts the
+// operation it carries out. The returned context is cancelled when the job is
+// cancelled or runs past its timeout, and the returned func releases it once
+// the job has completed or failed. A job that can't be recorded runs with ID
+// zero under ctx.
+func (r *Reconciler) beginJob(ctx context.Context, resourceID uint, jobType string,
+	log *logrus.Entry) (*models.ProvisioningJob, context.Context, func()) {
+	job := &models.ProvisioningJob{
+		ResourceID: resourceID,
+		JobType:    jobType,
+		Status:     jobStatusRunning,
+		StartedAt:  timePtr(time.Now()),
+	}
+	if err := r.db.Create(job).Error; err != nil {
+		log.WithError(err).Errorf("Failed to create %s job", jobType)
+		return job, ctx, func() {}
+	}
+	r.startOperation(job)
+
+	timeout := r.config.JobTimeout(jobType)
+	jobCtx, cancel := context.WithCancelCause(ctx)
+	jobCtx, stop := context.WithTimeoutCause(jobCtx, timeout, errJobTimedOut)
+
+	r.jobs.mu.Lock()
+	r.jobs.jobs[job.ID] = &runningJob{ctx: jobCtx, cancel: cancel, timeout: timeout}
+	r.jobs.mu.Unlock()
+
+	return job, jobCtx, func() {
+		r.jobs.mu.Lock()
+		delete(r.jobs.jobs, job.ID)
+		r.jobs.mu.Unlock()
+		stop()
+		cancel(nil)
+	}
+}
+
+// jobOutcome returns the status and message a failed job is recorded with,
+// telling a job that was cancelled or timed out from one that failed
+func (r *Reconciler) jobOutcome(id uint, message string) (string, string) {
+	job := r.jobs.get(id)
+	if job == nil {
+		return jobStatusFailed, message
+	}
+	switch cause := context.Cause(job.ctx); {
+	case errors.Is(cause, errJobCancelled):
+		return jobStatusCancelled, "Cancelled: " + message
+	case errors.Is(cause, errJobTimedOut):
+		return jobStatusFailed, fmt.Sprintf("Timed out after %s: %s", job.timeout, message)
+	}
+	return jobStatusFailed, message
+}
+
+// jobCancelLoop polls for the running jobs of this instance the API has
+// marked cancelling, and cancels them
+func (c *Controller) jobCancelLoop(ctx context.Context) {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(c.config.JobCancelPollInterval)
+	defer ticker.Stop()
+
+	c.log.WithField("interval", c.config.JobCancelPollInterval).Info("Starting job cancellation polling")
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-c.stopChan:
+			return
+		case <-ticker.C:
+			c.cancelJobs(ctx)
+		}
+	}
+}
+
+// cancelJobs cancels the running jobs marked cancelling
+func (c *Controller) cancelJobs(ctx context.Context) {
+	ids := c.reconciler.jobs.ids()
+	if len(ids) == 0 {
+		return
+	}
+
+	var cancelling []uint
+	if err := c.db.WithContext(ctx).Model(&models.ProvisioningJob{}).
+		Where("id IN ? AND status = ?", ids, jobStatusCancelling).
+		Pluck("id", &cancelling).Error; err != nil {
+		c.log.WithError(err).Warn("Failed to check for cancelled jobs")
+		return
+	}
+	for _, id := range cancelling {
+		if c.reconciler.jobs.cancel(id) {
+			c.log.WithField("job_id", id).Info("Cancelling provisioning job")
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/penguintechinc/nest/services/k8s-controller/pkg/loglevel"
+	"github.com/penguintechinc/nest/services/k8s-controller/pkg/models"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// logLevelService is the service the controller's log level overrides are
+// set for through the API
+const logLevelService = "controller"
+
+// refreshLogLevels applies the log level override set through the API, or
+// returns to LOG_LEVEL once it is removed or expires. It runs with each
+// heartbeat, so overrides reach every instance within HEARTBEAT_INTERVAL.
+func (c *Controller) refreshLogLevels(ctx context.Context) {
+	levels := loglevel.Standard()
+
+	var override models.LogLevelOverride
+	err := c.db.WithContext(ctx).
+		Where("service = ? AND deleted_at IS NULL", logLevelService).
+		Where("expires_at IS NULL OR expires_at > ?", time.Now().UTC()).
+		First(&override).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if c.logOverride.Swap(false) {
+			levels.ClearOverride()
+			c.log.Info("Log level override removed; logging at the configured level")
+		}
+		return
+	}
+	if err != nil {
+		c.log.WithError(err).Warn("Failed to load log level override")
+		return
+	}
+
+	base, components, err := parseLogLevelOverride(override)
+	if err != nil {
+		c.log.WithError(err).Warn("Ignoring invalid log level override")
+		return
+	}
+	levels.SetOverride(base, components)
+	if !c.logOverride.Swap(true) {
+		c.log.WithField("log_levels", levels.State()).Info("Log level override applied")
+	}
+}
+
+// parseLogLevelOverride parses the level and component levels of an
+// override. An empty level leaves components not listed at LOG_LEVEL.
+func parseLogLevelOverride(override models.LogLevelOverride) (*logrus.Level, map[string]logrus.Level, error) {
+	var base *logrus.Level
+	if override.Level != "" {
+		level, err := logrus.ParseLevel(override.Level)
+		if err != nil {
+			return nil, nil, err
+		}
+		base = &level
+	}
+
+	names := make(map[string]string, len(override.Components))
+	for name, value := range override.Components {
+		level, ok := value.(string)
+		if !ok {
+			return nil, nil, fmt.Errorf("component %s: level must be a string", name)
+		}
+		names[name] = level
+	}
+	components, err := loglevel.ParseComponents(names)
+	if err != nil {
+		return nil, nil, err
+	}
+	return base, components, nil
+}
@@ -0,0 +1,427 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/penguintechinc/nest/services/k8s-controller/pkg/models"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// Prometheus Operator resources
+var (
+	serviceMonitorGVR = schema.GroupVersionResource{Group: "monitoring.coreos.com", Version: "v1", Resource: "servicemonitors"}
+	podMonitorGVR     = schema.GroupVersionResource{Group: "monitoring.coreos.com", Version: "v1", Resource: "podmonitors"}
+)
+
+const (
+	exporterContainerName = "metrics-exporter"
+	exporterPortName      = "metrics"
+
+	monitorKindPodMonitor     = "podmonitor"
+	monitorKindServiceMonitor = "servicemonitor"
+)
+
+// exporterDefaults describes the exporter sidecar used for each resource type
+type exporterDefaults struct {
+	image        string
+	port         int32
+	username     string
+	buildEnvArgs func(dbPort int32) ([]corev1.EnvVar, []string)
+}
+
+var exporterByType = map[string]exporterDefaults{
+	"postgresql": {
+		image:    "quay.io/prometheuscommunity/postgres-exporter:v0.15.0",
+		port:     9187,
+		username: "postgres",
+		buildEnvArgs: func(dbPort int32) ([]corev1.EnvVar, []string) {
+			return []corev1.EnvVar{
+				{Name: "DATA_SOURCE_URI", Value: fmt.Sprintf("localhost:%d/postgres?sslmode=disable", dbPort)},
+				secretEnv("DATA_SOURCE_USER", "username"),
+				secretEnv("DATA_SOURCE_PASS", "password"),
+			}, nil
+		},
+	},
+	"mariadb": {
+		image:    "prom/mysqld-exporter:v0.15.1",
+		port:     9104,
+		username: "root",
+		buildEnvArgs: func(dbPort int32) ([]corev1.EnvVar, []string) {
+			return []corev1.EnvVar{
+				secretEnv("MYSQLD_USER", "username"),
+				secretEnv("MYSQLD_EXPORTER_PASSWORD", "password"),
+			}, []string{
+				fmt.Sprintf("--mysqld.address=localhost:%d", dbPort),
+				"--mysqld.username=$(MYSQLD_USER)",
+			}
+		},
+	},
+	"redis": {
+		image: "oliver006/redis_exporter:v1.62.0",
+		port:  9121,
+		buildEnvArgs: func(dbPort int32) ([]corev1.EnvVar, []string) {
+			return []corev1.EnvVar{
+				{Name: "REDIS_ADDR", Value: fmt.Sprintf("redis://localhost:%d", dbPort)},
+				secretEnv("REDIS_PASSWORD", "password"),
+			}, nil
+		},
+	},
+}
+
+// monitoringSpec is the parsed Config.monitoring block of a resource
+type monitoringSpec struct {
+	Enabled  bool
+	Kind     string
+	Interval string
+	Image    string
+}
+
+// monitoringConfig parses Config.monitoring, e.g.
+//
+//	{"monitoring": {"enabled": true, "kind": "podmonitor", "interval": "30s"}}
+func monitoringConfig(resource *models.Resource) monitoringSpec {
+	spec := monitoringSpec{Kind: monitorKindPodMonitor, Interval: "30s"}
+	if resource.Config == nil {
+		return spec
+	}
+
+	m, ok := resource.Config["monitoring"].(map[string]interface{})
+	if !ok {
+		return spec
+	}
+
+	spec.Enabled, _ = m["enabled"].(bool)
+	if v, ok := m["kind"].(string); ok && v == monitorKindServiceMonitor {
+		spec.Kind = v
+	}
+	if v, ok := m["interval"].(string); ok && v != "" {
+		spec.Interval = v
+	}
+	if v, ok := m["image"].(string); ok && v != "" {
+		spec.Image = v
+	}
+
+	return spec
+}
+
+// exporterSecretName returns the name of the Secret holding exporter credentials
+func exporterSecretName(resource *models.Resource) string {
+	return resource.Name + "-exporter"
+}
+
+// secretEnv builds an env var sourced from the exporter Secret. The Secret
+// name is filled in by exporterContainer.
+func secretEnv(name, key string) corev1.EnvVar {
+	return corev1.EnvVar{
+		Name: name,
+		ValueFrom: &corev1.EnvVarSource{
+			SecretKeyRef: &corev1.SecretKeySelector{
+				Key:      key,
+				Optional: boolPtr(true),
+			},
+		},
+	}
+}
+
+// exporterContainer builds the exporter sidecar for a resource, or returns
+// nil when monitoring is disabled or the resource type has no exporter
+func exporterContainer(resource *models.Resource, resourceType models.ResourceType, dbPort int32) *corev1.Container {
+	spec := monitoringConfig(resource)
+	defaults, ok := exporterByType[resourceType.Name]
+	if !spec.Enabled || !ok {
+		return nil
+	}
+
+	image := defaults.image
+	if spec.Image != "" {
+		image = spec.Image
+	}
+
+	env, args := defaults.buildEnvArgs(dbPort)
+	for i := range env {
+		if env[i].ValueFrom != nil && env[i].ValueFrom.SecretKeyRef != nil {
+			env[i].ValueFrom.SecretKeyRef.Name = exporterSecretName(resource)
+		}
+	}
+
+	return &corev1.Container{
+		Name:  exporterContainerName,
+		Image: image,
+		Args:  args,
+		Env:   env,
+		Ports: []corev1.ContainerPort{
+			{Name: exporterPortName, ContainerPort: defaults.port},
+		},
+	}
+}
+
+// ensureExporterSecret creates the Secret with exporter credentials if it does
+// not exist. The resource's own credentials are used when present, and the
+// Secret is updated when they change; otherwise credentials are generated
+// once and kept in the Secret.
+func (r *Reconciler) ensureExporterSecret(ctx context.Context, resource *models.Resource, resourceType models.ResourceType) error {
+	defaults, ok := exporterByType[resourceType.Name]
+	if !monitoringConfig(resource).Enabled || !ok {
+		return nil
+	}
+
+	namespace := *resource.K8sNamespace
+	name := exporterSecretName(resource)
+
+	existing, err := r.clientset.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err == nil {
+		username := stringFromMap(resource.Credentials, "username")
+		password := stringFromMap(resource.Credentials, "password")
+		if username == "" || password == "" ||
+			(string(existing.Data["username"]) == username && string(existing.Data["password"]) == password) {
+			return nil
+		}
+		existing.Data = map[string][]byte{"username": []byte(username), "password": []byte(password)}
+		if _, err := r.clientset.CoreV1().Secrets(namespace).Update(ctx, existing, metav1.UpdateOptions{}); err != nil {
+			return fmt.Errorf("failed to update exporter secret: %w", err)
+		}
+		return nil
+	}
+	if !errors.IsNotFound(err) {
+		return fmt.Errorf("failed to get exporter secret: %w", err)
+	}
+
+	username := stringFromMap(resource.Credentials, "username")
+	if username == "" {
+		username = defaults.username
+	}
+	password := stringFromMap(resource.Credentials, "password")
+	if password == "" {
+		password, err = r.generatePassword(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels: map[string]string{
+				"app":         resource.Name,
+				"managed-by":  "nest-controller",
+				"resource-id": fmt.Sprintf("%d", resource.ID),
+			},
+		},
+		Type: corev1.SecretTypeOpaque,
+		StringData: map[string]string{
+			"username": username,
+			"password": password,
+		},
+	}
+
+	if _, err := r.clientset.CoreV1().Secrets(namespace).Create(ctx, secret, metav1.CreateOptions{}); err != nil {
+		return fmt.Errorf("failed to create exporter secret: %w", err)
+	}
+
+	return nil
+}
+
+// reconcileMonitors creates or removes the Prometheus Operator objects that
+// scrape a resource's exporter. Nothing is done when the operator's CRDs are
+// not installed in the cluster.
+func (r *Reconciler) reconcileMonitors(ctx context.Context, resource *models.Resource, resourceType models.ResourceType) error {
+	if r.dynamicClient == nil || resource.K8sNamespace == nil {
+		return nil
+	}
+
+	spec := monitoringConfig(resource)
+	_, supported := exporterByType[resourceType.Name]
+	namespace := *resource.K8sNamespace
+	name := resource.Name + "-metrics"
+
+	hasServiceMonitors, hasPodMonitors := r.prometheusOperatorAPIs()
+	if !hasServiceMonitors && !hasPodMonitors {
+		if spec.Enabled {
+			r.log.WithField("resource_id", resource.ID).
+				Debug("Prometheus Operator not detected, skipping monitor creation")
+		}
+		return nil
+	}
+
+	// Fall back to whichever monitor kind the cluster serves
+	kind := spec.Kind
+	if kind == monitorKindServiceMonitor && !hasServiceMonitors {
+		kind = monitorKindPodMonitor
+	} else if kind == monitorKindPodMonitor && !hasPodMonitors {
+		kind = monitorKindServiceMonitor
+	}
+
+	// Remove monitors that are no longer wanted
+	wanted := spec.Enabled && supported
+	if hasServiceMonitors && (!wanted || kind != monitorKindServiceMonitor) {
+		r.deleteIgnoringNotFound(ctx, serviceMonitorGVR, namespace, name)
+		r.clientset.CoreV1().Services(namespace).Delete(ctx, name, metav1.DeleteOptions{})
+	}
+	if hasPodMonitors && (!wanted || kind != monitorKindPodMonitor) {
+		r.deleteIgnoringNotFound(ctx, podMonitorGVR, namespace, name)
+	}
+	if !wanted {
+		return nil
+	}
+
+	labels := map[string]interface{}{
+		"app":         resource.Name,
+		"managed-by":  "nest-controller",
+		"resource-id": fmt.Sprintf("%d", resource.ID),
+	}
+	selector := map[string]interface{}{
+		"matchLabels": map[string]interface{}{"resource-id": fmt.Sprintf("%d", resource.ID)},
+	}
+	endpoint := map[string]interface{}{
+		"port":     exporterPortName,
+		"interval": spec.Interval,
+		"path":     "/metrics",
+	}
+
+	var obj *unstructured.Unstructured
+	var gvr schema.GroupVersionResource
+
+	switch kind {
+	case monitorKindServiceMonitor:
+		if err := r.ensureMetricsService(ctx, resource, name); err != nil {
+			return err
+		}
+		gvr = serviceMonitorGVR
+		obj = &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "monitoring.coreos.com/v1",
+			"kind":       "ServiceMonitor",
+			"metadata":   map[string]interface{}{"name": name, "namespace": namespace, "labels": labels},
+			"spec": map[string]interface{}{
+				"selector":  selector,
+				"endpoints": []interface{}{endpoint},
+			},
+		}}
+	default:
+		gvr = podMonitorGVR
+		obj = &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "monitoring.coreos.com/v1",
+			"kind":       "PodMonitor",
+			"metadata":   map[string]interface{}{"name": name, "namespace": namespace, "labels": labels},
+			"spec": map[string]interface{}{
+				"selector":            selector,
+				"podMetricsEndpoints": []interface{}{endpoint},
+			},
+		}}
+	}
+
+	client := r.dynamicClient.Resource(gvr).Namespace(namespace)
+	existing, err := client.Get(ctx, name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		_, err = client.Create(ctx, obj, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	obj.SetResourceVersion(existing.GetResourceVersion())
+	_, err = client.Update(ctx, obj, metav1.UpdateOptions{})
+	return err
+}
+
+// ensureMetricsService creates the Service a ServiceMonitor selects
+func (r *Reconciler) ensureMetricsService(ctx context.Context, resource *models.Resource, name string) error {
+	namespace := *resource.K8sNamespace
+
+	_, err := r.clientset.CoreV1().Services(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err == nil {
+		return nil
+	}
+	if !errors.IsNotFound(err) {
+		return err
+	}
+
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels: map[string]string{
+				"app":         resource.Name,
+				"managed-by":  "nest-controller",
+				"resource-id": fmt.Sprintf("%d", resource.ID),
+			},
+		},
+		Spec: corev1.ServiceSpec{
+			ClusterIP: corev1.ClusterIPNone,
+			Selector:  map[string]string{"app": resource.Name},
+			Ports: []corev1.ServicePort{
+				{Name: exporterPortName, Port: 9100, TargetPort: intstr.FromString(exporterPortName)},
+			},
+		},
+	}
+
+	r.applyIPFamilies(&svc.Spec)
+
+	_, err = r.clientset.CoreV1().Services(namespace).Create(ctx, svc, metav1.CreateOptions{})
+	return err
+}
+
+// prometheusOperatorAPIs reports which Prometheus Operator monitor kinds are served
+func (r *Reconciler) prometheusOperatorAPIs() (serviceMonitors, podMonitors bool) {
+	list, err := r.clientset.Discovery().ServerResourcesForGroupVersion("monitoring.coreos.com/v1")
+	if err != nil {
+		return false, false
+	}
+
+	for _, res := range list.APIResources {
+		switch res.Name {
+		case serviceMonitorGVR.Resource:
+			serviceMonitors = true
+		case podMonitorGVR.Resource:
+			podMonitors = true
+		}
+	}
+	return serviceMonitors, podMonitors
+}
+
+// deleteIgnoringNotFound deletes a namespaced custom object if it exists
+func (r *Reconciler) deleteIgnoringNotFound(ctx context.Context, gvr schema.GroupVersionResource, namespace, name string) {
+	err := r.dynamicClient.Resource(gvr).Namespace(namespace).Delete(ctx, name, metav1.DeleteOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		r.log.WithError(err).WithField("name", name).Warn("Failed to delete monitor")
+	}
+}
+
+// removeMonitoring deletes the monitors, metrics Service, and exporter Secret
+// created for a resource
+func (r *Reconciler) removeMonitoring(ctx context.Context, resource *models.Resource) {
+	if resource.K8sNamespace == nil {
+		return
+	}
+	namespace := *resource.K8sNamespace
+	name := resource.Name + "-metrics"
+
+	if r.dynamicClient != nil {
+		hasServiceMonitors, hasPodMonitors := r.prometheusOperatorAPIs()
+		if hasServiceMonitors {
+			r.deleteIgnoringNotFound(ctx, serviceMonitorGVR, namespace, name)
+		}
+		if hasPodMonitors {
+			r.deleteIgnoringNotFound(ctx, podMonitorGVR, namespace, name)
+		}
+	}
+
+	if err := r.clientset.CoreV1().Services(namespace).Delete(ctx, name, metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+		r.log.WithError(err).WithField("name", name).Warn("Failed to delete metrics service")
+	}
+	secret := exporterSecretName(resource)
+	if err := r.clientset.CoreV1().Secrets(namespace).Delete(ctx, secret, metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+		r.log.WithError(err).WithField("name", secret).Warn("Failed to delete exporter secret")
+	}
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
@@ -0,0 +1,98 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	"github.com/penguintechinc/nest/services/k8s-controller/pkg/models"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// Operation statuses shared with the API's operations table
+const (
+	operationPending   = "pending"
+	operationRunning   = "running"
+	operationSucceeded = "succeeded"
+	operationFailed    = "failed"
+)
+
+// startOperation links the oldest pending operation on the job's resource to
+// the provisioning job carrying it out, and marks it running
+func (r *Reconciler) startOperation(job *models.ProvisioningJob) {
+	if job.ID == 0 {
+		return
+	}
+	oldest := r.db.Model(&models.Operation{}).Select("id").
+		Where("resource_id = ? AND status = ? AND deleted_at IS NULL", job.ResourceID, operationPending).
+		Order("id").Limit(1)
+	if err := r.db.Model(&models.Operation{}).Where("id = (?)", oldest).Updates(map[string]interface{}{
+		"status":              operationRunning,
+		"provisioning_job_id": job.ID,
+		"started_at":          job.StartedAt,
+	}).Error; err != nil {
+		r.log.WithError(err).WithField("job_id", job.ID).Warn("Failed to link operation to provisioning job")
+	}
+}
+
+// finishOperation records the outcome of the provisioning job on the
+// operation it carries out, and releases the operation's resource lock
+func (r *Reconciler) finishOperation(jobID uint, status, message string) {
+	if err := r.db.Model(&models.Operation{}).
+		Where("provisioning_job_id = ? AND status = ?", jobID, operationRunning).
+		Updates(map[string]interface{}{
+			"status":       status,
+			"message":      message,
+			"completed_at": time.Now().UTC(),
+		}).Error; err != nil {
+		r.log.WithError(err).WithField("job_id", jobID).Warn("Failed to record operation outcome")
+		return
+	}
+	finished := r.db.Model(&models.Operation{}).Select("id").Where("provisioning_job_id = ?", jobID)
+	releaseOperationLocks(r.db.Where("operation_id IN (?)", finished), r.log.WithField("job_id", jobID))
+}
+
+// pendingOperations lists the operations on a resource waiting for the
+// reconcile about to run
+func (c *Controller) pendingOperations(ctx context.Context, resourceID uint) []uint {
+	var ids []uint
+	if err := c.db.WithContext(ctx).Model(&models.Operation{}).
+		Where("resource_id = ? AND status = ? AND deleted_at IS NULL", resourceID, operationPending).
+		Pluck("id", &ids).Error; err != nil {
+		c.log.WithError(err).WithField("resource_id", resourceID).Warn("Failed to load pending operations")
+	}
+	return ids
+}
+
+// settleOperations finishes the operations that were pending when a
+// reconcile started and that it carried out without a provisioning job,
+// such as a restore or a resize to the size the resource already had
+func (c *Controller) settleOperations(ctx context.Context, ids []uint, reconcileErr error) {
+	if len(ids) == 0 {
+		return
+	}
+	updates := map[string]interface{}{
+		"status":       operationSucceeded,
+		"completed_at": time.Now().UTC(),
+	}
+	if reconcileErr != nil {
+		updates["status"] = operationFailed
+		updates["message"] = c.config.Redactor.String(reconcileErr.Error())
+	}
+	if err := c.db.WithContext(ctx).Model(&models.Operation{}).
+		Where("id IN ? AND status = ?", ids, operationPending).
+		Updates(updates).Error; err != nil {
+		c.log.WithError(err).WithField("operations", ids).Warn("Failed to settle operations")
+		return
+	}
+	releaseOperationLocks(c.db.WithContext(ctx).Where("operation_id IN ?", ids), c.log.WithField("operations", ids))
+}
+
+// releaseOperationLocks deletes the resource locks query selects, those of
+// finished operations, so the next operation on their resources can start.
+// A lock that can't be released lapses when it expires.
+func releaseOperationLocks(query *gorm.DB, log *logrus.Entry) {
+	if err := query.Delete(&models.ResourceLock{}).Error; err != nil {
+		log.WithError(err).Warn("Failed to release resource locks")
+	}
+}
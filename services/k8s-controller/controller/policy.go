@@ -0,0 +1,128 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/penguintechinc/nest/services/k8s-controller/pkg/models"
+	"github.com/penguintechinc/nest/services/k8s-controller/pkg/policy"
+	"github.com/sirupsen/logrus"
+	appsv1 "k8s.io/api/apps/v1"
+)
+
+// enforcePolicies evaluates the enabled Rego policies against a resource and
+// the StatefulSet generated for it, recording the violations on the resource
+// as drift from the policies. A violation of a blocking policy fails the
+// reconcile, so the spec isn't applied until the resource or the policy
+// changes. While OPA can't be reached policies aren't enforced, so an
+// outage of OPA doesn't stop reconciling.
+func (r *Reconciler) enforcePolicies(ctx context.Context, resource *models.Resource, resourceType models.ResourceType,
+	sts *appsv1.StatefulSet, log *logrus.Entry) error {
+	if r.policies == nil {
+		return nil
+	}
+
+	violations, err := r.evaluatePolicies(ctx, resource, resourceType, sts)
+	if err != nil {
+		log.WithError(err).Warn("Failed to evaluate policies")
+		return nil
+	}
+
+	if !reflect.DeepEqual(violations, resource.PolicyViolations) {
+		if err := r.db.WithContext(ctx).Model(&models.Resource{}).Where("id = ?", resource.ID).
+			UpdateColumn("policy_violations", violations).Error; err != nil {
+			return fmt.Errorf("failed to record policy violations: %w", err)
+		}
+		resource.PolicyViolations = violations
+	}
+
+	var blocking []string
+	for _, v := range violations {
+		if v.Severity == policy.SeverityBlock {
+			blocking = append(blocking, fmt.Sprintf("%s: %s", v.Policy, v.Message))
+		}
+	}
+	if len(blocking) > 0 {
+		return fmt.Errorf("blocked by policy: %s", strings.Join(blocking, "; "))
+	}
+	if len(violations) > 0 {
+		log.WithField("violations", len(violations)).Warn("Resource violates warning policies")
+	}
+	return nil
+}
+
+// evaluatePolicies returns the violations of the enabled policies. A policy
+// OPA has lost, such as after a restart, is loaded again.
+func (r *Reconciler) evaluatePolicies(ctx context.Context, resource *models.Resource, resourceType models.ResourceType,
+	sts *appsv1.StatefulSet) (models.PolicyViolations, error) {
+	var policies []models.Policy
+	if err := r.db.WithContext(ctx).Where("enabled = ? AND deleted_at IS NULL", true).Order("name").
+		Find(&policies).Error; err != nil {
+		return nil, err
+	}
+	if len(policies) == 0 {
+		return nil, nil
+	}
+
+	input := policyInput(resource, resourceType, sts)
+	results, err := r.policies.Evaluate(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	reloaded := false
+	for _, p := range policies {
+		if _, ok := results[p.Name]; ok {
+			continue
+		}
+		if err := r.policies.Put(ctx, p.Name, p.Rego); err != nil {
+			return nil, fmt.Errorf("loading policy %s: %w", p.Name, err)
+		}
+		reloaded = true
+	}
+	if reloaded {
+		if results, err = r.policies.Evaluate(ctx, input); err != nil {
+			return nil, err
+		}
+	}
+
+	var violations models.PolicyViolations
+	for _, p := range policies {
+		for _, msg := range results[p.Name] {
+			violations = append(violations, models.PolicyViolation{Policy: p.Name, Severity: p.Severity, Message: msg})
+		}
+	}
+	return violations, nil
+}
+
+// policyInput builds the input document policies are evaluated against, the
+// same document the API evaluates them against, with the images of every
+// container of the StatefulSet
+func policyInput(resource *models.Resource, resourceType models.ResourceType, sts *appsv1.StatefulSet) map[string]interface{} {
+	images := []string{}
+	for _, c := range sts.Spec.Template.Spec.InitContainers {
+		images = append(images, c.Image)
+	}
+	for _, c := range sts.Spec.Template.Spec.Containers {
+		images = append(images, c.Image)
+	}
+
+	return map[string]interface{}{
+		"operation": "reconcile",
+		"resource": map[string]interface{}{
+			"id":                  resource.ID,
+			"name":                resource.Name,
+			"engine":              resourceType.Name,
+			"team_id":             resource.TeamID,
+			"environment":         resource.Environment,
+			"lifecycle_mode":      resource.LifecycleMode,
+			"size_class":          resource.SizeClass,
+			"tls_enabled":         resource.TLSEnabled,
+			"deletion_protection": resource.DeletionProtection,
+			"can_backup":          resource.CanBackup,
+			"config":              resource.Config,
+		},
+		"images": images,
+	}
+}
@@ -0,0 +1,275 @@
+package controller
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/penguintechinc/nest/services/k8s-controller/pkg/config"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/time/rate"
+)
+
+// workItem is a resource waiting for a worker
+type workItem struct {
+	resourceID uint
+	teamID     uint
+	class      string
+	queuedAt   time.Time
+	// requested marks reconciles requested through the API, which run
+	// despite backoff and are handed back when draining
+	requested bool
+}
+
+// queueClass holds the resources of a priority class waiting for a worker
+type queueClass struct {
+	name        string
+	concurrency int
+	limiter     *rate.Limiter
+	active      int
+	items       []workItem
+}
+
+// priorityQueue is the work queue of the reconcile workers. Workers take
+// resources of the highest priority class first, skipping classes at their
+// concurrency or rate limit, so lower classes only run when higher ones are
+// idle or capped. Within a class, workers share out between teams: they
+// take the resource of the team with the fewest reconciles in flight, and
+// skip teams at teamMax. A resource is queued at most once.
+type priorityQueue struct {
+	mu      sync.Mutex
+	classes []*queueClass
+	byName  map[string]*queueClass
+	queued  map[uint]bool
+	wake    chan struct{}
+
+	teamMax  int
+	teams    map[uint]int
+	wait     *prometheus.HistogramVec
+	inFlight *prometheus.GaugeVec
+}
+
+// newPriorityQueue creates a work queue for the priority classes, highest
+// first, running at most teamMax reconciles of a team at once, or any
+// number when 0
+func newPriorityQueue(classes []config.PriorityClass, teamMax int) *priorityQueue {
+	q := &priorityQueue{
+		byName:  make(map[string]*queueClass, len(classes)),
+		queued:  map[uint]bool{},
+		wake:    make(chan struct{}, 1),
+		teamMax: teamMax,
+		teams:   map[uint]int{},
+		wait: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "nest_controller_queue_wait_seconds",
+			Help:    "Seconds resources waited in the work queue for a worker",
+			Buckets: []float64{0.1, 0.5, 1, 5, 15, 30, 60, 120, 300, 600},
+		}, []string{"team_id", "priority_class"}),
+		inFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "nest_controller_team_reconciles_in_flight",
+			Help: "Reconciles of each team running at once",
+		}, []string{"team_id"}),
+	}
+	for _, class := range classes {
+		qc := &queueClass{name: class.Name, concurrency: class.Concurrency}
+		if class.RatePerMinute > 0 {
+			qc.limiter = rate.NewLimiter(rate.Limit(float64(class.RatePerMinute)/60), 1)
+		}
+		q.classes = append(q.classes, qc)
+		q.byName[class.Name] = qc
+	}
+	return q
+}
+
+// Push queues a resource, reporting false when it is already queued. A
+// requested reconcile upgrades a scheduled one already queued.
+func (q *priorityQueue) Push(item workItem) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	class, ok := q.byName[item.class]
+	if !ok {
+		class = q.classes[len(q.classes)-1]
+		item.class = class.name
+	}
+	if q.queued[item.resourceID] {
+		if item.requested {
+			q.markRequested(item.resourceID)
+		}
+		return false
+	}
+	q.queued[item.resourceID] = true
+	item.queuedAt = time.Now()
+	class.items = append(class.items, item)
+	q.signal()
+	return true
+}
+
+// markRequested marks a queued resource requested. It is called with mu
+// held.
+func (q *priorityQueue) markRequested(resourceID uint) {
+	for _, class := range q.classes {
+		for i := range class.items {
+			if class.items[i].resourceID == resourceID {
+				class.items[i].requested = true
+				return
+			}
+		}
+	}
+}
+
+// Pop waits for the next resource a worker may take, returning it with a
+// func to call once its reconcile is done. It returns false once ctx is
+// done or stop is closed.
+func (q *priorityQueue) Pop(ctx context.Context, stop <-chan struct{}) (workItem, func(), bool) {
+	for {
+		item, release, wait, ok := q.next()
+		if ok {
+			return item, release, true
+		}
+
+		var timer *time.Timer
+		var expired <-chan time.Time
+		if wait > 0 {
+			timer = time.NewTimer(wait)
+			expired = timer.C
+		}
+		select {
+		case <-ctx.Done():
+			ok = false
+		case <-stop:
+			ok = false
+		case <-q.wake:
+			ok = true
+		case <-expired:
+			ok = true
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if !ok {
+			return workItem{}, nil, false
+		}
+	}
+}
+
+// next takes a resource of the highest class under its limits, from the
+// team with the fewest reconciles in flight. When none can be taken it
+// returns how long until a rate limit allows one, or 0 to wait for a push
+// or release.
+func (q *priorityQueue) next() (workItem, func(), time.Duration, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var wait time.Duration
+	for _, class := range q.classes {
+		if len(class.items) == 0 || (class.concurrency > 0 && class.active >= class.concurrency) {
+			continue
+		}
+		i := q.pick(class)
+		if i < 0 {
+			continue
+		}
+		if class.limiter != nil && !class.limiter.Allow() {
+			reservation := class.limiter.Reserve()
+			delay := reservation.Delay()
+			reservation.Cancel()
+			if wait == 0 || delay < wait {
+				wait = delay
+			}
+			continue
+		}
+
+		item := class.items[i]
+		class.items = append(class.items[:i], class.items[i+1:]...)
+		class.active++
+		delete(q.queued, item.resourceID)
+		team := strconv.FormatUint(uint64(item.teamID), 10)
+		q.teams[item.teamID]++
+		q.inFlight.WithLabelValues(team).Set(float64(q.teams[item.teamID]))
+		q.wait.WithLabelValues(team, class.name).Observe(time.Since(item.queuedAt).Seconds())
+		// Pass the wake-up on, in case more resources can be taken
+		q.signal()
+		release := func() {
+			q.mu.Lock()
+			class.active--
+			if q.teams[item.teamID]--; q.teams[item.teamID] == 0 {
+				delete(q.teams, item.teamID)
+				q.inFlight.DeleteLabelValues(team)
+			} else {
+				q.inFlight.WithLabelValues(team).Set(float64(q.teams[item.teamID]))
+			}
+			q.signal()
+			q.mu.Unlock()
+		}
+		return item, release, 0, true
+	}
+	return workItem{}, nil, wait, false
+}
+
+// pick returns the index of the class's resource to take next: the first
+// of the team with the fewest reconciles in flight, skipping teams at
+// teamMax, or -1 when every team queued is at it. It is called with mu
+// held.
+func (q *priorityQueue) pick(class *queueClass) int {
+	picked, fewest := -1, 0
+	for i, item := range class.items {
+		active := q.teams[item.teamID]
+		if q.teamMax > 0 && active >= q.teamMax {
+			continue
+		}
+		if picked < 0 || active < fewest {
+			picked, fewest = i, active
+			if active == 0 {
+				break
+			}
+		}
+	}
+	return picked
+}
+
+// signal wakes a waiting worker. It is called with mu held.
+func (q *priorityQueue) signal() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Flush removes and returns every queued resource
+func (q *priorityQueue) Flush() []workItem {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var items []workItem
+	for _, class := range q.classes {
+		items = append(items, class.items...)
+		class.items = nil
+	}
+	q.queued = map[uint]bool{}
+	return items
+}
+
+// Collectors returns the queue's Prometheus metrics: how long resources wait
+// for a worker, and each team's reconciles in flight
+func (q *priorityQueue) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{q.wait, q.inFlight}
+}
+
+// Depths returns the number of queued resources of each class
+func (q *priorityQueue) Depths() map[string]int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	depths := make(map[string]int, len(q.classes))
+	for _, class := range q.classes {
+		depths[class.name] = len(class.items)
+	}
+	return depths
+}
+
+// Collectors returns the controller's work queue and watcher metrics, for
+// registering with the metrics registry
+func (c *Controller) Collectors() []prometheus.Collector {
+	return append(c.queue.Collectors(), c.watcher.Collectors()...)
+}
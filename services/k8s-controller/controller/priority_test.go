@@ -0,0 +1,94 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/penguintechinc/nest/services/k8s-controller/pkg/config"
+)
+
+func TestPriorityQueueTakesHigherClassesFirst(t *testing.T) {
+	q := newPriorityQueue([]config.PriorityClass{{Name: "critical"}, {Name: "sandbox"}}, 0)
+	q.Push(workItem{resourceID: 1, class: "sandbox"})
+	q.Push(workItem{resourceID: 2, class: "critical"})
+
+	item, release, ok := q.Pop(context.Background(), nil)
+	if !ok || item.resourceID != 2 {
+		t.Fatalf("Expected the critical resource first, got %+v", item)
+	}
+	release()
+	item, release, ok = q.Pop(context.Background(), nil)
+	if !ok || item.resourceID != 1 {
+		t.Fatalf("Expected the sandbox resource next, got %+v", item)
+	}
+	release()
+}
+
+func TestPriorityQueueSkipsClassesAtConcurrency(t *testing.T) {
+	q := newPriorityQueue([]config.PriorityClass{{Name: "critical", Concurrency: 1}, {Name: "sandbox"}}, 0)
+	q.Push(workItem{resourceID: 1, class: "critical"})
+	q.Push(workItem{resourceID: 2, class: "critical"})
+	q.Push(workItem{resourceID: 3, class: "sandbox"})
+
+	_, release, _ := q.Pop(context.Background(), nil)
+	item, _, ok := q.Pop(context.Background(), nil)
+	if !ok || item.resourceID != 3 {
+		t.Fatalf("Expected the sandbox resource while critical is at its limit, got %+v", item)
+	}
+	release()
+	item, _, ok = q.Pop(context.Background(), nil)
+	if !ok || item.resourceID != 2 {
+		t.Fatalf("Expected the second critical resource once released, got %+v", item)
+	}
+}
+
+func TestPriorityQueueSharesOutBetweenTeams(t *testing.T) {
+	q := newPriorityQueue([]config.PriorityClass{{Name: "standard"}}, 2)
+	q.Push(workItem{resourceID: 1, teamID: 1, class: "standard"})
+	q.Push(workItem{resourceID: 2, teamID: 1, class: "standard"})
+	q.Push(workItem{resourceID: 3, teamID: 1, class: "standard"})
+	q.Push(workItem{resourceID: 4, teamID: 2, class: "standard"})
+
+	var taken []uint
+	for i := 0; i < 3; i++ {
+		item, _, ok := q.Pop(context.Background(), nil)
+		if !ok {
+			t.Fatal("Expected a resource")
+		}
+		taken = append(taken, item.resourceID)
+	}
+	if taken[0] != 1 || taken[1] != 4 || taken[2] != 2 {
+		t.Errorf("Expected the teams to take turns, got %v", taken)
+	}
+
+	stop := make(chan struct{})
+	close(stop)
+	if item, _, ok := q.Pop(context.Background(), stop); ok {
+		t.Errorf("Expected team 1 to be held at its limit, got %+v", item)
+	}
+}
+
+func TestPriorityQueueDeduplicates(t *testing.T) {
+	q := newPriorityQueue([]config.PriorityClass{{Name: "standard"}}, 0)
+	if !q.Push(workItem{resourceID: 1, class: "standard"}) {
+		t.Fatal("Expected the first push to queue the resource")
+	}
+	if q.Push(workItem{resourceID: 1, class: "standard", requested: true}) {
+		t.Error("Expected a queued resource not to be queued again")
+	}
+
+	items := q.Flush()
+	if len(items) != 1 || !items[0].requested {
+		t.Errorf("Expected one resource upgraded to requested, got %+v", items)
+	}
+}
+
+func TestPriorityQueuePopStops(t *testing.T) {
+	q := newPriorityQueue([]config.PriorityClass{{Name: "standard"}}, 0)
+	stop := make(chan struct{})
+	close(stop)
+
+	if _, _, ok := q.Pop(context.Background(), stop); ok {
+		t.Error("Expected Pop to return once stopped")
+	}
+}
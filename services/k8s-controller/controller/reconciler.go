@@ -6,29 +6,54 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/penguintechinc/nest/services/k8s-controller/pkg/config"
 	"github.com/penguintechinc/nest/services/k8s-controller/pkg/models"
+	"github.com/penguintechinc/nest/services/k8s-controller/pkg/policy"
 	"github.com/sirupsen/logrus"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/record"
 	"gorm.io/gorm"
 )
 
 // Reconciler handles reconciliation of resources
 type Reconciler struct {
-	db        *gorm.DB
-	clientset *kubernetes.Clientset
-	log       *logrus.Entry
+	db            *gorm.DB
+	clientset     kubernetes.Interface
+	dynamicClient dynamic.Interface
+	config        *config.Config
+	policies      *policy.Client
+	jobs          *runningJobs
+	events        record.EventBroadcaster
+	recorder      record.EventRecorder
+	log           *logrus.Entry
 }
 
 // NewReconciler creates a new reconciler instance
-func NewReconciler(db *gorm.DB, clientset *kubernetes.Clientset) *Reconciler {
-	return &Reconciler{
-		db:        db,
-		clientset: clientset,
-		log:       logrus.WithField("component", "reconciler"),
+func NewReconciler(db *gorm.DB, clientset kubernetes.Interface, dynamicClient dynamic.Interface, cfg *config.Config) *Reconciler {
+	r := &Reconciler{
+		db:            db,
+		clientset:     clientset,
+		dynamicClient: dynamicClient,
+		config:        cfg,
+		jobs:          newRunningJobs(),
+		log:           logrus.WithField("component", "reconciler"),
+	}
+	r.events, r.recorder = newEventBroadcaster(clientset)
+	if cfg.OPAURL != "" {
+		r.policies = policy.NewClient(cfg.OPAURL)
+	}
+	return r
+}
+
+// Stop stops recording Kubernetes Events
+func (r *Reconciler) Stop() {
+	if r.events != nil {
+		r.events.Shutdown()
 	}
 }
 
@@ -49,11 +74,21 @@ func (r *Reconciler) ReconcileResource(ctx context.Context, resource *models.Res
 		return nil
 	}
 
+	// Resources on a Docker host are provisioned there rather than in the
+	// cluster
+	if resource.DockerHostID != nil {
+		return r.reconcileDocker(ctx, resource, log)
+	}
+
 	// Handle deleted resources
 	if resource.DeletedAt != nil {
 		return r.reconcileDelete(ctx, resource, log)
 	}
 
+	if err := r.ensureFinalizer(ctx, resource); err != nil {
+		return err
+	}
+
 	// Get resource type to determine how to provision
 	var resourceType models.ResourceType
 	if err := r.db.First(&resourceType, resource.ResourceTypeID).Error; err != nil {
@@ -72,7 +107,11 @@ func (r *Reconciler) ReconcileResource(ctx context.Context, resource *models.Res
 	}
 
 	// Reconcile existing resource
-	return r.reconcileUpdate(ctx, resource, resourceType, currentState, log)
+	if err := r.reconcileUpdate(ctx, resource, resourceType, currentState, log); err != nil {
+		r.event(currentState, corev1.EventTypeWarning, eventReasonReconcileFailed, "Failed to reconcile: %v", err)
+		return err
+	}
+	return nil
 }
 
 // reconcileCreate creates a new resource in Kubernetes
@@ -85,19 +124,13 @@ func (r *Reconciler) reconcileCreate(ctx context.Context, resource *models.Resou
 		return err
 	}
 
-	// Create provisioning job
-	job := &models.ProvisioningJob{
-		ResourceID: resource.ID,
-		JobType:    "create",
-		Status:     "running",
-		StartedAt:  timePtr(time.Now()),
-	}
-	if err := r.db.Create(job).Error; err != nil {
-		log.WithError(err).Error("Failed to create provisioning job")
-	}
+	// Create provisioning job, which stops the provisioning when cancelled
+	// or timed out
+	job, ctx, done := r.beginJob(ctx, resource.ID, "create", log)
+	defer done()
 
 	// Create StatefulSet based on resource type
-	sts, err := r.buildStatefulSet(resource, resourceType)
+	sts, err := r.buildStatefulSet(ctx, resource, resourceType)
 	if err != nil {
 		r.failJob(job.ID, fmt.Sprintf("Failed to build StatefulSet: %v", err))
 		return r.updateResourceStatus(resource.ID, "error", map[string]interface{}{
@@ -111,17 +144,69 @@ func (r *Reconciler) reconcileCreate(ctx context.Context, resource *models.Resou
 		return fmt.Errorf("failed to ensure namespace: %w", err)
 	}
 
+	// Render engine tuning before the pods first start
+	if err := r.reconcileTuning(ctx, resource, resourceType, false); err != nil {
+		r.failJob(job.ID, fmt.Sprintf("Failed to reconcile tuning: %v", err))
+		return err
+	}
+
+	// Pull images through the configured registry mirror
+	if err := r.applyRegistry(ctx, resource, sts); err != nil {
+		r.failJob(job.ID, fmt.Sprintf("Failed to apply image registry: %v", err))
+		return fmt.Errorf("failed to apply image registry: %w", err)
+	}
+
+	// Don't provision a spec a blocking policy rejects
+	if err := r.enforcePolicies(ctx, resource, resourceType, sts, log); err != nil {
+		r.failJob(job.ID, err.Error())
+		r.event(sts, corev1.EventTypeWarning, eventReasonPolicyViolation, "Not created: %v", err)
+		return r.updateResourceStatus(resource.ID, "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	// Ensure exporter credentials exist before the sidecar starts
+	if err := r.ensureExporterSecret(ctx, resource, resourceType); err != nil {
+		r.failJob(job.ID, fmt.Sprintf("Failed to ensure exporter secret: %v", err))
+		return fmt.Errorf("failed to ensure exporter secret: %w", err)
+	}
+
+	// Record what the pods read so later changes roll them
+	checksum, err := r.configChecksum(ctx, resource, sts)
+	if err != nil {
+		r.failJob(job.ID, fmt.Sprintf("Failed to compute config checksum: %v", err))
+		return err
+	}
+	if sts.Spec.Template.Annotations == nil {
+		sts.Spec.Template.Annotations = map[string]string{}
+	}
+	sts.Spec.Template.Annotations[configChecksumAnnotation] = checksum
+
 	// Create the StatefulSet
 	created, err := r.clientset.AppsV1().StatefulSets(*resource.K8sNamespace).Create(
 		ctx, sts, metav1.CreateOptions{})
 	if err != nil {
 		r.failJob(job.ID, fmt.Sprintf("Failed to create StatefulSet: %v", err))
+		r.event(sts, corev1.EventTypeWarning, eventReasonCreateFailed, "Failed to create StatefulSet: %v", err)
 		return r.updateResourceStatus(resource.ID, "error", map[string]interface{}{
 			"error": err.Error(),
 		})
 	}
 
 	log.WithField("statefulset", created.Name).Info("StatefulSet created")
+	r.event(created, corev1.EventTypeNormal, eventReasonCreated, "Provisioned %s resource %s", resourceType.Name, resource.Name)
+
+	if err := r.recordSecurityFindings(ctx, resource, created); err != nil {
+		log.WithError(err).Warn("Failed to record security findings")
+	}
+
+	if err := r.reconcileMonitors(ctx, resource, resourceType); err != nil {
+		log.WithError(err).Warn("Failed to reconcile Prometheus monitors")
+	}
+
+	if _, err := r.ensureService(ctx, resource, resourceType); err != nil {
+		log.WithError(err).Warn("Failed to reconcile service")
+	}
 
 	// Update resource with k8s information
 	updates := map[string]interface{}{
@@ -151,17 +236,30 @@ func (r *Reconciler) reconcileUpdate(ctx context.Context, resource *models.Resou
 	log.Debug("Updating resource in Kubernetes")
 
 	// Check if update is needed
-	desiredState, err := r.buildStatefulSet(resource, resourceType)
+	desiredState, err := r.buildStatefulSet(ctx, resource, resourceType)
 	if err != nil {
 		return fmt.Errorf("failed to build desired state: %w", err)
 	}
+	if err := r.applyRegistry(ctx, resource, desiredState); err != nil {
+		return fmt.Errorf("failed to apply image registry: %w", err)
+	}
+	if err := r.enforcePolicies(ctx, resource, resourceType, desiredState, log); err != nil {
+		return err
+	}
+
+	// Keep exporter credentials in step with the resource's
+	if err := r.ensureExporterSecret(ctx, resource, resourceType); err != nil {
+		return fmt.Errorf("failed to ensure exporter secret: %w", err)
+	}
 
 	needsUpdate := false
+	var changes []string
 
 	// Check replicas
 	if desiredState.Spec.Replicas != nil && currentState.Spec.Replicas != nil {
 		if *desiredState.Spec.Replicas != *currentState.Spec.Replicas {
 			needsUpdate = true
+			changes = append(changes, fmt.Sprintf("replicas %d -> %d", *currentState.Spec.Replicas, *desiredState.Spec.Replicas))
 			log.WithFields(logrus.Fields{
 				"current": *currentState.Spec.Replicas,
 				"desired": *desiredState.Spec.Replicas,
@@ -169,21 +267,150 @@ func (r *Reconciler) reconcileUpdate(ctx context.Context, resource *models.Resou
 		}
 	}
 
+	// Check engine container requests, changed by resizing to another size
+	// class. This runs before the other checks replace the containers.
+	resized := resourcesDiffer(desiredState, currentState, resourceType)
+	if resized {
+		needsUpdate = true
+		log.WithField("size_class", resource.SizeClass).Info("Resource requests change")
+		currentState.Spec.Template.Spec.Containers = desiredState.Spec.Template.Spec.Containers
+	}
+
+	// Check exporter sidecar
+	if hasContainer(desiredState, exporterContainerName) != hasContainer(currentState, exporterContainerName) {
+		needsUpdate = true
+		log.WithField("monitoring", hasContainer(desiredState, exporterContainerName)).Info("Exporter sidecar change")
+		changes = append(changes, "exporter sidecar")
+		currentState.Spec.Template.Spec.Containers = desiredState.Spec.Template.Spec.Containers
+	}
+
+	// Reload tuning parameters live; restart parameters roll the pods
+	if err := r.reconcileTuning(ctx, resource, resourceType, true); err != nil {
+		return err
+	}
+	if tuningDiffers(desiredState, currentState) {
+		needsUpdate = true
+		log.Info("Tuning restart parameter change")
+		changes = append(changes, "tuning parameters")
+		if currentState.Spec.Template.Annotations == nil {
+			currentState.Spec.Template.Annotations = map[string]string{}
+		}
+		if hash, ok := desiredState.Spec.Template.Annotations[tuningRestartAnnotation]; ok {
+			currentState.Spec.Template.Annotations[tuningRestartAnnotation] = hash
+		} else {
+			delete(currentState.Spec.Template.Annotations, tuningRestartAnnotation)
+		}
+		currentState.Spec.Template.Spec.Containers = desiredState.Spec.Template.Spec.Containers
+		currentState.Spec.Template.Spec.Volumes = desiredState.Spec.Template.Spec.Volumes
+	}
+
+	// Check injected init containers and sidecars
+	if injectionsDiffer(desiredState, currentState) {
+		needsUpdate = true
+		log.Info("Injected container change")
+		changes = append(changes, "injected containers")
+		currentState.Spec.Template.Spec.InitContainers = desiredState.Spec.Template.Spec.InitContainers
+		currentState.Spec.Template.Spec.Containers = desiredState.Spec.Template.Spec.Containers
+	}
+
+	// Check image references and pull secrets after registry changes
+	if imagesDiffer(desiredState, currentState) {
+		needsUpdate = true
+		log.Info("Image registry change")
+		changes = append(changes, "image registry")
+		currentState.Spec.Template.Spec.Containers = desiredState.Spec.Template.Spec.Containers
+		currentState.Spec.Template.Spec.ImagePullSecrets = desiredState.Spec.Template.Spec.ImagePullSecrets
+	}
+
+	// Check pod security contexts against the hardening defaults
+	if securityDiffers(desiredState, currentState) {
+		needsUpdate = true
+		log.Info("Pod security context change")
+		changes = append(changes, "pod security context")
+		currentState.Spec.Template.Spec.SecurityContext = desiredState.Spec.Template.Spec.SecurityContext
+		currentState.Spec.Template.Spec.Containers = desiredState.Spec.Template.Spec.Containers
+		currentState.Spec.Template.Spec.Volumes = desiredState.Spec.Template.Spec.Volumes
+	}
+
+	// Roll the pods for changed Secrets and ConfigMaps, but only inside the
+	// resource's maintenance window; until then the restart is left pending
+	autoRestarts, err := r.featureEnabled(ctx, flagAutoRestarts, resource.TeamID)
+	if err != nil {
+		return err
+	}
+	checksum, err := r.configChecksum(ctx, resource, desiredState)
+	if err != nil {
+		return err
+	}
+	pendingRestart := false
+	if autoRestarts && checksum != currentState.Spec.Template.Annotations[configChecksumAnnotation] {
+		if r.inMaintenanceWindow(resource, time.Now()) {
+			needsUpdate = true
+			log.Info("Secret or config map change")
+			changes = append(changes, "secrets or config maps")
+			if currentState.Spec.Template.Annotations == nil {
+				currentState.Spec.Template.Annotations = map[string]string{}
+			}
+			currentState.Spec.Template.Annotations[configChecksumAnnotation] = checksum
+		} else {
+			pendingRestart = true
+			log.Debug("Restart for secret or config map change waits for the maintenance window")
+		}
+	}
+
+	if err := r.reconcileMonitors(ctx, resource, resourceType); err != nil {
+		log.WithError(err).Warn("Failed to reconcile Prometheus monitors")
+	}
+
 	if needsUpdate {
+		// Size class changes are tracked as scale jobs
+		var job *models.ProvisioningJob
+		jobCtx := ctx
+		if resized {
+			var done func()
+			job, jobCtx, done = r.beginJob(ctx, resource.ID, "scale", log)
+			defer done()
+		}
+
 		// Update the StatefulSet
 		currentState.Spec.Replicas = desiredState.Spec.Replicas
 		_, err := r.clientset.AppsV1().StatefulSets(*resource.K8sNamespace).Update(
-			ctx, currentState, metav1.UpdateOptions{})
+			jobCtx, currentState, metav1.UpdateOptions{})
 		if err != nil {
+			if job != nil {
+				r.failJob(job.ID, fmt.Sprintf("Failed to update StatefulSet: %v", err))
+			}
+			r.event(currentState, corev1.EventTypeWarning, eventReasonUpdateFailed, "Failed to update StatefulSet: %v", err)
 			return r.updateResourceStatus(resource.ID, "error", map[string]interface{}{
 				"error": err.Error(),
 			})
 		}
+		if job != nil {
+			r.completeJob(job.ID, fmt.Sprintf("Resized to size class %s", resource.SizeClass))
+		}
 
 		log.Info("StatefulSet updated")
+		if resized {
+			r.event(currentState, corev1.EventTypeNormal, eventReasonResized, "Resized to size class %s", resource.SizeClass)
+		}
+		if len(changes) > 0 {
+			r.event(currentState, corev1.EventTypeNormal, eventReasonUpdated, "%s", changesMessage(changes))
+		}
 		r.createAuditLog("resource.updated", "resources", resource.ID, resource.TeamID, nil)
 	}
 
+	if pendingRestart && resource.PendingRestartSince == nil {
+		r.event(currentState, corev1.EventTypeNormal, eventReasonRestartPending,
+			"Restart for a secret or config map change waits for the maintenance window")
+	}
+	if err := r.setPendingRestart(ctx, resource, pendingRestart); err != nil {
+		log.WithError(err).Warn("Failed to record pending restart")
+	}
+
+	if err := r.recordSecurityFindings(ctx, resource, currentState); err != nil {
+		log.WithError(err).Warn("Failed to record security findings")
+	}
+
 	// Update connection info from StatefulSet status
 	return r.updateConnectionInfo(ctx, resource, currentState)
 }
@@ -194,7 +421,10 @@ func (r *Reconciler) reconcileDelete(ctx context.Context, resource *models.Resou
 
 	if resource.K8sNamespace == nil || resource.K8sResourceName == nil {
 		log.Warn("Resource has no k8s information, marking as deleted")
-		return r.updateResourceStatus(resource.ID, "deleted", nil)
+		if err := r.updateResourceStatus(resource.ID, "deleted", nil); err != nil {
+			return err
+		}
+		return r.releaseFinalizer(ctx, resource)
 	}
 
 	// Delete the StatefulSet
@@ -206,10 +436,17 @@ func (r *Reconciler) reconcileDelete(ctx context.Context, resource *models.Resou
 
 	log.Info("StatefulSet deleted")
 
+	r.removeMonitoring(ctx, resource)
+	r.removeTuning(ctx, resource)
+	r.removeService(ctx, resource)
+
 	// Update resource status
 	if err := r.updateResourceStatus(resource.ID, "deleted", nil); err != nil {
 		return err
 	}
+	if err := r.releaseFinalizer(ctx, resource); err != nil {
+		return err
+	}
 
 	r.createAuditLog("resource.deleted", "resources", resource.ID, resource.TeamID, nil)
 
@@ -235,7 +472,7 @@ func (r *Reconciler) getK8sState(ctx context.Context, resource *models.Resource)
 }
 
 // buildStatefulSet creates a StatefulSet spec from a resource
-func (r *Reconciler) buildStatefulSet(resource *models.Resource, resourceType models.ResourceType) (*appsv1.StatefulSet, error) {
+func (r *Reconciler) buildStatefulSet(ctx context.Context, resource *models.Resource, resourceType models.ResourceType) (*appsv1.StatefulSet, error) {
 	// Extract replicas from config
 	replicas := int32(1)
 	if resource.Config != nil {
@@ -245,21 +482,9 @@ func (r *Reconciler) buildStatefulSet(resource *models.Resource, resourceType mo
 	}
 
 	// Build StatefulSet based on resource type
-	image := ""
-	port := int32(5432)
-
-	switch resourceType.Name {
-	case "postgresql":
-		image = "postgres:16-alpine"
-		port = 5432
-	case "mariadb":
-		image = "mariadb:11-jammy"
-		port = 3306
-	case "redis":
-		image = "redis:7-alpine"
-		port = 6379
-	default:
-		return nil, fmt.Errorf("unsupported resource type: %s", resourceType.Name)
+	image, port, err := engineImage(resourceType.Name)
+	if err != nil {
+		return nil, err
 	}
 
 	sts := &appsv1.StatefulSet{
@@ -305,9 +530,53 @@ func (r *Reconciler) buildStatefulSet(resource *models.Resource, resourceType mo
 		},
 	}
 
+	// Inject the metrics exporter sidecar when monitoring is enabled
+	if exporter := exporterContainer(resource, resourceType, port); exporter != nil {
+		sts.Spec.Template.Spec.Containers = append(sts.Spec.Template.Spec.Containers, *exporter)
+	}
+
+	// Size the engine container from its size class
+	if err := applyResources(resource, resourceType, sts); err != nil {
+		return nil, err
+	}
+
+	// Mount engine tuning rendered from Config.tuning
+	applyTuning(resource, resourceType, sts)
+
+	// Attach init containers and sidecars from Config and team policies
+	if err := r.applyInjections(ctx, resource, resourceType, sts); err != nil {
+		return nil, err
+	}
+
+	r.applySecurityContext(sts, resourceType)
+
 	return sts, nil
 }
 
+// engineImage returns the image and port of a resource type's engine
+func engineImage(typeName string) (string, int32, error) {
+	switch typeName {
+	case "postgresql":
+		return "postgres:16-alpine", 5432, nil
+	case "mariadb":
+		return "mariadb:11-jammy", 3306, nil
+	case "redis":
+		return "redis:7-alpine", 6379, nil
+	default:
+		return "", 0, fmt.Errorf("unsupported resource type: %s", typeName)
+	}
+}
+
+// hasContainer reports whether a StatefulSet's pod template has the named container
+func hasContainer(sts *appsv1.StatefulSet, name string) bool {
+	for _, c := range sts.Spec.Template.Spec.Containers {
+		if c.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
 // updateConnectionInfo updates resource connection info from k8s state
 func (r *Reconciler) updateConnectionInfo(ctx context.Context, resource *models.Resource,
 	sts *appsv1.StatefulSet) error {
@@ -320,11 +589,15 @@ func (r *Reconciler) updateConnectionInfo(ctx context.Context, resource *models.
 		return fmt.Errorf("failed to list pods: %w", err)
 	}
 
-	// Extract pod IPs and status
+	// Extract pod IPs and status. Dual-stack pods have an IP of each
+	// family, and IPv6-only pods only an IPv6 one.
 	podIPs := []string{}
 	allReady := true
 	for _, pod := range pods.Items {
-		if pod.Status.PodIP != "" {
+		for _, ip := range pod.Status.PodIPs {
+			podIPs = append(podIPs, ip.IP)
+		}
+		if len(pod.Status.PodIPs) == 0 && pod.Status.PodIP != "" {
 			podIPs = append(podIPs, pod.Status.PodIP)
 		}
 		if pod.Status.Phase != corev1.PodRunning {
@@ -340,6 +613,27 @@ func (r *Reconciler) updateConnectionInfo(ctx context.Context, resource *models.
 		"service_name":  fmt.Sprintf("%s.%s.svc.cluster.local", resource.Name, *resource.K8sNamespace),
 	}
 
+	// Record the Service's cluster IPs of each family, which the service
+	// name resolves to as A and AAAA records
+	svc, err := r.clientset.CoreV1().Services(*resource.K8sNamespace).Get(ctx, resource.Name, metav1.GetOptions{})
+	if err == nil {
+		clusterIPs := svc.Spec.ClusterIPs
+		if len(clusterIPs) == 0 && svc.Spec.ClusterIP != "" {
+			clusterIPs = []string{svc.Spec.ClusterIP}
+		}
+		ipv4, ipv6 := addressesByFamily(clusterIPs)
+		families := []string{}
+		for _, family := range svc.Spec.IPFamilies {
+			families = append(families, string(family))
+		}
+		connectionInfo["ipv4_addresses"] = ipv4
+		connectionInfo["ipv6_addresses"] = ipv6
+		connectionInfo["ip_families"] = families
+		if len(svc.Spec.Ports) > 0 {
+			connectionInfo["port"] = svc.Spec.Ports[0].Port
+		}
+	}
+
 	status := "active"
 	if !allReady || sts.Status.ReadyReplicas < sts.Status.Replicas {
 		status = "updating"
@@ -388,24 +682,30 @@ func (r *Reconciler) ensureNamespace(ctx context.Context, namespace string) erro
 
 func (r *Reconciler) completeJob(id uint, message string) {
 	now := time.Now()
+	message = r.config.Redactor.String(message)
 	r.db.Model(&models.ProvisioningJob{}).Where("id = ?", id).Updates(map[string]interface{}{
 		"status":       "completed",
 		"completed_at": &now,
 		"logs":         &message,
 	})
+	r.finishOperation(id, operationSucceeded, message)
 }
 
+// failJob records a job's failure, or its cancellation when the job was
+// cancelled before it failed
 func (r *Reconciler) failJob(id uint, message string) {
 	now := time.Now()
+	status, message := r.jobOutcome(id, r.config.Redactor.String(message))
 	r.db.Model(&models.ProvisioningJob{}).Where("id = ?", id).Updates(map[string]interface{}{
-		"status":        "failed",
+		"status":        status,
 		"completed_at":  &now,
 		"error_message": &message,
 	})
+	r.finishOperation(id, operationFailed, message)
 }
 
 func (r *Reconciler) createAuditLog(action, resourceType string, resourceID, teamID uint, details map[string]interface{}) {
-	detailsJSON := models.JSONMap(details)
+	detailsJSON := models.JSONMap(r.config.Redactor.Map(details))
 	resType := resourceType
 	resID := resourceID
 	log := &models.AuditLog{
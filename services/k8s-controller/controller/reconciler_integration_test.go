@@ -0,0 +1,147 @@
+//go:build integration
+
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/penguintechinc/nest/services/k8s-controller/pkg/models"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestReconcileCreate(t *testing.T) {
+	h := newTestHarness(t, nil)
+	resource := h.createResource(t, "cache")
+
+	h.reconcile(t, resource.ID, 30*time.Second)
+
+	if !h.namespaceExists(t) {
+		t.Errorf("Expected namespace %s to be created", h.namespace)
+	}
+	sts, err := h.controller.clientset.AppsV1().StatefulSets(h.namespace).
+		Get(context.Background(), resource.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Expected the StatefulSet to be created: %v", err)
+	}
+	if sts.Labels["resource-id"] == "" || sts.Labels["managed-by"] != "nest-controller" {
+		t.Errorf("Expected the StatefulSet to be labelled for the resource, got %v", sts.Labels)
+	}
+
+	created := h.load(t, resource.ID)
+	if created.Status != "active" {
+		t.Errorf("Expected status active, got %s", created.Status)
+	}
+	if created.K8sResourceName == nil || *created.K8sResourceName != resource.Name {
+		t.Errorf("Expected the StatefulSet name to be recorded, got %v", created.K8sResourceName)
+	}
+	if !created.Finalizers.Contains(controllerFinalizer) {
+		t.Errorf("Expected the controller finalizer, got %v", created.Finalizers)
+	}
+
+	var job models.ProvisioningJob
+	if err := h.db.Where("resource_id = ? AND job_type = ?", resource.ID, "create").First(&job).Error; err != nil {
+		t.Fatalf("Expected a create job: %v", err)
+	}
+	if job.Status != "completed" || job.CompletedAt == nil {
+		t.Errorf("Expected the create job to be completed, got %s", job.Status)
+	}
+
+	var status models.ReconcileStatus
+	if err := h.db.First(&status, "resource_id = ?", resource.ID).Error; err != nil {
+		t.Fatalf("Expected a reconcile status: %v", err)
+	}
+	if status.LastOutcome != reconcileOutcomeSuccess || status.RetryCount != 0 {
+		t.Errorf("Expected a successful reconcile, got %+v", status)
+	}
+}
+
+func TestReconcileUpdate(t *testing.T) {
+	h := newTestHarness(t, nil)
+	resource := h.createResource(t, "cache")
+	h.reconcile(t, resource.ID, 30*time.Second)
+
+	if err := h.db.Model(&models.Resource{}).Where("id = ?", resource.ID).
+		Update("config", models.JSONMap{"replicas": 3}).Error; err != nil {
+		t.Fatalf("Failed to update resource: %v", err)
+	}
+	h.reconcile(t, resource.ID, 30*time.Second)
+
+	sts, err := h.controller.clientset.AppsV1().StatefulSets(h.namespace).
+		Get(context.Background(), resource.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get StatefulSet: %v", err)
+	}
+	if sts.Spec.Replicas == nil || *sts.Spec.Replicas != 3 {
+		t.Errorf("Expected 3 replicas, got %v", sts.Spec.Replicas)
+	}
+
+	var audits int64
+	h.db.Model(&models.AuditLog{}).Where("action = ? AND resource_id = ?", "resource.updated", resource.ID).Count(&audits)
+	if audits != 1 {
+		t.Errorf("Expected 1 resource.updated audit log, got %d", audits)
+	}
+
+	// A reconcile without changes leaves the StatefulSet as it is
+	generation := sts.Generation
+	h.reconcile(t, resource.ID, 30*time.Second)
+	sts, err = h.controller.clientset.AppsV1().StatefulSets(h.namespace).
+		Get(context.Background(), resource.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get StatefulSet: %v", err)
+	}
+	if sts.Generation != generation {
+		t.Errorf("Expected an unchanged resource not to update the StatefulSet, generation %d -> %d",
+			generation, sts.Generation)
+	}
+}
+
+func TestReconcileDelete(t *testing.T) {
+	h := newTestHarness(t, nil)
+	resource := h.createResource(t, "cache")
+	h.reconcile(t, resource.ID, 30*time.Second)
+
+	now := time.Now().UTC()
+	if err := h.db.Model(&models.Resource{}).Where("id = ?", resource.ID).Updates(map[string]interface{}{
+		"deleted_at":     now,
+		"deletion_state": deletionStateDeleting,
+	}).Error; err != nil {
+		t.Fatalf("Failed to delete resource: %v", err)
+	}
+	h.reconcile(t, resource.ID, 30*time.Second)
+
+	_, err := h.controller.clientset.AppsV1().StatefulSets(h.namespace).
+		Get(context.Background(), resource.Name, metav1.GetOptions{})
+	if !errors.IsNotFound(err) {
+		t.Errorf("Expected the StatefulSet to be deleted, got %v", err)
+	}
+
+	deleted := h.load(t, resource.ID)
+	if deleted.Status != "deleted" {
+		t.Errorf("Expected status deleted, got %s", deleted.Status)
+	}
+	if deleted.Finalizers.Contains(controllerFinalizer) || deleted.DeletionState != deletionStateDeprovisioned {
+		t.Errorf("Expected the finalizer released and the resource deprovisioned, got %v and %s",
+			deleted.Finalizers, deleted.DeletionState)
+	}
+}
+
+func TestReconcileSkipsPartialLifecycle(t *testing.T) {
+	h := newTestHarness(t, nil)
+	resource := h.createResource(t, "cache")
+	if err := h.db.Model(&models.Resource{}).Where("id = ?", resource.ID).
+		Update("lifecycle_mode", "partial").Error; err != nil {
+		t.Fatalf("Failed to update resource: %v", err)
+	}
+
+	h.reconcile(t, resource.ID, 30*time.Second)
+
+	if h.namespaceExists(t) {
+		t.Errorf("Expected no namespace for a partial lifecycle resource")
+	}
+	if status := h.load(t, resource.ID).Status; status != "pending" {
+		t.Errorf("Expected status pending, got %s", status)
+	}
+}
@@ -0,0 +1,58 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestEnsureNamespaceCreates(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	r := &Reconciler{clientset: clientset}
+
+	if err := r.ensureNamespace(context.Background(), "nest-dev"); err != nil {
+		t.Fatalf("Failed to ensure namespace: %v", err)
+	}
+	ns, err := clientset.CoreV1().Namespaces().Get(context.Background(), "nest-dev", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Expected the namespace to be created: %v", err)
+	}
+	if ns.Labels["managed-by"] != "nest-controller" {
+		t.Errorf("Expected the namespace to be labelled, got %v", ns.Labels)
+	}
+}
+
+func TestEnsureNamespaceLeavesExisting(t *testing.T) {
+	existing := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "nest-dev"}}
+	clientset := fake.NewSimpleClientset(existing)
+	r := &Reconciler{clientset: clientset}
+
+	if err := r.ensureNamespace(context.Background(), "nest-dev"); err != nil {
+		t.Fatalf("Failed to ensure namespace: %v", err)
+	}
+	for _, action := range clientset.Actions() {
+		if action.GetVerb() == "create" {
+			t.Errorf("Expected no create for an existing namespace, got %v", action)
+		}
+	}
+}
+
+func TestEnsureNamespaceReturnsAPIErrors(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	clientset.PrependReactor("get", "namespaces", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(schema.GroupResource{Resource: "namespaces"}, "nest-dev", errors.New("denied"))
+	})
+	r := &Reconciler{clientset: clientset}
+
+	if err := r.ensureNamespace(context.Background(), "nest-dev"); !apierrors.IsForbidden(err) {
+		t.Errorf("Expected the forbidden error, got %v", err)
+	}
+}
@@ -0,0 +1,173 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/penguintechinc/nest/services/k8s-controller/pkg/models"
+	"gorm.io/gorm"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// pullSecretName is the dockerconfigjson Secret created in a resource's
+// namespace when its registry requires credentials
+const pullSecretName = "nest-registry-pull"
+
+// resolveRegistry returns the registry images of a resource are pulled
+// through, or nil when images are pulled from their public registries. Team
+// registries take precedence over global ones, and cluster-specific registries
+// over those for every cluster.
+func (r *Reconciler) resolveRegistry(ctx context.Context, resource *models.Resource) (*models.ImageRegistry, error) {
+	var registry models.ImageRegistry
+	err := r.db.WithContext(ctx).
+		Where("deleted_at IS NULL").
+		Where("team_id = ? OR team_id IS NULL", resource.TeamID).
+		Where("cluster = '' OR cluster = ?", r.config.ClusterName).
+		Order("team_id IS NULL, cluster = ''").
+		First(&registry).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve image registry: %w", err)
+	}
+	return &registry, nil
+}
+
+// mirrorImage rewrites an image reference to be pulled through a mirror
+// prefix, dropping the original registry host. Docker Hub official images
+// gain their implicit library/ namespace.
+func mirrorImage(image, prefix string) string {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if prefix == "" || strings.HasPrefix(image, prefix+"/") {
+		return image
+	}
+
+	path := image
+	if i := strings.Index(image, "/"); i >= 0 {
+		host := image[:i]
+		if strings.ContainsAny(host, ".:") || host == "localhost" {
+			path = image[i+1:]
+		}
+	} else {
+		path = "library/" + image
+	}
+	return prefix + "/" + path
+}
+
+// registryHost returns the host part of a mirror prefix
+func registryHost(prefix string) string {
+	if i := strings.Index(prefix, "/"); i >= 0 {
+		return prefix[:i]
+	}
+	return prefix
+}
+
+// applyRegistry rewrites the images of a StatefulSet's pod template to the
+// resource's registry and attaches its pull secret
+func (r *Reconciler) applyRegistry(ctx context.Context, resource *models.Resource, sts *appsv1.StatefulSet) error {
+	registry, err := r.resolveRegistry(ctx, resource)
+	if err != nil || registry == nil {
+		return err
+	}
+
+	spec := &sts.Spec.Template.Spec
+	for i := range spec.InitContainers {
+		spec.InitContainers[i].Image = mirrorImage(spec.InitContainers[i].Image, registry.MirrorPrefix)
+	}
+	for i := range spec.Containers {
+		spec.Containers[i].Image = mirrorImage(spec.Containers[i].Image, registry.MirrorPrefix)
+	}
+
+	if registry.Username == "" {
+		return nil
+	}
+	if err := r.ensurePullSecret(ctx, *resource.K8sNamespace, registry); err != nil {
+		return err
+	}
+	spec.ImagePullSecrets = []corev1.LocalObjectReference{{Name: pullSecretName}}
+	return nil
+}
+
+// ensurePullSecret creates or updates the registry pull secret in a namespace
+func (r *Reconciler) ensurePullSecret(ctx context.Context, namespace string, registry *models.ImageRegistry) error {
+	auth := base64.StdEncoding.EncodeToString([]byte(registry.Username + ":" + registry.Password))
+	dockerConfig, err := json.Marshal(map[string]interface{}{
+		"auths": map[string]interface{}{
+			registryHost(registry.MirrorPrefix): map[string]string{
+				"username": registry.Username,
+				"password": registry.Password,
+				"auth":     auth,
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	secrets := r.clientset.CoreV1().Secrets(namespace)
+	existing, err := secrets.Get(ctx, pullSecretName, metav1.GetOptions{})
+	if err == nil {
+		if bytes.Equal(existing.Data[corev1.DockerConfigJsonKey], dockerConfig) {
+			return nil
+		}
+		existing.Data = map[string][]byte{corev1.DockerConfigJsonKey: dockerConfig}
+		if _, err := secrets.Update(ctx, existing, metav1.UpdateOptions{}); err != nil {
+			return fmt.Errorf("failed to update pull secret: %w", err)
+		}
+		return nil
+	}
+	if !k8serrors.IsNotFound(err) {
+		return fmt.Errorf("failed to get pull secret: %w", err)
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      pullSecretName,
+			Namespace: namespace,
+			Labels: map[string]string{
+				"managed-by": "nest-controller",
+			},
+		},
+		Type: corev1.SecretTypeDockerConfigJson,
+		Data: map[string][]byte{corev1.DockerConfigJsonKey: dockerConfig},
+	}
+	if _, err := secrets.Create(ctx, secret, metav1.CreateOptions{}); err != nil && !k8serrors.IsAlreadyExists(err) {
+		return fmt.Errorf("failed to create pull secret: %w", err)
+	}
+	return nil
+}
+
+// imagesDiffer reports whether two pod templates differ in container images
+// or pull secrets
+func imagesDiffer(desired, current *appsv1.StatefulSet) bool {
+	want := desired.Spec.Template.Spec
+	have := current.Spec.Template.Spec
+	if len(want.ImagePullSecrets) != len(have.ImagePullSecrets) {
+		return true
+	}
+	for i := range want.ImagePullSecrets {
+		if want.ImagePullSecrets[i].Name != have.ImagePullSecrets[i].Name {
+			return true
+		}
+	}
+
+	images := make(map[string]string, len(have.Containers))
+	for _, c := range have.Containers {
+		images[c.Name] = c.Image
+	}
+	for _, c := range want.Containers {
+		if image, ok := images[c.Name]; ok && image != c.Image {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,213 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/penguintechinc/nest/services/k8s-controller/pkg/config"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// remoteWriteJob is the job label attached to every remote-written series
+const remoteWriteJob = "nest-k8s-controller"
+
+// RemoteWriter periodically gathers metrics from a Prometheus registry and
+// pushes them to an external Prometheus-compatible endpoint using the
+// remote-write protocol (snappy-compressed protobuf WriteRequest)
+type RemoteWriter struct {
+	url         string
+	username    string
+	password    string
+	bearerToken string
+	interval    time.Duration
+	gatherer    prometheus.Gatherer
+	client      *http.Client
+	log         *logrus.Entry
+}
+
+// remoteSeries is a single labelled sample ready for encoding
+type remoteSeries struct {
+	labels [][2]string
+	value  float64
+}
+
+// NewRemoteWriter creates a remote writer for the configured endpoint
+func NewRemoteWriter(cfg *config.Config, gatherer prometheus.Gatherer) *RemoteWriter {
+	return &RemoteWriter{
+		url:         cfg.RemoteWriteURL,
+		username:    cfg.RemoteWriteUsername,
+		password:    cfg.RemoteWritePassword,
+		bearerToken: cfg.RemoteWriteBearerToken,
+		interval:    cfg.RemoteWriteInterval,
+		gatherer:    gatherer,
+		client:      &http.Client{Timeout: 30 * time.Second},
+		log:         logrus.WithField("component", "remote-write"),
+	}
+}
+
+// Run pushes metrics on every interval until the context is cancelled
+func (w *RemoteWriter) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	w.log.WithFields(logrus.Fields{
+		"url":      w.url,
+		"interval": w.interval,
+	}).Info("Starting Prometheus remote writer")
+
+	for {
+		select {
+		case <-ctx.Done():
+			w.log.Info("Remote writer stopped")
+			return
+		case <-ticker.C:
+			if err := w.push(ctx); err != nil {
+				w.log.WithError(err).Warn("Remote write failed")
+			}
+		}
+	}
+}
+
+// push gathers the current metrics and sends them in a single WriteRequest
+func (w *RemoteWriter) push(ctx context.Context) error {
+	families, err := w.gatherer.Gather()
+	if err != nil {
+		return fmt.Errorf("failed to gather metrics: %w", err)
+	}
+
+	series := familiesToSeries(families)
+	if len(series) == 0 {
+		return nil
+	}
+
+	payload := snappy.Encode(nil, encodeWriteRequest(series, time.Now().UnixMilli()))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	if w.bearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+w.bearerToken)
+	} else if w.username != "" {
+		req.SetBasicAuth(w.username, w.password)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("remote endpoint returned %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+	}
+
+	w.log.WithField("series", len(series)).Debug("Remote write completed")
+	return nil
+}
+
+// familiesToSeries flattens gathered metric families into individual series,
+// expanding histograms and summaries the same way the text exposition does
+func familiesToSeries(families []*dto.MetricFamily) []remoteSeries {
+	var out []remoteSeries
+
+	for _, mf := range families {
+		name := mf.GetName()
+		for _, m := range mf.GetMetric() {
+			base := make([][2]string, 0, len(m.GetLabel())+1)
+			base = append(base, [2]string{"job", remoteWriteJob})
+			for _, lp := range m.GetLabel() {
+				base = append(base, [2]string{lp.GetName(), lp.GetValue()})
+			}
+
+			add := func(metricName string, value float64, extra ...[2]string) {
+				labels := make([][2]string, 0, len(base)+len(extra)+1)
+				labels = append(labels, [2]string{"__name__", metricName})
+				labels = append(labels, base...)
+				labels = append(labels, extra...)
+				sort.Slice(labels, func(i, j int) bool { return labels[i][0] < labels[j][0] })
+				out = append(out, remoteSeries{labels: labels, value: value})
+			}
+
+			switch mf.GetType() {
+			case dto.MetricType_COUNTER:
+				add(name, m.GetCounter().GetValue())
+			case dto.MetricType_GAUGE:
+				add(name, m.GetGauge().GetValue())
+			case dto.MetricType_UNTYPED:
+				add(name, m.GetUntyped().GetValue())
+			case dto.MetricType_SUMMARY:
+				s := m.GetSummary()
+				for _, q := range s.GetQuantile() {
+					add(name, q.GetValue(), [2]string{"quantile", strconv.FormatFloat(q.GetQuantile(), 'g', -1, 64)})
+				}
+				add(name+"_sum", s.GetSampleSum())
+				add(name+"_count", float64(s.GetSampleCount()))
+			case dto.MetricType_HISTOGRAM:
+				h := m.GetHistogram()
+				for _, b := range h.GetBucket() {
+					add(name+"_bucket", float64(b.GetCumulativeCount()),
+						[2]string{"le", strconv.FormatFloat(b.GetUpperBound(), 'g', -1, 64)})
+				}
+				add(name+"_bucket", float64(h.GetSampleCount()), [2]string{"le", "+Inf"})
+				add(name+"_sum", h.GetSampleSum())
+				add(name+"_count", float64(h.GetSampleCount()))
+			}
+		}
+	}
+
+	return out
+}
+
+// encodeWriteRequest encodes series as a prometheus.WriteRequest protobuf:
+//
+//	WriteRequest { repeated TimeSeries timeseries = 1; }
+//	TimeSeries   { repeated Label labels = 1; repeated Sample samples = 2; }
+//	Label        { string name = 1; string value = 2; }
+//	Sample       { double value = 1; int64 timestamp = 2; }
+func encodeWriteRequest(series []remoteSeries, timestampMs int64) []byte {
+	var buf []byte
+
+	for _, s := range series {
+		var ts []byte
+		for _, l := range s.labels {
+			var label []byte
+			label = protowire.AppendTag(label, 1, protowire.BytesType)
+			label = protowire.AppendString(label, l[0])
+			label = protowire.AppendTag(label, 2, protowire.BytesType)
+			label = protowire.AppendString(label, l[1])
+
+			ts = protowire.AppendTag(ts, 1, protowire.BytesType)
+			ts = protowire.AppendBytes(ts, label)
+		}
+
+		var sample []byte
+		sample = protowire.AppendTag(sample, 1, protowire.Fixed64Type)
+		sample = protowire.AppendFixed64(sample, math.Float64bits(s.value))
+		sample = protowire.AppendTag(sample, 2, protowire.VarintType)
+		sample = protowire.AppendVarint(sample, uint64(timestampMs))
+
+		ts = protowire.AppendTag(ts, 2, protowire.BytesType)
+		ts = protowire.AppendBytes(ts, sample)
+
+		buf = protowire.AppendTag(buf, 1, protowire.BytesType)
+		buf = protowire.AppendBytes(buf, ts)
+	}
+
+	return buf
+}
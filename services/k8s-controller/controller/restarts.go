@@ -0,0 +1,233 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/penguintechinc/nest/services/k8s-controller/pkg/models"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// configChecksumAnnotation carries a hash of the Secrets and ConfigMaps a pod
+// template reads, so changing one rolls the pods
+const configChecksumAnnotation = "nest.penguintech.io/config-checksum"
+
+// referencedObjects lists the Secrets and ConfigMaps a pod template mounts or
+// reads env vars from, sorted by name. The tuning ConfigMap is left out: its
+// reload parameters are applied live and its restart parameters have their
+// own annotation.
+func referencedObjects(resource *models.Resource, sts *appsv1.StatefulSet) (secrets, configMaps []string) {
+	seenSecrets, seenConfigMaps := map[string]bool{}, map[string]bool{}
+	addSecret := func(name string) {
+		if name != "" && !seenSecrets[name] {
+			seenSecrets[name] = true
+			secrets = append(secrets, name)
+		}
+	}
+	addConfigMap := func(name string) {
+		if name != "" && name != tuningConfigMapName(resource) && !seenConfigMaps[name] {
+			seenConfigMaps[name] = true
+			configMaps = append(configMaps, name)
+		}
+	}
+
+	spec := sts.Spec.Template.Spec
+	for _, v := range spec.Volumes {
+		if v.Secret != nil {
+			addSecret(v.Secret.SecretName)
+		}
+		if v.ConfigMap != nil {
+			addConfigMap(v.ConfigMap.Name)
+		}
+	}
+
+	containers := append(append([]corev1.Container{}, spec.InitContainers...), spec.Containers...)
+	for _, c := range containers {
+		for _, env := range c.Env {
+			if env.ValueFrom == nil {
+				continue
+			}
+			if env.ValueFrom.SecretKeyRef != nil {
+				addSecret(env.ValueFrom.SecretKeyRef.Name)
+			}
+			if env.ValueFrom.ConfigMapKeyRef != nil {
+				addConfigMap(env.ValueFrom.ConfigMapKeyRef.Name)
+			}
+		}
+		for _, from := range c.EnvFrom {
+			if from.SecretRef != nil {
+				addSecret(from.SecretRef.Name)
+			}
+			if from.ConfigMapRef != nil {
+				addConfigMap(from.ConfigMapRef.Name)
+			}
+		}
+	}
+
+	sort.Strings(secrets)
+	sort.Strings(configMaps)
+	return secrets, configMaps
+}
+
+// configChecksum hashes the data of the Secrets and ConfigMaps a pod template
+// reads. Objects that don't exist yet are hashed as absent.
+func (r *Reconciler) configChecksum(ctx context.Context, resource *models.Resource, sts *appsv1.StatefulSet) (string, error) {
+	namespace := *resource.K8sNamespace
+	secrets, configMaps := referencedObjects(resource, sts)
+
+	h := sha256.New()
+	writeData := func(kind, name string, data map[string]string) {
+		keys := make([]string, 0, len(data))
+		for k := range data {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		fmt.Fprintf(h, "%s/%s\n", kind, name)
+		for _, k := range keys {
+			fmt.Fprintf(h, "%s=%q\n", k, data[k])
+		}
+	}
+
+	for _, name := range secrets {
+		secret, err := r.clientset.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil && !errors.IsNotFound(err) {
+			return "", fmt.Errorf("failed to get secret %s: %w", name, err)
+		}
+		data := map[string]string{}
+		if err == nil {
+			for k, v := range secret.Data {
+				data[k] = string(v)
+			}
+		}
+		writeData("secret", name, data)
+	}
+	for _, name := range configMaps {
+		cm, err := r.clientset.CoreV1().ConfigMaps(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil && !errors.IsNotFound(err) {
+			return "", fmt.Errorf("failed to get config map %s: %w", name, err)
+		}
+		data := map[string]string{}
+		if err == nil {
+			data = cm.Data
+		}
+		writeData("configmap", name, data)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)[:8]), nil
+}
+
+// maintenanceWindow is a recurring UTC time range on certain weekdays, in
+// the form "sat,sun 02:00-04:00" or "* 22:00-02:00". A window that ends
+// before it starts runs past midnight into the next day.
+type maintenanceWindow struct {
+	days       map[time.Weekday]bool
+	start, end time.Duration
+}
+
+// weekdays maps the day names accepted in a maintenance window
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// parseMaintenanceWindow parses a maintenance window, matching the format the
+// API validates
+func parseMaintenanceWindow(s string) (*maintenanceWindow, error) {
+	fields := strings.Fields(s)
+	if len(fields) != 2 {
+		return nil, fmt.Errorf("maintenance window must be \"<days> HH:MM-HH:MM\"")
+	}
+
+	w := &maintenanceWindow{days: map[time.Weekday]bool{}}
+	for _, day := range strings.Split(strings.ToLower(fields[0]), ",") {
+		if day == "*" {
+			for _, d := range weekdays {
+				w.days[d] = true
+			}
+			continue
+		}
+		d, ok := weekdays[day]
+		if !ok {
+			return nil, fmt.Errorf("unknown day %q in maintenance window", day)
+		}
+		w.days[d] = true
+	}
+
+	bounds := strings.Split(fields[1], "-")
+	if len(bounds) != 2 {
+		return nil, fmt.Errorf("maintenance window must be \"<days> HH:MM-HH:MM\"")
+	}
+	var err error
+	if w.start, err = parseClock(bounds[0]); err != nil {
+		return nil, err
+	}
+	if w.end, err = parseClock(bounds[1]); err != nil {
+		return nil, err
+	}
+	if w.start == w.end {
+		return nil, fmt.Errorf("maintenance window must not be empty")
+	}
+	return w, nil
+}
+
+// parseClock parses HH:MM into an offset from midnight
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q in maintenance window", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// contains reports whether t falls inside the window
+func (w *maintenanceWindow) contains(t time.Time) bool {
+	t = t.UTC()
+	offset := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	if w.start < w.end {
+		return w.days[t.Weekday()] && offset >= w.start && offset < w.end
+	}
+	// Past midnight, the window belongs to the day it started on
+	previous := (t.Weekday() + 6) % 7
+	return (w.days[t.Weekday()] && offset >= w.start) || (w.days[previous] && offset < w.end)
+}
+
+// inMaintenanceWindow reports whether a resource's pods may be restarted at
+// t. Resources without a valid Config.maintenance_window may always be.
+func (r *Reconciler) inMaintenanceWindow(resource *models.Resource, t time.Time) bool {
+	spec, _ := resource.Config["maintenance_window"].(string)
+	if spec == "" {
+		return true
+	}
+	w, err := parseMaintenanceWindow(spec)
+	if err != nil {
+		r.log.WithError(err).WithField("resource_id", resource.ID).Warn("Ignoring invalid maintenance window")
+		return true
+	}
+	return w.contains(t)
+}
+
+// setPendingRestart records whether a restart for changed Secrets or
+// ConfigMaps is waiting on the resource's maintenance window
+func (r *Reconciler) setPendingRestart(ctx context.Context, resource *models.Resource, pending bool) error {
+	if pending == (resource.PendingRestartSince != nil) {
+		return nil
+	}
+	var since *time.Time
+	if pending {
+		since = timePtr(time.Now())
+	}
+	if err := r.db.WithContext(ctx).Model(&models.Resource{}).Where("id = ?", resource.ID).
+		UpdateColumn("pending_restart_since", since).Error; err != nil {
+		return fmt.Errorf("failed to record pending restart: %w", err)
+	}
+	resource.PendingRestartSince = since
+	return nil
+}
@@ -0,0 +1,205 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/penguintechinc/nest/services/k8s-controller/pkg/models"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// securityProfile holds what a resource type's image needs to run hardened
+type securityProfile struct {
+	runAsUser int64
+	fsGroup   int64
+	// writablePaths are mounted as emptyDir volumes when the root
+	// filesystem is read-only
+	writablePaths []string
+}
+
+// securityByType overrides the pod security defaults per resource type. The
+// database images start as root to chown their data directories unless run
+// as their own user with a writable data directory.
+var securityByType = map[string]securityProfile{
+	"postgresql": {
+		runAsUser:     70,
+		fsGroup:       70,
+		writablePaths: []string{"/var/lib/postgresql/data", "/var/run/postgresql", "/tmp"},
+	},
+	"mariadb": {
+		runAsUser:     999,
+		fsGroup:       999,
+		writablePaths: []string{"/var/lib/mysql", "/run/mysqld", "/tmp"},
+	},
+	"redis": {
+		runAsUser:     999,
+		fsGroup:       1000,
+		writablePaths: []string{"/data", "/tmp"},
+	},
+}
+
+// applySecurityContext applies the configured pod security defaults, with the
+// resource type's overrides, to a StatefulSet's pod template
+func (r *Reconciler) applySecurityContext(sts *appsv1.StatefulSet, resourceType models.ResourceType) {
+	cfg := r.config
+	profile := securityByType[resourceType.Name]
+	spec := &sts.Spec.Template.Spec
+
+	pod := &corev1.PodSecurityContext{}
+	if cfg.PodRunAsNonRoot {
+		pod.RunAsNonRoot = boolPtr(true)
+		if profile.runAsUser > 0 {
+			pod.RunAsUser = int64Ptr(profile.runAsUser)
+		}
+	}
+	if profile.fsGroup > 0 {
+		pod.FSGroup = int64Ptr(profile.fsGroup)
+	}
+	if cfg.PodSeccompProfile != "" {
+		pod.SeccompProfile = &corev1.SeccompProfile{Type: corev1.SeccompProfileType(cfg.PodSeccompProfile)}
+	}
+	spec.SecurityContext = pod
+
+	for i := range spec.InitContainers {
+		spec.InitContainers[i].SecurityContext = r.containerSecurityContext()
+	}
+	for i := range spec.Containers {
+		container := &spec.Containers[i]
+		container.SecurityContext = r.containerSecurityContext()
+
+		// Only the database container writes outside its volumes
+		if cfg.PodReadOnlyRootFS && container.Name == resourceType.Name {
+			for j, path := range profile.writablePaths {
+				name := fmt.Sprintf("writable-%d", j)
+				spec.Volumes = append(spec.Volumes, corev1.Volume{
+					Name:         name,
+					VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+				})
+				container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{Name: name, MountPath: path})
+			}
+		}
+	}
+}
+
+// containerSecurityContext builds the configured container security defaults
+func (r *Reconciler) containerSecurityContext() *corev1.SecurityContext {
+	sc := &corev1.SecurityContext{AllowPrivilegeEscalation: boolPtr(false)}
+	if r.config.PodReadOnlyRootFS {
+		sc.ReadOnlyRootFilesystem = boolPtr(true)
+	}
+	if len(r.config.PodDropCapabilities) > 0 {
+		drop := make([]corev1.Capability, len(r.config.PodDropCapabilities))
+		for i, capability := range r.config.PodDropCapabilities {
+			drop[i] = corev1.Capability(capability)
+		}
+		sc.Capabilities = &corev1.Capabilities{Drop: drop}
+	}
+	return sc
+}
+
+// securityDiffers reports whether two pod templates differ in security
+// contexts or the volumes that hardening adds
+func securityDiffers(desired, current *appsv1.StatefulSet) bool {
+	want := desired.Spec.Template.Spec
+	have := current.Spec.Template.Spec
+	if !reflect.DeepEqual(want.SecurityContext, have.SecurityContext) || len(want.Volumes) != len(have.Volumes) {
+		return true
+	}
+
+	contexts := make(map[string]*corev1.SecurityContext, len(have.Containers))
+	for _, c := range have.Containers {
+		contexts[c.Name] = c.SecurityContext
+	}
+	for _, c := range want.Containers {
+		if current, ok := contexts[c.Name]; ok && !reflect.DeepEqual(current, c.SecurityContext) {
+			return true
+		}
+	}
+	return false
+}
+
+// hardeningFindings checks a StatefulSet's pod template against the hardening
+// baseline, regardless of the configured defaults, and describes each gap
+func hardeningFindings(sts *appsv1.StatefulSet) models.StringList {
+	spec := sts.Spec.Template.Spec
+	findings := models.StringList{}
+
+	pod := spec.SecurityContext
+	if pod == nil {
+		pod = &corev1.PodSecurityContext{}
+	}
+	podSeccomp := pod.SeccompProfile != nil && pod.SeccompProfile.Type != corev1.SeccompProfileTypeUnconfined
+
+	containers := append(append([]corev1.Container{}, spec.InitContainers...), spec.Containers...)
+	for _, c := range containers {
+		sc := c.SecurityContext
+		if sc == nil {
+			sc = &corev1.SecurityContext{}
+		}
+
+		nonRoot := pod.RunAsNonRoot
+		if sc.RunAsNonRoot != nil {
+			nonRoot = sc.RunAsNonRoot
+		}
+		if nonRoot == nil || !*nonRoot {
+			findings = append(findings, c.Name+": may run as root")
+		}
+		if sc.ReadOnlyRootFilesystem == nil || !*sc.ReadOnlyRootFilesystem {
+			findings = append(findings, c.Name+": root filesystem is writable")
+		}
+		if sc.AllowPrivilegeEscalation == nil || *sc.AllowPrivilegeEscalation {
+			findings = append(findings, c.Name+": privilege escalation allowed")
+		}
+		if sc.Privileged != nil && *sc.Privileged {
+			findings = append(findings, c.Name+": privileged")
+		}
+		if !dropsAllCapabilities(sc.Capabilities) {
+			findings = append(findings, c.Name+": capabilities not dropped")
+		}
+		seccomp := podSeccomp
+		if sc.SeccompProfile != nil {
+			seccomp = sc.SeccompProfile.Type != corev1.SeccompProfileTypeUnconfined
+		}
+		if !seccomp {
+			findings = append(findings, c.Name+": no seccomp profile")
+		}
+	}
+
+	sort.Strings(findings)
+	return findings
+}
+
+// dropsAllCapabilities reports whether a capability set drops ALL
+func dropsAllCapabilities(caps *corev1.Capabilities) bool {
+	if caps == nil {
+		return false
+	}
+	for _, capability := range caps.Drop {
+		if capability == "ALL" {
+			return true
+		}
+	}
+	return false
+}
+
+// recordSecurityFindings stores the hardening findings of a resource's live
+// StatefulSet so non-hardened resources can be surfaced through the API
+func (r *Reconciler) recordSecurityFindings(ctx context.Context, resource *models.Resource, sts *appsv1.StatefulSet) error {
+	findings := hardeningFindings(sts)
+	if reflect.DeepEqual(findings, resource.SecurityFindings) {
+		return nil
+	}
+	if err := r.db.WithContext(ctx).Model(&models.Resource{}).Where("id = ?", resource.ID).
+		UpdateColumn("security_findings", findings).Error; err != nil {
+		return fmt.Errorf("failed to record security findings: %w", err)
+	}
+	resource.SecurityFindings = findings
+	return nil
+}
+
+func int64Ptr(i int64) *int64 {
+	return &i
+}
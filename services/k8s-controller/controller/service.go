@@ -0,0 +1,132 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/penguintechinc/nest/services/k8s-controller/pkg/models"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// applyIPFamilies sets the configured IP family policy and families on a
+// Service. PreferDualStack, the default, gets a cluster IP of each family
+// on dual-stack clusters and falls back to the cluster's one family
+// elsewhere, including IPv6-only clusters.
+func (r *Reconciler) applyIPFamilies(spec *corev1.ServiceSpec) {
+	policy := corev1.IPFamilyPolicy(r.config.ServiceIPFamilyPolicy)
+	spec.IPFamilyPolicy = &policy
+	spec.IPFamilies = nil
+	for _, family := range r.config.ServiceIPFamilies {
+		spec.IPFamilies = append(spec.IPFamilies, corev1.IPFamily(family))
+	}
+}
+
+// ensureService creates the ClusterIP Service clients reach a resource's
+// engine through, named after the resource, and keeps its IP family policy
+// and port in step with the configuration
+func (r *Reconciler) ensureService(ctx context.Context, resource *models.Resource, resourceType models.ResourceType) (*corev1.Service, error) {
+	_, port, err := engineImage(resourceType.Name)
+	if err != nil {
+		return nil, err
+	}
+	namespace := *resource.K8sNamespace
+	services := r.clientset.CoreV1().Services(namespace)
+
+	desired := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      resource.Name,
+			Namespace: namespace,
+			Labels: map[string]string{
+				"app":         resource.Name,
+				"managed-by":  "nest-controller",
+				"resource-id": fmt.Sprintf("%d", resource.ID),
+			},
+		},
+		Spec: corev1.ServiceSpec{
+			Type:     corev1.ServiceTypeClusterIP,
+			Selector: map[string]string{"app": resource.Name},
+			Ports: []corev1.ServicePort{
+				{Name: resourceType.Name, Port: port, TargetPort: intstr.FromInt(int(port))},
+			},
+		},
+	}
+	r.applyIPFamilies(&desired.Spec)
+
+	existing, err := services.Get(ctx, resource.Name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return services.Create(ctx, desired, metav1.CreateOptions{})
+	}
+	if err != nil {
+		return nil, err
+	}
+	// A Service of the same name NEST didn't create, such as one of an
+	// adopted StatefulSet, is left alone
+	if existing.Labels["managed-by"] != "nest-controller" {
+		return existing, nil
+	}
+
+	// The primary family of a Service can't change, so families are only
+	// added, when a single-stack Service becomes dual-stack
+	policyChanged := existing.Spec.IPFamilyPolicy == nil || *existing.Spec.IPFamilyPolicy != *desired.Spec.IPFamilyPolicy
+	if !policyChanged && portsEqual(existing.Spec.Ports, desired.Spec.Ports) {
+		return existing, nil
+	}
+	existing.Spec.IPFamilyPolicy = desired.Spec.IPFamilyPolicy
+	if *desired.Spec.IPFamilyPolicy == corev1.IPFamilyPolicySingleStack && len(existing.Spec.IPFamilies) > 1 {
+		existing.Spec.IPFamilies = existing.Spec.IPFamilies[:1]
+		if len(existing.Spec.ClusterIPs) > 1 {
+			existing.Spec.ClusterIPs = existing.Spec.ClusterIPs[:1]
+		}
+	}
+	existing.Spec.Ports = desired.Spec.Ports
+	return services.Update(ctx, existing, metav1.UpdateOptions{})
+}
+
+// portsEqual reports whether two Services expose the same ports
+func portsEqual(a, b []corev1.ServicePort) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Name != b[i].Name || a[i].Port != b[i].Port || a[i].TargetPort != b[i].TargetPort {
+			return false
+		}
+	}
+	return true
+}
+
+// removeService deletes the engine Service created for a resource
+func (r *Reconciler) removeService(ctx context.Context, resource *models.Resource) {
+	if resource.K8sNamespace == nil {
+		return
+	}
+	services := r.clientset.CoreV1().Services(*resource.K8sNamespace)
+	existing, err := services.Get(ctx, resource.Name, metav1.GetOptions{})
+	if err != nil || existing.Labels["managed-by"] != "nest-controller" {
+		return
+	}
+	if err := services.Delete(ctx, resource.Name, metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+		r.log.WithError(err).WithField("name", resource.Name).Warn("Failed to delete service")
+	}
+}
+
+// addressesByFamily splits addresses into IPv4 and IPv6 ones, the A and
+// AAAA records a client resolves
+func addressesByFamily(addresses []string) (ipv4, ipv6 []string) {
+	ipv4, ipv6 = []string{}, []string{}
+	for _, address := range addresses {
+		ip := net.ParseIP(address)
+		switch {
+		case ip == nil:
+		case ip.To4() != nil:
+			ipv4 = append(ipv4, address)
+		default:
+			ipv6 = append(ipv6, address)
+		}
+	}
+	return ipv4, ipv6
+}
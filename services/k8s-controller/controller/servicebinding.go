@@ -0,0 +1,131 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/penguintechinc/nest/services/k8s-controller/pkg/models"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// provisionedServiceGVR is NEST's servicebinding.io Provisioned Service,
+// whose status names the binding Secret a ServiceBinding projects into
+// workloads
+var provisionedServiceGVR = schema.GroupVersionResource{Group: "nest.penguintech.io", Version: "v1alpha1", Resource: "provisionedservices"}
+
+// servesResource reports whether a custom resource's CRD is installed in
+// the cluster
+func (c *Controller) servesResource(gvr schema.GroupVersionResource) bool {
+	if c.reconciler.dynamicClient == nil {
+		return false
+	}
+	list, err := c.clientset.Discovery().ServerResourcesForGroupVersion(gvr.GroupVersion().String())
+	if err != nil {
+		return false
+	}
+	for _, res := range list.APIResources {
+		if res.Name == gvr.Resource {
+			return true
+		}
+	}
+	return false
+}
+
+// publishProvisionedService creates or updates the ProvisionedService of a
+// binding, named after its Secret. Its status points at the Secret once it
+// has been written, and its Ready condition reports the last sync.
+func (c *Controller) publishProvisionedService(ctx context.Context, binding *models.ConsumerBinding, engine string, hasSecret bool, syncErr error) error {
+	client := c.reconciler.dynamicClient.Resource(provisionedServiceGVR).Namespace(binding.Namespace)
+	bindingID := strconv.FormatUint(uint64(binding.ID), 10)
+
+	obj, err := client.Get(ctx, binding.SecretName, metav1.GetOptions{})
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			return fmt.Errorf("failed to get ProvisionedService: %w", err)
+		}
+		obj, err = client.Create(ctx, &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": provisionedServiceGVR.GroupVersion().String(),
+			"kind":       "ProvisionedService",
+			"metadata": map[string]interface{}{
+				"name":      binding.SecretName,
+				"namespace": binding.Namespace,
+				"labels": map[string]interface{}{
+					"managed-by":  "nest-controller",
+					"resource-id": strconv.FormatUint(uint64(binding.ResourceID), 10),
+					bindingLabel:  bindingID,
+				},
+			},
+			"spec": map[string]interface{}{
+				"resourceId": int64(binding.ResourceID),
+				"type":       uriScheme(engine, false),
+			},
+		}}, metav1.CreateOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to create ProvisionedService: %w", err)
+		}
+	}
+	if obj.GetLabels()[bindingLabel] != bindingID {
+		return fmt.Errorf("ProvisionedService %s exists and is not managed by this binding", binding.SecretName)
+	}
+
+	ready := map[string]interface{}{"type": "Ready", "status": "True", "reason": "SecretSynced", "message": ""}
+	if syncErr != nil {
+		ready["status"], ready["reason"], ready["message"] = "False", "SyncFailed", syncErr.Error()
+	}
+	secretName := ""
+	if hasSecret {
+		secretName = binding.SecretName
+	}
+
+	// The condition keeps its transition time until its status changes
+	current, _, _ := unstructured.NestedMap(obj.Object, "status")
+	currentSecret, _, _ := unstructured.NestedString(current, "binding", "name")
+	ready["lastTransitionTime"] = time.Now().UTC().Format(time.RFC3339)
+	if conditions, ok := current["conditions"].([]interface{}); ok && len(conditions) > 0 {
+		if prev, ok := conditions[0].(map[string]interface{}); ok {
+			if currentSecret == secretName && prev["status"] == ready["status"] &&
+				prev["reason"] == ready["reason"] && prev["message"] == ready["message"] {
+				return nil
+			}
+			if prev["status"] == ready["status"] && prev["lastTransitionTime"] != nil {
+				ready["lastTransitionTime"] = prev["lastTransitionTime"]
+			}
+		}
+	}
+
+	status := map[string]interface{}{"conditions": []interface{}{ready}}
+	if secretName != "" {
+		status["binding"] = map[string]interface{}{"name": secretName}
+	}
+	obj.Object["status"] = status
+	if _, err := client.UpdateStatus(ctx, obj, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("failed to update ProvisionedService status: %w", err)
+	}
+	return nil
+}
+
+// removeProvisionedService deletes a binding's ProvisionedService if NEST
+// created it for the binding
+func (c *Controller) removeProvisionedService(ctx context.Context, binding *models.ConsumerBinding) error {
+	client := c.reconciler.dynamicClient.Resource(provisionedServiceGVR).Namespace(binding.Namespace)
+
+	obj, err := client.Get(ctx, binding.SecretName, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to get ProvisionedService: %w", err)
+	}
+	if obj.GetLabels()[bindingLabel] != strconv.FormatUint(uint64(binding.ID), 10) {
+		return nil
+	}
+	if err := client.Delete(ctx, binding.SecretName, metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete ProvisionedService: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package controller
+
+import (
+	"fmt"
+
+	"github.com/penguintechinc/nest/services/k8s-controller/pkg/models"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// configResources builds the engine container's requests from
+// Config.resources, which the API fills from the resource's size class, e.g.
+//
+//	{"resources": {"cpu": "1", "memory": "2Gi", "storage": "20Gi"}}
+//
+// Memory and storage are also set as limits. The pods keep their data in
+// emptyDir volumes, so storage is requested as ephemeral storage.
+func configResources(res *models.Resource) (corev1.ResourceRequirements, error) {
+	var requirements corev1.ResourceRequirements
+	m, ok := res.Config["resources"].(map[string]interface{})
+	if !ok {
+		return requirements, nil
+	}
+
+	names := map[string]corev1.ResourceName{
+		"cpu":     corev1.ResourceCPU,
+		"memory":  corev1.ResourceMemory,
+		"storage": corev1.ResourceEphemeralStorage,
+	}
+	for key, name := range names {
+		value, ok := m[key].(string)
+		if !ok || value == "" {
+			continue
+		}
+		quantity, err := resource.ParseQuantity(value)
+		if err != nil {
+			return requirements, fmt.Errorf("invalid %s request %q: %w", key, value, err)
+		}
+		if requirements.Requests == nil {
+			requirements.Requests = corev1.ResourceList{}
+		}
+		requirements.Requests[name] = quantity
+		if name != corev1.ResourceCPU {
+			if requirements.Limits == nil {
+				requirements.Limits = corev1.ResourceList{}
+			}
+			requirements.Limits[name] = quantity
+		}
+	}
+	return requirements, nil
+}
+
+// applyResources sets the engine container's requests and limits from the
+// resource's size class
+func applyResources(res *models.Resource, resourceType models.ResourceType, sts *appsv1.StatefulSet) error {
+	requirements, err := configResources(res)
+	if err != nil {
+		return err
+	}
+	for i := range sts.Spec.Template.Spec.Containers {
+		if sts.Spec.Template.Spec.Containers[i].Name == resourceType.Name {
+			sts.Spec.Template.Spec.Containers[i].Resources = requirements
+		}
+	}
+	return nil
+}
+
+// resourcesDiffer reports whether the engine containers of two pod templates
+// have different requests or limits, such as after a size class change
+func resourcesDiffer(desired, current *appsv1.StatefulSet, resourceType models.ResourceType) bool {
+	var want, have corev1.ResourceRequirements
+	for _, c := range desired.Spec.Template.Spec.Containers {
+		if c.Name == resourceType.Name {
+			want = c.Resources
+		}
+	}
+	for _, c := range current.Spec.Template.Spec.Containers {
+		if c.Name == resourceType.Name {
+			have = c.Resources
+		}
+	}
+	return !resourceListsEqual(want.Requests, have.Requests) || !resourceListsEqual(want.Limits, have.Limits)
+}
+
+// resourceListsEqual compares quantities by value, since the API server
+// normalises their string forms
+func resourceListsEqual(a, b corev1.ResourceList) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, quantity := range a {
+		other, ok := b[name]
+		if !ok || quantity.Cmp(other) != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,471 @@
+package controller
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/penguintechinc/nest/services/k8s-controller/pkg/config"
+	"github.com/penguintechinc/nest/services/k8s-controller/pkg/models"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// StatsCollector periodically gathers engine-level statistics (top queries,
+// connection counts, cache hit ratio, deadlocks) from managed databases and
+// stores them as ResourceStats samples
+type StatsCollector struct {
+	db           *gorm.DB
+	interval     time.Duration
+	topQueries   int
+	queryTimeout time.Duration
+	staleAfter   time.Duration
+	log          *logrus.Entry
+}
+
+// QueryStat describes a single normalized statement from the engine's
+// statement statistics view
+type QueryStat struct {
+	Query       string  `json:"query"`
+	Calls       int64   `json:"calls"`
+	TotalTimeMs float64 `json:"total_time_ms"`
+	MeanTimeMs  float64 `json:"mean_time_ms"`
+	Rows        int64   `json:"rows"`
+}
+
+// DatabaseInsights holds the engine statistics collected for one resource
+type DatabaseInsights struct {
+	Engine                string           `json:"engine"`
+	Connections           map[string]int64 `json:"connections"`
+	UserConnections       map[string]int64 `json:"user_connections"`
+	CacheHitRatio         float64          `json:"cache_hit_ratio"`
+	Deadlocks             int64            `json:"deadlocks"`
+	TopQueries            []QueryStat      `json:"top_queries"`
+	StatementStatsEnabled bool             `json:"statement_stats_enabled"`
+	CollectedAt           time.Time        `json:"collected_at"`
+}
+
+// connectionTarget holds the parameters needed to open a monitoring connection
+type connectionTarget struct {
+	host     string
+	port     int
+	user     string
+	password string
+	database string
+}
+
+// NewStatsCollector creates a new stats collector
+func NewStatsCollector(db *gorm.DB, cfg *config.Config) *StatsCollector {
+	return &StatsCollector{
+		db:           db,
+		interval:     cfg.StatsInterval,
+		topQueries:   cfg.StatsTopQueries,
+		queryTimeout: cfg.StatsQueryTimeout,
+		staleAfter:   cfg.CredentialStaleAfter,
+		log:          logrus.WithField("component", "stats_collector"),
+	}
+}
+
+// Run collects statistics on every interval while enabled returns true,
+// until the context is cancelled
+func (s *StatsCollector) Run(ctx context.Context, stopChan <-chan struct{}, enabled func() bool) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	s.log.WithField("interval", s.interval).Info("Starting stats collector")
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-stopChan:
+			return
+		case <-ticker.C:
+			if enabled() {
+				s.collectAll(ctx)
+			}
+		}
+	}
+}
+
+// collectAll collects statistics for every active database resource
+func (s *StatsCollector) collectAll(ctx context.Context) {
+	type row struct {
+		models.Resource
+		TypeName string
+	}
+
+	var rows []row
+	if err := s.db.Table("resources").
+		Select("resources.*, resource_types.name AS type_name").
+		Joins("INNER JOIN resource_types ON resource_types.id = resources.resource_type_id").
+		Where("resources.status = ? AND resources.lifecycle_mode IN ? AND resources.deleted_at IS NULL",
+			"active", []string{"full", "partial"}).
+		// Resources outside the cluster report their own stats through their agent
+		Where("resources.agent_id IS NULL").
+		Where("resource_types.name IN ?", []string{"postgresql", "mariadb", "mysql"}).
+		Find(&rows).Error; err != nil {
+		s.log.WithError(err).Error("Failed to query resources for stats collection")
+		return
+	}
+
+	for i := range rows {
+		resource := &rows[i].Resource
+		log := s.log.WithFields(logrus.Fields{
+			"resource_id": resource.ID,
+			"engine":      rows[i].TypeName,
+		})
+
+		insights, err := s.CollectInsights(ctx, resource, rows[i].TypeName)
+		if err != nil {
+			log.WithError(err).Warn("Failed to collect database insights")
+			continue
+		}
+
+		if err := s.recordCredentialUsage(resource, insights); err != nil {
+			log.WithError(err).Warn("Failed to record credential usage")
+		}
+		stale, err := s.staleAccounts(resource.ID, insights.CollectedAt)
+		if err != nil {
+			log.WithError(err).Warn("Failed to check for stale accounts")
+		}
+
+		if err := s.store(resource.ID, insights, stale); err != nil {
+			log.WithError(err).Error("Failed to store database insights")
+		}
+	}
+}
+
+// CollectInsights connects to a resource and gathers its engine statistics
+func (s *StatsCollector) CollectInsights(ctx context.Context, resource *models.Resource, engine string) (*DatabaseInsights, error) {
+	target, err := resolveConnectionTarget(resource, engine)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.queryTimeout)
+	defer cancel()
+
+	switch engine {
+	case "postgresql":
+		return s.collectPostgres(ctx, target)
+	case "mariadb", "mysql":
+		return s.collectMySQL(ctx, target, engine)
+	default:
+		return nil, fmt.Errorf("unsupported engine for insights: %s", engine)
+	}
+}
+
+// collectPostgres reads pg_stat_activity, pg_stat_database, and pg_stat_statements
+func (s *StatsCollector) collectPostgres(ctx context.Context, t *connectionTarget) (*DatabaseInsights, error) {
+	dsn := &url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(t.user, t.password),
+		Host:     net.JoinHostPort(t.host, strconv.Itoa(t.port)),
+		Path:     t.database,
+		RawQuery: "sslmode=prefer&connect_timeout=5",
+	}
+	conn, err := sql.Open("pgx", dsn.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open connection: %w", err)
+	}
+	defer conn.Close()
+
+	insights := &DatabaseInsights{
+		Engine:          "postgresql",
+		Connections:     map[string]int64{},
+		UserConnections: map[string]int64{},
+		TopQueries:      []QueryStat{},
+		CollectedAt:     time.Now().UTC(),
+	}
+
+	var total, active, idle, idleInTx int64
+	if err := conn.QueryRowContext(ctx, `
+		SELECT count(*),
+		       count(*) FILTER (WHERE state = 'active'),
+		       count(*) FILTER (WHERE state = 'idle'),
+		       count(*) FILTER (WHERE state LIKE 'idle in transaction%')
+		FROM pg_stat_activity
+		WHERE backend_type = 'client backend'`).Scan(&total, &active, &idle, &idleInTx); err != nil {
+		return nil, fmt.Errorf("failed to read pg_stat_activity: %w", err)
+	}
+	insights.Connections["total"] = total
+	insights.Connections["active"] = active
+	insights.Connections["idle"] = idle
+	insights.Connections["idle_in_transaction"] = idleInTx
+
+	// Connections by account, leaving out this one
+	if err := scanUserConnections(ctx, conn, `
+		SELECT usename, count(*)
+		FROM pg_stat_activity
+		WHERE backend_type = 'client backend' AND usename IS NOT NULL AND pid <> pg_backend_pid()
+		GROUP BY usename`, insights.UserConnections); err != nil {
+		return nil, fmt.Errorf("failed to read pg_stat_activity: %w", err)
+	}
+
+	var maxConns string
+	if err := conn.QueryRowContext(ctx, "SHOW max_connections").Scan(&maxConns); err == nil {
+		if v, err := strconv.ParseInt(maxConns, 10, 64); err == nil {
+			insights.Connections["max"] = v
+		}
+	}
+
+	if err := conn.QueryRowContext(ctx, `
+		SELECT COALESCE(sum(blks_hit) * 100.0 / NULLIF(sum(blks_hit) + sum(blks_read), 0), 0),
+		       COALESCE(sum(deadlocks), 0)
+		FROM pg_stat_database`).Scan(&insights.CacheHitRatio, &insights.Deadlocks); err != nil {
+		return nil, fmt.Errorf("failed to read pg_stat_database: %w", err)
+	}
+
+	// pg_stat_statements is optional; its absence is reported rather than treated as an error
+	rows, err := conn.QueryContext(ctx, `
+		SELECT query, calls, total_exec_time, mean_exec_time, rows
+		FROM pg_stat_statements
+		ORDER BY total_exec_time DESC
+		LIMIT $1`, s.topQueries)
+	if err != nil {
+		s.log.WithError(err).Debug("pg_stat_statements not available")
+		return insights, nil
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var q QueryStat
+		if err := rows.Scan(&q.Query, &q.Calls, &q.TotalTimeMs, &q.MeanTimeMs, &q.Rows); err != nil {
+			return nil, fmt.Errorf("failed to scan pg_stat_statements row: %w", err)
+		}
+		insights.TopQueries = append(insights.TopQueries, q)
+	}
+	insights.StatementStatsEnabled = true
+
+	return insights, rows.Err()
+}
+
+// collectMySQL reads global status counters, InnoDB metrics, and performance_schema digests
+func (s *StatsCollector) collectMySQL(ctx context.Context, t *connectionTarget, engine string) (*DatabaseInsights, error) {
+	cfg := mysql.NewConfig()
+	cfg.User = t.user
+	cfg.Passwd = t.password
+	cfg.Net = "tcp"
+	cfg.Addr = net.JoinHostPort(t.host, strconv.Itoa(t.port))
+	cfg.DBName = t.database
+	cfg.Timeout = 5 * time.Second
+
+	conn, err := sql.Open("mysql", cfg.FormatDSN())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open connection: %w", err)
+	}
+	defer conn.Close()
+
+	insights := &DatabaseInsights{
+		Engine:          engine,
+		Connections:     map[string]int64{},
+		UserConnections: map[string]int64{},
+		TopQueries:      []QueryStat{},
+		CollectedAt:     time.Now().UTC(),
+	}
+
+	statusRows, err := conn.QueryContext(ctx, `SHOW GLOBAL STATUS WHERE Variable_name IN
+		('Threads_connected', 'Threads_running', 'Innodb_buffer_pool_read_requests', 'Innodb_buffer_pool_reads')`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read global status: %w", err)
+	}
+	status := map[string]int64{}
+	for statusRows.Next() {
+		var name, value string
+		if err := statusRows.Scan(&name, &value); err != nil {
+			statusRows.Close()
+			return nil, fmt.Errorf("failed to scan global status: %w", err)
+		}
+		v, _ := strconv.ParseInt(value, 10, 64)
+		status[name] = v
+	}
+	statusRows.Close()
+
+	insights.Connections["total"] = status["Threads_connected"]
+	insights.Connections["active"] = status["Threads_running"]
+	insights.Connections["idle"] = status["Threads_connected"] - status["Threads_running"]
+
+	// Connections by account, leaving out this one and the server's own
+	// threads. Without the PROCESS privilege only this account's are seen.
+	if err := scanUserConnections(ctx, conn, `
+		SELECT USER, COUNT(*)
+		FROM information_schema.PROCESSLIST
+		WHERE ID <> CONNECTION_ID() AND USER NOT IN ('system user', 'event_scheduler')
+		GROUP BY USER`, insights.UserConnections); err != nil {
+		return nil, fmt.Errorf("failed to read processlist: %w", err)
+	}
+
+	var maxConns int64
+	if err := conn.QueryRowContext(ctx, "SELECT @@max_connections").Scan(&maxConns); err == nil {
+		insights.Connections["max"] = maxConns
+	}
+
+	if requests := status["Innodb_buffer_pool_read_requests"]; requests > 0 {
+		insights.CacheHitRatio = float64(requests-status["Innodb_buffer_pool_reads"]) * 100.0 / float64(requests)
+	}
+
+	if err := conn.QueryRowContext(ctx,
+		"SELECT COUNT FROM information_schema.INNODB_METRICS WHERE NAME = 'lock_deadlocks'").
+		Scan(&insights.Deadlocks); err != nil {
+		s.log.WithError(err).Debug("InnoDB deadlock metric not available")
+	}
+
+	// Timer columns are in picoseconds
+	rows, err := conn.QueryContext(ctx, `
+		SELECT COALESCE(DIGEST_TEXT, ''), COUNT_STAR, SUM_TIMER_WAIT / 1000000000,
+		       AVG_TIMER_WAIT / 1000000000, SUM_ROWS_SENT
+		FROM performance_schema.events_statements_summary_by_digest
+		ORDER BY SUM_TIMER_WAIT DESC
+		LIMIT ?`, s.topQueries)
+	if err != nil {
+		s.log.WithError(err).Debug("performance_schema statement digests not available")
+		return insights, nil
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var q QueryStat
+		if err := rows.Scan(&q.Query, &q.Calls, &q.TotalTimeMs, &q.MeanTimeMs, &q.Rows); err != nil {
+			return nil, fmt.Errorf("failed to scan statement digest row: %w", err)
+		}
+		insights.TopQueries = append(insights.TopQueries, q)
+	}
+	insights.StatementStatsEnabled = true
+
+	return insights, rows.Err()
+}
+
+// scanUserConnections reads account names and their connection counts
+func scanUserConnections(ctx context.Context, conn *sql.DB, query string, into map[string]int64) error {
+	rows, err := conn.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var user string
+		var count int64
+		if err := rows.Scan(&user, &count); err != nil {
+			return err
+		}
+		into[user] = count
+	}
+	return rows.Err()
+}
+
+// store persists insights as a ResourceStats sample. Connection counts and
+// cache hit ratio are also written at the top level of the metrics so risk
+// assessment treats them the same as connector-collected stats.
+func (s *StatsCollector) store(resourceID uint, insights *DatabaseInsights, stale []string) error {
+	riskLevel, factors := assessInsightsRisk(insights)
+	if len(stale) > 0 {
+		if riskLevel == "low" {
+			riskLevel = "medium"
+		}
+		factors = append(factors, fmt.Sprintf("%d accounts unused for %d days: %s",
+			len(stale), int(s.staleAfter.Hours()/24), strings.Join(stale, ", ")))
+	}
+
+	stats := &models.ResourceStats{
+		ResourceID: resourceID,
+		Timestamp:  insights.CollectedAt,
+		Metrics: models.JSONMap{
+			"resource_type":     insights.Engine,
+			"connections":       insights.Connections,
+			"cache_hit_ratio":   insights.CacheHitRatio,
+			"database_insights": insights,
+		},
+		RiskLevel: riskLevel,
+		RiskFactors: models.JSONMap{
+			"factors": factors,
+		},
+	}
+
+	return s.db.Create(stats).Error
+}
+
+// assessInsightsRisk derives a risk level from connection saturation and cache efficiency
+func assessInsightsRisk(insights *DatabaseInsights) (string, []string) {
+	level := "low"
+	factors := []string{}
+
+	if maxConns := insights.Connections["max"]; maxConns > 0 {
+		saturation := float64(insights.Connections["total"]) * 100.0 / float64(maxConns)
+		if saturation > 95 {
+			level = "high"
+			factors = append(factors, fmt.Sprintf("Connection saturation critical (%.1f%%)", saturation))
+		} else if saturation > 80 {
+			level = "medium"
+			factors = append(factors, fmt.Sprintf("Connection saturation high (%.1f%%)", saturation))
+		}
+	}
+
+	if insights.CacheHitRatio > 0 && insights.CacheHitRatio < 90 {
+		if level == "low" {
+			level = "medium"
+		}
+		factors = append(factors, fmt.Sprintf("Cache hit ratio low (%.1f%%)", insights.CacheHitRatio))
+	}
+
+	if insights.Deadlocks > 0 {
+		factors = append(factors, fmt.Sprintf("%d deadlocks recorded", insights.Deadlocks))
+	}
+
+	return level, factors
+}
+
+// resolveConnectionTarget builds monitoring connection parameters from a
+// resource's connection info and credentials
+func resolveConnectionTarget(resource *models.Resource, engine string) (*connectionTarget, error) {
+	t := &connectionTarget{}
+
+	t.host = stringFromMap(resource.ConnectionInfo, "host")
+	if t.host == "" {
+		t.host = stringFromMap(resource.ConnectionInfo, "service_name")
+	}
+	if t.host == "" {
+		return nil, fmt.Errorf("resource has no host in connection info")
+	}
+
+	switch engine {
+	case "postgresql":
+		t.port = 5432
+		t.database = "postgres"
+	default:
+		t.port = 3306
+	}
+	if port, ok := resource.ConnectionInfo["port"].(float64); ok && port > 0 {
+		t.port = int(port)
+	}
+	if db := stringFromMap(resource.ConnectionInfo, "database"); db != "" {
+		t.database = db
+	}
+
+	t.user = stringFromMap(resource.Credentials, "username")
+	t.password = stringFromMap(resource.Credentials, "password")
+	if t.user == "" {
+		return nil, fmt.Errorf("resource has no username in credentials")
+	}
+
+	return t, nil
+}
+
+// stringFromMap returns a string value from a JSON map, or "" if absent
+func stringFromMap(m models.JSONMap, key string) string {
+	if m == nil {
+		return ""
+	}
+	if v, ok := m[key].(string); ok {
+		return v
+	}
+	return ""
+}
@@ -0,0 +1,171 @@
+package controller
+
+import (
+	"context"
+	"encoding/pem"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/penguintechinc/nest/services/k8s-controller/pkg/models"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Trust bundle ConfigMap published into team namespaces
+const (
+	trustBundleName = "nest-ca-bundle"
+	trustBundleKey  = "ca.crt"
+)
+
+// trustBundleLoop publishes the team trust bundles at startup and on each
+// interval, so rotated or added CAs reach the namespaces within one interval
+func (c *Controller) trustBundleLoop(ctx context.Context) {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(c.config.TrustBundleInterval)
+	defer ticker.Stop()
+
+	c.log.WithField("interval", c.config.TrustBundleInterval).Info("Starting trust bundle distribution")
+	if c.current().EnableTrustBundles {
+		c.syncTrustBundles(ctx)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-c.stopChan:
+			return
+		case <-ticker.C:
+			if c.current().EnableTrustBundles {
+				c.syncTrustBundles(ctx)
+			}
+		}
+	}
+}
+
+// syncTrustBundles publishes the bundle of each namespace holding team
+// resources. A namespace shared by several teams trusts the CAs of all of
+// them.
+func (c *Controller) syncTrustBundles(ctx context.Context) {
+	log := c.log.WithField("action", "trust_bundles")
+
+	var cas []models.CertificateAuthority
+	if err := c.db.Where("deleted_at IS NULL AND status = ? AND type NOT IN ? AND certificate <> ''", "active", []string{"acme", "ssh"}).
+		Order("id").Find(&cas).Error; err != nil {
+		log.WithError(err).Error("Failed to query certificate authorities")
+		return
+	}
+
+	var placements []struct {
+		TeamID       uint
+		K8sNamespace string
+	}
+	if err := c.db.Model(&models.Resource{}).Distinct("team_id", "k8s_namespace").
+		Where("deleted_at IS NULL AND k8s_namespace IS NOT NULL AND k8s_namespace <> ''").
+		Scan(&placements).Error; err != nil {
+		log.WithError(err).Error("Failed to query team namespaces")
+		return
+	}
+	teams := map[string][]uint{}
+	for _, p := range placements {
+		teams[p.K8sNamespace] = append(teams[p.K8sNamespace], p.TeamID)
+	}
+
+	for namespace, teamIDs := range teams {
+		if err := c.publishTrustBundle(ctx, namespace, trustBundle(cas, teamIDs)); err != nil {
+			log.WithError(err).WithField("namespace", namespace).Warn("Failed to publish trust bundle")
+		}
+	}
+}
+
+// trustBundle concatenates the certificates, and imported chains, of the
+// CAs that issue for any of the teams, without duplicates
+func trustBundle(cas []models.CertificateAuthority, teamIDs []uint) string {
+	seen := map[string]bool{}
+	var bundle strings.Builder
+	for _, ca := range cas {
+		allowed := false
+		for _, teamID := range teamIDs {
+			allowed = allowed || ca.AllowsTeam(teamID)
+		}
+		if allowed {
+			writeCertificates(&bundle, seen, ca)
+		}
+	}
+	return bundle.String()
+}
+
+// writeCertificates appends a CA's certificate and imported chain to a
+// bundle, skipping certificates already seen
+func writeCertificates(bundle *strings.Builder, seen map[string]bool, ca models.CertificateAuthority) {
+	rest := []byte(ca.Certificate)
+	if ca.Chain != nil {
+		rest = append(rest, '\n')
+		rest = append(rest, *ca.Chain...)
+	}
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			return
+		}
+		if block.Type != "CERTIFICATE" || seen[string(block.Bytes)] {
+			continue
+		}
+		seen[string(block.Bytes)] = true
+		bundle.Write(pem.EncodeToMemory(block))
+	}
+}
+
+// publishTrustBundle creates or updates a namespace's bundle ConfigMap,
+// removing it when no CA issues for the namespace's teams. ConfigMaps of
+// the same name that NEST didn't create are left alone.
+func (c *Controller) publishTrustBundle(ctx context.Context, namespace, bundle string) error {
+	configMaps := c.clientset.CoreV1().ConfigMaps(namespace)
+
+	existing, err := configMaps.Get(ctx, trustBundleName, metav1.GetOptions{})
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			return fmt.Errorf("failed to get ConfigMap: %w", err)
+		}
+		if bundle == "" {
+			return nil
+		}
+		_, err = configMaps.Create(ctx, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      trustBundleName,
+				Namespace: namespace,
+				Labels:    map[string]string{"managed-by": "nest-controller"},
+			},
+			Data: map[string]string{trustBundleKey: bundle},
+		}, metav1.CreateOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to create ConfigMap: %w", err)
+		}
+		c.log.WithField("namespace", namespace).Info("Published trust bundle")
+		return nil
+	}
+
+	if existing.Labels["managed-by"] != "nest-controller" {
+		return fmt.Errorf("ConfigMap %s exists and is not managed by NEST", trustBundleName)
+	}
+	if bundle == "" {
+		if err := configMaps.Delete(ctx, trustBundleName, metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete ConfigMap: %w", err)
+		}
+		return nil
+	}
+	if existing.Data[trustBundleKey] == bundle {
+		return nil
+	}
+
+	existing.Data = map[string]string{trustBundleKey: bundle}
+	if _, err := configMaps.Update(ctx, existing, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("failed to update ConfigMap: %w", err)
+	}
+	c.log.WithField("namespace", namespace).Info("Updated trust bundle")
+	return nil
+}
@@ -0,0 +1,547 @@
+package controller
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/penguintechinc/nest/services/k8s-controller/pkg/models"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// tuningMountPath is where the tuning ConfigMap is mounted
+	tuningMountPath = "/etc/nest/tuning"
+	// tuningParamsKey holds the raw parameters in the tuning ConfigMap so
+	// changes can be diffed against the next desired state
+	tuningParamsKey = "tuning.json"
+	// tuningRestartAnnotation carries a hash of the restart parameters on the
+	// pod template, so changing one rolls the pods
+	tuningRestartAnnotation = "nest.penguintech.io/tuning-restart"
+)
+
+// tuningParam describes an engine parameter that may be set through
+// Config.tuning. Restart parameters only take effect after the pods are
+// restarted; the others are applied to the running engine.
+type tuningParam struct {
+	kind    string
+	restart bool
+}
+
+// tuningParams lists the supported tuning parameters per resource type,
+// matching the table the API validates against
+var tuningParams = map[string]map[string]tuningParam{
+	"postgresql": {
+		"shared_buffers":                      {"size", true},
+		"max_connections":                     {"int", true},
+		"wal_buffers":                         {"size", true},
+		"max_worker_processes":                {"int", true},
+		"shared_preload_libraries":            {"string", true},
+		"work_mem":                            {"size", false},
+		"maintenance_work_mem":                {"size", false},
+		"effective_cache_size":                {"size", false},
+		"max_wal_size":                        {"size", false},
+		"min_wal_size":                        {"size", false},
+		"checkpoint_completion_target":        {"float", false},
+		"random_page_cost":                    {"float", false},
+		"effective_io_concurrency":            {"int", false},
+		"default_statistics_target":           {"int", false},
+		"max_parallel_workers":                {"int", false},
+		"max_parallel_workers_per_gather":     {"int", false},
+		"log_min_duration_statement":          {"int", false},
+		"statement_timeout":                   {"int", false},
+		"idle_in_transaction_session_timeout": {"int", false},
+	},
+	"mariadb": {
+		"innodb_log_file_size":           {"size", true},
+		"innodb_buffer_pool_instances":   {"int", true},
+		"performance_schema":             {"bool", true},
+		"innodb_buffer_pool_size":        {"size", false},
+		"max_connections":                {"int", false},
+		"innodb_flush_log_at_trx_commit": {"int", false},
+		"innodb_io_capacity":             {"int", false},
+		"tmp_table_size":                 {"size", false},
+		"max_heap_table_size":            {"size", false},
+		"max_allowed_packet":             {"size", false},
+		"table_open_cache":               {"int", false},
+		"thread_cache_size":              {"int", false},
+		"slow_query_log":                 {"bool", false},
+		"long_query_time":                {"float", false},
+	},
+	"redis": {
+		"databases":               {"int", true},
+		"io-threads":              {"int", true},
+		"maxmemory":               {"size", false},
+		"maxmemory-policy":        {"string", false},
+		"timeout":                 {"int", false},
+		"tcp-keepalive":           {"int", false},
+		"appendonly":              {"bool", false},
+		"appendfsync":             {"string", false},
+		"save":                    {"string", false},
+		"slowlog-log-slower-than": {"int", false},
+		"slowlog-max-len":         {"int", false},
+	},
+}
+
+// tuningConfig parses Config.tuning, keeping only parameters known for the
+// resource type, e.g.
+//
+//	{"tuning": {"shared_buffers": "512MB", "work_mem": "16MB"}}
+func tuningConfig(resource *models.Resource, resourceType models.ResourceType) map[string]string {
+	params := tuningParams[resourceType.Name]
+	m, ok := resource.Config["tuning"].(map[string]interface{})
+	if !ok || params == nil {
+		return nil
+	}
+
+	tuning := map[string]string{}
+	for name, v := range m {
+		if _, known := params[name]; !known {
+			continue
+		}
+		switch value := v.(type) {
+		case string:
+			tuning[name] = value
+		case float64:
+			tuning[name] = strconv.FormatFloat(value, 'f', -1, 64)
+		case bool:
+			tuning[name] = strconv.FormatBool(value)
+		}
+	}
+	return tuning
+}
+
+// tuningConfigMapName returns the name of a resource's tuning ConfigMap
+func tuningConfigMapName(resource *models.Resource) string {
+	return resource.Name + "-tuning"
+}
+
+// tuningFile returns the config file name an engine reads tuning from
+func tuningFile(engine string) string {
+	switch engine {
+	case "postgresql":
+		return "postgresql.conf"
+	case "mariadb":
+		return "nest-tuning.cnf"
+	default:
+		return "redis.conf"
+	}
+}
+
+// renderTuning renders tuning parameters in the engine's config file syntax
+func renderTuning(engine string, tuning map[string]string) string {
+	names := make([]string, 0, len(tuning))
+	for name := range tuning {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString("# Generated by nest-controller from Config.tuning\n")
+	switch engine {
+	case "postgresql":
+		b.WriteString("listen_addresses = '*'\n")
+		for _, name := range names {
+			fmt.Fprintf(&b, "%s = '%s'\n", name, strings.ReplaceAll(tuning[name], "'", "''"))
+		}
+	case "mariadb":
+		b.WriteString("[mysqld]\n")
+		for _, name := range names {
+			fmt.Fprintf(&b, "%s = %s\n", name, tuning[name])
+		}
+	default:
+		for _, name := range names {
+			value := redisValue(tuningParams[engine][name], tuning[name])
+			if value == "" {
+				value = `""`
+			}
+			fmt.Fprintf(&b, "%s %s\n", name, value)
+		}
+	}
+	return b.String()
+}
+
+// tuningRestartHash hashes the restart parameters of a tuning document
+func tuningRestartHash(engine string, tuning map[string]string) string {
+	restart := map[string]string{}
+	for name, value := range tuning {
+		if tuningParams[engine][name].restart {
+			restart[name] = value
+		}
+	}
+	raw, _ := json.Marshal(restart)
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:8])
+}
+
+// applyTuning mounts the tuning ConfigMap into the database container and
+// points the engine at it. Resources without tuning keep the image defaults.
+func applyTuning(resource *models.Resource, resourceType models.ResourceType, sts *appsv1.StatefulSet) {
+	tuning := tuningConfig(resource, resourceType)
+	if len(tuning) == 0 {
+		return
+	}
+
+	spec := &sts.Spec.Template.Spec
+	spec.Volumes = append(spec.Volumes, corev1.Volume{
+		Name: "tuning",
+		VolumeSource: corev1.VolumeSource{
+			ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: tuningConfigMapName(resource)},
+			},
+		},
+	})
+
+	for i := range spec.Containers {
+		container := &spec.Containers[i]
+		if container.Name != resourceType.Name {
+			continue
+		}
+
+		file := tuningFile(resourceType.Name)
+		switch resourceType.Name {
+		case "postgresql":
+			container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{Name: "tuning", MountPath: tuningMountPath})
+			container.Args = []string{"-c", "config_file=" + tuningMountPath + "/" + file}
+		case "mariadb":
+			// conf.d already holds the image's own files, so only the one
+			// file is mounted
+			container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+				Name:      "tuning",
+				MountPath: "/etc/mysql/conf.d/" + file,
+				SubPath:   file,
+			})
+		case "redis":
+			container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{Name: "tuning", MountPath: tuningMountPath})
+			container.Args = []string{"redis-server", tuningMountPath + "/" + file}
+		}
+	}
+
+	if sts.Spec.Template.Annotations == nil {
+		sts.Spec.Template.Annotations = map[string]string{}
+	}
+	sts.Spec.Template.Annotations[tuningRestartAnnotation] = tuningRestartHash(resourceType.Name, tuning)
+}
+
+// removeTuning deletes a resource's tuning ConfigMap
+func (r *Reconciler) removeTuning(ctx context.Context, resource *models.Resource) {
+	name := tuningConfigMapName(resource)
+	if err := r.clientset.CoreV1().ConfigMaps(*resource.K8sNamespace).Delete(ctx, name, metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+		r.log.WithError(err).WithField("name", name).Warn("Failed to delete tuning config map")
+	}
+}
+
+// tuningDiffers reports whether two pod templates differ in how tuning is
+// wired in, including a change of restart parameters
+func tuningDiffers(desired, current *appsv1.StatefulSet) bool {
+	return desired.Spec.Template.Annotations[tuningRestartAnnotation] !=
+		current.Spec.Template.Annotations[tuningRestartAnnotation]
+}
+
+// reconcileTuning brings the tuning ConfigMap in line with Config.tuning.
+// When live is set, changed reload parameters are first applied to the
+// running engine; a failure leaves the ConfigMap untouched so the change is
+// retried on the next reconcile. Restart parameters are picked up when the
+// pods roll.
+func (r *Reconciler) reconcileTuning(ctx context.Context, resource *models.Resource, resourceType models.ResourceType, live bool) error {
+	namespace := *resource.K8sNamespace
+	name := tuningConfigMapName(resource)
+	configMaps := r.clientset.CoreV1().ConfigMaps(namespace)
+	tuning := tuningConfig(resource, resourceType)
+
+	existing, err := configMaps.Get(ctx, name, metav1.GetOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("failed to get tuning config map: %w", err)
+	}
+	found := err == nil
+
+	if len(tuning) == 0 {
+		if found {
+			if err := configMaps.Delete(ctx, name, metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+				return fmt.Errorf("failed to delete tuning config map: %w", err)
+			}
+		}
+		return nil
+	}
+
+	params, _ := json.Marshal(tuning)
+	data := map[string]string{
+		tuningFile(resourceType.Name): renderTuning(resourceType.Name, tuning),
+		tuningParamsKey:               string(params),
+	}
+
+	if !found {
+		cm := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+				Labels: map[string]string{
+					"app":         resource.Name,
+					"managed-by":  "nest-controller",
+					"resource-id": fmt.Sprintf("%d", resource.ID),
+				},
+			},
+			Data: data,
+		}
+		if _, err := configMaps.Create(ctx, cm, metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("failed to create tuning config map: %w", err)
+		}
+		return nil
+	}
+
+	if existing.Data[tuningParamsKey] == string(params) {
+		return nil
+	}
+
+	if live {
+		previous := map[string]string{}
+		if err := json.Unmarshal([]byte(existing.Data[tuningParamsKey]), &previous); err != nil {
+			r.log.WithError(err).WithField("resource_id", resource.ID).
+				Warn("Previous tuning parameters don't decode, reapplying every reload parameter")
+			previous = nil
+		}
+		if changes := reloadChanges(resourceType.Name, previous, tuning); len(changes) > 0 {
+			if err := r.reloadTuning(ctx, resource, resourceType.Name, changes); err != nil {
+				return fmt.Errorf("failed to apply tuning: %w", err)
+			}
+		}
+	}
+
+	existing.Data = data
+	if _, err := configMaps.Update(ctx, existing, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("failed to update tuning config map: %w", err)
+	}
+	return nil
+}
+
+// reloadChanges returns the reload parameters that changed between two
+// tuning documents. Removed parameters map to "" and are reset to their
+// defaults where the engine supports it. A nil previous is unknown, so every
+// reload parameter is returned.
+func reloadChanges(engine string, previous, desired map[string]string) map[string]string {
+	changes := map[string]string{}
+	for name, param := range tuningParams[engine] {
+		if param.restart || (previous != nil && previous[name] == desired[name]) {
+			continue
+		}
+		changes[name] = desired[name]
+	}
+	return changes
+}
+
+// reloadTuning applies reload parameters to the running engine
+func (r *Reconciler) reloadTuning(ctx context.Context, resource *models.Resource, engine string, changes map[string]string) error {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	switch engine {
+	case "postgresql":
+		return reloadPostgres(ctx, resource, changes)
+	case "mariadb":
+		return reloadMySQL(ctx, resource, engine, changes)
+	case "redis":
+		return reloadRedis(ctx, resource, changes)
+	}
+	return nil
+}
+
+// reloadPostgres sets parameters with ALTER SYSTEM and reloads the server
+// configuration
+func reloadPostgres(ctx context.Context, resource *models.Resource, changes map[string]string) error {
+	t, err := resolveConnectionTarget(resource, "postgresql")
+	if err != nil {
+		return err
+	}
+	dsn := &url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(t.user, t.password),
+		Host:     net.JoinHostPort(t.host, strconv.Itoa(t.port)),
+		Path:     t.database,
+		RawQuery: "sslmode=prefer&connect_timeout=5",
+	}
+	conn, err := sql.Open("pgx", dsn.String())
+	if err != nil {
+		return fmt.Errorf("failed to open connection: %w", err)
+	}
+	defer conn.Close()
+
+	// Names come from tuningParams, so only values need quoting
+	for name, value := range changes {
+		stmt := fmt.Sprintf("ALTER SYSTEM RESET %s", name)
+		if value != "" {
+			stmt = fmt.Sprintf("ALTER SYSTEM SET %s = '%s'", name, strings.ReplaceAll(value, "'", "''"))
+		}
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("failed to set %s: %w", name, err)
+		}
+	}
+	_, err = conn.ExecContext(ctx, "SELECT pg_reload_conf()")
+	return err
+}
+
+// reloadMySQL sets parameters with SET GLOBAL
+func reloadMySQL(ctx context.Context, resource *models.Resource, engine string, changes map[string]string) error {
+	t, err := resolveConnectionTarget(resource, engine)
+	if err != nil {
+		return err
+	}
+	cfg := mysql.NewConfig()
+	cfg.User = t.user
+	cfg.Passwd = t.password
+	cfg.Net = "tcp"
+	cfg.Addr = net.JoinHostPort(t.host, strconv.Itoa(t.port))
+	cfg.Timeout = 5 * time.Second
+
+	conn, err := sql.Open("mysql", cfg.FormatDSN())
+	if err != nil {
+		return fmt.Errorf("failed to open connection: %w", err)
+	}
+	defer conn.Close()
+
+	for name, value := range changes {
+		stmt := fmt.Sprintf("SET GLOBAL %s = DEFAULT", name)
+		if value != "" {
+			stmt = fmt.Sprintf("SET GLOBAL %s = %s", name, mysqlValue(tuningParams[engine][name], value))
+		}
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("failed to set %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// reloadRedis sets parameters with CONFIG SET. Removed parameters keep their
+// current value until the next restart, as Redis has no reset to default.
+func reloadRedis(ctx context.Context, resource *models.Resource, changes map[string]string) error {
+	host := stringFromMap(resource.ConnectionInfo, "host")
+	if host == "" {
+		host = stringFromMap(resource.ConnectionInfo, "service_name")
+	}
+	if host == "" {
+		return fmt.Errorf("resource has no host in connection info")
+	}
+	port := 6379
+	if p, ok := resource.ConnectionInfo["port"].(float64); ok && p > 0 {
+		port = int(p)
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	reader := bufio.NewReader(conn)
+
+	if password := stringFromMap(resource.Credentials, "password"); password != "" {
+		if err := redisCommand(conn, reader, "AUTH", password); err != nil {
+			return err
+		}
+	}
+	for name, value := range changes {
+		if value == "" {
+			continue
+		}
+		if err := redisCommand(conn, reader, "CONFIG", "SET", name, redisValue(tuningParams["redis"][name], value)); err != nil {
+			return fmt.Errorf("failed to set %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// redisCommand sends a command in RESP form and checks for an error reply
+func redisCommand(conn net.Conn, reader *bufio.Reader, args ...string) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := conn.Write([]byte(b.String())); err != nil {
+		return err
+	}
+
+	reply, err := reader.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	if strings.HasPrefix(reply, "-") {
+		return fmt.Errorf("redis: %s", strings.TrimSpace(reply[1:]))
+	}
+	return nil
+}
+
+// mysqlValue converts a tuning value to a SET GLOBAL literal; sizes are given
+// in bytes and booleans as ON or OFF
+func mysqlValue(param tuningParam, value string) string {
+	switch param.kind {
+	case "size":
+		return strconv.FormatInt(parseSize(value), 10)
+	case "bool":
+		if isTrue(value) {
+			return "ON"
+		}
+		return "OFF"
+	}
+	return value
+}
+
+// redisValue converts a tuning value to Redis syntax
+func redisValue(param tuningParam, value string) string {
+	if param.kind == "bool" {
+		if isTrue(value) {
+			return "yes"
+		}
+		return "no"
+	}
+	return value
+}
+
+// parseSize converts sizes such as 256MB or 1G to bytes
+func parseSize(value string) int64 {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	value = strings.TrimSuffix(value, "B")
+	multiplier := int64(1)
+	if value != "" {
+		switch value[len(value)-1] {
+		case 'K':
+			multiplier = 1 << 10
+		case 'M':
+			multiplier = 1 << 20
+		case 'G':
+			multiplier = 1 << 30
+		case 'T':
+			multiplier = 1 << 40
+		}
+		if multiplier > 1 {
+			value = value[:len(value)-1]
+		}
+	}
+	n, _ := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	return n * multiplier
+}
+
+// isTrue reports whether a tuning boolean is set
+func isTrue(value string) bool {
+	switch strings.ToLower(value) {
+	case "true", "on", "yes", "1":
+		return true
+	}
+	return false
+}
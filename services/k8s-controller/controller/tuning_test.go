@@ -0,0 +1,42 @@
+package controller
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReloadChanges(t *testing.T) {
+	desired := map[string]string{"maxmemory": "1gb", "databases": "32"}
+
+	tests := []struct {
+		name     string
+		previous map[string]string
+		want     map[string]string
+	}{
+		{
+			name:     "changed parameter",
+			previous: map[string]string{"maxmemory": "512mb", "timeout": "300"},
+			want:     map[string]string{"maxmemory": "1gb", "timeout": ""},
+		},
+		{
+			name:     "unchanged parameters",
+			previous: map[string]string{"maxmemory": "1gb", "databases": "16"},
+			want:     map[string]string{},
+		},
+		{
+			name: "unknown previous parameters",
+			want: map[string]string{
+				"maxmemory": "1gb", "maxmemory-policy": "", "timeout": "", "tcp-keepalive": "", "appendonly": "",
+				"appendfsync": "", "save": "", "slowlog-log-slower-than": "", "slowlog-max-len": "",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := reloadChanges("redis", tt.previous, desired); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/penguintechinc/nest/services/k8s-controller/pkg/models"
+	"gorm.io/gorm"
+)
+
+// SchemaVersion is the version of the API's database schema the controller
+// is built for. It is bumped with the API's when a migration changes a
+// table the controller reads or writes.
+const SchemaVersion = 5
+
+// ErrSchemaIncompatible is returned when the API's schema doesn't support
+// the controller's schema version
+var ErrSchemaIncompatible = errors.New("incompatible schema version")
+
+// CheckSchema compares the controller's schema version with the schema the
+// API last migrated. The controller works with schemas from the one it was
+// built for back to MinControllerSchemaVersion of the API; outside that
+// skew it returns an error wrapping ErrSchemaIncompatible. An API that
+// doesn't record its schema version predates the check, and is accepted.
+func CheckSchema(ctx context.Context, db *gorm.DB) (*models.SchemaInfo, error) {
+	var info models.SchemaInfo
+	err := db.WithContext(ctx).First(&info).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err != nil && !db.Migrator().HasTable(&info)) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load schema version: %w", err)
+	}
+
+	switch {
+	case info.SchemaVersion < SchemaVersion:
+		return &info, fmt.Errorf("%w: the controller needs schema version %d, but API %s has migrated %d; upgrade the API first",
+			ErrSchemaIncompatible, SchemaVersion, info.APIVersion, info.SchemaVersion)
+	case SchemaVersion < info.MinControllerSchemaVersion:
+		return &info, fmt.Errorf("%w: API %s supports controllers from schema version %d, but the controller is built for %d; upgrade the controller",
+			ErrSchemaIncompatible, info.APIVersion, info.MinControllerSchemaVersion, SchemaVersion)
+	}
+	return &info, nil
+}
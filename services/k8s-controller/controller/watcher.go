@@ -3,8 +3,10 @@ package controller
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
@@ -13,12 +15,34 @@ import (
 	"k8s.io/client-go/kubernetes"
 )
 
-// Watcher watches Kubernetes resources for changes
+// Watcher watches Kubernetes resources for changes. Events are coalesced by
+// object: within each debounce window only the latest event of an object is
+// passed on, so pod churn doesn't flood the event handler.
 type Watcher struct {
-	clientset       *kubernetes.Clientset
+	clientset       kubernetes.Interface
 	namespacePrefix string
 	eventChannel    chan ResourceEvent
 	log             *logrus.Entry
+
+	debounce time.Duration
+	mu       sync.Mutex
+	pending  map[eventKey]ResourceEvent
+	order    []eventKey
+	// resync is signalled when events are dropped because the event
+	// handler fell behind, so the controller resyncs instead
+	resync chan struct{}
+
+	received  *prometheus.CounterVec
+	coalesced prometheus.Counter
+	overflows prometheus.Counter
+	depth     prometheus.GaugeFunc
+}
+
+// eventKey identifies the object an event is about
+type eventKey struct {
+	kind      string
+	namespace string
+	name      string
 }
 
 // ResourceEvent represents a change to a Kubernetes resource
@@ -29,14 +53,35 @@ type ResourceEvent struct {
 	Resource  interface{}
 }
 
-// NewWatcher creates a new Kubernetes resource watcher
-func NewWatcher(clientset *kubernetes.Clientset, namespacePrefix string) *Watcher {
-	return &Watcher{
+// NewWatcher creates a new Kubernetes resource watcher, coalescing events
+// within debounce
+func NewWatcher(clientset kubernetes.Interface, namespacePrefix string, debounce time.Duration) *Watcher {
+	w := &Watcher{
 		clientset:       clientset,
 		namespacePrefix: namespacePrefix,
 		eventChannel:    make(chan ResourceEvent, 100),
 		log:             logrus.WithField("component", "watcher"),
+		debounce:        debounce,
+		pending:         map[eventKey]ResourceEvent{},
+		resync:          make(chan struct{}, 1),
+		received: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "nest_controller_watch_events_total",
+			Help: "Kubernetes watch events received",
+		}, []string{"kind"}),
+		coalesced: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "nest_controller_watch_events_coalesced_total",
+			Help: "Watch events replaced by a later event of the same object within the debounce window",
+		}),
+		overflows: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "nest_controller_watch_event_overflows_total",
+			Help: "Watch events dropped for a resync because the event handler fell behind",
+		}),
 	}
+	w.depth = prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "nest_controller_watch_event_queue_length",
+		Help: "Watch events waiting for the event handler",
+	}, func() float64 { return float64(len(w.eventChannel)) })
+	return w
 }
 
 // Start begins watching Kubernetes resources
@@ -56,6 +101,7 @@ func (w *Watcher) Start(ctx context.Context) error {
 		go w.watchStatefulSets(ctx, ns)
 		go w.watchPods(ctx, ns)
 	}
+	go w.dispatch(ctx)
 
 	return nil
 }
@@ -65,6 +111,76 @@ func (w *Watcher) GetEventChannel() <-chan ResourceEvent {
 	return w.eventChannel
 }
 
+// Resync returns a channel signalled when events were dropped, after which
+// every resource should be resynced
+func (w *Watcher) Resync() <-chan struct{} {
+	return w.resync
+}
+
+// Collectors returns the watcher's Prometheus metrics
+func (w *Watcher) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{w.received, w.coalesced, w.overflows, w.depth}
+}
+
+// enqueue holds an event until the end of the debounce window, replacing
+// any earlier event of the same object. It never blocks the watch.
+func (w *Watcher) enqueue(kind string, event ResourceEvent) {
+	w.received.WithLabelValues(kind).Inc()
+	key := eventKey{kind: kind, namespace: event.Namespace, name: event.Name}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, ok := w.pending[key]; ok {
+		w.coalesced.Inc()
+	} else {
+		w.order = append(w.order, key)
+	}
+	w.pending[key] = event
+}
+
+// dispatch passes the events of each debounce window on to the event
+// handler, in the order their objects first changed
+func (w *Watcher) dispatch(ctx context.Context) {
+	ticker := time.NewTicker(w.debounce)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.flush()
+		}
+	}
+}
+
+// flush sends the pending events to the event handler. Rather than block
+// the watches when the handler falls behind, it drops the events that don't
+// fit and signals a resync.
+func (w *Watcher) flush() {
+	w.mu.Lock()
+	order, pending := w.order, w.pending
+	w.order, w.pending = nil, make(map[eventKey]ResourceEvent, len(pending))
+	w.mu.Unlock()
+
+	for i, key := range order {
+		select {
+		case w.eventChannel <- pending[key]:
+			continue
+		default:
+		}
+
+		dropped := len(order) - i
+		w.overflows.Add(float64(dropped))
+		w.log.WithField("dropped", dropped).Warn("Event handler fell behind, dropping events for a resync")
+		select {
+		case w.resync <- struct{}{}:
+		default:
+		}
+		return
+	}
+}
+
 // getTeamNamespaces returns all namespaces with the team prefix
 func (w *Watcher) getTeamNamespaces(ctx context.Context) ([]string, error) {
 	namespaces, err := w.clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
@@ -116,12 +232,12 @@ func (w *Watcher) watchStatefulSets(ctx context.Context, namespace string) {
 				continue
 			}
 
-			w.eventChannel <- ResourceEvent{
+			w.enqueue("StatefulSet", ResourceEvent{
 				Type:      event.Type,
 				Namespace: namespace,
 				Name:      sts.Name,
 				Resource:  sts,
-			}
+			})
 
 			log.WithFields(logrus.Fields{
 				"type": event.Type,
@@ -168,12 +284,12 @@ func (w *Watcher) watchPods(ctx context.Context, namespace string) {
 				continue
 			}
 
-			w.eventChannel <- ResourceEvent{
+			w.enqueue("Pod", ResourceEvent{
 				Type:      event.Type,
 				Namespace: namespace,
 				Name:      pod.Name,
 				Resource:  pod,
-			}
+			})
 
 			log.WithFields(logrus.Fields{
 				"type":  event.Type,
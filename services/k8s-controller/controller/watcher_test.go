@@ -0,0 +1,40 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestWatcherCoalescesEvents(t *testing.T) {
+	w := NewWatcher(fake.NewSimpleClientset(), "nest-team-", time.Second)
+	w.enqueue("Pod", ResourceEvent{Type: watch.Added, Namespace: "nest-team-a", Name: "db-0"})
+	w.enqueue("Pod", ResourceEvent{Type: watch.Modified, Namespace: "nest-team-a", Name: "db-1"})
+	w.enqueue("Pod", ResourceEvent{Type: watch.Deleted, Namespace: "nest-team-a", Name: "db-0"})
+	w.enqueue("StatefulSet", ResourceEvent{Type: watch.Modified, Namespace: "nest-team-a", Name: "db-0"})
+	w.flush()
+
+	if len(w.eventChannel) != 3 {
+		t.Fatalf("Expected 3 coalesced events, got %d", len(w.eventChannel))
+	}
+	if event := <-w.eventChannel; event.Name != "db-0" || event.Type != watch.Deleted {
+		t.Errorf("Expected the latest event of db-0 first, got %+v", event)
+	}
+}
+
+func TestWatcherOverflowSignalsResync(t *testing.T) {
+	w := NewWatcher(fake.NewSimpleClientset(), "nest-team-", time.Second)
+	for i := 0; i < cap(w.eventChannel); i++ {
+		w.eventChannel <- ResourceEvent{}
+	}
+	w.enqueue("Pod", ResourceEvent{Type: watch.Modified, Namespace: "nest-team-a", Name: "db-0"})
+	w.flush()
+
+	select {
+	case <-w.Resync():
+	default:
+		t.Error("Expected a resync after dropping events")
+	}
+}
@@ -0,0 +1,319 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/penguintechinc/nest/services/k8s-controller/pkg/locks"
+	"github.com/penguintechinc/nest/services/k8s-controller/pkg/models"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// reconcileChannel is the Postgres NOTIFY channel the API signals when a
+// reconcile is requested
+const reconcileChannel = "nest_reconcile"
+
+// Reconcile outcomes recorded in reconcile_statuses
+const (
+	reconcileOutcomeSuccess = "success"
+	reconcileOutcomeError   = "error"
+)
+
+// listenLoop waits for reconcile notifications on a dedicated connection and
+// dispatches queued requests to the workers. Requests are also dispatched on
+// every (re)connect and reconcile interval so none are missed while the
+// listener is down.
+func (c *Controller) listenLoop(ctx context.Context) {
+	defer c.wg.Done()
+
+	log := c.log.WithField("component", "reconcile_listener")
+	backoff := c.config.BackoffBase
+
+	for {
+		err := c.listen(ctx, log)
+		select {
+		case <-ctx.Done():
+			return
+		case <-c.stopChan:
+			return
+		default:
+		}
+
+		log.WithError(err).WithField("retry_in", backoff).Warn("Reconcile listener disconnected")
+		select {
+		case <-ctx.Done():
+			return
+		case <-c.stopChan:
+			return
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > c.config.BackoffMax {
+			backoff = c.config.BackoffMax
+		}
+	}
+}
+
+// listen runs a single LISTEN session until it fails or the controller stops
+func (c *Controller) listen(ctx context.Context, log *logrus.Entry) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-c.stopChan:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	conn, err := pgx.Connect(ctx, c.config.GetDSN())
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+reconcileChannel); err != nil {
+		return err
+	}
+	log.Info("Listening for reconcile requests")
+
+	c.dispatchRequests(ctx)
+	for {
+		waitCtx, waitCancel := context.WithTimeout(ctx, c.current().ReconcileInterval)
+		_, err := conn.WaitForNotification(waitCtx)
+		waitCancel()
+		if err != nil && ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil && waitCtx.Err() == nil {
+			return err
+		}
+		c.dispatchRequests(ctx)
+	}
+}
+
+// dispatchRequests claims every pending reconcile request and queues its
+// resource for the workers. Claiming marks requests processed atomically, so
+// each request is handled by a single controller instance. A draining
+// instance leaves requests to the others.
+func (c *Controller) dispatchRequests(ctx context.Context) {
+	if c.draining.Load() {
+		return
+	}
+
+	var claimed []models.ReconcileRequest
+	if err := c.db.WithContext(ctx).Model(&claimed).
+		Clauses(clause.Returning{Columns: []clause.Column{{Name: "resource_id"}}}).
+		Where("processed_at IS NULL AND deleted_at IS NULL").
+		Update("processed_at", time.Now().UTC()).Error; err != nil {
+		c.log.WithError(err).Error("Failed to claim reconcile requests")
+		return
+	}
+
+	if len(claimed) == 0 {
+		return
+	}
+
+	ids := make([]uint, 0, len(claimed))
+	for _, request := range claimed {
+		ids = append(ids, request.ResourceID)
+	}
+	var resources []models.Resource
+	if err := c.db.WithContext(ctx).Select("id", "team_id", "environment", "priority_class").
+		Where("id IN ?", ids).Find(&resources).Error; err != nil {
+		c.log.WithError(err).Warn("Failed to load priority classes of requested resources")
+	}
+	items := make(map[uint]workItem, len(resources))
+	for _, resource := range resources {
+		items[resource.ID] = workItem{
+			teamID: resource.TeamID,
+			class:  c.config.PriorityClassOf(resource.PriorityClass, resource.Environment),
+		}
+	}
+
+	for _, id := range ids {
+		item, ok := items[id]
+		if !ok {
+			item.class = c.config.DefaultPriorityClass
+		}
+		item.resourceID = id
+		item.requested = true
+		c.queue.Push(item)
+	}
+}
+
+// reconcileRequested reconciles a resource on demand, ignoring any backoff
+// from earlier failures
+func (c *Controller) reconcileRequested(ctx context.Context, resourceID uint, log *logrus.Entry) {
+	var resource models.Resource
+	if err := c.db.WithContext(ctx).
+		Where("id = ? AND lifecycle_mode = ?", resourceID, "full").
+		Where("deleted_at IS NULL OR deletion_state = ?", deletionStateDeleting).
+		First(&resource).Error; err != nil {
+		log.WithError(err).WithField("resource_id", resourceID).Warn("Skipping reconcile request")
+		return
+	}
+
+	log.WithField("resource_id", resourceID).Info("Reconciling on request")
+	c.reconcileOne(ctx, &resource)
+}
+
+// reconcileScheduled reconciles a resource queued by the full
+// reconciliation, unless it was deleted or left full lifecycle management
+// while queued
+func (c *Controller) reconcileScheduled(ctx context.Context, resourceID uint, log *logrus.Entry) {
+	var resource models.Resource
+	err := c.db.WithContext(ctx).
+		Where("id = ? AND lifecycle_mode = ?", resourceID, "full").
+		Where("deleted_at IS NULL OR deletion_state = ?", deletionStateDeleting).
+		First(&resource).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return
+	}
+	if err != nil {
+		log.WithError(err).WithField("resource_id", resourceID).Error("Failed to load resource to reconcile")
+		return
+	}
+
+	c.reconcileOne(ctx, &resource)
+}
+
+// reconcileOne reconciles a single resource, updates its retry state, and
+// records the outcome. A resource already being reconciled, by this worker
+// pool or by another controller replica, is skipped.
+func (c *Controller) reconcileOne(ctx context.Context, resource *models.Resource) {
+	if _, busy := c.inFlight.LoadOrStore(resource.ID, struct{}{}); busy {
+		return
+	}
+	defer c.inFlight.Delete(resource.ID)
+
+	release, ok, err := locks.TryLock(ctx, c.db, locks.Key("reconcile", resource.ID))
+	if err != nil {
+		c.log.WithError(err).WithField("resource_id", resource.ID).Warn("Failed to lock resource for reconcile")
+		return
+	}
+	if !ok {
+		return
+	}
+	defer release()
+
+	operations := c.pendingOperations(ctx, resource.ID)
+	if err = c.faults.DelayReconcile(ctx); err == nil {
+		err = c.reconciler.ReconcileResource(ctx, resource)
+	}
+	if err != nil {
+		c.log.WithFields(logrus.Fields{
+			"resource_id": resource.ID,
+			"error":       err,
+		}).Error("Failed to reconcile resource")
+
+		c.addToRetryQueue(resource.ID)
+	} else {
+		c.removeFromRetryQueue(resource.ID)
+	}
+
+	c.recordReconcile(ctx, resource.ID, err)
+	c.recordResourceError(ctx, resource, err)
+	if err == nil {
+		c.recordObservedGeneration(ctx, resource)
+	}
+	c.updateConditions(ctx, resource, err)
+	c.settleOperations(ctx, operations, err)
+	c.lastReconcile.Store(time.Now().UnixNano())
+}
+
+// recordResourceError surfaces a reconcile failure on the resource itself so
+// users can see it through the API, and clears it after a success
+func (c *Controller) recordResourceError(ctx context.Context, resource *models.Resource, reconcileErr error) {
+	query := c.db.WithContext(ctx).Model(&models.Resource{}).Where("id = ?", resource.ID)
+
+	var err error
+	if reconcileErr != nil {
+		err = query.UpdateColumns(map[string]interface{}{
+			"last_error":           reconcileErr.Error(),
+			"last_error_at":        time.Now().UTC(),
+			"consecutive_failures": gorm.Expr("consecutive_failures + 1"),
+		}).Error
+	} else if resource.ConsecutiveFailures > 0 || resource.LastError != "" {
+		err = query.UpdateColumns(map[string]interface{}{
+			"last_error":           "",
+			"last_error_at":        nil,
+			"consecutive_failures": 0,
+		}).Error
+	}
+	if err != nil {
+		c.log.WithError(err).WithField("resource_id", resource.ID).Warn("Failed to record resource error")
+	}
+}
+
+// recordObservedGeneration records that the resource's spec, as of the
+// generation loaded for the reconcile, has been acted on. A later change
+// keeps its newer generation unobserved until the next reconcile.
+func (c *Controller) recordObservedGeneration(ctx context.Context, resource *models.Resource) {
+	if resource.ObservedGeneration >= resource.Generation {
+		return
+	}
+	if err := c.db.WithContext(ctx).Model(&models.Resource{}).
+		Where("id = ? AND observed_generation < ?", resource.ID, resource.Generation).
+		UpdateColumn("observed_generation", resource.Generation).Error; err != nil {
+		c.log.WithError(err).WithField("resource_id", resource.ID).Warn("Failed to record observed generation")
+	}
+}
+
+// recordReconcile upserts the resource's row in reconcile_statuses
+func (c *Controller) recordReconcile(ctx context.Context, resourceID uint, reconcileErr error) {
+	now := time.Now().UTC()
+	status := models.ReconcileStatus{
+		ResourceID:      resourceID,
+		LastReconcileAt: &now,
+		LastOutcome:     reconcileOutcomeSuccess,
+		InstanceID:      c.config.InstanceID,
+	}
+	if reconcileErr != nil {
+		status.LastOutcome = reconcileOutcomeError
+		status.LastError = reconcileErr.Error()
+	}
+
+	c.retryMutex.RLock()
+	if entry, ok := c.retryQueue[resourceID]; ok {
+		status.RetryCount = entry.retryCount
+		next := entry.nextRetry.UTC()
+		status.NextRetryAt = &next
+	}
+	c.retryMutex.RUnlock()
+
+	if err := c.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "resource_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"last_reconcile_at", "last_outcome", "last_error", "retry_count",
+			"next_retry_at", "instance_id", "updated_at",
+		}),
+	}).Create(&status).Error; err != nil {
+		c.log.WithError(err).WithField("resource_id", resourceID).Warn("Failed to record reconcile status")
+	}
+}
+
+// loadRetryQueue restores retry state persisted in reconcile_statuses so that
+// backoff for failing resources survives controller restarts
+func (c *Controller) loadRetryQueue(ctx context.Context) error {
+	var statuses []models.ReconcileStatus
+	if err := c.db.WithContext(ctx).Where("retry_count > 0").Find(&statuses).Error; err != nil {
+		return err
+	}
+
+	c.retryMutex.Lock()
+	defer c.retryMutex.Unlock()
+	for _, status := range statuses {
+		entry := &retryEntry{resourceID: status.ResourceID, retryCount: status.RetryCount}
+		if status.NextRetryAt != nil {
+			entry.nextRetry = *status.NextRetryAt
+		}
+		c.retryQueue[status.ResourceID] = entry
+	}
+
+	c.log.WithField("count", len(statuses)).Info("Restored retry queue")
+	return nil
+}
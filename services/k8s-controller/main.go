@@ -2,15 +2,30 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
 	"github.com/penguintechinc/nest/services/k8s-controller/controller"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
 	"github.com/penguintechinc/nest/services/k8s-controller/pkg/config"
+	"github.com/penguintechinc/nest/services/k8s-controller/pkg/configfile"
+	"github.com/penguintechinc/nest/services/k8s-controller/pkg/diagnostics"
+	"github.com/penguintechinc/nest/services/k8s-controller/pkg/loglevel"
+	"github.com/penguintechinc/nest/services/k8s-controller/pkg/mtls"
+	"github.com/penguintechinc/nest/services/k8s-controller/pkg/servertls"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/sirupsen/logrus"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -23,6 +38,32 @@ var (
 )
 
 func main() {
+	configFile := flag.String("config", os.Getenv("CONFIG_FILE"), "YAML config file; environment variables override its settings")
+	validateConfig := flag.Bool("validate-config", false, "Validate the configuration, print the problems found and exit")
+	flag.Parse()
+
+	// Apply the config file to the environment before reading it
+	var source *configfile.Source
+	if *configFile != "" {
+		source = configfile.NewSource(*configFile)
+		if _, err := source.Apply(); err != nil {
+			if *validateConfig {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			logrus.WithError(err).Fatal("Failed to load config file")
+		}
+	}
+
+	if *validateConfig {
+		if _, err := config.LoadConfig(); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid configuration:\n%v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println("Configuration is valid")
+		return
+	}
+
 	logrus.WithFields(logrus.Fields{
 		"version":    version,
 		"build_time": buildTime,
@@ -35,6 +76,10 @@ func main() {
 		logrus.WithError(err).Fatal("Failed to load configuration")
 	}
 
+	cfg.Version = version
+	cfg.BuildTime = buildTime
+	cfg.GitCommit = gitCommit
+
 	// Setup logging
 	if err := cfg.SetupLogging(); err != nil {
 		logrus.WithError(err).Fatal("Failed to setup logging")
@@ -46,15 +91,54 @@ func main() {
 		"worker_count":        cfg.WorkerCount,
 		"namespace_prefix":    cfg.NamespacePrefix,
 	}).Info("Configuration loaded")
+	if source != nil {
+		overridden, _ := source.Overridden()
+		logrus.WithFields(logrus.Fields{
+			"file":       source.Path(),
+			"overridden": overridden,
+		}).Info("Config file loaded")
+	}
+
+	// Issue the service identities for mutual TLS, including the
+	// controller's own, which it needs before it can reach Postgres
+	var identity *mtls.Identity
+	var issuer *controller.IdentityIssuer
+	if cfg.MTLSEnabled {
+		identity = &mtls.Identity{}
+		issuer, err = controller.NewIdentityIssuer(cfg, identity)
+		if err != nil {
+			logrus.WithError(err).Fatal("Failed to create identity issuer")
+		}
+		if err := issuer.Issue(context.Background()); err != nil {
+			logrus.WithError(err).Fatal("Failed to issue service identities")
+		}
+	}
 
 	// Connect to database
-	db, err := connectDatabase(cfg)
+	db, err := connectDatabase(cfg, identity)
 	if err != nil {
 		logrus.WithError(err).Fatal("Failed to connect to database")
 	}
 
 	logrus.Info("Database connection established")
 
+	// Check the API has migrated a schema this controller works with
+	schema, err := controller.CheckSchema(context.Background(), db)
+	switch {
+	case errors.Is(err, controller.ErrSchemaIncompatible) && cfg.SchemaSkewPolicy == "warn":
+		logrus.WithError(err).Warn("Starting despite an incompatible schema version")
+	case err != nil:
+		logrus.WithError(err).Fatal("Schema version check failed")
+	case schema == nil:
+		logrus.Warn("The API doesn't record its schema version; skipping the compatibility check")
+	default:
+		logrus.WithFields(logrus.Fields{
+			"schema_version": controller.SchemaVersion,
+			"api_version":    schema.APIVersion,
+			"api_schema":     schema.SchemaVersion,
+		}).Info("Schema version is compatible")
+	}
+
 	// Create controller
 	ctrl, err := controller.NewController(cfg, db)
 	if err != nil {
@@ -65,14 +149,66 @@ func main() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	// Renew service identities before they expire
+	if issuer != nil {
+		go issuer.Run(ctx)
+	}
+
+	// Serve health checks over TLS with the certificate files, when set,
+	// and metrics with them or the service identity, verifying client
+	// certificates as METRICS_CLIENT_AUTH sets
+	settings := servertls.Settings{
+		CertFile:     cfg.TLSCertFile,
+		KeyFile:      cfg.TLSKeyFile,
+		MinVersion:   cfg.TLSMinVersion,
+		CipherSuites: cfg.TLSCipherSuites,
+	}
+	healthTLS, err := settings.ServerConfig(nil)
+	if err != nil {
+		logrus.WithError(err).Fatal("Invalid TLS configuration")
+	}
+	var identityTLS *tls.Config
+	if identity != nil {
+		identityTLS = identity.ServerConfig()
+	}
+	settings.ClientCAFile = cfg.TLSClientCAFile
+	settings.ClientAuth = cfg.MetricsClientAuth
+	metricsTLS, err := settings.ServerConfig(identityTLS)
+	if err != nil {
+		logrus.WithError(err).Fatal("Invalid metrics TLS configuration")
+	}
+
 	// Start health check server
 	if cfg.EnableHealthCheck {
-		go startHealthServer(cfg.HealthCheckPort)
+		go startHealthServer(cfg.BindAddress, cfg.HealthCheckPort, healthTLS, ctrl)
+	}
+
+	// Build metrics registry
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	registry.MustRegister(ctrl.Collectors()...)
+	if cfg.ExposeResourceMetrics {
+		registry.MustRegister(controller.NewResourceMetricsCollector(db))
 	}
 
-	// Start metrics server
+	// Start metrics server, with the diagnostics endpoints when enabled
 	if cfg.EnableMetrics {
-		go startMetricsServer(cfg.MetricsPort)
+		var debug http.Handler
+		if cfg.EnableDebugEndpoints {
+			debug = ctrl.DebugHandler()
+			if cfg.DebugLocalOnly {
+				debug = diagnostics.LocalOnly(debug)
+			}
+		}
+		go startMetricsServer(cfg.BindAddress, cfg.MetricsPort, registry, metricsTLS, debug)
+	}
+
+	// Start remote write to an external Prometheus
+	if cfg.RemoteWriteURL != "" {
+		go controller.NewRemoteWriter(cfg, registry).Run(ctx)
 	}
 
 	// Start controller
@@ -80,11 +216,25 @@ func main() {
 		logrus.WithError(err).Fatal("Failed to start controller")
 	}
 
-	// Wait for interrupt signal
+	// Wait for interrupt signal, reloading the configuration on SIGHUP,
+	// toggling debug logging on SIGUSR1 and draining on SIGUSR2
 	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
-
-	<-sigChan
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGUSR1, syscall.SIGUSR2)
+
+loop:
+	for sig := range sigChan {
+		switch sig {
+		case syscall.SIGHUP:
+			reloadConfig(source, ctrl)
+		case syscall.SIGUSR1:
+			debug := loglevel.Standard().ToggleDebug()
+			logrus.WithField("debug", debug).Warn("Debug logging toggled by SIGUSR1")
+		case syscall.SIGUSR2:
+			go ctrl.Drain(ctx)
+		default:
+			break loop
+		}
+	}
 	logrus.Info("Shutdown signal received")
 
 	// Graceful shutdown
@@ -94,11 +244,50 @@ func main() {
 	logrus.Info("Controller shutdown complete")
 }
 
-// connectDatabase establishes a connection to the PostgreSQL database
-func connectDatabase(cfg *config.Config) (*gorm.DB, error) {
+// reloadConfig rereads the config file, when there is one, and the
+// environment, and applies the hot-reloadable settings: the log level, the
+// reconcile interval and the optional subsystems enabled. An invalid
+// configuration is logged and the running one kept.
+func reloadConfig(source *configfile.Source, ctrl *controller.Controller) {
+	if source != nil {
+		changed, err := source.Apply()
+		if err != nil {
+			logrus.WithError(err).Error("Failed to reload config file; keeping the running configuration")
+			return
+		}
+		logrus.WithField("changed", changed).Info("Config file reloaded")
+	}
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		logrus.WithError(err).Error("Invalid configuration; keeping the running configuration")
+		return
+	}
+	cfg.Version = version
+	cfg.BuildTime = buildTime
+	cfg.GitCommit = gitCommit
+	if err := cfg.ApplyLogLevel(); err != nil {
+		logrus.WithError(err).Error("Failed to apply log level")
+	}
+	ctrl.Reload(cfg)
+}
+
+// connectDatabase establishes a connection to the PostgreSQL database. With
+// a service identity, connections use mutual TLS instead of DB_SSL_MODE.
+func connectDatabase(cfg *config.Config, identity *mtls.Identity) (*gorm.DB, error) {
 	dsn := cfg.GetDSN()
 
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
+	dialector := postgres.Open(dsn)
+	if identity != nil {
+		connConfig, err := pgx.ParseConfig(dsn)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse database config: %w", err)
+		}
+		connConfig.TLSConfig = identity.ClientConfig(cfg.DBHost)
+		connConfig.Fallbacks = nil
+		dialector = postgres.New(postgres.Config{Conn: stdlib.OpenDB(*connConfig)})
+	}
+
+	db, err := gorm.Open(dialector, &gorm.Config{
 		Logger: NewGormLogger(),
 		NowFunc: func() time.Time {
 			return time.Now().UTC()
@@ -126,8 +315,9 @@ func connectDatabase(cfg *config.Config) (*gorm.DB, error) {
 	return db, nil
 }
 
-// startHealthServer starts the health check HTTP server
-func startHealthServer(port int) {
+// startHealthServer starts the health check HTTP server on a bind address,
+// or on every address when it is empty. It serves HTTPS with a TLS config.
+func startHealthServer(bindAddress string, port int, tlsConfig *tls.Config, ctrl *controller.Controller) {
 	mux := http.NewServeMux()
 
 	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
@@ -135,12 +325,29 @@ func startHealthServer(port int) {
 		w.Write([]byte("ok"))
 	})
 
+	// A draining controller is no longer ready, and /drainz succeeds once
+	// the drain finishes, for preStop hooks to wait on
 	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		if ctrl.Draining() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte("draining"))
+			return
+		}
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("ready"))
 	})
 
-	addr := fmt.Sprintf(":%d", port)
+	mux.HandleFunc("/drainz", func(w http.ResponseWriter, r *http.Request) {
+		if !ctrl.Drained() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte("not drained"))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("drained"))
+	})
+
+	addr := net.JoinHostPort(bindAddress, strconv.Itoa(port))
 	logrus.WithField("address", addr).Info("Starting health check server")
 
 	server := &http.Server{
@@ -151,22 +358,31 @@ func startHealthServer(port int) {
 		IdleTimeout:  120 * time.Second,
 	}
 
-	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+	var err error
+	if tlsConfig != nil {
+		server.TLSConfig = tlsConfig
+		err = server.ListenAndServeTLS("", "")
+	} else {
+		err = server.ListenAndServe()
+	}
+	if err != nil && err != http.ErrServerClosed {
 		logrus.WithError(err).Error("Health check server failed")
 	}
 }
 
-// startMetricsServer starts the Prometheus metrics HTTP server
-func startMetricsServer(port int) {
+// startMetricsServer starts the Prometheus metrics HTTP server. It serves
+// HTTPS with a TLS config, which for the service identity only accepts
+// clients with a certificate from the internal CA. A debug handler, when
+// given, serves /debug/.
+func startMetricsServer(bindAddress string, port int, registry *prometheus.Registry, tlsConfig *tls.Config, debug http.Handler) {
 	mux := http.NewServeMux()
 
-	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
-		// TODO: Implement Prometheus metrics
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("# Metrics endpoint\n"))
-	})
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	if debug != nil {
+		mux.Handle("/debug/", debug)
+	}
 
-	addr := fmt.Sprintf(":%d", port)
+	addr := net.JoinHostPort(bindAddress, strconv.Itoa(port))
 	logrus.WithField("address", addr).Info("Starting metrics server")
 
 	server := &http.Server{
@@ -176,8 +392,19 @@ func startMetricsServer(port int) {
 		WriteTimeout: 10 * time.Second,
 		IdleTimeout:  120 * time.Second,
 	}
+	if debug != nil {
+		// CPU profiles and traces are written for as long as they record
+		server.WriteTimeout = 2 * time.Minute
+	}
 
-	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+	var err error
+	if tlsConfig != nil {
+		server.TLSConfig = tlsConfig
+		err = server.ListenAndServeTLS("", "")
+	} else {
+		err = server.ListenAndServe()
+	}
+	if err != nil && err != http.ErrServerClosed {
 		logrus.WithError(err).Error("Metrics server failed")
 	}
 }
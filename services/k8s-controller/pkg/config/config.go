@@ -1,11 +1,16 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"regexp"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/penguintechinc/nest/services/k8s-controller/pkg/loglevel"
+	"github.com/penguintechinc/nest/services/k8s-controller/pkg/redact"
 	"github.com/sirupsen/logrus"
 )
 
@@ -18,6 +23,7 @@ type Config struct {
 	DBPassword string
 	DBName     string
 	DBSSL      string
+	DBSchema   string
 
 	// Kubernetes configuration
 	KubeConfig          string
@@ -31,10 +37,128 @@ type Config struct {
 	MaxRetries          int
 	BackoffBase         time.Duration
 	BackoffMax          time.Duration
+	// TeamMaxInFlight caps the reconciles of one team running at once, so
+	// a team can't take every worker; 0 is unlimited
+	TeamMaxInFlight int
+	// WatchDebounce is the window within which Kubernetes events of the
+	// same object are coalesced, keeping the latest
+	WatchDebounce time.Duration
+
+	// Priority classes of resources, highest first, with the class of
+	// resources that don't name one taken from their environment or else
+	// DefaultPriorityClass
+	PriorityClasses            []PriorityClass
+	EnvironmentPriorityClasses map[string]string
+	DefaultPriorityClass       string
+
+	// Fleet reporting configuration
+	InstanceID        string
+	ClusterName       string
+	HeartbeatInterval time.Duration
+	Version           string
+	BuildTime         string
+	GitCommit         string
+
+	// SchemaSkewPolicy is what the controller does when the API's schema
+	// doesn't support the schema version it was built for: warn, or refuse
+	// to start
+	SchemaSkewPolicy string
+
+	// DrainTimeout bounds how long a drain waits for reconciles in flight
+	DrainTimeout time.Duration
+
+	// Pod security defaults applied to generated workloads
+	PodRunAsNonRoot     bool
+	PodReadOnlyRootFS   bool
+	PodSeccompProfile   string
+	PodDropCapabilities []string
+
+	// Stats collection configuration
+	EnableStatsCollection bool
+	StatsInterval         time.Duration
+	StatsTopQueries       int
+	StatsQueryTimeout     time.Duration
+	CredentialStaleAfter  time.Duration
+
+	// Discovery of unmanaged databases in team namespaces
+	EnableDiscovery   bool
+	DiscoveryInterval time.Duration
+
+	// Audit of resources' records against the cluster
+	EnableConsistencyAudit   bool
+	ConsistencyAuditInterval time.Duration
+
+	// Distribution of CA trust bundles to team namespaces
+	EnableTrustBundles  bool
+	TrustBundleInterval time.Duration
+
+	// Secrets kept in application namespaces for consumer bindings
+	EnableConsumerBindings bool
+	BindingSyncInterval    time.Duration
+
+	// Crossplane-style claims fulfilled with NEST resources
+	EnableClaims      bool
+	ClaimSyncInterval time.Duration
+	ClaimResources    []string
+
+	// Prometheus integration
+	ExposeResourceMetrics  bool
+	RemoteWriteURL         string
+	RemoteWriteInterval    time.Duration
+	RemoteWriteUsername    string
+	RemoteWritePassword    string
+	RemoteWriteBearerToken string
+
+	// Runtime diagnostics and pprof profiles on the metrics server
+	EnableDebugEndpoints bool
+	DebugLocalOnly       bool
 
 	// Logging configuration
 	LogLevel            string
 	LogFormat           string
+	RedactPatterns      []string
+	Redactor            *redact.Redactor
+
+	// Mutual TLS configuration
+	MTLSEnabled       bool
+	MTLSNamespace     string
+	MTLSIdentity      string
+	MTLSIdentities    []string
+	MTLSCertTTL       time.Duration
+	MTLSCheckInterval time.Duration
+
+	// Networking of generated Services and the controller's own servers.
+	// An empty BindAddress listens on every address of both families.
+	ServiceIPFamilyPolicy string
+	ServiceIPFamilies     []string
+	BindAddress           string
+
+	// TLS of the health and metrics servers. With mutual TLS, the metrics
+	// server presents the service identity unless certificate files are set.
+	TLSCertFile       string
+	TLSKeyFile        string
+	TLSMinVersion     string
+	TLSCipherSuites   []string
+	TLSClientCAFile   string
+	MetricsClientAuth string
+
+	// Rego policy enforcement during reconcile; off when OPAURL is empty
+	OPAURL string
+
+	// How long provisioning jobs of each type may run before they are
+	// stopped, and how often the API is polled for jobs to cancel
+	JobTimeouts           map[string]time.Duration
+	JobCancelPollInterval time.Duration
+
+	// Fault injection for chaos testing: rates from 0 to 1 of Kubernetes
+	// API errors, database timeouts and slowed reconciles. Never enable it
+	// in production.
+	FaultInjectionEnabled  bool
+	FaultSeed              int64
+	FaultK8sErrorRate      float64
+	FaultDBTimeoutRate     float64
+	FaultSlowReconcileRate float64
+	FaultMaxReconcileDelay time.Duration
 
 	// Feature flags
 	EnableMetrics       bool
@@ -43,62 +167,375 @@ type Config struct {
 	HealthCheckPort     int
 }
 
-// LoadConfig loads configuration from environment variables
+// LoadConfig loads configuration from environment variables, which a
+// config file applied with configfile may have set. Values that don't
+// parse, or are out of range, are reported as errors naming the variable.
 func LoadConfig() (*Config, error) {
+	env := &envReader{}
 	config := &Config{
 		// Database defaults
-		DBHost:     getEnv("DB_HOST", "localhost"),
-		DBPort:     getEnvInt("DB_PORT", 5432),
-		DBUser:     getEnv("DB_USER", "nest"),
-		DBPassword: getEnv("DB_PASSWORD", ""),
-		DBName:     getEnv("DB_NAME", "nest"),
-		DBSSL:      getEnv("DB_SSL_MODE", "disable"),
+		DBHost:     env.getEnv("DB_HOST", "localhost"),
+		DBPort:     env.getEnvInt("DB_PORT", 5432),
+		DBUser:     env.getEnv("DB_USER", "nest"),
+		DBPassword: env.getEnv("DB_PASSWORD", ""),
+		DBName:     env.getEnv("DB_NAME", "nest"),
+		DBSSL:      env.getEnv("DB_SSL_MODE", "disable"),
+		DBSchema:   env.getEnv("DB_SCHEMA", ""),
 
 		// Kubernetes defaults
-		KubeConfig:         getEnv("KUBECONFIG", ""),
-		InCluster:          getEnvBool("IN_CLUSTER", true),
-		WatchAllNamespaces: getEnvBool("WATCH_ALL_NAMESPACES", false),
-		NamespacePrefix:    getEnv("NAMESPACE_PREFIX", "nest-team-"),
+		KubeConfig:         env.getEnv("KUBECONFIG", ""),
+		InCluster:          env.getEnvBool("IN_CLUSTER", true),
+		WatchAllNamespaces: env.getEnvBool("WATCH_ALL_NAMESPACES", false),
+		NamespacePrefix:    env.getEnv("NAMESPACE_PREFIX", "nest-team-"),
 
 		// Controller defaults
-		ReconcileInterval: getEnvDuration("RECONCILE_INTERVAL", 30*time.Second),
-		WorkerCount:       getEnvInt("WORKER_COUNT", 5),
-		MaxRetries:        getEnvInt("MAX_RETRIES", 3),
-		BackoffBase:       getEnvDuration("BACKOFF_BASE", 5*time.Second),
-		BackoffMax:        getEnvDuration("BACKOFF_MAX", 5*time.Minute),
+		ReconcileInterval: env.getEnvDuration("RECONCILE_INTERVAL", 30*time.Second),
+		WorkerCount:       env.getEnvInt("WORKER_COUNT", 5),
+		MaxRetries:        env.getEnvInt("MAX_RETRIES", 3),
+		BackoffBase:       env.getEnvDuration("BACKOFF_BASE", 5*time.Second),
+		BackoffMax:        env.getEnvDuration("BACKOFF_MAX", 5*time.Minute),
+		TeamMaxInFlight:   env.getEnvInt("TEAM_MAX_IN_FLIGHT", 0),
+		WatchDebounce:     env.getEnvDuration("WATCH_DEBOUNCE", time.Second),
+
+		// Fleet reporting defaults
+		InstanceID:        env.getEnv("POD_NAME", hostname()),
+		ClusterName:       env.getEnv("CLUSTER_NAME", "default"),
+		HeartbeatInterval: env.getEnvDuration("HEARTBEAT_INTERVAL", 15*time.Second),
+		SchemaSkewPolicy:  env.getEnv("SCHEMA_SKEW_POLICY", "refuse"),
+		DrainTimeout:      env.getEnvDuration("DRAIN_TIMEOUT", 2*time.Minute),
+
+		// Pod security defaults
+		PodRunAsNonRoot:     env.getEnvBool("POD_RUN_AS_NON_ROOT", true),
+		PodReadOnlyRootFS:   env.getEnvBool("POD_READ_ONLY_ROOT_FS", true),
+		PodSeccompProfile:   env.getEnv("POD_SECCOMP_PROFILE", "RuntimeDefault"),
+		PodDropCapabilities: env.getEnvList("POD_DROP_CAPABILITIES", []string{"ALL"}),
+
+		// Stats collection defaults
+		EnableStatsCollection: env.getEnvBool("ENABLE_STATS_COLLECTION", true),
+		StatsInterval:         env.getEnvDuration("STATS_INTERVAL", 60*time.Second),
+		StatsTopQueries:       env.getEnvInt("STATS_TOP_QUERIES", 10),
+		StatsQueryTimeout:     env.getEnvDuration("STATS_QUERY_TIMEOUT", 10*time.Second),
+		CredentialStaleAfter:  env.getEnvDuration("CREDENTIAL_STALE_AFTER", 90*24*time.Hour),
+
+		// Discovery defaults
+		EnableDiscovery:   env.getEnvBool("ENABLE_DISCOVERY", true),
+		DiscoveryInterval: env.getEnvDuration("DISCOVERY_INTERVAL", 5*time.Minute),
+
+		// Consistency audit defaults
+		EnableConsistencyAudit:   env.getEnvBool("ENABLE_CONSISTENCY_AUDIT", true),
+		ConsistencyAuditInterval: env.getEnvDuration("CONSISTENCY_AUDIT_INTERVAL", 24*time.Hour),
+
+		// Trust bundle defaults
+		EnableTrustBundles:  env.getEnvBool("ENABLE_TRUST_BUNDLES", true),
+		TrustBundleInterval: env.getEnvDuration("TRUST_BUNDLE_INTERVAL", 5*time.Minute),
+
+		// Consumer binding defaults
+		EnableConsumerBindings: env.getEnvBool("ENABLE_CONSUMER_BINDINGS", true),
+		BindingSyncInterval:    env.getEnvDuration("BINDING_SYNC_INTERVAL", 30*time.Second),
+
+		// Claim defaults
+		EnableClaims:      env.getEnvBool("ENABLE_CLAIMS", true),
+		ClaimSyncInterval: env.getEnvDuration("CLAIM_SYNC_INTERVAL", 30*time.Second),
+		ClaimResources:    env.getEnvList("CLAIM_RESOURCES", []string{"databaseclaims.nest.penguintech.io/v1alpha1"}),
+
+		// Prometheus integration defaults
+		ExposeResourceMetrics:  env.getEnvBool("EXPOSE_RESOURCE_METRICS", true),
+		RemoteWriteURL:         env.getEnv("REMOTE_WRITE_URL", ""),
+		RemoteWriteInterval:    env.getEnvDuration("REMOTE_WRITE_INTERVAL", 30*time.Second),
+		RemoteWriteUsername:    env.getEnv("REMOTE_WRITE_USERNAME", ""),
+		RemoteWritePassword:    env.getEnv("REMOTE_WRITE_PASSWORD", ""),
+		RemoteWriteBearerToken: env.getEnv("REMOTE_WRITE_BEARER_TOKEN", ""),
+
+		// Diagnostics defaults
+		EnableDebugEndpoints: env.getEnvBool("ENABLE_DEBUG_ENDPOINTS", false),
+		DebugLocalOnly:       env.getEnvBool("DEBUG_LOCAL_ONLY", true),
 
 		// Logging defaults
-		LogLevel:  getEnv("LOG_LEVEL", "info"),
-		LogFormat: getEnv("LOG_FORMAT", "json"),
+		LogLevel:       env.getEnv("LOG_LEVEL", "info"),
+		LogFormat:      env.getEnv("LOG_FORMAT", "json"),
+		RedactPatterns: env.getEnvList("REDACT_PATTERNS", nil),
+
+		// Mutual TLS defaults
+		MTLSEnabled:       env.getEnvBool("MTLS_ENABLED", false),
+		MTLSNamespace:     env.getEnv("MTLS_NAMESPACE", env.getEnv("POD_NAMESPACE", "nest-system")),
+		MTLSIdentity:      env.getEnv("MTLS_IDENTITY", "nest-controller"),
+		MTLSIdentities:    env.getEnvList("MTLS_IDENTITIES", []string{"nest-api", "nest-controller"}),
+		MTLSCertTTL:       env.getEnvDuration("MTLS_CERT_TTL", 720*time.Hour),
+		MTLSCheckInterval: env.getEnvDuration("MTLS_CHECK_INTERVAL", time.Hour),
+
+		// Networking defaults
+		ServiceIPFamilyPolicy: env.getEnv("SERVICE_IP_FAMILY_POLICY", "PreferDualStack"),
+		ServiceIPFamilies:     env.getEnvList("SERVICE_IP_FAMILIES", nil),
+		BindAddress:           env.getEnv("BIND_ADDRESS", ""),
+
+		// TLS defaults
+		TLSCertFile:       env.getEnv("TLS_CERT_FILE", ""),
+		TLSKeyFile:        env.getEnv("TLS_KEY_FILE", ""),
+		TLSMinVersion:     env.getEnv("TLS_MIN_VERSION", ""),
+		TLSCipherSuites:   env.getEnvList("TLS_CIPHER_SUITES", nil),
+		TLSClientCAFile:   env.getEnv("TLS_CLIENT_CA_FILE", ""),
+		MetricsClientAuth: env.getEnv("METRICS_CLIENT_AUTH", ""),
+
+		// Policy defaults
+		OPAURL: env.getEnv("OPA_URL", ""),
+
+		// Job defaults
+		JobCancelPollInterval: env.getEnvDuration("JOB_CANCEL_POLL_INTERVAL", 5*time.Second),
+
+		// Fault injection defaults; the seed defaults to the start time
+		FaultInjectionEnabled:  env.getEnvBool("FAULT_INJECTION_ENABLED", false),
+		FaultSeed:              int64(env.getEnvInt("FAULT_SEED", int(time.Now().UnixNano()))),
+		FaultK8sErrorRate:      env.getEnvFloat("FAULT_K8S_ERROR_RATE", 0),
+		FaultDBTimeoutRate:     env.getEnvFloat("FAULT_DB_TIMEOUT_RATE", 0),
+		FaultSlowReconcileRate: env.getEnvFloat("FAULT_SLOW_RECONCILE_RATE", 0),
+		FaultMaxReconcileDelay: env.getEnvDuration("FAULT_MAX_RECONCILE_DELAY", 10*time.Second),
 
 		// Feature flags
-		EnableMetrics:     getEnvBool("ENABLE_METRICS", true),
-		MetricsPort:       getEnvInt("METRICS_PORT", 9090),
-		EnableHealthCheck: getEnvBool("ENABLE_HEALTH_CHECK", true),
-		HealthCheckPort:   getEnvInt("HEALTH_CHECK_PORT", 8080),
+		EnableMetrics:     env.getEnvBool("ENABLE_METRICS", true),
+		MetricsPort:       env.getEnvInt("METRICS_PORT", 9090),
+		EnableHealthCheck: env.getEnvBool("ENABLE_HEALTH_CHECK", true),
+		HealthCheckPort:   env.getEnvInt("HEALTH_CHECK_PORT", 8080),
+	}
+
+	// Report every value that doesn't parse at once, rather than falling
+	// back to defaults
+	if len(env.errs) > 0 {
+		return nil, errors.Join(env.errs...)
 	}
 
 	// Validate required fields
 	if config.DBPassword == "" {
 		return nil, fmt.Errorf("DB_PASSWORD is required")
 	}
+	if config.DBSchema != "" && !schemaNamePattern.MatchString(config.DBSchema) {
+		return nil, fmt.Errorf("invalid DB_SCHEMA %q", config.DBSchema)
+	}
+
+	if config.WorkerCount <= 0 {
+		return nil, fmt.Errorf("WORKER_COUNT must be positive")
+	}
+	if config.TeamMaxInFlight < 0 {
+		return nil, fmt.Errorf("TEAM_MAX_IN_FLIGHT must not be negative")
+	}
+	if config.WatchDebounce <= 0 {
+		return nil, fmt.Errorf("WATCH_DEBOUNCE must be positive")
+	}
+	if config.ReconcileInterval <= 0 {
+		return nil, fmt.Errorf("RECONCILE_INTERVAL must be positive")
+	}
+	if config.ConsistencyAuditInterval <= 0 {
+		return nil, fmt.Errorf("CONSISTENCY_AUDIT_INTERVAL must be positive")
+	}
+	if _, err := logrus.ParseLevel(config.LogLevel); err != nil {
+		return nil, fmt.Errorf("LOG_LEVEL must be one of debug, info, warn or error")
+	}
+	if config.LogFormat != "json" && config.LogFormat != "text" {
+		return nil, fmt.Errorf("LOG_FORMAT must be json or text")
+	}
+
+	if config.SchemaSkewPolicy != "warn" && config.SchemaSkewPolicy != "refuse" {
+		return nil, fmt.Errorf("SCHEMA_SKEW_POLICY must be warn or refuse")
+	}
+
+	switch config.ServiceIPFamilyPolicy {
+	case "SingleStack", "PreferDualStack", "RequireDualStack":
+	default:
+		return nil, fmt.Errorf("SERVICE_IP_FAMILY_POLICY must be SingleStack, PreferDualStack or RequireDualStack")
+	}
+	if len(config.ServiceIPFamilies) > 2 {
+		return nil, fmt.Errorf("SERVICE_IP_FAMILIES takes at most two families")
+	}
+	for _, family := range config.ServiceIPFamilies {
+		if family != "IPv4" && family != "IPv6" {
+			return nil, fmt.Errorf("invalid SERVICE_IP_FAMILIES entry %q", family)
+		}
+	}
+
+	if config.MTLSEnabled {
+		if config.MTLSCertTTL < time.Hour {
+			return nil, fmt.Errorf("MTLS_CERT_TTL must be at least 1h")
+		}
+		own := false
+		for _, name := range config.MTLSIdentities {
+			own = own || name == config.MTLSIdentity
+		}
+		if !own {
+			config.MTLSIdentities = append(config.MTLSIdentities, config.MTLSIdentity)
+		}
+	}
+
+	for name, rate := range map[string]float64{
+		"FAULT_K8S_ERROR_RATE":      config.FaultK8sErrorRate,
+		"FAULT_DB_TIMEOUT_RATE":     config.FaultDBTimeoutRate,
+		"FAULT_SLOW_RECONCILE_RATE": config.FaultSlowReconcileRate,
+	} {
+		if rate < 0 || rate > 1 {
+			return nil, fmt.Errorf("%s must be between 0 and 1", name)
+		}
+	}
+
+	classes, err := parsePriorityClasses(env.getEnv("PRIORITY_CLASSES", "critical=0:0,standard=0:0,sandbox=2:30"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid PRIORITY_CLASSES: %w", err)
+	}
+	config.PriorityClasses = classes
+	config.EnvironmentPriorityClasses = map[string]string{}
+	for _, pair := range env.getEnvList("ENVIRONMENT_PRIORITY_CLASSES", []string{"production=critical", "prod=critical", "dev=sandbox"}) {
+		environment, class, ok := strings.Cut(pair, "=")
+		if !ok || config.priorityClass(strings.TrimSpace(class)) == nil {
+			return nil, fmt.Errorf("ENVIRONMENT_PRIORITY_CLASSES entry %q is not environment=class with a class of PRIORITY_CLASSES", pair)
+		}
+		config.EnvironmentPriorityClasses[strings.TrimSpace(environment)] = strings.TrimSpace(class)
+	}
+	config.DefaultPriorityClass = env.getEnv("DEFAULT_PRIORITY_CLASS", "standard")
+	if config.priorityClass(config.DefaultPriorityClass) == nil {
+		return nil, fmt.Errorf("DEFAULT_PRIORITY_CLASS must be one of PRIORITY_CLASSES")
+	}
+
+	jobTimeouts, err := parseJobTimeouts(os.Getenv("JOB_TIMEOUTS"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid JOB_TIMEOUTS: %w", err)
+	}
+	config.JobTimeouts = jobTimeouts
+
+	redactor, err := redact.New(config.RedactPatterns)
+	if err != nil {
+		return nil, fmt.Errorf("invalid REDACT_PATTERNS: %w", err)
+	}
+	config.Redactor = redactor
 
 	return config, nil
 }
 
-// GetDSN returns the database connection string
+// PriorityClass is a class of resources reconciled ahead of the classes
+// after it, within its own limits
+type PriorityClass struct {
+	Name string
+	// Concurrency caps the class's reconciles running at once; 0 leaves
+	// every worker to it
+	Concurrency int
+	// RatePerMinute caps how many of the class's resources start
+	// reconciling each minute; 0 is unlimited
+	RatePerMinute int
+}
+
+// parsePriorityClasses parses a comma-separated list of
+// name=concurrency:rate classes, highest priority first
+func parsePriorityClasses(s string) ([]PriorityClass, error) {
+	var classes []PriorityClass
+	seen := map[string]bool{}
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, limits, ok := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		concurrency, rate, ok2 := strings.Cut(limits, ":")
+		if !ok || !ok2 || name == "" {
+			return nil, fmt.Errorf("priority class %q is not name=concurrency:rate", entry)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("priority class %s is listed twice", name)
+		}
+		seen[name] = true
+		class := PriorityClass{Name: name}
+		var err error
+		if class.Concurrency, err = strconv.Atoi(strings.TrimSpace(concurrency)); err != nil || class.Concurrency < 0 {
+			return nil, fmt.Errorf("concurrency of priority class %s must be a non-negative integer", name)
+		}
+		if class.RatePerMinute, err = strconv.Atoi(strings.TrimSpace(rate)); err != nil || class.RatePerMinute < 0 {
+			return nil, fmt.Errorf("rate of priority class %s must be a non-negative integer", name)
+		}
+		classes = append(classes, class)
+	}
+	if len(classes) == 0 {
+		return nil, fmt.Errorf("at least one priority class is required")
+	}
+	return classes, nil
+}
+
+// priorityClass returns the priority class named name, or nil
+func (c *Config) priorityClass(name string) *PriorityClass {
+	for i := range c.PriorityClasses {
+		if c.PriorityClasses[i].Name == name {
+			return &c.PriorityClasses[i]
+		}
+	}
+	return nil
+}
+
+// PriorityClassOf returns the priority class of a resource: the class it
+// names, if configured, or else its environment's, or else the default
+func (c *Config) PriorityClassOf(class, environment string) string {
+	if class != "" && c.priorityClass(class) != nil {
+		return class
+	}
+	if class, ok := c.EnvironmentPriorityClasses[environment]; ok {
+		return class
+	}
+	return c.DefaultPriorityClass
+}
+
+// defaultJobTimeout bounds provisioning jobs of a type without a timeout of
+// its own
+const defaultJobTimeout = time.Hour
+
+// parseJobTimeouts overrides the default job timeouts with a comma-separated
+// list of type=duration pairs, such as "create=45m,scale=30m". The API reads
+// the same JOB_TIMEOUTS to reap jobs no controller finished.
+func parseJobTimeouts(s string) (map[string]time.Duration, error) {
+	timeouts := map[string]time.Duration{
+		"create": 30 * time.Minute,
+		"update": 15 * time.Minute,
+		"scale":  15 * time.Minute,
+	}
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		jobType, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("job timeout %q is not type=duration", pair)
+		}
+		timeout, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil || timeout <= 0 {
+			return nil, fmt.Errorf("job timeout of %s must be a positive duration", jobType)
+		}
+		timeouts[strings.TrimSpace(jobType)] = timeout
+	}
+	return timeouts, nil
+}
+
+// JobTimeout returns how long a provisioning job of jobType may run
+func (c *Config) JobTimeout(jobType string) time.Duration {
+	if timeout, ok := c.JobTimeouts[jobType]; ok {
+		return timeout
+	}
+	return defaultJobTimeout
+}
+
+// schemaNamePattern matches the tenant schema names the API creates
+var schemaNamePattern = regexp.MustCompile(`^[a-z_][a-z0-9_]{0,62}$`)
+
+// GetDSN returns the database connection string. With DB_SCHEMA set, the
+// controller serves that tenant's schema and reads shared tables from
+// public.
 func (c *Config) GetDSN() string {
-	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+	dsn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
 		c.DBHost, c.DBPort, c.DBUser, c.DBPassword, c.DBName, c.DBSSL)
+	if c.DBSchema != "" {
+		dsn += fmt.Sprintf(" search_path=%s,public", c.DBSchema)
+	}
+	return dsn
 }
 
 // SetupLogging configures the logging system
 func (c *Config) SetupLogging() error {
-	level, err := logrus.ParseLevel(c.LogLevel)
-	if err != nil {
-		return fmt.Errorf("invalid log level: %w", err)
+	if err := c.ApplyLogLevel(); err != nil {
+		return err
 	}
-	logrus.SetLevel(level)
 
 	if c.LogFormat == "json" {
 		logrus.SetFormatter(&logrus.JSONFormatter{
@@ -111,41 +548,113 @@ func (c *Config) SetupLogging() error {
 		})
 	}
 
+	// Scrub secrets from every entry, including GORM's SQL traces
+	logrus.AddHook(redact.NewHook(c.Redactor))
+
+	// Filter entries by the level of their component, which can be changed
+	// at runtime
+	loglevel.Standard().Install()
+
+	return nil
+}
+
+// ApplyLogLevel sets the configured level of the logger to LogLevel, which
+// applies unless overridden at runtime. Unlike the rest of SetupLogging, it
+// takes effect again when the configuration is reloaded.
+func (c *Config) ApplyLogLevel() error {
+	level, err := logrus.ParseLevel(c.LogLevel)
+	if err != nil {
+		return fmt.Errorf("invalid log level: %w", err)
+	}
+	loglevel.Standard().SetConfigured(level)
 	return nil
 }
 
 // Helper functions for environment variables
 
-func getEnv(key, defaultValue string) string {
+// envReader reads settings from the environment, noting each value that
+// doesn't parse so they can be reported together
+type envReader struct {
+	errs []error
+}
+
+// invalid notes a value of key that doesn't parse as kind
+func (r *envReader) invalid(key, value, kind string) {
+	r.errs = append(r.errs, fmt.Errorf("%s: %q is not %s", key, value, kind))
+}
+
+func (r *envReader) getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
 	}
 	return defaultValue
 }
 
-func getEnvInt(key string, defaultValue int) int {
+func hostname() string {
+	if name, err := os.Hostname(); err == nil {
+		return name
+	}
+	return "unknown"
+}
+
+func (r *envReader) getEnvInt(key string, defaultValue int) int {
 	if value := os.Getenv(key); value != "" {
-		if intValue, err := strconv.Atoi(value); err == nil {
-			return intValue
+		intValue, err := strconv.Atoi(value)
+		if err != nil {
+			r.invalid(key, value, "an integer")
+			return defaultValue
 		}
+		return intValue
 	}
 	return defaultValue
 }
 
-func getEnvBool(key string, defaultValue bool) bool {
+func (r *envReader) getEnvBool(key string, defaultValue bool) bool {
 	if value := os.Getenv(key); value != "" {
-		if boolValue, err := strconv.ParseBool(value); err == nil {
-			return boolValue
+		boolValue, err := strconv.ParseBool(value)
+		if err != nil {
+			r.invalid(key, value, "true or false")
+			return defaultValue
 		}
+		return boolValue
 	}
 	return defaultValue
 }
 
-func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+func (r *envReader) getEnvFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		floatValue, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			r.invalid(key, value, "a number")
+			return defaultValue
+		}
+		return floatValue
+	}
+	return defaultValue
+}
+
+func (r *envReader) getEnvList(key string, defaultValue []string) []string {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return defaultValue
+	}
+	list := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func (r *envReader) getEnvDuration(key string, defaultValue time.Duration) time.Duration {
 	if value := os.Getenv(key); value != "" {
-		if duration, err := time.ParseDuration(value); err == nil {
-			return duration
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			r.invalid(key, value, "a duration, such as 30s or 5m")
+			return defaultValue
 		}
+		return duration
 	}
 	return defaultValue
 }
@@ -0,0 +1,121 @@
+package configfile
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeFile writes a config file into a temporary directory
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestParseFlattensKeys(t *testing.T) {
+	path := writeFile(t, `
+db:
+  host: postgres
+  port: 5432
+reconcile-interval: 30s
+tls_cipher_suites: [TLS_AES_128_GCM_SHA256, TLS_AES_256_GCM_SHA384]
+opa_url:
+`)
+	values, err := Parse(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"DB_HOST":            "postgres",
+		"DB_PORT":            "5432",
+		"RECONCILE_INTERVAL": "30s",
+		"TLS_CIPHER_SUITES":  "TLS_AES_128_GCM_SHA256,TLS_AES_256_GCM_SHA384",
+	}
+	if !reflect.DeepEqual(values, want) {
+		t.Errorf("Parsed %v, want %v", values, want)
+	}
+}
+
+func TestParseReportsProblemsWithLines(t *testing.T) {
+	path := writeFile(t, `db_host: a
+db:
+  host: b
+claim_resources:
+  - {name: x}
+`)
+	_, err := Parse(path)
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+	for _, want := range []string{"line 3: DB_HOST is set more than once", "line 5: CLAIM_RESOURCES must be a list of plain values"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected %q in %q", want, err)
+		}
+	}
+
+	if _, err := Parse(writeFile(t, "- a\n- b\n")); err == nil {
+		t.Error("Expected an error for a file that isn't a map")
+	}
+}
+
+func TestApplyKeepsEnvironmentAndTracksChanges(t *testing.T) {
+	t.Setenv("CONFIGFILE_TEST_ENV", "from-env")
+	os.Unsetenv("CONFIGFILE_TEST_A")
+	os.Unsetenv("CONFIGFILE_TEST_B")
+	t.Cleanup(func() {
+		os.Unsetenv("CONFIGFILE_TEST_A")
+		os.Unsetenv("CONFIGFILE_TEST_B")
+	})
+
+	path := writeFile(t, "configfile_test_env: from-file\nconfigfile_test_a: one\nconfigfile_test_b: two\n")
+	source := NewSource(path)
+	changed, err := source.Apply()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"CONFIGFILE_TEST_A", "CONFIGFILE_TEST_B"}; !reflect.DeepEqual(changed, want) {
+		t.Errorf("Changed %v, want %v", changed, want)
+	}
+	if v := os.Getenv("CONFIGFILE_TEST_ENV"); v != "from-env" {
+		t.Errorf("Expected the environment to override the file, got %q", v)
+	}
+	if overridden, _ := source.Overridden(); !reflect.DeepEqual(overridden, []string{"CONFIGFILE_TEST_ENV"}) {
+		t.Errorf("Overridden %v", overridden)
+	}
+
+	// Reapplying changes what changed in the file and unsets what was
+	// removed from it
+	if err := os.WriteFile(path, []byte("configfile_test_a: uno\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	changed, err = source.Apply()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"CONFIGFILE_TEST_A", "CONFIGFILE_TEST_B"}; !reflect.DeepEqual(changed, want) {
+		t.Errorf("Changed %v, want %v", changed, want)
+	}
+	if v := os.Getenv("CONFIGFILE_TEST_A"); v != "uno" {
+		t.Errorf("CONFIGFILE_TEST_A = %q, want uno", v)
+	}
+	if _, ok := os.LookupEnv("CONFIGFILE_TEST_B"); ok {
+		t.Error("Expected CONFIGFILE_TEST_B to be unset")
+	}
+
+	// An invalid file leaves the environment alone
+	if err := os.WriteFile(path, []byte("configfile_test_a: [unclosed\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := source.Apply(); err == nil {
+		t.Error("Expected an error for an invalid file")
+	}
+	if v := os.Getenv("CONFIGFILE_TEST_A"); v != "uno" {
+		t.Errorf("CONFIGFILE_TEST_A = %q after a failed reload, want uno", v)
+	}
+}
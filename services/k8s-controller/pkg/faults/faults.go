@@ -0,0 +1,198 @@
+// Package faults injects failures into the controller for chaos testing:
+// errors from the Kubernetes API, database timeouts, and slow reconciles.
+// Each kind of fault draws from its own random source seeded from the same
+// seed, so a single worker sees the same faults in the same order on every
+// run.
+//
+// Fault injection is off unless FAULT_INJECTION_ENABLED is set, and must
+// never be enabled in production.
+package faults
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Kinds of faults
+const (
+	K8sError      = "k8s_error"
+	DBTimeout     = "db_timeout"
+	SlowReconcile = "slow_reconcile"
+)
+
+// Config sets how often each kind of fault is injected. Rates are
+// probabilities from 0 to 1.
+type Config struct {
+	Seed int64
+	// K8sErrorRate of Kubernetes API requests fail with a 500
+	K8sErrorRate float64
+	// DBTimeoutRate of database statements fail as timed out
+	DBTimeoutRate float64
+	// SlowReconcileRate of reconciles are delayed by up to
+	// MaxReconcileDelay
+	SlowReconcileRate float64
+	MaxReconcileDelay time.Duration
+}
+
+// Injector injects faults at the configured rates. A nil Injector injects
+// none.
+type Injector struct {
+	config  Config
+	sources map[string]*source
+	counts  map[string]*atomic.Int64
+	paused  atomic.Bool
+}
+
+// source is a random source safe for concurrent use
+type source struct {
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+func (s *source) float64() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rand.Float64()
+}
+
+func (s *source) int63n(n int64) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rand.Int63n(n)
+}
+
+// New creates an injector
+func New(cfg Config) *Injector {
+	i := &Injector{
+		config:  cfg,
+		sources: make(map[string]*source),
+		counts:  make(map[string]*atomic.Int64),
+	}
+	for n, kind := range []string{K8sError, DBTimeout, SlowReconcile} {
+		i.sources[kind] = &source{rand: rand.New(rand.NewSource(cfg.Seed + int64(n)))}
+		i.counts[kind] = &atomic.Int64{}
+	}
+	return i
+}
+
+// inject reports whether to inject a fault of kind, counting it when so
+func (i *Injector) inject(kind string, rate float64) bool {
+	if i == nil || rate <= 0 || i.paused.Load() || i.sources[kind].float64() >= rate {
+		return false
+	}
+	i.counts[kind].Add(1)
+	return true
+}
+
+// Pause stops injecting faults until Resume, such as to let a test check
+// that the controller recovers
+func (i *Injector) Pause() {
+	if i != nil {
+		i.paused.Store(true)
+	}
+}
+
+// Resume injects faults again after Pause
+func (i *Injector) Resume() {
+	if i != nil {
+		i.paused.Store(false)
+	}
+}
+
+// Injected returns how many faults of kind have been injected
+func (i *Injector) Injected(kind string) int64 {
+	if i == nil || i.counts[kind] == nil {
+		return 0
+	}
+	return i.counts[kind].Load()
+}
+
+// WrapTransport wraps a Kubernetes client transport to fail requests with
+// an InternalError status, as an API server would
+func (i *Injector) WrapTransport(rt http.RoundTripper) http.RoundTripper {
+	if i == nil || i.config.K8sErrorRate <= 0 {
+		return rt
+	}
+	return &faultyTransport{next: rt, injector: i}
+}
+
+type faultyTransport struct {
+	next     http.RoundTripper
+	injector *Injector
+}
+
+// injectedStatus is the body of an injected API server error
+const injectedStatus = `{"kind":"Status","apiVersion":"v1","metadata":{},"status":"Failure",` +
+	`"message":"injected fault","reason":"InternalError","code":500}`
+
+func (t *faultyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !t.injector.inject(K8sError, t.injector.config.K8sErrorRate) {
+		return t.next.RoundTrip(req)
+	}
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	return &http.Response{
+		Status:        "500 Internal Server Error",
+		StatusCode:    http.StatusInternalServerError,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"application/json"}},
+		Body:          io.NopCloser(bytes.NewBufferString(injectedStatus)),
+		ContentLength: int64(len(injectedStatus)),
+		Request:       req,
+	}, nil
+}
+
+// RegisterDB fails database statements as timed out, before they reach the
+// database
+func (i *Injector) RegisterDB(db *gorm.DB) error {
+	if i == nil || i.config.DBTimeoutRate <= 0 {
+		return nil
+	}
+	timeout := func(tx *gorm.DB) {
+		if i.inject(DBTimeout, i.config.DBTimeoutRate) {
+			tx.AddError(fmt.Errorf("injected fault: %w", context.DeadlineExceeded))
+		}
+	}
+
+	callbacks := db.Callback()
+	for name, register := range map[string]func(string, func(*gorm.DB)) error{
+		"create": callbacks.Create().Before("gorm:create").Register,
+		"query":  callbacks.Query().Before("gorm:query").Register,
+		"update": callbacks.Update().Before("gorm:update").Register,
+		"delete": callbacks.Delete().Before("gorm:delete").Register,
+		"row":    callbacks.Row().Before("gorm:row").Register,
+		"raw":    callbacks.Raw().Before("gorm:raw").Register,
+	} {
+		if err := register("faults:"+name, timeout); err != nil {
+			return fmt.Errorf("failed to register %s fault: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// DelayReconcile sleeps before a reconcile when one is to be slowed, and
+// returns the context's error when it is cancelled first
+func (i *Injector) DelayReconcile(ctx context.Context) error {
+	if i == nil || i.config.MaxReconcileDelay <= 0 || !i.inject(SlowReconcile, i.config.SlowReconcileRate) {
+		return nil
+	}
+	delay := time.Duration(i.sources[SlowReconcile].int63n(int64(i.config.MaxReconcileDelay)) + 1)
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(delay):
+		return nil
+	}
+}
@@ -0,0 +1,107 @@
+package faults
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// sequence records which of n draws of kind inject a fault
+func sequence(i *Injector, kind string, rate float64, n int) []bool {
+	seq := make([]bool, n)
+	for j := range seq {
+		seq[j] = i.inject(kind, rate)
+	}
+	return seq
+}
+
+func TestSameSeedInjectsSameFaults(t *testing.T) {
+	a := sequence(New(Config{Seed: 42}), DBTimeout, 0.3, 200)
+	b := sequence(New(Config{Seed: 42}), DBTimeout, 0.3, 200)
+	for j := range a {
+		if a[j] != b[j] {
+			t.Fatalf("Draw %d differs between injectors with the same seed", j)
+		}
+	}
+
+	c := sequence(New(Config{Seed: 43}), DBTimeout, 0.3, 200)
+	same := true
+	for j := range a {
+		same = same && a[j] == c[j]
+	}
+	if same {
+		t.Error("Expected another seed to inject other faults")
+	}
+}
+
+func TestKindsDrawIndependently(t *testing.T) {
+	// Faults of one kind don't shift the sequence of another
+	quiet := New(Config{Seed: 7})
+	busy := New(Config{Seed: 7})
+	sequence(busy, K8sError, 0.5, 50)
+
+	a := sequence(quiet, DBTimeout, 0.3, 100)
+	b := sequence(busy, DBTimeout, 0.3, 100)
+	for j := range a {
+		if a[j] != b[j] {
+			t.Fatalf("Draw %d of %s was shifted by %s faults", j, DBTimeout, K8sError)
+		}
+	}
+}
+
+func TestPause(t *testing.T) {
+	i := New(Config{Seed: 1})
+	i.Pause()
+	if i.inject(K8sError, 1) {
+		t.Error("Expected no fault while paused")
+	}
+	i.Resume()
+	if !i.inject(K8sError, 1) {
+		t.Error("Expected a fault after resuming")
+	}
+	if got := i.Injected(K8sError); got != 1 {
+		t.Errorf("Expected 1 injected fault, got %d", got)
+	}
+}
+
+func TestNilInjector(t *testing.T) {
+	var i *Injector
+	if i.inject(K8sError, 1) {
+		t.Error("Expected a nil injector to inject no faults")
+	}
+	if err := i.DelayReconcile(context.Background()); err != nil {
+		t.Errorf("Expected no delay error, got %v", err)
+	}
+	if rt := i.WrapTransport(http.DefaultTransport); rt != http.DefaultTransport {
+		t.Error("Expected a nil injector to leave the transport as it is")
+	}
+}
+
+func TestTransportReturnsServerError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: New(Config{Seed: 1, K8sErrorRate: 1}).WrapTransport(http.DefaultTransport)}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("Expected status 500, got %d", resp.StatusCode)
+	}
+}
+
+func TestDelayReconcileStopsWithContext(t *testing.T) {
+	i := New(Config{Seed: 1, SlowReconcileRate: 1, MaxReconcileDelay: time.Hour})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := i.DelayReconcile(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Expected the delay to end with the context, got %v", err)
+	}
+}
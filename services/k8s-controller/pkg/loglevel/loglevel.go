@@ -0,0 +1,196 @@
+// Package loglevel changes how verbosely the controller logs while it runs.
+// The level configured by LOG_LEVEL can be overridden, as a whole or for
+// single components, such as only the reconciler at debug, and debug
+// logging can be switched on for everything, as SIGUSR1 does.
+//
+// Components are named by the component field of log entries, or by the
+// source field of GORM's SQL traces.
+package loglevel
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Levels filters the entries of a logger by the level of their component
+type Levels struct {
+	logger *logrus.Logger
+
+	mu         sync.RWMutex
+	formatter  logrus.Formatter
+	configured logrus.Level
+	override   *logrus.Level
+	components map[string]logrus.Level
+	debug      bool
+}
+
+// State describes the levels in effect, for diagnostics
+type State struct {
+	Configured string            `json:"configured"`
+	Override   string            `json:"override,omitempty"`
+	Components map[string]string `json:"components,omitempty"`
+	Debug      bool              `json:"debug"`
+}
+
+// std filters the standard logger, which every component logs through
+var std = New(logrus.StandardLogger())
+
+// Standard returns the Levels of the standard logger
+func Standard() *Levels {
+	return std
+}
+
+// New creates the Levels of a logger, starting at its current level
+func New(logger *logrus.Logger) *Levels {
+	return &Levels{logger: logger, configured: logger.GetLevel()}
+}
+
+// Install wraps the logger's formatter to drop the entries of components
+// logging below their level. It is called once the formatter is set.
+func (l *Levels) Install() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, installed := l.logger.Formatter.(*filter); installed {
+		return
+	}
+	l.formatter = l.logger.Formatter
+	l.logger.SetFormatter(&filter{levels: l})
+}
+
+// SetConfigured sets the level configured by LOG_LEVEL, which applies
+// unless overridden
+func (l *Levels) SetConfigured(level logrus.Level) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.configured = level
+	l.apply()
+}
+
+// SetOverride overrides the configured level, when base isn't nil, and the
+// levels of the components given. Each call replaces the previous override.
+func (l *Levels) SetOverride(base *logrus.Level, components map[string]logrus.Level) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.override = base
+	l.components = components
+	l.apply()
+}
+
+// ClearOverride returns every component to the configured level
+func (l *Levels) ClearOverride() {
+	l.SetOverride(nil, nil)
+}
+
+// ToggleDebug switches debug logging for every component on or off,
+// returning whether it is now on
+func (l *Levels) ToggleDebug() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.debug = !l.debug
+	l.apply()
+	return l.debug
+}
+
+// State returns the levels in effect
+func (l *Levels) State() State {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	s := State{Configured: l.configured.String(), Debug: l.debug}
+	if l.override != nil {
+		s.Override = l.override.String()
+	}
+	if len(l.components) > 0 {
+		s.Components = make(map[string]string, len(l.components))
+		for name, level := range l.components {
+			s.Components[name] = level.String()
+		}
+	}
+	return s
+}
+
+// apply sets the logger to the most verbose level any component logs at,
+// so the filter sees their entries. It is called with mu held.
+func (l *Levels) apply() {
+	level := l.base()
+	for _, component := range l.components {
+		if component > level {
+			level = component
+		}
+	}
+	if l.debug && level < logrus.DebugLevel {
+		level = logrus.DebugLevel
+	}
+	l.logger.SetLevel(level)
+}
+
+// base returns the level of components without their own. It is called
+// with mu held.
+func (l *Levels) base() logrus.Level {
+	if l.override != nil {
+		return *l.override
+	}
+	return l.configured
+}
+
+// enabled reports whether an entry is at or above its component's level
+func (l *Levels) enabled(entry *logrus.Entry) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if l.debug {
+		return entry.Level <= logrus.DebugLevel
+	}
+	level := l.base()
+	if component, ok := l.components[componentOf(entry)]; ok {
+		level = component
+	}
+	return entry.Level <= level
+}
+
+// componentOf returns the component that logged an entry, if any
+func componentOf(entry *logrus.Entry) string {
+	for _, field := range []string{"component", "source"} {
+		if name, ok := entry.Data[field].(string); ok {
+			return name
+		}
+	}
+	return ""
+}
+
+// filter is the formatter installed on the logger. Entries it returns
+// nothing for aren't written.
+type filter struct {
+	levels *Levels
+}
+
+func (f *filter) Format(entry *logrus.Entry) ([]byte, error) {
+	if !f.levels.enabled(entry) {
+		return nil, nil
+	}
+	f.levels.mu.RLock()
+	formatter := f.levels.formatter
+	f.levels.mu.RUnlock()
+	return formatter.Format(entry)
+}
+
+// ParseComponents parses the levels of components, keyed by component name
+func ParseComponents(components map[string]string) (map[string]logrus.Level, error) {
+	names := make([]string, 0, len(components))
+	for name := range components {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	levels := make(map[string]logrus.Level, len(components))
+	for _, name := range names {
+		level, err := logrus.ParseLevel(components[name])
+		if err != nil {
+			return nil, fmt.Errorf("component %s: %w", name, err)
+		}
+		levels[strings.TrimSpace(name)] = level
+	}
+	return levels, nil
+}
@@ -0,0 +1,94 @@
+package loglevel
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+// newLogger creates a logger writing messages alone to a buffer
+func newLogger() (*logrus.Logger, *bytes.Buffer) {
+	var buf bytes.Buffer
+	logger := logrus.New()
+	logger.SetOutput(&buf)
+	logger.SetFormatter(&logrus.TextFormatter{DisableTimestamp: true})
+	logger.SetLevel(logrus.InfoLevel)
+	return logger, &buf
+}
+
+func TestComponentOverride(t *testing.T) {
+	logger, buf := newLogger()
+	levels := New(logger)
+	levels.Install()
+
+	levels.SetOverride(nil, map[string]logrus.Level{"reconciler": logrus.DebugLevel})
+	logger.WithField("component", "reconciler").Debug("reconciler debug")
+	logger.WithField("component", "watcher").Debug("watcher debug")
+	logger.WithField("component", "watcher").Info("watcher info")
+
+	out := buf.String()
+	if !strings.Contains(out, "reconciler debug") {
+		t.Error("Expected the reconciler's debug entry")
+	}
+	if strings.Contains(out, "watcher debug") {
+		t.Error("Expected the watcher's debug entry to be dropped")
+	}
+	if !strings.Contains(out, "watcher info") {
+		t.Error("Expected the watcher's info entry")
+	}
+
+	// Quieting a component and raising the rest
+	buf.Reset()
+	warn := logrus.WarnLevel
+	levels.SetOverride(&warn, map[string]logrus.Level{"gorm": logrus.ErrorLevel})
+	logger.WithField("component", "watcher").Info("watcher info")
+	logger.WithField("source", "gorm").Warn("slow query")
+	logger.Warn("warning")
+	if out := buf.String(); strings.Contains(out, "watcher info") || strings.Contains(out, "slow query") || !strings.Contains(out, "warning") {
+		t.Errorf("Unexpected output %q", out)
+	}
+
+	levels.ClearOverride()
+	if logger.GetLevel() != logrus.InfoLevel {
+		t.Errorf("Level %s after clearing the override, want info", logger.GetLevel())
+	}
+}
+
+func TestToggleDebug(t *testing.T) {
+	logger, buf := newLogger()
+	levels := New(logger)
+	levels.Install()
+	levels.SetOverride(nil, map[string]logrus.Level{"watcher": logrus.ErrorLevel})
+
+	if !levels.ToggleDebug() {
+		t.Fatal("Expected debug logging to be on")
+	}
+	logger.WithField("component", "watcher").Debug("watcher debug")
+	if !strings.Contains(buf.String(), "watcher debug") {
+		t.Error("Expected debug logging for every component")
+	}
+
+	buf.Reset()
+	if levels.ToggleDebug() {
+		t.Fatal("Expected debug logging to be off")
+	}
+	logger.WithField("component", "watcher").Info("watcher info")
+	if buf.Len() > 0 {
+		t.Errorf("Unexpected output %q", buf.String())
+	}
+}
+
+func TestParseComponents(t *testing.T) {
+	levels, err := ParseComponents(map[string]string{"reconciler": "debug", "watcher": "warn"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if levels["reconciler"] != logrus.DebugLevel || levels["watcher"] != logrus.WarnLevel {
+		t.Errorf("Parsed %v", levels)
+	}
+	if _, err := ParseComponents(map[string]string{"watcher": "loud"}); err == nil {
+		t.Error("Expected an error for an invalid level")
+	}
+}
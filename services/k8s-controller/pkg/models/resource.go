@@ -3,6 +3,8 @@ package models
 import (
 	"database/sql/driver"
 	"encoding/json"
+	"fmt"
+	"strings"
 	"time"
 )
 
@@ -30,12 +32,168 @@ func (j JSONMap) Value() (driver.Value, error) {
 	return json.Marshal(j)
 }
 
+// StringList represents a JSON array of strings stored in database
+type StringList []string
+
+// Scan implements sql.Scanner interface
+func (l *StringList) Scan(value interface{}) error {
+	if value == nil {
+		*l = nil
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(bytes, l)
+}
+
+// Value implements driver.Valuer interface
+func (l StringList) Value() (driver.Value, error) {
+	if l == nil {
+		return nil, nil
+	}
+	return json.Marshal(l)
+}
+
+// PolicyViolation is a violation of a Rego policy by a resource spec
+type PolicyViolation struct {
+	Policy   string `json:"policy"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+// PolicyViolations represents a JSON array of policy violations stored in
+// database
+type PolicyViolations []PolicyViolation
+
+// Scan implements sql.Scanner interface
+func (v *PolicyViolations) Scan(value interface{}) error {
+	if value == nil {
+		*v = nil
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(bytes, v)
+}
+
+// Value implements driver.Valuer interface
+func (v PolicyViolations) Value() (driver.Value, error) {
+	if v == nil {
+		return nil, nil
+	}
+	return json.Marshal(v)
+}
+
+// Condition is an aspect of a resource's state, following the Kubernetes
+// conventions: its status is True, False or Unknown, with a machine-readable
+// reason and a message, and LastTransitionTime changes only with the status
+type Condition struct {
+	Type               string    `json:"type"`
+	Status             string    `json:"status"`
+	Reason             string    `json:"reason"`
+	Message            string    `json:"message,omitempty"`
+	LastTransitionTime time.Time `json:"last_transition_time"`
+}
+
+// Conditions represents a JSON array of conditions stored in database
+type Conditions []Condition
+
+// Scan implements sql.Scanner interface
+func (l *Conditions) Scan(value interface{}) error {
+	if value == nil {
+		*l = nil
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(bytes, l)
+}
+
+// Value implements driver.Valuer interface
+func (l Conditions) Value() (driver.Value, error) {
+	if l == nil {
+		return nil, nil
+	}
+	return json.Marshal(l)
+}
+
+// Set sets a condition, keeping its last transition time unless its status
+// changes, and reports whether anything changed
+func (l *Conditions) Set(condition Condition, now time.Time) bool {
+	for i, existing := range *l {
+		if existing.Type != condition.Type {
+			continue
+		}
+		condition.LastTransitionTime = existing.LastTransitionTime
+		if existing.Status != condition.Status {
+			condition.LastTransitionTime = now
+		}
+		(*l)[i] = condition
+		return existing != condition
+	}
+	condition.LastTransitionTime = now
+	*l = append(*l, condition)
+	return true
+}
+
+// Remove removes a condition, reporting whether it was set
+func (l *Conditions) Remove(conditionType string) bool {
+	for i, existing := range *l {
+		if existing.Type == conditionType {
+			*l = append((*l)[:i], (*l)[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Contains reports whether the list holds s
+func (l StringList) Contains(s string) bool {
+	for _, item := range l {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// UintList represents a JSON array of IDs stored in database
+type UintList []uint
+
+// Scan implements sql.Scanner interface
+func (l *UintList) Scan(value interface{}) error {
+	if value == nil {
+		*l = nil
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(bytes, l)
+}
+
+// Value implements driver.Valuer interface
+func (l UintList) Value() (driver.Value, error) {
+	if l == nil {
+		return nil, nil
+	}
+	return json.Marshal(l)
+}
+
 // Resource represents a managed resource in the NEST database
 type Resource struct {
 	ID                  uint       `gorm:"primaryKey"`
 	Name                string     `gorm:"size:255;not null"`
 	ResourceTypeID      uint       `gorm:"not null"`
 	TeamID              uint       `gorm:"not null;index"`
+	Environment         string
 	Status              string     `gorm:"size:50;default:pending"`
 	LifecycleMode       string     `gorm:"size:50;not null"`
 	ProvisioningMethod  *string    `gorm:"size:50"`
@@ -53,6 +211,21 @@ type Resource struct {
 	CanBackup           bool    `gorm:"default:false"`
 	CanScale            bool    `gorm:"default:false"`
 	CreatedBy           *uint
+	LastError           string
+	LastErrorAt         *time.Time
+	ConsecutiveFailures int
+	DeletionProtection  bool
+	DeletionState       string
+	Finalizers          StringList `gorm:"type:jsonb"`
+	SecurityFindings    StringList `gorm:"type:jsonb"`
+	PolicyViolations    PolicyViolations `gorm:"type:jsonb"`
+	SizeClass           string
+	PriorityClass       string
+	PendingRestartSince *time.Time
+	Generation          int64
+	ObservedGeneration  int64
+	Conditions          Conditions `gorm:"type:jsonb"`
+	DockerHostID        *uint
 	CreatedAt           time.Time  `gorm:"autoCreateTime"`
 	UpdatedAt           time.Time  `gorm:"autoUpdateTime"`
 	DeletedAt           *time.Time `gorm:"index"`
@@ -120,3 +293,455 @@ type AuditLog struct {
 func (AuditLog) TableName() string {
 	return "audit_logs"
 }
+
+// ResourceStats represents a point-in-time statistics sample for a resource
+type ResourceStats struct {
+	ID          uint       `gorm:"primaryKey"`
+	ResourceID  uint       `gorm:"not null;index"`
+	Timestamp   time.Time  `gorm:"not null;index"`
+	Metrics     JSONMap    `gorm:"type:jsonb"`
+	RiskLevel   string     `gorm:"size:50"`
+	RiskFactors JSONMap    `gorm:"type:jsonb"`
+	CreatedAt   time.Time  `gorm:"autoCreateTime"`
+	UpdatedAt   time.Time  `gorm:"autoUpdateTime"`
+	DeletedAt   *time.Time `gorm:"index"`
+}
+
+// TableName specifies the table name for ResourceStats
+func (ResourceStats) TableName() string {
+	return "resource_stats"
+}
+
+// CredentialUsage is when an account of a resource was last seen connected
+// by stats collection. The table is migrated by the API.
+type CredentialUsage struct {
+	ID          uint   `gorm:"primaryKey"`
+	ResourceID  uint   `gorm:"not null;uniqueIndex:idx_credential_usages_account"`
+	TeamID      uint   `gorm:"not null;index"`
+	Username    string `gorm:"size:255;not null;uniqueIndex:idx_credential_usages_account"`
+	LastSeenAt  time.Time
+	Connections int
+	CreatedAt   time.Time `gorm:"autoCreateTime"`
+	UpdatedAt   time.Time `gorm:"autoUpdateTime"`
+}
+
+// TableName specifies the table name for CredentialUsage
+func (CredentialUsage) TableName() string {
+	return "credential_usages"
+}
+
+// ControllerInstance is the heartbeat record of a running controller. The
+// table is migrated by the API.
+type ControllerInstance struct {
+	ID               uint   `gorm:"primaryKey"`
+	InstanceID       string `gorm:"uniqueIndex;not null"`
+	Hostname         string
+	Cluster          string `gorm:"index"`
+	Version          string
+	Status           string
+	WorkerCount      int
+	QueueDepth       int
+	StartedAt        time.Time
+	LastHeartbeatAt  time.Time `gorm:"index"`
+	LastReconcileAt  *time.Time
+	SchemaVersion    int
+	BuildTime        string
+	GitCommit        string
+	DrainRequestedAt *time.Time
+	CreatedAt        time.Time  `gorm:"autoCreateTime"`
+	UpdatedAt        time.Time  `gorm:"autoUpdateTime"`
+	DeletedAt        *time.Time `gorm:"index"`
+}
+
+// TableName specifies the table name for ControllerInstance
+func (ControllerInstance) TableName() string {
+	return "controller_instances"
+}
+
+// SchemaInfo is the schema version the API last migrated and the oldest
+// controller schema version it supports. The table is migrated by the API.
+type SchemaInfo struct {
+	ID                         uint `gorm:"primaryKey"`
+	SchemaVersion              int
+	MinControllerSchemaVersion int
+	APIVersion                 string
+	MigratedAt                 time.Time
+}
+
+// TableName specifies the table name for SchemaInfo
+func (SchemaInfo) TableName() string {
+	return "schema_info"
+}
+
+// ImageRegistry is a registry mirror that images are pulled through. The
+// table is migrated by the API.
+type ImageRegistry struct {
+	ID           uint `gorm:"primaryKey"`
+	Name         string
+	TeamID       *uint  `gorm:"index"`
+	Cluster      string `gorm:"index"`
+	MirrorPrefix string `gorm:"not null"`
+	Username     string
+	Password     string
+	CreatedAt    time.Time  `gorm:"autoCreateTime"`
+	UpdatedAt    time.Time  `gorm:"autoUpdateTime"`
+	DeletedAt    *time.Time `gorm:"index"`
+}
+
+// TableName specifies the table name for ImageRegistry
+func (ImageRegistry) TableName() string {
+	return "image_registries"
+}
+
+// DockerHost is a Docker or Podman host that full lifecycle resources are
+// provisioned on as containers. The table is migrated by the API.
+type DockerHost struct {
+	ID               uint `gorm:"primaryKey"`
+	Name             string
+	Endpoint         string
+	Runtime          string
+	AdvertiseAddress string
+	CACert           string
+	ClientCert       string
+	ClientKey        string
+	LastContactAt    *time.Time
+	LastError        string
+	DeletedAt        *time.Time `gorm:"index"`
+}
+
+// TableName specifies the table name for DockerHost
+func (DockerHost) TableName() string {
+	return "docker_hosts"
+}
+
+// AdoptionCandidate is an unmanaged StatefulSet in a team namespace that
+// looks like a database. The controller proposes candidates and labels the
+// ones the API approves. The table is migrated by the API.
+type AdoptionCandidate struct {
+	ID          uint   `gorm:"primaryKey"`
+	Namespace   string `gorm:"not null"`
+	Name        string `gorm:"not null"`
+	Engine      string `gorm:"not null"`
+	Image       string
+	Replicas    int
+	ServiceName string
+	Port        int
+	Status      string `gorm:"default:proposed"`
+	ResourceID  *uint
+	LastSeenAt  *time.Time
+	CreatedAt   time.Time  `gorm:"autoCreateTime"`
+	UpdatedAt   time.Time  `gorm:"autoUpdateTime"`
+	DeletedAt   *time.Time `gorm:"index"`
+}
+
+// TableName specifies the table name for AdoptionCandidate
+func (AdoptionCandidate) TableName() string {
+	return "adoption_candidates"
+}
+
+// AllowedImage is an image pattern that may be injected into generated
+// StatefulSets. The table is migrated by the API.
+type AllowedImage struct {
+	ID        uint       `gorm:"primaryKey"`
+	Pattern   string     `gorm:"uniqueIndex;not null"`
+	DeletedAt *time.Time `gorm:"index"`
+}
+
+// TableName specifies the table name for AllowedImage
+func (AllowedImage) TableName() string {
+	return "allowed_images"
+}
+
+// ContainerPolicy attaches an init container or sidecar to every generated
+// StatefulSet of a team. The table is migrated by the API.
+type ContainerPolicy struct {
+	ID            uint       `gorm:"primaryKey"`
+	TeamID        uint       `gorm:"not null;index"`
+	Kind          string     `gorm:"not null"`
+	Name          string     `gorm:"not null"`
+	Image         string     `gorm:"not null"`
+	Command       StringList `gorm:"type:jsonb"`
+	Args          StringList `gorm:"type:jsonb"`
+	Env           JSONMap    `gorm:"type:jsonb"`
+	ResourceTypes StringList `gorm:"type:jsonb"`
+	Enabled       bool
+	CreatedAt     time.Time  `gorm:"autoCreateTime"`
+	UpdatedAt     time.Time  `gorm:"autoUpdateTime"`
+	DeletedAt     *time.Time `gorm:"index"`
+}
+
+// TableName specifies the table name for ContainerPolicy
+func (ContainerPolicy) TableName() string {
+	return "container_policies"
+}
+
+// ReconcileRequest is a queued request from the API to reconcile a resource
+// immediately. The table is migrated by the API.
+type ReconcileRequest struct {
+	ID          uint `gorm:"primaryKey"`
+	ResourceID  uint `gorm:"not null;index"`
+	RequestedBy uint
+	ProcessedAt *time.Time `gorm:"index"`
+	CreatedAt   time.Time  `gorm:"autoCreateTime"`
+	UpdatedAt   time.Time  `gorm:"autoUpdateTime"`
+	DeletedAt   *time.Time `gorm:"index"`
+}
+
+// TableName specifies the table name for ReconcileRequest
+func (ReconcileRequest) TableName() string {
+	return "reconcile_requests"
+}
+
+// ReconcileStatus is the latest reconcile outcome and retry state of a
+// resource. The table is migrated by the API.
+type ReconcileStatus struct {
+	ResourceID      uint `gorm:"primaryKey;autoIncrement:false"`
+	LastReconcileAt *time.Time
+	LastOutcome     string
+	LastError       string
+	RetryCount      int
+	NextRetryAt     *time.Time
+	InstanceID      string
+	UpdatedAt       time.Time `gorm:"autoUpdateTime"`
+}
+
+// TableName specifies the table name for ReconcileStatus
+func (ReconcileStatus) TableName() string {
+	return "reconcile_statuses"
+}
+
+// FeatureFlag overrides the default of a feature flag declared in code. The
+// table is migrated by the API.
+type FeatureFlag struct {
+	ID         uint   `gorm:"primaryKey"`
+	Key        string `gorm:"uniqueIndex;not null"`
+	Enabled    bool
+	Percentage int
+	Teams      UintList   `gorm:"type:jsonb"`
+	DeletedAt  *time.Time `gorm:"index"`
+}
+
+// TableName specifies the table name for FeatureFlag
+func (FeatureFlag) TableName() string {
+	return "feature_flags"
+}
+
+// LogLevelOverride changes the log level of a service at runtime, as a
+// whole or for single components, until it expires. The table is migrated
+// by the API.
+type LogLevelOverride struct {
+	ID         uint   `gorm:"primaryKey"`
+	Service    string `gorm:"uniqueIndex;not null"`
+	Level      string
+	Components JSONMap `gorm:"type:jsonb"`
+	ExpiresAt  *time.Time
+	DeletedAt  *time.Time `gorm:"index"`
+}
+
+// TableName specifies the table name for LogLevelOverride
+func (LogLevelOverride) TableName() string {
+	return "log_level_overrides"
+}
+
+// PasswordPolicy is the password and credential policy. Generated passwords
+// honor its minimum length. The table is migrated by the API.
+type PasswordPolicy struct {
+	ID        uint `gorm:"primaryKey"`
+	MinLength int
+	DeletedAt *time.Time `gorm:"index"`
+}
+
+// TableName specifies the table name for PasswordPolicy
+func (PasswordPolicy) TableName() string {
+	return "password_policies"
+}
+
+// CertificateAuthority is a CA whose certificates go into team trust
+// bundles. The table is migrated by the manager, which stores team_ids as a
+// PyDAL list:reference, such as |1|2|.
+type CertificateAuthority struct {
+	ID          uint `gorm:"primaryKey"`
+	Name        string
+	Type        string
+	Certificate string
+	Chain       *string
+	Status      string
+	TeamIDs     *string    `gorm:"column:team_ids"`
+	DeletedAt   *time.Time `gorm:"index"`
+}
+
+// TableName specifies the table name for CertificateAuthority
+func (CertificateAuthority) TableName() string {
+	return "certificate_authorities"
+}
+
+// AllowsTeam reports whether the CA issues certificates for a team; CAs
+// without teams issue for all of them
+func (ca CertificateAuthority) AllowsTeam(teamID uint) bool {
+	if ca.TeamIDs == nil || strings.Trim(*ca.TeamIDs, "|") == "" {
+		return true
+	}
+	return strings.Contains(*ca.TeamIDs, fmt.Sprintf("|%d|", teamID))
+}
+
+// ConsumerBinding binds a resource to an application namespace, where the
+// controller keeps a Secret with the resource's connection details. Deleted
+// bindings are removed by the controller once their Secret is. The table is
+// migrated by the API.
+type ConsumerBinding struct {
+	ID           uint   `gorm:"primaryKey"`
+	ResourceID   uint   `gorm:"not null;index"`
+	TeamID       uint   `gorm:"not null;index"`
+	Namespace    string `gorm:"not null"`
+	SecretName   string `gorm:"not null"`
+	Status       string `gorm:"default:pending"`
+	LastError    string
+	LastSyncedAt *time.Time
+	CreatedAt    time.Time  `gorm:"autoCreateTime"`
+	UpdatedAt    time.Time  `gorm:"autoUpdateTime"`
+	DeletedAt    *time.Time `gorm:"index"`
+}
+
+// TableName specifies the table name for ConsumerBinding
+func (ConsumerBinding) TableName() string {
+	return "consumer_bindings"
+}
+
+// ResourceClaim links a Crossplane-style claim to the resource the
+// controller created for it. The table is migrated by the API.
+type ResourceClaim struct {
+	ID         uint   `gorm:"primaryKey"`
+	ResourceID uint   `gorm:"not null;index"`
+	TeamID     uint   `gorm:"not null;index"`
+	APIVersion string `gorm:"not null"`
+	Kind       string `gorm:"not null"`
+	Namespace  string `gorm:"not null"`
+	Name       string `gorm:"not null"`
+	UID        string `gorm:"column:uid;uniqueIndex;not null"`
+	CreatedAt  time.Time  `gorm:"autoCreateTime"`
+	UpdatedAt  time.Time  `gorm:"autoUpdateTime"`
+	DeletedAt  *time.Time `gorm:"index"`
+}
+
+// TableName specifies the table name for ResourceClaim
+func (ResourceClaim) TableName() string {
+	return "resource_claims"
+}
+
+// Operation tracks a change to a resource requested through the API until
+// the controller has carried it out. The table is migrated by the API.
+type Operation struct {
+	ID                uint   `gorm:"primaryKey"`
+	Type              string `gorm:"size:50;not null"`
+	Status            string `gorm:"size:20;not null"`
+	ResourceID        uint   `gorm:"not null;index"`
+	TeamID            uint   `gorm:"not null;index"`
+	ProvisioningJobID *uint  `gorm:"index"`
+	Message           string `gorm:"type:text"`
+	RequestedBy       uint
+	StartedAt         *time.Time
+	CompletedAt       *time.Time
+	CreatedAt         time.Time  `gorm:"autoCreateTime"`
+	UpdatedAt         time.Time  `gorm:"autoUpdateTime"`
+	DeletedAt         *time.Time `gorm:"index"`
+}
+
+// TableName specifies the table name for Operation
+func (Operation) TableName() string {
+	return "operations"
+}
+
+// ResourceLock is the lock an operation holds on its resource while it is
+// in progress. The table is migrated by the API.
+type ResourceLock struct {
+	ID          uint   `gorm:"primaryKey"`
+	ResourceID  uint   `gorm:"not null;uniqueIndex"`
+	Holder      string `gorm:"size:100;not null"`
+	Reason      string `gorm:"size:255"`
+	OperationID *uint  `gorm:"index"`
+	AcquiredBy  uint
+	ExpiresAt   time.Time  `gorm:"not null;index"`
+	CreatedAt   time.Time  `gorm:"autoCreateTime"`
+	UpdatedAt   time.Time  `gorm:"autoUpdateTime"`
+	DeletedAt   *time.Time `gorm:"index"`
+}
+
+// TableName specifies the table name for ResourceLock
+func (ResourceLock) TableName() string {
+	return "resource_locks"
+}
+
+// Policy is a Rego policy resource specs are checked against. The table is
+// migrated by the API.
+type Policy struct {
+	ID        uint   `gorm:"primaryKey"`
+	Name      string `gorm:"size:255;not null"`
+	Rego      string `gorm:"type:text;not null"`
+	Severity  string `gorm:"size:20;not null"`
+	Enabled   bool
+	DeletedAt *time.Time `gorm:"index"`
+}
+
+// TableName specifies the table name for Policy
+func (Policy) TableName() string {
+	return "policies"
+}
+
+// ConsistencyDiscrepancy is a difference the consistency audit found between
+// a resource's record and its objects in the cluster
+type ConsistencyDiscrepancy struct {
+	ResourceID uint   `json:"resource_id"`
+	TeamID     uint   `json:"team_id"`
+	Name       string `json:"name"`
+	Check      string `json:"check"`
+	Expected   string `json:"expected,omitempty"`
+	Actual     string `json:"actual,omitempty"`
+	Message    string `json:"message"`
+}
+
+// ConsistencyDiscrepancies represents a JSON array of discrepancies stored
+// in database
+type ConsistencyDiscrepancies []ConsistencyDiscrepancy
+
+// Scan implements sql.Scanner interface
+func (d *ConsistencyDiscrepancies) Scan(value interface{}) error {
+	if value == nil {
+		*d = nil
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(bytes, d)
+}
+
+// Value implements driver.Valuer interface
+func (d ConsistencyDiscrepancies) Value() (driver.Value, error) {
+	if d == nil {
+		return json.Marshal([]ConsistencyDiscrepancy{})
+	}
+	return json.Marshal(d)
+}
+
+// ConsistencyReport is a run of the consistency audit of a cluster's
+// resources. The table is migrated by the API.
+type ConsistencyReport struct {
+	ID               uint   `gorm:"primaryKey"`
+	Cluster          string `gorm:"size:100;not null;index"`
+	InstanceID       string `gorm:"size:100"`
+	StartedAt        time.Time
+	CompletedAt      time.Time
+	ResourcesChecked int
+	ResourcesFailed  int
+	DiscrepancyCount int
+	Discrepancies    ConsistencyDiscrepancies `gorm:"type:jsonb"`
+	CreatedAt        time.Time                `gorm:"autoCreateTime"`
+	UpdatedAt        time.Time                `gorm:"autoUpdateTime"`
+	DeletedAt        *time.Time               `gorm:"index"`
+}
+
+// TableName specifies the table name for ConsistencyReport
+func (ConsistencyReport) TableName() string {
+	return "consistency_reports"
+}
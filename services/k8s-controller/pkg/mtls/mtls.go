@@ -0,0 +1,132 @@
+// Package mtls holds the controller's own service identity and builds the
+// TLS configs it uses for mutual TLS: with Postgres, and on the metrics
+// listener. The identity is updated in place whenever the issuer rotates
+// it, so connections and listeners pick up new certificates without a
+// restart.
+package mtls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+)
+
+// URIScheme is the scheme of the URI SAN naming a service identity, as in
+// spiffe://nest/nest-controller
+const URIScheme = "spiffe"
+
+// Keys of an identity in its Kubernetes secret
+const (
+	CertKey = "tls.crt"
+	KeyKey  = "tls.key"
+	CAKey   = "ca.crt"
+)
+
+// Identity is a service's certificate and key together with the CA pool
+// its peers are verified against. The zero value holds no identity until
+// Update is called.
+type Identity struct {
+	mu   sync.RWMutex
+	cert *tls.Certificate
+	pool *x509.CertPool
+}
+
+// Update replaces the identity with PEM encoded material
+func (id *Identity) Update(certPEM, keyPEM, caPEM []byte) error {
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return fmt.Errorf("failed to load service certificate: %w", err)
+	}
+	if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+		return fmt.Errorf("failed to parse service certificate: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return errors.New("no CA certificates found")
+	}
+
+	id.mu.Lock()
+	defer id.mu.Unlock()
+	id.cert = &cert
+	id.pool = pool
+	return nil
+}
+
+// current returns the identity's certificate and CA pool
+func (id *Identity) current() (*tls.Certificate, *x509.CertPool, error) {
+	id.mu.RLock()
+	defer id.mu.RUnlock()
+	if id.cert == nil {
+		return nil, nil, errors.New("no service identity has been issued")
+	}
+	return id.cert, id.pool, nil
+}
+
+// ServerConfig returns a TLS config for listeners that present the
+// identity and require client certificates issued by the CA
+func (id *Identity) ServerConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			cert, _, err := id.current()
+			return cert, err
+		},
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			cert, pool, err := id.current()
+			if err != nil {
+				return nil, err
+			}
+			return &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*cert},
+				ClientAuth:   tls.RequireAndVerifyClientCert,
+				ClientCAs:    pool,
+			}, nil
+		},
+	}
+}
+
+// ClientConfig returns a TLS config for connections that present the
+// identity and verify the server's certificate, for serverName, against
+// the CA. The pool is read at each handshake rather than fixed in the
+// config, so a rotated CA applies to new connections.
+func (id *Identity) ClientConfig(serverName string) *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: serverName,
+		// Verification is done in VerifyConnection against the current pool
+		InsecureSkipVerify: true,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			cert, _, err := id.current()
+			return cert, err
+		},
+		VerifyConnection: func(state tls.ConnectionState) error {
+			_, pool, err := id.current()
+			if err != nil {
+				return err
+			}
+			if len(state.PeerCertificates) == 0 {
+				return errors.New("server presented no certificate")
+			}
+			intermediates := x509.NewCertPool()
+			for _, cert := range state.PeerCertificates[1:] {
+				intermediates.AddCert(cert)
+			}
+			_, err = state.PeerCertificates[0].Verify(x509.VerifyOptions{
+				DNSName:       serverName,
+				Roots:         pool,
+				Intermediates: intermediates,
+				KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+			})
+			return err
+		},
+	}
+}
+
+// ServiceURI returns the URI SAN of a service identity
+func ServiceURI(name string) *url.URL {
+	return &url.URL{Scheme: URIScheme, Host: "nest", Path: "/" + name}
+}
@@ -0,0 +1,220 @@
+// Package policy evaluates Rego policies on an Open Policy Agent server
+// through its REST API. Policies are kept in the NEST database and loaded
+// into OPA as modules of the nest.policies package, one per policy, each
+// defining a deny rule that lists the messages of its violations:
+//
+//	package nest.policies.prod_backups
+//
+//	deny[msg] {
+//		input.resource.environment == "production"
+//		not input.resource.can_backup
+//		msg := "production resources must be backed up"
+//	}
+//
+// This is a copy of the API's shared/policy, since the controller is a
+// separate module. The two must load and evaluate policies the same way.
+package policy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Package is the Rego package policies are declared under
+const Package = "nest.policies"
+
+// Severities of a policy. Violations of a blocking policy reject a change;
+// violations of a warning policy are recorded on the resource.
+const (
+	SeverityBlock = "block"
+	SeverityWarn  = "warn"
+)
+
+// namePattern matches policy names, which must be valid Rego identifiers
+var namePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,62}$`)
+
+// packagePattern matches the package declaration of a Rego module
+var packagePattern = regexp.MustCompile(`(?m)^\s*package\s+([A-Za-z0-9_.]+)\s*$`)
+
+// ValidName reports whether name can name a policy
+func ValidName(name string) bool {
+	return namePattern.MatchString(name)
+}
+
+// CheckModule checks a Rego module declares the package of the policy name
+func CheckModule(name, module string) error {
+	match := packagePattern.FindStringSubmatch(module)
+	if match == nil {
+		return fmt.Errorf("the policy must declare package %s.%s", Package, name)
+	}
+	if match[1] != Package+"."+name {
+		return fmt.Errorf("the policy declares package %s; it must be %s.%s", match[1], Package, name)
+	}
+	return nil
+}
+
+// CompileError is returned when OPA rejects a policy's Rego
+type CompileError struct {
+	Message string
+}
+
+func (e *CompileError) Error() string {
+	return e.Message
+}
+
+// Client talks to an OPA server
+type Client struct {
+	url  string
+	http *http.Client
+}
+
+// NewClient creates a client for the OPA server at baseURL, such as
+// http://opa:8181
+func NewClient(baseURL string) *Client {
+	return &Client{
+		url:  strings.TrimRight(baseURL, "/"),
+		http: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Put loads or replaces the module of the policy name
+func (c *Client) Put(ctx context.Context, name, module string) error {
+	resp, err := c.do(ctx, http.MethodPut, "/v1/policies/nest/"+url.PathEscape(name), "text/plain", strings.NewReader(module))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusBadRequest {
+		return compileError(resp.Body)
+	}
+	return checkStatus(resp)
+}
+
+// Delete unloads the policy name. Unloading a policy OPA doesn't have
+// succeeds.
+func (c *Client) Delete(ctx context.Context, name string) error {
+	resp, err := c.do(ctx, http.MethodDelete, "/v1/policies/nest/"+url.PathEscape(name), "", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	return checkStatus(resp)
+}
+
+// Evaluate evaluates the loaded policies against input. The result maps the
+// name of each policy OPA has to the messages of its violations, which are
+// empty when the input complies.
+func (c *Client) Evaluate(ctx context.Context, input interface{}) (map[string][]string, error) {
+	body, err := json.Marshal(map[string]interface{}{"input": input})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.do(ctx, http.MethodPost, "/v1/data/"+strings.ReplaceAll(Package, ".", "/"), "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if err := checkStatus(resp); err != nil {
+		return nil, err
+	}
+
+	var doc struct {
+		Result map[string]struct {
+			Deny []json.RawMessage `json:"deny"`
+		} `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("decoding OPA result: %w", err)
+	}
+
+	results := make(map[string][]string, len(doc.Result))
+	for name, policy := range doc.Result {
+		messages := make([]string, 0, len(policy.Deny))
+		for _, raw := range policy.Deny {
+			messages = append(messages, denyMessage(raw))
+		}
+		results[name] = messages
+	}
+	return results, nil
+}
+
+func (c *Client) do(ctx context.Context, method, path, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.url+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("OPA request failed: %w", err)
+	}
+	return resp, nil
+}
+
+// denyMessage reads a violation, either a message or an object with a msg
+// field
+func denyMessage(raw json.RawMessage) string {
+	var msg string
+	if err := json.Unmarshal(raw, &msg); err == nil {
+		return msg
+	}
+	var obj struct {
+		Msg string `json:"msg"`
+	}
+	if err := json.Unmarshal(raw, &obj); err == nil && obj.Msg != "" {
+		return obj.Msg
+	}
+	return string(raw)
+}
+
+// compileError reads the errors OPA reports for a module it can't compile
+func compileError(body io.Reader) error {
+	var doc struct {
+		Message string `json:"message"`
+		Errors  []struct {
+			Message  string `json:"message"`
+			Location *struct {
+				Row int `json:"row"`
+				Col int `json:"col"`
+			} `json:"location"`
+		} `json:"errors"`
+	}
+	if err := json.NewDecoder(io.LimitReader(body, 1<<20)).Decode(&doc); err != nil {
+		return &CompileError{Message: "the policy could not be compiled"}
+	}
+	messages := make([]string, 0, len(doc.Errors))
+	for _, e := range doc.Errors {
+		if e.Location != nil {
+			messages = append(messages, fmt.Sprintf("%d:%d: %s", e.Location.Row, e.Location.Col, e.Message))
+		} else {
+			messages = append(messages, e.Message)
+		}
+	}
+	if len(messages) == 0 {
+		messages = append(messages, doc.Message)
+	}
+	return &CompileError{Message: strings.Join(messages, "; ")}
+}
+
+func checkStatus(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	return fmt.Errorf("OPA returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+}
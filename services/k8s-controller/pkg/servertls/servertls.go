@@ -0,0 +1,245 @@
+// Package servertls builds the TLS configs of HTTP listeners from their
+// settings: the certificate to present, read from files or taken from the
+// service identity issued by the controller's certificate subsystem, the
+// minimum TLS version and cipher suites, and whether client certificates
+// are verified. Certificate files are reread when they change, so renewed
+// certificates are picked up without a restart.
+//
+// This is a copy of the API's shared/servertls, since the controller is a
+// separate module.
+package servertls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Client certificate modes
+const (
+	ClientAuthNone     = "none"
+	ClientAuthOptional = "optional"
+	ClientAuthRequire  = "require"
+)
+
+// reloadCheckInterval is how often certificate files are checked for
+// changes, at most
+const reloadCheckInterval = 30 * time.Second
+
+// versions are the TLS versions a listener can be limited to
+var versions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// Settings are the TLS settings of a listener
+type Settings struct {
+	// CertFile and KeyFile hold the PEM certificate and key to present
+	CertFile string
+	KeyFile  string
+	// MinVersion is 1.2 or 1.3; the default is 1.2
+	MinVersion string
+	// CipherSuites are the names of the TLS 1.2 cipher suites allowed, as
+	// in crypto/tls; the default is Go's secure suites. TLS 1.3 suites
+	// can't be configured.
+	CipherSuites []string
+	// ClientCAFile holds the PEM CAs client certificates are verified
+	// against
+	ClientCAFile string
+	// ClientAuth is none, optional or require
+	ClientAuth string
+}
+
+// ServerConfig builds the TLS config of a listener. base, when not nil, is
+// the TLS config of the service's identity: its certificate is presented
+// unless the settings name certificate files, and client certificates are
+// verified against its CA unless they name a client CA file. It returns nil
+// when there is neither a base nor a certificate file, for a listener that
+// serves plain HTTP.
+func (s Settings) ServerConfig(base *tls.Config) (*tls.Config, error) {
+	if s.CertFile == "" && s.KeyFile != "" {
+		return nil, errors.New("a TLS key file requires a certificate file")
+	}
+	if base == nil && s.CertFile == "" {
+		return nil, nil
+	}
+
+	minVersion := uint16(tls.VersionTLS12)
+	if s.MinVersion != "" {
+		v, ok := versions[s.MinVersion]
+		if !ok {
+			return nil, fmt.Errorf("unsupported TLS version %q: must be 1.2 or 1.3", s.MinVersion)
+		}
+		minVersion = v
+	}
+	ciphers, err := cipherSuites(s.CipherSuites)
+	if err != nil {
+		return nil, err
+	}
+	clientAuth, err := clientAuthType(s.ClientAuth)
+	if err != nil {
+		return nil, err
+	}
+
+	var cert *fileCertificate
+	if s.CertFile != "" {
+		if s.KeyFile == "" {
+			return nil, errors.New("a TLS certificate file requires a key file")
+		}
+		cert = &fileCertificate{certFile: s.CertFile, keyFile: s.KeyFile}
+		if err := cert.reload(); err != nil {
+			return nil, err
+		}
+	}
+	var clientCAs *x509.CertPool
+	if s.ClientCAFile != "" {
+		pem, err := os.ReadFile(s.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CA file: %w", err)
+		}
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no CA certificates found in %s", s.ClientCAFile)
+		}
+	}
+	if base == nil && clientCAs == nil && clientAuth != nil && *clientAuth != tls.NoClientCert {
+		return nil, errors.New("client certificate authentication requires a client CA file")
+	}
+
+	apply := func(cfg *tls.Config) {
+		cfg.MinVersion = minVersion
+		cfg.CipherSuites = ciphers
+		if cert != nil {
+			cfg.Certificates = nil
+			cfg.GetCertificate = cert.get
+		}
+		if clientCAs != nil {
+			cfg.ClientCAs = clientCAs
+		}
+		if clientAuth != nil {
+			cfg.ClientAuth = *clientAuth
+		}
+	}
+
+	cfg := &tls.Config{}
+	if base != nil {
+		cfg = base.Clone()
+		// An identity config that picks up rotated certificates returns a
+		// config per handshake, which the settings apply to as well
+		if forClient := cfg.GetConfigForClient; forClient != nil {
+			cfg.GetConfigForClient = func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+				clientCfg, err := forClient(hello)
+				if err != nil || clientCfg == nil {
+					return clientCfg, err
+				}
+				apply(clientCfg)
+				return clientCfg, nil
+			}
+		}
+	}
+	apply(cfg)
+	return cfg, nil
+}
+
+// cipherSuites looks up cipher suites by name. Only Go's secure suites are
+// accepted.
+func cipherSuites(names []string) ([]uint16, error) {
+	var ids []uint16
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		found := false
+		for _, suite := range tls.CipherSuites() {
+			if suite.Name == name {
+				ids = append(ids, suite.ID)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown or insecure cipher suite %q", name)
+		}
+	}
+	return ids, nil
+}
+
+// clientAuthType parses a client certificate mode. It returns nil when no
+// mode is set, leaving the base config's.
+func clientAuthType(mode string) (*tls.ClientAuthType, error) {
+	var t tls.ClientAuthType
+	switch mode {
+	case "":
+		return nil, nil
+	case ClientAuthNone:
+		t = tls.NoClientCert
+	case ClientAuthOptional:
+		t = tls.VerifyClientCertIfGiven
+	case ClientAuthRequire:
+		t = tls.RequireAndVerifyClientCert
+	default:
+		return nil, fmt.Errorf("invalid client auth %q: must be one of none, optional, require", mode)
+	}
+	return &t, nil
+}
+
+// fileCertificate is a certificate read from PEM files and reread when they
+// change
+type fileCertificate struct {
+	certFile string
+	keyFile  string
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	modTime   time.Time
+	checkedAt time.Time
+}
+
+// get returns the certificate for a handshake. The previous certificate is
+// kept if the changed files can't be read.
+func (f *fileCertificate) get(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if time.Since(f.checkedAt) >= reloadCheckInterval {
+		f.reloadLocked()
+	}
+	return f.cert, nil
+}
+
+// reload reads the certificate files
+func (f *fileCertificate) reload() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.reloadLocked()
+}
+
+func (f *fileCertificate) reloadLocked() error {
+	f.checkedAt = time.Now()
+	var modTime time.Time
+	for _, file := range []string{f.certFile, f.keyFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return fmt.Errorf("failed to read TLS certificate: %w", err)
+		}
+		if info.ModTime().After(modTime) {
+			modTime = info.ModTime()
+		}
+	}
+	if f.cert != nil && !modTime.After(f.modTime) {
+		return nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(f.certFile, f.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	f.cert = &cert
+	f.modTime = modTime
+	return nil
+}
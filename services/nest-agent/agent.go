@@ -0,0 +1,345 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Engine applies desired state to a database running on the agent's host
+type Engine interface {
+	// Collect gathers the engine's stats and assesses their risk
+	Collect(ctx context.Context) (map[string]interface{}, string, []string, error)
+	// Tune applies changed tuning parameters to the running engine; an
+	// empty value resets a parameter where the engine supports it
+	Tune(ctx context.Context, tuning map[string]string) error
+	// SyncUser creates or updates a user with its password and roles
+	SyncUser(ctx context.Context, user *User) error
+	// Backup writes a backup into dir and returns its path and size
+	Backup(ctx context.Context, dir string) (string, int64, error)
+	// UseCertificate configures the engine to serve the certificate files
+	UseCertificate(ctx context.Context, certFile, keyFile string) error
+	// Close releases the engine's connections
+	Close() error
+}
+
+// engines opens an engine for a resource's desired state, keyed by the
+// resource type's name
+var engines = map[string]func(state *ResourceState, cfg *Config) (Engine, error){
+	"postgresql": newPostgresEngine,
+	"redis":      newRedisEngine,
+}
+
+// supportedEngines returns the engines the agent can manage
+func supportedEngines() []string {
+	names := make([]string, 0, len(engines))
+	for name := range engines {
+		names = append(names, name)
+	}
+	return names
+}
+
+// tuningName matches the parameter names engines accept, so that they can
+// be written into statements unquoted
+var tuningName = regexp.MustCompile(`^[a-z][a-z0-9_.-]*$`)
+
+// Agent polls the API for the desired state of its resources, applies it,
+// and reports the outcome
+type Agent struct {
+	config *Config
+	client *Client
+
+	// tuning is the last tuning applied per resource, so that only
+	// changes are applied on later polls
+	tuning map[uint]map[string]string
+}
+
+// NewAgent creates an agent
+func NewAgent(cfg *Config, client *Client) *Agent {
+	return &Agent{config: cfg, client: client, tuning: make(map[uint]map[string]string)}
+}
+
+// Run registers the agent and then syncs on the poll interval until the
+// context is cancelled
+func (a *Agent) Run(ctx context.Context) {
+	hostname, _ := os.Hostname()
+	for {
+		err := a.client.Register(ctx, hostname, supportedEngines())
+		if err == nil {
+			break
+		}
+		log.Printf("Error registering agent %s: %v", a.config.Name, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(a.config.PollInterval):
+		}
+	}
+	log.Printf("Registered agent %s", a.config.Name)
+
+	ticker := time.NewTicker(a.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		a.sync(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sync applies the desired state of every resource and reports back
+func (a *Agent) sync(ctx context.Context) {
+	desired, err := a.client.DesiredState(ctx)
+	if err != nil {
+		log.Printf("Error fetching desired state: %v", err)
+		return
+	}
+
+	if a.config.SSHCAFile != "" {
+		if err := a.applySSHCAKeys(desired.SSHCAKeys); err != nil {
+			log.Printf("Error installing SSH CA keys: %v", err)
+		}
+	}
+
+	reports := make([]*ResourceReport, 0, len(desired.Resources))
+	for _, state := range desired.Resources {
+		reports = append(reports, a.apply(ctx, state))
+	}
+	if err := a.client.Report(ctx, reports); err != nil {
+		log.Printf("Error reporting state: %v", err)
+	}
+}
+
+// apply applies one resource's desired state. Failures of individual users
+// and backups are reported with them; failures to reach the resource or
+// apply its configuration are reported on the resource.
+func (a *Agent) apply(ctx context.Context, state *ResourceState) *ResourceReport {
+	report := &ResourceReport{ResourceID: state.ResourceID}
+	fail := func(err error) *ResourceReport {
+		log.Printf("Error applying state of resource %d (%s): %v", state.ResourceID, state.Name, err)
+		report.Error = err.Error()
+		return report
+	}
+
+	open, ok := engines[state.Engine]
+	if !ok {
+		return fail(fmt.Errorf("unsupported engine %s", state.Engine))
+	}
+	engine, err := open(state, a.config)
+	if err != nil {
+		return fail(err)
+	}
+	defer engine.Close()
+
+	if state.Certificate != nil {
+		if err := a.applyCertificate(ctx, engine, state); err != nil {
+			return fail(err)
+		}
+	}
+
+	tuning := tuningValues(state.Tuning)
+	if changes := tuningChanges(a.tuning[state.ResourceID], tuning); len(changes) > 0 {
+		if err := engine.Tune(ctx, changes); err != nil {
+			return fail(err)
+		}
+	}
+	a.tuning[state.ResourceID] = tuning
+
+	for _, user := range state.Users {
+		result := &TaskResult{ID: user.ID}
+		if err := engine.SyncUser(ctx, user); err != nil {
+			log.Printf("Error syncing user %s on resource %d: %v", user.Username, state.ResourceID, err)
+			result.Error = err.Error()
+		}
+		report.Users = append(report.Users, result)
+	}
+
+	for _, job := range state.Backups {
+		result := &TaskResult{ID: job.ID}
+		dir := filepath.Join(a.config.BackupDir, state.Name)
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			result.Error = err.Error()
+		} else if result.Location, result.SizeBytes, err = runBackup(ctx, engine, job, dir); err != nil {
+			log.Printf("Error running backup job %d of resource %d: %v", job.ID, state.ResourceID, err)
+			result.Error = err.Error()
+		}
+		report.Backups = append(report.Backups, result)
+	}
+
+	metrics, riskLevel, factors, err := engine.Collect(ctx)
+	if err != nil {
+		return fail(fmt.Errorf("failed to collect stats: %w", err))
+	}
+	metrics["resource_type"] = state.Engine
+	metrics["agent"] = a.config.Name
+	report.Metrics = metrics
+	report.RiskLevel = riskLevel
+	report.RiskFactors = factors
+	return report
+}
+
+// applyCertificate writes a resource's certificate and key into its TLS
+// directory and points the engine at them. Nothing is done when the files
+// already hold the certificate.
+func (a *Agent) applyCertificate(ctx context.Context, engine Engine, state *ResourceState) error {
+	dir := filepath.Join(a.config.TLSDir, state.Name)
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+
+	current, err := os.ReadFile(certFile)
+	if err == nil && bytes.Equal(current, []byte(state.Certificate.Certificate)) {
+		return nil
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	if err := os.WriteFile(keyFile, []byte(state.Certificate.PrivateKey), 0o600); err != nil {
+		return fmt.Errorf("failed to write certificate key: %w", err)
+	}
+	if err := os.WriteFile(certFile, []byte(state.Certificate.Certificate), 0o644); err != nil {
+		return fmt.Errorf("failed to write certificate: %w", err)
+	}
+	if err := engine.UseCertificate(ctx, certFile, keyFile); err != nil {
+		// Retried on the next poll
+		os.Remove(certFile)
+		return fmt.Errorf("failed to load certificate: %w", err)
+	}
+	log.Printf("Installed certificate %d for resource %d, valid until %s",
+		state.Certificate.ID, state.ResourceID, state.Certificate.ValidUntil.Format(time.RFC3339))
+	return nil
+}
+
+// applySSHCAKeys writes the SSH CA keys the host trusts for user
+// certificates, for sshd's TrustedUserCAKeys. The file is replaced rather
+// than written in place, so sshd never reads it half written, and is left
+// alone when it already holds the keys.
+func (a *Agent) applySSHCAKeys(keys []string) error {
+	var content bytes.Buffer
+	for _, key := range keys {
+		content.WriteString(strings.TrimSpace(key))
+		content.WriteByte('\n')
+	}
+
+	current, err := os.ReadFile(a.config.SSHCAFile)
+	if err == nil && bytes.Equal(current, content.Bytes()) {
+		return nil
+	}
+
+	tmp := a.config.SSHCAFile + ".tmp"
+	if err := os.WriteFile(tmp, content.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("failed to write SSH CA keys: %w", err)
+	}
+	if err := os.Rename(tmp, a.config.SSHCAFile); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to install SSH CA keys: %w", err)
+	}
+	log.Printf("Installed %d SSH CA key(s) in %s", len(keys), a.config.SSHCAFile)
+	return nil
+}
+
+// tuningValues converts tuning parameters to strings, dropping names that
+// aren't plain parameter names
+func tuningValues(tuning map[string]interface{}) map[string]string {
+	values := make(map[string]string, len(tuning))
+	for name, v := range tuning {
+		if !tuningName.MatchString(name) {
+			continue
+		}
+		switch value := v.(type) {
+		case string:
+			values[name] = value
+		case float64:
+			values[name] = strconv.FormatFloat(value, 'f', -1, 64)
+		case bool:
+			values[name] = strconv.FormatBool(value)
+		}
+	}
+	return values
+}
+
+// tuningChanges returns the parameters that differ between the previously
+// applied and the desired tuning. Removed parameters map to an empty value,
+// for engines to reset.
+func tuningChanges(previous, desired map[string]string) map[string]string {
+	changes := map[string]string{}
+	for name, value := range desired {
+		if previous[name] != value {
+			changes[name] = value
+		}
+	}
+	for name := range previous {
+		if _, ok := desired[name]; !ok {
+			changes[name] = ""
+		}
+	}
+	return changes
+}
+
+// assessRisk derives a risk level from connection saturation and cache
+// efficiency, as the K8s controller does for resources in the cluster
+func assessRisk(connections, maxConnections int64, cacheHitRatio float64) (string, []string) {
+	level := "low"
+	factors := []string{}
+
+	if maxConnections > 0 {
+		saturation := float64(connections) * 100.0 / float64(maxConnections)
+		if saturation > 95 {
+			level = "high"
+			factors = append(factors, fmt.Sprintf("Connection saturation critical (%.1f%%)", saturation))
+		} else if saturation > 80 {
+			level = "medium"
+			factors = append(factors, fmt.Sprintf("Connection saturation high (%.1f%%)", saturation))
+		}
+	}
+
+	if cacheHitRatio > 0 && cacheHitRatio < 90 {
+		if level == "low" {
+			level = "medium"
+		}
+		factors = append(factors, fmt.Sprintf("Cache hit ratio low (%.1f%%)", cacheHitRatio))
+	}
+	return level, factors
+}
+
+// stringValue returns a string from a JSON object, or a default
+func stringValue(m map[string]interface{}, key, fallback string) string {
+	if v, ok := m[key].(string); ok && v != "" {
+		return v
+	}
+	return fallback
+}
+
+// intValue returns an integer from a JSON object, or a default
+func intValue(m map[string]interface{}, key string, fallback int) int {
+	if v, ok := m[key].(float64); ok && v > 0 {
+		return int(v)
+	}
+	return fallback
+}
+
+// runBackup runs a backup job, stopping it once it has run past the timeout
+// the API gave it
+func runBackup(ctx context.Context, engine Engine, job *BackupJob, dir string) (string, int64, error) {
+	if job.TimeoutSeconds > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(job.TimeoutSeconds)*time.Second)
+		defer cancel()
+	}
+	location, size, err := engine.Backup(ctx, dir)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return "", 0, fmt.Errorf("timed out after %ds: %w", job.TimeoutSeconds, err)
+	}
+	return location, size, err
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/penguintechinc/project-template/shared/mtls"
+)
+
+// ResourceState is the desired state of a resource as served by the API
+type ResourceState struct {
+	ResourceID     uint                   `json:"resource_id"`
+	Name           string                 `json:"name"`
+	Engine         string                 `json:"engine"`
+	LifecycleMode  string                 `json:"lifecycle_mode"`
+	ConnectionInfo map[string]interface{} `json:"connection_info"`
+	Credentials    map[string]interface{} `json:"credentials"`
+	Tuning         map[string]interface{} `json:"tuning"`
+	Users          []*User                `json:"users"`
+	Backups        []*BackupJob           `json:"backups"`
+	Certificate    *Certificate           `json:"certificate"`
+}
+
+// User is a resource user to create or update
+type User struct {
+	ID       uint     `json:"id"`
+	Username string   `json:"username"`
+	Password string   `json:"password"`
+	Roles    []string `json:"roles"`
+}
+
+// BackupJob is a backup to run
+type BackupJob struct {
+	ID             uint   `json:"id"`
+	JobType        string `json:"job_type"`
+	TimeoutSeconds int    `json:"timeout_seconds"`
+}
+
+// Certificate is the TLS certificate a resource should serve
+type Certificate struct {
+	ID          uint      `json:"id"`
+	Certificate string    `json:"certificate"`
+	PrivateKey  string    `json:"private_key"`
+	ValidUntil  time.Time `json:"valid_until"`
+}
+
+// ResourceReport is the outcome of applying a resource's desired state
+type ResourceReport struct {
+	ResourceID  uint                   `json:"resource_id"`
+	Error       string                 `json:"error,omitempty"`
+	Metrics     map[string]interface{} `json:"metrics,omitempty"`
+	RiskLevel   string                 `json:"risk_level,omitempty"`
+	RiskFactors []string               `json:"risk_factors,omitempty"`
+	Users       []*TaskResult          `json:"users,omitempty"`
+	Backups     []*TaskResult          `json:"backups,omitempty"`
+}
+
+// TaskResult is the outcome of syncing a user or running a backup
+type TaskResult struct {
+	ID        uint   `json:"id"`
+	Error     string `json:"error,omitempty"`
+	Location  string `json:"location,omitempty"`
+	SizeBytes int64  `json:"size_bytes,omitempty"`
+}
+
+// Client calls the NEST API with the agent's identity
+type Client struct {
+	baseURL string
+	name    string
+	http    *http.Client
+}
+
+// NewClient creates an API client that presents the identity and verifies
+// the API's certificate against the identity's CA
+func NewClient(baseURL, name string, identity *mtls.Identity) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid API URL: %w", err)
+	}
+	return &Client{
+		baseURL: baseURL,
+		name:    name,
+		http: &http.Client{
+			Timeout: 30 * time.Second,
+			Transport: &http.Transport{
+				TLSClientConfig: identity.ClientConfig(u.Hostname()),
+			},
+		},
+	}, nil
+}
+
+// Register registers the agent with the API
+func (c *Client) Register(ctx context.Context, hostname string, engines []string) error {
+	body := map[string]interface{}{
+		"name":     c.name,
+		"hostname": hostname,
+		"version":  version,
+		"engines":  engines,
+	}
+	return c.do(ctx, http.MethodPost, "/api/v1/agents/register", body, nil)
+}
+
+// DesiredState is the desired state of the agent's resources and host
+type DesiredState struct {
+	Resources []*ResourceState `json:"resources"`
+	SSHCAKeys []string         `json:"ssh_ca_keys"`
+}
+
+// DesiredState fetches the desired state of the agent's resources
+func (c *Client) DesiredState(ctx context.Context) (*DesiredState, error) {
+	var state DesiredState
+	if err := c.do(ctx, http.MethodGet, "/api/v1/agents/"+url.PathEscape(c.name)+"/desired-state", nil, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// Report sends the outcome of applying the desired state
+func (c *Client) Report(ctx context.Context, reports []*ResourceReport) error {
+	body := map[string]interface{}{"resources": reports}
+	return c.do(ctx, http.MethodPost, "/api/v1/agents/"+url.PathEscape(c.name)+"/report", body, nil)
+}
+
+// do sends a request and decodes the response into out. Error responses
+// are returned with the API's message.
+func (c *Client) do(ctx context.Context, method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		payload, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var apiErr struct {
+			Error   string `json:"error"`
+			Message string `json:"message"`
+		}
+		raw, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		if json.Unmarshal(raw, &apiErr) == nil && apiErr.Message != "" {
+			return fmt.Errorf("%s %s: %d %s: %s", method, path, resp.StatusCode, apiErr.Error, apiErr.Message)
+		}
+		return fmt.Errorf("%s %s: %d", method, path, resp.StatusCode)
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/penguintechinc/project-template/shared/mtls"
+)
+
+// Config holds the agent's configuration
+type Config struct {
+	// APIURL is the base URL of the NEST API, e.g. https://nest.example.com
+	APIURL string
+	// Name identifies the agent to the API. It must be the name the
+	// agent's identity is issued for, which it defaults to.
+	Name string
+	// CertDir holds the agent's identity: tls.crt and tls.key issued from
+	// the internal CA or by SPIRE, and ca.crt to verify the API with
+	CertDir string
+	// PollInterval is how often the desired state is fetched and applied
+	PollInterval time.Duration
+	// BackupDir is where backups are written
+	BackupDir string
+	// TLSDir is where resource certificates are written, one directory
+	// per resource
+	TLSDir string
+	// PgDumpPath is the pg_dump binary used for PostgreSQL backups
+	PgDumpPath string
+	// SSHCAFile is where the SSH CA keys the host trusts are written, for
+	// sshd's TrustedUserCAKeys; empty leaves SSH alone
+	SSHCAFile string
+}
+
+// LoadConfig reads the configuration from the environment
+func LoadConfig() (*Config, error) {
+	cfg := &Config{
+		APIURL:       os.Getenv("NEST_API_URL"),
+		Name:         os.Getenv("AGENT_NAME"),
+		CertDir:      getEnv("AGENT_CERT_DIR", mtls.DefaultDir),
+		PollInterval: 30 * time.Second,
+		BackupDir:    getEnv("AGENT_BACKUP_DIR", "/var/lib/nest-agent/backups"),
+		TLSDir:       getEnv("AGENT_TLS_DIR", "/var/lib/nest-agent/tls"),
+		PgDumpPath:   getEnv("AGENT_PG_DUMP", "pg_dump"),
+		SSHCAFile:    os.Getenv("AGENT_SSH_CA_FILE"),
+	}
+	if v := os.Getenv("AGENT_POLL_INTERVAL"); v != "" {
+		if parsed, err := time.ParseDuration(v); err == nil && parsed > 0 {
+			cfg.PollInterval = parsed
+		}
+	}
+
+	if cfg.APIURL == "" {
+		return nil, errors.New("NEST_API_URL is required")
+	}
+	return cfg, nil
+}
+
+// identityAgentName returns the agent name a service name is issued for,
+// such as db01 for nest-agent-db01 or an SVID whose path ends with it
+func identityAgentName(service string) (string, bool) {
+	name := strings.TrimPrefix(path.Base(service), "nest-agent-")
+	if name == path.Base(service) || name == "" {
+		return "", false
+	}
+	return name, true
+}
+
+// identityFiles returns the paths of the agent's certificate, key, and CA
+func (c *Config) identityFiles() (string, string, string) {
+	return filepath.Join(c.CertDir, mtls.CertFileName),
+		filepath.Join(c.CertDir, mtls.KeyFileName),
+		filepath.Join(c.CertDir, mtls.CAFileName)
+}
+
+// getEnv returns an environment variable or a default
+func getEnv(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
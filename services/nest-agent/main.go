@@ -0,0 +1,56 @@
+// Command nest-agent manages databases running on hosts outside Kubernetes
+// for NEST. It registers with the API under its service identity, polls
+// the desired state of the partial and monitor_only resources assigned to
+// it, applies users, tuning, backups, and certificates locally, and
+// reports stats back.
+package main
+
+import (
+	"context"
+	"log"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/penguintechinc/project-template/shared/mtls"
+)
+
+// version is set at build time
+var version = "dev"
+
+func main() {
+	cfg, err := LoadConfig()
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+
+	// Agents authenticate with a certificate rather than static
+	// credentials: an identity issued from the internal CA, or an
+	// X.509-SVID written by a SPIRE agent
+	identity, err := mtls.Load(cfg.identityFiles())
+	if err != nil {
+		log.Fatalf("Failed to load agent identity: %v", err)
+	}
+	if cfg.Name == "" {
+		name, ok := identityAgentName(identity.Name())
+		if !ok {
+			log.Fatalf("Identity %s is not an agent's; agents are issued nest-agent-<name>", identity.Name())
+		}
+		cfg.Name = name
+	}
+
+	client, err := NewClient(cfg.APIURL, cfg.Name, identity)
+	if err != nil {
+		log.Fatalf("Failed to create API client: %v", err)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	go identity.Watch(ctx, time.Minute)
+
+	log.Printf("Starting nest-agent %s as %s (%s), polling %s every %s",
+		version, cfg.Name, identity.Name(), cfg.APIURL, cfg.PollInterval)
+	NewAgent(cfg, client).Run(ctx)
+	log.Println("nest-agent stopped")
+}
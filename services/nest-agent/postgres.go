@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+// postgresEngine manages a PostgreSQL server on the agent's host
+type postgresEngine struct {
+	host     string
+	port     int
+	user     string
+	password string
+	database string
+	pgDump   string
+	conn     *sql.DB
+}
+
+// newPostgresEngine opens a connection to a resource's PostgreSQL server
+func newPostgresEngine(state *ResourceState, cfg *Config) (Engine, error) {
+	e := &postgresEngine{
+		host:     stringValue(state.ConnectionInfo, "host", "localhost"),
+		port:     intValue(state.ConnectionInfo, "port", 5432),
+		user:     stringValue(state.Credentials, "username", "postgres"),
+		password: stringValue(state.Credentials, "password", ""),
+		database: stringValue(state.ConnectionInfo, "database", "postgres"),
+		pgDump:   cfg.PgDumpPath,
+	}
+	dsn := &url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(e.user, e.password),
+		Host:     net.JoinHostPort(e.host, strconv.Itoa(e.port)),
+		Path:     e.database,
+		RawQuery: "sslmode=" + stringValue(state.ConnectionInfo, "ssl_mode", "prefer") + "&connect_timeout=5",
+	}
+	conn, err := sql.Open("pgx", dsn.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open connection: %w", err)
+	}
+	e.conn = conn
+	return e, nil
+}
+
+// Collect reads connection, cache, and size stats
+func (e *postgresEngine) Collect(ctx context.Context) (map[string]interface{}, string, []string, error) {
+	var total, active, idle, idleInTx, maxConns, deadlocks, size int64
+	var cacheHitRatio float64
+	if err := e.conn.QueryRowContext(ctx, `
+		SELECT count(*),
+		       count(*) FILTER (WHERE state = 'active'),
+		       count(*) FILTER (WHERE state = 'idle'),
+		       count(*) FILTER (WHERE state LIKE 'idle in transaction%'),
+		       current_setting('max_connections')::bigint
+		FROM pg_stat_activity
+		WHERE backend_type = 'client backend'`).Scan(&total, &active, &idle, &idleInTx, &maxConns); err != nil {
+		return nil, "", nil, fmt.Errorf("failed to read pg_stat_activity: %w", err)
+	}
+	if err := e.conn.QueryRowContext(ctx, `
+		SELECT COALESCE(sum(blks_hit) * 100.0 / NULLIF(sum(blks_hit) + sum(blks_read), 0), 0),
+		       COALESCE(sum(deadlocks), 0),
+		       (SELECT COALESCE(sum(pg_database_size(datname)), 0) FROM pg_database WHERE NOT datistemplate)
+		FROM pg_stat_database`).Scan(&cacheHitRatio, &deadlocks, &size); err != nil {
+		return nil, "", nil, fmt.Errorf("failed to read pg_stat_database: %w", err)
+	}
+
+	connections := map[string]int64{
+		"total":               total,
+		"active":              active,
+		"idle":                idle,
+		"idle_in_transaction": idleInTx,
+		"max":                 maxConns,
+	}
+	level, factors := assessRisk(total, maxConns, cacheHitRatio)
+	return map[string]interface{}{
+		"connections":     connections,
+		"cache_hit_ratio": cacheHitRatio,
+		"deadlocks":       deadlocks,
+		"size_bytes":      size,
+	}, level, factors, nil
+}
+
+// Tune sets parameters with ALTER SYSTEM and reloads the configuration.
+// Parameters that need a restart take effect at the next restart.
+func (e *postgresEngine) Tune(ctx context.Context, tuning map[string]string) error {
+	for name, value := range tuning {
+		stmt := fmt.Sprintf("ALTER SYSTEM RESET %s", name)
+		if value != "" {
+			stmt = fmt.Sprintf("ALTER SYSTEM SET %s = %s", name, quoteLiteral(value))
+		}
+		if _, err := e.conn.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("failed to set %s: %w", name, err)
+		}
+	}
+	_, err := e.conn.ExecContext(ctx, "SELECT pg_reload_conf()")
+	return err
+}
+
+// SyncUser creates or alters a login role and grants it its roles
+func (e *postgresEngine) SyncUser(ctx context.Context, user *User) error {
+	role := pgx.Identifier{user.Username}.Sanitize()
+
+	var exists bool
+	if err := e.conn.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM pg_roles WHERE rolname = $1)", user.Username).Scan(&exists); err != nil {
+		return err
+	}
+	stmt := "CREATE ROLE " + role + " WITH LOGIN"
+	if exists {
+		stmt = "ALTER ROLE " + role + " WITH LOGIN"
+	}
+	if user.Password != "" {
+		stmt += " PASSWORD " + quoteLiteral(user.Password)
+	}
+	if _, err := e.conn.ExecContext(ctx, stmt); err != nil {
+		return err
+	}
+
+	for _, granted := range user.Roles {
+		if _, err := e.conn.ExecContext(ctx, "GRANT "+pgx.Identifier{granted}.Sanitize()+" TO "+role); err != nil {
+			return fmt.Errorf("failed to grant %s: %w", granted, err)
+		}
+	}
+	return nil
+}
+
+// Backup dumps the database with pg_dump in its custom format
+func (e *postgresEngine) Backup(ctx context.Context, dir string) (string, int64, error) {
+	file := filepath.Join(dir, fmt.Sprintf("%s-%s.dump", e.database, time.Now().UTC().Format("20060102T150405Z")))
+	cmd := exec.CommandContext(ctx, e.pgDump, "--format=custom",
+		"--host", e.host, "--port", strconv.Itoa(e.port), "--username", e.user,
+		"--dbname", e.database, "--file", file)
+	cmd.Env = append(os.Environ(), "PGPASSWORD="+e.password)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		os.Remove(file)
+		return "", 0, fmt.Errorf("pg_dump failed: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+
+	info, err := os.Stat(file)
+	if err != nil {
+		return "", 0, err
+	}
+	return file, info.Size(), nil
+}
+
+// UseCertificate enables SSL with the certificate files and reloads the
+// configuration
+func (e *postgresEngine) UseCertificate(ctx context.Context, certFile, keyFile string) error {
+	return e.Tune(ctx, map[string]string{
+		"ssl":           "on",
+		"ssl_cert_file": certFile,
+		"ssl_key_file":  keyFile,
+	})
+}
+
+// Close closes the connection
+func (e *postgresEngine) Close() error {
+	return e.conn.Close()
+}
+
+// quoteLiteral quotes a string as a SQL literal
+func quoteLiteral(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
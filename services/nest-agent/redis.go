@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisSaveTimeout bounds how long a backup waits for BGSAVE to finish
+const redisSaveTimeout = 10 * time.Minute
+
+// redisEngine manages a Redis server on the agent's host
+type redisEngine struct {
+	client *redis.Client
+}
+
+// newRedisEngine opens a client for a resource's Redis server
+func newRedisEngine(state *ResourceState, cfg *Config) (Engine, error) {
+	host := stringValue(state.ConnectionInfo, "host", "localhost")
+	port := intValue(state.ConnectionInfo, "port", 6379)
+	client := redis.NewClient(&redis.Options{
+		Addr:        net.JoinHostPort(host, strconv.Itoa(port)),
+		Username:    stringValue(state.Credentials, "username", ""),
+		Password:    stringValue(state.Credentials, "password", ""),
+		DB:          intValue(state.ConnectionInfo, "database", 0),
+		DialTimeout: 5 * time.Second,
+	})
+	return &redisEngine{client: client}, nil
+}
+
+// Collect reads client, memory, and keyspace stats from INFO
+func (e *redisEngine) Collect(ctx context.Context) (map[string]interface{}, string, []string, error) {
+	raw, err := e.client.Info(ctx, "clients", "memory", "stats").Result()
+	if err != nil {
+		return nil, "", nil, err
+	}
+	info := parseRedisInfo(raw)
+
+	maxClients, err := e.client.ConfigGet(ctx, "maxclients").Result()
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	connected := infoInt(info, "connected_clients")
+	max, _ := strconv.ParseInt(maxClients["maxclients"], 10, 64)
+	hits := infoInt(info, "keyspace_hits")
+	misses := infoInt(info, "keyspace_misses")
+	var hitRatio float64
+	if hits+misses > 0 {
+		hitRatio = float64(hits) * 100.0 / float64(hits+misses)
+	}
+
+	level, factors := assessRisk(connected, max, hitRatio)
+	return map[string]interface{}{
+		"connections":       map[string]int64{"total": connected, "max": max},
+		"cache_hit_ratio":   hitRatio,
+		"used_memory_bytes": infoInt(info, "used_memory"),
+		"max_memory_bytes":  infoInt(info, "maxmemory"),
+		"evicted_keys":      infoInt(info, "evicted_keys"),
+	}, level, factors, nil
+}
+
+// Tune sets parameters with CONFIG SET. Removed parameters keep their
+// current value until the next restart, as Redis has no reset to default.
+func (e *redisEngine) Tune(ctx context.Context, tuning map[string]string) error {
+	for name, value := range tuning {
+		if value == "" {
+			continue
+		}
+		switch value {
+		case "true":
+			value = "yes"
+		case "false":
+			value = "no"
+		}
+		if err := e.client.ConfigSet(ctx, name, value).Err(); err != nil {
+			return fmt.Errorf("failed to set %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// SyncUser creates or updates an ACL user. Roles are taken as ACL rules,
+// such as "~app:*" or "+@read".
+func (e *redisEngine) SyncUser(ctx context.Context, user *User) error {
+	args := []interface{}{"ACL", "SETUSER", user.Username, "on"}
+	if user.Password != "" {
+		args = append(args, "resetpass", ">"+user.Password)
+	}
+	for _, rule := range user.Roles {
+		args = append(args, rule)
+	}
+	return e.client.Do(ctx, args...).Err()
+}
+
+// Backup runs BGSAVE, waits for it to finish, and copies the RDB file into
+// dir. The agent must be able to read the server's data directory.
+func (e *redisEngine) Backup(ctx context.Context, dir string) (string, int64, error) {
+	before, err := e.client.LastSave(ctx).Result()
+	if err != nil {
+		return "", 0, err
+	}
+	if err := e.client.BgSave(ctx).Err(); err != nil {
+		return "", 0, fmt.Errorf("BGSAVE failed: %w", err)
+	}
+
+	deadline := time.Now().Add(redisSaveTimeout)
+	for {
+		last, err := e.client.LastSave(ctx).Result()
+		if err != nil {
+			return "", 0, err
+		}
+		if last > before {
+			break
+		}
+		if time.Now().After(deadline) {
+			return "", 0, fmt.Errorf("BGSAVE did not finish within %s", redisSaveTimeout)
+		}
+		select {
+		case <-ctx.Done():
+			return "", 0, ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+
+	cfg, err := e.client.ConfigGet(ctx, "dir").Result()
+	if err != nil {
+		return "", 0, err
+	}
+	name, err := e.client.ConfigGet(ctx, "dbfilename").Result()
+	if err != nil {
+		return "", 0, err
+	}
+	source := filepath.Join(cfg["dir"], name["dbfilename"])
+	target := filepath.Join(dir, fmt.Sprintf("dump-%s.rdb", time.Now().UTC().Format("20060102T150405Z")))
+	size, err := copyFile(source, target)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to copy %s: %w", source, err)
+	}
+	return target, size, nil
+}
+
+// UseCertificate points the TLS listener at the certificate files. The
+// server must have been built with TLS support and have a tls-port.
+func (e *redisEngine) UseCertificate(ctx context.Context, certFile, keyFile string) error {
+	if err := e.client.ConfigSet(ctx, "tls-cert-file", certFile).Err(); err != nil {
+		return err
+	}
+	return e.client.ConfigSet(ctx, "tls-key-file", keyFile).Err()
+}
+
+// Close closes the client
+func (e *redisEngine) Close() error {
+	return e.client.Close()
+}
+
+// parseRedisInfo parses the key:value lines of an INFO reply
+func parseRedisInfo(raw string) map[string]string {
+	info := make(map[string]string)
+	for _, line := range strings.Split(raw, "\r\n") {
+		if key, value, ok := strings.Cut(line, ":"); ok && !strings.HasPrefix(line, "#") {
+			info[key] = value
+		}
+	}
+	return info
+}
+
+// infoInt returns an integer field of an INFO reply
+func infoInt(info map[string]string, key string) int64 {
+	n, _ := strconv.ParseInt(info[key], 10, 64)
+	return n
+}
+
+// copyFile copies a file and returns the number of bytes copied
+func copyFile(source, target string) (int64, error) {
+	in, err := os.Open(source)
+	if err != nil {
+		return 0, err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return 0, err
+	}
+	size, err := io.Copy(out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(target)
+	}
+	return size, err
+}
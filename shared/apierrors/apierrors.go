@@ -0,0 +1,229 @@
+// Package apierrors renders API errors as RFC 7807 problem details. Handlers
+// abort with a typed error code and a message; Middleware gives every
+// request an ID, which is echoed in each problem, and turns panics,
+// unrendered errors, and unknown routes into problems as well. Messages are
+// translated into the language negotiated from Accept-Language.
+package apierrors
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ContentType is the media type of problem responses
+const ContentType = "application/problem+json"
+
+// RequestIDHeader carries the request ID in requests and responses
+const RequestIDHeader = "X-Request-ID"
+
+// requestIDKey is the Gin context key of the request ID
+const requestIDKey = "request_id"
+
+// maxRequestIDLength bounds request IDs accepted from clients
+const maxRequestIDLength = 128
+
+// Code identifies the kind of an error. Clients should branch on the code
+// rather than the message, which may change and is translated.
+type Code string
+
+// Codes shared by every service. Services define their own, more specific
+// codes as Code constants or literals.
+const (
+	CodeInvalidRequest     Code = "invalid_request"
+	CodeUnauthorized       Code = "unauthorized"
+	CodeForbidden          Code = "forbidden"
+	CodeNotFound           Code = "not_found"
+	CodeMethodNotAllowed   Code = "method_not_allowed"
+	CodeConflict           Code = "conflict"
+	CodeDatabaseError      Code = "database_error"
+	CodeInternal           Code = "internal_error"
+	CodeServiceUnavailable Code = "service_unavailable"
+	CodeFeatureNotLicensed Code = "feature_not_licensed"
+)
+
+// Error is an API error with the HTTP status it is reported with
+type Error struct {
+	Status  int
+	Code    Code
+	Message string
+	Details interface{}
+	Err     error
+}
+
+// New creates an API error
+func New(status int, code Code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+// WithDetails attaches details, such as a validation error, to the error
+func (e *Error) WithDetails(details interface{}) *Error {
+	e.Details = details
+	return e
+}
+
+// Wrap records the underlying error, which is logged but not returned to
+// the client
+func (e *Error) Wrap(err error) *Error {
+	e.Err = err
+	return e
+}
+
+// Error implements the error interface
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Message, e.Err)
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// Unwrap returns the underlying error
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Problem is an RFC 7807 problem details document. Error and Message repeat
+// Code and Detail for clients of the earlier error format.
+type Problem struct {
+	Type      string      `json:"type"`
+	Title     string      `json:"title"`
+	Status    int         `json:"status"`
+	Detail    string      `json:"detail,omitempty"`
+	Instance  string      `json:"instance,omitempty"`
+	Code      Code        `json:"code"`
+	RequestID string      `json:"request_id,omitempty"`
+	Details   interface{} `json:"details,omitempty"`
+	Error     Code        `json:"error"`
+	Message   string      `json:"message"`
+}
+
+// NewProblem builds the problem document of an error for a request. The
+// title and message are translated into the request's language.
+func NewProblem(c *gin.Context, e *Error) *Problem {
+	language := Language(c)
+	message := Translate(language, e.Message)
+	return &Problem{
+		Type:      "urn:nest:problem:" + string(e.Code),
+		Title:     title(language, e.Status),
+		Status:    e.Status,
+		Detail:    message,
+		Instance:  c.Request.URL.Path,
+		Code:      e.Code,
+		RequestID: RequestID(c),
+		Details:   e.Details,
+		Error:     e.Code,
+		Message:   message,
+	}
+}
+
+// Abort stops the handler chain and writes an error response
+func Abort(c *gin.Context, status int, code Code, message string) {
+	AbortWith(c, New(status, code, message))
+}
+
+// AbortWithDetails stops the handler chain and writes an error response
+// with details
+func AbortWithDetails(c *gin.Context, status int, code Code, message string, details interface{}) {
+	AbortWith(c, New(status, code, message).WithDetails(details))
+}
+
+// AbortWith stops the handler chain and writes the error's problem
+// document. The error is also recorded on the context for logging.
+func AbortWith(c *gin.Context, e *Error) {
+	c.Error(e)
+	c.Abort()
+	Write(c, e)
+}
+
+// Write writes the problem document of an error
+func Write(c *gin.Context, e *Error) {
+	body, err := json.Marshal(NewProblem(c, e))
+	if err != nil {
+		body = []byte(`{"type":"urn:nest:problem:internal_error","status":500,"code":"internal_error"}`)
+	}
+	c.Header("Content-Language", Language(c))
+	c.Header("Vary", "Accept-Language")
+	c.Data(e.Status, ContentType, body)
+}
+
+// RequestID returns the ID of the current request
+func RequestID(c *gin.Context) string {
+	return c.GetString(requestIDKey)
+}
+
+// Middleware assigns each request an ID, taken from X-Request-ID when the
+// client sent a usable one, and reports panics and errors that handlers
+// recorded without writing a response as problems
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		c.Set(requestIDKey, id)
+		c.Header(RequestIDHeader, id)
+
+		defer func() {
+			if r := recover(); r != nil {
+				log.Printf("Panic handling request %s: %v\n%s", id, r, debug.Stack())
+				if !c.Writer.Written() {
+					AbortWith(c, New(http.StatusInternalServerError, CodeInternal, "Internal server error"))
+				}
+				c.Abort()
+			}
+		}()
+
+		c.Next()
+
+		if c.Writer.Written() || len(c.Errors) == 0 {
+			return
+		}
+		last := c.Errors.Last().Err
+		var apiErr *Error
+		if !errors.As(last, &apiErr) {
+			log.Printf("Error handling request %s: %v", id, last)
+			apiErr = New(http.StatusInternalServerError, CodeInternal, "Internal server error")
+		}
+		Write(c, apiErr)
+	}
+}
+
+// NotFound reports requests for unknown routes
+func NotFound(c *gin.Context) {
+	Abort(c, http.StatusNotFound, CodeNotFound, "Route not found")
+}
+
+// MethodNotAllowed reports requests with a method the route doesn't accept
+func MethodNotAllowed(c *gin.Context) {
+	Abort(c, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Method not allowed")
+}
+
+// validRequestID reports whether a client supplied request ID is safe to
+// echo back
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		if r < 0x21 || r > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID generates a random request ID
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,448 @@
+package apierrors
+
+// catalogGerman translates error messages into German
+var catalogGerman = map[string]string{
+	"A Docker host with this name already exists":                       "Ein Docker-Host mit diesem Namen existiert bereits",
+	"A binding already writes this Secret":                              "Eine Bindung schreibt dieses Secret bereits",
+	"A client certificate is required":                                  "Ein Client-Zertifikat ist erforderlich",
+	"A cloud account with this name already exists":                     "Ein Cloud-Konto mit diesem Namen existiert bereits",
+	"A container policy with this name already exists for the team":     "Für dieses Team existiert bereits eine Container-Richtlinie mit diesem Namen",
+	"A policy with this name already exists":                            "Eine Richtlinie mit diesem Namen existiert bereits",
+	"A resource can't have both an agent and a Docker host":             "Eine Ressource kann nicht sowohl einen Agenten als auch einen Docker-Host haben",
+	"A resource with this name already exists in this team environment": "In dieser Teamumgebung existiert bereits eine Ressource mit diesem Namen",
+	"A running job can't be discarded":                                  "Ein laufender Job kann nicht verworfen werden",
+	"A saved view with this name already exists":                        "Eine gespeicherte Ansicht mit diesem Namen existiert bereits",
+	"A tenant with this slug already exists":                            "Ein Mandant mit diesem Kurznamen existiert bereits",
+	"A validation webhook could not be reached":                         "Ein Validierungs-Webhook ist nicht erreichbar",
+	"A validation webhook with this name already exists":                "Ein Validierungs-Webhook mit diesem Namen existiert bereits",
+	"Access from this network address is not allowed":                   "Zugriff von dieser Netzwerkadresse ist nicht erlaubt",
+	"Adoption candidate not found":                                      "Übernahmekandidat nicht gefunden",
+	"Agent is registered to a different identity":                       "Der Agent ist für eine andere Identität registriert",
+	"Agent not found": "Agent nicht gefunden",
+	"Agent-managed resources can't be bound to namespaces":                 "Agentenverwaltete Ressourcen können nicht an Namespaces gebunden werden",
+	"Alert rule not found":                                                 "Alarmregel nicht gefunden",
+	"Allowed image not found":                                              "Zugelassenes Image nicht gefunden",
+	"An agent with this name is registered to a different identity":        "Ein Agent mit diesem Namen ist für eine andere Identität registriert",
+	"An invitation for this email address is already pending":              "Für diese E-Mail-Adresse steht bereits eine Einladung aus",
+	"Announcement not found":                                               "Ankündigung nicht gefunden",
+	"Archive run could not be started":                                     "Archivierungslauf konnte nicht gestartet werden",
+	"Authentication required":                                              "Authentifizierung erforderlich",
+	"Backstage token not found":                                            "Backstage-Token nicht gefunden",
+	"Backup job not found":                                                 "Sicherungsauftrag nicht gefunden",
+	"Cannot delete the global team":                                        "Das globale Team kann nicht gelöscht werden",
+	"Client certificate is not allowed":                                    "Das Client-Zertifikat ist nicht zugelassen",
+	"Cloud account not found":                                              "Cloud-Konto nicht gefunden",
+	"Confirmation token or username does not match":                        "Bestätigungstoken oder Benutzername stimmt nicht überein",
+	"Consumer binding not found":                                           "Consumer-Bindung nicht gefunden",
+	"Container policy not found":                                           "Container-Richtlinie nicht gefunden",
+	"Deleted resource not found or you do not have access":                 "Gelöschte Ressource nicht gefunden oder kein Zugriff",
+	"Diagnostics are only served to local clients":                         "Diagnosedaten werden nur lokalen Clients bereitgestellt",
+	"Docker host not found":                                                "Docker-Host nicht gefunden",
+	"Either team_id or resource_id is required":                            "Entweder team_id oder resource_id ist erforderlich",
+	"Email template does not render":                                       "E-Mail-Vorlage lässt sich nicht rendern",
+	"Email template not found":                                             "E-Mail-Vorlage nicht gefunden",
+	"Email template override not found":                                    "Überschreibung der E-Mail-Vorlage nicht gefunden",
+	"Environment is not part of the team's pipeline":                       "Die Umgebung ist nicht Teil der Pipeline des Teams",
+	"Erasure request has expired":                                          "Die Löschanfrage ist abgelaufen",
+	"Erasure request is no longer pending":                                 "Die Löschanfrage ist nicht mehr ausstehend",
+	"Erasure request not found":                                            "Löschanfrage nicht gefunden",
+	"Exactly one of from_event_id or since is required":                    "Genau eines von from_event_id oder since ist erforderlich",
+	"Failed to accept invitation":                                          "Einladung konnte nicht angenommen werden",
+	"Failed to acknowledge announcement":                                   "Ankündigung konnte nicht bestätigt werden",
+	"Failed to add team member":                                            "Teammitglied konnte nicht hinzugefügt werden",
+	"Failed to adopt StatefulSet":                                          "StatefulSet konnte nicht übernommen werden",
+	"Failed to apply resource":                                             "Ressource konnte nicht angewendet werden",
+	"Failed to build overview":                                             "Übersicht konnte nicht erstellt werden",
+	"Failed to build usage report":                                         "Nutzungsbericht konnte nicht erstellt werden",
+	"Failed to cancel backup job":                                          "Sicherungsauftrag konnte nicht abgebrochen werden",
+	"Failed to cancel erasure":                                             "Löschung konnte nicht abgebrochen werden",
+	"Failed to cancel provisioning job":                                    "Bereitstellungsauftrag konnte nicht abgebrochen werden",
+	"Failed to check container policies":                                   "Container-Richtlinien konnten nicht geprüft werden",
+	"Failed to check deletion protection":                                  "Löschschutz konnte nicht geprüft werden",
+	"Failed to check environment usage":                                    "Nutzung der Umgebungen konnte nicht geprüft werden",
+	"Failed to check existing resources":                                   "Vorhandene Ressourcen konnten nicht geprüft werden",
+	"Failed to check membership status":                                    "Mitgliedschaftsstatus konnte nicht geprüft werden",
+	"Failed to check network access rules":                                 "Netzwerkzugriffsregeln konnten nicht geprüft werden",
+	"Failed to check permissions":                                          "Berechtigungen konnten nicht geprüft werden",
+	"Failed to check resource access":                                      "Ressourcenzugriff konnte nicht geprüft werden",
+	"Failed to check resource claims":                                      "Ressourcen-Claims konnten nicht geprüft werden",
+	"Failed to check resource lock":                                        "Ressourcensperre konnte nicht geprüft werden",
+	"Failed to check security compliance":                                  "Sicherheitskonformität konnte nicht geprüft werden",
+	"Failed to check target environment":                                   "Zielumgebung konnte nicht geprüft werden",
+	"Failed to check team dependencies":                                    "Teamabhängigkeiten konnten nicht geprüft werden",
+	"Failed to check team name uniqueness":                                 "Eindeutigkeit des Teamnamens konnte nicht geprüft werden",
+	"Failed to check tenant hosts":                                         "Mandanten-Hosts konnten nicht geprüft werden",
+	"Failed to commit transaction":                                         "Transaktion konnte nicht abgeschlossen werden",
+	"Failed to count alerts":                                               "Alarme konnten nicht gezählt werden",
+	"Failed to count audit logs":                                           "Audit-Log-Einträge konnten nicht gezählt werden",
+	"Failed to count resources":                                            "Ressourcen konnten nicht gezählt werden",
+	"Failed to create Backstage token":                                     "Backstage-Token konnte nicht erstellt werden",
+	"Failed to create Docker host":                                         "Docker-Host konnte nicht erstellt werden",
+	"Failed to create alert rule":                                          "Alarmregel konnte nicht erstellt werden",
+	"Failed to create allowed image":                                       "Zugelassenes Image konnte nicht erstellt werden",
+	"Failed to create announcement":                                        "Ankündigung konnte nicht erstellt werden",
+	"Failed to create cloud account":                                       "Cloud-Konto konnte nicht erstellt werden",
+	"Failed to create consumer binding":                                    "Consumer-Bindung konnte nicht erstellt werden",
+	"Failed to create container policy":                                    "Container-Richtlinie konnte nicht erstellt werden",
+	"Failed to create image registry":                                      "Image-Registry konnte nicht erstellt werden",
+	"Failed to create integration":                                         "Integration konnte nicht erstellt werden",
+	"Failed to create invitation":                                          "Einladung konnte nicht erstellt werden",
+	"Failed to create network access rule":                                 "Netzwerkzugriffsregel konnte nicht erstellt werden",
+	"Failed to create policy":                                              "Richtlinie konnte nicht erstellt werden",
+	"Failed to create resource":                                            "Ressource konnte nicht erstellt werden",
+	"Failed to create team":                                                "Team konnte nicht erstellt werden",
+	"Failed to create tenant":                                              "Mandant konnte nicht erstellt werden",
+	"Failed to create validation webhook":                                  "Validierungs-Webhook konnte nicht erstellt werden",
+	"Failed to create workload identity":                                   "Workload-Identität konnte nicht erstellt werden",
+	"Failed to delete Backstage token":                                     "Backstage-Token konnte nicht gelöscht werden",
+	"Failed to delete Docker host":                                         "Docker-Host konnte nicht gelöscht werden",
+	"Failed to delete agent":                                               "Agent konnte nicht gelöscht werden",
+	"Failed to delete alert rule":                                          "Alarmregel konnte nicht gelöscht werden",
+	"Failed to delete allowed image":                                       "Zugelassenes Image konnte nicht gelöscht werden",
+	"Failed to delete announcement":                                        "Ankündigung konnte nicht gelöscht werden",
+	"Failed to delete cloud account":                                       "Cloud-Konto konnte nicht gelöscht werden",
+	"Failed to delete consumer binding":                                    "Consumer-Bindung konnte nicht gelöscht werden",
+	"Failed to delete container policy":                                    "Container-Richtlinie konnte nicht gelöscht werden",
+	"Failed to delete email template":                                      "E-Mail-Vorlage konnte nicht gelöscht werden",
+	"Failed to delete feature flag":                                        "Feature-Flag konnte nicht gelöscht werden",
+	"Failed to delete image registry":                                      "Image-Registry konnte nicht gelöscht werden",
+	"Failed to delete integration":                                         "Integration konnte nicht gelöscht werden",
+	"Failed to delete invitation":                                          "Einladung konnte nicht gelöscht werden",
+	"Failed to delete network access rule":                                 "Netzwerkzugriffsregel konnte nicht gelöscht werden",
+	"Failed to delete policy":                                              "Richtlinie konnte nicht gelöscht werden",
+	"Failed to delete preference":                                          "Einstellung konnte nicht gelöscht werden",
+	"Failed to delete resource":                                            "Ressource konnte nicht gelöscht werden",
+	"Failed to delete saved view":                                          "Gespeicherte Ansicht konnte nicht gelöscht werden",
+	"Failed to delete team members":                                        "Teammitglieder konnten nicht gelöscht werden",
+	"Failed to delete team":                                                "Team konnte nicht gelöscht werden",
+	"Failed to delete validation webhook":                                  "Validierungs-Webhook konnte nicht gelöscht werden",
+	"Failed to delete workload identity":                                   "Workload-Identität konnte nicht gelöscht werden",
+	"Failed to discard job":                                                "Job konnte nicht verworfen werden",
+	"Failed to dismiss adoption candidate":                                 "Übernahmekandidat konnte nicht verworfen werden",
+	"Failed to erase user data":                                            "Benutzerdaten konnten nicht gelöscht werden",
+	"Failed to evaluate permissions":                                       "Berechtigungen konnten nicht ausgewertet werden",
+	"Failed to evaluate feature flags":                                     "Feature-Flags konnten nicht ausgewertet werden",
+	"Failed to export backup coverage":                                     "Backup-Abdeckung konnte nicht exportiert werden",
+	"Failed to export certificates":                                        "Zertifikate konnten nicht exportiert werden",
+	"Failed to export resources":                                           "Ressourcen konnten nicht exportiert werden",
+	"Failed to export user data":                                           "Benutzerdaten konnten nicht exportiert werden",
+	"Failed to fetch reconcile status":                                     "Abgleichstatus konnte nicht abgerufen werden",
+	"Failed to fetch team deletion":                                        "Teamlöschung konnte nicht abgerufen werden",
+	"Failed to fetch team":                                                 "Team konnte nicht abgerufen werden",
+	"Failed to fetch transfer team":                                        "Zielteam der Übertragung konnte nicht abgerufen werden",
+	"Failed to generate token":                                             "Token konnte nicht erzeugt werden",
+	"Failed to list Backstage tokens":                                      "Backstage-Tokens konnten nicht aufgelistet werden",
+	"Failed to list Docker hosts":                                          "Docker-Hosts konnten nicht aufgelistet werden",
+	"Failed to list SSH certificates":                                      "SSH-Zertifikate konnten nicht aufgelistet werden",
+	"Failed to list adoption candidates":                                   "Übernahmekandidaten konnten nicht aufgelistet werden",
+	"Failed to list agents":                                                "Agenten konnten nicht aufgelistet werden",
+	"Failed to list alert rules":                                           "Alarmregeln konnten nicht aufgelistet werden",
+	"Failed to list alerts":                                                "Alarme konnten nicht aufgelistet werden",
+	"Failed to list allowed images":                                        "Zugelassene Images konnten nicht aufgelistet werden",
+	"Failed to list announcements":                                         "Ankündigungen konnten nicht aufgelistet werden",
+	"Failed to list archive runs":                                          "Archivierungsläufe konnten nicht aufgelistet werden",
+	"Failed to list audit logs":                                            "Audit-Log-Einträge konnten nicht aufgelistet werden",
+	"Failed to list certificates":                                          "Zertifikate konnten nicht aufgelistet werden",
+	"Failed to list cloud accounts":                                        "Cloud-Konten konnten nicht aufgelistet werden",
+	"Failed to list consumer bindings":                                     "Consumer-Bindungen konnten nicht aufgelistet werden",
+	"Failed to list container policies":                                    "Container-Richtlinien konnten nicht aufgelistet werden",
+	"Failed to list controller retry queue":                                "Wiederholungswarteschlange des Controllers konnte nicht aufgelistet werden",
+	"Failed to list controllers":                                           "Controller konnten nicht aufgelistet werden",
+	"Failed to list deleted resources":                                     "Gelöschte Ressourcen konnten nicht aufgelistet werden",
+	"Failed to list environments":                                          "Umgebungen konnten nicht aufgelistet werden",
+	"Failed to list feature flags":                                         "Feature-Flags konnten nicht aufgelistet werden",
+	"Failed to list image registries":                                      "Image-Registries konnten nicht aufgelistet werden",
+	"Failed to list integrations":                                          "Integrationen konnten nicht aufgelistet werden",
+	"Failed to list invitations":                                           "Einladungen konnten nicht aufgelistet werden",
+	"Failed to list jobs":                                                  "Jobs konnten nicht aufgelistet werden",
+	"Failed to list network access rules":                                  "Netzwerkzugriffsregeln konnten nicht aufgelistet werden",
+	"Failed to list operations":                                            "Vorgänge konnten nicht aufgelistet werden",
+	"Failed to list policies":                                              "Richtlinien konnten nicht aufgelistet werden",
+	"Failed to list preferences":                                           "Einstellungen konnten nicht aufgelistet werden",
+	"Failed to list provisioning jobs":                                     "Bereitstellungsjobs konnten nicht aufgelistet werden",
+	"Failed to list resource locks":                                        "Ressourcensperren konnten nicht aufgelistet werden",
+	"Failed to list resources":                                             "Ressourcen konnten nicht aufgelistet werden",
+	"Failed to list retention policies":                                    "Aufbewahrungsrichtlinien konnten nicht aufgelistet werden",
+	"Failed to list saved views":                                           "Gespeicherte Ansichten konnten nicht aufgelistet werden",
+	"Failed to list size classes":                                          "Größenklassen konnten nicht aufgelistet werden",
+	"Failed to list stuck resources":                                       "Hängende Ressourcen konnten nicht aufgelistet werden",
+	"Failed to list teams":                                                 "Teams konnten nicht aufgelistet werden",
+	"Failed to list tenants":                                               "Mandanten konnten nicht aufgelistet werden",
+	"Failed to list tickets":                                               "Tickets konnten nicht aufgelistet werden",
+	"Failed to list validation webhooks":                                   "Validierungs-Webhooks konnten nicht aufgelistet werden",
+	"Failed to list workload identities":                                   "Workload-Identitäten konnten nicht aufgelistet werden",
+	"Failed to load SSH CAs":                                               "SSH-CAs konnten nicht geladen werden",
+	"Failed to load agent resources":                                       "Ressourcen des Agenten konnten nicht geladen werden",
+	"Failed to load agent":                                                 "Agent konnte nicht geladen werden",
+	"Failed to load environments":                                          "Umgebungen konnten nicht geladen werden",
+	"Failed to load features":                                              "Funktionen konnten nicht geladen werden",
+	"Failed to load network access rules":                                  "Netzwerkzugriffsregeln konnten nicht geladen werden",
+	"Failed to load size classes":                                          "Größenklassen konnten nicht geladen werden",
+	"Failed to load team memberships":                                      "Teammitgliedschaften konnten nicht geladen werden",
+	"Failed to load trust bundle":                                          "Vertrauensbündel konnte nicht geladen werden",
+	"Failed to load user":                                                  "Benutzer konnte nicht geladen werden",
+	"Failed to load validation webhooks":                                   "Validierungs-Webhooks konnten nicht geladen werden",
+	"Failed to open approval ticket":                                       "Genehmigungsticket konnte nicht erstellt werden",
+	"Failed to promote resource":                                           "Ressource konnte nicht hochgestuft werden",
+	"Failed to provision tenant schema":                                    "Mandantenschema konnte nicht bereitgestellt werden",
+	"Failed to queue reconcile":                                            "Abgleich konnte nicht eingereiht werden",
+	"Failed to read queue depths":                                          "Warteschlangentiefen konnten nicht gelesen werden",
+	"Failed to record SSH certificate":                                     "SSH-Zertifikat konnte nicht gespeichert werden",
+	"Failed to record agent report":                                        "Bericht des Agenten konnte nicht gespeichert werden",
+	"Failed to register agent":                                             "Agent konnte nicht registriert werden",
+	"Failed to release resource lock":                                      "Ressourcensperre konnte nicht aufgehoben werden",
+	"Failed to remove team member":                                         "Teammitglied konnte nicht entfernt werden",
+	"Failed to requeue job":                                                "Job konnte nicht erneut eingereiht werden",
+	"Failed to resize resource":                                            "Größe der Ressource konnte nicht geändert werden",
+	"Failed to resolve replay position":                                    "Wiedergabeposition konnte nicht ermittelt werden",
+	"Failed to resolve tenant":                                             "Mandant konnte nicht ermittelt werden",
+	"Failed to restore resource":                                           "Ressource konnte nicht wiederhergestellt werden",
+	"Failed to retrieve Docker host":                                       "Docker-Host konnte nicht abgerufen werden",
+	"Failed to retrieve adoption candidate":                                "Übernahmekandidat konnte nicht abgerufen werden",
+	"Failed to retrieve agent":                                             "Agent konnte nicht abgerufen werden",
+	"Failed to retrieve alert rule":                                        "Alarmregel konnte nicht abgerufen werden",
+	"Failed to retrieve announcement":                                      "Ankündigung konnte nicht abgerufen werden",
+	"Failed to retrieve cloud account":                                     "Cloud-Konto konnte nicht abgerufen werden",
+	"Failed to retrieve consumer binding":                                  "Consumer-Bindung konnte nicht abgerufen werden",
+	"Failed to retrieve container policy":                                  "Container-Richtlinie konnte nicht abgerufen werden",
+	"Failed to retrieve database insights":                                 "Datenbankanalysen konnten nicht abgerufen werden",
+	"Failed to retrieve email template":                                    "E-Mail-Vorlage konnte nicht abgerufen werden",
+	"Failed to retrieve erasure request":                                   "Löschanfrage konnte nicht abgerufen werden",
+	"Failed to retrieve feature flag":                                      "Feature-Flag konnte nicht abgerufen werden",
+	"Failed to retrieve image registry":                                    "Image-Registry konnte nicht abgerufen werden",
+	"Failed to retrieve integration":                                       "Integration konnte nicht abgerufen werden",
+	"Failed to retrieve invitation":                                        "Einladung konnte nicht abgerufen werden",
+	"Failed to retrieve job":                                               "Job konnte nicht abgerufen werden",
+	"Failed to retrieve network access rule":                               "Netzwerkzugriffsregel konnte nicht abgerufen werden",
+	"Failed to retrieve operation":                                         "Vorgang konnte nicht abgerufen werden",
+	"Failed to retrieve password policy":                                   "Passwortrichtlinie konnte nicht abgerufen werden",
+	"Failed to retrieve policy":                                            "Richtlinie konnte nicht abgerufen werden",
+	"Failed to retrieve preference":                                        "Einstellung konnte nicht abgerufen werden",
+	"Failed to retrieve resource lock":                                     "Ressourcensperre konnte nicht abgerufen werden",
+	"Failed to retrieve resource type":                                     "Ressourcentyp konnte nicht abgerufen werden",
+	"Failed to retrieve resource":                                          "Ressource konnte nicht abgerufen werden",
+	"Failed to retrieve retention policy":                                  "Aufbewahrungsrichtlinie konnte nicht abgerufen werden",
+	"Failed to retrieve saved view":                                        "Gespeicherte Ansicht konnte nicht abgerufen werden",
+	"Failed to retrieve statistics":                                        "Statistiken konnten nicht abgerufen werden",
+	"Failed to retrieve team member":                                       "Teammitglied konnte nicht abgerufen werden",
+	"Failed to retrieve team members":                                      "Teammitglieder konnten nicht abgerufen werden",
+	"Failed to retrieve team memberships":                                  "Teammitgliedschaften konnten nicht abgerufen werden",
+	"Failed to retrieve team":                                              "Team konnte nicht abgerufen werden",
+	"Failed to retrieve teams":                                             "Teams konnten nicht abgerufen werden",
+	"Failed to retrieve token":                                             "Token konnte nicht abgerufen werden",
+	"Failed to retrieve user roles":                                        "Benutzerrollen konnten nicht abgerufen werden",
+	"Failed to retrieve user":                                              "Benutzer konnte nicht abgerufen werden",
+	"Failed to retrieve validation webhook":                                "Validierungs-Webhook konnte nicht abgerufen werden",
+	"Failed to retrieve workload identity":                                 "Workload-Identität konnte nicht abgerufen werden",
+	"Failed to save email template":                                        "E-Mail-Vorlage konnte nicht gespeichert werden",
+	"Failed to save environments":                                          "Umgebungen konnten nicht gespeichert werden",
+	"Failed to save feature flag":                                          "Feature-Flag konnte nicht gespeichert werden",
+	"Failed to save password policy":                                       "Passwortrichtlinie konnte nicht gespeichert werden",
+	"Failed to save preference":                                            "Einstellung konnte nicht gespeichert werden",
+	"Failed to save retention policy":                                      "Aufbewahrungsrichtlinie konnte nicht gespeichert werden",
+	"Failed to save size classes":                                          "Größenklassen konnten nicht gespeichert werden",
+	"Failed to save view":                                                  "Ansicht konnte nicht gespeichert werden",
+	"Failed to send email":                                                 "E-Mail konnte nicht gesendet werden",
+	"Failed to sign SSH certificate":                                       "SSH-Zertifikat konnte nicht signiert werden",
+	"Failed to start erasure":                                              "Löschung konnte nicht gestartet werden",
+	"Failed to start transaction":                                          "Transaktion konnte nicht gestartet werden",
+	"Failed to sync cloud account":                                         "Cloud-Konto konnte nicht synchronisiert werden",
+	"Failed to update Docker host":                                         "Docker-Host konnte nicht aktualisiert werden",
+	"Failed to update alert rule":                                          "Alarmregel konnte nicht aktualisiert werden",
+	"Failed to update announcement":                                        "Ankündigung konnte nicht aktualisiert werden",
+	"Failed to update cloud account":                                       "Cloud-Konto konnte nicht aktualisiert werden",
+	"Failed to update export cursor":                                       "Export-Cursor konnte nicht aktualisiert werden",
+	"Failed to update image registry":                                      "Image-Registry konnte nicht aktualisiert werden",
+	"Failed to update integration":                                         "Integration konnte nicht aktualisiert werden",
+	"Failed to update network access rule":                                 "Netzwerkzugriffsregel konnte nicht aktualisiert werden",
+	"Failed to update policy":                                              "Richtlinie konnte nicht aktualisiert werden",
+	"Failed to update resource":                                            "Ressource konnte nicht aktualisiert werden",
+	"Failed to update team":                                                "Team konnte nicht aktualisiert werden",
+	"Failed to update validation webhook":                                  "Validierungs-Webhook konnte nicht aktualisiert werden",
+	"Failed to verify Docker host":                                         "Docker-Host konnte nicht überprüft werden",
+	"Failed to verify agent":                                               "Agent konnte nicht überprüft werden",
+	"Failed to verify audit logs":                                          "Audit-Log-Einträge konnten nicht überprüft werden",
+	"Failed to verify resource type":                                       "Ressourcentyp konnte nicht überprüft werden",
+	"Failed to verify resource":                                            "Ressource konnte nicht überprüft werden",
+	"Failed to verify team":                                                "Team konnte nicht überprüft werden",
+	"Feature flag override not found":                                      "Feature-Flag-Überschreibung nicht gefunden",
+	"Failed to record impersonation":                                       "Identitätsübernahme konnte nicht protokolliert werden",
+	"Git sync failed":                                                      "Git-Sync fehlgeschlagen",
+	"Git sync integrations require a team_id":                              "Git-Sync-Integrationen erfordern eine team_id",
+	"Global admin access required":                                         "Globale Administratorrechte erforderlich",
+	"Global admins cannot be impersonated":                                 "Die Identität globaler Administratoren kann nicht übernommen werden",
+	"Global admins must be demoted before they can be erased":              "Globale Administratoren müssen herabgestuft werden, bevor sie gelöscht werden können",
+	"Image registry not found":                                             "Image-Registry nicht gefunden",
+	"Insufficient permissions to access this resource":                     "Unzureichende Berechtigungen für den Zugriff auf diese Ressource",
+	"Insufficient permissions to apply resources":                          "Unzureichende Berechtigungen zum Anwenden von Ressourcen",
+	"Insufficient permissions to cancel jobs":                              "Unzureichende Berechtigungen zum Abbrechen von Aufträgen",
+	"Insufficient permissions to create resources":                         "Unzureichende Berechtigungen zum Erstellen von Ressourcen",
+	"Insufficient permissions to delete resources":                         "Unzureichende Berechtigungen zum Löschen von Ressourcen",
+	"Insufficient permissions to manage alert rules for this team":         "Unzureichende Berechtigungen zum Verwalten der Alarmregeln dieses Teams",
+	"Insufficient permissions to manage consumer bindings":                 "Unzureichende Berechtigungen zum Verwalten von Consumer-Bindungen",
+	"Insufficient permissions to manage this image registry":               "Unzureichende Berechtigungen zum Verwalten dieser Image-Registry",
+	"Insufficient permissions to manage this integration":                  "Unzureichende Berechtigungen zum Verwalten dieser Integration",
+	"Insufficient permissions to promote resources":                        "Unzureichende Berechtigungen zum Hochstufen von Ressourcen",
+	"Insufficient permissions to reconcile resources":                      "Unzureichende Berechtigungen zum Abgleichen von Ressourcen",
+	"Insufficient permissions to request SSH certificates":                 "Unzureichende Berechtigungen zum Anfordern von SSH-Zertifikaten",
+	"Insufficient permissions to resize resources":                         "Unzureichende Berechtigungen zum Ändern der Ressourcengröße",
+	"Insufficient permissions to restore resources":                        "Unzureichende Berechtigungen zum Wiederherstellen von Ressourcen",
+	"Insufficient permissions to update resources":                         "Unzureichende Berechtigungen zum Aktualisieren von Ressourcen",
+	"Insufficient permissions":                                             "Unzureichende Berechtigungen",
+	"Insufficient team permissions":                                        "Unzureichende Teamberechtigungen",
+	"Impersonation is not enabled":                                         "Identitätsübernahme ist nicht aktiviert",
+	"Integration not found":                                                "Integration nicht gefunden",
+	"Integration test failed":                                              "Test der Integration fehlgeschlagen",
+	"Internal server error":                                                "Interner Serverfehler",
+	"Invalid authorization header format":                                  "Ungültiges Format des Authorization-Headers",
+	"Invalid export columns":                                               "Ungültige Exportspalten",
+	"Invalid feature flag key":                                             "Ungültiger Feature-Flag-Schlüssel",
+	"Invalid or expired token":                                             "Ungültiges oder abgelaufenes Token",
+	"Invalid preference key":                                               "Ungültiger Einstellungsschlüssel",
+	"Invalid request body":                                                 "Ungültiger Anfragetext",
+	"Invalid resource ID":                                                  "Ungültige Ressourcen-ID",
+	"Invalid resource type ID":                                             "Ungültige Ressourcentyp-ID",
+	"Invalid saved view":                                                   "Ungültige gespeicherte Ansicht",
+	"Invalid status value":                                                 "Ungültiger Statuswert",
+	"Invalid team ID":                                                      "Ungültige Team-ID",
+	"Invalid user ID":                                                      "Ungültige Benutzer-ID",
+	"Invalid username or password":                                         "Ungültiger Benutzername oder ungültiges Passwort",
+	"Invitation has already been accepted":                                 "Einladung wurde bereits angenommen",
+	"Invitation has expired":                                               "Einladung ist abgelaufen",
+	"Invitation not found":                                                 "Einladung nicht gefunden",
+	"Invitation was sent to a different email address":                     "Einladung wurde an eine andere E-Mail-Adresse gesendet",
+	"Job not found":                                                        "Job nicht gefunden",
+	"Logins must be valid local account names":                             "Logins müssen gültige lokale Kontonamen sein",
+	"Mail is not configured":                                               "E-Mail-Versand ist nicht konfiguriert",
+	"Method not allowed":                                                   "Methode nicht erlaubt",
+	"Missing authorization header":                                         "Authorization-Header fehlt",
+	"Missing or invalid CSRF token":                                        "CSRF-Token fehlt oder ist ungültig",
+	"Network access rule not found":                                        "Netzwerkzugriffsregel nicht gefunden",
+	"No SSH CA issues certificates for this team":                          "Keine SSH-CA stellt Zertifikate für dieses Team aus",
+	"No database insights available for this resource":                     "Für diese Ressource sind keine Datenbankanalysen verfügbar",
+	"No deletion found for team":                                           "Für dieses Team wurde keine Löschung gefunden",
+	"No resource type matches the StatefulSet's engine":                    "Kein Ressourcentyp passt zur Engine des StatefulSets",
+	"No retention policy is configured for this target":                    "Für dieses Ziel ist keine Aufbewahrungsrichtlinie konfiguriert",
+	"No statistics available for this resource":                            "Für diese Ressource sind keine Statistiken verfügbar",
+	"Only dead-lettered jobs can be requeued":                              "Nur Jobs in der Dead-Letter-Queue können erneut eingereiht werden",
+	"Only event export integrations support replay":                        "Nur Integrationen für den Ereignisexport unterstützen die Wiedergabe",
+	"Only full lifecycle resources are reconciled by the controller":       "Nur Ressourcen mit vollständigem Lebenszyklus werden vom Controller abgeglichen",
+	"Only full lifecycle resources can be applied":                         "Nur Ressourcen mit vollem Lebenszyklus können angewendet werden",
+	"Only full lifecycle resources can be resized":                         "Nur Ressourcen mit vollständigem Lebenszyklus können in der Größe geändert werden",
+	"Only git sync integrations can be synced":                             "Nur Git-Sync-Integrationen können synchronisiert werden",
+	"Only git sync integrations receive webhooks":                          "Nur Git-Sync-Integrationen empfangen Webhooks",
+	"Only global admins can create teams":                                  "Nur globale Administratoren können Teams erstellen",
+	"Only global admins can delete teams":                                  "Nur globale Administratoren können Teams löschen",
+	"Only pending or running jobs can be cancelled":                        "Nur ausstehende oder laufende Aufträge können abgebrochen werden",
+	"Only running jobs can be cancelled":                                   "Nur laufende Aufträge können abgebrochen werden",
+	"Only slack integrations receive commands":                             "Nur Slack-Integrationen empfangen Befehle",
+	"Only team admins can request root logins":                             "Nur Team-Administratoren können root-Logins anfordern",
+	"Operation not found or you do not have access":                        "Vorgang nicht gefunden oder kein Zugriff",
+	"Origin not allowed":                                                   "Origin nicht erlaubt",
+	"Platform admin access required":                                       "Plattform-Administratorrechte erforderlich",
+	"Policies could not be evaluated":                                      "Richtlinien konnten nicht ausgewertet werden",
+	"Policy not found":                                                     "Richtlinie nicht gefunden",
+	"Preference limit reached; delete unused preferences first":            "Einstellungslimit erreicht; löschen Sie zuerst nicht verwendete Einstellungen",
+	"Preference not found":                                                 "Einstellung nicht gefunden",
+	"Preference value must be JSON":                                        "Der Einstellungswert muss JSON sein",
+	"Preference values are limited to 16 KiB":                              "Einstellungswerte sind auf 16 KiB begrenzt",
+	"Provisioning job not found":                                           "Bereitstellungsauftrag nicht gefunden",
+	"Public key must be in authorized_keys format":                         "Der öffentliche Schlüssel muss im authorized_keys-Format vorliegen",
+	"Request signature is invalid":                                         "Die Signatur der Anfrage ist ungültig",
+	"Resizing is not enabled for this team":                                "Größenänderungen sind für dieses Team nicht aktiviert",
+	"Resource ID required":                                                 "Ressourcen-ID erforderlich",
+	"Resource has deletion protection enabled; disable it before deleting": "Für die Ressource ist der Löschschutz aktiviert; deaktivieren Sie ihn vor dem Löschen",
+	"Resource is not locked":                                               "Ressource ist nicht gesperrt",
+	"Resource not found or you do not have access":                         "Ressource nicht gefunden oder kein Zugriff",
+	"Resource not found":                                                   "Ressource nicht gefunden",
+	"Resource type not found":                                              "Ressourcentyp nicht gefunden",
+	"Resource was created by a claim; delete the claim instead":            "Die Ressource wurde von einem Claim erstellt; löschen Sie stattdessen den Claim",
+	"Resources can only be promoted to a later environment in the team's pipeline": "Ressourcen können nur in eine spätere Umgebung der Team-Pipeline hochgestuft werden",
+	"Resources exist in environments that would be removed":                        "In den zu entfernenden Umgebungen existieren Ressourcen",
+	"Resources managed by an agent must be partial or monitor_only":                "Von einem Agenten verwaltete Ressourcen müssen partial oder monitor_only sein",
+	"Resources on a Docker host must be full":                                      "Ressourcen auf einem Docker-Host müssen full sein",
+	"Route not found":             "Route nicht gefunden",
+	"SPIFFE ID is already mapped": "Die SPIFFE-ID ist bereits zugeordnet",
+	"SSH certificates are only issued for agent-managed resources": "SSH-Zertifikate werden nur für agentenverwaltete Ressourcen ausgestellt",
+	"Saved view limit reached; delete unused views first":          "Limit für gespeicherte Ansichten erreicht; löschen Sie zuerst nicht verwendete Ansichten",
+	"Saved view not found":                                 "Gespeicherte Ansicht nicht gefunden",
+	"Service account is inactive":                          "Das Dienstkonto ist inaktiv",
+	"Stored database insights could not be parsed":         "Gespeicherte Datenbankanalysen konnten nicht gelesen werden",
+	"TTL must be a positive duration of at most 8h":        "Die TTL muss eine positive Dauer von höchstens 8h sein",
+	"TTL must be a positive duration":                      "Die TTL muss eine positive Dauer sein",
+	"Team ID must be a valid number":                       "Team-ID muss eine gültige Zahl sein",
+	"Team ID required":                                     "Team-ID erforderlich",
+	"Team admin access required to export":                 "Für den Export ist Team-Administratorzugriff erforderlich",
+	"Team admin access required":                           "Team-Administratorrechte erforderlich",
+	"Team member not found":                                "Teammitglied nicht gefunden",
+	"Team name already exists":                             "Teamname existiert bereits",
+	"Team not found":                                       "Team nicht gefunden",
+	"Team owns resources with deletion protection enabled": "Das Team besitzt Ressourcen mit aktiviertem Löschschutz",
+	"Team still owns resources; transfer them with mode=transfer or delete them with mode=force": "Das Team besitzt noch Ressourcen; übertragen Sie sie mit mode=transfer oder löschen Sie sie mit mode=force",
+	"Tenant database is unavailable":                                                 "Mandantendatenbank ist nicht verfügbar",
+	"Tenant slug must be 2-31 lowercase letters, digits, or hyphens":                 "Der Kurzname des Mandanten muss aus 2 bis 31 Kleinbuchstaben, Ziffern oder Bindestrichen bestehen",
+	"The Docker host still has resources":                                            "Der Docker-Host hat noch Ressourcen",
+	"The StatefulSet has already been adopted":                                       "Das StatefulSet wurde bereits übernommen",
+	"The agent still manages resources":                                              "Der Agent verwaltet noch Ressourcen",
+	"The cloud account still has imported resources":                                 "Das Cloud-Konto hat noch importierte Ressourcen",
+	"The custom size class requires config.resources":                                "Die benutzerdefinierte Größenklasse erfordert config.resources",
+	"The global team cannot be deleted":                                              "Das globale Team kann nicht gelöscht werden",
+	"The manifest is too large":                                                      "Das Manifest ist zu groß",
+	"The password does not meet the password policy":                                 "Das Passwort entspricht nicht der Passwortrichtlinie",
+	"The policy could not be compiled":                                               "Die Richtlinie konnte nicht kompiliert werden",
+	"The policy engine could not be reached":                                         "Die Richtlinien-Engine ist nicht erreichbar",
+	"The policy engine is not configured":                                            "Die Richtlinien-Engine ist nicht konfiguriert",
+	"The repository's definitions can't be applied":                                  "Die Definitionen des Repositorys können nicht angewendet werden",
+	"The request must contain exactly one Resource manifest":                         "Die Anfrage muss genau ein Resource-Manifest enthalten",
+	"The resource is locked by another operation":                                    "Die Ressource ist durch einen anderen Vorgang gesperrt",
+	"The resource is managed by a git sync integration":                              "Die Ressource wird von einer Git-Sync-Integration verwaltet",
+	"The resource is past its restore window and is being purged":                    "Das Wiederherstellungsfenster der Ressource ist abgelaufen und sie wird endgültig gelöscht",
+	"The resource violates a blocking policy":                                        "Die Ressource verstößt gegen eine blockierende Richtlinie",
+	"The resource was rejected by a validation webhook":                              "Die Ressource wurde von einem Validierungs-Webhook abgelehnt",
+	"The rules would block your own address":                                         "Die Regeln würden Ihre eigene Adresse sperren",
+	"The workload's SVID is not valid":                                               "Die SVID des Workloads ist ungültig",
+	"This feature requires a license upgrade":                                        "Diese Funktion erfordert ein Lizenz-Upgrade",
+	"Token scope does not allow this request":                                        "Der Geltungsbereich des Tokens erlaubt diese Anfrage nicht",
+	"Transfer team not found":                                                        "Zielteam der Übertragung nicht gefunden",
+	"Unauthorized":                                                                   "Nicht autorisiert",
+	"Unknown resource type":                                                          "Unbekannter Ressourcentyp",
+	"User ID must be a valid number":                                                 "Benutzer-ID muss eine gültige Zahl sein",
+	"User account is inactive":                                                       "Benutzerkonto ist inaktiv",
+	"User context not found":                                                         "Benutzerkontext nicht gefunden",
+	"User does not have access to this team":                                         "Der Benutzer hat keinen Zugriff auf dieses Team",
+	"User does not have admin rights in this team":                                   "Der Benutzer hat keine Administratorrechte in diesem Team",
+	"User does not have required role in team":                                       "Der Benutzer hat nicht die erforderliche Rolle im Team",
+	"User does not have required role":                                               "Der Benutzer hat nicht die erforderliche Rolle",
+	"User is already a member of this team":                                          "Der Benutzer ist bereits Mitglied dieses Teams",
+	"User not found":                                                                 "Benutzer nicht gefunden",
+	"Username or email already exists":                                               "Benutzername oder E-Mail-Adresse existiert bereits",
+	"Username, email, and password are required":                                     "Benutzername, E-Mail-Adresse und Passwort sind erforderlich",
+	"Validation webhook not found":                                                   "Validierungs-Webhook nicht gefunden",
+	"Webhook signature is invalid":                                                   "Die Webhook-Signatur ist ungültig",
+	"Workload identity is not mapped to a service account":                           "Die Workload-Identität ist keinem Dienstkonto zugeordnet",
+	"Workload identity not found":                                                    "Workload-Identität nicht gefunden",
+	"You do not have access to this team":                                            "Sie haben keinen Zugriff auf dieses Team",
+	"action must be one of allow, deny":                                              "action muss allow oder deny sein",
+	"client_cert and client_key must be updated together":                            "client_cert und client_key müssen gemeinsam aktualisiert werden",
+	"ends_at must be after starts_at":                                                "ends_at muss nach starts_at liegen",
+	"failure_policy must be one of: fail, ignore":                                    "failure_policy muss einer der folgenden Werte sein: fail, ignore",
+	"format must be csv or xlsx":                                                     "format muss csv oder xlsx sein",
+	"kind must be Group or Resource":                                                 "kind muss Group oder Resource sein",
+	"kind must be one of init, sidecar":                                              "kind muss init oder sidecar sein",
+	"lifecycle_mode must be one of: full, partial, monitor_only":                     "lifecycle_mode muss full, partial oder monitor_only sein",
+	"metadata.team is required":                                                      "metadata.team ist erforderlich",
+	"mode must be one of block, transfer, force":                                     "mode muss block, transfer oder force sein",
+	"name must be lowercase letters, digits and underscores, starting with a letter": "name darf nur Kleinbuchstaben, Ziffern und Unterstriche enthalten und muss mit einem Buchstaben beginnen",
+	"operations must be a list of: create, update":                                   "operations muss eine Liste aus create, update sein",
+	"refs or team is required":                                                       "refs oder team ist erforderlich",
+	"scope must be catalog, read, or write":                                          "scope muss catalog, read oder write sein",
+	"severity must be one of: block, warn":                                           "severity muss einer der folgenden Werte sein: block, warn",
+	"since must be an RFC3339 timestamp":                                             "since muss ein RFC3339-Zeitstempel sein",
+	"since must be before until":                                                     "since muss vor until liegen",
+	"target must be one of: audit_logs, resource_stats":                              "target muss audit_logs oder resource_stats sein",
+	"timeout_seconds must be between 1 and 30":                                       "timeout_seconds muss zwischen 1 und 30 liegen",
+	"transfer_to must reference a different team":                                    "transfer_to muss auf ein anderes Team verweisen",
+	"until must be an RFC3339 timestamp":                                             "until muss ein RFC3339-Zeitstempel sein",
+	"url must be an absolute http or https URL":                                      "url muss eine absolute http- oder https-URL sein",
+	"within_days must be a number of days":                                           "within_days muss eine Anzahl von Tagen sein",
+}
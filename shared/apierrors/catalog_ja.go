@@ -0,0 +1,448 @@
+package apierrors
+
+// catalogJapanese translates error messages into Japanese
+var catalogJapanese = map[string]string{
+	"A Docker host with this name already exists":                       "この名前の Docker ホストは既に存在します",
+	"A binding already writes this Secret":                              "このSecretは既に別のバインディングが書き込んでいます",
+	"A client certificate is required":                                  "クライアント証明書が必要です",
+	"A cloud account with this name already exists":                     "この名前のクラウドアカウントは既に存在します",
+	"A container policy with this name already exists for the team":     "このチームには同じ名前のコンテナーポリシーが既に存在します",
+	"A policy with this name already exists":                            "この名前のポリシーは既に存在します",
+	"A resource can't have both an agent and a Docker host":             "リソースにエージェントと Docker ホストの両方を指定することはできません",
+	"A resource with this name already exists in this team environment": "このチーム環境には同じ名前のリソースが既に存在します",
+	"A running job can't be discarded":                                  "実行中のジョブは破棄できません",
+	"A saved view with this name already exists":                        "この名前の保存済みビューは既に存在します",
+	"A tenant with this slug already exists":                            "このスラッグのテナントは既に存在します",
+	"A validation webhook could not be reached":                         "検証 Webhook に接続できませんでした",
+	"A validation webhook with this name already exists":                "この名前の検証 Webhook は既に存在します",
+	"Access from this network address is not allowed":                   "このネットワークアドレスからのアクセスは許可されていません",
+	"Adoption candidate not found":                                      "引き継ぎ候補が見つかりません",
+	"Agent is registered to a different identity":                       "エージェントは別の ID で登録されています",
+	"Agent not found": "エージェントが見つかりません",
+	"Agent-managed resources can't be bound to namespaces":                 "エージェント管理のリソースは名前空間にバインドできません",
+	"Alert rule not found":                                                 "アラートルールが見つかりません",
+	"Allowed image not found":                                              "許可されたイメージが見つかりません",
+	"An agent with this name is registered to a different identity":        "この名前のエージェントは別の ID で登録されています",
+	"An invitation for this email address is already pending":              "このメールアドレスへの招待は既に保留中です",
+	"Announcement not found":                                               "お知らせが見つかりません",
+	"Archive run could not be started":                                     "アーカイブ処理を開始できませんでした",
+	"Authentication required":                                              "認証が必要です",
+	"Backstage token not found":                                            "Backstage トークンが見つかりません",
+	"Backup job not found":                                                 "バックアップジョブが見つかりません",
+	"Cannot delete the global team":                                        "グローバルチームは削除できません",
+	"Client certificate is not allowed":                                    "このクライアント証明書は許可されていません",
+	"Cloud account not found":                                              "クラウドアカウントが見つかりません",
+	"Confirmation token or username does not match":                        "確認トークンまたはユーザー名が一致しません",
+	"Consumer binding not found":                                           "コンシューマーバインディングが見つかりません",
+	"Container policy not found":                                           "コンテナーポリシーが見つかりません",
+	"Deleted resource not found or you do not have access":                 "削除済みリソースが見つからないか、アクセス権がありません",
+	"Diagnostics are only served to local clients":                         "診断情報はローカルクライアントにのみ提供されます",
+	"Docker host not found":                                                "Docker ホストが見つかりません",
+	"Either team_id or resource_id is required":                            "team_id または resource_id のいずれかが必要です",
+	"Email template does not render":                                       "メールテンプレートをレンダリングできません",
+	"Email template not found":                                             "メールテンプレートが見つかりません",
+	"Email template override not found":                                    "メールテンプレートの上書きが見つかりません",
+	"Environment is not part of the team's pipeline":                       "この環境はチームのパイプラインに含まれていません",
+	"Erasure request has expired":                                          "消去リクエストの有効期限が切れています",
+	"Erasure request is no longer pending":                                 "消去リクエストは保留中ではありません",
+	"Erasure request not found":                                            "消去リクエストが見つかりません",
+	"Exactly one of from_event_id or since is required":                    "from_event_id と since のどちらか一方のみを指定してください",
+	"Failed to accept invitation":                                          "招待の承諾に失敗しました",
+	"Failed to acknowledge announcement":                                   "お知らせを確認済みにできませんでした",
+	"Failed to add team member":                                            "チームメンバーを追加できませんでした",
+	"Failed to adopt StatefulSet":                                          "StatefulSetの引き継ぎに失敗しました",
+	"Failed to apply resource":                                             "リソースの適用に失敗しました",
+	"Failed to build overview":                                             "概要を作成できませんでした",
+	"Failed to build usage report":                                         "使用状況レポートを作成できませんでした",
+	"Failed to cancel backup job":                                          "バックアップジョブのキャンセルに失敗しました",
+	"Failed to cancel erasure":                                             "消去を取り消せませんでした",
+	"Failed to cancel provisioning job":                                    "プロビジョニングジョブのキャンセルに失敗しました",
+	"Failed to check container policies":                                   "コンテナーポリシーを確認できませんでした",
+	"Failed to check deletion protection":                                  "削除保護を確認できませんでした",
+	"Failed to check environment usage":                                    "環境の使用状況を確認できませんでした",
+	"Failed to check existing resources":                                   "既存のリソースを確認できませんでした",
+	"Failed to check membership status":                                    "メンバーシップの状態を確認できませんでした",
+	"Failed to check network access rules":                                 "ネットワークアクセスルールを確認できませんでした",
+	"Failed to check permissions":                                          "権限を確認できませんでした",
+	"Failed to check resource access":                                      "リソースへのアクセス権を確認できませんでした",
+	"Failed to check resource claims":                                      "リソースクレームを確認できませんでした",
+	"Failed to check resource lock":                                        "リソースのロックを確認できませんでした",
+	"Failed to check security compliance":                                  "セキュリティ準拠を確認できませんでした",
+	"Failed to check target environment":                                   "対象の環境を確認できませんでした",
+	"Failed to check team dependencies":                                    "チームの依存関係を確認できませんでした",
+	"Failed to check team name uniqueness":                                 "チーム名の重複を確認できませんでした",
+	"Failed to check tenant hosts":                                         "テナントのホストを確認できませんでした",
+	"Failed to commit transaction":                                         "トランザクションをコミットできませんでした",
+	"Failed to count alerts":                                               "アラート数を取得できませんでした",
+	"Failed to count audit logs":                                           "監査ログ数を取得できませんでした",
+	"Failed to count resources":                                            "リソース数を取得できませんでした",
+	"Failed to create Backstage token":                                     "Backstage トークンを作成できませんでした",
+	"Failed to create Docker host":                                         "Docker ホストの作成に失敗しました",
+	"Failed to create alert rule":                                          "アラートルールを作成できませんでした",
+	"Failed to create allowed image":                                       "許可されたイメージを作成できませんでした",
+	"Failed to create announcement":                                        "お知らせを作成できませんでした",
+	"Failed to create cloud account":                                       "クラウドアカウントの作成に失敗しました",
+	"Failed to create consumer binding":                                    "コンシューマーバインディングの作成に失敗しました",
+	"Failed to create container policy":                                    "コンテナーポリシーを作成できませんでした",
+	"Failed to create image registry":                                      "イメージレジストリを作成できませんでした",
+	"Failed to create integration":                                         "連携を作成できませんでした",
+	"Failed to create invitation":                                          "招待の作成に失敗しました",
+	"Failed to create network access rule":                                 "ネットワークアクセスルールを作成できませんでした",
+	"Failed to create policy":                                              "ポリシーを作成できませんでした",
+	"Failed to create resource":                                            "リソースを作成できませんでした",
+	"Failed to create team":                                                "チームを作成できませんでした",
+	"Failed to create tenant":                                              "テナントを作成できませんでした",
+	"Failed to create validation webhook":                                  "検証 Webhook を作成できませんでした",
+	"Failed to create workload identity":                                   "ワークロード ID の作成に失敗しました",
+	"Failed to delete Backstage token":                                     "Backstage トークンを削除できませんでした",
+	"Failed to delete Docker host":                                         "Docker ホストの削除に失敗しました",
+	"Failed to delete agent":                                               "エージェントの削除に失敗しました",
+	"Failed to delete alert rule":                                          "アラートルールを削除できませんでした",
+	"Failed to delete allowed image":                                       "許可されたイメージを削除できませんでした",
+	"Failed to delete announcement":                                        "お知らせを削除できませんでした",
+	"Failed to delete cloud account":                                       "クラウドアカウントの削除に失敗しました",
+	"Failed to delete consumer binding":                                    "コンシューマーバインディングの削除に失敗しました",
+	"Failed to delete container policy":                                    "コンテナーポリシーを削除できませんでした",
+	"Failed to delete email template":                                      "メールテンプレートの削除に失敗しました",
+	"Failed to delete feature flag":                                        "機能フラグを削除できませんでした",
+	"Failed to delete image registry":                                      "イメージレジストリを削除できませんでした",
+	"Failed to delete integration":                                         "連携を削除できませんでした",
+	"Failed to delete invitation":                                          "招待の削除に失敗しました",
+	"Failed to delete network access rule":                                 "ネットワークアクセスルールを削除できませんでした",
+	"Failed to delete policy":                                              "ポリシーを削除できませんでした",
+	"Failed to delete preference":                                          "設定を削除できませんでした",
+	"Failed to delete resource":                                            "リソースを削除できませんでした",
+	"Failed to delete saved view":                                          "保存済みビューを削除できませんでした",
+	"Failed to delete team members":                                        "チームメンバーを削除できませんでした",
+	"Failed to delete team":                                                "チームを削除できませんでした",
+	"Failed to delete validation webhook":                                  "検証 Webhook を削除できませんでした",
+	"Failed to delete workload identity":                                   "ワークロード ID の削除に失敗しました",
+	"Failed to discard job":                                                "ジョブの破棄に失敗しました",
+	"Failed to dismiss adoption candidate":                                 "引き継ぎ候補の却下に失敗しました",
+	"Failed to erase user data":                                            "ユーザーデータを消去できませんでした",
+	"Failed to evaluate permissions":                                       "権限を評価できませんでした",
+	"Failed to evaluate feature flags":                                     "機能フラグを評価できませんでした",
+	"Failed to export backup coverage":                                     "バックアップカバレッジのエクスポートに失敗しました",
+	"Failed to export certificates":                                        "証明書のエクスポートに失敗しました",
+	"Failed to export resources":                                           "リソースのエクスポートに失敗しました",
+	"Failed to export user data":                                           "ユーザーデータをエクスポートできませんでした",
+	"Failed to fetch reconcile status":                                     "リコンサイルの状態を取得できませんでした",
+	"Failed to fetch team deletion":                                        "チームの削除情報を取得できませんでした",
+	"Failed to fetch team":                                                 "チームを取得できませんでした",
+	"Failed to fetch transfer team":                                        "移管先のチームを取得できませんでした",
+	"Failed to generate token":                                             "トークンを生成できませんでした",
+	"Failed to list Backstage tokens":                                      "Backstage トークンを一覧表示できませんでした",
+	"Failed to list Docker hosts":                                          "Docker ホストの一覧取得に失敗しました",
+	"Failed to list SSH certificates":                                      "SSH証明書の一覧取得に失敗しました",
+	"Failed to list adoption candidates":                                   "引き継ぎ候補の一覧取得に失敗しました",
+	"Failed to list agents":                                                "エージェントの一覧取得に失敗しました",
+	"Failed to list alert rules":                                           "アラートルールの一覧を取得できませんでした",
+	"Failed to list alerts":                                                "アラートの一覧を取得できませんでした",
+	"Failed to list allowed images":                                        "許可されたイメージの一覧を取得できませんでした",
+	"Failed to list announcements":                                         "お知らせの一覧を取得できませんでした",
+	"Failed to list archive runs":                                          "アーカイブ処理の一覧を取得できませんでした",
+	"Failed to list audit logs":                                            "監査ログの一覧を取得できませんでした",
+	"Failed to list certificates":                                          "証明書の一覧取得に失敗しました",
+	"Failed to list cloud accounts":                                        "クラウドアカウントの一覧取得に失敗しました",
+	"Failed to list consumer bindings":                                     "コンシューマーバインディングの一覧取得に失敗しました",
+	"Failed to list container policies":                                    "コンテナーポリシーの一覧を取得できませんでした",
+	"Failed to list controller retry queue":                                "コントローラーの再試行キューを取得できませんでした",
+	"Failed to list controllers":                                           "コントローラーの一覧を取得できませんでした",
+	"Failed to list deleted resources":                                     "削除済みリソースの一覧を取得できませんでした",
+	"Failed to list environments":                                          "環境の一覧を取得できませんでした",
+	"Failed to list feature flags":                                         "機能フラグの一覧を取得できませんでした",
+	"Failed to list image registries":                                      "イメージレジストリの一覧を取得できませんでした",
+	"Failed to list integrations":                                          "連携の一覧を取得できませんでした",
+	"Failed to list invitations":                                           "招待の一覧取得に失敗しました",
+	"Failed to list jobs":                                                  "ジョブの一覧取得に失敗しました",
+	"Failed to list network access rules":                                  "ネットワークアクセスルールの一覧を取得できませんでした",
+	"Failed to list operations":                                            "操作の一覧取得に失敗しました",
+	"Failed to list policies":                                              "ポリシーの一覧を取得できませんでした",
+	"Failed to list preferences":                                           "設定の一覧を取得できませんでした",
+	"Failed to list provisioning jobs":                                     "プロビジョニングジョブの一覧取得に失敗しました",
+	"Failed to list resource locks":                                        "リソースのロックの一覧を取得できませんでした",
+	"Failed to list resources":                                             "リソースの一覧を取得できませんでした",
+	"Failed to list retention policies":                                    "保持ポリシーの一覧を取得できませんでした",
+	"Failed to list saved views":                                           "保存済みビューの一覧を取得できませんでした",
+	"Failed to list size classes":                                          "サイズクラスの一覧を取得できませんでした",
+	"Failed to list stuck resources":                                       "停止したリソースの一覧取得に失敗しました",
+	"Failed to list teams":                                                 "チームを一覧表示できませんでした",
+	"Failed to list tenants":                                               "テナントの一覧を取得できませんでした",
+	"Failed to list tickets":                                               "チケットの一覧を取得できませんでした",
+	"Failed to list validation webhooks":                                   "検証 Webhook の一覧を取得できませんでした",
+	"Failed to list workload identities":                                   "ワークロード ID の一覧取得に失敗しました",
+	"Failed to load SSH CAs":                                               "SSH CAの読み込みに失敗しました",
+	"Failed to load agent resources":                                       "エージェントのリソースの読み込みに失敗しました",
+	"Failed to load agent":                                                 "エージェントの読み込みに失敗しました",
+	"Failed to load environments":                                          "環境を読み込めませんでした",
+	"Failed to load features":                                              "機能を読み込めませんでした",
+	"Failed to load network access rules":                                  "ネットワークアクセスルールを読み込めませんでした",
+	"Failed to load size classes":                                          "サイズクラスを読み込めませんでした",
+	"Failed to load team memberships":                                      "チームメンバーシップの読み込みに失敗しました",
+	"Failed to load trust bundle":                                          "トラストバンドルの読み込みに失敗しました",
+	"Failed to load user":                                                  "ユーザーの読み込みに失敗しました",
+	"Failed to load validation webhooks":                                   "検証 Webhook を読み込めませんでした",
+	"Failed to open approval ticket":                                       "承認チケットを作成できませんでした",
+	"Failed to promote resource":                                           "リソースを昇格できませんでした",
+	"Failed to provision tenant schema":                                    "テナントのスキーマをプロビジョニングできませんでした",
+	"Failed to queue reconcile":                                            "リコンサイルをキューに追加できませんでした",
+	"Failed to read queue depths":                                          "キューの深さの読み取りに失敗しました",
+	"Failed to record SSH certificate":                                     "SSH証明書の記録に失敗しました",
+	"Failed to record agent report":                                        "エージェントのレポートの記録に失敗しました",
+	"Failed to register agent":                                             "エージェントの登録に失敗しました",
+	"Failed to release resource lock":                                      "リソースのロックを解除できませんでした",
+	"Failed to remove team member":                                         "チームメンバーを削除できませんでした",
+	"Failed to requeue job":                                                "ジョブの再キュー投入に失敗しました",
+	"Failed to resize resource":                                            "リソースのサイズを変更できませんでした",
+	"Failed to resolve replay position":                                    "再送の開始位置を特定できませんでした",
+	"Failed to resolve tenant":                                             "テナントを特定できませんでした",
+	"Failed to restore resource":                                           "リソースを復元できませんでした",
+	"Failed to retrieve Docker host":                                       "Docker ホストの取得に失敗しました",
+	"Failed to retrieve adoption candidate":                                "引き継ぎ候補の取得に失敗しました",
+	"Failed to retrieve agent":                                             "エージェントの取得に失敗しました",
+	"Failed to retrieve alert rule":                                        "アラートルールを取得できませんでした",
+	"Failed to retrieve announcement":                                      "お知らせを取得できませんでした",
+	"Failed to retrieve cloud account":                                     "クラウドアカウントの取得に失敗しました",
+	"Failed to retrieve consumer binding":                                  "コンシューマーバインディングの取得に失敗しました",
+	"Failed to retrieve container policy":                                  "コンテナーポリシーを取得できませんでした",
+	"Failed to retrieve database insights":                                 "データベースのインサイトを取得できませんでした",
+	"Failed to retrieve email template":                                    "メールテンプレートの取得に失敗しました",
+	"Failed to retrieve erasure request":                                   "消去リクエストを取得できませんでした",
+	"Failed to retrieve feature flag":                                      "機能フラグを取得できませんでした",
+	"Failed to retrieve image registry":                                    "イメージレジストリを取得できませんでした",
+	"Failed to retrieve integration":                                       "連携を取得できませんでした",
+	"Failed to retrieve invitation":                                        "招待の取得に失敗しました",
+	"Failed to retrieve job":                                               "ジョブの取得に失敗しました",
+	"Failed to retrieve network access rule":                               "ネットワークアクセスルールを取得できませんでした",
+	"Failed to retrieve operation":                                         "操作の取得に失敗しました",
+	"Failed to retrieve password policy":                                   "パスワードポリシーを取得できませんでした",
+	"Failed to retrieve policy":                                            "ポリシーを取得できませんでした",
+	"Failed to retrieve preference":                                        "設定を取得できませんでした",
+	"Failed to retrieve resource lock":                                     "リソースのロックを取得できませんでした",
+	"Failed to retrieve resource type":                                     "リソースタイプを取得できませんでした",
+	"Failed to retrieve resource":                                          "リソースを取得できませんでした",
+	"Failed to retrieve retention policy":                                  "保持ポリシーを取得できませんでした",
+	"Failed to retrieve saved view":                                        "保存済みビューを取得できませんでした",
+	"Failed to retrieve statistics":                                        "統計情報を取得できませんでした",
+	"Failed to retrieve team member":                                       "チームメンバーを取得できませんでした",
+	"Failed to retrieve team members":                                      "チームメンバーの一覧を取得できませんでした",
+	"Failed to retrieve team memberships":                                  "チームのメンバーシップを取得できませんでした",
+	"Failed to retrieve team":                                              "チームを取得できませんでした",
+	"Failed to retrieve teams":                                             "チームの一覧を取得できませんでした",
+	"Failed to retrieve token":                                             "トークンを取得できませんでした",
+	"Failed to retrieve user roles":                                        "ユーザーのロールを取得できませんでした",
+	"Failed to retrieve user":                                              "ユーザーを取得できませんでした",
+	"Failed to retrieve validation webhook":                                "検証 Webhook を取得できませんでした",
+	"Failed to retrieve workload identity":                                 "ワークロード ID の取得に失敗しました",
+	"Failed to save email template":                                        "メールテンプレートの保存に失敗しました",
+	"Failed to save environments":                                          "環境を保存できませんでした",
+	"Failed to save feature flag":                                          "機能フラグを保存できませんでした",
+	"Failed to save password policy":                                       "パスワードポリシーを保存できませんでした",
+	"Failed to save preference":                                            "設定を保存できませんでした",
+	"Failed to save retention policy":                                      "保持ポリシーを保存できませんでした",
+	"Failed to save size classes":                                          "サイズクラスを保存できませんでした",
+	"Failed to save view":                                                  "ビューを保存できませんでした",
+	"Failed to send email":                                                 "メールの送信に失敗しました",
+	"Failed to sign SSH certificate":                                       "SSH証明書の署名に失敗しました",
+	"Failed to start erasure":                                              "消去を開始できませんでした",
+	"Failed to start transaction":                                          "トランザクションを開始できませんでした",
+	"Failed to sync cloud account":                                         "クラウドアカウントの同期に失敗しました",
+	"Failed to update Docker host":                                         "Docker ホストの更新に失敗しました",
+	"Failed to update alert rule":                                          "アラートルールを更新できませんでした",
+	"Failed to update announcement":                                        "お知らせを更新できませんでした",
+	"Failed to update cloud account":                                       "クラウドアカウントの更新に失敗しました",
+	"Failed to update export cursor":                                       "エクスポートカーソルを更新できませんでした",
+	"Failed to update image registry":                                      "イメージレジストリを更新できませんでした",
+	"Failed to update integration":                                         "連携を更新できませんでした",
+	"Failed to update network access rule":                                 "ネットワークアクセスルールを更新できませんでした",
+	"Failed to update policy":                                              "ポリシーを更新できませんでした",
+	"Failed to update resource":                                            "リソースを更新できませんでした",
+	"Failed to update team":                                                "チームを更新できませんでした",
+	"Failed to update validation webhook":                                  "検証 Webhook を更新できませんでした",
+	"Failed to verify Docker host":                                         "Docker ホストの確認に失敗しました",
+	"Failed to verify agent":                                               "エージェントの確認に失敗しました",
+	"Failed to verify audit logs":                                          "監査ログを検証できませんでした",
+	"Failed to verify resource type":                                       "リソースタイプを検証できませんでした",
+	"Failed to verify resource":                                            "リソースを検証できませんでした",
+	"Failed to verify team":                                                "チームを検証できませんでした",
+	"Feature flag override not found":                                      "機能フラグの上書き設定が見つかりません",
+	"Failed to record impersonation":                                       "なりすましを記録できませんでした",
+	"Git sync failed":                                                      "Git 同期に失敗しました",
+	"Git sync integrations require a team_id":                              "Git 同期連携には team_id が必要です",
+	"Global admin access required":                                         "グローバル管理者権限が必要です",
+	"Global admins cannot be impersonated":                                 "グローバル管理者にはなりすませません",
+	"Global admins must be demoted before they can be erased":              "グローバル管理者は降格してから消去する必要があります",
+	"Image registry not found":                                             "イメージレジストリが見つかりません",
+	"Insufficient permissions to access this resource":                     "このリソースにアクセスする権限がありません",
+	"Insufficient permissions to apply resources":                          "リソースを適用する権限がありません",
+	"Insufficient permissions to cancel jobs":                              "ジョブをキャンセルする権限がありません",
+	"Insufficient permissions to create resources":                         "リソースを作成する権限がありません",
+	"Insufficient permissions to delete resources":                         "リソースを削除する権限がありません",
+	"Insufficient permissions to manage alert rules for this team":         "このチームのアラートルールを管理する権限がありません",
+	"Insufficient permissions to manage consumer bindings":                 "コンシューマーバインディングを管理する権限がありません",
+	"Insufficient permissions to manage this image registry":               "このイメージレジストリを管理する権限がありません",
+	"Insufficient permissions to manage this integration":                  "この連携を管理する権限がありません",
+	"Insufficient permissions to promote resources":                        "リソースを昇格する権限がありません",
+	"Insufficient permissions to reconcile resources":                      "リソースをリコンサイルする権限がありません",
+	"Insufficient permissions to request SSH certificates":                 "SSH証明書を要求する権限がありません",
+	"Insufficient permissions to resize resources":                         "リソースのサイズを変更する権限がありません",
+	"Insufficient permissions to restore resources":                        "リソースを復元する権限がありません",
+	"Insufficient permissions to update resources":                         "リソースを更新する権限がありません",
+	"Insufficient permissions":                                             "権限が不足しています",
+	"Insufficient team permissions":                                        "チームの権限が不足しています",
+	"Impersonation is not enabled":                                         "なりすましは有効になっていません",
+	"Integration not found":                                                "連携が見つかりません",
+	"Integration test failed":                                              "連携のテストに失敗しました",
+	"Internal server error":                                                "内部サーバーエラー",
+	"Invalid authorization header format":                                  "Authorization ヘッダーの形式が不正です",
+	"Invalid export columns":                                               "エクスポート列が無効です",
+	"Invalid feature flag key":                                             "機能フラグのキーが不正です",
+	"Invalid or expired token":                                             "トークンが無効か期限切れです",
+	"Invalid preference key":                                               "設定キーが無効です",
+	"Invalid request body":                                                 "リクエスト本文が不正です",
+	"Invalid resource ID":                                                  "リソース ID が不正です",
+	"Invalid resource type ID":                                             "リソースタイプ ID が不正です",
+	"Invalid saved view":                                                   "保存済みビューが無効です",
+	"Invalid status value":                                                 "ステータスの値が不正です",
+	"Invalid team ID":                                                      "チーム ID が不正です",
+	"Invalid user ID":                                                      "ユーザー ID が不正です",
+	"Invalid username or password":                                         "ユーザー名またはパスワードが正しくありません",
+	"Invitation has already been accepted":                                 "招待は既に承諾されています",
+	"Invitation has expired":                                               "招待の有効期限が切れています",
+	"Invitation not found":                                                 "招待が見つかりません",
+	"Invitation was sent to a different email address":                     "招待は別のメールアドレスに送信されました",
+	"Job not found":                                                        "ジョブが見つかりません",
+	"Logins must be valid local account names":                             "ログインは有効なローカルアカウント名である必要があります",
+	"Mail is not configured":                                               "メールが設定されていません",
+	"Method not allowed":                                                   "許可されていないメソッドです",
+	"Missing authorization header":                                         "Authorization ヘッダーがありません",
+	"Missing or invalid CSRF token":                                        "CSRFトークンがないか無効です",
+	"Network access rule not found":                                        "ネットワークアクセスルールが見つかりません",
+	"No SSH CA issues certificates for this team":                          "このチームに証明書を発行するSSH CAがありません",
+	"No database insights available for this resource":                     "このリソースのデータベースインサイトはありません",
+	"No deletion found for team":                                           "このチームの削除情報が見つかりません",
+	"No resource type matches the StatefulSet's engine":                    "StatefulSetのエンジンに一致するリソースタイプがありません",
+	"No retention policy is configured for this target":                    "この対象には保持ポリシーが設定されていません",
+	"No statistics available for this resource":                            "このリソースの統計情報はありません",
+	"Only dead-lettered jobs can be requeued":                              "デッドレターのジョブのみ再キュー投入できます",
+	"Only event export integrations support replay":                        "再送に対応しているのはイベントエクスポート連携のみです",
+	"Only full lifecycle resources are reconciled by the controller":       "コントローラーがリコンサイルするのはフルライフサイクルのリソースのみです",
+	"Only full lifecycle resources can be applied":                         "フルライフサイクルのリソースのみ適用できます",
+	"Only full lifecycle resources can be resized":                         "サイズを変更できるのはフルライフサイクルのリソースのみです",
+	"Only git sync integrations can be synced":                             "同期できるのは Git 同期連携のみです",
+	"Only git sync integrations receive webhooks":                          "Webhook を受信できるのは Git 同期連携のみです",
+	"Only global admins can create teams":                                  "チームを作成できるのはグローバル管理者のみです",
+	"Only global admins can delete teams":                                  "チームを削除できるのはグローバル管理者のみです",
+	"Only pending or running jobs can be cancelled":                        "キャンセルできるのは保留中または実行中のジョブのみです",
+	"Only running jobs can be cancelled":                                   "キャンセルできるのは実行中のジョブのみです",
+	"Only slack integrations receive commands":                             "コマンドを受信できるのは Slack 連携のみです",
+	"Only team admins can request root logins":                             "rootログインを要求できるのはチーム管理者のみです",
+	"Operation not found or you do not have access":                        "操作が見つからないか、アクセス権がありません",
+	"Origin not allowed":                                                   "このオリジンは許可されていません",
+	"Platform admin access required":                                       "プラットフォーム管理者権限が必要です",
+	"Policies could not be evaluated":                                      "ポリシーを評価できませんでした",
+	"Policy not found":                                                     "ポリシーが見つかりません",
+	"Preference limit reached; delete unused preferences first":            "設定の上限に達しました。先に未使用の設定を削除してください",
+	"Preference not found":                                                 "設定が見つかりません",
+	"Preference value must be JSON":                                        "設定値は JSON である必要があります",
+	"Preference values are limited to 16 KiB":                              "設定値は 16 KiB までです",
+	"Provisioning job not found":                                           "プロビジョニングジョブが見つかりません",
+	"Public key must be in authorized_keys format":                         "公開鍵はauthorized_keys形式である必要があります",
+	"Request signature is invalid":                                         "リクエストの署名が無効です",
+	"Resizing is not enabled for this team":                                "このチームではサイズ変更が有効になっていません",
+	"Resource ID required":                                                 "リソース ID が必要です",
+	"Resource has deletion protection enabled; disable it before deleting": "このリソースは削除保護が有効です。削除する前に無効にしてください",
+	"Resource is not locked":                                               "リソースはロックされていません",
+	"Resource not found or you do not have access":                         "リソースが見つからないか、アクセス権がありません",
+	"Resource not found":                                                   "リソースが見つかりません",
+	"Resource type not found":                                              "リソースタイプが見つかりません",
+	"Resource was created by a claim; delete the claim instead":            "このリソースはクレームによって作成されました。代わりにクレームを削除してください",
+	"Resources can only be promoted to a later environment in the team's pipeline": "リソースはチームのパイプラインの後続の環境にのみ昇格できます",
+	"Resources exist in environments that would be removed":                        "削除される環境にリソースが存在します",
+	"Resources managed by an agent must be partial or monitor_only":                "エージェントが管理するリソースは partial または monitor_only である必要があります",
+	"Resources on a Docker host must be full":                                      "Docker ホスト上のリソースは full である必要があります",
+	"Route not found":             "ルートが見つかりません",
+	"SPIFFE ID is already mapped": "この SPIFFE ID はすでに割り当てられています",
+	"SSH certificates are only issued for agent-managed resources": "SSH証明書はエージェント管理のリソースにのみ発行されます",
+	"Saved view limit reached; delete unused views first":          "保存済みビューの上限に達しました。先に未使用のビューを削除してください",
+	"Saved view not found":                                 "保存済みビューが見つかりません",
+	"Service account is inactive":                          "サービスアカウントが無効です",
+	"Stored database insights could not be parsed":         "保存されたデータベースインサイトを解析できませんでした",
+	"TTL must be a positive duration of at most 8h":        "TTLは8h以下の正の期間である必要があります",
+	"TTL must be a positive duration":                      "TTL は正の期間である必要があります",
+	"Team ID must be a valid number":                       "チーム ID は有効な数値である必要があります",
+	"Team ID required":                                     "チーム ID が必要です",
+	"Team admin access required to export":                 "エクスポートにはチーム管理者権限が必要です",
+	"Team admin access required":                           "チーム管理者権限が必要です",
+	"Team member not found":                                "チームメンバーが見つかりません",
+	"Team name already exists":                             "チーム名は既に存在します",
+	"Team not found":                                       "チームが見つかりません",
+	"Team owns resources with deletion protection enabled": "チームは削除保護が有効なリソースを所有しています",
+	"Team still owns resources; transfer them with mode=transfer or delete them with mode=force": "チームはまだリソースを所有しています。mode=transfer で移管するか、mode=force で削除してください",
+	"Tenant database is unavailable":                                                 "テナントのデータベースを利用できません",
+	"Tenant slug must be 2-31 lowercase letters, digits, or hyphens":                 "テナントのスラッグは 2～31 文字の英小文字、数字、ハイフンで指定してください",
+	"The Docker host still has resources":                                            "Docker ホストにはまだリソースがあります",
+	"The StatefulSet has already been adopted":                                       "このStatefulSetは既に引き継がれています",
+	"The agent still manages resources":                                              "エージェントはまだリソースを管理しています",
+	"The cloud account still has imported resources":                                 "クラウドアカウントにはまだインポートされたリソースがあります",
+	"The custom size class requires config.resources":                                "カスタムサイズクラスには config.resources が必要です",
+	"The global team cannot be deleted":                                              "グローバルチームは削除できません",
+	"The manifest is too large":                                                      "マニフェストが大きすぎます",
+	"The password does not meet the password policy":                                 "パスワードがパスワードポリシーを満たしていません",
+	"The policy could not be compiled":                                               "ポリシーをコンパイルできませんでした",
+	"The policy engine could not be reached":                                         "ポリシーエンジンに接続できませんでした",
+	"The policy engine is not configured":                                            "ポリシーエンジンが構成されていません",
+	"The repository's definitions can't be applied":                                  "リポジトリの定義を適用できません",
+	"The request must contain exactly one Resource manifest":                         "リクエストには Resource マニフェストを 1 つだけ含める必要があります",
+	"The resource is locked by another operation":                                    "リソースは別の操作によってロックされています",
+	"The resource is managed by a git sync integration":                              "このリソースは Git 同期連携によって管理されています",
+	"The resource is past its restore window and is being purged":                    "このリソースは復元期間を過ぎており、完全に削除されます",
+	"The resource violates a blocking policy":                                        "リソースがブロッキングポリシーに違反しています",
+	"The resource was rejected by a validation webhook":                              "リソースは検証 Webhook によって拒否されました",
+	"The rules would block your own address":                                         "このルールではあなた自身のアドレスがブロックされます",
+	"The workload's SVID is not valid":                                               "ワークロードの SVID が無効です",
+	"This feature requires a license upgrade":                                        "この機能を利用するにはライセンスのアップグレードが必要です",
+	"Token scope does not allow this request":                                        "トークンのスコープではこのリクエストは許可されていません",
+	"Transfer team not found":                                                        "移管先のチームが見つかりません",
+	"Unauthorized":                                                                   "認証されていません",
+	"Unknown resource type":                                                          "不明なリソースタイプです",
+	"User ID must be a valid number":                                                 "ユーザー ID は有効な数値である必要があります",
+	"User account is inactive":                                                       "ユーザーアカウントは無効です",
+	"User context not found":                                                         "ユーザーのコンテキストが見つかりません",
+	"User does not have access to this team":                                         "ユーザーはこのチームへのアクセス権がありません",
+	"User does not have admin rights in this team":                                   "ユーザーはこのチームの管理者権限を持っていません",
+	"User does not have required role in team":                                       "ユーザーはチーム内で必要なロールを持っていません",
+	"User does not have required role":                                               "ユーザーは必要なロールを持っていません",
+	"User is already a member of this team":                                          "ユーザーは既にこのチームのメンバーです",
+	"User not found":                                                                 "ユーザーが見つかりません",
+	"Username or email already exists":                                               "ユーザー名またはメールアドレスは既に存在します",
+	"Username, email, and password are required":                                     "ユーザー名、メールアドレス、パスワードは必須です",
+	"Validation webhook not found":                                                   "検証 Webhook が見つかりません",
+	"Webhook signature is invalid":                                                   "Webhook の署名が無効です",
+	"Workload identity is not mapped to a service account":                           "ワークロード ID がサービスアカウントに割り当てられていません",
+	"Workload identity not found":                                                    "ワークロード ID が見つかりません",
+	"You do not have access to this team":                                            "このチームへのアクセス権がありません",
+	"action must be one of allow, deny":                                              "action は allow または deny のいずれかである必要があります",
+	"client_cert and client_key must be updated together":                            "client_cert と client_key は一緒に更新する必要があります",
+	"ends_at must be after starts_at":                                                "ends_at は starts_at より後である必要があります",
+	"failure_policy must be one of: fail, ignore":                                    "failure_policy は fail、ignore のいずれかである必要があります",
+	"format must be csv or xlsx":                                                     "format は csv または xlsx を指定してください",
+	"kind must be Group or Resource":                                                 "kind は Group または Resource である必要があります",
+	"kind must be one of init, sidecar":                                              "kind には init または sidecar を指定してください",
+	"lifecycle_mode must be one of: full, partial, monitor_only":                     "lifecycle_mode には full、partial、monitor_only のいずれかを指定してください",
+	"metadata.team is required":                                                      "metadata.team は必須です",
+	"mode must be one of block, transfer, force":                                     "mode には block、transfer、force のいずれかを指定してください",
+	"name must be lowercase letters, digits and underscores, starting with a letter": "name は英小文字、数字、アンダースコアのみで、英字で始まる必要があります",
+	"operations must be a list of: create, update":                                   "operations は create、update のリストである必要があります",
+	"refs or team is required":                                                       "refs または team が必要です",
+	"scope must be catalog, read, or write":                                          "scope は catalog、read、write のいずれかである必要があります",
+	"severity must be one of: block, warn":                                           "severity は block、warn のいずれかである必要があります",
+	"since must be an RFC3339 timestamp":                                             "since には RFC3339 形式のタイムスタンプを指定してください",
+	"since must be before until":                                                     "since は until より前である必要があります",
+	"target must be one of: audit_logs, resource_stats":                              "target には audit_logs または resource_stats を指定してください",
+	"timeout_seconds must be between 1 and 30":                                       "timeout_seconds は 1 から 30 の間である必要があります",
+	"transfer_to must reference a different team":                                    "transfer_to には別のチームを指定してください",
+	"until must be an RFC3339 timestamp":                                             "until には RFC3339 形式のタイムスタンプを指定してください",
+	"url must be an absolute http or https URL":                                      "url は絶対 http または https URL である必要があります",
+	"within_days must be a number of days":                                           "within_days は日数で指定してください",
+}
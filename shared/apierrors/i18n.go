@@ -0,0 +1,137 @@
+package apierrors
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Languages error messages are available in. English is the source
+// language: messages are written in English and looked up in the other
+// catalogs by their English text.
+const (
+	LanguageEnglish  = "en"
+	LanguageGerman   = "de"
+	LanguageJapanese = "ja"
+)
+
+// languageKey is the Gin context key of the negotiated language
+const languageKey = "language"
+
+// catalogs map English messages to their translations, per language.
+// Messages missing from a catalog, such as those built from validation
+// errors, are returned in English.
+var catalogs = map[string]map[string]string{
+	LanguageGerman:   catalogGerman,
+	LanguageJapanese: catalogJapanese,
+}
+
+// titles translate the HTTP status titles of problems
+var titles = map[string]map[int]string{
+	LanguageGerman: {
+		http.StatusBadRequest:            "Ungültige Anfrage",
+		http.StatusUnauthorized:          "Nicht authentifiziert",
+		http.StatusForbidden:             "Verboten",
+		http.StatusNotFound:              "Nicht gefunden",
+		http.StatusMethodNotAllowed:      "Methode nicht erlaubt",
+		http.StatusConflict:              "Konflikt",
+		http.StatusLocked:                "Gesperrt",
+		http.StatusRequestEntityTooLarge: "Anfrage zu groß",
+		http.StatusTooManyRequests:       "Zu viele Anfragen",
+		http.StatusInternalServerError:   "Interner Serverfehler",
+		http.StatusBadGateway:            "Fehlerhaftes Gateway",
+		http.StatusServiceUnavailable:    "Dienst nicht verfügbar",
+	},
+	LanguageJapanese: {
+		http.StatusBadRequest:            "不正なリクエスト",
+		http.StatusUnauthorized:          "認証が必要です",
+		http.StatusForbidden:             "アクセス禁止",
+		http.StatusNotFound:              "見つかりません",
+		http.StatusMethodNotAllowed:      "許可されていないメソッド",
+		http.StatusConflict:              "競合",
+		http.StatusLocked:                "ロック中",
+		http.StatusRequestEntityTooLarge: "リクエストが大きすぎます",
+		http.StatusTooManyRequests:       "リクエストが多すぎます",
+		http.StatusInternalServerError:   "内部サーバーエラー",
+		http.StatusBadGateway:            "不正なゲートウェイ",
+		http.StatusServiceUnavailable:    "サービス利用不可",
+	},
+}
+
+// Translate returns a message in a language, falling back to the English
+// message
+func Translate(language, message string) string {
+	if translated, ok := catalogs[language][message]; ok {
+		return translated
+	}
+	return message
+}
+
+// title returns the localized title of an HTTP status
+func title(language string, status int) string {
+	if t, ok := titles[language][status]; ok {
+		return t
+	}
+	return http.StatusText(status)
+}
+
+// Language returns the language negotiated from the request's
+// Accept-Language header
+func Language(c *gin.Context) string {
+	if language := c.GetString(languageKey); language != "" {
+		return language
+	}
+	language := NegotiateLanguage(c.GetHeader("Accept-Language"))
+	c.Set(languageKey, language)
+	return language
+}
+
+// NegotiateLanguage picks the supported language a client prefers most
+// from an Accept-Language header, defaulting to English. Regional variants
+// such as de-AT match their language.
+func NegotiateLanguage(header string) string {
+	type preference struct {
+		language string
+		quality  float64
+	}
+
+	var prefs []preference
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		tag := strings.ToLower(strings.TrimSpace(fields[0]))
+		if tag == "" {
+			continue
+		}
+		quality := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if q, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					quality = q
+				}
+			}
+		}
+		if quality <= 0 {
+			continue
+		}
+		language := tag
+		if i := strings.IndexByte(tag, '-'); i > 0 {
+			language = tag[:i]
+		}
+		prefs = append(prefs, preference{language: language, quality: quality})
+	}
+
+	sort.SliceStable(prefs, func(i, j int) bool { return prefs[i].quality > prefs[j].quality })
+	for _, p := range prefs {
+		switch p.language {
+		case LanguageEnglish, LanguageGerman, LanguageJapanese:
+			return p.language
+		case "*":
+			return LanguageEnglish
+		}
+	}
+	return LanguageEnglish
+}
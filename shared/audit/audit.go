@@ -0,0 +1,241 @@
+// Package audit records field-level changes in audit log entries. Diff
+// compares the JSON form of a record before and after an update, masking
+// secret fields, and Render turns the stored changes back into readable
+// lines for the audit query API.
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/penguintechinc/project-template/shared/database"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// Actions of the entries written with a diff
+const (
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+)
+
+// Actions on personal data, for data subject requests
+const (
+	ActionExport = "export"
+	ActionErase  = "erase"
+)
+
+// ActionBlocked records a request refused by a network access rule
+const ActionBlocked = "blocked"
+
+// ActionIssue records credentials issued to a user, such as SSH certificates
+const ActionIssue = "issue"
+
+// ActionBackup records a backup requested outside the schedule
+const ActionBackup = "backup"
+
+// Mask replaces the before and after values of secret fields
+const Mask = "[REDACTED]"
+
+// secretField matches field names whose values must not be stored
+var secretField = regexp.MustCompile(`(?i)(passw(or)?d|secret|token|api_?key|private_?key|credential)`)
+
+// ignoredFields change on every save and say nothing about the update
+var ignoredFields = map[string]bool{
+	"created_at": true,
+	"updated_at": true,
+}
+
+// Change is one field that differs between two versions of a record.
+// Nested objects are compared field by field, with dotted paths such as
+// "config.resources.cpu". Before is nil for added fields and After is nil
+// for removed ones.
+type Change struct {
+	Field  string      `json:"field"`
+	Before interface{} `json:"before,omitempty"`
+	After  interface{} `json:"after,omitempty"`
+}
+
+// Details is the shape of AuditLog.Details for entries with a diff
+type Details struct {
+	Changes []Change `json:"changes"`
+}
+
+// Diff returns the fields that differ between the JSON forms of before and
+// after, sorted by field. Either may be nil, for records that were created
+// or deleted. Fields hidden from JSON, such as resource credentials, are
+// never compared.
+func Diff(before, after interface{}) ([]Change, error) {
+	beforeFields, err := flatten(before)
+	if err != nil {
+		return nil, err
+	}
+	afterFields, err := flatten(after)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]bool, len(afterFields))
+	for field := range beforeFields {
+		fields[field] = true
+	}
+	for field := range afterFields {
+		fields[field] = true
+	}
+
+	changes := []Change{}
+	for field := range fields {
+		old, hadOld := beforeFields[field]
+		cur, hasCur := afterFields[field]
+		if hadOld == hasCur && reflect.DeepEqual(old, cur) {
+			continue
+		}
+		change := Change{Field: field, Before: old, After: cur}
+		if secretField.MatchString(field) {
+			change.Before, change.After = masked(hadOld), masked(hasCur)
+		}
+		changes = append(changes, change)
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+	return changes, nil
+}
+
+func masked(present bool) interface{} {
+	if !present {
+		return nil
+	}
+	return Mask
+}
+
+// flatten returns the leaf values of v's JSON form keyed by dotted path.
+// Arrays are leaves, so reordering one shows as a single change.
+func flatten(v interface{}) (map[string]interface{}, error) {
+	fields := map[string]interface{}{}
+	if v == nil {
+		return fields, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode record for diff: %w", err)
+	}
+	var root interface{}
+	if err := json.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("failed to decode record for diff: %w", err)
+	}
+	object, ok := root.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("cannot diff %T, which is not a JSON object", v)
+	}
+	flattenInto(fields, "", object)
+	return fields, nil
+}
+
+func flattenInto(fields map[string]interface{}, prefix string, object map[string]interface{}) {
+	for key, value := range object {
+		if prefix == "" && ignoredFields[key] {
+			continue
+		}
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+		if nested, ok := value.(map[string]interface{}); ok && len(nested) > 0 {
+			flattenInto(fields, path, nested)
+			continue
+		}
+		fields[path] = value
+	}
+}
+
+// Record diffs before and after and writes the changes to the audit log as
+// a create, update, or delete of the record, depending on which of them is
+// nil. Updates that change nothing aren't recorded. teamID is the team the
+// record belongs to, if any.
+func Record(c *gin.Context, db *gorm.DB, userID uint, resourceType string, resourceID uint, teamID *uint, before, after interface{}) error {
+	changes, err := Diff(before, after)
+	if err != nil {
+		return err
+	}
+
+	action := ActionUpdate
+	switch {
+	case before == nil:
+		action = ActionCreate
+	case after == nil:
+		action = ActionDelete
+	case len(changes) == 0:
+		return nil
+	}
+
+	return RecordAction(c, db, userID, action, resourceType, resourceID, teamID, Details{Changes: changes})
+}
+
+// RecordAction writes an audit log entry for an action taken by a request,
+// with details encoded as JSON
+func RecordAction(c *gin.Context, db *gorm.DB, userID uint, action, resourceType string, resourceID uint, teamID *uint, details interface{}) error {
+	encoded, err := json.Marshal(details)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit details: %w", err)
+	}
+	entry := database.AuditLog{
+		UserID:       &userID,
+		Action:       action,
+		ResourceType: resourceType,
+		ResourceID:   &resourceID,
+		TeamID:       teamID,
+		Details:      datatypes.JSON(encoded),
+		IPAddress:    c.ClientIP(),
+		UserAgent:    c.Request.UserAgent(),
+		Timestamp:    time.Now().UTC(),
+	}
+	return db.WithContext(c.Request.Context()).Create(&entry).Error
+}
+
+// Changes returns the changes recorded in an entry's details, or nil when
+// it has none
+func Changes(details datatypes.JSON) []Change {
+	if len(details) == 0 {
+		return nil
+	}
+	var d Details
+	if err := json.Unmarshal(details, &d); err != nil {
+		return nil
+	}
+	return d.Changes
+}
+
+// Render formats changes as one line each: "field: before -> after" for
+// changed fields, "+ field: after" for added ones, and "- field: before"
+// for removed ones
+func Render(changes []Change) []string {
+	lines := make([]string, 0, len(changes))
+	for _, change := range changes {
+		switch {
+		case change.Before == nil && change.After != nil:
+			lines = append(lines, fmt.Sprintf("+ %s: %s", change.Field, renderValue(change.After)))
+		case change.After == nil && change.Before != nil:
+			lines = append(lines, fmt.Sprintf("- %s: %s", change.Field, renderValue(change.Before)))
+		default:
+			lines = append(lines, fmt.Sprintf("%s: %s -> %s", change.Field, renderValue(change.Before), renderValue(change.After)))
+		}
+	}
+	return lines
+}
+
+func renderValue(v interface{}) string {
+	if s, ok := v.(string); ok && s == Mask {
+		return s
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return strings.TrimSpace(string(data))
+}
@@ -0,0 +1,217 @@
+// Package compress compresses HTTP responses with the gzip or deflate
+// encoding the client accepts. Small responses, responses that are already
+// encoded or compressed, partial content and event streams are sent as
+// they are.
+package compress
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Encodings
+const (
+	Gzip    = "gzip"
+	Deflate = "deflate"
+)
+
+// DefaultMinSize is the smallest response worth compressing, in bytes
+const DefaultMinSize = 1024
+
+var gzipWriters = sync.Pool{New: func() interface{} {
+	return gzip.NewWriter(io.Discard)
+}}
+
+// incompressibleTypes are content types whose bodies are compressed already
+var incompressibleTypes = []string{
+	"image/", "video/", "audio/",
+	"application/gzip", "application/zip", "application/x-gzip", "application/zstd",
+	// Office documents such as XLSX are zip archives
+	"application/vnd.openxmlformats-",
+	"text/event-stream",
+}
+
+// Middleware compresses responses of at least minSize bytes
+func Middleware(minSize int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		encoding := Negotiate(c.GetHeader("Accept-Encoding"))
+		if encoding == "" || c.Request.Method == http.MethodHead {
+			c.Next()
+			return
+		}
+
+		w := &compressWriter{ResponseWriter: c.Writer, encoding: encoding, minSize: minSize}
+		c.Writer = w
+		defer func() {
+			w.close()
+			c.Writer = w.ResponseWriter
+		}()
+		c.Next()
+	}
+}
+
+// Negotiate picks the encoding to compress with from an Accept-Encoding
+// header: the one with the highest quality, preferring gzip on a tie. It
+// returns "" when neither is acceptable.
+func Negotiate(acceptEncoding string) string {
+	best, bestQ := "", 0.0
+	wildcard := -1.0
+	q := map[string]float64{}
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+		quality := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if parsed, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					quality = parsed
+				}
+			}
+		}
+		if name == "*" {
+			wildcard = quality
+		} else {
+			q[name] = quality
+		}
+	}
+	for _, encoding := range []string{Gzip, Deflate} {
+		quality, ok := q[encoding]
+		if !ok {
+			quality = wildcard
+		}
+		if quality > bestQ {
+			best, bestQ = encoding, quality
+		}
+	}
+	return best
+}
+
+// compressWriter holds back the start of a response until it is known to be
+// large enough to compress, then compresses the rest as it is written
+type compressWriter struct {
+	gin.ResponseWriter
+	encoding      string
+	minSize       int
+	buf           []byte
+	encoder       io.WriteCloser
+	passthrough   bool
+	headerPending bool
+}
+
+func (w *compressWriter) Write(data []byte) (int, error) {
+	switch {
+	case w.passthrough:
+		return w.ResponseWriter.Write(data)
+	case w.encoder != nil:
+		return w.encoder.Write(data)
+	}
+	w.buf = append(w.buf, data...)
+	if len(w.buf) >= w.minSize {
+		if err := w.start(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// WriteHeaderNow is deferred until the encoding is decided, since the
+// headers name it
+func (w *compressWriter) WriteHeaderNow() {
+	if w.passthrough || w.encoder != nil {
+		w.ResponseWriter.WriteHeaderNow()
+		return
+	}
+	w.headerPending = true
+}
+
+func (w *compressWriter) Written() bool {
+	return w.ResponseWriter.Written() || w.headerPending || len(w.buf) > 0
+}
+
+func (w *compressWriter) Flush() {
+	if !w.passthrough && w.encoder == nil {
+		w.start(len(w.buf) > 0)
+	}
+	if f, ok := w.encoder.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// start decides whether the response is compressed and writes what was held
+// back
+func (w *compressWriter) start(compress bool) error {
+	buf := w.buf
+	w.buf = nil
+	if !compress || !w.compressible() {
+		w.passthrough = true
+		w.ResponseWriter.WriteHeaderNow()
+		if len(buf) == 0 {
+			return nil
+		}
+		_, err := w.ResponseWriter.Write(buf)
+		return err
+	}
+
+	header := w.Header()
+	header.Set("Content-Encoding", w.encoding)
+	header.Add("Vary", "Accept-Encoding")
+	header.Del("Content-Length")
+	w.ResponseWriter.WriteHeaderNow()
+	if w.encoding == Gzip {
+		gz := gzipWriters.Get().(*gzip.Writer)
+		gz.Reset(w.ResponseWriter)
+		w.encoder = gz
+	} else {
+		fl, _ := flate.NewWriter(w.ResponseWriter, flate.DefaultCompression)
+		w.encoder = fl
+	}
+	_, err := w.encoder.Write(buf)
+	return err
+}
+
+// compressible reports whether the response may be compressed
+func (w *compressWriter) compressible() bool {
+	header := w.Header()
+	if header.Get("Content-Encoding") != "" || header.Get("Content-Range") != "" {
+		return false
+	}
+	status := w.Status()
+	if status < 200 || status == http.StatusNoContent || status == http.StatusPartialContent || status == http.StatusNotModified {
+		return false
+	}
+	contentType := header.Get("Content-Type")
+	for _, prefix := range incompressibleTypes {
+		if strings.HasPrefix(contentType, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// close ends the response: a short one is written as it is, and the
+// compressed stream of a long one is completed
+func (w *compressWriter) close() {
+	switch {
+	case w.encoder != nil:
+		w.encoder.Close()
+		if gz, ok := w.encoder.(*gzip.Writer); ok {
+			gz.Reset(io.Discard)
+			gzipWriters.Put(gz)
+		}
+	case !w.passthrough && (len(w.buf) > 0 || w.headerPending):
+		w.start(false)
+	}
+}
@@ -0,0 +1,198 @@
+// Package configfile loads a YAML config file into the environment
+// variables the services read their settings from, so every setting can be
+// given in the file, in the environment, or both. Keys name the variables:
+// nested maps are joined with underscores and upper-cased, so
+//
+//	db:
+//	  host: postgres
+//	reconcile_interval: 30s
+//	tls_cipher_suites: [TLS_AES_128_GCM_SHA256, TLS_AES_256_GCM_SHA384]
+//
+// sets DB_HOST, RECONCILE_INTERVAL and TLS_CIPHER_SUITES, the list joined
+// with commas. Variables set in the environment override the file.
+//
+// The controller keeps a copy of this package at pkg/configfile, since it
+// is a separate module. Both services must read files the same way.
+package configfile
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Parse reads the settings in a config file, keyed by environment variable
+func Parse(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	values := map[string]string{}
+	if len(root.Content) == 0 {
+		return values, nil
+	}
+	var errs []error
+	flatten(root.Content[0], "", values, &errs)
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("%s: %w", path, errors.Join(errs...))
+	}
+	return values, nil
+}
+
+// flatten adds the scalars under node to values, keyed by their path
+func flatten(node *yaml.Node, prefix string, values map[string]string, errs *[]error) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			keyNode, valueNode := node.Content[i], node.Content[i+1]
+			key := envKey(keyNode.Value)
+			if key == "" {
+				*errs = append(*errs, fmt.Errorf("line %d: invalid key %q", keyNode.Line, keyNode.Value))
+				continue
+			}
+			if prefix != "" {
+				key = prefix + "_" + key
+			}
+			flatten(valueNode, key, values, errs)
+		}
+	case yaml.SequenceNode:
+		items := make([]string, 0, len(node.Content))
+		for _, item := range node.Content {
+			if item.Kind != yaml.ScalarNode {
+				*errs = append(*errs, fmt.Errorf("line %d: %s must be a list of plain values", item.Line, prefix))
+				return
+			}
+			items = append(items, item.Value)
+		}
+		set(node, prefix, strings.Join(items, ","), values, errs)
+	case yaml.ScalarNode:
+		if node.Tag == "!!null" {
+			return
+		}
+		set(node, prefix, node.Value, values, errs)
+	case yaml.AliasNode:
+		flatten(node.Alias, prefix, values, errs)
+	default:
+		*errs = append(*errs, fmt.Errorf("line %d: the file must be a map of settings", node.Line))
+	}
+}
+
+// set records a setting, refusing top-level values and settings given
+// twice, such as both db_host and db.host
+func set(node *yaml.Node, key, value string, values map[string]string, errs *[]error) {
+	if key == "" {
+		*errs = append(*errs, fmt.Errorf("line %d: the file must be a map of settings", node.Line))
+		return
+	}
+	if _, exists := values[key]; exists {
+		*errs = append(*errs, fmt.Errorf("line %d: %s is set more than once", node.Line, key))
+		return
+	}
+	values[key] = value
+}
+
+// envKey turns a YAML key into the environment variable it sets, or ""
+// when it can't name one
+func envKey(key string) string {
+	key = strings.ToUpper(strings.TrimSpace(key))
+	key = strings.NewReplacer("-", "_", ".", "_").Replace(key)
+	for _, r := range key {
+		if !(r == '_' || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')) {
+			return ""
+		}
+	}
+	return key
+}
+
+// Source applies a config file to the environment and reapplies it when it
+// changes. Variables that were set in the environment when the Source was
+// created keep their values.
+type Source struct {
+	path string
+
+	mu       sync.Mutex
+	external map[string]bool
+	applied  map[string]string
+}
+
+// NewSource creates a Source for the config file at path, recording the
+// variables already set in the environment
+func NewSource(path string) *Source {
+	external := map[string]bool{}
+	for _, kv := range os.Environ() {
+		if key, _, ok := strings.Cut(kv, "="); ok {
+			external[key] = true
+		}
+	}
+	return &Source{path: path, external: external, applied: map[string]string{}}
+}
+
+// Path returns the path of the config file
+func (s *Source) Path() string {
+	return s.path
+}
+
+// Apply reads the config file and sets the variables it holds, other than
+// those overridden by the environment. Variables set by a previous Apply
+// and since removed from the file are unset. It returns the names of the
+// variables that changed, sorted, and leaves the environment alone when
+// the file is invalid.
+func (s *Source) Apply() ([]string, error) {
+	values, err := Parse(s.path)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var changed []string
+	for key, value := range values {
+		if s.external[key] {
+			continue
+		}
+		if previous, ok := s.applied[key]; ok && previous == value {
+			continue
+		}
+		if err := os.Setenv(key, value); err != nil {
+			return changed, fmt.Errorf("failed to set %s: %w", key, err)
+		}
+		s.applied[key] = value
+		changed = append(changed, key)
+	}
+	for key := range s.applied {
+		if _, ok := values[key]; !ok {
+			os.Unsetenv(key)
+			delete(s.applied, key)
+			changed = append(changed, key)
+		}
+	}
+	sort.Strings(changed)
+	return changed, nil
+}
+
+// Overridden returns the settings in the config file that the environment
+// overrides, sorted
+func (s *Source) Overridden() ([]string, error) {
+	values, err := Parse(s.path)
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	for key := range values {
+		if s.external[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
@@ -0,0 +1,244 @@
+// Package credpolicy checks passwords and generated credentials against a
+// configurable policy: minimum length, character classes, estimated
+// entropy, appearance in known breaches, and maximum age.
+package credpolicy
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Limits on policy settings
+const (
+	MinLengthFloor   = 8
+	MaxLengthCeiling = 256
+	maxEntropyBits   = 256
+)
+
+// DefaultBreachURL is the k-anonymity range API of Have I Been Pwned
+const DefaultBreachURL = "https://api.pwnedpasswords.com/range/"
+
+// Policy is the set of rules passwords and credentials must meet
+type Policy struct {
+	MinLength      int     `json:"min_length"`
+	MinClasses     int     `json:"min_classes"`
+	MinEntropyBits float64 `json:"min_entropy_bits"`
+	BreachCheck    bool    `json:"breach_check"`
+	MaxAgeDays     int     `json:"max_age_days"`
+}
+
+// Default returns the policy used until an admin configures one
+func Default() Policy {
+	return Policy{MinLength: 12, MinClasses: 3, MinEntropyBits: 50, BreachCheck: true}
+}
+
+// Validate reports whether the policy's settings are usable
+func (p Policy) Validate() error {
+	switch {
+	case p.MinLength < MinLengthFloor || p.MinLength > MaxLengthCeiling:
+		return fmt.Errorf("min_length must be between %d and %d", MinLengthFloor, MaxLengthCeiling)
+	case p.MinClasses < 0 || p.MinClasses > 4:
+		return fmt.Errorf("min_classes must be between 0 and 4")
+	case p.MinEntropyBits < 0 || p.MinEntropyBits > maxEntropyBits:
+		return fmt.Errorf("min_entropy_bits must be between 0 and %d", maxEntropyBits)
+	case p.MaxAgeDays < 0:
+		return fmt.Errorf("max_age_days must not be negative")
+	}
+	return nil
+}
+
+// BreachChecker counts how often a password appears in known breaches
+type BreachChecker interface {
+	BreachCount(ctx context.Context, password string) (int, error)
+}
+
+// Check returns the rules a password breaks, in the order they are checked.
+// A nil breach checker skips the breach check. An error is returned only
+// when the breach checker fails; callers decide whether to fail open.
+func (p Policy) Check(ctx context.Context, password string, breaches BreachChecker) ([]string, error) {
+	var violations []string
+	if n := len([]rune(password)); n < p.MinLength {
+		violations = append(violations, fmt.Sprintf("must be at least %d characters", p.MinLength))
+	}
+	if classes := CharacterClasses(password); classes < p.MinClasses {
+		violations = append(violations, fmt.Sprintf("must use at least %d of lowercase, uppercase, digits, and symbols", p.MinClasses))
+	}
+	if bits := Entropy(password); bits < p.MinEntropyBits {
+		violations = append(violations, fmt.Sprintf("is too predictable (%.0f of %.0f bits of entropy)", bits, p.MinEntropyBits))
+	}
+
+	if p.BreachCheck && breaches != nil {
+		count, err := breaches.BreachCount(ctx, password)
+		if err != nil {
+			return violations, err
+		}
+		if count > 0 {
+			violations = append(violations, "appears in a known data breach")
+		}
+	}
+	return violations, nil
+}
+
+// ExpiresAt returns when a password set at changedAt expires, or nil when
+// the policy has no maximum age
+func (p Policy) ExpiresAt(changedAt time.Time) *time.Time {
+	if p.MaxAgeDays <= 0 || changedAt.IsZero() {
+		return nil
+	}
+	expires := changedAt.Add(time.Duration(p.MaxAgeDays) * 24 * time.Hour)
+	return &expires
+}
+
+// Generate returns a random password that meets the policy's length,
+// character class, and entropy rules
+func (p Policy) Generate() (string, error) {
+	const alphabet = "abcdefghijkmnopqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789-_.~!#%^*+="
+
+	// Entropy is estimated against the full 95 character pool; the margin
+	// covers characters that don't count because they continue a run
+	length := int(math.Ceil(p.MinEntropyBits/math.Log2(95)*1.25)) + 1
+	if length < p.MinLength {
+		length = p.MinLength
+	}
+	if length < 24 {
+		length = 24
+	}
+
+	for attempt := 0; attempt < 100; attempt++ {
+		buf := make([]byte, length)
+		for i := range buf {
+			n, err := rand.Int(rand.Reader, big.NewInt(int64(len(alphabet))))
+			if err != nil {
+				return "", fmt.Errorf("failed to generate password: %w", err)
+			}
+			buf[i] = alphabet[n.Int64()]
+		}
+		password := string(buf)
+		if CharacterClasses(password) >= p.MinClasses && Entropy(password) >= p.MinEntropyBits {
+			return password, nil
+		}
+	}
+	return "", fmt.Errorf("failed to generate a password meeting the policy")
+}
+
+// CharacterClasses counts the classes, of lowercase, uppercase, digits, and
+// symbols, that a password uses
+func CharacterClasses(password string) int {
+	count := 0
+	for _, size := range classSizes(password) {
+		if size > 0 {
+			count++
+		}
+	}
+	return count
+}
+
+// Entropy estimates a password's entropy in bits from the size of the
+// alphabet its character classes span. Repeated characters and runs such as
+// "aaaa" or "1234" only count once, so padding a weak password doesn't make
+// it strong.
+func Entropy(password string) float64 {
+	runes := []rune(password)
+	if len(runes) == 0 {
+		return 0
+	}
+
+	pool := 0
+	for _, size := range classSizes(password) {
+		pool += size
+	}
+
+	effective := 1
+	for i := 1; i < len(runes); i++ {
+		step := runes[i] - runes[i-1]
+		if step == 0 || step == 1 || step == -1 {
+			continue
+		}
+		effective++
+	}
+	return float64(effective) * math.Log2(float64(pool))
+}
+
+// classSizes returns the alphabet size of each character class a password
+// uses, and zero for the classes it doesn't
+func classSizes(password string) [4]int {
+	var sizes [4]int
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			sizes[0] = 26
+		case unicode.IsUpper(r):
+			sizes[1] = 26
+		case unicode.IsDigit(r):
+			sizes[2] = 10
+		default:
+			sizes[3] = 33
+		}
+	}
+	return sizes
+}
+
+// PwnedPasswords checks passwords against a Have I Been Pwned style range
+// API. Only the first five hex characters of the password's SHA-1 hash are
+// sent; the response lists the suffixes of breached hashes with that prefix.
+type PwnedPasswords struct {
+	URL    string
+	Client *http.Client
+}
+
+// NewPwnedPasswords creates a breach checker for a range API
+func NewPwnedPasswords(url string, timeout time.Duration) *PwnedPasswords {
+	if url == "" {
+		url = DefaultBreachURL
+	}
+	return &PwnedPasswords{URL: url, Client: &http.Client{Timeout: timeout}}
+}
+
+// BreachCount returns how often the password appears in known breaches
+func (p *PwnedPasswords) BreachCount(ctx context.Context, password string) (int, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:5], hash[5:]
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.URL+prefix, nil)
+	if err != nil {
+		return 0, err
+	}
+	// Padding hides the true number of suffixes from observers
+	req.Header.Set("Add-Padding", "true")
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("breach check failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("breach check returned status %d", resp.StatusCode)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		candidate, count, ok := strings.Cut(line, ":")
+		if !ok || !strings.EqualFold(candidate, suffix) {
+			continue
+		}
+		n, err := strconv.Atoi(count)
+		if err != nil {
+			return 0, fmt.Errorf("breach check returned invalid count %q", count)
+		}
+		return n, nil
+	}
+	return 0, scanner.Err()
+}
@@ -0,0 +1,255 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// Cache is a key/value cache for hot read paths. Values are stored as JSON.
+// Keys are "<namespace>:<id>" so that a namespace can be invalidated at once.
+type Cache interface {
+	// Get decodes the cached value into dest and reports whether it was found
+	Get(ctx context.Context, key string, dest interface{}) (bool, error)
+	Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error
+	Delete(ctx context.Context, keys ...string) error
+	DeleteNamespace(ctx context.Context, namespace string) error
+}
+
+var (
+	cacheRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "nest_cache_requests_total",
+			Help: "Cache lookups by namespace and result (hit, miss, error)",
+		},
+		[]string{"namespace", "result"},
+	)
+	cacheInvalidations = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "nest_cache_invalidations_total",
+			Help: "Cache invalidations by namespace",
+		},
+		[]string{"namespace"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(cacheRequests, cacheInvalidations)
+}
+
+// cacheNamespace returns the namespace portion of a cache key
+func cacheNamespace(key string) string {
+	if i := strings.IndexByte(key, ':'); i > 0 {
+		return key[:i]
+	}
+	return key
+}
+
+// recordLookup counts a cache lookup result
+func recordLookup(key string, found bool, err error) {
+	result := "miss"
+	switch {
+	case err != nil:
+		result = "error"
+	case found:
+		result = "hit"
+	}
+	cacheRequests.WithLabelValues(cacheNamespace(key), result).Inc()
+}
+
+// memoryEntry is a cached value and its expiry
+type memoryEntry struct {
+	value   []byte
+	expires time.Time
+}
+
+// MemoryCache is an in-process Cache. Each API replica holds its own copy,
+// so invalidation only reaches the replica that performed the write.
+type MemoryCache struct {
+	mu      sync.RWMutex
+	entries map[string]memoryEntry
+}
+
+// NewMemoryCache creates an in-process cache
+func NewMemoryCache() *MemoryCache {
+	return &MemoryCache{entries: make(map[string]memoryEntry)}
+}
+
+// Get implements Cache
+func (m *MemoryCache) Get(ctx context.Context, key string, dest interface{}) (bool, error) {
+	m.mu.RLock()
+	entry, ok := m.entries[key]
+	m.mu.RUnlock()
+
+	if ok && time.Now().After(entry.expires) {
+		m.mu.Lock()
+		delete(m.entries, key)
+		m.mu.Unlock()
+		ok = false
+	}
+	if !ok {
+		recordLookup(key, false, nil)
+		return false, nil
+	}
+
+	err := json.Unmarshal(entry.value, dest)
+	recordLookup(key, err == nil, err)
+	return err == nil, err
+}
+
+// Set implements Cache
+func (m *MemoryCache) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode cache value: %w", err)
+	}
+
+	m.mu.Lock()
+	m.entries[key] = memoryEntry{value: data, expires: time.Now().Add(ttl)}
+	m.mu.Unlock()
+	return nil
+}
+
+// Delete implements Cache
+func (m *MemoryCache) Delete(ctx context.Context, keys ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, key := range keys {
+		delete(m.entries, key)
+	}
+	return nil
+}
+
+// DeleteNamespace implements Cache
+func (m *MemoryCache) DeleteNamespace(ctx context.Context, namespace string) error {
+	prefix := namespace + ":"
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for key := range m.entries {
+		if strings.HasPrefix(key, prefix) {
+			delete(m.entries, key)
+		}
+	}
+	cacheInvalidations.WithLabelValues(namespace).Inc()
+	return nil
+}
+
+// RedisCache is a Cache shared by every API replica
+type RedisCache struct {
+	client *RedisClient
+	prefix string
+}
+
+// NewRedisCache creates a Redis-backed cache. Keys are stored under "cache:".
+func NewRedisCache(client *RedisClient) *RedisCache {
+	return &RedisCache{client: client, prefix: "cache:"}
+}
+
+// Get implements Cache
+func (r *RedisCache) Get(ctx context.Context, key string, dest interface{}) (bool, error) {
+	data, err := r.client.Get(ctx, r.prefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		recordLookup(key, false, nil)
+		return false, nil
+	}
+	if err == nil {
+		err = json.Unmarshal(data, dest)
+	}
+	recordLookup(key, err == nil, err)
+	return err == nil, err
+}
+
+// Set implements Cache
+func (r *RedisCache) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode cache value: %w", err)
+	}
+	return r.client.Set(ctx, r.prefix+key, data, ttl).Err()
+}
+
+// Delete implements Cache
+func (r *RedisCache) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = r.prefix + key
+	}
+	return r.client.Del(ctx, prefixed...).Err()
+}
+
+// DeleteNamespace implements Cache
+func (r *RedisCache) DeleteNamespace(ctx context.Context, namespace string) error {
+	iter := r.client.Scan(ctx, 0, r.prefix+namespace+":*", 500).Iterator()
+	var keys []string
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+	cacheInvalidations.WithLabelValues(namespace).Inc()
+	if len(keys) == 0 {
+		return nil
+	}
+	return r.client.Del(ctx, keys...).Err()
+}
+
+// NewCacheFromEnv creates the cache selected by CACHE_BACKEND: "redis" (using
+// REDIS_URL), "memory" (the default) or "none". When Redis is unavailable the
+// in-process cache is used instead. It returns nil for "none".
+func NewCacheFromEnv() Cache {
+	switch strings.ToLower(getEnv("CACHE_BACKEND", "memory")) {
+	case "none":
+		return nil
+	case "redis":
+		client, err := NewRedisFromURL(os.Getenv("REDIS_URL"))
+		if err == nil {
+			return NewRedisCache(client)
+		}
+		log.Printf("Redis cache unavailable, falling back to in-process cache: %v", err)
+	}
+	return NewMemoryCache()
+}
+
+// CacheTTLFromEnv returns the cache TTL from CACHE_TTL, defaulting to 60s
+func CacheTTLFromEnv() time.Duration {
+	return getEnvDuration("CACHE_TTL", 60*time.Second)
+}
+
+// RegisterCacheInvalidation registers GORM callbacks that clear cache
+// namespaces whenever a row in one of the mapped tables is created, updated
+// or deleted
+func RegisterCacheInvalidation(db *gorm.DB, cache Cache, tables map[string][]string) error {
+	invalidate := func(tx *gorm.DB) {
+		if tx.Error != nil || tx.Statement == nil {
+			return
+		}
+		for _, namespace := range tables[tx.Statement.Table] {
+			if err := cache.DeleteNamespace(tx.Statement.Context, namespace); err != nil {
+				log.Printf("Failed to invalidate cache namespace %s: %v", namespace, err)
+			}
+		}
+	}
+
+	cb := db.Callback()
+	if err := cb.Create().After("gorm:create").Register("nest:cache_invalidate", invalidate); err != nil {
+		return err
+	}
+	if err := cb.Update().After("gorm:update").Register("nest:cache_invalidate", invalidate); err != nil {
+		return err
+	}
+	return cb.Delete().After("gorm:delete").Register("nest:cache_invalidate", invalidate)
+}
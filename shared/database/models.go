@@ -147,7 +147,7 @@ func (ResourceUser) TableName() string {
 type CertificateAuthority struct {
 	ID             uint           `gorm:"primaryKey" json:"id"`
 	Name           string         `gorm:"uniqueIndex;not null;size:255" json:"name"`
-	Type           string         `gorm:"not null;size:50" json:"type"` // root, intermediate, self-signed
+	Type           string         `gorm:"not null;size:50" json:"type"` // root, intermediate, self-signed, acme
 	Certificate    string         `gorm:"type:text;not null" json:"certificate"`
 	PrivateKey     string         `gorm:"type:text;not null" json:"-"`
 	Subject        string         `gorm:"size:255" json:"subject"`
@@ -267,6 +267,17 @@ type AuditLog struct {
 	IPAddress    string         `gorm:"size:45" json:"ip_address"`
 	UserAgent    string         `gorm:"type:text" json:"user_agent,omitempty"`
 	Timestamp    time.Time      `gorm:"index" json:"timestamp"`
+
+	// Hash chain set by the audit_logs insert trigger: ChainSeq orders the
+	// chain and EntryHash covers the entry together with PrevHash, the
+	// EntryHash of the entry before it. The personal data in IPAddress and
+	// UserAgent is covered through PIIDigest, so that erasing it, which
+	// sets ErasedAt, leaves the chain intact.
+	ChainSeq  *int64     `gorm:"index" json:"chain_seq,omitempty"`
+	PrevHash  string     `gorm:"size:64" json:"prev_hash,omitempty"`
+	EntryHash string     `gorm:"size:64" json:"entry_hash,omitempty"`
+	PIIDigest string     `gorm:"size:64" json:"-"`
+	ErasedAt  *time.Time `json:"erased_at,omitempty"`
 }
 
 // TableName specifies the table name for AuditLog
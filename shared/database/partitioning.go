@@ -0,0 +1,214 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PartitionSpec describes a table that is range-partitioned by month on a
+// timestamp column
+type PartitionSpec struct {
+	Table  string
+	Column string
+}
+
+// DefaultPartitionSpecs are the high-volume time-series tables
+var DefaultPartitionSpecs = []PartitionSpec{
+	{Table: "resource_stats", Column: "timestamp"},
+	{Table: "audit_logs", Column: "timestamp"},
+}
+
+// partitionSuffixLayout is the time layout of monthly partition name suffixes
+const partitionSuffixLayout = "200601"
+
+// MonthlyPartitionName returns the name of the partition holding the given month
+func MonthlyPartitionName(table string, month time.Time) string {
+	return table + "_p" + month.UTC().Format(partitionSuffixLayout)
+}
+
+// monthStart truncates a time to the first instant of its month in UTC
+func monthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// IsPartitioned reports whether a table is a partitioned parent table
+func IsPartitioned(ctx context.Context, db *gorm.DB, table string) (bool, error) {
+	var count int64
+	err := db.WithContext(ctx).Raw(`
+		SELECT COUNT(*) FROM pg_partitioned_table pt
+		JOIN pg_class c ON c.oid = pt.partrelid
+		WHERE c.relname = ? AND pg_table_is_visible(c.oid)`, table).Scan(&count).Error
+	return count > 0, err
+}
+
+// ConvertToPartitioned converts an existing regular table into a monthly
+// range-partitioned table in a single transaction. Existing rows are copied
+// into monthly partitions, secondary indexes are recreated on the parent, and
+// the primary key becomes (id, column) as Postgres requires the partition key
+// in every unique constraint. Tables that do not exist or are already
+// partitioned are left untouched.
+func ConvertToPartitioned(ctx context.Context, db *gorm.DB, ps PartitionSpec) error {
+	if !db.Migrator().HasTable(ps.Table) {
+		return nil
+	}
+	partitioned, err := IsPartitioned(ctx, db, ps.Table)
+	if err != nil || partitioned {
+		return err
+	}
+
+	legacy := ps.Table + "_unpartitioned"
+	log.Printf("Converting %s to a monthly partitioned table", ps.Table)
+
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Capture secondary index definitions before the table is renamed
+		var indexDefs []string
+		if err := tx.Raw(`
+			SELECT i.indexdef FROM pg_indexes i
+			JOIN pg_index x ON x.indexrelid = (quote_ident(i.schemaname) || '.' || quote_ident(i.indexname))::regclass
+			WHERE i.tablename = ? AND NOT x.indisprimary`, ps.Table).Scan(&indexDefs).Error; err != nil {
+			return fmt.Errorf("failed to read indexes: %w", err)
+		}
+
+		var sequence *string
+		if err := tx.Raw("SELECT pg_get_serial_sequence(?, 'id')", ps.Table).Scan(&sequence).Error; err != nil {
+			return fmt.Errorf("failed to read id sequence: %w", err)
+		}
+
+		stmts := []string{
+			fmt.Sprintf(`ALTER TABLE %s RENAME TO %s`, ps.Table, legacy),
+			fmt.Sprintf(`UPDATE %s SET %q = NOW() WHERE %q IS NULL`, legacy, ps.Column, ps.Column),
+			fmt.Sprintf(`CREATE TABLE %s (LIKE %s INCLUDING DEFAULTS INCLUDING CONSTRAINTS) PARTITION BY RANGE (%q)`,
+				ps.Table, legacy, ps.Column),
+			fmt.Sprintf(`ALTER TABLE %s ALTER COLUMN %q SET NOT NULL`, ps.Table, ps.Column),
+			fmt.Sprintf(`ALTER TABLE %s ADD PRIMARY KEY (id, %q)`, ps.Table, ps.Column),
+			fmt.Sprintf(`CREATE TABLE %s_default PARTITION OF %s DEFAULT`, ps.Table, ps.Table),
+		}
+		for _, stmt := range stmts {
+			if err := tx.Exec(stmt).Error; err != nil {
+				return fmt.Errorf("partition conversion failed at %q: %w", stmt, err)
+			}
+		}
+
+		// Create monthly partitions spanning the existing data
+		var bounds struct {
+			Min *time.Time
+			Max *time.Time
+		}
+		if err := tx.Raw(fmt.Sprintf(`SELECT MIN(%q) AS min, MAX(%q) AS max FROM %s`,
+			ps.Column, ps.Column, legacy)).Scan(&bounds).Error; err != nil {
+			return fmt.Errorf("failed to read data range: %w", err)
+		}
+		if bounds.Min != nil && bounds.Max != nil {
+			for m := monthStart(*bounds.Min); !m.After(*bounds.Max); m = m.AddDate(0, 1, 0) {
+				if err := createMonthlyPartition(tx, ps.Table, m); err != nil {
+					return err
+				}
+			}
+		}
+
+		moves := []string{
+			fmt.Sprintf(`INSERT INTO %s SELECT * FROM %s`, ps.Table, legacy),
+		}
+		if sequence != nil && *sequence != "" {
+			moves = append(moves, fmt.Sprintf(`ALTER SEQUENCE %s OWNED BY %s.id`, *sequence, ps.Table))
+		}
+		moves = append(moves, fmt.Sprintf(`DROP TABLE %s`, legacy))
+		for _, stmt := range moves {
+			if err := tx.Exec(stmt).Error; err != nil {
+				return fmt.Errorf("partition conversion failed at %q: %w", stmt, err)
+			}
+		}
+
+		// Recreate secondary indexes on the partitioned parent. Unique indexes
+		// without the partition key cannot exist on a partitioned table.
+		for _, def := range indexDefs {
+			if strings.HasPrefix(def, "CREATE UNIQUE INDEX") {
+				log.Printf("Skipping unique index on partitioned table %s: %s", ps.Table, def)
+				continue
+			}
+			def = strings.Replace(def, "."+legacy+" ", "."+ps.Table+" ", 1)
+			def = strings.Replace(def, " ON "+legacy+" ", " ON "+ps.Table+" ", 1)
+			if err := tx.Exec(def).Error; err != nil {
+				return fmt.Errorf("failed to recreate index %q: %w", def, err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// createMonthlyPartition creates the partition for a single month if missing
+func createMonthlyPartition(db *gorm.DB, table string, month time.Time) error {
+	from := monthStart(month)
+	to := from.AddDate(0, 1, 0)
+	stmt := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s PARTITION OF %s FOR VALUES FROM ('%s') TO ('%s')`,
+		MonthlyPartitionName(table, from), table,
+		from.Format(time.RFC3339), to.Format(time.RFC3339))
+	if err := db.Exec(stmt).Error; err != nil {
+		return fmt.Errorf("failed to create partition %s: %w", MonthlyPartitionName(table, from), err)
+	}
+	return nil
+}
+
+// EnsureMonthlyPartitions creates partitions from the current month through
+// monthsAhead future months. Rows outside every partition land in the
+// default partition, so a missed maintenance run never rejects inserts.
+func EnsureMonthlyPartitions(ctx context.Context, db *gorm.DB, ps PartitionSpec, monthsAhead int) error {
+	partitioned, err := IsPartitioned(ctx, db, ps.Table)
+	if err != nil || !partitioned {
+		return err
+	}
+
+	current := monthStart(time.Now())
+	for i := 0; i <= monthsAhead; i++ {
+		if err := createMonthlyPartition(db.WithContext(ctx), ps.Table, current.AddDate(0, i, 0)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DropPartitionsBefore drops monthly partitions whose entire range is older
+// than cutoff and returns the names of the dropped partitions
+func DropPartitionsBefore(ctx context.Context, db *gorm.DB, ps PartitionSpec, cutoff time.Time) ([]string, error) {
+	partitioned, err := IsPartitioned(ctx, db, ps.Table)
+	if err != nil || !partitioned {
+		return nil, err
+	}
+
+	var names []string
+	if err := db.WithContext(ctx).Raw(`
+		SELECT c.relname FROM pg_inherits i
+		JOIN pg_class c ON c.oid = i.inhrelid
+		JOIN pg_class p ON p.oid = i.inhparent
+		WHERE p.relname = ?`, ps.Table).Scan(&names).Error; err != nil {
+		return nil, fmt.Errorf("failed to list partitions: %w", err)
+	}
+
+	prefix := ps.Table + "_p"
+	var dropped []string
+	for _, name := range names {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		month, err := time.Parse(partitionSuffixLayout, strings.TrimPrefix(name, prefix))
+		if err != nil {
+			continue
+		}
+		if month.AddDate(0, 1, 0).After(cutoff) {
+			continue
+		}
+		if err := db.WithContext(ctx).Exec(fmt.Sprintf(`DROP TABLE IF EXISTS %s`, name)).Error; err != nil {
+			return dropped, fmt.Errorf("failed to drop partition %s: %w", name, err)
+		}
+		dropped = append(dropped, name)
+	}
+
+	return dropped, nil
+}
@@ -1,12 +1,17 @@
 package database
 
 import (
+	"crypto/tls"
 	"database/sql"
 	"fmt"
 	"log"
 	"os"
+	"sort"
+	"strings"
 	"time"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
@@ -15,7 +20,8 @@ import (
 // Database represents a database connection
 type Database struct {
 	*gorm.DB
-	config *Config
+	config   *Config
+	replicas *ReplicaResolver
 }
 
 // Config holds database configuration
@@ -33,6 +39,19 @@ type Config struct {
 	MaxIdleConns    int
 	ConnMaxLifetime time.Duration
 	ConnMaxIdleTime time.Duration
+
+	// Read replica settings
+	ReplicaDSNs           []string
+	ReplicaHealthInterval time.Duration
+	ReplicaMaxLag         time.Duration
+
+	// RuntimeParams are session parameters, such as search_path, set on
+	// every connection
+	RuntimeParams map[string]string
+
+	// TLSConfig, when set, replaces SSLMode: every connection uses TLS with
+	// this config, such as a service identity for mutual TLS
+	TLSConfig *tls.Config
 }
 
 // DefaultConfig returns default database configuration
@@ -50,6 +69,10 @@ func DefaultConfig() *Config {
 		MaxIdleConns:    10,
 		ConnMaxLifetime: 5 * time.Minute,
 		ConnMaxIdleTime: 1 * time.Minute,
+
+		ReplicaDSNs:           getEnvList("DATABASE_REPLICA_URLS"),
+		ReplicaHealthInterval: getEnvDuration("DATABASE_REPLICA_HEALTH_INTERVAL", 10*time.Second),
+		ReplicaMaxLag:         getEnvDuration("DATABASE_REPLICA_MAX_LAG", 0),
 	}
 }
 
@@ -95,7 +118,7 @@ func NewFromURL(url string) (*Database, error) {
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	return &Database{DB: db, config: config}, nil
+	return attachReplicas(&Database{DB: db, config: config})
 }
 
 // New creates a new database connection with configuration
@@ -109,6 +132,14 @@ func New(config *Config) (*Database, error) {
 		config.Host, config.Port, config.User, config.Password,
 		config.DBName, config.SSLMode, config.TimeZone,
 	)
+	keys := make([]string, 0, len(config.RuntimeParams))
+	for k := range config.RuntimeParams {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		dsn += fmt.Sprintf(" %s='%s'", k, strings.ReplaceAll(config.RuntimeParams[k], "'", `\'`))
+	}
 
 	var logLevel logger.LogLevel = logger.Silent
 	if os.Getenv("LOG_LEVEL") == "debug" {
@@ -122,10 +153,22 @@ func New(config *Config) (*Database, error) {
 		},
 	}
 
-	db, err := gorm.Open(postgres.New(postgres.Config{
+	dialector := postgres.New(postgres.Config{
 		DSN:                  dsn,
 		PreferSimpleProtocol: true, // disables implicit prepared statement usage
-	}), gormConfig)
+	})
+	if config.TLSConfig != nil {
+		connConfig, err := pgx.ParseConfig(dsn)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse database config: %w", err)
+		}
+		connConfig.TLSConfig = config.TLSConfig
+		connConfig.Fallbacks = nil
+		connConfig.DefaultQueryExecMode = pgx.QueryExecModeSimpleProtocol
+		dialector = postgres.New(postgres.Config{Conn: stdlib.OpenDB(*connConfig)})
+	}
+
+	db, err := gorm.Open(dialector, gormConfig)
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
@@ -144,7 +187,27 @@ func New(config *Config) (*Database, error) {
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	return &Database{DB: db, config: config}, nil
+	return attachReplicas(&Database{DB: db, config: config})
+}
+
+// attachReplicas routes reads to the configured read replicas, if any
+func attachReplicas(db *Database) (*Database, error) {
+	if len(db.config.ReplicaDSNs) == 0 {
+		return db, nil
+	}
+
+	resolver, err := NewReplicaResolver(db.config)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Use(resolver); err != nil {
+		resolver.Close()
+		return nil, fmt.Errorf("failed to register replica resolver: %w", err)
+	}
+	db.replicas = resolver
+
+	log.Printf("Routing reads to %d database replica(s)", len(db.config.ReplicaDSNs))
+	return db, nil
 }
 
 // configureConnectionPool sets up the connection pool
@@ -157,6 +220,9 @@ func configureConnectionPool(sqlDB *sql.DB, config *Config) {
 
 // Close closes the database connection
 func (db *Database) Close() error {
+	if db.replicas != nil {
+		db.replicas.Close()
+	}
 	sqlDB, err := db.DB.DB()
 	if err != nil {
 		return err
@@ -181,6 +247,29 @@ func (db *Database) HealthCheck() error {
 	return nil
 }
 
+// ReplicaStatus returns the health of each configured read replica
+func (db *Database) ReplicaStatus() map[string]bool {
+	if db.replicas == nil {
+		return map[string]bool{}
+	}
+	return db.replicas.Status()
+}
+
+// Pools returns the connection pools of the primary and each read replica,
+// by name, for exporting their statistics
+func (db *Database) Pools() map[string]*sql.DB {
+	pools := map[string]*sql.DB{}
+	if sqlDB, err := db.DB.DB(); err == nil {
+		pools["primary"] = sqlDB
+	}
+	if db.replicas != nil {
+		for _, r := range db.replicas.replicas {
+			pools[r.name] = r.db
+		}
+	}
+	return pools
+}
+
 // GetStats returns database connection statistics
 func (db *Database) GetStats() sql.DBStats {
 	sqlDB, err := db.DB.DB()
@@ -209,6 +298,27 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+// getEnvList gets a comma-separated environment variable as a list
+func getEnvList(key string) []string {
+	var values []string
+	for _, v := range strings.Split(os.Getenv(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// getEnvDuration gets a duration environment variable with a default value
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := time.ParseDuration(value); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
+
 // Common model definitions for GORM
 type BaseModel struct {
 	ID        uint           `gorm:"primarykey" json:"id"`
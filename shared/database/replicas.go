@@ -0,0 +1,219 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// usePrimaryKey is the statement setting that pins reads to the primary
+const usePrimaryKey = "nest:use_primary"
+
+// UsePrimary returns a reusable session whose queries always run against the
+// primary, for reads that must observe a write made moments earlier
+func UsePrimary(db *gorm.DB) *gorm.DB {
+	return db.Set(usePrimaryKey, true).Session(&gorm.Session{})
+}
+
+// replica is a single read replica connection and its last known health
+type replica struct {
+	name    string
+	db      *sql.DB
+	healthy atomic.Bool
+}
+
+// ReplicaResolver is a GORM plugin that routes read queries to healthy read
+// replicas in round-robin order. Writes, raw non-SELECT statements, locking
+// reads and everything inside a transaction stay on the primary. When no
+// replica is healthy, reads fail back to the primary.
+type ReplicaResolver struct {
+	replicas []*replica
+	next     atomic.Uint64
+	interval time.Duration
+	maxLag   time.Duration
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewReplicaResolver opens connections to the configured replicas. A replica
+// that cannot be reached at startup is marked unhealthy rather than failing
+// startup, and rejoins the rotation once its health check passes.
+func NewReplicaResolver(config *Config) (*ReplicaResolver, error) {
+	r := &ReplicaResolver{
+		interval: config.ReplicaHealthInterval,
+		maxLag:   config.ReplicaMaxLag,
+		stop:     make(chan struct{}),
+	}
+	if r.interval <= 0 {
+		r.interval = 10 * time.Second
+	}
+
+	for i, dsn := range config.ReplicaDSNs {
+		gdb, err := gorm.Open(postgres.New(postgres.Config{
+			DSN:                  dsn,
+			PreferSimpleProtocol: true,
+		}), &gorm.Config{
+			Logger:               logger.Default.LogMode(logger.Silent),
+			DisableAutomaticPing: true,
+		})
+		if err != nil {
+			r.Close()
+			return nil, fmt.Errorf("failed to open replica %d: %w", i, err)
+		}
+		sqlDB, err := gdb.DB()
+		if err != nil {
+			r.Close()
+			return nil, fmt.Errorf("failed to get replica %d sql.DB: %w", i, err)
+		}
+		configureConnectionPool(sqlDB, config)
+		r.replicas = append(r.replicas, &replica{name: fmt.Sprintf("replica-%d", i), db: sqlDB})
+	}
+
+	r.checkAll()
+	return r, nil
+}
+
+// Name implements gorm.Plugin
+func (r *ReplicaResolver) Name() string {
+	return "nest:replica_resolver"
+}
+
+// Initialize implements gorm.Plugin by registering read routing callbacks and
+// starting the replica health monitor
+func (r *ReplicaResolver) Initialize(db *gorm.DB) error {
+	if err := db.Callback().Query().Before("gorm:query").Register("nest:route_read", r.routeRead); err != nil {
+		return err
+	}
+	if err := db.Callback().Row().Before("gorm:row").Register("nest:route_read", r.routeRead); err != nil {
+		return err
+	}
+
+	go r.monitor()
+	return nil
+}
+
+// routeRead swaps the statement's connection pool for a healthy replica
+func (r *ReplicaResolver) routeRead(db *gorm.DB) {
+	if db.Error != nil {
+		return
+	}
+	if _, inTx := db.Statement.ConnPool.(gorm.TxCommitter); inTx {
+		return
+	}
+	if pinned, ok := db.Get(usePrimaryKey); ok && pinned == true {
+		return
+	}
+	if _, locking := db.Statement.Clauses["FOR"]; locking {
+		return
+	}
+	// Raw SQL is already built; only plain SELECTs may leave the primary
+	if db.Statement.SQL.Len() > 0 {
+		query := strings.ToUpper(strings.TrimSpace(db.Statement.SQL.String()))
+		if !strings.HasPrefix(query, "SELECT") || strings.Contains(query, " FOR UPDATE") || strings.Contains(query, " FOR SHARE") {
+			return
+		}
+	}
+
+	if pool := r.pick(); pool != nil {
+		db.Statement.ConnPool = pool
+	}
+}
+
+// pick returns the next healthy replica, or nil if none are healthy
+func (r *ReplicaResolver) pick() *sql.DB {
+	n := len(r.replicas)
+	start := r.next.Add(1)
+	for i := 0; i < n; i++ {
+		rep := r.replicas[(start+uint64(i))%uint64(n)]
+		if rep.healthy.Load() {
+			return rep.db
+		}
+	}
+	return nil
+}
+
+// monitor re-checks replica health on each interval until Close is called
+func (r *ReplicaResolver) monitor() {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			r.checkAll()
+		}
+	}
+}
+
+// checkAll updates the health of every replica, logging state changes
+func (r *ReplicaResolver) checkAll() {
+	for _, rep := range r.replicas {
+		err := r.check(rep)
+		healthy := err == nil
+		if rep.healthy.Swap(healthy) != healthy {
+			if healthy {
+				log.Printf("Database %s is healthy, routing reads to it", rep.name)
+			} else {
+				log.Printf("Database %s is unhealthy, removing from read rotation: %v", rep.name, err)
+			}
+		}
+	}
+}
+
+// check pings a replica and, when a maximum lag is configured, verifies its
+// replication delay
+func (r *ReplicaResolver) check(rep *replica) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	if err := rep.db.PingContext(ctx); err != nil {
+		return err
+	}
+	if r.maxLag <= 0 {
+		return nil
+	}
+
+	var lagSeconds float64
+	if err := rep.db.QueryRowContext(ctx,
+		"SELECT COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)",
+	).Scan(&lagSeconds); err != nil {
+		return fmt.Errorf("failed to read replication lag: %w", err)
+	}
+	if lag := time.Duration(lagSeconds * float64(time.Second)); lag > r.maxLag {
+		return fmt.Errorf("replication lag %s exceeds %s", lag.Round(time.Second), r.maxLag)
+	}
+	return nil
+}
+
+// Status returns the health of each replica keyed by name
+func (r *ReplicaResolver) Status() map[string]bool {
+	status := make(map[string]bool, len(r.replicas))
+	for _, rep := range r.replicas {
+		status[rep.name] = rep.healthy.Load()
+	}
+	return status
+}
+
+// Close stops health monitoring and closes replica connections
+func (r *ReplicaResolver) Close() error {
+	r.stopOnce.Do(func() { close(r.stop) })
+	var firstErr error
+	for _, rep := range r.replicas {
+		if err := rep.db.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,74 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"gorm.io/gorm"
+)
+
+// tenantContextKey is the request context key for the tenant scope
+type tenantContextKey struct{}
+
+// tenantScope is the schema and connection pool of the tenant a request
+// belongs to
+type tenantScope struct {
+	schema string
+	db     *gorm.DB
+}
+
+// schemaNamePattern restricts tenant schema names to safe identifiers, since
+// they are interpolated into DDL and search_path
+var schemaNamePattern = regexp.MustCompile(`^[a-z_][a-z0-9_]{0,62}$`)
+
+// ValidSchemaName reports whether a tenant schema name is a safe identifier
+func ValidSchemaName(schema string) bool {
+	return schemaNamePattern.MatchString(schema) && schema != "public" && schema != "pg_catalog"
+}
+
+// SchemaConfig returns a copy of a configuration whose connections resolve
+// tables in the given schema first and shared tables in public. Read
+// replicas are not used for tenant schemas.
+func SchemaConfig(base *Config, schema string, maxOpenConns int) (*Config, error) {
+	if !ValidSchemaName(schema) {
+		return nil, fmt.Errorf("invalid tenant schema name %q", schema)
+	}
+
+	config := *base
+	config.ReplicaDSNs = nil
+	config.MaxOpenConns = maxOpenConns
+	if config.MaxIdleConns > maxOpenConns {
+		config.MaxIdleConns = maxOpenConns
+	}
+	config.RuntimeParams = map[string]string{}
+	for k, v := range base.RuntimeParams {
+		config.RuntimeParams[k] = v
+	}
+	config.RuntimeParams["search_path"] = schema + ",public"
+	return &config, nil
+}
+
+// WithTenantDB returns a context carrying a tenant's schema and connection
+// pool
+func WithTenantDB(ctx context.Context, schema string, db *gorm.DB) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, tenantScope{schema: schema, db: db})
+}
+
+// TenantDB returns the connection pool of the context's tenant, or fallback
+// when the request is not scoped to a tenant
+func TenantDB(ctx context.Context, fallback *gorm.DB) *gorm.DB {
+	if scope, ok := ctx.Value(tenantContextKey{}).(tenantScope); ok && scope.db != nil {
+		return scope.db
+	}
+	return fallback
+}
+
+// TenantSchema returns the schema of the context's tenant, or "" when the
+// request is not scoped to a tenant
+func TenantSchema(ctx context.Context) string {
+	if scope, ok := ctx.Value(tenantContextKey{}).(tenantScope); ok {
+		return scope.schema
+	}
+	return ""
+}
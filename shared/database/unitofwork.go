@@ -0,0 +1,16 @@
+package database
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// UnitOfWork runs fn in one transaction of the context's tenant database, or
+// of db outside a tenant. The transaction commits when fn returns nil and
+// rolls back when it returns an error or panics, so the writes of a
+// multi-step change land together or not at all. fn's error is returned
+// unchanged, for the caller to map to a response.
+func UnitOfWork(ctx context.Context, db *gorm.DB, fn func(tx *gorm.DB) error) error {
+	return TenantDB(ctx, db).WithContext(ctx).Transaction(fn)
+}
@@ -0,0 +1,142 @@
+// Package diagnostics reports the Go runtime state of a service and serves
+// its pprof profiles, for troubleshooting in production. Neither is
+// authenticated here; services expose them to admins or to local clients
+// only.
+package diagnostics
+
+import (
+	"database/sql"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// RuntimeStats is a snapshot of the Go runtime
+type RuntimeStats struct {
+	GoVersion     string        `json:"go_version"`
+	StartedAt     time.Time     `json:"started_at"`
+	Uptime        string        `json:"uptime"`
+	NumCPU        int           `json:"num_cpu"`
+	GOMAXPROCS    int           `json:"gomaxprocs"`
+	Goroutines    int           `json:"goroutines"`
+	HeapAlloc     uint64        `json:"heap_alloc_bytes"`
+	HeapInuse     uint64        `json:"heap_inuse_bytes"`
+	HeapObjects   uint64        `json:"heap_objects"`
+	Sys           uint64        `json:"sys_bytes"`
+	NumGC         uint32        `json:"num_gc"`
+	LastGC        *time.Time    `json:"last_gc,omitempty"`
+	PauseTotal    time.Duration `json:"pause_total_ns"`
+	LastPause     time.Duration `json:"last_pause_ns"`
+	GCCPUFraction float64       `json:"gc_cpu_fraction"`
+	NextGC        uint64        `json:"next_gc_bytes"`
+}
+
+// Runtime takes a snapshot of the Go runtime of a process started at
+// startedAt
+func Runtime(startedAt time.Time) RuntimeStats {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	var gc debug.GCStats
+	debug.ReadGCStats(&gc)
+
+	stats := RuntimeStats{
+		GoVersion:     runtime.Version(),
+		StartedAt:     startedAt,
+		Uptime:        time.Since(startedAt).Round(time.Second).String(),
+		NumCPU:        runtime.NumCPU(),
+		GOMAXPROCS:    runtime.GOMAXPROCS(0),
+		Goroutines:    runtime.NumGoroutine(),
+		HeapAlloc:     mem.HeapAlloc,
+		HeapInuse:     mem.HeapInuse,
+		HeapObjects:   mem.HeapObjects,
+		Sys:           mem.Sys,
+		NumGC:         mem.NumGC,
+		PauseTotal:    gc.PauseTotal,
+		GCCPUFraction: mem.GCCPUFraction,
+		NextGC:        mem.NextGC,
+	}
+	if !gc.LastGC.IsZero() {
+		stats.LastGC = &gc.LastGC
+	}
+	if len(gc.Pause) > 0 {
+		stats.LastPause = gc.Pause[0]
+	}
+	return stats
+}
+
+// PoolStats is a snapshot of a database connection pool
+type PoolStats struct {
+	MaxOpen      int           `json:"max_open"`
+	Open         int           `json:"open"`
+	InUse        int           `json:"in_use"`
+	Idle         int           `json:"idle"`
+	WaitCount    int64         `json:"wait_count"`
+	WaitDuration time.Duration `json:"wait_duration_ns"`
+}
+
+// Pool takes a snapshot of a database connection pool
+func Pool(db *sql.DB) PoolStats {
+	stats := db.Stats()
+	return PoolStats{
+		MaxOpen:      stats.MaxOpenConnections,
+		Open:         stats.OpenConnections,
+		InUse:        stats.InUse,
+		Idle:         stats.Idle,
+		WaitCount:    stats.WaitCount,
+		WaitDuration: stats.WaitDuration,
+	}
+}
+
+// QueueDepth is the backlog of a buffered channel or other bounded queue
+type QueueDepth struct {
+	Length   int `json:"length"`
+	Capacity int `json:"capacity"`
+}
+
+// ServeProfile serves the pprof profile named by profile, such as heap or
+// goroutine, or the index of profiles when it is empty. Unlike the handlers
+// net/http/pprof registers, it works under any path prefix.
+func ServeProfile(w http.ResponseWriter, r *http.Request, profile string) {
+	switch strings.Trim(profile, "/") {
+	case "":
+		// Index serves the profile named after /debug/pprof/ in the path,
+		// so the path is reset to get the index itself
+		r.URL.Path = "/debug/pprof/"
+		pprof.Index(w, r)
+	case "cmdline":
+		pprof.Cmdline(w, r)
+	case "profile":
+		pprof.Profile(w, r)
+	case "symbol":
+		pprof.Symbol(w, r)
+	case "trace":
+		pprof.Trace(w, r)
+	default:
+		pprof.Handler(strings.Trim(profile, "/")).ServeHTTP(w, r)
+	}
+}
+
+// IsLocal reports whether a request's remote address is a loopback address
+func IsLocal(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// LocalOnly serves next to loopback clients only, and 403 to the rest
+func LocalOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !IsLocal(r.RemoteAddr) {
+			http.Error(w, "diagnostics are only served to local clients", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
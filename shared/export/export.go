@@ -0,0 +1,259 @@
+// Package export writes tables of report data as CSV or as Excel (XLSX)
+// workbooks, with the columns a client selects. The XLSX writer produces a
+// single worksheet of plain cells, which is all inventory exports need, so
+// no spreadsheet library is required.
+package export
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Formats
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+)
+
+// Table is the rows of an export, with one value per column
+type Table struct {
+	Columns []string
+	Rows    [][]string
+}
+
+// ContentType returns the MIME type of a format
+func ContentType(format string) string {
+	if format == FormatXLSX {
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// ValidFormat reports whether format is supported
+func ValidFormat(format string) bool {
+	return format == FormatCSV || format == FormatXLSX
+}
+
+// Select narrows a table to the comma-separated columns requested, in their
+// order. An empty selection keeps every column. It returns an error naming
+// the first column the table doesn't have.
+func (t Table) Select(columns string) (Table, error) {
+	if strings.TrimSpace(columns) == "" {
+		return t, nil
+	}
+	index := make(map[string]int, len(t.Columns))
+	for i, column := range t.Columns {
+		index[column] = i
+	}
+
+	var picked []int
+	selected := Table{}
+	for _, column := range strings.Split(columns, ",") {
+		column = strings.TrimSpace(column)
+		if column == "" {
+			continue
+		}
+		i, ok := index[column]
+		if !ok {
+			return Table{}, fmt.Errorf("unknown column %q", column)
+		}
+		picked = append(picked, i)
+		selected.Columns = append(selected.Columns, column)
+	}
+
+	selected.Rows = make([][]string, len(t.Rows))
+	for r, row := range t.Rows {
+		out := make([]string, len(picked))
+		for j, i := range picked {
+			out[j] = row[i]
+		}
+		selected.Rows[r] = out
+	}
+	return selected, nil
+}
+
+// Write writes the table in format, with a header row of its columns. sheet
+// names the XLSX worksheet.
+func (t Table) Write(w io.Writer, format, sheet string) error {
+	switch format {
+	case FormatCSV:
+		return t.writeCSV(w)
+	case FormatXLSX:
+		return t.writeXLSX(w, sheet)
+	default:
+		return fmt.Errorf("unsupported export format %q", format)
+	}
+}
+
+func (t Table) writeCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(t.Columns); err != nil {
+		return err
+	}
+	for _, row := range t.Rows {
+		escaped := make([]string, len(row))
+		for i, value := range row {
+			escaped[i] = escapeFormula(value)
+		}
+		if err := cw.Write(escaped); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// escapeFormula keeps spreadsheet programs opening a CSV from evaluating a
+// value as a formula, by prefixing values that would start one with a quote
+func escapeFormula(value string) string {
+	if value == "" {
+		return value
+	}
+	switch value[0] {
+	case '=', '+', '-', '@', '\t', '\r':
+		if _, err := strconv.ParseFloat(value, 64); err == nil {
+			return value
+		}
+		return "'" + value
+	}
+	return value
+}
+
+// The fixed parts of a single-sheet workbook
+const (
+	xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>
+<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>
+</Types>`
+	xlsxRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>
+</Relationships>`
+	xlsxWorkbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>
+</Relationships>`
+	xlsxWorkbook = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="%s" sheetId="1" r:id="rId1"/></sheets>
+</workbook>`
+)
+
+func (t Table) writeXLSX(w io.Writer, sheet string) error {
+	zw := zip.NewWriter(w)
+	parts := []struct {
+		name    string
+		content string
+	}{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRels},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels},
+		{"xl/workbook.xml", fmt.Sprintf(xlsxWorkbook, xmlEscape(sheetName(sheet)))},
+	}
+	for _, part := range parts {
+		f, err := zw.Create(part.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, part.content); err != nil {
+			return err
+		}
+	}
+
+	f, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(f, `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>`+
+		`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`); err != nil {
+		return err
+	}
+	if err := writeXLSXRow(f, 1, t.Columns, false); err != nil {
+		return err
+	}
+	for i, row := range t.Rows {
+		if err := writeXLSXRow(f, i+2, row, true); err != nil {
+			return err
+		}
+	}
+	if _, err := io.WriteString(f, `</sheetData></worksheet>`); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// writeXLSXRow writes a row of inline string cells, or of number cells for
+// values that are numbers when numbers is set
+func writeXLSXRow(w io.Writer, number int, values []string, numbers bool) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, `<row r="%d">`, number)
+	for i, value := range values {
+		ref := columnName(i) + strconv.Itoa(number)
+		if numbers && isNumber(value) {
+			fmt.Fprintf(&b, `<c r="%s"><v>%s</v></c>`, ref, value)
+			continue
+		}
+		fmt.Fprintf(&b, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, ref, xmlEscape(value))
+	}
+	b.WriteString(`</row>`)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// isNumber reports whether a value is a plain decimal number a spreadsheet
+// would show the same way. Values with leading zeros, such as serial
+// numbers, stay text.
+func isNumber(value string) bool {
+	if value == "" || (len(value) > 1 && value[0] == '0' && value[1] != '.') {
+		return false
+	}
+	for i, r := range value {
+		if (r < '0' || r > '9') && r != '.' && !(r == '-' && i == 0) {
+			return false
+		}
+	}
+	_, err := strconv.ParseFloat(value, 64)
+	return err == nil
+}
+
+// columnName returns the spreadsheet name of a zero-based column: A, B, ...
+// Z, AA, AB and so on
+func columnName(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+// sheetName makes a worksheet name valid: at most 31 characters, without
+// the characters Excel reserves
+func sheetName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	if name == "" {
+		name = "Sheet1"
+	}
+	if len([]rune(name)) > 31 {
+		name = string([]rune(name)[:31])
+	}
+	return name
+}
+
+func xmlEscape(value string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(value))
+	return b.String()
+}
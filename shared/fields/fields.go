@@ -0,0 +1,295 @@
+// Package fields lets clients shape API responses with two query
+// parameters:
+//
+//   - fields selects the fields of the returned objects, as in
+//     ?fields=id,name,team.name. A dotted path selects fields of a nested
+//     object. In a list response, such as {"resources": [...], "total": 3},
+//     the selection applies to each item and the other keys are kept.
+//   - expand selects the associations loaded with the returned objects, as
+//     in ?expand=team. Without it every association an endpoint loads by
+//     default is returned; ?expand= with no value returns none.
+//
+// Fields are selected by Middleware, which rewrites the JSON bodies of
+// successful responses, so every endpoint supports them. Associations are
+// selected by the handlers that load them, through Preload.
+package fields
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Query parameters
+const (
+	FieldsParam = "fields"
+	ExpandParam = "expand"
+)
+
+// Selection is a tree of selected fields. A field selected as a whole maps
+// to nil; a field with selected subfields maps to their selection.
+type Selection map[string]Selection
+
+// Parse reads a comma-separated list of field paths. It returns nil when
+// no field is selected.
+func Parse(list string) Selection {
+	var sel Selection
+	for _, path := range strings.Split(list, ",") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		if sel == nil {
+			sel = Selection{}
+		}
+		node := sel
+		parts := strings.Split(path, ".")
+		for i, part := range parts {
+			sub, seen := node[part]
+			if i == len(parts)-1 {
+				// A whole field wins over a selection of its subfields
+				node[part] = nil
+				break
+			}
+			if seen && sub == nil {
+				break
+			}
+			if sub == nil {
+				sub = Selection{}
+				node[part] = sub
+			}
+			node = sub
+		}
+	}
+	return sel
+}
+
+// Apply prunes a decoded JSON value to the selection. Arrays are pruned
+// item by item.
+func (s Selection) Apply(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		pruned := make(map[string]interface{}, len(s))
+		for key, sub := range s {
+			field, ok := v[key]
+			if !ok {
+				continue
+			}
+			if sub == nil {
+				pruned[key] = field
+			} else {
+				pruned[key] = sub.Apply(field)
+			}
+		}
+		return pruned
+	case []interface{}:
+		for i, item := range v {
+			v[i] = s.Apply(item)
+		}
+		return v
+	default:
+		return value
+	}
+}
+
+// Expanded reports whether the request asks for the association named by
+// its JSON key, such as resource_type. Every association is expanded when
+// the request has no expand parameter.
+func Expanded(c *gin.Context, association string) bool {
+	list, ok := c.GetQuery(ExpandParam)
+	if !ok {
+		return true
+	}
+	for _, name := range strings.Split(list, ",") {
+		if strings.TrimSpace(name) == association {
+			return true
+		}
+	}
+	return false
+}
+
+// Preload preloads the associations of query the request expands, named by
+// their Go field names, such as ResourceType for resource_type
+func Preload(c *gin.Context, query *gorm.DB, associations ...string) *gorm.DB {
+	for _, association := range associations {
+		if Expanded(c, snakeCase(association)) {
+			query = query.Preload(association)
+		}
+	}
+	return query
+}
+
+// Middleware selects the fields of successful JSON responses to requests
+// with a fields parameter
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sel := Parse(c.Query(FieldsParam))
+		if sel == nil {
+			c.Next()
+			return
+		}
+
+		w := &bufferedWriter{ResponseWriter: c.Writer, status: http.StatusOK}
+		c.Writer = w
+		c.Next()
+		c.Writer = w.ResponseWriter
+
+		if w.passthrough {
+			return
+		}
+		if !w.written {
+			w.ResponseWriter.WriteHeader(w.status)
+			return
+		}
+		body := w.body.Bytes()
+		if w.status >= 200 && w.status < 300 && len(body) > 0 &&
+			strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+			if filtered, err := filter(body, sel); err == nil {
+				body = filtered
+			}
+		}
+		w.ResponseWriter.WriteHeader(w.status)
+		w.ResponseWriter.WriteHeaderNow()
+		if len(body) > 0 {
+			w.ResponseWriter.Write(body)
+		}
+	}
+}
+
+// filter applies a selection to a JSON body
+func filter(body []byte, sel Selection) ([]byte, error) {
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return nil, err
+	}
+	if obj, ok := value.(map[string]interface{}); ok {
+		if key := collectionKey(obj); key != "" {
+			obj[key] = sel.Apply(obj[key])
+			return json.Marshal(obj)
+		}
+	}
+	return json.Marshal(sel.Apply(value))
+}
+
+// collectionKey returns the key of the items of a list response: the only
+// array of objects in an object whose other values are scalars, as in
+// {"resources": [...], "total": 3}
+func collectionKey(obj map[string]interface{}) string {
+	key := ""
+	for k, v := range obj {
+		switch v := v.(type) {
+		case []interface{}:
+			if key != "" {
+				return ""
+			}
+			for _, item := range v {
+				if _, ok := item.(map[string]interface{}); !ok {
+					return ""
+				}
+			}
+			key = k
+		case map[string]interface{}:
+			return ""
+		}
+	}
+	return key
+}
+
+// snakeCase converts a Go field name to its JSON key, as ResourceType to
+// resource_type
+func snakeCase(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// bufferedWriter holds a response back until the handler is done, so its
+// fields can be selected. A handler that flushes, such as one streaming
+// events, is written through unfiltered.
+type bufferedWriter struct {
+	gin.ResponseWriter
+	body        bytes.Buffer
+	status      int
+	written     bool
+	passthrough bool
+}
+
+func (w *bufferedWriter) WriteHeader(code int) {
+	if w.passthrough {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if code > 0 && !w.written {
+		w.status = code
+	}
+}
+
+func (w *bufferedWriter) WriteHeaderNow() {
+	if w.passthrough {
+		w.ResponseWriter.WriteHeaderNow()
+		return
+	}
+	w.written = true
+}
+
+func (w *bufferedWriter) Write(data []byte) (int, error) {
+	if w.passthrough {
+		return w.ResponseWriter.Write(data)
+	}
+	w.written = true
+	return w.body.Write(data)
+}
+
+func (w *bufferedWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *bufferedWriter) Status() int {
+	if w.passthrough {
+		return w.ResponseWriter.Status()
+	}
+	return w.status
+}
+
+func (w *bufferedWriter) Written() bool {
+	if w.passthrough {
+		return w.ResponseWriter.Written()
+	}
+	return w.written
+}
+
+func (w *bufferedWriter) Size() int {
+	if w.passthrough {
+		return w.ResponseWriter.Size()
+	}
+	if !w.written {
+		return -1
+	}
+	return w.body.Len()
+}
+
+func (w *bufferedWriter) Flush() {
+	if !w.passthrough {
+		w.passthrough = true
+		w.ResponseWriter.WriteHeader(w.status)
+		w.ResponseWriter.WriteHeaderNow()
+		if w.body.Len() > 0 {
+			w.ResponseWriter.Write(w.body.Bytes())
+			w.body.Reset()
+		}
+	}
+	w.ResponseWriter.Flush()
+}
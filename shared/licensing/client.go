@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"sync"
 	"time"
 )
 
@@ -16,6 +17,13 @@ type Client struct {
 	BaseURL    string
 	ServerID   string
 	HTTPClient *http.Client
+
+	// CacheTTL is how long a successful validation is reused by CachedValidate
+	CacheTTL time.Duration
+
+	cacheMutex       sync.Mutex
+	cachedValidation *ValidationResponse
+	validatedAt      time.Time
 }
 
 // ValidationResponse represents the license validation response
@@ -77,7 +85,18 @@ func NewClient(licenseKey, product string) *Client {
 		Product:    product,
 		BaseURL:    baseURL,
 		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+		CacheTTL:   cacheTTLFromEnv(),
+	}
+}
+
+// cacheTTLFromEnv reads LICENSE_CACHE_TTL, defaulting to 5 minutes
+func cacheTTLFromEnv() time.Duration {
+	if v := os.Getenv("LICENSE_CACHE_TTL"); v != "" {
+		if ttl, err := time.ParseDuration(v); err == nil {
+			return ttl
+		}
 	}
+	return 5 * time.Minute
 }
 
 // NewClientFromEnv creates a new license client from environment variables
@@ -98,21 +117,56 @@ func (c *Client) Validate() (*ValidationResponse, error) {
 
 	resp, err := c.makeRequest("POST", "/api/v2/validate", payload)
 	if err != nil {
+		recordCheck("validate", ResultError)
 		return nil, fmt.Errorf("license validation request failed: %w", err)
 	}
 
 	var validation ValidationResponse
 	if err := json.Unmarshal(resp, &validation); err != nil {
+		recordCheck("validate", ResultError)
 		return nil, fmt.Errorf("failed to parse validation response: %w", err)
 	}
 
 	if validation.Valid {
+		recordCheck("validate", ResultValid)
 		c.ServerID = validation.Metadata.ServerID
+	} else {
+		recordCheck("validate", ResultInvalid)
 	}
 
 	return &validation, nil
 }
 
+// CachedValidate returns the last valid validation response while it is
+// younger than CacheTTL, and validates against the license server otherwise.
+// Failed or invalid validations are never cached.
+func (c *Client) CachedValidate() (*ValidationResponse, error) {
+	c.cacheMutex.Lock()
+	defer c.cacheMutex.Unlock()
+
+	if c.cachedValidation != nil && time.Since(c.validatedAt) < c.CacheTTL {
+		recordCheck("validate", ResultCached)
+		return c.cachedValidation, nil
+	}
+
+	validation, err := c.Validate()
+	if err != nil {
+		return nil, err
+	}
+	if validation.Valid {
+		c.cachedValidation = validation
+		c.validatedAt = time.Now()
+	}
+	return validation, nil
+}
+
+// InvalidateCache discards the cached validation response
+func (c *Client) InvalidateCache() {
+	c.cacheMutex.Lock()
+	c.cachedValidation = nil
+	c.cacheMutex.Unlock()
+}
+
 // CheckFeature checks if a specific feature is enabled
 func (c *Client) CheckFeature(feature string) (bool, error) {
 	payload := map[string]string{
@@ -122,18 +176,22 @@ func (c *Client) CheckFeature(feature string) (bool, error) {
 
 	resp, err := c.makeRequest("POST", "/api/v2/features", payload)
 	if err != nil {
+		recordCheck("feature", ResultError)
 		return false, fmt.Errorf("feature check request failed: %w", err)
 	}
 
 	var response FeatureResponse
 	if err := json.Unmarshal(resp, &response); err != nil {
+		recordCheck("feature", ResultError)
 		return false, fmt.Errorf("failed to parse feature response: %w", err)
 	}
 
-	if len(response.Features) > 0 {
-		return response.Features[0].Entitled, nil
+	if len(response.Features) > 0 && response.Features[0].Entitled {
+		recordCheck("feature", ResultEntitled)
+		return true, nil
 	}
 
+	recordCheck("feature", ResultDenied)
 	return false, nil
 }
 
@@ -147,6 +205,19 @@ func (c *Client) Keepalive(usageData map[string]interface{}) error {
 		}
 	}
 
+	_, err := c.makeRequest("POST", "/api/v2/keepalive", c.KeepalivePayload(usageData))
+	if err != nil {
+		recordCheck("keepalive", ResultError)
+		return fmt.Errorf("keepalive request failed: %w", err)
+	}
+
+	recordCheck("keepalive", ResultSent)
+	return nil
+}
+
+// KeepalivePayload builds the body Keepalive sends for the given usage data,
+// so callers can show exactly what leaves the installation
+func (c *Client) KeepalivePayload(usageData map[string]interface{}) map[string]interface{} {
 	payload := map[string]interface{}{
 		"product":   c.Product,
 		"server_id": c.ServerID,
@@ -157,12 +228,7 @@ func (c *Client) Keepalive(usageData map[string]interface{}) error {
 		payload[key] = value
 	}
 
-	_, err := c.makeRequest("POST", "/api/v2/keepalive", payload)
-	if err != nil {
-		return fmt.Errorf("keepalive request failed: %w", err)
-	}
-
-	return nil
+	return payload
 }
 
 // makeRequest makes an HTTP request to the license server
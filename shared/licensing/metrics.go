@@ -0,0 +1,30 @@
+package licensing
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// Results of a license check, as labelled on ChecksTotal
+const (
+	ResultValid    = "valid"
+	ResultInvalid  = "invalid"
+	ResultCached   = "cached"
+	ResultEntitled = "entitled"
+	ResultDenied   = "denied"
+	ResultSent     = "sent"
+	ResultError    = "error"
+)
+
+// ChecksTotal counts the license checks made by clients, by check (validate,
+// feature or keepalive) and result. It is not registered by this package; a
+// service exporting it registers it with its registry.
+var ChecksTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "nest_license_checks_total",
+		Help: "Total number of license checks, by check and result",
+	},
+	[]string{"check", "result"},
+)
+
+// recordCheck counts a license check
+func recordCheck(check, result string) {
+	ChecksTotal.WithLabelValues(check, result).Inc()
+}
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/penguintechinc/project-template/shared/apierrors"
 )
 
 // FeatureGate manages feature access based on license
@@ -37,12 +38,7 @@ func NewFeatureGate(client *Client) *FeatureGate {
 func (fg *FeatureGate) RequireFeature(featureName string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		if !fg.HasFeature(featureName) {
-			c.JSON(http.StatusForbidden, gin.H{
-				"error":   "feature_not_available",
-				"message": "This feature requires a license upgrade",
-				"feature": featureName,
-			})
-			c.Abort()
+			apierrors.AbortWithDetails(c, http.StatusForbidden, "feature_not_available", "This feature requires a license upgrade", gin.H{"feature": featureName})
 			return
 		}
 		c.Next()
@@ -84,7 +80,7 @@ func (fg *FeatureGate) HasFeature(featureName string) bool {
 
 // refreshFeatures refreshes the features cache
 func (fg *FeatureGate) refreshFeatures() {
-	validation, err := fg.client.Validate()
+	validation, err := fg.client.CachedValidate()
 	if err != nil {
 		log.Printf("Failed to refresh license features: %v", err)
 		return
@@ -142,14 +138,17 @@ func RequireFeatureFunc(fg *FeatureGate, featureName string) func() error {
 	}
 }
 
-// LicenseMiddleware provides license validation middleware
+// LicenseMiddleware provides license validation middleware. A single feature
+// gate is shared by all requests so that validation results are cached
+// rather than fetched from the license server per request.
 func LicenseMiddleware(client *Client) gin.HandlerFunc {
+	fg := NewFeatureGate(client)
+
 	return func(c *gin.Context) {
 		// Add license client to context
 		c.Set("license_client", client)
 
 		// Add feature gate to context
-		fg := NewFeatureGate(client)
 		c.Set("feature_gate", fg)
 
 		c.Next()
@@ -6,6 +6,7 @@ import (
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/penguintechinc/project-template/shared/apierrors"
 )
 
 // UserContext represents the authenticated user in the request context
@@ -26,21 +27,12 @@ func RequireRole(requiredRole string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userCtx, err := GetUserContext(c)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"error":   "unauthorized",
-				"message": "User context not found",
-			})
-			c.Abort()
+			apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "User context not found")
 			return
 		}
 
 		if !HasRole(userCtx.Role, requiredRole) {
-			c.JSON(http.StatusForbidden, gin.H{
-				"error":   "insufficient_permissions",
-				"message": "User does not have required role",
-				"required": requiredRole,
-			})
-			c.Abort()
+			apierrors.AbortWithDetails(c, http.StatusForbidden, "insufficient_permissions", "User does not have required role", gin.H{"required": requiredRole})
 			return
 		}
 
@@ -53,11 +45,7 @@ func RequireTeamRole(requiredRole string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userCtx, err := GetUserContext(c)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"error":   "unauthorized",
-				"message": "User context not found",
-			})
-			c.Abort()
+			apierrors.Abort(c, http.StatusUnauthorized, apierrors.CodeUnauthorized, "User context not found")
 			return
 		}
 
@@ -70,23 +58,14 @@ func RequireTeamRole(requiredRole string) gin.HandlerFunc {
 		teamIDStr := c.Param("id")
 		teamID, err := strconv.ParseUint(teamIDStr, 10, 32)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error":   "invalid_team_id",
-				"message": "Team ID must be a valid number",
-			})
-			c.Abort()
+			apierrors.Abort(c, http.StatusBadRequest, "invalid_team_id", "Team ID must be a valid number")
 			return
 		}
 
 		// Check if user has required role in team
 		hasAccess, err := UserHasTeamRole(c, uint(teamID), userCtx.UserID, requiredRole)
 		if err != nil || !hasAccess {
-			c.JSON(http.StatusForbidden, gin.H{
-				"error":   "insufficient_permissions",
-				"message": "User does not have required role in team",
-				"required": requiredRole,
-			})
-			c.Abort()
+			apierrors.AbortWithDetails(c, http.StatusForbidden, "insufficient_permissions", "User does not have required role in team", gin.H{"required": requiredRole})
 			return
 		}
 
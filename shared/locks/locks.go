@@ -0,0 +1,93 @@
+// Package locks serializes mutations that race across API and controller
+// replicas. Named locks are PostgreSQL advisory locks, so every replica
+// sharing the database contends for the same lock; rows a transaction
+// reads before changing are locked with SELECT ... FOR UPDATE.
+//
+// The controller keeps a copy of this package at pkg/locks, since it is a
+// separate module. The two must name their locks the same way, so a lock
+// taken by the API excludes the controller and the other way round.
+package locks
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// namespace is the first key of every advisory lock taken here, keeping
+// them apart from the single-key locks the audit chain trigger takes
+const namespace = 0x4e455354 // "NEST"
+
+// Key joins parts into a lock name, as in Key("resource-name", teamID,
+// environment, name)
+func Key(parts ...interface{}) string {
+	s := make([]string, len(parts))
+	for i, part := range parts {
+		s[i] = fmt.Sprint(part)
+	}
+	return strings.Join(s, ":")
+}
+
+// Lock takes the named locks for the rest of tx, waiting for other
+// transactions holding them to finish. Locks are taken in sorted order so
+// two transactions locking the same names can't deadlock. It must be called
+// inside a transaction; the locks are released on commit or rollback.
+func Lock(tx *gorm.DB, names ...string) error {
+	sorted := append([]string(nil), names...)
+	sort.Strings(sorted)
+	for i, name := range sorted {
+		if i > 0 && name == sorted[i-1] {
+			continue
+		}
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?, hashtext(?))", namespace, name).Error; err != nil {
+			return fmt.Errorf("failed to lock %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// ForUpdate locks the rows the query reads until the transaction ends, for
+// a status transition that checks a row's state before changing it
+func ForUpdate(tx *gorm.DB) *gorm.DB {
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"})
+}
+
+// TryLock takes the named lock for work that outlives a transaction, such
+// as a reconcile, without waiting. ok is false when another replica holds
+// it. The lock is held on a connection of its own until release is called.
+func TryLock(ctx context.Context, db *gorm.DB, name string) (release func(), ok bool, err error) {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, false, err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get connection: %w", err)
+	}
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1, hashtext($2))", namespace, name).Scan(&ok); err != nil {
+		conn.Close()
+		return nil, false, fmt.Errorf("failed to lock %s: %w", name, err)
+	}
+	if !ok {
+		conn.Close()
+		return nil, false, nil
+	}
+	return func() { unlock(conn, name) }, true, nil
+}
+
+// unlock releases a session lock and returns its connection to the pool.
+// The unlock runs without the caller's context, which may be done by now.
+func unlock(conn *sql.Conn, name string) {
+	defer conn.Close()
+	if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1, hashtext($2))", namespace, name); err != nil {
+		// Closing a connection whose unlock failed would hand the still
+		// held lock back to the pool, so the connection is discarded
+		conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+	}
+}
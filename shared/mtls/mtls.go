@@ -0,0 +1,327 @@
+// Package mtls loads the service identity certificates the controller
+// issues from the internal CA and builds TLS configs that present them and
+// verify peers against the same CA. Identities are reloaded from disk as
+// the controller rotates them, so long-lived listeners and connection
+// pools pick up new certificates without a restart.
+package mtls
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// URIScheme is the scheme of the URI SAN naming a service identity, as in
+// spiffe://nest/nest-api
+const URIScheme = "spiffe"
+
+// InternalTrustDomain is the trust domain of the identities the controller
+// issues from the internal CA
+const InternalTrustDomain = "nest"
+
+// Default locations of an identity mounted from its Kubernetes secret
+const (
+	DefaultDir   = "/etc/nest/mtls"
+	CertFileName = "tls.crt"
+	KeyFileName  = "tls.key"
+	CAFileName   = "ca.crt"
+)
+
+// Identity is a service's certificate and key together with the CA pool
+// its peers are verified against
+type Identity struct {
+	certFile string
+	keyFile  string
+	caFile   string
+
+	// bundleFiles are the CA bundles of external SPIFFE trust domains,
+	// such as one run by SPIRE, keyed by trust domain
+	bundleFiles map[string]string
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	pool      *x509.CertPool
+	bundles   map[string]*x509.CertPool
+	clientCAs *x509.CertPool
+}
+
+// Load reads an identity from PEM files
+func Load(certFile, keyFile, caFile string) (*Identity, error) {
+	id := &Identity{certFile: certFile, keyFile: keyFile, caFile: caFile}
+	if err := id.Reload(); err != nil {
+		return nil, err
+	}
+	return id, nil
+}
+
+// FromEnv loads the identity in MTLS_CERT_DIR when MTLS_ENABLED is true,
+// and returns nil otherwise
+func FromEnv() (*Identity, error) {
+	if os.Getenv("MTLS_ENABLED") != "true" {
+		return nil, nil
+	}
+	dir := os.Getenv("MTLS_CERT_DIR")
+	if dir == "" {
+		dir = DefaultDir
+	}
+	return Load(filepath.Join(dir, CertFileName), filepath.Join(dir, KeyFileName), filepath.Join(dir, CAFileName))
+}
+
+// Reload rereads the identity's files. The previous certificate is kept
+// if they can't be read or don't hold a valid identity.
+func (id *Identity) Reload() error {
+	cert, err := tls.LoadX509KeyPair(id.certFile, id.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load service certificate: %w", err)
+	}
+	caPEM, err := os.ReadFile(id.caFile)
+	if err != nil {
+		return fmt.Errorf("failed to read CA certificate: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return fmt.Errorf("no CA certificates found in %s", id.caFile)
+	}
+	if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+		return fmt.Errorf("failed to parse service certificate: %w", err)
+	}
+	bundles, clientCAs, err := loadBundles(caPEM, id.bundleFiles)
+	if err != nil {
+		return err
+	}
+
+	id.mu.Lock()
+	defer id.mu.Unlock()
+	id.cert = &cert
+	id.pool = pool
+	id.bundles = bundles
+	id.clientCAs = clientCAs
+	return nil
+}
+
+// AddTrustBundle trusts client certificates issued from the PEM bundle of
+// an external SPIFFE trust domain, such as one run by SPIRE. The bundle is
+// reread along with the identity, so a SPIRE agent rotating it in place is
+// picked up by Watch. Bundles must be added before Watch is started.
+func (id *Identity) AddTrustBundle(trustDomain, file string) error {
+	if trustDomain == InternalTrustDomain {
+		return fmt.Errorf("trust domain %s is reserved for internal identities", trustDomain)
+	}
+	if id.bundleFiles == nil {
+		id.bundleFiles = make(map[string]string)
+	}
+	id.bundleFiles[trustDomain] = file
+	return id.Reload()
+}
+
+// loadBundles reads the trust bundle of each external trust domain, and
+// returns them with the pool client certificates are verified against
+// during the handshake: the internal CA together with every bundle
+func loadBundles(caPEM []byte, files map[string]string) (map[string]*x509.CertPool, *x509.CertPool, error) {
+	clientCAs := x509.NewCertPool()
+	clientCAs.AppendCertsFromPEM(caPEM)
+	bundles := make(map[string]*x509.CertPool, len(files))
+	for trustDomain, file := range files {
+		bundlePEM, err := os.ReadFile(file)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read trust bundle of %s: %w", trustDomain, err)
+		}
+		bundle := x509.NewCertPool()
+		if !bundle.AppendCertsFromPEM(bundlePEM) {
+			return nil, nil, fmt.Errorf("no CA certificates found in %s", file)
+		}
+		bundles[trustDomain] = bundle
+		clientCAs.AppendCertsFromPEM(bundlePEM)
+	}
+	return bundles, clientCAs, nil
+}
+
+// Watch reloads the identity on the interval until the context is
+// cancelled. Kubernetes updates mounted secrets in place, so rotated
+// certificates are picked up on the next reload.
+func (id *Identity) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			expires := id.NotAfter()
+			if err := id.Reload(); err != nil {
+				log.Printf("Error reloading service identity: %v", err)
+				continue
+			}
+			if !id.NotAfter().Equal(expires) {
+				log.Printf("Loaded rotated service identity %s, valid until %s", id.Name(), id.NotAfter().Format(time.RFC3339))
+			}
+		}
+	}
+}
+
+// current returns the identity's certificate and CA pool
+func (id *Identity) current() (*tls.Certificate, *x509.CertPool) {
+	id.mu.RLock()
+	defer id.mu.RUnlock()
+	return id.cert, id.pool
+}
+
+// Name returns the service name of the identity's certificate
+func (id *Identity) Name() string {
+	cert, _ := id.current()
+	return PeerName(cert.Leaf)
+}
+
+// NotAfter returns when the identity's certificate expires
+func (id *Identity) NotAfter() time.Time {
+	cert, _ := id.current()
+	return cert.Leaf.NotAfter
+}
+
+// ServerConfig returns a TLS config for listeners that present the
+// identity and verify client certificates against the CA and any trust
+// bundles. Which of them issued a certificate is checked again with
+// VerifyPeer or VerifySVID once the handshake is done. clientAuth is
+// tls.RequireAndVerifyClientCert for internal-only listeners, or
+// tls.VerifyClientCertIfGiven where some routes must stay reachable
+// without a certificate.
+func (id *Identity) ServerConfig(clientAuth tls.ClientAuthType) *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			cert, _ := id.current()
+			return cert, nil
+		},
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			id.mu.RLock()
+			defer id.mu.RUnlock()
+			return &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*id.cert},
+				ClientAuth:   clientAuth,
+				ClientCAs:    id.clientCAs,
+			}, nil
+		},
+	}
+}
+
+// ClientConfig returns a TLS config for connections that present the
+// identity and verify the server's certificate, for serverName, against
+// the CA. The pool is read at each handshake rather than fixed in the
+// config, so a rotated CA applies to new connections.
+func (id *Identity) ClientConfig(serverName string) *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: serverName,
+		// Verification is done in VerifyConnection against the current pool
+		InsecureSkipVerify: true,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			cert, _ := id.current()
+			return cert, nil
+		},
+		VerifyConnection: func(state tls.ConnectionState) error {
+			_, pool := id.current()
+			if len(state.PeerCertificates) == 0 {
+				return errors.New("server presented no certificate")
+			}
+			intermediates := x509.NewCertPool()
+			for _, cert := range state.PeerCertificates[1:] {
+				intermediates.AddCert(cert)
+			}
+			_, err := state.PeerCertificates[0].Verify(x509.VerifyOptions{
+				DNSName:       serverName,
+				Roots:         pool,
+				Intermediates: intermediates,
+				KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+			})
+			return err
+		},
+	}
+}
+
+// PeerName returns the service name of a certificate: the path of its
+// spiffe URI SAN, or its common name when it has none
+func PeerName(cert *x509.Certificate) string {
+	if cert == nil {
+		return ""
+	}
+	if uri := SPIFFEID(cert); uri != nil {
+		return strings.TrimPrefix(uri.Path, "/")
+	}
+	return cert.Subject.CommonName
+}
+
+// VerifyPeer verifies a client certificate chain against the internal CA
+// alone and returns the caller's service name
+func (id *Identity) VerifyPeer(chain []*x509.Certificate) (string, error) {
+	_, pool := id.current()
+	if err := verifyClient(chain, pool); err != nil {
+		return "", err
+	}
+	return PeerName(chain[0]), nil
+}
+
+// VerifySVID verifies a client certificate chain as an X.509-SVID of an
+// external trust domain: its SPIFFE ID must name a trust domain with a
+// bundle, and the chain must have been issued from that bundle alone
+func (id *Identity) VerifySVID(chain []*x509.Certificate) (*url.URL, error) {
+	if len(chain) == 0 {
+		return nil, errors.New("no client certificate")
+	}
+	spiffeID := SPIFFEID(chain[0])
+	if spiffeID == nil {
+		return nil, errors.New("certificate has no SPIFFE ID")
+	}
+
+	id.mu.RLock()
+	bundle := id.bundles[spiffeID.Host]
+	id.mu.RUnlock()
+	if bundle == nil {
+		return nil, fmt.Errorf("trust domain %s is not trusted", spiffeID.Host)
+	}
+	if err := verifyClient(chain, bundle); err != nil {
+		return nil, err
+	}
+	return spiffeID, nil
+}
+
+// verifyClient verifies a client certificate chain against roots
+func verifyClient(chain []*x509.Certificate, roots *x509.CertPool) error {
+	if len(chain) == 0 {
+		return errors.New("no client certificate")
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range chain[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := chain[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	return err
+}
+
+// SPIFFEID returns the spiffe URI SAN of a certificate, or nil when it has
+// none. An X.509-SVID carries exactly one.
+func SPIFFEID(cert *x509.Certificate) *url.URL {
+	if cert == nil {
+		return nil
+	}
+	for _, uri := range cert.URIs {
+		if uri.Scheme == URIScheme {
+			return uri
+		}
+	}
+	return nil
+}
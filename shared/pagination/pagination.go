@@ -0,0 +1,81 @@
+// Package pagination reads the page and page_size parameters of paginated
+// list endpoints and describes the page returned in standard headers:
+// X-Total-Count with the number of items across all pages, and an RFC 8288
+// Link header with the first, prev, next and last pages.
+package pagination
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Query parameters
+const (
+	PageParam     = "page"
+	PageSizeParam = "page_size"
+)
+
+// Headers
+const (
+	TotalCountHeader = "X-Total-Count"
+	LinkHeader       = "Link"
+)
+
+// Parse reads the page and page_size parameters. An invalid page is the
+// first; an invalid page size, or one over maxSize, is defaultSize.
+func Parse(c *gin.Context, defaultSize, maxSize int) (page, pageSize int) {
+	page = 1
+	if p := c.Query(PageParam); p != "" {
+		if parsed, err := strconv.Atoi(p); err == nil && parsed > 0 {
+			page = parsed
+		}
+	}
+
+	pageSize = defaultSize
+	if ps := c.Query(PageSizeParam); ps != "" {
+		if parsed, err := strconv.Atoi(ps); err == nil && parsed > 0 && parsed <= maxSize {
+			pageSize = parsed
+		}
+	}
+	return page, pageSize
+}
+
+// SetHeaders describes the page returned of total items. Links keep the
+// request's other query parameters, so they page through the same filtered
+// list.
+func SetHeaders(c *gin.Context, page, pageSize int, total int64) {
+	c.Header(TotalCountHeader, strconv.FormatInt(total, 10))
+
+	last := int((total + int64(pageSize) - 1) / int64(pageSize))
+	if last < 1 {
+		last = 1
+	}
+	links := []string{link(c, 1, pageSize, "first")}
+	if page > 1 {
+		prev := page - 1
+		if prev > last {
+			prev = last
+		}
+		links = append(links, link(c, prev, pageSize, "prev"))
+	}
+	if page < last {
+		links = append(links, link(c, page+1, pageSize, "next"))
+	}
+	links = append(links, link(c, last, pageSize, "last"))
+	c.Header(LinkHeader, strings.Join(links, ", "))
+}
+
+// link renders a link to a page of the requested list
+func link(c *gin.Context, page, pageSize int, rel string) string {
+	query := url.Values{}
+	for key, values := range c.Request.URL.Query() {
+		query[key] = values
+	}
+	query.Set(PageParam, strconv.Itoa(page))
+	query.Set(PageSizeParam, strconv.Itoa(pageSize))
+	return fmt.Sprintf(`<%s?%s>; rel="%s"`, c.Request.URL.Path, query.Encode(), rel)
+}
@@ -0,0 +1,273 @@
+// Package websecurity holds the middleware that lets browsers use the API
+// safely: CORS for the origins a deployment serves its frontend from, CSRF
+// protection for requests authenticated with a session cookie, and the
+// standard security headers.
+package websecurity
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/penguintechinc/project-template/shared/apierrors"
+)
+
+// CSRF token cookie and header. The cookie is readable by scripts of the
+// frontend's origin, which echo it in the header; other origins can't.
+const (
+	CSRFCookie = "nest_csrf"
+	CSRFHeader = "X-CSRF-Token"
+)
+
+// DefaultSessionCookie is the cookie browser sessions are kept in
+const DefaultSessionCookie = "nest_session"
+
+// DefaultContentSecurityPolicy only allows the API's own origin to supply
+// scripts, styles and other content, and nothing to frame it
+const DefaultContentSecurityPolicy = "default-src 'self'; img-src 'self' data:; object-src 'none'; base-uri 'self'; frame-ancestors 'none'"
+
+// exposedHeaders are the response headers cross-origin scripts may read
+var exposedHeaders = []string{apierrors.RequestIDHeader, "X-Total-Count", "Link", "Warning", "X-Operation-ID", "Location"}
+
+// Config is a deployment's browser security settings
+type Config struct {
+	// AllowedOrigins are the origins, such as https://nest.example.com,
+	// whose scripts may call the API. * allows every origin, without
+	// credentials.
+	AllowedOrigins []string
+	// AllowCredentials lets allowed origins send cookies
+	AllowCredentials bool
+	// SessionCookie is the cookie whose presence makes a request subject
+	// to CSRF checks
+	SessionCookie string
+	// CSRF enables CSRF protection
+	CSRF bool
+	// HSTSMaxAge is how long browsers keep to HTTPS; zero sends no
+	// Strict-Transport-Security header
+	HSTSMaxAge time.Duration
+	// ContentSecurityPolicy is sent with every response
+	ContentSecurityPolicy string
+	// TrustedProxies are the proxies whose X-Forwarded-Proto header is
+	// believed. Requests from other addresses are only HTTPS over TLS.
+	TrustedProxies []*net.IPNet
+}
+
+// ConfigFromEnv reads CORS_ORIGINS, CORS_ALLOW_CREDENTIALS,
+// SESSION_COOKIE_NAME, CSRF_ENABLED, HSTS_MAX_AGE,
+// CONTENT_SECURITY_POLICY and TRUSTED_PROXIES
+func ConfigFromEnv() Config {
+	cfg := Config{
+		AllowCredentials:      os.Getenv("CORS_ALLOW_CREDENTIALS") != "false",
+		SessionCookie:         os.Getenv("SESSION_COOKIE_NAME"),
+		CSRF:                  os.Getenv("CSRF_ENABLED") != "false",
+		HSTSMaxAge:            365 * 24 * time.Hour,
+		ContentSecurityPolicy: os.Getenv("CONTENT_SECURITY_POLICY"),
+	}
+	for _, origin := range strings.Split(os.Getenv("CORS_ORIGINS"), ",") {
+		if origin = strings.TrimRight(strings.TrimSpace(origin), "/"); origin != "" {
+			cfg.AllowedOrigins = append(cfg.AllowedOrigins, origin)
+		}
+	}
+	if cfg.SessionCookie == "" {
+		cfg.SessionCookie = DefaultSessionCookie
+	}
+	if v := os.Getenv("HSTS_MAX_AGE"); v != "" {
+		if maxAge, err := time.ParseDuration(v); err == nil {
+			cfg.HSTSMaxAge = maxAge
+		}
+	}
+	if cfg.ContentSecurityPolicy == "" {
+		cfg.ContentSecurityPolicy = DefaultContentSecurityPolicy
+	}
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			if network := parseProxy(proxy); network != nil {
+				cfg.TrustedProxies = append(cfg.TrustedProxies, network)
+			}
+		}
+	}
+	return cfg
+}
+
+// parseProxy parses a trusted proxy's address or CIDR block, taking an
+// address as a /32 or /128 block
+func parseProxy(proxy string) *net.IPNet {
+	if !strings.Contains(proxy, "/") {
+		ip := net.ParseIP(proxy)
+		if ip == nil {
+			return nil
+		}
+		if v4 := ip.To4(); v4 != nil {
+			return &net.IPNet{IP: v4, Mask: net.CIDRMask(32, 32)}
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}
+	}
+	_, network, err := net.ParseCIDR(proxy)
+	if err != nil {
+		return nil
+	}
+	return network
+}
+
+// secure reports whether a request came over HTTPS, directly or through a
+// trusted proxy that says so
+func (cfg Config) secure(c *gin.Context) bool {
+	if c.Request.TLS != nil {
+		return true
+	}
+	if c.GetHeader("X-Forwarded-Proto") != "https" {
+		return false
+	}
+	ip := net.ParseIP(c.RemoteIP())
+	for _, proxy := range cfg.TrustedProxies {
+		if ip != nil && proxy.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Headers sets the security headers of every response. HSTS is only sent
+// over HTTPS, directly or through a trusted proxy that says so.
+func Headers(cfg Config) gin.HandlerFunc {
+	hsts := ""
+	if cfg.HSTSMaxAge > 0 {
+		hsts = "max-age=" + strconv.Itoa(int(cfg.HSTSMaxAge.Seconds())) + "; includeSubDomains"
+	}
+	return func(c *gin.Context) {
+		header := c.Writer.Header()
+		header.Set("X-Content-Type-Options", "nosniff")
+		header.Set("X-Frame-Options", "DENY")
+		header.Set("Referrer-Policy", "strict-origin-when-cross-origin")
+		if cfg.ContentSecurityPolicy != "" {
+			header.Set("Content-Security-Policy", cfg.ContentSecurityPolicy)
+		}
+		if hsts != "" && cfg.secure(c) {
+			header.Set("Strict-Transport-Security", hsts)
+		}
+		c.Next()
+	}
+}
+
+// CORS lets scripts of the allowed origins call the API. Preflight
+// requests are answered here; a preflight from any other origin is
+// rejected.
+func CORS(cfg Config) gin.HandlerFunc {
+	allowed := make(map[string]bool, len(cfg.AllowedOrigins))
+	anyOrigin := false
+	for _, origin := range cfg.AllowedOrigins {
+		if origin == "*" {
+			anyOrigin = true
+		}
+		allowed[origin] = true
+	}
+	exposed := strings.Join(exposedHeaders, ", ")
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" {
+			c.Next()
+			return
+		}
+		header := c.Writer.Header()
+		header.Add("Vary", "Origin")
+		preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+
+		if !anyOrigin && !allowed[origin] {
+			if preflight {
+				apierrors.Abort(c, http.StatusForbidden, "origin_not_allowed", "Origin not allowed")
+				return
+			}
+			c.Next()
+			return
+		}
+
+		if anyOrigin {
+			header.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			header.Set("Access-Control-Allow-Origin", origin)
+			if cfg.AllowCredentials {
+				header.Set("Access-Control-Allow-Credentials", "true")
+			}
+		}
+		if !preflight {
+			header.Set("Access-Control-Expose-Headers", exposed)
+			c.Next()
+			return
+		}
+
+		header.Set("Access-Control-Allow-Methods", "GET, HEAD, POST, PUT, PATCH, DELETE, OPTIONS")
+		if requested := c.GetHeader("Access-Control-Request-Headers"); requested != "" {
+			header.Set("Access-Control-Allow-Headers", requested)
+		}
+		header.Set("Access-Control-Max-Age", "600")
+		c.AbortWithStatus(http.StatusNoContent)
+	}
+}
+
+// CSRF protects requests authenticated with the session cookie with a
+// double-submit token: requests that change state must echo the CSRF
+// cookie in the X-CSRF-Token header. The cookie is issued on safe requests
+// of a session that lacks one. Requests without the session cookie, such as
+// those with a bearer token, can't be forged by another site and aren't
+// checked.
+func CSRF(cfg Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !cfg.CSRF {
+			c.Next()
+			return
+		}
+		if _, err := c.Cookie(cfg.SessionCookie); err != nil {
+			c.Next()
+			return
+		}
+		token, _ := c.Cookie(CSRFCookie)
+
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			if token == "" {
+				if _, err := IssueCSRFToken(c, cfg); err != nil {
+					log.Printf("Error issuing CSRF token: %v", err)
+					apierrors.Abort(c, http.StatusInternalServerError, apierrors.CodeInternal, "Failed to issue CSRF token")
+					return
+				}
+			}
+			c.Next()
+			return
+		}
+
+		sent := c.GetHeader(CSRFHeader)
+		if token == "" || subtle.ConstantTimeCompare([]byte(sent), []byte(token)) != 1 {
+			apierrors.Abort(c, http.StatusForbidden, "csrf_token_invalid", "Missing or invalid CSRF token")
+			return
+		}
+		c.Next()
+	}
+}
+
+// IssueCSRFToken sets a new CSRF cookie, such as when a session starts,
+// and returns its token. No cookie is set when no random token can be
+// generated.
+func IssueCSRFToken(c *gin.Context, cfg Config) (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(buf)
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     CSRFCookie,
+		Value:    token,
+		Path:     "/",
+		Secure:   cfg.secure(c),
+		SameSite: http.SameSiteStrictMode,
+	})
+	return token, nil
+}
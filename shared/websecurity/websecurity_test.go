@@ -0,0 +1,152 @@
+package websecurity
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func testRouter(cfg Config) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Headers(cfg), CORS(cfg), CSRF(cfg))
+	r.GET("/resources", func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.POST("/resources", func(c *gin.Context) { c.Status(http.StatusCreated) })
+	r.OPTIONS("/resources", func(c *gin.Context) { c.Status(http.StatusOK) })
+	return r
+}
+
+func TestCORSPreflight(t *testing.T) {
+	r := testRouter(Config{AllowedOrigins: []string{"https://nest.example.com"}, AllowCredentials: true})
+
+	tests := []struct {
+		name   string
+		origin string
+		status int
+		allow  string
+	}{
+		{name: "allowed origin", origin: "https://nest.example.com", status: http.StatusNoContent, allow: "https://nest.example.com"},
+		{name: "other origin", origin: "https://evil.example.com", status: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodOptions, "/resources", nil)
+			req.Header.Set("Origin", tt.origin)
+			req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("Expected status %d, got %d", tt.status, w.Code)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.allow {
+				t.Errorf("Expected Access-Control-Allow-Origin %q, got %q", tt.allow, got)
+			}
+		})
+	}
+}
+
+func TestCSRF(t *testing.T) {
+	r := testRouter(Config{SessionCookie: DefaultSessionCookie, CSRF: true})
+
+	tests := []struct {
+		name    string
+		cookies []*http.Cookie
+		header  string
+		bearer  bool
+		status  int
+	}{
+		{
+			name:    "matching token",
+			cookies: []*http.Cookie{{Name: DefaultSessionCookie, Value: "s"}, {Name: CSRFCookie, Value: "token"}},
+			header:  "token",
+			status:  http.StatusCreated,
+		},
+		{
+			name:    "mismatched token",
+			cookies: []*http.Cookie{{Name: DefaultSessionCookie, Value: "s"}, {Name: CSRFCookie, Value: "token"}},
+			header:  "other",
+			status:  http.StatusForbidden,
+		},
+		{
+			name:    "missing token",
+			cookies: []*http.Cookie{{Name: DefaultSessionCookie, Value: "s"}},
+			status:  http.StatusForbidden,
+		},
+		{
+			name:   "bearer without a session cookie",
+			bearer: true,
+			status: http.StatusCreated,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/resources", nil)
+			for _, cookie := range tt.cookies {
+				req.AddCookie(cookie)
+			}
+			if tt.header != "" {
+				req.Header.Set(CSRFHeader, tt.header)
+			}
+			if tt.bearer {
+				req.Header.Set("Authorization", "Bearer token")
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Errorf("Expected status %d, got %d", tt.status, w.Code)
+			}
+		})
+	}
+}
+
+func TestCSRFIssuesToken(t *testing.T) {
+	r := testRouter(Config{SessionCookie: DefaultSessionCookie, CSRF: true})
+
+	req := httptest.NewRequest(http.MethodGet, "/resources", nil)
+	req.AddCookie(&http.Cookie{Name: DefaultSessionCookie, Value: "s"})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == CSRFCookie {
+			if len(cookie.Value) != 64 {
+				t.Errorf("Expected a 32-byte hex token, got %q", cookie.Value)
+			}
+			return
+		}
+	}
+	t.Fatal("Expected a CSRF cookie to be issued")
+}
+
+func TestForwardedProtoTrustedOnlyFromProxies(t *testing.T) {
+	cfg := Config{HSTSMaxAge: time.Hour, TrustedProxies: []*net.IPNet{parseProxy("10.0.0.0/8")}}
+	r := testRouter(cfg)
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		hsts       bool
+	}{
+		{name: "trusted proxy", remoteAddr: "10.1.2.3:40000", hsts: true},
+		{name: "direct client", remoteAddr: "203.0.113.7:40000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/resources", nil)
+			req.RemoteAddr = tt.remoteAddr
+			req.Header.Set("X-Forwarded-Proto", "https")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if got := w.Header().Get("Strict-Transport-Security") != ""; got != tt.hsts {
+				t.Errorf("Expected HSTS %v, got %v", tt.hsts, got)
+			}
+		})
+	}
+}